@@ -59,6 +59,16 @@ func TestFuzzyConversion(t *testing.T) {
 		Hub:    &nextver.VSphereVM{},
 		Spoke:  &VSphereVM{},
 	}))
+	t.Run("for VSphereClusterIdentity", utilconversion.FuzzTestFunc(utilconversion.FuzzTestFuncInput{
+		Scheme: scheme,
+		Hub:    &nextver.VSphereClusterIdentity{},
+		Spoke:  &VSphereClusterIdentity{},
+	}))
+	t.Run("for VSphereDeploymentZone", utilconversion.FuzzTestFunc(utilconversion.FuzzTestFuncInput{
+		Scheme: scheme,
+		Hub:    &nextver.VSphereDeploymentZone{},
+		Spoke:  &VSphereDeploymentZone{},
+	}))
 }
 
 func overrideVSphereClusterDeprecatedFieldsFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
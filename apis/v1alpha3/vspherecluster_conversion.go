@@ -40,6 +40,22 @@ func (src *VSphereCluster) ConvertTo(dstRaw conversion.Hub) error {
 	if restored.Spec.IdentityRef != nil {
 		dst.Spec.IdentityRef = restored.Spec.IdentityRef
 	}
+	dst.Spec.ClusterModules = restored.Spec.ClusterModules
+	dst.Spec.Placement = restored.Spec.Placement
+	dst.Spec.ControlPlaneEndpointProvider = restored.Spec.ControlPlaneEndpointProvider
+	dst.Spec.NetworkSettings = restored.Spec.NetworkSettings
+	dst.Spec.CreationRollback = restored.Spec.CreationRollback
+	dst.Spec.OvercommitPolicy = restored.Spec.OvercommitPolicy
+	dst.Spec.Naming = restored.Spec.Naming
+	dst.Spec.CABundleRef = restored.Spec.CABundleRef
+	dst.Status.V1Beta2 = restored.Status.V1Beta2
+	dst.Status.Placement = restored.Status.Placement
+	dst.Status.ControlPlaneEndpoint = restored.Status.ControlPlaneEndpoint
+	dst.Status.ControlPlaneEndpointFailover = restored.Status.ControlPlaneEndpointFailover
+	dst.Status.FailureReason = restored.Status.FailureReason
+	dst.Status.FailureMessage = restored.Status.FailureMessage
+	dst.Status.CreationRollback = restored.Status.CreationRollback
+	dst.Status.Preflight = restored.Status.Preflight
 	return nil
 }
 
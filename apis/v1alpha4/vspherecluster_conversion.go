@@ -18,6 +18,7 @@ limitations under the License.
 package v1alpha4
 
 import (
+	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
 
 	infrav1beta1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
@@ -26,7 +27,32 @@ import (
 // ConvertTo converts this VSphereCluster to the Hub version (v1beta1).
 func (src *VSphereCluster) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*infrav1beta1.VSphereCluster)
-	return Convert_v1alpha4_VSphereCluster_To_v1beta1_VSphereCluster(src, dst, nil)
+	if err := Convert_v1alpha4_VSphereCluster_To_v1beta1_VSphereCluster(src, dst, nil); err != nil {
+		return err
+	}
+
+	// Manually restore data.
+	restored := &infrav1beta1.VSphereCluster{}
+	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
+		return err
+	}
+	dst.Spec.ClusterModules = restored.Spec.ClusterModules
+	dst.Spec.Placement = restored.Spec.Placement
+	dst.Spec.ControlPlaneEndpointProvider = restored.Spec.ControlPlaneEndpointProvider
+	dst.Spec.NetworkSettings = restored.Spec.NetworkSettings
+	dst.Spec.CreationRollback = restored.Spec.CreationRollback
+	dst.Spec.OvercommitPolicy = restored.Spec.OvercommitPolicy
+	dst.Spec.Naming = restored.Spec.Naming
+	dst.Spec.CABundleRef = restored.Spec.CABundleRef
+	dst.Status.V1Beta2 = restored.Status.V1Beta2
+	dst.Status.Placement = restored.Status.Placement
+	dst.Status.ControlPlaneEndpoint = restored.Status.ControlPlaneEndpoint
+	dst.Status.ControlPlaneEndpointFailover = restored.Status.ControlPlaneEndpointFailover
+	dst.Status.FailureReason = restored.Status.FailureReason
+	dst.Status.FailureMessage = restored.Status.FailureMessage
+	dst.Status.CreationRollback = restored.Status.CreationRollback
+	dst.Status.Preflight = restored.Status.Preflight
+	return nil
 }
 
 // ConvertFrom converts from the Hub version (v1beta1) to this VSphereCluster.
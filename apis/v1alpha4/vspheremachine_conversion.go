@@ -38,7 +38,40 @@ func (src *VSphereMachine) ConvertTo(dstRaw conversion.Hub) error {
 	}
 
 	dst.Spec.AdditionalDisksGiB = restored.Spec.AdditionalDisksGiB
+	dst.Spec.PCIDevices = restored.Spec.PCIDevices
+	dst.Spec.HostnameStrategy = restored.Spec.HostnameStrategy
+	dst.Spec.HostnameDomain = restored.Spec.HostnameDomain
+	dst.Spec.Timeouts = restored.Spec.Timeouts
+	dst.Spec.DataDisks = restored.Spec.DataDisks
+	dst.Spec.PowerOffMode = restored.Spec.PowerOffMode
+	dst.Spec.GuestSoftPowerOffTimeout = restored.Spec.GuestSoftPowerOffTimeout
+	dst.Spec.DiskWipePolicy = restored.Spec.DiskWipePolicy
+	dst.Spec.DRSAutomationLevel = restored.Spec.DRSAutomationLevel
+	dst.Spec.HostAffinity = restored.Spec.HostAffinity
+	dst.Spec.TimeSync = restored.Spec.TimeSync
+	dst.Spec.SecureBoot = restored.Spec.SecureBoot
+	dst.Spec.TPM = restored.Spec.TPM
+	dst.Spec.Encryption = restored.Spec.Encryption
+	dst.Spec.OS = restored.Spec.OS
+	dst.Spec.GuestID = restored.Spec.GuestID
+	dst.Spec.CustomizationSpec = restored.Spec.CustomizationSpec
+	dst.Spec.WindowsActivation = restored.Spec.WindowsActivation
+	dst.Spec.ExistingVM = restored.Spec.ExistingVM
+	dst.Spec.DeletionPolicy = restored.Spec.DeletionPolicy
+	dst.Spec.BootstrapFormat = restored.Spec.BootstrapFormat
+	dst.Spec.FolderRelocationPolicy = restored.Spec.FolderRelocationPolicy
+	dst.Spec.CABundleRef = restored.Spec.CABundleRef
+	dst.Spec.TemplateDatacenter = restored.Spec.TemplateDatacenter
+	dst.Spec.RenamePolicy = restored.Spec.RenamePolicy
+	dst.Spec.AttachedVolumesPolicy = restored.Spec.AttachedVolumesPolicy
+	restoreNetworkDevices(dst.Spec.Network.Devices, restored.Spec.Network.Devices)
+	dst.Spec.Network.NTPServers = restored.Spec.Network.NTPServers
+	dst.Spec.Network.Proxy = restored.Spec.Network.Proxy
+	dst.Spec.Network.PublishedAddresses = restored.Spec.Network.PublishedAddresses
 	dst.Spec.TagIDs = restored.Spec.TagIDs
+	dst.Spec.ImageRef = restored.Spec.ImageRef
+	dst.Status.Topology = restored.Status.Topology
+	dst.Status.V1Beta2 = restored.Status.V1Beta2
 
 	return nil
 }
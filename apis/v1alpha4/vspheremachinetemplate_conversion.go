@@ -41,6 +41,38 @@ func (src *VSphereMachineTemplate) ConvertTo(dstRaw conversion.Hub) error {
 	}
 	dst.Spec.Template.Spec.TagIDs = restored.Spec.Template.Spec.TagIDs
 	dst.Spec.Template.Spec.AdditionalDisksGiB = restored.Spec.Template.Spec.AdditionalDisksGiB
+	dst.Spec.Template.Spec.PCIDevices = restored.Spec.Template.Spec.PCIDevices
+	dst.Spec.Template.Spec.HostnameStrategy = restored.Spec.Template.Spec.HostnameStrategy
+	dst.Spec.Template.Spec.HostnameDomain = restored.Spec.Template.Spec.HostnameDomain
+	dst.Spec.Template.Spec.Timeouts = restored.Spec.Template.Spec.Timeouts
+	dst.Spec.Template.Spec.DataDisks = restored.Spec.Template.Spec.DataDisks
+	dst.Spec.Template.Spec.PowerOffMode = restored.Spec.Template.Spec.PowerOffMode
+	dst.Spec.Template.Spec.GuestSoftPowerOffTimeout = restored.Spec.Template.Spec.GuestSoftPowerOffTimeout
+	dst.Spec.Template.Spec.DiskWipePolicy = restored.Spec.Template.Spec.DiskWipePolicy
+	dst.Spec.Template.Spec.DRSAutomationLevel = restored.Spec.Template.Spec.DRSAutomationLevel
+	dst.Spec.Template.Spec.HostAffinity = restored.Spec.Template.Spec.HostAffinity
+	dst.Spec.Template.Spec.TimeSync = restored.Spec.Template.Spec.TimeSync
+	dst.Spec.Template.Spec.SecureBoot = restored.Spec.Template.Spec.SecureBoot
+	dst.Spec.Template.Spec.TPM = restored.Spec.Template.Spec.TPM
+	dst.Spec.Template.Spec.Encryption = restored.Spec.Template.Spec.Encryption
+	dst.Spec.Template.Spec.OS = restored.Spec.Template.Spec.OS
+	dst.Spec.Template.Spec.GuestID = restored.Spec.Template.Spec.GuestID
+	dst.Spec.Template.Spec.CustomizationSpec = restored.Spec.Template.Spec.CustomizationSpec
+	dst.Spec.Template.Spec.WindowsActivation = restored.Spec.Template.Spec.WindowsActivation
+	dst.Spec.Template.Spec.ExistingVM = restored.Spec.Template.Spec.ExistingVM
+	dst.Spec.Template.Spec.DeletionPolicy = restored.Spec.Template.Spec.DeletionPolicy
+	dst.Spec.Template.Spec.BootstrapFormat = restored.Spec.Template.Spec.BootstrapFormat
+	dst.Spec.Template.Spec.FolderRelocationPolicy = restored.Spec.Template.Spec.FolderRelocationPolicy
+	dst.Spec.Template.Spec.CABundleRef = restored.Spec.Template.Spec.CABundleRef
+	dst.Spec.Template.Spec.TemplateDatacenter = restored.Spec.Template.Spec.TemplateDatacenter
+	dst.Spec.Template.Spec.RenamePolicy = restored.Spec.Template.Spec.RenamePolicy
+	dst.Spec.Template.Spec.AttachedVolumesPolicy = restored.Spec.Template.Spec.AttachedVolumesPolicy
+	dst.Spec.Template.Spec.ImageRef = restored.Spec.Template.Spec.ImageRef
+	restoreNetworkDevices(dst.Spec.Template.Spec.Network.Devices, restored.Spec.Template.Spec.Network.Devices)
+	dst.Spec.Template.Spec.Network.NTPServers = restored.Spec.Template.Spec.Network.NTPServers
+	dst.Spec.Template.Spec.Network.Proxy = restored.Spec.Template.Spec.Network.Proxy
+	dst.Spec.Template.Spec.Network.PublishedAddresses = restored.Spec.Template.Spec.Network.PublishedAddresses
+	dst.Status = restored.Status
 
 	return nil
 }
@@ -18,6 +18,24 @@ package v1beta1
 
 import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 
+// This file is the catalog of the condition types and reasons reported by the
+// controllers of this provider. The values are part of the API: automation may
+// key off them, so they must never be renamed or reused with another meaning;
+// new reasons are added instead and obsolete ones are marked as deprecated.
+//
+// All the condition types have a positive polarity (True means good), with the
+// exception of MachineNeedsRolloutCondition, which is True when an action is
+// required. Every condition is also mirrored, with a non-empty reason, into
+// status.v1beta2.conditions of the object reporting it.
+
+// Reasons used when mirroring the conditions into status.v1beta2.conditions.
+const (
+	// NoReasonReportedReason is the reason of a False or Unknown v1beta2 condition
+	// whose clusterv1.Condition does not report a reason. True conditions without
+	// a reason use the condition type as reason.
+	NoReasonReportedReason = "NoReasonReported"
+)
+
 // Conditions and condition Reasons for the VSphereCluster object.
 
 const (
@@ -34,6 +52,81 @@ const (
 	// Instead of reporting a false ready status, these failure domains are still under the process of reconciling
 	// and hence not yet reporting their status.
 	WaitingForFailureDomainStatusReason = "WaitingForFailureDomainStatus"
+
+	// ClusterPlacementReadyCondition documents the creation of the VM folder and the resource pool
+	// of a VSphereCluster with spec.placement set.
+	ClusterPlacementReadyCondition clusterv1.ConditionType = "ClusterPlacementReady"
+
+	// ClusterPlacementFailedReason (Severity=Warning) documents a controller detecting
+	// issues when creating or updating the VM folder, the resource pool or the permission of a
+	// VSphereCluster.
+	ClusterPlacementFailedReason = "ClusterPlacementFailed"
+
+	// DatastoreCapacityAvailableCondition documents whether the datastores used by the VSphereVMs
+	// of a VSphereCluster can hold the thin-provisioned disks of all their VMs once fully grown.
+	DatastoreCapacityAvailableCondition clusterv1.ConditionType = "DatastoreCapacityAvailable"
+
+	// DatastoreOvercommittedReason (Severity=Warning) documents that the VMs on a datastore used by
+	// the VSphereVMs of a VSphereCluster may use more storage space than the capacity of the datastore.
+	DatastoreOvercommittedReason = "DatastoreOvercommitted"
+
+	// ControlPlaneEndpointReadyCondition documents the provisioning of the control plane endpoint
+	// of a VSphereCluster with spec.controlPlaneEndpointProvider set.
+	ControlPlaneEndpointReadyCondition clusterv1.ConditionType = "ControlPlaneEndpointReady"
+
+	// WaitingForControlPlaneEndpointAddressReason (Severity=Info) documents a VSphereCluster waiting
+	// for the IPAM pool or the load balancer to allocate the address of its control plane endpoint.
+	WaitingForControlPlaneEndpointAddressReason = "WaitingForControlPlaneEndpointAddress"
+
+	// ControlPlaneEndpointProvisioningFailedReason (Severity=Warning) documents a controller detecting
+	// issues when provisioning the control plane endpoint of a VSphereCluster.
+	ControlPlaneEndpointProvisioningFailedReason = "ControlPlaneEndpointProvisioningFailed"
+
+	// ControlPlaneEndpointHealthyCondition documents the health probes of the control plane endpoint
+	// of a VSphereCluster with spec.controlPlaneEndpointProvider.kubeVIP.failover set.
+	ControlPlaneEndpointHealthyCondition clusterv1.ConditionType = "ControlPlaneEndpointHealthy"
+
+	// ControlPlaneEndpointUnreachableReason (Severity=Warning) documents a controller failing to
+	// reach the API servers of a VSphereCluster through the active address of its control plane endpoint.
+	ControlPlaneEndpointUnreachableReason = "ControlPlaneEndpointUnreachable"
+
+	// ControlPlaneEndpointHostnameMismatchReason (Severity=Warning) documents a controller detecting
+	// that the hostname of the control plane endpoint of a VSphereCluster does not resolve to its active address.
+	ControlPlaneEndpointHostnameMismatchReason = "ControlPlaneEndpointHostnameMismatch"
+
+	// ControlPlaneEndpointDNSRecordFailedReason (Severity=Warning) documents a controller failing to
+	// update the DNSEndpoint resolving the hostname of the control plane endpoint of a VSphereCluster.
+	ControlPlaneEndpointDNSRecordFailedReason = "ControlPlaneEndpointDNSRecordFailed"
+
+	// PreflightChecksSucceededCondition documents the preflight checks of the vCenter inventory
+	// referenced by the machines of a VSphereCluster, reported in status.preflight.
+	PreflightChecksSucceededCondition clusterv1.ConditionType = "PreflightChecksSucceeded"
+
+	// PreflightObjectNotFoundReason (Severity=Error) documents a datacenter, datastore, network,
+	// folder, resource pool or template referenced by the machines of a VSphereCluster which does
+	// not exist in vCenter.
+	PreflightObjectNotFoundReason = "PreflightObjectNotFound"
+
+	// PreflightPrivilegesMissingReason (Severity=Error) documents an object of the vCenter inventory
+	// referenced by the machines of a VSphereCluster on which the user of CAPV lacks privileges.
+	PreflightPrivilegesMissingReason = "PreflightPrivilegesMissing"
+
+	// PreflightChecksFailedReason (Severity=Warning) documents preflight checks which could not be
+	// completed, e.g. because vCenter could not be reached.
+	PreflightChecksFailedReason = "PreflightChecksFailed"
+
+	// RequiredPrivilegesGrantedCondition documents whether the user of CAPV holds, on the root folder of
+	// vCenter, the privileges required by the features used by the machines of a VSphereCluster.
+	RequiredPrivilegesGrantedCondition clusterv1.ConditionType = "RequiredPrivilegesGranted"
+
+	// RequiredPrivilegesMissingReason (Severity=Warning) documents a VSphereCluster whose features require
+	// privileges the user of CAPV does not hold on the root folder of vCenter; the privileges may still be
+	// granted by roles assigned on the objects used by the machines.
+	RequiredPrivilegesMissingReason = "RequiredPrivilegesMissing"
+
+	// RequiredPrivilegesCheckFailedReason (Severity=Warning) documents a VSphereCluster whose required
+	// privileges could not be checked, e.g. because vCenter could not be reached.
+	RequiredPrivilegesCheckFailedReason = "RequiredPrivilegesCheckFailed"
 )
 
 // Conditions and condition Reasons for the VSphereMachine and the VSphereVM object.
@@ -69,6 +162,19 @@ const (
 	// are automatically re-tried by the controller.
 	CloningFailedReason = "CloningFailed"
 
+	// OvercommitLimitReachedReason (Severity=Warning) documents a VSphereVM which is not cloned because
+	// none of the hosts of its resource pool can run it within the overcommit policy of its
+	// VSphereCluster; the clone is retried until a host has enough capacity.
+	OvercommitLimitReachedReason = "OvercommitLimitReached"
+
+	// ExistingVMNotFoundReason (Severity=Warning) documents a VSphereVM bound to an existing VM
+	// which is not found; the VM is never cloned, the VSphereVM waits for the VM to be found.
+	ExistingVMNotFoundReason = "ExistingVMNotFound"
+
+	// ExistingVMInUseReason (Severity=Error) documents a VSphereVM bound to an existing VM
+	// which is already bound to another VSphereVM.
+	ExistingVMInUseReason = "ExistingVMInUse"
+
 	// PoweringOnReason documents (Severity=Info) a VSphereMachine/VSphereVM currently executing the power on sequence.
 	PoweringOnReason = "PoweringOn"
 
@@ -77,18 +183,391 @@ const (
 	// are automatically re-tried by the controller.
 	PoweringOnFailedReason = "PoweringOnFailed"
 
-	// TaskFailure (Severity=Warning) documents a VSphereMachine/VSphere task failure; the reconcile look will automatically
+	// CustomizingReason (Severity=Info) documents a VSphereMachine/VSphereVM whose guest is being customized
+	// with the customization spec of the VSphereVM after being powered on.
+	CustomizingReason = "Customizing"
+
+	// GuestCustomizationFailedReason (Severity=Warning) documents a VSphereMachine/VSphereVM whose guest
+	// customization failed, as reported by VMware Tools; the VM is not reported ready until a user
+	// intervention fixes the guest.
+	GuestCustomizationFailedReason = "GuestCustomizationFailed"
+
+	// TaskFailureReason (Severity=Warning) documents a VSphereMachine/VSphere task failure; the reconcile look will automatically
 	// retry the operation, but a user intervention might be required to fix the problem.
-	TaskFailure = "TaskFailure"
+	TaskFailureReason = "TaskFailure"
 
-	// WaitingForNetworkAddressesReason (Severity=Info) documents a VSphereMachine waiting for the the machine network
-	// settings to be reported after machine being powered on.
+	// TaskFailure is the former name of TaskFailureReason.
 	//
-	// NOTE: This reason does not apply to VSphereVM (this state happens after the VSphereVM is in ready state).
+	// Deprecated: use TaskFailureReason.
+	TaskFailure = TaskFailureReason
+
+	// WaitingForNetworkAddressesReason (Severity=Info) documents a VSphereMachine/VSphereVM waiting for the
+	// machine network settings to be reported after machine being powered on.
 	WaitingForNetworkAddressesReason = "WaitingForNetworkAddresses"
 
 	// TagsAttachmentFailedReason (Severity=Error) documents a VSPhereMachine/VSphereVM tags attachment failure.
 	TagsAttachmentFailedReason = "TagsAttachmentFailed"
+
+	// DeletionProtectedReason (Severity=Warning) documents a VSphereMachine/VSphereVM whose deletion is blocked
+	// by the delete-protection annotation; the deletion resumes as soon as the annotation is removed.
+	DeletionProtectedReason = "DeletionProtected"
+
+	// ProvisioningTimeoutReason (Severity=Error) documents a VSphereMachine/VSphereVM whose
+	// provisioning did not complete a phase within its timeout.
+	ProvisioningTimeoutReason = "ProvisioningTimeout"
+
+	// LifecycleHookFailedReason (Severity=Warning) documents a VSphereVM whose lifecycle hook failed;
+	// the operation guarded by the hook is retried until the hook succeeds.
+	LifecycleHookFailedReason = "LifecycleHookFailed"
+
+	// VSphereVMRejectedReason (Severity=Error) documents a VSphereMachine whose VSphereVM is rejected
+	// by validation, e.g. because its placement is not set with the StrictPlacementValidation feature enabled.
+	VSphereVMRejectedReason = "VSphereVMRejected"
+
+	// VMNameInvalidReason (Severity=Error) documents a VSphereMachine whose VM name cannot be generated from the
+	// naming strategy of its VSphereCluster, e.g. because every generated name is already used in vCenter.
+	VMNameInvalidReason = "VMNameInvalid"
+
+	// IPFamilyMismatchReason (Severity=Error) documents a VSphereMachine whose network devices are not
+	// configured with the IP families of the pod and service CIDRs, or of the control plane endpoint, of its
+	// cluster. Its VSphereVM is not created.
+	IPFamilyMismatchReason = "IPFamilyMismatch"
+)
+
+// Conditions and Reasons related to the resolution of the template of a VSphereMachineImage.
+const (
+	// TemplateResolvedCondition documents the resolution of the template of a VSphereMachineImage.
+	TemplateResolvedCondition clusterv1.ConditionType = "TemplateResolved"
+
+	// ImageNotFoundReason (Severity=Warning) documents a VSphereMachineImage whose image is provided
+	// neither by a template nor by its content library, and that has no URL to import it from.
+	ImageNotFoundReason = "ImageNotFound"
+
+	// TemplateResolutionFailedReason (Severity=Warning) documents a controller detecting
+	// issues when looking up, importing or deploying the template of a VSphereMachineImage.
+	TemplateResolutionFailedReason = "TemplateResolutionFailed"
+
+	// ImageVerificationFailedReason (Severity=Error) documents a VSphereMachineImage whose content
+	// does not match its verification, or whose template cannot be verified.
+	ImageVerificationFailedReason = "ImageVerificationFailed"
+
+	// WaitingForImageReason (Severity=Info) documents a VSphereMachine waiting for the
+	// VSphereMachineImage referenced by its imageRef to resolve its template.
+	//
+	// NOTE: This reason does not apply to VSphereVM (this state happens before the VSphereVM is actually created).
+	WaitingForImageReason = "WaitingForImage"
+)
+
+// Conditions and Reasons related to the VMs of a VSphereWarmPool.
+const (
+	// WarmPoolReadyCondition documents whether a VSphereWarmPool holds its desired number of ready VMs.
+	WarmPoolReadyCondition clusterv1.ConditionType = "WarmPoolReady"
+
+	// WarmPoolScalingReason (Severity=Info) documents a VSphereWarmPool cloning or deleting VMs
+	// to reach its desired number of replicas.
+	WarmPoolScalingReason = "Scaling"
+
+	// WaitingForWarmPoolVMsReason (Severity=Info) documents a VSphereWarmPool waiting for its
+	// VMs to be cloned.
+	WaitingForWarmPoolVMsReason = "WaitingForVMs"
+)
+
+// Conditions and Reasons related to the VMs of a VSphereMachinePool.
+const (
+	// MachinePoolReadyCondition documents whether a VSphereMachinePool holds the desired number of ready VMs
+	// of its MachinePool, created from its current template.
+	MachinePoolReadyCondition clusterv1.ConditionType = "MachinePoolReady"
+
+	// MachinePoolScalingReason (Severity=Info) documents a VSphereMachinePool cloning or deleting VMs
+	// to reach the desired number of replicas of its MachinePool.
+	MachinePoolScalingReason = "Scaling"
+
+	// MachinePoolRollingUpdateReason (Severity=Info) documents a VSphereMachinePool replacing the VMs
+	// created from an earlier template or bootstrap data.
+	MachinePoolRollingUpdateReason = "RollingUpdate"
+
+	// WaitingForMachinePoolVMsReason (Severity=Info) documents a VSphereMachinePool waiting for its
+	// VMs to be ready.
+	WaitingForMachinePoolVMsReason = "WaitingForVMs"
+)
+
+// Conditions and Reasons related to the canary of a VSphereMachineTemplateRollout.
+const (
+	// CanaryReadyCondition documents whether the node of the canary Machine of a
+	// VSphereMachineTemplateRollout is healthy.
+	CanaryReadyCondition clusterv1.ConditionType = "CanaryReady"
+
+	// WaitingForCanaryReason (Severity=Info) documents a VSphereMachineTemplateRollout waiting for
+	// the node of its canary Machine to be healthy.
+	WaitingForCanaryReason = "WaitingForCanary"
+
+	// CanaryFailedReason (Severity=Error) documents a VSphereMachineTemplateRollout whose canary
+	// Machine failed, or whose node was not healthy within the timeout of the rollout.
+	CanaryFailedReason = "CanaryFailed"
+
+	// ProbesSucceededCondition documents whether the probe Jobs of a VSphereMachineTemplateRollout
+	// succeeded on the node of its canary Machine.
+	ProbesSucceededCondition clusterv1.ConditionType = "ProbesSucceeded"
+
+	// ProbesRunningReason (Severity=Info) documents a VSphereMachineTemplateRollout waiting for its
+	// probe Jobs to complete.
+	ProbesRunningReason = "ProbesRunning"
+
+	// ProbeFailedReason (Severity=Error) documents a VSphereMachineTemplateRollout whose probe Job
+	// failed, or did not succeed within the timeout of the rollout.
+	ProbeFailedReason = "ProbeFailed"
+)
+
+// Conditions and Reasons related to the in-place update of the resources of a running VM.
+// Used by VSphereVM and VSphereMachine.
+const (
+	// MachineNeedsRolloutCondition is set to True when the numCPUs, memoryMiB or diskGiB of a
+	// VSphereVM have changed but cannot be applied to the running VM, so the machine must be
+	// replaced for the change to take effect. The condition is removed once the VM matches its spec.
+	MachineNeedsRolloutCondition clusterv1.ConditionType = "MachineNeedsRollout"
+
+	// ResourcesNotHotPluggableReason documents a VSphereVM whose resource changes cannot be
+	// hot-added to the running VM.
+	ResourcesNotHotPluggableReason = "ResourcesNotHotPluggable"
+)
+
+// Conditions and Reasons related to the guest shutdown of a VM before it is destroyed.
+// Used by VSphereVM.
+const (
+	// GuestSoftPowerOffSucceededCondition documents the shutdown of the guest OS of a VSphereVM
+	// whose powerOffMode is soft or trySoft. The condition is set to True once the VM is
+	// powered off.
+	GuestSoftPowerOffSucceededCondition clusterv1.ConditionType = "GuestSoftPowerOffSucceeded"
+
+	// GuestSoftPowerOffInProgressReason (Severity=Info) documents a VSphereVM waiting for its
+	// guest OS to shut down.
+	GuestSoftPowerOffInProgressReason = "GuestSoftPowerOffInProgress"
+
+	// GuestSoftPowerOffFailedReason (Severity=Warning) documents a VSphereVM whose guest OS
+	// could not be shut down, either because VMware Tools is not running or because the
+	// shutdown exceeded its timeout.
+	GuestSoftPowerOffFailedReason = "GuestSoftPowerOffFailed"
+)
+
+// Conditions and Reasons related to the wipe of the disks of a VM before it is destroyed.
+// Used by VSphereVM.
+const (
+	// DisksWipedCondition documents the wipe of the disks of a VSphereVM whose diskWipePolicy
+	// is zero or cryptoErase. The condition is set to True once every disk is wiped, and the
+	// VM can be destroyed.
+	DisksWipedCondition clusterv1.ConditionType = "DisksWiped"
+
+	// DiskWipeInProgressReason (Severity=Info) documents a VSphereVM waiting for a disk of its
+	// VM to be wiped.
+	DiskWipeInProgressReason = "DiskWipeInProgress"
+
+	// DiskWipeFailedReason (Severity=Warning) documents a VSphereVM whose disk could not be
+	// wiped. The wipe of the disk is retried, and the VM is not destroyed until it succeeds.
+	DiskWipeFailedReason = "DiskWipeFailed"
+)
+
+// Conditions and Reasons related to the persistent volumes attached to a VM before it is destroyed.
+// Used by VSphereVM.
+const (
+	// VolumesDetachedCondition documents the persistent volumes attached to a VSphereVM whose
+	// attachedVolumesPolicy is Block or Detach. The condition is set to True once no volume is
+	// attached, and the VM can be destroyed.
+	VolumesDetachedCondition clusterv1.ConditionType = "VolumesDetached"
+
+	// VolumesAttachedReason (Severity=Warning) documents a VSphereVM whose deletion is blocked
+	// by the volumes attached to its VM, listed in the message of the condition.
+	VolumesAttachedReason = "VolumesAttached"
+
+	// VolumeDetachInProgressReason (Severity=Info) documents a VSphereVM waiting for the
+	// volumes of its VM to be detached.
+	VolumeDetachInProgressReason = "VolumeDetachInProgress"
+
+	// VolumeDetachFailedReason (Severity=Warning) documents a VSphereVM whose volumes could not
+	// be detached. The detach is retried, and the VM is not destroyed until it succeeds.
+	VolumeDetachFailedReason = "VolumeDetachFailed"
+)
+
+// Conditions and Reasons related to the snapshots of a VM, which block its reconfigurations
+// and migrations. Used by VSphereVM.
+const (
+	// SnapshotsHealthyCondition documents whether the disks of the VM of a VSphereVM need a
+	// consolidation, or run on a chain of snapshots longer than the snapshot chain limit of the
+	// manager. While the condition is False, the VM is neither reconfigured nor relocated.
+	SnapshotsHealthyCondition clusterv1.ConditionType = "SnapshotsHealthy"
+
+	// ConsolidationNeededReason (Severity=Warning) documents a VSphereVM whose disks need a
+	// consolidation, e.g. after the removal of a snapshot failed.
+	ConsolidationNeededReason = "ConsolidationNeeded"
+
+	// ConsolidationInProgressReason (Severity=Info) documents a VSphereVM waiting for the disks
+	// of its VM to be consolidated.
+	ConsolidationInProgressReason = "ConsolidationInProgress"
+
+	// ConsolidationFailedReason (Severity=Warning) documents a VSphereVM whose disks could not be
+	// consolidated. The consolidation is retried.
+	ConsolidationFailedReason = "ConsolidationFailed"
+
+	// SnapshotChainTooLongReason (Severity=Warning) documents a VSphereVM whose VM runs on a
+	// chain of snapshots longer than the snapshot chain limit of the manager.
+	SnapshotChainTooLongReason = "SnapshotChainTooLong"
+)
+
+// Conditions and Reasons related to the drift of the configuration of a VM, checked
+// with the drift check interval of the manager. Used by VSphereVM.
+const (
+	// ConfigurationInSyncCondition documents whether the configuration of the VM of a
+	// VSphereVM, as last checked, matches the VSphereVM.
+	ConfigurationInSyncCondition clusterv1.ConditionType = "ConfigurationInSync"
+
+	// ConfigurationDriftedReason (Severity=Warning) documents a VSphereVM whose VM was
+	// reconfigured, moved or resized in vCenter since it was cloned, e.g. by an administrator.
+	ConfigurationDriftedReason = "ConfigurationDrifted"
+
+	// DriftCorrectionBlockedReason (Severity=Warning) documents a VSphereVM whose drift is
+	// not corrected, e.g. because the snapshots of its VM block its migration back into its
+	// resource pool. The correction is attempted again on the next drift check.
+	DriftCorrectionBlockedReason = "DriftCorrectionBlocked"
+)
+
+// Conditions and Reasons related to the encryption of a VM. Used by VSphereVM and
+// VSphereMachine.
+const (
+	// EncryptionReadyCondition documents whether the VM of a VSphereVM is encrypted. It is only
+	// reported when the encryption of the VSphereVM is set.
+	EncryptionReadyCondition clusterv1.ConditionType = "EncryptionReady"
+
+	// EncryptionNotSupportedReason (Severity=Error) documents a VSphereVM whose VM cannot be
+	// cloned encrypted, e.g. because its storage policy does not enable the VM encryption, or
+	// because its key provider cannot generate a key. The VM is not cloned.
+	EncryptionNotSupportedReason = "EncryptionNotSupported"
+
+	// VMNotEncryptedReason (Severity=Error) documents a VSphereVM whose VM is not encrypted,
+	// e.g. an existing VM, or a VM decrypted in vCenter since it was cloned.
+	VMNotEncryptedReason = "VMNotEncrypted"
+)
+
+// Conditions and Reasons related to the clock of the guest of a VM.
+// Used by VSphereVM.
+const (
+	// ClockSynchronizedCondition documents whether the skew of the clock of the guest of a
+	// VSphereVM from the clock of its ESXi host, reported by the guest, is within the maxClockSkew
+	// of its timeSync. It is only reported when maxClockSkew is set, and is not part of the Ready
+	// summary of the VSphereVM.
+	ClockSynchronizedCondition clusterv1.ConditionType = "ClockSynchronized"
+
+	// WaitingForClockReportReason (Severity=Info) documents a VSphereVM whose guest has not
+	// reported the skew of its clock yet.
+	WaitingForClockReportReason = "WaitingForClockReport"
+
+	// ClockSkewedReason (Severity=Warning) documents a VSphereVM whose guest clock is ahead of or
+	// behind the clock of its ESXi host by more than the maxClockSkew of its timeSync.
+	ClockSkewedReason = "ClockSkewed"
+)
+
+// Conditions and Reasons related to the activation of the Windows guest of a VM.
+// Used by VSphereVM and VSphereMachine.
+const (
+	// WindowsActivatedCondition documents whether the Windows guest of a VSphereVM reports an
+	// activated license. It is only reported when the windowsActivation of the VSphereVM is set.
+	WindowsActivatedCondition clusterv1.ConditionType = "WindowsActivated"
+
+	// WaitingForActivationReportReason (Severity=Info) documents a VSphereVM whose guest has not
+	// reported the status of its activation yet.
+	WaitingForActivationReportReason = "WaitingForActivationReport"
+
+	// WindowsGracePeriodReason (Severity=Warning) documents a VSphereVM whose guest is not activated
+	// and runs in an activation grace period, after which the functionality of Windows is reduced.
+	WindowsGracePeriodReason = "WindowsGracePeriod"
+
+	// WindowsNotActivatedReason (Severity=Error) documents a VSphereVM whose guest is not activated,
+	// and whose activation grace period, if any, ended.
+	WindowsNotActivatedReason = "WindowsNotActivated"
+)
+
+// Conditions and Reasons related to the health of a VM reported by vSphere.
+// Used by VSphereVM and VSphereMachine.
+const (
+	// GuestHeartbeatHealthyCondition documents whether VMware Tools runs in the guest of a ready
+	// VSphereVM and reports a green heartbeat.
+	GuestHeartbeatHealthyCondition clusterv1.ConditionType = "GuestHeartbeatHealthy"
+
+	// GuestToolsNotRunningReason (Severity=Warning) documents a VSphereVM whose guest does not run
+	// VMware Tools.
+	GuestToolsNotRunningReason = "GuestToolsNotRunning"
+
+	// GuestHeartbeatUnhealthyReason (Severity=Warning) documents a VSphereVM whose guest reports a
+	// yellow or red heartbeat.
+	GuestHeartbeatUnhealthyReason = "GuestHeartbeatUnhealthy"
+
+	// VMRunningCondition documents whether the VM of a ready VSphereVM runs without interruption.
+	// It is False after the VM is powered off outside of Kubernetes or restarted by vSphere HA,
+	// until its guest reports a green heartbeat again.
+	VMRunningCondition clusterv1.ConditionType = "VMRunning"
+
+	// PoweredOffExternallyReason (Severity=Warning) documents a ready VSphereVM whose VM was
+	// found powered off, and is powered on again.
+	PoweredOffExternallyReason = "PoweredOffExternally"
+
+	// RestartedByHAReason (Severity=Warning) documents a VSphereVM whose VM was restarted by
+	// vSphere HA, on another host after the failure of its host, or after its guest stopped
+	// reporting a heartbeat.
+	RestartedByHAReason = "RestartedByHA"
+)
+
+// Conditions and Reasons related to the bootstrap of the guest of a machine, which
+// completes when the node of the machine joins the cluster. Used by VSphereMachine.
+const (
+	// GuestBootstrappedCondition documents whether the node of the Machine of a VSphereMachine joined
+	// the cluster. It is reported once the VM of the VSphereMachine is provisioned, and is not part of
+	// the Ready condition of the VSphereMachine, which the bootstrap of the node waits for. It is False
+	// with the ProvisioningTimeoutReason when the node did not join within its bootstrap join timeout.
+	GuestBootstrappedCondition clusterv1.ConditionType = "GuestBootstrapped"
+
+	// WaitingForGuestBootstrapReason (Severity=Info) documents a VSphereMachine whose VM is
+	// provisioned, waiting for the node of its Machine to join the cluster.
+	WaitingForGuestBootstrapReason = "WaitingForGuestBootstrap"
+)
+
+// Conditions and Reasons related to the vCenter cluster modules used to enforce
+// anti-affinity between the VMs of a cluster. Used by VSphereCluster.
+const (
+	// ClusterModulesAvailableCondition documents the availability of the cluster modules
+	// for the control plane and the MachineDeployments of a VSphereCluster.
+	ClusterModulesAvailableCondition clusterv1.ConditionType = "ClusterModulesAvailable"
+
+	// ClusterModuleSetupFailedReason (Severity=Warning) documents a controller detecting
+	// issues when setting up the cluster modules for the VSphereCluster.
+	ClusterModuleSetupFailedReason = "ClusterModuleSetupFailed"
+)
+
+// Conditions and Reasons related to the static IP addresses of a VSphereVM: claiming them from
+// IPAM pools and detecting their conflicts. Used by VSphereVM.
+const (
+	// IPAddressClaimedCondition documents the status of claiming the IP addresses of the network
+	// devices of a VSphereVM from the IPAM pools referenced in their addressesFromPools.
+	IPAddressClaimedCondition clusterv1.ConditionType = "IPAddressClaimed"
+
+	// WaitingForIPAddressReason (Severity=Info) documents a VSphereVM waiting for its IPAddressClaims
+	// to be bound to IP addresses.
+	WaitingForIPAddressReason = "WaitingForIPAddress"
+
+	// IPAddressClaimFailedReason (Severity=Warning) documents a controller detecting
+	// issues when claiming IP addresses for a VSphereVM.
+	IPAddressClaimFailedReason = "IPAddressClaimFailed"
+
+	// IPAddressPoolNotFoundReason (Severity=Warning) documents a VSphereVM whose IPAddressClaims are not
+	// bound because an IPAM pool referenced by its network devices does not exist in its namespace.
+	IPAddressPoolNotFoundReason = "IPAddressPoolNotFound"
+
+	// IPAddressUniqueCondition documents whether the static IP addresses of a VSphereVM, set in its
+	// spec or claimed from IPAM pools, are not in use by another machine when the VM is cloned. It is
+	// only reported with the IPConflictDetection feature gate.
+	IPAddressUniqueCondition clusterv1.ConditionType = "IPAddressUnique"
+
+	// IPAddressConflictReason (Severity=Warning) documents a VSphereVM which is not cloned because
+	// one of its static IP addresses is reported by vCenter for another VM or host, or answers ICMP
+	// echo requests.
+	IPAddressConflictReason = "IPAddressConflict"
 )
 
 // Conditions and Reasons related to utilizing a VSphereIdentity to make connections to a VCenter.
@@ -101,12 +580,21 @@ const (
 	// VCenterUnreachableReason (Severity=Error) documents a controller detecting
 	// issues with VCenter reachability.
 	VCenterUnreachableReason = "VCenterUnreachable"
+
+	// VCenterCredentialsInvalidReason (Severity=Error) documents a controller
+	// whose credentials are rejected by VCenter, e.g. after they were rotated.
+	VCenterCredentialsInvalidReason = "VCenterCredentialsInvalid"
 )
 
 const (
-	// CredentialsAvailableCondidtion is used by VSphereClusterIdentity when a credential
+	// CredentialsAvailableCondition is used by VSphereClusterIdentity when a credential
 	// secret is available and unused by other VSphereClusterIdentities.
-	CredentialsAvailableCondidtion clusterv1.ConditionType = "CredentialsAvailable"
+	CredentialsAvailableCondition clusterv1.ConditionType = "CredentialsAvailable"
+
+	// CredentialsAvailableCondidtion is the misspelled former name of CredentialsAvailableCondition.
+	//
+	// Deprecated: use CredentialsAvailableCondition.
+	CredentialsAvailableCondidtion = CredentialsAvailableCondition
 
 	// SecretNotAvailableReason is used when the secret referenced by the VSphereClusterIdentity cannot be found.
 	SecretNotAvailableReason = "SecretNotAvailable"
@@ -162,3 +650,17 @@ const (
 	// associated to the VSphereDeploymentZone is misconfigured.
 	DatastoreNotFoundReason = "DatastoreNotFound"
 )
+
+// Conditions and Reasons related to the usage of a VSphereResourceQuota.
+const (
+	// ResourceQuotaSatisfiedCondition documents whether the resources requested by the VSphereMachines
+	// of the namespace of a VSphereResourceQuota are within its hard limits.
+	ResourceQuotaSatisfiedCondition clusterv1.ConditionType = "ResourceQuotaSatisfied"
+
+	// ResourceQuotaExceededReason (Severity=Warning) documents a VSphereResourceQuota whose hard limits
+	// are exceeded by the VSphereMachines of its namespace, e.g. after the limits were lowered. The
+	// existing machines are kept, but no machine can be created until enough machines are deleted.
+	// It also documents a VSphereVM whose VMProvisionedCondition is false because its clone would exceed
+	// a VSphereResourceQuota; the clone is retried until enough resources are released.
+	ResourceQuotaExceededReason = "ResourceQuotaExceeded"
+)
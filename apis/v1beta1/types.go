@@ -20,6 +20,8 @@ package v1beta1
 import (
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
@@ -32,11 +34,22 @@ const (
 	// ready.
 	AnnotationControlPlaneReady = "vsphere.infrastructure.cluster.x-k8s.io/control-plane-ready"
 
+	// AnnotationDeleteProtection blocks the deletion of a VSphereMachine or
+	// VSphereVM, and of the underlying virtual machine, for as long as it is
+	// set on the object.
+	AnnotationDeleteProtection = "vsphere.infrastructure.x-k8s.io/delete-protection"
+
+	// AnnotationSkipAntiAffinity opts a KubeadmControlPlane or a
+	// MachineDeployment out of the vCenter cluster module used to spread its
+	// VMs across ESXi hosts.
+	AnnotationSkipAntiAffinity = "vsphere.infrastructure.cluster.x-k8s.io/skip-anti-affinity"
+
 	// ValueReady is the ready value for *Ready annotations.
 	ValueReady = "true"
 )
 
 // CloneMode is the type of clone operation used to clone a VM from a template.
+// +kubebuilder:validation:Enum=fullClone;linkedClone
 type CloneMode string
 
 const (
@@ -52,17 +65,264 @@ const (
 	LinkedClone CloneMode = "linkedClone"
 )
 
+// HostnameStrategy is the source of the guest hostname of a virtual machine.
+// +kubebuilder:validation:Enum=machineName;vmName;fqdn
+type HostnameStrategy string
+
+const (
+	// MachineNameHostnameStrategy uses the name of the CAPI Machine as the
+	// guest hostname. This is the default.
+	MachineNameHostnameStrategy HostnameStrategy = "machineName"
+
+	// VMNameHostnameStrategy uses the name of the virtual machine in vSphere
+	// as the guest hostname.
+	VMNameHostnameStrategy HostnameStrategy = "vmName"
+
+	// FQDNHostnameStrategy uses the name of the CAPI Machine followed by the
+	// HostnameDomain as the guest hostname.
+	FQDNHostnameStrategy HostnameStrategy = "fqdn"
+)
+
+// VirtualMachinePowerOffMode is the mode of the power off of a virtual
+// machine before it is destroyed.
+// +kubebuilder:validation:Enum=hard;soft;trySoft
+type VirtualMachinePowerOffMode string
+
+const (
+	// HardPowerOffMode powers off the virtual machine without shutting down
+	// its guest OS. This is the default.
+	HardPowerOffMode VirtualMachinePowerOffMode = "hard"
+
+	// SoftPowerOffMode shuts down the guest OS through VMware Tools, and
+	// waits for the virtual machine to be powered off.
+	SoftPowerOffMode VirtualMachinePowerOffMode = "soft"
+
+	// TrySoftPowerOffMode shuts down the guest OS through VMware Tools, and
+	// powers off the virtual machine when VMware Tools is not running or the
+	// guest OS is not shut down within the GuestSoftPowerOffTimeout.
+	TrySoftPowerOffMode VirtualMachinePowerOffMode = "trySoft"
+)
+
+// DiskWipePolicy is the policy of the wipe of the disks of a virtual machine
+// before it is destroyed.
+// +kubebuilder:validation:Enum=none;zero;cryptoErase
+type DiskWipePolicy string
+
+const (
+	// NoneDiskWipePolicy destroys the virtual machine without wiping its
+	// disks. This is the default.
+	NoneDiskWipePolicy DiskWipePolicy = "none"
+
+	// ZeroDiskWipePolicy overwrites every disk of the virtual machine with
+	// zeros before it is destroyed.
+	ZeroDiskWipePolicy DiskWipePolicy = "zero"
+
+	// CryptoEraseDiskWipePolicy relies on the encryption of the disks of the
+	// virtual machine, whose data cannot be read once their key is removed
+	// from its key provider before the virtual machine is destroyed. Only
+	// the key generated for the virtual machine with the keyProviderID of its
+	// encryption is removed. The disks which are not encrypted with it are
+	// overwritten with zeros before it is destroyed.
+	CryptoEraseDiskWipePolicy DiskWipePolicy = "cryptoErase"
+)
+
+// AttachedVolumesPolicy is the policy applied to the persistent volumes of
+// Kubernetes, managed by Cloud Native Storage, which are still attached to a
+// virtual machine when it is deleted.
+// +kubebuilder:validation:Enum=Ignore;Block;Detach
+type AttachedVolumesPolicy string
+
+const (
+	// IgnoreAttachedVolumesPolicy destroys the virtual machine regardless of
+	// its attached volumes. This is the default.
+	IgnoreAttachedVolumesPolicy AttachedVolumesPolicy = "Ignore"
+
+	// BlockAttachedVolumesPolicy keeps the virtual machine until its volumes
+	// are detached, e.g. by the CSI driver once its node is drained.
+	BlockAttachedVolumesPolicy AttachedVolumesPolicy = "Block"
+
+	// DetachAttachedVolumesPolicy detaches the volumes of the virtual machine
+	// with Cloud Native Storage before it is destroyed.
+	DetachAttachedVolumesPolicy AttachedVolumesPolicy = "Detach"
+)
+
+// DRSAutomationLevel is the DRS automation level of a virtual machine.
+// +kubebuilder:validation:Enum=FullyAutomated;PartiallyAutomated;Manual;Disabled
+type DRSAutomationLevel string
+
+const (
+	// FullyAutomatedDRSAutomationLevel lets DRS place the virtual machine on
+	// power on and migrate it to balance the load of the compute cluster.
+	FullyAutomatedDRSAutomationLevel DRSAutomationLevel = "FullyAutomated"
+
+	// PartiallyAutomatedDRSAutomationLevel lets DRS place the virtual machine
+	// on power on, and only recommends its migrations.
+	PartiallyAutomatedDRSAutomationLevel DRSAutomationLevel = "PartiallyAutomated"
+
+	// ManualDRSAutomationLevel only lets DRS recommend the placement and the
+	// migrations of the virtual machine.
+	ManualDRSAutomationLevel DRSAutomationLevel = "Manual"
+
+	// DisabledDRSAutomationLevel excludes the virtual machine from DRS, which
+	// neither places nor migrates it.
+	DisabledDRSAutomationLevel DRSAutomationLevel = "Disabled"
+)
+
+// HostAffinitySpec pins a virtual machine to the hosts of a host group of its
+// compute cluster with a VM-Host affinity rule. Exactly one of HostGroupName
+// and RuleName must be set.
+type HostAffinitySpec struct {
+	// HostGroupName is the name of a host group of the compute cluster of the
+	// virtual machine. The virtual machine is added to the VM group
+	// "<hostGroupName>-capv", which a mandatory VM-Host affinity rule of the
+	// same name binds to the host group. The VM group and the rule are
+	// created when missing.
+	// +optional
+	HostGroupName string `json:"hostGroupName,omitempty"`
+
+	// RuleName is the name of an existing VM-Host affinity rule of the
+	// compute cluster of the virtual machine, whose VM group the virtual
+	// machine is added to.
+	// +optional
+	RuleName string `json:"ruleName,omitempty"`
+}
+
+// EncryptionSpec configures the encryption of a virtual machine and of its
+// disks at rest, which is applied by the storage policy of the virtual machine
+// when it is cloned.
+type EncryptionSpec struct {
+	// KeyProviderID is the ID of the key provider of vCenter, e.g. a KMS
+	// cluster or a native key provider, generating the key of the virtual
+	// machine. vCenter uses its default key provider when empty.
+	// +optional
+	KeyProviderID string `json:"keyProviderID,omitempty"`
+}
+
+// TimeSyncSpec configures the synchronization and the monitoring of the guest
+// clock of a virtual machine.
+type TimeSyncSpec struct {
+	// SyncWithHost enables the periodic synchronization of the guest clock
+	// with the clock of the ESXi host of the virtual machine by VMware Tools.
+	// The guest clock is only synchronized at boot and resume when false.
+	// +optional
+	SyncWithHost bool `json:"syncWithHost,omitempty"`
+
+	// MaxClockSkew is the maximum skew of the guest clock from the clock of
+	// the ESXi host of the virtual machine, reported by the guest through
+	// VMware Tools. The ClockSynchronized condition of the VSphereVM is false
+	// when the skew exceeds it. The skew is not monitored when empty.
+	// +optional
+	MaxClockSkew *metav1.Duration `json:"maxClockSkew,omitempty"`
+}
+
+// WindowsActivationSpec configures the activation of the Windows guest of a
+// virtual machine. The commands activating the guest and reporting its
+// activation status are run at the first logon after the customization, so
+// the sysprep customization specification must enable the autologon.
+type WindowsActivationSpec struct {
+	// ProductKeySecretName is the name of a Secret, in the namespace of the
+	// machine, whose productKey key holds the product key of the guest, e.g.
+	// a KMS client setup key. The product key of the customization
+	// specification is kept when empty.
+	// +optional
+	ProductKeySecretName string `json:"productKeySecretName,omitempty"`
+
+	// KMSServer is the address of the KMS host the guest activates with,
+	// either a hostname or an IP address, optionally followed by a port.
+	// The guest discovers the KMS host from the DNS when empty.
+	// +optional
+	KMSServer string `json:"kmsServer,omitempty"`
+}
+
+// OS is the family of the guest operating system of a virtual machine.
+// +kubebuilder:validation:Enum=Linux;Windows
+type OS string
+
+const (
+	// LinuxOS is a Linux guest, bootstrapped with cloud-init. This is the
+	// default.
+	LinuxOS OS = "Linux"
+
+	// WindowsOS is a Windows guest, bootstrapped with Cloudbase-Init.
+	WindowsOS OS = "Windows"
+)
+
+// BootstrapFormat is the format of the bootstrap data of a virtual machine.
+// +kubebuilder:validation:Enum=cloud-config;ignition
+type BootstrapFormat string
+
+const (
+	// CloudConfigBootstrapFormat is bootstrap data read by cloud-init, or by
+	// Cloudbase-Init on Windows. This is the default.
+	CloudConfigBootstrapFormat BootstrapFormat = "cloud-config"
+
+	// IgnitionBootstrapFormat is an Ignition config, read by the Flatcar
+	// Container Linux and Fedora CoreOS images.
+	IgnitionBootstrapFormat BootstrapFormat = "ignition"
+)
+
+// FolderRelocationPolicy is the policy applied to a virtual machine moved out
+// of its folder in vCenter.
+// +kubebuilder:validation:Enum=Accept;Restore
+type FolderRelocationPolicy string
+
+const (
+	// AcceptFolderRelocationPolicy keeps the virtual machine in the folder it
+	// was moved into. This is the default.
+	AcceptFolderRelocationPolicy FolderRelocationPolicy = "Accept"
+
+	// RestoreFolderRelocationPolicy moves the virtual machine back into its
+	// folder.
+	RestoreFolderRelocationPolicy FolderRelocationPolicy = "Restore"
+)
+
+// RenamePolicy is the policy applied to a virtual machine renamed in vCenter.
+// +kubebuilder:validation:Enum=Accept;Restore
+type RenamePolicy string
+
+const (
+	// AcceptRenamePolicy keeps the new name of the virtual machine. This is
+	// the default.
+	AcceptRenamePolicy RenamePolicy = "Accept"
+
+	// RestoreRenamePolicy renames the virtual machine back to its name.
+	RestoreRenamePolicy RenamePolicy = "Restore"
+)
+
+// CABundleReference references the PEM-encoded CA certificates of a vCenter
+// server, in a Secret or a ConfigMap in the namespace of the referencing
+// object.
+type CABundleReference struct {
+	// Kind is the kind of the object holding the CA bundle.
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind string `json:"kind"`
+
+	// Name is the name of the object holding the CA bundle.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key of the CA bundle in the data of the object.
+	// Defaults to ca.crt.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
 // VirtualMachineCloneSpec is information used to clone a virtual machine.
 type VirtualMachineCloneSpec struct {
 	// Template is the name or inventory path of the template used to clone
-	// the virtual machine.
-	// +kubebuilder:validation:MinLength=1
-	Template string `json:"template"`
+	// the virtual machine. When no template has this name, the OVF or VM
+	// template item of a content library with this name is deployed once per
+	// datastore as a base template, which is then used to clone the virtual
+	// machine. It is required unless the VSphereMachine resolves its template
+	// from a VSphereMachineImage with ImageRef.
+	// +optional
+	Template string `json:"template,omitempty"`
 
 	// CloneMode specifies the type of clone operation.
 	// The LinkedClone mode is only support for templates that have at least
-	// one snapshot. If the template has no snapshots, then CloneMode defaults
-	// to FullClone.
+	// one snapshot. When LinkedClone is set explicitly, a snapshot is taken of
+	// a source VM without snapshots, and the clone fails if the source is a
+	// template without snapshots.
 	// When LinkedClone mode is enabled the DiskGiB field is ignored as it is
 	// not possible to expand disks of linked clones.
 	// Defaults to LinkedClone, but fails gracefully to FullClone if the source
@@ -71,7 +331,7 @@ type VirtualMachineCloneSpec struct {
 	CloneMode CloneMode `json:"cloneMode,omitempty"`
 
 	// Snapshot is the name of the snapshot from which to create a linked clone.
-	// This field is ignored if LinkedClone is not enabled.
+	// Cannot be set when CloneMode is FullClone.
 	// Defaults to the source's current snapshot.
 	// +optional
 	Snapshot string `json:"snapshot,omitempty"`
@@ -88,12 +348,25 @@ type VirtualMachineCloneSpec struct {
 	// +optional
 	Thumbprint string `json:"thumbprint,omitempty"`
 
+	// CABundleRef references the PEM-encoded CA certificates against which
+	// the certificate of the vCenter server is verified.
+	// +optional
+	CABundleRef *CABundleReference `json:"caBundleRef,omitempty"`
+
 	// Datacenter is the name or inventory path of the datacenter in which the
 	// virtual machine is created/located.
 	// Defaults to * which selects the default datacenter.
 	// +optional
 	Datacenter string `json:"datacenter,omitempty"`
 
+	// TemplateDatacenter is the name or inventory path of the datacenter of
+	// the template, when it is not in Datacenter. The virtual machine is
+	// still created in Datacenter, with a full clone unless the template is
+	// on a datastore of both datacenters. The template must be in the same
+	// vCenter as the virtual machine.
+	// +optional
+	TemplateDatacenter string `json:"templateDatacenter,omitempty"`
+
 	// Folder is the name or inventory path of the folder in which the
 	// virtual machine is created/located.
 	// +optional
@@ -145,11 +418,311 @@ type VirtualMachineCloneSpec struct {
 	AdditionalDisksGiB []int32 `json:"additionalDisksGiB,omitempty"`
 	// CustomVMXKeys is a dictionary of advanced VMX options that can be set on VM
 	// Defaults to empty map
+	// The options are set in the extraConfig of the VM when it is cloned. The
+	// keys set by the provider, e.g. guestinfo.userdata, guestinfo.metadata
+	// and the keys prefixed with capv. or guestinfo.capv., cannot be set.
 	// +optional
 	CustomVMXKeys map[string]string `json:"customVMXKeys,omitempty"`
 	// TagIDs is an optional set of tags to add to an instance.
 	// +optional
 	TagIDs []string `json:"tagIDs,omitempty"`
+	// PCIDevices is the list of PCI passthrough devices, or virtual GPUs,
+	// attached to the virtual machine. Setting any device locks the memory
+	// reservation of the virtual machine to its configured memory size.
+	// +optional
+	PCIDevices []PCIDeviceSpec `json:"pciDevices,omitempty"`
+	// HostnameStrategy is the source of the guest hostname. The same value is
+	// written to the cloud-init metadata, which the bootstrap data uses as the
+	// Kubernetes node name.
+	// Defaults to machineName.
+	// +optional
+	HostnameStrategy HostnameStrategy `json:"hostnameStrategy,omitempty"`
+	// HostnameDomain is the domain suffix of the guest hostname.
+	// Required when HostnameStrategy is fqdn.
+	// +optional
+	HostnameDomain string `json:"hostnameDomain,omitempty"`
+	// Timeouts overrides the timeouts of the phases of the provisioning of
+	// the VM configured on the controller manager.
+	// +optional
+	Timeouts *ProvisioningTimeouts `json:"timeouts,omitempty"`
+	// DataDisks is the list of data disks created and attached to the
+	// virtual machine when it is cloned. The disks are owned by the virtual
+	// machine and deleted along with it.
+	// +optional
+	DataDisks []DataDiskSpec `json:"dataDisks,omitempty"`
+	// PowerOffMode is the mode of the power off of the virtual machine
+	// before it is destroyed.
+	// Defaults to hard.
+	// +optional
+	PowerOffMode VirtualMachinePowerOffMode `json:"powerOffMode,omitempty"`
+	// GuestSoftPowerOffTimeout is the maximum duration of the shutdown of the
+	// guest OS when PowerOffMode is trySoft, after which the virtual machine
+	// is powered off.
+	// Defaults to 5m.
+	// +optional
+	GuestSoftPowerOffTimeout *metav1.Duration `json:"guestSoftPowerOffTimeout,omitempty"`
+	// DiskWipePolicy is the policy of the wipe of the disks of the virtual
+	// machine before it is destroyed, e.g. for clusters handling regulated
+	// data. The deletion of the virtual machine waits for its disks to be
+	// wiped.
+	// Defaults to none.
+	// +optional
+	DiskWipePolicy DiskWipePolicy `json:"diskWipePolicy,omitempty"`
+	// AttachedVolumesPolicy is the policy applied when persistent volumes of
+	// Kubernetes are still attached to the virtual machine when it is
+	// deleted, e.g. when a MachineDeployment is scaled down before the CSI
+	// driver detached them. The attached volumes are found with Cloud Native
+	// Storage before the virtual machine is powered off.
+	// Defaults to Ignore.
+	// +optional
+	AttachedVolumesPolicy AttachedVolumesPolicy `json:"attachedVolumesPolicy,omitempty"`
+	// DRSAutomationLevel overrides the DRS automation level of the compute
+	// cluster for the virtual machine, to control its migrations, e.g. for
+	// latency-sensitive or host-pinned workloads. The automation level of
+	// the compute cluster applies when empty. It has no effect on a virtual
+	// machine which is not in a compute cluster.
+	// +optional
+	DRSAutomationLevel DRSAutomationLevel `json:"drsAutomationLevel,omitempty"`
+	// HostAffinity pins the virtual machine to the hosts of a host group of
+	// its compute cluster, e.g. for the workloads licensed per host. The
+	// virtual machine is added to the VM group of the rule before it is
+	// powered on. It has no effect on a virtual machine which is not in a
+	// compute cluster.
+	// +optional
+	HostAffinity *HostAffinitySpec `json:"hostAffinity,omitempty"`
+	// TimeSync configures the synchronization of the guest clock of the
+	// virtual machine with its ESXi host, and the monitoring of its skew.
+	// The NTP servers of the guest are set in Network.NTPServers.
+	// +optional
+	TimeSync *TimeSyncSpec `json:"timeSync,omitempty"`
+	// SecureBoot enables the EFI secure boot of the virtual machine, so that
+	// only signed boot loaders and kernels are run. The template must use the
+	// EFI firmware.
+	// +optional
+	SecureBoot bool `json:"secureBoot,omitempty"`
+	// TPM attaches a virtual TPM 2.0 device to the virtual machine when it is
+	// cloned, to support the measured boot of the node image. A virtual TPM
+	// requires an encrypted virtual machine, so StoragePolicyName must name a
+	// storage policy with VM encryption, and a key provider must be
+	// configured in vCenter.
+	// +optional
+	TPM bool `json:"tpm,omitempty"`
+	// Encryption encrypts the virtual machine and its disks when it is
+	// cloned. StoragePolicyName must name a storage policy with VM
+	// encryption, and the virtual machine is a full clone unless its template
+	// is encrypted. The encryption is reported in the EncryptionReady
+	// condition of the VSphereVM.
+	// +optional
+	Encryption *EncryptionSpec `json:"encryption,omitempty"`
+	// OS is the family of the guest operating system of the virtual machine,
+	// which selects the format of the metadata written to the guestinfo of
+	// the virtual machine. The guest hostname of a Windows virtual machine is
+	// shortened to the 15 characters of a computer name.
+	// Defaults to Linux.
+	// +optional
+	OS OS `json:"os,omitempty"`
+	// GuestID is the identifier of the guest operating system of the virtual
+	// machine, e.g. "windows2019srv_64Guest", which overrides the one of the
+	// template when the virtual machine is cloned.
+	// +optional
+	GuestID string `json:"guestID,omitempty"`
+	// CustomizationSpec is the name of a guest customization specification
+	// of vCenter applied to the virtual machine when it is cloned. Only the
+	// sysprep specifications of Windows guests are supported; the computer
+	// name and the network settings of the specification are replaced by the
+	// ones of the virtual machine.
+	// +optional
+	CustomizationSpec string `json:"customizationSpec,omitempty"`
+	// WindowsActivation configures the activation of the Windows guest of the
+	// virtual machine by its sysprep customization, and the report of its
+	// activation status in the WindowsActivated condition of the VSphereVM.
+	// It requires CustomizationSpec.
+	// +optional
+	WindowsActivation *WindowsActivationSpec `json:"windowsActivation,omitempty"`
+	// BootstrapFormat is the format of the bootstrap data of the virtual
+	// machine, which selects the guestinfo keys the bootstrap data is written
+	// to. It must match the format of the bootstrap data rendered by the
+	// bootstrap provider.
+	// Defaults to cloud-config.
+	// +optional
+	BootstrapFormat BootstrapFormat `json:"bootstrapFormat,omitempty"`
+	// FolderRelocationPolicy is the policy applied when the virtual machine
+	// is moved out of Folder in vCenter, e.g. by an administrator. The virtual
+	// machine is found by its UUID wherever it is, and its current folder is
+	// reported in the status of the VSphereVM.
+	// Defaults to Accept.
+	// +optional
+	FolderRelocationPolicy FolderRelocationPolicy `json:"folderRelocationPolicy,omitempty"`
+	// RenamePolicy is the policy applied when the virtual machine is renamed
+	// in vCenter, e.g. by an administrator. The virtual machine is found by
+	// its UUID whatever its name, and its current name is reported in the
+	// status of the VSphereVM.
+	// Defaults to Accept.
+	// +optional
+	RenamePolicy RenamePolicy `json:"renamePolicy,omitempty"`
+	// ExistingVM binds the VSphereVM to a virtual machine which exists in
+	// vCenter, e.g. a node built by hand, instead of cloning one. Only the
+	// power state, the metadata and the bootstrap data of an existing
+	// virtual machine are managed, and it is kept in vCenter when the
+	// VSphereVM is deleted unless its deletion policy is Delete. It cannot
+	// be set together with Template nor ImageRef, nor in a
+	// VSphereMachineTemplate.
+	// +optional
+	ExistingVM *ExistingVMSpec `json:"existingVM,omitempty"`
+	// DeletionPolicy is the policy applied to the virtual machine when the
+	// VSphereVM is deleted, e.g. to keep it for forensics. It can be changed
+	// after the VSphereVM is created, and cannot be set together with
+	// ExistingVM, which has a deletion policy of its own.
+	// Defaults to Delete.
+	// +optional
+	DeletionPolicy VMDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// VMDeletionPolicy is the policy applied to a cloned virtual machine when its
+// VSphereVM is deleted.
+// +kubebuilder:validation:Enum=Delete;Retain;PowerOffAndRetain
+type VMDeletionPolicy string
+
+const (
+	// DeleteVMDeletionPolicy powers off and destroys the virtual machine.
+	// This is the default.
+	DeleteVMDeletionPolicy VMDeletionPolicy = "Delete"
+
+	// RetainVMDeletionPolicy keeps the virtual machine in vCenter, in its
+	// power state.
+	RetainVMDeletionPolicy VMDeletionPolicy = "Retain"
+
+	// PowerOffAndRetainVMDeletionPolicy powers off the virtual machine and
+	// keeps it in vCenter.
+	PowerOffAndRetainVMDeletionPolicy VMDeletionPolicy = "PowerOffAndRetain"
+)
+
+// ExistingVMSpec identifies a virtual machine which exists in vCenter by
+// exactly one of its managed object reference and its BIOS UUID.
+type ExistingVMSpec struct {
+	// MoRef is the value of the managed object reference of the virtual
+	// machine, e.g. "vm-42".
+	// +optional
+	MoRef string `json:"moRef,omitempty"`
+
+	// UUID is the BIOS UUID of the virtual machine.
+	// +optional
+	UUID string `json:"uuid,omitempty"`
+
+	// DeletionPolicy is the policy applied to the virtual machine when the
+	// VSphereVM is deleted.
+	// Defaults to Retain.
+	// +optional
+	DeletionPolicy ExistingVMDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// ExistingVMDeletionPolicy is the policy applied to an existing virtual
+// machine when its VSphereVM is deleted.
+// +kubebuilder:validation:Enum=Retain;Delete
+type ExistingVMDeletionPolicy string
+
+const (
+	// RetainExistingVMDeletionPolicy keeps the virtual machine in vCenter,
+	// in its power state. This is the default.
+	RetainExistingVMDeletionPolicy ExistingVMDeletionPolicy = "Retain"
+
+	// DeleteExistingVMDeletionPolicy powers off and destroys the virtual
+	// machine, like a cloned one.
+	DeleteExistingVMDeletionPolicy ExistingVMDeletionPolicy = "Delete"
+)
+
+// DiskProvisioningMode is the provisioning mode of a virtual disk.
+// +kubebuilder:validation:Enum=Thin;Thick;EagerlyZeroed
+type DiskProvisioningMode string
+
+const (
+	// ThinProvisioningMode allocates the storage of the disk on demand.
+	ThinProvisioningMode DiskProvisioningMode = "Thin"
+
+	// ThickProvisioningMode allocates the storage of the disk when the disk
+	// is created, and zeroes it on first write.
+	ThickProvisioningMode DiskProvisioningMode = "Thick"
+
+	// EagerlyZeroedProvisioningMode allocates and zeroes the storage of the
+	// disk when the disk is created.
+	EagerlyZeroedProvisioningMode DiskProvisioningMode = "EagerlyZeroed"
+)
+
+// DataDiskSpec defines a data disk created and attached to the virtual
+// machine.
+type DataDiskSpec struct {
+	// Name is the name of the disk, used as the label of the virtual disk.
+	Name string `json:"name"`
+	// SizeGiB is the size of the disk, in GiB.
+	// +kubebuilder:validation:Minimum=1
+	SizeGiB int32 `json:"sizeGiB"`
+	// ProvisioningMode is the provisioning mode of the disk.
+	// Defaults to Thin.
+	// +optional
+	ProvisioningMode DiskProvisioningMode `json:"provisioningMode,omitempty"`
+	// StoragePolicyName is the name of the storage policy of the disk.
+	// Defaults to the storage policy of the virtual machine.
+	// +optional
+	StoragePolicyName string `json:"storagePolicyName,omitempty"`
+	// Controller is the virtual controller added to the virtual machine to
+	// attach the disk to.
+	// Defaults to the controller of the primary disk of the template.
+	// +optional
+	Controller *DataDiskControllerSpec `json:"controller,omitempty"`
+}
+
+// DiskControllerType is the type of a virtual disk controller.
+// +kubebuilder:validation:Enum=ParaVirtual;NVMe
+type DiskControllerType string
+
+const (
+	// ParaVirtualDiskControllerType is the VMware Paravirtual SCSI controller.
+	ParaVirtualDiskControllerType DiskControllerType = "ParaVirtual"
+
+	// NVMeDiskControllerType is the NVMe controller.
+	NVMeDiskControllerType DiskControllerType = "NVMe"
+)
+
+// DataDiskControllerSpec defines the virtual controller of a data disk.
+type DataDiskControllerSpec struct {
+	// Type is the type of the controller.
+	Type DiskControllerType `json:"type"`
+	// Dedicated attaches the disk to a controller of its own. The data disks
+	// of the same type which are not dedicated share a single controller.
+	// +optional
+	Dedicated bool `json:"dedicated,omitempty"`
+	// ExtraConfig is a dictionary of advanced options of the controller,
+	// e.g. to tune its queues. The options are set in the extraConfig of the
+	// VM when it is cloned, prefixed with the name of the controller, e.g.
+	// "scsi1.". The disks sharing a controller cannot set an option to
+	// different values.
+	// +optional
+	ExtraConfig map[string]string `json:"extraConfig,omitempty"`
+}
+
+// ProvisioningTimeouts defines the timeouts of the phases of the provisioning
+// of a VM. The VM is marked as failed when a phase exceeds its timeout. A
+// phase has no timeout unless it is set here or on the controller manager,
+// and a zero duration disables the timeout of the controller manager.
+type ProvisioningTimeouts struct {
+	// Clone is the maximum duration of the clone of the VM.
+	// +optional
+	Clone *metav1.Duration `json:"clone,omitempty"`
+
+	// ToolsStart is the maximum duration between the power on of the VM and
+	// VMware Tools running in the guest.
+	// +optional
+	ToolsStart *metav1.Duration `json:"toolsStart,omitempty"`
+
+	// IPAcquisition is the maximum duration between the power on of the VM
+	// and the VM reporting its IP addresses.
+	// +optional
+	IPAcquisition *metav1.Duration `json:"ipAcquisition,omitempty"`
+
+	// BootstrapJoin is the maximum duration between the VM being ready and
+	// the node of its Machine joining the cluster.
+	// +optional
+	BootstrapJoin *metav1.Duration `json:"bootstrapJoin,omitempty"`
 }
 
 // VSphereMachineTemplateResource describes the data needed to create a VSphereMachine from a template
@@ -193,6 +766,22 @@ func (v APIEndpoint) String() string {
 	return fmt.Sprintf("%s:%d", v.Host, v.Port)
 }
 
+// PCIDeviceSpec defines a PCI device attached to the virtual machine. Either
+// the DeviceID and VendorID of a dynamic DirectPath I/O device, or the
+// VGPUProfile of a virtual GPU, must be set.
+type PCIDeviceSpec struct {
+	// DeviceID is the device ID of the PCI passthrough device, in integer.
+	// +optional
+	DeviceID *int32 `json:"deviceId,omitempty"`
+	// VendorID is the vendor ID of the PCI passthrough device, in integer.
+	// +optional
+	VendorID *int32 `json:"vendorId,omitempty"`
+	// VGPUProfile is the name of the vGPU profile to attach to the virtual
+	// machine, e.g. "grid_t4-4c".
+	// +optional
+	VGPUProfile string `json:"vGPUProfile,omitempty"`
+}
+
 // NetworkSpec defines the virtual machine's network configuration.
 type NetworkSpec struct {
 	// Devices is the list of network devices used by the virtual machine.
@@ -209,6 +798,76 @@ type NetworkSpec struct {
 	// server endpoint on this machine
 	// +optional
 	PreferredAPIServerCIDR string `json:"preferredAPIServerCidr,omitempty"`
+
+	// NTPServers is a list of NTP servers with which the virtual machine
+	// synchronizes its clock.
+	// Defaults to the NTP servers of the networkSettings of the VSphereCluster.
+	// +optional
+	NTPServers []string `json:"ntpServers,omitempty"`
+
+	// Proxy is the HTTP proxy used by the container runtime of the virtual
+	// machine.
+	// Defaults to the proxy of the networkSettings of the VSphereCluster.
+	// +optional
+	Proxy *ProxySpec `json:"proxy,omitempty"`
+
+	// PublishedAddresses selects the addresses of the virtual machine
+	// published in the status of the VSphereVM, and so to the Machine, on
+	// machines with more than one network device. Only the addresses
+	// reachable by the control plane should be published, so they match
+	// the addresses the node and its certificates are served on.
+	// Defaults to the addresses of every device.
+	// +optional
+	PublishedAddresses *PublishedAddressesSpec `json:"publishedAddresses,omitempty"`
+}
+
+// PublishedAddressesSpec selects the addresses of a virtual machine which are
+// published, by the network devices they are assigned to and by their CIDRs.
+type PublishedAddressesSpec struct {
+	// Devices selects the network devices whose addresses are published.
+	// Defaults to All.
+	// +optional
+	Devices PublishedAddressDevices `json:"devices,omitempty"`
+
+	// CIDRs restricts the published addresses to the addresses in one of
+	// the CIDRs, e.g. "10.0.0.0/16".
+	// +optional
+	CIDRs []string `json:"cidrs,omitempty"`
+}
+
+// PublishedAddressDevices selects the network devices whose addresses are
+// published.
+// +kubebuilder:validation:Enum=All;Management;NonWorkload
+type PublishedAddressDevices string
+
+const (
+	// AllPublishedAddressDevices publishes the addresses of every device.
+	AllPublishedAddressDevices PublishedAddressDevices = "All"
+
+	// ManagementPublishedAddressDevices publishes the addresses of the
+	// device with the Management role, or of the first device when no
+	// device has the role.
+	ManagementPublishedAddressDevices PublishedAddressDevices = "Management"
+
+	// NonWorkloadPublishedAddressDevices publishes the addresses of the
+	// devices without the Workload role.
+	NonWorkloadPublishedAddressDevices PublishedAddressDevices = "NonWorkload"
+)
+
+// ProxySpec defines an HTTP proxy.
+type ProxySpec struct {
+	// HTTPProxy is the URL of the proxy of the HTTP requests.
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// HTTPSProxy is the URL of the proxy of the HTTPS requests.
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// NoProxy is a list of host names, domains, IP addresses and CIDRs
+	// reached without the proxy.
+	// +optional
+	NoProxy []string `json:"noProxy,omitempty"`
 }
 
 // NetworkDeviceSpec defines the network configuration for a virtual machine's
@@ -223,6 +882,15 @@ type NetworkDeviceSpec struct {
 	// +optional
 	DeviceName string `json:"deviceName,omitempty"`
 
+	// Role is the role of the device on machines with more than one network
+	// device.
+	// The management device carries the default route and is the interface
+	// kube-vip binds the control plane endpoint to.
+	// A workload device does not accept default routes from DHCP and must not
+	// define a gateway; use Routes to reach its networks instead.
+	// +optional
+	Role NetworkDeviceRole `json:"role,omitempty"`
+
 	// DHCP4 is a flag that indicates whether or not to use DHCP for IPv4
 	// on this device.
 	// If true then IPAddrs should not contain any IPv4 addresses.
@@ -235,13 +903,20 @@ type NetworkDeviceSpec struct {
 	// +optional
 	DHCP6 bool `json:"dhcp6,omitempty"`
 
+	// SLAAC is a flag that indicates whether or not to configure IPv6
+	// addresses and the IPv6 default route from the router advertisements,
+	// with stateless address autoconfiguration, on this device.
+	// It can be combined with DHCP6 and static IPv6 addresses.
+	// +optional
+	SLAAC bool `json:"slaac,omitempty"`
+
 	// Gateway4 is the IPv4 gateway used by this device.
 	// Required when DHCP4 is false.
 	// +optional
 	Gateway4 string `json:"gateway4,omitempty"`
 
-	// Gateway4 is the IPv4 gateway used by this device.
-	// Required when DHCP6 is false.
+	// Gateway6 is the IPv6 gateway used by this device.
+	// Required when DHCP6 and SLAAC are false.
 	// +optional
 	Gateway6 string `json:"gateway6,omitempty"`
 
@@ -251,6 +926,13 @@ type NetworkDeviceSpec struct {
 	// +optional
 	IPAddrs []string `json:"ipAddrs,omitempty"`
 
+	// AddressesFromPools is a list of references to the IPAM pools from which
+	// an IP address is claimed for this device. The claimed addresses, and
+	// their gateways when Gateway4 or Gateway6 are not set, are added to the
+	// static configuration of the device.
+	// +optional
+	AddressesFromPools []corev1.TypedLocalObjectReference `json:"addressesFromPools,omitempty"`
+
 	// MTU is the device’s Maximum Transmission Unit size in bytes.
 	// +optional
 	MTU *int64 `json:"mtu,omitempty"`
@@ -263,6 +945,19 @@ type NetworkDeviceSpec struct {
 	// +optional
 	MACAddr string `json:"macAddr,omitempty"`
 
+	// AdapterType is the type of the virtual network adapter of the device.
+	// Defaults to vmxnet3.
+	// Please note that sriov and pvrdma adapters require the memory of the
+	// VM to be fully reserved, which is usually configured on the template.
+	// +optional
+	AdapterType NetworkAdapterType `json:"adapterType,omitempty"`
+
+	// PhysicalFunction is the PCI ID of the SR-IOV physical function, e.g.
+	// 0000:3b:00.0, providing the virtual function of the device.
+	// It must be set when AdapterType is sriov.
+	// +optional
+	PhysicalFunction string `json:"physicalFunction,omitempty"`
+
 	// Nameservers is a list of IPv4 and/or IPv6 addresses used as DNS
 	// nameservers.
 	// Please note that Linux allows only three nameservers (https://linux.die.net/man/5/resolv.conf).
@@ -279,6 +974,37 @@ type NetworkDeviceSpec struct {
 	SearchDomains []string `json:"searchDomains,omitempty"`
 }
 
+// NetworkDeviceRole describes the traffic carried by a network device.
+// +kubebuilder:validation:Enum=Management;Workload
+type NetworkDeviceRole string
+
+const (
+	// NetworkDeviceRoleManagement is the role of the device carrying the
+	// management traffic and the control plane endpoint.
+	NetworkDeviceRoleManagement NetworkDeviceRole = "Management"
+
+	// NetworkDeviceRoleWorkload is the role of a device carrying only the
+	// traffic of the workloads.
+	NetworkDeviceRoleWorkload NetworkDeviceRole = "Workload"
+)
+
+// NetworkAdapterType is the type of the virtual network adapter of a
+// network device.
+// +kubebuilder:validation:Enum=vmxnet3;sriov;pvrdma
+type NetworkAdapterType string
+
+const (
+	// NetworkAdapterTypeVmxnet3 is the paravirtualized vmxnet3 adapter.
+	NetworkAdapterTypeVmxnet3 NetworkAdapterType = "vmxnet3"
+
+	// NetworkAdapterTypeSRIOV is an SR-IOV passthrough adapter backed by a
+	// virtual function of a physical adapter of the host.
+	NetworkAdapterTypeSRIOV NetworkAdapterType = "sriov"
+
+	// NetworkAdapterTypePVRDMA is the paravirtual RDMA adapter.
+	NetworkAdapterTypePVRDMA NetworkAdapterType = "pvrdma"
+)
+
 // NetworkRouteSpec defines a static network route.
 type NetworkRouteSpec struct {
 	// To is an IPv4 or IPv6 address.
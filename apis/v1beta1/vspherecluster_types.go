@@ -18,8 +18,10 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
 const (
@@ -27,6 +29,12 @@ const (
 	// resources associated with VSphereCluster before removing it from the
 	// API server.
 	ClusterFinalizer = "vspherecluster.infrastructure.cluster.x-k8s.io"
+
+	// ClusterEstimatedHourlyCostAnnotation is the annotation of a
+	// VSphereCluster set to the sum of the estimated hourly costs of its
+	// VSphereMachines, when the cost weights of the controller manager are
+	// set.
+	ClusterEstimatedHourlyCostAnnotation = "vspherecluster.infrastructure.cluster.x-k8s.io/estimated-hourly-cost"
 )
 
 // VSphereClusterSpec defines the desired state of VSphereCluster
@@ -38,6 +46,13 @@ type VSphereClusterSpec struct {
 	// +optional
 	Thumbprint string `json:"thumbprint,omitempty"`
 
+	// CABundleRef references the PEM-encoded CA certificates against which
+	// the certificate of the vCenter server is verified, instead of the
+	// system roots. The VSphereMachines of the cluster without a CA bundle
+	// use the one of the cluster.
+	// +optional
+	CABundleRef *CABundleReference `json:"caBundleRef,omitempty"`
+
 	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
 	// +optional
 	ControlPlaneEndpoint APIEndpoint `json:"controlPlaneEndpoint"`
@@ -46,6 +61,344 @@ type VSphereClusterSpec struct {
 	// the identity to use when reconciling the cluster.
 	// +optional
 	IdentityRef *VSphereIdentityReference `json:"identityRef,omitempty"`
+
+	// ClusterModules hosts information regarding the anti-affinity vSphere constructs
+	// for each of the objects responsible for creation of VM objects belonging to the cluster.
+	// +optional
+	ClusterModules []ClusterModule `json:"clusterModules,omitempty"`
+
+	// Placement instructs the controller to create a VM folder and a resource
+	// pool for the cluster, in which the VMs of the machines without a failure
+	// domain are placed. The folder and the resource pool are deleted with the
+	// cluster when they are empty.
+	// +optional
+	Placement *ClusterPlacementSpec `json:"placement,omitempty"`
+
+	// ControlPlaneEndpointProvider provisions the ControlPlaneEndpoint of the
+	// cluster when it is empty, either with a kube-vip static pod on the
+	// control plane machines or with a virtual service of NSX Advanced Load
+	// Balancer. The provisioned endpoint is recorded in ControlPlaneEndpoint.
+	// +optional
+	ControlPlaneEndpointProvider *ControlPlaneEndpointProviderSpec `json:"controlPlaneEndpointProvider,omitempty"`
+
+	// NetworkSettings are the DNS, NTP and proxy settings of the machines of
+	// the cluster, used unless set in the network of a machine. They are
+	// applied to the machines created after they are set.
+	// +optional
+	NetworkSettings *ClusterNetworkSettings `json:"networkSettings,omitempty"`
+
+	// CreationRollback enables the rollback of the cluster when its control
+	// plane is not initialized in time after its creation: the VMs of its
+	// machines, and the VM folder and the resource pool created for it, are
+	// deleted, and the cluster is marked as failed.
+	// +optional
+	CreationRollback *ClusterCreationRollbackSpec `json:"creationRollback,omitempty"`
+
+	// OvercommitPolicy limits the overcommit of the CPU and the memory of the
+	// ESXi hosts on which the VMs of the machines of the cluster are placed
+	// when they are cloned.
+	// +optional
+	OvercommitPolicy *OvercommitPolicy `json:"overcommitPolicy,omitempty"`
+
+	// Naming defines the names of the VMs of the machines of the cluster,
+	// and of the VM folder and the resource pool created for it.
+	// +optional
+	Naming *NamingStrategy `json:"naming,omitempty"`
+}
+
+// NamingStrategy defines the templates of the names CAPV gives to the objects
+// it creates in vCenter for a cluster. The templates are Go templates which
+// can refer to .ClusterName, .Namespace, .MachineName and .RandomSuffix, a
+// random string of 5 characters, and use the trunc and lower functions, e.g.
+// "{{.ClusterName}}-{{.MachineName | trunc 20}}-{{.RandomSuffix}}". The
+// rendered names are converted to DNS labels of at most 63 characters.
+type NamingStrategy struct {
+	// VM is the template of the names of the VMs of the machines of the
+	// cluster. It must render a distinct name for each machine, so it must
+	// refer to .MachineName or .RandomSuffix. The name of a VM is set once,
+	// when its VSphereVM is created. Defaults to the name of the Machine.
+	// +optional
+	VM string `json:"vm,omitempty"`
+
+	// Placement is the template of the name of the VM folder and the
+	// resource pool created for the cluster. It cannot refer to .MachineName
+	// or .RandomSuffix. Defaults to <namespace>-<cluster name>.
+	// +optional
+	Placement string `json:"placement,omitempty"`
+}
+
+// OvercommitPolicy defines the maximum overcommit ratios of the ESXi hosts on
+// which VMs are placed, in percent of the physical resources of the hosts.
+type OvercommitPolicy struct {
+	// CPURatioPercent is the maximum ratio of the virtual CPUs of the powered
+	// on VMs of a host, including the placed VM, to the physical CPU cores of
+	// the host, in percent: 400 allows 4 virtual CPUs per core.
+	// The CPU is not limited when empty.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	CPURatioPercent *int32 `json:"cpuRatioPercent,omitempty"`
+
+	// MemoryRatioPercent is the maximum ratio of the memory of the powered on
+	// VMs of a host, including the placed VM, to the physical memory of the
+	// host, in percent: 100 does not allow the memory to be overcommitted.
+	// The memory is not limited when empty.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MemoryRatioPercent *int32 `json:"memoryRatioPercent,omitempty"`
+}
+
+// ClusterCreationRollbackSpec defines when the creation of a cluster is
+// rolled back.
+type ClusterCreationRollbackSpec struct {
+	// Timeout is the duration, from the creation of the VSphereCluster, within
+	// which the control plane of the cluster must be initialized.
+	Timeout metav1.Duration `json:"timeout"`
+}
+
+// ClusterNetworkSettings defines the DNS, NTP and proxy settings of the
+// machines of a cluster.
+type ClusterNetworkSettings struct {
+	// Nameservers is a list of IPv4 and/or IPv6 addresses used as DNS
+	// nameservers by the network devices without nameservers.
+	// +optional
+	Nameservers []string `json:"nameservers,omitempty"`
+
+	// SearchDomains is a list of search domains used by the network devices
+	// without search domains.
+	// +optional
+	SearchDomains []string `json:"searchDomains,omitempty"`
+
+	// NTPServers is a list of NTP servers used by the machines without NTP
+	// servers.
+	// +optional
+	NTPServers []string `json:"ntpServers,omitempty"`
+
+	// Proxy is the HTTP proxy used by the machines without a proxy.
+	// +optional
+	Proxy *ProxySpec `json:"proxy,omitempty"`
+}
+
+// ControlPlaneEndpointProviderSpec defines how the control plane endpoint of a
+// cluster is provisioned. Exactly one of KubeVIP and AVI must be set.
+type ControlPlaneEndpointProviderSpec struct {
+	// Port is the port of the control plane endpoint.
+	// Defaults to 6443.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// KubeVIP provisions the endpoint with a virtual IP address claimed from
+	// an IPAM pool, which kube-vip advertises from the control plane machines.
+	// The static pod of kube-vip is added to the bootstrap data of the control
+	// plane machines.
+	// +optional
+	KubeVIP *KubeVIPEndpointSpec `json:"kubeVIP,omitempty"`
+
+	// AVI provisions the endpoint with a virtual service of NSX Advanced Load
+	// Balancer, balancing the API servers of the control plane machines.
+	// +optional
+	AVI *AVIEndpointSpec `json:"avi,omitempty"`
+}
+
+// KubeVIPEndpointSpec defines a control plane endpoint advertised by kube-vip.
+type KubeVIPEndpointSpec struct {
+	// AddressFromPool is the IPAM pool from which the virtual IP address of the
+	// endpoint is claimed.
+	AddressFromPool corev1.TypedLocalObjectReference `json:"addressFromPool"`
+
+	// Interface is the network interface of the control plane machines on
+	// which the virtual IP address is advertised.
+	// Defaults to eth0.
+	// +optional
+	Interface string `json:"interface,omitempty"`
+
+	// Image is the image of kube-vip.
+	// Defaults to ghcr.io/kube-vip/kube-vip:v0.4.1.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Failover claims alternate virtual IP addresses from AddressFromPool,
+	// which kube-vip advertises as well, health-probes the active address and
+	// promotes an alternate one when it becomes unreachable.
+	// +optional
+	Failover *KubeVIPFailoverSpec `json:"failover,omitempty"`
+}
+
+// KubeVIPFailoverSpec defines the failover of a control plane endpoint
+// between several virtual IP addresses advertised by kube-vip.
+type KubeVIPFailoverSpec struct {
+	// Hostname is the DNS name of the control plane endpoint, recorded in
+	// ControlPlaneEndpoint instead of a virtual IP address so that the
+	// kubeconfig and the certificates of the cluster remain valid after a
+	// failover. It must resolve to the active address, reported in
+	// status.controlPlaneEndpointFailover.activeAddress.
+	Hostname string `json:"hostname"`
+
+	// Alternates is the number of alternate virtual IP addresses claimed.
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4
+	// +optional
+	Alternates int32 `json:"alternates,omitempty"`
+
+	// Period is the interval between two probes of the active address.
+	// Defaults to 30s.
+	// +optional
+	Period *metav1.Duration `json:"period,omitempty"`
+
+	// Timeout is the maximum duration of a probe.
+	// Defaults to 5s.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed probes of the
+	// active address after which an alternate address is promoted.
+	// Defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+
+	// ManageDNSRecord maintains an ExternalDNS DNSEndpoint, named after the
+	// claim of the endpoint, resolving Hostname to the active address, so
+	// that the record of the hostname is moved to a promoted address by
+	// ExternalDNS. The record is managed by hand otherwise.
+	// +optional
+	ManageDNSRecord bool `json:"manageDNSRecord,omitempty"`
+}
+
+// AVIEndpointSpec defines a control plane endpoint provisioned as a virtual
+// service of NSX Advanced Load Balancer, formerly Avi Vantage.
+type AVIEndpointSpec struct {
+	// Controller is the URL of the NSX Advanced Load Balancer controller,
+	// e.g. https://avi.example.com.
+	Controller string `json:"controller"`
+
+	// CredentialsSecretName is the name of the Secret, in the namespace of the
+	// VSphereCluster, holding the username and the password of the controller
+	// at the username and password keys.
+	CredentialsSecretName string `json:"credentialsSecretName"`
+
+	// CACertificate is the PEM-encoded CA certificate of the controller.
+	// Defaults to the system roots.
+	// +optional
+	CACertificate string `json:"caCertificate,omitempty"`
+
+	// Tenant is the tenant of the virtual service.
+	// Defaults to admin.
+	// +optional
+	Tenant string `json:"tenant,omitempty"`
+
+	// Cloud is the name of the cloud of the virtual service.
+	// Defaults to Default-Cloud.
+	// +optional
+	Cloud string `json:"cloud,omitempty"`
+
+	// Network is the name of the network from which the IPAM of the
+	// controller allocates the virtual IP address of the virtual service.
+	Network string `json:"network"`
+
+	// APIVersion is the version of the API of the controller.
+	// Defaults to 20.1.1.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+}
+
+// ClusterPlacementSpec defines the VM folder and resource pool created for
+// a cluster. They are both named <namespace>-<cluster name>, unless named
+// after the placement template of the naming strategy of the cluster.
+type ClusterPlacementSpec struct {
+	// Datacenter is the name or inventory path of the datacenter in which the
+	// folder and the resource pool are created.
+	Datacenter string `json:"datacenter"`
+
+	// Folder is the name or inventory path of the folder in which the folder
+	// of the cluster is created. Defaults to the VM folder of the datacenter.
+	// +optional
+	Folder string `json:"folder,omitempty"`
+
+	// ResourcePool is the name or inventory path of the resource pool in which
+	// the resource pool of the cluster is created. Defaults to the default
+	// resource pool of the datacenter.
+	// +optional
+	ResourcePool string `json:"resourcePool,omitempty"`
+
+	// CPU is the allocation of the resource pool of the cluster, in MHz.
+	// +optional
+	CPU *ResourceAllocation `json:"cpu,omitempty"`
+
+	// Memory is the allocation of the resource pool of the cluster, in MiB.
+	// +optional
+	Memory *ResourceAllocation `json:"memory,omitempty"`
+
+	// Permission grants a role to a principal on the folder and the resource
+	// pool of the cluster, e.g. to the automation account of the cluster.
+	// The role is created with the cluster, and deleted with its permissions
+	// when the cluster is deleted or the permission unset.
+	// +optional
+	Permission *ClusterPermissionSpec `json:"permission,omitempty"`
+}
+
+// ClusterPermissionSpec defines the permission granted on the folder and the
+// resource pool of a cluster. Its role is named capv-<namespace>-<cluster
+// name>.
+type ClusterPermissionSpec struct {
+	// Principal is the user or group granted the role, e.g.
+	// VSPHERE.LOCAL\capv-team-a.
+	// +kubebuilder:validation:MinLength=1
+	Principal string `json:"principal"`
+
+	// Group is true when the principal is a group.
+	// +optional
+	Group bool `json:"group,omitempty"`
+
+	// Privileges are the IDs of the privileges of the role, e.g.
+	// VirtualMachine.Interact.PowerOn. Defaults to the privileges CAPV
+	// requires on the VMs and the resource pools to clone, configure, power
+	// and destroy the VMs.
+	// +optional
+	Privileges []string `json:"privileges,omitempty"`
+
+	// Propagate grants the role on the objects of the folder and the
+	// resource pool too. Defaults to true.
+	// +optional
+	Propagate *bool `json:"propagate,omitempty"`
+}
+
+// ResourceAllocation defines the reservation and limit of a resource of a
+// resource pool.
+type ResourceAllocation struct {
+	// Reservation is the amount of the resource guaranteed to the pool.
+	// Defaults to 0.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Reservation *int64 `json:"reservation,omitempty"`
+
+	// Limit is the maximum amount of the resource of the pool. Defaults to
+	// unlimited.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Limit *int64 `json:"limit,omitempty"`
+
+	// ExpandableReservation allows the reservation of the pool to grow beyond
+	// Reservation when the parent pool has unreserved resources. Defaults to
+	// true.
+	// +optional
+	ExpandableReservation *bool `json:"expandableReservation,omitempty"`
+}
+
+// ClusterModule holds the anti affinity construct `ClusterModule` identifier
+// in use by the VMs owned by the object referred by the TargetObjectName field.
+type ClusterModule struct {
+	// ControlPlane indicates whether the referred object is a K8s control plane
+	ControlPlane bool `json:"controlPlane"`
+
+	// TargetObjectName points to the object that uses the Cluster Module information to enforce
+	// anti-affinity amongst its descendant VM objects.
+	TargetObjectName string `json:"targetObjectName"`
+
+	// ModuleUUID is the unique identifier of the `ClusterModule` used by the object.
+	ModuleUUID string `json:"moduleUUID"`
 }
 
 // VSphereClusterStatus defines the observed state of VSphereClusterSpec
@@ -57,8 +410,301 @@ type VSphereClusterStatus struct {
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 
+	// V1Beta2 groups the fields of the VSphereCluster status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereClusterV1Beta2Status `json:"v1beta2,omitempty"`
+
 	// FailureDomains is a list of failure domain objects synced from the infrastructure provider.
 	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
+
+	// Placement holds the inventory paths of the VM folder and the resource
+	// pool created for the cluster when spec.placement is set.
+	// +optional
+	Placement *ClusterPlacementStatus `json:"placement,omitempty"`
+
+	// ControlPlaneEndpoint is the control plane endpoint provisioned when
+	// spec.controlPlaneEndpointProvider is set.
+	// +optional
+	ControlPlaneEndpoint *APIEndpoint `json:"controlPlaneEndpoint,omitempty"`
+
+	// ControlPlaneEndpointFailover reports the virtual IP addresses and the
+	// health probes of the control plane endpoint when
+	// spec.controlPlaneEndpointProvider.kubeVIP.failover is set.
+	// +optional
+	ControlPlaneEndpointFailover *ControlPlaneEndpointFailoverStatus `json:"controlPlaneEndpointFailover,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem
+	// reconciling the cluster, such as the rollback of its creation, and will
+	// contain a succinct value suitable for machine interpretation.
+	// +optional
+	FailureReason *capierrors.ClusterStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem
+	// reconciling the cluster and will contain a more verbose string suitable
+	// for logging and human consumption.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// CreationRollback reports the rollback of the creation of the cluster,
+	// once spec.creationRollback.timeout has expired.
+	// +optional
+	CreationRollback *ClusterCreationRollbackStatus `json:"creationRollback,omitempty"`
+
+	// Preflight reports the checks of the vCenter inventory referenced by
+	// the machines of the cluster, run before their VMs are cloned.
+	// +optional
+	Preflight *PreflightStatus `json:"preflight,omitempty"`
+}
+
+// PreflightStatus is the result of the preflight checks of the vCenter
+// inventory referenced by the machines of a cluster.
+type PreflightStatus struct {
+	// Checks are the checks of each object of the inventory, sorted by
+	// datacenter, kind and name.
+	// +optional
+	Checks []PreflightCheck `json:"checks,omitempty"`
+
+	// CheckedTime is the time the checks were last run.
+	CheckedTime metav1.Time `json:"checkedTime"`
+}
+
+// PreflightObjectKind is the kind of an object of the vCenter inventory
+// checked before the VMs of a cluster are cloned.
+type PreflightObjectKind string
+
+const (
+	// PreflightDatacenter is the datacenter of a VM.
+	PreflightDatacenter PreflightObjectKind = "Datacenter"
+
+	// PreflightDatastore is the datastore of a VM.
+	PreflightDatastore PreflightObjectKind = "Datastore"
+
+	// PreflightNetwork is a network of a VM.
+	PreflightNetwork PreflightObjectKind = "Network"
+
+	// PreflightFolder is the folder of a VM.
+	PreflightFolder PreflightObjectKind = "Folder"
+
+	// PreflightResourcePool is the resource pool of a VM.
+	PreflightResourcePool PreflightObjectKind = "ResourcePool"
+
+	// PreflightTemplate is the template a VM is cloned from.
+	PreflightTemplate PreflightObjectKind = "Template"
+)
+
+// PreflightCheckResult is the result of a preflight check.
+type PreflightCheckResult string
+
+const (
+	// PreflightPassed is the result of a check of an object which exists and
+	// on which the user of CAPV has the privileges required to clone VMs.
+	PreflightPassed PreflightCheckResult = "Passed"
+
+	// PreflightNotFound is the result of a check of an object which does not
+	// exist, or which the user of CAPV cannot see.
+	PreflightNotFound PreflightCheckResult = "NotFound"
+
+	// PreflightMissingPrivileges is the result of a check of an object on
+	// which the user of CAPV lacks privileges required to clone VMs.
+	PreflightMissingPrivileges PreflightCheckResult = "MissingPrivileges"
+
+	// PreflightFailed is the result of a check which could not be completed,
+	// e.g. because vCenter could not be reached.
+	PreflightFailed PreflightCheckResult = "Failed"
+)
+
+// PreflightCheck is the check of an object of the vCenter inventory.
+type PreflightCheck struct {
+	// Kind is the kind of the object.
+	Kind PreflightObjectKind `json:"kind"`
+
+	// Name is the name or the inventory path of the object, as set in the
+	// machines of the cluster.
+	Name string `json:"name"`
+
+	// Datacenter is the datacenter in which the object is found. It is empty
+	// for the default datacenter and for datacenters.
+	// +optional
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// Result is the result of the check.
+	Result PreflightCheckResult `json:"result"`
+
+	// MissingPrivileges are the privileges the user of CAPV lacks on the
+	// object, when the result is MissingPrivileges.
+	// +optional
+	MissingPrivileges []string `json:"missingPrivileges,omitempty"`
+
+	// Message describes the result of the check when it did not pass.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ClusterCreationRollbackStatus defines the observed state of the rollback
+// of the creation of a cluster.
+type ClusterCreationRollbackStatus struct {
+	// StartTime is the time at which the rollback started.
+	StartTime metav1.Time `json:"startTime"`
+
+	// CompletionTime is the time at which the VMs, the VM folder and the
+	// resource pool of the cluster were deleted.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Machines reports the state of the machines of the cluster when the
+	// rollback started.
+	// +optional
+	Machines []MachineRollbackReport `json:"machines,omitempty"`
+}
+
+// MachineRollbackReport reports the state of a machine of a cluster whose
+// creation is rolled back.
+type MachineRollbackReport struct {
+	// Name is the name of the Machine.
+	Name string `json:"name"`
+
+	// ControlPlane is true for the machines of the control plane.
+	// +optional
+	ControlPlane bool `json:"controlPlane,omitempty"`
+
+	// Phase is the phase of the Machine.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// VMAddresses are the IP addresses of the VSphereVM of the machine.
+	// +optional
+	VMAddresses []string `json:"vmAddresses,omitempty"`
+
+	// VMTask is the last task of the VSphereVM of the machine.
+	// +optional
+	VMTask *VirtualMachineTaskStatus `json:"vmTask,omitempty"`
+
+	// FailureMessage is the failure message of the Machine, its
+	// VSphereMachine or its VSphereVM.
+	// +optional
+	FailureMessage string `json:"failureMessage,omitempty"`
+
+	// Conditions are the conditions of the Machine, its VSphereMachine and its
+	// VSphereVM which were not true.
+	// +optional
+	Conditions []MachineRollbackCondition `json:"conditions,omitempty"`
+}
+
+// MachineRollbackCondition is a condition, which was not true, of an object
+// of a machine whose creation is rolled back.
+type MachineRollbackCondition struct {
+	// Kind is the kind of the object of the condition.
+	Kind string `json:"kind"`
+
+	// Type is the type of the condition.
+	Type clusterv1.ConditionType `json:"type"`
+
+	// Status is the status of the condition.
+	Status corev1.ConditionStatus `json:"status"`
+
+	// Reason is the reason of the condition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is the message of the condition.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ControlPlaneEndpointFailoverStatus defines the observed state of the
+// failover of a control plane endpoint.
+type ControlPlaneEndpointFailoverStatus struct {
+	// Addresses are the virtual IP addresses advertised by kube-vip, in the
+	// order in which they are promoted.
+	// +optional
+	Addresses []string `json:"addresses,omitempty"`
+
+	// ActiveAddress is the virtual IP address to which the hostname of the
+	// control plane endpoint must resolve.
+	// +optional
+	ActiveAddress string `json:"activeAddress,omitempty"`
+
+	// ConsecutiveFailures is the number of consecutive failed probes of the
+	// active address.
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// LastProbeTime is the time of the last probe of the active address.
+	// +optional
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+
+	// LastFailoverTime is the time at which the active address was last
+	// promoted.
+	// +optional
+	LastFailoverTime *metav1.Time `json:"lastFailoverTime,omitempty"`
+}
+
+// ClusterPlacementStatus defines the observed VM folder and resource pool of
+// a cluster.
+type ClusterPlacementStatus struct {
+	// Folder is the inventory path of the VM folder of the cluster.
+	// +optional
+	Folder string `json:"folder,omitempty"`
+
+	// ResourcePool is the inventory path of the resource pool of the cluster.
+	// +optional
+	ResourcePool string `json:"resourcePool,omitempty"`
+
+	// Permission is the permission granted on the folder and the resource
+	// pool of the cluster.
+	// +optional
+	Permission *ClusterPermissionStatus `json:"permission,omitempty"`
+}
+
+// ClusterPermissionStatus defines the observed permission granted on the
+// folder and the resource pool of a cluster.
+type ClusterPermissionStatus struct {
+	// Role is the name of the role created for the cluster.
+	Role string `json:"role"`
+
+	// RoleID is the ID of the role created for the cluster. A role of the
+	// same name with another ID was not created for the cluster, and is
+	// neither updated nor deleted.
+	// +optional
+	RoleID int32 `json:"roleID,omitempty"`
+
+	// Principal is the user or group granted the role.
+	Principal string `json:"principal"`
+
+	// Group is true when the principal is a group.
+	// +optional
+	Group bool `json:"group,omitempty"`
+
+	// Privileges are the IDs of the privileges of the role.
+	// +optional
+	Privileges []string `json:"privileges,omitempty"`
+
+	// Propagate is true when the role is granted on the objects of the
+	// folder and the resource pool too.
+	// +optional
+	Propagate bool `json:"propagate,omitempty"`
+
+	// Folder is the inventory path of the folder the role is granted on.
+	// +optional
+	Folder string `json:"folder,omitempty"`
+
+	// ResourcePool is the inventory path of the resource pool the role is
+	// granted on.
+	// +optional
+	ResourcePool string `json:"resourcePool,omitempty"`
+}
+
+// VSphereClusterV1Beta2Status groups the fields of the VSphereCluster status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereClusterV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereCluster using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -86,6 +732,22 @@ func (c *VSphereCluster) SetConditions(conditions clusterv1.Conditions) {
 	c.Status.Conditions = conditions
 }
 
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereCluster.
+func (c *VSphereCluster) GetV1Beta2Conditions() []metav1.Condition {
+	if c.Status.V1Beta2 == nil {
+		return nil
+	}
+	return c.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereCluster.
+func (c *VSphereCluster) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if c.Status.V1Beta2 == nil {
+		c.Status.V1Beta2 = &VSphereClusterV1Beta2Status{}
+	}
+	c.Status.V1Beta2.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // VSphereClusterList contains a list of VSphereCluster
@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"net"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/naming"
+)
+
+func (c *VSphereCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(c).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-vspherecluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vsphereclusters,versions=v1beta1,name=validation.vspherecluster.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &VSphereCluster{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (c *VSphereCluster) ValidateCreate() error {
+	var allErrs field.ErrorList
+	spec := c.Spec
+
+	allErrs = append(allErrs, validateControlPlaneEndpointProvider(field.NewPath("spec", "controlPlaneEndpointProvider"), spec.ControlPlaneEndpointProvider)...)
+	allErrs = append(allErrs, validateClusterNetworkSettings(field.NewPath("spec", "networkSettings"), spec.NetworkSettings)...)
+	allErrs = append(allErrs, validateClusterCreationRollback(field.NewPath("spec", "creationRollback"), spec.CreationRollback)...)
+	allErrs = append(allErrs, validateNamingStrategy(field.NewPath("spec", "naming"), spec.Naming)...)
+
+	return aggregateObjErrors(c.GroupVersionKind().GroupKind(), c.Name, allErrs)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// The server and the control plane endpoint can be set once, e.g. by the
+// endpoint provider, but not changed afterwards, since the VMs and the
+// kubeconfig of the cluster depend on them. The placement template cannot be
+// changed either, as the folder and the resource pool of the cluster are
+// named after it.
+func (c *VSphereCluster) ValidateUpdate(oldRaw runtime.Object) error {
+	var allErrs field.ErrorList
+	old := oldRaw.(*VSphereCluster) //nolint:forcetypeassert
+	spec := c.Spec
+
+	if old.Spec.Server != "" && spec.Server != old.Spec.Server {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "server"), "cannot be modified"))
+	}
+	if !old.Spec.ControlPlaneEndpoint.IsZero() && spec.ControlPlaneEndpoint != old.Spec.ControlPlaneEndpoint {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "controlPlaneEndpoint"), "cannot be modified"))
+	}
+	if old.Spec.Naming != nil && old.Spec.Naming.Placement != "" && (spec.Naming == nil || spec.Naming.Placement != old.Spec.Naming.Placement) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "naming", "placement"), "cannot be modified"))
+	}
+
+	allErrs = append(allErrs, validateControlPlaneEndpointProvider(field.NewPath("spec", "controlPlaneEndpointProvider"), spec.ControlPlaneEndpointProvider)...)
+	allErrs = append(allErrs, validateClusterNetworkSettings(field.NewPath("spec", "networkSettings"), spec.NetworkSettings)...)
+	allErrs = append(allErrs, validateClusterCreationRollback(field.NewPath("spec", "creationRollback"), spec.CreationRollback)...)
+	allErrs = append(allErrs, validateNamingStrategy(field.NewPath("spec", "naming"), spec.Naming)...)
+
+	return aggregateObjErrors(c.GroupVersionKind().GroupKind(), c.Name, allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (c *VSphereCluster) ValidateDelete() error {
+	return nil
+}
+
+func validateControlPlaneEndpointProvider(fldPath *field.Path, provider *ControlPlaneEndpointProviderSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if provider == nil {
+		return allErrs
+	}
+	switch {
+	case provider.KubeVIP == nil && provider.AVI == nil:
+		allErrs = append(allErrs, field.Required(fldPath, "exactly one of kubeVIP and avi must be set"))
+	case provider.KubeVIP != nil && provider.AVI != nil:
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("avi"), "cannot be set together with kubeVIP"))
+	}
+	if provider.KubeVIP != nil {
+		pool := provider.KubeVIP.AddressFromPool
+		poolPath := fldPath.Child("kubeVIP", "addressFromPool")
+		if pool.APIGroup == nil || *pool.APIGroup == "" {
+			allErrs = append(allErrs, field.Required(poolPath.Child("apiGroup"), "must be set"))
+		}
+		if pool.Kind == "" {
+			allErrs = append(allErrs, field.Required(poolPath.Child("kind"), "must be set"))
+		}
+		if pool.Name == "" {
+			allErrs = append(allErrs, field.Required(poolPath.Child("name"), "must be set"))
+		}
+	}
+	return allErrs
+}
+
+func validateClusterNetworkSettings(fldPath *field.Path, settings *ClusterNetworkSettings) field.ErrorList {
+	var allErrs field.ErrorList
+	if settings == nil {
+		return allErrs
+	}
+	for i, nameserver := range settings.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("nameservers").Index(i), nameserver, "must be an IPv4 or IPv6 address"))
+		}
+	}
+	return allErrs
+}
+
+func validateClusterCreationRollback(fldPath *field.Path, rollback *ClusterCreationRollbackSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if rollback == nil {
+		return allErrs
+	}
+	if rollback.Timeout.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("timeout"), rollback.Timeout.Duration.String(), "must be greater than 0"))
+	}
+	return allErrs
+}
+
+func validateNamingStrategy(fldPath *field.Path, strategy *NamingStrategy) field.ErrorList {
+	var allErrs field.ErrorList
+	if strategy == nil {
+		return allErrs
+	}
+	if strategy.VM != "" {
+		if err := naming.ValidateVMTemplate(strategy.VM); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("vm"), strategy.VM, err.Error()))
+		}
+	}
+	if strategy.Placement != "" {
+		if err := naming.ValidatePlacementTemplate(strategy.Placement); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("placement"), strategy.Placement, err.Error()))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestVSphereCluster_ValidateCreate(t *testing.T) {
+	kubeVIP := &KubeVIPEndpointSpec{
+		AddressFromPool: corev1.TypedLocalObjectReference{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "vips"},
+	}
+
+	tests := []struct {
+		name    string
+		spec    VSphereClusterSpec
+		wantErr bool
+	}{
+		{
+			name: "no endpoint provider",
+			spec: VSphereClusterSpec{Server: "vcenter.example.com"},
+		},
+		{
+			name: "kube-vip endpoint provider",
+			spec: VSphereClusterSpec{ControlPlaneEndpointProvider: &ControlPlaneEndpointProviderSpec{KubeVIP: kubeVIP}},
+		},
+		{
+			name:    "empty endpoint provider",
+			spec:    VSphereClusterSpec{ControlPlaneEndpointProvider: &ControlPlaneEndpointProviderSpec{}},
+			wantErr: true,
+		},
+		{
+			name: "kube-vip and avi endpoint providers",
+			spec: VSphereClusterSpec{ControlPlaneEndpointProvider: &ControlPlaneEndpointProviderSpec{
+				KubeVIP: kubeVIP,
+				AVI:     &AVIEndpointSpec{Controller: "https://avi.example.com"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "kube-vip pool without a kind",
+			spec: VSphereClusterSpec{ControlPlaneEndpointProvider: &ControlPlaneEndpointProviderSpec{
+				KubeVIP: &KubeVIPEndpointSpec{AddressFromPool: corev1.TypedLocalObjectReference{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Name: "vips"}},
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid nameserver",
+			spec:    VSphereClusterSpec{NetworkSettings: &ClusterNetworkSettings{Nameservers: []string{"10.0.0.2", "dns.example.com"}}},
+			wantErr: true,
+		},
+		{
+			name: "creation rollback",
+			spec: VSphereClusterSpec{CreationRollback: &ClusterCreationRollbackSpec{Timeout: metav1.Duration{Duration: 30 * time.Minute}}},
+		},
+		{
+			name:    "creation rollback without a timeout",
+			spec:    VSphereClusterSpec{CreationRollback: &ClusterCreationRollbackSpec{}},
+			wantErr: true,
+		},
+		{
+			name: "naming strategy",
+			spec: VSphereClusterSpec{Naming: &NamingStrategy{
+				VM:        "{{.ClusterName}}-{{.MachineName | trunc 20}}-{{.RandomSuffix}}",
+				Placement: "capv-{{.Namespace}}-{{.ClusterName}}",
+			}},
+		},
+		{
+			name:    "VM name template without the machine name or a random suffix",
+			spec:    VSphereClusterSpec{Naming: &NamingStrategy{VM: "{{.ClusterName}}"}},
+			wantErr: true,
+		},
+		{
+			name:    "placement name template with a random suffix",
+			spec:    VSphereClusterSpec{Naming: &NamingStrategy{Placement: "{{.ClusterName}}-{{.RandomSuffix}}"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cluster := &VSphereCluster{Spec: tt.spec}
+			if tt.wantErr {
+				g.Expect(cluster.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(cluster.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestVSphereCluster_ValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldSpec VSphereClusterSpec
+		spec    VSphereClusterSpec
+		wantErr bool
+	}{
+		{
+			name:    "server set",
+			oldSpec: VSphereClusterSpec{},
+			spec:    VSphereClusterSpec{Server: "vcenter.example.com"},
+		},
+		{
+			name:    "server changed",
+			oldSpec: VSphereClusterSpec{Server: "vcenter.example.com"},
+			spec:    VSphereClusterSpec{Server: "other.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "thumbprint changed",
+			oldSpec: VSphereClusterSpec{Server: "vcenter.example.com", Thumbprint: "AA:BB"},
+			spec:    VSphereClusterSpec{Server: "vcenter.example.com", Thumbprint: "CC:DD"},
+		},
+		{
+			name:    "control plane endpoint set",
+			oldSpec: VSphereClusterSpec{},
+			spec:    VSphereClusterSpec{ControlPlaneEndpoint: APIEndpoint{Host: "10.0.0.10", Port: 6443}},
+		},
+		{
+			name:    "control plane endpoint changed",
+			oldSpec: VSphereClusterSpec{ControlPlaneEndpoint: APIEndpoint{Host: "10.0.0.10", Port: 6443}},
+			spec:    VSphereClusterSpec{ControlPlaneEndpoint: APIEndpoint{Host: "10.0.0.11", Port: 6443}},
+			wantErr: true,
+		},
+		{
+			name:    "VM name template changed",
+			oldSpec: VSphereClusterSpec{Naming: &NamingStrategy{VM: "{{.MachineName}}"}},
+			spec:    VSphereClusterSpec{Naming: &NamingStrategy{VM: "{{.ClusterName}}-{{.RandomSuffix}}"}},
+		},
+		{
+			name:    "placement name template changed",
+			oldSpec: VSphereClusterSpec{Naming: &NamingStrategy{Placement: "{{.ClusterName}}"}},
+			spec:    VSphereClusterSpec{Naming: &NamingStrategy{Placement: "capv-{{.ClusterName}}"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			oldCluster := &VSphereCluster{Spec: tt.oldSpec}
+			cluster := &VSphereCluster{Spec: tt.spec}
+			if tt.wantErr {
+				g.Expect(cluster.ValidateUpdate(oldCluster)).NotTo(Succeed())
+			} else {
+				g.Expect(cluster.ValidateUpdate(oldCluster)).To(Succeed())
+			}
+		})
+	}
+}
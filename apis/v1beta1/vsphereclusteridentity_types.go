@@ -24,6 +24,12 @@ import (
 
 const (
 	SecretIdentitySetFinalizer = "vspherecluster/infrastructure.cluster.x-k8s.io"
+
+	// IdentitySecretLabel is set to "true" on the Secrets holding the vCenter
+	// credentials of the VSphereClusters and the VSphereClusterIdentities, so
+	// that they are watched for their rotation whatever the cache label
+	// selector of the manager.
+	IdentitySecretLabel = "vspherecluster.infrastructure.cluster.x-k8s.io/identity-secret"
 )
 
 type VSphereClusterIdentitySpec struct {
@@ -45,6 +51,23 @@ type VSphereClusterIdentityStatus struct {
 	// Conditions defines current service state of the VSphereCluster.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereClusterIdentity status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereClusterIdentityV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereClusterIdentityV1Beta2Status groups the fields of the VSphereClusterIdentity status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereClusterIdentityV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereClusterIdentity using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 type AllowedNamespaces struct {
@@ -78,6 +101,22 @@ func (c *VSphereClusterIdentity) SetConditions(conditions clusterv1.Conditions)
 	c.Status.Conditions = conditions
 }
 
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereClusterIdentity.
+func (c *VSphereClusterIdentity) GetV1Beta2Conditions() []metav1.Condition {
+	if c.Status.V1Beta2 == nil {
+		return nil
+	}
+	return c.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereClusterIdentity.
+func (c *VSphereClusterIdentity) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if c.Status.V1Beta2 == nil {
+		c.Status.V1Beta2 = &VSphereClusterIdentityV1Beta2Status{}
+	}
+	c.Status.V1Beta2.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:path=vsphereclusteridentities,scope=Cluster,categories=cluster-api
 // +kubebuilder:storageversion
@@ -37,7 +37,16 @@ var _ webhook.Validator = &VSphereClusterTemplate{}
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
 func (r *VSphereClusterTemplate) ValidateCreate() error {
-	return nil
+	var allErrs field.ErrorList
+
+	// The cluster modules are created in vCenter for each cluster, and
+	// recorded in its spec by CAPV.
+	if len(r.Spec.Template.Spec.ClusterModules) != 0 {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "template", "spec", "clusterModules"), "cannot be set in templates"))
+	}
+	allErrs = append(allErrs, validateNamingStrategy(field.NewPath("spec", "template", "spec", "naming"), r.Spec.Template.Spec.Naming)...)
+
+	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestVSphereClusterTemplate_ValidateCreate(t *testing.T) {
+	g := NewWithT(t)
+
+	clusterTemplate := &VSphereClusterTemplate{
+		Spec: VSphereClusterTemplateSpec{
+			Template: VSphereClusterTemplateResource{
+				Spec: VSphereClusterSpec{Server: "vcenter.example.com"},
+			},
+		},
+	}
+	g.Expect(clusterTemplate.ValidateCreate()).To(Succeed())
+
+	clusterTemplate.Spec.Template.Spec.ClusterModules = []ClusterModule{{TargetObjectName: "md-0", ModuleUUID: "uuid"}}
+	g.Expect(clusterTemplate.ValidateCreate()).NotTo(Succeed())
+}
+
+func TestVSphereClusterTemplate_ValidateUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	oldClusterTemplate := &VSphereClusterTemplate{
+		Spec: VSphereClusterTemplateSpec{
+			Template: VSphereClusterTemplateResource{
+				Spec: VSphereClusterSpec{Server: "vcenter.example.com"},
+			},
+		},
+	}
+	clusterTemplate := oldClusterTemplate.DeepCopy()
+	clusterTemplate.Labels = map[string]string{"foo": "bar"}
+	g.Expect(clusterTemplate.ValidateUpdate(oldClusterTemplate)).To(Succeed())
+
+	clusterTemplate.Spec.Template.Spec.Server = "other.example.com"
+	g.Expect(clusterTemplate.ValidateUpdate(oldClusterTemplate)).NotTo(Succeed())
+}
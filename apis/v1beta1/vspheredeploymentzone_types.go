@@ -82,6 +82,23 @@ type VSphereDeploymentZoneStatus struct {
 	// Conditions defines current service state of the VSphereMachine.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereDeploymentZone status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereDeploymentZoneV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereDeploymentZoneV1Beta2Status groups the fields of the VSphereDeploymentZone status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereDeploymentZoneV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereDeploymentZone using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -106,6 +123,22 @@ func (z *VSphereDeploymentZone) SetConditions(conditions clusterv1.Conditions) {
 	z.Status.Conditions = conditions
 }
 
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereDeploymentZone.
+func (z *VSphereDeploymentZone) GetV1Beta2Conditions() []metav1.Condition {
+	if z.Status.V1Beta2 == nil {
+		return nil
+	}
+	return z.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereDeploymentZone.
+func (z *VSphereDeploymentZone) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if z.Status.V1Beta2 == nil {
+		z.Status.V1Beta2 = &VSphereDeploymentZoneV1Beta2Status{}
+	}
+	z.Status.V1Beta2.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // VSphereDeploymentZoneList contains a list of VSphereDeploymentZone
@@ -18,6 +18,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/errors"
@@ -28,6 +29,30 @@ const (
 	// resources associated with VSphereMachine before removing it from the
 	// API Server.
 	MachineFinalizer = "vspheremachine.infrastructure.cluster.x-k8s.io"
+
+	// NodeESXiHostLabel is the label of a Node set to the name of the ESXi
+	// host of the VM of its machine.
+	NodeESXiHostLabel = "vspheremachine.infrastructure.cluster.x-k8s.io/esxi-host"
+
+	// NodeDatastoreLabel is the label of a Node set to the name of the
+	// datastore of the VM of its machine.
+	NodeDatastoreLabel = "vspheremachine.infrastructure.cluster.x-k8s.io/datastore"
+
+	// NodeTopologyLabelsAnnotation is the annotation of a Node set to the
+	// comma-separated keys of the topology labels set by CAPV, so that the
+	// labels which are no longer part of the topology are removed.
+	NodeTopologyLabelsAnnotation = "vspheremachine.infrastructure.cluster.x-k8s.io/topology-labels"
+
+	// MachineEstimatedHourlyCostAnnotation is the annotation of a
+	// VSphereMachine set to the estimated hourly cost of its VM, when the
+	// cost weights of the controller manager are set.
+	MachineEstimatedHourlyCostAnnotation = "vspheremachine.infrastructure.cluster.x-k8s.io/estimated-hourly-cost"
+
+	// MachineProvisioningDurationAnnotation is the annotation of a
+	// VSphereMachine set to "pending" while the Node of its Machine has not
+	// joined the cluster yet, then to the provisioning duration of the Machine
+	// once it is observed, e.g. "7m12s".
+	MachineProvisioningDurationAnnotation = "vspheremachine.infrastructure.cluster.x-k8s.io/provisioning-duration"
 )
 
 // VSphereMachineSpec defines the desired state of VSphereMachine
@@ -42,6 +67,12 @@ type VSphereMachineSpec struct {
 	// FailureDomain is the failure domain unique identifier this Machine should be attached to, as defined in Cluster API.
 	// For this infrastructure provider, the name is equivalent to the name of the VSphereDeploymentZone.
 	FailureDomain *string `json:"failureDomain,omitempty"`
+
+	// ImageRef is a reference to the VSphereMachineImage, in the namespace of
+	// the VSphereMachine, whose resolved template is used to clone the
+	// virtual machine. ImageRef and Template are mutually exclusive.
+	// +optional
+	ImageRef *corev1.LocalObjectReference `json:"imageRef,omitempty"`
 }
 
 // VSphereMachineStatus defines the observed state of VSphereMachine
@@ -58,6 +89,10 @@ type VSphereMachineStatus struct {
 	// +optional
 	Network []NetworkStatus `json:"network,omitempty"`
 
+	// Topology is the placement of the VM of the machine, as last observed.
+	// +optional
+	Topology *MachineTopologyStatus `json:"topology,omitempty"`
+
 	// FailureReason will be set in the event that there is a terminal problem
 	// reconciling the Machine and will contain a succinct value suitable
 	// for machine interpretation.
@@ -99,6 +134,43 @@ type VSphereMachineStatus struct {
 	// Conditions defines current service state of the VSphereMachine.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereMachine status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereMachineV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereMachineV1Beta2Status groups the fields of the VSphereMachine status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereMachineV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereMachine using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// MachineTopologyStatus is the placement of the VM of a machine.
+type MachineTopologyStatus struct {
+	// Region is the name of the region of the failure domain of the machine.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Zone is the name of the zone of the failure domain of the machine.
+	// +optional
+	Zone string `json:"zone,omitempty"`
+
+	// Host is the name of the ESXi host of the VM.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Datastore is the name of the datastore of the configuration files of
+	// the VM.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -127,6 +199,22 @@ func (m *VSphereMachine) SetConditions(conditions clusterv1.Conditions) {
 	m.Status.Conditions = conditions
 }
 
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereMachine.
+func (m *VSphereMachine) GetV1Beta2Conditions() []metav1.Condition {
+	if m.Status.V1Beta2 == nil {
+		return nil
+	}
+	return m.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereMachine.
+func (m *VSphereMachine) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if m.Status.V1Beta2 == nil {
+		m.Status.V1Beta2 = &VSphereMachineV1Beta2Status{}
+	}
+	m.Status.V1Beta2.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // VSphereMachineList contains a list of VSphereMachine
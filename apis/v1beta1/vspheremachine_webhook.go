@@ -27,6 +27,8 @@ import (
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 )
 
 func (m *VSphereMachine) SetupWebhookWithManager(mgr ctrl.Manager) error {
@@ -65,6 +67,27 @@ func (m *VSphereMachine) ValidateCreate() error {
 		}
 	}
 
+	allErrs = append(allErrs, validateAddressesFromPools(field.NewPath("spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateNetworkAdapters(field.NewPath("spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateNetworkDeviceRoles(field.NewPath("spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateNetworkDeviceGateways(field.NewPath("spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateHostnameStrategy(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePowerOffMode(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateTPM(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateEncryption(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateHostAffinity(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateTimeSync(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateOS(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePCIDevices(field.NewPath("spec", "pciDevices"), spec.PCIDevices)...)
+	allErrs = append(allErrs, validateDataDisks(field.NewPath("spec", "dataDisks"), spec.DataDisks)...)
+	allErrs = append(allErrs, validateCloneMode(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateCustomVMXKeys(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateWindowsActivation(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePublishedAddresses(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateExistingVM(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateDeletionPolicy(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateTemplateSource(field.NewPath("spec"), spec)...)
+
 	return aggregateObjErrors(m.GroupVersionKind().GroupKind(), m.Name, allErrs)
 }
 
@@ -90,6 +113,19 @@ func (m *VSphereMachine) ValidateUpdate(old runtime.Object) error {
 	delete(oldVSphereMachineSpec, "providerID")
 	delete(newVSphereMachineSpec, "providerID")
 
+	// allow changes to deletionPolicy, so the VM can be retained before the
+	// machine is deleted
+	delete(oldVSphereMachineSpec, "deletionPolicy")
+	delete(newVSphereMachineSpec, "deletionPolicy")
+
+	// allow changes to the resources when they can be updated in place
+	if feature.Gates.Enabled(feature.InPlaceResourceUpdate) {
+		for _, key := range inPlaceUpdatableFields {
+			delete(oldVSphereMachineSpec, key)
+			delete(newVSphereMachineSpec, key)
+		}
+	}
+
 	newVSphereMachineNetwork := newVSphereMachineSpec["network"].(map[string]interface{})
 	oldVSphereMachineNetwork := oldVSphereMachineSpec["network"].(map[string]interface{})
 
@@ -107,6 +143,17 @@ func (m *VSphereMachine) ValidateUpdate(old runtime.Object) error {
 		}
 	}
 
+	// reject the added devices which cannot be hot-added to the running VM
+	if oldMachine, ok := old.(*VSphereMachine); ok && feature.Gates.Enabled(feature.NetworkDeviceHotAdd) {
+		allErrs = append(allErrs, validateNetworkDeviceHotAdd(field.NewPath("spec", "network", "devices"), oldMachine.Spec.Network.Devices, m.Spec.Network.Devices)...)
+	}
+
+	// reject retaining a VM with pool addresses, unless it was admitted
+	// before it was rejected
+	if oldMachine, ok := old.(*VSphereMachine); ok && len(validateDeletionPolicy(field.NewPath("spec"), oldMachine.Spec.VirtualMachineCloneSpec)) == 0 {
+		allErrs = append(allErrs, validateDeletionPolicy(field.NewPath("spec"), m.Spec.VirtualMachineCloneSpec)...)
+	}
+
 	if !reflect.DeepEqual(oldVSphereMachineSpec, newVSphereMachineSpec) {
 		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "cannot be modified"))
 	}
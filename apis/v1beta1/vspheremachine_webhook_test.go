@@ -18,8 +18,15 @@ package v1beta1
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 )
 
 var someProviderID = "vsphere://42305f0b-dad7-1d3d-5727-0eaffffffffc"
@@ -64,6 +71,383 @@ func TestVSphereMachine_ValidateCreate(t *testing.T) {
 			vsphereMachine: createVSphereMachine("foo.com", nil, "", []string{"192.168.0.1/32", "192.168.0.3/32"}),
 			wantErr:        false,
 		},
+		{
+			name:           "PCI device without vendorId",
+			vsphereMachine: createVSphereMachineWithPCIDevices(PCIDeviceSpec{DeviceID: pointer.Int32(7864)}),
+			wantErr:        true,
+		},
+		{
+			name:           "PCI device with both vGPU profile and deviceId",
+			vsphereMachine: createVSphereMachineWithPCIDevices(PCIDeviceSpec{DeviceID: pointer.Int32(7864), VendorID: pointer.Int32(4318), VGPUProfile: "grid_t4-4c"}),
+			wantErr:        true,
+		},
+		{
+			name: "successful VSphereMachine creation with PCI devices",
+			vsphereMachine: createVSphereMachineWithPCIDevices(
+				PCIDeviceSpec{DeviceID: pointer.Int32(7864), VendorID: pointer.Int32(4318)},
+				PCIDeviceSpec{VGPUProfile: "grid_t4-4c"}),
+			wantErr: false,
+		},
+		{
+			name:           "fqdn hostname strategy without domain",
+			vsphereMachine: createVSphereMachineWithHostnameStrategy(FQDNHostnameStrategy, ""),
+			wantErr:        true,
+		},
+		{
+			name:           "fqdn hostname strategy with an invalid domain",
+			vsphereMachine: createVSphereMachineWithHostnameStrategy(FQDNHostnameStrategy, "Example_Domain"),
+			wantErr:        true,
+		},
+		{
+			name:           "hostname domain without fqdn hostname strategy",
+			vsphereMachine: createVSphereMachineWithHostnameStrategy(VMNameHostnameStrategy, "example.com"),
+			wantErr:        true,
+		},
+		{
+			name:           "successful VSphereMachine creation with fqdn hostname strategy",
+			vsphereMachine: createVSphereMachineWithHostnameStrategy(FQDNHostnameStrategy, "example.com"),
+			wantErr:        false,
+		},
+		{
+			name: "multiple management network devices",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "mgmt", Role: NetworkDeviceRoleManagement, DHCP4: true},
+				NetworkDeviceSpec{NetworkName: "mgmt-2", Role: NetworkDeviceRoleManagement, DHCP4: true}),
+			wantErr: true,
+		},
+		{
+			name: "workload network device with a gateway",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "mgmt", Role: NetworkDeviceRoleManagement, DHCP4: true},
+				NetworkDeviceSpec{NetworkName: "workload", Role: NetworkDeviceRoleWorkload, IPAddrs: []string{"10.0.0.10/24"}, Gateway4: "10.0.0.1"}),
+			wantErr: true,
+		},
+		{
+			name: "workload network device with SLAAC",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "mgmt", Role: NetworkDeviceRoleManagement, DHCP4: true},
+				NetworkDeviceSpec{NetworkName: "workload", Role: NetworkDeviceRoleWorkload, SLAAC: true}),
+			wantErr: true,
+		},
+		{
+			name: "IPv6 gateway set as gateway4",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "vm-network", IPAddrs: []string{"fd00::10/64"}, Gateway4: "fd00::1"}),
+			wantErr: true,
+		},
+		{
+			name: "IPv4 gateway set as gateway6",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "vm-network", IPAddrs: []string{"10.0.0.10/24"}, Gateway6: "10.0.0.1"}),
+			wantErr: true,
+		},
+		{
+			name: "successful VSphereMachine creation with a dual-stack network device",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "vm-network", IPAddrs: []string{"10.0.0.10/24", "fd00::10/64"}, Gateway4: "10.0.0.1", Gateway6: "fd00::1"}),
+			wantErr: false,
+		},
+		{
+			name: "successful VSphereMachine creation with management and workload network devices",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "mgmt", Role: NetworkDeviceRoleManagement, DHCP4: true},
+				NetworkDeviceSpec{NetworkName: "workload", Role: NetworkDeviceRoleWorkload, IPAddrs: []string{"10.0.0.10/24"},
+					Routes: []NetworkRouteSpec{{To: "10.1.0.0/16", Via: "10.0.0.1", Metric: 100}}}),
+			wantErr: false,
+		},
+		{
+			name: "successful VSphereMachine creation with addresses from an IPAM pool",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "vm-network", AddressesFromPools: []corev1.TypedLocalObjectReference{
+					{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"},
+				}}),
+			wantErr: false,
+		},
+		{
+			name: "successful VSphereMachine creation with a pinned MAC address and an SR-IOV adapter",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "mgmt", DHCP4: true, MACAddr: "00:50:56:00:00:01"},
+				NetworkDeviceSpec{NetworkName: "data", DHCP4: true, AdapterType: NetworkAdapterTypeSRIOV, PhysicalFunction: "0000:3b:00.0"}),
+			wantErr: false,
+		},
+		{
+			name: "invalid MAC address",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "mgmt", DHCP4: true, MACAddr: "00:50:56:00:00"}),
+			wantErr: true,
+		},
+		{
+			name: "SR-IOV adapter without physical function",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "data", DHCP4: true, AdapterType: NetworkAdapterTypeSRIOV}),
+			wantErr: true,
+		},
+		{
+			name: "physical function on a vmxnet3 adapter",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "data", DHCP4: true, PhysicalFunction: "0000:3b:00.0"}),
+			wantErr: true,
+		},
+		{
+			name:           "linked clone from a snapshot",
+			vsphereMachine: createVSphereMachineWithCloneMode(LinkedClone, "base"),
+			wantErr:        false,
+		},
+		{
+			name:           "full clone from a snapshot",
+			vsphereMachine: createVSphereMachineWithCloneMode(FullClone, "base"),
+			wantErr:        true,
+		},
+		{
+			name: "data disks",
+			vsphereMachine: createVSphereMachineWithDataDisks(
+				DataDiskSpec{Name: "etcd", SizeGiB: 10, ProvisioningMode: EagerlyZeroedProvisioningMode},
+				DataDiskSpec{Name: "containerd", SizeGiB: 50, StoragePolicyName: "fast"}),
+			wantErr: false,
+		},
+		{
+			name: "data disks with the same name",
+			vsphereMachine: createVSphereMachineWithDataDisks(
+				DataDiskSpec{Name: "etcd", SizeGiB: 10},
+				DataDiskSpec{Name: "etcd", SizeGiB: 20}),
+			wantErr: true,
+		},
+		{
+			name:           "data disk without size",
+			vsphereMachine: createVSphereMachineWithDataDisks(DataDiskSpec{Name: "etcd"}),
+			wantErr:        true,
+		},
+		{
+			name: "data disks on dedicated and shared controllers",
+			vsphereMachine: createVSphereMachineWithDataDisks(
+				DataDiskSpec{Name: "etcd", SizeGiB: 10, Controller: &DataDiskControllerSpec{Type: ParaVirtualDiskControllerType, Dedicated: true, ExtraConfig: map[string]string{"queueDepth": "254"}}},
+				DataDiskSpec{Name: "containerd", SizeGiB: 50, Controller: &DataDiskControllerSpec{Type: NVMeDiskControllerType, ExtraConfig: map[string]string{"option": "1"}}},
+				DataDiskSpec{Name: "logs", SizeGiB: 20, Controller: &DataDiskControllerSpec{Type: NVMeDiskControllerType, ExtraConfig: map[string]string{"option": "1"}}}),
+			wantErr: false,
+		},
+		{
+			name: "data disk with an unknown controller type",
+			vsphereMachine: createVSphereMachineWithDataDisks(
+				DataDiskSpec{Name: "etcd", SizeGiB: 10, Controller: &DataDiskControllerSpec{Type: "LsiLogic"}}),
+			wantErr: true,
+		},
+		{
+			name: "data disk with a controller option set by vSphere",
+			vsphereMachine: createVSphereMachineWithDataDisks(
+				DataDiskSpec{Name: "etcd", SizeGiB: 10, Controller: &DataDiskControllerSpec{Type: ParaVirtualDiskControllerType, ExtraConfig: map[string]string{"virtualDev": "lsilogic"}}}),
+			wantErr: true,
+		},
+		{
+			name: "data disks setting an option of a shared controller to different values",
+			vsphereMachine: createVSphereMachineWithDataDisks(
+				DataDiskSpec{Name: "etcd", SizeGiB: 10, Controller: &DataDiskControllerSpec{Type: NVMeDiskControllerType, ExtraConfig: map[string]string{"option": "1"}}},
+				DataDiskSpec{Name: "logs", SizeGiB: 20, Controller: &DataDiskControllerSpec{Type: NVMeDiskControllerType, ExtraConfig: map[string]string{"option": "2"}}}),
+			wantErr: true,
+		},
+		{
+			name: "data disks on too many dedicated controllers",
+			vsphereMachine: createVSphereMachineWithDataDisks(
+				DataDiskSpec{Name: "a", SizeGiB: 1, Controller: &DataDiskControllerSpec{Type: NVMeDiskControllerType, Dedicated: true}},
+				DataDiskSpec{Name: "b", SizeGiB: 1, Controller: &DataDiskControllerSpec{Type: NVMeDiskControllerType, Dedicated: true}},
+				DataDiskSpec{Name: "c", SizeGiB: 1, Controller: &DataDiskControllerSpec{Type: NVMeDiskControllerType, Dedicated: true}},
+				DataDiskSpec{Name: "d", SizeGiB: 1, Controller: &DataDiskControllerSpec{Type: NVMeDiskControllerType, Dedicated: true}},
+				DataDiskSpec{Name: "e", SizeGiB: 1, Controller: &DataDiskControllerSpec{Type: NVMeDiskControllerType, Dedicated: true}}),
+			wantErr: true,
+		},
+		{
+			name:           "trySoft power off with a guest shutdown timeout",
+			vsphereMachine: createVSphereMachineWithPowerOffMode(TrySoftPowerOffMode, &metav1.Duration{Duration: 10 * time.Minute}),
+			wantErr:        false,
+		},
+		{
+			name:           "soft power off with a guest shutdown timeout",
+			vsphereMachine: createVSphereMachineWithPowerOffMode(SoftPowerOffMode, &metav1.Duration{Duration: 10 * time.Minute}),
+			wantErr:        true,
+		},
+		{
+			name:           "trySoft power off with a zero guest shutdown timeout",
+			vsphereMachine: createVSphereMachineWithPowerOffMode(TrySoftPowerOffMode, &metav1.Duration{}),
+			wantErr:        true,
+		},
+		{
+			name:           "virtual TPM with a storage policy",
+			vsphereMachine: createVSphereMachineWithTPM("encryption-policy"),
+			wantErr:        false,
+		},
+		{
+			name:           "virtual TPM without storage policy",
+			vsphereMachine: createVSphereMachineWithTPM(""),
+			wantErr:        true,
+		},
+		{
+			name:           "encryption with a storage policy",
+			vsphereMachine: createVSphereMachineWithEncryption("encryption-policy"),
+			wantErr:        false,
+		},
+		{
+			name:           "encryption without storage policy",
+			vsphereMachine: createVSphereMachineWithEncryption(""),
+			wantErr:        true,
+		},
+		{
+			name:           "Windows guest with a sysprep customization spec",
+			vsphereMachine: createVSphereMachineWithOS(WindowsOS, "windows2019srv_64Guest", "sysprep"),
+			wantErr:        false,
+		},
+		{
+			name:           "Windows guest with a Linux guest ID",
+			vsphereMachine: createVSphereMachineWithOS(WindowsOS, "ubuntu64Guest", ""),
+			wantErr:        true,
+		},
+		{
+			name:           "Linux guest with a Windows guest ID",
+			vsphereMachine: createVSphereMachineWithOS("", "windows2019srv_64Guest", ""),
+			wantErr:        true,
+		},
+		{
+			name:           "Linux guest with a customization spec",
+			vsphereMachine: createVSphereMachineWithOS(LinuxOS, "ubuntu64Guest", "sysprep"),
+			wantErr:        true,
+		},
+		{
+			name:           "Windows activation with a KMS server and a product key",
+			vsphereMachine: createVSphereMachineWithWindowsActivation("sysprep", "kms.example.com:1688", "windows-product-key"),
+			wantErr:        false,
+		},
+		{
+			name:           "Windows activation with an IPv6 KMS server",
+			vsphereMachine: createVSphereMachineWithWindowsActivation("sysprep", "[fd00::10]:1688", ""),
+			wantErr:        false,
+		},
+		{
+			name:           "Windows activation without a customization spec",
+			vsphereMachine: createVSphereMachineWithWindowsActivation("", "kms.example.com", ""),
+			wantErr:        true,
+		},
+		{
+			name:           "Windows activation with a KMS server injecting a command",
+			vsphereMachine: createVSphereMachineWithWindowsActivation("sysprep", "kms.example.com & shutdown /s", ""),
+			wantErr:        true,
+		},
+		{
+			name:           "Windows activation with an invalid KMS port",
+			vsphereMachine: createVSphereMachineWithWindowsActivation("sysprep", "kms.example.com:99999", ""),
+			wantErr:        true,
+		},
+		{
+			name:           "published addresses in a CIDR",
+			vsphereMachine: createVSphereMachineWithPublishedAddresses(ManagementPublishedAddressDevices, "10.0.0.0/16", "fd00::/64"),
+			wantErr:        false,
+		},
+		{
+			name:           "published addresses in an invalid CIDR",
+			vsphereMachine: createVSphereMachineWithPublishedAddresses(AllPublishedAddressDevices, "10.0.0.1"),
+			wantErr:        true,
+		},
+		{
+			name:           "existing VM referenced by its moRef",
+			vsphereMachine: createVSphereMachineWithExistingVM("", &ExistingVMSpec{MoRef: "vm-42"}),
+			wantErr:        false,
+		},
+		{
+			name:           "existing VM referenced by its UUID",
+			vsphereMachine: createVSphereMachineWithExistingVM("", &ExistingVMSpec{UUID: "42170f5c-4b5a-8e2f-1d3c-6e0f2a9b7c11"}),
+			wantErr:        false,
+		},
+		{
+			name:           "existing VM referenced by both its moRef and its UUID",
+			vsphereMachine: createVSphereMachineWithExistingVM("", &ExistingVMSpec{MoRef: "vm-42", UUID: "42170f5c-4b5a-8e2f-1d3c-6e0f2a9b7c11"}),
+			wantErr:        true,
+		},
+		{
+			name:           "existing VM without a reference",
+			vsphereMachine: createVSphereMachineWithExistingVM("", &ExistingVMSpec{}),
+			wantErr:        true,
+		},
+		{
+			name: "existing VM with the deletion policy of cloned VMs",
+			vsphereMachine: func() *VSphereMachine {
+				vsphereMachine := createVSphereMachineWithExistingVM("", &ExistingVMSpec{MoRef: "vm-42"})
+				vsphereMachine.Spec.DeletionPolicy = RetainVMDeletionPolicy
+				return vsphereMachine
+			}(),
+			wantErr: true,
+		},
+		{
+			name:           "existing VM with a template",
+			vsphereMachine: createVSphereMachineWithExistingVM("ubuntu", &ExistingVMSpec{MoRef: "vm-42"}),
+			wantErr:        true,
+		},
+		{
+			name:           "Linux guest with Ignition bootstrap data",
+			vsphereMachine: createVSphereMachineWithBootstrapFormat(LinuxOS, IgnitionBootstrapFormat),
+			wantErr:        false,
+		},
+		{
+			name:           "Windows guest with Ignition bootstrap data",
+			vsphereMachine: createVSphereMachineWithBootstrapFormat(WindowsOS, IgnitionBootstrapFormat),
+			wantErr:        true,
+		},
+		{
+			name: "addresses from an IPAM pool without apiGroup",
+			vsphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "vm-network", AddressesFromPools: []corev1.TypedLocalObjectReference{
+					{Kind: "InClusterIPPool", Name: "pool"},
+				}}),
+			wantErr: true,
+		},
+		{
+			name: "retained VM with addresses from an IPAM pool",
+			vsphereMachine: func() *VSphereMachine {
+				vsphereMachine := createVSphereMachineWithNetworkDevices(
+					NetworkDeviceSpec{NetworkName: "vm-network", AddressesFromPools: []corev1.TypedLocalObjectReference{
+						{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"},
+					}})
+				vsphereMachine.Spec.DeletionPolicy = PowerOffAndRetainVMDeletionPolicy
+				return vsphereMachine
+			}(),
+			wantErr: true,
+		},
+		{
+			name:           "image reference instead of template",
+			vsphereMachine: createVSphereMachineWithImageRef("", "ubuntu-2204-kube-v1.28.3"),
+			wantErr:        false,
+		},
+		{
+			name:           "image reference together with template",
+			vsphereMachine: createVSphereMachineWithImageRef("ubuntu", "ubuntu-2204-kube-v1.28.3"),
+			wantErr:        true,
+		},
+		{
+			name:           "neither template nor image reference",
+			vsphereMachine: createVSphereMachineWithImageRef("", ""),
+			wantErr:        true,
+		},
+		{
+			name:           "custom VMX keys",
+			vsphereMachine: createVSphereMachineWithCustomVMXKeys(map[string]string{"disk.EnableUUID": "TRUE", "vhv.enable": "TRUE"}),
+			wantErr:        false,
+		},
+		{
+			name:           "custom VMX key set by the provider",
+			vsphereMachine: createVSphereMachineWithCustomVMXKeys(map[string]string{"guestinfo.userdata": "Zm9v"}),
+			wantErr:        true,
+		},
+		{
+			name:           "custom VMX key set by the provider in another case",
+			vsphereMachine: createVSphereMachineWithCustomVMXKeys(map[string]string{"GuestInfo.Metadata.Encoding": "base64"}),
+			wantErr:        true,
+		},
+		{
+			name:           "custom VMX key with a prefix of the provider",
+			vsphereMachine: createVSphereMachineWithCustomVMXKeys(map[string]string{"capv.dataDisk.etcd": "1000:1"}),
+			wantErr:        true,
+		},
+		{
+			name:           "empty custom VMX key",
+			vsphereMachine: createVSphereMachineWithCustomVMXKeys(map[string]string{"": "TRUE"}),
+			wantErr:        true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -112,6 +496,32 @@ func TestVSphereMachine_ValidateUpdate(t *testing.T) {
 			vsphereMachine:    createVSphereMachine("foo.com", &someProviderID, "", []string{"<nil>/32", "192.168.0.10/33"}),
 			wantErr:           true,
 		},
+		{
+			name:              "updating deletionPolicy can be done",
+			oldVSphereMachine: createVSphereMachine("foo.com", nil, "", []string{"192.168.0.1/32"}),
+			vsphereMachine: func() *VSphereMachine {
+				vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{"192.168.0.1/32"})
+				vsphereMachine.Spec.DeletionPolicy = RetainVMDeletionPolicy
+				return vsphereMachine
+			}(),
+			wantErr: false,
+		},
+		{
+			name: "retaining a VM with addresses from an IPAM pool cannot be done",
+			oldVSphereMachine: createVSphereMachineWithNetworkDevices(
+				NetworkDeviceSpec{NetworkName: "vm-network", AddressesFromPools: []corev1.TypedLocalObjectReference{
+					{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"},
+				}}),
+			vsphereMachine: func() *VSphereMachine {
+				vsphereMachine := createVSphereMachineWithNetworkDevices(
+					NetworkDeviceSpec{NetworkName: "vm-network", AddressesFromPools: []corev1.TypedLocalObjectReference{
+						{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"},
+					}})
+				vsphereMachine.Spec.DeletionPolicy = RetainVMDeletionPolicy
+				return vsphereMachine
+			}(),
+			wantErr: true,
+		},
 		{
 			name:              "updating server cannot be done",
 			oldVSphereMachine: createVSphereMachine("foo.com", nil, "", []string{"192.168.0.1/32"}),
@@ -131,12 +541,48 @@ func TestVSphereMachine_ValidateUpdate(t *testing.T) {
 	}
 }
 
+func TestVSphereMachine_ValidateUpdate_InPlaceResourceUpdate(t *testing.T) {
+	g := NewWithT(t)
+
+	oldVSphereMachine := createVSphereMachine("foo.com", nil, "", []string{"192.168.0.1/32"})
+	vsphereMachine := oldVSphereMachine.DeepCopy()
+	vsphereMachine.Spec.NumCPUs = 4
+	vsphereMachine.Spec.MemoryMiB = 8192
+	vsphereMachine.Spec.DiskGiB = 40
+
+	g.Expect(vsphereMachine.ValidateUpdate(oldVSphereMachine)).NotTo(Succeed())
+
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.InPlaceResourceUpdate, true)()
+	g.Expect(vsphereMachine.ValidateUpdate(oldVSphereMachine)).To(Succeed())
+
+	vsphereMachine.Spec.Template = "other-template"
+	g.Expect(vsphereMachine.ValidateUpdate(oldVSphereMachine)).NotTo(Succeed())
+}
+
+func TestVSphereMachine_ValidateUpdate_NetworkDeviceHotAdd(t *testing.T) {
+	g := NewWithT(t)
+
+	oldVSphereMachine := createVSphereMachineWithNetworkDevices(NetworkDeviceSpec{NetworkName: "VM Network", DHCP4: true})
+	vsphereMachine := createVSphereMachineWithNetworkDevices(
+		NetworkDeviceSpec{NetworkName: "VM Network", DHCP4: true},
+		NetworkDeviceSpec{NetworkName: "storage", AdapterType: NetworkAdapterTypeSRIOV, PhysicalFunction: "0000:3b:00.0"},
+	)
+	g.Expect(vsphereMachine.ValidateUpdate(oldVSphereMachine)).To(Succeed())
+
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.NetworkDeviceHotAdd, true)()
+	g.Expect(vsphereMachine.ValidateUpdate(oldVSphereMachine)).NotTo(Succeed())
+
+	vsphereMachine.Spec.Network.Devices[1].AdapterType = NetworkAdapterTypeVmxnet3
+	g.Expect(vsphereMachine.ValidateUpdate(oldVSphereMachine)).To(Succeed())
+}
+
 func createVSphereMachine(server string, providerID *string, preferredAPIServerCIDR string, ips []string) *VSphereMachine {
 	VSphereMachine := &VSphereMachine{
 		Spec: VSphereMachineSpec{
 			ProviderID: providerID,
 			VirtualMachineCloneSpec: VirtualMachineCloneSpec{
-				Server: server,
+				Template: "ubuntu",
+				Server:   server,
 				Network: NetworkSpec{
 					PreferredAPIServerCIDR: preferredAPIServerCIDR,
 					Devices:                []NetworkDeviceSpec{},
@@ -151,3 +597,106 @@ func createVSphereMachine(server string, providerID *string, preferredAPIServerC
 	}
 	return VSphereMachine
 }
+
+func createVSphereMachineWithPCIDevices(devices ...PCIDeviceSpec) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.PCIDevices = devices
+	return vsphereMachine
+}
+
+func createVSphereMachineWithCloneMode(cloneMode CloneMode, snapshot string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.CloneMode = cloneMode
+	vsphereMachine.Spec.Snapshot = snapshot
+	return vsphereMachine
+}
+
+func createVSphereMachineWithDataDisks(disks ...DataDiskSpec) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.DataDisks = disks
+	return vsphereMachine
+}
+
+func createVSphereMachineWithCustomVMXKeys(keys map[string]string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.CustomVMXKeys = keys
+	return vsphereMachine
+}
+
+func createVSphereMachineWithPowerOffMode(mode VirtualMachinePowerOffMode, timeout *metav1.Duration) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.PowerOffMode = mode
+	vsphereMachine.Spec.GuestSoftPowerOffTimeout = timeout
+	return vsphereMachine
+}
+
+func createVSphereMachineWithTPM(storagePolicyName string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.SecureBoot = true
+	vsphereMachine.Spec.TPM = true
+	vsphereMachine.Spec.StoragePolicyName = storagePolicyName
+	return vsphereMachine
+}
+
+func createVSphereMachineWithEncryption(storagePolicyName string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.Encryption = &EncryptionSpec{KeyProviderID: "kms"}
+	vsphereMachine.Spec.StoragePolicyName = storagePolicyName
+	return vsphereMachine
+}
+
+func createVSphereMachineWithOS(os OS, guestID, customizationSpec string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.OS = os
+	vsphereMachine.Spec.GuestID = guestID
+	vsphereMachine.Spec.CustomizationSpec = customizationSpec
+	return vsphereMachine
+}
+
+func createVSphereMachineWithWindowsActivation(customizationSpec, kmsServer, productKeySecretName string) *VSphereMachine {
+	vsphereMachine := createVSphereMachineWithOS(WindowsOS, "windows2019srv_64Guest", customizationSpec)
+	vsphereMachine.Spec.WindowsActivation = &WindowsActivationSpec{KMSServer: kmsServer, ProductKeySecretName: productKeySecretName}
+	return vsphereMachine
+}
+
+func createVSphereMachineWithPublishedAddresses(devices PublishedAddressDevices, cidrs ...string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.Network.PublishedAddresses = &PublishedAddressesSpec{Devices: devices, CIDRs: cidrs}
+	return vsphereMachine
+}
+
+func createVSphereMachineWithExistingVM(template string, existingVM *ExistingVMSpec) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.Template = template
+	vsphereMachine.Spec.ExistingVM = existingVM
+	return vsphereMachine
+}
+
+func createVSphereMachineWithBootstrapFormat(os OS, format BootstrapFormat) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.OS = os
+	vsphereMachine.Spec.BootstrapFormat = format
+	return vsphereMachine
+}
+
+func createVSphereMachineWithNetworkDevices(devices ...NetworkDeviceSpec) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.Network.Devices = devices
+	return vsphereMachine
+}
+
+func createVSphereMachineWithHostnameStrategy(strategy HostnameStrategy, domain string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.HostnameStrategy = strategy
+	vsphereMachine.Spec.HostnameDomain = domain
+	return vsphereMachine
+}
+
+func createVSphereMachineWithImageRef(template, imageName string) *VSphereMachine {
+	vsphereMachine := createVSphereMachine("foo.com", nil, "", []string{})
+	vsphereMachine.Spec.Template = template
+	if imageName != "" {
+		vsphereMachine.Spec.ImageRef = &corev1.LocalObjectReference{Name: imageName}
+	}
+	return vsphereMachine
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// VSphereMachineImageSpec defines the desired state of VSphereMachineImage.
+type VSphereMachineImageSpec struct {
+	// Server is the IP address or FQDN of the vSphere server on which the
+	// template of the image is resolved.
+	Server string `json:"server"`
+
+	// Thumbprint is the colon-separated SHA-1 checksum of the given vCenter server's host certificate
+	// When this is set to empty, the template is resolved without TLS
+	// certificate validation of the communication with the vCenter server.
+	// +optional
+	Thumbprint string `json:"thumbprint,omitempty"`
+
+	// CABundleRef references the PEM-encoded CA certificates against which
+	// the certificate of the vCenter server is verified.
+	// +optional
+	CABundleRef *CABundleReference `json:"caBundleRef,omitempty"`
+
+	// Datacenter is the name or inventory path of the datacenter in which the
+	// template of the image is resolved.
+	Datacenter string `json:"datacenter"`
+
+	// ImageName is the name of the OS image, e.g. "ubuntu-2204".
+	// +kubebuilder:validation:MinLength=1
+	ImageName string `json:"imageName"`
+
+	// KubernetesVersion is the Kubernetes version of the image, e.g. "v1.28.3".
+	// The image is resolved to the template and content library item named
+	// <imageName>-kube-<kubernetesVersion>, e.g. "ubuntu-2204-kube-v1.28.3".
+	// +kubebuilder:validation:MinLength=1
+	KubernetesVersion string `json:"kubernetesVersion"`
+
+	// ContentLibrary is the name of the content library the template is
+	// deployed from when no template of the image exists in the datacenter.
+	ContentLibrary string `json:"contentLibrary"`
+
+	// URL is the location of the OVA imported into the content library when
+	// the library has no item for the image. When empty, a missing item is
+	// reported in the conditions of the VSphereMachineImage.
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Folder is the name or inventory path of the folder in which the
+	// template is looked up and deployed.
+	// +optional
+	Folder string `json:"folder,omitempty"`
+
+	// Datastore is the name or inventory path of the datastore in which the
+	// template is deployed.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+
+	// ResourcePool is the name or inventory path of the resource pool in which
+	// the template is deployed.
+	// +optional
+	ResourcePool string `json:"resourcePool,omitempty"`
+
+	// Verification declares the expected content of the image. When set, the
+	// content library item of the image is verified before the template is
+	// deployed from it, and the image is not ready until it is.
+	// +optional
+	Verification *ImageVerification `json:"verification,omitempty"`
+}
+
+// ImageVerification declares the expected content of the image of a
+// VSphereMachineImage.
+type ImageVerification struct {
+	// Checksum is the expected checksum of the image. vCenter verifies the OVA
+	// imported from the URL of the image against it, and an existing item of
+	// the content library must have a file with this checksum.
+	// +optional
+	Checksum *ImageChecksum `json:"checksum,omitempty"`
+
+	// Signature is the signature of the SHA256 checksum of the image, e.g.
+	// written by "cosign sign-blob --key". It is verified against the checksum
+	// before the OVA is imported, and against the SHA256 checksums of the files
+	// of an existing item of the content library.
+	// +optional
+	Signature *ImageSignature `json:"signature,omitempty"`
+}
+
+// ImageChecksum is the checksum of an image.
+type ImageChecksum struct {
+	// Algorithm is the algorithm of the checksum.
+	// +kubebuilder:validation:Enum=SHA1;SHA256;SHA512;MD5
+	Algorithm string `json:"algorithm"`
+
+	// Value is the hex-encoded checksum.
+	// +kubebuilder:validation:Pattern=`^[0-9a-fA-F]+$`
+	Value string `json:"value"`
+}
+
+// ImageSignature is the signature of the checksum of an image.
+type ImageSignature struct {
+	// Value is the base64-encoded ECDSA or RSA PKCS #1 v1.5 signature of the
+	// SHA256 checksum of the image.
+	// +kubebuilder:validation:MinLength=1
+	Value string `json:"value"`
+
+	// PublicKeyRef references the PEM-encoded public key the signature is
+	// verified with.
+	PublicKeyRef ImagePublicKeyReference `json:"publicKeyRef"`
+}
+
+// ImagePublicKeyReference references a PEM-encoded public key stored in a
+// Secret or a ConfigMap in the namespace of the referencing object.
+type ImagePublicKeyReference struct {
+	// Kind is the kind of the object holding the public key.
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind string `json:"kind"`
+
+	// Name is the name of the object holding the public key.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key of the public key in the data of the object.
+	// Defaults to cosign.pub.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// VSphereMachineImageStatus defines the observed state of VSphereMachineImage.
+type VSphereMachineImageStatus struct {
+	// Ready is true when the template of the image is resolved.
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// TemplateRef is the managed object reference of the resolved template,
+	// e.g. "VirtualMachine:vm-42". It is used as the template of the
+	// VSphereVMs of the VSphereMachines referencing the image.
+	// +optional
+	TemplateRef string `json:"templateRef,omitempty"`
+
+	// VerifiedChecksum is the checksum of the content library item the
+	// template was deployed from, verified against spec.verification, in the
+	// <algorithm>:<value> format, e.g. "SHA256:9f86d0...". The template is
+	// only used as is while it matches spec.verification.
+	// +optional
+	VerifiedChecksum string `json:"verifiedChecksum,omitempty"`
+
+	// Conditions defines current service state of the VSphereMachineImage.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereMachineImage status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereMachineImageV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereMachineImageV1Beta2Status groups the fields of the VSphereMachineImage status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereMachineImageV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereMachineImage using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=vspheremachineimages,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.imageName",description="Name of the OS image"
+// +kubebuilder:printcolumn:name="Kubernetes",type="string",JSONPath=".spec.kubernetesVersion",description="Kubernetes version of the image"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Template is resolved"
+// +kubebuilder:printcolumn:name="Template",type="string",JSONPath=".status.templateRef",description="Managed object reference of the template"
+
+// VSphereMachineImage resolves a machine image by name and Kubernetes version
+// to a template, importing it from a content library when missing.
+type VSphereMachineImage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VSphereMachineImageSpec   `json:"spec,omitempty"`
+	Status VSphereMachineImageStatus `json:"status,omitempty"`
+}
+
+func (r *VSphereMachineImage) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+func (r *VSphereMachineImage) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereMachineImage.
+func (r *VSphereMachineImage) GetV1Beta2Conditions() []metav1.Condition {
+	if r.Status.V1Beta2 == nil {
+		return nil
+	}
+	return r.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereMachineImage.
+func (r *VSphereMachineImage) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if r.Status.V1Beta2 == nil {
+		r.Status.V1Beta2 = &VSphereMachineImageV1Beta2Status{}
+	}
+	r.Status.V1Beta2.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VSphereMachineImageList contains a list of VSphereMachineImage.
+type VSphereMachineImageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VSphereMachineImage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VSphereMachineImage{}, &VSphereMachineImageList{})
+}
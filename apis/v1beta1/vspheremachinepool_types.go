@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// MachinePoolFinalizer allows the VSphereMachinePool controller to delete
+	// the VSphereVMs of the pool, and wait for their VMs to be destroyed,
+	// before the pool is removed from the API server.
+	MachinePoolFinalizer = "vspheremachinepool.infrastructure.cluster.x-k8s.io"
+
+	// MachinePoolLabel is the label set on the VSphereVMs of a
+	// VSphereMachinePool to the name of the pool.
+	MachinePoolLabel = "vspheremachinepool.infrastructure.cluster.x-k8s.io/name"
+
+	// MachinePoolTemplateHashLabel is the label set on the VSphereVMs of a
+	// VSphereMachinePool to the hash of the template and the bootstrap data
+	// they were created from. The VSphereVMs with another hash are replaced.
+	MachinePoolTemplateHashLabel = "vspheremachinepool.infrastructure.cluster.x-k8s.io/template-hash"
+
+	// MachinePoolFailureDomainAnnotation is set on the VSphereVMs of a
+	// VSphereMachinePool to the failure domain of the MachinePool they are
+	// placed in.
+	MachinePoolFailureDomainAnnotation = "vspheremachinepool.infrastructure.cluster.x-k8s.io/failure-domain"
+)
+
+// VSphereMachinePoolSpec defines the desired state of VSphereMachinePool.
+type VSphereMachinePoolSpec struct {
+	// ProviderIDList is the list of the provider IDs of the VMs of the pool,
+	// as set by the controller.
+	// +optional
+	ProviderIDList []string `json:"providerIDList,omitempty"`
+
+	// Template is the clone spec of the VMs of the pool. The VMs are replaced
+	// when it changes.
+	Template VirtualMachineCloneSpec `json:"template"`
+
+	// RollingUpdate is the strategy of the replacement of the VMs when the
+	// template or the bootstrap data of the pool changes.
+	// +optional
+	RollingUpdate VSphereMachinePoolRollingUpdate `json:"rollingUpdate,omitempty"`
+}
+
+// VSphereMachinePoolRollingUpdate controls the replacement of the VMs of a
+// VSphereMachinePool.
+type VSphereMachinePoolRollingUpdate struct {
+	// MaxSurge is the maximum number of VMs that can be created above the
+	// desired number of replicas during the replacement. Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxSurge *int32 `json:"maxSurge,omitempty"`
+
+	// MaxUnavailable is the maximum number of VMs that can be unavailable
+	// during the replacement. Defaults to 0. When both MaxSurge and
+	// MaxUnavailable are 0, one VM is created above the desired number of
+	// replicas.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxUnavailable *int32 `json:"maxUnavailable,omitempty"`
+}
+
+// VSphereMachinePoolStatus defines the observed state of VSphereMachinePool.
+type VSphereMachinePoolStatus struct {
+	// Ready is true when the pool creates the VMs of the MachinePool.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Replicas is the number of VMs of the pool.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of VMs of the pool which are ready.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// UpdatedReplicas is the number of VMs of the pool created from its
+	// current template and bootstrap data.
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+
+	// Conditions defines current service state of the VSphereMachinePool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereMachinePool status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereMachinePoolV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereMachinePoolV1Beta2Status groups the fields of the VSphereMachinePool status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereMachinePoolV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereMachinePool using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=vspheremachinepools,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this VSphereMachinePool belongs"
+// +kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready",description="VSphereMachinePool ready status"
+// +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.replicas",description="Number of VMs"
+// +kubebuilder:printcolumn:name="Updated",type="integer",JSONPath=".status.updatedReplicas",description="Number of VMs created from the current template"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of VSphereMachinePool"
+
+// VSphereMachinePool is the infrastructure of a MachinePool. It clones the
+// VMs of the MachinePool from its template, without a Machine for each VM.
+type VSphereMachinePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VSphereMachinePoolSpec   `json:"spec,omitempty"`
+	Status VSphereMachinePoolStatus `json:"status,omitempty"`
+}
+
+func (r *VSphereMachinePool) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+func (r *VSphereMachinePool) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereMachinePool.
+func (r *VSphereMachinePool) GetV1Beta2Conditions() []metav1.Condition {
+	if r.Status.V1Beta2 == nil {
+		return nil
+	}
+	return r.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereMachinePool.
+func (r *VSphereMachinePool) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if r.Status.V1Beta2 == nil {
+		r.Status.V1Beta2 = &VSphereMachinePoolV1Beta2Status{}
+	}
+	r.Status.V1Beta2.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VSphereMachinePoolList contains a list of VSphereMachinePool.
+type VSphereMachinePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VSphereMachinePool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VSphereMachinePool{}, &VSphereMachinePoolList{})
+}
@@ -54,6 +54,31 @@ func (r *VSphereMachineTemplate) ValidateCreate() error {
 		}
 	}
 
+	allErrs = append(allErrs, validateAddressesFromPools(field.NewPath("spec", "template", "spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateNetworkAdapters(field.NewPath("spec", "template", "spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateNetworkDeviceRoles(field.NewPath("spec", "template", "spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateNetworkDeviceGateways(field.NewPath("spec", "template", "spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateHostnameStrategy(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePowerOffMode(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateDeletionPolicy(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateTPM(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateEncryption(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateHostAffinity(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateTimeSync(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateOS(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePCIDevices(field.NewPath("spec", "template", "spec", "pciDevices"), spec.PCIDevices)...)
+	allErrs = append(allErrs, validateDataDisks(field.NewPath("spec", "template", "spec", "dataDisks"), spec.DataDisks)...)
+	allErrs = append(allErrs, validateCloneMode(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateCustomVMXKeys(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateWindowsActivation(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePublishedAddresses(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	// An existing VM is bound to a single machine, so it cannot be shared by
+	// the machines created from a template.
+	if spec.ExistingVM != nil {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "template", "spec", "existingVM"), "cannot be set in a template"))
+	}
+	allErrs = append(allErrs, validateTemplateSource(field.NewPath("spec", "template", "spec"), spec)...)
+
 	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
@@ -113,7 +113,8 @@ func createVSphereMachineTemplate(server string, providerID *string, preferredAP
 				Spec: VSphereMachineSpec{
 					ProviderID: providerID,
 					VirtualMachineCloneSpec: VirtualMachineCloneSpec{
-						Server: server,
+						Template: "ubuntu",
+						Server:   server,
 						Network: NetworkSpec{
 							PreferredAPIServerCIDR: preferredAPIServerCIDR,
 							Devices:                []NetworkDeviceSpec{},
@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// TemplateRolloutLabel is the label set on the canary Machine and on the
+	// probe Jobs of a VSphereMachineTemplateRollout to the name of the rollout.
+	TemplateRolloutLabel = "vspheremachinetemplaterollout.infrastructure.cluster.x-k8s.io/name"
+)
+
+// TemplateRolloutPhase is the phase of a VSphereMachineTemplateRollout.
+type TemplateRolloutPhase string
+
+const (
+	// TemplateRolloutPhaseCanary is the phase of a rollout waiting for the
+	// node of its canary Machine to be healthy.
+	TemplateRolloutPhaseCanary TemplateRolloutPhase = "Canary"
+
+	// TemplateRolloutPhaseProbing is the phase of a rollout running its probe
+	// Jobs on the node of its canary Machine.
+	TemplateRolloutPhaseProbing TemplateRolloutPhase = "Probing"
+
+	// TemplateRolloutPhasePromoted is the phase of a rollout whose template is
+	// referenced by the MachineDeployment.
+	TemplateRolloutPhasePromoted TemplateRolloutPhase = "Promoted"
+
+	// TemplateRolloutPhaseFailed is the phase of a rollout whose canary
+	// Machine or probe Jobs failed. The MachineDeployment is not changed.
+	TemplateRolloutPhaseFailed TemplateRolloutPhase = "Failed"
+)
+
+// VSphereMachineTemplateRolloutSpec defines the desired state of VSphereMachineTemplateRollout.
+type VSphereMachineTemplateRolloutSpec struct {
+	// ClusterName is the name of the Cluster of the MachineDeployment.
+	// +kubebuilder:validation:MinLength=1
+	ClusterName string `json:"clusterName"`
+
+	// MachineDeploymentName is the name of the MachineDeployment whose
+	// infrastructure template is rolled out.
+	// +kubebuilder:validation:MinLength=1
+	MachineDeploymentName string `json:"machineDeploymentName"`
+
+	// TemplateName is the name of the VSphereMachineTemplate rolled out. The
+	// infrastructure reference of the MachineDeployment is set to it once the
+	// node of the canary Machine is healthy and the probe Jobs succeeded.
+	// +kubebuilder:validation:MinLength=1
+	TemplateName string `json:"templateName"`
+
+	// ProbeJobs are the Jobs run in the workload cluster on the node of the
+	// canary Machine once it is healthy. All of them must succeed for the
+	// template to be promoted.
+	// +optional
+	ProbeJobs []TemplateRolloutProbeJob `json:"probeJobs,omitempty"`
+
+	// Timeout is the time after the creation of the canary Machine within
+	// which its node must be healthy and the probe Jobs must succeed.
+	// Defaults to 30m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// TemplateRolloutProbeJob is a Job run on the node of a canary Machine.
+type TemplateRolloutProbeJob struct {
+	// Name of the probe. The Job is named after the rollout and the probe.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Namespace of the Job in the workload cluster. Defaults to default.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Spec of the Job. Its pods are bound to the node of the canary Machine.
+	Spec batchv1.JobSpec `json:"spec"`
+}
+
+// VSphereMachineTemplateRolloutStatus defines the observed state of VSphereMachineTemplateRollout.
+type VSphereMachineTemplateRolloutStatus struct {
+	// Phase of the rollout.
+	// +optional
+	Phase TemplateRolloutPhase `json:"phase,omitempty"`
+
+	// CanaryMachineName is the name of the canary Machine created from the
+	// template.
+	// +optional
+	CanaryMachineName string `json:"canaryMachineName,omitempty"`
+
+	// CanaryNodeName is the name of the node of the canary Machine.
+	// +optional
+	CanaryNodeName string `json:"canaryNodeName,omitempty"`
+
+	// Conditions defines current service state of the VSphereMachineTemplateRollout.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereMachineTemplateRollout status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereMachineTemplateRolloutV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereMachineTemplateRolloutV1Beta2Status groups the fields of the VSphereMachineTemplateRollout status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereMachineTemplateRolloutV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereMachineTemplateRollout using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=vspheremachinetemplaterollouts,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="MachineDeployment",type="string",JSONPath=".spec.machineDeploymentName",description="MachineDeployment of the rollout"
+// +kubebuilder:printcolumn:name="Template",type="string",JSONPath=".spec.templateName",description="VSphereMachineTemplate rolled out"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Phase of the rollout"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of VSphereMachineTemplateRollout"
+
+// VSphereMachineTemplateRollout rolls out a VSphereMachineTemplate to a
+// MachineDeployment through a canary Machine, whose node must be healthy and
+// pass the probe Jobs before the MachineDeployment references the template.
+type VSphereMachineTemplateRollout struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VSphereMachineTemplateRolloutSpec   `json:"spec,omitempty"`
+	Status VSphereMachineTemplateRolloutStatus `json:"status,omitempty"`
+}
+
+func (r *VSphereMachineTemplateRollout) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+func (r *VSphereMachineTemplateRollout) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereMachineTemplateRollout.
+func (r *VSphereMachineTemplateRollout) GetV1Beta2Conditions() []metav1.Condition {
+	if r.Status.V1Beta2 == nil {
+		return nil
+	}
+	return r.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereMachineTemplateRollout.
+func (r *VSphereMachineTemplateRollout) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if r.Status.V1Beta2 == nil {
+		r.Status.V1Beta2 = &VSphereMachineTemplateRolloutV1Beta2Status{}
+	}
+	r.Status.V1Beta2.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VSphereMachineTemplateRolloutList contains a list of VSphereMachineTemplateRollout.
+type VSphereMachineTemplateRolloutList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VSphereMachineTemplateRollout `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VSphereMachineTemplateRollout{}, &VSphereMachineTemplateRolloutList{})
+}
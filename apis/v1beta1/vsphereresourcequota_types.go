@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// VSphereResources is an amount of the vSphere resources requested by the
+// clone specs of VSphereMachines.
+type VSphereResources struct {
+	// NumCPUs is the number of virtual processors.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	NumCPUs *int64 `json:"numCPUs,omitempty"`
+
+	// MemoryMiB is the size of the memory, in MiB.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MemoryMiB *int64 `json:"memoryMiB,omitempty"`
+
+	// DiskGiB is the size of the disks, in GiB: the primary disk, the
+	// additional disks and the data disks.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	DiskGiB *int64 `json:"diskGiB,omitempty"`
+}
+
+// VSphereResourceQuotaSpec defines the desired state of VSphereResourceQuota.
+type VSphereResourceQuotaSpec struct {
+	// Hard is the maximum amount of the resources requested by all the
+	// VSphereMachines of the namespace. A resource which is not set is not
+	// limited. The creation of a VSphereMachine which would exceed it is
+	// rejected.
+	Hard VSphereResources `json:"hard"`
+}
+
+// VSphereResourceQuotaStatus defines the observed state of VSphereResourceQuota.
+type VSphereResourceQuotaStatus struct {
+	// Hard is the enforced amount of the resources, as of the last
+	// computation of the usage.
+	// +optional
+	Hard VSphereResources `json:"hard,omitempty"`
+
+	// Used is the amount of the resources limited by the quota requested by
+	// all the VSphereMachines of the namespace.
+	// +optional
+	Used VSphereResources `json:"used,omitempty"`
+
+	// Machines is the number of VSphereMachines of the namespace.
+	// +optional
+	Machines int32 `json:"machines"`
+
+	// Conditions defines current service state of the VSphereResourceQuota.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereResourceQuota status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereResourceQuotaV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereResourceQuotaV1Beta2Status groups the fields of the VSphereResourceQuota status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereResourceQuotaV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereResourceQuota using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=vsphereresourcequotas,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="CPUs",type="string",JSONPath=".status.used.numCPUs",description="Number of virtual processors used"
+// +kubebuilder:printcolumn:name="Memory",type="string",JSONPath=".status.used.memoryMiB",description="Memory used, in MiB"
+// +kubebuilder:printcolumn:name="Disk",type="string",JSONPath=".status.used.diskGiB",description="Disk used, in GiB"
+// +kubebuilder:printcolumn:name="Machines",type="integer",JSONPath=".status.machines",description="Number of VSphereMachines"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of VSphereResourceQuota"
+
+// VSphereResourceQuota limits the aggregate vSphere resources requested by the
+// VSphereMachines of its namespace.
+type VSphereResourceQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VSphereResourceQuotaSpec   `json:"spec,omitempty"`
+	Status VSphereResourceQuotaStatus `json:"status,omitempty"`
+}
+
+func (r *VSphereResourceQuota) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+func (r *VSphereResourceQuota) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereResourceQuota.
+func (r *VSphereResourceQuota) GetV1Beta2Conditions() []metav1.Condition {
+	if r.Status.V1Beta2 == nil {
+		return nil
+	}
+	return r.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereResourceQuota.
+func (r *VSphereResourceQuota) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if r.Status.V1Beta2 == nil {
+		r.Status.V1Beta2 = &VSphereResourceQuotaV1Beta2Status{}
+	}
+	r.Status.V1Beta2.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VSphereResourceQuotaList contains a list of VSphereResourceQuota.
+type VSphereResourceQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VSphereResourceQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VSphereResourceQuota{}, &VSphereResourceQuotaList{})
+}
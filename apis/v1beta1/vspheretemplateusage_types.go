@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VSphereTemplateUsageSpec identifies the template whose usage is reported.
+type VSphereTemplateUsageSpec struct {
+	// Server is the vSphere server of the template. It is empty when the
+	// server of the objects referencing the template is not known.
+	// +optional
+	Server string `json:"server,omitempty"`
+
+	// Template is the managed object reference of the template, e.g.
+	// "VirtualMachine:vm-42", whatever the name, inventory path or instance
+	// UUID written in the objects referencing it. It is the template as
+	// written in the objects when it cannot be looked up in vCenter.
+	Template string `json:"template"`
+
+	// TemplateName is the name of the template in vCenter, when it was
+	// looked up.
+	// +optional
+	TemplateName string `json:"templateName,omitempty"`
+}
+
+// VSphereTemplateReference is an object referencing a template.
+type VSphereTemplateReference struct {
+	// Kind is the kind of the object, e.g. "VSphereMachineTemplate".
+	Kind string `json:"kind"`
+
+	// Namespace is the namespace of the object.
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the object.
+	Name string `json:"name"`
+
+	// ClusterName is the name of the cluster of the object, if any.
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+}
+
+// VSphereTemplateUsageStatus defines the observed state of
+// VSphereTemplateUsage.
+type VSphereTemplateUsageStatus struct {
+	// References are the objects referencing the template: the
+	// VSphereMachineTemplates, VSphereMachinePools and VSphereWarmPools from
+	// which VMs may still be cloned, and the VSphereMachines and VSphereVMs
+	// of the VMs cloned from the template.
+	// +optional
+	References []VSphereTemplateReference `json:"references,omitempty"`
+
+	// ReferenceCount is the number of references.
+	// +optional
+	ReferenceCount int32 `json:"referenceCount"`
+
+	// SafeToDelete is true when no object references the template.
+	// +optional
+	SafeToDelete bool `json:"safeToDelete"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=vspheretemplateusages,scope=Cluster,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.server",description="vSphere server of the template"
+// +kubebuilder:printcolumn:name="Template",type="string",JSONPath=".spec.template",description="Template referenced by the objects"
+// +kubebuilder:printcolumn:name="TemplateName",type="string",JSONPath=".spec.templateName",description="Name of the template in vCenter"
+// +kubebuilder:printcolumn:name="References",type="integer",JSONPath=".status.referenceCount",description="Number of objects referencing the template"
+// +kubebuilder:printcolumn:name="SafeToDelete",type="boolean",JSONPath=".status.safeToDelete",description="No object references the template"
+
+// VSphereTemplateUsage reports the objects of all the namespaces referencing
+// a template, so that administrators can tell whether the template can be
+// deleted from vCenter.
+type VSphereTemplateUsage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VSphereTemplateUsageSpec   `json:"spec,omitempty"`
+	Status VSphereTemplateUsageStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VSphereTemplateUsageList contains a list of VSphereTemplateUsage.
+type VSphereTemplateUsageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VSphereTemplateUsage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VSphereTemplateUsage{}, &VSphereTemplateUsageList{})
+}
@@ -28,6 +28,86 @@ const (
 	// VMFinalizer allows the reconciler to clean up resources associated
 	// with a VSphereVM before removing it from the API Server.
 	VMFinalizer = "vspherevm.infrastructure.cluster.x-k8s.io"
+
+	// VMConsoleRequestAnnotation requests a ticket for the HTML5 console of
+	// the VM of a VSphereVM, reported in status.console. The annotation is
+	// removed once the ticket is acquired.
+	VMConsoleRequestAnnotation = "vspherevm.infrastructure.cluster.x-k8s.io/request-console"
+
+	// VMLogsRequestAnnotation requests the collection of the vmware.log and
+	// the serial output of the VM of a VSphereVM into a ConfigMap, reported
+	// in status.logs. The annotation is removed once the logs are collected.
+	VMLogsRequestAnnotation = "vspherevm.infrastructure.cluster.x-k8s.io/request-logs"
+
+	// VMOperationAnnotation requests an operation on the VM of a VSphereVM.
+	// The annotation is removed once the operation is processed, so each
+	// request is processed once, and its result is reported in
+	// status.operation.
+	VMOperationAnnotation = "vspherevm.infrastructure.cluster.x-k8s.io/operation"
+
+	// VMRelocateHostAnnotation is the name of the ESXi host to which the
+	// relocate operation migrates the VM of a VSphereVM.
+	VMRelocateHostAnnotation = "vspherevm.infrastructure.cluster.x-k8s.io/relocate-host"
+
+	// VMRelocateDatastoreAnnotation is the name of the datastore to which the
+	// relocate operation migrates the disks of the VM of a VSphereVM.
+	VMRelocateDatastoreAnnotation = "vspherevm.infrastructure.cluster.x-k8s.io/relocate-datastore"
+
+	// VMInstanceUUIDAnnotation is set on a VSphereVM to the instance UUID of
+	// its VM, which is the UID of the VSphereVM the VM was cloned for. The VM
+	// of a VSphereVM restored from a backup, or moved to another management
+	// cluster, with another UID is found by this instance UUID rather than
+	// cloned again.
+	VMInstanceUUIDAnnotation = "vspherevm.infrastructure.cluster.x-k8s.io/instance-uuid"
+
+	// VMEncryptionKeyAnnotation is set on a VSphereVM to the ID of the key
+	// generated by its key provider to encrypt its VM. The key is generated
+	// before the first clone attempt, and reused by the next ones.
+	VMEncryptionKeyAnnotation = "vspherevm.infrastructure.cluster.x-k8s.io/encryption-key"
+
+	// VMPreDeleteHooksCompletedAnnotation is set on a deleted VSphereVM once
+	// the PreDelete lifecycle hooks succeeded, so that they are not invoked
+	// again while its VM is destroyed.
+	VMPreDeleteHooksCompletedAnnotation = "vspherevm.infrastructure.cluster.x-k8s.io/pre-delete-hooks-completed"
+)
+
+// VirtualMachineOperation is an operation requested on the VM of a VSphereVM
+// with the operation annotation.
+type VirtualMachineOperation string
+
+const (
+	// RestartOperation resets the VM.
+	RestartOperation VirtualMachineOperation = "restart"
+
+	// RecloneOperation deletes the Machine of the VM, so that its owner, a
+	// MachineSet or a KubeadmControlPlane, replaces it with a Machine whose
+	// VM is cloned again. It requires the MachineReclone feature gate.
+	RecloneOperation VirtualMachineOperation = "reclone"
+
+	// RecustomizeOperation sets the current bootstrap data and metadata of
+	// the VM again, then resets the VM so that its guest reads them at boot.
+	RecustomizeOperation VirtualMachineOperation = "recustomize"
+
+	// RelocateOperation migrates the VM, running or not, to the host and/or
+	// the datastore set in the relocate annotations, with vMotion.
+	RelocateOperation VirtualMachineOperation = "relocate"
+)
+
+// VirtualMachineOperationResult is the result of an operation requested on
+// the VM of a VSphereVM.
+type VirtualMachineOperationResult string
+
+const (
+	// OperationSucceeded is the result of an operation which succeeded.
+	OperationSucceeded VirtualMachineOperationResult = "Succeeded"
+
+	// OperationFailed is the result of an operation which failed, or which
+	// is unknown.
+	OperationFailed VirtualMachineOperationResult = "Failed"
+
+	// OperationInProgress is the result of an operation whose task is still
+	// running in vCenter, e.g. a relocation.
+	OperationInProgress VirtualMachineOperationResult = "InProgress"
 )
 
 // VSphereVMSpec defines the desired state of VSphereVM.
@@ -47,6 +127,12 @@ type VSphereVMSpec struct {
 	// this CRD as unstructured data.
 	// +optional
 	BiosUUID string `json:"biosUUID,omitempty"`
+
+	// VMName is the name of the VM in vCenter, rendered from the naming
+	// strategy of the VSphereCluster when the VSphereVM is created.
+	// Defaults to the name of the VSphereVM.
+	// +optional
+	VMName string `json:"vmName,omitempty"`
 }
 
 // VSphereVMStatus defines the observed state of VSphereVM
@@ -85,11 +171,84 @@ type VSphereVMStatus struct {
 	// +optional
 	TaskRef string `json:"taskRef,omitempty"`
 
+	// Task is the state of the task referenced by TaskRef, as last observed
+	// in vCenter.
+	// This value is set automatically at runtime and should not be set or
+	// modified by users.
+	// +optional
+	Task *VirtualMachineTaskStatus `json:"task,omitempty"`
+
 	// Network returns the network status for each of the machine's configured
 	// network interfaces.
 	// +optional
 	Network []NetworkStatus `json:"network,omitempty"`
 
+	// Storage is the storage consumption of the VM, as last observed in
+	// vCenter.
+	// +optional
+	Storage *VirtualMachineStorageStatus `json:"storage,omitempty"`
+
+	// Folder is the inventory path of the folder of the VM, as last observed
+	// in vCenter. It differs from spec.folder when the VM was moved into
+	// another folder and the folder relocation policy accepts it.
+	// +optional
+	Folder string `json:"folder,omitempty"`
+
+	// VMName is the name of the VM, as last observed in vCenter. It differs
+	// from the name of the VM when the VM was renamed and the rename policy
+	// accepts it.
+	// +optional
+	VMName string `json:"vmName,omitempty"`
+
+	// Host is the name of the ESXi host of the VM, as last observed in
+	// vCenter.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Datastore is the name of the datastore of the configuration files of
+	// the VM, as last observed in vCenter.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+
+	// VMRef is the managed object reference of the VM in vCenter, with the
+	// links to the VM in the vSphere Client.
+	// This value is set automatically at runtime and should not be set or
+	// modified by users.
+	// +optional
+	VMRef *VirtualMachineReference `json:"vmRef,omitempty"`
+
+	// Console is the HTML5 console of the VM, last requested with the
+	// request-console annotation.
+	// +optional
+	Console *VirtualMachineConsoleStatus `json:"console,omitempty"`
+
+	// Logs is the ConfigMap holding the logs of the VM, last requested with
+	// the request-logs annotation.
+	// +optional
+	Logs *VirtualMachineLogsStatus `json:"logs,omitempty"`
+
+	// Operation is the result of the last operation requested with the
+	// operation annotation.
+	// +optional
+	Operation *VirtualMachineOperationStatus `json:"operation,omitempty"`
+
+	// DiskWipe is the progress of the wipe of the disks of the VM, according
+	// to the disk wipe policy, before it is destroyed.
+	// +optional
+	DiskWipe *VirtualMachineDiskWipeStatus `json:"diskWipe,omitempty"`
+
+	// Drift is the drift of the configuration of the VM from the VSphereVM,
+	// as last checked with the drift check interval of the manager.
+	// +optional
+	Drift *VirtualMachineDriftStatus `json:"drift,omitempty"`
+
+	// ModuleUUID is the unique identifier for the vCenter cluster module construct
+	// which is used to configure anti-affinity. Objects with the same ModuleUUID
+	// will be anti-affine, meaning that the vCenter DRS will best effort schedule
+	// the VMs on separate hosts.
+	// +optional
+	ModuleUUID *string `json:"moduleUUID,omitempty"`
+
 	// FailureReason will be set in the event that there is a terminal problem
 	// reconciling the vspherevm and will contain a succinct value suitable
 	// for vm interpretation.
@@ -123,6 +282,238 @@ type VSphereVMStatus struct {
 	// Conditions defines current service state of the VSphereVM.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereVM status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereVMV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereVMV1Beta2Status groups the fields of the VSphereVM status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereVMV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereVM using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// VirtualMachineTaskStatus is the state of a vCenter task related to a
+// VSphereVM.
+type VirtualMachineTaskStatus struct {
+	// Ref is the managed object reference of the task.
+	Ref string `json:"ref"`
+
+	// DescriptionID identifies the operation of the task,
+	// e.g. VirtualMachine.clone.
+	// +optional
+	DescriptionID string `json:"descriptionID,omitempty"`
+
+	// Description is the description of the current step of the task.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// State is the state of the task: queued, running, success or error.
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// Progress is the percentage of completion of a running task.
+	// +optional
+	Progress int32 `json:"progress,omitempty"`
+
+	// QueueTime is the time at which the task was queued in vCenter.
+	// +optional
+	QueueTime metav1.Time `json:"queueTime,omitempty"`
+
+	// StartTime is the time at which the task started running.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Error is the fault message of a failed task.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// VirtualMachineReference is the reference to the VM of a VSphereVM in
+// vCenter.
+type VirtualMachineReference struct {
+	// Value is the managed object ID of the VM, e.g. vm-42.
+	Value string `json:"value"`
+
+	// ServerGUID is the instance UUID of the vCenter of the VM, which
+	// identifies the VM in the vSphere Client with its managed object ID.
+	// +optional
+	ServerGUID string `json:"serverGUID,omitempty"`
+
+	// WebClientURL is the URL of the summary of the VM in the vSphere Client.
+	// +optional
+	WebClientURL string `json:"webClientURL,omitempty"`
+
+	// ConsoleURL is the URL of the HTML5 web console of the VM in the vSphere
+	// Client, which requires to log in to vCenter.
+	// +optional
+	ConsoleURL string `json:"consoleURL,omitempty"`
+}
+
+// VirtualMachineConsoleStatus is a ticket for the HTML5 console of the VM of
+// a VSphereVM.
+type VirtualMachineConsoleStatus struct {
+	// URL is the WebSocket URL of the WebMKS console of the VM, which embeds
+	// a single-use ticket. The ticket expires shortly after it is acquired.
+	URL string `json:"url"`
+
+	// SSLThumbprint is the SHA1 thumbprint of the certificate of the ESXi
+	// host serving the console.
+	// +optional
+	SSLThumbprint string `json:"sslThumbprint,omitempty"`
+
+	// AcquiredTime is the time the ticket was acquired.
+	AcquiredTime metav1.Time `json:"acquiredTime"`
+}
+
+// VirtualMachineLogsStatus is the ConfigMap holding the logs collected from
+// the VM of a VSphereVM.
+type VirtualMachineLogsStatus struct {
+	// ConfigMapName is the name of the ConfigMap, in the namespace of the
+	// VSphereVM, holding the end of the vmware.log file and of the serial
+	// output of the VM.
+	ConfigMapName string `json:"configMapName"`
+
+	// CollectedTime is the time the logs were collected.
+	CollectedTime metav1.Time `json:"collectedTime"`
+}
+
+// VirtualMachineOperationStatus is the result of an operation requested on
+// the VM of a VSphereVM.
+type VirtualMachineOperationStatus struct {
+	// Operation is the operation, as set in the operation annotation.
+	Operation VirtualMachineOperation `json:"operation"`
+
+	// Result is the result of the operation, Succeeded, Failed or
+	// InProgress.
+	Result VirtualMachineOperationResult `json:"result"`
+
+	// Message describes the result of the operation.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// TaskRef is the managed object reference of the task of the operation,
+	// while the operation is in progress.
+	// +optional
+	TaskRef string `json:"taskRef,omitempty"`
+
+	// ProcessedTime is the time the operation was processed.
+	ProcessedTime metav1.Time `json:"processedTime"`
+}
+
+// VirtualMachineDiskWipeStatus is the progress of the wipe of the disks of
+// the VM of a VSphereVM.
+type VirtualMachineDiskWipeStatus struct {
+	// WipedDisks are the file names of the disks of the VM which are wiped.
+	// +optional
+	WipedDisks []string `json:"wipedDisks,omitempty"`
+
+	// Disk is the file name of the disk being wiped.
+	// +optional
+	Disk string `json:"disk,omitempty"`
+
+	// TaskRef is the managed object ID of the task wiping the disk.
+	// +optional
+	TaskRef string `json:"taskRef,omitempty"`
+
+	// CompletedTime is the time every disk of the VM was wiped.
+	// +optional
+	CompletedTime *metav1.Time `json:"completedTime,omitempty"`
+}
+
+// VirtualMachineDriftProperty is a property of the configuration of a VM
+// which is compared with its VSphereVM.
+// +kubebuilder:validation:Enum=Network;Disk;ExtraConfig;Folder;ResourcePool
+type VirtualMachineDriftProperty string
+
+const (
+	// NetworkDriftProperty is the network backing of the NICs of the VM.
+	NetworkDriftProperty VirtualMachineDriftProperty = "Network"
+
+	// DiskDriftProperty is the capacity of the primary disk of the VM.
+	DiskDriftProperty VirtualMachineDriftProperty = "Disk"
+
+	// ExtraConfigDriftProperty is the custom VMX keys of the VM.
+	ExtraConfigDriftProperty VirtualMachineDriftProperty = "ExtraConfig"
+
+	// FolderDriftProperty is the folder of the VM.
+	FolderDriftProperty VirtualMachineDriftProperty = "Folder"
+
+	// ResourcePoolDriftProperty is the resource pool of the VM.
+	ResourcePoolDriftProperty VirtualMachineDriftProperty = "ResourcePool"
+)
+
+// VirtualMachineDriftStatus is the drift of the configuration of the VM of a
+// VSphereVM from the VSphereVM.
+type VirtualMachineDriftStatus struct {
+	// Drifted are the properties of the VM which differ from the VSphereVM.
+	// +optional
+	Drifted []VirtualMachineDrift `json:"drifted,omitempty"`
+
+	// LastCheckTime is the time the configuration of the VM was last compared
+	// with the VSphereVM.
+	LastCheckTime metav1.Time `json:"lastCheckTime"`
+}
+
+// VirtualMachineDrift is a property of the configuration of a VM which
+// differs from its VSphereVM.
+type VirtualMachineDrift struct {
+	// Property is the drifted property.
+	Property VirtualMachineDriftProperty `json:"property"`
+
+	// Desired is the value of the property set by the VSphereVM.
+	// +optional
+	Desired string `json:"desired,omitempty"`
+
+	// Actual is the value of the property observed in vCenter.
+	// +optional
+	Actual string `json:"actual,omitempty"`
+}
+
+// VirtualMachineStorageStatus is the storage consumption of a VSphereVM.
+type VirtualMachineStorageStatus struct {
+	// CommittedBytes is the storage space used by the files of the VM on all
+	// datastores.
+	CommittedBytes int64 `json:"committedBytes"`
+
+	// ProvisionedBytes is the storage space the files of the VM may use on
+	// all datastores once their thin-provisioned disks are fully grown.
+	ProvisionedBytes int64 `json:"provisionedBytes"`
+
+	// Datastores is the storage consumption of the VM per datastore.
+	// +optional
+	Datastores []VirtualMachineDatastoreUsage `json:"datastores,omitempty"`
+}
+
+// VirtualMachineDatastoreUsage is the storage consumption of a VSphereVM on a
+// datastore, along with the capacity of the datastore.
+type VirtualMachineDatastoreUsage struct {
+	// Name is the name of the datastore.
+	Name string `json:"name"`
+
+	// CommittedBytes is the storage space used by the files of the VM on the
+	// datastore.
+	CommittedBytes int64 `json:"committedBytes"`
+
+	// ProvisionedBytes is the storage space the files of the VM may use on
+	// the datastore once their thin-provisioned disks are fully grown.
+	ProvisionedBytes int64 `json:"provisionedBytes"`
+
+	// CapacityBytes is the capacity of the datastore.
+	CapacityBytes int64 `json:"capacityBytes"`
+
+	// DatastoreProvisionedBytes is the storage space the files of all the VMs
+	// on the datastore may use once fully grown. The datastore is overcommitted
+	// when it exceeds CapacityBytes.
+	DatastoreProvisionedBytes int64 `json:"datastoreProvisionedBytes"`
 }
 
 // +kubebuilder:object:root=true
@@ -147,6 +538,22 @@ func (r *VSphereVM) SetConditions(conditions clusterv1.Conditions) {
 	r.Status.Conditions = conditions
 }
 
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereVM.
+func (r *VSphereVM) GetV1Beta2Conditions() []metav1.Condition {
+	if r.Status.V1Beta2 == nil {
+		return nil
+	}
+	return r.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereVM.
+func (r *VSphereVM) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if r.Status.V1Beta2 == nil {
+		r.Status.V1Beta2 = &VSphereVMV1Beta2Status{}
+	}
+	r.Status.V1Beta2.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // VSphereVMList contains a list of VSphereVM
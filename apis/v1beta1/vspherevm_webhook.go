@@ -26,6 +26,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 )
 
 func (r *VSphereVM) SetupWebhookWithManager(mgr ctrl.Manager) error {
@@ -53,6 +55,30 @@ func (r *VSphereVM) ValidateCreate() error {
 		}
 	}
 
+	allErrs = append(allErrs, validateAddressesFromPools(field.NewPath("spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateNetworkAdapters(field.NewPath("spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateNetworkDeviceRoles(field.NewPath("spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateNetworkDeviceGateways(field.NewPath("spec", "network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateHostnameStrategy(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePowerOffMode(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateTPM(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateEncryption(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateHostAffinity(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateTimeSync(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateOS(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePCIDevices(field.NewPath("spec", "pciDevices"), spec.PCIDevices)...)
+	allErrs = append(allErrs, validateDataDisks(field.NewPath("spec", "dataDisks"), spec.DataDisks)...)
+	allErrs = append(allErrs, validateCloneMode(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateCustomVMXKeys(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateWindowsActivation(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePublishedAddresses(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateExistingVM(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateDeletionPolicy(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validatePlacement(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	if spec.Template == "" && spec.ExistingVM == nil {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "template"), "must be set"))
+	}
+
 	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
@@ -81,6 +107,23 @@ func (r *VSphereVM) ValidateUpdate(old runtime.Object) error {
 	delete(oldVSphereVMSpec, "bootstrapRef")
 	delete(newVSphereVMSpec, "bootstrapRef")
 
+	// allow changes to caBundleRef, which only affects the connection to vCenter
+	delete(oldVSphereVMSpec, "caBundleRef")
+	delete(newVSphereVMSpec, "caBundleRef")
+
+	// allow changes to deletionPolicy, so the VM can be retained before the
+	// VSphereVM is deleted
+	delete(oldVSphereVMSpec, "deletionPolicy")
+	delete(newVSphereVMSpec, "deletionPolicy")
+
+	// allow changes to the resources when they can be updated in place
+	if feature.Gates.Enabled(feature.InPlaceResourceUpdate) {
+		for _, key := range inPlaceUpdatableFields {
+			delete(oldVSphereVMSpec, key)
+			delete(newVSphereVMSpec, key)
+		}
+	}
+
 	newVSphereVMNetwork := newVSphereVMSpec["network"].(map[string]interface{})
 	oldVSphereVMNetwork := oldVSphereVMSpec["network"].(map[string]interface{})
 
@@ -88,6 +131,17 @@ func (r *VSphereVM) ValidateUpdate(old runtime.Object) error {
 	delete(oldVSphereVMNetwork, "devices")
 	delete(newVSphereVMNetwork, "devices")
 
+	// reject the added devices which cannot be hot-added to the running VM
+	if oldVM, ok := old.(*VSphereVM); ok && feature.Gates.Enabled(feature.NetworkDeviceHotAdd) {
+		allErrs = append(allErrs, validateNetworkDeviceHotAdd(field.NewPath("spec", "network", "devices"), oldVM.Spec.Network.Devices, r.Spec.Network.Devices)...)
+	}
+
+	// reject retaining a VM with pool addresses, unless it was admitted
+	// before it was rejected
+	if oldVM, ok := old.(*VSphereVM); ok && len(validateDeletionPolicy(field.NewPath("spec"), oldVM.Spec.VirtualMachineCloneSpec)) == 0 {
+		allErrs = append(allErrs, validateDeletionPolicy(field.NewPath("spec"), r.Spec.VirtualMachineCloneSpec)...)
+	}
+
 	if !reflect.DeepEqual(oldVSphereVMSpec, newVSphereVMSpec) {
 		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "cannot be modified"))
 	}
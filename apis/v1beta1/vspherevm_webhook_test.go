@@ -18,9 +18,15 @@ package v1beta1
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 )
 
 var biosUUID = "vsphere://42305f0b-dad7-1d3d-5727-0eafffffbbbfc"
@@ -49,6 +55,53 @@ func TestVSphereVM_ValidateCreate(t *testing.T) {
 			vSphereVM: createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32", "192.168.0.3/32"}, nil),
 			wantErr:   false,
 		},
+		{
+			name: "hostAffinity sets both hostGroupName and ruleName",
+			vSphereVM: func() *VSphereVM {
+				vm := createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil)
+				vm.Spec.HostAffinity = &HostAffinitySpec{HostGroupName: "licensed-hosts", RuleName: "licensed-vms"}
+				return vm
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "hostAffinity sets neither hostGroupName nor ruleName",
+			vSphereVM: func() *VSphereVM {
+				vm := createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil)
+				vm.Spec.HostAffinity = &HostAffinitySpec{}
+				return vm
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "timeSync sets a zero maxClockSkew",
+			vSphereVM: func() *VSphereVM {
+				vm := createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil)
+				vm.Spec.TimeSync = &TimeSyncSpec{MaxClockSkew: &metav1.Duration{}}
+				return vm
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "timeSync sets maxClockSkew for a Windows guest",
+			vSphereVM: func() *VSphereVM {
+				vm := createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil)
+				vm.Spec.OS = WindowsOS
+				vm.Spec.GuestID = "windows2019srv_64Guest"
+				vm.Spec.TimeSync = &TimeSyncSpec{SyncWithHost: true, MaxClockSkew: &metav1.Duration{Duration: time.Minute}}
+				return vm
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "hostAffinity sets a host group",
+			vSphereVM: func() *VSphereVM {
+				vm := createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil)
+				vm.Spec.HostAffinity = &HostAffinitySpec{HostGroupName: "licensed-hosts"}
+				return vm
+			}(),
+			wantErr: false,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -91,6 +144,39 @@ func TestVSphereVM_ValidateUpdate(t *testing.T) {
 			vSphereVM:    createVSphereVM("foo.com", biosUUID, "", []string{"192.168.0.1/32", "192.168.0.10/32"}, &corev1.ObjectReference{}),
 			wantErr:      false,
 		},
+		{
+			name:         "updating caBundleRef can be done",
+			oldVSphereVM: createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil),
+			vSphereVM: func() *VSphereVM {
+				vm := createVSphereVM("foo.com", biosUUID, "", []string{"192.168.0.1/32"}, nil)
+				vm.Spec.CABundleRef = &CABundleReference{Kind: "ConfigMap", Name: "vcenter-ca"}
+				return vm
+			}(),
+			wantErr: false,
+		},
+		{
+			name:         "updating deletionPolicy can be done",
+			oldVSphereVM: createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil),
+			vSphereVM: func() *VSphereVM {
+				vm := createVSphereVM("foo.com", biosUUID, "", []string{"192.168.0.1/32"}, nil)
+				vm.Spec.DeletionPolicy = PowerOffAndRetainVMDeletionPolicy
+				return vm
+			}(),
+			wantErr: false,
+		},
+		{
+			name:         "retaining a VM with addresses from an IPAM pool cannot be done",
+			oldVSphereVM: createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil),
+			vSphereVM: func() *VSphereVM {
+				vm := createVSphereVM("foo.com", biosUUID, "", []string{"192.168.0.1/32"}, nil)
+				vm.Spec.Network.Devices[0].AddressesFromPools = []corev1.TypedLocalObjectReference{
+					{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"},
+				}
+				vm.Spec.DeletionPolicy = PowerOffAndRetainVMDeletionPolicy
+				return vm
+			}(),
+			wantErr: true,
+		},
 		{
 			name:         "updating server cannot be done",
 			oldVSphereVM: createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil),
@@ -110,13 +196,92 @@ func TestVSphereVM_ValidateUpdate(t *testing.T) {
 	}
 }
 
+func TestVSphereVM_ValidateCreate_StrictPlacementValidation(t *testing.T) {
+	placed := func() *VSphereVM {
+		vm := createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil)
+		vm.Spec.Datacenter = "dc0"
+		vm.Spec.Datastore = "ds0"
+		vm.Spec.Folder = "vms"
+		vm.Spec.ResourcePool = "cluster0/Resources"
+		vm.Spec.Network.Devices[0].NetworkName = "vm-network"
+		return vm
+	}
+
+	g := NewWithT(t)
+	unplaced := createVSphereVM("foo.com", "", "", []string{"192.168.0.1/32"}, nil)
+	g.Expect(unplaced.ValidateCreate()).To(Succeed())
+
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.StrictPlacementValidation, true)()
+
+	tests := []struct {
+		name    string
+		mutate  func(vm *VSphereVM)
+		wantErr string
+	}{
+		{
+			name:   "placement set",
+			mutate: func(vm *VSphereVM) {},
+		},
+		{
+			name:    "datacenter not set",
+			mutate:  func(vm *VSphereVM) { vm.Spec.Datacenter = "" },
+			wantErr: "spec.datacenter",
+		},
+		{
+			name:    "datastore not set",
+			mutate:  func(vm *VSphereVM) { vm.Spec.Datastore = "" },
+			wantErr: "spec.datastore",
+		},
+		{
+			name: "datastore selected by a storage policy",
+			mutate: func(vm *VSphereVM) {
+				vm.Spec.Datastore = ""
+				vm.Spec.StoragePolicyName = "gold"
+			},
+		},
+		{
+			name:    "folder not set",
+			mutate:  func(vm *VSphereVM) { vm.Spec.Folder = "" },
+			wantErr: "spec.folder",
+		},
+		{
+			name:    "resource pool not set",
+			mutate:  func(vm *VSphereVM) { vm.Spec.ResourcePool = "" },
+			wantErr: "spec.resourcePool",
+		},
+		{
+			name:    "network not set",
+			mutate:  func(vm *VSphereVM) { vm.Spec.Network.Devices[0].NetworkName = "" },
+			wantErr: "spec.network.devices[0].networkName",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			vm := placed()
+			tc.mutate(vm)
+			err := vm.ValidateCreate()
+			if tc.wantErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tc.wantErr)))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+
+	// Updates of the VSphereVMs created before the feature was enabled are
+	// not rejected.
+	g.Expect(unplaced.DeepCopy().ValidateUpdate(unplaced)).To(Succeed())
+}
+
 func createVSphereVM(server string, biosUUID string, preferredAPIServerCIDR string, ips []string, bootstrapRef *corev1.ObjectReference) *VSphereVM {
 	VSphereVM := &VSphereVM{
 		Spec: VSphereVMSpec{
 			BiosUUID:     biosUUID,
 			BootstrapRef: bootstrapRef,
 			VirtualMachineCloneSpec: VirtualMachineCloneSpec{
-				Server: server,
+				Template: "ubuntu",
+				Server:   server,
 				Network: NetworkSpec{
 					PreferredAPIServerCIDR: preferredAPIServerCIDR,
 					Devices:                []NetworkDeviceSpec{},
@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VSphereVMInventorySpec defines the desired state of VSphereVMInventory.
+type VSphereVMInventorySpec struct {
+	// ClusterName is the name of the Cluster whose VMs are reported.
+	ClusterName string `json:"clusterName"`
+}
+
+// VSphereVMInventoryEntry describes a VM of the cluster as observed in vCenter.
+type VSphereVMInventoryEntry struct {
+	// Name is the name of the VSphereVM.
+	Name string `json:"name"`
+
+	// UUID is the BIOS UUID of the VM.
+	// +optional
+	UUID string `json:"uuid,omitempty"`
+
+	// Host is the name of the ESXi host running the VM.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Datastores is the list of the names of the datastores backing the VM.
+	// +optional
+	Datastores []string `json:"datastores,omitempty"`
+
+	// IPAddrs is the list of the IP addresses reported for the VM.
+	// +optional
+	IPAddrs []string `json:"ipAddrs,omitempty"`
+
+	// PowerState is the power state of the VM.
+	// +optional
+	PowerState VirtualMachinePowerState `json:"powerState,omitempty"`
+}
+
+// VSphereVMInventoryStatus defines the observed state of VSphereVMInventory.
+type VSphereVMInventoryStatus struct {
+	// LastUpdated is the time at which the inventory was last collected.
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+
+	// VirtualMachines is the list of the VMs of the cluster.
+	// +optional
+	VirtualMachines []VSphereVMInventoryEntry `json:"virtualMachines,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=vspherevminventories,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterName",description="Cluster whose VMs are reported"
+// +kubebuilder:printcolumn:name="LastUpdated",type="date",JSONPath=".status.lastUpdated",description="Time at which the inventory was last collected"
+
+// VSphereVMInventory is a periodically refreshed snapshot of the VMs of a
+// cluster, for consumption by external systems without vCenter access.
+type VSphereVMInventory struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VSphereVMInventorySpec   `json:"spec,omitempty"`
+	Status VSphereVMInventoryStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VSphereVMInventoryList contains a list of VSphereVMInventory.
+type VSphereVMInventoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VSphereVMInventory `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VSphereVMInventory{}, &VSphereVMInventoryList{})
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// WarmPoolLabel is the label set on the VSphereVMs of a VSphereWarmPool
+	// to the name of the pool.
+	WarmPoolLabel = "vspherewarmpool.infrastructure.cluster.x-k8s.io/name"
+
+	// WarmPoolClaimedByAnnotation is set on a VSphereVM of a VSphereWarmPool
+	// to the name of the VSphereVM that claimed its virtual machine. A claimed
+	// VSphereVM is no longer reconciled, and is deleted without destroying its
+	// virtual machine.
+	WarmPoolClaimedByAnnotation = "vspherewarmpool.infrastructure.cluster.x-k8s.io/claimed-by"
+
+	// WarmPoolVMAnnotation is set on a VSphereVM to the name of the VSphereVM
+	// of a VSphereWarmPool whose virtual machine it claimed.
+	WarmPoolVMAnnotation = "vspherewarmpool.infrastructure.cluster.x-k8s.io/vm"
+
+	// WarmPoolPowerStateAnnotation is set on the VSphereVMs of a
+	// VSphereWarmPool to the power state their virtual machine is kept in,
+	// and on the VSphereVM claiming a virtual machine kept powered on until
+	// the virtual machine is restarted with its bootstrap data.
+	WarmPoolPowerStateAnnotation = "vspherewarmpool.infrastructure.cluster.x-k8s.io/power-state"
+)
+
+// WarmPoolPowerState is the power state of the VMs of a VSphereWarmPool
+// until they are claimed.
+// +kubebuilder:validation:Enum=poweredOff;poweredOn
+type WarmPoolPowerState string
+
+const (
+	// PoweredOffWarmPoolPowerState keeps the VMs powered off. A claimed VM
+	// boots for the first time with its bootstrap data. This is the default.
+	PoweredOffWarmPoolPowerState WarmPoolPowerState = "poweredOff"
+
+	// PoweredOnWarmPoolPowerState powers on the VMs, which boot without
+	// bootstrap data. A claimed VM is restarted once its bootstrap data is
+	// set, which bootstraps it without waiting for its guest OS to boot for
+	// the first time.
+	PoweredOnWarmPoolPowerState WarmPoolPowerState = "poweredOn"
+)
+
+// VSphereWarmPoolSpec defines the desired state of VSphereWarmPool.
+type VSphereWarmPoolSpec struct {
+	// Replicas is the number of unclaimed VMs kept in the pool.
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+
+	// ClusterName is the name of the Cluster whose machines claim the VMs of
+	// the pool. The VMs are cloned with the credentials of the cluster. When
+	// empty, the VMs are cloned with the credentials of the manager and can be
+	// claimed by the machines of any cluster in the namespace.
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// MachineDeploymentName is the name of the MachineDeployment whose
+	// machines claim the VMs of the pool. When empty, the VMs can be claimed
+	// by the machines of any MachineDeployment, and by the control plane
+	// machines.
+	// Requires ClusterName, as MachineDeployments of other clusters may have
+	// the same name.
+	// +optional
+	MachineDeploymentName string `json:"machineDeploymentName,omitempty"`
+
+	// PowerState is the power state of the VMs of the pool until they are
+	// claimed.
+	// Defaults to poweredOff.
+	// +optional
+	PowerState WarmPoolPowerState `json:"powerState,omitempty"`
+
+	// Template is the clone spec of the VMs of the pool. A machine claims a VM
+	// of the pool instead of cloning one when its clone spec, after the
+	// overrides of its failure domain, is equal to the template.
+	Template VirtualMachineCloneSpec `json:"template"`
+}
+
+// VSphereWarmPoolStatus defines the observed state of VSphereWarmPool.
+type VSphereWarmPoolStatus struct {
+	// Replicas is the number of unclaimed VMs of the pool.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of unclaimed VMs of the pool that are cloned
+	// and can be claimed.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// Conditions defines current service state of the VSphereWarmPool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereWarmPool status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereWarmPoolV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereWarmPoolV1Beta2Status groups the fields of the VSphereWarmPool status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereWarmPoolV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereWarmPool using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=vspherewarmpools,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".spec.replicas",description="Desired number of unclaimed VMs"
+// +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.replicas",description="Number of unclaimed VMs"
+// +kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas",description="Number of VMs that can be claimed"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of VSphereWarmPool"
+
+// VSphereWarmPool keeps a number of VMs cloned ahead of time, powered off or
+// powered on without bootstrap data, which the machines matching its template
+// claim instead of cloning a VM.
+type VSphereWarmPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VSphereWarmPoolSpec   `json:"spec,omitempty"`
+	Status VSphereWarmPoolStatus `json:"status,omitempty"`
+}
+
+func (r *VSphereWarmPool) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+func (r *VSphereWarmPool) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereWarmPool.
+func (r *VSphereWarmPool) GetV1Beta2Conditions() []metav1.Condition {
+	if r.Status.V1Beta2 == nil {
+		return nil
+	}
+	return r.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereWarmPool.
+func (r *VSphereWarmPool) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if r.Status.V1Beta2 == nil {
+		r.Status.V1Beta2 = &VSphereWarmPoolV1Beta2Status{}
+	}
+	r.Status.V1Beta2.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VSphereWarmPoolList contains a list of VSphereWarmPool.
+type VSphereWarmPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VSphereWarmPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VSphereWarmPool{}, &VSphereWarmPoolList{})
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (r *VSphereWarmPool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-vspherewarmpool,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vspherewarmpools,versions=v1beta1,name=validation.vspherewarmpool.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &VSphereWarmPool{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *VSphereWarmPool) ValidateCreate() error {
+	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, r.validate())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *VSphereWarmPool) ValidateUpdate(old runtime.Object) error {
+	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, r.validate())
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *VSphereWarmPool) ValidateDelete() error {
+	return nil
+}
+
+func (r *VSphereWarmPool) validate() field.ErrorList {
+	var allErrs field.ErrorList
+
+	// The name of a MachineDeployment is only unique within its cluster, so
+	// the VMs of the pool would be claimed by the MachineDeployments of the
+	// same name in the other clusters of the namespace.
+	if r.Spec.MachineDeploymentName != "" && r.Spec.ClusterName == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("spec", "clusterName"), "must be set when machineDeploymentName is set"))
+	}
+
+	// Ignition only reads its config on the first boot of the guest, which
+	// the VMs kept powered on boot without bootstrap data.
+	if r.Spec.PowerState == PoweredOnWarmPoolPowerState && r.Spec.Template.BootstrapFormat == IgnitionBootstrapFormat {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "powerState"), "cannot be poweredOn when template.bootstrapFormat is ignition"))
+	}
+
+	return allErrs
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestVSphereWarmPool_ValidateCreate(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name    string
+		pool    VSphereWarmPool
+		wantErr bool
+	}{
+		{
+			name: "pool of any cluster and any MachineDeployment",
+			pool: VSphereWarmPool{},
+		},
+		{
+			name: "pool of a MachineDeployment of a cluster",
+			pool: VSphereWarmPool{Spec: VSphereWarmPoolSpec{ClusterName: "cluster-0", MachineDeploymentName: "md-0"}},
+		},
+		{
+			name:    "pool of a MachineDeployment without a cluster",
+			pool:    VSphereWarmPool{Spec: VSphereWarmPoolSpec{MachineDeploymentName: "md-0"}},
+			wantErr: true,
+		},
+		{
+			name: "pool of powered on VMs",
+			pool: VSphereWarmPool{Spec: VSphereWarmPoolSpec{PowerState: PoweredOnWarmPoolPowerState}},
+		},
+		{
+			name: "pool of powered on VMs bootstrapped by Ignition",
+			pool: VSphereWarmPool{Spec: VSphereWarmPoolSpec{
+				PowerState: PoweredOnWarmPoolPowerState,
+				Template:   VirtualMachineCloneSpec{BootstrapFormat: IgnitionBootstrapFormat},
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		for _, err := range []error{tc.pool.ValidateCreate(), tc.pool.ValidateUpdate(&VSphereWarmPool{})} {
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred(), tc.name)
+			} else {
+				g.Expect(err).NotTo(HaveOccurred(), tc.name)
+			}
+		}
+	}
+}
@@ -17,11 +17,521 @@ limitations under the License.
 package v1beta1
 
 import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 )
 
+// inPlaceUpdatableFields are the fields of a VirtualMachineCloneSpec that can
+// be changed on a running VM when the InPlaceResourceUpdate feature is enabled.
+var inPlaceUpdatableFields = []string{"numCPUs", "memoryMiB", "diskGiB"}
+
+func validatePCIDevices(fldPath *field.Path, devices []PCIDeviceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, device := range devices {
+		idxPath := fldPath.Index(i)
+		if device.VGPUProfile != "" {
+			if device.DeviceID != nil || device.VendorID != nil {
+				allErrs = append(allErrs, field.Invalid(idxPath, device, "vGPUProfile cannot be set together with deviceId and vendorId"))
+			}
+			continue
+		}
+		if device.DeviceID == nil {
+			allErrs = append(allErrs, field.Required(idxPath.Child("deviceId"), "must be set when vGPUProfile is not set"))
+		}
+		if device.VendorID == nil {
+			allErrs = append(allErrs, field.Required(idxPath.Child("vendorId"), "must be set when vGPUProfile is not set"))
+		}
+	}
+	return allErrs
+}
+
+func validateNetworkDeviceRoles(fldPath *field.Path, devices []NetworkDeviceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	managementDevices := 0
+	for i, device := range devices {
+		idxPath := fldPath.Index(i)
+		switch device.Role {
+		case NetworkDeviceRoleManagement:
+			managementDevices++
+			if managementDevices > 1 {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("role"), device.Role, "only one device can have the Management role"))
+			}
+		case NetworkDeviceRoleWorkload:
+			if device.Gateway4 != "" {
+				allErrs = append(allErrs, field.Forbidden(idxPath.Child("gateway4"), "cannot be set on a device with the Workload role, use routes instead"))
+			}
+			if device.Gateway6 != "" {
+				allErrs = append(allErrs, field.Forbidden(idxPath.Child("gateway6"), "cannot be set on a device with the Workload role, use routes instead"))
+			}
+			if device.SLAAC {
+				allErrs = append(allErrs, field.Forbidden(idxPath.Child("slaac"), "cannot be set on a device with the Workload role, since the router advertisements install a default route"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateNetworkDeviceGateways rejects the gateways which are not addresses
+// of the family of their field, e.g. an IPv6 gateway set as gateway4.
+func validateNetworkDeviceGateways(fldPath *field.Path, devices []NetworkDeviceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, device := range devices {
+		idxPath := fldPath.Index(i)
+		if device.Gateway4 != "" {
+			if ip := net.ParseIP(device.Gateway4); ip == nil || ip.To4() == nil {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("gateway4"), device.Gateway4, "must be an IPv4 address"))
+			}
+		}
+		if device.Gateway6 != "" {
+			if ip := net.ParseIP(device.Gateway6); ip == nil || ip.To4() != nil {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("gateway6"), device.Gateway6, "must be an IPv6 address"))
+			}
+		}
+	}
+	return allErrs
+}
+
+func validatePublishedAddresses(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Network.PublishedAddresses == nil {
+		return allErrs
+	}
+	for i, cidr := range spec.Network.PublishedAddresses.CIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("network", "publishedAddresses", "cidrs").Index(i), cidr, "must be a valid CIDR"))
+		}
+	}
+	return allErrs
+}
+
+// validateNetworkDeviceHotAdd rejects the devices added to a running machine
+// whose adapter cannot be hot-added to its VM.
+func validateNetworkDeviceHotAdd(fldPath *field.Path, oldDevices, devices []NetworkDeviceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	for i := len(oldDevices); i < len(devices); i++ {
+		switch devices[i].AdapterType {
+		case NetworkAdapterTypeSRIOV, NetworkAdapterTypePVRDMA:
+			allErrs = append(allErrs, field.Forbidden(fldPath.Index(i).Child("adapterType"), fmt.Sprintf("a %s adapter cannot be hot-added", devices[i].AdapterType)))
+		}
+	}
+	return allErrs
+}
+
+func validateNetworkAdapters(fldPath *field.Path, devices []NetworkDeviceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, device := range devices {
+		idxPath := fldPath.Index(i)
+		if device.MACAddr != "" {
+			if _, err := net.ParseMAC(device.MACAddr); err != nil {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("macAddr"), device.MACAddr, "must be a valid MAC address"))
+			}
+		}
+		if device.AdapterType == NetworkAdapterTypeSRIOV {
+			if device.PhysicalFunction == "" {
+				allErrs = append(allErrs, field.Required(idxPath.Child("physicalFunction"), "must be set when adapterType is sriov"))
+			}
+		} else if device.PhysicalFunction != "" {
+			allErrs = append(allErrs, field.Forbidden(idxPath.Child("physicalFunction"), "can only be set when adapterType is sriov"))
+		}
+	}
+	return allErrs
+}
+
+func validateAddressesFromPools(fldPath *field.Path, devices []NetworkDeviceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, device := range devices {
+		for j, pool := range device.AddressesFromPools {
+			poolPath := fldPath.Index(i).Child("addressesFromPools").Index(j)
+			if pool.APIGroup == nil || *pool.APIGroup == "" {
+				allErrs = append(allErrs, field.Required(poolPath.Child("apiGroup"), "must be set"))
+			}
+			if pool.Kind == "" {
+				allErrs = append(allErrs, field.Required(poolPath.Child("kind"), "must be set"))
+			}
+			if pool.Name == "" {
+				allErrs = append(allErrs, field.Required(poolPath.Child("name"), "must be set"))
+			}
+		}
+	}
+	return allErrs
+}
+
+func validateHostnameStrategy(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	domainPath := fldPath.Child("hostnameDomain")
+	if spec.HostnameStrategy != FQDNHostnameStrategy {
+		if spec.HostnameDomain != "" {
+			allErrs = append(allErrs, field.Forbidden(domainPath, "can only be set when hostnameStrategy is fqdn"))
+		}
+		return allErrs
+	}
+	if spec.HostnameDomain == "" {
+		allErrs = append(allErrs, field.Required(domainPath, "must be set when hostnameStrategy is fqdn"))
+		return allErrs
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(spec.HostnameDomain) {
+		allErrs = append(allErrs, field.Invalid(domainPath, spec.HostnameDomain, msg))
+	}
+	return allErrs
+}
+
+func validateCloneMode(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.CloneMode == FullClone && spec.Snapshot != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("snapshot"), "cannot be set when cloneMode is fullClone"))
+	}
+	return allErrs
+}
+
+func validateHostAffinity(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	affinity := spec.HostAffinity
+	if affinity == nil {
+		return allErrs
+	}
+	if (affinity.HostGroupName == "") == (affinity.RuleName == "") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("hostAffinity"), affinity, "exactly one of hostGroupName and ruleName must be set"))
+	}
+	return allErrs
+}
+
+func validateTimeSync(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.TimeSync == nil || spec.TimeSync.MaxClockSkew == nil {
+		return allErrs
+	}
+	skewPath := fldPath.Child("timeSync", "maxClockSkew")
+	if spec.TimeSync.MaxClockSkew.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(skewPath, spec.TimeSync.MaxClockSkew.Duration.String(), "must be greater than 0"))
+	}
+	if spec.OS == WindowsOS {
+		allErrs = append(allErrs, field.Forbidden(skewPath, "cannot be set for Windows guests, which do not report their clock"))
+	}
+	return allErrs
+}
+
+func validateTPM(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.TPM && spec.StoragePolicyName == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("storagePolicyName"), "must be set to a storage policy with VM encryption when tpm is enabled"))
+	}
+	return allErrs
+}
+
+// validateEncryption requires the storage policy with VM encryption of a
+// virtual machine encrypted when it is cloned. The encryption of an existing
+// VM is only reported.
+func validateEncryption(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Encryption != nil && spec.ExistingVM == nil && spec.StoragePolicyName == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("storagePolicyName"), "must be set to a storage policy with VM encryption when encryption is set"))
+	}
+	return allErrs
+}
+
+func validateOS(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	family := LinuxOS
+	if spec.OS == WindowsOS {
+		family = WindowsOS
+	}
+	// The identifiers of all the Windows guests start with "windows".
+	if spec.GuestID != "" && strings.HasPrefix(spec.GuestID, "windows") != (family == WindowsOS) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("guestID"), spec.GuestID, fmt.Sprintf("must identify a %s guest", family)))
+	}
+	if spec.CustomizationSpec != "" && family != WindowsOS {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("customizationSpec"), "can only be set when os is Windows"))
+	}
+	if spec.BootstrapFormat == IgnitionBootstrapFormat && family == WindowsOS {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("bootstrapFormat"), "cannot be ignition when os is Windows"))
+	}
+	return allErrs
+}
+
+func validateWindowsActivation(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.WindowsActivation == nil {
+		return allErrs
+	}
+	activationPath := fldPath.Child("windowsActivation")
+	if spec.CustomizationSpec == "" {
+		allErrs = append(allErrs, field.Forbidden(activationPath, "requires customizationSpec"))
+	}
+	// The KMS server is written in the commands run by the guest, so it is
+	// restricted to a host and a port.
+	if server := spec.WindowsActivation.KMSServer; server != "" && !isValidKMSServer(server) {
+		allErrs = append(allErrs, field.Invalid(activationPath.Child("kmsServer"), server, "must be a hostname or an IP address, optionally followed by a port"))
+	}
+	if name := spec.WindowsActivation.ProductKeySecretName; name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(activationPath.Child("productKeySecretName"), name, msg))
+		}
+	}
+	return allErrs
+}
+
+// isValidKMSServer returns whether a KMS server is a hostname or an IP
+// address, optionally followed by a port.
+func isValidKMSServer(server string) bool {
+	host := server
+	if h, port, err := net.SplitHostPort(server); err == nil {
+		n, err := strconv.Atoi(port)
+		if err != nil || len(validation.IsValidPortNum(n)) > 0 {
+			return false
+		}
+		host = h
+	}
+	return len(validation.IsValidIP(host)) == 0 || len(validation.IsDNS1123Subdomain(strings.ToLower(host))) == 0
+}
+
+func validatePowerOffMode(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	timeoutPath := fldPath.Child("guestSoftPowerOffTimeout")
+	if spec.GuestSoftPowerOffTimeout == nil {
+		return allErrs
+	}
+	if spec.PowerOffMode != TrySoftPowerOffMode {
+		allErrs = append(allErrs, field.Forbidden(timeoutPath, "can only be set when powerOffMode is trySoft"))
+	}
+	if spec.GuestSoftPowerOffTimeout.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(timeoutPath, spec.GuestSoftPowerOffTimeout.Duration.String(), "must be greater than 0"))
+	}
+	return allErrs
+}
+
+// validatePlacement requires the placement of the VM to be set when the
+// StrictPlacementValidation feature is enabled, so that the VM is not placed
+// in the defaults discovered in vCenter. The datastore is not required when a
+// storage policy selects it, and the placement of an existing VM is not
+// required.
+func validatePlacement(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if !feature.Gates.Enabled(feature.StrictPlacementValidation) || spec.ExistingVM != nil {
+		return allErrs
+	}
+	const msg = "must be set when the StrictPlacementValidation feature is enabled"
+	if spec.Datacenter == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("datacenter"), msg))
+	}
+	if spec.Datastore == "" && spec.StoragePolicyName == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("datastore"), msg))
+	}
+	if spec.Folder == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("folder"), msg))
+	}
+	if spec.ResourcePool == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("resourcePool"), msg))
+	}
+	for i, device := range spec.Network.Devices {
+		if device.NetworkName == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("network", "devices").Index(i).Child("networkName"), msg))
+		}
+	}
+	return allErrs
+}
+
+// validateDeletionPolicy forbids retaining the VM of a VSphereVM whose
+// addresses are claimed from IPAM pools: the claims are deleted with the
+// VSphereVM, so the addresses of the retained VM would be allocated again.
+func validateDeletionPolicy(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.DeletionPolicy != RetainVMDeletionPolicy && spec.DeletionPolicy != PowerOffAndRetainVMDeletionPolicy {
+		return allErrs
+	}
+	for _, device := range spec.Network.Devices {
+		if len(device.AddressesFromPools) > 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("deletionPolicy"), fmt.Sprintf("cannot be %s when addresses are claimed from IPAM pools", spec.DeletionPolicy)))
+			break
+		}
+	}
+	return allErrs
+}
+
+// validateExistingVM requires an existing VM to be referenced by exactly one
+// of its managed object reference and its BIOS UUID, and forbids the template
+// it would otherwise be cloned from and the deletion policy of cloned VMs.
+func validateExistingVM(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.ExistingVM == nil {
+		return allErrs
+	}
+	existingPath := fldPath.Child("existingVM")
+	switch {
+	case spec.ExistingVM.MoRef == "" && spec.ExistingVM.UUID == "":
+		allErrs = append(allErrs, field.Required(existingPath, "either moRef or uuid must be set"))
+	case spec.ExistingVM.MoRef != "" && spec.ExistingVM.UUID != "":
+		allErrs = append(allErrs, field.Forbidden(existingPath.Child("uuid"), "cannot be set together with moRef"))
+	}
+	if spec.Template != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("template"), "cannot be set together with existingVM"))
+	}
+	if spec.DeletionPolicy != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("deletionPolicy"), "cannot be set together with existingVM, use existingVM.deletionPolicy"))
+	}
+	return allErrs
+}
+
+func validateTemplateSource(fldPath *field.Path, spec VSphereMachineSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	switch {
+	case spec.ExistingVM != nil && spec.ImageRef != nil:
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("imageRef"), "cannot be set together with existingVM"))
+	case spec.ExistingVM != nil:
+	case spec.Template != "" && spec.ImageRef != nil:
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("imageRef"), "cannot be set together with template"))
+	case spec.Template == "" && spec.ImageRef == nil:
+		allErrs = append(allErrs, field.Required(fldPath.Child("template"), "either template or imageRef must be set"))
+	case spec.ImageRef != nil && spec.ImageRef.Name == "":
+		allErrs = append(allErrs, field.Required(fldPath.Child("imageRef", "name"), "must be set"))
+	}
+	return allErrs
+}
+
+// reservedVMXKeys are the keys of the extraConfig of a VM which are set by
+// CAPV, from the bootstrap data and the metadata of the VM.
+var reservedVMXKeys = map[string]bool{
+	"guestinfo.userdata":                       true,
+	"guestinfo.userdata.encoding":              true,
+	"guestinfo.metadata":                       true,
+	"guestinfo.metadata.encoding":              true,
+	"guestinfo.ignition.config.data":           true,
+	"guestinfo.ignition.config.data.encoding":  true,
+	"guestinfo.afterburn.initrd.network-kargs": true,
+}
+
+// reservedVMXKeyPrefixes are the prefixes of the keys of the extraConfig of a
+// VM which are set by CAPV, e.g. for the data disks of the VM, or reported to
+// CAPV by the guest.
+var reservedVMXKeyPrefixes = []string{"capv.", "guestinfo.capv."}
+
+// IsReservedVMXKey returns true if a key of the extraConfig of a VM is set by
+// CAPV, so it cannot be set in customVMXKeys. Like vSphere, it ignores the
+// case of the key.
+func IsReservedVMXKey(key string) bool {
+	key = strings.ToLower(key)
+	if reservedVMXKeys[key] {
+		return true
+	}
+	for _, prefix := range reservedVMXKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateCustomVMXKeys(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	keys := make([]string, 0, len(spec.CustomVMXKeys))
+	for key := range spec.CustomVMXKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyPath := fldPath.Child("customVMXKeys").Key(key)
+		switch {
+		case strings.TrimSpace(key) == "":
+			allErrs = append(allErrs, field.Invalid(keyPath, key, "must not be empty"))
+		case IsReservedVMXKey(key):
+			allErrs = append(allErrs, field.Forbidden(keyPath, "is set by the provider"))
+		}
+	}
+	return allErrs
+}
+
+func validateDataDisks(fldPath *field.Path, disks []DataDiskSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, disk := range disks {
+		idxPath := fldPath.Index(i)
+		switch {
+		case disk.Name == "":
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), "must be set"))
+		case names[disk.Name]:
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), disk.Name))
+		}
+		names[disk.Name] = true
+		if disk.SizeGiB <= 0 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("sizeGiB"), disk.SizeGiB, "must be greater than 0"))
+		}
+	}
+	return append(allErrs, validateDataDiskControllers(fldPath, disks)...)
+}
+
+// maxDiskControllers is the maximum number of controllers of each type of a
+// VM.
+const maxDiskControllers = 4
+
+// reservedControllerOptions are the options of a controller, lowercased,
+// which vSphere sets from its device.
+var reservedControllerOptions = map[string]bool{
+	"present":       true,
+	"virtualdev":    true,
+	"pcislotnumber": true,
+}
+
+func validateDataDiskControllers(fldPath *field.Path, disks []DataDiskSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	controllers := map[DiskControllerType]int{}
+	shared := map[DiskControllerType]map[string]string{}
+	for i, disk := range disks {
+		controller := disk.Controller
+		if controller == nil {
+			continue
+		}
+		ctrlPath := fldPath.Index(i).Child("controller")
+		switch controller.Type {
+		case ParaVirtualDiskControllerType, NVMeDiskControllerType:
+		default:
+			allErrs = append(allErrs, field.NotSupported(ctrlPath.Child("type"), controller.Type, []string{string(ParaVirtualDiskControllerType), string(NVMeDiskControllerType)}))
+			continue
+		}
+		if controller.Dedicated || shared[controller.Type] == nil {
+			controllers[controller.Type]++
+			if controllers[controller.Type] == maxDiskControllers+1 {
+				allErrs = append(allErrs, field.Invalid(ctrlPath, controller.Type, fmt.Sprintf("a VM cannot have more than %d %s controllers", maxDiskControllers, controller.Type)))
+			}
+		}
+		options := map[string]string{}
+		if !controller.Dedicated {
+			if shared[controller.Type] == nil {
+				shared[controller.Type] = map[string]string{}
+			}
+			options = shared[controller.Type]
+		}
+		keys := make([]string, 0, len(controller.ExtraConfig))
+		for key := range controller.ExtraConfig {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			keyPath := ctrlPath.Child("extraConfig").Key(key)
+			value := controller.ExtraConfig[key]
+			switch {
+			case strings.TrimSpace(key) == "" || strings.HasPrefix(key, ".") || strings.Contains(key, ":"):
+				allErrs = append(allErrs, field.Invalid(keyPath, key, "must be an option of the controller"))
+			case reservedControllerOptions[strings.ToLower(key)]:
+				allErrs = append(allErrs, field.Forbidden(keyPath, "is set by vSphere"))
+			case options[strings.ToLower(key)] != "" && options[strings.ToLower(key)] != value:
+				allErrs = append(allErrs, field.Invalid(keyPath, value, fmt.Sprintf("must match the value %q set by another disk of the shared %s controller", options[strings.ToLower(key)], controller.Type)))
+			default:
+				options[strings.ToLower(key)] = value
+			}
+		}
+	}
+	return allErrs
+}
+
 func aggregateObjErrors(gk schema.GroupKind, name string, allErrs field.ErrorList) error {
 	if len(allErrs) == 0 {
 		return nil
@@ -22,7 +22,8 @@ limitations under the License.
 package v1beta1
 
 import (
-	"k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	apiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/errors"
@@ -43,6 +44,21 @@ func (in *APIEndpoint) DeepCopy() *APIEndpoint {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AVIEndpointSpec) DeepCopyInto(out *AVIEndpointSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AVIEndpointSpec.
+func (in *AVIEndpointSpec) DeepCopy() *AVIEndpointSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AVIEndpointSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AllowedNamespaces) DeepCopyInto(out *AllowedNamespaces) {
 	*out = *in
@@ -60,415 +76,2273 @@ func (in *AllowedNamespaces) DeepCopy() *AllowedNamespaces {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *FailureDomain) DeepCopyInto(out *FailureDomain) {
+func (in *CABundleReference) DeepCopyInto(out *CABundleReference) {
 	*out = *in
-	if in.AutoConfigure != nil {
-		in, out := &in.AutoConfigure, &out.AutoConfigure
-		*out = new(bool)
-		**out = **in
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailureDomain.
-func (in *FailureDomain) DeepCopy() *FailureDomain {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CABundleReference.
+func (in *CABundleReference) DeepCopy() *CABundleReference {
 	if in == nil {
 		return nil
 	}
-	out := new(FailureDomain)
+	out := new(CABundleReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *FailureDomainHosts) DeepCopyInto(out *FailureDomainHosts) {
+func (in *ClusterCreationRollbackSpec) DeepCopyInto(out *ClusterCreationRollbackSpec) {
 	*out = *in
+	out.Timeout = in.Timeout
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailureDomainHosts.
-func (in *FailureDomainHosts) DeepCopy() *FailureDomainHosts {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCreationRollbackSpec.
+func (in *ClusterCreationRollbackSpec) DeepCopy() *ClusterCreationRollbackSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(FailureDomainHosts)
+	out := new(ClusterCreationRollbackSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Network) DeepCopyInto(out *Network) {
+func (in *ClusterCreationRollbackStatus) DeepCopyInto(out *ClusterCreationRollbackStatus) {
 	*out = *in
-	if in.DHCP4 != nil {
-		in, out := &in.DHCP4, &out.DHCP4
-		*out = new(bool)
-		**out = **in
-	}
-	if in.DHCP6 != nil {
-		in, out := &in.DHCP6, &out.DHCP6
-		*out = new(bool)
-		**out = **in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Machines != nil {
+		in, out := &in.Machines, &out.Machines
+		*out = make([]MachineRollbackReport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
-func (in *Network) DeepCopy() *Network {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterCreationRollbackStatus.
+func (in *ClusterCreationRollbackStatus) DeepCopy() *ClusterCreationRollbackStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Network)
+	out := new(ClusterCreationRollbackStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NetworkDeviceSpec) DeepCopyInto(out *NetworkDeviceSpec) {
+func (in *ClusterModule) DeepCopyInto(out *ClusterModule) {
 	*out = *in
-	if in.IPAddrs != nil {
-		in, out := &in.IPAddrs, &out.IPAddrs
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.MTU != nil {
-		in, out := &in.MTU, &out.MTU
-		*out = new(int64)
-		**out = **in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterModule.
+func (in *ClusterModule) DeepCopy() *ClusterModule {
+	if in == nil {
+		return nil
 	}
+	out := new(ClusterModule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterNetworkSettings) DeepCopyInto(out *ClusterNetworkSettings) {
+	*out = *in
 	if in.Nameservers != nil {
 		in, out := &in.Nameservers, &out.Nameservers
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.Routes != nil {
-		in, out := &in.Routes, &out.Routes
-		*out = make([]NetworkRouteSpec, len(*in))
-		copy(*out, *in)
-	}
 	if in.SearchDomains != nil {
 		in, out := &in.SearchDomains, &out.SearchDomains
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NTPServers != nil {
+		in, out := &in.NTPServers, &out.NTPServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(ProxySpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDeviceSpec.
-func (in *NetworkDeviceSpec) DeepCopy() *NetworkDeviceSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterNetworkSettings.
+func (in *ClusterNetworkSettings) DeepCopy() *ClusterNetworkSettings {
 	if in == nil {
 		return nil
 	}
-	out := new(NetworkDeviceSpec)
+	out := new(ClusterNetworkSettings)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NetworkRouteSpec) DeepCopyInto(out *NetworkRouteSpec) {
+func (in *ClusterPermissionSpec) DeepCopyInto(out *ClusterPermissionSpec) {
 	*out = *in
+	if in.Privileges != nil {
+		in, out := &in.Privileges, &out.Privileges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Propagate != nil {
+		in, out := &in.Propagate, &out.Propagate
+		*out = new(bool)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkRouteSpec.
-func (in *NetworkRouteSpec) DeepCopy() *NetworkRouteSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPermissionSpec.
+func (in *ClusterPermissionSpec) DeepCopy() *ClusterPermissionSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(NetworkRouteSpec)
+	out := new(ClusterPermissionSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
+func (in *ClusterPermissionStatus) DeepCopyInto(out *ClusterPermissionStatus) {
 	*out = *in
-	if in.Devices != nil {
-		in, out := &in.Devices, &out.Devices
-		*out = make([]NetworkDeviceSpec, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.Routes != nil {
-		in, out := &in.Routes, &out.Routes
-		*out = make([]NetworkRouteSpec, len(*in))
+	if in.Privileges != nil {
+		in, out := &in.Privileges, &out.Privileges
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
-func (in *NetworkSpec) DeepCopy() *NetworkSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPermissionStatus.
+func (in *ClusterPermissionStatus) DeepCopy() *ClusterPermissionStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(NetworkSpec)
+	out := new(ClusterPermissionStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NetworkStatus) DeepCopyInto(out *NetworkStatus) {
+func (in *ClusterPlacementSpec) DeepCopyInto(out *ClusterPlacementSpec) {
 	*out = *in
-	if in.IPAddrs != nil {
-		in, out := &in.IPAddrs, &out.IPAddrs
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.CPU != nil {
+		in, out := &in.CPU, &out.CPU
+		*out = new(ResourceAllocation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Memory != nil {
+		in, out := &in.Memory, &out.Memory
+		*out = new(ResourceAllocation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Permission != nil {
+		in, out := &in.Permission, &out.Permission
+		*out = new(ClusterPermissionSpec)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkStatus.
-func (in *NetworkStatus) DeepCopy() *NetworkStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPlacementSpec.
+func (in *ClusterPlacementSpec) DeepCopy() *ClusterPlacementSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(NetworkStatus)
+	out := new(ClusterPlacementSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PlacementConstraint) DeepCopyInto(out *PlacementConstraint) {
+func (in *ClusterPlacementStatus) DeepCopyInto(out *ClusterPlacementStatus) {
 	*out = *in
+	if in.Permission != nil {
+		in, out := &in.Permission, &out.Permission
+		*out = new(ClusterPermissionStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementConstraint.
-func (in *PlacementConstraint) DeepCopy() *PlacementConstraint {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPlacementStatus.
+func (in *ClusterPlacementStatus) DeepCopy() *ClusterPlacementStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PlacementConstraint)
+	out := new(ClusterPlacementStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SSHUser) DeepCopyInto(out *SSHUser) {
+func (in *ControlPlaneEndpointFailoverStatus) DeepCopyInto(out *ControlPlaneEndpointFailoverStatus) {
 	*out = *in
-	if in.AuthorizedKeys != nil {
-		in, out := &in.AuthorizedKeys, &out.AuthorizedKeys
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.LastProbeTime != nil {
+		in, out := &in.LastProbeTime, &out.LastProbeTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastFailoverTime != nil {
+		in, out := &in.LastFailoverTime, &out.LastFailoverTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHUser.
-func (in *SSHUser) DeepCopy() *SSHUser {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneEndpointFailoverStatus.
+func (in *ControlPlaneEndpointFailoverStatus) DeepCopy() *ControlPlaneEndpointFailoverStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(SSHUser)
+	out := new(ControlPlaneEndpointFailoverStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Topology) DeepCopyInto(out *Topology) {
+func (in *ControlPlaneEndpointProviderSpec) DeepCopyInto(out *ControlPlaneEndpointProviderSpec) {
 	*out = *in
-	if in.ComputeCluster != nil {
-		in, out := &in.ComputeCluster, &out.ComputeCluster
-		*out = new(string)
-		**out = **in
+	if in.KubeVIP != nil {
+		in, out := &in.KubeVIP, &out.KubeVIP
+		*out = new(KubeVIPEndpointSpec)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.Hosts != nil {
-		in, out := &in.Hosts, &out.Hosts
-		*out = new(FailureDomainHosts)
+	if in.AVI != nil {
+		in, out := &in.AVI, &out.AVI
+		*out = new(AVIEndpointSpec)
 		**out = **in
 	}
-	if in.Networks != nil {
-		in, out := &in.Networks, &out.Networks
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Topology.
-func (in *Topology) DeepCopy() *Topology {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneEndpointProviderSpec.
+func (in *ControlPlaneEndpointProviderSpec) DeepCopy() *ControlPlaneEndpointProviderSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Topology)
+	out := new(ControlPlaneEndpointProviderSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereCluster) DeepCopyInto(out *VSphereCluster) {
+func (in *DataDiskControllerSpec) DeepCopyInto(out *DataDiskControllerSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	if in.ExtraConfig != nil {
+		in, out := &in.ExtraConfig, &out.ExtraConfig
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereCluster.
-func (in *VSphereCluster) DeepCopy() *VSphereCluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataDiskControllerSpec.
+func (in *DataDiskControllerSpec) DeepCopy() *DataDiskControllerSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereCluster)
+	out := new(DataDiskControllerSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereCluster) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterIdentity) DeepCopyInto(out *VSphereClusterIdentity) {
+func (in *DataDiskSpec) DeepCopyInto(out *DataDiskSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	if in.Controller != nil {
+		in, out := &in.Controller, &out.Controller
+		*out = new(DataDiskControllerSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentity.
-func (in *VSphereClusterIdentity) DeepCopy() *VSphereClusterIdentity {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataDiskSpec.
+func (in *DataDiskSpec) DeepCopy() *DataDiskSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterIdentity)
+	out := new(DataDiskSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereClusterIdentity) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterIdentityList) DeepCopyInto(out *VSphereClusterIdentityList) {
+func (in *EncryptionSpec) DeepCopyInto(out *EncryptionSpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]VSphereClusterIdentity, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentityList.
-func (in *VSphereClusterIdentityList) DeepCopy() *VSphereClusterIdentityList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EncryptionSpec.
+func (in *EncryptionSpec) DeepCopy() *EncryptionSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterIdentityList)
+	out := new(EncryptionSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereClusterIdentityList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExistingVMSpec) DeepCopyInto(out *ExistingVMSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExistingVMSpec.
+func (in *ExistingVMSpec) DeepCopy() *ExistingVMSpec {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(ExistingVMSpec)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterIdentitySpec) DeepCopyInto(out *VSphereClusterIdentitySpec) {
+func (in *FailureDomain) DeepCopyInto(out *FailureDomain) {
 	*out = *in
-	if in.AllowedNamespaces != nil {
-		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
-		*out = new(AllowedNamespaces)
-		(*in).DeepCopyInto(*out)
+	if in.AutoConfigure != nil {
+		in, out := &in.AutoConfigure, &out.AutoConfigure
+		*out = new(bool)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentitySpec.
-func (in *VSphereClusterIdentitySpec) DeepCopy() *VSphereClusterIdentitySpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailureDomain.
+func (in *FailureDomain) DeepCopy() *FailureDomain {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterIdentitySpec)
+	out := new(FailureDomain)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterIdentityStatus) DeepCopyInto(out *VSphereClusterIdentityStatus) {
+func (in *FailureDomainHosts) DeepCopyInto(out *FailureDomainHosts) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make(apiv1beta1.Conditions, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentityStatus.
-func (in *VSphereClusterIdentityStatus) DeepCopy() *VSphereClusterIdentityStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailureDomainHosts.
+func (in *FailureDomainHosts) DeepCopy() *FailureDomainHosts {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterIdentityStatus)
+	out := new(FailureDomainHosts)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterList) DeepCopyInto(out *VSphereClusterList) {
+func (in *HostAffinitySpec) DeepCopyInto(out *HostAffinitySpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]VSphereCluster, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterList.
-func (in *VSphereClusterList) DeepCopy() *VSphereClusterList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostAffinitySpec.
+func (in *HostAffinitySpec) DeepCopy() *HostAffinitySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterList)
+	out := new(HostAffinitySpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereClusterList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageChecksum) DeepCopyInto(out *ImageChecksum) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageChecksum.
+func (in *ImageChecksum) DeepCopy() *ImageChecksum {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageChecksum)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImagePublicKeyReference) DeepCopyInto(out *ImagePublicKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePublicKeyReference.
+func (in *ImagePublicKeyReference) DeepCopy() *ImagePublicKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ImagePublicKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSignature) DeepCopyInto(out *ImageSignature) {
+	*out = *in
+	out.PublicKeyRef = in.PublicKeyRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSignature.
+func (in *ImageSignature) DeepCopy() *ImageSignature {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSignature)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageVerification) DeepCopyInto(out *ImageVerification) {
+	*out = *in
+	if in.Checksum != nil {
+		in, out := &in.Checksum, &out.Checksum
+		*out = new(ImageChecksum)
+		**out = **in
+	}
+	if in.Signature != nil {
+		in, out := &in.Signature, &out.Signature
+		*out = new(ImageSignature)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageVerification.
+func (in *ImageVerification) DeepCopy() *ImageVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeVIPEndpointSpec) DeepCopyInto(out *KubeVIPEndpointSpec) {
+	*out = *in
+	in.AddressFromPool.DeepCopyInto(&out.AddressFromPool)
+	if in.Failover != nil {
+		in, out := &in.Failover, &out.Failover
+		*out = new(KubeVIPFailoverSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeVIPEndpointSpec.
+func (in *KubeVIPEndpointSpec) DeepCopy() *KubeVIPEndpointSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeVIPEndpointSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeVIPFailoverSpec) DeepCopyInto(out *KubeVIPFailoverSpec) {
+	*out = *in
+	if in.Period != nil {
+		in, out := &in.Period, &out.Period
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeVIPFailoverSpec.
+func (in *KubeVIPFailoverSpec) DeepCopy() *KubeVIPFailoverSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeVIPFailoverSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineRollbackCondition) DeepCopyInto(out *MachineRollbackCondition) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineRollbackCondition.
+func (in *MachineRollbackCondition) DeepCopy() *MachineRollbackCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineRollbackCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineRollbackReport) DeepCopyInto(out *MachineRollbackReport) {
+	*out = *in
+	if in.VMAddresses != nil {
+		in, out := &in.VMAddresses, &out.VMAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.VMTask != nil {
+		in, out := &in.VMTask, &out.VMTask
+		*out = new(VirtualMachineTaskStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]MachineRollbackCondition, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineRollbackReport.
+func (in *MachineRollbackReport) DeepCopy() *MachineRollbackReport {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineRollbackReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineTopologyStatus) DeepCopyInto(out *MachineTopologyStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineTopologyStatus.
+func (in *MachineTopologyStatus) DeepCopy() *MachineTopologyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineTopologyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamingStrategy) DeepCopyInto(out *NamingStrategy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamingStrategy.
+func (in *NamingStrategy) DeepCopy() *NamingStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(NamingStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Network) DeepCopyInto(out *Network) {
+	*out = *in
+	if in.DHCP4 != nil {
+		in, out := &in.DHCP4, &out.DHCP4
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DHCP6 != nil {
+		in, out := &in.DHCP6, &out.DHCP6
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
+func (in *Network) DeepCopy() *Network {
+	if in == nil {
+		return nil
+	}
+	out := new(Network)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkDeviceSpec) DeepCopyInto(out *NetworkDeviceSpec) {
+	*out = *in
+	if in.IPAddrs != nil {
+		in, out := &in.IPAddrs, &out.IPAddrs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AddressesFromPools != nil {
+		in, out := &in.AddressesFromPools, &out.AddressesFromPools
+		*out = make([]corev1.TypedLocalObjectReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MTU != nil {
+		in, out := &in.MTU, &out.MTU
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Nameservers != nil {
+		in, out := &in.Nameservers, &out.Nameservers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]NetworkRouteSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.SearchDomains != nil {
+		in, out := &in.SearchDomains, &out.SearchDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkDeviceSpec.
+func (in *NetworkDeviceSpec) DeepCopy() *NetworkDeviceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkDeviceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkRouteSpec) DeepCopyInto(out *NetworkRouteSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkRouteSpec.
+func (in *NetworkRouteSpec) DeepCopy() *NetworkRouteSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkRouteSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
+	*out = *in
+	if in.Devices != nil {
+		in, out := &in.Devices, &out.Devices
+		*out = make([]NetworkDeviceSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]NetworkRouteSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.NTPServers != nil {
+		in, out := &in.NTPServers, &out.NTPServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(ProxySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PublishedAddresses != nil {
+		in, out := &in.PublishedAddresses, &out.PublishedAddresses
+		*out = new(PublishedAddressesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
+func (in *NetworkSpec) DeepCopy() *NetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkStatus) DeepCopyInto(out *NetworkStatus) {
+	*out = *in
+	if in.IPAddrs != nil {
+		in, out := &in.IPAddrs, &out.IPAddrs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkStatus.
+func (in *NetworkStatus) DeepCopy() *NetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OvercommitPolicy) DeepCopyInto(out *OvercommitPolicy) {
+	*out = *in
+	if in.CPURatioPercent != nil {
+		in, out := &in.CPURatioPercent, &out.CPURatioPercent
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MemoryRatioPercent != nil {
+		in, out := &in.MemoryRatioPercent, &out.MemoryRatioPercent
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OvercommitPolicy.
+func (in *OvercommitPolicy) DeepCopy() *OvercommitPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(OvercommitPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PCIDeviceSpec) DeepCopyInto(out *PCIDeviceSpec) {
+	*out = *in
+	if in.DeviceID != nil {
+		in, out := &in.DeviceID, &out.DeviceID
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VendorID != nil {
+		in, out := &in.VendorID, &out.VendorID
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PCIDeviceSpec.
+func (in *PCIDeviceSpec) DeepCopy() *PCIDeviceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PCIDeviceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlacementConstraint) DeepCopyInto(out *PlacementConstraint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementConstraint.
+func (in *PlacementConstraint) DeepCopy() *PlacementConstraint {
+	if in == nil {
+		return nil
+	}
+	out := new(PlacementConstraint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreflightCheck) DeepCopyInto(out *PreflightCheck) {
+	*out = *in
+	if in.MissingPrivileges != nil {
+		in, out := &in.MissingPrivileges, &out.MissingPrivileges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreflightCheck.
+func (in *PreflightCheck) DeepCopy() *PreflightCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(PreflightCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PreflightStatus) DeepCopyInto(out *PreflightStatus) {
+	*out = *in
+	if in.Checks != nil {
+		in, out := &in.Checks, &out.Checks
+		*out = make([]PreflightCheck, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.CheckedTime.DeepCopyInto(&out.CheckedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreflightStatus.
+func (in *PreflightStatus) DeepCopy() *PreflightStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PreflightStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningTimeouts) DeepCopyInto(out *ProvisioningTimeouts) {
+	*out = *in
+	if in.Clone != nil {
+		in, out := &in.Clone, &out.Clone
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ToolsStart != nil {
+		in, out := &in.ToolsStart, &out.ToolsStart
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.IPAcquisition != nil {
+		in, out := &in.IPAcquisition, &out.IPAcquisition
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.BootstrapJoin != nil {
+		in, out := &in.BootstrapJoin, &out.BootstrapJoin
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningTimeouts.
+func (in *ProvisioningTimeouts) DeepCopy() *ProvisioningTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxySpec) DeepCopyInto(out *ProxySpec) {
+	*out = *in
+	if in.NoProxy != nil {
+		in, out := &in.NoProxy, &out.NoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxySpec.
+func (in *ProxySpec) DeepCopy() *ProxySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PublishedAddressesSpec) DeepCopyInto(out *PublishedAddressesSpec) {
+	*out = *in
+	if in.CIDRs != nil {
+		in, out := &in.CIDRs, &out.CIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PublishedAddressesSpec.
+func (in *PublishedAddressesSpec) DeepCopy() *PublishedAddressesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PublishedAddressesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceAllocation) DeepCopyInto(out *ResourceAllocation) {
+	*out = *in
+	if in.Reservation != nil {
+		in, out := &in.Reservation, &out.Reservation
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ExpandableReservation != nil {
+		in, out := &in.ExpandableReservation, &out.ExpandableReservation
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceAllocation.
+func (in *ResourceAllocation) DeepCopy() *ResourceAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHUser) DeepCopyInto(out *SSHUser) {
+	*out = *in
+	if in.AuthorizedKeys != nil {
+		in, out := &in.AuthorizedKeys, &out.AuthorizedKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHUser.
+func (in *SSHUser) DeepCopy() *SSHUser {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHUser)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateRolloutProbeJob) DeepCopyInto(out *TemplateRolloutProbeJob) {
+	*out = *in
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateRolloutProbeJob.
+func (in *TemplateRolloutProbeJob) DeepCopy() *TemplateRolloutProbeJob {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateRolloutProbeJob)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeSyncSpec) DeepCopyInto(out *TimeSyncSpec) {
+	*out = *in
+	if in.MaxClockSkew != nil {
+		in, out := &in.MaxClockSkew, &out.MaxClockSkew
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeSyncSpec.
+func (in *TimeSyncSpec) DeepCopy() *TimeSyncSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeSyncSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Topology) DeepCopyInto(out *Topology) {
+	*out = *in
+	if in.ComputeCluster != nil {
+		in, out := &in.ComputeCluster, &out.ComputeCluster
+		*out = new(string)
+		**out = **in
+	}
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = new(FailureDomainHosts)
+		**out = **in
+	}
+	if in.Networks != nil {
+		in, out := &in.Networks, &out.Networks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Topology.
+func (in *Topology) DeepCopy() *Topology {
+	if in == nil {
+		return nil
+	}
+	out := new(Topology)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereCluster) DeepCopyInto(out *VSphereCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereCluster.
+func (in *VSphereCluster) DeepCopy() *VSphereCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterIdentity) DeepCopyInto(out *VSphereClusterIdentity) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentity.
+func (in *VSphereClusterIdentity) DeepCopy() *VSphereClusterIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereClusterIdentity) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterIdentityList) DeepCopyInto(out *VSphereClusterIdentityList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereClusterIdentity, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentityList.
+func (in *VSphereClusterIdentityList) DeepCopy() *VSphereClusterIdentityList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterIdentityList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereClusterIdentityList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterIdentitySpec) DeepCopyInto(out *VSphereClusterIdentitySpec) {
+	*out = *in
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = new(AllowedNamespaces)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentitySpec.
+func (in *VSphereClusterIdentitySpec) DeepCopy() *VSphereClusterIdentitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterIdentitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterIdentityStatus) DeepCopyInto(out *VSphereClusterIdentityStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereClusterIdentityV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentityStatus.
+func (in *VSphereClusterIdentityStatus) DeepCopy() *VSphereClusterIdentityStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterIdentityStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterIdentityV1Beta2Status) DeepCopyInto(out *VSphereClusterIdentityV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterIdentityV1Beta2Status.
+func (in *VSphereClusterIdentityV1Beta2Status) DeepCopy() *VSphereClusterIdentityV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterIdentityV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterList) DeepCopyInto(out *VSphereClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterList.
+func (in *VSphereClusterList) DeepCopy() *VSphereClusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterSpec) DeepCopyInto(out *VSphereClusterSpec) {
+	*out = *in
+	if in.CABundleRef != nil {
+		in, out := &in.CABundleRef, &out.CABundleRef
+		*out = new(CABundleReference)
+		**out = **in
+	}
+	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.IdentityRef != nil {
+		in, out := &in.IdentityRef, &out.IdentityRef
+		*out = new(VSphereIdentityReference)
+		**out = **in
+	}
+	if in.ClusterModules != nil {
+		in, out := &in.ClusterModules, &out.ClusterModules
+		*out = make([]ClusterModule, len(*in))
+		copy(*out, *in)
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(ClusterPlacementSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ControlPlaneEndpointProvider != nil {
+		in, out := &in.ControlPlaneEndpointProvider, &out.ControlPlaneEndpointProvider
+		*out = new(ControlPlaneEndpointProviderSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NetworkSettings != nil {
+		in, out := &in.NetworkSettings, &out.NetworkSettings
+		*out = new(ClusterNetworkSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CreationRollback != nil {
+		in, out := &in.CreationRollback, &out.CreationRollback
+		*out = new(ClusterCreationRollbackSpec)
+		**out = **in
+	}
+	if in.OvercommitPolicy != nil {
+		in, out := &in.OvercommitPolicy, &out.OvercommitPolicy
+		*out = new(OvercommitPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Naming != nil {
+		in, out := &in.Naming, &out.Naming
+		*out = new(NamingStrategy)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterSpec.
+func (in *VSphereClusterSpec) DeepCopy() *VSphereClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterStatus) DeepCopyInto(out *VSphereClusterStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereClusterV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make(apiv1beta1.FailureDomains, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(ClusterPlacementStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ControlPlaneEndpoint != nil {
+		in, out := &in.ControlPlaneEndpoint, &out.ControlPlaneEndpoint
+		*out = new(APIEndpoint)
+		**out = **in
+	}
+	if in.ControlPlaneEndpointFailover != nil {
+		in, out := &in.ControlPlaneEndpointFailover, &out.ControlPlaneEndpointFailover
+		*out = new(ControlPlaneEndpointFailoverStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.ClusterStatusError)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
+	if in.CreationRollback != nil {
+		in, out := &in.CreationRollback, &out.CreationRollback
+		*out = new(ClusterCreationRollbackStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Preflight != nil {
+		in, out := &in.Preflight, &out.Preflight
+		*out = new(PreflightStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterStatus.
+func (in *VSphereClusterStatus) DeepCopy() *VSphereClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterTemplate) DeepCopyInto(out *VSphereClusterTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterTemplate.
+func (in *VSphereClusterTemplate) DeepCopy() *VSphereClusterTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereClusterTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterTemplateList) DeepCopyInto(out *VSphereClusterTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereClusterTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterTemplateList.
+func (in *VSphereClusterTemplateList) DeepCopy() *VSphereClusterTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereClusterTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterTemplateResource) DeepCopyInto(out *VSphereClusterTemplateResource) {
+	*out = *in
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterTemplateResource.
+func (in *VSphereClusterTemplateResource) DeepCopy() *VSphereClusterTemplateResource {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterTemplateResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterTemplateSpec) DeepCopyInto(out *VSphereClusterTemplateSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterTemplateSpec.
+func (in *VSphereClusterTemplateSpec) DeepCopy() *VSphereClusterTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterV1Beta2Status) DeepCopyInto(out *VSphereClusterV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterV1Beta2Status.
+func (in *VSphereClusterV1Beta2Status) DeepCopy() *VSphereClusterV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereDeploymentZone) DeepCopyInto(out *VSphereDeploymentZone) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZone.
+func (in *VSphereDeploymentZone) DeepCopy() *VSphereDeploymentZone {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereDeploymentZone)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereDeploymentZone) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereDeploymentZoneList) DeepCopyInto(out *VSphereDeploymentZoneList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereDeploymentZone, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZoneList.
+func (in *VSphereDeploymentZoneList) DeepCopy() *VSphereDeploymentZoneList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereDeploymentZoneList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereDeploymentZoneList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereDeploymentZoneSpec) DeepCopyInto(out *VSphereDeploymentZoneSpec) {
+	*out = *in
+	if in.ControlPlane != nil {
+		in, out := &in.ControlPlane, &out.ControlPlane
+		*out = new(bool)
+		**out = **in
+	}
+	out.PlacementConstraint = in.PlacementConstraint
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZoneSpec.
+func (in *VSphereDeploymentZoneSpec) DeepCopy() *VSphereDeploymentZoneSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereDeploymentZoneSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereDeploymentZoneStatus) DeepCopyInto(out *VSphereDeploymentZoneStatus) {
+	*out = *in
+	if in.Ready != nil {
+		in, out := &in.Ready, &out.Ready
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereDeploymentZoneV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZoneStatus.
+func (in *VSphereDeploymentZoneStatus) DeepCopy() *VSphereDeploymentZoneStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereDeploymentZoneStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereDeploymentZoneV1Beta2Status) DeepCopyInto(out *VSphereDeploymentZoneV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZoneV1Beta2Status.
+func (in *VSphereDeploymentZoneV1Beta2Status) DeepCopy() *VSphereDeploymentZoneV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereDeploymentZoneV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereFailureDomain) DeepCopyInto(out *VSphereFailureDomain) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereFailureDomain.
+func (in *VSphereFailureDomain) DeepCopy() *VSphereFailureDomain {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereFailureDomain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereFailureDomain) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereFailureDomainList) DeepCopyInto(out *VSphereFailureDomainList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereFailureDomain, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereFailureDomainList.
+func (in *VSphereFailureDomainList) DeepCopy() *VSphereFailureDomainList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereFailureDomainList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereFailureDomainList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereFailureDomainSpec) DeepCopyInto(out *VSphereFailureDomainSpec) {
+	*out = *in
+	in.Region.DeepCopyInto(&out.Region)
+	in.Zone.DeepCopyInto(&out.Zone)
+	in.Topology.DeepCopyInto(&out.Topology)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereFailureDomainSpec.
+func (in *VSphereFailureDomainSpec) DeepCopy() *VSphereFailureDomainSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereFailureDomainSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereIdentityReference) DeepCopyInto(out *VSphereIdentityReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereIdentityReference.
+func (in *VSphereIdentityReference) DeepCopy() *VSphereIdentityReference {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereIdentityReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachine) DeepCopyInto(out *VSphereMachine) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachine.
+func (in *VSphereMachine) DeepCopy() *VSphereMachine {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachine)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachine) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineImage) DeepCopyInto(out *VSphereMachineImage) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineImage.
+func (in *VSphereMachineImage) DeepCopy() *VSphereMachineImage {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachineImage) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineImageList) DeepCopyInto(out *VSphereMachineImageList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereMachineImage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineImageList.
+func (in *VSphereMachineImageList) DeepCopy() *VSphereMachineImageList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineImageList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachineImageList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineImageSpec) DeepCopyInto(out *VSphereMachineImageSpec) {
+	*out = *in
+	if in.CABundleRef != nil {
+		in, out := &in.CABundleRef, &out.CABundleRef
+		*out = new(CABundleReference)
+		**out = **in
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(ImageVerification)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineImageSpec.
+func (in *VSphereMachineImageSpec) DeepCopy() *VSphereMachineImageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineImageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineImageStatus) DeepCopyInto(out *VSphereMachineImageStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereMachineImageV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineImageStatus.
+func (in *VSphereMachineImageStatus) DeepCopy() *VSphereMachineImageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineImageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineImageV1Beta2Status) DeepCopyInto(out *VSphereMachineImageV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineImageV1Beta2Status.
+func (in *VSphereMachineImageV1Beta2Status) DeepCopy() *VSphereMachineImageV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineImageV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineList) DeepCopyInto(out *VSphereMachineList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereMachine, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineList.
+func (in *VSphereMachineList) DeepCopy() *VSphereMachineList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachineList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachinePool) DeepCopyInto(out *VSphereMachinePool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachinePool.
+func (in *VSphereMachinePool) DeepCopy() *VSphereMachinePool {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachinePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachinePool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachinePoolList) DeepCopyInto(out *VSphereMachinePoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereMachinePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachinePoolList.
+func (in *VSphereMachinePoolList) DeepCopy() *VSphereMachinePoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachinePoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachinePoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachinePoolRollingUpdate) DeepCopyInto(out *VSphereMachinePoolRollingUpdate) {
+	*out = *in
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachinePoolRollingUpdate.
+func (in *VSphereMachinePoolRollingUpdate) DeepCopy() *VSphereMachinePoolRollingUpdate {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachinePoolRollingUpdate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachinePoolSpec) DeepCopyInto(out *VSphereMachinePoolSpec) {
+	*out = *in
+	if in.ProviderIDList != nil {
+		in, out := &in.ProviderIDList, &out.ProviderIDList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+	in.RollingUpdate.DeepCopyInto(&out.RollingUpdate)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachinePoolSpec.
+func (in *VSphereMachinePoolSpec) DeepCopy() *VSphereMachinePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachinePoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachinePoolStatus) DeepCopyInto(out *VSphereMachinePoolStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereMachinePoolV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachinePoolStatus.
+func (in *VSphereMachinePoolStatus) DeepCopy() *VSphereMachinePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachinePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachinePoolV1Beta2Status) DeepCopyInto(out *VSphereMachinePoolV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachinePoolV1Beta2Status.
+func (in *VSphereMachinePoolV1Beta2Status) DeepCopy() *VSphereMachinePoolV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachinePoolV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineSpec) DeepCopyInto(out *VSphereMachineSpec) {
+	*out = *in
+	in.VirtualMachineCloneSpec.DeepCopyInto(&out.VirtualMachineCloneSpec)
+	if in.ProviderID != nil {
+		in, out := &in.ProviderID, &out.ProviderID
+		*out = new(string)
+		**out = **in
+	}
+	if in.FailureDomain != nil {
+		in, out := &in.FailureDomain, &out.FailureDomain
+		*out = new(string)
+		**out = **in
+	}
+	if in.ImageRef != nil {
+		in, out := &in.ImageRef, &out.ImageRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineSpec.
+func (in *VSphereMachineSpec) DeepCopy() *VSphereMachineSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineStatus) DeepCopyInto(out *VSphereMachineStatus) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]apiv1beta1.MachineAddress, len(*in))
+		copy(*out, *in)
+	}
+	if in.Network != nil {
+		in, out := &in.Network, &out.Network
+		*out = make([]NetworkStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Topology != nil {
+		in, out := &in.Topology, &out.Topology
+		*out = new(MachineTopologyStatus)
+		**out = **in
+	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.MachineStatusError)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereMachineV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineStatus.
+func (in *VSphereMachineStatus) DeepCopy() *VSphereMachineStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineTemplate) DeepCopyInto(out *VSphereMachineTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplate.
+func (in *VSphereMachineTemplate) DeepCopy() *VSphereMachineTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachineTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
 		return c
 	}
 	return nil
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterSpec) DeepCopyInto(out *VSphereClusterSpec) {
+func (in *VSphereMachineTemplateList) DeepCopyInto(out *VSphereMachineTemplateList) {
 	*out = *in
-	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
-	if in.IdentityRef != nil {
-		in, out := &in.IdentityRef, &out.IdentityRef
-		*out = new(VSphereIdentityReference)
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereMachineTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateList.
+func (in *VSphereMachineTemplateList) DeepCopy() *VSphereMachineTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachineTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineTemplateResource) DeepCopyInto(out *VSphereMachineTemplateResource) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateResource.
+func (in *VSphereMachineTemplateResource) DeepCopy() *VSphereMachineTemplateResource {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineTemplateResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineTemplateRollout) DeepCopyInto(out *VSphereMachineTemplateRollout) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateRollout.
+func (in *VSphereMachineTemplateRollout) DeepCopy() *VSphereMachineTemplateRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineTemplateRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachineTemplateRollout) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineTemplateRolloutList) DeepCopyInto(out *VSphereMachineTemplateRolloutList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereMachineTemplateRollout, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateRolloutList.
+func (in *VSphereMachineTemplateRolloutList) DeepCopy() *VSphereMachineTemplateRolloutList {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineTemplateRolloutList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereMachineTemplateRolloutList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineTemplateRolloutSpec) DeepCopyInto(out *VSphereMachineTemplateRolloutSpec) {
+	*out = *in
+	if in.ProbeJobs != nil {
+		in, out := &in.ProbeJobs, &out.ProbeJobs
+		*out = make([]TemplateRolloutProbeJob, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
 		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterSpec.
-func (in *VSphereClusterSpec) DeepCopy() *VSphereClusterSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateRolloutSpec.
+func (in *VSphereMachineTemplateRolloutSpec) DeepCopy() *VSphereMachineTemplateRolloutSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterSpec)
+	out := new(VSphereMachineTemplateRolloutSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterStatus) DeepCopyInto(out *VSphereClusterStatus) {
+func (in *VSphereMachineTemplateRolloutStatus) DeepCopyInto(out *VSphereMachineTemplateRolloutStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -477,45 +2351,126 @@ func (in *VSphereClusterStatus) DeepCopyInto(out *VSphereClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.FailureDomains != nil {
-		in, out := &in.FailureDomains, &out.FailureDomains
-		*out = make(apiv1beta1.FailureDomains, len(*in))
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereMachineTemplateRolloutV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateRolloutStatus.
+func (in *VSphereMachineTemplateRolloutStatus) DeepCopy() *VSphereMachineTemplateRolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineTemplateRolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineTemplateRolloutV1Beta2Status) DeepCopyInto(out *VSphereMachineTemplateRolloutV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateRolloutV1Beta2Status.
+func (in *VSphereMachineTemplateRolloutV1Beta2Status) DeepCopy() *VSphereMachineTemplateRolloutV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineTemplateRolloutV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineTemplateSpec) DeepCopyInto(out *VSphereMachineTemplateSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateSpec.
+func (in *VSphereMachineTemplateSpec) DeepCopy() *VSphereMachineTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineTemplateStatus) DeepCopyInto(out *VSphereMachineTemplateStatus) {
+	*out = *in
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = make(corev1.ResourceList, len(*in))
 		for key, val := range *in {
-			(*out)[key] = *val.DeepCopy()
+			(*out)[key] = val.DeepCopy()
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterStatus.
-func (in *VSphereClusterStatus) DeepCopy() *VSphereClusterStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateStatus.
+func (in *VSphereMachineTemplateStatus) DeepCopy() *VSphereMachineTemplateStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterStatus)
+	out := new(VSphereMachineTemplateStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterTemplate) DeepCopyInto(out *VSphereClusterTemplate) {
+func (in *VSphereMachineV1Beta2Status) DeepCopyInto(out *VSphereMachineV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineV1Beta2Status.
+func (in *VSphereMachineV1Beta2Status) DeepCopy() *VSphereMachineV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereResourceQuota) DeepCopyInto(out *VSphereResourceQuota) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterTemplate.
-func (in *VSphereClusterTemplate) DeepCopy() *VSphereClusterTemplate {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereResourceQuota.
+func (in *VSphereResourceQuota) DeepCopy() *VSphereResourceQuota {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterTemplate)
+	out := new(VSphereResourceQuota)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereClusterTemplate) DeepCopyObject() runtime.Object {
+func (in *VSphereResourceQuota) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -523,90 +2478,170 @@ func (in *VSphereClusterTemplate) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterTemplateList) DeepCopyInto(out *VSphereClusterTemplateList) {
+func (in *VSphereResourceQuotaList) DeepCopyInto(out *VSphereResourceQuotaList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]VSphereClusterTemplate, len(*in))
+		*out = make([]VSphereResourceQuota, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterTemplateList.
-func (in *VSphereClusterTemplateList) DeepCopy() *VSphereClusterTemplateList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereResourceQuotaList.
+func (in *VSphereResourceQuotaList) DeepCopy() *VSphereResourceQuotaList {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterTemplateList)
+	out := new(VSphereResourceQuotaList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereClusterTemplateList) DeepCopyObject() runtime.Object {
+func (in *VSphereResourceQuotaList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
-	return nil
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereResourceQuotaSpec) DeepCopyInto(out *VSphereResourceQuotaSpec) {
+	*out = *in
+	in.Hard.DeepCopyInto(&out.Hard)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereResourceQuotaSpec.
+func (in *VSphereResourceQuotaSpec) DeepCopy() *VSphereResourceQuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereResourceQuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereResourceQuotaStatus) DeepCopyInto(out *VSphereResourceQuotaStatus) {
+	*out = *in
+	in.Hard.DeepCopyInto(&out.Hard)
+	in.Used.DeepCopyInto(&out.Used)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereResourceQuotaV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereResourceQuotaStatus.
+func (in *VSphereResourceQuotaStatus) DeepCopy() *VSphereResourceQuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereResourceQuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereResourceQuotaV1Beta2Status) DeepCopyInto(out *VSphereResourceQuotaV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereResourceQuotaV1Beta2Status.
+func (in *VSphereResourceQuotaV1Beta2Status) DeepCopy() *VSphereResourceQuotaV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereResourceQuotaV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterTemplateResource) DeepCopyInto(out *VSphereClusterTemplateResource) {
+func (in *VSphereResources) DeepCopyInto(out *VSphereResources) {
 	*out = *in
-	in.Spec.DeepCopyInto(&out.Spec)
+	if in.NumCPUs != nil {
+		in, out := &in.NumCPUs, &out.NumCPUs
+		*out = new(int64)
+		**out = **in
+	}
+	if in.MemoryMiB != nil {
+		in, out := &in.MemoryMiB, &out.MemoryMiB
+		*out = new(int64)
+		**out = **in
+	}
+	if in.DiskGiB != nil {
+		in, out := &in.DiskGiB, &out.DiskGiB
+		*out = new(int64)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterTemplateResource.
-func (in *VSphereClusterTemplateResource) DeepCopy() *VSphereClusterTemplateResource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereResources.
+func (in *VSphereResources) DeepCopy() *VSphereResources {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterTemplateResource)
+	out := new(VSphereResources)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereClusterTemplateSpec) DeepCopyInto(out *VSphereClusterTemplateSpec) {
+func (in *VSphereTemplateReference) DeepCopyInto(out *VSphereTemplateReference) {
 	*out = *in
-	in.Template.DeepCopyInto(&out.Template)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterTemplateSpec.
-func (in *VSphereClusterTemplateSpec) DeepCopy() *VSphereClusterTemplateSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereTemplateReference.
+func (in *VSphereTemplateReference) DeepCopy() *VSphereTemplateReference {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereClusterTemplateSpec)
+	out := new(VSphereTemplateReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereDeploymentZone) DeepCopyInto(out *VSphereDeploymentZone) {
+func (in *VSphereTemplateUsage) DeepCopyInto(out *VSphereTemplateUsage) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
+	out.Spec = in.Spec
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZone.
-func (in *VSphereDeploymentZone) DeepCopy() *VSphereDeploymentZone {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereTemplateUsage.
+func (in *VSphereTemplateUsage) DeepCopy() *VSphereTemplateUsage {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereDeploymentZone)
+	out := new(VSphereTemplateUsage)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereDeploymentZone) DeepCopyObject() runtime.Object {
+func (in *VSphereTemplateUsage) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -614,31 +2649,31 @@ func (in *VSphereDeploymentZone) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereDeploymentZoneList) DeepCopyInto(out *VSphereDeploymentZoneList) {
+func (in *VSphereTemplateUsageList) DeepCopyInto(out *VSphereTemplateUsageList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]VSphereDeploymentZone, len(*in))
+		*out = make([]VSphereTemplateUsage, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZoneList.
-func (in *VSphereDeploymentZoneList) DeepCopy() *VSphereDeploymentZoneList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereTemplateUsageList.
+func (in *VSphereTemplateUsageList) DeepCopy() *VSphereTemplateUsageList {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereDeploymentZoneList)
+	out := new(VSphereTemplateUsageList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereDeploymentZoneList) DeepCopyObject() runtime.Object {
+func (in *VSphereTemplateUsageList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -646,73 +2681,61 @@ func (in *VSphereDeploymentZoneList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereDeploymentZoneSpec) DeepCopyInto(out *VSphereDeploymentZoneSpec) {
+func (in *VSphereTemplateUsageSpec) DeepCopyInto(out *VSphereTemplateUsageSpec) {
 	*out = *in
-	if in.ControlPlane != nil {
-		in, out := &in.ControlPlane, &out.ControlPlane
-		*out = new(bool)
-		**out = **in
-	}
-	out.PlacementConstraint = in.PlacementConstraint
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZoneSpec.
-func (in *VSphereDeploymentZoneSpec) DeepCopy() *VSphereDeploymentZoneSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereTemplateUsageSpec.
+func (in *VSphereTemplateUsageSpec) DeepCopy() *VSphereTemplateUsageSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereDeploymentZoneSpec)
+	out := new(VSphereTemplateUsageSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereDeploymentZoneStatus) DeepCopyInto(out *VSphereDeploymentZoneStatus) {
+func (in *VSphereTemplateUsageStatus) DeepCopyInto(out *VSphereTemplateUsageStatus) {
 	*out = *in
-	if in.Ready != nil {
-		in, out := &in.Ready, &out.Ready
-		*out = new(bool)
-		**out = **in
-	}
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make(apiv1beta1.Conditions, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.References != nil {
+		in, out := &in.References, &out.References
+		*out = make([]VSphereTemplateReference, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereDeploymentZoneStatus.
-func (in *VSphereDeploymentZoneStatus) DeepCopy() *VSphereDeploymentZoneStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereTemplateUsageStatus.
+func (in *VSphereTemplateUsageStatus) DeepCopy() *VSphereTemplateUsageStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereDeploymentZoneStatus)
+	out := new(VSphereTemplateUsageStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereFailureDomain) DeepCopyInto(out *VSphereFailureDomain) {
+func (in *VSphereVM) DeepCopyInto(out *VSphereVM) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereFailureDomain.
-func (in *VSphereFailureDomain) DeepCopy() *VSphereFailureDomain {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVM.
+func (in *VSphereVM) DeepCopy() *VSphereVM {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereFailureDomain)
+	out := new(VSphereVM)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereFailureDomain) DeepCopyObject() runtime.Object {
+func (in *VSphereVM) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -720,31 +2743,26 @@ func (in *VSphereFailureDomain) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereFailureDomainList) DeepCopyInto(out *VSphereFailureDomainList) {
+func (in *VSphereVMInventory) DeepCopyInto(out *VSphereVMInventory) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]VSphereFailureDomain, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereFailureDomainList.
-func (in *VSphereFailureDomainList) DeepCopy() *VSphereFailureDomainList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMInventory.
+func (in *VSphereVMInventory) DeepCopy() *VSphereVMInventory {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereFailureDomainList)
+	out := new(VSphereVMInventory)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereFailureDomainList) DeepCopyObject() runtime.Object {
+func (in *VSphereVMInventory) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -752,91 +2770,129 @@ func (in *VSphereFailureDomainList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereFailureDomainSpec) DeepCopyInto(out *VSphereFailureDomainSpec) {
+func (in *VSphereVMInventoryEntry) DeepCopyInto(out *VSphereVMInventoryEntry) {
 	*out = *in
-	in.Region.DeepCopyInto(&out.Region)
-	in.Zone.DeepCopyInto(&out.Zone)
-	in.Topology.DeepCopyInto(&out.Topology)
+	if in.Datastores != nil {
+		in, out := &in.Datastores, &out.Datastores
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPAddrs != nil {
+		in, out := &in.IPAddrs, &out.IPAddrs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereFailureDomainSpec.
-func (in *VSphereFailureDomainSpec) DeepCopy() *VSphereFailureDomainSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMInventoryEntry.
+func (in *VSphereVMInventoryEntry) DeepCopy() *VSphereVMInventoryEntry {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereFailureDomainSpec)
+	out := new(VSphereVMInventoryEntry)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereIdentityReference) DeepCopyInto(out *VSphereIdentityReference) {
+func (in *VSphereVMInventoryList) DeepCopyInto(out *VSphereVMInventoryList) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VSphereVMInventory, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereIdentityReference.
-func (in *VSphereIdentityReference) DeepCopy() *VSphereIdentityReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMInventoryList.
+func (in *VSphereVMInventoryList) DeepCopy() *VSphereVMInventoryList {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereIdentityReference)
+	out := new(VSphereVMInventoryList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VSphereVMInventoryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereMachine) DeepCopyInto(out *VSphereMachine) {
+func (in *VSphereVMInventorySpec) DeepCopyInto(out *VSphereVMInventorySpec) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachine.
-func (in *VSphereMachine) DeepCopy() *VSphereMachine {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMInventorySpec.
+func (in *VSphereVMInventorySpec) DeepCopy() *VSphereVMInventorySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereMachine)
+	out := new(VSphereVMInventorySpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereMachine) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereVMInventoryStatus) DeepCopyInto(out *VSphereVMInventoryStatus) {
+	*out = *in
+	if in.LastUpdated != nil {
+		in, out := &in.LastUpdated, &out.LastUpdated
+		*out = (*in).DeepCopy()
 	}
-	return nil
+	if in.VirtualMachines != nil {
+		in, out := &in.VirtualMachines, &out.VirtualMachines
+		*out = make([]VSphereVMInventoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMInventoryStatus.
+func (in *VSphereVMInventoryStatus) DeepCopy() *VSphereVMInventoryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereVMInventoryStatus)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereMachineList) DeepCopyInto(out *VSphereMachineList) {
+func (in *VSphereVMList) DeepCopyInto(out *VSphereVMList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]VSphereMachine, len(*in))
+		*out = make([]VSphereVM, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineList.
-func (in *VSphereMachineList) DeepCopy() *VSphereMachineList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMList.
+func (in *VSphereVMList) DeepCopy() *VSphereVMList {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereMachineList)
+	out := new(VSphereVMList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereMachineList) DeepCopyObject() runtime.Object {
+func (in *VSphereVMList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -844,39 +2900,40 @@ func (in *VSphereMachineList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereMachineSpec) DeepCopyInto(out *VSphereMachineSpec) {
+func (in *VSphereVMSpec) DeepCopyInto(out *VSphereVMSpec) {
 	*out = *in
 	in.VirtualMachineCloneSpec.DeepCopyInto(&out.VirtualMachineCloneSpec)
-	if in.ProviderID != nil {
-		in, out := &in.ProviderID, &out.ProviderID
-		*out = new(string)
-		**out = **in
-	}
-	if in.FailureDomain != nil {
-		in, out := &in.FailureDomain, &out.FailureDomain
-		*out = new(string)
+	if in.BootstrapRef != nil {
+		in, out := &in.BootstrapRef, &out.BootstrapRef
+		*out = new(corev1.ObjectReference)
 		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineSpec.
-func (in *VSphereMachineSpec) DeepCopy() *VSphereMachineSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMSpec.
+func (in *VSphereVMSpec) DeepCopy() *VSphereVMSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereMachineSpec)
+	out := new(VSphereVMSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereMachineStatus) DeepCopyInto(out *VSphereMachineStatus) {
+func (in *VSphereVMStatus) DeepCopyInto(out *VSphereVMStatus) {
 	*out = *in
 	if in.Addresses != nil {
 		in, out := &in.Addresses, &out.Addresses
-		*out = make([]apiv1beta1.MachineAddress, len(*in))
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	in.RetryAfter.DeepCopyInto(&out.RetryAfter)
+	if in.Task != nil {
+		in, out := &in.Task, &out.Task
+		*out = new(VirtualMachineTaskStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Network != nil {
 		in, out := &in.Network, &out.Network
 		*out = make([]NetworkStatus, len(*in))
@@ -884,6 +2941,46 @@ func (in *VSphereMachineStatus) DeepCopyInto(out *VSphereMachineStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(VirtualMachineStorageStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VMRef != nil {
+		in, out := &in.VMRef, &out.VMRef
+		*out = new(VirtualMachineReference)
+		**out = **in
+	}
+	if in.Console != nil {
+		in, out := &in.Console, &out.Console
+		*out = new(VirtualMachineConsoleStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Logs != nil {
+		in, out := &in.Logs, &out.Logs
+		*out = new(VirtualMachineLogsStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Operation != nil {
+		in, out := &in.Operation, &out.Operation
+		*out = new(VirtualMachineOperationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DiskWipe != nil {
+		in, out := &in.DiskWipe, &out.DiskWipe
+		*out = new(VirtualMachineDiskWipeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = new(VirtualMachineDriftStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ModuleUUID != nil {
+		in, out := &in.ModuleUUID, &out.ModuleUUID
+		*out = new(string)
+		**out = **in
+	}
 	if in.FailureReason != nil {
 		in, out := &in.FailureReason, &out.FailureReason
 		*out = new(errors.MachineStatusError)
@@ -894,118 +2991,54 @@ func (in *VSphereMachineStatus) DeepCopyInto(out *VSphereMachineStatus) {
 		*out = new(string)
 		**out = **in
 	}
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make(apiv1beta1.Conditions, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineStatus.
-func (in *VSphereMachineStatus) DeepCopy() *VSphereMachineStatus {
-	if in == nil {
-		return nil
-	}
-	out := new(VSphereMachineStatus)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereMachineTemplate) DeepCopyInto(out *VSphereMachineTemplate) {
-	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplate.
-func (in *VSphereMachineTemplate) DeepCopy() *VSphereMachineTemplate {
-	if in == nil {
-		return nil
-	}
-	out := new(VSphereMachineTemplate)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereMachineTemplate) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereMachineTemplateList) DeepCopyInto(out *VSphereMachineTemplateList) {
-	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]VSphereMachineTemplate, len(*in))
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateList.
-func (in *VSphereMachineTemplateList) DeepCopy() *VSphereMachineTemplateList {
-	if in == nil {
-		return nil
-	}
-	out := new(VSphereMachineTemplateList)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereMachineTemplateList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereVMV1Beta2Status)
+		(*in).DeepCopyInto(*out)
 	}
-	return nil
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereMachineTemplateResource) DeepCopyInto(out *VSphereMachineTemplateResource) {
-	*out = *in
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateResource.
-func (in *VSphereMachineTemplateResource) DeepCopy() *VSphereMachineTemplateResource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMStatus.
+func (in *VSphereVMStatus) DeepCopy() *VSphereVMStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereMachineTemplateResource)
+	out := new(VSphereVMStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereMachineTemplateSpec) DeepCopyInto(out *VSphereMachineTemplateSpec) {
+func (in *VSphereVMV1Beta2Status) DeepCopyInto(out *VSphereVMV1Beta2Status) {
 	*out = *in
-	in.Template.DeepCopyInto(&out.Template)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineTemplateSpec.
-func (in *VSphereMachineTemplateSpec) DeepCopy() *VSphereMachineTemplateSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMV1Beta2Status.
+func (in *VSphereVMV1Beta2Status) DeepCopy() *VSphereVMV1Beta2Status {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereMachineTemplateSpec)
+	out := new(VSphereVMV1Beta2Status)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereVM) DeepCopyInto(out *VSphereVM) {
+func (in *VSphereWarmPool) DeepCopyInto(out *VSphereWarmPool) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -1013,18 +3046,18 @@ func (in *VSphereVM) DeepCopyInto(out *VSphereVM) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVM.
-func (in *VSphereVM) DeepCopy() *VSphereVM {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereWarmPool.
+func (in *VSphereWarmPool) DeepCopy() *VSphereWarmPool {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereVM)
+	out := new(VSphereWarmPool)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereVM) DeepCopyObject() runtime.Object {
+func (in *VSphereWarmPool) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -1032,31 +3065,31 @@ func (in *VSphereVM) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereVMList) DeepCopyInto(out *VSphereVMList) {
+func (in *VSphereWarmPoolList) DeepCopyInto(out *VSphereWarmPoolList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]VSphereVM, len(*in))
+		*out = make([]VSphereWarmPool, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMList.
-func (in *VSphereVMList) DeepCopy() *VSphereVMList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereWarmPoolList.
+func (in *VSphereWarmPoolList) DeepCopy() *VSphereWarmPoolList {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereVMList)
+	out := new(VSphereWarmPoolList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VSphereVMList) DeepCopyObject() runtime.Object {
+func (in *VSphereWarmPoolList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -1064,67 +3097,66 @@ func (in *VSphereVMList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereVMSpec) DeepCopyInto(out *VSphereVMSpec) {
+func (in *VSphereWarmPoolSpec) DeepCopyInto(out *VSphereWarmPoolSpec) {
 	*out = *in
-	in.VirtualMachineCloneSpec.DeepCopyInto(&out.VirtualMachineCloneSpec)
-	if in.BootstrapRef != nil {
-		in, out := &in.BootstrapRef, &out.BootstrapRef
-		*out = new(v1.ObjectReference)
-		**out = **in
-	}
+	in.Template.DeepCopyInto(&out.Template)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMSpec.
-func (in *VSphereVMSpec) DeepCopy() *VSphereVMSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereWarmPoolSpec.
+func (in *VSphereWarmPoolSpec) DeepCopy() *VSphereWarmPoolSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereVMSpec)
+	out := new(VSphereWarmPoolSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VSphereVMStatus) DeepCopyInto(out *VSphereVMStatus) {
+func (in *VSphereWarmPoolStatus) DeepCopyInto(out *VSphereWarmPoolStatus) {
 	*out = *in
-	if in.Addresses != nil {
-		in, out := &in.Addresses, &out.Addresses
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	in.RetryAfter.DeepCopyInto(&out.RetryAfter)
-	if in.Network != nil {
-		in, out := &in.Network, &out.Network
-		*out = make([]NetworkStatus, len(*in))
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.FailureReason != nil {
-		in, out := &in.FailureReason, &out.FailureReason
-		*out = new(errors.MachineStatusError)
-		**out = **in
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereWarmPoolV1Beta2Status)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.FailureMessage != nil {
-		in, out := &in.FailureMessage, &out.FailureMessage
-		*out = new(string)
-		**out = **in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereWarmPoolStatus.
+func (in *VSphereWarmPoolStatus) DeepCopy() *VSphereWarmPoolStatus {
+	if in == nil {
+		return nil
 	}
+	out := new(VSphereWarmPoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereWarmPoolV1Beta2Status) DeepCopyInto(out *VSphereWarmPoolV1Beta2Status) {
+	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make(apiv1beta1.Conditions, len(*in))
+		*out = make([]v1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereVMStatus.
-func (in *VSphereVMStatus) DeepCopy() *VSphereVMStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereWarmPoolV1Beta2Status.
+func (in *VSphereWarmPoolV1Beta2Status) DeepCopy() *VSphereWarmPoolV1Beta2Status {
 	if in == nil {
 		return nil
 	}
-	out := new(VSphereVMStatus)
+	out := new(VSphereWarmPoolV1Beta2Status)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -1154,6 +3186,11 @@ func (in *VirtualMachine) DeepCopy() *VirtualMachine {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualMachineCloneSpec) DeepCopyInto(out *VirtualMachineCloneSpec) {
 	*out = *in
+	if in.CABundleRef != nil {
+		in, out := &in.CABundleRef, &out.CABundleRef
+		*out = new(CABundleReference)
+		**out = **in
+	}
 	in.Network.DeepCopyInto(&out.Network)
 	if in.AdditionalDisksGiB != nil {
 		in, out := &in.AdditionalDisksGiB, &out.AdditionalDisksGiB
@@ -1172,6 +3209,55 @@ func (in *VirtualMachineCloneSpec) DeepCopyInto(out *VirtualMachineCloneSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PCIDevices != nil {
+		in, out := &in.PCIDevices, &out.PCIDevices
+		*out = make([]PCIDeviceSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Timeouts != nil {
+		in, out := &in.Timeouts, &out.Timeouts
+		*out = new(ProvisioningTimeouts)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DataDisks != nil {
+		in, out := &in.DataDisks, &out.DataDisks
+		*out = make([]DataDiskSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.GuestSoftPowerOffTimeout != nil {
+		in, out := &in.GuestSoftPowerOffTimeout, &out.GuestSoftPowerOffTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.HostAffinity != nil {
+		in, out := &in.HostAffinity, &out.HostAffinity
+		*out = new(HostAffinitySpec)
+		**out = **in
+	}
+	if in.TimeSync != nil {
+		in, out := &in.TimeSync, &out.TimeSync
+		*out = new(TimeSyncSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Encryption != nil {
+		in, out := &in.Encryption, &out.Encryption
+		*out = new(EncryptionSpec)
+		**out = **in
+	}
+	if in.WindowsActivation != nil {
+		in, out := &in.WindowsActivation, &out.WindowsActivation
+		*out = new(WindowsActivationSpec)
+		**out = **in
+	}
+	if in.ExistingVM != nil {
+		in, out := &in.ExistingVM, &out.ExistingVM
+		*out = new(ExistingVMSpec)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineCloneSpec.
@@ -1183,3 +3269,196 @@ func (in *VirtualMachineCloneSpec) DeepCopy() *VirtualMachineCloneSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineConsoleStatus) DeepCopyInto(out *VirtualMachineConsoleStatus) {
+	*out = *in
+	in.AcquiredTime.DeepCopyInto(&out.AcquiredTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineConsoleStatus.
+func (in *VirtualMachineConsoleStatus) DeepCopy() *VirtualMachineConsoleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineConsoleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineDatastoreUsage) DeepCopyInto(out *VirtualMachineDatastoreUsage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineDatastoreUsage.
+func (in *VirtualMachineDatastoreUsage) DeepCopy() *VirtualMachineDatastoreUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineDatastoreUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineDiskWipeStatus) DeepCopyInto(out *VirtualMachineDiskWipeStatus) {
+	*out = *in
+	if in.WipedDisks != nil {
+		in, out := &in.WipedDisks, &out.WipedDisks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CompletedTime != nil {
+		in, out := &in.CompletedTime, &out.CompletedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineDiskWipeStatus.
+func (in *VirtualMachineDiskWipeStatus) DeepCopy() *VirtualMachineDiskWipeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineDiskWipeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineDrift) DeepCopyInto(out *VirtualMachineDrift) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineDrift.
+func (in *VirtualMachineDrift) DeepCopy() *VirtualMachineDrift {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineDrift)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineDriftStatus) DeepCopyInto(out *VirtualMachineDriftStatus) {
+	*out = *in
+	if in.Drifted != nil {
+		in, out := &in.Drifted, &out.Drifted
+		*out = make([]VirtualMachineDrift, len(*in))
+		copy(*out, *in)
+	}
+	in.LastCheckTime.DeepCopyInto(&out.LastCheckTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineDriftStatus.
+func (in *VirtualMachineDriftStatus) DeepCopy() *VirtualMachineDriftStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineDriftStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineLogsStatus) DeepCopyInto(out *VirtualMachineLogsStatus) {
+	*out = *in
+	in.CollectedTime.DeepCopyInto(&out.CollectedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineLogsStatus.
+func (in *VirtualMachineLogsStatus) DeepCopy() *VirtualMachineLogsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineLogsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineOperationStatus) DeepCopyInto(out *VirtualMachineOperationStatus) {
+	*out = *in
+	in.ProcessedTime.DeepCopyInto(&out.ProcessedTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineOperationStatus.
+func (in *VirtualMachineOperationStatus) DeepCopy() *VirtualMachineOperationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineOperationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineReference) DeepCopyInto(out *VirtualMachineReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineReference.
+func (in *VirtualMachineReference) DeepCopy() *VirtualMachineReference {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineStorageStatus) DeepCopyInto(out *VirtualMachineStorageStatus) {
+	*out = *in
+	if in.Datastores != nil {
+		in, out := &in.Datastores, &out.Datastores
+		*out = make([]VirtualMachineDatastoreUsage, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineStorageStatus.
+func (in *VirtualMachineStorageStatus) DeepCopy() *VirtualMachineStorageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineStorageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualMachineTaskStatus) DeepCopyInto(out *VirtualMachineTaskStatus) {
+	*out = *in
+	in.QueueTime.DeepCopyInto(&out.QueueTime)
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualMachineTaskStatus.
+func (in *VirtualMachineTaskStatus) DeepCopy() *VirtualMachineTaskStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualMachineTaskStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WindowsActivationSpec) DeepCopyInto(out *WindowsActivationSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WindowsActivationSpec.
+func (in *WindowsActivationSpec) DeepCopy() *WindowsActivationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WindowsActivationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
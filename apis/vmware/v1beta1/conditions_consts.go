@@ -19,6 +19,9 @@ package v1beta1
 
 import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 
+// The condition types and reasons below follow the same stability and polarity
+// rules as the catalog of the infrastructure.cluster.x-k8s.io/v1beta1 API group.
+
 const (
 	// ResourcePolicyReadyCondition reports the successful creation of a
 	// Resource Policy.
@@ -34,7 +37,7 @@ const (
 	// Cluster Network.
 	ClusterNetworkReadyCondition clusterv1.ConditionType = "ClusterNetworkReady"
 
-	// ClusterNetworkProvisionStarted is used when waiting for Cluster
+	// ClusterNetworkProvisionStartedReason is used when waiting for Cluster
 	// Network to be Ready.
 	ClusterNetworkProvisionStartedReason = "ClusterNetworkProvisionStarted"
 	// ClusterNetworkProvisionFailedReason is used when any errors occur
@@ -55,6 +58,22 @@ const (
 	WaitingForLoadBalancerIPReason = "WaitingForLoadBalancerIP"
 )
 
+const (
+	// VMResourcesAvailableCondition reports whether the VirtualMachineClasses
+	// and the storage classes used by the machines of a cluster are still
+	// available in its namespace.
+	VMResourcesAvailableCondition clusterv1.ConditionType = "VMResourcesAvailable"
+
+	// VMClassUnavailableReason (Severity=Error) documents a
+	// VirtualMachineClass used by the cluster which is not bound to its
+	// namespace anymore, or is being unbound from it.
+	VMClassUnavailableReason = "VMClassUnavailable"
+	// StorageClassUnavailableReason (Severity=Error) documents a storage
+	// class used by the cluster which is not part of the storage quotas of
+	// its namespace anymore.
+	StorageClassUnavailableReason = "StorageClassUnavailable"
+)
+
 // Conditions and condition Reasons for VSphereMachine.
 const (
 	// ConditionType VMProvisionedCondition is shared with infrav1.VSPhereMachine
@@ -69,6 +88,17 @@ const (
 	WaitingForNetworkAddressReason = "WaitingForNetworkAddress"
 	// WaitingForBIOSUUIDReason (Severity=Info) documents a VSphereMachine waiting for the the machine to have a BIOS UUID.
 	WaitingForBIOSUUIDReason = "WaitingForBIOSUUID"
+	// ClassNotFoundReason (Severity=Error) documents a VSphereMachine whose VirtualMachineClass does not exist or is not
+	// bound to its namespace.
+	ClassNotFoundReason = "ClassNotFound"
+	// StorageClassNotFoundReason (Severity=Error) documents a VSphereMachine whose storage class, or the storage class of
+	// one of its volumes, is not part of the storage quotas of its namespace.
+	StorageClassNotFoundReason = "StorageClassNotFound"
+	// ImageNotFoundReason (Severity=Error) documents a VSphereMachine whose VirtualMachineImage does not exist.
+	ImageNotFoundReason = "ImageNotFound"
+	// ImageIncompatibleReason (Severity=Error) documents a VSphereMachine whose VirtualMachineImage is not supported by
+	// VM Operator, or ships another Kubernetes version than the one of its Machine.
+	ImageIncompatibleReason = "ImageIncompatible"
 )
 
 const (
@@ -89,9 +119,24 @@ const (
 	SupervisorHeadlessSvcName      = "supervisor"
 	SupervisorHeadlessSvcPort      = 6443
 
+	// SupervisorEndpointConfigMapNamespace and SupervisorEndpointConfigMapName identify the ConfigMap published in
+	// the guest cluster that advertises the supervisor API server address to in-guest components.
+	SupervisorEndpointConfigMapNamespace = "kube-public"
+	SupervisorEndpointConfigMapName      = "supervisor-apiserver-endpoint"
+
+	// SupervisorEndpointConfigMapHostKey is the key in the endpoint ConfigMap holding the supervisor API server host.
+	SupervisorEndpointConfigMapHostKey = "host"
+	// SupervisorEndpointConfigMapPortKey is the key in the endpoint ConfigMap holding the supervisor API server port.
+	SupervisorEndpointConfigMapPortKey = "port"
+	// SupervisorEndpointConfigMapServerKey is the key in the endpoint ConfigMap holding the supervisor API server URL.
+	SupervisorEndpointConfigMapServerKey = "server"
+
 	// ServiceDiscoveryReadyCondition documents the status of service discoveries
 	ServiceDiscoveryReadyCondition clusterv1.ConditionType = "ServiceDiscoveryReady"
 
 	// SupervisorHeadlessServiceSetupFailedReason documents the headless service setup for svc api server failed
 	SupervisorHeadlessServiceSetupFailedReason = "SupervisorHeadlessServiceSetupFailed"
+
+	// SupervisorEndpointConfigMapSetupFailedReason documents the supervisor endpoint ConfigMap setup in the guest cluster failed
+	SupervisorEndpointConfigMapSetupFailedReason = "SupervisorEndpointConfigMapSetupFailed"
 )
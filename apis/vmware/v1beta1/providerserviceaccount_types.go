@@ -23,10 +23,62 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// ProviderServiceAccountNamespaceLabel is set on the target namespaces created in the guest cluster for the
+	// ProviderServiceAccounts. Only the namespaces with this label are deleted when the guest cluster is deleted. The
+	// target namespaces created by former versions are labelled when they were created right before their target secret.
+	ProviderServiceAccountNamespaceLabel = "providerserviceaccount.vmware.infrastructure.cluster.x-k8s.io/target-namespace"
+
+	// ProviderServiceAccountLabel is set on the ServiceAccounts, Roles and RoleBindings created in the supervisor for a
+	// ProviderServiceAccount, to the name of the ProviderServiceAccount. The objects created by former versions of the
+	// controller do not have it.
+	ProviderServiceAccountLabel = "providerserviceaccount.vmware.infrastructure.cluster.x-k8s.io/name"
+
+	// ProviderServiceAccountTargetLabel is set on the target secrets created in the guest clusters for the
+	// ProviderServiceAccounts selecting clusters with a ClusterSelector, to the name of the ProviderServiceAccount. The
+	// target secrets with this label are removed from the guest clusters which are no longer selected.
+	ProviderServiceAccountTargetLabel = "providerserviceaccount.vmware.infrastructure.cluster.x-k8s.io/provider-serviceaccount"
+
+	// ProviderServiceAccountTokenExpirationAnnotation is set on the target secrets holding a projected token. It records
+	// the expiration time of the token, in RFC 3339 format.
+	ProviderServiceAccountTokenExpirationAnnotation = "providerserviceaccount.vmware.infrastructure.cluster.x-k8s.io/token-expiration"
+
+	// ProviderServiceAccountTokenAudiencesAnnotation is set on the target secrets holding a projected token. It records
+	// the comma separated audiences the token was requested for.
+	ProviderServiceAccountTokenAudiencesAnnotation = "providerserviceaccount.vmware.infrastructure.cluster.x-k8s.io/token-audiences"
+
+	// ProviderServiceAccountMigratedFromAnnotation is set on the ProviderServiceAccounts migrated from a legacy
+	// ProviderServiceAccount of the same namespace and name. It records the API version of the legacy object, e.g.
+	// run.tanzu.vmware.com/v1alpha1. The spec of the migrated ProviderServiceAccount is kept in sync with the legacy
+	// object until the legacy object is deleted.
+	ProviderServiceAccountMigratedFromAnnotation = "providerserviceaccount.vmware.infrastructure.cluster.x-k8s.io/migrated-from"
+
+	// ProviderServiceAccountInjectLabel is set by the users on the Deployments, StatefulSets and DaemonSets of the target
+	// namespace of a guest cluster, to the name of the ProviderServiceAccount whose projected token is injected in their
+	// pods. It is also set on the secrets holding the injected tokens.
+	ProviderServiceAccountInjectLabel = "providerserviceaccount.vmware.infrastructure.cluster.x-k8s.io/inject"
+
+	// ProviderServiceAccountInjectAudiencesAnnotation is set by the users on the workloads labelled with
+	// ProviderServiceAccountInjectLabel, to the comma separated audiences of the token injected in their pods, among the
+	// audiences of the token projection. The audiences of the token projection are used when it is not set.
+	ProviderServiceAccountInjectAudiencesAnnotation = "providerserviceaccount.vmware.infrastructure.cluster.x-k8s.io/inject-audiences"
+
+	// DefaultTokenExpirationSeconds is the default duration of validity of a projected token.
+	DefaultTokenExpirationSeconds = int64(3600)
+)
+
 // ProviderServiceAccountSpec defines the desired state of ProviderServiceAccount.
 type ProviderServiceAccountSpec struct {
 	// Ref specifies the reference to the VSphereCluster for which the ProviderServiceAccount needs to be realized.
-	Ref *corev1.ObjectReference `json:"ref"`
+	// Either Ref or ClusterSelector must be set.
+	// +optional
+	Ref *corev1.ObjectReference `json:"ref,omitempty"`
+
+	// ClusterSelector selects the Clusters of the namespace of the ProviderServiceAccount, by their labels, for whose
+	// VSphereClusters the ProviderServiceAccount needs to be realized, instead of a single Ref. The target secret is
+	// removed from the clusters which stop matching, the target namespace is kept.
+	// +optional
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
 
 	// Rules specifies the privileges that need to be granted to the service account.
 	Rules []rbacv1.PolicyRule `json:"rules"`
@@ -38,6 +90,24 @@ type ProviderServiceAccountSpec struct {
 	// TargetSecretName is the name of the secret in the target cluster that contains the generated service account
 	// token.
 	TargetSecretName string `json:"targetSecretName"`
+
+	// TokenProjection, when set, syncs a bound token of the service account to the target secret, requested for the
+	// given audiences and rotated before it expires, instead of the long-lived token of the service account.
+	// +optional
+	TokenProjection *TokenProjection `json:"tokenProjection,omitempty"`
+}
+
+// TokenProjection defines the bound token synced to the target secret of a ProviderServiceAccount.
+type TokenProjection struct {
+	// Audiences are the intended audiences of the token. The token is valid for the supervisor API server when empty.
+	// +optional
+	Audiences []string `json:"audiences,omitempty"`
+
+	// ExpirationSeconds is the requested duration of validity of the token. The token is rotated once 80% of this
+	// duration has elapsed. Defaults to 3600.
+	// +kubebuilder:validation:Minimum=600
+	// +optional
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
 }
 
 // ProviderServiceAccountStatus defines the observed state of ProviderServiceAccount.
@@ -51,6 +121,7 @@ type ProviderServiceAccountStatus struct {
 // +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="VSphereCluster",type=string,JSONPath=.spec.ref.name
+// +kubebuilder:printcolumn:name="ClusterSelector",type=string,JSONPath=.spec.clusterSelector,priority=1
 // +kubebuilder:printcolumn:name="TargetNamespace",type=string,JSONPath=.spec.targetNamespace
 // +kubebuilder:printcolumn:name="TargetSecretName",type=string,JSONPath=.spec.targetSecretName
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//nolint:godot
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SystemServiceAccountName is the name of a system service account, in the
+// form system.serviceaccount.<namespace>.<name>.
+// +kubebuilder:validation:MaxLength=317
+// +kubebuilder:validation:Pattern=`^system\.serviceaccount\.[a-z0-9]([-a-z0-9]*[a-z0-9])?\.[a-z0-9]([-.a-z0-9]*[a-z0-9])?$`
+type SystemServiceAccountName string
+
+// SystemServiceAccountRegistrySpec defines the desired state of SystemServiceAccountRegistry
+type SystemServiceAccountRegistrySpec struct {
+	// ServiceAccounts are the system service accounts allowed to modify the
+	// Cluster API objects of the supervisor. The service accounts of the
+	// ProviderServiceAccounts are added while they are realized for a cluster,
+	// the other ones are managed by the administrators.
+	// +optional
+	// +listType=set
+	ServiceAccounts []SystemServiceAccountName `json:"serviceAccounts,omitempty"`
+}
+
+// SystemServiceAccountStatus reports the clusters a system service account is
+// synced to.
+type SystemServiceAccountStatus struct {
+	// Name is the name of the system service account.
+	Name SystemServiceAccountName `json:"name"`
+
+	// Clusters are the VSphereClusters, in the form <namespace>/<name>, the
+	// ProviderServiceAccount of the service account was last synced to.
+	// +optional
+	// +listType=set
+	Clusters []string `json:"clusters,omitempty"`
+}
+
+// SystemServiceAccountRegistryStatus defines the observed state of SystemServiceAccountRegistry
+type SystemServiceAccountRegistryStatus struct {
+	// ServiceAccounts are the system service accounts synced to at least one
+	// cluster.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	ServiceAccounts []SystemServiceAccountStatus `json:"serviceAccounts,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=systemserviceaccountregistries,scope=Cluster,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// SystemServiceAccountRegistry is the schema for the SystemServiceAccountRegistry API.
+// It replaces the ConfigMap listing the system service accounts when the
+// controller manager is started with --system-serviceaccount-registry.
+type SystemServiceAccountRegistry struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SystemServiceAccountRegistrySpec   `json:"spec,omitempty"`
+	Status SystemServiceAccountRegistryStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SystemServiceAccountRegistryList contains a list of SystemServiceAccountRegistry
+type SystemServiceAccountRegistryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SystemServiceAccountRegistry `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SystemServiceAccountRegistry{}, &SystemServiceAccountRegistryList{})
+}
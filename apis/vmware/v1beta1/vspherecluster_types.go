@@ -46,15 +46,42 @@ type VSphereClusterStatus struct {
 	// +optional
 	ResourcePolicyName string `json:"resourcePolicyName,omitempty"`
 
+	// NetworkName is the name of the network provisioned for the cluster by
+	// the network provider, if one exists
+	// +optional
+	NetworkName string `json:"networkName,omitempty"`
+
+	// NetworkSNATIP is the IP address used to SNAT the egress traffic of the
+	// cluster network, if one exists
+	// +optional
+	NetworkSNATIP string `json:"networkSNATIP,omitempty"`
+
 	// Conditions defines current service state of the VSphereCluster.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 
+	// V1Beta2 groups the fields of the VSphereCluster status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereClusterV1Beta2Status `json:"v1beta2,omitempty"`
+
 	// FailureDomains is a list of failure domain objects synced from the
 	// infrastructure provider.
 	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
 }
 
+// VSphereClusterV1Beta2Status groups the fields of the VSphereCluster status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereClusterV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereCluster using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:path=vsphereclusters,scope=Namespaced,categories=cluster-api
 // +kubebuilder:storageversion
@@ -86,6 +113,22 @@ func (r *VSphereCluster) SetConditions(conditions clusterv1.Conditions) {
 	r.Status.Conditions = conditions
 }
 
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereCluster.
+func (r *VSphereCluster) GetV1Beta2Conditions() []metav1.Condition {
+	if r.Status.V1Beta2 == nil {
+		return nil
+	}
+	return r.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereCluster.
+func (r *VSphereCluster) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if r.Status.V1Beta2 == nil {
+		r.Status.V1Beta2 = &VSphereClusterV1Beta2Status{}
+	}
+	r.Status.V1Beta2.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&VSphereCluster{}, &VSphereClusterList{})
 }
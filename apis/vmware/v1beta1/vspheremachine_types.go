@@ -64,8 +64,24 @@ type VSphereMachineSpec struct {
 	// Volumes is the set of PVCs to be created and attached to the VSphereMachine
 	// +optional
 	Volumes []VSphereMachineVolume `json:"volumes,omitempty"`
+
+	// SecurityGroups is the list of security groups the network interfaces of
+	// the underlying virtual machine are members of. With the NSX network
+	// provider, each group is translated to a tag of the ports of the virtual
+	// machine, which NSX groups can select to apply firewall rules.
+	// The list is ignored by the other network providers.
+	// +optional
+	// +kubebuilder:validation:MaxItems=16
+	SecurityGroups []SecurityGroupName `json:"securityGroups,omitempty"`
 }
 
+// SecurityGroupName is the name of a security group, which must be a valid
+// label name.
+// +kubebuilder:validation:MinLength=1
+// +kubebuilder:validation:MaxLength=63
+// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9]([-_.a-zA-Z0-9]*[a-zA-Z0-9])?$`
+type SecurityGroupName string
+
 // VSphereMachineStatus defines the observed state of VSphereMachine
 type VSphereMachineStatus struct {
 	// Ready is true when the provider resource is ready.
@@ -129,6 +145,23 @@ type VSphereMachineStatus struct {
 	// Conditions defines current service state of the VSphereMachine.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
+	// V1Beta2 groups the fields of the VSphereMachine status that follow the conventions of
+	// the Cluster API v1beta2 contract.
+	// +optional
+	V1Beta2 *VSphereMachineV1Beta2Status `json:"v1beta2,omitempty"`
+}
+
+// VSphereMachineV1Beta2Status groups the fields of the VSphereMachine status that follow the
+// conventions of the Cluster API v1beta2 contract.
+type VSphereMachineV1Beta2Status struct {
+	// Conditions mirrors the conditions of the VSphereMachine using the metav1.Condition type;
+	// every condition has a non-empty reason from the documented reason catalog.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // VSphereMachine is the Schema for the vspheremachines API
@@ -165,6 +198,22 @@ func (r *VSphereMachine) SetConditions(conditions clusterv1.Conditions) {
 	r.Status.Conditions = conditions
 }
 
+// GetV1Beta2Conditions returns the v1beta2 conditions of the VSphereMachine.
+func (r *VSphereMachine) GetV1Beta2Conditions() []metav1.Condition {
+	if r.Status.V1Beta2 == nil {
+		return nil
+	}
+	return r.Status.V1Beta2.Conditions
+}
+
+// SetV1Beta2Conditions sets the v1beta2 conditions of the VSphereMachine.
+func (r *VSphereMachine) SetV1Beta2Conditions(conditions []metav1.Condition) {
+	if r.Status.V1Beta2 == nil {
+		r.Status.V1Beta2 = &VSphereMachineV1Beta2Status{}
+	}
+	r.Status.V1Beta2.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&VSphereMachine{}, &VSphereMachineList{})
 }
@@ -24,6 +24,7 @@ package v1beta1
 import (
 	"k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	apiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/errors"
@@ -95,6 +96,11 @@ func (in *ProviderServiceAccountSpec) DeepCopyInto(out *ProviderServiceAccountSp
 		*out = new(v1.ObjectReference)
 		**out = **in
 	}
+	if in.ClusterSelector != nil {
+		in, out := &in.ClusterSelector, &out.ClusterSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Rules != nil {
 		in, out := &in.Rules, &out.Rules
 		*out = make([]rbacv1.PolicyRule, len(*in))
@@ -102,6 +108,11 @@ func (in *ProviderServiceAccountSpec) DeepCopyInto(out *ProviderServiceAccountSp
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.TokenProjection != nil {
+		in, out := &in.TokenProjection, &out.TokenProjection
+		*out = new(TokenProjection)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderServiceAccountSpec.
@@ -129,6 +140,152 @@ func (in *ProviderServiceAccountStatus) DeepCopy() *ProviderServiceAccountStatus
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SystemServiceAccountRegistry) DeepCopyInto(out *SystemServiceAccountRegistry) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SystemServiceAccountRegistry.
+func (in *SystemServiceAccountRegistry) DeepCopy() *SystemServiceAccountRegistry {
+	if in == nil {
+		return nil
+	}
+	out := new(SystemServiceAccountRegistry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SystemServiceAccountRegistry) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SystemServiceAccountRegistryList) DeepCopyInto(out *SystemServiceAccountRegistryList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SystemServiceAccountRegistry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SystemServiceAccountRegistryList.
+func (in *SystemServiceAccountRegistryList) DeepCopy() *SystemServiceAccountRegistryList {
+	if in == nil {
+		return nil
+	}
+	out := new(SystemServiceAccountRegistryList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SystemServiceAccountRegistryList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SystemServiceAccountRegistrySpec) DeepCopyInto(out *SystemServiceAccountRegistrySpec) {
+	*out = *in
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]SystemServiceAccountName, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SystemServiceAccountRegistrySpec.
+func (in *SystemServiceAccountRegistrySpec) DeepCopy() *SystemServiceAccountRegistrySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SystemServiceAccountRegistrySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SystemServiceAccountRegistryStatus) DeepCopyInto(out *SystemServiceAccountRegistryStatus) {
+	*out = *in
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]SystemServiceAccountStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SystemServiceAccountRegistryStatus.
+func (in *SystemServiceAccountRegistryStatus) DeepCopy() *SystemServiceAccountRegistryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SystemServiceAccountRegistryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SystemServiceAccountStatus) DeepCopyInto(out *SystemServiceAccountStatus) {
+	*out = *in
+	if in.Clusters != nil {
+		in, out := &in.Clusters, &out.Clusters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SystemServiceAccountStatus.
+func (in *SystemServiceAccountStatus) DeepCopy() *SystemServiceAccountStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SystemServiceAccountStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TokenProjection) DeepCopyInto(out *TokenProjection) {
+	*out = *in
+	if in.Audiences != nil {
+		in, out := &in.Audiences, &out.Audiences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExpirationSeconds != nil {
+		in, out := &in.ExpirationSeconds, &out.ExpirationSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TokenProjection.
+func (in *TokenProjection) DeepCopy() *TokenProjection {
+	if in == nil {
+		return nil
+	}
+	out := new(TokenProjection)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VSphereCluster) DeepCopyInto(out *VSphereCluster) {
 	*out = *in
@@ -214,6 +371,11 @@ func (in *VSphereClusterStatus) DeepCopyInto(out *VSphereClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereClusterV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.FailureDomains != nil {
 		in, out := &in.FailureDomains, &out.FailureDomains
 		*out = make(apiv1beta1.FailureDomains, len(*in))
@@ -323,6 +485,28 @@ func (in *VSphereClusterTemplateSpec) DeepCopy() *VSphereClusterTemplateSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereClusterV1Beta2Status) DeepCopyInto(out *VSphereClusterV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereClusterV1Beta2Status.
+func (in *VSphereClusterV1Beta2Status) DeepCopy() *VSphereClusterV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereClusterV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VSphereMachine) DeepCopyInto(out *VSphereMachine) {
 	*out = *in
@@ -402,6 +586,11 @@ func (in *VSphereMachineSpec) DeepCopyInto(out *VSphereMachineSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SecurityGroups != nil {
+		in, out := &in.SecurityGroups, &out.SecurityGroups
+		*out = make([]SecurityGroupName, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineSpec.
@@ -444,6 +633,11 @@ func (in *VSphereMachineStatus) DeepCopyInto(out *VSphereMachineStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.V1Beta2 != nil {
+		in, out := &in.V1Beta2, &out.V1Beta2
+		*out = new(VSphereMachineV1Beta2Status)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineStatus.
@@ -546,6 +740,28 @@ func (in *VSphereMachineTemplateSpec) DeepCopy() *VSphereMachineTemplateSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSphereMachineV1Beta2Status) DeepCopyInto(out *VSphereMachineV1Beta2Status) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSphereMachineV1Beta2Status.
+func (in *VSphereMachineV1Beta2Status) DeepCopy() *VSphereMachineV1Beta2Status {
+	if in == nil {
+		return nil
+	}
+	out := new(VSphereMachineV1Beta2Status)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VSphereMachineVolume) DeepCopyInto(out *VSphereMachineVolume) {
 	*out = *in
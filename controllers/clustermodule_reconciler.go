@@ -0,0 +1,238 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/clustermodule"
+)
+
+// +kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=kubeadmcontrolplanes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinedeployments,verbs=get;list;watch
+
+// controlPlaneModuleKeyPrefix is used to keep the key of the KubeadmControlPlane
+// distinct from a MachineDeployment with the same name.
+const controlPlaneModuleKeyPrefix = "kcp"
+
+type clusterModuleReconciler struct {
+	*context.ControllerContext
+
+	ClusterModuleService clustermodule.Service
+}
+
+func newClusterModuleReconciler(controllerCtx *context.ControllerContext) clusterModuleReconciler {
+	return clusterModuleReconciler{
+		ControllerContext:    controllerCtx,
+		ClusterModuleService: clustermodule.NewService(),
+	}
+}
+
+// Reconcile ensures a cluster module exists for the KubeadmControlPlane and
+// for every MachineDeployment of the cluster, and removes the cluster modules
+// whose owning object is gone or has opted out of anti-affinity.
+func (r clusterModuleReconciler) Reconcile(ctx *context.ClusterContext) (reterr error) {
+	ctx.Logger.Info("reconcile anti affinity setup")
+
+	defer func() {
+		if reterr != nil {
+			conditions.MarkFalse(ctx.VSphereCluster, infrav1.ClusterModulesAvailableCondition, infrav1.ClusterModuleSetupFailedReason,
+				clusterv1.ConditionSeverityWarning, reterr.Error())
+		} else if len(ctx.VSphereCluster.Spec.ClusterModules) > 0 {
+			conditions.MarkTrue(ctx.VSphereCluster, infrav1.ClusterModulesAvailableCondition)
+		} else {
+			conditions.Delete(ctx.VSphereCluster, infrav1.ClusterModulesAvailableCondition)
+		}
+	}()
+
+	objectMap, err := r.fetchMachineOwnerObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errList []error
+	clusterModuleSpecs := []infrav1.ClusterModule{}
+	for _, mod := range ctx.VSphereCluster.Spec.ClusterModules {
+		curr := mod.TargetObjectName
+		if mod.ControlPlane {
+			curr = appendKCPKey(curr)
+		}
+		obj, ok := objectMap[curr]
+		if !ok {
+			// The owning object is gone or has opted out, so the cluster module
+			// is not needed anymore.
+			if err := r.ClusterModuleService.Remove(ctx, mod.ModuleUUID); err != nil {
+				ctx.Logger.Error(err, "failed to delete cluster module for object",
+					"name", mod.TargetObjectName, "moduleUUID", mod.ModuleUUID)
+				clusterModuleSpecs = append(clusterModuleSpecs, mod)
+				errList = append(errList, err)
+			}
+			continue
+		}
+
+		exists, err := r.ClusterModuleService.DoesExist(ctx, obj, mod.ModuleUUID)
+		if err != nil {
+			ctx.Logger.Error(err, "failed to verify cluster module for object",
+				"name", mod.TargetObjectName, "moduleUUID", mod.ModuleUUID)
+			clusterModuleSpecs = append(clusterModuleSpecs, mod)
+			delete(objectMap, curr)
+			errList = append(errList, err)
+			continue
+		}
+		if exists {
+			clusterModuleSpecs = append(clusterModuleSpecs, mod)
+			delete(objectMap, curr)
+		}
+		// A cluster module which no longer exists in vCenter is recreated below.
+	}
+
+	for _, obj := range objectMap {
+		moduleUUID, err := r.ClusterModuleService.Create(ctx, obj)
+		if err != nil {
+			ctx.Logger.Error(err, "failed to create cluster module for object", "name", obj.GetName())
+			errList = append(errList, err)
+			continue
+		}
+		// An empty UUID means the object does not require a cluster module.
+		if moduleUUID != "" {
+			clusterModuleSpecs = append(clusterModuleSpecs, infrav1.ClusterModule{
+				ControlPlane:     obj.IsControlPlane(),
+				TargetObjectName: obj.GetName(),
+				ModuleUUID:       moduleUUID,
+			})
+		}
+	}
+	ctx.VSphereCluster.Spec.ClusterModules = clusterModuleSpecs
+
+	if len(errList) > 0 {
+		return errors.Wrapf(kerrors.NewAggregate(errList), "failed to reconcile cluster modules for %s", ctx)
+	}
+	return nil
+}
+
+// ReconcileDelete removes the cluster modules of the VSphereCluster.
+func (r clusterModuleReconciler) ReconcileDelete(ctx *context.ClusterContext) error {
+	var errList []error
+	clusterModuleSpecs := []infrav1.ClusterModule{}
+	for _, mod := range ctx.VSphereCluster.Spec.ClusterModules {
+		if err := r.ClusterModuleService.Remove(ctx, mod.ModuleUUID); err != nil {
+			ctx.Logger.Error(err, "failed to delete cluster module for object",
+				"name", mod.TargetObjectName, "moduleUUID", mod.ModuleUUID)
+			clusterModuleSpecs = append(clusterModuleSpecs, mod)
+			errList = append(errList, err)
+		}
+	}
+	ctx.VSphereCluster.Spec.ClusterModules = clusterModuleSpecs
+	return kerrors.NewAggregate(errList)
+}
+
+// fetchMachineOwnerObjects returns the non-deleted KubeadmControlPlane and
+// MachineDeployments of the cluster which have not opted out of anti-affinity.
+func (r clusterModuleReconciler) fetchMachineOwnerObjects(ctx *context.ClusterContext) (map[string]clustermodule.Wrapper, error) {
+	objects := map[string]clustermodule.Wrapper{}
+
+	labels := map[string]string{clusterv1.ClusterLabelName: ctx.Cluster.GetName()}
+	kcpList := &controlplanev1.KubeadmControlPlaneList{}
+	if err := r.Client.List(
+		ctx, kcpList,
+		client.InNamespace(ctx.Cluster.GetNamespace()),
+		client.MatchingLabels(labels)); err != nil {
+		return nil, errors.Wrapf(err, "failed to list control plane objects for %s", ctx)
+	}
+	if len(kcpList.Items) > 1 {
+		return nil, errors.Errorf("multiple control plane objects found for %s, expected 1, found %d", ctx, len(kcpList.Items))
+	}
+	if len(kcpList.Items) != 0 {
+		if kcp := &kcpList.Items[0]; kcp.GetDeletionTimestamp().IsZero() && !skipsAntiAffinity(kcp) {
+			objects[appendKCPKey(kcp.GetName())] = clustermodule.NewWrapper(kcp)
+		}
+	}
+
+	mdList := &clusterv1.MachineDeploymentList{}
+	if err := r.Client.List(
+		ctx, mdList,
+		client.InNamespace(ctx.Cluster.GetNamespace()),
+		client.MatchingLabels(labels)); err != nil {
+		return nil, errors.Wrapf(err, "failed to list machine deployments for %s", ctx)
+	}
+	for i := range mdList.Items {
+		md := &mdList.Items[i]
+		if md.GetDeletionTimestamp().IsZero() && !skipsAntiAffinity(md) {
+			objects[md.GetName()] = clustermodule.NewWrapper(md)
+		}
+	}
+	return objects, nil
+}
+
+// PopulateWatchesOnController adds the watches on the objects owning the
+// cluster modules to the controller of the VSphereCluster.
+func (r clusterModuleReconciler) PopulateWatchesOnController(builder *ctrl.Builder) {
+	builder.Watches(
+		&source.Kind{Type: &controlplanev1.KubeadmControlPlane{}},
+		handler.EnqueueRequestsFromMapFunc(r.toAffinityInput),
+	).Watches(
+		&source.Kind{Type: &clusterv1.MachineDeployment{}},
+		handler.EnqueueRequestsFromMapFunc(r.toAffinityInput),
+	)
+}
+
+// toAffinityInput maps a KubeadmControlPlane or MachineDeployment to the
+// VSphereCluster of its cluster.
+func (r clusterModuleReconciler) toAffinityInput(obj client.Object) []reconcile.Request {
+	cluster, err := clusterutilv1.GetClusterFromMetadata(r, r.Client, metav1.ObjectMeta{
+		Namespace: obj.GetNamespace(),
+		Labels:    obj.GetLabels(),
+	})
+	if err != nil {
+		r.Logger.V(4).Error(err, "failed to get owner cluster", "namespace", obj.GetNamespace(), "name", obj.GetName())
+		return nil
+	}
+
+	if cluster.Spec.InfrastructureRef == nil || cluster.Spec.InfrastructureRef.Kind != "VSphereCluster" {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: client.ObjectKey{
+			Namespace: cluster.Namespace,
+			Name:      cluster.Spec.InfrastructureRef.Name,
+		}},
+	}
+}
+
+func skipsAntiAffinity(obj client.Object) bool {
+	_, ok := obj.GetAnnotations()[infrav1.AnnotationSkipAntiAffinity]
+	return ok
+}
+
+func appendKCPKey(name string) string {
+	return strings.Join([]string{controlPlaneModuleKeyPrefix, name}, "-")
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/clustermodule"
+)
+
+// fakeClusterModuleService records the cluster modules it manages in memory.
+type fakeClusterModuleService struct {
+	modules map[string]bool
+	removed []string
+}
+
+func (s *fakeClusterModuleService) Create(_ *context.ClusterContext, wrapper clustermodule.Wrapper) (string, error) {
+	uuid := "uuid-" + wrapper.GetName()
+	s.modules[uuid] = true
+	return uuid, nil
+}
+
+func (s *fakeClusterModuleService) DoesExist(_ *context.ClusterContext, _ clustermodule.Wrapper, moduleUUID string) (bool, error) {
+	return s.modules[moduleUUID], nil
+}
+
+func (s *fakeClusterModuleService) Remove(_ *context.ClusterContext, moduleUUID string) error {
+	delete(s.modules, moduleUUID)
+	s.removed = append(s.removed, moduleUUID)
+	return nil
+}
+
+func TestClusterModuleReconciler_Reconcile(t *testing.T) {
+	kcp := func(annotations map[string]string) client.Object {
+		return &controlplanev1.KubeadmControlPlane{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   fake.Namespace,
+				Name:        "kcp",
+				Labels:      map[string]string{clusterv1.ClusterLabelName: fake.Clusterv1a2Name},
+				Annotations: annotations,
+			},
+			Spec: controlplanev1.KubeadmControlPlaneSpec{
+				MachineTemplate: controlplanev1.KubeadmControlPlaneMachineTemplate{
+					InfrastructureRef: corev1.ObjectReference{Kind: "VSphereMachineTemplate", Name: "cp-template"},
+				},
+			},
+		}
+	}
+	md := func(name string) client.Object {
+		return &clusterv1.MachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      name,
+				Labels:    map[string]string{clusterv1.ClusterLabelName: fake.Clusterv1a2Name},
+			},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		objects         []client.Object
+		existingModules []infrav1.ClusterModule
+		serviceModules  map[string]bool
+		expectedModules []infrav1.ClusterModule
+		expectedRemoved []string
+	}{
+		{
+			name:    "creates a cluster module per control plane and machine deployment",
+			objects: []client.Object{kcp(nil), md("md-0")},
+			expectedModules: []infrav1.ClusterModule{
+				{ControlPlane: true, TargetObjectName: "kcp", ModuleUUID: "uuid-kcp"},
+				{ControlPlane: false, TargetObjectName: "md-0", ModuleUUID: "uuid-md-0"},
+			},
+		},
+		{
+			name:    "keeps existing cluster modules and removes stale ones",
+			objects: []client.Object{kcp(nil)},
+			existingModules: []infrav1.ClusterModule{
+				{ControlPlane: true, TargetObjectName: "kcp", ModuleUUID: "uuid-existing"},
+				{ControlPlane: false, TargetObjectName: "md-deleted", ModuleUUID: "uuid-md-deleted"},
+			},
+			serviceModules: map[string]bool{"uuid-existing": true, "uuid-md-deleted": true},
+			expectedModules: []infrav1.ClusterModule{
+				{ControlPlane: true, TargetObjectName: "kcp", ModuleUUID: "uuid-existing"},
+			},
+			expectedRemoved: []string{"uuid-md-deleted"},
+		},
+		{
+			name:    "recreates a cluster module missing from vCenter",
+			objects: []client.Object{md("md-0")},
+			existingModules: []infrav1.ClusterModule{
+				{ControlPlane: false, TargetObjectName: "md-0", ModuleUUID: "uuid-lost"},
+			},
+			expectedModules: []infrav1.ClusterModule{
+				{ControlPlane: false, TargetObjectName: "md-0", ModuleUUID: "uuid-md-0"},
+			},
+		},
+		{
+			name:    "skips objects opting out of anti-affinity",
+			objects: []client.Object{kcp(map[string]string{infrav1.AnnotationSkipAntiAffinity: ""})},
+			existingModules: []infrav1.ClusterModule{
+				{ControlPlane: true, TargetObjectName: "kcp", ModuleUUID: "uuid-kcp"},
+			},
+			serviceModules:  map[string]bool{"uuid-kcp": true},
+			expectedModules: []infrav1.ClusterModule{},
+			expectedRemoved: []string{"uuid-kcp"},
+		},
+	}
+
+	for _, tt := range tests {
+		// Looks odd, but need to reinitialize test variable
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(tt.objects...))
+			ctx := fake.NewClusterContext(controllerCtx)
+			ctx.VSphereCluster.Spec.ClusterModules = tt.existingModules
+
+			svc := &fakeClusterModuleService{modules: map[string]bool{}}
+			for uuid := range tt.serviceModules {
+				svc.modules[uuid] = true
+			}
+			r := clusterModuleReconciler{
+				ControllerContext:    controllerCtx,
+				ClusterModuleService: svc,
+			}
+
+			g.Expect(r.Reconcile(ctx)).To(Succeed())
+			g.Expect(ctx.VSphereCluster.Spec.ClusterModules).To(ConsistOf(tt.expectedModules))
+			g.Expect(svc.removed).To(ConsistOf(tt.expectedRemoved))
+			if len(tt.expectedModules) > 0 {
+				g.Expect(conditions.IsTrue(ctx.VSphereCluster, infrav1.ClusterModulesAvailableCondition)).To(BeTrue())
+			} else {
+				g.Expect(conditions.Has(ctx.VSphereCluster, infrav1.ClusterModulesAvailableCondition)).To(BeFalse())
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+)
+
+// vCenterUnavailableReason returns the reason of the VCenterAvailable
+// condition of an object whose session to vCenter failed to be created.
+func vCenterUnavailableReason(err error) string {
+	if capverrors.IsInvalidLogin(err) {
+		return infrav1.VCenterCredentialsInvalidReason
+	}
+	return infrav1.VCenterUnreachableReason
+}
+
+// markGuestBootstrapped sets the GuestBootstrapped condition of a
+// VSphereMachine whose VM is provisioned, from the node of its Machine.
+func markGuestBootstrapped(ctx context.MachineContext) {
+	if ctx.GetMachine().Status.NodeRef != nil {
+		conditions.MarkTrue(ctx.GetVSphereMachine(), infrav1.GuestBootstrappedCondition)
+		return
+	}
+	conditions.MarkFalse(ctx.GetVSphereMachine(), infrav1.GuestBootstrappedCondition, infrav1.WaitingForGuestBootstrapReason, clusterv1.ConditionSeverityInfo, "")
+}
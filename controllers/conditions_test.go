@@ -0,0 +1,55 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestVCenterUnavailableReason(t *testing.T) {
+	g := NewWithT(t)
+	invalidLogin := soap.WrapSoapFault(&soap.Fault{Detail: struct {
+		Fault types.AnyType `xml:",any,typeattr"`
+	}{Fault: types.InvalidLogin{}}})
+
+	g.Expect(vCenterUnavailableReason(errors.Wrap(invalidLogin, "unable to login"))).To(Equal(infrav1.VCenterCredentialsInvalidReason))
+	g.Expect(vCenterUnavailableReason(errors.New("connection refused"))).To(Equal(infrav1.VCenterUnreachableReason))
+}
+
+func TestMarkGuestBootstrapped(t *testing.T) {
+	g := NewWithT(t)
+	controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+	machineCtx := fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+
+	markGuestBootstrapped(machineCtx)
+	g.Expect(conditions.IsFalse(machineCtx.VSphereMachine, infrav1.GuestBootstrappedCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(machineCtx.VSphereMachine, infrav1.GuestBootstrappedCondition)).To(Equal(infrav1.WaitingForGuestBootstrapReason))
+
+	machineCtx.Machine.Status.NodeRef = &corev1.ObjectReference{Name: "node"}
+	markGuestBootstrapped(machineCtx)
+	g.Expect(conditions.IsTrue(machineCtx.VSphereMachine, infrav1.GuestBootstrappedCondition)).To(BeTrue())
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+)
+
+// isGuestClusterBeingDeleted returns true if the Cluster owning the
+// VSphereCluster is being deleted. CAPI deletes the control plane of the guest
+// cluster before the VSphereCluster, so the controllers syncing objects to the
+// guest cluster remove them while its API server is still reachable, instead
+// of leaving them in the backups of the guest cluster.
+func isGuestClusterBeingDeleted(ctx *vmwarecontext.ClusterContext) bool {
+	cluster, err := clusterutilv1.GetOwnerCluster(ctx, ctx.Client, ctx.VSphereCluster.ObjectMeta)
+	if err != nil || cluster == nil {
+		ctx.Logger.V(4).Info("Unable to get owner cluster, assuming it is not being deleted", "err", err)
+		return false
+	}
+	return !cluster.DeletionTimestamp.IsZero()
+}
+
+// deleteGuestObjects deletes the objects from the guest cluster. The deletion
+// is best effort: the objects already gone are skipped, and the remaining ones
+// are still deleted when one of the deletions fails.
+func deleteGuestObjects(ctx *vmwarecontext.GuestClusterContext, objs ...client.Object) error {
+	var errs []error
+	for _, obj := range objs {
+		if err := ctx.GuestClient.Delete(ctx, obj); err != nil {
+			if !apierrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		ctx.Logger.Info("Deleted object from guest cluster", "kind", reflect.TypeOf(obj).Elem().Name(),
+			"namespace", obj.GetNamespace(), "name", obj.GetName())
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// clusterDeletionStarted filters the events of the Clusters to the updates
+// setting their deletion timestamp, so the VSphereClusters are reconciled as
+// soon as the deletion of their Cluster starts.
+var clusterDeletionStarted = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldCluster, ok := e.ObjectOld.(*clusterv1.Cluster)
+		if !ok {
+			return false
+		}
+		newCluster, ok := e.ObjectNew.(*clusterv1.Cluster)
+		if !ok {
+			return false
+		}
+		return oldCluster.DeletionTimestamp.IsZero() && !newCluster.DeletionTimestamp.IsZero()
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
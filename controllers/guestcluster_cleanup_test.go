@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/builder"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+var _ = Describe("Guest cluster cleanup on deletion", func() {
+	var ctx *builder.UnitTestContextForController
+
+	BeforeEach(func() {
+		ctx = serviceDiscoveryTestSuite.NewUnitTestContextForController()
+	})
+	AfterEach(func() {
+		ctx = nil
+	})
+
+	It("detects the deletion of the owner cluster", func() {
+		Expect(isGuestClusterBeingDeleted(ctx.ClusterContext)).To(BeFalse())
+
+		cluster := &clusterv1.Cluster{}
+		Expect(ctx.Client.Get(ctx, client.ObjectKey{Namespace: ctx.VSphereCluster.Namespace, Name: fake.Clusterv1a2Name}, cluster)).To(Succeed())
+		ctx.VSphereCluster.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Cluster",
+			Name:       cluster.Name,
+			UID:        cluster.UID,
+		}}
+		Expect(isGuestClusterBeingDeleted(ctx.ClusterContext)).To(BeFalse())
+
+		cluster.Finalizers = []string{clusterv1.ClusterFinalizer}
+		Expect(ctx.Client.Update(ctx, cluster)).To(Succeed())
+		Expect(ctx.Client.Delete(ctx, cluster)).To(Succeed())
+		Expect(isGuestClusterBeingDeleted(ctx.ClusterContext)).To(BeTrue())
+	})
+
+	It("deletes the guest objects which exist", func() {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "synced"}}
+		Expect(ctx.GuestClient.Create(ctx, secret)).To(Succeed())
+
+		missing := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "missing"}}
+		Expect(deleteGuestObjects(ctx.GuestClusterContext, missing, secret.DeepCopy())).To(Succeed())
+		Expect(ctx.GuestClient.Get(ctx, client.ObjectKeyFromObject(secret), &corev1.Secret{})).NotTo(Succeed())
+	})
+
+	It("only reconciles when the deletion of a cluster starts", func() {
+		cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "workload"}}
+		deleting := cluster.DeepCopy()
+		now := metav1.Now()
+		deleting.DeletionTimestamp = &now
+
+		Expect(clusterDeletionStarted.Create(event.CreateEvent{Object: cluster})).To(BeFalse())
+		Expect(clusterDeletionStarted.Update(event.UpdateEvent{ObjectOld: cluster, ObjectNew: cluster})).To(BeFalse())
+		Expect(clusterDeletionStarted.Update(event.UpdateEvent{ObjectOld: cluster, ObjectNew: deleting})).To(BeTrue())
+		Expect(clusterDeletionStarted.Update(event.UpdateEvent{ObjectOld: deleting, ObjectNew: deleting})).To(BeFalse())
+		Expect(clusterDeletionStarted.Delete(event.DeleteEvent{Object: deleting})).To(BeFalse())
+	})
+})
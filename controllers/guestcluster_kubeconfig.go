@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// kubeconfigSecretMapper maps the kubeconfig secret of a guest cluster to its
+// VSphereCluster. The clients of the guest clusters are created from the
+// kubeconfig secret at each reconcile, so reconciling the VSphereCluster as
+// soon as CAPI rotates the secret replaces the clients using the previous CA
+// or credentials right away, instead of once the requeue of a reconcile which
+// failed with them is due.
+type kubeconfigSecretMapper struct {
+	ctx *context.ControllerManagerContext
+}
+
+func (d kubeconfigSecretMapper) Map(o client.Object) []reconcile.Request {
+	clusterName, purpose, err := secret.ParseSecretName(o.GetName())
+	if err != nil || purpose != secret.Kubeconfig {
+		return nil
+	}
+	key := client.ObjectKey{Namespace: o.GetNamespace(), Name: clusterName}
+	if err := d.ctx.Client.Get(d.ctx, key, &vmwarev1.VSphereCluster{}); err != nil {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
+// kubeconfigSecretChanged filters the events of the secrets to the creation
+// of secrets and the updates of their data, so the resyncs of the secrets do
+// not reconcile the VSphereClusters.
+var kubeconfigSecretChanged = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return true },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSecret, ok := e.ObjectOld.(*corev1.Secret)
+		if !ok {
+			return false
+		}
+		newSecret, ok := e.ObjectNew.(*corev1.Secret)
+		if !ok {
+			return false
+		}
+		return !reflect.DeepEqual(oldSecret.Data, newSecret.Data)
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/builder"
+)
+
+var _ = Describe("Guest cluster kubeconfig secret rotation", func() {
+	var ctx *builder.UnitTestContextForController
+
+	BeforeEach(func() {
+		ctx = serviceDiscoveryTestSuite.NewUnitTestContextForController()
+	})
+	AfterEach(func() {
+		ctx = nil
+	})
+
+	newSecret := func(name string, data string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ctx.VSphereCluster.Namespace, Name: name},
+			Data:       map[string][]byte{"value": []byte(data)},
+		}
+	}
+
+	It("maps the kubeconfig secret of a cluster to its VSphereCluster", func() {
+		mapper := kubeconfigSecretMapper{ctx: ctx.ControllerManagerContext}
+		Expect(mapper.Map(newSecret(ctx.VSphereCluster.Name+"-kubeconfig", "kubeconfig"))).To(ConsistOf(reconcile.Request{
+			NamespacedName: client.ObjectKey{Namespace: ctx.VSphereCluster.Namespace, Name: ctx.VSphereCluster.Name},
+		}))
+		Expect(mapper.Map(newSecret(ctx.VSphereCluster.Name+"-ca", "ca"))).To(BeEmpty())
+		Expect(mapper.Map(newSecret("missing-kubeconfig", "kubeconfig"))).To(BeEmpty())
+	})
+
+	It("only reconciles on changes of the kubeconfig", func() {
+		oldSecret := newSecret(ctx.VSphereCluster.Name+"-kubeconfig", "kubeconfig")
+		resynced := oldSecret.DeepCopy()
+		resynced.ResourceVersion = "2"
+		Expect(kubeconfigSecretChanged.Update(event.UpdateEvent{ObjectOld: oldSecret, ObjectNew: resynced})).To(BeFalse())
+
+		rotated := newSecret(ctx.VSphereCluster.Name+"-kubeconfig", "rotated kubeconfig")
+		Expect(kubeconfigSecretChanged.Update(event.UpdateEvent{ObjectOld: oldSecret, ObjectNew: rotated})).To(BeTrue())
+		Expect(kubeconfigSecretChanged.Create(event.CreateEvent{Object: rotated})).To(BeTrue())
+		Expect(kubeconfigSecretChanged.Delete(event.DeleteEvent{Object: rotated})).To(BeFalse())
+	})
+})
@@ -0,0 +1,109 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+const (
+	// controlPlaneUpgradeGuestWriteInterval is the minimum interval between two
+	// rounds of writes to a guest cluster while its control plane is being upgraded.
+	controlPlaneUpgradeGuestWriteInterval = time.Minute * 5
+)
+
+// +kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=kubeadmcontrolplanes,verbs=get;list;watch
+
+// guestWriteThrottle limits how often a controller writes to a guest cluster
+// while the control plane of that cluster is being upgraded, so the controller
+// does not add to the apiserver churn caused by the rolling update. Outside of
+// an upgrade, writes are never throttled.
+type guestWriteThrottle struct {
+	mu        sync.Mutex
+	interval  time.Duration
+	lastWrite map[types.NamespacedName]time.Time
+}
+
+func newGuestWriteThrottle(interval time.Duration) *guestWriteThrottle {
+	return &guestWriteThrottle{
+		interval:  interval,
+		lastWrite: map[types.NamespacedName]time.Time{},
+	}
+}
+
+// allow returns true, and records the write for the given cluster while an
+// upgrade is in progress, unless the previous write of the upgrade happened
+// less than the throttle interval ago. In that case it returns false and the
+// time left until the next write is allowed. The writes outside of an upgrade
+// are not recorded, so they do not delay the first write of the next one.
+func (t *guestWriteThrottle) allow(key types.NamespacedName, upgrading bool) (bool, time.Duration) {
+	if t == nil {
+		return true, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !upgrading {
+		delete(t.lastWrite, key)
+		return true, 0
+	}
+	now := time.Now()
+	if last, ok := t.lastWrite[key]; ok {
+		if wait := t.interval - now.Sub(last); wait > 0 {
+			return false, wait
+		}
+	}
+	t.lastWrite[key] = now
+	return true, 0
+}
+
+// forget drops the write history of the given cluster.
+func (t *guestWriteThrottle) forget(key types.NamespacedName) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.lastWrite, key)
+}
+
+// throttleGuestWrites returns a non-empty result if writes to the guest cluster
+// should be skipped for now because its control plane is being upgraded.
+func throttleGuestWrites(ctx *vmwarecontext.ClusterContext, throttle *guestWriteThrottle) (reconcile.Result, bool) {
+	upgrading := false
+	cluster, err := clusterutilv1.GetOwnerCluster(ctx, ctx.Client, ctx.VSphereCluster.ObjectMeta)
+	if err != nil || cluster == nil {
+		ctx.Logger.V(4).Info("Unable to get owner cluster, not throttling guest cluster writes", "err", err)
+	} else if upgrading, err = util.IsControlPlaneUpgradeInProgress(ctx, ctx.Client, cluster); err != nil {
+		ctx.Logger.V(4).Info("Unable to check control plane upgrade status, not throttling guest cluster writes", "err", err)
+	}
+
+	key := types.NamespacedName{Namespace: ctx.VSphereCluster.Namespace, Name: ctx.VSphereCluster.Name}
+	if ok, wait := throttle.allow(key, upgrading); !ok {
+		ctx.Logger.V(4).Info("Control plane upgrade in progress, throttling guest cluster writes", "requeueAfter", wait)
+		return reconcile.Result{RequeueAfter: wait}, true
+	}
+	return reconcile.Result{}, false
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestGuestWriteThrottle_Allow(t *testing.T) {
+	g := NewWithT(t)
+	key := types.NamespacedName{Namespace: "default", Name: "cluster"}
+	throttle := newGuestWriteThrottle(time.Minute)
+
+	// The writes outside of an upgrade are neither throttled nor recorded, so
+	// the first write of an upgrade is allowed.
+	g.Expect(throttle.allow(key, false)).To(BeTrue())
+	g.Expect(throttle.allow(key, false)).To(BeTrue())
+	g.Expect(throttle.lastWrite).NotTo(HaveKey(key))
+	g.Expect(throttle.allow(key, true)).To(BeTrue())
+
+	// The next write of the upgrade waits for the interval.
+	ok, wait := throttle.allow(key, true)
+	g.Expect(ok).To(BeFalse())
+	g.Expect(wait).To(BeNumerically("~", time.Minute, time.Second))
+
+	// A write after the upgrade forgets the writes of the upgrade.
+	g.Expect(throttle.allow(key, false)).To(BeTrue())
+	g.Expect(throttle.allow(key, true)).To(BeTrue())
+
+	// A nil throttle allows every write.
+	var none *guestWriteThrottle
+	g.Expect(none.allow(key, true)).To(BeTrue())
+}
@@ -0,0 +1,287 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+const (
+	// orphanedVMClusterNotFoundReason is the reason of the VMs whose Cluster
+	// no longer exists.
+	orphanedVMClusterNotFoundReason = "ClusterNotFound"
+
+	// orphanedVMVSphereVMNotFoundReason is the reason of the VMs whose
+	// VSphereVM no longer exists.
+	orphanedVMVSphereVMNotFoundReason = "VSphereVMNotFound"
+)
+
+// orphanedVMsTotal counts the orphaned VMs found in each vCenter, by reason and
+// result of their deletion.
+var orphanedVMsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "capv_orphaned_vms_total",
+		Help: "Total number of orphaned VMs found by the garbage collector, by vCenter, reason and result of their deletion.",
+	},
+	[]string{"server", "reason", "result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(orphanedVMsTotal)
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vsphereclusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherevms,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters,verbs=get;list;watch
+
+// AddOrphanedVMCollectorToManager adds the garbage collector that periodically
+// deletes the VMs whose VSphereVM or Cluster no longer exists to the provided
+// manager. The VMs are found by the managed tag of their cluster, so the
+// ManagedTags feature gate must be enabled, and only the VMs marked with the
+// ID of the management cluster are deleted.
+func AddOrphanedVMCollectorToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	if !feature.Gates.Enabled(feature.ManagedTags) {
+		return errors.Errorf("the orphaned VM garbage collector requires the %s feature gate", feature.ManagedTags)
+	}
+	if ctx.ManagementClusterID == "" {
+		return errors.New("the orphaned VM garbage collector requires the ID of the management cluster")
+	}
+
+	var (
+		controllerNameShort = "orphanedvm-collector"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+
+	// The collector needs leader election, so that only the leader deletes
+	// VMs.
+	return mgr.Add(newOrphanedVMCollector(controllerContext))
+}
+
+// orphanedVMCollector deletes a VM once it is found orphaned by two
+// consecutive collections, so that a VM whose VSphereVM is being created, or
+// is restored from a backup, is not deleted by the collection racing with it.
+type orphanedVMCollector struct {
+	*context.ControllerContext
+
+	// orphaned are the reasons of the VMs found orphaned by the last
+	// collection, by vCenter and managed object reference.
+	orphaned map[string]string
+}
+
+func newOrphanedVMCollector(ctx *context.ControllerContext) *orphanedVMCollector {
+	return &orphanedVMCollector{ControllerContext: ctx, orphaned: map[string]string{}}
+}
+
+// Start garbage collects the orphaned VMs at every interval until the context
+// is done.
+func (r *orphanedVMCollector) Start(ctx goctx.Context) error {
+	ticker := time.NewTicker(r.OrphanedVMGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.collect(ctx); err != nil {
+				r.Logger.Error(err, "failed to garbage collect the orphaned VMs")
+			}
+		}
+	}
+}
+
+// collect garbage collects the orphaned VMs of every vCenter of a
+// VSphereCluster. A vCenter which cannot be reached does not prevent the
+// collection in the other vCenters.
+func (r *orphanedVMCollector) collect(ctx goctx.Context) error {
+	vsphereClusters := &infrav1.VSphereClusterList{}
+	if err := r.Client.List(ctx, vsphereClusters, ctrlclient.InNamespace(r.WatchNamespace)); err != nil {
+		return errors.Wrap(err, "failed to list VSphereClusters")
+	}
+
+	// The VMs of a vCenter are listed with the credentials of its first
+	// VSphereCluster.
+	sort.Slice(vsphereClusters.Items, func(i, j int) bool {
+		return vsphereClusters.Items[i].Namespace+"/"+vsphereClusters.Items[i].Name <
+			vsphereClusters.Items[j].Namespace+"/"+vsphereClusters.Items[j].Name
+	})
+	// The VMs of a vCenter which cannot be reached are found orphaned again
+	// before they are deleted.
+	orphaned := map[string]string{}
+	servers := map[string]struct{}{}
+	for i := range vsphereClusters.Items {
+		vsphereCluster := &vsphereClusters.Items[i]
+		if _, ok := servers[vsphereCluster.Spec.Server]; ok || vsphereCluster.Spec.Server == "" {
+			continue
+		}
+		servers[vsphereCluster.Spec.Server] = struct{}{}
+		if err := r.collectServer(ctx, vsphereCluster, orphaned); err != nil {
+			r.Logger.Error(err, "failed to garbage collect the orphaned VMs of vCenter", "server", vsphereCluster.Spec.Server)
+		}
+	}
+	r.orphaned = orphaned
+	return nil
+}
+
+// collectServer deletes the managed VMs of the vCenter of a VSphereCluster
+// which were cloned by the management cluster, belong to the watched
+// namespace, and whose VSphereVM or Cluster no longer exists since the last
+// collection. The VMs found orphaned are recorded in orphaned.
+func (r *orphanedVMCollector) collectServer(ctx goctx.Context, vsphereCluster *infrav1.VSphereCluster, orphaned map[string]string) error {
+	params := session.NewParams().
+		WithServer(vsphereCluster.Spec.Server).
+		WithThumbprint(vsphereCluster.Spec.Thumbprint).
+		WithUserInfo(r.Username, r.Password).
+		WithFeatures(session.Feature{
+			EnableKeepAlive:       r.EnableKeepAlive,
+			KeepAliveDuration:     r.KeepAliveDuration,
+			MaxConcurrentRequests: r.MaxConcurrentVCenterRequests,
+		})
+	caBundle, err := identity.GetCABundle(ctx, r.Client, vsphereCluster.Namespace, vsphereCluster.Spec.CABundleRef)
+	if err != nil {
+		return errors.Wrap(err, "failed to retrieve the CA bundle of the vCenter")
+	}
+	params = params.WithCABundle(caBundle)
+	if vsphereCluster.Spec.IdentityRef != nil {
+		creds, err := identity.GetCredentials(ctx, r.Client, vsphereCluster, r.Namespace)
+		if err != nil {
+			return errors.Wrap(err, "failed to retrieve credentials from IdentityRef")
+		}
+		params = params.WithUserInfo(creds.Username, creds.Password)
+	}
+	authSession, err := session.GetOrCreate(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	managedVMs, err := govmomi.ListManagedVMs(ctx, authSession)
+	if err != nil {
+		return err
+	}
+
+	// The VSphereVMs of each cluster are only listed once.
+	vsphereVMsByCluster := map[ctrlclient.ObjectKey][]infrav1.VSphereVM{}
+	for _, managedVM := range managedVMs {
+		// The VMs of the other namespaces may be managed by another manager,
+		// and the VMs of another management cluster, or cloned before the
+		// ID of the management cluster was recorded, may use the same
+		// namespaces and cluster names.
+		if !r.IsWatchedNamespace(managedVM.ClusterNamespace) || managedVM.ManagementClusterID != r.ManagementClusterID {
+			continue
+		}
+
+		key := ctrlclient.ObjectKey{Namespace: managedVM.ClusterNamespace, Name: managedVM.ClusterName}
+		vsphereVMs, ok := vsphereVMsByCluster[key]
+		if !ok {
+			vsphereVMs, err = r.listClusterVSphereVMs(ctx, key)
+			if err != nil {
+				return err
+			}
+			vsphereVMsByCluster[key] = vsphereVMs
+		}
+
+		// The events of a VM whose Cluster no longer exists are recorded on
+		// the VSphereCluster of its vCenter.
+		var (
+			reason string
+			obj    runtime.Object
+		)
+		cluster := &clusterv1.Cluster{}
+		switch err := r.Client.Get(ctx, key, cluster); {
+		case apierrors.IsNotFound(err):
+			reason, obj = orphanedVMClusterNotFoundReason, vsphereCluster
+		case err != nil:
+			return errors.Wrapf(err, "failed to get Cluster %s", key)
+		case cluster.Spec.Paused:
+			// The VSphereVMs of a paused Cluster may be being moved to another
+			// management cluster.
+			continue
+		case !hasVSphereVM(vsphereVMs, managedVM):
+			reason, obj = orphanedVMVSphereVMNotFoundReason, cluster
+		default:
+			continue
+		}
+
+		orphanedKey := vsphereCluster.Spec.Server + "/" + managedVM.Ref.Value
+		orphaned[orphanedKey] = reason
+		if _, ok := r.orphaned[orphanedKey]; !ok {
+			r.Logger.Info("Found orphaned VM, deleting it at the next collection if it is still orphaned",
+				"server", vsphereCluster.Spec.Server, "vm", managedVM.Name, "cluster", key, "reason", reason)
+			continue
+		}
+
+		r.Logger.Info("Deleting orphaned VM", "server", vsphereCluster.Spec.Server, "vm", managedVM.Name, "cluster", key, "reason", reason)
+		r.Recorder.Warnf(obj, "OrphanedVMFound", "Deleting orphaned VM %s of cluster %s in vCenter %s: %s", managedVM.Name, key, vsphereCluster.Spec.Server, reason)
+		if err := govmomi.DestroyManagedVM(ctx, authSession, managedVM.Ref); err != nil {
+			orphanedVMsTotal.WithLabelValues(vsphereCluster.Spec.Server, reason, "failed").Inc()
+			r.Recorder.Warnf(obj, "OrphanedVMDeletionFailed", "Failed to delete orphaned VM %s of cluster %s: %v", managedVM.Name, key, err)
+			continue
+		}
+		orphanedVMsTotal.WithLabelValues(vsphereCluster.Spec.Server, reason, "deleted").Inc()
+		r.Recorder.Eventf(obj, "OrphanedVMDeleted", "Deleted orphaned VM %s of cluster %s", managedVM.Name, key)
+	}
+	return nil
+}
+
+// listClusterVSphereVMs returns the VSphereVMs of a cluster.
+func (r *orphanedVMCollector) listClusterVSphereVMs(ctx goctx.Context, key ctrlclient.ObjectKey) ([]infrav1.VSphereVM, error) {
+	vsphereVMs := &infrav1.VSphereVMList{}
+	if err := r.Client.List(ctx, vsphereVMs,
+		ctrlclient.InNamespace(key.Namespace),
+		ctrlclient.MatchingLabels{clusterv1.ClusterLabelName: key.Name}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list VSphereVMs of cluster %s", key)
+	}
+	return vsphereVMs.Items, nil
+}
+
+// hasVSphereVM returns true if one of the VSphereVMs is the one of the VM,
+// either by name or, for the VMs claimed from a warm pool, by BIOS UUID.
+func hasVSphereVM(vsphereVMs []infrav1.VSphereVM, managedVM govmomi.ManagedVM) bool {
+	for i := range vsphereVMs {
+		if vsphereVMs[i].Name == managedVM.Name ||
+			(managedVM.BiosUUID != "" && vsphereVMs[i].Spec.BiosUUID == managedVM.BiosUUID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/metadata"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestOrphanedVMCollector_Collect(t *testing.T) {
+	g := NewWithT(t)
+	ctx := goctx.Background()
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	s, err := session.GetOrCreate(ctx, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// Tag the VMs with the managed tag of their cluster.
+	tagVM := func(name, clusterName string) {
+		vm, err := s.Finder.VirtualMachine(ctx, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		tagID, err := metadata.EnsureManagedTag(ctx, s.TagManager, metadata.ClusterTagCategory, metadata.ClusterTagName(fake.Namespace, clusterName))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(s.TagManager.AttachTag(ctx, tagID, vm.Reference())).To(Succeed())
+	}
+	tagVM("DC0_H0_VM0", "cluster")
+	tagVM("DC0_H0_VM1", "cluster")
+	tagVM("DC0_C0_RP0_VM0", "deleted-cluster")
+	tagVM("DC0_C0_RP0_VM1", "paused-cluster")
+	markVMs := func(managementClusterID string) {
+		for _, name := range []string{"DC0_H0_VM0", "DC0_H0_VM1", "DC0_C0_RP0_VM0", "DC0_C0_RP0_VM1"} {
+			vm, err := s.Finder.VirtualMachine(ctx, name)
+			g.Expect(err).NotTo(HaveOccurred())
+			task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+				ExtraConfig: []types.BaseOptionValue{&types.OptionValue{Key: extra.ManagementClusterIDKey, Value: managementClusterID}},
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(task.Wait(ctx)).To(Succeed())
+		}
+	}
+	vmExists := func(name string) bool {
+		_, err := s.Finder.VirtualMachine(ctx, name)
+		return err == nil
+	}
+
+	vsphereVMOf := func(name string) *infrav1.VSphereVM {
+		return &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      name,
+			Labels:    map[string]string{clusterv1.ClusterLabelName: "cluster"},
+		}}
+	}
+	vsphereCluster := &infrav1.VSphereCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "cluster"},
+		Spec:       infrav1.VSphereClusterSpec{Server: simr.ServerURL().Host},
+	}
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "cluster"}}
+	pausedCluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "paused-cluster"},
+		Spec:       clusterv1.ClusterSpec{Paused: true},
+	}
+	mgmtContext := fake.NewControllerManagerContext(vsphereCluster, cluster, pausedCluster, vsphereVMOf("DC0_H0_VM0"), vsphereVMOf("other"))
+	mgmtContext.Username = simr.Username()
+	mgmtContext.Password = simr.Password()
+	mgmtContext.ManagementClusterID = "management-cluster"
+	r := newOrphanedVMCollector(fake.NewControllerContext(mgmtContext))
+
+	// The VMs cloned before the ID of the management cluster was recorded,
+	// or cloned by another management cluster, are kept.
+	g.Expect(r.collect(ctx)).To(Succeed())
+	g.Expect(r.collect(ctx)).To(Succeed())
+	g.Expect(vmExists("DC0_H0_VM1")).To(BeTrue())
+	markVMs("other-management-cluster")
+	g.Expect(r.collect(ctx)).To(Succeed())
+	g.Expect(r.collect(ctx)).To(Succeed())
+	g.Expect(vmExists("DC0_H0_VM1")).To(BeTrue())
+
+	// The VMs of the management cluster found orphaned by a collection are
+	// only deleted by the next one.
+	markVMs("management-cluster")
+	server := simr.ServerURL().Host
+	g.Expect(r.collect(ctx)).To(Succeed())
+	g.Expect(vmExists("DC0_H0_VM1")).To(BeTrue())
+	g.Expect(vmExists("DC0_C0_RP0_VM0")).To(BeTrue())
+	g.Expect(r.collect(ctx)).To(Succeed())
+
+	// The VM of an existing VSphereVM is kept.
+	_, err = s.Finder.VirtualMachine(ctx, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The VM of a paused Cluster, whose VSphereVMs may be being moved, is kept.
+	_, err = s.Finder.VirtualMachine(ctx, "DC0_C0_RP0_VM1")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The VMs whose VSphereVM or Cluster no longer exists are deleted.
+	_, err = s.Finder.VirtualMachine(ctx, "DC0_H0_VM1")
+	g.Expect(err).To(HaveOccurred())
+	_, err = s.Finder.VirtualMachine(ctx, "DC0_C0_RP0_VM0")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(testutil.ToFloat64(orphanedVMsTotal.WithLabelValues(server, orphanedVMVSphereVMNotFoundReason, "deleted"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(orphanedVMsTotal.WithLabelValues(server, orphanedVMClusterNotFoundReason, "deleted"))).To(Equal(1.0))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// isPaused returns true if the object has the paused annotation, or if its
+// Cluster is paused, e.g. while clusterctl moves it to another management
+// cluster. The Cluster may be nil, for the objects which do not belong to a
+// Cluster or whose Cluster does not exist.
+func isPaused(cluster *clusterv1.Cluster, obj metav1.Object) bool {
+	if cluster == nil {
+		return annotations.HasPaused(obj)
+	}
+	return annotations.IsPaused(cluster, obj)
+}
+
+// isPausedByClusterName returns true if the object, or the Cluster with the
+// given name in the namespace of the object, is paused.
+func isPausedByClusterName(ctx goctx.Context, c ctrlclient.Client, clusterName string, obj metav1.Object) (bool, error) {
+	if clusterName == "" {
+		return isPaused(nil, obj), nil
+	}
+	cluster, err := clusterutilv1.GetClusterByName(ctx, c, obj.GetNamespace(), clusterName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return isPaused(nil, obj), nil
+		}
+		return false, err
+	}
+	return isPaused(cluster, obj), nil
+}
+
+// isPausedByOwnerCluster returns true if the object, or the Cluster owning
+// it, is paused.
+func isPausedByOwnerCluster(ctx goctx.Context, c ctrlclient.Client, obj metav1.Object) (bool, error) {
+	cluster, err := clusterutilv1.GetOwnerCluster(ctx, c, metav1.ObjectMeta{
+		Namespace:       obj.GetNamespace(),
+		OwnerReferences: obj.GetOwnerReferences(),
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	return isPaused(cluster, obj), nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestIsPausedByClusterName(t *testing.T) {
+	cluster := func(paused bool) *clusterv1.Cluster {
+		return &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "cluster"},
+			Spec:       clusterv1.ClusterSpec{Paused: paused},
+		}
+	}
+	image := func(annotations map[string]string) *infrav1.VSphereMachineImage {
+		return &infrav1.VSphereMachineImage{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "image", Annotations: annotations},
+		}
+	}
+	paused := map[string]string{clusterv1.PausedAnnotation: ""}
+
+	tests := []struct {
+		name        string
+		objs        []client.Object
+		clusterName string
+		obj         *infrav1.VSphereMachineImage
+		expected    bool
+	}{
+		{name: "without cluster", obj: image(nil)},
+		{name: "without cluster, with paused annotation", obj: image(paused), expected: true},
+		{name: "with cluster", objs: []client.Object{cluster(false)}, clusterName: "cluster", obj: image(nil)},
+		{name: "with paused cluster", objs: []client.Object{cluster(true)}, clusterName: "cluster", obj: image(nil), expected: true},
+		{name: "with cluster, with paused annotation", objs: []client.Object{cluster(false)}, clusterName: "cluster", obj: image(paused), expected: true},
+		{name: "with missing cluster, with paused annotation", clusterName: "cluster", obj: image(paused), expected: true},
+		{name: "with missing cluster", clusterName: "cluster", obj: image(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mgmtContext := fake.NewControllerManagerContext(tt.objs...)
+
+			isPaused, err := isPausedByClusterName(mgmtContext, mgmtContext.Client, tt.clusterName, tt.obj)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(isPaused).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestIsPausedByOwnerCluster(t *testing.T) {
+	g := NewWithT(t)
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "cluster"},
+		Spec:       clusterv1.ClusterSpec{Paused: true},
+	}
+	template := &infrav1.VSphereMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      "template",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       "cluster",
+			}},
+		},
+	}
+	mgmtContext := fake.NewControllerManagerContext(cluster)
+
+	isPaused, err := isPausedByOwnerCluster(mgmtContext, mgmtContext.Client, template)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(isPaused).To(BeTrue())
+
+	// The template is no longer paused once its Cluster is gone.
+	g.Expect(mgmtContext.Client.Delete(mgmtContext, cluster)).To(Succeed())
+	isPaused, err = isPausedByOwnerCluster(mgmtContext, mgmtContext.Client, template)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(isPaused).To(BeFalse())
+}
+
+func TestWarmPoolReconciler_Paused(t *testing.T) {
+	g := NewWithT(t)
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "cluster"},
+		Spec:       clusterv1.ClusterSpec{Paused: true},
+	}
+	pool := &infrav1.VSphereWarmPool{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "pool", UID: "pool-uid"},
+		Spec: infrav1.VSphereWarmPoolSpec{
+			Replicas:    2,
+			ClusterName: "cluster",
+			Template:    infrav1.VirtualMachineCloneSpec{Template: "ubuntu", Server: "vcenter", Datacenter: "dc0"},
+		},
+	}
+	mgmtContext := fake.NewControllerManagerContext(cluster, pool)
+	r := warmPoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+	// A pool being moved by clusterctl does not clone VMs, which would
+	// otherwise duplicate the VMs moved with it.
+	_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pool)})
+	g.Expect(err).NotTo(HaveOccurred())
+	vms := &infrav1.VSphereVMList{}
+	g.Expect(mgmtContext.Client.List(mgmtContext, vms)).To(Succeed())
+	g.Expect(vms.Items).To(BeEmpty())
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbldr "sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// priorityClass is the class of the machines and VMs reconciled by their own
+// instance of the machine and VM controllers. Each instance has its own work
+// queue and workers, so the reconcile requests of the control plane machines
+// never wait behind the ones of the worker machines, e.g. when a control plane
+// machine is remediated while hundreds of worker machines are created.
+type priorityClass string
+
+const (
+	// controlPlanePriorityClass is the class of the machines and VMs of
+	// control planes.
+	controlPlanePriorityClass priorityClass = "control-plane"
+
+	// workerPriorityClass is the class of the other machines and VMs.
+	workerPriorityClass priorityClass = "worker"
+)
+
+// priorityClasses are the priority classes, by decreasing priority.
+var priorityClasses = []priorityClass{controlPlanePriorityClass, workerPriorityClass}
+
+// priorityClassOf returns the priority class of an object, from its control
+// plane label.
+func priorityClassOf(obj metav1.Object) priorityClass {
+	if _, ok := obj.GetLabels()[clusterv1.MachineControlPlaneLabelName]; ok {
+		return controlPlanePriorityClass
+	}
+	return workerPriorityClass
+}
+
+// controllerName returns the name of the controller instance of the priority
+// class. The worker instance keeps the name of the controller, e.g.
+// vspherevm-controller, and the control plane one is suffixed with the class,
+// e.g. vspherevm-control-plane-controller.
+func (p priorityClass) controllerName(controllerName string) string {
+	if p == workerPriorityClass {
+		return controllerName
+	}
+	return fmt.Sprintf("%s-%s-controller", strings.TrimSuffix(controllerName, "-controller"), p)
+}
+
+// newControllerManagedBy returns a builder for the controller instance of the
+// priority class. The worker instance keeps the default name of the builder,
+// and so the labels of the metrics of the controller.
+func (p priorityClass) newControllerManagedBy(mgr manager.Manager, controllerName string) *ctrlbldr.Builder {
+	builder := ctrl.NewControllerManagedBy(mgr)
+	if p != workerPriorityClass {
+		builder = builder.Named(controllerName)
+	}
+	return builder
+}
+
+// maxConcurrentReconciles returns the maximum number of concurrent reconciles
+// of the controller instance of the priority class.
+func (p priorityClass) maxConcurrentReconciles(ctx *context.ControllerManagerContext) int {
+	if p == controlPlanePriorityClass && ctx.ControlPlaneMaxConcurrentReconciles > 0 {
+		return ctx.ControlPlaneMaxConcurrentReconciles
+	}
+	return ctx.MaxConcurrentReconciles
+}
+
+// predicate returns a predicate accepting the events of the objects of the
+// priority class.
+func (p priorityClass) predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return priorityClassOf(obj) == p
+	})
+}
+
+// reconciler returns a reconciler which only passes the requests of the
+// objects of the priority class to the provided reconciler. It drops the
+// requests enqueued by the watches of other objects, e.g. of the Cluster, for
+// the objects of the other class. The requests of the objects which no longer
+// exist are passed to the reconcilers of both classes.
+func (p priorityClass) reconciler(c client.Client, newObject func() client.Object, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx goctx.Context, req ctrl.Request) (reconcile.Result, error) {
+		obj := newObject()
+		if err := c.Get(ctx, req.NamespacedName, obj); err == nil && priorityClassOf(obj) != p {
+			return reconcile.Result{}, nil
+		}
+		return r.Reconcile(ctx, req)
+	})
+}
+
+// priorityEventChannelsFor returns the generic event channels of the
+// controller instances of each priority class of a resource. The events sent
+// to the generic event channel of the resource are dispatched to the channel
+// of the class of their object, until the controller manager stops.
+func priorityEventChannelsFor(ctx *context.ControllerManagerContext, gvk schema.GroupVersionKind) map[priorityClass]chan event.GenericEvent {
+	channels := make(map[priorityClass]chan event.GenericEvent, len(priorityClasses))
+	for _, p := range priorityClasses {
+		channels[p] = make(chan event.GenericEvent)
+	}
+	source := ctx.GetGenericEventChannelFor(gvk)
+	go func() {
+		for {
+			select {
+			case e := <-source:
+				// Do not block the events of a class on the controller
+				// instance of the other one.
+				go func(channel chan event.GenericEvent) {
+					select {
+					case channel <- e:
+					case <-ctx.Done():
+					}
+				}(channels[priorityClassOf(e.Object)])
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return channels
+}
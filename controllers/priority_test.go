@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestPriorityClass(t *testing.T) {
+	controlPlaneVM := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "control-plane",
+		Labels:    map[string]string{clusterv1.MachineControlPlaneLabelName: ""},
+	}}
+	workerVM := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "worker"}}
+
+	t.Run("is the class of the control plane label", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(priorityClassOf(controlPlaneVM)).To(Equal(controlPlanePriorityClass))
+		g.Expect(priorityClassOf(workerVM)).To(Equal(workerPriorityClass))
+	})
+
+	t.Run("names the controller instances", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(controlPlanePriorityClass.controllerName("vspherevm-controller")).To(Equal("vspherevm-control-plane-controller"))
+		g.Expect(workerPriorityClass.controllerName("vspherevm-controller")).To(Equal("vspherevm-controller"))
+	})
+
+	t.Run("defaults the concurrency of the control plane", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := fake.NewControllerManagerContext()
+		ctx.MaxConcurrentReconciles = 10
+		g.Expect(controlPlanePriorityClass.maxConcurrentReconciles(ctx)).To(Equal(10))
+		ctx.ControlPlaneMaxConcurrentReconciles = 2
+		g.Expect(controlPlanePriorityClass.maxConcurrentReconciles(ctx)).To(Equal(2))
+		g.Expect(workerPriorityClass.maxConcurrentReconciles(ctx)).To(Equal(10))
+	})
+
+	t.Run("only reconciles the objects of the class", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := fake.NewControllerManagerContext(controlPlaneVM, workerVM)
+		var reconciled []string
+		inner := reconcile.Func(func(_ goctx.Context, req ctrl.Request) (reconcile.Result, error) {
+			reconciled = append(reconciled, req.Name)
+			return reconcile.Result{}, nil
+		})
+		r := controlPlanePriorityClass.reconciler(ctx.Client, func() client.Object { return &infrav1.VSphereVM{} }, inner)
+
+		for _, name := range []string{"control-plane", "worker", "deleted"} {
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: apitypes.NamespacedName{Namespace: "default", Name: name}})
+			g.Expect(err).NotTo(HaveOccurred())
+		}
+		g.Expect(reconciled).To(Equal([]string{"control-plane", "deleted"}))
+	})
+
+	t.Run("dispatches the generic events by class", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := fake.NewControllerManagerContext()
+		cancelCtx, cancel := goctx.WithCancel(ctx)
+		defer cancel()
+		ctx.Context = cancelCtx
+		gvk := infrav1.GroupVersion.WithKind("VSphereVM")
+		channels := priorityEventChannelsFor(ctx, gvk)
+
+		// The event of a worker does not block the one of the control plane.
+		ctx.GetGenericEventChannelFor(gvk) <- event.GenericEvent{Object: workerVM}
+		ctx.GetGenericEventChannelFor(gvk) <- event.GenericEvent{Object: controlPlaneVM}
+		g.Eventually(channels[controlPlanePriorityClass], time.Second).Should(Receive(Equal(event.GenericEvent{Object: controlPlaneVM})))
+		g.Eventually(channels[workerPriorityClass], time.Second).Should(Receive(Equal(event.GenericEvent{Object: workerVM})))
+	})
+}
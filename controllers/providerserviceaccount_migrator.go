@@ -0,0 +1,197 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+// LegacyProviderServiceAccountGVK is the kind of the ProviderServiceAccounts
+// of the guest cluster controller, which are migrated to the
+// ProviderServiceAccounts of this provider.
+var LegacyProviderServiceAccountGVK = schema.GroupVersionKind{Group: "run.tanzu.vmware.com", Version: "v1alpha1", Kind: "ProviderServiceAccount"}
+
+// legacyProviderServiceAccountSpec is the spec of a legacy
+// ProviderServiceAccount, whose ref is either a VSphereCluster or a
+// TanzuKubernetesCluster.
+type legacyProviderServiceAccountSpec struct {
+	Ref              *corev1.ObjectReference `json:"ref"`
+	Rules            []rbacv1.PolicyRule     `json:"rules"`
+	TargetNamespace  string                  `json:"targetNamespace"`
+	TargetSecretName string                  `json:"targetSecretName"`
+}
+
+// +kubebuilder:rbac:groups=run.tanzu.vmware.com,resources=providerserviceaccounts,verbs=get;list;watch
+// +kubebuilder:rbac:groups=vmware.infrastructure.cluster.x-k8s.io,resources=providerserviceaccounts,verbs=get;list;watch;create;update;patch
+
+// AddProviderServiceAccountMigratorToManager adds the controller migrating
+// the legacy ProviderServiceAccounts to the provided manager.
+func AddProviderServiceAccountMigratorToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controllerNameShort = "providerserviceaccount-migrator"
+		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
+	)
+
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+	r := providerServiceAccountMigrator{ControllerContext: controllerContext}
+
+	legacy := &unstructured.Unstructured{}
+	legacy.SetGroupVersionKind(LegacyProviderServiceAccountGVK)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerNameShort).
+		For(legacy).
+		// Watch the migrated ProviderServiceAccounts, which have the name of
+		// their legacy object, to restore their spec if it is changed.
+		Watches(
+			&source.Kind{Type: &vmwarev1.ProviderServiceAccount{}},
+			&handler.EnqueueRequestForObject{},
+		).
+		Complete(r)
+}
+
+// providerServiceAccountMigrator creates a ProviderServiceAccount for each
+// legacy ProviderServiceAccount, with the same namespace, name and spec, so
+// that the service accounts of the guest clusters are reconciled by this
+// provider once the guest cluster controller stops. The spec of the migrated
+// ProviderServiceAccount follows the legacy object while it exists, and the
+// migrated ProviderServiceAccount is kept once the legacy object is deleted.
+type providerServiceAccountMigrator struct {
+	*context.ControllerContext
+}
+
+func (r providerServiceAccountMigrator) Reconcile(ctx goctx.Context, req reconcile.Request) (reconcile.Result, error) {
+	logger := r.Logger.WithValues("providerserviceaccount", req.NamespacedName)
+
+	legacy := &unstructured.Unstructured{}
+	legacy.SetGroupVersionKind(LegacyProviderServiceAccountGVK)
+	if err := r.Client.Get(ctx, req.NamespacedName, legacy); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !legacy.GetDeletionTimestamp().IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	spec, err := r.migratedSpec(ctx, legacy)
+	if err != nil {
+		r.Recorder.Warn(legacy, "MigrationFailed", err.Error())
+		return reconcile.Result{}, err
+	}
+
+	pSvcAccount := &vmwarev1.ProviderServiceAccount{}
+	if err := r.Client.Get(ctx, req.NamespacedName, pSvcAccount); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+		pSvcAccount = &vmwarev1.ProviderServiceAccount{}
+		pSvcAccount.Namespace, pSvcAccount.Name = legacy.GetNamespace(), legacy.GetName()
+		pSvcAccount.Labels = legacy.GetLabels()
+		pSvcAccount.Annotations = map[string]string{vmwarev1.ProviderServiceAccountMigratedFromAnnotation: legacy.GetAPIVersion()}
+		pSvcAccount.Spec = spec
+		if err := r.Client.Create(ctx, pSvcAccount); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to create ProviderServiceAccount %s", req.NamespacedName)
+		}
+		logger.Info("migrated legacy ProviderServiceAccount")
+		r.Recorder.Eventf(legacy, "Migrated", "migrated to ProviderServiceAccount %s of %s", req.NamespacedName, vmwarev1.GroupVersion)
+		return reconcile.Result{}, nil
+	}
+
+	// A ProviderServiceAccount which was not migrated, e.g. created manually
+	// for the same guest cluster, is left alone.
+	if _, ok := pSvcAccount.Annotations[vmwarev1.ProviderServiceAccountMigratedFromAnnotation]; !ok {
+		logger.V(4).Info("skipping the migration of the legacy ProviderServiceAccount, a ProviderServiceAccount of the same name exists")
+		return reconcile.Result{}, nil
+	}
+
+	// The token projection is not a field of the legacy spec: it is kept.
+	spec.TokenProjection = pSvcAccount.Spec.TokenProjection
+	if reflect.DeepEqual(spec, pSvcAccount.Spec) {
+		return reconcile.Result{}, nil
+	}
+	patch := client.MergeFrom(pSvcAccount.DeepCopy())
+	pSvcAccount.Spec = spec
+	if err := r.Client.Patch(ctx, pSvcAccount, patch); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to patch ProviderServiceAccount %s", req.NamespacedName)
+	}
+	logger.Info("updated the ProviderServiceAccount migrated from the legacy ProviderServiceAccount")
+	return reconcile.Result{}, nil
+}
+
+// migratedSpec returns the spec of the ProviderServiceAccount migrated from a
+// legacy ProviderServiceAccount. A legacy ref to a TanzuKubernetesCluster is
+// replaced by the VSphereCluster of the Cluster of the same name.
+func (r providerServiceAccountMigrator) migratedSpec(ctx goctx.Context, legacy *unstructured.Unstructured) (vmwarev1.ProviderServiceAccountSpec, error) {
+	var legacySpec legacyProviderServiceAccountSpec
+	if content, ok := legacy.Object["spec"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, &legacySpec); err != nil {
+			return vmwarev1.ProviderServiceAccountSpec{}, errors.Wrap(err, "failed to decode the spec of the legacy ProviderServiceAccount")
+		}
+	}
+	if legacySpec.Ref == nil || legacySpec.Ref.Name == "" {
+		return vmwarev1.ProviderServiceAccountSpec{}, errors.New("the legacy ProviderServiceAccount has no ref")
+	}
+
+	spec := vmwarev1.ProviderServiceAccountSpec{
+		Rules:            legacySpec.Rules,
+		TargetNamespace:  legacySpec.TargetNamespace,
+		TargetSecretName: legacySpec.TargetSecretName,
+	}
+	ref := legacySpec.Ref
+	if gv, err := schema.ParseGroupVersion(ref.APIVersion); err == nil && gv.Group == vmwarev1.GroupVersion.Group && ref.Kind == "VSphereCluster" {
+		spec.Ref = &corev1.ObjectReference{APIVersion: vmwarev1.GroupVersion.String(), Kind: "VSphereCluster", Name: ref.Name}
+		return spec, nil
+	}
+
+	cluster := &clusterv1.Cluster{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: legacy.GetNamespace(), Name: ref.Name}, cluster); err != nil {
+		return spec, errors.Wrapf(err, "failed to get the Cluster of %s %s", ref.Kind, ref.Name)
+	}
+	infraRef := cluster.Spec.InfrastructureRef
+	if infraRef == nil || infraRef.Kind != "VSphereCluster" || infraRef.GroupVersionKind().Group != vmwarev1.GroupVersion.Group {
+		return spec, errors.Errorf("the Cluster %s has no supervisor VSphereCluster", ref.Name)
+	}
+	spec.Ref = &corev1.ObjectReference{APIVersion: vmwarev1.GroupVersion.String(), Kind: "VSphereCluster", Name: infraRef.Name}
+	return spec, nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestProviderServiceAccountMigrator(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "tkc"},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{APIVersion: vmwarev1.GroupVersion.String(), Kind: "VSphereCluster", Name: "tkc-xyz"},
+		},
+	}
+	manual := &vmwarev1.ProviderServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "manual"},
+		Spec:       vmwarev1.ProviderServiceAccountSpec{Ref: &corev1.ObjectReference{Name: "other"}, TargetNamespace: "other"},
+	}
+	mgmtContext := fake.NewControllerManagerContext(cluster, manual)
+	mgmtContext.Scheme.AddKnownTypeWithName(LegacyProviderServiceAccountGVK, &unstructured.Unstructured{})
+	mgmtContext.Scheme.AddKnownTypeWithName(LegacyProviderServiceAccountGVK.GroupVersion().WithKind("ProviderServiceAccountList"), &unstructured.UnstructuredList{})
+	r := providerServiceAccountMigrator{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+	legacy := func(name string, ref map[string]interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"ref": ref,
+				"rules": []interface{}{
+					map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"persistentvolumeclaims"}, "verbs": []interface{}{"get"}},
+				},
+				"targetNamespace":  "csi",
+				"targetSecretName": "csi-token",
+			},
+		}}
+		u.SetGroupVersionKind(LegacyProviderServiceAccountGVK)
+		u.SetNamespace(fake.Namespace)
+		u.SetName(name)
+		u.SetLabels(map[string]string{"app": "csi"})
+		g.Expect(mgmtContext.Client.Create(mgmtContext, u)).To(Succeed())
+		return u
+	}
+	reconcileLegacy := func(name string) error {
+		_, err := r.Reconcile(mgmtContext, reconcile.Request{NamespacedName: client.ObjectKey{Namespace: fake.Namespace, Name: name}})
+		return err
+	}
+	migrated := func(name string) *vmwarev1.ProviderServiceAccount {
+		pSvcAccount := &vmwarev1.ProviderServiceAccount{}
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKey{Namespace: fake.Namespace, Name: name}, pSvcAccount)).To(Succeed())
+		return pSvcAccount
+	}
+
+	// A legacy ref to a TanzuKubernetesCluster is replaced by the
+	// VSphereCluster of its Cluster.
+	tkc := legacy("tkc-csi", map[string]interface{}{"apiVersion": "run.tanzu.vmware.com/v1alpha1", "kind": "TanzuKubernetesCluster", "name": "tkc"})
+	g.Expect(reconcileLegacy("tkc-csi")).To(Succeed())
+	pSvcAccount := migrated("tkc-csi")
+	g.Expect(pSvcAccount.Annotations).To(HaveKeyWithValue(vmwarev1.ProviderServiceAccountMigratedFromAnnotation, "run.tanzu.vmware.com/v1alpha1"))
+	g.Expect(pSvcAccount.Labels).To(HaveKeyWithValue("app", "csi"))
+	g.Expect(pSvcAccount.Spec.Ref).To(Equal(&corev1.ObjectReference{APIVersion: vmwarev1.GroupVersion.String(), Kind: "VSphereCluster", Name: "tkc-xyz"}))
+	g.Expect(pSvcAccount.Spec.Rules).To(HaveLen(1))
+	g.Expect(pSvcAccount.Spec.Rules[0].Resources).To(ConsistOf("persistentvolumeclaims"))
+	g.Expect(pSvcAccount.Spec.TargetNamespace).To(Equal("csi"))
+	g.Expect(pSvcAccount.Spec.TargetSecretName).To(Equal("csi-token"))
+
+	// The migrated spec follows the legacy object, but for the token
+	// projection which the legacy spec does not have.
+	pSvcAccount.Spec.TokenProjection = &vmwarev1.TokenProjection{Audiences: []string{"csi"}}
+	pSvcAccount.Spec.TargetNamespace = "changed"
+	g.Expect(mgmtContext.Client.Update(mgmtContext, pSvcAccount)).To(Succeed())
+	g.Expect(unstructured.SetNestedField(tkc.Object, "csi-secret", "spec", "targetSecretName")).To(Succeed())
+	g.Expect(mgmtContext.Client.Update(mgmtContext, tkc)).To(Succeed())
+	g.Expect(reconcileLegacy("tkc-csi")).To(Succeed())
+	pSvcAccount = migrated("tkc-csi")
+	g.Expect(pSvcAccount.Spec.TargetNamespace).To(Equal("csi"))
+	g.Expect(pSvcAccount.Spec.TargetSecretName).To(Equal("csi-secret"))
+	g.Expect(pSvcAccount.Spec.TokenProjection).NotTo(BeNil())
+
+	// A legacy ref to a VSphereCluster is kept.
+	legacy("cluster-csi", map[string]interface{}{"apiVersion": vmwarev1.GroupVersion.String(), "kind": "VSphereCluster", "name": "cluster"})
+	g.Expect(reconcileLegacy("cluster-csi")).To(Succeed())
+	g.Expect(migrated("cluster-csi").Spec.Ref.Name).To(Equal("cluster"))
+
+	// The ProviderServiceAccounts which were not migrated are left alone.
+	legacy("manual", map[string]interface{}{"apiVersion": vmwarev1.GroupVersion.String(), "kind": "VSphereCluster", "name": "cluster"})
+	g.Expect(reconcileLegacy("manual")).To(Succeed())
+	g.Expect(migrated("manual").Spec.TargetNamespace).To(Equal("other"))
+
+	// The legacy objects whose Cluster cannot be found are retried.
+	legacy("missing-csi", map[string]interface{}{"kind": "TanzuKubernetesCluster", "name": "missing"})
+	g.Expect(reconcileLegacy("missing-csi")).To(MatchError(ContainSubstring("failed to get the Cluster of TanzuKubernetesCluster missing")))
+
+	// The migrated ProviderServiceAccount is kept once the legacy object is
+	// deleted.
+	g.Expect(mgmtContext.Client.Delete(mgmtContext, tkc)).To(Succeed())
+	g.Expect(reconcileLegacy("tkc-csi")).To(Succeed())
+	migrated("tkc-csi")
+}
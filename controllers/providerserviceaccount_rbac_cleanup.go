@@ -0,0 +1,322 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+// RBACCleanupPolicy is the policy applied to the ServiceAccounts, Roles and
+// RoleBindings of the ProviderServiceAccounts created by former versions of
+// the controller.
+type RBACCleanupPolicy string
+
+const (
+	// RBACCleanupReport reports the objects with outdated labels or owner
+	// references, and the orphaned objects, without changing them.
+	RBACCleanupReport RBACCleanupPolicy = "Report"
+	// RBACCleanupRelabel relabels and sets the owner reference of the objects
+	// of the existing ProviderServiceAccounts, and reports the orphaned
+	// objects.
+	RBACCleanupRelabel RBACCleanupPolicy = "Relabel"
+	// RBACCleanupDelete relabels the objects of the existing
+	// ProviderServiceAccounts, and deletes the orphaned objects.
+	RBACCleanupDelete RBACCleanupPolicy = "Delete"
+
+	// rbacCleanupRetryInterval is the interval at which a failed cleanup is
+	// retried.
+	rbacCleanupRetryInterval = time.Minute
+)
+
+// ParseRBACCleanupPolicy returns the RBACCleanupPolicy of its name.
+func ParseRBACCleanupPolicy(name string) (RBACCleanupPolicy, error) {
+	switch policy := RBACCleanupPolicy(name); policy {
+	case RBACCleanupReport, RBACCleanupRelabel, RBACCleanupDelete:
+		return policy, nil
+	}
+	return "", errors.Errorf("invalid RBAC cleanup policy %q, must be one of %s, %s or %s", name, RBACCleanupReport, RBACCleanupRelabel, RBACCleanupDelete)
+}
+
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;update;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;update;delete
+// +kubebuilder:rbac:groups=run.tanzu.vmware.com,resources=providerserviceaccounts,verbs=get
+
+// AddProviderServiceAccountRBACCleanupToManager adds the cleanup of the
+// ServiceAccounts, Roles and RoleBindings of the ProviderServiceAccounts
+// created by former versions of the controller to the provided manager.
+func AddProviderServiceAccountRBACCleanupToManager(ctx *context.ControllerManagerContext, mgr manager.Manager, policy RBACCleanupPolicy) error {
+	var (
+		controllerNameShort = "providerserviceaccount-rbac-cleanup"
+		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
+	)
+
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+
+	// The cleanup needs leader election, so that it runs once. The objects
+	// are read from the API server, so that the Roles and RoleBindings of
+	// every namespace are not added to the cache.
+	return mgr.Add(rbacCleanup{ControllerContext: controllerContext, reader: mgr.GetAPIReader(), policy: policy})
+}
+
+// rbacCleanup finds the ServiceAccounts, Roles and RoleBindings owned by a
+// ProviderServiceAccount, or labeled with its name, whose labels or owner
+// references were set by a former version of the controller or by the guest
+// cluster controller. The objects of an existing ProviderServiceAccount are
+// relabeled and owned by it, and the objects of a ProviderServiceAccount which
+// no longer exists, which the garbage collector may not delete, e.g. because
+// their owner is of a kind no longer served, are orphaned.
+type rbacCleanup struct {
+	*context.ControllerContext
+
+	// reader reads the objects to clean up and their ProviderServiceAccounts.
+	reader ctrlclient.Reader
+	policy RBACCleanupPolicy
+}
+
+// rbacCleanupResult counts the objects of a cleanup.
+type rbacCleanupResult struct {
+	outdated, relabeled, orphaned, deleted int
+}
+
+// Start cleans up the objects, retrying a failed cleanup until it succeeds or
+// the context is done.
+func (r rbacCleanup) Start(ctx goctx.Context) error {
+	for {
+		err := r.cleanup(ctx)
+		if err == nil {
+			return nil
+		}
+		r.Logger.Error(err, "failed to clean up the RBAC objects of the provider serviceaccounts, retrying", "after", rbacCleanupRetryInterval)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(rbacCleanupRetryInterval):
+		}
+	}
+}
+
+// cleanup cleans up the ServiceAccounts, Roles and RoleBindings. An object
+// which fails to be cleaned up does not prevent the cleanup of the others.
+func (r rbacCleanup) cleanup(ctx goctx.Context) error {
+	var objs []ctrlclient.Object
+	serviceAccounts := &corev1.ServiceAccountList{}
+	if err := r.reader.List(ctx, serviceAccounts); err != nil {
+		return errors.Wrap(err, "failed to list the serviceaccounts")
+	}
+	for i := range serviceAccounts.Items {
+		objs = append(objs, &serviceAccounts.Items[i])
+	}
+	roles := &rbacv1.RoleList{}
+	if err := r.reader.List(ctx, roles); err != nil {
+		return errors.Wrap(err, "failed to list the roles")
+	}
+	for i := range roles.Items {
+		objs = append(objs, &roles.Items[i])
+	}
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.reader.List(ctx, roleBindings); err != nil {
+		return errors.Wrap(err, "failed to list the rolebindings")
+	}
+	for i := range roleBindings.Items {
+		objs = append(objs, &roleBindings.Items[i])
+	}
+
+	var (
+		result rbacCleanupResult
+		errs   []error
+	)
+	for _, obj := range objs {
+		if err := r.cleanupObject(ctx, obj, &result); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.Logger.Info("Cleaned up the RBAC objects of the provider serviceaccounts", "policy", r.policy,
+		"outdated", result.outdated, "relabeled", result.relabeled, "orphaned", result.orphaned, "deleted", result.deleted)
+	return kerrors.NewAggregate(errs)
+}
+
+// cleanupObject relabels an object of an existing ProviderServiceAccount
+// whose labels or owner references are outdated, or deletes an orphaned
+// object, according to the policy.
+func (r rbacCleanup) cleanupObject(ctx goctx.Context, obj ctrlclient.Object, result *rbacCleanupResult) error {
+	name, ownerRef, ok := providerServiceAccountOwner(obj)
+	if !ok || !obj.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+	kind := objectKind(obj)
+	logger := r.Logger.WithValues(kind, ctrlclient.ObjectKeyFromObject(obj), "providerserviceaccount", name)
+
+	pSvcAccount := &vmwarev1.ProviderServiceAccount{}
+	err := r.reader.Get(ctx, ctrlclient.ObjectKey{Namespace: obj.GetNamespace(), Name: name}, pSvcAccount)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to get the provider serviceaccount of %s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+	}
+	if err == nil {
+		if !isOutdated(obj, pSvcAccount) {
+			return nil
+		}
+		result.outdated++
+		if r.policy == RBACCleanupReport {
+			logger.Info("Found an object of a provider serviceaccount with outdated labels or owner references")
+			r.Recorder.Warnf(pSvcAccount, "OutdatedRBAC", "%s %s has outdated labels or owner references", kind, obj.GetName())
+			return nil
+		}
+		if err := relabel(obj, pSvcAccount, r.Scheme); err != nil {
+			return errors.Wrapf(err, "failed to relabel %s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+		}
+		if err := r.Client.Update(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to relabel %s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+		}
+		result.relabeled++
+		logger.Info("Relabeled an object of a provider serviceaccount")
+		r.Recorder.Eventf(pSvcAccount, "RBACRelabeled", "relabeled %s %s, created by a former version of the controller", kind, obj.GetName())
+		return nil
+	}
+
+	// The objects owned by a legacy ProviderServiceAccount which still
+	// exists belong to the guest cluster controller.
+	if ownerRef != nil && ownerRef.APIVersion == LegacyProviderServiceAccountGVK.GroupVersion().String() {
+		legacy := &unstructured.Unstructured{}
+		legacy.SetGroupVersionKind(LegacyProviderServiceAccountGVK)
+		err := r.reader.Get(ctx, ctrlclient.ObjectKey{Namespace: obj.GetNamespace(), Name: name}, legacy)
+		switch {
+		case err == nil:
+			return nil
+		case !apierrors.IsNotFound(err) && !meta.IsNoMatchError(err):
+			return errors.Wrapf(err, "failed to get the legacy provider serviceaccount of %s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+		}
+	}
+
+	result.orphaned++
+	if r.policy != RBACCleanupDelete {
+		logger.Info("Found an orphaned object of a provider serviceaccount which no longer exists")
+		r.Recorder.Warnf(obj, "OrphanedRBAC", "the provider serviceaccount %s of %s %s no longer exists", name, kind, obj.GetName())
+		return nil
+	}
+	if err := r.Client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete %s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+	}
+	result.deleted++
+	logger.Info("Deleted an orphaned object of a provider serviceaccount which no longer exists")
+	r.Recorder.Eventf(obj, "OrphanedRBACDeleted", "deleted %s %s, whose provider serviceaccount %s no longer exists", kind, obj.GetName(), name)
+	return nil
+}
+
+// providerServiceAccountGroups are the API groups of the ProviderServiceAccounts
+// of this provider and of the guest cluster controller.
+var providerServiceAccountGroups = map[string]bool{
+	vmwarev1.GroupVersion.Group:           true,
+	LegacyProviderServiceAccountGVK.Group: true,
+}
+
+// providerServiceAccountOwner returns the name of the ProviderServiceAccount
+// of an object, and its owner reference to a ProviderServiceAccount, if any.
+// The objects neither owned by a ProviderServiceAccount nor labeled with its
+// name, or controlled by another kind of object, are not created for a
+// ProviderServiceAccount.
+func providerServiceAccountOwner(obj ctrlclient.Object) (string, *metav1.OwnerReference, bool) {
+	if ref := metav1.GetControllerOfNoCopy(obj); ref != nil && ref.Kind != kindProviderServiceAccount {
+		return "", nil, false
+	}
+	for _, ref := range obj.GetOwnerReferences() {
+		ref := ref
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err == nil && ref.Kind == kindProviderServiceAccount && providerServiceAccountGroups[gv.Group] {
+			return ref.Name, &ref, true
+		}
+	}
+	if name := obj.GetLabels()[vmwarev1.ProviderServiceAccountLabel]; name != "" {
+		return name, nil, true
+	}
+	return "", nil, false
+}
+
+// isOutdated returns whether an object is not labeled with the name of its
+// ProviderServiceAccount, or not only owned by it as ProviderServiceAccount.
+func isOutdated(obj ctrlclient.Object, pSvcAccount *vmwarev1.ProviderServiceAccount) bool {
+	if obj.GetLabels()[vmwarev1.ProviderServiceAccountLabel] != pSvcAccount.Name {
+		return true
+	}
+	var owned bool
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind != kindProviderServiceAccount {
+			continue
+		}
+		if ref.UID != pSvcAccount.UID || ref.APIVersion != vmwarev1.GroupVersion.String() || ref.Controller == nil || !*ref.Controller {
+			return true
+		}
+		owned = true
+	}
+	return !owned
+}
+
+// relabel labels an object with the name of its ProviderServiceAccount, and
+// replaces its owner references to ProviderServiceAccounts with a controller
+// reference to it.
+func relabel(obj ctrlclient.Object, pSvcAccount *vmwarev1.ProviderServiceAccount, scheme *runtime.Scheme) error {
+	var refs []metav1.OwnerReference
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind != kindProviderServiceAccount {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+	if err := controllerutil.SetControllerReference(pSvcAccount, obj, scheme); err != nil {
+		return err
+	}
+	setProviderServiceAccountLabel(obj, *pSvcAccount)
+	return nil
+}
+
+// objectKind returns the kind of a ServiceAccount, Role or RoleBinding, in
+// lower case for the logs and events.
+func objectKind(obj ctrlclient.Object) string {
+	switch obj.(type) {
+	case *corev1.ServiceAccount:
+		return "serviceaccount"
+	case *rbacv1.Role:
+		return "role"
+	case *rbacv1.RoleBinding:
+		return "rolebinding"
+	}
+	return "object"
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestRBACCleanup(t *testing.T) {
+	g := NewWithT(t)
+
+	pSvcAccount := &vmwarev1.ProviderServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "csi", UID: "csi-uid"},
+	}
+	ownerRef := func(apiVersion, name string, uid types.UID) metav1.OwnerReference {
+		return metav1.OwnerReference{APIVersion: apiVersion, Kind: "ProviderServiceAccount", Name: name, UID: uid, Controller: pointer.Bool(true)}
+	}
+	legacyAPIVersion := LegacyProviderServiceAccountGVK.GroupVersion().String()
+	meta := func(name string, labels map[string]string, refs ...metav1.OwnerReference) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: fake.Namespace, Name: name, Labels: labels, OwnerReferences: refs}
+	}
+	current := ownerRef(vmwarev1.GroupVersion.String(), "csi", "csi-uid")
+	objs := []client.Object{
+		pSvcAccount,
+		// Created by the guest cluster controller, for a ProviderServiceAccount
+		// which was migrated.
+		&corev1.ServiceAccount{ObjectMeta: meta("csi", nil, ownerRef(legacyAPIVersion, "csi", "legacy-uid"))},
+		// Created by a former version of the controller, without label.
+		&rbacv1.RoleBinding{ObjectMeta: meta("csi", nil, current)},
+		// Up to date.
+		&rbacv1.Role{ObjectMeta: meta("csi", map[string]string{vmwarev1.ProviderServiceAccountLabel: "csi"}, current)},
+		// Orphaned, their ProviderServiceAccount was deleted and the legacy
+		// one is gone.
+		&rbacv1.Role{ObjectMeta: meta("gone", map[string]string{vmwarev1.ProviderServiceAccountLabel: "gone"})},
+		&rbacv1.RoleBinding{ObjectMeta: meta("gone", nil, ownerRef(legacyAPIVersion, "gone", "gone-uid"))},
+		// Owned by a legacy ProviderServiceAccount which still exists.
+		&corev1.ServiceAccount{ObjectMeta: meta("alive", nil, ownerRef(legacyAPIVersion, "alive", "alive-uid"))},
+		// Not created for a ProviderServiceAccount.
+		&corev1.ServiceAccount{ObjectMeta: meta("default", nil)},
+		&rbacv1.Role{ObjectMeta: meta("other", map[string]string{vmwarev1.ProviderServiceAccountLabel: "gone"},
+			metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "other", UID: "other-uid", Controller: pointer.Bool(true)})},
+	}
+	mgmtContext := fake.NewControllerManagerContext(objs...)
+	mgmtContext.Scheme.AddKnownTypeWithName(LegacyProviderServiceAccountGVK, &unstructured.Unstructured{})
+	alive := &unstructured.Unstructured{}
+	alive.SetGroupVersionKind(LegacyProviderServiceAccountGVK)
+	alive.SetNamespace(fake.Namespace)
+	alive.SetName("alive")
+	g.Expect(mgmtContext.Client.Create(mgmtContext, alive)).To(Succeed())
+	controllerCtx := fake.NewControllerContext(mgmtContext)
+
+	get := func(obj client.Object, name string) error {
+		return mgmtContext.Client.Get(mgmtContext, client.ObjectKey{Namespace: fake.Namespace, Name: name}, obj)
+	}
+	expectRelabeled := func(obj client.Object) {
+		g.Expect(obj.GetLabels()).To(HaveKeyWithValue(vmwarev1.ProviderServiceAccountLabel, "csi"))
+		g.Expect(obj.GetOwnerReferences()).To(HaveLen(1))
+		g.Expect(obj.GetOwnerReferences()[0].APIVersion).To(Equal(vmwarev1.GroupVersion.String()))
+		g.Expect(obj.GetOwnerReferences()[0].UID).To(BeEquivalentTo("csi-uid"))
+	}
+
+	// The objects are only reported.
+	r := rbacCleanup{ControllerContext: controllerCtx, reader: mgmtContext.Client, policy: RBACCleanupReport}
+	g.Expect(r.cleanup(mgmtContext)).To(Succeed())
+	serviceAccount := &corev1.ServiceAccount{}
+	g.Expect(get(serviceAccount, "csi")).To(Succeed())
+	g.Expect(serviceAccount.Labels).To(BeEmpty())
+	g.Expect(get(&rbacv1.Role{}, "gone")).To(Succeed())
+
+	// The objects of the existing ProviderServiceAccounts are relabeled.
+	r.policy = RBACCleanupRelabel
+	g.Expect(r.cleanup(mgmtContext)).To(Succeed())
+	g.Expect(get(serviceAccount, "csi")).To(Succeed())
+	expectRelabeled(serviceAccount)
+	roleBinding := &rbacv1.RoleBinding{}
+	g.Expect(get(roleBinding, "csi")).To(Succeed())
+	expectRelabeled(roleBinding)
+	g.Expect(get(&rbacv1.Role{}, "gone")).To(Succeed())
+
+	// The orphaned objects are deleted, but those of the legacy
+	// ProviderServiceAccounts which still exist.
+	r.policy = RBACCleanupDelete
+	g.Expect(r.cleanup(mgmtContext)).To(Succeed())
+	g.Expect(apierrors.IsNotFound(get(&rbacv1.Role{}, "gone"))).To(BeTrue())
+	g.Expect(apierrors.IsNotFound(get(&rbacv1.RoleBinding{}, "gone"))).To(BeTrue())
+	g.Expect(get(&corev1.ServiceAccount{}, "alive")).To(Succeed())
+	g.Expect(get(&corev1.ServiceAccount{}, "default")).To(Succeed())
+	g.Expect(get(&rbacv1.Role{}, "other")).To(Succeed())
+	role := &rbacv1.Role{}
+	g.Expect(get(role, "csi")).To(Succeed())
+	expectRelabeled(role)
+}
+
+func TestParseRBACCleanupPolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	policy, err := ParseRBACCleanupPolicy("Relabel")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(policy).To(Equal(RBACCleanupRelabel))
+	_, err = ParseRBACCleanupPolicy("Remove")
+	g.Expect(err).To(MatchError(ContainSubstring(`invalid RBAC cleanup policy "Remove"`)))
+}
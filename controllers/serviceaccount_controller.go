@@ -22,22 +22,30 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/controllers/remote"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbldr "sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -45,12 +53,14 @@ import (
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/builder"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 )
 
 // +kubebuilder:rbac:groups=vmware.infrastructure.cluster.x-k8s.io,resources=providerserviceaccounts,verbs=get;list;watch;
 // +kubebuilder:rbac:groups=vmware.infrastructure.cluster.x-k8s.io,resources=providerserviceaccounts/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
 
@@ -58,6 +68,20 @@ const (
 	controllerName             = "provider-serviceaccount-controller"
 	kindProviderServiceAccount = "ProviderServiceAccount"
 	systemServiceAccountPrefix = "system.serviceaccount"
+
+	// rootCAConfigMapName is the ConfigMap publishing the CA certificate of
+	// the API server in every namespace.
+	rootCAConfigMapName = "kube-root-ca.crt"
+
+	// minTokenRotateAfter is the minimum duration after which a projected
+	// token is rotated.
+	minTokenRotateAfter = 30 * time.Second
+
+	// formerTargetNamespaceWindow is the maximum duration between the
+	// creation of a target namespace and of its target secret for the
+	// namespace to be considered created by a former version of this
+	// controller.
+	formerTargetNamespaceWindow = time.Minute
 )
 
 // AddServiceAccountProviderControllerToManager adds this controller to the provided manager.
@@ -74,19 +98,62 @@ func AddServiceAccountProviderControllerToManager(ctx *context.ControllerManager
 		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
 		Logger:                   ctx.Logger.WithName(controllerNameShort),
 	}
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return errors.Wrap(err, "failed to create the clientset requesting the projected tokens")
+	}
 	r := ServiceAccountReconciler{
-		ControllerContext: controllerContext,
+		ControllerContext:  controllerContext,
+		guestWriteThrottle: newGuestWriteThrottle(controlPlaneUpgradeGuestWriteInterval),
+		tokenClient:        clientset.CoreV1(),
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).For(controlledType).
+	bldr := ctrl.NewControllerManagedBy(mgr).For(controlledType).
 		// Watch a ProviderServiceAccount
 		Watches(
 			&source.Kind{Type: &vmwarev1.ProviderServiceAccount{}}, &handler.EnqueueRequestForObject{}).
+		// Watch the ProviderServiceAccounts with a cluster selector, which
+		// are realized for every VSphereCluster of their namespace whose
+		// Cluster matches it.
+		Watches(
+			&source.Kind{Type: &vmwarev1.ProviderServiceAccount{}},
+			handler.EnqueueRequestsFromMapFunc(providerServiceAccountMapper{ctx}.Map),
+		).
 		Watches(
 			&source.Kind{Type: &corev1.ServiceAccount{}},
 			handler.EnqueueRequestsFromMapFunc(requestMapper{ctx}.Map),
 		).
-		Complete(r)
+		// Watch the kubeconfig secrets of the guest clusters to reconcile
+		// with the new kubeconfig as soon as it is rotated.
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(kubeconfigSecretMapper{ctx}.Map),
+			ctrlbldr.WithPredicates(kubeconfigSecretChanged),
+		).
+		// Watch the Clusters to remove the objects synced to the guest
+		// clusters as soon as their deletion starts.
+		Watches(
+			&source.Kind{Type: &clusterv1.Cluster{}},
+			handler.EnqueueRequestsFromMapFunc(clusterutilv1.ClusterToInfrastructureMapFunc(vmwarev1.GroupVersion.WithKind("VSphereCluster"))),
+			ctrlbldr.WithPredicates(clusterDeletionStarted),
+		).
+		// Watch the labels of the Clusters, matched by the cluster
+		// selectors of the ProviderServiceAccounts.
+		Watches(
+			&source.Kind{Type: &clusterv1.Cluster{}},
+			handler.EnqueueRequestsFromMapFunc(clusterutilv1.ClusterToInfrastructureMapFunc(vmwarev1.GroupVersion.WithKind("VSphereCluster"))),
+			ctrlbldr.WithPredicates(clusterLabelsChanged),
+		)
+	if ctx.SystemServiceAccountRegistry != "" {
+		// Watch the spec of the SystemServiceAccountRegistry, to register
+		// the service accounts removed from it again.
+		bldr = bldr.Watches(
+			&source.Kind{Type: &vmwarev1.SystemServiceAccountRegistry{}},
+			handler.EnqueueRequestsFromMapFunc(systemServiceAccountRegistryMapper{ctx}.Map),
+			ctrlbldr.WithPredicates(predicate.GenerationChangedPredicate{}),
+		)
+	}
+	return bldr.Complete(r)
 }
 
 type requestMapper struct {
@@ -110,13 +177,7 @@ func getVSphereCluster(ctx *context.ControllerManagerContext, pSvcAccountKey typ
 	if err := ctx.Client.Get(ctx, pSvcAccountKey, pSvcAccount); err != nil {
 		return nil
 	}
-
-	vsphereClusterRef := pSvcAccount.Spec.Ref
-	if vsphereClusterRef == nil || vsphereClusterRef.Name == "" {
-		return nil
-	}
-	key := client.ObjectKey{Namespace: pSvcAccount.Namespace, Name: vsphereClusterRef.Name}
-	return []reconcile.Request{{NamespacedName: key}}
+	return vsphereClusterRequests(ctx, pSvcAccount)
 }
 
 func NewServiceAccountReconciler() builder.Reconciler {
@@ -125,9 +186,19 @@ func NewServiceAccountReconciler() builder.Reconciler {
 
 type ServiceAccountReconciler struct {
 	*context.ControllerContext
+
+	// guestWriteThrottle limits writes to guest clusters whose control plane
+	// is being upgraded.
+	guestWriteThrottle *guestWriteThrottle
+
+	// tokenClient requests the bound tokens of the provider serviceaccounts
+	// with a token projection.
+	tokenClient corev1client.ServiceAccountsGetter
 }
 
-func (r ServiceAccountReconciler) Reconcile(ctx goctx.Context, req reconcile.Request) (_ reconcile.Result, reterr error) {
+func (r ServiceAccountReconciler) Reconcile(ctx goctx.Context, req reconcile.Request) (result reconcile.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
 	r.ControllerContext.Logger.V(4).Info("Starting Reconcile")
 
 	// Get the vSphereCluster for this request.
@@ -141,6 +212,17 @@ func (r ServiceAccountReconciler) Reconcile(ctx goctx.Context, req reconcile.Req
 		return reconcile.Result{}, err
 	}
 
+	// The guest cluster is not written to while the cluster is paused, e.g.
+	// while it is moved to another management cluster.
+	paused, err := isPausedByOwnerCluster(r, r.Client, vsphereCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if paused {
+		r.Logger.V(4).Info("VSphereCluster linked to a cluster that is paused", "cluster", clusterKey)
+		return reconcile.Result{}, nil
+	}
+
 	// Create the patch helper.
 	patchHelper, err := patch.NewHelper(vsphereCluster, r.Client)
 	if err != nil {
@@ -172,6 +254,7 @@ func (r ServiceAccountReconciler) Reconcile(ctx goctx.Context, req reconcile.Req
 		}
 	}()
 	if !vsphereCluster.DeletionTimestamp.IsZero() {
+		r.guestWriteThrottle.forget(clusterKey)
 		return r.ReconcileDelete(clusterContext)
 	}
 
@@ -184,12 +267,26 @@ func (r ServiceAccountReconciler) Reconcile(ctx goctx.Context, req reconcile.Req
 		clusterContext.Logger.Info("The control plane is not ready yet", "err", err)
 		return reconcile.Result{RequeueAfter: clusterNotReadyRequeueTime}, nil
 	}
-
-	// Defer to the Reconciler for reconciling a non-delete event.
-	return r.ReconcileNormal(&vmwarecontext.GuestClusterContext{
+	guestClusterContext := &vmwarecontext.GuestClusterContext{
 		ClusterContext: clusterContext,
 		GuestClient:    guestClient,
-	})
+	}
+
+	// Remove the target secrets from the target cluster, instead of syncing
+	// them, once the deletion of the cluster has started.
+	if isGuestClusterBeingDeleted(clusterContext) {
+		r.guestWriteThrottle.forget(clusterKey)
+		return r.reconcileGuestClusterDelete(guestClusterContext)
+	}
+
+	// Reduce the write frequency to the target cluster while its control plane
+	// is being upgraded.
+	if result, throttled := throttleGuestWrites(clusterContext, r.guestWriteThrottle); throttled {
+		return result, nil
+	}
+
+	// Defer to the Reconciler for reconciling a non-delete event.
+	return r.ReconcileNormal(guestClusterContext)
 }
 
 func (r ServiceAccountReconciler) ReconcileDelete(ctx *vmwarecontext.ClusterContext) (reconcile.Result, error) {
@@ -202,12 +299,66 @@ func (r ServiceAccountReconciler) ReconcileDelete(ctx *vmwarecontext.ClusterCont
 	}
 
 	for _, pSvcAccount := range pSvcAccounts {
+		// The serviceaccount of a provider serviceaccount with a cluster
+		// selector is shared by the clusters it selects, and must stay mapped
+		// while other clusters use it.
+		if selected, err := selectsOtherClusters(ctx, &pSvcAccount); err != nil || selected {
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			continue
+		}
 		// Delete entries for configmap with serviceaccount
-		if err := r.deleteServiceAccountConfigMap(ctx, pSvcAccount); err != nil {
+		if err := r.unregisterSystemServiceAccount(ctx, pSvcAccount); err != nil {
 			return reconcile.Result{}, errors.Wrapf(err, "unable to delete configmap entry for provider serviceaccount %s", pSvcAccount.Name)
 		}
 	}
+	if err := r.syncSystemServiceAccountStatus(ctx, nil); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "unable to update the status of the system service account registry")
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// reconcileGuestClusterDelete deletes the target secrets of the provider
+// serviceaccounts from the target cluster, as well as the target namespaces
+// created for them.
+func (r ServiceAccountReconciler) reconcileGuestClusterDelete(ctx *vmwarecontext.GuestClusterContext) (reconcile.Result, error) {
+	ctx.Logger.V(4).Info("Reconciling deleting Provider ServiceAccounts from guest cluster", "cluster", ctx.VSphereCluster.Name)
+
+	pSvcAccounts, err := getProviderServiceAccounts(ctx.ClusterContext)
+	if err != nil {
+		ctx.Logger.Error(err, "Error fetching provider serviceaccounts")
+		return reconcile.Result{}, err
+	}
+
+	var objs []client.Object
+	targetNamespaces := map[string]bool{}
+	for _, pSvcAccount := range pSvcAccounts {
+		objs = append(objs, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pSvcAccount.Spec.TargetSecretName,
+				Namespace: pSvcAccount.Spec.TargetNamespace,
+			},
+		})
+		targetNamespaces[pSvcAccount.Spec.TargetNamespace] = true
+	}
+
+	// Only delete the target namespaces created by this controller, the other
+	// ones may hold objects which do not belong to the provider serviceaccounts.
+	var namespaceList corev1.NamespaceList
+	if err := ctx.GuestClient.List(ctx, &namespaceList, client.HasLabels{vmwarev1.ProviderServiceAccountNamespaceLabel}); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "unable to list target namespaces")
+	}
+	for i := range namespaceList.Items {
+		if targetNamespaces[namespaceList.Items[i].Name] {
+			objs = append(objs, &namespaceList.Items[i])
+		}
+	}
 
+	if err := deleteGuestObjects(ctx, objs...); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "unable to delete provider serviceaccount objects from guest cluster")
+	}
 	return reconcile.Result{}, nil
 }
 
@@ -227,43 +378,65 @@ func (r ServiceAccountReconciler) ReconcileNormal(ctx *vmwarecontext.GuestCluste
 		ctx.Logger.Error(err, "Error fetching provider serviceaccounts")
 		return reconcile.Result{}, err
 	}
-	err = r.ensureProviderServiceAccounts(ctx, pSvcAccounts)
+	rotateAfter, err := r.ensureProviderServiceAccounts(ctx, pSvcAccounts)
 	if err != nil {
 		ctx.Logger.Error(err, "Error ensuring provider serviceaccounts")
 		return reconcile.Result{}, err
 	}
+	if err := removeUnselectedTargetSecrets(ctx, pSvcAccounts); err != nil {
+		ctx.Logger.Error(err, "Error removing the target secrets of unselected provider serviceaccounts")
+		return reconcile.Result{}, err
+	}
+	if err := r.syncSystemServiceAccountStatus(ctx.ClusterContext, pSvcAccounts); err != nil {
+		ctx.Logger.Error(err, "Error updating the status of the system service account registry")
+		return reconcile.Result{}, err
+	}
 
-	return reconcile.Result{}, nil
+	// Requeue to rotate the first projected token to expire.
+	return reconcile.Result{RequeueAfter: rotateAfter}, nil
 }
 
-// Ensure service accounts from provider spec is created.
-func (r ServiceAccountReconciler) ensureProviderServiceAccounts(ctx *vmwarecontext.GuestClusterContext, pSvcAccounts []vmwarev1.ProviderServiceAccount) error {
+// Ensure service accounts from provider spec is created. It returns the
+// duration after which the first projected token must be rotated, if any.
+func (r ServiceAccountReconciler) ensureProviderServiceAccounts(ctx *vmwarecontext.GuestClusterContext, pSvcAccounts []vmwarev1.ProviderServiceAccount) (time.Duration, error) {
+	var rotateAfter time.Duration
 	for _, pSvcAccount := range pSvcAccounts {
 		// 1. Create service accounts by the name specified in Provider Spec
 		if err := r.ensureServiceAccount(ctx.ClusterContext, pSvcAccount); err != nil {
-			return errors.Wrapf(err, "unable to create provider serviceaccount %s", pSvcAccount.Name)
+			return 0, errors.Wrapf(err, "unable to create provider serviceaccount %s", pSvcAccount.Name)
 		}
 		// 2. Update configmap with serviceaccount
-		if err := r.ensureServiceAccountConfigMap(ctx.ClusterContext, pSvcAccount); err != nil {
-			return errors.Wrapf(err, "unable to sync configmap for provider serviceaccount %s", pSvcAccount.Name)
+		if err := r.registerSystemServiceAccount(ctx.ClusterContext, pSvcAccount); err != nil {
+			return 0, errors.Wrapf(err, "unable to sync configmap for provider serviceaccount %s", pSvcAccount.Name)
 		}
 
 		// 3. Create the associated role for the service account
 		if err := r.ensureRole(ctx.ClusterContext, pSvcAccount); err != nil {
-			return errors.Wrapf(err, "unable to create role for provider serviceaccount %s", pSvcAccount.Name)
+			return 0, errors.Wrapf(err, "unable to create role for provider serviceaccount %s", pSvcAccount.Name)
 		}
 
 		// 4. Create the associated roleBinding for the service account
 		if err := r.ensureRoleBinding(ctx.ClusterContext, pSvcAccount); err != nil {
-			return errors.Wrapf(err, "unable to create rolebinding for provider serviceaccount %s", pSvcAccount.Name)
+			return 0, errors.Wrapf(err, "unable to create rolebinding for provider serviceaccount %s", pSvcAccount.Name)
 		}
 
-		// 5. Sync the service account with the target
-		if err := r.syncServiceAccountSecret(ctx, pSvcAccount); err != nil {
-			return errors.Wrapf(err, "unable to sync secret for provider serviceaccount %s", pSvcAccount.Name)
+		// 5. Sync the service account with the target, either its long-lived
+		// token or a projected token.
+		if pSvcAccount.Spec.TokenProjection == nil {
+			if err := r.syncServiceAccountSecret(ctx, pSvcAccount); err != nil {
+				return 0, errors.Wrapf(err, "unable to sync secret for provider serviceaccount %s", pSvcAccount.Name)
+			}
+			continue
+		}
+		after, err := r.syncProjectedToken(ctx, pSvcAccount)
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to sync projected token for provider serviceaccount %s", pSvcAccount.Name)
+		}
+		if rotateAfter == 0 || after < rotateAfter {
+			rotateAfter = after
 		}
 	}
-	return nil
+	return rotateAfter, nil
 }
 
 func (r ServiceAccountReconciler) ensureServiceAccount(ctx *vmwarecontext.ClusterContext, pSvcAccount vmwarev1.ProviderServiceAccount) error {
@@ -271,6 +444,7 @@ func (r ServiceAccountReconciler) ensureServiceAccount(ctx *vmwarecontext.Cluste
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      getServiceAccountName(pSvcAccount),
 			Namespace: pSvcAccount.Namespace,
+			Labels:    map[string]string{vmwarev1.ProviderServiceAccountLabel: pSvcAccount.Name},
 		},
 	}
 	logger := ctx.Logger.WithValues("providerserviceaccount", pSvcAccount.Name, "serviceaccount", svcAccount.Name)
@@ -301,6 +475,7 @@ func (r ServiceAccountReconciler) ensureRole(ctx *vmwarecontext.ClusterContext,
 		if err := controllerutil.SetControllerReference(&pSvcAccount, &role, ctx.Scheme); err != nil {
 			return err
 		}
+		setProviderServiceAccountLabel(&role, pSvcAccount)
 		role.Rules = pSvcAccount.Spec.Rules
 		return nil
 	})
@@ -322,6 +497,7 @@ func (r ServiceAccountReconciler) ensureRoleBinding(ctx *vmwarecontext.ClusterCo
 		if err := controllerutil.SetControllerReference(&pSvcAccount, &roleBinding, ctx.Scheme); err != nil {
 			return err
 		}
+		setProviderServiceAccountLabel(&roleBinding, pSvcAccount)
 		roleBinding.RoleRef = rbacv1.RoleRef{
 			Name:     roleName,
 			Kind:     "Role",
@@ -365,22 +541,8 @@ func (r ServiceAccountReconciler) syncServiceAccountSecret(ctx *vmwarecontext.Gu
 		return err
 	}
 
-	// Create the target namespace if it is not existing
-	targetNamespace := &corev1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: pSvcAccount.Spec.TargetNamespace,
-		},
-	}
-
-	if err = ctx.GuestClient.Get(ctx, client.ObjectKey{Name: pSvcAccount.Spec.TargetNamespace}, targetNamespace); err != nil {
-		if apierrors.IsNotFound(err) {
-			err = ctx.GuestClient.Create(ctx, targetNamespace)
-			if err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
+	if err := ensureTargetNamespace(ctx, pSvcAccount); err != nil {
+		return err
 	}
 
 	targetSecret := &corev1.Secret{
@@ -391,12 +553,197 @@ func (r ServiceAccountReconciler) syncServiceAccountSecret(ctx *vmwarecontext.Gu
 	}
 	logger.V(4).Info("Creating or updating secret in cluster", "namespace", targetSecret.Namespace, "name", targetSecret.Name)
 	_, err = controllerutil.CreateOrUpdate(ctx, ctx.GuestClient, targetSecret, func() error {
+		setTargetLabel(targetSecret, pSvcAccount)
 		targetSecret.Data = sourceSecret.Data
 		return nil
 	})
 	return err
 }
 
+// syncProjectedToken syncs a bound token of the service account, requested
+// for the audiences of the token projection, to the target secret, and
+// injects the tokens of their own audiences in the pods of the workloads
+// consuming it. It returns the duration after which the first token must be
+// rotated.
+func (r ServiceAccountReconciler) syncProjectedToken(ctx *vmwarecontext.GuestClusterContext, pSvcAccount vmwarev1.ProviderServiceAccount) (time.Duration, error) {
+	if err := ensureTargetNamespace(ctx, pSvcAccount); err != nil {
+		return 0, err
+	}
+	targetSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pSvcAccount.Spec.TargetSecretName,
+			Namespace: pSvcAccount.Spec.TargetNamespace,
+		},
+	}
+	rotateAfter, err := r.syncTokenSecret(ctx, pSvcAccount, targetSecret, pSvcAccount.Spec.TokenProjection.Audiences)
+	if err != nil {
+		return 0, err
+	}
+	after, err := r.injectProjectedTokens(ctx, pSvcAccount)
+	if err != nil {
+		return 0, err
+	}
+	if after != 0 && after < rotateAfter {
+		rotateAfter = after
+	}
+	return rotateAfter, nil
+}
+
+// syncTokenSecret syncs a bound token of the service account, requested for
+// the given audiences, to a secret of the guest cluster. The token is only
+// requested again once 80% of its duration of validity has elapsed, or when
+// the audiences change. It returns the duration after which the token must
+// be rotated.
+func (r ServiceAccountReconciler) syncTokenSecret(ctx *vmwarecontext.GuestClusterContext, pSvcAccount vmwarev1.ProviderServiceAccount, secret *corev1.Secret, audiences []string) (time.Duration, error) {
+	logger := ctx.Logger.WithValues("providerserviceaccount", pSvcAccount.Name, "namespace", secret.Namespace, "name", secret.Name)
+	expirationSeconds := vmwarev1.DefaultTokenExpirationSeconds
+	if pSvcAccount.Spec.TokenProjection.ExpirationSeconds != nil {
+		expirationSeconds = *pSvcAccount.Spec.TokenProjection.ExpirationSeconds
+	}
+	audiencesValue := strings.Join(audiences, ",")
+
+	current := &corev1.Secret{}
+	if err := ctx.GuestClient.Get(ctx, client.ObjectKeyFromObject(secret), current); err != nil && !apierrors.IsNotFound(err) {
+		return 0, err
+	}
+	if current.Annotations[vmwarev1.ProviderServiceAccountTokenAudiencesAnnotation] == audiencesValue {
+		if expiration, err := time.Parse(time.RFC3339, current.Annotations[vmwarev1.ProviderServiceAccountTokenExpirationAnnotation]); err == nil {
+			if time.Until(tokenRotationTime(expiration, expirationSeconds)) > 0 {
+				return tokenRotateAfter(expiration, expirationSeconds), nil
+			}
+		}
+	}
+
+	logger.Info("Requesting projected token for provider service account", "audiences", audiencesValue)
+	tokenRequest, err := r.tokenClient.ServiceAccounts(pSvcAccount.Namespace).CreateToken(ctx, getServiceAccountName(pSvcAccount), &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to request token")
+	}
+
+	// The CA certificate of the supervisor is published in every namespace.
+	rootCA := &corev1.ConfigMap{}
+	if err := ctx.Client.Get(ctx, client.ObjectKey{Namespace: pSvcAccount.Namespace, Name: rootCAConfigMapName}, rootCA); err != nil && !apierrors.IsNotFound(err) {
+		return 0, err
+	}
+
+	logger.V(4).Info("Creating or updating secret in cluster")
+	labels, ownerReferences := secret.Labels, secret.OwnerReferences
+	_, err = controllerutil.CreateOrUpdate(ctx, ctx.GuestClient, secret, func() error {
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[vmwarev1.ProviderServiceAccountTokenAudiencesAnnotation] = audiencesValue
+		secret.Annotations[vmwarev1.ProviderServiceAccountTokenExpirationAnnotation] = tokenRequest.Status.ExpirationTimestamp.UTC().Format(time.RFC3339)
+		if len(labels) > 0 && secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+		for k, v := range labels {
+			secret.Labels[k] = v
+		}
+		if len(ownerReferences) > 0 {
+			secret.OwnerReferences = ownerReferences
+		}
+		setTargetLabel(secret, pSvcAccount)
+		secret.Data = map[string][]byte{
+			corev1.ServiceAccountTokenKey:     []byte(tokenRequest.Status.Token),
+			corev1.ServiceAccountNamespaceKey: []byte(pSvcAccount.Namespace),
+		}
+		if caCrt, ok := rootCA.Data[corev1.ServiceAccountRootCAKey]; ok {
+			secret.Data[corev1.ServiceAccountRootCAKey] = []byte(caCrt)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return tokenRotateAfter(tokenRequest.Status.ExpirationTimestamp.Time, expirationSeconds), nil
+}
+
+// tokenRotationTime returns the time at which a projected token expiring at
+// the given time must be rotated, once 80% of its duration of validity has
+// elapsed.
+func tokenRotationTime(expiration time.Time, expirationSeconds int64) time.Time {
+	return expiration.Add(-time.Duration(expirationSeconds) * time.Second / 5)
+}
+
+// tokenRotateAfter returns the duration after which a projected token
+// expiring at the given time must be rotated, which is at least
+// minTokenRotateAfter, so that a token issued for less than it was requested
+// for is still rotated.
+func tokenRotateAfter(expiration time.Time, expirationSeconds int64) time.Duration {
+	if after := time.Until(tokenRotationTime(expiration, expirationSeconds)); after > minTokenRotateAfter {
+		return after
+	}
+	return minTokenRotateAfter
+}
+
+// ensureTargetNamespace creates the target namespace of the provider
+// serviceaccount if it is not existing, and labels it so it is deleted with
+// the cluster. An existing target namespace is labelled when it was created
+// by a former version of this controller, see isFormerTargetNamespace.
+func ensureTargetNamespace(ctx *vmwarecontext.GuestClusterContext, pSvcAccount vmwarev1.ProviderServiceAccount) error {
+	targetNamespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: pSvcAccount.Spec.TargetNamespace,
+			Labels: map[string]string{
+				vmwarev1.ProviderServiceAccountNamespaceLabel: "true",
+			},
+		},
+	}
+
+	if err := ctx.GuestClient.Get(ctx, client.ObjectKey{Name: pSvcAccount.Spec.TargetNamespace}, targetNamespace); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctx.GuestClient.Create(ctx, targetNamespace)
+		}
+		return err
+	}
+	if _, ok := targetNamespace.Labels[vmwarev1.ProviderServiceAccountNamespaceLabel]; ok {
+		return nil
+	}
+
+	targetSecret := &corev1.Secret{}
+	if err := ctx.GuestClient.Get(ctx, client.ObjectKey{Namespace: targetNamespace.Name, Name: pSvcAccount.Spec.TargetSecretName}, targetSecret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !isFormerTargetNamespace(targetNamespace, targetSecret) {
+		return nil
+	}
+
+	ctx.Logger.Info("Labelling target namespace created by a former version", "namespace", targetNamespace.Name)
+	patch := client.MergeFrom(targetNamespace.DeepCopy())
+	if targetNamespace.Labels == nil {
+		targetNamespace.Labels = map[string]string{}
+	}
+	targetNamespace.Labels[vmwarev1.ProviderServiceAccountNamespaceLabel] = "true"
+	return ctx.GuestClient.Patch(ctx, targetNamespace, patch)
+}
+
+// isFormerTargetNamespace returns whether an existing target namespace was
+// created by a former version of this controller, which did not label the
+// target namespaces it created. Such a namespace was created right before
+// its target secret, in the same reconciliation, whereas a namespace which
+// already existed, e.g. kube-system, was created before the guest cluster
+// was registered with the supervisor. The default and kube-* namespaces are
+// never labelled, nor the namespaces whose creation time is unknown.
+func isFormerTargetNamespace(namespace *corev1.Namespace, targetSecret *corev1.Secret) bool {
+	if namespace.Name == metav1.NamespaceDefault || strings.HasPrefix(namespace.Name, "kube-") {
+		return false
+	}
+	if namespace.CreationTimestamp.IsZero() || targetSecret.CreationTimestamp.IsZero() {
+		return false
+	}
+	created := targetSecret.CreationTimestamp.Sub(namespace.CreationTimestamp.Time)
+	return created >= 0 && created <= formerTargetNamespaceWindow
+}
+
 func (r ServiceAccountReconciler) getConfigMapAndBuffer(ctx *vmwarecontext.ClusterContext) (*corev1.ConfigMap, *corev1.ConfigMap, error) {
 	configMap := &corev1.ConfigMap{}
 
@@ -453,7 +800,11 @@ func (r ServiceAccountReconciler) ensureServiceAccountConfigMap(ctx *vmwareconte
 }
 
 func getProviderServiceAccounts(ctx *vmwarecontext.ClusterContext) ([]vmwarev1.ProviderServiceAccount, error) {
-	var pSvcAccounts []vmwarev1.ProviderServiceAccount
+	var (
+		pSvcAccounts []vmwarev1.ProviderServiceAccount
+		cluster      *clusterv1.Cluster
+		err          error
+	)
 
 	pSvcAccountList := vmwarev1.ProviderServiceAccountList{}
 	if err := ctx.Client.List(ctx, &pSvcAccountList, client.InNamespace(ctx.VSphereCluster.Namespace)); err != nil {
@@ -470,11 +821,39 @@ func getProviderServiceAccounts(ctx *vmwarecontext.ClusterContext) ([]vmwarev1.P
 		ref := pSvcAccount.Spec.Ref
 		if ref != nil && ref.Name == ctx.VSphereCluster.Name {
 			pSvcAccounts = append(pSvcAccounts, pSvcAccount)
+			continue
+		}
+		if pSvcAccount.Spec.ClusterSelector == nil {
+			continue
+		}
+		// The Cluster is only fetched for the provider serviceaccounts with a
+		// cluster selector, which match against its labels.
+		if cluster == nil {
+			if cluster, err = clusterutilv1.GetOwnerCluster(ctx, ctx.Client, ctx.VSphereCluster.ObjectMeta); err != nil {
+				return nil, err
+			}
+			if cluster == nil {
+				continue
+			}
+		}
+		if selectsCluster(&pSvcAccount, ctx.VSphereCluster, cluster) {
+			pSvcAccounts = append(pSvcAccounts, pSvcAccount)
 		}
 	}
 	return pSvcAccounts, nil
 }
 
+// setProviderServiceAccountLabel labels an object created in the supervisor
+// for a provider serviceaccount with its name.
+func setProviderServiceAccountLabel(obj metav1.Object, pSvcAccount vmwarev1.ProviderServiceAccount) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[vmwarev1.ProviderServiceAccountLabel] = pSvcAccount.Name
+	obj.SetLabels(labels)
+}
+
 func getRoleName(pSvcAccount vmwarev1.ProviderServiceAccount) string {
 	return pSvcAccount.Name
 }
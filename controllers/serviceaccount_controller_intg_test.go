@@ -57,7 +57,7 @@ var _ = Describe("ServiceAccount controller integration tests", func() {
 		BeforeEach(func() {
 			pSvcAccount = getTestProviderServiceAccount(intCtx.Namespace, testProviderSvcAccountName, intCtx.VSphereCluster)
 			createTestResource(intCtx, intCtx.Client, pSvcAccount)
-			assertEventuallyExistsInNamespace(intCtx, intCtx.Client, intCtx.Namespace, testProviderSvcAccountName, pSvcAccount)
+			builder.AssertEventuallyExistsInNamespace(intCtx, intCtx.Client, intCtx.Namespace, testProviderSvcAccountName, pSvcAccount)
 		})
 		AfterEach(func() {
 			// Deleting the provider service account is not strictly required as the context itself gets teared down but
@@ -17,11 +17,20 @@ limitations under the License.
 package controllers
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
@@ -50,8 +59,8 @@ func unitTestsReconcileNormal() {
 	Context("When no provider service account is available", func() {
 		It("Should reconcile", func() {
 			By("Not creating any entities")
-			assertNoEntities(ctx, ctx.Client, testNS)
-			assertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
+			builder.AssertNoServiceAccountEntities(ctx, ctx.Client, testNS)
+			builder.AssertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
 		})
 	})
 
@@ -69,12 +78,12 @@ func unitTestsReconcileNormal() {
 		})
 		Context("When serviceaccount secret is created", func() {
 			It("Should reconcile", func() {
-				assertTargetNamespace(ctx, ctx.GuestClient, testTargetNS, false)
+				builder.AssertTargetNamespace(ctx, ctx.GuestClient, testTargetNS, false)
 				updateServiceAccountSecretAndReconcileNormal(ctx)
-				assertTargetNamespace(ctx, ctx.GuestClient, testTargetNS, true)
+				builder.AssertTargetNamespace(ctx, ctx.GuestClient, testTargetNS, true)
 				By("Creating the target secret in the target namespace")
 				assertTargetSecret(ctx, ctx.GuestClient, testTargetNS, testTargetSecret)
-				assertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
+				builder.AssertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
 			})
 		})
 		Context("When serviceaccount secret is modified", func() {
@@ -84,7 +93,18 @@ func unitTestsReconcileNormal() {
 				updateServiceAccountSecretAndReconcileNormal(ctx)
 				By("Updating the target secret in the target namespace")
 				assertTargetSecret(ctx, ctx.GuestClient, testTargetNS, testTargetSecret)
-				assertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
+				builder.AssertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
+			})
+		})
+		Context("When the serviceaccount token is rotated", func() {
+			It("Should update the target secret", func() {
+				updateServiceAccountSecretAndReconcileNormal(ctx)
+				assertTargetSecret(ctx, ctx.GuestClient, testTargetNS, testTargetSecret)
+				builder.RotateServiceAccountToken(ctx, ctx.Client, testNS, testSvcAccountSecretName, testRotatedSecretToken)
+				Expect(ctx.ReconcileNormal()).Should(Succeed())
+				By("Updating the target secret with the rotated token")
+				builder.AssertTargetSecret(ctx, ctx.GuestClient, testTargetNS, testTargetSecret, testRotatedSecretToken)
+				builder.AssertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
 			})
 		})
 		Context("When invalid role exists", func() {
@@ -93,7 +113,7 @@ func unitTestsReconcileNormal() {
 			})
 			It("Should update role", func() {
 				assertRoleWithGetPVC(ctx, ctx.Client, testNS, testRoleName)
-				assertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
+				builder.AssertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
 			})
 		})
 		Context("When invalid rolebinding exists", func() {
@@ -102,7 +122,171 @@ func unitTestsReconcileNormal() {
 			})
 			It("Should update rolebinding", func() {
 				assertRoleBinding(ctx, ctx.Client, testNS, testRoleBindingName)
-				assertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
+				builder.AssertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
+			})
+		})
+		Context("When the ProviderServiceAccount has a token projection", func() {
+			var (
+				tokenClient *k8sfake.Clientset
+				requests    []authenticationv1.TokenRequestSpec
+			)
+			setTokenProjection := func(audiences ...string) {
+				pSvcAccount := &vmwarev1.ProviderServiceAccount{}
+				Expect(ctx.Client.Get(ctx, client.ObjectKey{Namespace: testNS, Name: testProviderSvcAccountName}, pSvcAccount)).To(Succeed())
+				pSvcAccount.Spec.TokenProjection = &vmwarev1.TokenProjection{Audiences: audiences}
+				Expect(ctx.Client.Update(ctx, pSvcAccount)).To(Succeed())
+			}
+			BeforeEach(func() {
+				initObjects = append(initObjects, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNS, Name: rootCAConfigMapName},
+					Data:       map[string]string{corev1.ServiceAccountRootCAKey: "ca"},
+				})
+				requests = nil
+				tokenClient = k8sfake.NewSimpleClientset()
+				tokenClient.PrependReactor("create", "serviceaccounts", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					if action.GetSubresource() != "token" {
+						return false, nil, nil
+					}
+					tokenRequest := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenRequest).DeepCopy()
+					requests = append(requests, tokenRequest.Spec)
+					tokenRequest.Status = authenticationv1.TokenRequestStatus{
+						Token:               fmt.Sprintf("projected-token-%d", len(requests)),
+						ExpirationTimestamp: metav1.NewTime(time.Now().Add(time.Duration(*tokenRequest.Spec.ExpirationSeconds) * time.Second)),
+					}
+					return true, tokenRequest, nil
+				})
+			})
+			JustBeforeEach(func() {
+				ctx.Reconciler = ServiceAccountReconciler{tokenClient: tokenClient.CoreV1()}
+				setTokenProjection("vsphere-csi")
+			})
+			It("Should sync a projected token instead of the serviceaccount secret", func() {
+				result, err := ctx.Reconciler.ReconcileNormal(ctx.GuestClusterContext)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(requests).To(HaveLen(1))
+				Expect(requests[0].Audiences).To(Equal([]string{"vsphere-csi"}))
+				Expect(*requests[0].ExpirationSeconds).To(Equal(vmwarev1.DefaultTokenExpirationSeconds))
+				By("Requeueing to rotate the token once 80% of its duration of validity has elapsed")
+				Expect(result.RequeueAfter).To(BeNumerically("~", 48*time.Minute, time.Minute))
+
+				secret := &corev1.Secret{}
+				Expect(ctx.GuestClient.Get(ctx, client.ObjectKey{Namespace: testTargetNS, Name: testTargetSecret}, secret)).To(Succeed())
+				Expect(secret.Data).To(Equal(map[string][]byte{
+					corev1.ServiceAccountTokenKey:     []byte("projected-token-1"),
+					corev1.ServiceAccountNamespaceKey: []byte(testNS),
+					corev1.ServiceAccountRootCAKey:    []byte("ca"),
+				}))
+				Expect(secret.Annotations).To(HaveKeyWithValue(vmwarev1.ProviderServiceAccountTokenAudiencesAnnotation, "vsphere-csi"))
+				Expect(secret.Annotations).To(HaveKey(vmwarev1.ProviderServiceAccountTokenExpirationAnnotation))
+				builder.AssertProviderServiceAccountsCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
+
+				By("Keeping the token until it must be rotated")
+				Expect(ctx.ReconcileNormal()).To(Succeed())
+				Expect(requests).To(HaveLen(1))
+			})
+			It("Should request a new token when the audiences change", func() {
+				Expect(ctx.ReconcileNormal()).To(Succeed())
+				setTokenProjection("vsphere-csi", "vsphere-cpi")
+
+				Expect(ctx.ReconcileNormal()).To(Succeed())
+				Expect(requests).To(HaveLen(2))
+				builder.AssertTargetSecret(ctx, ctx.GuestClient, testTargetNS, testTargetSecret, "projected-token-2")
+			})
+			It("Should requeue after a positive duration when the token is issued for less than requested", func() {
+				tokenClient.PrependReactor("create", "serviceaccounts", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					tokenRequest := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenRequest).DeepCopy()
+					requests = append(requests, tokenRequest.Spec)
+					tokenRequest.Status = authenticationv1.TokenRequestStatus{
+						Token:               "short-lived-token",
+						ExpirationTimestamp: metav1.NewTime(time.Now().Add(5 * time.Minute)),
+					}
+					return true, tokenRequest, nil
+				})
+				result, err := ctx.Reconciler.ReconcileNormal(ctx.GuestClusterContext)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(Equal(minTokenRotateAfter))
+			})
+			Context("When workloads consume the projected token", func() {
+				JustBeforeEach(func() {
+					for _, obj := range []client.Object{
+						&appsv1.Deployment{
+							ObjectMeta: metav1.ObjectMeta{
+								Namespace: testTargetNS,
+								Name:      "csi-controller",
+								Labels:    map[string]string{vmwarev1.ProviderServiceAccountInjectLabel: testProviderSvcAccountName},
+								Annotations: map[string]string{
+									vmwarev1.ProviderServiceAccountInjectAudiencesAnnotation: "vsphere-cpi",
+								},
+							},
+							Spec: appsv1.DeploymentSpec{
+								Template: corev1.PodTemplateSpec{
+									Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "csi"}, {Name: "sidecar"}}},
+								},
+							},
+						},
+						&appsv1.DaemonSet{
+							ObjectMeta: metav1.ObjectMeta{
+								Namespace: testTargetNS,
+								Name:      "csi-node",
+								Labels:    map[string]string{vmwarev1.ProviderServiceAccountInjectLabel: testProviderSvcAccountName},
+								Annotations: map[string]string{
+									vmwarev1.ProviderServiceAccountInjectAudiencesAnnotation: "vsphere-other",
+								},
+							},
+							Spec: appsv1.DaemonSetSpec{
+								Template: corev1.PodTemplateSpec{
+									Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "csi"}}},
+								},
+							},
+						},
+					} {
+						createTestResource(ctx, ctx.GuestClient, obj)
+					}
+				})
+				It("Should inject a token of their own audiences in their pods", func() {
+					setTokenProjection("vsphere-csi", "vsphere-cpi")
+					Expect(ctx.ReconcileNormal()).To(Succeed())
+
+					By("Syncing a token of the audiences of the workload to a secret it owns")
+					Expect(requests).To(HaveLen(2))
+					Expect(requests[1].Audiences).To(Equal([]string{"vsphere-cpi"}))
+					secretName := testTargetSecret + "-deployment-csi-controller"
+					builder.AssertTargetSecret(ctx, ctx.GuestClient, testTargetNS, secretName, "projected-token-2")
+					secret := &corev1.Secret{}
+					Expect(ctx.GuestClient.Get(ctx, client.ObjectKey{Namespace: testTargetNS, Name: secretName}, secret)).To(Succeed())
+					Expect(secret.OwnerReferences).To(HaveLen(1))
+					Expect(secret.OwnerReferences[0].Name).To(Equal("csi-controller"))
+
+					By("Mounting the secret in all the containers of the pods")
+					deployment := &appsv1.Deployment{}
+					Expect(ctx.GuestClient.Get(ctx, client.ObjectKey{Namespace: testTargetNS, Name: "csi-controller"}, deployment)).To(Succeed())
+					podSpec := deployment.Spec.Template.Spec
+					Expect(podSpec.Volumes).To(HaveLen(1))
+					Expect(podSpec.Volumes[0].Projected.Sources[0].Secret.Name).To(Equal(secretName))
+					for _, container := range podSpec.Containers {
+						Expect(container.VolumeMounts).To(ConsistOf(corev1.VolumeMount{
+							Name:      podSpec.Volumes[0].Name,
+							MountPath: injectedTokenMountPath + "/" + testProviderSvcAccountName,
+							ReadOnly:  true,
+						}))
+					}
+
+					By("Not injecting the audiences which are not granted")
+					daemonSet := &appsv1.DaemonSet{}
+					Expect(ctx.GuestClient.Get(ctx, client.ObjectKey{Namespace: testTargetNS, Name: "csi-node"}, daemonSet)).To(Succeed())
+					Expect(daemonSet.Spec.Template.Spec.Volumes).To(BeEmpty())
+
+					By("Keeping the injected token until it must be rotated")
+					Expect(ctx.ReconcileNormal()).To(Succeed())
+					Expect(requests).To(HaveLen(2))
+
+					By("Removing the secret once the workload no longer consumes the token")
+					deployment.Labels = nil
+					Expect(ctx.GuestClient.Update(ctx, deployment)).To(Succeed())
+					Expect(ctx.ReconcileNormal()).To(Succeed())
+					err := ctx.GuestClient.Get(ctx, client.ObjectKey{Namespace: testTargetNS, Name: secretName}, secret)
+					Expect(apierrors.IsNotFound(err)).To(BeTrue())
+				})
 			})
 		})
 	})
@@ -114,3 +298,127 @@ func updateServiceAccountSecretAndReconcileNormal(ctx *builder.UnitTestContextFo
 	assertServiceAccountAndUpdateSecret(ctx, ctx.Client, testNS, testSvcAccountName)
 	Expect(ctx.ReconcileNormal()).Should(Succeed())
 }
+
+var _ = Describe("ServiceAccountReconciler ReconcileDelete", unitTestsReconcileDelete)
+
+func unitTestsReconcileDelete() {
+	var (
+		ctx            *builder.UnitTestContextForController
+		vsphereCluster *vmwarev1.VSphereCluster
+		pSvcAccount    *vmwarev1.ProviderServiceAccount
+	)
+
+	BeforeEach(func() {
+		obj := fake.NewVSphereCluster()
+		vsphereCluster = &obj
+		vsphereCluster.Namespace = testNS
+		_ = os.Setenv("SERVICE_ACCOUNTS_CM_NAMESPACE", testSystemSvcAcctNs)
+		_ = os.Setenv("SERVICE_ACCOUNTS_CM_NAME", testSystemSvcAcctCM)
+		pSvcAccount = getTestProviderServiceAccount(testNS, testProviderSvcAccountName, vsphereCluster)
+	})
+	JustBeforeEach(func() {
+		ctx = ServiceAccountProviderTestsuite.NewUnitTestContextForControllerWithVSphereCluster(vsphereCluster, false,
+			getSystemServiceAccountsConfigMap(testSystemSvcAcctNs, testSystemSvcAcctCM),
+			pSvcAccount.DeepCopy())
+	})
+	AfterEach(func() {
+		ctx = nil
+	})
+
+	Context("When the cluster is deleted", func() {
+		It("Should unregister the serviceaccount from the system service accounts", func() {
+			updateServiceAccountSecretAndReconcileNormal(ctx)
+			builder.AssertSystemServiceAccountRegistered(ctx, ctx.Client, testSystemSvcAcctNs, testSystemSvcAcctCM, pSvcAccount, true)
+			Expect(ctx.ReconcileDelete()).Should(Succeed())
+			By("Removing the serviceaccount from the system service accounts ConfigMap")
+			builder.AssertSystemServiceAccountRegistered(ctx, ctx.Client, testSystemSvcAcctNs, testSystemSvcAcctCM, pSvcAccount, false)
+		})
+	})
+	Context("When the serviceaccount is not registered", func() {
+		It("Should reconcile", func() {
+			Expect(ctx.ReconcileDelete()).Should(Succeed())
+			builder.AssertSystemServiceAccountRegistered(ctx, ctx.Client, testSystemSvcAcctNs, testSystemSvcAcctCM, pSvcAccount, false)
+		})
+	})
+}
+
+var _ = Describe("ServiceAccountReconciler reconcileGuestClusterDelete", unitTestsReconcileGuestClusterDelete)
+
+func unitTestsReconcileGuestClusterDelete() {
+	var (
+		ctx            *builder.UnitTestContextForController
+		vsphereCluster *vmwarev1.VSphereCluster
+		initObjects    []client.Object
+	)
+
+	BeforeEach(func() {
+		obj := fake.NewVSphereCluster()
+		vsphereCluster = &obj
+		vsphereCluster.Namespace = testNS
+		_ = os.Setenv("SERVICE_ACCOUNTS_CM_NAMESPACE", testSystemSvcAcctNs)
+		_ = os.Setenv("SERVICE_ACCOUNTS_CM_NAME", testSystemSvcAcctCM)
+		initObjects = []client.Object{
+			getSystemServiceAccountsConfigMap(testSystemSvcAcctNs, testSystemSvcAcctCM),
+			getTestProviderServiceAccount(testNS, testProviderSvcAccountName, vsphereCluster),
+		}
+	})
+	JustBeforeEach(func() {
+		ctx = ServiceAccountProviderTestsuite.NewUnitTestContextForControllerWithVSphereCluster(vsphereCluster, false, initObjects...)
+	})
+	AfterEach(func() {
+		ctx = nil
+	})
+
+	Context("When the target namespace is created by the controller", func() {
+		It("Should delete the target secret and namespace", func() {
+			updateServiceAccountSecretAndReconcileNormal(ctx)
+			assertTargetSecret(ctx, ctx.GuestClient, testTargetNS, testTargetSecret)
+			_, err := ServiceAccountReconciler{}.reconcileGuestClusterDelete(ctx.GuestClusterContext)
+			Expect(err).NotTo(HaveOccurred())
+			By("Deleting the target secret and namespace from the guest cluster")
+			Expect(ctx.GuestClient.Get(ctx, client.ObjectKey{Namespace: testTargetNS, Name: testTargetSecret}, &corev1.Secret{})).NotTo(Succeed())
+			builder.AssertTargetNamespace(ctx, ctx.GuestClient, testTargetNS, false)
+		})
+	})
+	Context("When the target namespace already exists", func() {
+		It("Should only delete the target secret", func() {
+			Expect(ctx.GuestClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testTargetNS}})).To(Succeed())
+			updateServiceAccountSecretAndReconcileNormal(ctx)
+			assertTargetSecret(ctx, ctx.GuestClient, testTargetNS, testTargetSecret)
+			_, err := ServiceAccountReconciler{}.reconcileGuestClusterDelete(ctx.GuestClusterContext)
+			Expect(err).NotTo(HaveOccurred())
+			By("Keeping the target namespace in the guest cluster")
+			Expect(ctx.GuestClient.Get(ctx, client.ObjectKey{Namespace: testTargetNS, Name: testTargetSecret}, &corev1.Secret{})).NotTo(Succeed())
+			builder.AssertTargetNamespace(ctx, ctx.GuestClient, testTargetNS, true)
+		})
+	})
+	Context("When the target namespace was created by a former version", func() {
+		It("Should label and delete the target namespace", func() {
+			created := metav1.NewTime(time.Now().Add(-time.Hour))
+			Expect(ctx.GuestClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testTargetNS, CreationTimestamp: created}})).To(Succeed())
+			Expect(ctx.GuestClient.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Namespace: testTargetNS, Name: testTargetSecret, CreationTimestamp: metav1.NewTime(created.Add(time.Second)),
+			}})).To(Succeed())
+			updateServiceAccountSecretAndReconcileNormal(ctx)
+			namespace := &corev1.Namespace{}
+			Expect(ctx.GuestClient.Get(ctx, client.ObjectKey{Name: testTargetNS}, namespace)).To(Succeed())
+			Expect(namespace.Labels).To(HaveKeyWithValue(vmwarev1.ProviderServiceAccountNamespaceLabel, "true"))
+			_, err := ServiceAccountReconciler{}.reconcileGuestClusterDelete(ctx.GuestClusterContext)
+			Expect(err).NotTo(HaveOccurred())
+			builder.AssertTargetNamespace(ctx, ctx.GuestClient, testTargetNS, false)
+		})
+	})
+	Context("When the target namespace existed before its target secret", func() {
+		It("Should keep the target namespace", func() {
+			created := metav1.NewTime(time.Now().Add(-time.Hour))
+			Expect(ctx.GuestClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testTargetNS, CreationTimestamp: created}})).To(Succeed())
+			Expect(ctx.GuestClient.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Namespace: testTargetNS, Name: testTargetSecret, CreationTimestamp: metav1.NewTime(created.Add(10 * time.Minute)),
+			}})).To(Succeed())
+			updateServiceAccountSecretAndReconcileNormal(ctx)
+			_, err := ServiceAccountReconciler{}.reconcileGuestClusterDelete(ctx.GuestClusterContext)
+			Expect(err).NotTo(HaveOccurred())
+			builder.AssertTargetNamespace(ctx, ctx.GuestClient, testTargetNS, true)
+		})
+	})
+}
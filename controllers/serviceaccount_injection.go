@@ -0,0 +1,244 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+)
+
+// injectedTokenMountPath is the directory in which the projected tokens are
+// mounted in the containers of the pods they are injected in, one
+// subdirectory per provider serviceaccount.
+const injectedTokenMountPath = "/var/run/secrets/vmware.infrastructure.cluster.x-k8s.io"
+
+// injectedWorkload is a workload of a guest cluster in whose pods a projected
+// token is injected.
+type injectedWorkload struct {
+	kind     string
+	obj      client.Object
+	template *corev1.PodTemplateSpec
+}
+
+// injectProjectedTokens injects a bound token of the service account in the
+// pods of the workloads of the target namespace labelled with its name, each
+// requested for the audiences of the workload, with a projected volume of a
+// secret owned by the workload. The secrets of the workloads which no longer
+// consume the token are removed. It returns the duration after which the
+// first token must be rotated, or zero if no token is injected.
+func (r ServiceAccountReconciler) injectProjectedTokens(ctx *vmwarecontext.GuestClusterContext, pSvcAccount vmwarev1.ProviderServiceAccount) (time.Duration, error) {
+	logger := ctx.Logger.WithValues("providerserviceaccount", pSvcAccount.Name)
+	workloads, err := listInjectedWorkloads(ctx, pSvcAccount)
+	if err != nil {
+		return 0, err
+	}
+
+	var rotateAfter time.Duration
+	injected := map[string]bool{}
+	for _, workload := range workloads {
+		audiences, err := injectedAudiences(workload.obj, pSvcAccount.Spec.TokenProjection)
+		if err != nil {
+			logger.Info("Not injecting the projected token", "kind", workload.kind, "name", workload.obj.GetName(), "reason", err.Error())
+			continue
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      injectedSecretName(pSvcAccount, workload),
+				Namespace: pSvcAccount.Spec.TargetNamespace,
+				Labels:    map[string]string{vmwarev1.ProviderServiceAccountInjectLabel: pSvcAccount.Name},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: appsv1.SchemeGroupVersion.String(),
+					Kind:       workload.kind,
+					Name:       workload.obj.GetName(),
+					UID:        workload.obj.GetUID(),
+				}},
+			},
+		}
+		injected[secret.Name] = true
+		after, err := r.syncTokenSecret(ctx, pSvcAccount, secret, audiences)
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to sync the projected token of %s %s", workload.kind, workload.obj.GetName())
+		}
+		if err := injectTokenVolume(ctx, pSvcAccount, workload, secret.Name); err != nil {
+			return 0, errors.Wrapf(err, "unable to inject the projected token in %s %s", workload.kind, workload.obj.GetName())
+		}
+		if rotateAfter == 0 || after < rotateAfter {
+			rotateAfter = after
+		}
+	}
+
+	secrets := &corev1.SecretList{}
+	if err := ctx.GuestClient.List(ctx, secrets, client.InNamespace(pSvcAccount.Spec.TargetNamespace),
+		client.MatchingLabels{vmwarev1.ProviderServiceAccountInjectLabel: pSvcAccount.Name}); err != nil {
+		return 0, errors.Wrap(err, "unable to list the secrets of the injected tokens")
+	}
+	var objs []client.Object
+	for i := range secrets.Items {
+		if !injected[secrets.Items[i].Name] {
+			objs = append(objs, &secrets.Items[i])
+		}
+	}
+	if err := deleteGuestObjects(ctx, objs...); err != nil {
+		return 0, err
+	}
+	return rotateAfter, nil
+}
+
+// listInjectedWorkloads lists the Deployments, StatefulSets and DaemonSets of
+// the target namespace labelled with the name of the provider serviceaccount.
+func listInjectedWorkloads(ctx *vmwarecontext.GuestClusterContext, pSvcAccount vmwarev1.ProviderServiceAccount) ([]injectedWorkload, error) {
+	opts := []client.ListOption{
+		client.InNamespace(pSvcAccount.Spec.TargetNamespace),
+		client.MatchingLabels{vmwarev1.ProviderServiceAccountInjectLabel: pSvcAccount.Name},
+	}
+	var workloads []injectedWorkload
+
+	deployments := &appsv1.DeploymentList{}
+	if err := ctx.GuestClient.List(ctx, deployments, opts...); err != nil {
+		return nil, errors.Wrap(err, "unable to list the deployments")
+	}
+	for i := range deployments.Items {
+		workloads = append(workloads, injectedWorkload{kind: "Deployment", obj: &deployments.Items[i], template: &deployments.Items[i].Spec.Template})
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := ctx.GuestClient.List(ctx, statefulSets, opts...); err != nil {
+		return nil, errors.Wrap(err, "unable to list the statefulsets")
+	}
+	for i := range statefulSets.Items {
+		workloads = append(workloads, injectedWorkload{kind: "StatefulSet", obj: &statefulSets.Items[i], template: &statefulSets.Items[i].Spec.Template})
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := ctx.GuestClient.List(ctx, daemonSets, opts...); err != nil {
+		return nil, errors.Wrap(err, "unable to list the daemonsets")
+	}
+	for i := range daemonSets.Items {
+		workloads = append(workloads, injectedWorkload{kind: "DaemonSet", obj: &daemonSets.Items[i], template: &daemonSets.Items[i].Spec.Template})
+	}
+	return workloads, nil
+}
+
+// injectedAudiences returns the audiences of the token injected in the pods
+// of a workload, which must be among the audiences of the token projection.
+func injectedAudiences(obj client.Object, projection *vmwarev1.TokenProjection) ([]string, error) {
+	value, ok := obj.GetAnnotations()[vmwarev1.ProviderServiceAccountInjectAudiencesAnnotation]
+	if !ok {
+		return projection.Audiences, nil
+	}
+	granted := map[string]bool{}
+	for _, audience := range projection.Audiences {
+		granted[audience] = true
+	}
+	var audiences []string
+	for _, audience := range strings.Split(value, ",") {
+		audience = strings.TrimSpace(audience)
+		if audience == "" {
+			continue
+		}
+		if !granted[audience] {
+			return nil, errors.Errorf("the audience %q is not among the audiences of the token projection", audience)
+		}
+		audiences = append(audiences, audience)
+	}
+	if len(audiences) == 0 {
+		return nil, errors.Errorf("no audience in the %s annotation", vmwarev1.ProviderServiceAccountInjectAudiencesAnnotation)
+	}
+	return audiences, nil
+}
+
+// injectedSecretName returns the name of the secret holding the token
+// injected in the pods of a workload.
+func injectedSecretName(pSvcAccount vmwarev1.ProviderServiceAccount, workload injectedWorkload) string {
+	return fmt.Sprintf("%s-%s-%s", pSvcAccount.Spec.TargetSecretName, strings.ToLower(workload.kind), workload.obj.GetName())
+}
+
+// injectedVolumeName returns the name of the volume of the token of a
+// provider serviceaccount in the pods it is injected in, which is a valid
+// DNS label whatever the length of the name of the provider serviceaccount.
+func injectedVolumeName(pSvcAccount vmwarev1.ProviderServiceAccount) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(pSvcAccount.Name))
+	return fmt.Sprintf("providerserviceaccount-%08x", h.Sum32())
+}
+
+// injectTokenVolume adds the projected volume of the secret of the token to
+// the pod template of a workload, mounted in all its containers, so that the
+// pods rolled out from it read the rotated tokens from the volume. The volume
+// is optional, so that the pods still start once the workload no longer
+// consumes the token and its secret is removed.
+func injectTokenVolume(ctx *vmwarecontext.GuestClusterContext, pSvcAccount vmwarev1.ProviderServiceAccount, workload injectedWorkload, secretName string) error {
+	before := workload.obj.DeepCopyObject().(client.Object)
+	spec := &workload.template.Spec
+
+	optional := true
+	volume := corev1.Volume{
+		Name: injectedVolumeName(pSvcAccount),
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{
+					Secret: &corev1.SecretProjection{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						Optional:             &optional,
+					},
+				}},
+			},
+		},
+	}
+	found := false
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == volume.Name {
+			spec.Volumes[i], found = volume, true
+		}
+	}
+	if !found {
+		spec.Volumes = append(spec.Volumes, volume)
+	}
+
+	mount := corev1.VolumeMount{Name: volume.Name, MountPath: path.Join(injectedTokenMountPath, pSvcAccount.Name), ReadOnly: true}
+	for i := range spec.Containers {
+		container := &spec.Containers[i]
+		found := false
+		for j := range container.VolumeMounts {
+			if container.VolumeMounts[j].Name == mount.Name {
+				container.VolumeMounts[j], found = mount, true
+			}
+		}
+		if !found {
+			container.VolumeMounts = append(container.VolumeMounts, mount)
+		}
+	}
+
+	if apiequality.Semantic.DeepEqual(before, workload.obj) {
+		return nil
+	}
+	ctx.Logger.Info("Injecting projected token in workload", "providerserviceaccount", pSvcAccount.Name, "kind", workload.kind, "name", workload.obj.GetName())
+	return ctx.GuestClient.Patch(ctx, workload.obj, client.MergeFromWithOptions(before, client.MergeFromWithOptimisticLock{}))
+}
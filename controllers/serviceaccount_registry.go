@@ -0,0 +1,220 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+)
+
+// +kubebuilder:rbac:groups=vmware.infrastructure.cluster.x-k8s.io,resources=systemserviceaccountregistries,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=vmware.infrastructure.cluster.x-k8s.io,resources=systemserviceaccountregistries/status,verbs=get;update;patch
+
+// registerSystemServiceAccount adds the service account of a provider
+// serviceaccount to the SystemServiceAccountRegistry, if one is configured,
+// and to the ConfigMap, which is kept for the components still reading it.
+func (r ServiceAccountReconciler) registerSystemServiceAccount(ctx *vmwarecontext.ClusterContext, pSvcAccount vmwarev1.ProviderServiceAccount) error {
+	if ctx.SystemServiceAccountRegistry == "" {
+		return r.ensureServiceAccountConfigMap(ctx, pSvcAccount)
+	}
+	name := vmwarev1.SystemServiceAccountName(getSystemServiceAccountFullName(pSvcAccount))
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		registry, err := r.getSystemServiceAccountRegistry(ctx)
+		if err != nil {
+			return err
+		}
+		for _, serviceAccount := range registry.Spec.ServiceAccounts {
+			if serviceAccount == name {
+				return nil
+			}
+		}
+		ctx.Logger.Info("Registering system service account", "registry", registry.Name, "serviceaccount", name)
+		registry.Spec.ServiceAccounts = append(registry.Spec.ServiceAccounts, name)
+		if registry.ResourceVersion == "" {
+			return ctx.Client.Create(ctx, registry)
+		}
+		return ctx.Client.Update(ctx, registry)
+	})
+	if err != nil || GetCMNamespaceName().Name == "" {
+		return err
+	}
+	// The registry replaces the ConfigMap, which is not required to exist.
+	return client.IgnoreNotFound(r.ensureServiceAccountConfigMap(ctx, pSvcAccount))
+}
+
+// unregisterSystemServiceAccount removes the service account of a provider
+// serviceaccount from the SystemServiceAccountRegistry, if one is configured,
+// and from the ConfigMap.
+func (r ServiceAccountReconciler) unregisterSystemServiceAccount(ctx *vmwarecontext.ClusterContext, pSvcAccount vmwarev1.ProviderServiceAccount) error {
+	if ctx.SystemServiceAccountRegistry == "" {
+		return r.deleteServiceAccountConfigMap(ctx, pSvcAccount)
+	}
+	name := vmwarev1.SystemServiceAccountName(getSystemServiceAccountFullName(pSvcAccount))
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		registry := &vmwarev1.SystemServiceAccountRegistry{}
+		if err := ctx.Client.Get(ctx, client.ObjectKey{Name: ctx.SystemServiceAccountRegistry}, registry); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		serviceAccounts := registry.Spec.ServiceAccounts[:0]
+		for _, serviceAccount := range registry.Spec.ServiceAccounts {
+			if serviceAccount != name {
+				serviceAccounts = append(serviceAccounts, serviceAccount)
+			}
+		}
+		if len(serviceAccounts) == len(registry.Spec.ServiceAccounts) {
+			return nil
+		}
+		ctx.Logger.Info("Unregistering system service account", "registry", registry.Name, "serviceaccount", name)
+		registry.Spec.ServiceAccounts = serviceAccounts
+		return ctx.Client.Update(ctx, registry)
+	})
+	if err != nil || GetCMNamespaceName().Name == "" {
+		return err
+	}
+	return client.IgnoreNotFound(r.deleteServiceAccountConfigMap(ctx, pSvcAccount))
+}
+
+// getSystemServiceAccountRegistry returns the SystemServiceAccountRegistry.
+// A registry which is not existing yet is returned unsaved, with the service
+// accounts of the ConfigMap it replaces, if any.
+func (r ServiceAccountReconciler) getSystemServiceAccountRegistry(ctx *vmwarecontext.ClusterContext) (*vmwarev1.SystemServiceAccountRegistry, error) {
+	registry := &vmwarev1.SystemServiceAccountRegistry{}
+	err := ctx.Client.Get(ctx, client.ObjectKey{Name: ctx.SystemServiceAccountRegistry}, registry)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return registry, err
+	}
+
+	registry = &vmwarev1.SystemServiceAccountRegistry{ObjectMeta: metav1.ObjectMeta{Name: ctx.SystemServiceAccountRegistry}}
+	if key := GetCMNamespaceName(); key.Name != "" {
+		configMap := &corev1.ConfigMap{}
+		if err := ctx.Client.Get(ctx, key, configMap); err != nil && !apierrors.IsNotFound(err) {
+			return nil, errors.Wrap(err, "unable to get the system service accounts configmap")
+		}
+		for name, valid := range configMap.Data {
+			if valid == strconv.FormatBool(true) {
+				registry.Spec.ServiceAccounts = append(registry.Spec.ServiceAccounts, vmwarev1.SystemServiceAccountName(name))
+			}
+		}
+		sort.Slice(registry.Spec.ServiceAccounts, func(i, j int) bool {
+			return registry.Spec.ServiceAccounts[i] < registry.Spec.ServiceAccounts[j]
+		})
+	}
+	return registry, nil
+}
+
+// syncSystemServiceAccountStatus reports in the status of the
+// SystemServiceAccountRegistry that the service accounts of the given
+// provider serviceaccounts are synced to the cluster, and only them.
+func (r ServiceAccountReconciler) syncSystemServiceAccountStatus(ctx *vmwarecontext.ClusterContext, pSvcAccounts []vmwarev1.ProviderServiceAccount) error {
+	if ctx.SystemServiceAccountRegistry == "" {
+		return nil
+	}
+	cluster := client.ObjectKeyFromObject(ctx.VSphereCluster).String()
+	synced := map[vmwarev1.SystemServiceAccountName]bool{}
+	for _, pSvcAccount := range pSvcAccounts {
+		synced[vmwarev1.SystemServiceAccountName(getSystemServiceAccountFullName(pSvcAccount))] = true
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		registry := &vmwarev1.SystemServiceAccountRegistry{}
+		if err := ctx.Client.Get(ctx, client.ObjectKey{Name: ctx.SystemServiceAccountRegistry}, registry); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		statuses, changed := setSyncedCluster(registry.Status.ServiceAccounts, cluster, synced)
+		if !changed {
+			return nil
+		}
+		registry.Status.ServiceAccounts = statuses
+		return ctx.Client.Status().Update(ctx, registry)
+	})
+}
+
+// setSyncedCluster adds the cluster to the statuses of the synced service
+// accounts, and removes it from the other ones. The statuses left without
+// clusters are removed. It returns whether the statuses changed.
+func setSyncedCluster(statuses []vmwarev1.SystemServiceAccountStatus, cluster string, synced map[vmwarev1.SystemServiceAccountName]bool) ([]vmwarev1.SystemServiceAccountStatus, bool) {
+	var (
+		result  []vmwarev1.SystemServiceAccountStatus
+		changed bool
+		found   = map[vmwarev1.SystemServiceAccountName]bool{}
+	)
+	for _, status := range statuses {
+		found[status.Name] = true
+		clusters := make([]string, 0, len(status.Clusters)+1)
+		listed := false
+		for _, c := range status.Clusters {
+			if c != cluster {
+				clusters = append(clusters, c)
+				continue
+			}
+			listed = true
+			if synced[status.Name] {
+				clusters = append(clusters, c)
+			}
+		}
+		if synced[status.Name] && !listed {
+			clusters = append(clusters, cluster)
+			sort.Strings(clusters)
+		}
+		changed = changed || len(clusters) != len(status.Clusters)
+		if len(clusters) > 0 {
+			result = append(result, vmwarev1.SystemServiceAccountStatus{Name: status.Name, Clusters: clusters})
+		}
+	}
+	for name := range synced {
+		if !found[name] {
+			result = append(result, vmwarev1.SystemServiceAccountStatus{Name: name, Clusters: []string{cluster}})
+			changed = true
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, changed
+}
+
+// systemServiceAccountRegistryMapper maps the SystemServiceAccountRegistry to
+// every VSphereCluster, so that the service accounts removed from its spec
+// are registered again without waiting for the next resync.
+type systemServiceAccountRegistryMapper struct {
+	ctx *context.ControllerManagerContext
+}
+
+func (d systemServiceAccountRegistryMapper) Map(o client.Object) []reconcile.Request {
+	if o.GetName() != d.ctx.SystemServiceAccountRegistry {
+		return nil
+	}
+	vsphereClusters := &vmwarev1.VSphereClusterList{}
+	if err := d.ctx.Client.List(d.ctx, vsphereClusters); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(vsphereClusters.Items))
+	for i := range vsphereClusters.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&vsphereClusters.Items[i])})
+	}
+	return requests
+}
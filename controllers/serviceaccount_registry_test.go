@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+)
+
+func TestSystemServiceAccountRegistry(t *testing.T) {
+	g := NewWithT(t)
+
+	_ = os.Setenv("SERVICE_ACCOUNTS_CM_NAMESPACE", "vmware-system-capw")
+	_ = os.Setenv("SERVICE_ACCOUNTS_CM_NAME", "system-serviceaccounts")
+	defer func() {
+		_ = os.Unsetenv("SERVICE_ACCOUNTS_CM_NAMESPACE")
+		_ = os.Unsetenv("SERVICE_ACCOUNTS_CM_NAME")
+	}()
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "vmware-system-capw", Name: "system-serviceaccounts"},
+		Data: map[string]string{
+			"system.serviceaccount.kube-system.admin":  "true",
+			"system.serviceaccount.kube-system.nobody": "false",
+		},
+	}
+	vsphereCluster := &vmwarev1.VSphereCluster{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "cluster"}}
+	pSvcAccount := vmwarev1.ProviderServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "csi"}}
+	fullName := vmwarev1.SystemServiceAccountName(getSystemServiceAccountFullName(pSvcAccount))
+
+	mgmtContext := fake.NewControllerManagerContext(configMap, vsphereCluster)
+	mgmtContext.SystemServiceAccountRegistry = "system-serviceaccounts"
+	controllerCtx := fake.NewControllerContext(mgmtContext)
+	ctx := &vmwarecontext.ClusterContext{ControllerContext: controllerCtx, VSphereCluster: vsphereCluster, Logger: controllerCtx.Logger}
+	r := ServiceAccountReconciler{ControllerContext: controllerCtx}
+
+	getRegistry := func() *vmwarev1.SystemServiceAccountRegistry {
+		registry := &vmwarev1.SystemServiceAccountRegistry{}
+		g.Expect(ctx.Client.Get(ctx, client.ObjectKey{Name: "system-serviceaccounts"}, registry)).To(Succeed())
+		return registry
+	}
+
+	// The registry is created with the service accounts of the ConfigMap.
+	g.Expect(r.registerSystemServiceAccount(ctx, pSvcAccount)).To(Succeed())
+	g.Expect(getRegistry().Spec.ServiceAccounts).To(Equal([]vmwarev1.SystemServiceAccountName{
+		"system.serviceaccount.kube-system.admin",
+		fullName,
+	}))
+	g.Expect(r.registerSystemServiceAccount(ctx, pSvcAccount)).To(Succeed())
+	g.Expect(getRegistry().Spec.ServiceAccounts).To(HaveLen(2))
+
+	// The service account is mirrored into the ConfigMap.
+	g.Expect(ctx.Client.Get(ctx, client.ObjectKeyFromObject(configMap), configMap)).To(Succeed())
+	g.Expect(configMap.Data).To(HaveKeyWithValue(string(fullName), "true"))
+
+	g.Expect(r.syncSystemServiceAccountStatus(ctx, []vmwarev1.ProviderServiceAccount{pSvcAccount})).To(Succeed())
+	g.Expect(getRegistry().Status.ServiceAccounts).To(Equal([]vmwarev1.SystemServiceAccountStatus{
+		{Name: fullName, Clusters: []string{fake.Namespace + "/cluster"}},
+	}))
+
+	g.Expect(r.syncSystemServiceAccountStatus(ctx, nil)).To(Succeed())
+	g.Expect(getRegistry().Status.ServiceAccounts).To(BeEmpty())
+	g.Expect(r.unregisterSystemServiceAccount(ctx, pSvcAccount)).To(Succeed())
+	g.Expect(getRegistry().Spec.ServiceAccounts).To(Equal([]vmwarev1.SystemServiceAccountName{"system.serviceaccount.kube-system.admin"}))
+
+	// The service account is removed from the ConfigMap, the other entries
+	// are left unchanged.
+	g.Expect(ctx.Client.Get(ctx, client.ObjectKeyFromObject(configMap), configMap)).To(Succeed())
+	g.Expect(configMap.Data).To(Equal(map[string]string{
+		"system.serviceaccount.kube-system.admin":  "true",
+		"system.serviceaccount.kube-system.nobody": "false",
+	}))
+}
+
+func TestSetSyncedCluster(t *testing.T) {
+	g := NewWithT(t)
+
+	statuses := []vmwarev1.SystemServiceAccountStatus{
+		{Name: "system.serviceaccount.ns.a", Clusters: []string{"ns/one", "ns/two"}},
+		{Name: "system.serviceaccount.ns.b", Clusters: []string{"ns/two"}},
+	}
+	synced := map[vmwarev1.SystemServiceAccountName]bool{"system.serviceaccount.ns.a": true, "system.serviceaccount.ns.c": true}
+	result, changed := setSyncedCluster(statuses, "ns/two", synced)
+	g.Expect(changed).To(BeTrue())
+	g.Expect(result).To(Equal([]vmwarev1.SystemServiceAccountStatus{
+		{Name: "system.serviceaccount.ns.a", Clusters: []string{"ns/one", "ns/two"}},
+		{Name: "system.serviceaccount.ns.c", Clusters: []string{"ns/two"}},
+	}))
+
+	_, changed = setSyncedCluster(result, "ns/two", synced)
+	g.Expect(changed).To(BeFalse())
+}
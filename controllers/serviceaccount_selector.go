@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+)
+
+// selectsCluster returns whether a provider serviceaccount is realized for a
+// VSphereCluster, either by its ref or by the cluster selector matching the
+// labels of its Cluster, which is nil if the VSphereCluster has none.
+func selectsCluster(pSvcAccount *vmwarev1.ProviderServiceAccount, vsphereCluster *vmwarev1.VSphereCluster, cluster *clusterv1.Cluster) bool {
+	if ref := pSvcAccount.Spec.Ref; ref != nil {
+		return ref.Name == vsphereCluster.Name
+	}
+	if pSvcAccount.Spec.ClusterSelector == nil || cluster == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(pSvcAccount.Spec.ClusterSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(cluster.Labels))
+}
+
+// selectsOtherClusters returns whether a provider serviceaccount with a
+// cluster selector is realized for another VSphereCluster of its namespace
+// than the given one, which is not being deleted.
+func selectsOtherClusters(ctx *vmwarecontext.ClusterContext, pSvcAccount *vmwarev1.ProviderServiceAccount) (bool, error) {
+	if pSvcAccount.Spec.ClusterSelector == nil {
+		return false, nil
+	}
+	vsphereClusters := &vmwarev1.VSphereClusterList{}
+	if err := ctx.Client.List(ctx, vsphereClusters, client.InNamespace(pSvcAccount.Namespace)); err != nil {
+		return false, errors.Wrap(err, "unable to list the VSphereClusters")
+	}
+	for i := range vsphereClusters.Items {
+		vsphereCluster := &vsphereClusters.Items[i]
+		if vsphereCluster.Name == ctx.VSphereCluster.Name || !vsphereCluster.DeletionTimestamp.IsZero() {
+			continue
+		}
+		cluster, err := clusterutilv1.GetOwnerCluster(ctx, ctx.Client, vsphereCluster.ObjectMeta)
+		if err != nil {
+			return false, err
+		}
+		if selectsCluster(pSvcAccount, vsphereCluster, cluster) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// removeUnselectedTargetSecrets removes from the guest cluster the target
+// secrets created for the provider serviceaccounts with a cluster selector
+// which are no longer realized for it, e.g. because its Cluster stopped
+// matching the selector, the provider serviceaccount was deleted or its
+// target changed, and the secrets of the tokens injected for the provider
+// serviceaccounts which no longer project a token.
+func removeUnselectedTargetSecrets(ctx *vmwarecontext.GuestClusterContext, pSvcAccounts []vmwarev1.ProviderServiceAccount) error {
+	targets := map[client.ObjectKey]bool{}
+	projections := map[string]bool{}
+	for _, pSvcAccount := range pSvcAccounts {
+		targets[client.ObjectKey{Namespace: pSvcAccount.Spec.TargetNamespace, Name: pSvcAccount.Spec.TargetSecretName}] = true
+		if pSvcAccount.Spec.TokenProjection != nil {
+			projections[pSvcAccount.Name] = true
+		}
+	}
+
+	secrets := &corev1.SecretList{}
+	if err := ctx.GuestClient.List(ctx, secrets, client.HasLabels{vmwarev1.ProviderServiceAccountTargetLabel}); err != nil {
+		return errors.Wrap(err, "unable to list the target secrets")
+	}
+	injectedSecrets := &corev1.SecretList{}
+	if err := ctx.GuestClient.List(ctx, injectedSecrets, client.HasLabels{vmwarev1.ProviderServiceAccountInjectLabel}); err != nil {
+		return errors.Wrap(err, "unable to list the secrets of the injected tokens")
+	}
+	var objs []client.Object
+	for i := range secrets.Items {
+		// The secrets of the injected tokens are removed below.
+		if _, ok := secrets.Items[i].Labels[vmwarev1.ProviderServiceAccountInjectLabel]; ok {
+			continue
+		}
+		if !targets[client.ObjectKeyFromObject(&secrets.Items[i])] {
+			objs = append(objs, &secrets.Items[i])
+		}
+	}
+	for i := range injectedSecrets.Items {
+		if !projections[injectedSecrets.Items[i].Labels[vmwarev1.ProviderServiceAccountInjectLabel]] {
+			objs = append(objs, &injectedSecrets.Items[i])
+		}
+	}
+	return deleteGuestObjects(ctx, objs...)
+}
+
+// setTargetLabel labels the target secret of a provider serviceaccount with
+// a cluster selector, so that it is removed from the guest clusters which are
+// no longer selected.
+func setTargetLabel(secret *corev1.Secret, pSvcAccount vmwarev1.ProviderServiceAccount) {
+	if pSvcAccount.Spec.ClusterSelector == nil {
+		return
+	}
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[vmwarev1.ProviderServiceAccountTargetLabel] = pSvcAccount.Name
+}
+
+// providerServiceAccountMapper maps a provider serviceaccount to the
+// VSphereClusters it may be realized for: the VSphereCluster of its ref, or
+// every VSphereCluster of its namespace for a cluster selector, so that the
+// clusters which stop matching it are reconciled too.
+type providerServiceAccountMapper struct {
+	ctx *context.ControllerManagerContext
+}
+
+func (d providerServiceAccountMapper) Map(o client.Object) []reconcile.Request {
+	pSvcAccount, ok := o.(*vmwarev1.ProviderServiceAccount)
+	if !ok {
+		return nil
+	}
+	return vsphereClusterRequests(d.ctx, pSvcAccount)
+}
+
+func vsphereClusterRequests(ctx *context.ControllerManagerContext, pSvcAccount *vmwarev1.ProviderServiceAccount) []reconcile.Request {
+	if ref := pSvcAccount.Spec.Ref; ref != nil {
+		if ref.Name == "" {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: pSvcAccount.Namespace, Name: ref.Name}}}
+	}
+	if pSvcAccount.Spec.ClusterSelector == nil {
+		return nil
+	}
+	vsphereClusters := &vmwarev1.VSphereClusterList{}
+	if err := ctx.Client.List(ctx, vsphereClusters, client.InNamespace(pSvcAccount.Namespace)); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(vsphereClusters.Items))
+	for i := range vsphereClusters.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&vsphereClusters.Items[i])})
+	}
+	return requests
+}
+
+// clusterLabelsChanged filters the events of the Clusters to the updates of
+// their labels, so the VSphereClusters are reconciled as soon as their Cluster
+// starts or stops matching the cluster selector of a provider serviceaccount.
+var clusterLabelsChanged = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
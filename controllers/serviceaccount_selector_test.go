@@ -0,0 +1,143 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+)
+
+func TestProviderServiceAccountClusterSelector(t *testing.T) {
+	newCluster := func(name string, labels map[string]string) (*clusterv1.Cluster, *vmwarev1.VSphereCluster) {
+		cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: name, Labels: labels}}
+		vsphereCluster := &vmwarev1.VSphereCluster{ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      name,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       name,
+			}},
+		}}
+		return cluster, vsphereCluster
+	}
+	newPSA := func(name, targetSecretName string, selector *metav1.LabelSelector, ref *corev1.ObjectReference) *vmwarev1.ProviderServiceAccount {
+		return &vmwarev1.ProviderServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: name},
+			Spec: vmwarev1.ProviderServiceAccountSpec{
+				Ref:              ref,
+				ClusterSelector:  selector,
+				TargetNamespace:  "target",
+				TargetSecretName: targetSecretName,
+			},
+		}
+	}
+	prod := &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+
+	prodCluster, prodVSphereCluster := newCluster("prod", map[string]string{"env": "prod"})
+	devCluster, devVSphereCluster := newCluster("dev", map[string]string{"env": "dev"})
+	selected := newPSA("selected", "selected-secret", prod, nil)
+	referenced := newPSA("referenced", "referenced-secret", nil, &corev1.ObjectReference{Name: "dev"})
+
+	mgmtContext := fake.NewControllerManagerContext(prodCluster, prodVSphereCluster, devCluster, devVSphereCluster, selected, referenced)
+	controllerCtx := fake.NewControllerContext(mgmtContext)
+	clusterContext := func(vsphereCluster *vmwarev1.VSphereCluster) *vmwarecontext.ClusterContext {
+		return &vmwarecontext.ClusterContext{
+			ControllerContext: controllerCtx,
+			VSphereCluster:    vsphereCluster,
+			Logger:            controllerCtx.Logger,
+		}
+	}
+
+	t.Run("selectsCluster", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(selectsCluster(selected, prodVSphereCluster, prodCluster)).To(BeTrue())
+		g.Expect(selectsCluster(selected, devVSphereCluster, devCluster)).To(BeFalse())
+		g.Expect(selectsCluster(selected, prodVSphereCluster, nil)).To(BeFalse())
+		g.Expect(selectsCluster(referenced, devVSphereCluster, nil)).To(BeTrue())
+		g.Expect(selectsCluster(referenced, prodVSphereCluster, prodCluster)).To(BeFalse())
+	})
+
+	t.Run("getProviderServiceAccounts matches the labels of the Cluster", func(t *testing.T) {
+		g := NewWithT(t)
+		pSvcAccounts, err := getProviderServiceAccounts(clusterContext(prodVSphereCluster))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(pSvcAccounts).To(HaveLen(1))
+		g.Expect(pSvcAccounts[0].Name).To(Equal("selected"))
+
+		pSvcAccounts, err = getProviderServiceAccounts(clusterContext(devVSphereCluster))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(pSvcAccounts).To(HaveLen(1))
+		g.Expect(pSvcAccounts[0].Name).To(Equal("referenced"))
+	})
+
+	t.Run("vsphereClusterRequests maps a selector to the VSphereClusters of its namespace", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(vsphereClusterRequests(mgmtContext, selected)).To(HaveLen(2))
+		requests := vsphereClusterRequests(mgmtContext, referenced)
+		g.Expect(requests).To(HaveLen(1))
+		g.Expect(requests[0].Name).To(Equal("dev"))
+	})
+
+	t.Run("selectsOtherClusters", func(t *testing.T) {
+		g := NewWithT(t)
+		other, err := selectsOtherClusters(clusterContext(prodVSphereCluster), selected)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(other).To(BeFalse())
+		other, err = selectsOtherClusters(clusterContext(devVSphereCluster), selected)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(other).To(BeTrue())
+	})
+
+	t.Run("removeUnselectedTargetSecrets", func(t *testing.T) {
+		g := NewWithT(t)
+		targetSecret := func(name, pSvcAccountName string) *corev1.Secret {
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "target", Name: name}}
+			if pSvcAccountName != "" {
+				setTargetLabel(secret, *newPSA(pSvcAccountName, name, prod, nil))
+			}
+			return secret
+		}
+		guestClient := ctrlfake.NewClientBuilder().WithScheme(mgmtContext.Scheme).WithObjects(
+			targetSecret("selected-secret", "selected"),
+			// The Cluster stopped matching the selector of the ProviderServiceAccount.
+			targetSecret("unselected-secret", "unselected"),
+			// Not created for a ProviderServiceAccount with a selector.
+			targetSecret("other-secret", ""),
+		).Build()
+		ctx := &vmwarecontext.GuestClusterContext{ClusterContext: clusterContext(prodVSphereCluster), GuestClient: guestClient}
+
+		g.Expect(removeUnselectedTargetSecrets(ctx, []vmwarev1.ProviderServiceAccount{*selected})).To(Succeed())
+		get := func(name string) error {
+			return guestClient.Get(ctx, client.ObjectKey{Namespace: "target", Name: name}, &corev1.Secret{})
+		}
+		g.Expect(get("selected-secret")).To(Succeed())
+		g.Expect(get("other-secret")).To(Succeed())
+		g.Expect(apierrors.IsNotFound(get("unselected-secret"))).To(BeTrue())
+	})
+}
@@ -22,6 +22,7 @@ import (
 	"net"
 	"net/url"
 	"reflect"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
@@ -35,9 +36,11 @@ import (
 	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/controllers/remote"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbldr "sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -49,6 +52,7 @@ import (
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/builder"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 )
 
@@ -83,7 +87,8 @@ func AddServiceDiscoveryControllerToManager(ctx *context.ControllerManagerContex
 	}
 	vsphereCluster := &vmwarev1.VSphereCluster{}
 	r := serviceDiscoveryReconciler{
-		ControllerContext: controllerContext,
+		ControllerContext:  controllerContext,
+		guestWriteThrottle: newGuestWriteThrottle(controlPlaneUpgradeGuestWriteInterval),
 	}
 
 	configMapCache, err := cache.New(mgr.GetConfig(), cache.Options{
@@ -110,12 +115,26 @@ func AddServiceDiscoveryControllerToManager(ctx *context.ControllerManagerContex
 			src,
 			handler.EnqueueRequestsFromMapFunc(configMapMapper{ctx: controllerContext.ControllerManagerContext}.Map),
 		).
+		// Watch the kubeconfig secrets of the guest clusters to reconcile
+		// with the new kubeconfig as soon as it is rotated.
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(kubeconfigSecretMapper{ctx: controllerContext.ControllerManagerContext}.Map),
+			ctrlbldr.WithPredicates(kubeconfigSecretChanged),
+		).
 		// watch the CAPI cluster
 		Watches(
 			&source.Kind{Type: &clusterv1.Cluster{}}, &handler.EnqueueRequestForOwner{
 				OwnerType:    vsphereCluster,
 				IsController: true,
 			}).
+		// Watch the Clusters to remove the objects synced to the guest
+		// clusters as soon as their deletion starts.
+		Watches(
+			&source.Kind{Type: &clusterv1.Cluster{}},
+			handler.EnqueueRequestsFromMapFunc(clusterutilv1.ClusterToInfrastructureMapFunc(vmwarev1.GroupVersion.WithKind("VSphereCluster"))),
+			ctrlbldr.WithPredicates(clusterDeletionStarted),
+		).
 		Complete(r)
 }
 
@@ -125,9 +144,15 @@ func newServiceDiscoveryReconciler() builder.Reconciler {
 
 type serviceDiscoveryReconciler struct {
 	*context.ControllerContext
+
+	// guestWriteThrottle limits writes to guest clusters whose control plane
+	// is being upgraded.
+	guestWriteThrottle *guestWriteThrottle
 }
 
-func (r serviceDiscoveryReconciler) Reconcile(ctx goctx.Context, req reconcile.Request) (_ reconcile.Result, reterr error) {
+func (r serviceDiscoveryReconciler) Reconcile(ctx goctx.Context, req reconcile.Request) (result reconcile.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
 	logger := r.Logger.WithName(req.Namespace).WithName(req.Name)
 	logger.V(4).Info("Starting Reconcile")
 
@@ -142,6 +167,17 @@ func (r serviceDiscoveryReconciler) Reconcile(ctx goctx.Context, req reconcile.R
 		return reconcile.Result{}, err
 	}
 
+	// The guest cluster is not written to while the cluster is paused, e.g.
+	// while it is moved to another management cluster.
+	paused, err := isPausedByOwnerCluster(r, r.Client, vsphereCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if paused {
+		r.Logger.V(4).Info("VSphereCluster linked to a cluster that is paused", "cluster", clusterKey)
+		return reconcile.Result{}, nil
+	}
+
 	// Create the patch helper.
 	patchHelper, err := patch.NewHelper(vsphereCluster, r.Client)
 	if err != nil {
@@ -175,6 +211,7 @@ func (r serviceDiscoveryReconciler) Reconcile(ctx goctx.Context, req reconcile.R
 
 	// This type of controller doesn't care about delete events.
 	if !vsphereCluster.DeletionTimestamp.IsZero() {
+		r.guestWriteThrottle.forget(clusterKey)
 		return reconcile.Result{}, nil
 	}
 
@@ -187,12 +224,26 @@ func (r serviceDiscoveryReconciler) Reconcile(ctx goctx.Context, req reconcile.R
 		logger.Info("The control plane is not ready yet", "err", err)
 		return reconcile.Result{RequeueAfter: clusterNotReadyRequeueTime}, nil
 	}
-
-	// Defer to the Reconciler for reconciling a non-delete event.
-	return r.ReconcileNormal(&vmwarecontext.GuestClusterContext{
+	guestClusterContext := &vmwarecontext.GuestClusterContext{
 		ClusterContext: clusterContext,
 		GuestClient:    guestClient,
-	})
+	}
+
+	// Remove the supervisor service and its address from the target cluster,
+	// instead of publishing them, once the deletion of the cluster has started.
+	if isGuestClusterBeingDeleted(clusterContext) {
+		r.guestWriteThrottle.forget(clusterKey)
+		return r.reconcileGuestClusterDelete(guestClusterContext)
+	}
+
+	// Reduce the write frequency to the target cluster while its control plane
+	// is being upgraded.
+	if result, throttled := throttleGuestWrites(clusterContext, r.guestWriteThrottle); throttled {
+		return result, nil
+	}
+
+	// Defer to the Reconciler for reconciling a non-delete event.
+	return r.ReconcileNormal(guestClusterContext)
 }
 
 type svcMapper struct {
@@ -244,6 +295,22 @@ func (r serviceDiscoveryReconciler) ReconcileNormal(ctx *vmwarecontext.GuestClus
 	return reconcile.Result{}, nil
 }
 
+// reconcileGuestClusterDelete deletes the headless service to the supervisor
+// api server, its endpoints and the supervisor endpoint ConfigMap from the
+// target cluster.
+func (r serviceDiscoveryReconciler) reconcileGuestClusterDelete(ctx *vmwarecontext.GuestClusterContext) (reconcile.Result, error) {
+	ctx.Logger.V(4).Info("Reconciling deleting Service Discovery from guest cluster", "cluster", ctx.VSphereCluster.Name)
+	supervisorPort := vmwarev1.SupervisorAPIServerPort
+	if err := deleteGuestObjects(ctx,
+		NewSupervisorHeadlessService(vmwarev1.SupervisorHeadlessSvcPort, supervisorPort),
+		NewSupervisorHeadlessServiceEndpoints("", supervisorPort),
+		NewSupervisorEndpointConfigMap("", supervisorPort),
+	); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to delete supervisor service discovery objects for %v", ctx.VSphereCluster)
+	}
+	return reconcile.Result{}, nil
+}
+
 // Setup a local k8s service in the target cluster that proxies to the Supervisor Cluster API Server. The add-ons are
 // dependent on this local service to connect to the Supervisor Cluster.
 func (r serviceDiscoveryReconciler) reconcileSupervisorHeadlessService(ctx *vmwarecontext.GuestClusterContext) error {
@@ -286,10 +353,41 @@ func (r serviceDiscoveryReconciler) reconcileSupervisorHeadlessService(ctx *vmwa
 		}
 	}
 
+	if err := r.reconcileSupervisorEndpointConfigMap(ctx, supervisorHost, supervisorPort); err != nil {
+		conditions.MarkFalse(ctx.VSphereCluster, vmwarev1.ServiceDiscoveryReadyCondition, vmwarev1.SupervisorEndpointConfigMapSetupFailedReason,
+			clusterv1.ConditionSeverityWarning, err.Error())
+		return err
+	}
+
 	conditions.MarkTrue(ctx.VSphereCluster, vmwarev1.ServiceDiscoveryReadyCondition)
 	return nil
 }
 
+// Publish the discovered supervisor api server address in a well-known ConfigMap in the target cluster, so in-guest
+// components that talk back to the Supervisor Cluster do not need to hardcode its address.
+func (r serviceDiscoveryReconciler) reconcileSupervisorEndpointConfigMap(ctx *vmwarecontext.GuestClusterContext, supervisorHost string, supervisorPort int) error {
+	newConfigMap := NewSupervisorEndpointConfigMap(supervisorHost, supervisorPort)
+	configMapKey := types.NamespacedName{Name: newConfigMap.Name, Namespace: newConfigMap.Namespace}
+	if createErr := ctx.GuestClient.Create(ctx, newConfigMap); createErr != nil { //nolint:nestif
+		if apierrors.IsAlreadyExists(createErr) {
+			var configMap corev1.ConfigMap
+			if getErr := ctx.GuestClient.Get(ctx, configMapKey, &configMap); getErr != nil {
+				return errors.Wrapf(getErr, "cannot get configmap %s", configMapKey)
+			}
+			// Update only if modified
+			if !reflect.DeepEqual(configMap.Data, newConfigMap.Data) {
+				configMap.Data = newConfigMap.Data
+				if updateErr := ctx.GuestClient.Update(ctx, &configMap); updateErr != nil {
+					return errors.Wrapf(updateErr, "cannot update configmap %s", configMapKey)
+				}
+			}
+		} else {
+			return errors.Wrapf(createErr, "cannot create configmap %s", configMapKey)
+		}
+	}
+	return nil
+}
+
 func GetSupervisorAPIServerAddress(ctx *vmwarecontext.ClusterContext) (string, error) {
 	// Discover the supervisor api server address
 	// 1. Check if a k8s service "kube-system/kube-apiserver-lb-svc" is available, if so, fetch the loadbalancer IP.
@@ -354,6 +452,21 @@ func NewSupervisorHeadlessServiceEndpoints(targetHost string, targetPort int) *c
 	}
 }
 
+func NewSupervisorEndpointConfigMap(targetHost string, targetPort int) *corev1.ConfigMap {
+	port := strconv.Itoa(targetPort)
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      vmwarev1.SupervisorEndpointConfigMapName,
+			Namespace: vmwarev1.SupervisorEndpointConfigMapNamespace,
+		},
+		Data: map[string]string{
+			vmwarev1.SupervisorEndpointConfigMapHostKey:   targetHost,
+			vmwarev1.SupervisorEndpointConfigMapPortKey:   port,
+			vmwarev1.SupervisorEndpointConfigMapServerKey: "https://" + net.JoinHostPort(targetHost, port),
+		},
+	}
+}
+
 func GetSupervisorAPIServerVIP(client client.Client) (string, error) {
 	svc := &corev1.Service{}
 	svcKey := types.NamespacedName{Name: vmwarev1.SupervisorLoadBalancerSvcName, Namespace: vmwarev1.SupervisorLoadBalancerSvcNamespace}
@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"time"
+
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+const (
+	// storageVersionMigrationProvider is the value of the provider label of
+	// the CRDs of this provider.
+	storageVersionMigrationProvider = "infrastructure-vsphere"
+
+	// storageVersionMigrationPageSize is the number of objects listed at
+	// once when they are migrated.
+	storageVersionMigrationPageSize = 100
+
+	// storageVersionMigrationRetryInterval is the interval at which a failed
+	// migration is retried.
+	storageVersionMigrationRetryInterval = time.Minute
+)
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=patch;update
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=*,verbs=get;list;update
+// +kubebuilder:rbac:groups=vmware.infrastructure.cluster.x-k8s.io,resources=*,verbs=get;list;update
+
+// AddStorageVersionMigratorToManager adds the migrator that rewrites the
+// objects of the CRDs of the provider stored in former versions to the
+// storage version of their CRD to the provided manager.
+func AddStorageVersionMigratorToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controllerNameShort = "storageversion-migrator"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+
+	// The migrator needs leader election, so that the objects are rewritten
+	// once. The objects are read from the API server, so that the kinds of
+	// every CRD are not added to the cache.
+	return mgr.Add(storageVersionMigrator{ControllerContext: controllerContext, reader: mgr.GetAPIReader()})
+}
+
+// storageVersionMigrator rewrites the objects of the CRDs of the provider
+// whose status lists other stored versions than their storage version. The API
+// server stores an object in the storage version of its CRD when it is
+// updated, even if the update does not change it, so that the former versions
+// can then be removed from the stored versions of the CRD, and later from the
+// CRD itself.
+type storageVersionMigrator struct {
+	*context.ControllerContext
+
+	// reader reads the CRDs and the objects to migrate.
+	reader ctrlclient.Reader
+}
+
+// Start migrates the objects of the CRDs of the provider, retrying a failed
+// migration until it succeeds or the context is done.
+func (r storageVersionMigrator) Start(ctx goctx.Context) error {
+	for {
+		err := r.migrate(ctx)
+		if err == nil {
+			return nil
+		}
+		r.Logger.Error(err, "failed to migrate the storage versions, retrying", "after", storageVersionMigrationRetryInterval)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(storageVersionMigrationRetryInterval):
+		}
+	}
+}
+
+// migrate migrates the objects of every CRD of the provider. A CRD which fails
+// to be migrated does not prevent the migration of the other CRDs.
+func (r storageVersionMigrator) migrate(ctx goctx.Context) error {
+	crds := &apiextensionsv1.CustomResourceDefinitionList{}
+	if err := r.reader.List(ctx, crds, ctrlclient.MatchingLabels{clusterv1.ProviderLabelName: storageVersionMigrationProvider}); err != nil {
+		return errors.Wrap(err, "failed to list the CRDs of the provider")
+	}
+	var errs []error
+	for i := range crds.Items {
+		if err := r.migrateCRD(ctx, &crds.Items[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// migrateCRD rewrites the objects of a CRD whose stored versions are not only
+// its storage version, then sets its stored versions to its storage version.
+func (r storageVersionMigrator) migrateCRD(ctx goctx.Context, crd *apiextensionsv1.CustomResourceDefinition) error {
+	version := storageVersion(crd)
+	if version == "" {
+		return nil
+	}
+	if len(crd.Status.StoredVersions) == 1 && crd.Status.StoredVersions[0] == version {
+		return nil
+	}
+	logger := r.Logger.WithValues("crd", crd.Name, "version", version, "storedVersions", crd.Status.StoredVersions)
+	logger.Info("Migrating the objects to the storage version")
+
+	var migrated int
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: version, Kind: crd.Spec.Names.ListKind})
+	for {
+		if err := r.reader.List(ctx, list, ctrlclient.Limit(storageVersionMigrationPageSize), ctrlclient.Continue(list.GetContinue())); err != nil {
+			return errors.Wrapf(err, "failed to list the objects of %s", crd.Name)
+		}
+		for i := range list.Items {
+			// An object deleted or updated since it was listed does not
+			// need to be rewritten.
+			if err := r.Client.Update(ctx, &list.Items[i]); err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+				return errors.Wrapf(err, "failed to migrate %s %s/%s", crd.Spec.Names.Kind, list.Items[i].GetNamespace(), list.Items[i].GetName())
+			}
+			migrated++
+		}
+		if list.GetContinue() == "" {
+			break
+		}
+	}
+
+	patch := ctrlclient.MergeFrom(crd.DeepCopy())
+	crd.Status.StoredVersions = []string{version}
+	if err := r.Client.Status().Patch(ctx, crd, patch); err != nil {
+		return errors.Wrapf(err, "failed to set the stored versions of %s", crd.Name)
+	}
+	logger.Info("Migrated the objects to the storage version", "objects", migrated)
+	return nil
+}
+
+// storageVersion returns the storage version of a CRD.
+func storageVersion(crd *apiextensionsv1.CustomResourceDefinition) string {
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	return ""
+}
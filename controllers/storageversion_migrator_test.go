@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestStorageVersionMigrator(t *testing.T) {
+	g := NewWithT(t)
+	crd := func(name, kind string, labels map[string]string, storedVersions ...string) *apiextensionsv1.CustomResourceDefinition {
+		return &apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				Group: infrav1.GroupVersion.Group,
+				Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: kind, ListKind: kind + "List"},
+				Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+					{Name: "v1alpha3", Served: true},
+					{Name: "v1beta1", Served: true, Storage: true},
+				},
+			},
+			Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: storedVersions},
+		}
+	}
+	providerLabels := map[string]string{clusterv1.ProviderLabelName: storageVersionMigrationProvider}
+	machine := func(name string) *infrav1.VSphereMachine {
+		return &infrav1.VSphereMachine{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: name}}
+	}
+	mgmtContext := fake.NewControllerManagerContext(
+		crd("vspheremachines.infrastructure.cluster.x-k8s.io", "VSphereMachine", providerLabels, "v1alpha3", "v1beta1"),
+		crd("vsphereclusters.infrastructure.cluster.x-k8s.io", "VSphereCluster", providerLabels, "v1beta1"),
+		crd("vspherevms.infrastructure.cluster.x-k8s.io", "VSphereVM", nil, "v1alpha3", "v1beta1"),
+		machine("machine-0"),
+		machine("machine-1"),
+		&infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "vm"}},
+	)
+	controllerCtx := fake.NewControllerContext(mgmtContext)
+	r := storageVersionMigrator{ControllerContext: controllerCtx, reader: controllerCtx.Client}
+
+	resourceVersion := func(obj client.Object) string {
+		g.Expect(controllerCtx.Client.Get(controllerCtx, client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+		return obj.GetResourceVersion()
+	}
+	machineVersion := resourceVersion(machine("machine-0"))
+	vmVersion := resourceVersion(&infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "vm"}})
+	clusterCRDVersion := resourceVersion(&apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "vsphereclusters.infrastructure.cluster.x-k8s.io"}})
+
+	g.Expect(r.migrate(controllerCtx)).To(Succeed())
+
+	// The objects of the CRD with a former stored version are rewritten.
+	machine0 := machine("machine-0")
+	g.Expect(resourceVersion(machine0)).NotTo(Equal(machineVersion))
+	machinesCRD := &apiextensionsv1.CustomResourceDefinition{}
+	g.Expect(controllerCtx.Client.Get(controllerCtx, client.ObjectKey{Name: "vspheremachines.infrastructure.cluster.x-k8s.io"}, machinesCRD)).To(Succeed())
+	g.Expect(machinesCRD.Status.StoredVersions).To(ConsistOf("v1beta1"))
+
+	// The migrated CRDs and the CRDs of other providers are not changed.
+	g.Expect(resourceVersion(&apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "vsphereclusters.infrastructure.cluster.x-k8s.io"}})).To(Equal(clusterCRDVersion))
+	g.Expect(resourceVersion(&infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "vm"}})).To(Equal(vmVersion))
+	vmsCRD := &apiextensionsv1.CustomResourceDefinition{}
+	g.Expect(controllerCtx.Client.Get(controllerCtx, client.ObjectKey{Name: "vspherevms.infrastructure.cluster.x-k8s.io"}, vmsCRD)).To(Succeed())
+	g.Expect(vmsCRD.Status.StoredVersions).To(ConsistOf("v1alpha3", "v1beta1"))
+}
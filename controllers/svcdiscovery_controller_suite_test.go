@@ -95,7 +95,7 @@ func assertHeadlessSvcWithNoEndpoints(ctx context.Context, guestClient client.Cl
 func assertHeadlessSvcWithVIPEndpoints(ctx context.Context, guestClient client.Client, namespace, name string) {
 	assertHeadlessSvc(ctx, guestClient, namespace, name)
 	headlessEndpoints := &corev1.Endpoints{}
-	assertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
+	builder.AssertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
 	Expect(headlessEndpoints.Subsets[0].Addresses[0].IP).To(Equal(testSupervisorAPIServerVIP))
 	Expect(headlessEndpoints.Subsets[0].Ports[0].Port).To(Equal(int32(supervisorAPIServerPort)))
 }
@@ -103,7 +103,7 @@ func assertHeadlessSvcWithVIPEndpoints(ctx context.Context, guestClient client.C
 func assertHeadlessSvcWithVIPHostnameEndpoints(ctx context.Context, guestClient client.Client, namespace, name string) {
 	assertHeadlessSvc(ctx, guestClient, namespace, name)
 	headlessEndpoints := &corev1.Endpoints{}
-	assertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
+	builder.AssertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
 	Expect(headlessEndpoints.Subsets[0].Addresses[0].Hostname).To(Equal(testSupervisorAPIServerVIPHostName))
 	Expect(headlessEndpoints.Subsets[0].Ports[0].Port).To(Equal(int32(supervisorAPIServerPort)))
 }
@@ -111,7 +111,7 @@ func assertHeadlessSvcWithVIPHostnameEndpoints(ctx context.Context, guestClient
 func assertHeadlessSvcWithFIPEndpoints(ctx context.Context, guestClient client.Client, namespace, name string) {
 	assertHeadlessSvc(ctx, guestClient, namespace, name)
 	headlessEndpoints := &corev1.Endpoints{}
-	assertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
+	builder.AssertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
 	Expect(headlessEndpoints.Subsets[0].Addresses[0].IP).To(Equal(testSupervisorAPIServerFIP))
 	Expect(headlessEndpoints.Subsets[0].Ports[0].Port).To(Equal(int32(testSupervisorAPIServerPort)))
 }
@@ -119,11 +119,24 @@ func assertHeadlessSvcWithFIPEndpoints(ctx context.Context, guestClient client.C
 func assertHeadlessSvcWithFIPHostNameEndpoints(ctx context.Context, guestClient client.Client, namespace, name string) {
 	assertHeadlessSvc(ctx, guestClient, namespace, name)
 	headlessEndpoints := &corev1.Endpoints{}
-	assertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
+	builder.AssertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
 	Expect(headlessEndpoints.Subsets[0].Addresses[0].Hostname).To(Equal(testSupervisorAPIServerFIPHostName))
 	Expect(headlessEndpoints.Subsets[0].Ports[0].Port).To(Equal(int32(testSupervisorAPIServerPort)))
 }
 
+func assertSupervisorEndpointConfigMap(ctx context.Context, guestClient client.Client, host string) {
+	configMap := &corev1.ConfigMap{}
+	builder.AssertEventuallyExistsInNamespace(ctx, guestClient, vmwarev1beta1.SupervisorEndpointConfigMapNamespace, vmwarev1beta1.SupervisorEndpointConfigMapName, configMap)
+	Expect(configMap.Data).To(HaveKeyWithValue(vmwarev1beta1.SupervisorEndpointConfigMapHostKey, host))
+	Expect(configMap.Data).To(HaveKeyWithValue(vmwarev1beta1.SupervisorEndpointConfigMapPortKey, strconv.Itoa(supervisorAPIServerPort)))
+	Expect(configMap.Data).To(HaveKeyWithValue(vmwarev1beta1.SupervisorEndpointConfigMapServerKey, "https://"+host+":"+strconv.Itoa(supervisorAPIServerPort)))
+}
+
+func assertNoSupervisorEndpointConfigMap(ctx context.Context, guestClient client.Client) {
+	configMap := &corev1.ConfigMap{}
+	assertEventuallyDoesNotExistInNamespace(ctx, guestClient, vmwarev1beta1.SupervisorEndpointConfigMapNamespace, vmwarev1beta1.SupervisorEndpointConfigMapName, configMap)
+}
+
 func assertServiceDiscoveryCondition(vsphereCluster *vmwarev1beta1.VSphereCluster, status corev1.ConditionStatus,
 	message string, reason string, severity clusterv1.ConditionSeverity) {
 	c := conditions.Get(vsphereCluster, vmwarev1beta1.ServiceDiscoveryReadyCondition)
@@ -141,7 +154,7 @@ func assertServiceDiscoveryCondition(vsphereCluster *vmwarev1beta1.VSphereCluste
 func assertHeadlessSvcWithUpdatedVIPEndpoints(ctx context.Context, guestClient client.Client, namespace, name string) {
 	assertHeadlessSvc(ctx, guestClient, namespace, name)
 	headlessEndpoints := &corev1.Endpoints{}
-	assertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
+	builder.AssertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, headlessEndpoints)
 	EventuallyWithOffset(2, func() string {
 		key := client.ObjectKey{Namespace: namespace, Name: name}
 		Expect(guestClient.Get(ctx, key, headlessEndpoints)).Should(Succeed())
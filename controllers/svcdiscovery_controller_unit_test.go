@@ -29,6 +29,7 @@ import (
 )
 
 var _ = Describe("ServiceDiscoveryReconciler ReconcileNormal", serviceDiscoveryUnitTestsReconcileNormal)
+var _ = Describe("ServiceDiscoveryReconciler reconcileGuestClusterDelete", serviceDiscoveryUnitTestsReconcileGuestClusterDelete)
 
 func serviceDiscoveryUnitTestsReconcileNormal() {
 	var (
@@ -45,6 +46,7 @@ func serviceDiscoveryUnitTestsReconcileNormal() {
 		It("Should reconcile headless svc", func() {
 			By("creating a service and no endpoint in the guest cluster")
 			assertHeadlessSvcWithNoEndpoints(ctx, ctx.GuestClient, supervisorHeadlessSvcNamespace, supervisorHeadlessSvcName)
+			assertNoSupervisorEndpointConfigMap(ctx, ctx.GuestClient)
 			assertServiceDiscoveryCondition(ctx.VSphereCluster, corev1.ConditionFalse, "Unable to discover supervisor apiserver address",
 				vmwarev1b1.SupervisorHeadlessServiceSetupFailedReason, clusterv1.ConditionSeverityWarning)
 		})
@@ -59,6 +61,7 @@ func serviceDiscoveryUnitTestsReconcileNormal() {
 		It("Should reconcile headless svc", func() {
 			By("creating a service and endpoints using the VIP in the guest cluster")
 			assertHeadlessSvcWithVIPEndpoints(ctx, ctx.GuestClient, vmwarev1b1.SupervisorHeadlessSvcNamespace, vmwarev1b1.SupervisorHeadlessSvcName)
+			assertSupervisorEndpointConfigMap(ctx, ctx.GuestClient, testSupervisorAPIServerVIP)
 			assertServiceDiscoveryCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
 		})
 		It("Should get supervisor master endpoint IP", func() {
@@ -75,6 +78,7 @@ func serviceDiscoveryUnitTestsReconcileNormal() {
 		It("Should reconcile headless svc", func() {
 			By("creating a service and endpoints using the FIP in the guest cluster")
 			assertHeadlessSvcWithFIPEndpoints(ctx, ctx.GuestClient, supervisorHeadlessSvcNamespace, supervisorHeadlessSvcName)
+			assertSupervisorEndpointConfigMap(ctx, ctx.GuestClient, testSupervisorAPIServerFIP)
 			assertServiceDiscoveryCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
 		})
 	})
@@ -99,6 +103,7 @@ func serviceDiscoveryUnitTestsReconcileNormal() {
 		It("Should reconcile headless svc", func() {
 			By("creating a service and endpoints using the VIP in the guest cluster")
 			assertHeadlessSvcWithVIPHostnameEndpoints(ctx, ctx.GuestClient, supervisorHeadlessSvcNamespace, supervisorHeadlessSvcName)
+			assertSupervisorEndpointConfigMap(ctx, ctx.GuestClient, testSupervisorAPIServerVIPHostName)
 			assertServiceDiscoveryCondition(ctx.VSphereCluster, corev1.ConditionTrue, "", "", "")
 		})
 	})
@@ -123,6 +128,7 @@ func serviceDiscoveryUnitTestsReconcileNormal() {
 		It("Should reconcile headless svc", func() {
 			By("creating a service and no endpoint in the guest cluster")
 			assertHeadlessSvcWithNoEndpoints(ctx, ctx.GuestClient, supervisorHeadlessSvcNamespace, supervisorHeadlessSvcName)
+			assertNoSupervisorEndpointConfigMap(ctx, ctx.GuestClient)
 			assertServiceDiscoveryCondition(ctx.VSphereCluster, corev1.ConditionFalse, "Unable to discover supervisor apiserver address",
 				vmwarev1b1.SupervisorHeadlessServiceSetupFailedReason, clusterv1.ConditionSeverityWarning)
 		})
@@ -136,6 +142,7 @@ func serviceDiscoveryUnitTestsReconcileNormal() {
 		It("Should reconcile headless svc", func() {
 			By("creating a service and no endpoint in the guest cluster")
 			assertHeadlessSvcWithNoEndpoints(ctx, ctx.GuestClient, supervisorHeadlessSvcNamespace, supervisorHeadlessSvcName)
+			assertNoSupervisorEndpointConfigMap(ctx, ctx.GuestClient)
 			assertServiceDiscoveryCondition(ctx.VSphereCluster, corev1.ConditionFalse, "Unable to discover supervisor apiserver address",
 				vmwarev1b1.SupervisorHeadlessServiceSetupFailedReason, clusterv1.ConditionSeverityWarning)
 		})
@@ -152,6 +159,7 @@ func serviceDiscoveryUnitTestsReconcileNormal() {
 		It("Should reconcile headless svc", func() {
 			By("creating a service and no endpoint in the guest cluster")
 			assertHeadlessSvcWithNoEndpoints(ctx, ctx.GuestClient, supervisorHeadlessSvcNamespace, supervisorHeadlessSvcName)
+			assertNoSupervisorEndpointConfigMap(ctx, ctx.GuestClient)
 			assertServiceDiscoveryCondition(ctx.VSphereCluster, corev1.ConditionFalse, "Unable to discover supervisor apiserver address",
 				vmwarev1b1.SupervisorHeadlessServiceSetupFailedReason, clusterv1.ConditionSeverityWarning)
 		})
@@ -168,8 +176,36 @@ func serviceDiscoveryUnitTestsReconcileNormal() {
 		It("Should reconcile headless svc", func() {
 			By("creating a service and no endpoint in the guest cluster")
 			assertHeadlessSvcWithNoEndpoints(ctx, ctx.GuestClient, supervisorHeadlessSvcNamespace, supervisorHeadlessSvcName)
+			assertNoSupervisorEndpointConfigMap(ctx, ctx.GuestClient)
 			assertServiceDiscoveryCondition(ctx.VSphereCluster, corev1.ConditionFalse, "Unable to discover supervisor apiserver address",
 				vmwarev1b1.SupervisorHeadlessServiceSetupFailedReason, clusterv1.ConditionSeverityWarning)
 		})
 	})
 }
+
+func serviceDiscoveryUnitTestsReconcileGuestClusterDelete() {
+	var ctx *builder.UnitTestContextForController
+
+	BeforeEach(func() {
+		ctx = serviceDiscoveryTestSuite.NewUnitTestContextForController(newTestSupervisorLBServiceWithIPStatus())
+	})
+	AfterEach(func() {
+		ctx = nil
+	})
+
+	It("Should delete the headless svc from the guest cluster", func() {
+		assertHeadlessSvcWithVIPEndpoints(ctx, ctx.GuestClient, vmwarev1b1.SupervisorHeadlessSvcNamespace, vmwarev1b1.SupervisorHeadlessSvcName)
+		assertSupervisorEndpointConfigMap(ctx, ctx.GuestClient, testSupervisorAPIServerVIP)
+
+		_, err := serviceDiscoveryReconciler{}.reconcileGuestClusterDelete(ctx.GuestClusterContext)
+		Expect(err).NotTo(HaveOccurred())
+		By("deleting the service, its endpoints and the endpoint configmap from the guest cluster")
+		assertEventuallyDoesNotExistInNamespace(ctx, ctx.GuestClient, vmwarev1b1.SupervisorHeadlessSvcNamespace, vmwarev1b1.SupervisorHeadlessSvcName, &corev1.Service{})
+		assertEventuallyDoesNotExistInNamespace(ctx, ctx.GuestClient, vmwarev1b1.SupervisorHeadlessSvcNamespace, vmwarev1b1.SupervisorHeadlessSvcName, &corev1.Endpoints{})
+		assertNoSupervisorEndpointConfigMap(ctx, ctx.GuestClient)
+
+		By("skipping the objects already deleted")
+		_, err = serviceDiscoveryReconciler{}.reconcileGuestClusterDelete(ctx.GuestClusterContext)
+		Expect(err).NotTo(HaveOccurred())
+	})
+}
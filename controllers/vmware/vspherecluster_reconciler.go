@@ -27,6 +27,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/collections"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
@@ -86,6 +87,10 @@ func (r ClusterReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctr
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	if annotations.HasPaused(vsphereCluster) || cluster != nil && cluster.Spec.Paused {
+		logger.V(4).Info("VSphereCluster linked to a cluster that is paused")
+		return reconcile.Result{}, nil
+	}
 
 	// Build the patch helper.
 	patchHelper, err := patch.NewHelper(vsphereCluster, r.Client)
@@ -115,7 +120,7 @@ func (r ClusterReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctr
 
 	// Handle deleted clusters
 	if !vsphereCluster.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(clusterContext), nil
+		return r.reconcileDelete(clusterContext)
 	}
 
 	if cluster == nil {
@@ -127,7 +132,7 @@ func (r ClusterReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctr
 	return r.reconcileNormal(clusterContext)
 }
 
-func (r *ClusterReconciler) reconcileDelete(ctx *vmware.ClusterContext) reconcile.Result {
+func (r *ClusterReconciler) reconcileDelete(ctx *vmware.ClusterContext) (reconcile.Result, error) {
 	ctx.Logger.Info("Reconciling vsphereCluster delete")
 
 	deletingConditionTypes := []clusterv1.ConditionType{
@@ -142,10 +147,16 @@ func (r *ClusterReconciler) reconcileDelete(ctx *vmware.ClusterContext) reconcil
 		}
 	}
 
+	if err := r.NetworkProvider.DeleteClusterNetwork(ctx); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err,
+			"failed to delete cluster network for vsphereCluster %s/%s",
+			ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name)
+	}
+
 	// Cluster is deleted so remove the finalizer.
 	controllerutil.RemoveFinalizer(ctx.VSphereCluster, vmwarev1.ClusterFinalizer)
 
-	return reconcile.Result{}
+	return reconcile.Result{}, nil
 }
 
 func (r *ClusterReconciler) reconcileNormal(ctx *vmware.ClusterContext) (reconcile.Result, error) {
@@ -176,6 +187,14 @@ func (r *ClusterReconciler) reconcileNormal(ctx *vmware.ClusterContext) (reconci
 	conditions.MarkTrue(ctx.VSphereCluster, vmwarev1.ResourcePolicyReadyCondition)
 	ctx.VSphereCluster.Status.ResourcePolicyName = resourcePolicyName
 
+	// Report the VirtualMachineClasses and the storage classes which are
+	// withdrawn from the namespace, before they fail the next machines.
+	if err := r.reconcileVMResources(ctx); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err,
+			"failed to reconcile VM resources for vsphereCluster %s/%s",
+			ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name)
+	}
+
 	// Configure the cluster for the cluster network
 	err = r.NetworkProvider.ProvisionClusterNetwork(ctx)
 	if err != nil {
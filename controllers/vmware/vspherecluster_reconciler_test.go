@@ -18,17 +18,23 @@ package vmware
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	vmoprv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
 	topologyv1 "github.com/vmware-tanzu/vm-operator/external/tanzu-topology/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apirecord "k8s.io/client-go/tools/record"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/network"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/vmoperator"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
@@ -111,6 +117,99 @@ var _ = Describe("Cluster Controller Tests", func() {
 			Expect(c.Status).NotTo(Equal(corev1.ConditionFalse))
 			Expect(c.Reason).NotTo(Equal(clusterv1.DeletingReason))
 		})
+
+		It("should remove the finalizer once the cluster network is deleted", func() {
+			controllerutil.AddFinalizer(ctx.VSphereCluster, infrav1.ClusterFinalizer)
+			_, err := reconciler.reconcileDelete(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ctx.VSphereCluster.Finalizers).NotTo(ContainElement(infrav1.ClusterFinalizer))
+		})
+	})
+
+	Context("Test reconcileVMResources", func() {
+		var recorder *apirecord.FakeRecorder
+
+		BeforeEach(func() {
+			recorder = apirecord.NewFakeRecorder(10)
+			ctx.ControllerContext.Recorder = record.New(recorder)
+
+			Expect(ctx.Client.Create(ctx, vsphereMachine)).To(Succeed())
+			Expect(ctx.Client.Create(ctx, &vmoprv1.VirtualMachineClassBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: className},
+				ClassRef:   vmoprv1.ClassReference{Kind: "VirtualMachineClass", Name: className},
+			})).To(Succeed())
+		})
+
+		It("should mark the VM resources available", func() {
+			Expect(reconciler.reconcileVMResources(ctx)).To(Succeed())
+			Expect(conditions.IsTrue(ctx.VSphereCluster, infrav1.VMResourcesAvailableCondition)).To(BeTrue())
+			Expect(recorder.Events).To(BeEmpty())
+		})
+
+		It("should report a class being unbound from the namespace", func() {
+			template := &infrav1.VSphereMachineTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-template",
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: clusterv1.GroupVersion.String(),
+						Kind:       "Cluster",
+						Name:       clusterName,
+					}},
+				},
+			}
+			template.Spec.Template.Spec.ClassName = "best-effort-large"
+			Expect(ctx.Client.Create(ctx, template)).To(Succeed())
+			Expect(ctx.Client.Create(ctx, &vmoprv1.VirtualMachineClassBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "best-effort-large",
+					DeletionTimestamp: &metav1.Time{Time: time.Now()},
+					Finalizers:        []string{"vmoperator.vmware.com/virtualmachineclassbinding"},
+				},
+				ClassRef: vmoprv1.ClassReference{Kind: "VirtualMachineClass", Name: "best-effort-large"},
+			})).To(Succeed())
+
+			Expect(reconciler.reconcileVMResources(ctx)).To(Succeed())
+			c := conditions.Get(ctx.VSphereCluster, infrav1.VMResourcesAvailableCondition)
+			Expect(c).NotTo(BeNil())
+			Expect(c.Status).To(Equal(corev1.ConditionFalse))
+			Expect(c.Reason).To(Equal(infrav1.VMClassUnavailableReason))
+			Expect(c.Message).To(ContainSubstring("best-effort-large"))
+			Expect(recorder.Events).To(HaveLen(1))
+
+			// The event is only recorded once for the same withdrawn resources.
+			Expect(reconciler.reconcileVMResources(ctx)).To(Succeed())
+			Expect(recorder.Events).To(HaveLen(1))
+		})
+
+		It("should report a storage class removed from the storage quotas", func() {
+			Expect(ctx.Client.Create(ctx, &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-quota"},
+				Spec: corev1.ResourceQuotaSpec{
+					Hard: corev1.ResourceList{
+						"other-storageClass.storageclass.storage.k8s.io/requests.storage": resource.MustParse("100Gi"),
+					},
+				},
+			})).To(Succeed())
+
+			Expect(reconciler.reconcileVMResources(ctx)).To(Succeed())
+			c := conditions.Get(ctx.VSphereCluster, infrav1.VMResourcesAvailableCondition)
+			Expect(c).NotTo(BeNil())
+			Expect(c.Status).To(Equal(corev1.ConditionFalse))
+			Expect(c.Reason).To(Equal(infrav1.StorageClassUnavailableReason))
+			Expect(c.Message).To(ContainSubstring(storageClass))
+		})
+
+		It("should ignore the storage classes when the namespace has no storage quota", func() {
+			Expect(ctx.Client.Create(ctx, &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-quota"},
+				Spec: corev1.ResourceQuotaSpec{
+					Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+				},
+			})).To(Succeed())
+
+			Expect(reconciler.reconcileVMResources(ctx)).To(Succeed())
+			Expect(conditions.IsTrue(ctx.VSphereCluster, infrav1.VMResourcesAvailableCondition)).To(BeTrue())
+		})
 	})
 
 	Context("Test getFailureDomains", func() {
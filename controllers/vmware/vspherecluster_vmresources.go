@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vmware
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	vmoprv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/vmoperator"
+)
+
+// +kubebuilder:rbac:groups="",resources=resourcequotas,verbs=get;list;watch
+
+// reconcileVMResources reports in the VMResourcesAvailable condition whether
+// the VirtualMachineClasses and the storage classes used by the machines and
+// the machine templates of the cluster are still available in its namespace.
+// A withdrawn resource is also recorded as a warning event. The machines
+// created with it are held before their VM is created, so the condition
+// blocks the scale ups and the rollouts of the cluster which use it.
+func (r *ClusterReconciler) reconcileVMResources(ctx *vmware.ClusterContext) error {
+	classNames, storageClasses, err := r.usedVMResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reason string
+	var messages []string
+	unavailableStorageClasses, err := vmoperator.UnavailableStorageClasses(ctx, ctx.Client, ctx.VSphereCluster.Namespace, storageClasses)
+	if err != nil {
+		return err
+	}
+	if len(unavailableStorageClasses) > 0 {
+		reason = vmwarev1.StorageClassUnavailableReason
+		messages = append(messages, fmt.Sprintf("storage classes %s are not part of the storage quotas of namespace %s",
+			strings.Join(unavailableStorageClasses, ", "), ctx.VSphereCluster.Namespace))
+	}
+	unavailableClasses, err := unavailableVMClasses(ctx, ctx.VSphereCluster.Namespace, classNames)
+	if err != nil {
+		return err
+	}
+	if len(unavailableClasses) > 0 {
+		reason = vmwarev1.VMClassUnavailableReason
+		messages = append([]string{fmt.Sprintf("VirtualMachineClasses %s are not bound to namespace %s",
+			strings.Join(unavailableClasses, ", "), ctx.VSphereCluster.Namespace)}, messages...)
+	}
+
+	if len(messages) == 0 {
+		conditions.MarkTrue(ctx.VSphereCluster, vmwarev1.VMResourcesAvailableCondition)
+		return nil
+	}
+
+	message := strings.Join(messages, "; ")
+	if c := conditions.Get(ctx.VSphereCluster, vmwarev1.VMResourcesAvailableCondition); c == nil || c.Status != corev1.ConditionFalse || c.Message != message {
+		ctx.Recorder.Warnf(ctx.VSphereCluster, "VMResourcesWithdrawn", "New machines of the cluster cannot be created: %s", message)
+	}
+	conditions.MarkFalse(ctx.VSphereCluster, vmwarev1.VMResourcesAvailableCondition, reason, clusterv1.ConditionSeverityError, message)
+	return nil
+}
+
+// usedVMResources returns the names of the VirtualMachineClasses and of the
+// storage classes used by the VSphereMachines of the cluster, and by the
+// VSphereMachineTemplates owned by the cluster, from which the machines of the
+// next scale up or rollout are created.
+func (r *ClusterReconciler) usedVMResources(ctx *vmware.ClusterContext) (map[string]struct{}, map[string]struct{}, error) {
+	classNames := map[string]struct{}{}
+	storageClasses := map[string]struct{}{}
+	addSpec := func(spec *vmwarev1.VSphereMachineSpec) {
+		if spec.ClassName != "" {
+			classNames[spec.ClassName] = struct{}{}
+		}
+		if spec.StorageClass != "" {
+			storageClasses[spec.StorageClass] = struct{}{}
+		}
+		for _, volume := range spec.Volumes {
+			if volume.StorageClass != "" {
+				storageClasses[volume.StorageClass] = struct{}{}
+			}
+		}
+	}
+
+	machines := &vmwarev1.VSphereMachineList{}
+	if err := ctx.Client.List(ctx, machines,
+		client.InNamespace(ctx.VSphereCluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: ctx.Cluster.Name}); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to list VSphereMachines of cluster %s/%s", ctx.Cluster.Namespace, ctx.Cluster.Name)
+	}
+	for i := range machines.Items {
+		if machines.Items[i].DeletionTimestamp.IsZero() {
+			addSpec(&machines.Items[i].Spec)
+		}
+	}
+
+	templates := &vmwarev1.VSphereMachineTemplateList{}
+	if err := ctx.Client.List(ctx, templates, client.InNamespace(ctx.VSphereCluster.Namespace)); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to list VSphereMachineTemplates in namespace %s", ctx.VSphereCluster.Namespace)
+	}
+	for i := range templates.Items {
+		if clusterutilv1.IsOwnedByObject(&templates.Items[i], ctx.Cluster) {
+			addSpec(&templates.Items[i].Spec.Template.Spec)
+		}
+	}
+	return classNames, storageClasses, nil
+}
+
+// unavailableVMClasses returns the sorted names of the VirtualMachineClasses
+// which are not bound to the namespace, or whose binding is being deleted.
+// Nothing is returned when vm-operator is not installed.
+func unavailableVMClasses(ctx *vmware.ClusterContext, namespace string, classNames map[string]struct{}) ([]string, error) {
+	if len(classNames) == 0 {
+		return nil, nil
+	}
+
+	bindings := &vmoprv1.VirtualMachineClassBindingList{}
+	if err := ctx.Client.List(ctx, bindings, client.InNamespace(namespace)); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to list VirtualMachineClassBindings in namespace %s", namespace)
+	}
+	bound := map[string]struct{}{}
+	for _, binding := range bindings.Items {
+		if binding.DeletionTimestamp.IsZero() {
+			bound[binding.ClassRef.Name] = struct{}{}
+		}
+	}
+
+	var unavailable []string
+	for className := range classNames {
+		if _, ok := bound[className]; !ok {
+			unavailable = append(unavailable, className)
+		}
+	}
+	sort.Strings(unavailable)
+	return unavailable, nil
+}
+
+// VMResourceToClusters returns the VSphereClusters in the namespace of a
+// VirtualMachineClassBinding or of a ResourceQuota, whose VMResourcesAvailable
+// condition may change with it.
+func (r *ClusterReconciler) VMResourceToClusters(o client.Object) []reconcile.Request {
+	clusters := &vmwarev1.VSphereClusterList{}
+	if err := r.Client.List(r, clusters, client.InNamespace(o.GetNamespace())); err != nil {
+		r.Logger.Error(err, "failed to list VSphereClusters", "namespace", o.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(clusters.Items))
+	for i := range clusters.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&clusters.Items[i])})
+	}
+	return requests
+}
@@ -22,21 +22,26 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	vmoprv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	clusterutilv1 "sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbldr "sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/controllers/vmware"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	inframanager "sigs.k8s.io/cluster-api-provider-vsphere/pkg/manager"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
@@ -92,20 +97,48 @@ func AddClusterControllerToManager(ctx *context.ControllerManagerContext, mgr ma
 			ControlPlaneService:   vmoperator.CPService{},
 			NetworkProvider:       networkProvider,
 		}
-		return ctrl.NewControllerManagedBy(mgr).
+		builder := ctrl.NewControllerManagedBy(mgr).
 			Named(controllerNameShort).
 			For(clusterControlledType).
 			Watches(
 				&source.Kind{Type: &vmwarev1.VSphereMachine{}},
 				handler.EnqueueRequestsFromMapFunc(reconciler.VSphereMachineToCluster),
 			).
-			WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
-			Complete(reconciler)
+			// Watch the quotas of the namespaces, to report the storage
+			// classes withdrawn from the clusters.
+			Watches(
+				&source.Kind{Type: &corev1.ResourceQuota{}},
+				handler.EnqueueRequestsFromMapFunc(reconciler.VMResourceToClusters),
+			).
+			WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles})
+
+		// Watch the class bindings of the namespaces, to report the classes
+		// withdrawn from the clusters, only when vm-operator has installed
+		// the VirtualMachineClassBinding type.
+		classBindingGVK := vmoprv1.SchemeGroupVersion.WithKind("VirtualMachineClassBinding")
+		if _, err := mgr.GetRESTMapper().RESTMapping(classBindingGVK.GroupKind(), classBindingGVK.Version); err == nil {
+			builder = builder.Watches(
+				&source.Kind{Type: &vmoprv1.VirtualMachineClassBinding{}},
+				handler.EnqueueRequestsFromMapFunc(reconciler.VMResourceToClusters),
+			)
+		}
+		return builder.Complete(reconciler)
 	}
 
-	reconciler := clusterReconciler{ControllerContext: controllerContext}
+	reconciler := clusterReconciler{
+		ControllerContext:       controllerContext,
+		clusterModuleReconciler: newClusterModuleReconciler(controllerContext),
+		endpointProbes:          newEndpointProbes(),
+	}
+	credentialsCache, err := newCredentialsSecretCache(ctx, mgr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the credentials secret cache")
+	}
+	if err := mgr.Add(credentialsCache); err != nil {
+		return errors.Wrapf(err, "failed to start the credentials secret cache")
+	}
 	clusterToInfraFn := clusterutilv1.ClusterToInfrastructureMapFunc(clusterControlledTypeGVK)
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		// Watch the controlled, infrastructure resource.
 		For(clusterControlledType).
 		// Watch the CAPI resource that owns this infrastructure resource.
@@ -144,6 +177,15 @@ func AddClusterControllerToManager(ctx *context.ControllerManagerContext, mgr ma
 			&source.Kind{Type: &infrav1.VSphereDeploymentZone{}},
 			handler.EnqueueRequestsFromMapFunc(reconciler.deploymentZoneToCluster),
 		).
+		// Watch the secrets holding the vCenter credentials of the clusters,
+		// to log in again as soon as the credentials are rotated. They are
+		// watched through their own cache, as they may not match the cache
+		// label selector of the manager.
+		Watches(
+			source.NewKindWithCache(&corev1.Secret{}, credentialsCache),
+			handler.EnqueueRequestsFromMapFunc(credentialsSecretMapper{ctx}.Map),
+			ctrlbldr.WithPredicates(credentialsSecretChanged),
+		).
 		// Watch a GenericEvent channel for the controlled resource.
 		//
 		// This is useful when there are events outside of Kubernetes that
@@ -154,6 +196,21 @@ func AddClusterControllerToManager(ctx *context.ControllerManagerContext, mgr ma
 			&handler.EnqueueRequestForObject{},
 		).
 		WithEventFilter(predicates.ResourceIsNotExternallyManaged(reconciler.Logger)).
-		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
-		Complete(reconciler)
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles})
+
+	if feature.Gates.Enabled(feature.NodeAntiAffinity) {
+		// Watch the objects owning the cluster modules so that the modules
+		// are created as soon as a control plane or a machine deployment is.
+		reconciler.clusterModuleReconciler.PopulateWatchesOnController(builder)
+	}
+	if ctx.CostWeights.Enabled() {
+		// Watch the VSphereMachines of the clusters so that the estimated
+		// cost of a cluster follows its machines.
+		builder.Watches(
+			&source.Kind{Type: &infrav1.VSphereMachine{}},
+			handler.EnqueueRequestsFromMapFunc(reconciler.machineToClusterCost),
+			ctrlbldr.WithPredicates(predicate.GenerationChangedPredicate{}),
+		)
+	}
+	return builder.Complete(reconciler)
 }
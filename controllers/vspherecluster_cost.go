@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// reconcileCost sets the sum of the estimated hourly costs of the
+// VSphereMachines of a cluster in the annotation of its VSphereCluster, or
+// removes the annotation if the cost is not estimated.
+func (r clusterReconciler) reconcileCost(ctx *context.ClusterContext) error {
+	vsphereCluster := ctx.VSphereCluster
+	if !r.CostWeights.Enabled() {
+		delete(vsphereCluster.Annotations, infrav1.ClusterEstimatedHourlyCostAnnotation)
+		return nil
+	}
+
+	machines := &infrav1.VSphereMachineList{}
+	if err := r.Client.List(ctx, machines,
+		client.InNamespace(ctx.Cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: ctx.Cluster.Name}); err != nil {
+		return errors.Wrapf(err, "failed to list the VSphereMachines of %s", ctx)
+	}
+	var cost float64
+	for i := range machines.Items {
+		cost += r.CostWeights.MachineHourlyCost(&machines.Items[i].Spec.VirtualMachineCloneSpec)
+	}
+	if vsphereCluster.Annotations == nil {
+		vsphereCluster.Annotations = map[string]string{}
+	}
+	vsphereCluster.Annotations[infrav1.ClusterEstimatedHourlyCostAnnotation] = context.FormatCost(cost)
+	return nil
+}
+
+// machineToClusterCost returns the VSphereCluster of the cluster of a
+// VSphereMachine, whose estimated cost follows the VSphereMachines.
+func (r clusterReconciler) machineToClusterCost(o client.Object) []reconcile.Request {
+	vsphereMachine, ok := o.(*infrav1.VSphereMachine)
+	if !ok {
+		return nil
+	}
+	cluster, err := clusterutilv1.GetClusterFromMetadata(r, r.Client, vsphereMachine.ObjectMeta)
+	if err != nil || cluster.Spec.InfrastructureRef == nil {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name},
+	}}
+}
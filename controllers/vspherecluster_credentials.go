@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// credentialsSecretMapper maps a secret holding vCenter credentials to the
+// VSphereClusters using it, through their identityRef of kind Secret, or
+// through a VSphereClusterIdentity referencing the secret. The VSphereClusters
+// are reconciled so that they log in with the rotated credentials right away,
+// instead of once the sessions logged in with the previous ones expire.
+type credentialsSecretMapper struct {
+	ctx *context.ControllerManagerContext
+}
+
+func (d credentialsSecretMapper) Map(o client.Object) []reconcile.Request {
+	// The secrets of the VSphereClusterIdentities are in the namespace of the
+	// controller.
+	identities := map[string]bool{}
+	if o.GetNamespace() == d.ctx.Namespace {
+		identityList := &infrav1.VSphereClusterIdentityList{}
+		if err := d.ctx.Client.List(d.ctx, identityList); err != nil {
+			d.ctx.Logger.Error(err, "unable to list VSphereClusterIdentities")
+			return nil
+		}
+		for _, identity := range identityList.Items {
+			if identity.Spec.SecretName == o.GetName() {
+				identities[identity.Name] = true
+			}
+		}
+	}
+
+	clusterList := &infrav1.VSphereClusterList{}
+	if err := d.ctx.Client.List(d.ctx, clusterList); err != nil {
+		d.ctx.Logger.Error(err, "unable to list VSphereClusters")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, cluster := range clusterList.Items {
+		ref := cluster.Spec.IdentityRef
+		if ref == nil {
+			continue
+		}
+		switch {
+		case ref.Kind == infrav1.SecretKind && cluster.Namespace == o.GetNamespace() && ref.Name == o.GetName():
+		case ref.Kind == infrav1.VSphereClusterIdentityKind && identities[ref.Name]:
+		default:
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Name}})
+	}
+	return requests
+}
+
+// newCredentialsSecretCache returns a cache of the secrets labeled with the
+// IdentitySecretLabel, in the namespaces watched by the manager. The cache of
+// the manager holds none of them when its cache label selector does not match
+// them, so their rotation is watched through this cache instead.
+func newCredentialsSecretCache(ctx *context.ControllerManagerContext, mgr manager.Manager) (cache.Cache, error) {
+	opts := cache.Options{
+		Scheme: mgr.GetScheme(),
+		Mapper: mgr.GetRESTMapper(),
+		SelectorsByObject: cache.SelectorsByObject{
+			&corev1.Secret{}: {Label: labels.SelectorFromSet(labels.Set{infrav1.IdentitySecretLabel: "true"})},
+		},
+		Namespace: ctx.WatchNamespace,
+	}
+	if len(ctx.WatchNamespaces) == 0 {
+		return cache.New(mgr.GetConfig(), opts)
+	}
+	namespaces := append([]string{ctx.Namespace}, ctx.WatchNamespaces...)
+	return cache.MultiNamespacedCacheBuilder(namespaces)(mgr.GetConfig(), opts)
+}
+
+// setIdentitySecretLabel labels a secret holding vCenter credentials, so that
+// its rotation is watched.
+func setIdentitySecretLabel(secret *corev1.Secret) {
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[infrav1.IdentitySecretLabel] = "true"
+}
+
+// credentialsSecretChanged filters the events of the secrets to the updates
+// of their data, so neither the resyncs of the secrets nor the initial list of
+// the secrets invalidate the vCenter sessions.
+var credentialsSecretChanged = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSecret, ok := e.ObjectOld.(*corev1.Secret)
+		if !ok {
+			return false
+		}
+		newSecret, ok := e.ObjectNew.(*corev1.Secret)
+		if !ok {
+			return false
+		}
+		return !reflect.DeepEqual(oldSecret.Data, newSecret.Data)
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
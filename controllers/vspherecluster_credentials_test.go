@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestCredentialsSecretMapper(t *testing.T) {
+	vsphereCluster := func(name string, ref *infrav1.VSphereIdentityReference) *infrav1.VSphereCluster {
+		return &infrav1.VSphereCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: name},
+			Spec:       infrav1.VSphereClusterSpec{Server: "vcenter", IdentityRef: ref},
+		}
+	}
+	secret := func(namespace, name string) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+	mgmtContext := fake.NewControllerManagerContext(
+		&infrav1.VSphereClusterIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "identity"},
+			Spec:       infrav1.VSphereClusterIdentitySpec{SecretName: "identity-creds"},
+		},
+		vsphereCluster("with-secret", &infrav1.VSphereIdentityReference{Kind: infrav1.SecretKind, Name: "creds"}),
+		vsphereCluster("with-identity", &infrav1.VSphereIdentityReference{Kind: infrav1.VSphereClusterIdentityKind, Name: "identity"}),
+		vsphereCluster("with-manager-creds", nil),
+	)
+	mapper := credentialsSecretMapper{mgmtContext}
+
+	tests := []struct {
+		name     string
+		secret   *corev1.Secret
+		expected []reconcile.Request
+	}{
+		{
+			name:     "secret of a VSphereCluster",
+			secret:   secret(fake.Namespace, "creds"),
+			expected: []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: fake.Namespace, Name: "with-secret"}}},
+		},
+		{
+			name:     "secret of a VSphereClusterIdentity",
+			secret:   secret(fake.ControllerManagerNamespace, "identity-creds"),
+			expected: []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: fake.Namespace, Name: "with-identity"}}},
+		},
+		{
+			name:   "secret with the name of the secret of a VSphereClusterIdentity, in another namespace",
+			secret: secret(fake.Namespace, "identity-creds"),
+		},
+		{
+			name:   "unrelated secret",
+			secret: secret(fake.Namespace, "other"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(mapper.Map(tt.secret)).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCredentialsSecretChanged(t *testing.T) {
+	g := NewWithT(t)
+	secret := func(password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "creds"},
+			Data:       map[string][]byte{"username": []byte("user"), "password": []byte(password)},
+		}
+	}
+
+	g.Expect(credentialsSecretChanged.Update(event.UpdateEvent{ObjectOld: secret("old"), ObjectNew: secret("new")})).To(BeTrue())
+	g.Expect(credentialsSecretChanged.Update(event.UpdateEvent{ObjectOld: secret("old"), ObjectNew: secret("old")})).To(BeFalse())
+	g.Expect(credentialsSecretChanged.Create(event.CreateEvent{Object: secret("new")})).To(BeFalse())
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	apiv1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/ipam"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/avi"
+	infrautilv1 "sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+// defaultControlPlaneEndpointPort is the port of a provisioned control plane
+// endpoint when none is set.
+const defaultControlPlaneEndpointPort = 6443
+
+// controlPlaneEndpointClaimName returns the name of the IPAddressClaim of the
+// virtual IP address advertised by kube-vip.
+func controlPlaneEndpointClaimName(vsphereCluster *infrav1.VSphereCluster) string {
+	return vsphereCluster.Name + "-control-plane-endpoint"
+}
+
+// aviVirtualServiceName returns the name of the virtual service, VsVip and
+// pool of the control plane endpoint, which are unique in a tenant.
+func aviVirtualServiceName(vsphereCluster *infrav1.VSphereCluster) string {
+	return fmt.Sprintf("%s-%s-control-plane", vsphereCluster.Namespace, vsphereCluster.Name)
+}
+
+// reconcileControlPlaneEndpoint provisions the control plane endpoint of the
+// cluster when spec.controlPlaneEndpointProvider is set. It returns false
+// while the address of the endpoint is not allocated yet.
+//
+// An endpoint set in the spec without being recorded in the status was set by
+// the user and is left as is.
+func (r clusterReconciler) reconcileControlPlaneEndpoint(ctx *context.ClusterContext) (bool, error) {
+	provider := ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider
+	if provider == nil {
+		conditions.Delete(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition)
+		return true, nil
+	}
+	if !ctx.VSphereCluster.Spec.ControlPlaneEndpoint.IsZero() && ctx.VSphereCluster.Status.ControlPlaneEndpoint == nil {
+		conditions.MarkTrue(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition)
+		return true, nil
+	}
+	if (provider.KubeVIP == nil) == (provider.AVI == nil) {
+		err := errors.New("exactly one of kubeVIP and avi must be set in spec.controlPlaneEndpointProvider")
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition, infrav1.ControlPlaneEndpointProvisioningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return false, err
+	}
+
+	var (
+		host string
+		err  error
+	)
+	if provider.KubeVIP != nil {
+		host, err = r.reconcileKubeVIPAddress(ctx, provider.KubeVIP, controlPlaneEndpointClaimName(ctx.VSphereCluster))
+		// The failover is only added to the endpoint of a new cluster, as the
+		// endpoint of the Cluster is not updated afterwards.
+		failover := ctx.VSphereCluster.Status.ControlPlaneEndpoint == nil || ctx.VSphereCluster.Status.ControlPlaneEndpointFailover != nil
+		if err == nil && host != "" && provider.KubeVIP.Failover != nil && failover {
+			host, err = r.reconcileKubeVIPFailoverAddresses(ctx, provider.KubeVIP, host)
+		}
+	} else {
+		host, err = r.reconcileAVIVirtualService(ctx, provider.AVI, controlPlaneEndpointPort(provider))
+	}
+	if err != nil {
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition, infrav1.ControlPlaneEndpointProvisioningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return false, errors.Wrapf(err, "failed to provision the control plane endpoint of %s", ctx)
+	}
+	if host == "" {
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition, infrav1.WaitingForControlPlaneEndpointAddressReason, clusterv1.ConditionSeverityInfo, "")
+		return false, nil
+	}
+
+	endpoint := infrav1.APIEndpoint{Host: host, Port: controlPlaneEndpointPort(provider)}
+	if ctx.VSphereCluster.Spec.ControlPlaneEndpoint != endpoint {
+		ctx.Logger.Info("Provisioned the control plane endpoint", "controlPlaneEndpoint", endpoint.String())
+	}
+	ctx.VSphereCluster.Spec.ControlPlaneEndpoint = endpoint
+	ctx.VSphereCluster.Status.ControlPlaneEndpoint = &endpoint
+	conditions.MarkTrue(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition)
+	return true, nil
+}
+
+// reconcileKubeVIPAddress claims the virtual IP address advertised by kube-vip
+// from its IPAM pool. It returns an empty address until the claim is bound.
+func (r clusterReconciler) reconcileKubeVIPAddress(ctx *context.ClusterContext, spec *infrav1.KubeVIPEndpointSpec, claimName string) (string, error) {
+	address, err := ipam.ReconcileClaim(ctx, ctx.Client, ctx.VSphereCluster, infrav1.GroupVersion.WithKind("VSphereCluster"),
+		claimName, spec.AddressFromPool)
+	if err != nil || address == nil {
+		return "", err
+	}
+	ip, _, err := net.ParseCIDR(address.Address)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid address %q claimed for the control plane endpoint", address.Address)
+	}
+	return ip.String(), nil
+}
+
+// reconcileAVIVirtualService ensures the virtual service of the control plane
+// endpoint balances the API servers of the control plane machines with an
+// address. It returns the virtual IP address of the virtual service, which is
+// empty until the controller allocates it.
+func (r clusterReconciler) reconcileAVIVirtualService(ctx *context.ClusterContext, spec *infrav1.AVIEndpointSpec, port int32) (string, error) {
+	aviClient, err := r.getAVIClient(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+
+	vsphereMachines, err := infrautilv1.GetVSphereMachinesInCluster(ctx, ctx.Client, ctx.Cluster.Namespace, ctx.Cluster.Name)
+	if err != nil {
+		return "", errors.Wrapf(err,
+			"unable to list VSphereMachines part of VSphereCluster %s/%s", ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name)
+	}
+	servers := []string{}
+	for _, vsphereMachine := range vsphereMachines {
+		if !infrautilv1.IsControlPlaneMachine(vsphereMachine) || !vsphereMachine.DeletionTimestamp.IsZero() {
+			continue
+		}
+		ip, err := infrautilv1.GetMachinePreferredIPAddress(vsphereMachine)
+		if err != nil {
+			continue
+		}
+		servers = append(servers, ip)
+	}
+
+	return aviClient.EnsureVirtualService(ctx, aviVirtualServiceName(ctx.VSphereCluster), port, servers)
+}
+
+// reconcileControlPlaneEndpointDelete releases the address of the control
+// plane endpoint once the VMs of the cluster are deleted. Like the deletion of
+// the managed tags, it is best effort, so a load balancer which cannot be
+// reached does not block the deletion of the cluster.
+func (r clusterReconciler) reconcileControlPlaneEndpointDelete(ctx *context.ClusterContext) {
+	if r.endpointProbes != nil {
+		r.endpointProbes.forget(ctx.VSphereCluster.UID)
+	}
+	provider := ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider
+	if provider == nil || ctx.VSphereCluster.Status.ControlPlaneEndpoint == nil && provider.AVI == nil {
+		return
+	}
+
+	if provider.KubeVIP != nil {
+		claimNames := []string{controlPlaneEndpointClaimName(ctx.VSphereCluster)}
+		if provider.KubeVIP.Failover != nil {
+			for i := 1; i <= int(failoverAlternates(provider.KubeVIP.Failover)); i++ {
+				claimNames = append(claimNames, controlPlaneEndpointAlternateClaimName(ctx.VSphereCluster, i))
+			}
+		}
+		for _, claimName := range claimNames {
+			if err := ipam.ReleaseClaim(ctx, ctx.Client, ctx.VSphereCluster.Namespace, claimName); err != nil {
+				ctx.Logger.Error(err, "failed to release the address of the control plane endpoint", "claim", claimName)
+			}
+		}
+	}
+	if provider.AVI != nil {
+		aviClient, err := r.getAVIClient(ctx, provider.AVI)
+		if err != nil {
+			ctx.Logger.Error(err, "failed to delete the virtual service of the control plane endpoint")
+			return
+		}
+		if err := aviClient.DeleteVirtualService(ctx, aviVirtualServiceName(ctx.VSphereCluster)); err != nil {
+			ctx.Logger.Error(err, "failed to delete the virtual service of the control plane endpoint")
+		}
+	}
+}
+
+// getAVIClient returns a client of the NSX Advanced Load Balancer controller
+// authenticated with the credentials of its Secret.
+func (r clusterReconciler) getAVIClient(ctx *context.ClusterContext, spec *infrav1.AVIEndpointSpec) (*avi.Client, error) {
+	secret := &apiv1.Secret{}
+	secretKey := client.ObjectKey{Namespace: ctx.VSphereCluster.Namespace, Name: spec.CredentialsSecretName}
+	if err := ctx.Client.Get(ctx, secretKey, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to get the credentials of the load balancer controller from Secret %s", secretKey)
+	}
+	username, password := string(secret.Data["username"]), string(secret.Data["password"])
+	if username == "" || password == "" {
+		return nil, errors.Errorf("Secret %s must set the username and password of the load balancer controller", secretKey)
+	}
+	return avi.NewClient(*spec, username, password)
+}
+
+func controlPlaneEndpointPort(provider *infrav1.ControlPlaneEndpointProviderSpec) int32 {
+	if provider.Port == 0 {
+		return defaultControlPlaneEndpointPort
+	}
+	return provider.Port
+}
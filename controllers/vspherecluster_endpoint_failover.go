@@ -0,0 +1,373 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// +kubebuilder:rbac:groups=externaldns.k8s.io,resources=dnsendpoints,verbs=get;list;watch;create;update;patch
+
+const (
+	defaultFailoverAlternates       = 1
+	defaultFailoverPeriod           = 30 * time.Second
+	defaultFailoverTimeout          = 5 * time.Second
+	defaultFailoverFailureThreshold = 3
+
+	// dnsRecordTTL is the TTL of the DNS record of the hostname of an endpoint
+	// with failover, short enough for the clients to follow a promotion.
+	dnsRecordTTL = 30 * time.Second
+)
+
+var (
+	// probeControlPlaneAddress dials the API servers of a cluster through an
+	// address of its control plane endpoint.
+	probeControlPlaneAddress = func(ctx goctx.Context, address string, port int32, timeout time.Duration) error {
+		dialer := net.Dialer{Timeout: timeout}
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, strconv.Itoa(int(port))))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	// lookupHost resolves the hostname of a control plane endpoint.
+	lookupHost = net.DefaultResolver.LookupHost
+
+	// dnsEndpointGVK is the GroupVersionKind of the DNSEndpoint type of
+	// ExternalDNS.
+	dnsEndpointGVK = schema.GroupVersionKind{Group: "externaldns.k8s.io", Version: "v1alpha1", Kind: "DNSEndpoint"}
+)
+
+// controlPlaneEndpointAlternateClaimName returns the name of the
+// IPAddressClaim of an alternate virtual IP address advertised by kube-vip.
+func controlPlaneEndpointAlternateClaimName(vsphereCluster *infrav1.VSphereCluster, i int) string {
+	return fmt.Sprintf("%s-%d", controlPlaneEndpointClaimName(vsphereCluster), i)
+}
+
+// reconcileKubeVIPFailoverAddresses claims the alternate virtual IP addresses
+// of an endpoint with failover, and records them after the claimed address in
+// the status of the VSphereCluster. It returns the hostname of the endpoint,
+// or an empty string until all the claims are bound.
+func (r clusterReconciler) reconcileKubeVIPFailoverAddresses(ctx *context.ClusterContext, spec *infrav1.KubeVIPEndpointSpec, address string) (string, error) {
+	addresses := []string{address}
+	for i := 1; i <= int(failoverAlternates(spec.Failover)); i++ {
+		alternate, err := r.reconcileKubeVIPAddress(ctx, spec, controlPlaneEndpointAlternateClaimName(ctx.VSphereCluster, i))
+		if err != nil || alternate == "" {
+			return "", err
+		}
+		addresses = append(addresses, alternate)
+	}
+
+	status := ctx.VSphereCluster.Status.ControlPlaneEndpointFailover
+	if status == nil {
+		status = &infrav1.ControlPlaneEndpointFailoverStatus{}
+		ctx.VSphereCluster.Status.ControlPlaneEndpointFailover = status
+	}
+	status.Addresses = addresses
+	if !containsString(addresses, status.ActiveAddress) {
+		status.ActiveAddress = address
+	}
+	return spec.Failover.Hostname, nil
+}
+
+// reconcileControlPlaneEndpointFailover probes the addresses of a control plane
+// endpoint with failover once its period elapsed, and promotes the next
+// reachable address once the active one failed FailureThreshold consecutive
+// probes. The probes run in the background, and their results are applied by
+// the reconciliation following their completion, so that unreachable
+// addresses do not hold back the reconciliation. It returns the duration
+// until the next probe or its results, or zero when the endpoint has no
+// failover.
+func (r clusterReconciler) reconcileControlPlaneEndpointFailover(ctx *context.ClusterContext) time.Duration {
+	provider := ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider
+	status := ctx.VSphereCluster.Status.ControlPlaneEndpointFailover
+	if provider == nil || provider.KubeVIP == nil || provider.KubeVIP.Failover == nil || status == nil {
+		conditions.Delete(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition)
+		return 0
+	}
+	spec := provider.KubeVIP.Failover
+
+	// The addresses are not advertised until the control plane is initialized.
+	period := failoverPeriod(spec)
+	if !conditions.IsTrue(ctx.Cluster, clusterv1.ControlPlaneInitializedCondition) {
+		return period
+	}
+
+	timeout := failoverTimeout(spec)
+	result, running := r.endpointProbes.result(ctx.VSphereCluster.UID)
+	if running {
+		return time.Second
+	}
+	if result == nil {
+		if status.LastProbeTime != nil {
+			if next := status.LastProbeTime.Add(period); time.Now().Before(next) {
+				return time.Until(next)
+			}
+		}
+		now := metav1.Now()
+		status.LastProbeTime = &now
+		r.endpointProbes.start(ctx.VSphereCluster.UID, spec.Hostname, status.Addresses, ctx.VSphereCluster.Spec.ControlPlaneEndpoint.Port, timeout)
+		return timeout + time.Second
+	}
+
+	next := period
+	if status.LastProbeTime != nil {
+		next = time.Until(status.LastProbeTime.Add(period))
+	}
+	// The addresses changed while they were probed.
+	if !result.probed(status.ActiveAddress) {
+		status.LastProbeTime = nil
+		return time.Second
+	}
+	if err := result.errs[status.ActiveAddress]; err != nil {
+		status.ConsecutiveFailures++
+		ctx.Logger.Info("failed to probe the control plane endpoint",
+			"address", status.ActiveAddress, "consecutiveFailures", status.ConsecutiveFailures, "error", err.Error())
+		if status.ConsecutiveFailures < failoverFailureThreshold(spec) {
+			return next
+		}
+		if !r.promoteControlPlaneEndpointAddress(ctx, status, result) {
+			conditions.MarkFalse(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition, infrav1.ControlPlaneEndpointUnreachableReason, clusterv1.ConditionSeverityWarning,
+				"none of the addresses %v of the control plane endpoint is reachable", status.Addresses)
+			return next
+		}
+	}
+	status.ConsecutiveFailures = 0
+
+	if spec.ManageDNSRecord {
+		if err := r.reconcileControlPlaneEndpointDNSRecord(ctx, spec.Hostname, status.ActiveAddress); err != nil {
+			ctx.Logger.Error(err, "failed to update the DNS record of the control plane endpoint")
+			conditions.MarkFalse(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition, infrav1.ControlPlaneEndpointDNSRecordFailedReason, clusterv1.ConditionSeverityWarning,
+				"failed to update the DNS record of %s: %v", spec.Hostname, err)
+			return next
+		}
+	}
+
+	switch {
+	case result.lookupErr != nil:
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition, infrav1.ControlPlaneEndpointHostnameMismatchReason, clusterv1.ConditionSeverityWarning,
+			"failed to resolve %s: %v", spec.Hostname, result.lookupErr)
+	case !containsString(result.resolved, status.ActiveAddress):
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition, infrav1.ControlPlaneEndpointHostnameMismatchReason, clusterv1.ConditionSeverityWarning,
+			"%s resolves to %v instead of the active address %s", spec.Hostname, result.resolved, status.ActiveAddress)
+	default:
+		conditions.MarkTrue(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition)
+	}
+	return next
+}
+
+// promoteControlPlaneEndpointAddress makes the first address following the
+// active one which was reachable in the probe the active address. It returns
+// false when none of the other addresses was reachable.
+func (r clusterReconciler) promoteControlPlaneEndpointAddress(ctx *context.ClusterContext, status *infrav1.ControlPlaneEndpointFailoverStatus, result *endpointProbeResult) bool {
+	active := 0
+	for i, address := range status.Addresses {
+		if address == status.ActiveAddress {
+			active = i
+		}
+	}
+	for i := 1; i < len(status.Addresses); i++ {
+		address := status.Addresses[(active+i)%len(status.Addresses)]
+		if err := result.errs[address]; err != nil || !result.probed(address) {
+			ctx.Logger.Info("failed to probe an alternate address of the control plane endpoint", "address", address, "error", fmt.Sprint(err))
+			continue
+		}
+
+		r.Recorder.Warnf(ctx.VSphereCluster, "ControlPlaneEndpointFailover",
+			"Promoted address %s of the control plane endpoint, %s is unreachable", address, status.ActiveAddress)
+		now := metav1.Now()
+		status.ActiveAddress = address
+		status.LastFailoverTime = &now
+		return true
+	}
+	return false
+}
+
+// reconcileControlPlaneEndpointDNSRecord creates or updates the ExternalDNS
+// DNSEndpoint resolving the hostname of the control plane endpoint to its
+// active address. The DNSEndpoint is owned by the VSphereCluster.
+func (r clusterReconciler) reconcileControlPlaneEndpointDNSRecord(ctx *context.ClusterContext, hostname, address string) error {
+	dnsEndpoint := &unstructured.Unstructured{}
+	dnsEndpoint.SetGroupVersionKind(dnsEndpointGVK)
+	dnsEndpoint.SetNamespace(ctx.VSphereCluster.Namespace)
+	dnsEndpoint.SetName(controlPlaneEndpointClaimName(ctx.VSphereCluster))
+	_, err := ctrlutil.CreateOrPatch(ctx, ctx.Client, dnsEndpoint, func() error {
+		dnsEndpoint.SetOwnerReferences(clusterutilv1.EnsureOwnerRef(dnsEndpoint.GetOwnerReferences(), metav1.OwnerReference{
+			APIVersion: infrav1.GroupVersion.String(),
+			Kind:       "VSphereCluster",
+			Name:       ctx.VSphereCluster.Name,
+			UID:        ctx.VSphereCluster.UID,
+			Controller: pointer.Bool(true),
+		}))
+		return unstructured.SetNestedSlice(dnsEndpoint.Object, []interface{}{
+			map[string]interface{}{
+				"dnsName":    hostname,
+				"recordType": "A",
+				"recordTTL":  int64(dnsRecordTTL.Seconds()),
+				"targets":    []interface{}{address},
+			},
+		}, "spec", "endpoints")
+	})
+	return errors.Wrapf(err, "failed to reconcile DNSEndpoint %s/%s", dnsEndpoint.GetNamespace(), dnsEndpoint.GetName())
+}
+
+// endpointProbes runs the probes of the control plane endpoints with failover
+// in the background, one at a time per VSphereCluster.
+type endpointProbes struct {
+	mu      sync.Mutex
+	results map[types.UID]*endpointProbeResult
+}
+
+// endpointProbeResult is the result of the probes of the addresses of a
+// control plane endpoint, and of the resolution of its hostname.
+type endpointProbeResult struct {
+	done      bool
+	errs      map[string]error
+	resolved  []string
+	lookupErr error
+}
+
+// probed returns whether the address was probed.
+func (p *endpointProbeResult) probed(address string) bool {
+	_, ok := p.errs[address]
+	return ok
+}
+
+func newEndpointProbes() *endpointProbes {
+	return &endpointProbes{results: map[types.UID]*endpointProbeResult{}}
+}
+
+// start probes the addresses of the endpoint of a VSphereCluster, and resolves
+// its hostname, in the background, each within the timeout. It does nothing
+// while a probe of the endpoint is running.
+func (p *endpointProbes) start(uid types.UID, hostname string, addresses []string, port int32, timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.results[uid]; ok {
+		return
+	}
+	p.results[uid] = &endpointProbeResult{}
+
+	probe, lookup := probeControlPlaneAddress, lookupHost
+	go func() {
+		ctx, cancel := goctx.WithTimeout(goctx.Background(), timeout)
+		defer cancel()
+		result := &endpointProbeResult{done: true, errs: map[string]error{}}
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		for _, address := range addresses {
+			wg.Add(1)
+			go func(address string) {
+				defer wg.Done()
+				err := probe(ctx, address, port, timeout)
+				mu.Lock()
+				defer mu.Unlock()
+				result.errs[address] = err
+			}(address)
+		}
+		result.resolved, result.lookupErr = lookup(ctx, hostname)
+		wg.Wait()
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if _, ok := p.results[uid]; ok {
+			p.results[uid] = result
+		}
+	}()
+}
+
+// result returns the result of the completed probe of the endpoint of a
+// VSphereCluster, which is forgotten, or whether a probe is still running.
+func (p *endpointProbes) result(uid types.UID) (*endpointProbeResult, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result, ok := p.results[uid]
+	switch {
+	case !ok:
+		return nil, false
+	case !result.done:
+		return nil, true
+	}
+	delete(p.results, uid)
+	return result, false
+}
+
+// forget forgets the probe of the endpoint of a deleted VSphereCluster.
+func (p *endpointProbes) forget(uid types.UID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.results, uid)
+}
+
+func failoverAlternates(spec *infrav1.KubeVIPFailoverSpec) int32 {
+	if spec.Alternates == 0 {
+		return defaultFailoverAlternates
+	}
+	return spec.Alternates
+}
+
+func failoverPeriod(spec *infrav1.KubeVIPFailoverSpec) time.Duration {
+	if spec.Period == nil {
+		return defaultFailoverPeriod
+	}
+	return spec.Period.Duration
+}
+
+func failoverTimeout(spec *infrav1.KubeVIPFailoverSpec) time.Duration {
+	if spec.Timeout == nil {
+		return defaultFailoverTimeout
+	}
+	return spec.Timeout.Duration
+}
+
+func failoverFailureThreshold(spec *infrav1.KubeVIPFailoverSpec) int32 {
+	if spec.FailureThreshold == 0 {
+		return defaultFailoverFailureThreshold
+	}
+	return spec.FailureThreshold
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
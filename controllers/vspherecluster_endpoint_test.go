@@ -0,0 +1,337 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/ipam"
+)
+
+func TestClusterReconciler_ReconcileControlPlaneEndpoint(t *testing.T) {
+	kubeVIP := func() *infrav1.ControlPlaneEndpointProviderSpec {
+		return &infrav1.ControlPlaneEndpointProviderSpec{
+			KubeVIP: &infrav1.KubeVIPEndpointSpec{
+				AddressFromPool: corev1.TypedLocalObjectReference{
+					APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "vip-pool",
+				},
+			},
+		}
+	}
+
+	t.Run("without a provider", func(t *testing.T) {
+		g := NewWithT(t)
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		ctx := fake.NewClusterContext(controllerCtx)
+		r := clusterReconciler{ControllerContext: controllerCtx}
+
+		ok, err := r.reconcileControlPlaneEndpoint(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(conditions.Has(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition)).To(BeFalse())
+	})
+
+	t.Run("with an endpoint set by the user", func(t *testing.T) {
+		g := NewWithT(t)
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		ctx := fake.NewClusterContext(controllerCtx)
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider = kubeVIP()
+		ctx.VSphereCluster.Spec.ControlPlaneEndpoint = infrav1.APIEndpoint{Host: "10.0.0.100", Port: 443}
+		r := clusterReconciler{ControllerContext: controllerCtx}
+
+		ok, err := r.reconcileControlPlaneEndpoint(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereCluster.Spec.ControlPlaneEndpoint).To(Equal(infrav1.APIEndpoint{Host: "10.0.0.100", Port: 443}))
+		g.Expect(ctx.VSphereCluster.Status.ControlPlaneEndpoint).To(BeNil())
+	})
+
+	t.Run("with both kube-vip and avi", func(t *testing.T) {
+		g := NewWithT(t)
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		ctx := fake.NewClusterContext(controllerCtx)
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider = kubeVIP()
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider.AVI = &infrav1.AVIEndpointSpec{Controller: "https://avi.example.com"}
+		r := clusterReconciler{ControllerContext: controllerCtx}
+
+		_, err := r.reconcileControlPlaneEndpoint(ctx)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(conditions.GetReason(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition)).To(Equal(infrav1.ControlPlaneEndpointProvisioningFailedReason))
+	})
+
+	t.Run("with kube-vip", func(t *testing.T) {
+		g := NewWithT(t)
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		ctx := fake.NewClusterContext(controllerCtx)
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider = kubeVIP()
+		r := clusterReconciler{ControllerContext: controllerCtx}
+
+		// The claim is created but not yet bound.
+		ok, err := r.reconcileControlPlaneEndpoint(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(conditions.GetReason(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition)).To(Equal(infrav1.WaitingForControlPlaneEndpointAddressReason))
+
+		claim := &unstructured.Unstructured{}
+		claim.SetGroupVersionKind(ipam.IPAddressClaimGVK)
+		claimKey := client.ObjectKey{Namespace: fake.Namespace, Name: controlPlaneEndpointClaimName(ctx.VSphereCluster)}
+		g.Expect(ctx.Client.Get(ctx, claimKey, claim)).To(Succeed())
+		g.Expect(claim.GetOwnerReferences()).To(HaveLen(1))
+		g.Expect(claim.GetOwnerReferences()[0].Kind).To(Equal("VSphereCluster"))
+
+		// Bind the claim to an address as an IPAM provider would.
+		address := &unstructured.Unstructured{}
+		address.SetGroupVersionKind(ipam.IPAddressGVK)
+		address.SetNamespace(fake.Namespace)
+		address.SetName("vip-address")
+		g.Expect(unstructured.SetNestedField(address.Object, "10.0.0.10", "spec", "address")).To(Succeed())
+		g.Expect(unstructured.SetNestedField(address.Object, int64(24), "spec", "prefix")).To(Succeed())
+		g.Expect(ctx.Client.Create(ctx, address)).To(Succeed())
+		g.Expect(unstructured.SetNestedField(claim.Object, "vip-address", "status", "addressRef", "name")).To(Succeed())
+		g.Expect(ctx.Client.Update(ctx, claim)).To(Succeed())
+
+		ok, err = r.reconcileControlPlaneEndpoint(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		endpoint := infrav1.APIEndpoint{Host: "10.0.0.10", Port: 6443}
+		g.Expect(ctx.VSphereCluster.Spec.ControlPlaneEndpoint).To(Equal(endpoint))
+		g.Expect(ctx.VSphereCluster.Status.ControlPlaneEndpoint).To(Equal(&endpoint))
+		g.Expect(conditions.IsTrue(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition)).To(BeTrue())
+
+		// Deleting the cluster releases the address.
+		r.reconcileControlPlaneEndpointDelete(ctx)
+		g.Expect(ctx.Client.Get(ctx, claimKey, claim)).NotTo(Succeed())
+	})
+
+	t.Run("with avi credentials missing", func(t *testing.T) {
+		g := NewWithT(t)
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		ctx := fake.NewClusterContext(controllerCtx)
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider = &infrav1.ControlPlaneEndpointProviderSpec{
+			AVI: &infrav1.AVIEndpointSpec{Controller: "https://avi.example.com", CredentialsSecretName: "avi-credentials", Network: "vip"},
+		}
+		r := clusterReconciler{ControllerContext: controllerCtx}
+
+		ok, err := r.reconcileControlPlaneEndpoint(ctx)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		condition := conditions.Get(ctx.VSphereCluster, infrav1.ControlPlaneEndpointReadyCondition)
+		g.Expect(condition.Reason).To(Equal(infrav1.ControlPlaneEndpointProvisioningFailedReason))
+		g.Expect(condition.Severity).To(Equal(clusterv1.ConditionSeverityWarning))
+	})
+}
+
+func TestClusterReconciler_ReconcileControlPlaneEndpointFailover(t *testing.T) {
+	kubeVIP := func() *infrav1.ControlPlaneEndpointProviderSpec {
+		return &infrav1.ControlPlaneEndpointProviderSpec{
+			KubeVIP: &infrav1.KubeVIPEndpointSpec{
+				AddressFromPool: corev1.TypedLocalObjectReference{
+					APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "vip-pool",
+				},
+				Failover: &infrav1.KubeVIPFailoverSpec{Hostname: "api.workload.example.com"},
+			},
+		}
+	}
+
+	t.Run("claims the alternate addresses", func(t *testing.T) {
+		g := NewWithT(t)
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		ctx := fake.NewClusterContext(controllerCtx)
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider = kubeVIP()
+		r := clusterReconciler{ControllerContext: controllerCtx}
+
+		bind := func(claimName, ip string) {
+			claim := &unstructured.Unstructured{}
+			claim.SetGroupVersionKind(ipam.IPAddressClaimGVK)
+			g.Expect(ctx.Client.Get(ctx, client.ObjectKey{Namespace: fake.Namespace, Name: claimName}, claim)).To(Succeed())
+			address := &unstructured.Unstructured{}
+			address.SetGroupVersionKind(ipam.IPAddressGVK)
+			address.SetNamespace(fake.Namespace)
+			address.SetName(claimName)
+			g.Expect(unstructured.SetNestedField(address.Object, ip, "spec", "address")).To(Succeed())
+			g.Expect(unstructured.SetNestedField(address.Object, int64(24), "spec", "prefix")).To(Succeed())
+			g.Expect(ctx.Client.Create(ctx, address)).To(Succeed())
+			g.Expect(unstructured.SetNestedField(claim.Object, claimName, "status", "addressRef", "name")).To(Succeed())
+			g.Expect(ctx.Client.Update(ctx, claim)).To(Succeed())
+		}
+
+		ok, err := r.reconcileControlPlaneEndpoint(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		bind(controlPlaneEndpointClaimName(ctx.VSphereCluster), "10.0.0.10")
+
+		// The endpoint waits for the alternate address.
+		ok, err = r.reconcileControlPlaneEndpoint(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		bind(controlPlaneEndpointAlternateClaimName(ctx.VSphereCluster, 1), "10.0.0.11")
+
+		ok, err = r.reconcileControlPlaneEndpoint(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		endpoint := infrav1.APIEndpoint{Host: "api.workload.example.com", Port: 6443}
+		g.Expect(ctx.VSphereCluster.Spec.ControlPlaneEndpoint).To(Equal(endpoint))
+		g.Expect(ctx.VSphereCluster.Status.ControlPlaneEndpointFailover.Addresses).To(Equal([]string{"10.0.0.10", "10.0.0.11"}))
+		g.Expect(ctx.VSphereCluster.Status.ControlPlaneEndpointFailover.ActiveAddress).To(Equal("10.0.0.10"))
+
+		// Deleting the cluster releases all the addresses.
+		r.reconcileControlPlaneEndpointDelete(ctx)
+		claim := &unstructured.Unstructured{}
+		claim.SetGroupVersionKind(ipam.IPAddressClaimGVK)
+		g.Expect(ctx.Client.Get(ctx, client.ObjectKey{Namespace: fake.Namespace, Name: controlPlaneEndpointAlternateClaimName(ctx.VSphereCluster, 1)}, claim)).NotTo(Succeed())
+	})
+
+	t.Run("promotes an alternate address", func(t *testing.T) {
+		probe, lookup := probeControlPlaneAddress, lookupHost
+		defer func() {
+			probeControlPlaneAddress, lookupHost = probe, lookup
+		}()
+		reachable := map[string]bool{"10.0.0.10": true, "10.0.0.11": true}
+		probeControlPlaneAddress = func(_ goctx.Context, address string, port int32, _ time.Duration) error {
+			if !reachable[address] || port != 6443 {
+				return errors.Errorf("connection to %s refused", address)
+			}
+			return nil
+		}
+		resolved := []string{"10.0.0.10"}
+		lookupHost = func(_ goctx.Context, host string) ([]string, error) {
+			return resolved, nil
+		}
+
+		g := NewWithT(t)
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		ctx := fake.NewClusterContext(controllerCtx)
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider = kubeVIP()
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider.KubeVIP.Failover.FailureThreshold = 2
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider.KubeVIP.Failover.ManageDNSRecord = true
+		ctx.VSphereCluster.Spec.ControlPlaneEndpoint = infrav1.APIEndpoint{Host: "api.workload.example.com", Port: 6443}
+		ctx.VSphereCluster.Status.ControlPlaneEndpointFailover = &infrav1.ControlPlaneEndpointFailoverStatus{
+			Addresses:     []string{"10.0.0.10", "10.0.0.11"},
+			ActiveAddress: "10.0.0.10",
+		}
+		status := ctx.VSphereCluster.Status.ControlPlaneEndpointFailover
+		r := clusterReconciler{ControllerContext: controllerCtx, endpointProbes: newEndpointProbes()}
+		// runProbe starts a probe of the addresses, and applies its results
+		// once it completes in the background.
+		runProbe := func() {
+			status.LastProbeTime = nil
+			g.Expect(r.reconcileControlPlaneEndpointFailover(ctx)).To(Equal(defaultFailoverTimeout + time.Second))
+			g.Eventually(func() bool {
+				r.endpointProbes.mu.Lock()
+				defer r.endpointProbes.mu.Unlock()
+				return r.endpointProbes.results[ctx.VSphereCluster.UID].done
+			}).Should(BeTrue())
+			g.Expect(r.reconcileControlPlaneEndpointFailover(ctx)).To(BeNumerically("<=", defaultFailoverPeriod))
+		}
+		// dnsTargets returns the targets of the DNS record of the hostname.
+		dnsTargets := func() []interface{} {
+			dnsEndpoint := &unstructured.Unstructured{}
+			dnsEndpoint.SetGroupVersionKind(dnsEndpointGVK)
+			g.Expect(ctx.Client.Get(ctx, client.ObjectKey{Namespace: fake.Namespace, Name: controlPlaneEndpointClaimName(ctx.VSphereCluster)}, dnsEndpoint)).To(Succeed())
+			endpoints, _, err := unstructured.NestedSlice(dnsEndpoint.Object, "spec", "endpoints")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(endpoints).To(HaveLen(1))
+			g.Expect(endpoints[0]).To(HaveKeyWithValue("dnsName", "api.workload.example.com"))
+			return endpoints[0].(map[string]interface{})["targets"].([]interface{})
+		}
+
+		// The addresses are not probed before the control plane is initialized.
+		g.Expect(r.reconcileControlPlaneEndpointFailover(ctx)).To(Equal(defaultFailoverPeriod))
+		g.Expect(status.LastProbeTime).To(BeNil())
+
+		conditions.MarkTrue(ctx.Cluster, clusterv1.ControlPlaneInitializedCondition)
+		runProbe()
+		g.Expect(conditions.IsTrue(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition)).To(BeTrue())
+		g.Expect(dnsTargets()).To(Equal([]interface{}{"10.0.0.10"}))
+
+		// The next probe waits for the end of the period.
+		g.Expect(r.reconcileControlPlaneEndpointFailover(ctx)).To(BeNumerically("<", defaultFailoverPeriod))
+
+		// The active address is kept until it fails FailureThreshold probes.
+		reachable["10.0.0.10"] = false
+		runProbe()
+		g.Expect(status.ActiveAddress).To(Equal("10.0.0.10"))
+		g.Expect(status.ConsecutiveFailures).To(Equal(int32(1)))
+
+		runProbe()
+		g.Expect(status.ActiveAddress).To(Equal("10.0.0.11"))
+		g.Expect(status.ConsecutiveFailures).To(BeZero())
+		g.Expect(status.LastFailoverTime).NotTo(BeNil())
+		// The DNS record is moved to the promoted address.
+		g.Expect(dnsTargets()).To(Equal([]interface{}{"10.0.0.11"}))
+		condition := conditions.Get(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition)
+		g.Expect(condition.Reason).To(Equal(infrav1.ControlPlaneEndpointHostnameMismatchReason))
+
+		// The condition is true again once the hostname resolves to the promoted address.
+		resolved = []string{"10.0.0.11"}
+		runProbe()
+		g.Expect(conditions.IsTrue(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition)).To(BeTrue())
+
+		// The active address is kept when none of the addresses is reachable.
+		reachable["10.0.0.11"] = false
+		for i := 0; i < 2; i++ {
+			runProbe()
+		}
+		g.Expect(status.ActiveAddress).To(Equal("10.0.0.11"))
+		g.Expect(conditions.GetReason(ctx.VSphereCluster, infrav1.ControlPlaneEndpointHealthyCondition)).To(Equal(infrav1.ControlPlaneEndpointUnreachableReason))
+	})
+
+	t.Run("does not wait for the probes", func(t *testing.T) {
+		probe, lookup := probeControlPlaneAddress, lookupHost
+		defer func() {
+			probeControlPlaneAddress, lookupHost = probe, lookup
+		}()
+		lookupHost = func(_ goctx.Context, host string) ([]string, error) {
+			return []string{"10.0.0.10"}, nil
+		}
+		unblock := make(chan struct{})
+		defer close(unblock)
+		probeControlPlaneAddress = func(_ goctx.Context, _ string, _ int32, _ time.Duration) error {
+			<-unblock
+			return nil
+		}
+
+		g := NewWithT(t)
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		ctx := fake.NewClusterContext(controllerCtx)
+		ctx.VSphereCluster.Spec.ControlPlaneEndpointProvider = kubeVIP()
+		ctx.VSphereCluster.Status.ControlPlaneEndpointFailover = &infrav1.ControlPlaneEndpointFailoverStatus{
+			Addresses:     []string{"10.0.0.10", "10.0.0.11"},
+			ActiveAddress: "10.0.0.10",
+		}
+		conditions.MarkTrue(ctx.Cluster, clusterv1.ControlPlaneInitializedCondition)
+		r := clusterReconciler{ControllerContext: controllerCtx, endpointProbes: newEndpointProbes()}
+
+		// The reconciliation is requeued until the running probe completes.
+		g.Expect(r.reconcileControlPlaneEndpointFailover(ctx)).To(Equal(defaultFailoverTimeout + time.Second))
+		g.Expect(r.reconcileControlPlaneEndpointFailover(ctx)).To(Equal(time.Second))
+		g.Expect(ctx.VSphereCluster.Status.ControlPlaneEndpointFailover.ConsecutiveFailures).To(BeZero())
+	})
+}
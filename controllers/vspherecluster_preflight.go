@@ -0,0 +1,208 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/privileges"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+const (
+	// preflightInterval is the interval at which the preflight checks of a
+	// cluster are run again once they passed.
+	preflightInterval = 10 * time.Minute
+
+	// preflightRetryInterval is the interval at which the preflight checks of
+	// a cluster are run again while some of them fail.
+	preflightRetryInterval = time.Minute
+)
+
+// reconcilePreflight checks the objects of the vCenter inventory referenced by
+// the VSphereMachines of the cluster and by the VSphereMachineTemplates owned
+// by the cluster, and reports the checks in status.preflight and in the
+// PreflightChecksSucceeded condition, and the privileges required by the
+// features of the machines in the RequiredPrivilegesGranted condition. The
+// checks do not block the reconciliation of the cluster, the VMs referencing a
+// failing object fail to clone as before. They are run again when the objects
+// referenced change, and otherwise at an interval.
+func (r clusterReconciler) reconcilePreflight(ctx *context.ClusterContext) error {
+	specs, err := preflightCloneSpecs(ctx)
+	if err != nil {
+		return err
+	}
+	checks := govmomi.PreflightChecks(specs...)
+
+	if status := ctx.VSphereCluster.Status.Preflight; status != nil && samePreflightObjects(status.Checks, checks) {
+		interval := preflightInterval
+		if len(failedPreflightChecks(status.Checks)) > 0 {
+			interval = preflightRetryInterval
+		}
+		if time.Since(status.CheckedTime.Time) < interval {
+			return nil
+		}
+	}
+
+	if len(checks) > 0 {
+		authSession, err := r.getVCenterSession(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get the vCenter session of the preflight checks of %s", ctx)
+		}
+		govmomi.RunPreflightChecks(ctx, ctx.Logger, authSession, checks)
+		reconcileRequiredPrivileges(ctx, authSession, specs)
+	}
+	ctx.VSphereCluster.Status.Preflight = &infrav1.PreflightStatus{
+		Checks:      checks,
+		CheckedTime: metav1.Now(),
+	}
+
+	failed := failedPreflightChecks(checks)
+	if len(failed) == 0 {
+		conditions.MarkTrue(ctx.VSphereCluster, infrav1.PreflightChecksSucceededCondition)
+		return nil
+	}
+	reason, severity := infrav1.PreflightChecksFailedReason, clusterv1.ConditionSeverityWarning
+	messages := make([]string, 0, len(failed))
+	for _, check := range failed {
+		switch {
+		case check.Result == infrav1.PreflightNotFound:
+			reason, severity = infrav1.PreflightObjectNotFoundReason, clusterv1.ConditionSeverityError
+		case check.Result == infrav1.PreflightMissingPrivileges && reason != infrav1.PreflightObjectNotFoundReason:
+			reason, severity = infrav1.PreflightPrivilegesMissingReason, clusterv1.ConditionSeverityError
+		}
+		messages = append(messages, check.Message)
+	}
+	ctx.Logger.Info("Preflight checks failed", "checks", messages)
+	conditions.MarkFalse(ctx.VSphereCluster, infrav1.PreflightChecksSucceededCondition, reason, severity, strings.Join(messages, "; "))
+	return nil
+}
+
+// reconcileRequiredPrivileges reports, in the RequiredPrivilegesGranted
+// condition, the privileges required by the features used by the cluster and
+// its machines which the user of CAPV does not hold on the root folder of
+// vCenter. The condition is only a warning, as the privileges may be granted by
+// roles assigned on the objects used by the machines, which the preflight
+// checks of the objects cover.
+func reconcileRequiredPrivileges(ctx *context.ClusterContext, s *session.Session, specs []*infrav1.VirtualMachineCloneSpec) {
+	features := requiredPrivilegeFeatures(specs)
+	if placement := ctx.VSphereCluster.Spec.Placement; placement != nil && placement.Permission != nil {
+		features.ClusterPermissions = true
+	}
+	missing, err := privileges.Missing(ctx, s, privileges.Required(features))
+	if err != nil {
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.RequiredPrivilegesGrantedCondition, infrav1.RequiredPrivilegesCheckFailedReason, clusterv1.ConditionSeverityWarning, "%v", err)
+		return
+	}
+	if len(missing) == 0 {
+		conditions.MarkTrue(ctx.VSphereCluster, infrav1.RequiredPrivilegesGrantedCondition)
+		return
+	}
+	ctx.Logger.Info("Required privileges are missing on the root folder", "privileges", missing)
+	conditions.MarkFalse(ctx.VSphereCluster, infrav1.RequiredPrivilegesGrantedCondition, infrav1.RequiredPrivilegesMissingReason, clusterv1.ConditionSeverityWarning,
+		"missing privileges %v on the root folder", missing)
+}
+
+// requiredPrivilegeFeatures returns the features which require privileges
+// used by the given clone specs and enabled by the feature gates. The storage
+// DRS is not used by CAPV to place the VMs.
+func requiredPrivilegeFeatures(specs []*infrav1.VirtualMachineCloneSpec) privileges.Features {
+	features := privileges.Features{
+		ManagedTags:    feature.Gates.Enabled(feature.ManagedTags),
+		ClusterModules: feature.Gates.Enabled(feature.NodeAntiAffinity),
+	}
+	for _, spec := range specs {
+		if spec.CloneMode == infrav1.LinkedClone {
+			features.LinkedClones = true
+		}
+		if len(spec.TagIDs) > 0 {
+			features.Tags = true
+		}
+		if spec.Encryption != nil {
+			features.Encryption = true
+		}
+	}
+	return features
+}
+
+// preflightCloneSpecs returns the clone specs of the VSphereMachines of the
+// cluster which are not being deleted, and of the VSphereMachineTemplates
+// owned by the cluster, from which its next machines are created.
+func preflightCloneSpecs(ctx *context.ClusterContext) ([]*infrav1.VirtualMachineCloneSpec, error) {
+	machines := &infrav1.VSphereMachineList{}
+	if err := ctx.Client.List(ctx, machines,
+		client.InNamespace(ctx.Cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: ctx.Cluster.Name}); err != nil {
+		return nil, errors.Wrapf(err, "unable to list VSphereMachines part of VSphereCluster %s/%s", ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name)
+	}
+	templates := &infrav1.VSphereMachineTemplateList{}
+	if err := ctx.Client.List(ctx, templates, client.InNamespace(ctx.Cluster.Namespace)); err != nil {
+		return nil, errors.Wrapf(err, "unable to list VSphereMachineTemplates in namespace %s", ctx.Cluster.Namespace)
+	}
+
+	specs := []*infrav1.VirtualMachineCloneSpec{}
+	for i := range machines.Items {
+		if machines.Items[i].DeletionTimestamp.IsZero() {
+			specs = append(specs, &machines.Items[i].Spec.VirtualMachineCloneSpec)
+		}
+	}
+	for i := range templates.Items {
+		for _, ref := range templates.Items[i].OwnerReferences {
+			if ref.Kind == "Cluster" && strings.HasPrefix(ref.APIVersion, clusterv1.GroupVersion.Group+"/") && ref.Name == ctx.Cluster.Name {
+				specs = append(specs, &templates.Items[i].Spec.Template.Spec.VirtualMachineCloneSpec)
+				break
+			}
+		}
+	}
+	return specs, nil
+}
+
+// samePreflightObjects returns whether two lists of checks, sorted the same
+// way, check the same objects.
+func samePreflightObjects(a, b []infrav1.PreflightCheck) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Kind != b[i].Kind || a[i].Name != b[i].Name || a[i].Datacenter != b[i].Datacenter {
+			return false
+		}
+	}
+	return true
+}
+
+// failedPreflightChecks returns the checks which did not pass.
+func failedPreflightChecks(checks []infrav1.PreflightCheck) []infrav1.PreflightCheck {
+	failed := []infrav1.PreflightCheck{}
+	for _, check := range checks {
+		if check.Result != infrav1.PreflightPassed {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
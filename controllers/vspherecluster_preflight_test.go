@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/privileges"
+)
+
+func TestClusterReconciler_ReconcilePreflight(t *testing.T) {
+	t.Run("without machines", func(t *testing.T) {
+		g := NewWithT(t)
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		ctx := fake.NewClusterContext(controllerCtx)
+		r := clusterReconciler{ControllerContext: controllerCtx}
+
+		g.Expect(r.reconcilePreflight(ctx)).To(Succeed())
+		g.Expect(ctx.VSphereCluster.Status.Preflight).NotTo(BeNil())
+		g.Expect(ctx.VSphereCluster.Status.Preflight.Checks).To(BeEmpty())
+		g.Expect(conditions.IsTrue(ctx.VSphereCluster, infrav1.PreflightChecksSucceededCondition)).To(BeTrue())
+	})
+
+	t.Run("with recent checks of the same objects", func(t *testing.T) {
+		g := NewWithT(t)
+		machine := &infrav1.VSphereMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      "machine-0",
+				Labels:    map[string]string{clusterv1.ClusterLabelName: fake.Clusterv1a2Name},
+			},
+			Spec: infrav1.VSphereMachineSpec{VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Template: "ubuntu"}},
+		}
+		template := &infrav1.VSphereMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      "md-0",
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: clusterv1.GroupVersion.String(),
+					Kind:       "Cluster",
+					Name:       fake.Clusterv1a2Name,
+				}},
+			},
+			Spec: infrav1.VSphereMachineTemplateSpec{Template: infrav1.VSphereMachineTemplateResource{
+				Spec: infrav1.VSphereMachineSpec{VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Template: "ubuntu", Datastore: "ds"}},
+			}},
+		}
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(machine, template))
+		ctx := fake.NewClusterContext(controllerCtx)
+		r := clusterReconciler{ControllerContext: controllerCtx}
+
+		status := &infrav1.PreflightStatus{
+			Checks: []infrav1.PreflightCheck{
+				{Kind: infrav1.PreflightDatastore, Name: "ds", Result: infrav1.PreflightPassed},
+				{Kind: infrav1.PreflightTemplate, Name: "ubuntu", Result: infrav1.PreflightNotFound, Message: `Template "ubuntu" not found`},
+			},
+			CheckedTime: metav1.NewTime(time.Now().Add(-30 * time.Second)),
+		}
+		ctx.VSphereCluster.Status.Preflight = status.DeepCopy()
+
+		// The checks are not run again, so vCenter is not reached.
+		g.Expect(r.reconcilePreflight(ctx)).To(Succeed())
+		g.Expect(ctx.VSphereCluster.Status.Preflight).To(Equal(status))
+	})
+}
+
+func TestFailedPreflightChecks(t *testing.T) {
+	g := NewWithT(t)
+	checks := []infrav1.PreflightCheck{
+		{Kind: infrav1.PreflightDatastore, Name: "ds", Result: infrav1.PreflightPassed},
+		{Kind: infrav1.PreflightNetwork, Name: "net", Result: infrav1.PreflightMissingPrivileges},
+	}
+	g.Expect(failedPreflightChecks(checks)).To(Equal(checks[1:]))
+	g.Expect(samePreflightObjects(checks, checks[:1])).To(BeFalse())
+	g.Expect(samePreflightObjects(checks, []infrav1.PreflightCheck{
+		{Kind: infrav1.PreflightDatastore, Name: "ds"},
+		{Kind: infrav1.PreflightNetwork, Name: "net", Result: infrav1.PreflightPassed},
+	})).To(BeTrue())
+}
+
+func TestRequiredPrivilegeFeatures(t *testing.T) {
+	g := NewWithT(t)
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.ManagedTags, false)()
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.NodeAntiAffinity, true)()
+
+	g.Expect(requiredPrivilegeFeatures(nil)).To(Equal(privileges.Features{ClusterModules: true}))
+	g.Expect(requiredPrivilegeFeatures([]*infrav1.VirtualMachineCloneSpec{
+		{CloneMode: infrav1.FullClone},
+		{CloneMode: infrav1.LinkedClone, TagIDs: []string{"urn:vmomi:InventoryServiceTag:1"}},
+		{Encryption: &infrav1.EncryptionSpec{KeyProviderID: "kms"}},
+	})).To(Equal(privileges.Features{LinkedClones: true, Tags: true, ClusterModules: true, Encryption: true}))
+}
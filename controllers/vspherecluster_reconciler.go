@@ -20,6 +20,8 @@ package controllers
 import (
 	goctx "context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -42,18 +44,30 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/naming"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/privileges"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/metadata"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 	infrautilv1 "sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
 type clusterReconciler struct {
 	*context.ControllerContext
+
+	clusterModuleReconciler clusterModuleReconciler
+	endpointProbes          *endpointProbes
 }
 
 // Reconcile ensures the back-end state reflects the Kubernetes resource state intent.
-func (r clusterReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+func (r clusterReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
 	// Get the VSphereCluster resource for this request.
 	vsphereCluster := &infrav1.VSphereCluster{}
 	if err := r.Client.Get(r, req.NamespacedName, vsphereCluster); err != nil {
@@ -161,6 +175,24 @@ func (r clusterReconciler) reconcileDelete(ctx *context.ClusterContext) (reconci
 		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
 	}
 
+	if feature.Gates.Enabled(feature.NodeAntiAffinity) {
+		if err := r.clusterModuleReconciler.ReconcileDelete(ctx); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to delete cluster modules for %s", ctx)
+		}
+	}
+
+	if feature.Gates.Enabled(feature.ManagedTags) {
+		r.reconcileManagedTagsDelete(ctx)
+	}
+
+	r.reconcilePlacementDelete(ctx)
+
+	// Reset the overcommit ratios of the hosts the VMs of the cluster were
+	// placed on, which are no longer observed once its VMs are deleted.
+	vcenter.ForgetOvercommitRatios(ctx.Cluster.Namespace, ctx.Cluster.Name)
+
+	r.reconcileControlPlaneEndpointDelete(ctx)
+
 	// Remove finalizer on Identity Secret
 	if identity.IsSecretIdentity(ctx.VSphereCluster) {
 		secret := &apiv1.Secret{}
@@ -198,6 +230,10 @@ func (r clusterReconciler) reconcileNormal(ctx *context.ClusterContext) (reconci
 	// If the VSphereCluster doesn't have our finalizer, add it.
 	ctrlutil.AddFinalizer(ctx.VSphereCluster, infrav1.ClusterFinalizer)
 
+	if err := r.reconcileCost(ctx); err != nil {
+		return reconcile.Result{}, err
+	}
+
 	ok, err := r.reconcileDeploymentZones(ctx)
 	if err != nil {
 		return reconcile.Result{}, err
@@ -213,33 +249,87 @@ func (r clusterReconciler) reconcileNormal(ctx *context.ClusterContext) (reconci
 	}
 
 	if err := r.reconcileVCenterConnectivity(ctx); err != nil {
-		conditions.MarkFalse(ctx.VSphereCluster, infrav1.VCenterAvailableCondition, infrav1.VCenterUnreachableReason, clusterv1.ConditionSeverityError, err.Error())
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.VCenterAvailableCondition, vCenterUnavailableReason(err), clusterv1.ConditionSeverityError, err.Error())
 		return reconcile.Result{}, errors.Wrapf(err,
 			"unexpected error while probing vcenter for %s", ctx)
 	}
 	conditions.MarkTrue(ctx.VSphereCluster, infrav1.VCenterAvailableCondition)
+
+	rolledBack, rollbackAfter, err := r.reconcileCreationRollback(ctx)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err,
+			"failed to roll back the creation of %s", ctx)
+	}
+	if rolledBack {
+		return reconcile.Result{RequeueAfter: rollbackAfter}, nil
+	}
+
+	if err := r.reconcilePreflight(ctx); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err,
+			"failed to run the preflight checks of %s", ctx)
+	}
+
+	if err := r.reconcilePlacement(ctx); err != nil {
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.ClusterPlacementReadyCondition, infrav1.ClusterPlacementFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return reconcile.Result{}, errors.Wrapf(err,
+			"failed to reconcile the folder and resource pool of %s", ctx)
+	}
+
+	ok, err = r.reconcileControlPlaneEndpoint(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !ok {
+		ctx.Logger.Info("waiting for the address of the control plane endpoint")
+		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+	}
 	ctx.VSphereCluster.Status.Ready = true
 
+	// Requeue the VSphereCluster to probe its control plane endpoint at the
+	// end of each period of its failover.
+	probeAfter := r.reconcileControlPlaneEndpointFailover(ctx)
+	// Requeue the VSphereCluster at the latest when its creation rollback
+	// timeout expires.
+	if rollbackAfter > 0 && (probeAfter == 0 || rollbackAfter < probeAfter) {
+		probeAfter = rollbackAfter
+	}
+
+	if err := r.reconcileDatastoreCapacity(ctx); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err,
+			"failed to reconcile the datastore capacity of %s", ctx)
+	}
+
+	if feature.Gates.Enabled(feature.NodeAntiAffinity) {
+		if err := r.clusterModuleReconciler.Reconcile(ctx); err != nil {
+			ctx.Logger.Error(err, "failed to reconcile cluster modules")
+			return reconcile.Result{}, err
+		}
+	} else {
+		// The cluster modules are not reconciled without the feature gate,
+		// so their condition would be stale.
+		conditions.Delete(ctx.VSphereCluster, infrav1.ClusterModulesAvailableCondition)
+	}
+
 	// Ensure the VSphereCluster is reconciled when the API server first comes online.
 	// A reconcile event will only be triggered if the Cluster is not marked as
 	// ControlPlaneInitialized.
 	r.reconcileVSphereClusterWhenAPIServerIsOnline(ctx)
 	if ctx.VSphereCluster.Spec.ControlPlaneEndpoint.IsZero() {
 		ctx.Logger.Info("control plane endpoint is not reconciled")
-		return reconcile.Result{}, nil
+		return reconcile.Result{RequeueAfter: probeAfter}, nil
 	}
 
 	// If the cluster is deleted, that's mean that the workload cluster is being deleted and so the CCM/CSI instances
 	if !ctx.Cluster.DeletionTimestamp.IsZero() {
-		return reconcile.Result{}, nil
+		return reconcile.Result{RequeueAfter: probeAfter}, nil
 	}
 
 	// Wait until the API server is online and accessible.
 	if !r.isAPIServerOnline(ctx) {
-		return reconcile.Result{}, nil
+		return reconcile.Result{RequeueAfter: probeAfter}, nil
 	}
 
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: probeAfter}, nil
 }
 
 func (r clusterReconciler) reconcileIdentitySecret(ctx *context.ClusterContext) error {
@@ -272,6 +362,7 @@ func (r clusterReconciler) reconcileIdentitySecret(ctx *context.ClusterContext)
 		if !ctrlutil.ContainsFinalizer(secret, infrav1.SecretIdentitySetFinalizer) {
 			ctrlutil.AddFinalizer(secret, infrav1.SecretIdentitySetFinalizer)
 		}
+		setIdentitySecretLabel(secret)
 		err = r.Client.Update(ctx, secret)
 		if err != nil {
 			return err
@@ -282,29 +373,220 @@ func (r clusterReconciler) reconcileIdentitySecret(ctx *context.ClusterContext)
 }
 
 func (r clusterReconciler) reconcileVCenterConnectivity(ctx *context.ClusterContext) error {
+	_, err := r.getVCenterSession(ctx)
+	return err
+}
+
+func (r clusterReconciler) getVCenterSession(ctx *context.ClusterContext) (*session.Session, error) {
+	return r.getVCenterSessionForDatacenter(ctx, "")
+}
+
+func (r clusterReconciler) getVCenterSessionForDatacenter(ctx *context.ClusterContext, datacenter string) (*session.Session, error) {
 	params := session.NewParams().
 		WithServer(ctx.VSphereCluster.Spec.Server).
+		WithDatacenter(datacenter).
 		WithThumbprint(ctx.VSphereCluster.Spec.Thumbprint).
 		WithFeatures(session.Feature{
-			EnableKeepAlive:   r.EnableKeepAlive,
-			KeepAliveDuration: r.KeepAliveDuration,
+			EnableKeepAlive:       r.EnableKeepAlive,
+			KeepAliveDuration:     r.KeepAliveDuration,
+			MaxConcurrentRequests: r.MaxConcurrentVCenterRequests,
 		})
 
+	caBundle, err := identity.GetCABundle(ctx, r.Client, ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Spec.CABundleRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the CA bundle of the vCenter")
+	}
+	params = params.WithCABundle(caBundle)
+
 	if ctx.VSphereCluster.Spec.IdentityRef != nil {
 		creds, err := identity.GetCredentials(ctx, r.Client, ctx.VSphereCluster, r.Namespace)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		params = params.WithUserInfo(creds.Username, creds.Password)
-		_, err = session.GetOrCreate(ctx, params)
-		return err
+		return session.GetOrCreate(ctx, params)
 	}
 
 	params = params.WithUserInfo(ctx.Username, ctx.Password)
-	_, err := session.GetOrCreate(ctx,
+	return session.GetOrCreate(ctx,
 		params)
-	return err
+}
+
+// reconcileManagedTagsDelete deletes the managed tag of the cluster once its
+// VMs are deleted. The deletion is best effort, so a vCenter which cannot be
+// reached does not block the deletion of the cluster.
+func (r clusterReconciler) reconcileManagedTagsDelete(ctx *context.ClusterContext) {
+	authSession, err := r.getVCenterSession(ctx)
+	if err != nil {
+		ctx.Logger.Error(err, "failed to delete the managed tag of the cluster")
+		return
+	}
+	tagName := metadata.ClusterTagName(ctx.VSphereCluster.Namespace, ctx.Cluster.Name)
+	if err := metadata.DeleteManagedTag(ctx, authSession.TagManager, metadata.ClusterTagCategory, tagName); err != nil {
+		ctx.Logger.Error(err, "failed to delete the managed tag of the cluster")
+	}
+}
+
+// reconcilePlacement creates the VM folder and the resource pool of the
+// cluster when spec.placement is set, grants the role of its permission on
+// them, tags them with the managed tags of the cluster, and records their
+// inventory paths.
+func (r clusterReconciler) reconcilePlacement(ctx *context.ClusterContext) error {
+	placement := ctx.VSphereCluster.Spec.Placement
+	if placement == nil {
+		conditions.Delete(ctx.VSphereCluster, infrav1.ClusterPlacementReadyCondition)
+		return nil
+	}
+
+	authSession, err := r.getVCenterSessionForDatacenter(ctx, placement.Datacenter)
+	if err != nil {
+		return err
+	}
+	name, err := clusterPlacementName(ctx)
+	if err != nil {
+		return err
+	}
+	status, err := govmomi.ReconcileClusterPlacement(ctx, authSession, placement, name)
+	if err != nil {
+		return err
+	}
+	var current *infrav1.ClusterPermissionStatus
+	if ctx.VSphereCluster.Status.Placement != nil {
+		current = ctx.VSphereCluster.Status.Placement.Permission
+	}
+	// The webhook does not reject the privileges admitted before the allowed
+	// privileges were changed, nor the clusters created while it was not
+	// deployed.
+	if placement.Permission != nil {
+		if notAllowed := privileges.NotAllowedForPermission(placement.Permission.Privileges, r.ClusterPermissionAllowedPrivileges); len(notAllowed) > 0 {
+			return errors.Errorf("privileges %s are not allowed for the permission of the cluster", strings.Join(notAllowed, ", "))
+		}
+	}
+	if status.Permission, err = govmomi.ReconcileClusterPermission(ctx, authSession, placement.Permission, clusterRoleName(ctx), status, current); err != nil {
+		// The role created for the cluster is recorded, so that it is not
+		// taken for a role of the same name created outside of CAPV.
+		ctx.VSphereCluster.Status.Placement = status
+		return err
+	}
+	ctx.VSphereCluster.Status.Placement = status
+	if feature.Gates.Enabled(feature.ManagedTags) {
+		if err := govmomi.TagClusterPlacement(ctx, authSession, status, map[string]string{
+			metadata.ClusterTagCategory:   metadata.ClusterTagName(ctx.VSphereCluster.Namespace, ctx.Cluster.Name),
+			metadata.NamespaceTagCategory: ctx.VSphereCluster.Namespace,
+		}); err != nil {
+			return err
+		}
+	}
+	conditions.MarkTrue(ctx.VSphereCluster, infrav1.ClusterPlacementReadyCondition)
+	return nil
+}
+
+// reconcilePlacementDelete deletes the permission, the VM folder and the
+// resource pool of the cluster once its VMs are deleted. Like the deletion of
+// the managed tags, it is best effort, so a vCenter which cannot be reached
+// does not block the deletion of the cluster.
+func (r clusterReconciler) reconcilePlacementDelete(ctx *context.ClusterContext) {
+	placement, status := ctx.VSphereCluster.Spec.Placement, ctx.VSphereCluster.Status.Placement
+	if placement == nil || status == nil {
+		return
+	}
+
+	authSession, err := r.getVCenterSessionForDatacenter(ctx, placement.Datacenter)
+	if err != nil {
+		ctx.Logger.Error(err, "failed to delete the folder and resource pool of the cluster")
+		return
+	}
+	// The permission is removed first, as the folder or the resource pool
+	// may be kept.
+	if status.Permission != nil {
+		if err := govmomi.DeleteClusterPermission(ctx, authSession, status, status.Permission); err != nil {
+			ctx.Logger.Error(err, "failed to delete the permission of the cluster", "role", status.Permission.Role)
+		}
+	}
+	deleted, err := govmomi.DeleteClusterPlacement(ctx, authSession, status)
+	if err != nil {
+		ctx.Logger.Error(err, "failed to delete the folder and resource pool of the cluster")
+		return
+	}
+	if !deleted {
+		ctx.Logger.Info("Keeping the folder or resource pool of the cluster which is not empty",
+			"folder", status.Folder, "resourcePool", status.ResourcePool)
+	}
+}
+
+// clusterRoleName returns the name of the vCenter role of the permission of
+// the cluster, which is unique across the clusters of all the namespaces.
+func clusterRoleName(ctx *context.ClusterContext) string {
+	return fmt.Sprintf("capv-%s-%s", ctx.VSphereCluster.Namespace, ctx.Cluster.Name)
+}
+
+// reconcileDatastoreCapacity flags the VSphereCluster when a datastore used by
+// its VSphereVMs is overcommitted and some of their disks on the datastore are
+// thin-provisioned, so the datastore may run out of space as the disks grow.
+func (r clusterReconciler) reconcileDatastoreCapacity(ctx *context.ClusterContext) error {
+	vms := &infrav1.VSphereVMList{}
+	if err := ctx.Client.List(ctx, vms,
+		client.InNamespace(ctx.Cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: ctx.Cluster.Name}); err != nil {
+		return errors.Wrapf(err, "unable to list VSphereVMs part of VSphereCluster %s/%s", ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name)
+	}
+
+	overcommitted := overcommittedDatastores(vms.Items)
+	if len(overcommitted) == 0 {
+		conditions.MarkTrue(ctx.VSphereCluster, infrav1.DatastoreCapacityAvailableCondition)
+		return nil
+	}
+	conditions.MarkFalse(ctx.VSphereCluster, infrav1.DatastoreCapacityAvailableCondition, infrav1.DatastoreOvercommittedReason, clusterv1.ConditionSeverityWarning,
+		"datastores %s may use more than their capacity", strings.Join(overcommitted, ", "))
+	return nil
+}
+
+// overcommittedDatastores returns the sorted names and overcommit ratios of
+// the datastores on which the given VSphereVMs have thin-provisioned disks and
+// whose VMs may use more than the capacity of the datastore.
+func overcommittedDatastores(vms []infrav1.VSphereVM) []string {
+	ratios := map[string]int64{}
+	for i := range vms {
+		storage := vms[i].Status.Storage
+		if storage == nil {
+			continue
+		}
+		for _, usage := range storage.Datastores {
+			if usage.ProvisionedBytes <= usage.CommittedBytes || usage.CapacityBytes <= 0 ||
+				usage.DatastoreProvisionedBytes <= usage.CapacityBytes {
+				continue
+			}
+			if ratio := usage.DatastoreProvisionedBytes * 100 / usage.CapacityBytes; ratio > ratios[usage.Name] {
+				ratios[usage.Name] = ratio
+			}
+		}
+	}
+
+	overcommitted := make([]string, 0, len(ratios))
+	for name, ratio := range ratios {
+		overcommitted = append(overcommitted, fmt.Sprintf("%s (%d%%)", name, ratio))
+	}
+	sort.Strings(overcommitted)
+	return overcommitted
+}
+
+// clusterPlacementName returns the name of the VM folder and of the resource
+// pool of the cluster. Cluster names are only unique within a namespace, so
+// the name includes the namespace of the cluster, unless it is rendered from
+// the placement template of the naming strategy of the cluster.
+func clusterPlacementName(ctx *context.ClusterContext) (string, error) {
+	if strategy := ctx.VSphereCluster.Spec.Naming; strategy != nil && strategy.Placement != "" {
+		name, err := naming.Render(strategy.Placement, naming.Input{
+			ClusterName: ctx.Cluster.Name,
+			Namespace:   ctx.Cluster.Namespace,
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to generate the name of the folder and resource pool of %s", ctx)
+		}
+		return name, nil
+	}
+	return ctx.VSphereCluster.Namespace + "-" + ctx.Cluster.Name, nil
 }
 
 func (r clusterReconciler) reconcileDeploymentZones(ctx *context.ClusterContext) (bool, error) {
@@ -499,11 +781,7 @@ func (r clusterReconciler) controlPlaneMachineToCluster(o client.Object) []ctrl.
 		return nil
 	}
 
-	if conditions.IsTrue(cluster, clusterv1.ControlPlaneInitializedCondition) {
-		return nil
-	}
-
-	if !cluster.Spec.ControlPlaneEndpoint.IsZero() {
+	if cluster.Spec.InfrastructureRef == nil {
 		return nil
 	}
 
@@ -518,17 +796,32 @@ func (r clusterReconciler) controlPlaneMachineToCluster(o client.Object) []ctrl.
 			"namespace", vsphereClusterKey.Namespace, "name", vsphereClusterKey.Name)
 		return nil
 	}
-
-	if !vsphereCluster.Spec.ControlPlaneEndpoint.IsZero() {
-		return nil
-	}
-
-	return []ctrl.Request{{
+	request := []ctrl.Request{{
 		NamespacedName: types.NamespacedName{
 			Namespace: vsphereClusterKey.Namespace,
 			Name:      vsphereClusterKey.Name,
 		},
 	}}
+
+	// The pool of the virtual service of the control plane endpoint follows
+	// the addresses of the control plane machines.
+	if provider := vsphereCluster.Spec.ControlPlaneEndpointProvider; provider != nil && provider.AVI != nil {
+		return request
+	}
+
+	if conditions.IsTrue(cluster, clusterv1.ControlPlaneInitializedCondition) {
+		return nil
+	}
+
+	if !cluster.Spec.ControlPlaneEndpoint.IsZero() {
+		return nil
+	}
+
+	if !vsphereCluster.Spec.ControlPlaneEndpoint.IsZero() {
+		return nil
+	}
+
+	return request
 }
 
 func (r clusterReconciler) deploymentZoneToCluster(o client.Object) []ctrl.Request {
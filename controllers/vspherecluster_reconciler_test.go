@@ -133,13 +133,13 @@ var _ = Describe("VIM based VSphere ClusterReconciler", func() {
 				return len(instance.Finalizers) > 0
 			}, timeout).Should(BeTrue())
 
-			// checking cluster is setting the ownerRef on the secret
+			// checking cluster is setting the ownerRef and the identity label on the secret
 			secretKey := client.ObjectKey{Namespace: secret.Namespace, Name: secret.Name}
 			Eventually(func() bool {
 				if err := testEnv.Get(ctx, secretKey, secret); err != nil {
 					return false
 				}
-				return len(secret.OwnerReferences) > 0
+				return len(secret.OwnerReferences) > 0 && secret.Labels[infrav1.IdentitySecretLabel] == "true"
 			}, timeout).Should(BeTrue())
 
 			By("setting the VSphereCluster's VCenterAvailableCondition to true")
@@ -519,7 +519,7 @@ func TestClusterReconciler_ReconcileDeploymentZones(t *testing.T) {
 			Spec: infrav1.VSphereClusterSpec{Server: server},
 		}
 
-		r := clusterReconciler{controllerCtx}
+		r := clusterReconciler{ControllerContext: controllerCtx}
 		reconciled, err := r.reconcileDeploymentZones(ctx)
 		g.Expect(err).NotTo(HaveOccurred())
 		g.Expect(reconciled).To(BeTrue())
@@ -576,7 +576,7 @@ func TestClusterReconciler_ReconcileDeploymentZones(t *testing.T) {
 			ctx := fake.NewClusterContext(controllerCtx)
 			ctx.VSphereCluster.Spec.Server = server
 
-			r := clusterReconciler{controllerCtx}
+			r := clusterReconciler{ControllerContext: controllerCtx}
 			reconciled, err := r.reconcileDeploymentZones(ctx)
 			g.Expect(err).NotTo(HaveOccurred())
 			g.Expect(reconciled).To(Equal(tt.reconciled))
@@ -585,6 +585,74 @@ func TestClusterReconciler_ReconcileDeploymentZones(t *testing.T) {
 	}
 }
 
+func TestClusterReconciler_ReconcileDatastoreCapacity(t *testing.T) {
+	vm := func(name, cluster string, usages ...infrav1.VirtualMachineDatastoreUsage) *infrav1.VSphereVM {
+		return &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      name,
+				Labels:    map[string]string{clusterv1.ClusterLabelName: cluster},
+			},
+			Status: infrav1.VSphereVMStatus{Storage: &infrav1.VirtualMachineStorageStatus{Datastores: usages}},
+		}
+	}
+	thin := infrav1.VirtualMachineDatastoreUsage{Name: "ds0", CommittedBytes: 10, ProvisionedBytes: 40, CapacityBytes: 100, DatastoreProvisionedBytes: 150}
+	thick := infrav1.VirtualMachineDatastoreUsage{Name: "ds1", CommittedBytes: 40, ProvisionedBytes: 40, CapacityBytes: 100, DatastoreProvisionedBytes: 200}
+	fits := infrav1.VirtualMachineDatastoreUsage{Name: "ds2", CommittedBytes: 10, ProvisionedBytes: 40, CapacityBytes: 100, DatastoreProvisionedBytes: 80}
+
+	tests := []struct {
+		name     string
+		initObjs []client.Object
+		assert   func(*WithT, *infrav1.VSphereCluster)
+	}{
+		{
+			name:     "with datastores holding the disks of their VMs",
+			initObjs: []client.Object{vm("vm-0", fake.Clusterv1a2Name, fits), vm("vm-1", fake.Clusterv1a2Name)},
+			assert: func(g *WithT, cluster *infrav1.VSphereCluster) {
+				g.Expect(conditions.IsTrue(cluster, infrav1.DatastoreCapacityAvailableCondition)).To(BeTrue())
+			},
+		},
+		{
+			name:     "with thick-provisioned disks on an overcommitted datastore",
+			initObjs: []client.Object{vm("vm-0", fake.Clusterv1a2Name, thick)},
+			assert: func(g *WithT, cluster *infrav1.VSphereCluster) {
+				g.Expect(conditions.IsTrue(cluster, infrav1.DatastoreCapacityAvailableCondition)).To(BeTrue())
+			},
+		},
+		{
+			name:     "with thin-provisioned disks of another cluster on an overcommitted datastore",
+			initObjs: []client.Object{vm("vm-0", "other-cluster", thin)},
+			assert: func(g *WithT, cluster *infrav1.VSphereCluster) {
+				g.Expect(conditions.IsTrue(cluster, infrav1.DatastoreCapacityAvailableCondition)).To(BeTrue())
+			},
+		},
+		{
+			name:     "with thin-provisioned disks on an overcommitted datastore",
+			initObjs: []client.Object{vm("vm-0", fake.Clusterv1a2Name, thin, fits), vm("vm-1", fake.Clusterv1a2Name, thin, thick)},
+			assert: func(g *WithT, cluster *infrav1.VSphereCluster) {
+				g.Expect(conditions.IsFalse(cluster, infrav1.DatastoreCapacityAvailableCondition)).To(BeTrue())
+				condition := conditions.Get(cluster, infrav1.DatastoreCapacityAvailableCondition)
+				g.Expect(condition.Reason).To(Equal(infrav1.DatastoreOvercommittedReason))
+				g.Expect(condition.Severity).To(Equal(clusterv1.ConditionSeverityWarning))
+				g.Expect(condition.Message).To(Equal("datastores ds0 (150%) may use more than their capacity"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(tt.initObjs...))
+			ctx := fake.NewClusterContext(controllerCtx)
+
+			r := clusterReconciler{ControllerContext: controllerCtx}
+			g.Expect(r.reconcileDatastoreCapacity(ctx)).To(Succeed())
+			tt.assert(g, ctx.VSphereCluster)
+		})
+	}
+}
+
 func deploymentZone(server, fdName string, cp, ready *bool) *infrav1.VSphereDeploymentZone {
 	return &infrav1.VSphereDeploymentZone{
 		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("zone-%s", fdName)},
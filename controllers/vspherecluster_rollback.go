@@ -0,0 +1,171 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	infrautilv1 "sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+// reconcileCreationRollback rolls back the creation of a cluster whose
+// control plane is not initialized within the timeout of its
+// spec.creationRollback. It returns true once the rollback has started, in
+// which case the cluster must not be reconciled further, and otherwise the
+// duration after which the timeout expires.
+func (r clusterReconciler) reconcileCreationRollback(ctx *context.ClusterContext) (bool, time.Duration, error) {
+	rollback := ctx.VSphereCluster.Spec.CreationRollback
+	if ctx.VSphereCluster.Status.CreationRollback == nil {
+		if rollback == nil || conditions.IsTrue(ctx.Cluster, clusterv1.ControlPlaneInitializedCondition) {
+			return false, 0, nil
+		}
+		deadline := ctx.VSphereCluster.CreationTimestamp.Add(rollback.Timeout.Duration)
+		if remaining := time.Until(deadline); remaining > 0 {
+			return false, remaining, nil
+		}
+
+		report, err := r.creationRollbackReport(ctx)
+		if err != nil {
+			return false, 0, err
+		}
+		ctx.VSphereCluster.Status.CreationRollback = report
+		ctx.VSphereCluster.Status.FailureReason = capierrors.ClusterStatusErrorPtr(capierrors.CreateClusterError)
+		ctx.VSphereCluster.Status.FailureMessage = pointer.String(fmt.Sprintf(
+			"control plane was not initialized within %s, the creation of the cluster is rolled back", rollback.Timeout.Duration))
+		r.Recorder.Warnf(ctx.VSphereCluster, "ClusterCreationRollback",
+			"Rolling back the creation of the cluster, its control plane was not initialized within %s", rollback.Timeout.Duration)
+	}
+	ctx.VSphereCluster.Status.Ready = false
+
+	if ctx.VSphereCluster.Status.CreationRollback.CompletionTime != nil {
+		return true, 0, nil
+	}
+
+	// Delete the VMs of the cluster, and then its VM folder and resource pool,
+	// which are only deleted once empty.
+	vms := &infrav1.VSphereVMList{}
+	if err := ctx.Client.List(ctx, vms,
+		client.InNamespace(ctx.Cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: ctx.Cluster.Name}); err != nil {
+		return true, 0, errors.Wrapf(err, "unable to list VSphereVMs part of VSphereCluster %s/%s", ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name)
+	}
+	for i := range vms.Items {
+		vm := &vms.Items[i]
+		if !vm.DeletionTimestamp.IsZero() {
+			continue
+		}
+		ctx.Logger.Info("Deleting VSphereVM of the rolled back cluster", "namespace", vm.Namespace, "name", vm.Name)
+		if err := ctx.Client.Delete(ctx, vm); err != nil && !apierrors.IsNotFound(err) {
+			return true, 0, errors.Wrapf(err, "failed to delete VSphereVM %s/%s", vm.Namespace, vm.Name)
+		}
+	}
+	if len(vms.Items) > 0 {
+		ctx.Logger.Info("Waiting for the VSphereVMs of the rolled back cluster to be deleted", "count", len(vms.Items))
+		return true, 10 * time.Second, nil
+	}
+
+	r.reconcilePlacementDelete(ctx)
+
+	now := metav1.Now()
+	ctx.VSphereCluster.Status.CreationRollback.CompletionTime = &now
+	r.Recorder.Eventf(ctx.VSphereCluster, "ClusterCreationRolledBack", "Deleted the VMs of the cluster")
+	return true, 0, nil
+}
+
+// creationRollbackReport returns the state of the machines of a cluster
+// whose creation is rolled back.
+func (r clusterReconciler) creationRollbackReport(ctx *context.ClusterContext) (*infrav1.ClusterCreationRollbackStatus, error) {
+	machines, err := infrautilv1.GetMachinesInCluster(ctx, ctx.Client, ctx.Cluster.Namespace, ctx.Cluster.Name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(machines, func(i, j int) bool { return machines[i].Name < machines[j].Name })
+
+	report := &infrav1.ClusterCreationRollbackStatus{StartTime: metav1.Now()}
+	for _, machine := range machines {
+		machineReport := infrav1.MachineRollbackReport{
+			Name:         machine.Name,
+			ControlPlane: infrautilv1.IsControlPlaneMachine(machine),
+			Phase:        machine.Status.Phase,
+			Conditions:   rollbackConditions("Machine", machine.Status.Conditions),
+		}
+		if machine.Status.FailureMessage != nil {
+			machineReport.FailureMessage = *machine.Status.FailureMessage
+		}
+
+		if ref := machine.Spec.InfrastructureRef; ref.Kind == "VSphereMachine" && ref.Name != "" {
+			key := client.ObjectKey{Namespace: machine.Namespace, Name: ref.Name}
+			vsphereMachine := &infrav1.VSphereMachine{}
+			switch err := ctx.Client.Get(ctx, key, vsphereMachine); {
+			case err == nil:
+				machineReport.Conditions = append(machineReport.Conditions, rollbackConditions("VSphereMachine", vsphereMachine.Status.Conditions)...)
+				if machineReport.FailureMessage == "" && vsphereMachine.Status.FailureMessage != nil {
+					machineReport.FailureMessage = *vsphereMachine.Status.FailureMessage
+				}
+			case !apierrors.IsNotFound(err):
+				return nil, errors.Wrapf(err, "failed to get VSphereMachine %s", key)
+			}
+
+			vm := &infrav1.VSphereVM{}
+			switch err := ctx.Client.Get(ctx, key, vm); {
+			case err == nil:
+				machineReport.VMAddresses = vm.Status.Addresses
+				machineReport.VMTask = vm.Status.Task
+				machineReport.Conditions = append(machineReport.Conditions, rollbackConditions("VSphereVM", vm.Status.Conditions)...)
+				if machineReport.FailureMessage == "" && vm.Status.FailureMessage != nil {
+					machineReport.FailureMessage = *vm.Status.FailureMessage
+				}
+			case !apierrors.IsNotFound(err):
+				return nil, errors.Wrapf(err, "failed to get VSphereVM %s", key)
+			}
+		}
+		report.Machines = append(report.Machines, machineReport)
+	}
+	return report, nil
+}
+
+// rollbackConditions returns the conditions of an object which are not true.
+func rollbackConditions(kind string, objConditions clusterv1.Conditions) []infrav1.MachineRollbackCondition {
+	var result []infrav1.MachineRollbackCondition
+	for _, c := range objConditions {
+		if c.Status == corev1.ConditionTrue {
+			continue
+		}
+		result = append(result, infrav1.MachineRollbackCondition{
+			Kind:    kind,
+			Type:    c.Type,
+			Status:  c.Status,
+			Reason:  c.Reason,
+			Message: c.Message,
+		})
+	}
+	return result
+}
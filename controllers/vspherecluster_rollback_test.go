@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestClusterReconciler_ReconcileCreationRollback(t *testing.T) {
+	newClusterContext := func(created time.Time, objects ...client.Object) (clusterReconciler, *context.ClusterContext) {
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(objects...))
+		ctx := fake.NewClusterContext(controllerCtx)
+		ctx.VSphereCluster.CreationTimestamp = metav1.NewTime(created)
+		ctx.VSphereCluster.Status.Ready = true
+		ctx.VSphereCluster.Spec.CreationRollback = &infrav1.ClusterCreationRollbackSpec{Timeout: metav1.Duration{Duration: 30 * time.Minute}}
+		return clusterReconciler{ControllerContext: controllerCtx}, ctx
+	}
+
+	t.Run("without a rollback", func(t *testing.T) {
+		g := NewWithT(t)
+		r, ctx := newClusterContext(time.Now().Add(-time.Hour))
+		ctx.VSphereCluster.Spec.CreationRollback = nil
+
+		rolledBack, after, err := r.reconcileCreationRollback(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(rolledBack).To(BeFalse())
+		g.Expect(after).To(BeZero())
+	})
+
+	t.Run("before the timeout", func(t *testing.T) {
+		g := NewWithT(t)
+		r, ctx := newClusterContext(time.Now().Add(-10 * time.Minute))
+
+		rolledBack, after, err := r.reconcileCreationRollback(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(rolledBack).To(BeFalse())
+		g.Expect(after).To(BeNumerically("~", 20*time.Minute, time.Minute))
+		g.Expect(ctx.VSphereCluster.Status.CreationRollback).To(BeNil())
+	})
+
+	t.Run("with an initialized control plane", func(t *testing.T) {
+		g := NewWithT(t)
+		r, ctx := newClusterContext(time.Now().Add(-time.Hour))
+		conditions.MarkTrue(ctx.Cluster, clusterv1.ControlPlaneInitializedCondition)
+
+		rolledBack, _, err := r.reconcileCreationRollback(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(rolledBack).To(BeFalse())
+		g.Expect(ctx.VSphereCluster.Status.FailureReason).To(BeNil())
+	})
+
+	t.Run("after the timeout", func(t *testing.T) {
+		g := NewWithT(t)
+		labels := map[string]string{clusterv1.ClusterLabelName: fake.Clusterv1a2Name}
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      "control-plane-0",
+				Labels:    map[string]string{clusterv1.ClusterLabelName: fake.Clusterv1a2Name, clusterv1.MachineControlPlaneLabelName: ""},
+			},
+			Spec: clusterv1.MachineSpec{
+				ClusterName:       fake.Clusterv1a2Name,
+				InfrastructureRef: corev1.ObjectReference{Kind: "VSphereMachine", Name: "control-plane-0"},
+			},
+			Status: clusterv1.MachineStatus{Phase: string(clusterv1.MachinePhaseProvisioning)},
+		}
+		vm := &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "control-plane-0", Labels: labels},
+			Status: infrav1.VSphereVMStatus{
+				Conditions: clusterv1.Conditions{
+					{Type: infrav1.VMProvisionedCondition, Status: corev1.ConditionFalse, Reason: infrav1.CloningFailedReason, Message: "no space left on datastore"},
+					{Type: clusterv1.ReadyCondition, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+		r, ctx := newClusterContext(time.Now().Add(-time.Hour), machine, vm)
+
+		rolledBack, after, err := r.reconcileCreationRollback(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(rolledBack).To(BeTrue())
+		g.Expect(after).To(Equal(10 * time.Second))
+		g.Expect(ctx.VSphereCluster.Status.Ready).To(BeFalse())
+		g.Expect(*ctx.VSphereCluster.Status.FailureReason).To(Equal(capierrors.CreateClusterError))
+		g.Expect(ctx.VSphereCluster.Status.FailureMessage).NotTo(BeNil())
+
+		report := ctx.VSphereCluster.Status.CreationRollback
+		g.Expect(report).NotTo(BeNil())
+		g.Expect(report.CompletionTime).To(BeNil())
+		g.Expect(report.Machines).To(Equal([]infrav1.MachineRollbackReport{{
+			Name:         "control-plane-0",
+			ControlPlane: true,
+			Phase:        string(clusterv1.MachinePhaseProvisioning),
+			Conditions: []infrav1.MachineRollbackCondition{{
+				Kind: "VSphereVM", Type: infrav1.VMProvisionedCondition, Status: corev1.ConditionFalse,
+				Reason: infrav1.CloningFailedReason, Message: "no space left on datastore",
+			}},
+		}}))
+		g.Expect(ctx.Client.Get(ctx, client.ObjectKeyFromObject(vm), &infrav1.VSphereVM{})).NotTo(Succeed())
+
+		// The rollback completes once the VMs are deleted.
+		rolledBack, after, err = r.reconcileCreationRollback(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(rolledBack).To(BeTrue())
+		g.Expect(after).To(BeZero())
+		g.Expect(ctx.VSphereCluster.Status.CreationRollback.CompletionTime).NotTo(BeNil())
+		g.Expect(ctx.VSphereCluster.Status.CreationRollback.Machines).To(Equal(report.Machines))
+
+		// The cluster is no longer reconciled, even once its control plane is initialized.
+		conditions.MarkTrue(ctx.Cluster, clusterv1.ControlPlaneInitializedCondition)
+		rolledBack, _, err = r.reconcileCreationRollback(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(rolledBack).To(BeTrue())
+	})
+}
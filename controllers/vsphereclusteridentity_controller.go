@@ -38,7 +38,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 )
 
@@ -77,7 +79,9 @@ type clusterIdentityReconciler struct {
 	*context.ControllerContext
 }
 
-func (r clusterIdentityReconciler) Reconcile(ctx _context.Context, req reconcile.Request) (_ reconcile.Result, reterr error) {
+func (r clusterIdentityReconciler) Reconcile(ctx _context.Context, req reconcile.Request) (result reconcile.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
 	// TODO(gab-satchi) consider creating a context for the clusterIdentity
 	// Get VSphereClusterIdentity
 	identity := &infrav1.VSphereClusterIdentity{}
@@ -90,6 +94,11 @@ func (r clusterIdentityReconciler) Reconcile(ctx _context.Context, req reconcile
 		return reconcile.Result{}, err
 	}
 
+	if isPaused(nil, identity) {
+		r.Logger.V(4).Info("VSphereClusterIdentity is paused", "key", req.NamespacedName)
+		return reconcile.Result{}, nil
+	}
+
 	// Create the patch helper.
 	patchHelper, err := patch.NewHelper(identity, r.Client)
 	if err != nil {
@@ -102,7 +111,8 @@ func (r clusterIdentityReconciler) Reconcile(ctx _context.Context, req reconcile
 	}
 
 	defer func() {
-		conditions.SetSummary(identity, conditions.WithConditions(infrav1.CredentialsAvailableCondidtion))
+		conditions.SetSummary(identity, conditions.WithConditions(infrav1.CredentialsAvailableCondition))
+		v1beta2conditions.Mirror(identity)
 
 		if err := patchHelper.Patch(ctx, identity); err != nil {
 			if reterr == nil {
@@ -123,35 +133,41 @@ func (r clusterIdentityReconciler) Reconcile(ctx _context.Context, req reconcile
 		Name:      identity.Spec.SecretName,
 	}
 	if err := r.Client.Get(ctx, secretKey, secret); err != nil {
-		conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondidtion, infrav1.SecretNotAvailableReason, clusterv1.ConditionSeverityWarning, err.Error())
+		conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondition, infrav1.SecretNotAvailableReason, clusterv1.ConditionSeverityWarning, err.Error())
 		return reconcile.Result{}, errors.Errorf("secret: %s not found in namespace: %s", secretKey.Name, secretKey.Namespace)
 	}
 
-	if !clusterutilv1.IsOwnedByObject(secret, identity) {
-		if len(secret.OwnerReferences) > 0 {
-			conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondidtion, infrav1.SecretAlreadyInUseReason, clusterv1.ConditionSeverityError, "secret being used by another Cluster/VSphereIdentity")
-			identity.Status.Ready = false
-			return reconcile.Result{}, errors.New("secret being used by another Cluster/VSphereIdentity")
-		}
+	// The secrets set before the identity label was introduced are labeled
+	// the next time their identity is reconciled.
+	owned := clusterutilv1.IsOwnedByObject(secret, identity)
+	if !owned || secret.Labels[infrav1.IdentitySecretLabel] != "true" {
+		if !owned {
+			if len(secret.OwnerReferences) > 0 {
+				conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondition, infrav1.SecretAlreadyInUseReason, clusterv1.ConditionSeverityError, "secret being used by another Cluster/VSphereIdentity")
+				identity.Status.Ready = false
+				return reconcile.Result{}, errors.New("secret being used by another Cluster/VSphereIdentity")
+			}
 
-		secret.SetOwnerReferences([]metav1.OwnerReference{{
-			APIVersion: infrav1.GroupVersion.String(),
-			Kind:       identity.Kind,
-			Name:       identity.Name,
-			UID:        identity.UID,
-		}})
+			secret.SetOwnerReferences([]metav1.OwnerReference{{
+				APIVersion: infrav1.GroupVersion.String(),
+				Kind:       identity.Kind,
+				Name:       identity.Name,
+				UID:        identity.UID,
+			}})
+		}
 
 		if !ctrlutil.ContainsFinalizer(secret, infrav1.SecretIdentitySetFinalizer) {
 			ctrlutil.AddFinalizer(secret, infrav1.SecretIdentitySetFinalizer)
 		}
+		setIdentitySecretLabel(secret)
 		err = r.Client.Update(ctx, secret)
 		if err != nil {
-			conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondidtion, infrav1.SecretOwnerReferenceFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondition, infrav1.SecretOwnerReferenceFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 			return reconcile.Result{}, err
 		}
 	}
 
-	conditions.MarkTrue(identity, infrav1.CredentialsAvailableCondidtion)
+	conditions.MarkTrue(identity, infrav1.CredentialsAvailableCondition)
 	identity.Status.Ready = true
 	return reconcile.Result{}, nil
 }
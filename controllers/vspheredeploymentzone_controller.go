@@ -43,6 +43,7 @@ import (
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
@@ -95,7 +96,9 @@ type vsphereDeploymentZoneReconciler struct {
 	*context.ControllerContext
 }
 
-func (r vsphereDeploymentZoneReconciler) Reconcile(ctx goctx.Context, request reconcile.Request) (_ reconcile.Result, reterr error) {
+func (r vsphereDeploymentZoneReconciler) Reconcile(ctx goctx.Context, request reconcile.Request) (result reconcile.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
 	logr := r.Logger.WithValues("vspheredeploymentzone", request.Name)
 	// Fetch the VSphereDeploymentZone for this request.
 	vsphereDeploymentZone := &infrav1.VSphereDeploymentZone{}
@@ -117,6 +120,11 @@ func (r vsphereDeploymentZoneReconciler) Reconcile(ctx goctx.Context, request re
 		return reconcile.Result{}, err
 	}
 
+	if isPaused(nil, vsphereDeploymentZone) || isPaused(nil, failureDomain) {
+		logr.V(4).Info("VSphereDeploymentZone or its VSphereFailureDomain is paused")
+		return reconcile.Result{}, nil
+	}
+
 	patchHelper, err := patch.NewHelper(vsphereDeploymentZone, r.Client)
 	if err != nil {
 		return reconcile.Result{}, errors.Wrapf(
@@ -156,7 +164,7 @@ func (r vsphereDeploymentZoneReconciler) reconcileNormal(ctx *context.VSphereDep
 	authSession, err := r.getVCenterSession(ctx)
 	if err != nil {
 		ctx.Logger.V(4).Error(err, "unable to create session")
-		conditions.MarkFalse(ctx.VSphereDeploymentZone, infrav1.VCenterAvailableCondition, infrav1.VCenterUnreachableReason, clusterv1.ConditionSeverityError, err.Error())
+		conditions.MarkFalse(ctx.VSphereDeploymentZone, infrav1.VCenterAvailableCondition, vCenterUnavailableReason(err), clusterv1.ConditionSeverityError, err.Error())
 		ctx.VSphereDeploymentZone.Status.Ready = pointer.Bool(false)
 		return reconcile.Result{}, errors.Wrapf(err, "unable to create auth session")
 	}
@@ -226,8 +234,9 @@ func (r vsphereDeploymentZoneReconciler) getVCenterSession(ctx *context.VSphereD
 		WithDatacenter(ctx.VSphereFailureDomain.Spec.Topology.Datacenter).
 		WithUserInfo(r.ControllerContext.Username, r.ControllerContext.Password).
 		WithFeatures(session.Feature{
-			EnableKeepAlive:   r.EnableKeepAlive,
-			KeepAliveDuration: r.KeepAliveDuration,
+			EnableKeepAlive:       r.EnableKeepAlive,
+			KeepAliveDuration:     r.KeepAliveDuration,
+			MaxConcurrentRequests: r.MaxConcurrentVCenterRequests,
 		})
 
 	clusterList := &infrav1.VSphereClusterList{}
@@ -239,6 +248,12 @@ func (r vsphereDeploymentZoneReconciler) getVCenterSession(ctx *context.VSphereD
 		if ctx.VSphereDeploymentZone.Spec.Server == vsphereCluster.Spec.Server && vsphereCluster.Spec.IdentityRef != nil {
 			logger := ctx.Logger.WithValues("cluster", vsphereCluster.Name)
 			params = params.WithThumbprint(vsphereCluster.Spec.Thumbprint)
+			caBundle, err := identity.GetCABundle(ctx, r.Client, vsphereCluster.Namespace, vsphereCluster.Spec.CABundleRef)
+			if err != nil {
+				logger.Error(err, "error retrieving the CA bundle of the vCenter")
+				continue
+			}
+			params = params.WithCABundle(caBundle)
 			clust := vsphereCluster
 			creds, err := identity.GetCredentials(ctx, r.Client, &clust, r.Namespace)
 			if err != nil {
@@ -36,18 +36,22 @@ import (
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbldr "sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
 	inframanager "sigs.k8s.io/cluster-api-provider-vsphere/pkg/manager"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services"
@@ -64,6 +68,7 @@ import (
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachineimages;virtualmachineimages/status,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=vmoperator.vmware.com,resources=virtualmachineclasses;virtualmachineclassbindings,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=nodes;events;configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=configmaps/status,verbs=get;update;patch
 
@@ -80,75 +85,107 @@ func AddMachineControllerToManager(ctx *context.ControllerManagerContext, mgr ma
 		controlledTypeName  = reflect.TypeOf(controlledType).Elem().Name()
 		controlledTypeGVK   = infrav1.GroupVersion.WithKind(controlledTypeName)
 		controllerNameShort = fmt.Sprintf("%s-controller", strings.ToLower(controlledTypeName))
-		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
 	)
 
 	if supervisorBased {
 		controlledTypeGVK = vmwarev1.GroupVersion.WithKind(controlledTypeName)
 		controllerNameShort = fmt.Sprintf("%s-supervisor-controller", strings.ToLower(controlledTypeName))
-		controllerNameLong = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
 	}
 
-	// Build the controller context.
-	controllerContext := &context.ControllerContext{
-		ControllerManagerContext: ctx,
-		Name:                     controllerNameShort,
-		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
-		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	var networkProvider services.NetworkProvider
+	if supervisorBased {
+		if networkProvider, err = inframanager.GetNetworkProvider(ctx); err != nil {
+			return errors.Wrap(err, "failed to create a network provider")
+		}
 	}
 
-	builder := ctrl.NewControllerManagedBy(mgr).
-		// Watch the controlled, infrastructure resource.
-		For(controlledType).
-		// Watch the CAPI resource that owns this infrastructure resource.
-		Watches(
-			&source.Kind{Type: &clusterv1.Machine{}},
-			handler.EnqueueRequestsFromMapFunc(clusterutilv1.MachineToInfrastructureMapFunc(controlledTypeGVK)),
-		).
-		// Watch a GenericEvent channel for the controlled resource.
-		//
-		// This is useful when there are events outside of Kubernetes that
-		// should cause a resource to be synchronized, such as a goroutine
-		// waiting on some asynchronous, external task to complete.
-		Watches(
-			&source.Channel{Source: ctx.GetGenericEventChannelFor(controlledTypeGVK)},
-			&handler.EnqueueRequestForObject{},
-		).
-		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles})
-
-	r := machineReconciler{
-		ControllerContext: controllerContext,
-		VMService:         &services.VimMachineService{},
-		supervisorBased:   supervisorBased,
+	if err := metrics.Registry.Register(&machineStateCollector{client: ctx.Client, logger: ctx.Logger.WithName(controllerNameShort), supervisorBased: supervisorBased}); err != nil {
+		return errors.Wrap(err, "failed to register the VSphereMachine metrics")
 	}
-
-	if supervisorBased {
-		// Watch any VirtualMachine resources owned by this VSphereMachine
-		builder.Owns(&vmoprv1.VirtualMachine{})
-		r.VMService = &vmoperator.VmopMachineService{}
-		networkProvider, err := inframanager.GetNetworkProvider(ctx)
-		if err != nil {
-			return errors.Wrap(err, "failed to create a network provider")
+	if !supervisorBased && ctx.CostWeights.Enabled() {
+		if err := metrics.Registry.Register(&machineCostCollector{client: ctx.Client, logger: ctx.Logger.WithName(controllerNameShort), weights: ctx.CostWeights}); err != nil {
+			return errors.Wrap(err, "failed to register the VSphereMachine cost metrics")
 		}
-		r.networkProvider = networkProvider
-	} else {
-		// Watch any VSphereVM resources owned by the controlled type.
-		builder.Watches(&source.Kind{Type: &infrav1.VSphereVM{}}, &handler.EnqueueRequestForOwner{OwnerType: controlledType, IsController: false})
 	}
 
-	c, err := builder.Build(r)
-	if err != nil {
-		return err
+	// The machines of the control planes are reconciled by their own
+	// controller instance, so they do not wait behind the ones of the workers.
+	provisioningDuration := newProvisioningDurationObserver()
+	var syncer *nodeSyncer
+	nodeConditions, nodeLabels := feature.Gates.Enabled(feature.NodeVMHealthConditions), feature.Gates.Enabled(feature.NodeTopologyLabels)
+	if !supervisorBased && (nodeConditions || nodeLabels) {
+		syncer = newNodeSyncer(nodeConditions, nodeLabels)
 	}
+	eventChannels := priorityEventChannelsFor(ctx, controlledTypeGVK)
+	for _, priority := range priorityClasses {
+		controllerName := priority.controllerName(controllerNameShort)
+
+		// Build the controller context.
+		controllerContext := &context.ControllerContext{
+			ControllerManagerContext: ctx,
+			Name:                     controllerName,
+			Recorder:                 record.New(mgr.GetEventRecorderFor(fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerName))),
+			Logger:                   ctx.Logger.WithName(controllerName),
+		}
+
+		builder := priority.newControllerManagedBy(mgr, controllerName).
+			// Watch the controlled, infrastructure resource.
+			For(controlledType, ctrlbldr.WithPredicates(priority.predicate())).
+			// Watch the CAPI resource that owns this infrastructure resource.
+			Watches(
+				&source.Kind{Type: &clusterv1.Machine{}},
+				handler.EnqueueRequestsFromMapFunc(clusterutilv1.MachineToInfrastructureMapFunc(controlledTypeGVK)),
+				ctrlbldr.WithPredicates(priority.predicate()),
+			).
+			// Watch a GenericEvent channel for the controlled resource.
+			//
+			// This is useful when there are events outside of Kubernetes that
+			// should cause a resource to be synchronized, such as a goroutine
+			// waiting on some asynchronous, external task to complete.
+			Watches(
+				&source.Channel{Source: eventChannels[priority]},
+				&handler.EnqueueRequestForObject{},
+			).
+			WithOptions(controller.Options{MaxConcurrentReconciles: priority.maxConcurrentReconciles(ctx)})
+
+		r := machineReconciler{
+			ControllerContext:    controllerContext,
+			VMService:            &services.VimMachineService{},
+			supervisorBased:      supervisorBased,
+			provisioningDuration: provisioningDuration,
+			nodeSyncer:           syncer,
+		}
 
-	if !supervisorBased {
-		err = c.Watch(
-			&source.Kind{Type: &clusterv1.Cluster{}},
-			handler.EnqueueRequestsFromMapFunc(r.clusterToVSphereMachines),
-			predicates.ClusterUnpausedAndInfrastructureReady(r.Logger))
+		if supervisorBased {
+			// Watch any VirtualMachine resources owned by this VSphereMachine
+			builder.Owns(&vmoprv1.VirtualMachine{})
+			r.VMService = &vmoperator.VmopMachineService{}
+			r.networkProvider = networkProvider
+		} else {
+			// Watch any VSphereVM resources owned by the controlled type.
+			builder.Watches(
+				&source.Kind{Type: &infrav1.VSphereVM{}},
+				&handler.EnqueueRequestForOwner{OwnerType: controlledType, IsController: false},
+				ctrlbldr.WithPredicates(priority.predicate()),
+			)
+		}
+
+		c, err := builder.Build(priority.reconciler(ctx.Client, func() client.Object {
+			return controlledType.DeepCopyObject().(client.Object)
+		}, r))
 		if err != nil {
 			return err
 		}
+
+		if !supervisorBased {
+			err = c.Watch(
+				&source.Kind{Type: &clusterv1.Cluster{}},
+				handler.EnqueueRequestsFromMapFunc(r.clusterToVSphereMachines),
+				predicates.ClusterUnpausedAndInfrastructureReady(r.Logger))
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -158,10 +195,20 @@ type machineReconciler struct {
 	VMService       services.VSphereMachineService
 	networkProvider services.NetworkProvider
 	supervisorBased bool
+
+	// provisioningDuration observes the provisioning duration of the Machines.
+	// A nil value observes nothing.
+	provisioningDuration *provisioningDurationObserver
+
+	// nodeSyncer copies the VM health conditions and the topology of the
+	// VSphereMachines to their Nodes. A nil value copies nothing.
+	nodeSyncer *nodeSyncer
 }
 
 // Reconcile ensures the back-end state reflects the Kubernetes resource state intent.
-func (r machineReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+func (r machineReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
 	var machineContext context.MachineContext
 	logger := r.Logger.WithName(req.Namespace).WithName(req.Name)
 	logger.V(3).Info("Starting Reconcile VSphereMachine")
@@ -238,16 +285,29 @@ func (r machineReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctr
 	}
 
 	// Handle non-deleted machines
+	r.provisioningDuration.observe(machineContext)
 	return r.reconcileNormal(machineContext)
 }
 
 func (r machineReconciler) reconcileDelete(ctx context.MachineContext) (reconcile.Result, error) {
 	ctx.GetLogger().Info("Handling deleted VSphereMachine")
+
+	// Do not delete the VSphereMachine while it is protected.
+	if _, ok := ctx.GetVSphereMachine().GetAnnotations()[infrav1.AnnotationDeleteProtection]; ok {
+		ctx.GetLogger().Info("VSphereMachine is protected from deletion, remove the annotation to proceed", "annotation", infrav1.AnnotationDeleteProtection)
+		conditions.MarkFalse(ctx.GetVSphereMachine(), infrav1.VMProvisionedCondition, infrav1.DeletionProtectedReason, clusterv1.ConditionSeverityWarning,
+			"deletion is blocked by the %s annotation", infrav1.AnnotationDeleteProtection)
+		return reconcile.Result{}, nil
+	}
+
 	conditions.MarkFalse(ctx.GetVSphereMachine(), infrav1.VMProvisionedCondition, clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
 
 	if err := r.VMService.ReconcileDelete(ctx); err != nil {
 		if apierrors.IsNotFound(err) {
 			// The VM is deleted so remove the finalizer.
+			r.reconcileNotifications(ctx)
+			r.notifyDeleted(ctx)
+			r.nodeSyncer.forget(ctx.GetVSphereMachine().GetUID())
 			ctrlutil.RemoveFinalizer(ctx.GetVSphereMachine(), infrav1.MachineFinalizer)
 			return reconcile.Result{}, nil
 		}
@@ -274,6 +334,8 @@ func (r machineReconciler) reconcileNormal(ctx context.MachineContext) (reconcil
 	// If the VSphereMachine doesn't have our finalizer, add it.
 	ctrlutil.AddFinalizer(ctx.GetVSphereMachine(), infrav1.MachineFinalizer)
 
+	r.reconcileCost(ctx)
+
 	// nolint:gocritic
 	if r.supervisorBased {
 		err := r.setVMModifiers(ctx)
@@ -302,13 +364,22 @@ func (r machineReconciler) reconcileNormal(ctx context.MachineContext) (reconcil
 	}
 
 	requeue, err := r.VMService.ReconcileNormal(ctx)
+	r.reconcileNotifications(ctx)
 	if err != nil {
 		return reconcile.Result{}, err
-	} else if requeue {
+	}
+
+	// The health of a VM matters most while the VM is not running, so it is
+	// copied to the Node even when the VSphereMachine is requeued.
+	if err := r.reconcileNode(ctx); err != nil {
+		return reconcile.Result{}, err
+	}
+	if requeue {
 		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
 	}
 
 	conditions.MarkTrue(ctx.GetVSphereMachine(), infrav1.VMProvisionedCondition)
+	markGuestBootstrapped(ctx)
 	return reconcile.Result{}, nil
 }
 
@@ -359,6 +430,18 @@ func (r *machineReconciler) setVMModifiers(c context.MachineContext) error {
 		if err != nil {
 			return nil, errors.Errorf("failed to configure machine network: %+v", err)
 		}
+		// The security groups rely on NCP realizing the labels of the VM as
+		// tags of its ports, so they are only declared behind a feature gate.
+		if !feature.Gates.Enabled(feature.NSXSecurityGroups) {
+			return vm, nil
+		}
+		securityGroups := make([]string, 0, len(ctx.VSphereMachine.Spec.SecurityGroups))
+		for _, group := range ctx.VSphereMachine.Spec.SecurityGroups {
+			securityGroups = append(securityGroups, string(group))
+		}
+		if err := r.networkProvider.ConfigureVirtualMachineSecurityGroups(ctx.ClusterContext, vm, securityGroups); err != nil {
+			return nil, errors.Errorf("failed to configure machine security groups: %+v", err)
+		}
 		return vm, nil
 	}
 	ctx.VMModifiers = []vmware.VMModifier{networkModifier}
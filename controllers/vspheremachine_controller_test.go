@@ -17,9 +17,13 @@ limitations under the License.
 package controllers
 
 import (
+	goctx "context"
+	"testing"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -28,6 +32,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services"
 )
 
 var _ = Describe("VsphereMachineReconciler", func() {
@@ -205,3 +211,35 @@ var _ = Describe("VsphereMachineReconciler", func() {
 		})
 	})
 })
+
+func TestMachineReconciler_ReconcileDeleteWithDeleteProtection(t *testing.T) {
+	g := NewWithT(t)
+
+	controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+	machineCtx := fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+	machineCtx.VSphereMachine.Annotations = map[string]string{infrav1.AnnotationDeleteProtection: ""}
+	machineCtx.VSphereMachine.Finalizers = []string{infrav1.MachineFinalizer}
+	vm := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: machineCtx.VSphereMachine.Namespace, Name: machineCtx.Machine.Name}}
+	g.Expect(controllerCtx.Client.Create(goctx.Background(), vm)).To(Succeed())
+	r := machineReconciler{ControllerContext: controllerCtx, VMService: &services.VimMachineService{}}
+
+	// The VSphereVM of a protected VSphereMachine is not deleted.
+	_, err := r.reconcileDelete(machineCtx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(machineCtx.VSphereMachine.Finalizers).To(ContainElement(infrav1.MachineFinalizer))
+	g.Expect(conditions.GetReason(machineCtx.VSphereMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.DeletionProtectedReason))
+	g.Expect(controllerCtx.Client.Get(goctx.Background(), client.ObjectKeyFromObject(vm), &infrav1.VSphereVM{})).To(Succeed())
+
+	// Once the annotation is removed, the VSphereVM is deleted, then the
+	// finalizer of the VSphereMachine is removed.
+	delete(machineCtx.VSphereMachine.Annotations, infrav1.AnnotationDeleteProtection)
+	_, err = r.reconcileDelete(machineCtx)
+	g.Expect(err).NotTo(HaveOccurred())
+	err = controllerCtx.Client.Get(goctx.Background(), client.ObjectKeyFromObject(vm), &infrav1.VSphereVM{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	g.Expect(machineCtx.VSphereMachine.Finalizers).To(ContainElement(infrav1.MachineFinalizer))
+
+	_, err = r.reconcileDelete(machineCtx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(machineCtx.VSphereMachine.Finalizers).NotTo(ContainElement(infrav1.MachineFinalizer))
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+var (
+	machineCostDesc = prometheus.NewDesc(
+		"capv_machine_estimated_hourly_cost",
+		"Estimated hourly cost of the VM of each VSphereMachine, from the cost weights of the controller manager, by namespace, cluster and machine.",
+		[]string{"namespace", "cluster", "machine"},
+		nil,
+	)
+	clusterCostDesc = prometheus.NewDesc(
+		"capv_cluster_estimated_hourly_cost",
+		"Estimated hourly cost of the VMs of the VSphereMachines of each cluster, from the cost weights of the controller manager, by namespace and cluster.",
+		[]string{"namespace", "cluster"},
+		nil,
+	)
+)
+
+// reconcileCost sets the estimated hourly cost of the VM of a VSphereMachine
+// in its annotation, or removes the annotation if the cost is not estimated.
+// The VSphereMachines of supervisor clusters, whose resources are defined by
+// their VM class, are not estimated.
+func (r machineReconciler) reconcileCost(ctx context.MachineContext) {
+	vimCtx, ok := ctx.(*context.VIMMachineContext)
+	if !ok {
+		return
+	}
+	vsphereMachine := vimCtx.VSphereMachine
+	if !r.CostWeights.Enabled() {
+		delete(vsphereMachine.Annotations, infrav1.MachineEstimatedHourlyCostAnnotation)
+		return
+	}
+	if vsphereMachine.Annotations == nil {
+		vsphereMachine.Annotations = map[string]string{}
+	}
+	cost := r.CostWeights.MachineHourlyCost(&vsphereMachine.Spec.VirtualMachineCloneSpec)
+	vsphereMachine.Annotations[infrav1.MachineEstimatedHourlyCostAnnotation] = context.FormatCost(cost)
+}
+
+// machineCostCollector reports the estimated hourly cost of the VSphereMachines
+// and of their clusters when the metrics are scraped, from the cache of the
+// manager.
+type machineCostCollector struct {
+	client  ctrlclient.Reader
+	logger  logr.Logger
+	weights context.CostWeights
+}
+
+// Describe implements prometheus.Collector.
+func (c *machineCostCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- machineCostDesc
+	ch <- clusterCostDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *machineCostCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := goctx.WithTimeout(goctx.Background(), machineStateListTimeout)
+	defer cancel()
+
+	machines := &infrav1.VSphereMachineList{}
+	if err := c.client.List(ctx, machines); err != nil {
+		c.logger.Error(err, "failed to list the VSphereMachines for the cost metrics")
+		return
+	}
+	clusters := map[[2]string]float64{}
+	for i := range machines.Items {
+		m := &machines.Items[i]
+		cluster := m.Labels[clusterv1.ClusterLabelName]
+		cost := c.weights.MachineHourlyCost(&m.Spec.VirtualMachineCloneSpec)
+		ch <- prometheus.MustNewConstMetric(machineCostDesc, prometheus.GaugeValue, cost, m.Namespace, cluster, m.Name)
+		clusters[[2]string{m.Namespace, cluster}] += cost
+	}
+	for key, cost := range clusters {
+		ch <- prometheus.MustNewConstMetric(clusterCostDesc, prometheus.GaugeValue, cost, key[0], key[1])
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+var testCostWeights = context.CostWeights{VCPUHour: 0.03, MemoryGiBHour: 0.004, DiskGiBHour: 0.0001}
+
+func costMachine(name, cluster string, spec infrav1.VirtualMachineCloneSpec) *infrav1.VSphereMachine {
+	return &infrav1.VSphereMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      name,
+			Labels:    map[string]string{clusterv1.ClusterLabelName: cluster},
+		},
+		Spec: infrav1.VSphereMachineSpec{VirtualMachineCloneSpec: spec},
+	}
+}
+
+func TestMachineHourlyCost(t *testing.T) {
+	g := NewWithT(t)
+	spec := &infrav1.VirtualMachineCloneSpec{
+		NumCPUs:   4,
+		MemoryMiB: 8192,
+		DiskGiB:   40,
+		DataDisks: []infrav1.DataDiskSpec{{Name: "etcd", SizeGiB: 60}},
+	}
+	// 4 * 0.03 + 8 * 0.004 + 100 * 0.0001
+	g.Expect(testCostWeights.MachineHourlyCost(spec)).To(BeNumerically("~", 0.162, 1e-9))
+	g.Expect(testCostWeights.MachineHourlyCost(&infrav1.VirtualMachineCloneSpec{})).To(BeZero())
+	g.Expect(context.FormatCost(0.162)).To(Equal("0.1620"))
+	g.Expect(context.CostWeights{}.Enabled()).To(BeFalse())
+	g.Expect(testCostWeights.Enabled()).To(BeTrue())
+}
+
+func TestMachineCostCollector(t *testing.T) {
+	g := NewWithT(t)
+	mgmtContext := fake.NewControllerManagerContext(
+		costMachine("small", "cluster", infrav1.VirtualMachineCloneSpec{NumCPUs: 2, MemoryMiB: 4096}),
+		costMachine("large", "cluster", infrav1.VirtualMachineCloneSpec{NumCPUs: 8, MemoryMiB: 16384, DiskGiB: 100}),
+		costMachine("other", "other-cluster", infrav1.VirtualMachineCloneSpec{NumCPUs: 1}),
+	)
+	c := &machineCostCollector{client: mgmtContext.Client, logger: mgmtContext.Logger, weights: testCostWeights}
+
+	g.Expect(testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP capv_cluster_estimated_hourly_cost Estimated hourly cost of the VMs of the VSphereMachines of each cluster, from the cost weights of the controller manager, by namespace and cluster.
+# TYPE capv_cluster_estimated_hourly_cost gauge
+capv_cluster_estimated_hourly_cost{cluster="cluster",namespace="default"} 0.39
+capv_cluster_estimated_hourly_cost{cluster="other-cluster",namespace="default"} 0.03
+# HELP capv_machine_estimated_hourly_cost Estimated hourly cost of the VM of each VSphereMachine, from the cost weights of the controller manager, by namespace, cluster and machine.
+# TYPE capv_machine_estimated_hourly_cost gauge
+capv_machine_estimated_hourly_cost{cluster="cluster",machine="large",namespace="default"} 0.314
+capv_machine_estimated_hourly_cost{cluster="cluster",machine="small",namespace="default"} 0.076
+capv_machine_estimated_hourly_cost{cluster="other-cluster",machine="other",namespace="default"} 0.03
+`))).To(Succeed())
+}
+
+func TestMachineReconcileCost(t *testing.T) {
+	g := NewWithT(t)
+	controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+	machineCtx := fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+	machineCtx.VSphereMachine.Spec.NumCPUs = 2
+	machineCtx.VSphereMachine.Spec.MemoryMiB = 4096
+	r := machineReconciler{ControllerContext: controllerCtx}
+
+	// 2 * 0.03 + 4 * 0.004 + 20 * 0.0001, with the disk of the fake machine.
+	controllerCtx.CostWeights = testCostWeights
+	r.reconcileCost(machineCtx)
+	g.Expect(machineCtx.VSphereMachine.Annotations).To(HaveKeyWithValue(infrav1.MachineEstimatedHourlyCostAnnotation, "0.0780"))
+
+	controllerCtx.CostWeights = context.CostWeights{}
+	r.reconcileCost(machineCtx)
+	g.Expect(machineCtx.VSphereMachine.Annotations).NotTo(HaveKey(infrav1.MachineEstimatedHourlyCostAnnotation))
+}
+
+func TestClusterReconcileCost(t *testing.T) {
+	g := NewWithT(t)
+	controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(
+		costMachine("small", fake.Clusterv1a2Name, infrav1.VirtualMachineCloneSpec{NumCPUs: 2, MemoryMiB: 4096}),
+		costMachine("large", fake.Clusterv1a2Name, infrav1.VirtualMachineCloneSpec{NumCPUs: 8, MemoryMiB: 16384, DiskGiB: 100}),
+		costMachine("other", "other-cluster", infrav1.VirtualMachineCloneSpec{NumCPUs: 1}),
+	))
+	clusterCtx := fake.NewClusterContext(controllerCtx)
+	r := clusterReconciler{ControllerContext: controllerCtx}
+
+	controllerCtx.CostWeights = testCostWeights
+	g.Expect(r.reconcileCost(clusterCtx)).To(Succeed())
+	g.Expect(clusterCtx.VSphereCluster.Annotations).To(HaveKeyWithValue(infrav1.ClusterEstimatedHourlyCostAnnotation, "0.3900"))
+
+	clusterCtx.Cluster.Spec.InfrastructureRef = &corev1.ObjectReference{Kind: "VSphereCluster", Name: clusterCtx.VSphereCluster.Name}
+	g.Expect(controllerCtx.Client.Update(controllerCtx, clusterCtx.Cluster)).To(Succeed())
+	g.Expect(r.machineToClusterCost(costMachine("small", fake.Clusterv1a2Name, infrav1.VirtualMachineCloneSpec{}))).To(ConsistOf(
+		reconcile.Request{NamespacedName: client.ObjectKeyFromObject(clusterCtx.VSphereCluster)}))
+
+	controllerCtx.CostWeights = context.CostWeights{}
+	g.Expect(r.reconcileCost(clusterCtx)).To(Succeed())
+	g.Expect(clusterCtx.VSphereCluster.Annotations).NotTo(HaveKey(infrav1.ClusterEstimatedHourlyCostAnnotation))
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// nodeVMHealthConditionTypes maps the VM health conditions of a VSphereMachine
+// to the conditions of its Node, which the unhealthyConditions of a
+// MachineHealthCheck can match.
+var nodeVMHealthConditionTypes = []struct {
+	machine clusterv1.ConditionType
+	node    corev1.NodeConditionType
+}{
+	{machine: infrav1.GuestHeartbeatHealthyCondition, node: "VSphereGuestHeartbeatHealthy"},
+	{machine: infrav1.VMRunningCondition, node: "VSphereVMRunning"},
+}
+
+// nodeVMHealthConditions returns the Node conditions of the VM health
+// conditions of a VSphereMachine.
+func nodeVMHealthConditions(vsphereMachine *infrav1.VSphereMachine) []corev1.NodeCondition {
+	nodeConditions := []corev1.NodeCondition{}
+	for _, t := range nodeVMHealthConditionTypes {
+		if c := conditions.Get(vsphereMachine, t.machine); c != nil {
+			nodeConditions = append(nodeConditions, corev1.NodeCondition{
+				Type:               t.node,
+				Status:             c.Status,
+				Reason:             c.Reason,
+				Message:            c.Message,
+				LastTransitionTime: c.LastTransitionTime,
+			})
+		}
+	}
+	return nodeConditions
+}
+
+// mergeNodeVMHealthConditions replaces the VM health conditions of a Node
+// with the desired ones, heartbeating at the given time, and keeps its other
+// conditions.
+func mergeNodeVMHealthConditions(current, desired []corev1.NodeCondition, now metav1.Time) []corev1.NodeCondition {
+	merged := []corev1.NodeCondition{}
+	for _, c := range current {
+		isVMHealth := false
+		for _, t := range nodeVMHealthConditionTypes {
+			isVMHealth = isVMHealth || c.Type == t.node
+		}
+		if !isVMHealth {
+			merged = append(merged, c)
+		}
+	}
+	for _, c := range desired {
+		c.LastHeartbeatTime = now
+		merged = append(merged, c)
+	}
+	return merged
+}
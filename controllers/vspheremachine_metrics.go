@@ -0,0 +1,204 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+const (
+	machineStateProvisioning = "provisioning"
+	machineStateReady        = "ready"
+	machineStateFailed       = "failed"
+	machineStateDeleting     = "deleting"
+
+	// machineStateListTimeout is the timeout of the listing of the
+	// VSphereMachines on a scrape.
+	machineStateListTimeout = 10 * time.Second
+)
+
+var machinesDesc = prometheus.NewDesc(
+	"capv_machines",
+	"Number of VSphereMachines, by namespace, cluster and state (provisioning, ready, failed or deleting).",
+	[]string{"namespace", "cluster", "state"},
+	nil,
+)
+
+// machineProvisioningDuration observes the time from the creation of each
+// Machine to the readiness of its Node.
+var machineProvisioningDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "capv_machine_provisioning_duration_seconds",
+		Help:    "Time from the creation of a Machine to the readiness of its Node, by namespace, cluster, role (control-plane or worker), machine template and VM template.",
+		Buckets: []float64{30, 60, 120, 180, 300, 450, 600, 900, 1200, 1800, 2700, 3600},
+	},
+	[]string{"namespace", "cluster", "role", "machine_template", "vm_template"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(machineProvisioningDuration)
+}
+
+// provisioningDurationPending is the value of the provisioning duration
+// annotation of a VSphereMachine whose Machine is not observed yet.
+const provisioningDurationPending = "pending"
+
+// provisioningDurationObserver observes the provisioning duration of each
+// Machine once, when its VSphereMachine is first reconciled after the Node of
+// the Machine became healthy. Whether a Machine was observed is recorded in the
+// MachineProvisioningDurationAnnotation of its VSphereMachine, so that the
+// Machines are not observed again when the controller manager restarts, e.g.
+// once their Node was unhealthy for a while.
+type provisioningDurationObserver struct{}
+
+// newProvisioningDurationObserver returns an observer of the Machines.
+func newProvisioningDurationObserver() *provisioningDurationObserver {
+	return &provisioningDurationObserver{}
+}
+
+// observe observes the provisioning duration of the Machine of a
+// VSphereMachine, from the creation timestamp of the Machine to the last
+// transition of its NodeHealthy condition. Only the Machines whose Node had
+// not joined the cluster when their VSphereMachine was first reconciled are
+// observed, so that the Machines provisioned before the annotation existed are
+// not observed with the time of a later transition.
+func (o *provisioningDurationObserver) observe(ctx context.MachineContext) {
+	if o == nil {
+		return
+	}
+	machine := ctx.GetMachine()
+	if machine == nil {
+		return
+	}
+	vsphereMachine := ctx.GetVSphereMachine()
+	value, ok := vsphereMachine.GetAnnotations()[infrav1.MachineProvisioningDurationAnnotation]
+	if !ok {
+		if machine.Status.NodeRef == nil {
+			annotations.AddAnnotations(vsphereMachine, map[string]string{infrav1.MachineProvisioningDurationAnnotation: provisioningDurationPending})
+		}
+		return
+	}
+	if value != provisioningDurationPending || machine.Status.NodeRef == nil {
+		return
+	}
+	nodeHealthy := conditions.Get(machine, clusterv1.MachineNodeHealthyCondition)
+	if nodeHealthy == nil || nodeHealthy.Status != corev1.ConditionTrue {
+		return
+	}
+
+	role := "worker"
+	if util.IsControlPlaneMachine(vsphereMachine) {
+		role = "control-plane"
+	}
+	var vmTemplate string
+	switch m := vsphereMachine.(type) {
+	case *infrav1.VSphereMachine:
+		vmTemplate = m.Spec.Template
+	case *vmwarev1.VSphereMachine:
+		vmTemplate = m.Spec.ImageName
+	}
+	duration := nodeHealthy.LastTransitionTime.Sub(machine.CreationTimestamp.Time)
+	machineProvisioningDuration.WithLabelValues(
+		machine.Namespace,
+		machine.Spec.ClusterName,
+		role,
+		vsphereMachine.GetAnnotations()[clusterv1.TemplateClonedFromNameAnnotation],
+		vmTemplate,
+	).Observe(duration.Seconds())
+	annotations.AddAnnotations(vsphereMachine, map[string]string{infrav1.MachineProvisioningDurationAnnotation: duration.Round(time.Second).String()})
+}
+
+// machineStateCollector reports the number of VSphereMachines per state when
+// the metrics are scraped, from the cache of the manager.
+type machineStateCollector struct {
+	client          ctrlclient.Reader
+	logger          logr.Logger
+	supervisorBased bool
+}
+
+// Describe implements prometheus.Collector.
+func (c *machineStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- machinesDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *machineStateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := goctx.WithTimeout(goctx.Background(), machineStateListTimeout)
+	defer cancel()
+
+	counts := map[[3]string]int{}
+	count := func(meta metav1.ObjectMeta, ready bool, failureReason *capierrors.MachineStatusError, failureMessage *string) {
+		key := [3]string{meta.Namespace, meta.Labels[clusterv1.ClusterLabelName], machineState(meta, ready, failureReason, failureMessage)}
+		counts[key]++
+	}
+	if c.supervisorBased {
+		machines := &vmwarev1.VSphereMachineList{}
+		if err := c.client.List(ctx, machines); err != nil {
+			c.logger.Error(err, "failed to list the VSphereMachines for the metrics")
+			return
+		}
+		for i := range machines.Items {
+			m := &machines.Items[i]
+			count(m.ObjectMeta, m.Status.Ready, m.Status.FailureReason, m.Status.FailureMessage)
+		}
+	} else {
+		machines := &infrav1.VSphereMachineList{}
+		if err := c.client.List(ctx, machines); err != nil {
+			c.logger.Error(err, "failed to list the VSphereMachines for the metrics")
+			return
+		}
+		for i := range machines.Items {
+			m := &machines.Items[i]
+			count(m.ObjectMeta, m.Status.Ready, m.Status.FailureReason, m.Status.FailureMessage)
+		}
+	}
+
+	for key, n := range counts {
+		ch <- prometheus.MustNewConstMetric(machinesDesc, prometheus.GaugeValue, float64(n), key[0], key[1], key[2])
+	}
+}
+
+// machineState returns the state of a VSphereMachine reported by the metrics.
+func machineState(meta metav1.ObjectMeta, ready bool, failureReason *capierrors.MachineStatusError, failureMessage *string) string {
+	switch {
+	case !meta.DeletionTimestamp.IsZero():
+		return machineStateDeleting
+	case failureReason != nil || failureMessage != nil:
+		return machineStateFailed
+	case ready:
+		return machineStateReady
+	default:
+		return machineStateProvisioning
+	}
+}
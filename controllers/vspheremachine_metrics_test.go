@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestMachineStateCollector(t *testing.T) {
+	g := NewWithT(t)
+	machine := func(name string, status infrav1.VSphereMachineStatus) *infrav1.VSphereMachine {
+		return &infrav1.VSphereMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      name,
+				Labels:    map[string]string{clusterv1.ClusterLabelName: "cluster"},
+			},
+			Status: status,
+		}
+	}
+	failureReason := capierrors.CreateMachineError
+	mgmtContext := fake.NewControllerManagerContext(
+		machine("provisioning", infrav1.VSphereMachineStatus{}),
+		machine("ready-0", infrav1.VSphereMachineStatus{Ready: true}),
+		machine("ready-1", infrav1.VSphereMachineStatus{Ready: true}),
+		machine("failed", infrav1.VSphereMachineStatus{Ready: true, FailureReason: &failureReason}),
+	)
+	c := &machineStateCollector{client: mgmtContext.Client, logger: mgmtContext.Logger}
+
+	g.Expect(testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP capv_machines Number of VSphereMachines, by namespace, cluster and state (provisioning, ready, failed or deleting).
+# TYPE capv_machines gauge
+capv_machines{cluster="cluster",namespace="default",state="failed"} 1
+capv_machines{cluster="cluster",namespace="default",state="provisioning"} 1
+capv_machines{cluster="cluster",namespace="default",state="ready"} 2
+`))).To(Succeed())
+}
+
+func TestProvisioningDurationObserver(t *testing.T) {
+	observer := newProvisioningDurationObserver()
+	machineContext := func(name string, joined, healthy bool, healthySince time.Time) *context.VIMMachineContext {
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         fake.Namespace,
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(healthySince.Add(-5 * time.Minute)),
+			},
+			Spec: clusterv1.MachineSpec{ClusterName: "observed-cluster"},
+		}
+		if joined {
+			machine.Status.NodeRef = &corev1.ObjectReference{Name: name}
+		}
+		status := corev1.ConditionFalse
+		if healthy {
+			status = corev1.ConditionTrue
+		}
+		machine.SetConditions(clusterv1.Conditions{{Type: clusterv1.MachineNodeHealthyCondition, Status: status, LastTransitionTime: metav1.NewTime(healthySince)}})
+		vsphereMachine := &infrav1.VSphereMachine{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   fake.Namespace,
+			Name:        name,
+			Annotations: map[string]string{clusterv1.TemplateClonedFromNameAnnotation: "md-0"},
+		}}
+		vsphereMachine.Spec.Template = "ubuntu-2004"
+		return &context.VIMMachineContext{BaseMachineContext: &context.BaseMachineContext{Machine: machine}, VSphereMachine: vsphereMachine}
+	}
+	sampleCount := func() uint64 {
+		histogram := machineProvisioningDuration.WithLabelValues(fake.Namespace, "observed-cluster", "worker", "md-0", "ubuntu-2004").(prometheus.Histogram)
+		metric := &dto.Metric{}
+		if err := histogram.Write(metric); err != nil {
+			t.Fatal(err)
+		}
+		return metric.GetHistogram().GetSampleCount()
+	}
+
+	t.Run("observes a Machine once its Node is healthy", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := machineContext("machine", false, false, time.Now())
+		observer.observe(ctx)
+		g.Expect(ctx.VSphereMachine.Annotations).To(HaveKeyWithValue(infrav1.MachineProvisioningDurationAnnotation, "pending"))
+		g.Expect(sampleCount()).To(BeZero())
+
+		healthy := machineContext("machine", true, true, time.Now())
+		healthy.VSphereMachine.Annotations = ctx.VSphereMachine.Annotations
+		observer.observe(healthy)
+		g.Expect(healthy.VSphereMachine.Annotations).To(HaveKeyWithValue(infrav1.MachineProvisioningDurationAnnotation, "5m0s"))
+		g.Expect(sampleCount()).To(Equal(uint64(1)))
+
+		// The observation is recorded in the VSphereMachine, so the Machine
+		// is not observed again, e.g. by a restarted controller manager.
+		observer.observe(healthy)
+		observer = newProvisioningDurationObserver()
+		observer.observe(healthy)
+		g.Expect(sampleCount()).To(Equal(uint64(1)))
+	})
+
+	t.Run("does not observe the Machines whose Node joined before their first reconcile", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := machineContext("already-healthy", true, true, time.Now())
+		observer.observe(ctx)
+		observer.observe(ctx)
+		g.Expect(ctx.VSphereMachine.Annotations).NotTo(HaveKey(infrav1.MachineProvisioningDurationAnnotation))
+		g.Expect(sampleCount()).To(Equal(uint64(1)))
+	})
+}
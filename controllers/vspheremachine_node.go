@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// nodeSyncer copies the VM health conditions and the topology of the
+// VSphereMachines to the conditions and the labels of their Nodes.
+type nodeSyncer struct {
+	remoteClientGetter remote.ClusterClientGetter
+
+	// conditions and labels enable the copy of the VM health conditions and
+	// of the topology labels.
+	conditions, labels bool
+
+	// synced holds the Node state last copied for each VSphereMachine, by
+	// UID, so the Node is only patched when it changes.
+	synced sync.Map
+}
+
+// nodeState is the state of a Node copied from its VSphereMachine.
+type nodeState struct {
+	conditions []corev1.NodeCondition
+	labels     map[string]string
+}
+
+func newNodeSyncer(conditions, labels bool) *nodeSyncer {
+	return &nodeSyncer{remoteClientGetter: remote.NewClusterClient, conditions: conditions, labels: labels}
+}
+
+// forget drops the Node state last copied for a VSphereMachine. It does
+// nothing on a nil syncer.
+func (s *nodeSyncer) forget(uid types.UID) {
+	if s == nil {
+		return
+	}
+	s.synced.Delete(uid)
+}
+
+// reconcileNode copies the VM health conditions and the topology labels of a
+// VSphereMachine to the Node of its Machine, when they changed since they were
+// last copied. It does nothing without a node syncer, which is only set with
+// the NodeVMHealthConditions or the NodeTopologyLabels feature gate.
+func (r machineReconciler) reconcileNode(ctx context.MachineContext) error {
+	s := r.nodeSyncer
+	vimCtx, ok := ctx.(*context.VIMMachineContext)
+	if s == nil || !ok || vimCtx.Machine.Status.NodeRef == nil {
+		return nil
+	}
+	vsphereMachine, machine := vimCtx.VSphereMachine, vimCtx.Machine
+
+	desired := nodeState{conditions: []corev1.NodeCondition{}, labels: map[string]string{}}
+	if s.conditions {
+		desired.conditions = nodeVMHealthConditions(vsphereMachine)
+	}
+	if s.labels {
+		desired.labels = nodeTopologyLabels(vimCtx)
+	}
+	synced, ok := s.synced.Load(vsphereMachine.GetUID())
+	if ok && apiequality.Semantic.DeepEqual(synced.(nodeState).conditions, desired.conditions) && apiequality.Semantic.DeepEqual(synced.(nodeState).labels, desired.labels) {
+		return nil
+	}
+
+	clusterKey := clusterutilv1.ObjectKey(vimCtx.Cluster)
+	guestClient, err := s.remoteClientGetter(vimCtx, r.Name, r.Client, clusterKey)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the client of Cluster %s", clusterKey)
+	}
+	node := &corev1.Node{}
+	if err := guestClient.Get(vimCtx, client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get Node %s", machine.Status.NodeRef.Name)
+	}
+
+	if s.labels {
+		patchBase := client.MergeFrom(node.DeepCopy())
+		setNodeTopologyLabels(node, desired.labels)
+		if err := guestClient.Patch(vimCtx, node, patchBase); err != nil {
+			return errors.Wrapf(err, "failed to patch the labels of Node %s", node.Name)
+		}
+	}
+	if s.conditions {
+		patchBase := client.StrategicMergeFrom(node.DeepCopy())
+		node.Status.Conditions = mergeNodeVMHealthConditions(node.Status.Conditions, desired.conditions, metav1.Now())
+		if err := guestClient.Status().Patch(vimCtx, node, patchBase); err != nil {
+			return errors.Wrapf(err, "failed to patch the conditions of Node %s", node.Name)
+		}
+	}
+	vimCtx.Logger.V(4).Info("Copied the VM health conditions and the topology labels to the Node", "node", node.Name)
+	s.synced.Store(vsphereMachine.GetUID(), desired)
+	return nil
+}
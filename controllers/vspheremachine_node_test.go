@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestReconcileNodeConditions(t *testing.T) {
+	g := NewWithT(t)
+	controllerContext := fake.NewControllerContext(fake.NewControllerManagerContext())
+	machineContext := fake.NewMachineContext(fake.NewClusterContext(controllerContext))
+	machineContext.Machine.Status.NodeRef = &corev1.ObjectReference{Name: "node-0"}
+	vsphereMachine := machineContext.VSphereMachine
+
+	guestClient := fake.NewFakeGuestClusterClient(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-0"},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+		}},
+	})
+	clientRequests := 0
+	syncer := newNodeSyncer(true, false)
+	syncer.remoteClientGetter = func(goctx.Context, string, client.Client, client.ObjectKey) (client.Client, error) {
+		clientRequests++
+		return guestClient, nil
+	}
+	r := machineReconciler{ControllerContext: controllerContext, nodeSyncer: syncer}
+	nodeCondition := func(conditionType corev1.NodeConditionType) *corev1.NodeCondition {
+		node := &corev1.Node{}
+		g.Expect(guestClient.Get(goctx.Background(), client.ObjectKey{Name: "node-0"}, node)).To(Succeed())
+		for i := range node.Status.Conditions {
+			if node.Status.Conditions[i].Type == conditionType {
+				return &node.Status.Conditions[i]
+			}
+		}
+		return nil
+	}
+
+	// The VM health conditions are copied to the Node, which keeps its other
+	// conditions.
+	conditions.MarkTrue(vsphereMachine, infrav1.GuestHeartbeatHealthyCondition)
+	conditions.MarkFalse(vsphereMachine, infrav1.VMRunningCondition, infrav1.PoweredOffExternallyReason, clusterv1.ConditionSeverityWarning, "powered off")
+	g.Expect(r.reconcileNode(machineContext)).To(Succeed())
+	g.Expect(nodeCondition("VSphereGuestHeartbeatHealthy").Status).To(Equal(corev1.ConditionTrue))
+	g.Expect(nodeCondition("VSphereVMRunning").Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(nodeCondition("VSphereVMRunning").Reason).To(Equal(infrav1.PoweredOffExternallyReason))
+	g.Expect(nodeCondition(corev1.NodeReady)).NotTo(BeNil())
+	g.Expect(clientRequests).To(Equal(1))
+
+	// The Node is not patched again while the conditions do not change.
+	g.Expect(r.reconcileNode(machineContext)).To(Succeed())
+	g.Expect(clientRequests).To(Equal(1))
+
+	// A condition deleted from the VSphereMachine is deleted from the Node.
+	conditions.Delete(vsphereMachine, infrav1.VMRunningCondition)
+	g.Expect(r.reconcileNode(machineContext)).To(Succeed())
+	g.Expect(nodeCondition("VSphereVMRunning")).To(BeNil())
+	g.Expect(nodeCondition("VSphereGuestHeartbeatHealthy")).NotTo(BeNil())
+	g.Expect(clientRequests).To(Equal(2))
+
+	// Nothing is copied without the feature gate, which leaves the syncer nil.
+	r.nodeSyncer = nil
+	conditions.MarkFalse(vsphereMachine, infrav1.GuestHeartbeatHealthyCondition, infrav1.GuestToolsNotRunningReason, clusterv1.ConditionSeverityWarning, "")
+	g.Expect(r.reconcileNode(machineContext)).To(Succeed())
+	g.Expect(nodeCondition("VSphereGuestHeartbeatHealthy").Status).To(Equal(corev1.ConditionTrue))
+}
+
+func TestReconcileNodeLabels(t *testing.T) {
+	g := NewWithT(t)
+	controllerContext := fake.NewControllerContext(fake.NewControllerManagerContext())
+	machineContext := fake.NewMachineContext(fake.NewClusterContext(controllerContext))
+	machineContext.Machine.Status.NodeRef = &corev1.ObjectReference{Name: "node-0"}
+	machineContext.VSphereMachine.Status.Topology = &infrav1.MachineTopologyStatus{
+		Region:    "region-a",
+		Zone:      "zone-a",
+		Host:      "esx-0.example.com",
+		Datastore: "local datastore",
+	}
+	conditions.MarkTrue(machineContext.VSphereMachine, infrav1.GuestHeartbeatHealthyCondition)
+
+	guestClient := fake.NewFakeGuestClusterClient(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-0", Labels: map[string]string{corev1.LabelHostname: "node-0"}},
+	})
+	syncer := newNodeSyncer(false, true)
+	syncer.remoteClientGetter = func(goctx.Context, string, client.Client, client.ObjectKey) (client.Client, error) {
+		return guestClient, nil
+	}
+	r := machineReconciler{ControllerContext: controllerContext, nodeSyncer: syncer}
+	node := func() *corev1.Node {
+		node := &corev1.Node{}
+		g.Expect(guestClient.Get(goctx.Background(), client.ObjectKey{Name: "node-0"}, node)).To(Succeed())
+		return node
+	}
+
+	// The labels with a valid value are set, the other labels of the Node are
+	// kept, and the conditions are not copied without their feature gate.
+	g.Expect(r.reconcileNode(machineContext)).To(Succeed())
+	g.Expect(node().Labels).To(Equal(map[string]string{
+		corev1.LabelHostname:       "node-0",
+		corev1.LabelTopologyRegion: "region-a",
+		corev1.LabelTopologyZone:   "zone-a",
+		infrav1.NodeESXiHostLabel:  "esx-0.example.com",
+	}))
+	g.Expect(node().Status.Conditions).To(BeEmpty())
+
+	// The labels follow the VM when it moves to another host.
+	machineContext.VSphereMachine.Status.Topology.Host = "esx-1.example.com"
+	g.Expect(r.reconcileNode(machineContext)).To(Succeed())
+	g.Expect(node().Labels).To(HaveKeyWithValue(infrav1.NodeESXiHostLabel, "esx-1.example.com"))
+
+	// The labels set before are removed once they are no longer part of the
+	// topology, and the labels set by others are kept.
+	guestNode := node()
+	guestNode.Labels["example.com/owner"] = "ops"
+	g.Expect(guestClient.Update(goctx.Background(), guestNode)).To(Succeed())
+	machineContext.VSphereMachine.Status.Topology.Host = ""
+	machineContext.VSphereMachine.Status.Topology.Zone = ""
+	g.Expect(r.reconcileNode(machineContext)).To(Succeed())
+	g.Expect(node().Labels).To(Equal(map[string]string{
+		corev1.LabelHostname:       "node-0",
+		corev1.LabelTopologyRegion: "region-a",
+		"example.com/owner":        "ops",
+	}))
+	g.Expect(node().Annotations).To(HaveKeyWithValue(infrav1.NodeTopologyLabelsAnnotation, corev1.LabelTopologyRegion))
+}
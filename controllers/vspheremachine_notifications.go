@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/notify"
+)
+
+// notifiedEventsAnnotation records the machine lifecycle events already sent
+// for a VSphereMachine, so that each event is only sent once it is delivered
+// to all its sinks.
+const notifiedEventsAnnotation = "vsphere.infrastructure.cluster.x-k8s.io/notified-events"
+
+// lifecycleEvent is a lifecycle event of a VSphereMachine, which occurred
+// when the VSphereMachine reached its point of the lifecycle.
+type lifecycleEvent struct {
+	eventType notify.EventType
+	occurred  bool
+	reason    string
+}
+
+// reconcileNotifications sends the lifecycle events of a VSphereMachine which
+// were not sent yet, in the order of the lifecycle. The events are sent in the
+// background, so that the reconciliation does not wait for the sinks, and the
+// VSphereMachine is reconciled again once the delivery is over to record it.
+// An event whose delivery failed is sent again on the next reconciliation, and
+// blocks the following events so that they are received in order. A failed
+// delivery is logged and does not fail the reconciliation.
+func (r machineReconciler) reconcileNotifications(ctx context.MachineContext) {
+	vimCtx, ok := ctx.(*context.VIMMachineContext)
+	if !ok || r.MachineNotifier == nil {
+		return
+	}
+	vsphereMachine, machine := vimCtx.VSphereMachine, vimCtx.Machine
+
+	vm := &infrav1.VSphereVM{}
+	if err := r.Client.Get(vimCtx, client.ObjectKeyFromObject(vsphereMachine), vm); err != nil {
+		if !apierrors.IsNotFound(err) {
+			ctx.GetLogger().Error(err, "failed to get the VSphereVM of the machine lifecycle events")
+			return
+		}
+		vm = nil
+	}
+
+	events := []lifecycleEvent{
+		{eventType: notify.MachineCreated, occurred: vm != nil && vm.Status.Ready && vm.Spec.BiosUUID != ""},
+		{eventType: notify.MachineJoined, occurred: machine.Status.NodeRef != nil},
+	}
+	if conditions.IsFalse(machine, clusterv1.MachineOwnerRemediatedCondition) {
+		events = append(events, lifecycleEvent{
+			eventType: notify.MachineRemediated,
+			occurred:  true,
+			reason:    conditions.GetMessage(machine, clusterv1.MachineOwnerRemediatedCondition),
+		})
+	}
+
+	for _, e := range events {
+		if !e.occurred {
+			// A machine may be remediated without its node ever joining.
+			if e.eventType == notify.MachineJoined {
+				continue
+			}
+			return
+		}
+		if wasNotified(vsphereMachine, e.eventType) {
+			continue
+		}
+		notification := notify.NewEvent(e.eventType, machine, vsphereMachine, vm)
+		notification.Reason = e.reason
+		obj := vsphereMachine.DeepCopy()
+		delivered, err := r.MachineNotifier.NotifyAsync(r, notification, func() { r.requeueVSphereMachine(obj) })
+		if err != nil {
+			ctx.GetLogger().Error(err, "failed to send machine lifecycle event, will retry", "event", e.eventType)
+			return
+		}
+		if !delivered {
+			ctx.GetLogger().V(4).Info("waiting for the delivery of machine lifecycle event", "event", e.eventType)
+			return
+		}
+		setNotified(vsphereMachine, e.eventType)
+	}
+}
+
+// requeueVSphereMachine triggers a reconciliation of a VSphereMachine by
+// sending a GenericEvent into the event channel of the VSphereMachines.
+func (r machineReconciler) requeueVSphereMachine(vsphereMachine *infrav1.VSphereMachine) {
+	select {
+	case r.GetGenericEventChannelFor(infrav1.GroupVersion.WithKind("VSphereMachine")) <- event.GenericEvent{Object: vsphereMachine}:
+	case <-r.Done():
+	}
+}
+
+// notifyDeleted sends the MachineDeleted event of a VSphereMachine whose VM is
+// deleted, if its MachineCreated event was sent. Like the deletion of the
+// managed tags, it is best effort: it is sent in the background, after the
+// events of the machine still being delivered, so a sink which cannot be
+// reached does not block the deletion of the machine.
+func (r machineReconciler) notifyDeleted(ctx context.MachineContext) {
+	vimCtx, ok := ctx.(*context.VIMMachineContext)
+	if !ok || r.MachineNotifier == nil {
+		return
+	}
+	defer r.MachineNotifier.Forget(vimCtx.VSphereMachine.Namespace, vimCtx.VSphereMachine.Name)
+	if !wasNotified(vimCtx.VSphereMachine, notify.MachineCreated) {
+		return
+	}
+	r.MachineNotifier.SendAsync(r, notify.NewEvent(notify.MachineDeleted, vimCtx.Machine, vimCtx.VSphereMachine, nil))
+}
+
+func wasNotified(vsphereMachine *infrav1.VSphereMachine, eventType notify.EventType) bool {
+	for _, e := range strings.Split(vsphereMachine.Annotations[notifiedEventsAnnotation], ",") {
+		if e == string(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+func setNotified(vsphereMachine *infrav1.VSphereMachine, eventType notify.EventType) {
+	if vsphereMachine.Annotations == nil {
+		vsphereMachine.Annotations = map[string]string{}
+	}
+	notified := vsphereMachine.Annotations[notifiedEventsAnnotation]
+	if notified != "" {
+		notified += ","
+	}
+	vsphereMachine.Annotations[notifiedEventsAnnotation] = notified + string(eventType)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// nodeTopologyLabels returns the labels of a Node set from the topology of its
+// VSphereMachine. The values which are not valid label values, e.g. the names
+// of datastores with spaces, are skipped.
+func nodeTopologyLabels(ctx *context.VIMMachineContext) map[string]string {
+	labels := map[string]string{}
+	topology := ctx.VSphereMachine.Status.Topology
+	if topology == nil {
+		return labels
+	}
+	for key, value := range map[string]string{
+		corev1.LabelTopologyRegion: topology.Region,
+		corev1.LabelTopologyZone:   topology.Zone,
+		infrav1.NodeESXiHostLabel:  topology.Host,
+		infrav1.NodeDatastoreLabel: topology.Datastore,
+	} {
+		if value == "" {
+			continue
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			ctx.Logger.V(4).Info("Skipping a topology label with an invalid value", "label", key, "value", value, "errors", errs)
+			continue
+		}
+		labels[key] = value
+	}
+	return labels
+}
+
+// setNodeTopologyLabels sets the topology labels of a Node, and removes the
+// topology labels set before which are no longer part of the topology. The
+// keys of the labels set are recorded in an annotation of the Node, so that
+// the labels set by others, e.g. the zone labels of the vSphere CPI, are kept.
+func setNodeTopologyLabels(node *corev1.Node, labels map[string]string) {
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	for _, key := range strings.Split(node.Annotations[infrav1.NodeTopologyLabelsAnnotation], ",") {
+		if _, ok := labels[key]; !ok {
+			delete(node.Labels, key)
+		}
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key, value := range labels {
+		node.Labels[key] = value
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		delete(node.Annotations, infrav1.NodeTopologyLabelsAnnotation)
+		return
+	}
+	sort.Strings(keys)
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[infrav1.NodeTopologyLabelsAnnotation] = strings.Join(keys, ",")
+}
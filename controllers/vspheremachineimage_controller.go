@@ -0,0 +1,236 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheremachineimages,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheremachineimages/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
+
+// imagePublicKeyKey is the default key of the public key in the data of the
+// object referenced by the signature of a VSphereMachineImage.
+const imagePublicKeyKey = "cosign.pub"
+
+// AddVSphereMachineImageControllerToManager adds the controller that resolves
+// the template of each VSphereMachineImage to the provided manager.
+func AddVSphereMachineImageControllerToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controllerNameShort = "vspheremachineimage-controller"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+	r := machineImageReconciler{ControllerContext: controllerContext}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerNameShort).
+		For(&infrav1.VSphereMachineImage{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+type machineImageReconciler struct {
+	*context.ControllerContext
+}
+
+// Reconcile resolves the template of a VSphereMachineImage and records its
+// managed object reference in the status.
+func (r machineImageReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
+	logger := r.Logger.WithValues("vspheremachineimage", req.NamespacedName)
+
+	image := &infrav1.VSphereMachineImage{}
+	if err := r.Client.Get(ctx, req.NamespacedName, image); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if isPaused(nil, image) {
+		logger.V(4).Info("VSphereMachineImage is paused")
+		return reconcile.Result{}, nil
+	}
+
+	// The resolved template is left in vCenter, as other images or VMs may
+	// still be using it.
+	if !image.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(image, r.Client)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to init patch helper for VSphereMachineImage %s", req.NamespacedName)
+	}
+	defer func() {
+		conditions.SetSummary(image, conditions.WithConditions(infrav1.TemplateResolvedCondition))
+		v1beta2conditions.Mirror(image)
+		if err := patchHelper.Patch(ctx, image); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			logger.Error(err, "patch failed")
+		}
+	}()
+
+	params := session.NewParams().
+		WithServer(image.Spec.Server).
+		WithThumbprint(image.Spec.Thumbprint).
+		WithDatacenter(image.Spec.Datacenter).
+		WithUserInfo(r.Username, r.Password).
+		WithFeatures(session.Feature{
+			EnableKeepAlive:       r.EnableKeepAlive,
+			KeepAliveDuration:     r.KeepAliveDuration,
+			MaxConcurrentRequests: r.MaxConcurrentVCenterRequests,
+		})
+	caBundle, err := identity.GetCABundle(ctx, r.Client, image.Namespace, image.Spec.CABundleRef)
+	if err != nil {
+		conditions.MarkFalse(image, infrav1.TemplateResolvedCondition, infrav1.TemplateResolutionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return reconcile.Result{}, errors.Wrapf(err, "failed to retrieve the CA bundle of VSphereMachineImage %s", req.NamespacedName)
+	}
+	params = params.WithCABundle(caBundle)
+	authSession, err := session.GetOrCreate(ctx, params)
+	if err != nil {
+		conditions.MarkFalse(image, infrav1.TemplateResolvedCondition, infrav1.TemplateResolutionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return reconcile.Result{}, errors.Wrapf(err, "failed to create vCenter session for VSphereMachineImage %s", req.NamespacedName)
+	}
+
+	verifiers, err := r.imageVerifiers(ctx, image)
+	if err != nil {
+		image.Status.Ready = false
+		conditions.MarkFalse(image, infrav1.TemplateResolvedCondition, infrav1.ImageVerificationFailedReason, clusterv1.ConditionSeverityError, err.Error())
+		return reconcile.Result{}, errors.Wrapf(err, "failed to build the verifiers of VSphereMachineImage %s", req.NamespacedName)
+	}
+
+	ref, verifiedChecksum, err := govmomi.ResolveImageTemplate(ctx, authSession, image, verifiers)
+	if err != nil {
+		image.Status.Ready = false
+		reason, severity := infrav1.TemplateResolutionFailedReason, clusterv1.ConditionSeverityWarning
+		switch {
+		case errors.Is(err, govmomi.ErrImageNotFound):
+			reason = infrav1.ImageNotFoundReason
+		case errors.Is(err, govmomi.ErrImageVerificationFailed):
+			reason, severity = infrav1.ImageVerificationFailedReason, clusterv1.ConditionSeverityError
+			if conditions.GetReason(image, infrav1.TemplateResolvedCondition) != reason {
+				r.Recorder.Warnf(image, "ImageVerificationFailed", "Image %s failed verification: %v", govmomi.ImageItemName(image), err)
+			}
+		}
+		conditions.MarkFalse(image, infrav1.TemplateResolvedCondition, reason, severity, err.Error())
+		return reconcile.Result{}, errors.Wrapf(err, "failed to resolve the template of VSphereMachineImage %s", req.NamespacedName)
+	}
+
+	if image.Status.TemplateRef != ref.String() {
+		logger.Info("Resolved template", "template", govmomi.ImageItemName(image), "ref", ref.String())
+	}
+	image.Status.TemplateRef = ref.String()
+	image.Status.VerifiedChecksum = verifiedChecksum
+	image.Status.Ready = true
+	conditions.MarkTrue(image, infrav1.TemplateResolvedCondition)
+	return reconcile.Result{}, nil
+}
+
+// imageVerifiers returns the verifiers of the content of the image declared
+// in spec.verification.
+func (r machineImageReconciler) imageVerifiers(ctx goctx.Context, image *infrav1.VSphereMachineImage) ([]govmomi.ImageVerifier, error) {
+	verification := image.Spec.Verification
+	if verification == nil {
+		return nil, nil
+	}
+
+	var verifiers []govmomi.ImageVerifier
+	if verification.Checksum != nil {
+		verifiers = append(verifiers, govmomi.ChecksumVerifier{Expected: *verification.Checksum})
+	}
+	if verification.Signature != nil {
+		publicKey, err := getImagePublicKey(ctx, r.Client, image.Namespace, verification.Signature.PublicKeyRef)
+		if err != nil {
+			return nil, err
+		}
+		verifier, err := govmomi.NewSignatureVerifier(verification.Signature.Value, publicKey)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = append(verifiers, verifier)
+	}
+	if len(verifiers) == 0 {
+		return nil, errors.New("verification requires a checksum or a signature")
+	}
+	return verifiers, nil
+}
+
+// getImagePublicKey returns the PEM-encoded public key referenced by the
+// signature of an image.
+func getImagePublicKey(ctx goctx.Context, c client.Client, namespace string, ref infrav1.ImagePublicKeyReference) ([]byte, error) {
+	key := ref.Key
+	if key == "" {
+		key = imagePublicKeyKey
+	}
+	objKey := client.ObjectKey{Namespace: namespace, Name: ref.Name}
+
+	var publicKey []byte
+	switch ref.Kind {
+	case "Secret":
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, objKey, secret); err != nil {
+			return nil, errors.Wrapf(err, "failed to get Secret %s", objKey)
+		}
+		publicKey = secret.Data[key]
+	case "ConfigMap":
+		configMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, objKey, configMap); err != nil {
+			return nil, errors.Wrapf(err, "failed to get ConfigMap %s", objKey)
+		}
+		publicKey = []byte(configMap.Data[key])
+	default:
+		return nil, errors.Errorf("unsupported kind %q of public key reference", ref.Kind)
+	}
+	if len(publicKey) == 0 {
+		return nil, errors.Errorf("%s %s has no public key in %q", ref.Kind, objKey, key)
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/library"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+//nolint:forcetypeassert
+func TestMachineImageReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("failed to create VC simulator")
+	}
+	t.Cleanup(simr.Destroy)
+
+	image := func(name, kubernetesVersion string) *infrav1.VSphereMachineImage {
+		return &infrav1.VSphereMachineImage{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: name},
+			Spec: infrav1.VSphereMachineImageSpec{
+				Server:            simr.ServerURL().Host,
+				Datacenter:        "DC0",
+				ImageName:         "ubuntu-2204",
+				KubernetesVersion: kubernetesVersion,
+				ContentLibrary:    "images",
+			},
+		}
+	}
+	resolved, missing := image("resolved", "v1.28.3"), image("missing", "v1.29.0")
+	// The template of the unverified image was not deployed from a verified
+	// content library item.
+	unverified := image("unverified", "v1.28.3")
+	unverified.Spec.Verification = &infrav1.ImageVerification{
+		Checksum: &infrav1.ImageChecksum{Algorithm: "SHA256", Value: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+	}
+
+	mgmtContext := fake.NewControllerManagerContext(resolved, missing, unverified)
+	mgmtContext.Username = simr.Username()
+	mgmtContext.Password = simr.Password()
+	r := machineImageReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+	authSession, err := session.GetOrCreate(mgmtContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithDatacenter("DC0").
+		WithUserInfo(simr.Username(), simr.Password()))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The template of the resolved image exists in the datacenter.
+	simVM := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	task, err := object.NewVirtualMachine(authSession.Client.Client, simVM.Reference()).Rename(mgmtContext, "ubuntu-2204-kube-v1.28.3")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task.Wait(mgmtContext)).To(Succeed())
+
+	// The content library has no item for the missing image.
+	datastore := simulator.Map.Any("Datastore")
+	_, err = library.NewManager(authSession.TagManager.Client).CreateLibrary(mgmtContext, library.Library{
+		Name:    "images",
+		Type:    "LOCAL",
+		Storage: []library.StorageBackings{{DatastoreID: datastore.Reference().Value, Type: "DATASTORE"}},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(resolved)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(resolved), resolved)).To(Succeed())
+	g.Expect(resolved.Status.Ready).To(BeTrue())
+	g.Expect(resolved.Status.TemplateRef).To(Equal("VirtualMachine:" + simVM.Reference().Value))
+	g.Expect(conditions.IsTrue(resolved, infrav1.TemplateResolvedCondition)).To(BeTrue())
+
+	_, err = r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(missing)})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(missing), missing)).To(Succeed())
+	g.Expect(missing.Status.Ready).To(BeFalse())
+	g.Expect(missing.Status.TemplateRef).To(BeEmpty())
+	g.Expect(conditions.GetReason(missing, infrav1.TemplateResolvedCondition)).To(Equal(infrav1.ImageNotFoundReason))
+
+	_, err = r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(unverified)})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(unverified), unverified)).To(Succeed())
+	g.Expect(unverified.Status.Ready).To(BeFalse())
+	g.Expect(unverified.Status.TemplateRef).To(BeEmpty())
+	g.Expect(conditions.GetReason(unverified, infrav1.TemplateResolvedCondition)).To(Equal(infrav1.ImageVerificationFailedReason))
+}
@@ -0,0 +1,438 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	exputil "sigs.k8s.io/cluster-api/exp/util"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheremachinepools,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheremachinepools/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinepools;machinepools/status,verbs=get;list;watch
+
+// AddVSphereMachinePoolControllerToManager adds the controller that clones
+// the VMs of each VSphereMachinePool to the provided manager.
+func AddVSphereMachinePoolControllerToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controlledType     = &infrav1.VSphereMachinePool{}
+		controlledTypeName = "VSphereMachinePool"
+		controlledTypeGVK  = infrav1.GroupVersion.WithKind(controlledTypeName)
+
+		controllerNameShort = "vspheremachinepool-controller"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+	r := machinePoolReconciler{ControllerContext: controllerContext}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerNameShort).
+		For(controlledType).
+		Owns(&infrav1.VSphereVM{}).
+		// Watch the MachinePools to scale the pools as soon as their replicas change.
+		Watches(
+			&source.Kind{Type: &expv1.MachinePool{}},
+			handler.EnqueueRequestsFromMapFunc(exputil.MachinePoolToInfrastructureMapFunc(controlledTypeGVK, controllerContext.Logger)),
+		).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+type machinePoolReconciler struct {
+	*context.ControllerContext
+}
+
+// Reconcile clones or deletes the VSphereVMs of a VSphereMachinePool to keep
+// the number of replicas of its MachinePool, replaces the VSphereVMs created
+// from an earlier template or bootstrap data, and reports the provider IDs of
+// the ready VMs to the MachinePool.
+func (r machinePoolReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
+	logger := r.Logger.WithValues("vspheremachinepool", req.NamespacedName)
+
+	pool := &infrav1.VSphereMachinePool{}
+	if err := r.Client.Get(ctx, req.NamespacedName, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if !pool.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, pool)
+	}
+
+	machinePool, err := exputil.GetOwnerMachinePool(ctx, r.Client, pool.ObjectMeta)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if machinePool == nil {
+		logger.Info("Waiting for MachinePool Controller to set OwnerRef on VSphereMachinePool")
+		return reconcile.Result{}, nil
+	}
+	cluster, err := clusterutilv1.GetClusterFromMetadata(ctx, r.Client, machinePool.ObjectMeta)
+	if err != nil {
+		logger.Info("MachinePool is missing cluster label or cluster does not exist")
+		return reconcile.Result{}, nil
+	}
+	if annotations.IsPaused(cluster, pool) {
+		logger.V(4).Info("VSphereMachinePool linked to a cluster that is paused")
+		return reconcile.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(pool, r.Client)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to init patch helper for VSphereMachinePool %s", req.NamespacedName)
+	}
+	defer func() {
+		conditions.SetSummary(pool, conditions.WithConditions(infrav1.MachinePoolReadyCondition))
+		v1beta2conditions.Mirror(pool)
+		if err := patchHelper.Patch(ctx, pool); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			logger.Error(err, "patch failed")
+		}
+	}()
+
+	// If the VSphereMachinePool doesn't have our finalizer, add it.
+	ctrlutil.AddFinalizer(pool, infrav1.MachinePoolFinalizer)
+
+	if !cluster.Status.InfrastructureReady {
+		logger.Info("Cluster infrastructure is not ready yet")
+		conditions.MarkFalse(pool, infrav1.MachinePoolReadyCondition, infrav1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+		return reconcile.Result{}, nil
+	}
+	dataSecretName := machinePool.Spec.Template.Spec.Bootstrap.DataSecretName
+	if dataSecretName == nil {
+		logger.Info("Waiting for bootstrap data to be available")
+		conditions.MarkFalse(pool, infrav1.MachinePoolReadyCondition, infrav1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "")
+		return reconcile.Result{}, nil
+	}
+
+	vsphereCluster := &infrav1.VSphereCluster{}
+	vsphereClusterKey := ctrlclient.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+	if err := r.Client.Get(ctx, vsphereClusterKey, vsphereCluster); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get VSphereCluster %s", vsphereClusterKey)
+	}
+
+	return reconcile.Result{}, r.reconcileVMs(ctx, pool, machinePool, vsphereCluster, *dataSecretName)
+}
+
+// reconcileDelete deletes the VSphereVMs of a deleted pool, and removes its
+// finalizer once they are gone, so that the MachinePool is only deleted
+// once the VMs of the pool are destroyed. The VSphereVMs are not deleted
+// while the pool or its cluster is paused, e.g. while they are moved.
+func (r machinePoolReconciler) reconcileDelete(ctx goctx.Context, pool *infrav1.VSphereMachinePool) (reconcile.Result, error) {
+	logger := r.Logger.WithValues("vspheremachinepool", ctrlclient.ObjectKeyFromObject(pool))
+	if !ctrlutil.ContainsFinalizer(pool, infrav1.MachinePoolFinalizer) {
+		return reconcile.Result{}, nil
+	}
+	paused := annotations.HasPaused(pool)
+	if cluster, err := clusterutilv1.GetClusterFromMetadata(ctx, r.Client, pool.ObjectMeta); err == nil {
+		paused = paused || annotations.IsPaused(cluster, pool)
+	}
+	if paused {
+		logger.V(4).Info("VSphereMachinePool linked to a cluster that is paused")
+		return reconcile.Result{}, nil
+	}
+
+	vms := &infrav1.VSphereVMList{}
+	if err := r.Client.List(ctx, vms, ctrlclient.InNamespace(pool.Namespace), ctrlclient.MatchingLabels{infrav1.MachinePoolLabel: pool.Name}); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to list the VSphereVMs of VSphereMachinePool %s/%s", pool.Namespace, pool.Name)
+	}
+	for i := range vms.Items {
+		vm := &vms.Items[i]
+		if !vm.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.deleteVM(ctx, vm); err != nil {
+			return reconcile.Result{}, err
+		}
+		logger.Info("Deleted VSphereVM of deleted VSphereMachinePool", "vm", vm.Name)
+	}
+
+	patchHelper, err := patch.NewHelper(pool, r.Client)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to init patch helper for VSphereMachinePool %s/%s", pool.Namespace, pool.Name)
+	}
+	if len(vms.Items) > 0 {
+		// The deletions of the VSphereVMs of the pool requeue it.
+		logger.Info("Waiting for the VSphereVMs of the VSphereMachinePool to be deleted", "count", len(vms.Items))
+		conditions.MarkFalse(pool, infrav1.MachinePoolReadyCondition, clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo,
+			"%d VMs to delete", len(vms.Items))
+		conditions.SetSummary(pool, conditions.WithConditions(infrav1.MachinePoolReadyCondition))
+	} else {
+		ctrlutil.RemoveFinalizer(pool, infrav1.MachinePoolFinalizer)
+	}
+	return reconcile.Result{}, patchHelper.Patch(ctx, pool)
+}
+
+func (r machinePoolReconciler) reconcileVMs(ctx goctx.Context, pool *infrav1.VSphereMachinePool, machinePool *expv1.MachinePool,
+	vsphereCluster *infrav1.VSphereCluster, dataSecretName string) error {
+	logger := r.Logger.WithValues("vspheremachinepool", ctrlclient.ObjectKeyFromObject(pool))
+
+	hash, err := machinePoolTemplateHash(pool, dataSecretName)
+	if err != nil {
+		return err
+	}
+
+	vms := &infrav1.VSphereVMList{}
+	if err := r.Client.List(ctx, vms, ctrlclient.InNamespace(pool.Namespace), ctrlclient.MatchingLabels{infrav1.MachinePoolLabel: pool.Name}); err != nil {
+		return errors.Wrapf(err, "failed to list the VSphereVMs of VSphereMachinePool %s/%s", pool.Namespace, pool.Name)
+	}
+	var current, outdated []*infrav1.VSphereVM
+	for i := range vms.Items {
+		vm := &vms.Items[i]
+		switch {
+		case !vm.DeletionTimestamp.IsZero():
+		case vm.Labels[infrav1.MachinePoolTemplateHashLabel] == hash:
+			current = append(current, vm)
+		default:
+			outdated = append(outdated, vm)
+		}
+	}
+
+	replicas := int32(1)
+	if machinePool.Spec.Replicas != nil {
+		replicas = *machinePool.Spec.Replicas
+	}
+	maxSurge, maxUnavailable := machinePoolRollingUpdateLimits(pool.Spec.RollingUpdate)
+
+	// Scale down, deleting the VMs that are not ready first.
+	sortNotReadyFirst(current)
+	for int32(len(current)) > replicas {
+		if err := r.deleteVM(ctx, current[0]); err != nil {
+			return err
+		}
+		logger.Info("Deleted VSphereVM", "vm", current[0].Name)
+		current = current[1:]
+	}
+
+	// Scale up, with at most maxSurge VMs above the replicas while the
+	// outdated VMs are replaced.
+	for int32(len(current)) < replicas && int32(len(current)+len(outdated)) < replicas+maxSurge {
+		vm := newMachinePoolVM(pool, machinePool, hash, dataSecretName, machinePoolFailureDomain(machinePool, current))
+		services.ApplyMachinePoolVMPlacement(ctx, r.Client, logger, vm, vsphereCluster,
+			optionalAnnotation(vm, infrav1.MachinePoolFailureDomainAnnotation))
+		if err := r.Client.Create(ctx, vm); err != nil {
+			return errors.Wrapf(err, "failed to create VSphereVM for VSphereMachinePool %s/%s", pool.Namespace, pool.Name)
+		}
+		logger.Info("Created VSphereVM", "vm", vm.Name)
+		current = append(current, vm)
+	}
+
+	// Replace the outdated VMs, keeping at least replicas-maxUnavailable ready
+	// VMs. The outdated VMs that are not ready are deleted first.
+	readyReplicas := countReadyVMs(current) + countReadyVMs(outdated)
+	sortNotReadyFirst(outdated)
+	for len(outdated) > 0 {
+		vm := outdated[0]
+		if vm.Status.Ready {
+			if readyReplicas-1 < replicas-maxUnavailable {
+				break
+			}
+			readyReplicas--
+		}
+		if err := r.deleteVM(ctx, vm); err != nil {
+			return err
+		}
+		logger.Info("Deleted VSphereVM with an outdated template", "vm", vm.Name)
+		outdated = outdated[1:]
+	}
+
+	var providerIDList []string
+	for _, vm := range append(current, outdated...) {
+		if !vm.Status.Ready {
+			continue
+		}
+		if providerID := util.ConvertUUIDToProviderID(vm.Spec.BiosUUID); providerID != "" {
+			providerIDList = append(providerIDList, providerID)
+		}
+	}
+	sort.Strings(providerIDList)
+	pool.Spec.ProviderIDList = providerIDList
+	pool.Status.Ready = true
+	pool.Status.Replicas = int32(len(current) + len(outdated))
+	pool.Status.ReadyReplicas = readyReplicas
+	pool.Status.UpdatedReplicas = int32(len(current))
+	switch {
+	case len(outdated) > 0:
+		conditions.MarkFalse(pool, infrav1.MachinePoolReadyCondition, infrav1.MachinePoolRollingUpdateReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d VMs updated", pool.Status.UpdatedReplicas, replicas)
+	case pool.Status.Replicas != replicas:
+		conditions.MarkFalse(pool, infrav1.MachinePoolReadyCondition, infrav1.MachinePoolScalingReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d VMs", pool.Status.Replicas, replicas)
+	case pool.Status.ReadyReplicas < replicas:
+		conditions.MarkFalse(pool, infrav1.MachinePoolReadyCondition, infrav1.WaitingForMachinePoolVMsReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d VMs ready", pool.Status.ReadyReplicas, replicas)
+	default:
+		conditions.MarkTrue(pool, infrav1.MachinePoolReadyCondition)
+	}
+	return nil
+}
+
+// deleteVM deletes a VSphereVM of the pool, which destroys its VM.
+func (r machinePoolReconciler) deleteVM(ctx goctx.Context, vm *infrav1.VSphereVM) error {
+	if err := r.Client.Delete(ctx, vm); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete VSphereVM %s/%s", vm.Namespace, vm.Name)
+	}
+	return nil
+}
+
+// newMachinePoolVM returns a VSphereVM of the pool, cloned from its template
+// with the bootstrap data of the MachinePool.
+func newMachinePoolVM(pool *infrav1.VSphereMachinePool, machinePool *expv1.MachinePool, hash, dataSecretName string, failureDomain *string) *infrav1.VSphereVM {
+	vm := &infrav1.VSphereVM{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:    pool.Namespace,
+			GenerateName: pool.Name + "-",
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName:           machinePool.Spec.ClusterName,
+				infrav1.MachinePoolLabel:             pool.Name,
+				infrav1.MachinePoolTemplateHashLabel: hash,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(pool, infrav1.GroupVersion.WithKind("VSphereMachinePool")),
+			},
+		},
+	}
+	if failureDomain != nil {
+		vm.Annotations = map[string]string{
+			infrav1.MachinePoolFailureDomainAnnotation: *failureDomain,
+		}
+	}
+	pool.Spec.Template.DeepCopyInto(&vm.Spec.VirtualMachineCloneSpec)
+	vm.Spec.BootstrapRef = &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Name:       dataSecretName,
+		Namespace:  machinePool.Namespace,
+	}
+	return vm
+}
+
+// machinePoolTemplateHash returns the hash of the template and of the
+// bootstrap data of the pool, which changes when the VMs must be replaced.
+func machinePoolTemplateHash(pool *infrav1.VSphereMachinePool, dataSecretName string) (string, error) {
+	data, err := json.Marshal(pool.Spec.Template)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to marshal the template of VSphereMachinePool %s/%s", pool.Namespace, pool.Name)
+	}
+	hasher := fnv.New32a()
+	_, _ = hasher.Write(data)
+	_, _ = hasher.Write([]byte(dataSecretName))
+	return fmt.Sprintf("%x", hasher.Sum32()), nil
+}
+
+// machinePoolRollingUpdateLimits returns the maxSurge and maxUnavailable of
+// the pool, with their defaults. At least one VM is surged when no VM can be
+// unavailable, so that the replacement makes progress.
+func machinePoolRollingUpdateLimits(rollingUpdate infrav1.VSphereMachinePoolRollingUpdate) (int32, int32) {
+	maxSurge, maxUnavailable := int32(1), int32(0)
+	if rollingUpdate.MaxSurge != nil {
+		maxSurge = *rollingUpdate.MaxSurge
+	}
+	if rollingUpdate.MaxUnavailable != nil {
+		maxUnavailable = *rollingUpdate.MaxUnavailable
+	}
+	if maxSurge == 0 && maxUnavailable == 0 {
+		maxSurge = 1
+	}
+	return maxSurge, maxUnavailable
+}
+
+// machinePoolFailureDomain returns the failure domain of the MachinePool with
+// the fewest VMs, in the order of the failure domains of the MachinePool.
+func machinePoolFailureDomain(machinePool *expv1.MachinePool, vms []*infrav1.VSphereVM) *string {
+	if len(machinePool.Spec.FailureDomains) == 0 {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, vm := range vms {
+		counts[vm.Annotations[infrav1.MachinePoolFailureDomainAnnotation]]++
+	}
+	failureDomain := machinePool.Spec.FailureDomains[0]
+	for _, fd := range machinePool.Spec.FailureDomains[1:] {
+		if counts[fd] < counts[failureDomain] {
+			failureDomain = fd
+		}
+	}
+	return &failureDomain
+}
+
+func optionalAnnotation(obj metav1.Object, key string) *string {
+	if value, ok := obj.GetAnnotations()[key]; ok {
+		return &value
+	}
+	return nil
+}
+
+func sortNotReadyFirst(vms []*infrav1.VSphereVM) {
+	sort.SliceStable(vms, func(i, j int) bool {
+		return !vms[i].Status.Ready && vms[j].Status.Ready
+	})
+}
+
+func countReadyVMs(vms []*infrav1.VSphereVM) int32 {
+	var ready int32
+	for _, vm := range vms {
+		if vm.Status.Ready {
+			ready++
+		}
+	}
+	return ready
+}
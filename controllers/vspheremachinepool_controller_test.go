@@ -0,0 +1,280 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestMachinePoolReconciler_Reconcile(t *testing.T) {
+	template := infrav1.VirtualMachineCloneSpec{Template: "ubuntu", Server: "vcenter", Datacenter: "dc0"}
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "cluster"},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{Kind: "VSphereCluster", Name: "cluster"},
+		},
+		Status: clusterv1.ClusterStatus{InfrastructureReady: true},
+	}
+	vsphereCluster := &infrav1.VSphereCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "cluster"},
+		Spec:       infrav1.VSphereClusterSpec{Server: "vcenter"},
+	}
+	machinePool := func(replicas int32, dataSecretName *string) *expv1.MachinePool {
+		return &expv1.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      "pool",
+				UID:       "machinepool-uid",
+				Labels:    map[string]string{clusterv1.ClusterLabelName: "cluster"},
+			},
+			Spec: expv1.MachinePoolSpec{
+				ClusterName: "cluster",
+				Replicas:    pointer.Int32(replicas),
+				Template: clusterv1.MachineTemplateSpec{
+					Spec: clusterv1.MachineSpec{
+						ClusterName: "cluster",
+						Bootstrap:   clusterv1.Bootstrap{DataSecretName: dataSecretName},
+					},
+				},
+			},
+		}
+	}
+	pool := func() *infrav1.VSphereMachinePool {
+		return &infrav1.VSphereMachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      "pool",
+				UID:       "pool-uid",
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: expv1.GroupVersion.String(), Kind: "MachinePool", Name: "pool", UID: "machinepool-uid"},
+				},
+			},
+			Spec: infrav1.VSphereMachinePoolSpec{Template: template},
+		}
+	}
+	poolVM := func(name, biosUUID, hash string, ready bool) *infrav1.VSphereVM {
+		vm := &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      name,
+				Labels:    map[string]string{infrav1.MachinePoolLabel: "pool", infrav1.MachinePoolTemplateHashLabel: hash},
+			},
+			Spec:   infrav1.VSphereVMSpec{BiosUUID: biosUUID},
+			Status: infrav1.VSphereVMStatus{Ready: ready},
+		}
+		template.DeepCopyInto(&vm.Spec.VirtualMachineCloneSpec)
+		return vm
+	}
+	listPoolVMs := func(g *WithT, c client.Client) []infrav1.VSphereVM {
+		vms := &infrav1.VSphereVMList{}
+		g.Expect(c.List(goctx.Background(), vms, client.MatchingLabels{infrav1.MachinePoolLabel: "pool"})).To(Succeed())
+		return vms.Items
+	}
+	currentHash := func(g *WithT) string {
+		hash, err := machinePoolTemplateHash(pool(), "bootstrap")
+		g.Expect(err).NotTo(HaveOccurred())
+		return hash
+	}
+
+	t.Run("waits for the bootstrap data", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool()
+		mgmtContext := fake.NewControllerManagerContext(p, machinePool(2, nil), cluster.DeepCopy(), vsphereCluster.DeepCopy())
+		r := machinePoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(listPoolVMs(g, mgmtContext.Client)).To(BeEmpty())
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(p), p)).To(Succeed())
+		g.Expect(conditions.GetReason(p, infrav1.MachinePoolReadyCondition)).To(Equal(infrav1.WaitingForBootstrapDataReason))
+	})
+
+	t.Run("clones the VMs of the pool across the failure domains", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool()
+		mp := machinePool(2, pointer.String("bootstrap"))
+		mp.Spec.FailureDomains = []string{"fd-a", "fd-b"}
+		mgmtContext := fake.NewControllerManagerContext(p, mp, cluster.DeepCopy(), vsphereCluster.DeepCopy())
+		r := machinePoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		vms := listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(2))
+		failureDomains := []string{}
+		for _, vm := range vms {
+			g.Expect(vm.Name).To(HavePrefix("pool-"))
+			g.Expect(vm.Labels).To(HaveKeyWithValue(clusterv1.ClusterLabelName, "cluster"))
+			g.Expect(vm.Labels).To(HaveKeyWithValue(infrav1.MachinePoolTemplateHashLabel, currentHash(g)))
+			g.Expect(vm.Spec.BootstrapRef).NotTo(BeNil())
+			g.Expect(vm.Spec.BootstrapRef.Name).To(Equal("bootstrap"))
+			g.Expect(metav1.IsControlledBy(&vm, p)).To(BeTrue())
+			failureDomains = append(failureDomains, vm.Annotations[infrav1.MachinePoolFailureDomainAnnotation])
+		}
+		g.Expect(failureDomains).To(ConsistOf("fd-a", "fd-b"))
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(p), p)).To(Succeed())
+		g.Expect(p.Finalizers).To(ContainElement(infrav1.MachinePoolFinalizer))
+		g.Expect(p.Status.Replicas).To(Equal(int32(2)))
+		g.Expect(p.Spec.ProviderIDList).To(BeEmpty())
+		g.Expect(conditions.GetReason(p, infrav1.MachinePoolReadyCondition)).To(Equal(infrav1.WaitingForMachinePoolVMsReason))
+	})
+
+	t.Run("reports the provider IDs of the ready VMs", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool()
+		hash := currentHash(g)
+		mgmtContext := fake.NewControllerManagerContext(p, machinePool(2, pointer.String("bootstrap")), cluster.DeepCopy(), vsphereCluster.DeepCopy(),
+			poolVM("vm-1", "42300000-0000-0000-0000-000000000001", hash, true),
+			poolVM("vm-0", "42300000-0000-0000-0000-000000000000", hash, true))
+		r := machinePoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(p), p)).To(Succeed())
+		g.Expect(p.Spec.ProviderIDList).To(Equal([]string{
+			"vsphere://42300000-0000-0000-0000-000000000000",
+			"vsphere://42300000-0000-0000-0000-000000000001",
+		}))
+		g.Expect(p.Status.Ready).To(BeTrue())
+		g.Expect(p.Status.ReadyReplicas).To(Equal(int32(2)))
+		g.Expect(conditions.IsTrue(p, infrav1.MachinePoolReadyCondition)).To(BeTrue())
+	})
+
+	t.Run("deletes the VMs that are not ready first when scaling down", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool()
+		hash := currentHash(g)
+		mgmtContext := fake.NewControllerManagerContext(p, machinePool(1, pointer.String("bootstrap")), cluster.DeepCopy(), vsphereCluster.DeepCopy(),
+			poolVM("ready", "", hash, true), poolVM("pending", "", hash, false))
+		r := machinePoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		vms := listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(1))
+		g.Expect(vms[0].Name).To(Equal("ready"))
+	})
+
+	t.Run("surges a VM before deleting a VM with an outdated template", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool()
+		mgmtContext := fake.NewControllerManagerContext(p, machinePool(1, pointer.String("bootstrap")), cluster.DeepCopy(), vsphereCluster.DeepCopy(),
+			poolVM("outdated", "42300000-0000-0000-0000-000000000000", "old", true))
+		r := machinePoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		// The outdated VM is kept until the new VM is ready.
+		vms := listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(2))
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(p), p)).To(Succeed())
+		g.Expect(p.Status.UpdatedReplicas).To(Equal(int32(1)))
+		g.Expect(p.Spec.ProviderIDList).To(Equal([]string{"vsphere://42300000-0000-0000-0000-000000000000"}))
+		g.Expect(conditions.GetReason(p, infrav1.MachinePoolReadyCondition)).To(Equal(infrav1.MachinePoolRollingUpdateReason))
+
+		for i := range vms {
+			if vms[i].Name != "outdated" {
+				vms[i].Status.Ready = true
+				g.Expect(mgmtContext.Client.Update(mgmtContext, &vms[i])).To(Succeed())
+			}
+		}
+		_, err = r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		vms = listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(1))
+		g.Expect(vms[0].Name).NotTo(Equal("outdated"))
+	})
+
+	t.Run("deletes the VMs of a deleted pool before removing its finalizer", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool()
+		p.Finalizers = []string{infrav1.MachinePoolFinalizer}
+		vm := poolVM("vm-0", "", currentHash(g), true)
+		vm.Finalizers = []string{infrav1.VMFinalizer}
+		mgmtContext := fake.NewControllerManagerContext(p, machinePool(1, pointer.String("bootstrap")), cluster.DeepCopy(), vsphereCluster.DeepCopy(), vm)
+		r := machinePoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+		g.Expect(mgmtContext.Client.Delete(mgmtContext, p)).To(Succeed())
+
+		// The pool waits for its VSphereVMs, which wait for their VMs.
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+		vms := listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(1))
+		g.Expect(vms[0].DeletionTimestamp.IsZero()).To(BeFalse())
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(p), p)).To(Succeed())
+		g.Expect(p.Finalizers).To(ContainElement(infrav1.MachinePoolFinalizer))
+		g.Expect(conditions.GetReason(p, infrav1.MachinePoolReadyCondition)).To(Equal(clusterv1.DeletingReason))
+
+		vms[0].Finalizers = nil
+		g.Expect(mgmtContext.Client.Update(mgmtContext, &vms[0])).To(Succeed())
+		_, err = r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(apierrors.IsNotFound(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(p), p))).To(BeTrue())
+	})
+
+	t.Run("does not delete the VMs of a deleted pool while it is paused", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool()
+		p.Finalizers = []string{infrav1.MachinePoolFinalizer}
+		p.Annotations = map[string]string{clusterv1.PausedAnnotation: ""}
+		mgmtContext := fake.NewControllerManagerContext(p, machinePool(1, pointer.String("bootstrap")), cluster.DeepCopy(), vsphereCluster.DeepCopy(),
+			poolVM("vm-0", "", currentHash(g), true))
+		r := machinePoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+		g.Expect(mgmtContext.Client.Delete(mgmtContext, p)).To(Succeed())
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(listPoolVMs(g, mgmtContext.Client)).To(HaveLen(1))
+	})
+}
+
+func TestMachinePoolRollingUpdateLimits(t *testing.T) {
+	g := NewWithT(t)
+
+	maxSurge, maxUnavailable := machinePoolRollingUpdateLimits(infrav1.VSphereMachinePoolRollingUpdate{})
+	g.Expect(maxSurge).To(Equal(int32(1)))
+	g.Expect(maxUnavailable).To(BeZero())
+
+	maxSurge, maxUnavailable = machinePoolRollingUpdateLimits(infrav1.VSphereMachinePoolRollingUpdate{MaxSurge: pointer.Int32(0), MaxUnavailable: pointer.Int32(0)})
+	g.Expect(maxSurge).To(Equal(int32(1)))
+	g.Expect(maxUnavailable).To(BeZero())
+
+	maxSurge, maxUnavailable = machinePoolRollingUpdateLimits(infrav1.VSphereMachinePoolRollingUpdate{MaxSurge: pointer.Int32(0), MaxUnavailable: pointer.Int32(2)})
+	g.Expect(maxSurge).To(BeZero())
+	g.Expect(maxUnavailable).To(Equal(int32(2)))
+}
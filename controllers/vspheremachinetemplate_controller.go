@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+const (
+	// nvidiaGPUResourceName is the extended resource of the NVIDIA GPUs,
+	// advertised by the device plugin of NVIDIA.
+	nvidiaGPUResourceName corev1.ResourceName = "nvidia.com/gpu"
+
+	// nvidiaVendorID is the PCI vendor ID of NVIDIA.
+	nvidiaVendorID = 0x10de
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheremachinetemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheremachinetemplates/status,verbs=get;update;patch
+
+// AddVSphereMachineTemplateControllerToManager adds the controller that
+// reports the capacity of the VSphereMachineTemplates to the provided
+// manager.
+func AddVSphereMachineTemplateControllerToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controllerNameShort = "vspheremachinetemplate-controller"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+	r := machineTemplateReconciler{ControllerContext: controllerContext}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerNameShort).
+		For(&infrav1.VSphereMachineTemplate{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+type machineTemplateReconciler struct {
+	*context.ControllerContext
+}
+
+// Reconcile sets the capacity of a VSphereMachineTemplate, which the cluster
+// autoscaler reads to scale a node group backed by the template from zero
+// replicas.
+func (r machineTemplateReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
+	template := &infrav1.VSphereMachineTemplate{}
+	if err := r.Client.Get(ctx, req.NamespacedName, template); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !template.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	paused, err := isPausedByOwnerCluster(ctx, r.Client, template)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if paused {
+		return reconcile.Result{}, nil
+	}
+
+	capacity := machineTemplateCapacity(template.Spec.Template.Spec.VirtualMachineCloneSpec)
+	if apiequality.Semantic.DeepEqual(template.Status.Capacity, capacity) {
+		return reconcile.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(template, r.Client)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to init patch helper for VSphereMachineTemplate %s", req.NamespacedName)
+	}
+	template.Status.Capacity = capacity
+	if err := patchHelper.Patch(ctx, template); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to patch the capacity of VSphereMachineTemplate %s", req.NamespacedName)
+	}
+	r.Logger.V(4).Info("Updated the capacity of VSphereMachineTemplate", "vspheremachinetemplate", req.NamespacedName, "capacity", capacity)
+	return reconcile.Result{}, nil
+}
+
+// machineTemplateCapacity returns the resources of the VMs cloned from a
+// clone spec. The cpu and the memory are omitted when the spec does not set
+// them, since they are then those of the VM template in vCenter. The NVIDIA
+// GPUs are the vGPU profiles and the PCI passthrough devices of NVIDIA.
+func machineTemplateCapacity(spec infrav1.VirtualMachineCloneSpec) corev1.ResourceList {
+	capacity := corev1.ResourceList{}
+	if spec.NumCPUs > 0 {
+		capacity[corev1.ResourceCPU] = *resource.NewQuantity(int64(spec.NumCPUs), resource.DecimalSI)
+	}
+	if spec.MemoryMiB > 0 {
+		capacity[corev1.ResourceMemory] = *resource.NewQuantity(spec.MemoryMiB*1024*1024, resource.BinarySI)
+	}
+	var gpus int64
+	for _, device := range spec.PCIDevices {
+		if device.VGPUProfile != "" || (device.VendorID != nil && *device.VendorID == nvidiaVendorID) {
+			gpus++
+		}
+	}
+	if gpus > 0 {
+		capacity[nvidiaGPUResourceName] = *resource.NewQuantity(gpus, resource.DecimalSI)
+	}
+	if len(capacity) == 0 {
+		return nil
+	}
+	return capacity
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestMachineTemplateReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+	template := &infrav1.VSphereMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "workers"},
+		Spec: infrav1.VSphereMachineTemplateSpec{
+			Template: infrav1.VSphereMachineTemplateResource{
+				Spec: infrav1.VSphereMachineSpec{
+					VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Template: "ubuntu", NumCPUs: 4, MemoryMiB: 8192},
+				},
+			},
+		},
+	}
+	mgmtContext := fake.NewControllerManagerContext(template)
+	r := machineTemplateReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+	_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(template)})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(template), template)).To(Succeed())
+	g.Expect(template.Status.Capacity).To(HaveLen(2))
+	g.Expect(template.Status.Capacity.Cpu().Equal(resource.MustParse("4"))).To(BeTrue())
+	g.Expect(template.Status.Capacity.Memory().Equal(resource.MustParse("8Gi"))).To(BeTrue())
+}
+
+func TestMachineTemplateCapacity(t *testing.T) {
+	t.Run("omits the resources of the VM template", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(machineTemplateCapacity(infrav1.VirtualMachineCloneSpec{Template: "ubuntu"})).To(BeNil())
+	})
+
+	t.Run("counts the NVIDIA GPUs", func(t *testing.T) {
+		g := NewWithT(t)
+		capacity := machineTemplateCapacity(infrav1.VirtualMachineCloneSpec{
+			NumCPUs: 8,
+			PCIDevices: []infrav1.PCIDeviceSpec{
+				{VGPUProfile: "grid_t4-4c"},
+				{VendorID: pointer.Int32(nvidiaVendorID), DeviceID: pointer.Int32(7864)},
+				{VendorID: pointer.Int32(0x8086), DeviceID: pointer.Int32(5510)},
+			},
+		})
+		g.Expect(capacity).To(HaveKey(corev1.ResourceCPU))
+		gpus := capacity[nvidiaGPUResourceName]
+		g.Expect(gpus.Value()).To(Equal(int64(2)))
+	})
+}
@@ -0,0 +1,413 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+const (
+	// templateRolloutRequeueInterval is the interval at which a
+	// VSphereMachineTemplateRollout is requeued while its canary Machine is
+	// provisioned and its probe Jobs run.
+	templateRolloutRequeueInterval = 15 * time.Second
+
+	// defaultTemplateRolloutTimeout is the timeout of a
+	// VSphereMachineTemplateRollout which does not set one.
+	defaultTemplateRolloutTimeout = 30 * time.Minute
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheremachinetemplaterollouts,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheremachinetemplaterollouts/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheremachinetemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinedeployments,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=bootstrap.cluster.x-k8s.io,resources=kubeadmconfigtemplates,verbs=get;list;watch
+// +kubebuilder:rbac:groups=bootstrap.cluster.x-k8s.io,resources=kubeadmconfigs,verbs=create
+
+// AddVSphereMachineTemplateRolloutControllerToManager adds the controller that
+// rolls out a VSphereMachineTemplate to a MachineDeployment through a canary
+// Machine to the provided manager.
+func AddVSphereMachineTemplateRolloutControllerToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controllerNameShort = "vspheremachinetemplaterollout-controller"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+	r := templateRolloutReconciler{
+		ControllerContext:  controllerContext,
+		remoteClientGetter: remote.NewClusterClient,
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerNameShort).
+		For(&infrav1.VSphereMachineTemplateRollout{}).
+		Owns(&clusterv1.Machine{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+type templateRolloutReconciler struct {
+	*context.ControllerContext
+
+	// remoteClientGetter returns the client of the workload cluster in which
+	// the probe Jobs run.
+	remoteClientGetter remote.ClusterClientGetter
+}
+
+// Reconcile creates the canary Machine of a VSphereMachineTemplateRollout,
+// runs the probe Jobs on its node once it is healthy, and then sets the
+// infrastructure reference of the MachineDeployment to the template and
+// deletes the canary Machine. A failed rollout keeps its canary Machine for
+// troubleshooting and leaves the MachineDeployment unchanged.
+func (r templateRolloutReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
+	logger := r.Logger.WithValues("vspheremachinetemplaterollout", req.NamespacedName)
+
+	rollout := &infrav1.VSphereMachineTemplateRollout{}
+	if err := r.Client.Get(ctx, req.NamespacedName, rollout); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	paused, err := isPausedByClusterName(ctx, r.Client, rollout.Spec.ClusterName, rollout)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if paused {
+		logger.V(4).Info("VSphereMachineTemplateRollout is paused")
+		return reconcile.Result{}, nil
+	}
+
+	// The canary Machine of the rollout is garbage collected with it.
+	if !rollout.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+	if rollout.Status.Phase == infrav1.TemplateRolloutPhasePromoted || rollout.Status.Phase == infrav1.TemplateRolloutPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(rollout, r.Client)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to init patch helper for VSphereMachineTemplateRollout %s", req.NamespacedName)
+	}
+	defer func() {
+		conditions.SetSummary(rollout, conditions.WithConditions(infrav1.CanaryReadyCondition, infrav1.ProbesSucceededCondition))
+		v1beta2conditions.Mirror(rollout)
+		if err := patchHelper.Patch(ctx, rollout); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			logger.Error(err, "patch failed")
+		}
+	}()
+
+	md := &clusterv1.MachineDeployment{}
+	mdKey := ctrlclient.ObjectKey{Namespace: rollout.Namespace, Name: rollout.Spec.MachineDeploymentName}
+	if err := r.Client.Get(ctx, mdKey, md); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get MachineDeployment %s", mdKey)
+	}
+	if md.Spec.ClusterName != rollout.Spec.ClusterName {
+		return reconcile.Result{}, errors.Errorf("MachineDeployment %s does not belong to Cluster %s", mdKey, rollout.Spec.ClusterName)
+	}
+
+	// Nothing is left to roll out when the MachineDeployment already
+	// references the template.
+	if md.Spec.Template.Spec.InfrastructureRef.Name == rollout.Spec.TemplateName {
+		return reconcile.Result{}, r.promote(ctx, rollout, md)
+	}
+
+	canary, created, err := r.reconcileCanary(ctx, rollout, md)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	rollout.Status.CanaryMachineName = canary.Name
+	if created {
+		rollout.Status.Phase = infrav1.TemplateRolloutPhaseCanary
+		conditions.MarkFalse(rollout, infrav1.CanaryReadyCondition, infrav1.WaitingForCanaryReason, clusterv1.ConditionSeverityInfo,
+			"waiting for the node of canary Machine %s to be healthy", canary.Name)
+		return reconcile.Result{RequeueAfter: templateRolloutRequeueInterval}, nil
+	}
+	deadline := canary.CreationTimestamp.Add(templateRolloutTimeout(rollout))
+
+	if canary.Status.FailureReason != nil || canary.Status.FailureMessage != nil {
+		message := fmt.Sprintf("canary Machine %s failed", canary.Name)
+		if canary.Status.FailureMessage != nil {
+			message += ": " + *canary.Status.FailureMessage
+		}
+		return r.fail(rollout, infrav1.CanaryReadyCondition, infrav1.CanaryFailedReason, message)
+	}
+	if canary.Status.NodeRef == nil || !conditions.IsTrue(canary, clusterv1.MachineNodeHealthyCondition) {
+		if time.Now().After(deadline) {
+			return r.fail(rollout, infrav1.CanaryReadyCondition, infrav1.CanaryFailedReason,
+				fmt.Sprintf("the node of canary Machine %s is not healthy after %s", canary.Name, templateRolloutTimeout(rollout)))
+		}
+		rollout.Status.Phase = infrav1.TemplateRolloutPhaseCanary
+		conditions.MarkFalse(rollout, infrav1.CanaryReadyCondition, infrav1.WaitingForCanaryReason, clusterv1.ConditionSeverityInfo,
+			"waiting for the node of canary Machine %s to be healthy", canary.Name)
+		return reconcile.Result{RequeueAfter: templateRolloutRequeueInterval}, nil
+	}
+	rollout.Status.CanaryNodeName = canary.Status.NodeRef.Name
+	conditions.MarkTrue(rollout, infrav1.CanaryReadyCondition)
+
+	if len(rollout.Spec.ProbeJobs) > 0 {
+		rollout.Status.Phase = infrav1.TemplateRolloutPhaseProbing
+		clusterKey := ctrlclient.ObjectKey{Namespace: rollout.Namespace, Name: rollout.Spec.ClusterName}
+		guestClient, err := r.remoteClientGetter(ctx, r.Name, r.Client, clusterKey)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to get the client of Cluster %s", clusterKey)
+		}
+		succeeded, failure, err := r.reconcileProbeJobs(ctx, guestClient, rollout)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if failure != "" {
+			return r.fail(rollout, infrav1.ProbesSucceededCondition, infrav1.ProbeFailedReason, failure)
+		}
+		if !succeeded {
+			if time.Now().After(deadline) {
+				return r.fail(rollout, infrav1.ProbesSucceededCondition, infrav1.ProbeFailedReason,
+					fmt.Sprintf("the probe Jobs did not succeed after %s", templateRolloutTimeout(rollout)))
+			}
+			conditions.MarkFalse(rollout, infrav1.ProbesSucceededCondition, infrav1.ProbesRunningReason, clusterv1.ConditionSeverityInfo, "")
+			return reconcile.Result{RequeueAfter: templateRolloutRequeueInterval}, nil
+		}
+	}
+	conditions.MarkTrue(rollout, infrav1.ProbesSucceededCondition)
+
+	return reconcile.Result{}, r.promote(ctx, rollout, md)
+}
+
+// promote sets the infrastructure reference of the MachineDeployment to the
+// template of the rollout, then deletes the canary Machine so that the
+// capacity of the MachineDeployment does not drop during the rollout.
+func (r templateRolloutReconciler) promote(ctx goctx.Context, rollout *infrav1.VSphereMachineTemplateRollout, md *clusterv1.MachineDeployment) error {
+	if md.Spec.Template.Spec.InfrastructureRef.Name != rollout.Spec.TemplateName {
+		mdPatch := ctrlclient.MergeFrom(md.DeepCopy())
+		md.Spec.Template.Spec.InfrastructureRef.Name = rollout.Spec.TemplateName
+		if err := r.Client.Patch(ctx, md, mdPatch); err != nil {
+			return errors.Wrapf(err, "failed to set the infrastructure template of MachineDeployment %s/%s", md.Namespace, md.Name)
+		}
+		r.Logger.Info("Promoted template", "vspheremachinetemplaterollout", rollout.Name, "machinedeployment", md.Name, "template", rollout.Spec.TemplateName)
+	}
+	canary := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Namespace: rollout.Namespace, Name: canaryMachineName(rollout)}}
+	if err := r.Client.Delete(ctx, canary); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete canary Machine %s/%s", canary.Namespace, canary.Name)
+	}
+	rollout.Status.Phase = infrav1.TemplateRolloutPhasePromoted
+	return nil
+}
+
+// reconcileCanary returns the canary Machine of the rollout, creating it from
+// the Machine template of the MachineDeployment with the infrastructure
+// template of the rollout, and whether it was created. The canary Machine
+// does not carry the labels of the MachineDeployment, so that its MachineSets
+// do not adopt it.
+func (r templateRolloutReconciler) reconcileCanary(ctx goctx.Context, rollout *infrav1.VSphereMachineTemplateRollout, md *clusterv1.MachineDeployment) (*clusterv1.Machine, bool, error) {
+	canary := &clusterv1.Machine{}
+	key := ctrlclient.ObjectKey{Namespace: rollout.Namespace, Name: canaryMachineName(rollout)}
+	err := r.Client.Get(ctx, key, canary)
+	switch {
+	case err == nil:
+		return canary, false, nil
+	case !apierrors.IsNotFound(err):
+		return nil, false, errors.Wrapf(err, "failed to get canary Machine %s", key)
+	}
+
+	templateKey := ctrlclient.ObjectKey{Namespace: rollout.Namespace, Name: rollout.Spec.TemplateName}
+	if err := r.Client.Get(ctx, templateKey, &infrav1.VSphereMachineTemplate{}); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to get VSphereMachineTemplate %s", templateKey)
+	}
+
+	// The clones are owned by the rollout until the Machine controller adds
+	// the canary Machine to their owners, so that they are garbage collected
+	// when the creation of the canary Machine fails.
+	ownerRef := metav1.NewControllerRef(rollout, infrav1.GroupVersion.WithKind("VSphereMachineTemplateRollout"))
+	labels := map[string]string{
+		clusterv1.ClusterLabelName:   rollout.Spec.ClusterName,
+		infrav1.TemplateRolloutLabel: rollout.Name,
+	}
+	spec := md.Spec.Template.Spec.DeepCopy()
+	infraRef, err := external.CloneTemplate(ctx, &external.CloneTemplateInput{
+		Client: r.Client,
+		TemplateRef: &corev1.ObjectReference{
+			APIVersion: infrav1.GroupVersion.String(),
+			Kind:       "VSphereMachineTemplate",
+			Namespace:  rollout.Namespace,
+			Name:       rollout.Spec.TemplateName,
+		},
+		Namespace:   rollout.Namespace,
+		ClusterName: rollout.Spec.ClusterName,
+		OwnerRef:    &metav1.OwnerReference{APIVersion: ownerRef.APIVersion, Kind: ownerRef.Kind, Name: ownerRef.Name, UID: ownerRef.UID},
+		Labels:      labels,
+	})
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to clone VSphereMachineTemplate %s", templateKey)
+	}
+	spec.InfrastructureRef = *infraRef
+	if spec.Bootstrap.ConfigRef != nil {
+		bootstrapRef, err := external.CloneTemplate(ctx, &external.CloneTemplateInput{
+			Client:      r.Client,
+			TemplateRef: spec.Bootstrap.ConfigRef,
+			Namespace:   rollout.Namespace,
+			ClusterName: rollout.Spec.ClusterName,
+			OwnerRef:    &metav1.OwnerReference{APIVersion: ownerRef.APIVersion, Kind: ownerRef.Kind, Name: ownerRef.Name, UID: ownerRef.UID},
+			Labels:      labels,
+		})
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to clone bootstrap template %s", spec.Bootstrap.ConfigRef.Name)
+		}
+		spec.Bootstrap.ConfigRef = bootstrapRef
+	}
+
+	canary = &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       key.Namespace,
+			Name:            key.Name,
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+		Spec: *spec,
+	}
+	if err := r.Client.Create(ctx, canary); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to create canary Machine %s", key)
+	}
+	r.Logger.Info("Created canary Machine", "vspheremachinetemplaterollout", rollout.Name, "machine", canary.Name)
+	return canary, true, nil
+}
+
+// reconcileProbeJobs creates the probe Jobs of the rollout on the node of its
+// canary Machine. It returns true when all of them succeeded, and the failure
+// of the first Job that failed.
+func (r templateRolloutReconciler) reconcileProbeJobs(ctx goctx.Context, guestClient ctrlclient.Client, rollout *infrav1.VSphereMachineTemplateRollout) (bool, string, error) {
+	succeeded := true
+	for i := range rollout.Spec.ProbeJobs {
+		probe := &rollout.Spec.ProbeJobs[i]
+		job := &batchv1.Job{}
+		key := ctrlclient.ObjectKey{Namespace: probe.Namespace, Name: rollout.Name + "-" + probe.Name}
+		if key.Namespace == "" {
+			key.Namespace = metav1.NamespaceDefault
+		}
+		err := guestClient.Get(ctx, key, job)
+		switch {
+		case apierrors.IsNotFound(err):
+			job = newProbeJob(rollout, probe, key)
+			if err := guestClient.Create(ctx, job); err != nil {
+				return false, "", errors.Wrapf(err, "failed to create probe Job %s", key)
+			}
+			r.Logger.Info("Created probe Job", "vspheremachinetemplaterollout", rollout.Name, "job", key)
+			succeeded = false
+			continue
+		case err != nil:
+			return false, "", errors.Wrapf(err, "failed to get probe Job %s", key)
+		}
+
+		// A Job left by an earlier canary of a rollout of the same name is
+		// replaced.
+		if job.Spec.Template.Spec.NodeName != rollout.Status.CanaryNodeName {
+			if err := guestClient.Delete(ctx, job, ctrlclient.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+				return false, "", errors.Wrapf(err, "failed to delete probe Job %s", key)
+			}
+			succeeded = false
+			continue
+		}
+		for _, c := range job.Status.Conditions {
+			if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+				return false, fmt.Sprintf("probe Job %s failed: %s", key, c.Message), nil
+			}
+		}
+		if job.Status.Succeeded == 0 {
+			succeeded = false
+		}
+	}
+	return succeeded, "", nil
+}
+
+// fail marks the rollout as failed with the reason and message of the failed
+// condition. A failed rollout is not reconciled anymore.
+func (r templateRolloutReconciler) fail(rollout *infrav1.VSphereMachineTemplateRollout, condition clusterv1.ConditionType, reason, message string) (reconcile.Result, error) {
+	r.Logger.Info("Template rollout failed", "vspheremachinetemplaterollout", rollout.Name, "reason", reason, "message", message)
+	r.Recorder.Warn(rollout, reason, message)
+	rollout.Status.Phase = infrav1.TemplateRolloutPhaseFailed
+	conditions.MarkFalse(rollout, condition, reason, clusterv1.ConditionSeverityError, "%s", message)
+	return reconcile.Result{}, nil
+}
+
+// newProbeJob returns the Job of a probe of the rollout, whose pods are bound
+// to the node of the canary Machine.
+func newProbeJob(rollout *infrav1.VSphereMachineTemplateRollout, probe *infrav1.TemplateRolloutProbeJob, key ctrlclient.ObjectKey) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: key.Namespace,
+			Name:      key.Name,
+			Labels: map[string]string{
+				infrav1.TemplateRolloutLabel: rollout.Name,
+			},
+		},
+	}
+	probe.Spec.DeepCopyInto(&job.Spec)
+	job.Spec.Template.Spec.NodeName = rollout.Status.CanaryNodeName
+	return job
+}
+
+// templateRolloutTimeout returns the time within which the canary Machine of
+// the rollout must pass its probes.
+func templateRolloutTimeout(rollout *infrav1.VSphereMachineTemplateRollout) time.Duration {
+	if rollout.Spec.Timeout != nil {
+		return rollout.Spec.Timeout.Duration
+	}
+	return defaultTemplateRolloutTimeout
+}
+
+// canaryMachineName returns the name of the canary Machine of the rollout.
+func canaryMachineName(rollout *infrav1.VSphereMachineTemplateRollout) string {
+	return rollout.Name + "-canary"
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestTemplateRolloutReconciler_Reconcile(t *testing.T) {
+	rollout := func(probeJobs ...infrav1.TemplateRolloutProbeJob) *infrav1.VSphereMachineTemplateRollout {
+		return &infrav1.VSphereMachineTemplateRollout{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "rollout", UID: "rollout-uid"},
+			Spec: infrav1.VSphereMachineTemplateRolloutSpec{
+				ClusterName:           "cluster",
+				MachineDeploymentName: "workers",
+				TemplateName:          "new",
+				ProbeJobs:             probeJobs,
+			},
+		}
+	}
+	template := func(name string) *infrav1.VSphereMachineTemplate {
+		return &infrav1.VSphereMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: name},
+			Spec: infrav1.VSphereMachineTemplateSpec{
+				Template: infrav1.VSphereMachineTemplateResource{
+					Spec: infrav1.VSphereMachineSpec{VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Template: name}},
+				},
+			},
+		}
+	}
+	dataSecretName := "bootstrap-data"
+	machineDeployment := &clusterv1.MachineDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "workers"},
+		Spec: clusterv1.MachineDeploymentSpec{
+			ClusterName: "cluster",
+			Template: clusterv1.MachineTemplateSpec{
+				ObjectMeta: clusterv1.ObjectMeta{Labels: map[string]string{"pool": "workers"}},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: "cluster",
+					Bootstrap:   clusterv1.Bootstrap{DataSecretName: &dataSecretName},
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: infrav1.GroupVersion.String(),
+						Kind:       "VSphereMachineTemplate",
+						Name:       "old",
+					},
+				},
+			},
+		},
+	}
+	canary := func(healthy bool) *clusterv1.Machine {
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "rollout-canary", CreationTimestamp: metav1.Now()},
+			Spec:       clusterv1.MachineSpec{ClusterName: "cluster"},
+		}
+		if healthy {
+			machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: "canary-node"}
+			conditions.MarkTrue(machine, clusterv1.MachineNodeHealthyCondition)
+		}
+		return machine
+	}
+	probeJob := infrav1.TemplateRolloutProbeJob{
+		Name: "smoke",
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{{Name: "smoke", Image: "busybox"}},
+				},
+			},
+		},
+	}
+	getRollout := func(g *WithT, c client.Client) *infrav1.VSphereMachineTemplateRollout {
+		r := &infrav1.VSphereMachineTemplateRollout{}
+		g.Expect(c.Get(goctx.Background(), client.ObjectKey{Namespace: fake.Namespace, Name: "rollout"}, r)).To(Succeed())
+		return r
+	}
+	getMachineDeployment := func(g *WithT, c client.Client) *clusterv1.MachineDeployment {
+		md := &clusterv1.MachineDeployment{}
+		g.Expect(c.Get(goctx.Background(), client.ObjectKeyFromObject(machineDeployment), md)).To(Succeed())
+		return md
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKey{Namespace: fake.Namespace, Name: "rollout"}}
+
+	t.Run("creates the canary Machine from the template", func(t *testing.T) {
+		g := NewWithT(t)
+		mgmtContext := fake.NewControllerManagerContext(rollout(), machineDeployment.DeepCopy(), template("new"))
+		r := templateRolloutReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		result, err := r.Reconcile(mgmtContext, req)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result.RequeueAfter).To(Equal(templateRolloutRequeueInterval))
+
+		machine := &clusterv1.Machine{}
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKey{Namespace: fake.Namespace, Name: "rollout-canary"}, machine)).To(Succeed())
+		g.Expect(machine.Labels).To(HaveKeyWithValue(infrav1.TemplateRolloutLabel, "rollout"))
+		g.Expect(machine.Labels).NotTo(HaveKey("pool"))
+		g.Expect(machine.Spec.Bootstrap.DataSecretName).To(Equal(&dataSecretName))
+		g.Expect(machine.Spec.InfrastructureRef.Kind).To(Equal("VSphereMachine"))
+
+		vsphereMachine := &infrav1.VSphereMachine{}
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKey{Namespace: fake.Namespace, Name: machine.Spec.InfrastructureRef.Name}, vsphereMachine)).To(Succeed())
+		g.Expect(vsphereMachine.Spec.Template).To(Equal("new"))
+
+		r2 := getRollout(g, mgmtContext.Client)
+		g.Expect(r2.Status.Phase).To(Equal(infrav1.TemplateRolloutPhaseCanary))
+		g.Expect(r2.Status.CanaryMachineName).To(Equal("rollout-canary"))
+		g.Expect(conditions.GetReason(r2, infrav1.CanaryReadyCondition)).To(Equal(infrav1.WaitingForCanaryReason))
+		g.Expect(getMachineDeployment(g, mgmtContext.Client).Spec.Template.Spec.InfrastructureRef.Name).To(Equal("old"))
+	})
+
+	t.Run("fails when the node of the canary Machine is not healthy within the timeout", func(t *testing.T) {
+		g := NewWithT(t)
+		p := rollout()
+		p.Spec.Timeout = &metav1.Duration{Duration: time.Minute}
+		stale := canary(false)
+		stale.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+		mgmtContext := fake.NewControllerManagerContext(p, machineDeployment.DeepCopy(), template("new"), stale)
+		r := templateRolloutReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, req)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		r2 := getRollout(g, mgmtContext.Client)
+		g.Expect(r2.Status.Phase).To(Equal(infrav1.TemplateRolloutPhaseFailed))
+		g.Expect(conditions.GetReason(r2, infrav1.CanaryReadyCondition)).To(Equal(infrav1.CanaryFailedReason))
+		g.Expect(getMachineDeployment(g, mgmtContext.Client).Spec.Template.Spec.InfrastructureRef.Name).To(Equal("old"))
+		// The canary Machine is kept for troubleshooting.
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(stale), &clusterv1.Machine{})).To(Succeed())
+	})
+
+	t.Run("runs the probe Jobs on the canary node before promoting the template", func(t *testing.T) {
+		g := NewWithT(t)
+		mgmtContext := fake.NewControllerManagerContext(rollout(probeJob), machineDeployment.DeepCopy(), template("new"), canary(true))
+		guestClient := clientfake.NewClientBuilder().WithScheme(mgmtContext.Scheme).Build()
+		r := templateRolloutReconciler{
+			ControllerContext: fake.NewControllerContext(mgmtContext),
+			remoteClientGetter: func(goctx.Context, string, client.Client, client.ObjectKey) (client.Client, error) {
+				return guestClient, nil
+			},
+		}
+
+		result, err := r.Reconcile(mgmtContext, req)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result.RequeueAfter).To(Equal(templateRolloutRequeueInterval))
+
+		job := &batchv1.Job{}
+		g.Expect(guestClient.Get(mgmtContext, client.ObjectKey{Namespace: metav1.NamespaceDefault, Name: "rollout-smoke"}, job)).To(Succeed())
+		g.Expect(job.Spec.Template.Spec.NodeName).To(Equal("canary-node"))
+		r2 := getRollout(g, mgmtContext.Client)
+		g.Expect(r2.Status.Phase).To(Equal(infrav1.TemplateRolloutPhaseProbing))
+		g.Expect(conditions.IsTrue(r2, infrav1.CanaryReadyCondition)).To(BeTrue())
+		g.Expect(conditions.GetReason(r2, infrav1.ProbesSucceededCondition)).To(Equal(infrav1.ProbesRunningReason))
+		g.Expect(getMachineDeployment(g, mgmtContext.Client).Spec.Template.Spec.InfrastructureRef.Name).To(Equal("old"))
+
+		job.Status.Succeeded = 1
+		g.Expect(guestClient.Status().Update(mgmtContext, job)).To(Succeed())
+		_, err = r.Reconcile(mgmtContext, req)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		r2 = getRollout(g, mgmtContext.Client)
+		g.Expect(r2.Status.Phase).To(Equal(infrav1.TemplateRolloutPhasePromoted))
+		g.Expect(conditions.IsTrue(r2, clusterv1.ReadyCondition)).To(BeTrue())
+		g.Expect(getMachineDeployment(g, mgmtContext.Client).Spec.Template.Spec.InfrastructureRef.Name).To(Equal("new"))
+		err = mgmtContext.Client.Get(mgmtContext, client.ObjectKey{Namespace: fake.Namespace, Name: "rollout-canary"}, &clusterv1.Machine{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("fails when a probe Job fails", func(t *testing.T) {
+		g := NewWithT(t)
+		mgmtContext := fake.NewControllerManagerContext(rollout(probeJob), machineDeployment.DeepCopy(), template("new"), canary(true))
+		failed := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: metav1.NamespaceDefault, Name: "rollout-smoke"},
+			Spec:       batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{NodeName: "canary-node"}}},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}},
+			},
+		}
+		guestClient := clientfake.NewClientBuilder().WithScheme(mgmtContext.Scheme).WithObjects(failed).Build()
+		r := templateRolloutReconciler{
+			ControllerContext: fake.NewControllerContext(mgmtContext),
+			remoteClientGetter: func(goctx.Context, string, client.Client, client.ObjectKey) (client.Client, error) {
+				return guestClient, nil
+			},
+		}
+
+		_, err := r.Reconcile(mgmtContext, req)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		r2 := getRollout(g, mgmtContext.Client)
+		g.Expect(r2.Status.Phase).To(Equal(infrav1.TemplateRolloutPhaseFailed))
+		g.Expect(conditions.GetReason(r2, infrav1.ProbesSucceededCondition)).To(Equal(infrav1.ProbeFailedReason))
+		g.Expect(conditions.GetMessage(r2, infrav1.ProbesSucceededCondition)).To(ContainSubstring("BackoffLimitExceeded"))
+		g.Expect(getMachineDeployment(g, mgmtContext.Client).Spec.Template.Spec.InfrastructureRef.Name).To(Equal("old"))
+	})
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/quota"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vsphereresourcequotas,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vsphereresourcequotas/status,verbs=get;update;patch
+
+// AddVSphereResourceQuotaControllerToManager adds the controller that reports
+// the usage of each VSphereResourceQuota to the provided manager.
+func AddVSphereResourceQuotaControllerToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controllerNameShort = "vsphereresourcequota-controller"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+	r := resourceQuotaReconciler{ControllerContext: controllerContext}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerNameShort).
+		For(&infrav1.VSphereResourceQuota{}).
+		// Watch the VSphereMachines, whose resources are used by the quotas
+		// of their namespace.
+		Watches(
+			&source.Kind{Type: &infrav1.VSphereMachine{}},
+			handler.EnqueueRequestsFromMapFunc(r.vsphereMachineToResourceQuotas),
+		).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+type resourceQuotaReconciler struct {
+	*context.ControllerContext
+}
+
+// Reconcile reports the resources requested by the VSphereMachines of the
+// namespace of a VSphereResourceQuota in its status.
+func (r resourceQuotaReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
+	logger := r.Logger.WithValues("vsphereresourcequota", req.NamespacedName)
+
+	resourceQuota := &infrav1.VSphereResourceQuota{}
+	if err := r.Client.Get(ctx, req.NamespacedName, resourceQuota); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !resourceQuota.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(resourceQuota, r.Client)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to init patch helper for VSphereResourceQuota %s", req.NamespacedName)
+	}
+	defer func() {
+		conditions.SetSummary(resourceQuota, conditions.WithConditions(infrav1.ResourceQuotaSatisfiedCondition))
+		v1beta2conditions.Mirror(resourceQuota)
+		if err := patchHelper.Patch(ctx, resourceQuota); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			logger.Error(err, "patch failed")
+		}
+	}()
+
+	machines := &infrav1.VSphereMachineList{}
+	if err := r.Client.List(ctx, machines, ctrlclient.InNamespace(resourceQuota.Namespace)); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to list the VSphereMachines of VSphereResourceQuota %s", req.NamespacedName)
+	}
+
+	hard := resourceQuota.Spec.Hard
+	used := quota.Limited(hard, quota.Usage(machines.Items))
+	resourceQuota.Status.Hard = hard
+	resourceQuota.Status.Used = used
+	resourceQuota.Status.Machines = int32(len(machines.Items))
+
+	if exceeded := quota.Exceeded(hard, used); exceeded != "" {
+		conditions.MarkFalse(resourceQuota, infrav1.ResourceQuotaSatisfiedCondition, infrav1.ResourceQuotaExceededReason, clusterv1.ConditionSeverityWarning, exceeded)
+	} else {
+		conditions.MarkTrue(resourceQuota, infrav1.ResourceQuotaSatisfiedCondition)
+	}
+	return reconcile.Result{}, nil
+}
+
+// vsphereMachineToResourceQuotas returns the reconcile requests of the
+// VSphereResourceQuotas of the namespace of a VSphereMachine.
+func (r resourceQuotaReconciler) vsphereMachineToResourceQuotas(o ctrlclient.Object) []reconcile.Request {
+	quotas := &infrav1.VSphereResourceQuotaList{}
+	if err := r.Client.List(r, quotas, ctrlclient.InNamespace(o.GetNamespace())); err != nil {
+		r.Logger.Error(err, "failed to list VSphereResourceQuotas", "namespace", o.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(quotas.Items))
+	for _, q := range quotas.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: apitypes.NamespacedName{Namespace: q.Namespace, Name: q.Name}})
+	}
+	return requests
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestResourceQuotaReconciler_Reconcile(t *testing.T) {
+	resourceQuota := func(numCPUs int64) *infrav1.VSphereResourceQuota {
+		return &infrav1.VSphereResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "quota"},
+			Spec:       infrav1.VSphereResourceQuotaSpec{Hard: infrav1.VSphereResources{NumCPUs: pointer.Int64(numCPUs), MemoryMiB: pointer.Int64(16384)}},
+		}
+	}
+	machine := func(name string, numCPUs int32) *infrav1.VSphereMachine {
+		m := &infrav1.VSphereMachine{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: name}}
+		m.Spec.NumCPUs, m.Spec.MemoryMiB, m.Spec.DiskGiB = numCPUs, 4096, 20
+		return m
+	}
+	reconcileQuota := func(g *WithT, objects ...client.Object) *infrav1.VSphereResourceQuota {
+		mgmtContext := fake.NewControllerManagerContext(objects...)
+		r := resourceQuotaReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+		key := client.ObjectKey{Namespace: fake.Namespace, Name: "quota"}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: key})
+		g.Expect(err).NotTo(HaveOccurred())
+		q := &infrav1.VSphereResourceQuota{}
+		g.Expect(mgmtContext.Client.Get(goctx.Background(), key, q)).To(Succeed())
+		return q
+	}
+
+	t.Run("reports the usage of the limited resources", func(t *testing.T) {
+		g := NewWithT(t)
+		q := reconcileQuota(g, resourceQuota(8), machine("machine-0", 2), machine("machine-1", 4))
+
+		g.Expect(q.Status.Machines).To(Equal(int32(2)))
+		g.Expect(q.Status.Hard).To(Equal(q.Spec.Hard))
+		g.Expect(q.Status.Used).To(Equal(infrav1.VSphereResources{NumCPUs: pointer.Int64(6), MemoryMiB: pointer.Int64(8192)}))
+		g.Expect(conditions.IsTrue(q, infrav1.ResourceQuotaSatisfiedCondition)).To(BeTrue())
+	})
+
+	t.Run("reports an exceeded quota", func(t *testing.T) {
+		g := NewWithT(t)
+		q := reconcileQuota(g, resourceQuota(4), machine("machine-0", 2), machine("machine-1", 4))
+
+		condition := conditions.Get(q, infrav1.ResourceQuotaSatisfiedCondition)
+		g.Expect(condition).NotTo(BeNil())
+		g.Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+		g.Expect(condition.Reason).To(Equal(infrav1.ResourceQuotaExceededReason))
+		g.Expect(condition.Message).To(Equal("numCPUs: 6 > 4"))
+	})
+
+	t.Run("maps the machines to the quotas of their namespace", func(t *testing.T) {
+		g := NewWithT(t)
+		mgmtContext := fake.NewControllerManagerContext(resourceQuota(8))
+		r := resourceQuotaReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		requests := r.vsphereMachineToResourceQuotas(machine("machine", 2))
+		g.Expect(requests).To(HaveLen(1))
+		g.Expect(requests[0].Name).To(Equal("quota"))
+		g.Expect(r.vsphereMachineToResourceQuotas(&infrav1.VSphereMachine{ObjectMeta: metav1.ObjectMeta{Namespace: "other"}})).To(BeEmpty())
+	})
+}
@@ -0,0 +1,391 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheretemplateusages,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspheretemplateusages/status,verbs=get;update;patch
+
+// AddVSphereTemplateUsageControllerToManager adds the controller that reports
+// the objects referencing each template in a VSphereTemplateUsage to the
+// provided manager.
+func AddVSphereTemplateUsageControllerToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controllerNameShort = "vspheretemplateusage-controller"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+	r := templateUsageReconciler{ControllerContext: controllerContext}
+
+	// All the usages are computed at once, so the changes of the objects
+	// referencing templates are all mapped to the same request.
+	toUsages := handler.EnqueueRequestsFromMapFunc(func(ctrlclient.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: templateUsageRequestName}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerNameShort).
+		For(&infrav1.VSphereTemplateUsage{}).
+		Watches(&source.Kind{Type: &infrav1.VSphereMachineTemplate{}}, toUsages).
+		Watches(&source.Kind{Type: &infrav1.VSphereMachine{}}, toUsages).
+		Watches(&source.Kind{Type: &infrav1.VSphereVM{}}, toUsages).
+		Watches(&source.Kind{Type: &infrav1.VSphereMachinePool{}}, toUsages).
+		Watches(&source.Kind{Type: &infrav1.VSphereWarmPool{}}, toUsages).
+		Watches(&source.Kind{Type: &infrav1.VSphereCluster{}}, toUsages).
+		Watches(&source.Kind{Type: &infrav1.VSphereMachineImage{}}, toUsages).
+		// The usages are computed from all the objects, so they are not
+		// computed concurrently.
+		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
+		Complete(r)
+}
+
+// templateUsageRequestName is the name of the request which computes the
+// usages of all the templates.
+const templateUsageRequestName = "templates"
+
+type templateUsageReconciler struct {
+	*context.ControllerContext
+}
+
+// templateKey identifies a template.
+type templateKey struct {
+	server   string
+	template string
+}
+
+// Reconcile computes the references of all the templates, whatever the
+// request, and stores them in the VSphereTemplateUsages. The usage of a
+// template which is no longer referenced is kept, and reports that the
+// template can be deleted.
+func (r templateUsageReconciler) Reconcile(ctx goctx.Context, _ ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
+	references, names, err := r.collectTemplateReferences(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	usages := &infrav1.VSphereTemplateUsageList{}
+	if err := r.Client.List(ctx, usages); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "failed to list VSphereTemplateUsages")
+	}
+	for i := range usages.Items {
+		usage := &usages.Items[i]
+		key := templateKey{server: usage.Spec.Server, template: usage.Spec.Template}
+		if _, ok := references[key]; ok {
+			continue
+		}
+		// The usage of a template which was not looked up is only kept while
+		// it is referenced, as the objects now referencing the template may
+		// spell it differently, or it may have been looked up since.
+		if !isTemplateRef(usage.Spec.Template) {
+			if err := r.Client.Delete(ctx, usage); err != nil && !apierrors.IsNotFound(err) {
+				return reconcile.Result{}, errors.Wrapf(err, "failed to delete VSphereTemplateUsage %s", usage.Name)
+			}
+			continue
+		}
+		references[key] = nil
+		names[key] = usage.Spec.TemplateName
+	}
+
+	for key, refs := range references {
+		usage := &infrav1.VSphereTemplateUsage{ObjectMeta: metav1.ObjectMeta{Name: templateUsageName(key)}}
+		_, err := ctrlutil.CreateOrPatch(ctx, r.Client, usage, func() error {
+			usage.Spec.Server = key.server
+			usage.Spec.Template = key.template
+			usage.Spec.TemplateName = names[key]
+			usage.Status.References = refs
+			usage.Status.ReferenceCount = int32(len(refs))
+			usage.Status.SafeToDelete = len(refs) == 0
+			return nil
+		})
+		if err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to update VSphereTemplateUsage %s", usage.Name)
+		}
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// collectTemplateReferences returns the objects referencing each template,
+// sorted by kind, namespace and name, and the names of the templates looked
+// up in vCenter. The objects without a server use the server of their
+// VSphereCluster, and the objects referencing a VSphereMachineImage the
+// template resolved for the image.
+func (r templateUsageReconciler) collectTemplateReferences(ctx goctx.Context) (map[templateKey][]infrav1.VSphereTemplateReference, map[templateKey]string, error) {
+	vsphereClusters := &infrav1.VSphereClusterList{}
+	if err := r.Client.List(ctx, vsphereClusters); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list VSphereClusters")
+	}
+	clusters := map[types.NamespacedName]*infrav1.VSphereCluster{}
+	for i := range vsphereClusters.Items {
+		vsphereCluster := &vsphereClusters.Items[i]
+		if clusterName := templateUsageClusterName(vsphereCluster); clusterName != "" {
+			clusters[types.NamespacedName{Namespace: vsphereCluster.Namespace, Name: clusterName}] = vsphereCluster
+		}
+	}
+
+	machineImages := &infrav1.VSphereMachineImageList{}
+	if err := r.Client.List(ctx, machineImages); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list VSphereMachineImages")
+	}
+	images := map[types.NamespacedName]*infrav1.VSphereMachineImage{}
+	for i := range machineImages.Items {
+		images[ctrlclient.ObjectKeyFromObject(&machineImages.Items[i])] = &machineImages.Items[i]
+	}
+
+	lookups := map[templateLookupKey]templateLookup{}
+	references := map[templateKey][]infrav1.VSphereTemplateReference{}
+	names := map[templateKey]string{}
+	add := func(kind string, obj ctrlclient.Object, spec infrav1.VirtualMachineCloneSpec, imageRef *corev1.LocalObjectReference) {
+		clusterName := templateUsageClusterName(obj)
+		if spec.Server == "" {
+			if vsphereCluster := clusters[types.NamespacedName{Namespace: obj.GetNamespace(), Name: clusterName}]; vsphereCluster != nil {
+				spec.Server, spec.Thumbprint = vsphereCluster.Spec.Server, vsphereCluster.Spec.Thumbprint
+			}
+		}
+		if spec.Template == "" && imageRef != nil {
+			image := images[types.NamespacedName{Namespace: obj.GetNamespace(), Name: imageRef.Name}]
+			if image == nil || image.Status.TemplateRef == "" {
+				return
+			}
+			spec.Template = image.Status.TemplateRef
+			if spec.Server == "" {
+				spec.Server, spec.Thumbprint, spec.Datacenter = image.Spec.Server, image.Spec.Thumbprint, image.Spec.Datacenter
+			}
+		}
+		if spec.Template == "" {
+			return
+		}
+
+		lookupKey := templateLookupKey{server: spec.Server, datacenter: spec.Datacenter, template: spec.Template}
+		lookup, ok := lookups[lookupKey]
+		if !ok {
+			lookup = r.lookupTemplate(ctx, obj.GetNamespace(), spec)
+			lookups[lookupKey] = lookup
+		}
+		if lookup.name != "" {
+			names[lookup.key] = lookup.name
+		}
+		references[lookup.key] = append(references[lookup.key], infrav1.VSphereTemplateReference{
+			Kind:        kind,
+			Namespace:   obj.GetNamespace(),
+			Name:        obj.GetName(),
+			ClusterName: clusterName,
+		})
+	}
+
+	machineTemplates := &infrav1.VSphereMachineTemplateList{}
+	if err := r.Client.List(ctx, machineTemplates); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list VSphereMachineTemplates")
+	}
+	for i := range machineTemplates.Items {
+		spec := machineTemplates.Items[i].Spec.Template.Spec
+		add("VSphereMachineTemplate", &machineTemplates.Items[i], spec.VirtualMachineCloneSpec, spec.ImageRef)
+	}
+
+	machinePools := &infrav1.VSphereMachinePoolList{}
+	if err := r.Client.List(ctx, machinePools); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list VSphereMachinePools")
+	}
+	for i := range machinePools.Items {
+		add("VSphereMachinePool", &machinePools.Items[i], machinePools.Items[i].Spec.Template, nil)
+	}
+
+	warmPools := &infrav1.VSphereWarmPoolList{}
+	if err := r.Client.List(ctx, warmPools); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list VSphereWarmPools")
+	}
+	for i := range warmPools.Items {
+		add("VSphereWarmPool", &warmPools.Items[i], warmPools.Items[i].Spec.Template, nil)
+	}
+
+	machines := &infrav1.VSphereMachineList{}
+	if err := r.Client.List(ctx, machines); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list VSphereMachines")
+	}
+	for i := range machines.Items {
+		add("VSphereMachine", &machines.Items[i], machines.Items[i].Spec.VirtualMachineCloneSpec, machines.Items[i].Spec.ImageRef)
+	}
+
+	vms := &infrav1.VSphereVMList{}
+	if err := r.Client.List(ctx, vms); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list VSphereVMs")
+	}
+	for i := range vms.Items {
+		add("VSphereVM", &vms.Items[i], vms.Items[i].Spec.VirtualMachineCloneSpec, nil)
+	}
+
+	for _, refs := range references {
+		sort.Slice(refs, func(i, j int) bool {
+			if refs[i].Kind != refs[j].Kind {
+				return refs[i].Kind < refs[j].Kind
+			}
+			if refs[i].Namespace != refs[j].Namespace {
+				return refs[i].Namespace < refs[j].Namespace
+			}
+			return refs[i].Name < refs[j].Name
+		})
+	}
+	return references, names, nil
+}
+
+// templateLookupKey identifies a template as written in the objects
+// referencing it.
+type templateLookupKey struct {
+	server     string
+	datacenter string
+	template   string
+}
+
+// templateLookup is the template of a templateLookupKey.
+type templateLookup struct {
+	key  templateKey
+	name string
+}
+
+// lookupTemplate looks up a template in vCenter, so that the objects spelling
+// it differently, by name, inventory path, instance UUID or managed object
+// reference, are counted in the same usage. A template which cannot be looked
+// up, e.g. the item of a content library which was not deployed yet, is
+// identified as written in the objects.
+func (r templateUsageReconciler) lookupTemplate(ctx goctx.Context, namespace string, spec infrav1.VirtualMachineCloneSpec) templateLookup {
+	lookup := templateLookup{key: templateKey{server: spec.Server, template: spec.Template}}
+	if spec.Server == "" {
+		return lookup
+	}
+	logger := r.Logger.WithValues("server", spec.Server, "template", spec.Template)
+
+	caBundle, err := identity.GetCABundle(ctx, r.Client, namespace, spec.CABundleRef)
+	if err != nil {
+		logger.V(4).Info("unable to retrieve the CA bundle to look up the template", "error", err.Error())
+		return lookup
+	}
+	authSession, err := session.GetOrCreate(ctx, session.NewParams().
+		WithServer(spec.Server).
+		WithThumbprint(spec.Thumbprint).
+		WithCABundle(caBundle).
+		WithDatacenter(spec.Datacenter).
+		WithUserInfo(r.Username, r.Password).
+		WithFeatures(session.Feature{
+			EnableKeepAlive:       r.EnableKeepAlive,
+			KeepAliveDuration:     r.KeepAliveDuration,
+			MaxConcurrentRequests: r.MaxConcurrentVCenterRequests,
+		}))
+	if err != nil {
+		logger.V(4).Info("unable to create a vCenter session to look up the template", "error", err.Error())
+		return lookup
+	}
+	ref, name, err := govmomi.LookupTemplateRef(ctx, logger, authSession, spec.Template)
+	if err != nil || ref == nil {
+		logger.V(4).Info("template not looked up", "error", fmt.Sprint(err))
+		return lookup
+	}
+	lookup.key.template = ref.String()
+	lookup.name = name
+	return lookup
+}
+
+// isTemplateRef returns whether a template is identified by its managed
+// object reference.
+func isTemplateRef(template string) bool {
+	var ref vim25types.ManagedObjectReference
+	return ref.FromString(template) && ref.Type == "VirtualMachine"
+}
+
+// templateUsageClusterName returns the name of the cluster of an object, from
+// its cluster label or, for a VSphereCluster, from its owner Cluster.
+func templateUsageClusterName(obj ctrlclient.Object) string {
+	if clusterName := obj.GetLabels()[clusterv1.ClusterLabelName]; clusterName != "" {
+		return clusterName
+	}
+	if _, ok := obj.(*infrav1.VSphereCluster); ok {
+		for _, ref := range obj.GetOwnerReferences() {
+			if ref.Kind == "Cluster" && strings.HasPrefix(ref.APIVersion, clusterv1.GroupVersion.Group+"/") {
+				return ref.Name
+			}
+		}
+	}
+	return ""
+}
+
+// templateUsageName returns the name of the VSphereTemplateUsage of a
+// template: the base name of the template, made a valid object name, and a
+// hash of the server and the template, which tells apart the templates with
+// the same base name.
+func templateUsageName(key templateKey) string {
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, path.Base(key.template))
+	if len(base) > 200 {
+		base = base[:200]
+	}
+	base = strings.Trim(base, "-.")
+	if base == "" {
+		base = "template"
+	}
+
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(key.server + "/" + key.template))
+	return fmt.Sprintf("%s-%08x", base, hasher.Sum32())
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+//nolint:forcetypeassert
+func TestTemplateUsageReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("failed to create VC simulator")
+	}
+	t.Cleanup(simr.Destroy)
+	server := simr.ServerURL().Host
+	simVM := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	templateRef := simVM.Reference().String()
+
+	vsphereCluster := &infrav1.VSphereCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       fake.Namespace,
+			Name:            "workload-abcde",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: "workload"}},
+		},
+		Spec: infrav1.VSphereClusterSpec{Server: server},
+	}
+	// The template is referenced by its name, its inventory path and, through
+	// a VSphereMachineImage, its managed object reference.
+	workers := &infrav1.VSphereMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      "workers",
+			Labels:    map[string]string{clusterv1.ClusterLabelName: "workload"},
+		},
+		Spec: infrav1.VSphereMachineTemplateSpec{
+			Template: infrav1.VSphereMachineTemplateResource{
+				Spec: infrav1.VSphereMachineSpec{
+					VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Datacenter: "DC0", Template: simVM.Name},
+				},
+			},
+		},
+	}
+	vm := &infrav1.VSphereVM{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      "workers-1",
+			Labels:    map[string]string{clusterv1.ClusterLabelName: "workload"},
+		},
+		Spec: infrav1.VSphereVMSpec{
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Server: server, Datacenter: "DC0", Template: "/DC0/vm/" + simVM.Name},
+		},
+	}
+	image := &infrav1.VSphereMachineImage{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "ubuntu"},
+		Spec:       infrav1.VSphereMachineImageSpec{Server: server, Datacenter: "DC0"},
+		Status:     infrav1.VSphereMachineImageStatus{Ready: true, TemplateRef: templateRef},
+	}
+	machine := &infrav1.VSphereMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      "workers-2",
+			Labels:    map[string]string{clusterv1.ClusterLabelName: "workload"},
+		},
+		Spec: infrav1.VSphereMachineSpec{ImageRef: &corev1.LocalObjectReference{Name: image.Name}},
+	}
+	// The template of the other VSphereVM is not found, and is identified as
+	// written in the VSphereVM.
+	missing := &infrav1.VSphereVM{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "vm"},
+		Spec: infrav1.VSphereVMSpec{
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Server: server, Datacenter: "DC0", Template: "/DC0/vm/ubuntu-2004"},
+		},
+	}
+	oldKey := templateKey{server: server, template: "VirtualMachine:vm-1804"}
+	oldUsage := &infrav1.VSphereTemplateUsage{
+		ObjectMeta: metav1.ObjectMeta{Name: templateUsageName(oldKey)},
+		Spec:       infrav1.VSphereTemplateUsageSpec{Server: oldKey.server, Template: oldKey.template, TemplateName: "ubuntu-1804"},
+		Status: infrav1.VSphereTemplateUsageStatus{
+			References:     []infrav1.VSphereTemplateReference{{Kind: "VSphereVM", Namespace: fake.Namespace, Name: "deleted"}},
+			ReferenceCount: 1,
+		},
+	}
+	// The usage of the template as written in the objects before it was
+	// looked up is removed.
+	spelledKey := templateKey{server: server, template: simVM.Name}
+	spelledUsage := &infrav1.VSphereTemplateUsage{
+		ObjectMeta: metav1.ObjectMeta{Name: templateUsageName(spelledKey)},
+		Spec:       infrav1.VSphereTemplateUsageSpec{Server: spelledKey.server, Template: spelledKey.template},
+	}
+
+	mgmtContext := fake.NewControllerManagerContext(vsphereCluster, workers, vm, image, machine, missing, oldUsage, spelledUsage)
+	mgmtContext.Username = simr.Username()
+	mgmtContext.Password = simr.Password()
+	r := templateUsageReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+	_, err = r.Reconcile(mgmtContext, ctrl.Request{})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	usage := &infrav1.VSphereTemplateUsage{}
+	key := client.ObjectKey{Name: templateUsageName(templateKey{server: server, template: templateRef})}
+	g.Expect(mgmtContext.Client.Get(mgmtContext, key, usage)).To(Succeed())
+	g.Expect(usage.Spec.TemplateName).To(Equal(simVM.Name))
+	g.Expect(usage.Status.SafeToDelete).To(BeFalse())
+	g.Expect(usage.Status.ReferenceCount).To(Equal(int32(3)))
+	g.Expect(usage.Status.References).To(Equal([]infrav1.VSphereTemplateReference{
+		{Kind: "VSphereMachine", Namespace: fake.Namespace, Name: "workers-2", ClusterName: "workload"},
+		{Kind: "VSphereMachineTemplate", Namespace: fake.Namespace, Name: "workers", ClusterName: "workload"},
+		{Kind: "VSphereVM", Namespace: fake.Namespace, Name: "workers-1", ClusterName: "workload"},
+	}))
+
+	key = client.ObjectKey{Name: templateUsageName(templateKey{server: server, template: "/DC0/vm/ubuntu-2004"})}
+	g.Expect(mgmtContext.Client.Get(mgmtContext, key, usage)).To(Succeed())
+	g.Expect(usage.Spec.TemplateName).To(BeEmpty())
+	g.Expect(usage.Status.ReferenceCount).To(Equal(int32(1)))
+
+	g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(oldUsage), usage)).To(Succeed())
+	g.Expect(usage.Spec.TemplateName).To(Equal("ubuntu-1804"))
+	g.Expect(usage.Status.SafeToDelete).To(BeTrue())
+	g.Expect(usage.Status.References).To(BeEmpty())
+
+	err = mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(spelledUsage), usage)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestTemplateUsageName(t *testing.T) {
+	g := NewWithT(t)
+
+	name := templateUsageName(templateKey{server: "vcenter.example.com", template: "/DC0/vm/Templates/Ubuntu 20.04"})
+	g.Expect(name).To(MatchRegexp(`^ubuntu-20.04-[0-9a-f]{8}$`))
+	g.Expect(templateUsageName(templateKey{server: "other.example.com", template: "/DC0/vm/Templates/Ubuntu 20.04"})).NotTo(Equal(name))
+	g.Expect(templateUsageName(templateKey{template: "VirtualMachine:vm-42"})).To(MatchRegexp(`^virtualmachine-vm-42-[0-9a-f]{8}$`))
+}
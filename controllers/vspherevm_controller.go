@@ -24,7 +24,9 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/types"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	apitypes "k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	clusterutilv1 "sigs.k8s.io/cluster-api/util"
@@ -32,29 +34,41 @@ import (
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbldr "sigs.k8s.io/controller-runtime/pkg/builder"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/hooks"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/ipam"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/kubevip"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherevms,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherevms/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddresses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=get;list;watch;delete
 
 // AddVMControllerToManager adds the VM controller to the provided manager.
 //nolint:forcetypeassert
@@ -65,74 +79,111 @@ func AddVMControllerToManager(ctx *context.ControllerManagerContext, mgr manager
 		controlledTypeGVK  = infrav1.GroupVersion.WithKind(controlledTypeName)
 
 		controllerNameShort = fmt.Sprintf("%s-controller", strings.ToLower(controlledTypeName))
-		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
 	)
 
-	// Build the controller context.
-	controllerContext := &context.ControllerContext{
-		ControllerManagerContext: ctx,
-		Name:                     controllerNameShort,
-		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
-		Logger:                   ctx.Logger.WithName(controllerNameShort),
-	}
-	r := vmReconciler{ControllerContext: controllerContext}
-	controller, err := ctrl.NewControllerManagedBy(mgr).
-		// Watch the controlled, infrastructure resource.
-		For(controlledType).
-		// Watch a GenericEvent channel for the controlled resource.
-		//
-		// This is useful when there are events outside of Kubernetes that
-		// should cause a resource to be synchronized, such as a goroutine
-		// waiting on some asynchronous, external task to complete.
-		Watches(
-			&source.Channel{Source: ctx.GetGenericEventChannelFor(controlledTypeGVK)},
-			&handler.EnqueueRequestForObject{},
-		).
-		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
-		Build(r)
-	if err != nil {
-		return err
+	if err := metrics.Registry.Register(&vmStorageCollector{client: ctx.Client, logger: ctx.Logger.WithName(controllerNameShort)}); err != nil {
+		return errors.Wrap(err, "failed to register the VSphereVM storage metrics")
 	}
 
-	err = controller.Watch(
-		&source.Kind{Type: &clusterv1.Cluster{}},
-		handler.EnqueueRequestsFromMapFunc(r.clusterToVSphereVMs),
-		predicate.Funcs{
-			UpdateFunc: func(e event.UpdateEvent) bool {
-				oldCluster := e.ObjectOld.(*clusterv1.Cluster)
-				newCluster := e.ObjectNew.(*clusterv1.Cluster)
-				return oldCluster.Spec.Paused && !newCluster.Spec.Paused
-			},
-			CreateFunc: func(e event.CreateEvent) bool {
-				if _, ok := e.Object.GetAnnotations()[clusterv1.PausedAnnotation]; !ok {
-					return false
-				}
-				return true
-			},
-		})
-	if err != nil {
-		return err
+	// The VSphereVMs of the control planes are reconciled by their own
+	// controller instance, so they do not wait behind the ones of the workers.
+	statusBatcher := newVMStatusBatcher(ctx.VMStatusBatchInterval)
+	eventChannels := priorityEventChannelsFor(ctx, controlledTypeGVK)
+	for _, priority := range priorityClasses {
+		controllerName := priority.controllerName(controllerNameShort)
+
+		// Build the controller context.
+		controllerContext := &context.ControllerContext{
+			ControllerManagerContext: ctx,
+			Name:                     controllerName,
+			Recorder:                 record.New(mgr.GetEventRecorderFor(fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerName))),
+			Logger:                   ctx.Logger.WithName(controllerName),
+		}
+		r := vmReconciler{
+			ControllerContext: controllerContext,
+			statusBatcher:     statusBatcher,
+		}
+
+		controller, err := priority.newControllerManagedBy(mgr, controllerName).
+			// Watch the controlled, infrastructure resource.
+			For(controlledType, ctrlbldr.WithPredicates(priority.predicate())).
+			// Watch a GenericEvent channel for the controlled resource.
+			//
+			// This is useful when there are events outside of Kubernetes that
+			// should cause a resource to be synchronized, such as a goroutine
+			// waiting on some asynchronous, external task to complete.
+			Watches(
+				&source.Channel{Source: eventChannels[priority]},
+				&handler.EnqueueRequestForObject{},
+			).
+			WithOptions(controller.Options{MaxConcurrentReconciles: priority.maxConcurrentReconciles(ctx)}).
+			Build(priority.reconciler(ctx.Client, func() ctrlclient.Object { return &infrav1.VSphereVM{} }, r))
+		if err != nil {
+			return err
+		}
+
+		err = controller.Watch(
+			&source.Kind{Type: &clusterv1.Cluster{}},
+			handler.EnqueueRequestsFromMapFunc(r.clusterToVSphereVMs),
+			predicate.Funcs{
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					oldCluster := e.ObjectOld.(*clusterv1.Cluster)
+					newCluster := e.ObjectNew.(*clusterv1.Cluster)
+					return oldCluster.Spec.Paused && !newCluster.Spec.Paused
+				},
+				CreateFunc: func(e event.CreateEvent) bool {
+					if _, ok := e.Object.GetAnnotations()[clusterv1.PausedAnnotation]; !ok {
+						return false
+					}
+					return true
+				},
+			})
+		if err != nil {
+			return err
+		}
+
+		// Watch the IPAddressClaims of the VSphereVMs only when an IPAM provider
+		// has installed the IPAddressClaim type.
+		if _, err := mgr.GetRESTMapper().RESTMapping(ipam.IPAddressClaimGVK.GroupKind(), ipam.IPAddressClaimGVK.Version); err == nil {
+			claim := &unstructured.Unstructured{}
+			claim.SetGroupVersionKind(ipam.IPAddressClaimGVK)
+			err = controller.Watch(
+				&source.Kind{Type: claim},
+				&handler.EnqueueRequestForOwner{OwnerType: controlledType, IsController: true},
+			)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
 type vmReconciler struct {
 	*context.ControllerContext
+
+	// statusBatcher coalesces the network and storage status patches of the VSphereVMs.
+	// A nil value patches every change right away.
+	statusBatcher *vmStatusBatcher
 }
 
 // Reconcile ensures the back-end state reflects the Kubernetes resource state intent.
-func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
 	// Get the VSphereVM resource for this request.
 	vsphereVM := &infrav1.VSphereVM{}
 	if err := r.Client.Get(r, req.NamespacedName, vsphereVM); err != nil {
 		if apierrors.IsNotFound(err) {
 			r.Logger.Info("VSphereVM not found, won't reconcile", "key", req.NamespacedName)
+			r.statusBatcher.forget(req.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		return reconcile.Result{}, err
 	}
 
 	// Create the patch helper.
+	patchBase := vsphereVM.DeepCopy()
 	patchHelper, err := patch.NewHelper(vsphereVM, r.Client)
 	if err != nil {
 		return reconcile.Result{}, errors.Wrapf(
@@ -145,42 +196,90 @@ func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Res
 
 	authSession, err := r.retrieveVcenterSession(ctx, vsphereVM)
 	if err != nil {
-		conditions.MarkFalse(vsphereVM, infrav1.VCenterAvailableCondition, infrav1.VCenterUnreachableReason, clusterv1.ConditionSeverityError, err.Error())
+		conditions.MarkFalse(vsphereVM, infrav1.VCenterAvailableCondition, vCenterUnavailableReason(err), clusterv1.ConditionSeverityError, err.Error())
 		return reconcile.Result{}, err
 	}
 	conditions.MarkTrue(vsphereVM, infrav1.VCenterAvailableCondition)
 
-	// Fetch the owner VSphereMachine.
-	vsphereMachine, err := util.GetOwnerVSphereMachine(r, r.Client, vsphereVM.ObjectMeta)
-	// vsphereMachine can be nil in cases where custom mover other than clusterctl
-	// moves the resources without ownerreferences set
-	// in that case nil vsphereMachine can cause panic and CrashLoopBackOff the pod
-	// preventing vspheremachine_controller from setting the ownerref
-	if err != nil || vsphereMachine == nil {
-		r.Logger.Info("Owner VSphereMachine not found, won't reconcile", "key", req.NamespacedName)
-		return reconcile.Result{}, nil
-	}
+	var (
+		vsphereFailureDomain *infrav1.VSphereFailureDomain
+		clusterModuleInfo    *string
+		hostname             string
+		kubeVIPManifest      []byte
+		overcommitPolicy     *infrav1.OvercommitPolicy
+	)
+	//nolint:nestif
+	if _, ok := vsphereVM.Labels[infrav1.WarmPoolLabel]; ok {
+		// The VM of a claimed VSphereVM of a warm pool is reconciled by the
+		// VSphereVM that claimed it.
+		if _, claimed := vsphereVM.Annotations[infrav1.WarmPoolClaimedByAnnotation]; claimed {
+			if vsphereVM.DeletionTimestamp.IsZero() {
+				return reconcile.Result{}, nil
+			}
+			r.CancelWaitsFor(vsphereVM.UID)
+			ctrlutil.RemoveFinalizer(vsphereVM, infrav1.VMFinalizer)
+			return reconcile.Result{}, patchHelper.Patch(r, vsphereVM)
+		}
+		hostname = vsphereVM.Name
+	} else if _, ok := vsphereVM.Labels[infrav1.MachinePoolLabel]; ok {
+		// The VMs of a machine pool have no Machine. They are placed in the
+		// failure domain, and therefore in the VM group, chosen by the pool.
+		if failureDomain, ok := vsphereVM.Annotations[infrav1.MachinePoolFailureDomainAnnotation]; ok {
+			if vsphereFailureDomain, err = r.fetchFailureDomain(failureDomain); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		hostname = vsphereVM.Name
+	} else {
+		// Fetch the owner VSphereMachine.
+		vsphereMachine, err := util.GetOwnerVSphereMachine(r, r.Client, vsphereVM.ObjectMeta)
+		// vsphereMachine can be nil in cases where custom mover other than clusterctl
+		// moves the resources without ownerreferences set
+		// in that case nil vsphereMachine can cause panic and CrashLoopBackOff the pod
+		// preventing vspheremachine_controller from setting the ownerref
+		if err != nil || vsphereMachine == nil {
+			r.Logger.Info("Owner VSphereMachine not found, won't reconcile", "key", req.NamespacedName)
+			return reconcile.Result{}, nil
+		}
 
-	// Fetch the CAPI Machine.
-	machine, err := clusterutilv1.GetOwnerMachine(r, r.Client, vsphereMachine.ObjectMeta)
-	if err != nil {
-		return reconcile.Result{}, err
-	}
-	if machine == nil {
-		r.Logger.Info("Waiting for OwnerRef to be set on VSphereMachine", "key", vsphereMachine.Name)
-		return reconcile.Result{}, nil
-	}
+		// Fetch the CAPI Machine.
+		machine, err := clusterutilv1.GetOwnerMachine(r, r.Client, vsphereMachine.ObjectMeta)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if machine == nil {
+			r.Logger.Info("Waiting for OwnerRef to be set on VSphereMachine", "key", vsphereMachine.Name)
+			return reconcile.Result{}, nil
+		}
+
+		if failureDomain := machine.Spec.FailureDomain; failureDomain != nil {
+			if vsphereFailureDomain, err = r.fetchFailureDomain(*failureDomain); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+
+		if feature.Gates.Enabled(feature.NodeAntiAffinity) {
+			clusterModuleInfo, err = r.fetchClusterModuleInfo(machine)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+		}
 
-	var vsphereFailureDomain *infrav1.VSphereFailureDomain
-	if failureDomain := machine.Spec.FailureDomain; failureDomain != nil {
-		vsphereDeploymentZone := &infrav1.VSphereDeploymentZone{}
-		if err := r.Client.Get(r, apitypes.NamespacedName{Name: *failureDomain}, vsphereDeploymentZone); err != nil {
-			return reconcile.Result{}, errors.Wrapf(err, "failed to find vsphere deployment zone %s", *failureDomain)
+		if clusterutilv1.IsControlPlaneMachine(machine) {
+			kubeVIPManifest, err = r.fetchKubeVIPManifest(machine)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
 		}
 
-		vsphereFailureDomain = &infrav1.VSphereFailureDomain{}
-		if err := r.Client.Get(r, apitypes.NamespacedName{Name: vsphereDeploymentZone.Spec.FailureDomain}, vsphereFailureDomain); err != nil {
-			return reconcile.Result{}, errors.Wrapf(err, "failed to find vsphere failure domain %s", vsphereDeploymentZone.Spec.FailureDomain)
+		hostname = util.GetMachineHostname(vsphereVM.Spec.VirtualMachineCloneSpec, machine.Name, util.GetVMName(vsphereVM))
+	}
+
+	// The overcommit policy only applies to the placement of the VMs which
+	// are not cloned yet.
+	if vsphereVM.Spec.BiosUUID == "" && vsphereVM.DeletionTimestamp.IsZero() {
+		if overcommitPolicy, err = r.fetchOvercommitPolicy(vsphereVM); err != nil {
+			return reconcile.Result{}, err
 		}
 	}
 
@@ -189,6 +288,11 @@ func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Res
 		ControllerContext:    r.ControllerContext,
 		VSphereVM:            vsphereVM,
 		VSphereFailureDomain: vsphereFailureDomain,
+		ClusterModuleInfo:    clusterModuleInfo,
+		Hostname:             hostname,
+		KubeVIPManifest:      kubeVIPManifest,
+		Timeouts:             r.ProvisioningTimeouts.Resolve(vsphereVM.Spec.Timeouts),
+		OvercommitPolicy:     overcommitPolicy,
 		Session:              authSession,
 		Logger:               r.Logger.WithName(req.Namespace).WithName(req.Name),
 		PatchHelper:          patchHelper,
@@ -212,8 +316,21 @@ func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Res
 			conditions.WithConditions(
 				infrav1.VMProvisionedCondition,
 				infrav1.VCenterAvailableCondition,
+				infrav1.IPAddressClaimedCondition,
+				infrav1.IPAddressUniqueCondition,
+				infrav1.EncryptionReadyCondition,
 			),
 		)
+		v1beta2conditions.Mirror(vmContext.VSphereVM)
+
+		// Coalesce the patches that only update the network or storage status.
+		if batched, wait := r.statusBatcher.deferPatch(req.NamespacedName, patchBase, vmContext.VSphereVM); batched {
+			vmContext.Logger.V(4).Info("Batching status patch", "requeueAfter", wait)
+			if result.RequeueAfter == 0 || result.RequeueAfter > wait {
+				result.RequeueAfter = wait
+			}
+			return
+		}
 
 		// Patch the VSphereVM resource.
 		if err := vmContext.Patch(); err != nil {
@@ -231,6 +348,10 @@ func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Res
 				vsphereVM.Namespace, vsphereVM.Name)
 			return reconcile.Result{}, nil
 		}
+	} else if isPaused(nil, vsphereVM) {
+		// The VSphereVMs of a warm pool do not belong to a Cluster.
+		r.Logger.V(4).Info("VSphereVM is paused", "vm", req.NamespacedName)
+		return reconcile.Result{}, nil
 	}
 
 	// Handle deleted machines
@@ -242,26 +363,163 @@ func (r vmReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (_ ctrl.Res
 	return r.reconcileNormal(vmContext)
 }
 
+// fetchFailureDomain returns the VSphereFailureDomain of the
+// VSphereDeploymentZone named after the failure domain.
+func (r vmReconciler) fetchFailureDomain(failureDomain string) (*infrav1.VSphereFailureDomain, error) {
+	vsphereDeploymentZone := &infrav1.VSphereDeploymentZone{}
+	if err := r.Client.Get(r, apitypes.NamespacedName{Name: failureDomain}, vsphereDeploymentZone); err != nil {
+		return nil, errors.Wrapf(err, "failed to find vsphere deployment zone %s", failureDomain)
+	}
+
+	vsphereFailureDomain := &infrav1.VSphereFailureDomain{}
+	if err := r.Client.Get(r, apitypes.NamespacedName{Name: vsphereDeploymentZone.Spec.FailureDomain}, vsphereFailureDomain); err != nil {
+		return nil, errors.Wrapf(err, "failed to find vsphere failure domain %s", vsphereDeploymentZone.Spec.FailureDomain)
+	}
+	return vsphereFailureDomain, nil
+}
+
+// fetchKubeVIPManifest returns the static pod manifest of kube-vip of a
+// control plane Machine, or nil if the control plane endpoint of its cluster
+// is not advertised by kube-vip.
+func (r vmReconciler) fetchKubeVIPManifest(machine *clusterv1.Machine) ([]byte, error) {
+	cluster, err := clusterutilv1.GetClusterFromMetadata(r, r.Client, machine.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.Spec.InfrastructureRef == nil {
+		return nil, nil
+	}
+
+	vsphereCluster := &infrav1.VSphereCluster{}
+	key := apitypes.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+	if err := r.Client.Get(r, key, vsphereCluster); err != nil {
+		return nil, errors.Wrapf(err, "failed to get VSphereCluster %s", key)
+	}
+
+	provider := vsphereCluster.Spec.ControlPlaneEndpointProvider
+	if provider == nil || provider.KubeVIP == nil {
+		return nil, nil
+	}
+	if vsphereCluster.Status.ControlPlaneEndpoint == nil {
+		return nil, errors.Errorf("the control plane endpoint of VSphereCluster %s is not provisioned yet", key)
+	}
+	if failover := vsphereCluster.Status.ControlPlaneEndpointFailover; failover != nil {
+		return kubevip.FailoverManifest(*provider.KubeVIP, vsphereCluster.Status.ControlPlaneEndpoint.Port, failover.Addresses)
+	}
+	return kubevip.Manifest(*provider.KubeVIP, *vsphereCluster.Status.ControlPlaneEndpoint)
+}
+
+// fetchOvercommitPolicy returns the overcommit policy of the VSphereCluster of
+// a VSphereVM, or nil if the VSphereVM is not part of a cluster.
+func (r vmReconciler) fetchOvercommitPolicy(vsphereVM *infrav1.VSphereVM) (*infrav1.OvercommitPolicy, error) {
+	if _, ok := vsphereVM.Labels[clusterv1.ClusterLabelName]; !ok {
+		return nil, nil
+	}
+	cluster, err := clusterutilv1.GetClusterFromMetadata(r, r.Client, vsphereVM.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.Spec.InfrastructureRef == nil {
+		return nil, nil
+	}
+
+	vsphereCluster := &infrav1.VSphereCluster{}
+	key := apitypes.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+	if err := r.Client.Get(r, key, vsphereCluster); err != nil {
+		return nil, errors.Wrapf(err, "failed to get VSphereCluster %s", key)
+	}
+	return vsphereCluster.Spec.OvercommitPolicy, nil
+}
+
+// fetchClusterModuleInfo returns the UUID of the cluster module of the object
+// owning the Machine, or nil if the owning object has no cluster module.
+func (r vmReconciler) fetchClusterModuleInfo(machine *clusterv1.Machine) (*string, error) {
+	cluster, err := clusterutilv1.GetClusterFromMetadata(r, r.Client, machine.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.Spec.InfrastructureRef == nil {
+		return nil, nil
+	}
+
+	vsphereCluster := &infrav1.VSphereCluster{}
+	key := apitypes.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+	if err := r.Client.Get(r, key, vsphereCluster); err != nil {
+		return nil, errors.Wrapf(err, "failed to get VSphereCluster %s", key)
+	}
+
+	isControlPlane := clusterutilv1.IsControlPlaneMachine(machine)
+	ownerName := machine.Labels[clusterv1.MachineDeploymentLabelName]
+	if isControlPlane {
+		ownerName = ""
+		for _, ref := range machine.OwnerReferences {
+			if ref.Kind == "KubeadmControlPlane" {
+				ownerName = ref.Name
+				break
+			}
+		}
+	}
+	if ownerName == "" {
+		return nil, nil
+	}
+
+	for _, mod := range vsphereCluster.Spec.ClusterModules {
+		if mod.ControlPlane == isControlPlane && mod.TargetObjectName == ownerName {
+			moduleUUID := mod.ModuleUUID
+			return &moduleUUID, nil
+		}
+	}
+	return nil, nil
+}
+
 func (r vmReconciler) reconcileDelete(ctx *context.VMContext) (reconcile.Result, error) {
 	ctx.Logger.Info("Handling deleted VSphereVM")
 
+	// Do not destroy the VM while the VSphereVM is protected.
+	if _, ok := ctx.VSphereVM.Annotations[infrav1.AnnotationDeleteProtection]; ok {
+		ctx.Logger.Info("VSphereVM is protected from deletion, remove the annotation to proceed", "annotation", infrav1.AnnotationDeleteProtection)
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.DeletionProtectedReason, clusterv1.ConditionSeverityWarning,
+			"deletion is blocked by the %s annotation", infrav1.AnnotationDeleteProtection)
+		return reconcile.Result{}, nil
+	}
+
+	// Invoke the PreDelete hooks only once, before the destruction of the VM
+	// starts, and record that they succeeded.
+	if _, ok := ctx.VSphereVM.Annotations[infrav1.VMPreDeleteHooksCompletedAnnotation]; !ok && ctx.LifecycleHooks != nil {
+		if err := ctx.LifecycleHooks.Run(ctx, hooks.NewRequest(hooks.PreDelete, ctx.VSphereVM)); err != nil {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.LifecycleHookFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return reconcile.Result{}, err
+		}
+		if ctx.VSphereVM.Annotations == nil {
+			ctx.VSphereVM.Annotations = map[string]string{}
+		}
+		ctx.VSphereVM.Annotations[infrav1.VMPreDeleteHooksCompletedAnnotation] = ""
+	}
+
 	// TODO(akutz) Implement selection of VM service based on vSphere version
 	var vmService services.VirtualMachineService = &govmomi.VMService{}
 
 	conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
 	vm, err := vmService.DestroyVM(ctx)
 	if err != nil {
-		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, "DeletionFailed", clusterv1.ConditionSeverityWarning, err.Error())
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		return reconcile.Result{}, errors.Wrapf(err, "failed to destroy VM")
 	}
 
 	// Requeue the operation until the VM is "notfound".
 	if vm.State != infrav1.VirtualMachineStateNotFound {
 		ctx.Logger.Info("vm state is not reconciled", "expected-vm-state", infrav1.VirtualMachineStateNotFound, "actual-vm-state", vm.State)
-		return reconcile.Result{}, nil
+		return reconcile.Result{RequeueAfter: vmRequeueAfter(ctx, time.Now())}, nil
+	}
+
+	// Release the addresses claimed from IPAM pools.
+	if err := ipam.ReleaseClaims(ctx, ctx.Client, ctx.VSphereVM); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to release IP addresses")
 	}
 
-	// The VM is deleted so remove the finalizer.
+	// The VM is deleted so stop waiting on it in the background, and remove
+	// the finalizer.
+	ctx.CancelWaitsFor(ctx.VSphereVM.UID)
 	ctrlutil.RemoveFinalizer(ctx.VSphereVM, infrav1.VMFinalizer)
 
 	return reconcile.Result{}, nil
@@ -278,7 +536,16 @@ func (r vmReconciler) reconcileNormal(ctx *context.VMContext) (reconcile.Result,
 	// TODO(akutz) Implement selection of VM service based on vSphere version
 	var vmService services.VirtualMachineService = &govmomi.VMService{}
 
-	if r.isWaitingForStaticIPAllocation(ctx) {
+	// The VMs of a warm pool get their addresses once they are claimed.
+	_, isWarmPoolVM := ctx.VSphereVM.Labels[infrav1.WarmPoolLabel]
+
+	if !isWarmPoolVM {
+		if ok, err := r.reconcileIPAddressClaims(ctx); err != nil || !ok {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if !isWarmPoolVM && r.isWaitingForStaticIPAllocation(ctx) {
 		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.WaitingForStaticIPAllocationReason, clusterv1.ConditionSeverityInfo, "")
 		ctx.Logger.Info("vm is waiting for static ip to be available")
 		return reconcile.Result{}, nil
@@ -287,6 +554,12 @@ func (r vmReconciler) reconcileNormal(ctx *context.VMContext) (reconcile.Result,
 	// Get or create the VM.
 	vm, err := vmService.ReconcileVM(ctx)
 	if err != nil {
+		// A terminal error marks the VSphereVM as failed, which is not
+		// reconciled any further.
+		if reason, message, ok := capverrors.Failure(err); ok {
+			ctx.VSphereVM.Status.FailureReason = &reason
+			ctx.VSphereVM.Status.FailureMessage = &message
+		}
 		return reconcile.Result{}, errors.Wrapf(err, "failed to reconcile VM")
 	}
 
@@ -296,7 +569,7 @@ func (r vmReconciler) reconcileNormal(ctx *context.VMContext) (reconcile.Result,
 			"VM state is not reconciled",
 			"expected-vm-state", infrav1.VirtualMachineStateReady,
 			"actual-vm-state", vm.State)
-		return reconcile.Result{}, nil
+		return reconcile.Result{RequeueAfter: vmRequeueAfter(ctx, time.Now())}, nil
 	}
 
 	// Update the VSphereVM's BIOS UUID.
@@ -309,30 +582,86 @@ func (r vmReconciler) reconcileNormal(ctx *context.VMContext) (reconcile.Result,
 		return reconcile.Result{}, errors.Errorf("bios uuid is empty while VM is ready")
 	}
 
+	// The VMs of a warm pool are ready to be claimed once they are cloned.
+	if isWarmPoolVM {
+		ctx.VSphereVM.Status.Ready = true
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.VMProvisionedCondition)
+		return reconcile.Result{}, nil
+	}
+
 	// Update the VSphereVM's network status.
-	r.reconcileNetwork(ctx, vm)
+	if err := r.reconcileNetwork(ctx, vm); err != nil {
+		return reconcile.Result{}, err
+	}
 
 	// we didn't get any addresses, requeue
 	if len(ctx.VSphereVM.Status.Addresses) == 0 {
+		if !ctx.VSphereVM.Status.Ready {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.WaitingForNetworkAddressesReason, clusterv1.ConditionSeverityInfo, "")
+		}
 		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
 	}
 
+	// Invoke the PostPowerOn hooks once, before the VM is first reported ready.
+	if !ctx.VSphereVM.Status.Ready {
+		if err := ctx.LifecycleHooks.Run(ctx, hooks.NewRequest(hooks.PostPowerOn, ctx.VSphereVM)); err != nil {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.LifecycleHookFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return reconcile.Result{}, err
+		}
+	}
+
 	// Once the network is online the VM is considered ready.
 	ctx.VSphereVM.Status.Ready = true
 	conditions.MarkTrue(ctx.VSphereVM, infrav1.VMProvisionedCondition)
 	ctx.Logger.Info("VSphereVM is ready")
 
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: driftCheckRequeueAfter(ctx, time.Now())}, nil
+}
+
+// reconcileIPAddressClaims claims the addresses of the network devices that
+// reference IPAM pools and stores them in the context. It returns false while
+// any of the claims is not yet bound to an address.
+func (r vmReconciler) reconcileIPAddressClaims(ctx *context.VMContext) (bool, error) {
+	if !ipam.HasPools(ctx.VSphereVM) {
+		return true, nil
+	}
+
+	state, bound, err := ipam.ReconcileClaims(ctx, ctx.Client, ctx.VSphereVM)
+	if err != nil {
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.IPAddressClaimedCondition, infrav1.IPAddressClaimFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return false, err
+	}
+	if !bound {
+		// The pools are not required to exist when the machines are created,
+		// e.g. by clusterctl move, so a missing pool is reported here.
+		missing, err := ipam.MissingPools(ctx, ctx.Client, ctx.VSphereVM)
+		if err != nil {
+			return false, err
+		}
+		if len(missing) > 0 {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.IPAddressClaimedCondition, infrav1.IPAddressPoolNotFoundReason, clusterv1.ConditionSeverityWarning,
+				"IPAM pools not found: %s", strings.Join(missing, ", "))
+			ctx.Logger.Info("vm is waiting for its ipam pools to be created", "pools", missing)
+			return false, nil
+		}
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.IPAddressClaimedCondition, infrav1.WaitingForIPAddressReason, clusterv1.ConditionSeverityInfo, "")
+		ctx.Logger.Info("vm is waiting for ip addresses to be claimed from ipam pools")
+		return false, nil
+	}
+
+	ctx.IPAMState = state
+	conditions.MarkTrue(ctx.VSphereVM, infrav1.IPAddressClaimedCondition)
+	return true, nil
 }
 
 // isWaitingForStaticIPAllocation checks whether the VM should wait for a static IP
 // to be allocated.
-// It checks the state of both DHCP4 and DHCP6 for all the network devices and if
-// any static IP addresses are specified.
+// It checks the state of DHCP4, DHCP6 and SLAAC for all the network devices and if
+// any static IP addresses are specified, either directly or from IPAM pools.
 func (r vmReconciler) isWaitingForStaticIPAllocation(ctx *context.VMContext) bool {
 	devices := ctx.VSphereVM.Spec.Network.Devices
-	for _, dev := range devices {
-		if !dev.DHCP4 && !dev.DHCP6 && len(dev.IPAddrs) == 0 {
+	for i, dev := range devices {
+		if !dev.DHCP4 && !dev.DHCP6 && !dev.SLAAC && len(dev.IPAddrs) == 0 && len(ctx.IPAMState[i]) == 0 {
 			// Static IP is not available yet
 			return true
 		}
@@ -341,13 +670,16 @@ func (r vmReconciler) isWaitingForStaticIPAllocation(ctx *context.VMContext) boo
 	return false
 }
 
-func (r vmReconciler) reconcileNetwork(ctx *context.VMContext, vm infrav1.VirtualMachine) {
+// reconcileNetwork reports the network status of the VM, and the addresses of
+// the VM published according to its network spec.
+func (r vmReconciler) reconcileNetwork(ctx *context.VMContext, vm infrav1.VirtualMachine) error {
 	ctx.VSphereVM.Status.Network = vm.Network
-	ipAddrs := make([]string, 0, len(vm.Network))
-	for _, netStatus := range ctx.VSphereVM.Status.Network {
-		ipAddrs = append(ipAddrs, netStatus.IPAddrs...)
+	ipAddrs, err := util.GetPublishedIPAddresses(ctx.VSphereVM.Spec.Network, vm.Network)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the published addresses of VSphereVM %s", ctx.VSphereVM.Name)
 	}
 	ctx.VSphereVM.Status.Addresses = ipAddrs
+	return nil
 }
 
 func (r *vmReconciler) clusterToVSphereVMs(a ctrlclient.Object) []reconcile.Request {
@@ -382,9 +714,15 @@ func (r *vmReconciler) retrieveVcenterSession(ctx goctx.Context, vsphereVM *infr
 		WithUserInfo(r.ControllerContext.Username, r.ControllerContext.Password).
 		WithThumbprint(vsphereVM.Spec.Thumbprint).
 		WithFeatures(session.Feature{
-			EnableKeepAlive:   r.EnableKeepAlive,
-			KeepAliveDuration: r.KeepAliveDuration,
+			EnableKeepAlive:       r.EnableKeepAlive,
+			KeepAliveDuration:     r.KeepAliveDuration,
+			MaxConcurrentRequests: r.MaxConcurrentVCenterRequests,
 		})
+	caBundle, err := identity.GetCABundle(ctx, r.Client, vsphereVM.Namespace, vsphereVM.Spec.CABundleRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the CA bundle of the vCenter")
+	}
+	params = params.WithCABundle(caBundle)
 	cluster, err := clusterutilv1.GetClusterFromMetadata(r.ControllerContext, r.Client, vsphereVM.ObjectMeta)
 	if err != nil {
 		r.Logger.Info("VsphereVM is missing cluster label or cluster does not exist")
@@ -418,3 +756,77 @@ func (r *vmReconciler) retrieveVcenterSession(ctx goctx.Context, vsphereVM *infr
 	return session.GetOrCreate(r.Context,
 		params)
 }
+
+const (
+	// minTaskRequeueAfter and maxTaskRequeueAfter bound the delay before a
+	// VSphereVM with an in-flight vCenter task is reconciled again.
+	minTaskRequeueAfter = 5 * time.Second
+	maxTaskRequeueAfter = time.Minute
+
+	// queuedTaskRequeueAfter is the delay used while a task has not reported
+	// any progress yet.
+	queuedTaskRequeueAfter = 15 * time.Second
+)
+
+// vmRequeueAfter returns the delay after which a VSphereVM whose VM is not
+// reconciled yet is reconciled again: when its task is expected to complete,
+// or after throttle.RequeueAfter when it waits for a slot of its vCenter.
+func vmRequeueAfter(ctx *context.VMContext, now time.Time) time.Duration {
+	if ctx.VSphereVM.Status.TaskRef == "" && ctx.TaskThrottle.Waiting(ctx.VSphereVM.Spec.Server, string(ctx.VSphereVM.UID)) {
+		return throttle.RequeueAfter
+	}
+	return taskRequeueAfter(ctx.VSphereVM.Status, now)
+}
+
+// driftCheckRequeueAfter returns the delay after which the drift of the VM of
+// a ready VSphereVM is checked again, or zero when the drift is not checked.
+func driftCheckRequeueAfter(ctx *context.VMContext, now time.Time) time.Duration {
+	interval := ctx.DriftCheckInterval
+	if interval <= 0 {
+		return 0
+	}
+	drift := ctx.VSphereVM.Status.Drift
+	if drift == nil {
+		return interval
+	}
+	if after := drift.LastCheckTime.Add(interval).Sub(now); after > 0 {
+		return after
+	}
+	return minTaskRequeueAfter
+}
+
+// taskRequeueAfter returns when a VSphereVM waiting on a vCenter task should
+// be reconciled again. The remaining time of a running task is estimated from
+// its progress, while a failed task is retried once its RetryAfter time has
+// passed. A zero duration is returned when no task is tracked.
+func taskRequeueAfter(status infrav1.VSphereVMStatus, now time.Time) time.Duration {
+	task := status.Task
+	if task == nil {
+		return 0
+	}
+
+	var after time.Duration
+	switch task.State {
+	case string(types.TaskInfoStateError):
+		if status.RetryAfter.IsZero() {
+			return 0
+		}
+		after = status.RetryAfter.Sub(now)
+	case string(types.TaskInfoStateRunning):
+		if task.Progress <= 0 || task.Progress >= 100 || task.StartTime == nil {
+			return queuedTaskRequeueAfter
+		}
+		elapsed := now.Sub(task.StartTime.Time)
+		after = elapsed * time.Duration(100-task.Progress) / time.Duration(task.Progress)
+	default:
+		return queuedTaskRequeueAfter
+	}
+
+	if after < minTaskRequeueAfter {
+		return minTaskRequeueAfter
+	}
+	if after > maxTaskRequeueAfter {
+		return maxTaskRequeueAfter
+	}
+	return after
+}
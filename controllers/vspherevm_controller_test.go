@@ -18,8 +18,13 @@ package controllers
 
 import (
 	goctx "context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr"
 	. "github.com/onsi/gomega"
 	"github.com/vmware/govmomi/simulator"
 	corev1 "k8s.io/api/core/v1"
@@ -34,8 +39,11 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/hooks"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
 	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
 )
 
@@ -190,7 +198,7 @@ func TestVmReconciler_WaitingForStaticIPAllocation(t *testing.T) {
 
 	controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
 	vmContext := fake.NewVMContext(controllerCtx)
-	r := vmReconciler{controllerCtx}
+	r := vmReconciler{ControllerContext: controllerCtx}
 
 	for _, tt := range tests {
 		// Need to explicitly reinitialize test variable, looks odd, but needed
@@ -320,3 +328,126 @@ func TestRetrievingVCenterCredentialsFromCluster(t *testing.T) {
 	vCenterCondition := conditions.Get(vm, infrav1.VCenterAvailableCondition)
 	g.Expect(vCenterCondition.Status).To(Equal(corev1.ConditionTrue))
 }
+
+func TestVmReconciler_ReconcileDeleteWithDeleteProtection(t *testing.T) {
+	g := NewWithT(t)
+
+	controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+	vmContext := fake.NewVMContext(controllerCtx)
+	vmContext.VSphereVM.Annotations = map[string]string{infrav1.AnnotationDeleteProtection: ""}
+	vmContext.VSphereVM.Finalizers = []string{infrav1.VMFinalizer}
+	r := vmReconciler{ControllerContext: controllerCtx}
+
+	_, err := r.reconcileDelete(vmContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vmContext.VSphereVM.Finalizers).To(ContainElement(infrav1.VMFinalizer))
+
+	vmProvisionCondition := conditions.Get(vmContext.VSphereVM, infrav1.VMProvisionedCondition)
+	g.Expect(vmProvisionCondition).NotTo(BeNil())
+	g.Expect(vmProvisionCondition.Status).To(Equal(corev1.ConditionFalse))
+	g.Expect(vmProvisionCondition.Reason).To(Equal(infrav1.DeletionProtectedReason))
+}
+
+func TestVmReconciler_ReconcileDeleteRunsPreDeleteHooksOnce(t *testing.T) {
+	g := NewWithT(t)
+
+	var invocations int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&invocations, 1)
+	}))
+	defer server.Close()
+	runner, err := hooks.NewRunner(&hooks.Config{Hooks: []hooks.HookConfig{
+		{Name: "cmdb", Points: []hooks.Point{hooks.PreDelete}, URL: server.URL},
+	}}, logr.Discard())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+	controllerCtx.LifecycleHooks = runner
+	vmContext := fake.NewVMContext(controllerCtx)
+	vmContext.VSphereVM.Annotations = map[string]string{infrav1.AnnotationDeleteProtection: ""}
+	vmContext.Session, err = session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	r := vmReconciler{ControllerContext: controllerCtx}
+
+	// The hooks are not invoked while the VSphereVM is protected.
+	_, err = r.reconcileDelete(vmContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&invocations)).To(BeZero())
+
+	// The hooks which succeeded are recorded, and not invoked again when the
+	// destruction of the VM is retried.
+	delete(vmContext.VSphereVM.Annotations, infrav1.AnnotationDeleteProtection)
+	_, err = r.reconcileDelete(vmContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vmContext.VSphereVM.Annotations).To(HaveKey(infrav1.VMPreDeleteHooksCompletedAnnotation))
+	conditions.MarkFalse(vmContext.VSphereVM, infrav1.VMProvisionedCondition, clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, "failed to destroy VM")
+	_, err = r.reconcileDelete(vmContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&invocations)).To(Equal(int32(1)))
+}
+
+func TestTaskRequeueAfter(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+	started := metav1.NewTime(now.Add(-40 * time.Second))
+
+	g.Expect(taskRequeueAfter(infrav1.VSphereVMStatus{}, now)).To(BeZero())
+
+	queued := infrav1.VSphereVMStatus{Task: &infrav1.VirtualMachineTaskStatus{State: "queued"}}
+	g.Expect(taskRequeueAfter(queued, now)).To(Equal(queuedTaskRequeueAfter))
+
+	// 40s for 50% of the task leaves about 40s.
+	running := infrav1.VSphereVMStatus{Task: &infrav1.VirtualMachineTaskStatus{State: "running", Progress: 50, StartTime: &started}}
+	g.Expect(taskRequeueAfter(running, now)).To(Equal(40 * time.Second))
+
+	running.Task.Progress = 99
+	g.Expect(taskRequeueAfter(running, now)).To(Equal(minTaskRequeueAfter))
+
+	running.Task.Progress = 1
+	g.Expect(taskRequeueAfter(running, now)).To(Equal(maxTaskRequeueAfter))
+
+	failed := infrav1.VSphereVMStatus{
+		Task:       &infrav1.VirtualMachineTaskStatus{State: "error"},
+		RetryAfter: metav1.NewTime(now.Add(30 * time.Second)),
+	}
+	g.Expect(taskRequeueAfter(failed, now)).To(Equal(30 * time.Second))
+}
+
+func TestVMRequeueAfter(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	g.Expect(vmRequeueAfter(vmContext, now)).To(BeZero())
+
+	vmContext.TaskThrottle = throttle.New(throttle.Limits{throttle.Clone: 1})
+	g.Expect(vmContext.TaskThrottle.Acquire(vmContext.VSphereVM.Spec.Server, throttle.Clone, "ns/other", "other")).To(BeTrue())
+	g.Expect(vmContext.TaskThrottle.Acquire(vmContext.VSphereVM.Spec.Server, throttle.Clone, "ns/cluster", string(vmContext.VSphereVM.UID))).To(BeFalse())
+	g.Expect(vmRequeueAfter(vmContext, now)).To(Equal(throttle.RequeueAfter))
+}
+
+func TestDriftCheckRequeueAfter(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	g.Expect(driftCheckRequeueAfter(vmContext, now)).To(BeZero())
+
+	vmContext.DriftCheckInterval = time.Hour
+	g.Expect(driftCheckRequeueAfter(vmContext, now)).To(Equal(time.Hour))
+
+	vmContext.VSphereVM.Status.Drift = &infrav1.VirtualMachineDriftStatus{LastCheckTime: metav1.NewTime(now.Add(-20 * time.Minute))}
+	g.Expect(driftCheckRequeueAfter(vmContext, now)).To(Equal(40 * time.Minute))
+
+	vmContext.VSphereVM.Status.Drift.LastCheckTime = metav1.NewTime(now.Add(-2 * time.Hour))
+	g.Expect(driftCheckRequeueAfter(vmContext, now)).To(Equal(minTaskRequeueAfter))
+}
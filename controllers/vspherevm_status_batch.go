@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// vmStatusBatcher coalesces the status patches of VSphereVMs that only update
+// their network or storage status, so VSphereVMs reporting new addresses at the
+// same time, e.g. after a vCenter reconnect, are patched at most once per batch
+// interval. Any other change to a VSphereVM is patched right away.
+type vmStatusBatcher struct {
+	mu        sync.Mutex
+	interval  time.Duration
+	lastPatch map[types.NamespacedName]time.Time
+}
+
+func newVMStatusBatcher(interval time.Duration) *vmStatusBatcher {
+	if interval <= 0 {
+		return nil
+	}
+	return &vmStatusBatcher{
+		interval:  interval,
+		lastPatch: map[types.NamespacedName]time.Time{},
+	}
+}
+
+// deferPatch returns true and the time left until the end of the batch interval if
+// the patch of the given VSphereVM should be skipped because only its network
+// or storage status changed since it was read and it was patched less than the batch
+// interval ago. Otherwise it records a patch of the VSphereVM, if anything
+// changed, and returns false.
+func (b *vmStatusBatcher) deferPatch(key types.NamespacedName, before, after *infrav1.VSphereVM) (bool, time.Duration) {
+	if b == nil || apiequality.Semantic.DeepEqual(before, after) {
+		return false, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := b.lastPatch[key]; ok && onlyObservedStatusChanged(before, after) {
+		if wait := b.interval - now.Sub(last); wait > 0 {
+			return true, wait
+		}
+	}
+	b.lastPatch[key] = now
+	return false, 0
+}
+
+// forget drops the patch history of the given VSphereVM.
+func (b *vmStatusBatcher) forget(key types.NamespacedName) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.lastPatch, key)
+}
+
+// onlyObservedStatusChanged returns true if the two VSphereVMs differ only in
+// their network status, addresses and storage consumption.
+func onlyObservedStatusChanged(before, after *infrav1.VSphereVM) bool {
+	before, after = before.DeepCopy(), after.DeepCopy()
+	before.Status.Network, after.Status.Network = nil, nil
+	before.Status.Addresses, after.Status.Addresses = nil, nil
+	before.Status.Storage, after.Status.Storage = nil, nil
+	return apiequality.Semantic.DeepEqual(before, after)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestVMStatusBatcher(t *testing.T) {
+	g := NewWithT(t)
+
+	key := types.NamespacedName{Namespace: "ns", Name: "vm"}
+	vm := &infrav1.VSphereVM{Status: infrav1.VSphereVMStatus{Ready: true, Addresses: []string{"192.168.1.10"}}}
+	withAddresses := func(addrs ...string) *infrav1.VSphereVM {
+		updated := vm.DeepCopy()
+		updated.Status.Addresses = addrs
+		updated.Status.Network = []infrav1.NetworkStatus{{IPAddrs: addrs}}
+		return updated
+	}
+
+	t.Run("a nil batcher never defers patches", func(t *testing.T) {
+		var batcher *vmStatusBatcher
+		g.Expect(newVMStatusBatcher(0)).To(BeNil())
+		batched, _ := batcher.deferPatch(key, vm, withAddresses("192.168.1.11"))
+		g.Expect(batched).To(BeFalse())
+	})
+
+	t.Run("network status patches are coalesced", func(t *testing.T) {
+		batcher := newVMStatusBatcher(time.Minute)
+
+		// The first patch is never deferred.
+		batched, _ := batcher.deferPatch(key, vm, withAddresses("192.168.1.11"))
+		g.Expect(batched).To(BeFalse())
+
+		batched, wait := batcher.deferPatch(key, vm, withAddresses("192.168.1.12"))
+		g.Expect(batched).To(BeTrue())
+		g.Expect(wait).To(BeNumerically(">", 0))
+		g.Expect(wait).To(BeNumerically("<=", time.Minute))
+
+		// So are the patches that only update the storage consumption.
+		withStorage := vm.DeepCopy()
+		withStorage.Status.Storage = &infrav1.VirtualMachineStorageStatus{CommittedBytes: 1 << 30}
+		batched, _ = batcher.deferPatch(key, vm, withStorage)
+		g.Expect(batched).To(BeTrue())
+
+		// Nothing changed, so nothing is patched or deferred.
+		batched, _ = batcher.deferPatch(key, vm, vm.DeepCopy())
+		g.Expect(batched).To(BeFalse())
+
+		// Other changes are patched right away.
+		notReady := withAddresses("192.168.1.12")
+		notReady.Status.Ready = false
+		batched, _ = batcher.deferPatch(key, vm, notReady)
+		g.Expect(batched).To(BeFalse())
+
+		batcher.forget(key)
+		batched, _ = batcher.deferPatch(key, vm, withAddresses("192.168.1.13"))
+		g.Expect(batched).To(BeFalse())
+	})
+
+	t.Run("patches are allowed once the batch interval elapsed", func(t *testing.T) {
+		batcher := newVMStatusBatcher(time.Minute)
+		batcher.lastPatch[key] = time.Now().Add(-2 * time.Minute)
+		batched, _ := batcher.deferPatch(key, vm, withAddresses("192.168.1.11"))
+		g.Expect(batched).To(BeFalse())
+	})
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// vmStorageListTimeout is the timeout of the listing of the VSphereVMs on a
+// scrape.
+const vmStorageListTimeout = 10 * time.Second
+
+var (
+	vmStorageLabels        = []string{"namespace", "name", "cluster", "server", "datastore"}
+	datastoreStorageLabels = []string{"server", "datastore"}
+
+	vmStorageCommittedDesc = prometheus.NewDesc(
+		"capv_vm_storage_committed_bytes",
+		"Storage space used by the files of a VSphereVM on a datastore.",
+		vmStorageLabels,
+		nil,
+	)
+	vmStorageProvisionedDesc = prometheus.NewDesc(
+		"capv_vm_storage_provisioned_bytes",
+		"Storage space the files of a VSphereVM may use on a datastore once their thin-provisioned disks are fully grown.",
+		vmStorageLabels,
+		nil,
+	)
+	datastoreManagedCommittedDesc = prometheus.NewDesc(
+		"capv_datastore_managed_committed_bytes",
+		"Storage space used on a datastore by the VSphereVMs.",
+		datastoreStorageLabels,
+		nil,
+	)
+	datastoreManagedProvisionedDesc = prometheus.NewDesc(
+		"capv_datastore_managed_provisioned_bytes",
+		"Storage space the VSphereVMs may use on a datastore once their thin-provisioned disks are fully grown.",
+		datastoreStorageLabels,
+		nil,
+	)
+	datastoreCapacityDesc = prometheus.NewDesc(
+		"capv_datastore_capacity_bytes",
+		"Capacity of a datastore used by VSphereVMs.",
+		datastoreStorageLabels,
+		nil,
+	)
+	datastoreProvisionedDesc = prometheus.NewDesc(
+		"capv_datastore_provisioned_bytes",
+		"Storage space all the VMs on a datastore used by VSphereVMs may use once their thin-provisioned disks are fully grown. The datastore is overcommitted when it exceeds its capacity.",
+		datastoreStorageLabels,
+		nil,
+	)
+)
+
+// vmStorageCollector reports the storage consumption of the VSphereVMs, as
+// recorded in their status, when the metrics are scraped, from the cache of
+// the manager.
+type vmStorageCollector struct {
+	client ctrlclient.Reader
+	logger logr.Logger
+}
+
+// Describe implements prometheus.Collector.
+func (c *vmStorageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- vmStorageCommittedDesc
+	ch <- vmStorageProvisionedDesc
+	ch <- datastoreManagedCommittedDesc
+	ch <- datastoreManagedProvisionedDesc
+	ch <- datastoreCapacityDesc
+	ch <- datastoreProvisionedDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *vmStorageCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := goctx.WithTimeout(goctx.Background(), vmStorageListTimeout)
+	defer cancel()
+
+	vms := &infrav1.VSphereVMList{}
+	if err := c.client.List(ctx, vms); err != nil {
+		c.logger.Error(err, "failed to list the VSphereVMs for the metrics")
+		return
+	}
+
+	datastores := map[[2]string]*infrav1.VirtualMachineDatastoreUsage{}
+	for i := range vms.Items {
+		vm := &vms.Items[i]
+		if vm.Status.Storage == nil {
+			continue
+		}
+		for _, usage := range vm.Status.Storage.Datastores {
+			labels := []string{vm.Namespace, vm.Name, vm.Labels[clusterv1.ClusterLabelName], vm.Spec.Server, usage.Name}
+			ch <- prometheus.MustNewConstMetric(vmStorageCommittedDesc, prometheus.GaugeValue, float64(usage.CommittedBytes), labels...)
+			ch <- prometheus.MustNewConstMetric(vmStorageProvisionedDesc, prometheus.GaugeValue, float64(usage.ProvisionedBytes), labels...)
+
+			key := [2]string{vm.Spec.Server, usage.Name}
+			datastore, ok := datastores[key]
+			if !ok {
+				datastore = &infrav1.VirtualMachineDatastoreUsage{Name: usage.Name}
+				datastores[key] = datastore
+			}
+			datastore.CommittedBytes += usage.CommittedBytes
+			datastore.ProvisionedBytes += usage.ProvisionedBytes
+			// The VSphereVMs observed the datastore at different times, so
+			// report the largest values they observed.
+			if usage.CapacityBytes > datastore.CapacityBytes {
+				datastore.CapacityBytes = usage.CapacityBytes
+			}
+			if usage.DatastoreProvisionedBytes > datastore.DatastoreProvisionedBytes {
+				datastore.DatastoreProvisionedBytes = usage.DatastoreProvisionedBytes
+			}
+		}
+	}
+
+	for key, datastore := range datastores {
+		ch <- prometheus.MustNewConstMetric(datastoreManagedCommittedDesc, prometheus.GaugeValue, float64(datastore.CommittedBytes), key[0], key[1])
+		ch <- prometheus.MustNewConstMetric(datastoreManagedProvisionedDesc, prometheus.GaugeValue, float64(datastore.ProvisionedBytes), key[0], key[1])
+		ch <- prometheus.MustNewConstMetric(datastoreCapacityDesc, prometheus.GaugeValue, float64(datastore.CapacityBytes), key[0], key[1])
+		ch <- prometheus.MustNewConstMetric(datastoreProvisionedDesc, prometheus.GaugeValue, float64(datastore.DatastoreProvisionedBytes), key[0], key[1])
+	}
+}
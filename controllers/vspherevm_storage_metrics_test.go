@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestVMStorageCollector(t *testing.T) {
+	g := NewWithT(t)
+	vm := func(name string, usages ...infrav1.VirtualMachineDatastoreUsage) *infrav1.VSphereVM {
+		return &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      name,
+				Labels:    map[string]string{clusterv1.ClusterLabelName: "cluster"},
+			},
+			Spec: infrav1.VSphereVMSpec{
+				VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Server: "vcenter"},
+			},
+			Status: infrav1.VSphereVMStatus{Storage: &infrav1.VirtualMachineStorageStatus{Datastores: usages}},
+		}
+	}
+	mgmtContext := fake.NewControllerManagerContext(
+		vm("vm-0", infrav1.VirtualMachineDatastoreUsage{Name: "ds0", CommittedBytes: 10, ProvisionedBytes: 40, CapacityBytes: 100, DatastoreProvisionedBytes: 120}),
+		vm("vm-1", infrav1.VirtualMachineDatastoreUsage{Name: "ds0", CommittedBytes: 20, ProvisionedBytes: 40, CapacityBytes: 100, DatastoreProvisionedBytes: 130}),
+		&infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "vm-2"}},
+	)
+	c := &vmStorageCollector{client: mgmtContext.Client, logger: mgmtContext.Logger}
+
+	g.Expect(testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP capv_datastore_capacity_bytes Capacity of a datastore used by VSphereVMs.
+# TYPE capv_datastore_capacity_bytes gauge
+capv_datastore_capacity_bytes{datastore="ds0",server="vcenter"} 100
+# HELP capv_datastore_managed_committed_bytes Storage space used on a datastore by the VSphereVMs.
+# TYPE capv_datastore_managed_committed_bytes gauge
+capv_datastore_managed_committed_bytes{datastore="ds0",server="vcenter"} 30
+# HELP capv_datastore_managed_provisioned_bytes Storage space the VSphereVMs may use on a datastore once their thin-provisioned disks are fully grown.
+# TYPE capv_datastore_managed_provisioned_bytes gauge
+capv_datastore_managed_provisioned_bytes{datastore="ds0",server="vcenter"} 80
+# HELP capv_datastore_provisioned_bytes Storage space all the VMs on a datastore used by VSphereVMs may use once their thin-provisioned disks are fully grown. The datastore is overcommitted when it exceeds its capacity.
+# TYPE capv_datastore_provisioned_bytes gauge
+capv_datastore_provisioned_bytes{datastore="ds0",server="vcenter"} 130
+# HELP capv_vm_storage_committed_bytes Storage space used by the files of a VSphereVM on a datastore.
+# TYPE capv_vm_storage_committed_bytes gauge
+capv_vm_storage_committed_bytes{cluster="cluster",datastore="ds0",name="vm-0",namespace="default",server="vcenter"} 10
+capv_vm_storage_committed_bytes{cluster="cluster",datastore="ds0",name="vm-1",namespace="default",server="vcenter"} 20
+# HELP capv_vm_storage_provisioned_bytes Storage space the files of a VSphereVM may use on a datastore once their thin-provisioned disks are fully grown.
+# TYPE capv_vm_storage_provisioned_bytes gauge
+capv_vm_storage_provisioned_bytes{cluster="cluster",datastore="ds0",name="vm-0",namespace="default",server="vcenter"} 40
+capv_vm_storage_provisioned_bytes{cluster="cluster",datastore="ds0",name="vm-1",namespace="default",server="vcenter"} 40
+`))).To(Succeed())
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"sort"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherevminventories,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherevminventories/status,verbs=get;update;patch
+
+// AddVSphereVMInventoryControllerToManager adds the controller that
+// periodically reports the VMs of each VSphereCluster in a VSphereVMInventory
+// to the provided manager.
+func AddVSphereVMInventoryControllerToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controllerNameShort = "vspherevminventory-controller"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+	r := vmInventoryReconciler{ControllerContext: controllerContext}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerNameShort).
+		// The inventory is refreshed at the end of every interval, so only
+		// the VSphereClusters are watched.
+		For(&infrav1.VSphereCluster{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+type vmInventoryReconciler struct {
+	*context.ControllerContext
+}
+
+// Reconcile collects the inventory of the VMs of a VSphereCluster and
+// stores it in the VSphereVMInventory of the same name.
+func (r vmInventoryReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
+	logger := r.Logger.WithValues("vspherecluster", req.NamespacedName)
+
+	vsphereCluster := &infrav1.VSphereCluster{}
+	if err := r.Client.Get(ctx, req.NamespacedName, vsphereCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// The inventory is garbage collected with its VSphereCluster.
+	if !vsphereCluster.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	cluster, err := clusterutilv1.GetOwnerCluster(ctx, r.Client, vsphereCluster.ObjectMeta)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if cluster == nil {
+		logger.V(4).Info("Waiting for Cluster Controller to set OwnerRef on VSphereCluster")
+		return reconcile.Result{}, nil
+	}
+	if annotations.IsPaused(cluster, vsphereCluster) {
+		logger.V(4).Info("VSphereCluster linked to a cluster that is paused")
+		return reconcile.Result{}, nil
+	}
+
+	entries, err := r.collectInventory(ctx, vsphereCluster, cluster.Name)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	inventory := &infrav1.VSphereVMInventory{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: vsphereCluster.Namespace,
+			Name:      vsphereCluster.Name,
+		},
+	}
+	_, err = ctrlutil.CreateOrPatch(ctx, r.Client, inventory, func() error {
+		if inventory.Labels == nil {
+			inventory.Labels = map[string]string{}
+		}
+		inventory.Labels[clusterv1.ClusterLabelName] = cluster.Name
+		inventory.Spec.ClusterName = cluster.Name
+		inventory.Status.VirtualMachines = entries
+		now := metav1.Now()
+		inventory.Status.LastUpdated = &now
+		return ctrlutil.SetControllerReference(vsphereCluster, inventory, r.Scheme)
+	})
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to update VSphereVMInventory %s/%s", inventory.Namespace, inventory.Name)
+	}
+
+	return reconcile.Result{RequeueAfter: r.VMInventoryInterval}, nil
+}
+
+// collectInventory returns the inventory entries of the VSphereVMs of the
+// cluster, sorted by name. The entries of the VMs that cannot be looked up in
+// vCenter only contain the details known to the VSphereVMs.
+func (r vmInventoryReconciler) collectInventory(ctx goctx.Context, vsphereCluster *infrav1.VSphereCluster, clusterName string) ([]infrav1.VSphereVMInventoryEntry, error) {
+	vms := &infrav1.VSphereVMList{}
+	if err := r.Client.List(ctx, vms,
+		ctrlclient.InNamespace(vsphereCluster.Namespace),
+		ctrlclient.MatchingLabels{clusterv1.ClusterLabelName: clusterName}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list VSphereVMs of cluster %s/%s", vsphereCluster.Namespace, clusterName)
+	}
+
+	params := session.NewParams().
+		WithServer(vsphereCluster.Spec.Server).
+		WithThumbprint(vsphereCluster.Spec.Thumbprint).
+		WithUserInfo(r.Username, r.Password).
+		WithFeatures(session.Feature{
+			EnableKeepAlive:       r.EnableKeepAlive,
+			KeepAliveDuration:     r.KeepAliveDuration,
+			MaxConcurrentRequests: r.MaxConcurrentVCenterRequests,
+		})
+	caBundle, err := identity.GetCABundle(ctx, r.Client, vsphereCluster.Namespace, vsphereCluster.Spec.CABundleRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the CA bundle of the vCenter")
+	}
+	params = params.WithCABundle(caBundle)
+	if vsphereCluster.Spec.IdentityRef != nil {
+		creds, err := identity.GetCredentials(ctx, r.Client, vsphereCluster, r.Namespace)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to retrieve credentials from IdentityRef")
+		}
+		params = params.WithUserInfo(creds.Username, creds.Password)
+	}
+
+	entries := make([]infrav1.VSphereVMInventoryEntry, 0, len(vms.Items))
+	for i := range vms.Items {
+		vm := &vms.Items[i]
+		entry := infrav1.VSphereVMInventoryEntry{Name: vm.Name, UUID: vm.Spec.BiosUUID, IPAddrs: vm.Status.Addresses}
+		authSession, err := session.GetOrCreate(ctx, params.WithDatacenter(vm.Spec.Datacenter))
+		if err == nil {
+			entry, err = govmomi.GetVMInventoryEntry(ctx, authSession, vm)
+		}
+		if err != nil {
+			r.Logger.Error(err, "failed to look up the inventory of VSphereVM", "namespace", vm.Namespace, "name", vm.Name)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
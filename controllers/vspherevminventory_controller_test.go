@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+//nolint:forcetypeassert
+func TestVMInventoryReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("failed to create VC simulator")
+	}
+	t.Cleanup(simr.Destroy)
+	simVM := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: fake.Clusterv1a2Name},
+	}
+	vsphereCluster := &infrav1.VSphereCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      "vsphere-cluster",
+			UID:       "vsphere-cluster-uid",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: cluster.Name},
+			},
+		},
+		Spec: infrav1.VSphereClusterSpec{Server: simr.ServerURL().Host},
+	}
+	vm := func(name, biosUUID string) *infrav1.VSphereVM {
+		return &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      name,
+				Labels:    map[string]string{clusterv1.ClusterLabelName: cluster.Name},
+			},
+			Spec: infrav1.VSphereVMSpec{
+				VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Server: simr.ServerURL().Host, Datacenter: "*"},
+				BiosUUID:                biosUUID,
+			},
+			Status: infrav1.VSphereVMStatus{Addresses: []string{"10.0.0.10"}},
+		}
+	}
+	otherClusterVM := vm("other", "")
+	otherClusterVM.Labels[clusterv1.ClusterLabelName] = "other-cluster"
+
+	mgmtContext := fake.NewControllerManagerContext(cluster, vsphereCluster, vm("vm-b", simVM.Config.Uuid), vm("vm-a", ""), otherClusterVM)
+	mgmtContext.Username = simr.Username()
+	mgmtContext.Password = simr.Password()
+	mgmtContext.VMInventoryInterval = 5 * time.Minute
+	r := vmInventoryReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+	result, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(vsphereCluster)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(5 * time.Minute))
+
+	inventory := &infrav1.VSphereVMInventory{}
+	g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(vsphereCluster), inventory)).To(Succeed())
+	g.Expect(inventory.Spec.ClusterName).To(Equal(cluster.Name))
+	g.Expect(inventory.OwnerReferences).To(HaveLen(1))
+	g.Expect(inventory.OwnerReferences[0].Name).To(Equal(vsphereCluster.Name))
+	g.Expect(inventory.Status.LastUpdated).NotTo(BeNil())
+
+	entries := inventory.Status.VirtualMachines
+	g.Expect(entries).To(HaveLen(2))
+	g.Expect(entries[0].Name).To(Equal("vm-a"))
+	g.Expect(entries[0].Host).To(BeEmpty())
+	g.Expect(entries[0].IPAddrs).To(ConsistOf("10.0.0.10"))
+	g.Expect(entries[1].Name).To(Equal("vm-b"))
+	g.Expect(entries[1].UUID).To(Equal(simVM.Config.Uuid))
+	g.Expect(entries[1].Host).NotTo(BeEmpty())
+	g.Expect(entries[1].Datastores).NotTo(BeEmpty())
+	g.Expect(entries[1].PowerState).To(Equal(infrav1.VirtualMachinePowerStatePoweredOn))
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+// warmPoolClaimRequeueInterval is the interval at which a VSphereWarmPool is
+// requeued while the VSphereVMs claiming its VMs are being created.
+const warmPoolClaimRequeueInterval = 10 * time.Second
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherewarmpools,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherewarmpools/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=get;list;watch
+
+// AddVSphereWarmPoolControllerToManager adds the controller that keeps the
+// VMs of each VSphereWarmPool to the provided manager.
+func AddVSphereWarmPoolControllerToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+	var (
+		controllerNameShort = "vspherewarmpool-controller"
+		controllerNameLong  = ctx.Namespace + "/" + ctx.Name + "/" + controllerNameShort
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+	r := warmPoolReconciler{ControllerContext: controllerContext}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(controllerNameShort).
+		For(&infrav1.VSphereWarmPool{}).
+		Owns(&infrav1.VSphereVM{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(r)
+}
+
+type warmPoolReconciler struct {
+	*context.ControllerContext
+}
+
+// Reconcile clones or deletes the VSphereVMs of a VSphereWarmPool to keep the
+// desired number of unclaimed VMs, and deletes the VSphereVMs whose VM has been
+// claimed once the VSphereVM claiming it exists.
+func (r warmPoolReconciler) Reconcile(ctx goctx.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
+	defer func() { result, reterr = capverrors.Requeue(r.Logger, r.Name, result, reterr) }()
+
+	logger := r.Logger.WithValues("vspherewarmpool", req.NamespacedName)
+
+	pool := &infrav1.VSphereWarmPool{}
+	if err := r.Client.Get(ctx, req.NamespacedName, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	paused, err := isPausedByClusterName(ctx, r.Client, pool.Spec.ClusterName, pool)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if paused {
+		logger.V(4).Info("VSphereWarmPool is paused")
+		return reconcile.Result{}, nil
+	}
+
+	// The VSphereVMs of the pool are garbage collected with it.
+	if !pool.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(pool, r.Client)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to init patch helper for VSphereWarmPool %s", req.NamespacedName)
+	}
+	defer func() {
+		conditions.SetSummary(pool, conditions.WithConditions(infrav1.WarmPoolReadyCondition))
+		v1beta2conditions.Mirror(pool)
+		if err := patchHelper.Patch(ctx, pool); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			logger.Error(err, "patch failed")
+		}
+	}()
+
+	vms := &infrav1.VSphereVMList{}
+	if err := r.Client.List(ctx, vms, ctrlclient.InNamespace(pool.Namespace), ctrlclient.MatchingLabels{infrav1.WarmPoolLabel: pool.Name}); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to list the VSphereVMs of VSphereWarmPool %s", req.NamespacedName)
+	}
+
+	var (
+		unclaimed     []*infrav1.VSphereVM
+		readyReplicas int32
+		claimPending  bool
+	)
+	for i := range vms.Items {
+		vm := &vms.Items[i]
+		if !vm.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if _, ok := vm.Annotations[infrav1.WarmPoolClaimedByAnnotation]; ok {
+			pending, err := r.reconcileClaimedVM(ctx, vm)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			claimPending = claimPending || pending
+			continue
+		}
+		// The VMs cloned from an earlier template can no longer be claimed.
+		if !apiequality.Semantic.DeepEqual(vm.Spec.VirtualMachineCloneSpec, pool.Spec.Template) {
+			logger.Info("Deleting VSphereVM with an outdated template", "vm", vm.Name)
+			if err := r.deleteVM(ctx, vm); err != nil {
+				return reconcile.Result{}, err
+			}
+			continue
+		}
+		// The VMs kept in another power state are replaced.
+		if warmPoolPowerState(vm.Annotations[infrav1.WarmPoolPowerStateAnnotation]) != warmPoolPowerState(string(pool.Spec.PowerState)) {
+			logger.Info("Deleting VSphereVM with an outdated power state", "vm", vm.Name)
+			if err := r.deleteVM(ctx, vm); err != nil {
+				return reconcile.Result{}, err
+			}
+			continue
+		}
+		// The VMs kept for another MachineDeployment would only be claimed by
+		// its machines.
+		if vm.Labels[clusterv1.MachineDeploymentLabelName] != pool.Spec.MachineDeploymentName {
+			logger.Info("Deleting VSphereVM of another MachineDeployment", "vm", vm.Name)
+			if err := r.deleteVM(ctx, vm); err != nil {
+				return reconcile.Result{}, err
+			}
+			continue
+		}
+		if vm.Status.Ready {
+			readyReplicas++
+		}
+		unclaimed = append(unclaimed, vm)
+	}
+
+	// Scale down, deleting the VMs that are not ready first.
+	sort.SliceStable(unclaimed, func(i, j int) bool {
+		return !unclaimed[i].Status.Ready && unclaimed[j].Status.Ready
+	})
+	for int32(len(unclaimed)) > pool.Spec.Replicas {
+		vm := unclaimed[0]
+		if err := r.deleteVM(ctx, vm); err != nil {
+			return reconcile.Result{}, err
+		}
+		if vm.Status.Ready {
+			readyReplicas--
+		}
+		unclaimed = unclaimed[1:]
+	}
+
+	// Scale up.
+	for replicas := int32(len(unclaimed)); replicas < pool.Spec.Replicas; replicas++ {
+		vm := newWarmPoolVM(pool)
+		if err := r.Client.Create(ctx, vm); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to create VSphereVM for VSphereWarmPool %s", req.NamespacedName)
+		}
+		logger.Info("Created VSphereVM", "vm", vm.Name)
+		unclaimed = append(unclaimed, vm)
+	}
+
+	pool.Status.Replicas = int32(len(unclaimed))
+	pool.Status.ReadyReplicas = readyReplicas
+	switch {
+	case pool.Status.Replicas != pool.Spec.Replicas:
+		conditions.MarkFalse(pool, infrav1.WarmPoolReadyCondition, infrav1.WarmPoolScalingReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d VMs", pool.Status.Replicas, pool.Spec.Replicas)
+	case pool.Status.ReadyReplicas < pool.Spec.Replicas:
+		conditions.MarkFalse(pool, infrav1.WarmPoolReadyCondition, infrav1.WaitingForWarmPoolVMsReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d VMs ready", pool.Status.ReadyReplicas, pool.Spec.Replicas)
+	default:
+		conditions.MarkTrue(pool, infrav1.WarmPoolReadyCondition)
+	}
+
+	if claimPending {
+		return reconcile.Result{RequeueAfter: warmPoolClaimRequeueInterval}, nil
+	}
+	return reconcile.Result{}, nil
+}
+
+// reconcileClaimedVM deletes a claimed VSphereVM of the pool once the VSphereVM
+// claiming its VM exists, which does not destroy the VM. The claim is released
+// when neither the claiming VSphereVM nor its Machine exist. It returns true
+// while the claiming VSphereVM is being created.
+func (r warmPoolReconciler) reconcileClaimedVM(ctx goctx.Context, vm *infrav1.VSphereVM) (bool, error) {
+	key := ctrlclient.ObjectKey{Namespace: vm.Namespace, Name: vm.Annotations[infrav1.WarmPoolClaimedByAnnotation]}
+	err := r.Client.Get(ctx, key, &infrav1.VSphereVM{})
+	switch {
+	case err == nil:
+		return false, r.deleteVM(ctx, vm)
+	case !apierrors.IsNotFound(err):
+		return false, errors.Wrapf(err, "failed to get VSphereVM %s", key)
+	}
+
+	// The VSphereVM of a Machine has the name of the Machine.
+	err = r.Client.Get(ctx, key, &clusterv1.Machine{})
+	switch {
+	case err == nil:
+		return true, nil
+	case !apierrors.IsNotFound(err):
+		return false, errors.Wrapf(err, "failed to get Machine %s", key)
+	}
+
+	r.Logger.Info("Releasing the claim of a deleted Machine", "vm", vm.Name, "machine", key.Name)
+	delete(vm.Annotations, infrav1.WarmPoolClaimedByAnnotation)
+	if err := r.Client.Update(ctx, vm); err != nil {
+		return false, errors.Wrapf(err, "failed to release the claim of VSphereVM %s/%s", vm.Namespace, vm.Name)
+	}
+	return false, nil
+}
+
+// deleteVM deletes a VSphereVM of the pool unless it changed since it was
+// listed, so that a VM claimed in the meantime is not deleted.
+func (r warmPoolReconciler) deleteVM(ctx goctx.Context, vm *infrav1.VSphereVM) error {
+	preconditions := ctrlclient.Preconditions{UID: &vm.UID, ResourceVersion: &vm.ResourceVersion}
+	if err := r.Client.Delete(ctx, vm, preconditions); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete VSphereVM %s/%s", vm.Namespace, vm.Name)
+	}
+	return nil
+}
+
+// warmPoolPowerState returns the power state of the VMs of a pool, which
+// defaults to powered off. The VSphereVMs created before their power state
+// was recorded are kept powered off.
+func warmPoolPowerState(powerState string) infrav1.WarmPoolPowerState {
+	if powerState == "" {
+		return infrav1.PoweredOffWarmPoolPowerState
+	}
+	return infrav1.WarmPoolPowerState(powerState)
+}
+
+// newWarmPoolVM returns a VSphereVM of the pool, cloned from its template
+// without bootstrap data.
+func newWarmPoolVM(pool *infrav1.VSphereWarmPool) *infrav1.VSphereVM {
+	vm := &infrav1.VSphereVM{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:    pool.Namespace,
+			GenerateName: pool.Name + "-",
+			Labels: map[string]string{
+				infrav1.WarmPoolLabel: pool.Name,
+			},
+			Annotations: map[string]string{
+				infrav1.WarmPoolPowerStateAnnotation: string(warmPoolPowerState(string(pool.Spec.PowerState))),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(pool, infrav1.GroupVersion.WithKind("VSphereWarmPool")),
+			},
+		},
+	}
+	if pool.Spec.ClusterName != "" {
+		vm.Labels[clusterv1.ClusterLabelName] = pool.Spec.ClusterName
+	}
+	if pool.Spec.MachineDeploymentName != "" {
+		vm.Labels[clusterv1.MachineDeploymentLabelName] = pool.Spec.MachineDeploymentName
+	}
+	pool.Spec.Template.DeepCopyInto(&vm.Spec.VirtualMachineCloneSpec)
+	return vm
+}
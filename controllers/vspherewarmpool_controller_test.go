@@ -0,0 +1,183 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestWarmPoolReconciler_Reconcile(t *testing.T) {
+	template := infrav1.VirtualMachineCloneSpec{Template: "ubuntu", Server: "vcenter", Datacenter: "dc0"}
+	pool := func(replicas int32) *infrav1.VSphereWarmPool {
+		return &infrav1.VSphereWarmPool{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "pool", UID: "pool-uid"},
+			Spec:       infrav1.VSphereWarmPoolSpec{Replicas: replicas, ClusterName: "cluster", Template: template},
+		}
+	}
+	poolVM := func(name string, ready bool, annotations map[string]string) *infrav1.VSphereVM {
+		vm := &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   fake.Namespace,
+				Name:        name,
+				Labels:      map[string]string{infrav1.WarmPoolLabel: "pool"},
+				Annotations: annotations,
+			},
+			Status: infrav1.VSphereVMStatus{Ready: ready},
+		}
+		template.DeepCopyInto(&vm.Spec.VirtualMachineCloneSpec)
+		return vm
+	}
+	listPoolVMs := func(g *WithT, c client.Client) []infrav1.VSphereVM {
+		vms := &infrav1.VSphereVMList{}
+		g.Expect(c.List(goctx.Background(), vms, client.MatchingLabels{infrav1.WarmPoolLabel: "pool"})).To(Succeed())
+		return vms.Items
+	}
+
+	t.Run("clones the VMs of the pool", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool(2)
+		mgmtContext := fake.NewControllerManagerContext(p)
+		r := warmPoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		vms := listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(2))
+		for _, vm := range vms {
+			g.Expect(vm.Name).To(HavePrefix("pool-"))
+			g.Expect(vm.Labels).To(HaveKeyWithValue(clusterv1.ClusterLabelName, "cluster"))
+			g.Expect(vm.Spec.VirtualMachineCloneSpec).To(Equal(template))
+			g.Expect(vm.Spec.BootstrapRef).To(BeNil())
+			g.Expect(vm.Annotations).To(HaveKeyWithValue(infrav1.WarmPoolPowerStateAnnotation, string(infrav1.PoweredOffWarmPoolPowerState)))
+			g.Expect(metav1.IsControlledBy(&vm, p)).To(BeTrue())
+		}
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(p), p)).To(Succeed())
+		g.Expect(p.Status.Replicas).To(Equal(int32(2)))
+		g.Expect(p.Status.ReadyReplicas).To(BeZero())
+		g.Expect(conditions.GetReason(p, infrav1.WarmPoolReadyCondition)).To(Equal(infrav1.WaitingForWarmPoolVMsReason))
+	})
+
+	t.Run("deletes the VMs that are not ready first when scaling down", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool(1)
+		mgmtContext := fake.NewControllerManagerContext(p, poolVM("ready", true, nil), poolVM("pending", false, nil))
+		r := warmPoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		vms := listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(1))
+		g.Expect(vms[0].Name).To(Equal("ready"))
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKeyFromObject(p), p)).To(Succeed())
+		g.Expect(p.Status.ReadyReplicas).To(Equal(int32(1)))
+		g.Expect(conditions.IsTrue(p, infrav1.WarmPoolReadyCondition)).To(BeTrue())
+	})
+
+	t.Run("replaces the VMs with an outdated template", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool(1)
+		outdated := poolVM("outdated", true, nil)
+		outdated.Spec.Template = "ubuntu-old"
+		mgmtContext := fake.NewControllerManagerContext(p, outdated)
+		r := warmPoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		vms := listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(1))
+		g.Expect(vms[0].Name).NotTo(Equal("outdated"))
+		g.Expect(vms[0].Spec.Template).To(Equal("ubuntu"))
+	})
+
+	t.Run("replaces the VMs kept in another power state", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool(1)
+		p.Spec.PowerState = infrav1.PoweredOnWarmPoolPowerState
+		mgmtContext := fake.NewControllerManagerContext(p, poolVM("powered-off", true, nil))
+		r := warmPoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		vms := listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(1))
+		g.Expect(vms[0].Name).NotTo(Equal("powered-off"))
+		g.Expect(vms[0].Annotations).To(HaveKeyWithValue(infrav1.WarmPoolPowerStateAnnotation, string(infrav1.PoweredOnWarmPoolPowerState)))
+	})
+
+	t.Run("keeps the VMs of the pool for its MachineDeployment", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool(1)
+		p.Spec.MachineDeploymentName = "workers"
+		mgmtContext := fake.NewControllerManagerContext(p, poolVM("any-deployment", true, nil))
+		r := warmPoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		_, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+
+		vms := listPoolVMs(g, mgmtContext.Client)
+		g.Expect(vms).To(HaveLen(1))
+		g.Expect(vms[0].Name).NotTo(Equal("any-deployment"))
+		g.Expect(vms[0].Labels).To(HaveKeyWithValue(clusterv1.MachineDeploymentLabelName, "workers"))
+	})
+
+	t.Run("reconciles the claimed VMs", func(t *testing.T) {
+		g := NewWithT(t)
+		p := pool(0)
+		claimant := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "machine-0"}}
+		pendingMachine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "machine-1"}}
+		mgmtContext := fake.NewControllerManagerContext(p, claimant, pendingMachine,
+			poolVM("adopted", true, map[string]string{infrav1.WarmPoolClaimedByAnnotation: "machine-0"}),
+			poolVM("pending", true, map[string]string{infrav1.WarmPoolClaimedByAnnotation: "machine-1"}),
+			poolVM("orphaned", true, map[string]string{infrav1.WarmPoolClaimedByAnnotation: "machine-2"}),
+		)
+		r := warmPoolReconciler{ControllerContext: fake.NewControllerContext(mgmtContext)}
+
+		result, err := r.Reconcile(mgmtContext, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(p)})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result.RequeueAfter).To(Equal(warmPoolClaimRequeueInterval))
+
+		// The VSphereVM whose VM is adopted by the claimant is deleted.
+		err = mgmtContext.Client.Get(mgmtContext, client.ObjectKey{Namespace: fake.Namespace, Name: "adopted"}, &infrav1.VSphereVM{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		// The claim of a Machine whose VSphereVM is not created yet is kept.
+		pending := &infrav1.VSphereVM{}
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKey{Namespace: fake.Namespace, Name: "pending"}, pending)).To(Succeed())
+		g.Expect(pending.Annotations).To(HaveKeyWithValue(infrav1.WarmPoolClaimedByAnnotation, "machine-1"))
+
+		// The claim of a deleted Machine is released.
+		orphaned := &infrav1.VSphereVM{}
+		g.Expect(mgmtContext.Client.Get(mgmtContext, client.ObjectKey{Namespace: fake.Namespace, Name: "orphaned"}, orphaned)).To(Succeed())
+		g.Expect(orphaned.Annotations).NotTo(HaveKey(infrav1.WarmPoolClaimedByAnnotation))
+	})
+}
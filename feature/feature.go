@@ -22,12 +22,136 @@ import (
 )
 
 const (
-// Every capv-specific feature gate should add method here following this template:
-//
-// // owner: @username
-// // alpha: v1.X
-// MyFeature featuregate.Feature = "MyFeature".
+	// Every capv-specific feature gate should add method here following this template:
+	//
+	// // owner: @username
+	// // alpha: v1.X
+	// MyFeature featuregate.Feature = "MyFeature".
 
+	// NodeAntiAffinity is a feature gate for the NodeAntiAffinity functionality.
+	// It places the VMs of a control plane or of a MachineDeployment in a
+	// vSphere cluster module to spread them across ESXi hosts.
+	//
+	// alpha: v1.2
+	NodeAntiAffinity featuregate.Feature = "NodeAntiAffinity"
+
+	// InPlaceResourceUpdate is a feature gate for the in-place update of the
+	// numCPUs, memoryMiB and diskGiB of a running VM. The resources are
+	// hot-added when the VM allows it, otherwise the VSphereVM is marked as
+	// needing a rollout.
+	//
+	// alpha: v1.3
+	InPlaceResourceUpdate featuregate.Feature = "InPlaceResourceUpdate"
+
+	// ManagedTags is a feature gate for the tags managed by CAPV. The VMs
+	// are tagged with the name of their cluster and the role of their
+	// machine, and the tag of a cluster is deleted with the cluster.
+	//
+	// alpha: v1.3
+	ManagedTags featuregate.Feature = "ManagedTags"
+
+	// StrictPlacementValidation is a feature gate for the rejection of the
+	// VSphereVMs whose datacenter, datastore, folder, resource pool or
+	// networks are not set, instead of placing their VMs in the defaults
+	// discovered in vCenter.
+	//
+	// alpha: v1.3
+	StrictPlacementValidation featuregate.Feature = "StrictPlacementValidation"
+
+	// IPConflictDetection is a feature gate for the detection of the static
+	// IP addresses of a VM, set in its spec or claimed from IPAM pools, which
+	// are already in use, before the VM is cloned with them.
+	//
+	// alpha: v1.3
+	IPConflictDetection featuregate.Feature = "IPConflictDetection"
+
+	// MachinePool is a feature gate for the VSphereMachinePool controller,
+	// which clones the VMs of the experimental MachinePools of CAPI. It
+	// requires the MachinePool feature of CAPI to be enabled.
+	//
+	// alpha: v1.3
+	MachinePool featuregate.Feature = "MachinePool"
+
+	// TemplateUsage is a feature gate for the VSphereTemplateUsage
+	// controller, which reports the objects referencing each template so that
+	// administrators can tell whether a template can be deleted.
+	//
+	// alpha: v1.3
+	TemplateUsage featuregate.Feature = "TemplateUsage"
+
+	// NodeVMHealthConditions is a feature gate for the copy of the health of
+	// the VM of a machine, reported by vSphere, to the conditions of its Node
+	// in the workload cluster, so MachineHealthChecks can remediate machines
+	// upon it.
+	//
+	// alpha: v1.3
+	NodeVMHealthConditions featuregate.Feature = "NodeVMHealthConditions"
+
+	// NodeTopologyLabels is a feature gate for the copy of the topology of a
+	// machine, the region and the zone of its failure domain and the ESXi
+	// host and the datastore of its VM, to the labels of its Node in the
+	// workload cluster.
+	//
+	// alpha: v1.3
+	NodeTopologyLabels featuregate.Feature = "NodeTopologyLabels"
+
+	// VMDiagnostics is a feature gate for the diagnostics of VMs requested
+	// with annotations of their VSphereVMs: a ticket for the HTML5 console of
+	// a VM, and the collection of its vmware.log and serial output into a
+	// ConfigMap.
+	//
+	// alpha: v1.3
+	VMDiagnostics featuregate.Feature = "VMDiagnostics"
+
+	// NetworkDeviceHotAdd is a feature gate for the hot-add of the network
+	// devices added to an existing VSphereMachine, whose NICs are added to
+	// its running VM and configured by cloud-init on their hotplug.
+	//
+	// alpha: v1.3
+	NetworkDeviceHotAdd featuregate.Feature = "NetworkDeviceHotAdd"
+
+	// InventoryCache is a feature gate for the cache of the inventory of
+	// each vCenter, kept up to date by the property collector, from which
+	// the datastores, networks and templates of the VMs are resolved.
+	//
+	// alpha: v1.3
+	InventoryCache featuregate.Feature = "InventoryCache"
+
+	// StorageVersionMigration is a feature gate for the migration of the
+	// objects of the CRDs of the provider stored in former versions to the
+	// storage version of their CRD, after an upgrade of the provider.
+	//
+	// alpha: v1.3
+	StorageVersionMigration featuregate.Feature = "StorageVersionMigration"
+
+	// VCenterAudit is a feature gate for the audit of the operations of the
+	// provider mutating vCenter, recorded as events on the objects on behalf
+	// of which they are performed and sent to the audit sink.
+	//
+	// alpha: v1.3
+	VCenterAudit featuregate.Feature = "VCenterAudit"
+
+	// ProviderServiceAccountMigration is a feature gate for the migration of
+	// the legacy run.tanzu.vmware.com ProviderServiceAccounts to the
+	// ProviderServiceAccounts of the vmware.infrastructure.cluster.x-k8s.io
+	// group, which are kept in sync while both exist.
+	//
+	// alpha: v1.3
+	ProviderServiceAccountMigration featuregate.Feature = "ProviderServiceAccountMigration"
+
+	// MachineReclone is a feature gate for the reclone operation of the
+	// VSphereVMs, which deletes their Machine so that its owner replaces it.
+	//
+	// alpha: v1.3
+	MachineReclone featuregate.Feature = "MachineReclone"
+
+	// NSXSecurityGroups is a feature gate for the security groups of the
+	// supervisor VSphereMachines, declared as labels of their VirtualMachines
+	// for NCP to realize as tags of their segment ports. Only the NSX-T
+	// network provider supports them.
+	//
+	// alpha: v1.3
+	NSXSecurityGroups featuregate.Feature = "NSXSecurityGroups"
 )
 
 func init() {
@@ -38,5 +162,21 @@ func init() {
 // To add a new feature, define a key for it above and add it here.
 var defaultCAPVFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
 	// Every feature should be initiated here:
-
+	NodeAntiAffinity:                {Default: false, PreRelease: featuregate.Alpha},
+	InPlaceResourceUpdate:           {Default: false, PreRelease: featuregate.Alpha},
+	ManagedTags:                     {Default: false, PreRelease: featuregate.Alpha},
+	StrictPlacementValidation:       {Default: false, PreRelease: featuregate.Alpha},
+	IPConflictDetection:             {Default: false, PreRelease: featuregate.Alpha},
+	MachinePool:                     {Default: false, PreRelease: featuregate.Alpha},
+	TemplateUsage:                   {Default: false, PreRelease: featuregate.Alpha},
+	NodeVMHealthConditions:          {Default: false, PreRelease: featuregate.Alpha},
+	NodeTopologyLabels:              {Default: false, PreRelease: featuregate.Alpha},
+	VMDiagnostics:                   {Default: false, PreRelease: featuregate.Alpha},
+	NetworkDeviceHotAdd:             {Default: false, PreRelease: featuregate.Alpha},
+	InventoryCache:                  {Default: false, PreRelease: featuregate.Alpha},
+	StorageVersionMigration:         {Default: false, PreRelease: featuregate.Alpha},
+	VCenterAudit:                    {Default: false, PreRelease: featuregate.Alpha},
+	ProviderServiceAccountMigration: {Default: false, PreRelease: featuregate.Alpha},
+	MachineReclone:                  {Default: false, PreRelease: featuregate.Alpha},
+	NSXSecurityGroups:               {Default: false, PreRelease: featuregate.Alpha},
 }
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// privileges prints the vCenter privileges required by CAPV with the given
+// features, either as a list or as the govc command creating a role with them,
+// and optionally checks them against the privileges of a vCenter user.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/privileges"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+func main() {
+	var features privileges.Features
+	flag.BoolVar(&features.LinkedClones, "linked-clones", false, "The machines are linked clones of their template.")
+	flag.BoolVar(&features.Tags, "tags", false, "The machines attach the tags of their tagIDs to their VMs.")
+	flag.BoolVar(&features.ManagedTags, "managed-tags", false, "The ManagedTags feature gate is enabled.")
+	flag.BoolVar(&features.StorageDRS, "storage-drs", false, "The disks of the VMs are placed in datastore clusters.")
+	flag.BoolVar(&features.ClusterModules, "cluster-modules", false, "The NodeAntiAffinity feature gate is enabled.")
+	flag.BoolVar(&features.ClusterPermissions, "cluster-permissions", false, "The clusters grant a permission on their folder and resource pool.")
+	flag.BoolVar(&features.Relocations, "relocations", false, "The VMs are migrated with the relocate operation.")
+	flag.BoolVar(&features.AttachedVolumes, "attached-volumes", false, "The machines set an attachedVolumesPolicy.")
+	flag.BoolVar(&features.Encryption, "encryption", false, "The machines set an encryption.")
+	role := flag.String("role", "", "Print the govc command creating a role with this name rather than the list of privileges.")
+	server := flag.String("server", "", "Check the privileges of the user on the root folder of this vCenter.")
+	username := flag.String("username", "", "The user whose privileges are checked. Its password is read from the VSPHERE_PASSWORD environment variable.")
+	thumbprint := flag.String("thumbprint", "", "The thumbprint of the certificate of vCenter.")
+	flag.Parse()
+
+	required := privileges.Required(features)
+	if *server == "" {
+		if *role != "" {
+			fmt.Printf("govc role.create %s %s\n", *role, strings.Join(required, " "))
+			return
+		}
+		fmt.Println(strings.Join(required, "\n"))
+		return
+	}
+
+	missing, err := missingPrivileges(*server, *username, os.Getenv("VSPHERE_PASSWORD"), *thumbprint, required)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(missing) > 0 {
+		fmt.Println(strings.Join(missing, "\n"))
+		os.Exit(1)
+	}
+}
+
+func missingPrivileges(server, username, password, thumbprint string, required []string) ([]string, error) {
+	ctx := context.Background()
+	s, err := session.GetOrCreate(ctx, session.NewParams().
+		WithServer(server).
+		WithUserInfo(username, password).
+		WithThumbprint(thumbprint))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create a session with %s", server)
+	}
+	return privileges.Missing(ctx, s, required)
+}
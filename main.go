@@ -24,6 +24,7 @@ import (
 	"net/http/pprof"
 	"os"
 	"reflect"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -40,14 +41,22 @@ import (
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/constants"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/manager"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/version"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/webhooks/crossnamespace"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/webhooks/ippool"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/webhooks/namespacedefaults"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/webhooks/permission"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/webhooks/quota"
+	vmwarewebhooks "sigs.k8s.io/cluster-api-provider-vsphere/pkg/webhooks/vmware"
 )
 
 var (
 	setupLog = ctrllog.Log.WithName("entrypoint")
 
-	managerOpts manager.Options
-	syncPeriod  time.Duration
+	managerOpts  manager.Options
+	syncPeriod   time.Duration
+	featureGates string
 
 	defaultProfilerAddr      = os.Getenv("PROFILER_ADDR")
 	defaultSyncPeriod        = manager.DefaultSyncPeriod
@@ -87,6 +96,15 @@ func main() {
 		"namespace",
 		"",
 		"Namespace that the controller watches to reconcile cluster-api objects. If unspecified, the controller watches for cluster-api objects across all namespaces.")
+	watchNamespaces := flag.String(
+		"watch-namespaces",
+		"",
+		"Comma-separated list of namespaces that the controller watches to reconcile cluster-api objects, in addition to the namespace of the controller. Cannot be set with --namespace.")
+	flag.StringVar(
+		&managerOpts.CacheLabelSelector,
+		"cache-label-selector",
+		"",
+		"Label selector of the Secrets and ConfigMaps held in the cache of the controller, e.g. cluster.x-k8s.io/cluster-name. The other Secrets and ConfigMaps are read from the API server. If unspecified, all the Secrets and ConfigMaps are cached.")
 	profilerAddress := flag.String(
 		"profiler-address",
 		defaultProfilerAddr,
@@ -101,6 +119,11 @@ func main() {
 		"max-concurrent-reconciles",
 		10,
 		"The maximum number of allowed, concurrent reconciles.")
+	flag.IntVar(
+		&managerOpts.ControlPlaneMaxConcurrentReconciles,
+		"control-plane-max-concurrent-reconciles",
+		0,
+		"The maximum number of allowed, concurrent reconciles of the machines and VMs of control planes, which do not wait behind the reconciles of the worker machines (defaults to --max-concurrent-reconciles).")
 	flag.StringVar(
 		&managerOpts.PodName,
 		"pod-name",
@@ -123,6 +146,71 @@ func main() {
 		"/etc/capv/credentials.yaml",
 		"path to CAPV's credentials file",
 	)
+	flag.StringVar(
+		&managerOpts.LifecycleHooksConfigFile,
+		"lifecycle-hooks-config",
+		"",
+		"path to the configuration of the webhooks and exec plugins invoked at the PreClone, PostPowerOn and PreDelete points of the VM lifecycle",
+	)
+	flag.StringVar(
+		&managerOpts.MachineNotificationsConfigFile,
+		"machine-notifications-config",
+		"",
+		"path to the configuration of the webhooks and exec plugins to which the MachineCreated, MachineJoined, MachineRemediated and MachineDeleted events are sent",
+	)
+	flag.DurationVar(
+		&managerOpts.VMInventoryInterval,
+		"vm-inventory-interval",
+		0,
+		"The interval at which the VSphereVMInventory of each VSphereCluster is refreshed (set to 0 to disable the inventory)",
+	)
+	flag.DurationVar(
+		&managerOpts.OrphanedVMGCInterval,
+		"orphaned-vm-gc-interval",
+		0,
+		"The interval at which the VMs whose VSphereVM or Cluster no longer exists are deleted, which requires the ManagedTags feature gate (set to 0 to disable the garbage collection)",
+	)
+	flag.StringVar(
+		&managerOpts.ManagementClusterID,
+		"management-cluster-id",
+		"",
+		"The ID of the management cluster recorded on the VMs it clones, so that the garbage collection of the orphaned VMs only deletes the VMs cloned by this management cluster. Defaults to the UID of the kube-system namespace")
+	flag.DurationVar(
+		&managerOpts.VMStatusBatchInterval,
+		"vm-status-batch-interval",
+		0,
+		"The minimum interval between two patches of a VSphereVM that only update its addresses or storage consumption, coalescing the updates in between (set to 0 to patch every update)",
+	)
+	flag.DurationVar(
+		&managerOpts.ProvisioningTimeouts.Clone,
+		"clone-timeout",
+		0,
+		"The maximum duration of the clone of a VM (disabled when 0, the default)",
+	)
+	flag.DurationVar(
+		&managerOpts.ProvisioningTimeouts.ToolsStart,
+		"tools-start-timeout",
+		0,
+		"The maximum duration between the power on of a VM and VMware Tools running in the guest (disabled when 0, the default)",
+	)
+	flag.DurationVar(
+		&managerOpts.ProvisioningTimeouts.IPAcquisition,
+		"ip-acquisition-timeout",
+		0,
+		"The maximum duration between the power on of a VM and the VM reporting its IP addresses (disabled when 0, the default)",
+	)
+	flag.DurationVar(
+		&managerOpts.ProvisioningTimeouts.BootstrapJoin,
+		"bootstrap-join-timeout",
+		0,
+		"The maximum duration between a VM being ready and the node of its Machine joining the cluster (disabled when 0, the default)",
+	)
+	flag.DurationVar(
+		&managerOpts.IPConflictProbeTimeout,
+		"ip-conflict-probe-timeout",
+		manager.DefaultIPConflictProbeTimeout,
+		"The timeout of the ICMP probe of the static IP addresses of a VM before it is cloned, which requires the IPConflictDetection feature gate (set to 0 to only check the addresses reported by vCenter)",
+	)
 	flag.BoolVar(
 		&managerOpts.EnableKeepAlive,
 		"enable-keep-alive",
@@ -135,11 +223,96 @@ func main() {
 		defaultKeepAliveDuration,
 		"idle time interval(minutes) in between send() requests in keepalive handler")
 
+	flag.IntVar(
+		&managerOpts.MaxConcurrentVCenterRequests,
+		"max-concurrent-vcenter-requests",
+		0,
+		"The maximum number of concurrent requests to each vCenter (set to 0 to not limit the requests)")
+
+	maxConcurrentClones := flag.Int(
+		"max-concurrent-vcenter-clones",
+		0,
+		"The maximum number of concurrent clone tasks issued by the VSphereVMs to each vCenter, shared fairly between the clusters (set to 0 to not limit the clones)")
+	maxConcurrentReconfigures := flag.Int(
+		"max-concurrent-vcenter-reconfigures",
+		0,
+		"The maximum number of concurrent reconfigure tasks issued by the VSphereVMs to each vCenter, shared fairly between the clusters (set to 0 to not limit the reconfigures)")
+	maxConcurrentPowerOps := flag.Int(
+		"max-concurrent-vcenter-power-ops",
+		0,
+		"The maximum number of concurrent power on and power off tasks issued by the VSphereVMs to each vCenter, shared fairly between the clusters (set to 0 to not limit the power operations)")
+
+	flag.Float64Var(
+		&managerOpts.CostWeights.VCPUHour,
+		"cost-per-vcpu-hour",
+		0,
+		"The hourly cost of a virtual processor used to estimate the cost of the VSphereMachines and the VSphereClusters (the cost is not estimated if all the costs are 0)")
+	flag.Float64Var(
+		&managerOpts.CostWeights.MemoryGiBHour,
+		"cost-per-memory-gib-hour",
+		0,
+		"The hourly cost of a GiB of memory used to estimate the cost of the VSphereMachines and the VSphereClusters")
+	flag.Float64Var(
+		&managerOpts.CostWeights.DiskGiBHour,
+		"cost-per-disk-gib-hour",
+		0,
+		"The hourly cost of a GiB of disk used to estimate the cost of the VSphereMachines and the VSphereClusters")
+
+	flag.IntVar(
+		&managerOpts.SnapshotChainLimit,
+		"snapshot-chain-limit",
+		0,
+		"The maximum length of the chain of snapshots of a VM before its reconfigurations and migrations are blocked (set to 0 to not check the chains)")
+	flag.BoolVar(
+		&managerOpts.AutoConsolidateDisks,
+		"auto-consolidate-disks",
+		false,
+		"Consolidate the disks of the VMs which need a consolidation, e.g. after the removal of a snapshot failed")
+	flag.DurationVar(
+		&managerOpts.DriftCheckInterval,
+		"drift-check-interval",
+		0,
+		"The interval at which the configuration of each ready VM is compared with its VSphereVM (set to 0 to not check the drift)")
+	flag.BoolVar(
+		&managerOpts.AutoCorrectDrift,
+		"auto-correct-drift",
+		false,
+		"Correct the reversible drift of the VMs, e.g. move a VM back into its folder, when the drift is checked")
+	flag.StringVar(
+		&managerOpts.VCenterAuditSink,
+		"vcenter-audit-sink",
+		"",
+		"The sink of the JSON records of the operations mutating vCenter, audited with the VCenterAudit feature gate: a file to which they are appended, - for the standard output, or an http(s) URL to which they are posted")
+
 	flag.StringVar(
 		&managerOpts.NetworkProvider,
 		"network-provider",
 		"",
 		"network provider to be used by Supervisor based clusters.")
+	flag.StringVar(
+		&managerOpts.CrossNamespaceRefPolicy,
+		"cross-namespace-ref-policy",
+		string(crossnamespace.AllowPolicy),
+		"The policy applied to object references pointing to another namespace than the one of the referencing object. Options are Allow, Audit (log the references) and Deny (reject the objects)")
+	flag.StringVar(
+		&managerOpts.RBACCleanupPolicy,
+		"provider-serviceaccount-rbac-cleanup",
+		"",
+		"The policy applied to the ServiceAccounts, Roles and RoleBindings of the ProviderServiceAccounts created by former versions of the controller in supervisor mode. Options are Report (log and record events), Relabel (relabel the objects of the existing ProviderServiceAccounts) and Delete (relabel them and delete the orphaned objects); they are not cleaned up if it is not set")
+	clusterPermissionAllowedPrivileges := flag.String(
+		"cluster-permission-allowed-privileges",
+		"",
+		"Comma-separated list of the privileges the role of the permission of a cluster may hold in addition to the privileges CAPV requires on the VMs and the resource pools, e.g. VirtualMachine.Interact.ConsoleInteract")
+	flag.StringVar(
+		&managerOpts.SystemServiceAccountRegistry,
+		"system-serviceaccount-registry",
+		"",
+		"The name of the cluster-scoped SystemServiceAccountRegistry listing the system service accounts in supervisor mode, created if it is not existing. The ConfigMap named by the SERVICE_ACCOUNTS_CM_NAMESPACE and SERVICE_ACCOUNTS_CM_NAME environment variables is used if it is not set")
+	flag.StringVar(
+		&featureGates,
+		"feature-gates",
+		"",
+		"A set of key=value pairs that describe feature gates for alpha/experimental features. Options are:\n"+strings.Join(feature.MutableGates.KnownFeatures(), "\n"))
 
 	flag.Parse()
 
@@ -148,6 +321,16 @@ func main() {
 			"Watching objects only in namespace for reconciliation",
 			"namespace", managerOpts.Namespace)
 	}
+	if *watchNamespaces != "" {
+		managerOpts.WatchNamespaces = strings.Split(*watchNamespaces, ",")
+		setupLog.Info(
+			"Watching objects only in namespaces for reconciliation",
+			"namespaces", managerOpts.WatchNamespaces)
+	}
+
+	if *clusterPermissionAllowedPrivileges != "" {
+		managerOpts.ClusterPermissionAllowedPrivileges = strings.Split(*clusterPermissionAllowedPrivileges, ",")
+	}
 
 	if *profilerAddress != "" {
 		setupLog.Info(
@@ -155,9 +338,28 @@ func main() {
 			"profiler-address", *profilerAddress)
 		go runProfiler(*profilerAddress)
 	}
+	if err := feature.MutableGates.Set(featureGates); err != nil {
+		setupLog.Error(err, "unable to set feature gates")
+		os.Exit(1)
+	}
 	setupLog.V(1).Info(fmt.Sprintf("feature gates: %+v\n", feature.Gates))
+	if _, err := crossnamespace.ParsePolicy(managerOpts.CrossNamespaceRefPolicy); err != nil {
+		setupLog.Error(err, "unable to set the cross-namespace reference policy")
+		os.Exit(1)
+	}
+	if managerOpts.RBACCleanupPolicy != "" {
+		if _, err := controllers.ParseRBACCleanupPolicy(managerOpts.RBACCleanupPolicy); err != nil {
+			setupLog.Error(err, "unable to set the provider serviceaccount RBAC cleanup policy")
+			os.Exit(1)
+		}
+	}
 
 	managerOpts.SyncPeriod = &syncPeriod
+	managerOpts.MaxConcurrentVCenterTasks = throttle.Limits{
+		throttle.Clone:       *maxConcurrentClones,
+		throttle.Reconfigure: *maxConcurrentReconfigures,
+		throttle.Power:       *maxConcurrentPowerOps,
+	}
 
 	// Create a function that adds all of the controllers and webhooks to the
 	// manager.
@@ -192,6 +394,12 @@ func main() {
 			}
 		}
 
+		if feature.Gates.Enabled(feature.StorageVersionMigration) {
+			if err := controllers.AddStorageVersionMigratorToManager(ctx, mgr); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	}
 
@@ -214,6 +422,9 @@ func main() {
 }
 
 func setupVAPIControllers(ctx *context.ControllerManagerContext, mgr ctrlmgr.Manager) error {
+	if err := (&v1beta1.VSphereCluster{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
 	if err := (&v1beta1.VSphereClusterTemplate{}).SetupWebhookWithManager(mgr); err != nil {
 		return err
 	}
@@ -239,6 +450,45 @@ func setupVAPIControllers(ctx *context.ControllerManagerContext, mgr ctrlmgr.Man
 		return err
 	}
 
+	crossNamespaceRefPolicy, err := crossnamespace.ParsePolicy(ctx.CrossNamespaceRefPolicy)
+	if err != nil {
+		return err
+	}
+	if err := (&crossnamespace.VSphereClusterValidator{Policy: crossNamespaceRefPolicy, Logger: ctx.Logger.WithName("crossnamespace")}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&crossnamespace.VSphereVMValidator{Policy: crossNamespaceRefPolicy, Logger: ctx.Logger.WithName("crossnamespace")}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&permission.VSphereClusterValidator{AllowedPrivileges: ctx.ClusterPermissionAllowedPrivileges}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&namespacedefaults.VSphereClusterDefaulter{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&namespacedefaults.VSphereMachineDefaulter{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&namespacedefaults.VSphereMachineTemplateDefaulter{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&ippool.VSphereClusterValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&ippool.VSphereMachineValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&ippool.VSphereMachineTemplateValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
+	if err := (&quota.VSphereMachineValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
 	if err := (&v1beta1.VSphereDeploymentZone{}).SetupWebhookWithManager(mgr); err != nil {
 		return err
 	}
@@ -253,6 +503,10 @@ func setupVAPIControllers(ctx *context.ControllerManagerContext, mgr ctrlmgr.Man
 		return err
 	}
 
+	if err := (&v1beta1.VSphereWarmPool{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
 	if err := controllers.AddClusterControllerToManager(ctx, mgr, &v1beta1.VSphereCluster{}); err != nil {
 		return err
 	}
@@ -268,10 +522,62 @@ func setupVAPIControllers(ctx *context.ControllerManagerContext, mgr ctrlmgr.Man
 	if err := controllers.AddVSphereDeploymentZoneControllerToManager(ctx, mgr); err != nil {
 		return err
 	}
+	if err := controllers.AddVSphereMachineImageControllerToManager(ctx, mgr); err != nil {
+		return err
+	}
+	if err := controllers.AddVSphereWarmPoolControllerToManager(ctx, mgr); err != nil {
+		return err
+	}
+	if err := controllers.AddVSphereResourceQuotaControllerToManager(ctx, mgr); err != nil {
+		return err
+	}
+	if err := controllers.AddVSphereMachineTemplateControllerToManager(ctx, mgr); err != nil {
+		return err
+	}
+	if err := controllers.AddVSphereMachineTemplateRolloutControllerToManager(ctx, mgr); err != nil {
+		return err
+	}
+	if feature.Gates.Enabled(feature.MachinePool) {
+		if err := controllers.AddVSphereMachinePoolControllerToManager(ctx, mgr); err != nil {
+			return err
+		}
+	}
+	if feature.Gates.Enabled(feature.TemplateUsage) {
+		if err := controllers.AddVSphereTemplateUsageControllerToManager(ctx, mgr); err != nil {
+			return err
+		}
+	}
+	if ctx.VMInventoryInterval > 0 {
+		if err := controllers.AddVSphereVMInventoryControllerToManager(ctx, mgr); err != nil {
+			return err
+		}
+	}
+	if ctx.OrphanedVMGCInterval > 0 {
+		if err := controllers.AddOrphanedVMCollectorToManager(ctx, mgr); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func setupSupervisorControllers(ctx *context.ControllerManagerContext, mgr ctrlmgr.Manager) error {
+	if err := (&vmwarewebhooks.VSphereMachineValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&vmwarewebhooks.VSphereMachineTemplateValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+	if err := (&vmwarewebhooks.ProviderServiceAccountValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+	crossNamespaceRefPolicy, err := crossnamespace.ParsePolicy(ctx.CrossNamespaceRefPolicy)
+	if err != nil {
+		return err
+	}
+	if err := (&crossnamespace.ProviderServiceAccountValidator{Policy: crossNamespaceRefPolicy, Logger: ctx.Logger.WithName("crossnamespace")}).SetupWebhookWithManager(mgr); err != nil {
+		return err
+	}
+
 	if err := controllers.AddClusterControllerToManager(ctx, mgr, &vmwarev1b1.VSphereCluster{}); err != nil {
 		return err
 	}
@@ -288,6 +594,28 @@ func setupSupervisorControllers(ctx *context.ControllerManagerContext, mgr ctrlm
 		return err
 	}
 
+	if ctx.RBACCleanupPolicy != "" {
+		policy, err := controllers.ParseRBACCleanupPolicy(ctx.RBACCleanupPolicy)
+		if err != nil {
+			return err
+		}
+		if err := controllers.AddProviderServiceAccountRBACCleanupToManager(ctx, mgr, policy); err != nil {
+			return err
+		}
+	}
+
+	if feature.Gates.Enabled(feature.ProviderServiceAccountMigration) {
+		legacyGVK := controllers.LegacyProviderServiceAccountGVK
+		if _, err := mgr.GetRESTMapper().RESTMapping(legacyGVK.GroupKind(), legacyGVK.Version); err != nil {
+			if !meta.IsNoMatchError(err) {
+				return err
+			}
+			setupLog.Info(fmt.Sprintf("CRD for %s not loaded, skipping the migration of the legacy ProviderServiceAccounts.", legacyGVK.String()))
+		} else if err := controllers.AddProviderServiceAccountMigratorToManager(ctx, mgr); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -54,8 +54,14 @@ func RunRoot(command *cobra.Command) error {
 	switch flavor {
 	case "vip":
 		util.PrintObjects(flavors.MultiNodeTemplateWithKubeVIP())
+	case "multi-homed":
+		util.PrintObjects(flavors.MultiNodeTemplateWithKubeVIPMultiHomed())
 	case "external-loadbalancer":
 		util.PrintObjects(flavors.MultiNodeTemplateWithExternalLoadBalancer())
+	case "cluster-class":
+		util.PrintObjects(flavors.ClusterClassTemplateWithKubeVIP())
+	case "cluster-topology":
+		util.PrintObjects(flavors.ClusterTopologyTemplate())
 	default:
 		return errors.Errorf("invalid flavor")
 	}
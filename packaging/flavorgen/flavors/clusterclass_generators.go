@@ -0,0 +1,537 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flavors
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/packaging/flavorgen/flavors/env"
+	"sigs.k8s.io/cluster-api-provider-vsphere/packaging/flavorgen/flavors/util"
+)
+
+// Names of the variables of the ClusterClass.
+const (
+	sshKeyVariable              = "sshKey"
+	controlPlaneIPAddrVariable  = "controlPlaneIpAddr"
+	credsSecretNameVariable     = "credsSecretName"
+	infraServerVariable         = "infraServer"
+	datastoreVariable           = "datastore"
+	networkVariable             = "network"
+	controlPlaneMachineVariable = "controlPlaneMachine"
+	workerMachineVariable       = "workerMachine"
+)
+
+func newClusterClass() clusterv1.ClusterClass {
+	return clusterv1.ClusterClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       util.TypeToKind(&clusterv1.ClusterClass{}),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      env.ClusterClassNameVar,
+			Namespace: env.NamespaceVar,
+		},
+		Spec: clusterv1.ClusterClassSpec{
+			Infrastructure: clusterv1.LocalObjectTemplate{
+				Ref: &corev1.ObjectReference{
+					APIVersion: infrav1.GroupVersion.String(),
+					Kind:       util.TypeToKind(&infrav1.VSphereClusterTemplate{}),
+					Name:       env.ClusterClassNameVar,
+				},
+			},
+			ControlPlane: clusterv1.ControlPlaneClass{
+				LocalObjectTemplate: clusterv1.LocalObjectTemplate{
+					Ref: &corev1.ObjectReference{
+						APIVersion: controlplanev1.GroupVersion.String(),
+						Kind:       util.TypeToKind(&controlplanev1.KubeadmControlPlaneTemplate{}),
+						Name:       env.ClusterClassNameVar + env.ControlPlaneTemplateNameSuffix,
+					},
+				},
+				MachineInfrastructure: &clusterv1.LocalObjectTemplate{
+					Ref: &corev1.ObjectReference{
+						APIVersion: infrav1.GroupVersion.String(),
+						Kind:       util.TypeToKind(&infrav1.VSphereMachineTemplate{}),
+						Name:       env.ClusterClassNameVar + env.ControlPlaneMachineTemplateSuffix,
+					},
+				},
+			},
+			Workers: clusterv1.WorkersClass{
+				MachineDeployments: []clusterv1.MachineDeploymentClass{
+					{
+						Class: env.ClusterClassNameVar + env.WorkerMachineDeploymentClassSuffix,
+						Template: clusterv1.MachineDeploymentClassTemplate{
+							Bootstrap: clusterv1.LocalObjectTemplate{
+								Ref: &corev1.ObjectReference{
+									APIVersion: bootstrapv1.GroupVersion.String(),
+									Kind:       util.TypeToKind(&bootstrapv1.KubeadmConfigTemplate{}),
+									Name:       env.ClusterClassNameVar + env.WorkerBootstrapTemplateNameSuffix,
+								},
+							},
+							Infrastructure: clusterv1.LocalObjectTemplate{
+								Ref: &corev1.ObjectReference{
+									APIVersion: infrav1.GroupVersion.String(),
+									Kind:       util.TypeToKind(&infrav1.VSphereMachineTemplate{}),
+									Name:       env.ClusterClassNameVar + env.WorkerMachineTemplateNameSuffix,
+								},
+							},
+						},
+					},
+				},
+			},
+			Variables: clusterClassVariables(),
+			Patches:   clusterClassPatches(),
+		},
+	}
+}
+
+func clusterClassVariables() []clusterv1.ClusterClassVariable {
+	return []clusterv1.ClusterClassVariable{
+		{
+			Name:     sshKeyVariable,
+			Required: false,
+			Schema: clusterv1.VariableSchema{
+				OpenAPIV3Schema: clusterv1.JSONSchemaProps{
+					Description: "The public SSH key authorized to log in as the capv user.",
+					Type:        "string",
+				},
+			},
+		},
+		{
+			Name:     controlPlaneIPAddrVariable,
+			Required: true,
+			Schema: clusterv1.VariableSchema{
+				OpenAPIV3Schema: clusterv1.JSONSchemaProps{
+					Description: "The IP address of the control plane endpoint, advertised by kube-vip.",
+					Type:        "string",
+				},
+			},
+		},
+		{
+			Name:     credsSecretNameVariable,
+			Required: true,
+			Schema: clusterv1.VariableSchema{
+				OpenAPIV3Schema: clusterv1.JSONSchemaProps{
+					Description: "The name of the Secret with the credentials of vCenter.",
+					Type:        "string",
+				},
+			},
+		},
+		{
+			Name:     infraServerVariable,
+			Required: true,
+			Schema: clusterv1.VariableSchema{
+				OpenAPIV3Schema: clusterv1.JSONSchemaProps{
+					Description: "The vCenter server of the cluster, and the TLS thumbprint of its certificate if it is not trusted.",
+					Type:        "object",
+					Properties: map[string]clusterv1.JSONSchemaProps{
+						"url":        {Type: "string"},
+						"thumbprint": {Type: "string"},
+					},
+					Required: []string{"url", "thumbprint"},
+				},
+			},
+		},
+		{
+			Name:     datastoreVariable,
+			Required: false,
+			Schema: clusterv1.VariableSchema{
+				OpenAPIV3Schema: clusterv1.JSONSchemaProps{
+					Description: "The datastore of the disks of the machines, instead of the one of the ClusterClass.",
+					Type:        "string",
+				},
+			},
+		},
+		{
+			Name:     networkVariable,
+			Required: false,
+			Schema: clusterv1.VariableSchema{
+				OpenAPIV3Schema: clusterv1.JSONSchemaProps{
+					Description: "The network of the first network device of the machines, instead of the one of the ClusterClass.",
+					Type:        "string",
+				},
+			},
+		},
+		machineVariable(controlPlaneMachineVariable, "The resources of the control plane machines."),
+		machineVariable(workerMachineVariable, "The resources of the worker machines."),
+	}
+}
+
+// machineVariable returns a variable sizing the machines, whose properties
+// default to the sizes of the other flavors.
+func machineVariable(name, description string) clusterv1.ClusterClassVariable {
+	return clusterv1.ClusterClassVariable{
+		Name:     name,
+		Required: false,
+		Schema: clusterv1.VariableSchema{
+			OpenAPIV3Schema: clusterv1.JSONSchemaProps{
+				Description: description,
+				Type:        "object",
+				Default:     jsonValue("{}"),
+				Properties: map[string]clusterv1.JSONSchemaProps{
+					"numCPUs": {
+						Type:    "integer",
+						Minimum: pointer.Int64(2),
+						Default: jsonValue(fmt.Sprint(env.DefaultNumCPUs)),
+					},
+					"memoryMiB": {
+						Type:    "integer",
+						Minimum: pointer.Int64(2048),
+						Default: jsonValue(fmt.Sprint(env.DefaultMemoryMiB)),
+					},
+					"diskGiB": {
+						Type:    "integer",
+						Minimum: pointer.Int64(20),
+						Default: jsonValue(fmt.Sprint(env.DefaultDiskGiB)),
+					},
+				},
+			},
+		},
+	}
+}
+
+func clusterClassPatches() []clusterv1.ClusterClassPatch {
+	controlPlaneMachineTemplate := clusterv1.PatchSelector{
+		APIVersion:     infrav1.GroupVersion.String(),
+		Kind:           util.TypeToKind(&infrav1.VSphereMachineTemplate{}),
+		MatchResources: clusterv1.PatchSelectorMatch{ControlPlane: true},
+	}
+	workerMachineTemplate := clusterv1.PatchSelector{
+		APIVersion: infrav1.GroupVersion.String(),
+		Kind:       util.TypeToKind(&infrav1.VSphereMachineTemplate{}),
+		MatchResources: clusterv1.PatchSelectorMatch{
+			MachineDeploymentClass: &clusterv1.PatchSelectorMatchMachineDeploymentClass{
+				Names: []string{env.ClusterClassNameVar + env.WorkerMachineDeploymentClassSuffix},
+			},
+		},
+	}
+	bothMachineTemplates := clusterv1.PatchSelector{
+		APIVersion: infrav1.GroupVersion.String(),
+		Kind:       util.TypeToKind(&infrav1.VSphereMachineTemplate{}),
+		MatchResources: clusterv1.PatchSelectorMatch{
+			ControlPlane:           true,
+			MachineDeploymentClass: workerMachineTemplate.MatchResources.MachineDeploymentClass,
+		},
+	}
+	controlPlaneTemplate := clusterv1.PatchSelector{
+		APIVersion:     controlplanev1.GroupVersion.String(),
+		Kind:           util.TypeToKind(&controlplanev1.KubeadmControlPlaneTemplate{}),
+		MatchResources: clusterv1.PatchSelectorMatch{ControlPlane: true},
+	}
+
+	return []clusterv1.ClusterClassPatch{
+		{
+			Name: "infraClusterSubstitutions",
+			Definitions: []clusterv1.PatchDefinition{
+				{
+					Selector: clusterv1.PatchSelector{
+						APIVersion:     infrav1.GroupVersion.String(),
+						Kind:           util.TypeToKind(&infrav1.VSphereClusterTemplate{}),
+						MatchResources: clusterv1.PatchSelectorMatch{InfrastructureCluster: true},
+					},
+					JSONPatches: []clusterv1.JSONPatch{
+						{
+							Op:        "add",
+							Path:      "/spec/template/spec/controlPlaneEndpoint",
+							ValueFrom: &clusterv1.JSONPatchValue{Template: pointer.String(fmt.Sprintf("host: '{{ .%s }}'\nport: 6443", controlPlaneIPAddrVariable))},
+						},
+						{
+							Op:        "add",
+							Path:      "/spec/template/spec/identityRef",
+							ValueFrom: &clusterv1.JSONPatchValue{Template: pointer.String(fmt.Sprintf("kind: %s\nname: '{{ .%s }}'", infrav1.SecretKind, credsSecretNameVariable))},
+						},
+						{
+							Op:        "replace",
+							Path:      "/spec/template/spec/server",
+							ValueFrom: &clusterv1.JSONPatchValue{Variable: pointer.String(infraServerVariable + ".url")},
+						},
+						{
+							Op:        "replace",
+							Path:      "/spec/template/spec/thumbprint",
+							ValueFrom: &clusterv1.JSONPatchValue{Variable: pointer.String(infraServerVariable + ".thumbprint")},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: "kubeVIPPodManifest",
+			Definitions: []clusterv1.PatchDefinition{
+				{
+					Selector: controlPlaneTemplate,
+					JSONPatches: []clusterv1.JSONPatch{
+						{
+							Op:        "add",
+							Path:      "/spec/template/spec/kubeadmConfigSpec/files",
+							ValueFrom: &clusterv1.JSONPatchValue{Template: pointer.String(kubeVIPFilesTemplate())},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:      "sshKey",
+			EnabledIf: pointer.String(fmt.Sprintf("{{ if .%s }}true{{ end }}", sshKeyVariable)),
+			Definitions: []clusterv1.PatchDefinition{
+				{
+					Selector: controlPlaneTemplate,
+					JSONPatches: []clusterv1.JSONPatch{
+						{
+							Op:        "add",
+							Path:      "/spec/template/spec/kubeadmConfigSpec/users",
+							ValueFrom: &clusterv1.JSONPatchValue{Template: pointer.String(usersTemplate())},
+						},
+					},
+				},
+				{
+					Selector: clusterv1.PatchSelector{
+						APIVersion:     bootstrapv1.GroupVersion.String(),
+						Kind:           util.TypeToKind(&bootstrapv1.KubeadmConfigTemplate{}),
+						MatchResources: workerMachineTemplate.MatchResources,
+					},
+					JSONPatches: []clusterv1.JSONPatch{
+						{
+							Op:        "add",
+							Path:      "/spec/template/spec/users",
+							ValueFrom: &clusterv1.JSONPatchValue{Template: pointer.String(usersTemplate())},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: "infraMachineSubstitutions",
+			Definitions: []clusterv1.PatchDefinition{
+				{
+					Selector: bothMachineTemplates,
+					JSONPatches: []clusterv1.JSONPatch{
+						{
+							Op:        "replace",
+							Path:      "/spec/template/spec/server",
+							ValueFrom: &clusterv1.JSONPatchValue{Variable: pointer.String(infraServerVariable + ".url")},
+						},
+						{
+							Op:        "replace",
+							Path:      "/spec/template/spec/thumbprint",
+							ValueFrom: &clusterv1.JSONPatchValue{Variable: pointer.String(infraServerVariable + ".thumbprint")},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:      "datastore",
+			EnabledIf: pointer.String(fmt.Sprintf("{{ if .%s }}true{{ end }}", datastoreVariable)),
+			Definitions: []clusterv1.PatchDefinition{
+				{
+					Selector: bothMachineTemplates,
+					JSONPatches: []clusterv1.JSONPatch{
+						{
+							Op:        "replace",
+							Path:      "/spec/template/spec/datastore",
+							ValueFrom: &clusterv1.JSONPatchValue{Variable: pointer.String(datastoreVariable)},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:      "network",
+			EnabledIf: pointer.String(fmt.Sprintf("{{ if .%s }}true{{ end }}", networkVariable)),
+			Definitions: []clusterv1.PatchDefinition{
+				{
+					Selector: bothMachineTemplates,
+					JSONPatches: []clusterv1.JSONPatch{
+						{
+							Op:        "replace",
+							Path:      "/spec/template/spec/network/devices/0/networkName",
+							ValueFrom: &clusterv1.JSONPatchValue{Variable: pointer.String(networkVariable)},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: "machineResources",
+			Definitions: []clusterv1.PatchDefinition{
+				{
+					Selector:    controlPlaneMachineTemplate,
+					JSONPatches: machineResourcesPatches(controlPlaneMachineVariable),
+				},
+				{
+					Selector:    workerMachineTemplate,
+					JSONPatches: machineResourcesPatches(workerMachineVariable),
+				},
+			},
+		},
+	}
+}
+
+// machineResourcesPatches returns the patches sizing a VSphereMachineTemplate
+// from a variable returned by machineVariable.
+func machineResourcesPatches(variable string) []clusterv1.JSONPatch {
+	patches := []clusterv1.JSONPatch{}
+	for _, field := range []string{"numCPUs", "memoryMiB", "diskGiB"} {
+		patches = append(patches, clusterv1.JSONPatch{
+			Op:        "replace",
+			Path:      "/spec/template/spec/" + field,
+			ValueFrom: &clusterv1.JSONPatchValue{Variable: pointer.String(variable + "." + field)},
+		})
+	}
+	return patches
+}
+
+// kubeVIPFilesTemplate returns the template of the files of the control plane
+// machines, which write the kube-vip static pod advertising the address of
+// the control plane endpoint.
+func kubeVIPFilesTemplate() string {
+	pod := kubeVIPPod(env.VipNetworkInterfaceVar, fmt.Sprintf("{{ .%s }}", controlPlaneIPAddrVariable))
+	var sb strings.Builder
+	sb.WriteString("- owner: root:root\n")
+	sb.WriteString("  path: /etc/kubernetes/manifests/kube-vip.yaml\n")
+	sb.WriteString("  content: |\n")
+	for _, line := range strings.Split(strings.TrimSuffix(pod, "\n"), "\n") {
+		sb.WriteString("    " + line + "\n")
+	}
+	return sb.String()
+}
+
+// usersTemplate returns the template of the users of the machines.
+func usersTemplate() string {
+	return fmt.Sprintf("- name: capv\n  sshAuthorizedKeys:\n  - '{{ .%s }}'\n  sudo: ALL=(ALL) NOPASSWD:ALL", sshKeyVariable)
+}
+
+func jsonValue(raw string) *apiextensionsv1.JSON {
+	return &apiextensionsv1.JSON{Raw: []byte(raw)}
+}
+
+// newVSphereClusterTemplate returns the template of the VSphereCluster of a
+// ClusterClass. Its server, identity and control plane endpoint are replaced
+// by the patches of the ClusterClass.
+func newVSphereClusterTemplate() infrav1.VSphereClusterTemplate {
+	return infrav1.VSphereClusterTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: infrav1.GroupVersion.String(),
+			Kind:       util.TypeToKind(&infrav1.VSphereClusterTemplate{}),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      env.ClusterClassNameVar,
+			Namespace: env.NamespaceVar,
+		},
+		Spec: infrav1.VSphereClusterTemplateSpec{
+			Template: infrav1.VSphereClusterTemplateResource{
+				Spec: infrav1.VSphereClusterSpec{
+					Server:     env.VSphereServerVar,
+					Thumbprint: env.VSphereThumbprint,
+				},
+			},
+		},
+	}
+}
+
+func newClusterClassVSphereMachineTemplate(name string) infrav1.VSphereMachineTemplate {
+	machineTemplate := newVSphereMachineTemplate()
+	machineTemplate.Name = name
+	return machineTemplate
+}
+
+// newKubeadmControlPlaneTemplate returns the template of the control plane of
+// a ClusterClass. Its files and users are set by the patches of the
+// ClusterClass.
+func newKubeadmControlPlaneTemplate() controlplanev1.KubeadmControlPlaneTemplate {
+	kubeadmConfigSpec := defaultKubeadmInitSpec(nil)
+	kubeadmConfigSpec.Users = nil
+	return controlplanev1.KubeadmControlPlaneTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: controlplanev1.GroupVersion.String(),
+			Kind:       util.TypeToKind(&controlplanev1.KubeadmControlPlaneTemplate{}),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      env.ClusterClassNameVar + env.ControlPlaneTemplateNameSuffix,
+			Namespace: env.NamespaceVar,
+		},
+		Spec: controlplanev1.KubeadmControlPlaneTemplateSpec{
+			Template: controlplanev1.KubeadmControlPlaneTemplateResource{
+				Spec: controlplanev1.KubeadmControlPlaneTemplateResourceSpec{
+					KubeadmConfigSpec: kubeadmConfigSpec,
+				},
+			},
+		},
+	}
+}
+
+func newClusterClassKubeadmConfigTemplate() bootstrapv1.KubeadmConfigTemplate {
+	kubeadmConfigTemplate := newKubeadmConfigTemplate()
+	kubeadmConfigTemplate.Name = env.ClusterClassNameVar + env.WorkerBootstrapTemplateNameSuffix
+	kubeadmConfigTemplate.Spec.Template.Spec.Users = nil
+	return kubeadmConfigTemplate
+}
+
+// newClusterTopologyCluster returns a Cluster whose topology is defined by
+// the ClusterClass. The replicas of its control plane and MachineDeployment
+// are replaced by variables of the template; see util.PrintObjects.
+func newClusterTopologyCluster() clusterv1.Cluster {
+	return clusterv1.Cluster{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       util.TypeToKind(&clusterv1.Cluster{}),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      env.ClusterNameVar,
+			Namespace: env.NamespaceVar,
+			Labels:    clusterLabels(),
+		},
+		Spec: clusterv1.ClusterSpec{
+			ClusterNetwork: &clusterv1.ClusterNetwork{
+				Pods: &clusterv1.NetworkRanges{
+					CIDRBlocks: []string{env.DefaultClusterCIDR},
+				},
+			},
+			Topology: &clusterv1.Topology{
+				Class:   env.ClusterClassNameVar,
+				Version: env.KubernetesVersionVar,
+				Variables: []clusterv1.ClusterVariable{
+					{
+						Name:  sshKeyVariable,
+						Value: *jsonValue(fmt.Sprintf("%q", env.VSphereSSHAuthorizedKeysVar)),
+					},
+					{
+						Name:  controlPlaneIPAddrVariable,
+						Value: *jsonValue(fmt.Sprintf("%q", env.ControlPlaneEndpointVar)),
+					},
+					{
+						Name:  credsSecretNameVariable,
+						Value: *jsonValue(fmt.Sprintf("%q", env.ClusterNameVar)),
+					},
+					{
+						Name:  infraServerVariable,
+						Value: *jsonValue(fmt.Sprintf(`{"url":%q,"thumbprint":%q}`, env.VSphereServerVar, env.VSphereThumbprint)),
+					},
+				},
+			},
+		},
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package env
 
 const (
+	ClusterClassNameVar         = "${CLUSTER_CLASS_NAME}"
 	ClusterNameVar              = "${CLUSTER_NAME}"
 	ControlPlaneMachineCountVar = "${CONTROL_PLANE_MACHINE_COUNT}"
 	DefaultCloudProviderImage   = "gcr.io/cloud-provider-vsphere/cpi/release/manager:v1.2.1"
@@ -33,6 +34,7 @@ const (
 	VSphereFolderVar            = "${VSPHERE_FOLDER}"
 	VSphereHaproxyTemplateVar   = "${VSPHERE_HAPROXY_TEMPLATE}"
 	VSphereNetworkVar           = "${VSPHERE_NETWORK}"
+	VSphereWorkloadNetworkVar   = "${VSPHERE_WORKLOAD_NETWORK}"
 	VSphereResourcePoolVar      = "${VSPHERE_RESOURCE_POOL}"
 	VSphereServerVar            = "${VSPHERE_SERVER}"
 	VSphereSSHAuthorizedKeysVar = "${VSPHERE_SSH_AUTHORIZED_KEY}"
@@ -45,4 +47,16 @@ const (
 	VSphereUsername              = "${VSPHERE_USERNAME}"
 	VSpherePassword              = "${VSPHERE_PASSWORD}" /* #nosec */
 	ClusterResourceSetNameSuffix = "-crs-0"
+	// Suffixes of the names of the templates of a ClusterClass, and name of
+	// the MachineDeployment of the cluster-topology flavor.
+	ControlPlaneTemplateNameSuffix     = "-controlplane"
+	ControlPlaneMachineTemplateSuffix  = "-controlplane-template"
+	WorkerMachineDeploymentClassSuffix = "-worker"
+	WorkerMachineTemplateNameSuffix    = "-worker-machinetemplate"
+	WorkerBootstrapTemplateNameSuffix  = "-worker-bootstrap-template"
+	TopologyMachineDeploymentName      = "md-0"
+	// Names of the network devices of the multi-homed flavor; kube-vip binds
+	// to the management device.
+	ManagementNetworkDeviceName = "eth0"
+	WorkloadNetworkDeviceName   = "eth1"
 )
@@ -20,12 +20,44 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/packaging/flavorgen/flavors/crs"
+	"sigs.k8s.io/cluster-api-provider-vsphere/packaging/flavorgen/flavors/env"
 )
 
 func MultiNodeTemplateWithKubeVIP() []runtime.Object {
 	vsphereCluster := newVSphereCluster()
 	machineTemplate := newVSphereMachineTemplate()
-	controlPlane := newKubeadmControlplane(444, machineTemplate, newKubeVIPFiles())
+	controlPlane := newKubeadmControlplane(444, machineTemplate, newKubeVIPFiles(env.VipNetworkInterfaceVar))
+	kubeadmJoinTemplate := newKubeadmConfigTemplate()
+	cluster := newCluster(vsphereCluster, &controlPlane)
+	machineDeployment := newMachineDeployment(cluster, machineTemplate, kubeadmJoinTemplate)
+	clusterResourceSet := newClusterResourceSet(cluster)
+	crsResourcesCSI := crs.CreateCrsResourceObjectsCSI(&clusterResourceSet)
+	crsResourcesCPI := crs.CreateCrsResourceObjectsCPI(&clusterResourceSet)
+	identitySecret := newIdentitySecret()
+
+	MultiNodeTemplate := []runtime.Object{
+		&cluster,
+		&vsphereCluster,
+		&machineTemplate,
+		&controlPlane,
+		&kubeadmJoinTemplate,
+		&machineDeployment,
+		&clusterResourceSet,
+		&identitySecret,
+	}
+
+	MultiNodeTemplate = append(MultiNodeTemplate, crsResourcesCSI...)
+	MultiNodeTemplate = append(MultiNodeTemplate, crsResourcesCPI...)
+
+	return MultiNodeTemplate
+}
+
+// MultiNodeTemplateWithKubeVIPMultiHomed returns a kube-vip template whose
+// machines have a management and a workload network device.
+func MultiNodeTemplateWithKubeVIPMultiHomed() []runtime.Object {
+	vsphereCluster := newVSphereCluster()
+	machineTemplate := newMultiHomedVSphereMachineTemplate()
+	controlPlane := newKubeadmControlplane(444, machineTemplate, newKubeVIPFiles(env.ManagementNetworkDeviceName))
 	kubeadmJoinTemplate := newKubeadmConfigTemplate()
 	cluster := newCluster(vsphereCluster, &controlPlane)
 	machineDeployment := newMachineDeployment(cluster, machineTemplate, kubeadmJoinTemplate)
@@ -78,3 +110,43 @@ func MultiNodeTemplateWithExternalLoadBalancer() []runtime.Object {
 
 	return MultiNodeTemplate
 }
+
+// ClusterClassTemplateWithKubeVIP returns a ClusterClass and its templates,
+// whose clusters are customized by the variables of their topology.
+func ClusterClassTemplateWithKubeVIP() []runtime.Object {
+	clusterClass := newClusterClass()
+	vsphereClusterTemplate := newVSphereClusterTemplate()
+	controlPlaneTemplate := newKubeadmControlPlaneTemplate()
+	controlPlaneMachineTemplate := newClusterClassVSphereMachineTemplate(env.ClusterClassNameVar + env.ControlPlaneMachineTemplateSuffix)
+	workerMachineTemplate := newClusterClassVSphereMachineTemplate(env.ClusterClassNameVar + env.WorkerMachineTemplateNameSuffix)
+	workerBootstrapTemplate := newClusterClassKubeadmConfigTemplate()
+
+	return []runtime.Object{
+		&clusterClass,
+		&vsphereClusterTemplate,
+		&controlPlaneTemplate,
+		&controlPlaneMachineTemplate,
+		&workerMachineTemplate,
+		&workerBootstrapTemplate,
+	}
+}
+
+// ClusterTopologyTemplate returns a cluster whose topology is defined by the
+// ClusterClass of ClusterClassTemplateWithKubeVIP.
+func ClusterTopologyTemplate() []runtime.Object {
+	cluster := newClusterTopologyCluster()
+	clusterResourceSet := newClusterResourceSet(cluster)
+	crsResourcesCSI := crs.CreateCrsResourceObjectsCSI(&clusterResourceSet)
+	crsResourcesCPI := crs.CreateCrsResourceObjectsCPI(&clusterResourceSet)
+	identitySecret := newIdentitySecret()
+
+	ClusterTopologyTemplate := []runtime.Object{
+		&cluster,
+		&clusterResourceSet,
+		&identitySecret,
+	}
+	ClusterTopologyTemplate = append(ClusterTopologyTemplate, crsResourcesCSI...)
+	ClusterTopologyTemplate = append(ClusterTopologyTemplate, crsResourcesCPI...)
+
+	return ClusterTopologyTemplate
+}
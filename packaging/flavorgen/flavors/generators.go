@@ -146,6 +146,29 @@ func defaultVirtualMachineCloneSpec() infrav1.VirtualMachineCloneSpec {
 	}
 }
 
+// newMultiHomedVSphereMachineTemplate returns a machine template with a
+// management and a workload network device.
+func newMultiHomedVSphereMachineTemplate() infrav1.VSphereMachineTemplate {
+	machineTemplate := newVSphereMachineTemplate()
+	machineTemplate.Spec.Template.Spec.Network.Devices = []infrav1.NetworkDeviceSpec{
+		{
+			NetworkName: env.VSphereNetworkVar,
+			DeviceName:  env.ManagementNetworkDeviceName,
+			Role:        infrav1.NetworkDeviceRoleManagement,
+			DHCP4:       true,
+			DHCP6:       false,
+		},
+		{
+			NetworkName: env.VSphereWorkloadNetworkVar,
+			DeviceName:  env.WorkloadNetworkDeviceName,
+			Role:        infrav1.NetworkDeviceRoleWorkload,
+			DHCP4:       true,
+			DHCP6:       false,
+		},
+	}
+	return machineTemplate
+}
+
 func defaultKubeadmInitSpec(files []bootstrapv1.File) bootstrapv1.KubeadmConfigSpec {
 	return bootstrapv1.KubeadmConfigSpec{
 		InitConfiguration: &bootstrapv1.InitConfiguration{
@@ -237,7 +260,7 @@ func defaultPreKubeadmCommands() []string {
 	}
 }
 
-func kubeVIPPod() string {
+func kubeVIPPod(vipInterface, address string) string {
 	hostPathType := corev1.HostPathFileOrCreate
 	pod := &corev1.Pod{
 		TypeMeta: metav1.TypeMeta{
@@ -266,13 +289,13 @@ func kubeVIPPod() string {
 						{
 							// Interface that the vip should bind to
 							Name:  "vip_interface",
-							Value: env.VipNetworkInterfaceVar,
+							Value: vipInterface,
 						},
 						{
 							// VIP IP address
 							// 'vip_address' was replaced by 'address'
 							Name:  "address",
-							Value: env.ControlPlaneEndpointVar,
+							Value: address,
 						},
 						{
 							// VIP TCP port
@@ -426,12 +449,12 @@ func newMachineDeployment(cluster clusterv1.Cluster, machineTemplate infrav1.VSp
 	}
 }
 
-func newKubeVIPFiles() []bootstrapv1.File {
+func newKubeVIPFiles(vipInterface string) []bootstrapv1.File {
 	return []bootstrapv1.File{
 		{
 			Owner:   "root:root",
 			Path:    "/etc/kubernetes/manifests/kube-vip.yaml",
-			Content: kubeVIPPod(),
+			Content: kubeVIPPod(vipInterface, env.ControlPlaneEndpointVar),
 		},
 	}
 }
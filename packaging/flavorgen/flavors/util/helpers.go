@@ -34,6 +34,9 @@ type Replacement struct {
 	Name      string
 	Value     interface{}
 	FieldPath []string
+	// IfPresent is the path of a field the object must have for the
+	// replacement to apply, e.g. the topology of a Cluster.
+	IfPresent []string
 }
 
 var (
@@ -56,9 +59,30 @@ var (
 			Value:     map[string]interface{}{},
 			FieldPath: []string{"spec", "selector", "matchLabels"},
 		},
+		{
+			Kind:      "Cluster",
+			Name:      "${CLUSTER_NAME}",
+			Value:     env.ControlPlaneMachineCountVar,
+			FieldPath: []string{"spec", "topology", "controlPlane", "replicas"},
+			IfPresent: []string{"spec", "topology"},
+		},
+		{
+			Kind: "Cluster",
+			Name: "${CLUSTER_NAME}",
+			Value: []interface{}{
+				map[string]interface{}{
+					"class":    env.ClusterClassNameVar + env.WorkerMachineDeploymentClassSuffix,
+					"name":     env.TopologyMachineDeploymentName,
+					"replicas": env.WorkerMachineCountVar,
+				},
+			},
+			FieldPath: []string{"spec", "topology", "workers", "machineDeployments"},
+			IfPresent: []string{"spec", "topology"},
+		},
 	}
 
 	stringVars = []string{
+		regexVar(env.ClusterClassNameVar),
 		regexVar(env.ClusterNameVar),
 		regexVar(env.ClusterNameVar + env.MachineDeploymentNameSuffix),
 		regexVar(env.NamespaceVar),
@@ -74,6 +98,7 @@ var (
 		regexVar(env.VSphereTemplateVar),
 		regexVar(env.VSphereHaproxyTemplateVar),
 		regexVar(env.VSphereStoragePolicyVar),
+		regexVar(env.VSphereThumbprint),
 	}
 )
 
@@ -139,6 +164,11 @@ func GenerateObjectYAML(obj runtime.Object, replacements []Replacement) string {
 	for _, v := range replacements {
 		v := v
 		if v.Name == data.GetName() && v.Kind == data.GetKind() {
+			if v.IfPresent != nil {
+				if _, found, _ := unstructured.NestedFieldNoCopy(data.Object, v.IfPresent...); !found {
+					continue
+				}
+			}
 			if err := unstructured.SetNestedField(data.Object, v.Value, v.FieldPath...); err != nil {
 				panic(err)
 			}
@@ -0,0 +1,249 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records the operations of the provider which mutate the
+// inventory of vCenter, such as the clones, reconfigurations and deletions of
+// the VMs, as Kubernetes events on the objects on behalf of which they are
+// performed and, optionally, as JSON records sent to a sink, so that the
+// changes of the infrastructure made by the provider can be reviewed.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+const (
+	// EventReason is the reason of the events of the operations which
+	// succeeded.
+	EventReason = "VCenterMutation"
+	// FailedEventReason is the reason of the events of the operations which
+	// failed.
+	FailedEventReason = "VCenterMutationFailed"
+
+	defaultSinkTimeout = 10 * time.Second
+	// defaultQueueSize is the number of records waiting to be sent to the
+	// sink, beyond which the records are dropped.
+	defaultQueueSize = 1000
+)
+
+// Result is the result of an operation.
+type Result string
+
+const (
+	// Succeeded is the result of an operation, or of its task, which
+	// succeeded.
+	Succeeded Result = "Succeeded"
+	// Failed is the result of an operation, or of its task, which failed.
+	Failed Result = "Failed"
+	// Submitted is the result of an operation which created a task, whose
+	// result is recorded separately once its completion is observed.
+	Submitted Result = "Submitted"
+)
+
+// Record is the audit record of an operation mutating vCenter.
+type Record struct {
+	Time metav1.Time `json:"time"`
+	// Server is the vCenter in which the operation is performed, and User
+	// the user of the session performing it.
+	Server string `json:"server"`
+	User   string `json:"user"`
+	// Controller is the controller performing the operation, and Kind,
+	// Namespace and Name identify the object on behalf of which it is
+	// performed. They are empty for the operations performed outside of the
+	// reconciliation of an object.
+	Controller string `json:"controller,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// Operation is the SOAP method, e.g. CloneVM_Task, or the HTTP method
+	// and path of the REST request, e.g. POST /api/vcenter/tagging/tag.
+	Operation string `json:"operation"`
+	// Target is the managed object the operation is performed on, e.g.
+	// VirtualMachine:vm-42.
+	Target string `json:"target,omitempty"`
+	// Task is the task of the operation, e.g. task-123.
+	Task   string `json:"task,omitempty"`
+	Result Result `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Owner is the object on behalf of which vCenter is mutated, on which the
+// events of the operations are recorded.
+type Owner struct {
+	Object     client.Object
+	Kind       string
+	Controller string
+	Recorder   record.Recorder
+}
+
+type ownerKey struct{}
+
+// OwnerKey is the key of the Owner of the operations in the values of a
+// context. The contexts of the controllers return their object for it.
+var OwnerKey = ownerKey{}
+
+// WithOwner returns a copy of the context with the Owner of the operations.
+func WithOwner(ctx context.Context, owner Owner) context.Context {
+	return context.WithValue(ctx, OwnerKey, owner)
+}
+
+// OwnerFrom returns the Owner of the operations performed with the context.
+func OwnerFrom(ctx context.Context) (Owner, bool) {
+	owner, ok := ctx.Value(OwnerKey).(Owner)
+	return owner, ok && owner.Object != nil
+}
+
+// Auditor records the operations. A nil Auditor records nothing.
+type Auditor struct {
+	sink   Sink
+	logger logr.Logger
+
+	// queue holds the records waiting to be sent to the sink by a single
+	// worker, which closes done once queue is closed and drained.
+	mu     sync.RWMutex
+	closed bool
+	queue  chan Record
+	done   chan struct{}
+}
+
+// NewAuditor returns an Auditor recording the events of the operations, and
+// sending their records to the sink in the background unless it is nil.
+func NewAuditor(sink Sink, logger logr.Logger) *Auditor {
+	a := &Auditor{sink: sink, logger: logger}
+	if sink != nil {
+		a.queue = make(chan Record, defaultQueueSize)
+		a.done = make(chan struct{})
+		go a.send()
+	}
+	return a
+}
+
+// Close sends the queued records to the sink and stops sending the
+// records, the records recorded afterwards are only recorded as events.
+func (a *Auditor) Close() {
+	if a == nil || a.queue == nil {
+		return
+	}
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	<-a.done
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultAuditor *Auditor
+)
+
+// SetAuditor sets the Auditor of the operations of the vCenter sessions.
+func SetAuditor(a *Auditor) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultAuditor = a
+}
+
+// GetAuditor returns the Auditor of the operations of the vCenter sessions, nil
+// if they are not recorded.
+func GetAuditor() *Auditor {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultAuditor
+}
+
+// Record records the operation on its owner, if any, and queues its record
+// for the sink. A record is dropped when the queue is full, and the failures
+// of the sink are logged, they do not fail the operation.
+func (a *Auditor) Record(owner *Owner, r Record) {
+	if a == nil {
+		return
+	}
+	if r.Time.IsZero() {
+		r.Time = metav1.Now()
+	}
+	if owner != nil && owner.Object != nil {
+		r.Controller = owner.Controller
+		r.Kind = owner.Kind
+		r.Namespace = owner.Object.GetNamespace()
+		r.Name = owner.Object.GetName()
+		if owner.Recorder != nil {
+			if r.Result == Failed {
+				owner.Recorder.Warn(owner.Object, FailedEventReason, r.message())
+			} else {
+				owner.Recorder.Event(owner.Object, EventReason, r.message())
+			}
+		}
+	}
+	if a.queue == nil {
+		return
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.queue <- r:
+	default:
+		a.logger.Error(errors.New("the queue of the sink is full"), "dropped vCenter audit record", "operation", r.Operation, "target", r.Target, "task", r.Task)
+	}
+}
+
+// send sends the queued records to the sink until the queue is closed.
+func (a *Auditor) send() {
+	defer close(a.done)
+	for r := range a.queue {
+		body, err := json.Marshal(r)
+		if err != nil {
+			a.logger.Error(err, "failed to encode vCenter audit record", "operation", r.Operation)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), defaultSinkTimeout)
+		if err := a.sink.Send(ctx, body); err != nil {
+			a.logger.Error(err, "failed to send vCenter audit record", "operation", r.Operation, "target", r.Target, "task", r.Task)
+		}
+		cancel()
+	}
+}
+
+// message returns the message of the event of the record.
+func (r Record) message() string {
+	msg := fmt.Sprintf("%s by %s in %s", r.Operation, r.User, r.Server)
+	if r.Target != "" {
+		msg = fmt.Sprintf("%s on %s by %s in %s", r.Operation, r.Target, r.User, r.Server)
+	}
+	if r.Task != "" {
+		msg += fmt.Sprintf(", task %s", r.Task)
+	}
+	msg += fmt.Sprintf(": %s", r.Result)
+	if r.Error != "" {
+		msg += fmt.Sprintf(": %s", r.Error)
+	}
+	return msg
+}
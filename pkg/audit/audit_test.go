@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientrecord "k8s.io/client-go/tools/record"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+func TestRecord(t *testing.T) {
+	g := NewWithT(t)
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewSink(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	auditor := NewAuditor(sink, ctrllog.Log)
+
+	fakeRecorder := clientrecord.NewFakeRecorder(10)
+	owner := Owner{
+		Object:     &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "vm"}},
+		Kind:       "VSphereVM",
+		Controller: "vspherevm-controller",
+		Recorder:   record.New(fakeRecorder),
+	}
+	auditor.Record(&owner, Record{Server: "vcenter", User: "capv", Operation: "PowerOnVM_Task", Target: "VirtualMachine:vm-42", Task: "task-1", Result: Succeeded})
+	auditor.Record(&owner, Record{Server: "vcenter", User: "capv", Operation: "Destroy_Task", Target: "VirtualMachine:vm-42", Task: "task-2", Result: Failed, Error: "access denied"})
+	auditor.Record(nil, Record{Server: "vcenter", User: "capv", Operation: "CreateFolder", Target: "Folder:group-v3", Result: Succeeded})
+
+	// The operations are recorded as events on their owner.
+	g.Expect(<-fakeRecorder.Events).To(Equal("Normal VCenterMutation PowerOnVM_Task on VirtualMachine:vm-42 by capv in vcenter, task task-1: Succeeded"))
+	g.Expect(<-fakeRecorder.Events).To(Equal("Warning VCenterMutationFailed Destroy_Task on VirtualMachine:vm-42 by capv in vcenter, task task-2: Failed: access denied"))
+	g.Expect(fakeRecorder.Events).To(BeEmpty())
+
+	// The records are appended to the audit log, one per line, in the
+	// background; Close sends the queued records.
+	auditor.Close()
+	auditor.Record(nil, Record{Server: "vcenter", User: "capv", Operation: "Destroy_Task", Result: Succeeded})
+	content, err := os.ReadFile(path) //nolint:gosec
+	g.Expect(err).NotTo(HaveOccurred())
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	g.Expect(lines).To(HaveLen(3))
+	var r Record
+	g.Expect(json.Unmarshal([]byte(lines[1]), &r)).To(Succeed())
+	g.Expect(r.Time.IsZero()).To(BeFalse())
+	r.Time = metav1.Time{}
+	g.Expect(r).To(Equal(Record{
+		Server:     "vcenter",
+		User:       "capv",
+		Controller: "vspherevm-controller",
+		Kind:       "VSphereVM",
+		Namespace:  "ns",
+		Name:       "vm",
+		Operation:  "Destroy_Task",
+		Target:     "VirtualMachine:vm-42",
+		Task:       "task-2",
+		Result:     Failed,
+		Error:      "access denied",
+	}))
+	var unowned Record
+	g.Expect(json.Unmarshal([]byte(lines[2]), &unowned)).To(Succeed())
+	g.Expect(unowned.Kind).To(BeEmpty())
+	g.Expect(unowned.Operation).To(Equal("CreateFolder"))
+
+	// A nil Auditor records nothing.
+	(*Auditor)(nil).Record(&owner, Record{Operation: "PowerOffVM_Task"})
+	g.Expect(fakeRecorder.Events).To(BeEmpty())
+}
+
+// blockingSink blocks the records it is sent until unblock is closed.
+type blockingSink struct {
+	unblock chan struct{}
+	sent    chan []byte
+}
+
+func (s blockingSink) Send(_ context.Context, body []byte) error {
+	<-s.unblock
+	s.sent <- body
+	return nil
+}
+
+func TestRecordQueueFull(t *testing.T) {
+	g := NewWithT(t)
+
+	sink := blockingSink{unblock: make(chan struct{}), sent: make(chan []byte, defaultQueueSize+2)}
+	auditor := NewAuditor(sink, ctrllog.Log)
+
+	// Record is not held back by a sink which does not respond, the records
+	// beyond the size of the queue are dropped.
+	for i := 0; i < defaultQueueSize+10; i++ {
+		auditor.Record(nil, Record{Operation: "ReconfigVM_Task", Result: Succeeded})
+	}
+	close(sink.unblock)
+	auditor.Close()
+	g.Expect(len(sink.sent)).To(BeNumerically(">=", defaultQueueSize))
+	g.Expect(len(sink.sent)).To(BeNumerically("<=", defaultQueueSize+1))
+}
+
+func TestHTTPSink(t *testing.T) {
+	g := NewWithT(t)
+
+	received := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		received <- body
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(server.URL)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sink.Send(context.Background(), []byte(`{"operation":"CloneVM_Task"}`))).To(Succeed())
+	g.Expect(<-received).To(MatchJSON(`{"operation":"CloneVM_Task"}`))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	sink, err = NewSink(failing.URL)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(sink.Send(context.Background(), []byte(`{}`))).To(MatchError(ContainSubstring("unexpected status 503: unavailable")))
+
+	_, err = NewSink("")
+	g.Expect(err).To(HaveOccurred())
+	_, err = NewSink(filepath.Join(t.TempDir(), "missing", "audit.log"))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestOwnerFrom(t *testing.T) {
+	g := NewWithT(t)
+
+	_, ok := OwnerFrom(context.Background())
+	g.Expect(ok).To(BeFalse())
+	vm := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Name: "vm"}}
+	owner, ok := OwnerFrom(WithOwner(context.Background(), Owner{Object: vm, Kind: "VSphereVM"}))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(owner.Object).To(BeIdenticalTo(vm))
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Sink receives the JSON records of the operations.
+type Sink interface {
+	Send(ctx context.Context, record []byte) error
+}
+
+// NewSink returns the Sink of a target, which is either:
+//   - "-", for the records written to the standard output, one per line;
+//   - an http:// or https:// URL, to which each record is POSTed;
+//   - the path of a file, to which the records are appended, one per line.
+func NewSink(target string) (Sink, error) {
+	switch {
+	case target == "":
+		return nil, errors.New("the audit sink is empty")
+	case target == "-":
+		return &writerSink{w: os.Stdout}, nil
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		return httpSink(target), nil
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open the audit log %q", target)
+	}
+	return &writerSink{w: f}, nil
+}
+
+// writerSink writes the records to a writer, one per line.
+type writerSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Send implements Sink.
+func (s *writerSink) Send(_ context.Context, record []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(append(record, '\n'))
+	return err
+}
+
+// httpSink POSTs the records to a URL.
+type httpSink string
+
+// Send implements Sink.
+func (s httpSink) Send(ctx context.Context, record []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, string(s), bytes.NewReader(record))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
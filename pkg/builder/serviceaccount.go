@@ -0,0 +1,242 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builder
+
+import (
+	goctx "context"
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+)
+
+// The helpers below describe the contract of the ProviderServiceAccount
+// controller, so that the controllers consuming ProviderServiceAccounts can be
+// tested against it: the ServiceAccount, Role and RoleBinding of a
+// ProviderServiceAccount are named after it, the token of the ServiceAccount
+// is copied to the target secret in the guest cluster, and the ServiceAccount
+// is registered in the system service accounts ConfigMap while the cluster
+// exists.
+
+// FakeProviderServiceAccount returns a ProviderServiceAccount which grants
+// read access to PersistentVolumeClaims, and whose token is written to the
+// target secret in the target namespace of the guest cluster. The
+// ProviderServiceAccount is owned by the VSphereCluster, if any.
+func FakeProviderServiceAccount(namespace, name, targetNamespace, targetSecretName string, vSphereCluster *vmwarev1.VSphereCluster) *vmwarev1.ProviderServiceAccount {
+	pSvcAccount := &vmwarev1.ProviderServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: vmwarev1.ProviderServiceAccountSpec{
+			Rules: []rbacv1.PolicyRule{
+				{
+					Verbs:     []string{"get"},
+					APIGroups: []string{""},
+					Resources: []string{"persistentvolumeclaims"},
+				},
+			},
+			TargetNamespace:  targetNamespace,
+			TargetSecretName: targetSecretName,
+		},
+	}
+
+	if vSphereCluster != nil {
+		pSvcAccount.OwnerReferences = []metav1.OwnerReference{
+			{
+				APIVersion: vmwarev1.GroupVersion.String(),
+				Kind:       "VSphereCluster",
+				Name:       vSphereCluster.Name,
+				UID:        vSphereCluster.UID,
+				Controller: pointer.Bool(true),
+			},
+		}
+		pSvcAccount.Spec.Ref = &corev1.ObjectReference{
+			Name: vSphereCluster.Name,
+		}
+	}
+	return pSvcAccount
+}
+
+// FakeSystemServiceAccountsConfigMap returns the ConfigMap which registers
+// the given system service accounts.
+func FakeSystemServiceAccountsConfigMap(namespace, name string, serviceAccounts ...string) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Data: map[string]string{},
+	}
+	for _, serviceAccount := range serviceAccounts {
+		configMap.Data[serviceAccount] = "true"
+	}
+	return configMap
+}
+
+// SystemServiceAccountName returns the key of the ServiceAccount of a
+// ProviderServiceAccount in the system service accounts ConfigMap.
+func SystemServiceAccountName(pSvcAccount *vmwarev1.ProviderServiceAccount) string {
+	return fmt.Sprintf("system.serviceaccount.%s.%s", pSvcAccount.Namespace, pSvcAccount.Name)
+}
+
+// FakeServiceAccountTokenSecret returns a secret holding the token of a
+// ServiceAccount, as created by the token controller.
+func FakeServiceAccountTokenSecret(namespace, name, token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			"token": []byte(token),
+		},
+	}
+}
+
+// FakeTargetSecret returns a target secret in the guest cluster holding the
+// token, e.g. to simulate an outdated target secret.
+func FakeTargetSecret(namespace, name, token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			"token": []byte(token),
+		},
+	}
+}
+
+// SetServiceAccountTokenSecret does the job of the token controller, which
+// envtest does not run: it waits for the ServiceAccount, creates the secret
+// holding its token and references the secret from the ServiceAccount.
+func SetServiceAccountTokenSecret(ctx goctx.Context, c client.Client, namespace, name, secretName, token string) {
+	svcAccount := &corev1.ServiceAccount{}
+	AssertEventuallyExistsInNamespace(ctx, c, namespace, name, svcAccount)
+	ExpectWithOffset(1, c.Create(ctx, FakeServiceAccountTokenSecret(namespace, secretName, token))).To(Succeed())
+	svcAccount.Secrets = []corev1.ObjectReference{{Name: secretName}}
+	ExpectWithOffset(1, c.Update(ctx, svcAccount)).To(Succeed())
+}
+
+// RotateServiceAccountToken replaces the token in the secret of a
+// ServiceAccount, as done when the token is rotated.
+func RotateServiceAccountToken(ctx goctx.Context, c client.Client, namespace, secretName, token string) {
+	secret := &corev1.Secret{}
+	ExpectWithOffset(1, c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: secretName}, secret)).To(Succeed())
+	secret.Data = map[string][]byte{"token": []byte(token)}
+	ExpectWithOffset(1, c.Update(ctx, secret)).To(Succeed())
+}
+
+// AssertEventuallyExistsInNamespace asserts that the object eventually
+// exists, and reads it into obj.
+func AssertEventuallyExistsInNamespace(ctx goctx.Context, c client.Client, namespace, name string, obj client.Object) {
+	EventuallyWithOffset(2, func() error {
+		key := client.ObjectKey{Namespace: namespace, Name: name}
+		return c.Get(ctx, key, obj)
+	}).Should(Succeed())
+}
+
+// AssertNoServiceAccountEntities asserts that no ServiceAccount, Role or
+// RoleBinding is created in the namespace.
+func AssertNoServiceAccountEntities(ctx goctx.Context, c client.Client, namespace string) {
+	Consistently(func() int {
+		var serviceAccountList corev1.ServiceAccountList
+		err := c.List(ctx, &serviceAccountList, client.InNamespace(namespace))
+		Expect(err).ShouldNot(HaveOccurred())
+		return len(serviceAccountList.Items)
+	}, time.Second*3).Should(Equal(0))
+
+	Consistently(func() int {
+		var roleList rbacv1.RoleList
+		err := c.List(ctx, &roleList, client.InNamespace(namespace))
+		Expect(err).ShouldNot(HaveOccurred())
+		return len(roleList.Items)
+	}, time.Second*3).Should(Equal(0))
+
+	Consistently(func() int {
+		var roleBindingList rbacv1.RoleBindingList
+		err := c.List(ctx, &roleBindingList, client.InNamespace(namespace))
+		Expect(err).ShouldNot(HaveOccurred())
+		return len(roleBindingList.Items)
+	}, time.Second*3).Should(Equal(0))
+}
+
+// AssertTargetSecret asserts that the target secret in the guest cluster
+// eventually holds the token.
+func AssertTargetSecret(ctx goctx.Context, guestClient client.Client, namespace, name, token string) {
+	secret := &corev1.Secret{}
+	AssertEventuallyExistsInNamespace(ctx, guestClient, namespace, name, secret)
+	EventuallyWithOffset(2, func() []byte {
+		key := client.ObjectKey{Namespace: namespace, Name: name}
+		Expect(guestClient.Get(ctx, key, secret)).Should(Succeed())
+		return secret.Data["token"]
+	}).Should(Equal([]byte(token)))
+}
+
+// AssertTargetNamespace asserts whether the target namespace exists in the
+// guest cluster.
+func AssertTargetNamespace(ctx goctx.Context, guestClient client.Client, namespaceName string, isExist bool) {
+	namespace := &corev1.Namespace{}
+	err := guestClient.Get(ctx, client.ObjectKey{Name: namespaceName}, namespace)
+	if isExist {
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	} else {
+		ExpectWithOffset(1, apierrors.IsNotFound(err)).To(BeTrue())
+	}
+}
+
+// AssertSystemServiceAccountRegistered asserts whether the ServiceAccount of
+// the ProviderServiceAccount is registered in the system service accounts
+// ConfigMap.
+func AssertSystemServiceAccountRegistered(ctx goctx.Context, c client.Client, namespace, name string, pSvcAccount *vmwarev1.ProviderServiceAccount, isRegistered bool) {
+	configMap := &corev1.ConfigMap{}
+	ExpectWithOffset(1, c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, configMap)).To(Succeed())
+	if isRegistered {
+		ExpectWithOffset(1, configMap.Data).To(HaveKeyWithValue(SystemServiceAccountName(pSvcAccount), "true"))
+	} else {
+		ExpectWithOffset(1, configMap.Data).NotTo(HaveKey(SystemServiceAccountName(pSvcAccount)))
+	}
+}
+
+// AssertProviderServiceAccountsCondition asserts the
+// ProviderServiceAccountsReady condition of the VSphereCluster. The message
+// of the condition must contain the message, if any.
+func AssertProviderServiceAccountsCondition(vCluster *vmwarev1.VSphereCluster, status corev1.ConditionStatus,
+	message string, reason string, severity clusterv1.ConditionSeverity) {
+	c := conditions.Get(vCluster, vmwarev1.ProviderServiceAccountsReadyCondition)
+	ExpectWithOffset(1, c).NotTo(BeNil())
+	ExpectWithOffset(1, c.Status).To(Equal(status))
+	ExpectWithOffset(1, c.Reason).To(Equal(reason))
+	ExpectWithOffset(1, c.Severity).To(Equal(severity))
+	if message == "" {
+		ExpectWithOffset(1, c.Message).To(BeEmpty())
+	} else {
+		ExpectWithOffset(1, strings.Contains(c.Message, message)).To(BeTrue(), "expect condition message contains: %s, actual: %s", message, c.Message)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	goruntime "runtime"
 
 	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
@@ -34,6 +35,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
 	vmwarecontext "sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
 )
 
@@ -46,6 +48,10 @@ type TestSuite struct {
 	flags                 TestFlags
 	newReconcilerFn       NewReconcilerFunc
 	webhookName           string
+
+	// vcenter is the simulated vCenter behind the unit test contexts, once
+	// started with StartVCenter.
+	vcenter *fake.VCenter
 }
 
 func (s *TestSuite) isWebhookTest() bool {
@@ -60,6 +66,12 @@ type Reconciler interface {
 	ReconcileNormal(ctx *vmwarecontext.GuestClusterContext) (reconcile.Result, error)
 }
 
+// DeleteReconciler is a Reconciler which also reconciles the deletion of
+// clusters.
+type DeleteReconciler interface {
+	ReconcileDelete(ctx *vmwarecontext.ClusterContext) (reconcile.Result, error)
+}
+
 // NewReconcilerFunc is a base type for functions that return a reconciler.
 type NewReconcilerFunc func() Reconciler
 
@@ -81,6 +93,27 @@ func NewTestSuiteForController(newReconcilerFn NewReconcilerFunc) *TestSuite {
 	return testSuite
 }
 
+// StartVCenter starts a vCenter simulated by vcsim, with the given model or the
+// default vCenter model of vcsim if nil, behind the unit test contexts created
+// next: their controller manager context holds the credentials of the
+// simulator, so that the reconcilers under test exercise the clone, power and
+// task flows against it. Faults are injected with the methods of the returned
+// VCenter, which must be stopped with StopVCenter.
+func (s *TestSuite) StartVCenter(model *simulator.Model) *fake.VCenter {
+	vc, err := fake.NewVCenter(model)
+	Expect(err).NotTo(HaveOccurred())
+	s.vcenter = vc
+	return vc
+}
+
+// StopVCenter stops the simulated vCenter started with StartVCenter, if any.
+func (s *TestSuite) StopVCenter() {
+	if s.vcenter != nil {
+		s.vcenter.Destroy()
+		s.vcenter = nil
+	}
+}
+
 func (s *TestSuite) SetIntegrationTestClient(integrationTestClient client.Client) {
 	s.integrationTestClient = integrationTestClient
 }
@@ -147,6 +180,10 @@ func (s *TestSuite) NewUnitTestContextForControllerWithPrototypeCluster(initObje
 func (s *TestSuite) NewUnitTestContextForControllerWithVSphereCluster(vsphereCluster *vmwarev1.VSphereCluster, prototypeCluster bool, initObjects ...client.Object) *UnitTestContextForController {
 	if s.flags.UnitTestsEnabled {
 		ctx := NewUnitTestContextForController(s.newReconcilerFn, vsphereCluster, prototypeCluster, initObjects, nil)
+		if s.vcenter != nil {
+			s.vcenter.SetCredentials(ctx.ControllerManagerContext)
+			ctx.VCenter = s.vcenter
+		}
 		reconcileNormalAndExpectSuccess(ctx)
 		// Update the VSphereCluster and its status in the fake client.
 		Expect(ctx.Client.Update(ctx, ctx.VSphereCluster)).To(Succeed())
@@ -19,6 +19,7 @@ package builder
 import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 	vmoprv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -39,6 +40,10 @@ type UnitTestContextForController struct {
 
 	VirtualMachineImage *vmoprv1.VirtualMachineImage
 
+	// VCenter is the simulated vCenter of the suite, when it was started with
+	// TestSuite.StartVCenter.
+	VCenter *fake.VCenter
+
 	// reconciler is the builder.Reconciler being unit tested.
 	Reconciler Reconciler
 }
@@ -79,3 +84,14 @@ func (ctx UnitTestContextForController) ReconcileNormal() error {
 	_, err := ctx.Reconciler.ReconcileNormal(ctx.GuestClusterContext)
 	return err
 }
+
+// ReconcileDelete manually invokes the ReconcileDelete method on the
+// controller, which must be a DeleteReconciler.
+func (ctx UnitTestContextForController) ReconcileDelete() error {
+	reconciler, ok := ctx.Reconciler.(DeleteReconciler)
+	if !ok {
+		return errors.Errorf("%T does not reconcile deletions", ctx.Reconciler)
+	}
+	_, err := reconciler.ReconcileDelete(ctx.ClusterContext)
+	return err
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package clustermodules wraps the vCenter REST API used to manage cluster
+// modules, the construct vCenter DRS uses to place VMs on separate hosts.
+package clustermodules
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vapi/cluster"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Provider is the interface used to manage cluster modules and their members.
+type Provider interface {
+	// CreateModule creates a new cluster module in the compute cluster and
+	// returns its identifier.
+	CreateModule(ctx context.Context, clusterRef types.ManagedObjectReference) (string, error)
+	// DeleteModule deletes the cluster module.
+	DeleteModule(ctx context.Context, moduleID string) error
+	// DoesModuleExist reports whether the cluster module exists in the compute cluster.
+	DoesModuleExist(ctx context.Context, moduleID string, clusterRef types.ManagedObjectReference) (bool, error)
+	// IsMoRefModuleMember reports whether the VM is a member of the cluster module.
+	IsMoRefModuleMember(ctx context.Context, moduleID string, moRef types.ManagedObjectReference) (bool, error)
+	// AddMoRefToModule adds the VM to the cluster module if it is not yet a member.
+	AddMoRefToModule(ctx context.Context, moduleID string, moRef types.ManagedObjectReference) error
+	// RemoveMoRefFromModule removes the VM from the cluster module.
+	RemoveMoRefFromModule(ctx context.Context, moduleID string, moRef types.ManagedObjectReference) error
+}
+
+type provider struct {
+	manager *cluster.Manager
+}
+
+// NewProvider returns a Provider using the given vCenter REST client.
+func NewProvider(restClient *rest.Client) Provider {
+	return &provider{
+		manager: cluster.NewManager(restClient),
+	}
+}
+
+func (p *provider) CreateModule(ctx context.Context, clusterRef types.ManagedObjectReference) (string, error) {
+	moduleID, err := p.manager.CreateModule(ctx, clusterRef)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create cluster module for compute cluster %s", clusterRef.Value)
+	}
+	return moduleID, nil
+}
+
+func (p *provider) DeleteModule(ctx context.Context, moduleID string) error {
+	if err := p.manager.DeleteModule(ctx, moduleID); err != nil {
+		return errors.Wrapf(err, "failed to delete cluster module %s", moduleID)
+	}
+	return nil
+}
+
+func (p *provider) DoesModuleExist(ctx context.Context, moduleID string, clusterRef types.ManagedObjectReference) (bool, error) {
+	if moduleID == "" {
+		return false, nil
+	}
+	modules, err := p.manager.ListModules(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list cluster modules")
+	}
+	for _, module := range modules {
+		if module.Module == moduleID && module.Cluster == clusterRef.Value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *provider) IsMoRefModuleMember(ctx context.Context, moduleID string, moRef types.ManagedObjectReference) (bool, error) {
+	members, err := p.manager.ListModuleMembers(ctx, moduleID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to list members of cluster module %s", moduleID)
+	}
+	for _, member := range members {
+		if member == moRef {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *provider) AddMoRefToModule(ctx context.Context, moduleID string, moRef types.ManagedObjectReference) error {
+	isMember, err := p.IsMoRefModuleMember(ctx, moduleID, moRef)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		added, err := p.manager.AddModuleMembers(ctx, moduleID, moRef)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add %s to cluster module %s", moRef.Value, moduleID)
+		}
+		// vCenter only refuses to add VMs that are not part of the compute
+		// cluster of the module.
+		if !added {
+			return errors.Errorf("failed to add %s to cluster module %s, VM is not part of the module compute cluster", moRef.Value, moduleID)
+		}
+	}
+	return nil
+}
+
+func (p *provider) RemoveMoRefFromModule(ctx context.Context, moduleID string, moRef types.ManagedObjectReference) error {
+	if _, err := p.manager.RemoveModuleMembers(ctx, moduleID, moRef); err != nil {
+		return errors.Wrapf(err, "failed to remove %s from cluster module %s", moRef.Value, moduleID)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustermodules
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+
+	// run init func to register the cluster modules API endpoints.
+	_ "github.com/vmware/govmomi/vapi/cluster/simulator"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+func TestProvider(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		g := NewWithT(t)
+
+		restClient := rest.NewClient(vc)
+		g.Expect(restClient.Login(ctx, simulator.DefaultLogin)).To(Succeed())
+		provider := NewProvider(restClient)
+
+		clusterRef := simulator.Map.Any("ClusterComputeResource").Reference()
+		vm, err := find.NewFinder(vc).VirtualMachine(ctx, "DC0_C0_RP0_VM0")
+		g.Expect(err).NotTo(HaveOccurred())
+		vmRef := vm.Reference()
+		standaloneVM, err := find.NewFinder(vc).VirtualMachine(ctx, "DC0_H0_VM0")
+		g.Expect(err).NotTo(HaveOccurred())
+
+		moduleID, err := provider.CreateModule(ctx, clusterRef)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(moduleID).NotTo(BeEmpty())
+
+		exists, err := provider.DoesModuleExist(ctx, moduleID, clusterRef)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(exists).To(BeTrue())
+
+		exists, err = provider.DoesModuleExist(ctx, moduleID, types.ManagedObjectReference{Type: "ClusterComputeResource", Value: "enoent"})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(exists).To(BeFalse())
+
+		// VMs outside of the compute cluster cannot be added.
+		g.Expect(provider.AddMoRefToModule(ctx, moduleID, standaloneVM.Reference())).NotTo(Succeed())
+
+		// Adding the same VM twice is a no-op.
+		g.Expect(provider.AddMoRefToModule(ctx, moduleID, vmRef)).To(Succeed())
+		g.Expect(provider.AddMoRefToModule(ctx, moduleID, vmRef)).To(Succeed())
+		isMember, err := provider.IsMoRefModuleMember(ctx, moduleID, vmRef)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(isMember).To(BeTrue())
+
+		g.Expect(provider.RemoveMoRefFromModule(ctx, moduleID, vmRef)).To(Succeed())
+		isMember, err = provider.IsMoRefModuleMember(ctx, moduleID, vmRef)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(isMember).To(BeFalse())
+
+		g.Expect(provider.DeleteModule(ctx, moduleID)).To(Succeed())
+		exists, err = provider.DoesModuleExist(ctx, moduleID, clusterRef)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(exists).To(BeFalse())
+	})
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1beta2 mirrors the clusterv1.Conditions of the objects of this provider
+// into the metav1.Condition based status.v1beta2.conditions.
+package v1beta2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// Setter is an object exposing both the clusterv1.Conditions
+// and the status.v1beta2 mirror of its conditions.
+type Setter interface {
+	conditions.Getter
+	GetV1Beta2Conditions() []metav1.Condition
+	SetV1Beta2Conditions([]metav1.Condition)
+}
+
+// Mirror mirrors the clusterv1.Conditions of obj into its
+// status.v1beta2 conditions. It must be called after the summary condition is
+// computed, right before the object is patched.
+func Mirror(obj Setter) {
+	v1beta2Conditions := Convert(obj.GetConditions(), obj.GetGeneration())
+	if v1beta2Conditions == nil && obj.GetV1Beta2Conditions() == nil {
+		return
+	}
+	obj.SetV1Beta2Conditions(v1beta2Conditions)
+}
+
+// Convert converts clusterv1.Conditions into metav1.Conditions.
+// metav1.Condition requires a reason, so a True condition without a reason is
+// mirrored with its condition type as reason, and a False or Unknown condition
+// without a reason with infrav1.NoReasonReportedReason.
+func Convert(in clusterv1.Conditions, observedGeneration int64) []metav1.Condition {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]metav1.Condition, 0, len(in))
+	for _, c := range in {
+		reason := c.Reason
+		if reason == "" {
+			reason = infrav1.NoReasonReportedReason
+			if c.Status == corev1.ConditionTrue {
+				reason = string(c.Type)
+			}
+		}
+		out = append(out, metav1.Condition{
+			Type:               string(c.Type),
+			Status:             metav1.ConditionStatus(c.Status),
+			ObservedGeneration: observedGeneration,
+			LastTransitionTime: c.LastTransitionTime,
+			Reason:             reason,
+			Message:            c.Message,
+		})
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestMirror(t *testing.T) {
+	g := NewWithT(t)
+
+	now := metav1.Now()
+	vm := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Generation: 3}}
+	Mirror(vm)
+	g.Expect(vm.Status.V1Beta2).To(BeNil())
+
+	vm.Status.Conditions = clusterv1.Conditions{
+		{Type: clusterv1.ReadyCondition, Status: corev1.ConditionTrue, LastTransitionTime: now},
+		{
+			Type:               infrav1.VMProvisionedCondition,
+			Status:             corev1.ConditionFalse,
+			Severity:           clusterv1.ConditionSeverityInfo,
+			Reason:             infrav1.CloningReason,
+			Message:            "cloning",
+			LastTransitionTime: now,
+		},
+		{Type: infrav1.VCenterAvailableCondition, Status: corev1.ConditionUnknown, LastTransitionTime: now},
+	}
+	Mirror(vm)
+	g.Expect(vm.GetV1Beta2Conditions()).To(Equal([]metav1.Condition{
+		{Type: "Ready", Status: metav1.ConditionTrue, ObservedGeneration: 3, LastTransitionTime: now, Reason: "Ready"},
+		{Type: "VMProvisioned", Status: metav1.ConditionFalse, ObservedGeneration: 3, LastTransitionTime: now, Reason: infrav1.CloningReason, Message: "cloning"},
+		{Type: "VCenterAvailable", Status: metav1.ConditionUnknown, ObservedGeneration: 3, LastTransitionTime: now, Reason: infrav1.NoReasonReportedReason},
+	}))
+
+	vm.Status.Conditions = nil
+	Mirror(vm)
+	g.Expect(vm.Status.V1Beta2).NotTo(BeNil())
+	g.Expect(vm.GetV1Beta2Conditions()).To(BeEmpty())
+}
+
+func TestMirror_VSphereResourceQuota(t *testing.T) {
+	g := NewWithT(t)
+
+	now := metav1.Now()
+	quota := &infrav1.VSphereResourceQuota{ObjectMeta: metav1.ObjectMeta{Generation: 1}}
+	quota.Status.Conditions = clusterv1.Conditions{
+		{
+			Type:               infrav1.ResourceQuotaSatisfiedCondition,
+			Status:             corev1.ConditionFalse,
+			Severity:           clusterv1.ConditionSeverityWarning,
+			Reason:             infrav1.ResourceQuotaExceededReason,
+			Message:            "numCPUs: 10 > 8",
+			LastTransitionTime: now,
+		},
+	}
+	Mirror(quota)
+	g.Expect(quota.Status.V1Beta2).NotTo(BeNil())
+	g.Expect(quota.GetV1Beta2Conditions()).To(Equal([]metav1.Condition{
+		{Type: "ResourceQuotaSatisfied", Status: metav1.ConditionFalse, ObservedGeneration: 1, LastTransitionTime: now, Reason: infrav1.ResourceQuotaExceededReason, Message: "numCPUs: 10 > 8"},
+	}))
+}
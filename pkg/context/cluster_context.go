@@ -25,6 +25,8 @@ import (
 	"sigs.k8s.io/cluster-api/util/patch"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/audit"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
 )
 
 // ClusterContext is a Go context used with a VSphereCluster.
@@ -41,6 +43,16 @@ func (c *ClusterContext) String() string {
 	return fmt.Sprintf("%s %s/%s", c.VSphereCluster.GroupVersionKind(), c.VSphereCluster.Namespace, c.VSphereCluster.Name)
 }
 
+// Value returns the VSphereCluster as the owner of the vCenter operations
+// audited with the context, and the values of the controller context for the
+// other keys.
+func (c *ClusterContext) Value(key interface{}) interface{} {
+	if key == audit.OwnerKey && c.VSphereCluster != nil {
+		return audit.Owner{Object: c.VSphereCluster, Kind: "VSphereCluster", Controller: c.Name, Recorder: c.Recorder}
+	}
+	return c.ControllerContext.Value(key)
+}
+
 // Patch updates the object and its status on the API server.
 func (c *ClusterContext) Patch() error {
 	// always update the readyCondition.
@@ -49,6 +61,7 @@ func (c *ClusterContext) Patch() error {
 			infrav1.VCenterAvailableCondition,
 		),
 	)
+	v1beta2conditions.Mirror(c.VSphereCluster)
 
 	return c.PatchHelper.Patch(c, c.VSphereCluster)
 }
@@ -24,10 +24,15 @@ import (
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/hooks"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/notify"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/quota"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
 )
 
 // ControllerManagerContext is the context of the controller that owns the
@@ -54,6 +59,11 @@ type ControllerManagerContext struct {
 	// no value is specified then all namespaces are watched.
 	WatchNamespace string
 
+	// WatchNamespaces are the namespaces the controllers watch for changes,
+	// in addition to Namespace, when the controllers watch several
+	// namespaces.
+	WatchNamespaces []string
+
 	// Client is the controller manager's client.
 	Client client.Client
 
@@ -70,6 +80,11 @@ type ControllerManagerContext struct {
 	// controller will receive concurrently.
 	MaxConcurrentReconciles int
 
+	// ControlPlaneMaxConcurrentReconciles is the maximum number of reconcile
+	// requests of the machines and VMs of control planes the machine and VM
+	// controllers will receive concurrently.
+	ControlPlaneMaxConcurrentReconciles int
+
 	// Username is the username for the account used to access remote vSphere
 	// endpoints.
 	Username string
@@ -86,10 +101,96 @@ type ControllerManagerContext struct {
 	// in keepalive handler
 	KeepAliveDuration time.Duration
 
+	// MaxConcurrentVCenterRequests is a session feature to limit the number
+	// of concurrent requests to each vCenter
+	MaxConcurrentVCenterRequests int
+
 	// NetworkProvider is the network provider used by Supervisor based clusters
 	NetworkProvider string
 
+	// VMInventoryInterval is the interval at which the VSphereVMInventory of
+	// each VSphereCluster is refreshed.
+	VMInventoryInterval time.Duration
+
+	// OrphanedVMGCInterval is the interval at which the VMs whose VSphereVM
+	// or Cluster no longer exists are garbage collected.
+	OrphanedVMGCInterval time.Duration
+
+	// ManagementClusterID identifies the management cluster on the VMs it
+	// clones.
+	ManagementClusterID string
+
+	// VMStatusBatchInterval is the minimum interval between two patches of
+	// a VSphereVM that only update its network or storage status.
+	VMStatusBatchInterval time.Duration
+
+	// CrossNamespaceRefPolicy is the policy applied to the object references
+	// pointing to another namespace than the one of the referencing object.
+	CrossNamespaceRefPolicy string
+
+	// RBACCleanupPolicy is the policy applied to the
+	// ServiceAccounts, Roles and RoleBindings of the ProviderServiceAccounts
+	// created by former versions of the controller.
+	RBACCleanupPolicy string
+
+	// ClusterPermissionAllowedPrivileges are the privileges the role of the
+	// permission of a cluster may hold in addition to the privileges CAPV
+	// requires.
+	ClusterPermissionAllowedPrivileges []string
+
+	// SystemServiceAccountRegistry is the name of the
+	// SystemServiceAccountRegistry listing the system service accounts,
+	// instead of the ConfigMap.
+	SystemServiceAccountRegistry string
+
+	// ProvisioningTimeouts are the default timeouts of the phases of the
+	// provisioning of VMs.
+	ProvisioningTimeouts ProvisioningTimeouts
+
+	// IPConflictProbeTimeout is the timeout of the ICMP probe of the static
+	// IP addresses of a VM before it is cloned.
+	IPConflictProbeTimeout time.Duration
+
+	// LifecycleHooks invokes the external hooks at the lifecycle points of
+	// VSphereVMs. A nil value invokes no hook.
+	LifecycleHooks *hooks.Runner
+
+	// MachineNotifier sends the lifecycle events of the VSphereMachines to
+	// external systems. A nil value sends no event.
+	MachineNotifier *notify.Notifier
+
+	// TaskThrottle limits the concurrent clone, reconfigure and power tasks
+	// issued to each vCenter. A nil value throttles no task.
+	TaskThrottle *throttle.Throttle
+
+	// QuotaAdmitter enforces the VSphereResourceQuotas when the VMs are
+	// cloned. A nil value enforces no quota.
+	QuotaAdmitter *quota.Admitter
+
+	// CostWeights are the hourly costs of the resources of the VMs used to
+	// estimate the cost of the machines and the clusters.
+	CostWeights CostWeights
+
+	// SnapshotChainLimit is the maximum length of the chain of snapshots a
+	// VM runs on before its snapshots block its reconfigurations and
+	// migrations. A zero value does not check the chains.
+	SnapshotChainLimit int
+
+	// AutoConsolidateDisks consolidates the disks of the VMs which need a
+	// consolidation.
+	AutoConsolidateDisks bool
+
+	// DriftCheckInterval is the interval at which the configuration of each
+	// ready VM is compared with its VSphereVM. A zero value does not check
+	// the drift.
+	DriftCheckInterval time.Duration
+
+	// AutoCorrectDrift corrects the reversible drift of the VMs when the
+	// drift is checked.
+	AutoCorrectDrift bool
+
 	genericEventCache sync.Map
+	waitCancelFuncs   sync.Map
 }
 
 // String returns ControllerManagerName.
@@ -97,6 +198,22 @@ func (c *ControllerManagerContext) String() string {
 	return c.Name
 }
 
+// IsWatchedNamespace returns whether the controllers watch a namespace.
+func (c *ControllerManagerContext) IsWatchedNamespace(namespace string) bool {
+	if len(c.WatchNamespaces) > 0 {
+		if namespace == c.Namespace {
+			return true
+		}
+		for _, ns := range c.WatchNamespaces {
+			if ns == namespace {
+				return true
+			}
+		}
+		return false
+	}
+	return c.WatchNamespace == "" || c.WatchNamespace == namespace
+}
+
 // GetGenericEventChannelFor returns a generic event channel for a resource
 // specified by the provided GroupVersionKind.
 func (c *ControllerManagerContext) GetGenericEventChannelFor(gvk schema.GroupVersionKind) chan event.GenericEvent {
@@ -106,3 +223,32 @@ func (c *ControllerManagerContext) GetGenericEventChannelFor(gvk schema.GroupVer
 	val, _ := c.genericEventCache.LoadOrStore(gvk, make(chan event.GenericEvent))
 	return val.(chan event.GenericEvent)
 }
+
+// WaitContextFor returns the context of the background waits on the vCenter
+// objects of the resource with the provided UID, such as the waits for the
+// completion of its tasks. The context is cancelled by CancelWaitsFor, or when
+// the controller manager stops.
+func (c *ControllerManagerContext) WaitContextFor(uid types.UID) context.Context {
+	if val, ok := c.waitCancelFuncs.Load(uid); ok {
+		return val.(*waitContext).ctx
+	}
+	ctx, cancel := context.WithCancel(c)
+	val, loaded := c.waitCancelFuncs.LoadOrStore(uid, &waitContext{ctx: ctx, cancel: cancel})
+	if loaded {
+		cancel()
+	}
+	return val.(*waitContext).ctx
+}
+
+// CancelWaitsFor cancels the background waits of the resource with the
+// provided UID, once the resource is deleted.
+func (c *ControllerManagerContext) CancelWaitsFor(uid types.UID) {
+	if val, ok := c.waitCancelFuncs.LoadAndDelete(uid); ok {
+		val.(*waitContext).cancel()
+	}
+}
+
+type waitContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"strconv"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// CostWeights are the hourly costs of the resources of the VMs used to
+// estimate the cost of the machines and the clusters. The estimation is
+// disabled if all the weights are zero.
+type CostWeights struct {
+	// VCPUHour is the cost of a virtual processor for an hour.
+	VCPUHour float64
+
+	// MemoryGiBHour is the cost of a GiB of memory for an hour.
+	MemoryGiBHour float64
+
+	// DiskGiBHour is the cost of a GiB of disk for an hour.
+	DiskGiBHour float64
+}
+
+// Enabled returns whether the cost of the machines is estimated.
+func (w CostWeights) Enabled() bool {
+	return w.VCPUHour != 0 || w.MemoryGiBHour != 0 || w.DiskGiBHour != 0
+}
+
+// MachineHourlyCost returns the estimated hourly cost of the VM of a machine,
+// from the processors, the memory and the disks of its spec. The resources
+// not set in the spec, which are inherited from the template, are not counted.
+func (w CostWeights) MachineHourlyCost(spec *infrav1.VirtualMachineCloneSpec) float64 {
+	diskGiB := int64(spec.DiskGiB)
+	for _, disk := range spec.DataDisks {
+		diskGiB += int64(disk.SizeGiB)
+	}
+	return float64(spec.NumCPUs)*w.VCPUHour +
+		float64(spec.MemoryMiB)/1024*w.MemoryGiBHour +
+		float64(diskGiB)*w.DiskGiBHour
+}
+
+// FormatCost formats an estimated cost as the value of an annotation.
+func FormatCost(cost float64) string {
+	return strconv.FormatFloat(cost, 'f', 4, 64)
+}
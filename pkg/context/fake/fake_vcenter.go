@@ -0,0 +1,272 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	goctx "context"
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+
+	// run init func to register the tagging API endpoints.
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+// VCenter is a vCenter simulated by vcsim, for unit testing reconcilers
+// against the clone, power and task flows of vSphere without a real vCenter.
+// Faults and delays may be injected into its tasks and methods, and its
+// sessions expired.
+//
+// The simulator shares global state, so the tests using a VCenter must not
+// run in parallel, and must destroy it once done.
+type VCenter struct {
+	model  *simulator.Model
+	server *simulator.Server
+
+	mu           sync.Mutex
+	taskFaults   map[string]types.BaseMethodFault
+	taskDelays   map[string]time.Duration
+	methodFaults map[string]types.BaseMethodFault
+}
+
+// NewVCenter returns a VCenter simulating the given model, or the default
+// vCenter model of vcsim if nil: a datacenter DC0 with a cluster DC0_C0, hosts,
+// the datastore LocalDS_0, the network "VM Network" and the VMs DC0_H0_VM0,
+// DC0_H0_VM1, DC0_C0_RP0_VM0 and DC0_C0_RP0_VM1.
+func NewVCenter(model *simulator.Model) (*VCenter, error) {
+	if model == nil {
+		model = simulator.VPX()
+	}
+	if err := model.Create(); err != nil {
+		return nil, err
+	}
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+
+	vc := &VCenter{
+		model:        model,
+		taskFaults:   map[string]types.BaseMethodFault{},
+		taskDelays:   map[string]time.Duration{},
+		methodFaults: map[string]types.BaseMethodFault{},
+	}
+	simulator.Map.AddHandler(vc)
+	simulator.Map.Handler = vc.handleMethod
+	vc.server = model.Service.NewServer()
+	return vc, nil
+}
+
+// Destroy stops the simulator and removes its model.
+func (vc *VCenter) Destroy() {
+	vc.server.Close()
+	vc.model.Remove()
+}
+
+// Server returns the address of the simulator, to set as the server of the
+// VSphereVMs and VSphereClusters.
+func (vc *VCenter) Server() string {
+	return vc.server.URL.Host
+}
+
+// Username returns the user of the simulator.
+func (vc *VCenter) Username() string {
+	return vc.server.URL.User.Username()
+}
+
+// Password returns the password of the user of the simulator.
+func (vc *VCenter) Password() string {
+	password, _ := vc.server.URL.User.Password()
+	return password
+}
+
+// SetCredentials sets the credentials of the simulator in a controller
+// manager context, so that its reconcilers log in the simulator.
+func (vc *VCenter) SetCredentials(ctx *context.ControllerManagerContext) {
+	ctx.Username = vc.Username()
+	ctx.Password = vc.Password()
+}
+
+// Session returns the session of the simulator for the given datacenter.
+func (vc *VCenter) Session(ctx goctx.Context, datacenter string) (*session.Session, error) {
+	return session.GetOrCreate(ctx, session.NewParams().
+		WithServer(vc.Server()).
+		WithUserInfo(vc.Username(), vc.Password()).
+		WithDatacenter(datacenter))
+}
+
+// FailTasks fails the tasks of the given description ID with the given
+// fault, without running them, until the faults are cleared. The description
+// IDs of the tasks of vcsim are named after its methods, e.g.
+// VirtualMachine.cloneVm, VirtualMachine.reconfigVm or VirtualMachine.powerOn.
+func (vc *VCenter) FailTasks(descriptionID string, fault types.BaseMethodFault) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	vc.taskFaults[descriptionID] = fault
+}
+
+// DelayTasks delays the completion of the tasks of the given description ID,
+// until the faults are cleared.
+func (vc *VCenter) DelayTasks(descriptionID string, delay time.Duration) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	vc.taskDelays[descriptionID] = delay
+}
+
+// FailMethod fails the calls to the method of the given name with the given
+// fault, e.g. RetrievePropertiesEx or CreateContainerView, until the faults
+// are cleared.
+func (vc *VCenter) FailMethod(name string, fault types.BaseMethodFault) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	vc.methodFaults[name] = fault
+}
+
+// ClearFaults clears the faults and the delays injected into the tasks and
+// the methods of the simulator.
+func (vc *VCenter) ClearFaults() {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	vc.taskFaults = map[string]types.BaseMethodFault{}
+	vc.taskDelays = map[string]time.Duration{}
+	vc.methodFaults = map[string]types.BaseMethodFault{}
+}
+
+// ExpireSessions terminates the sessions of the simulator, as vCenter does
+// once they are idle for too long, so that the requests of their clients fail
+// as not authenticated.
+func (vc *VCenter) ExpireSessions(ctx goctx.Context) error {
+	c, err := govmomi.NewClient(ctx, vc.server.URL, true)
+	if err != nil {
+		return errors.Wrap(err, "unable to log in the simulator")
+	}
+	defer func() {
+		_ = c.Logout(ctx)
+	}()
+
+	current, err := c.SessionManager.UserSession(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get the session of the simulator")
+	}
+	var sessionManager mo.SessionManager
+	if err := c.RetrieveOne(ctx, *c.ServiceContent.SessionManager, []string{"sessionList"}, &sessionManager); err != nil {
+		return errors.Wrap(err, "unable to list the sessions of the simulator")
+	}
+	var keys []string
+	for _, s := range sessionManager.SessionList {
+		if s.Key != current.Key {
+			keys = append(keys, s.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.SessionManager.TerminateSession(ctx, keys)
+}
+
+// WaitForTask waits for the completion of a task of the simulator, e.g. the
+// task in the taskRef of a VSphereVM, so that it does not run against the
+// simulator of the next test. It returns the fault of a failed task.
+func (vc *VCenter) WaitForTask(ctx goctx.Context, client *vim25.Client, ref string) error {
+	return object.NewTask(client, types.ManagedObjectReference{Type: "Task", Value: ref}).Wait(ctx)
+}
+
+// Reference implements simulator.RegisterObject, under which the VCenter is
+// notified of the tasks created by the simulator.
+func (vc *VCenter) Reference() types.ManagedObjectReference {
+	return types.ManagedObjectReference{Type: "FakeVCenter", Value: "fault-injector"}
+}
+
+// PutObject implements simulator.RegisterObject. It injects the faults and
+// the delays of the tasks created by the simulator, before they are run.
+func (vc *VCenter) PutObject(obj mo.Reference) {
+	task, ok := obj.(*simulator.Task)
+	if !ok {
+		return
+	}
+	vc.mu.Lock()
+	fault, delay := vc.taskFaults[task.Info.DescriptionId], vc.taskDelays[task.Info.DescriptionId]
+	vc.mu.Unlock()
+
+	execute := task.Execute
+	if fault != nil {
+		execute = func(*simulator.Task) (types.AnyType, types.BaseMethodFault) {
+			return nil, fault
+		}
+	}
+	if delay > 0 {
+		next := execute
+		execute = func(task *simulator.Task) (types.AnyType, types.BaseMethodFault) {
+			time.Sleep(delay)
+			return next(task)
+		}
+	}
+	task.Execute = execute
+}
+
+// UpdateObject implements simulator.RegisterObject.
+func (vc *VCenter) UpdateObject(mo.Reference, []types.PropertyChange) {}
+
+// RemoveObject implements simulator.RegisterObject.
+func (vc *VCenter) RemoveObject(*simulator.Context, types.ManagedObjectReference) {}
+
+// handleMethod injects the faults of the methods of the simulator.
+func (vc *VCenter) handleMethod(_ *simulator.Context, method *simulator.Method) (mo.Reference, types.BaseMethodFault) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	return nil, vc.methodFaults[method.Name]
+}
+
+// NewVMContextWithVCenter returns a fake VMContext whose VSphereVM is cloned
+// in the given simulated vCenter, from the template DC0_H0_VM0 into the
+// cluster DC0_C0 of the datacenter DC0, with the session of the datacenter.
+// The credentials of the simulator are set in the controller manager
+// context, so that the reconcilers log in the simulator too.
+func NewVMContextWithVCenter(ctx *context.ControllerContext, vc *VCenter) (*context.VMContext, error) {
+	vc.SetCredentials(ctx.ControllerManagerContext)
+
+	vmContext := NewVMContext(ctx)
+	vmContext.VSphereVM.Spec.Server = vc.Server()
+	vmContext.VSphereVM.Spec.Datacenter = "DC0"
+	vmContext.VSphereVM.Spec.Template = "DC0_H0_VM0"
+	vmContext.VSphereVM.Spec.ResourcePool = "/DC0/host/DC0_C0/Resources"
+	if err := ctx.Client.Update(ctx, vmContext.VSphereVM); err != nil {
+		return nil, errors.Wrapf(err, "failed to update VSphereVM %s/%s", vmContext.VSphereVM.Namespace, vmContext.VSphereVM.Name)
+	}
+	helper, err := patch.NewHelper(vmContext.VSphereVM, ctx.Client)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to init patch helper for VSphereVM %s/%s", vmContext.VSphereVM.Namespace, vmContext.VSphereVM.Name)
+	}
+	vmContext.PatchHelper = helper
+
+	authSession, err := vc.Session(ctx, vmContext.VSphereVM.Spec.Datacenter)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the session of the simulator")
+	}
+	vmContext.Session = authSession
+	return vmContext, nil
+}
@@ -25,6 +25,7 @@ import (
 	"sigs.k8s.io/cluster-api/util/patch"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
 )
 
 type BaseMachineContext struct {
@@ -62,6 +63,7 @@ func (c *VIMMachineContext) String() string {
 
 // Patch updates the object and its status on the API server.
 func (c *VIMMachineContext) Patch() error {
+	v1beta2conditions.Mirror(c.VSphereMachine)
 	return c.PatchHelper.Patch(c, c.VSphereMachine)
 }
 
@@ -0,0 +1,52 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"time"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// ProvisioningTimeouts are the timeouts of the phases of the provisioning of
+// a VM. A zero timeout disables the timeout of its phase.
+type ProvisioningTimeouts struct {
+	Clone         time.Duration
+	ToolsStart    time.Duration
+	IPAcquisition time.Duration
+	BootstrapJoin time.Duration
+}
+
+// Resolve returns the timeouts with the overrides of a machine applied.
+func (t ProvisioningTimeouts) Resolve(overrides *infrav1.ProvisioningTimeouts) ProvisioningTimeouts {
+	if overrides == nil {
+		return t
+	}
+	if overrides.Clone != nil {
+		t.Clone = overrides.Clone.Duration
+	}
+	if overrides.ToolsStart != nil {
+		t.ToolsStart = overrides.ToolsStart.Duration
+	}
+	if overrides.IPAcquisition != nil {
+		t.IPAcquisition = overrides.IPAcquisition.Duration
+	}
+	if overrides.BootstrapJoin != nil {
+		t.BootstrapJoin = overrides.BootstrapJoin.Duration
+	}
+	return t
+}
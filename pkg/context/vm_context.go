@@ -23,6 +23,8 @@ import (
 	"sigs.k8s.io/cluster-api/util/patch"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/audit"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/ipam"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
@@ -34,6 +36,23 @@ type VMContext struct {
 	Logger               logr.Logger
 	Session              *session.Session
 	VSphereFailureDomain *infrav1.VSphereFailureDomain
+	ClusterModuleInfo    *string
+	// Hostname is the guest hostname of the VM, resolved according to its
+	// hostname strategy.
+	Hostname string
+	// IPAMState holds the addresses claimed from IPAM pools for the network
+	// devices of the VM.
+	IPAMState ipam.State
+	// KubeVIPManifest is the static pod manifest of kube-vip added to the
+	// bootstrap data of a control plane machine of a cluster whose control
+	// plane endpoint is advertised by kube-vip.
+	KubeVIPManifest []byte
+	// Timeouts are the timeouts of the phases of the provisioning of the VM,
+	// with the overrides of the VSphereVM applied.
+	Timeouts ProvisioningTimeouts
+	// OvercommitPolicy is the overcommit policy of the VSphereCluster of the
+	// VM, which limits the hosts on which the VM is placed when it is cloned.
+	OvercommitPolicy *infrav1.OvercommitPolicy
 }
 
 // String returns VSphereVMGroupVersionKind VSphereVMNamespace/VSphereVMName.
@@ -46,6 +65,16 @@ func (c *VMContext) Patch() error {
 	return c.PatchHelper.Patch(c, c.VSphereVM)
 }
 
+// Value returns the VSphereVM as the owner of the vCenter operations audited
+// with the context, and the values of the controller context for the other
+// keys.
+func (c *VMContext) Value(key interface{}) interface{} {
+	if key == audit.OwnerKey && c.VSphereVM != nil {
+		return audit.Owner{Object: c.VSphereVM, Kind: "VSphereVM", Controller: c.Name, Recorder: c.Recorder}
+	}
+	return c.ControllerContext.Value(key)
+}
+
 // GetLogger returns this context's logger.
 func (c *VMContext) GetLogger() logr.Logger {
 	return c.Logger
@@ -25,6 +25,7 @@ import (
 	"sigs.k8s.io/cluster-api/util/patch"
 
 	vmwarev1b1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 )
 
@@ -50,7 +51,9 @@ func (c *ClusterContext) Patch() error {
 			vmwarev1b1.ResourcePolicyReadyCondition,
 			vmwarev1b1.ClusterNetworkReadyCondition,
 			vmwarev1b1.LoadBalancerReadyCondition,
+			vmwarev1b1.VMResourcesAvailableCondition,
 		),
 	)
+	v1beta2conditions.Mirror(c.VSphereCluster)
 	return c.PatchHelper.Patch(c, c.VSphereCluster)
 }
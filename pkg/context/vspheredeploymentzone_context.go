@@ -24,6 +24,7 @@ import (
 	"sigs.k8s.io/cluster-api/util/patch"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	v1beta2conditions "sigs.k8s.io/cluster-api-provider-vsphere/pkg/conditions/v1beta2"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
@@ -44,6 +45,8 @@ func (c *VSphereDeploymentZoneContext) Patch() error {
 			infrav1.PlacementConstraintMetCondition,
 		),
 	)
+	v1beta2conditions.Mirror(c.VSphereDeploymentZone)
+
 	return c.PatchHelper.Patch(c, c.VSphereDeploymentZone)
 }
 
@@ -0,0 +1,206 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package errors classifies the errors of the services and the controllers,
+// so that they are requeued, reported in the failure fields of the machines
+// and counted in the metrics consistently.
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/vmware/govmomi/task"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+)
+
+// Class is the class of an error, which decides how its reconciliation is
+// retried.
+type Class string
+
+const (
+	// Terminal is the class of the errors which cannot be recovered by
+	// retrying, e.g. a VM removed from vCenter. They are not requeued.
+	Terminal Class = "terminal"
+
+	// Transient is the class of the errors which may be recovered by
+	// retrying, e.g. a network error. They are requeued with the exponential
+	// backoff of the controller. An error which is not classified is
+	// transient.
+	Transient Class = "transient"
+
+	// Throttled is the class of the errors returned by a server which
+	// refuses the request until its load decreases. They are requeued after
+	// the delay suggested by the server, if any.
+	Throttled Class = "throttled"
+
+	// Permission is the class of the errors caused by invalid credentials or
+	// missing privileges. They are requeued at a fixed interval, until an
+	// administrator fixes the credentials or the privileges.
+	Permission Class = "permission"
+)
+
+// Error is an error with its class.
+type Error struct {
+	// Class is the class of the error.
+	Class Class
+
+	// Reason is the failure reason reported in the status of the machine of
+	// a terminal error, if any.
+	Reason capierrors.MachineStatusError
+
+	// RetryAfter is the delay before the reconciliation is retried. The
+	// default delay of the class is used if it is not set.
+	RetryAfter time.Duration
+
+	// Err is the classified error.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewTerminal returns a terminal error. The reason, if not empty, is reported
+// with the message of the error in the failure fields of the machine.
+func NewTerminal(reason capierrors.MachineStatusError, err error) error {
+	return &Error{Class: Terminal, Reason: reason, Err: err}
+}
+
+// NewTerminalf returns a terminal error with a formatted message.
+func NewTerminalf(reason capierrors.MachineStatusError, format string, args ...interface{}) error {
+	return NewTerminal(reason, fmt.Errorf(format, args...))
+}
+
+// NewTransient returns a transient error.
+func NewTransient(err error) error {
+	return &Error{Class: Transient, Err: err}
+}
+
+// ClassOf returns the class of an error. The errors which were not created
+// by this package are classified from the errors they wrap: the forbidden
+// errors of the API server denied by its authorizer, its unauthorized errors,
+// and the NoPermission, NotAuthenticated and InvalidLogin faults of vCenter,
+// are permission errors, and the TooManyRequests errors of the API server are
+// throttled errors. The other forbidden errors, e.g. the denials of the
+// admission webhooks and the creations in a terminating namespace, are
+// transient.
+func ClassOf(err error) Class {
+	if err == nil {
+		return ""
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		switch {
+		case isAuthorizationDenied(e) || apierrors.IsUnauthorized(e):
+			return Permission
+		case apierrors.IsTooManyRequests(e):
+			return Throttled
+		}
+		if fault := vimFault(e); fault != nil {
+			switch fault.(type) {
+			case *types.NoPermission, *types.NotAuthenticated, *types.InvalidLogin,
+				types.NoPermission, types.NotAuthenticated, types.InvalidLogin:
+				return Permission
+			}
+		}
+	}
+	return Transient
+}
+
+// authorizationDenied matches the message of the forbidden errors of the API
+// server denied by its authorizer, e.g. `User "system:serviceaccount:capv"
+// cannot get resource "secrets"`.
+var authorizationDenied = regexp.MustCompile(`User ".*" cannot `)
+
+// isAuthorizationDenied returns whether an error is a forbidden error of the
+// API server denied by its authorizer, i.e. a missing RBAC permission.
+func isAuthorizationDenied(err error) bool {
+	var status apierrors.APIStatus
+	if !apierrors.IsForbidden(err) || !errors.As(err, &status) {
+		return false
+	}
+	return authorizationDenied.MatchString(status.Status().Message)
+}
+
+// IsInvalidLogin returns whether an error is, or wraps, the InvalidLogin
+// fault of vCenter, returned when its credentials are rejected.
+func IsInvalidLogin(err error) bool {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		switch vimFault(e).(type) {
+		case *types.InvalidLogin, types.InvalidLogin:
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal returns whether an error is terminal.
+func IsTerminal(err error) bool {
+	return ClassOf(err) == Terminal
+}
+
+// RetryAfter returns the delay before the reconciliation of an error is
+// retried, or zero if the default delay of its class applies.
+func RetryAfter(err error) time.Duration {
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.RetryAfter
+	}
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+// Failure returns the failure reason and message of a terminal error with a
+// reason, which are reported in the status of the machine.
+func Failure(err error) (capierrors.MachineStatusError, string, bool) {
+	var classified *Error
+	if !errors.As(err, &classified) || classified.Class != Terminal || classified.Reason == "" {
+		return "", "", false
+	}
+	return classified.Reason, classified.Err.Error(), true
+}
+
+// vimFault returns the vCenter fault of an error returned by govmomi, if any.
+// The faults of the SOAP responses are values, while the faults of the tasks
+// are pointers.
+func vimFault(err error) types.AnyType {
+	switch {
+	case soap.IsSoapFault(err):
+		return soap.ToSoapFault(err).VimFault()
+	case soap.IsVimFault(err):
+		return soap.ToVimFault(err)
+	}
+	var taskErr task.Error
+	if errors.As(err, &taskErr) {
+		return taskErr.Fault()
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/task"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func soapErr(fault types.AnyType) error {
+	return soap.WrapSoapFault(&soap.Fault{Detail: struct {
+		Fault types.AnyType `xml:",any,typeattr"`
+	}{Fault: fault}})
+}
+
+func TestClassOf(t *testing.T) {
+	resource := schema.GroupResource{Resource: "secrets"}
+	taskErr := func(fault types.BaseMethodFault) error {
+		return task.Error{LocalizedMethodFault: &types.LocalizedMethodFault{Fault: fault}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"nil", nil, ""},
+		{"unclassified", fmt.Errorf("connection refused"), Transient},
+		{"terminal", NewTerminalf(capierrors.UpdateMachineError, "vm removed"), Terminal},
+		{"wrapped terminal", errors.Wrap(NewTerminalf(capierrors.UpdateMachineError, "vm removed"), "failed to reconcile VM"), Terminal},
+		{"throttled", &Error{Class: Throttled, Err: fmt.Errorf("slow down")}, Throttled},
+		{"forbidden", apierrors.NewForbidden(resource, "creds", fmt.Errorf(`User "system:serviceaccount:capv-system:capv" cannot get resource "secrets"`)), Permission},
+		{"denied by a webhook", apierrors.NewForbidden(resource, "creds", fmt.Errorf(`admission webhook "quota.vspheremachine.infrastructure.x-k8s.io" denied the request`)), Transient},
+		{"in a terminating namespace", apierrors.NewForbidden(resource, "creds", fmt.Errorf("unable to create new content in namespace team-a because it is being terminated")), Transient},
+		{"wrapped unauthorized", errors.Wrap(apierrors.NewUnauthorized("denied"), "failed to get secret"), Permission},
+		{"too many requests", apierrors.NewTooManyRequests("slow down", 10), Throttled},
+		{"not found", apierrors.NewNotFound(resource, "creds"), Transient},
+		{"no permission", errors.Wrap(taskErr(&types.NoPermission{}), "failed to clone VM"), Permission},
+		{"invalid login", taskErr(&types.InvalidLogin{}), Permission},
+		{"soap invalid login", errors.Wrap(soapErr(types.InvalidLogin{}), "unable to login"), Permission},
+		{"other fault", taskErr(&types.InvalidState{}), Transient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(ClassOf(tt.err)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestIsInvalidLogin(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(IsInvalidLogin(errors.Wrap(soapErr(types.InvalidLogin{}), "unable to login"))).To(BeTrue())
+	g.Expect(IsInvalidLogin(task.Error{LocalizedMethodFault: &types.LocalizedMethodFault{Fault: &types.InvalidLogin{}}})).To(BeTrue())
+	g.Expect(IsInvalidLogin(soapErr(types.NoPermission{}))).To(BeFalse())
+	g.Expect(IsInvalidLogin(fmt.Errorf("connection refused"))).To(BeFalse())
+}
+
+func TestFailure(t *testing.T) {
+	g := NewWithT(t)
+
+	reason, message, ok := Failure(errors.Wrap(NewTerminalf(capierrors.UpdateMachineError, "vm %s removed", "a"), "failed to reconcile VM"))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(reason).To(Equal(capierrors.UpdateMachineError))
+	g.Expect(message).To(Equal("vm a removed"))
+
+	_, _, ok = Failure(NewTerminal("", fmt.Errorf("invalid spec")))
+	g.Expect(ok).To(BeFalse())
+
+	_, _, ok = Failure(NewTransient(fmt.Errorf("connection refused")))
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestRequeue(t *testing.T) {
+	resource := schema.GroupResource{Resource: "secrets"}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantResult reconcile.Result
+		wantErr    bool
+	}{
+		{"no error", nil, reconcile.Result{Requeue: true}, false},
+		{"transient", fmt.Errorf("connection refused"), reconcile.Result{Requeue: true}, true},
+		{"transient with delay", &Error{Class: Transient, RetryAfter: time.Minute, Err: fmt.Errorf("not ready")}, reconcile.Result{RequeueAfter: time.Minute}, false},
+		{"terminal", NewTerminalf(capierrors.UpdateMachineError, "vm removed"), reconcile.Result{}, false},
+		{"throttled", &Error{Class: Throttled, Err: fmt.Errorf("slow down")}, reconcile.Result{RequeueAfter: DefaultThrottledRetryAfter}, false},
+		{"throttled with suggested delay", apierrors.NewTooManyRequests("slow down", 10), reconcile.Result{RequeueAfter: 10 * time.Second}, false},
+		{"permission", apierrors.NewForbidden(resource, "creds", fmt.Errorf(`User "capv" cannot get resource "secrets"`)), reconcile.Result{RequeueAfter: DefaultPermissionRetryAfter}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			result, err := Requeue(logr.Discard(), "test", reconcile.Result{Requeue: true}, tt.err)
+			g.Expect(result).To(Equal(tt.wantResult))
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// DefaultThrottledRetryAfter is the delay before the reconciliation of a
+	// throttled error is retried, when the server does not suggest one.
+	DefaultThrottledRetryAfter = 30 * time.Second
+
+	// DefaultPermissionRetryAfter is the delay before the reconciliation of
+	// a permission error is retried.
+	DefaultPermissionRetryAfter = 5 * time.Minute
+)
+
+// reconcileErrorsTotal counts the errors of the reconciliations, by
+// controller and class.
+var reconcileErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "capv_reconcile_errors_total",
+		Help: "Total number of reconciliations which failed, by controller and class of error (terminal, transient, throttled or permission).",
+	},
+	[]string{"controller", "class"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileErrorsTotal)
+}
+
+// Requeue returns the result of a reconciliation according to the class of
+// its error, and counts the error:
+//
+//   - A transient error is returned, so that the reconciliation is retried
+//     with the exponential backoff of the controller, unless it sets a delay.
+//   - A throttled or permission error is logged, and the reconciliation is
+//     retried after a fixed delay, so that the backoff does not grow while the
+//     server is loaded or the credentials are fixed.
+//   - A terminal error is logged, and the reconciliation is not retried.
+func Requeue(logger logr.Logger, controller string, result reconcile.Result, err error) (reconcile.Result, error) {
+	if err == nil {
+		return result, nil
+	}
+
+	class := ClassOf(err)
+	reconcileErrorsTotal.WithLabelValues(controller, string(class)).Inc()
+
+	retryAfter := RetryAfter(err)
+	switch class {
+	case Terminal:
+		logger.Error(err, "Reconciliation failed with a terminal error, not retrying")
+		return reconcile.Result{}, nil
+	case Throttled:
+		if retryAfter == 0 {
+			retryAfter = DefaultThrottledRetryAfter
+		}
+	case Permission:
+		if retryAfter == 0 {
+			retryAfter = DefaultPermissionRetryAfter
+		}
+	default:
+		if retryAfter == 0 {
+			return result, err
+		}
+	}
+	logger.Error(err, "Reconciliation failed, retrying", "class", class, "retryAfter", retryAfter)
+	return reconcile.Result{RequeueAfter: retryAfter}, nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hooks
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Point is a point of the lifecycle of a VSphereVM at which hooks are invoked.
+type Point string
+
+const (
+	// PreClone is invoked before the VM is cloned.
+	PreClone Point = "PreClone"
+
+	// PostPowerOn is invoked once the VM is powered on and reports its
+	// network addresses.
+	PostPowerOn Point = "PostPowerOn"
+
+	// PreDelete is invoked before the VM is destroyed.
+	PreDelete Point = "PreDelete"
+)
+
+// FailurePolicy defines how the failure of a hook is handled.
+type FailurePolicy string
+
+const (
+	// Fail blocks the lifecycle operation until the hook succeeds.
+	Fail FailurePolicy = "Fail"
+
+	// Ignore logs the failure of the hook and proceeds with the lifecycle
+	// operation.
+	Ignore FailurePolicy = "Ignore"
+)
+
+// HookConfig describes a single hook. Exactly one of URL and Command must be
+// set.
+type HookConfig struct {
+	// Name identifies the hook in logs and errors.
+	Name string `json:"name"`
+
+	// Points is the list of lifecycle points at which the hook is invoked.
+	Points []Point `json:"points"`
+
+	// URL is the endpoint of an HTTP webhook. The Request is sent as the
+	// JSON body of a POST request, and any non 2xx response is a failure.
+	URL string `json:"url,omitempty"`
+
+	// Command is an exec plugin and its arguments. The Request is written as
+	// JSON to the standard input of the command, and a non-zero exit code is
+	// a failure.
+	Command []string `json:"command,omitempty"`
+
+	// Timeout is the maximum duration of an invocation of the hook.
+	// Defaults to 10s.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// FailurePolicy defines how the failure of the hook is handled.
+	// Defaults to Fail.
+	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// Config is the configuration of the lifecycle hooks.
+type Config struct {
+	Hooks []HookConfig `json:"hooks"`
+}
+
+// LoadConfig reads the hooks configuration from a YAML or JSON file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read lifecycle hooks config %q", path)
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse lifecycle hooks config %q", path)
+	}
+	return config, nil
+}
+
+func (c HookConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("name must be set")
+	}
+	if (c.URL == "") == (len(c.Command) == 0) {
+		return errors.Errorf("exactly one of url and command must be set for hook %q", c.Name)
+	}
+	if len(c.Points) == 0 {
+		return errors.Errorf("points must be set for hook %q", c.Name)
+	}
+	for _, p := range c.Points {
+		switch p {
+		case PreClone, PostPowerOn, PreDelete:
+		default:
+			return errors.Errorf("invalid point %q for hook %q", p, c.Name)
+		}
+	}
+	switch c.FailurePolicy {
+	case "", Fail, Ignore:
+	default:
+		return errors.Errorf("invalid failure policy %q for hook %q", c.FailurePolicy, c.Name)
+	}
+	return nil
+}
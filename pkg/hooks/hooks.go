@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package hooks invokes external HTTP webhooks and exec plugins at defined
+// points of the lifecycle of a VSphereVM, so that CMDB registration, IP
+// reservation or security scanning can be integrated with the provider.
+//
+// Hooks can be invoked more than once for the same VM and point, for example
+// when a clone is retried, and must therefore be idempotent.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Request is the machine context sent to the hooks.
+type Request struct {
+	Point      Point    `json:"point"`
+	Namespace  string   `json:"namespace"`
+	Cluster    string   `json:"cluster,omitempty"`
+	Name       string   `json:"name"`
+	Server     string   `json:"server,omitempty"`
+	Datacenter string   `json:"datacenter,omitempty"`
+	Template   string   `json:"template,omitempty"`
+	BiosUUID   string   `json:"biosUUID,omitempty"`
+	Addresses  []string `json:"addresses,omitempty"`
+}
+
+// NewRequest returns the Request for a VSphereVM at a lifecycle point.
+func NewRequest(point Point, vm *infrav1.VSphereVM) Request {
+	return Request{
+		Point:      point,
+		Namespace:  vm.Namespace,
+		Cluster:    vm.Labels[clusterv1.ClusterLabelName],
+		Name:       vm.Name,
+		Server:     vm.Spec.Server,
+		Datacenter: vm.Spec.Datacenter,
+		Template:   vm.Spec.Template,
+		BiosUUID:   vm.Spec.BiosUUID,
+		Addresses:  vm.Status.Addresses,
+	}
+}
+
+// invoker invokes a single hook.
+type invoker func(ctx context.Context, body []byte) error
+
+type hook struct {
+	config HookConfig
+	invoke invoker
+}
+
+// Runner invokes the configured hooks. A nil Runner invokes no hook.
+type Runner struct {
+	hooks  []hook
+	logger logr.Logger
+}
+
+// NewRunner returns a Runner for the hooks of the configuration.
+func NewRunner(config *Config, logger logr.Logger) (*Runner, error) {
+	r := &Runner{logger: logger}
+	for _, c := range config.Hooks {
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+		h := hook{config: c}
+		if c.URL != "" {
+			h.invoke = httpInvoker(c.URL)
+		} else {
+			h.invoke = execInvoker(c)
+		}
+		r.hooks = append(r.hooks, h)
+	}
+	return r, nil
+}
+
+// Run invokes, in order, the hooks registered for the point of the request.
+// It returns the errors of the failed hooks whose failure policy is Fail.
+func (r *Runner) Run(ctx context.Context, req Request) error {
+	if r == nil {
+		return nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode lifecycle hook request")
+	}
+
+	var errList []error
+	for _, h := range r.hooks {
+		if !h.handles(req.Point) {
+			continue
+		}
+		timeout := defaultTimeout
+		if h.config.Timeout != nil {
+			timeout = h.config.Timeout.Duration
+		}
+		hookCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := h.invoke(hookCtx, body)
+		cancel()
+		if err == nil {
+			continue
+		}
+		err = errors.Wrapf(err, "lifecycle hook %q failed at %s for %s/%s", h.config.Name, req.Point, req.Namespace, req.Name)
+		if h.config.FailurePolicy == Ignore {
+			r.logger.Error(err, "ignoring failed lifecycle hook")
+			continue
+		}
+		errList = append(errList, err)
+	}
+	return kerrors.NewAggregate(errList)
+}
+
+func (h hook) handles(point Point) bool {
+	for _, p := range h.config.Points {
+		if p == point {
+			return true
+		}
+	}
+	return false
+}
+
+func httpInvoker(url string) invoker {
+	return func(ctx context.Context, body []byte) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return errors.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+		}
+		return nil
+	}
+}
+
+func execInvoker(config HookConfig) invoker {
+	return func(ctx context.Context, body []byte) error {
+		cmd := exec.CommandContext(ctx, config.Command[0], config.Command[1:]...) //nolint:gosec
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Env = append(os.Environ(), fmt.Sprintf("CAPV_HOOK_NAME=%s", config.Name))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "command output: %s", bytes.TrimSpace(out))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestRunner_Run(t *testing.T) {
+	vm := &infrav1.VSphereVM{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vm-0"},
+	}
+
+	t.Run("nil runner invokes no hook", func(t *testing.T) {
+		g := NewWithT(t)
+		var r *Runner
+		g.Expect(r.Run(context.Background(), NewRequest(PreClone, vm))).To(Succeed())
+	})
+
+	t.Run("http hook receives the request at its points only", func(t *testing.T) {
+		g := NewWithT(t)
+		var received []Request
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := Request{}
+			g.Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+			received = append(received, req)
+		}))
+		defer server.Close()
+
+		r, err := NewRunner(&Config{Hooks: []HookConfig{
+			{Name: "cmdb", Points: []Point{PostPowerOn, PreDelete}, URL: server.URL},
+		}}, ctrllog.Log)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(r.Run(context.Background(), NewRequest(PreClone, vm))).To(Succeed())
+		g.Expect(r.Run(context.Background(), NewRequest(PreDelete, vm))).To(Succeed())
+		g.Expect(received).To(HaveLen(1))
+		g.Expect(received[0].Point).To(Equal(PreDelete))
+		g.Expect(received[0].Name).To(Equal("vm-0"))
+	})
+
+	t.Run("failure policy", func(t *testing.T) {
+		g := NewWithT(t)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "rejected", http.StatusForbidden)
+		}))
+		defer server.Close()
+
+		r, err := NewRunner(&Config{Hooks: []HookConfig{
+			{Name: "scanner", Points: []Point{PreClone}, URL: server.URL, FailurePolicy: Ignore},
+		}}, ctrllog.Log)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(r.Run(context.Background(), NewRequest(PreClone, vm))).To(Succeed())
+
+		r, err = NewRunner(&Config{Hooks: []HookConfig{
+			{Name: "scanner", Points: []Point{PreClone}, URL: server.URL},
+		}}, ctrllog.Log)
+		g.Expect(err).NotTo(HaveOccurred())
+		err = r.Run(context.Background(), NewRequest(PreClone, vm))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("rejected"))
+	})
+
+	t.Run("exec hook", func(t *testing.T) {
+		g := NewWithT(t)
+		out := filepath.Join(t.TempDir(), "request.json")
+
+		r, err := NewRunner(&Config{Hooks: []HookConfig{
+			{Name: "ipam", Points: []Point{PreClone}, Command: []string{"sh", "-c", "cat > " + out}},
+			{Name: "failing", Points: []Point{PreDelete}, Command: []string{"false"}},
+		}}, ctrllog.Log)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(r.Run(context.Background(), NewRequest(PreClone, vm))).To(Succeed())
+		data, err := os.ReadFile(out)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(ContainSubstring(`"point":"PreClone"`))
+
+		g.Expect(r.Run(context.Background(), NewRequest(PreDelete, vm))).NotTo(Succeed())
+	})
+}
+
+func TestNewRunner_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config HookConfig
+	}{
+		{name: "missing name", config: HookConfig{Points: []Point{PreClone}, URL: "http://hook"}},
+		{name: "missing url and command", config: HookConfig{Name: "hook", Points: []Point{PreClone}}},
+		{name: "both url and command", config: HookConfig{Name: "hook", Points: []Point{PreClone}, URL: "http://hook", Command: []string{"true"}}},
+		{name: "missing points", config: HookConfig{Name: "hook", URL: "http://hook"}},
+		{name: "invalid point", config: HookConfig{Name: "hook", Points: []Point{"PostClone"}, URL: "http://hook"}},
+		{name: "invalid failure policy", config: HookConfig{Name: "hook", Points: []Point{PreClone}, URL: "http://hook", FailurePolicy: "Retry"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := NewRunner(&Config{Hooks: []HookConfig{tt.config}}, ctrllog.Log)
+			g.Expect(err).To(HaveOccurred())
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	g := NewWithT(t)
+	path := filepath.Join(t.TempDir(), "hooks.yaml")
+	g.Expect(os.WriteFile(path, []byte(`hooks:
+- name: cmdb
+  points: [PostPowerOn, PreDelete]
+  url: https://cmdb.example.com/hooks
+  timeout: 30s
+  failurePolicy: Ignore
+`), 0600)).To(Succeed())
+
+	config, err := LoadConfig(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config.Hooks).To(HaveLen(1))
+	g.Expect(config.Hooks[0].Timeout.Duration.String()).To(Equal("30s"))
+	g.Expect(config.Hooks[0].FailurePolicy).To(Equal(Ignore))
+}
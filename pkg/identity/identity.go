@@ -32,6 +32,10 @@ import (
 const (
 	UsernameKey = "username"
 	PasswordKey = "password"
+
+	// CABundleKey is the default key of a CA bundle in the data of its Secret
+	// or ConfigMap.
+	CABundleKey = "ca.crt"
 )
 
 type Credentials struct {
@@ -112,6 +116,49 @@ func GetCredentials(ctx context.Context, c client.Client, cluster *infrav1.VSphe
 	return credentials, nil
 }
 
+// GetCABundle returns the PEM-encoded CA certificates referenced by ref in the
+// given namespace, or nil when ref is nil.
+func GetCABundle(ctx context.Context, c client.Client, namespace string, ref *infrav1.CABundleReference) ([]byte, error) {
+	if ref == nil {
+		return nil, nil
+	}
+	if c == nil {
+		return nil, errors.New("kubernetes client is required")
+	}
+	key := ref.Key
+	if key == "" {
+		key = CABundleKey
+	}
+	objKey := client.ObjectKey{Namespace: namespace, Name: ref.Name}
+
+	var caBundle []byte
+	switch ref.Kind {
+	case "Secret":
+		secret := &apiv1.Secret{}
+		if err := c.Get(ctx, objKey, secret); err != nil {
+			return nil, err
+		}
+		caBundle = secret.Data[key]
+	case "ConfigMap":
+		configMap := &apiv1.ConfigMap{}
+		if err := c.Get(ctx, objKey, configMap); err != nil {
+			return nil, err
+		}
+		if data, ok := configMap.Data[key]; ok {
+			caBundle = []byte(data)
+		} else {
+			caBundle = configMap.BinaryData[key]
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind %s used for CA bundle", ref.Kind)
+	}
+
+	if len(caBundle) == 0 {
+		return nil, fmt.Errorf("%s %s has no CA bundle in key %s", ref.Kind, objKey, key)
+	}
+	return caBundle, nil
+}
+
 func IsSecretIdentity(cluster *infrav1.VSphereCluster) bool {
 	if cluster == nil || cluster.Spec.IdentityRef == nil {
 		return false
@@ -203,6 +203,61 @@ var _ = Describe("GetCredentials", func() {
 	})
 })
 
+var _ = Describe("GetCABundle", func() {
+	var ns *corev1.Namespace
+
+	BeforeEach(func() {
+		ns = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "namespace-",
+			},
+		}
+		Expect(k8sclient.Create(ctx, ns)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(k8sclient.Delete(ctx, ns)).To(Succeed())
+	})
+
+	It("should return nil without a reference", func() {
+		caBundle, err := GetCABundle(ctx, k8sclient, ns.Name, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(caBundle).To(BeNil())
+	})
+
+	It("should return the CA bundle of a secret", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "ca-", Namespace: ns.Name},
+			Data:       map[string][]byte{CABundleKey: []byte("secret-ca")},
+		}
+		Expect(k8sclient.Create(ctx, secret)).To(Succeed())
+		caBundle, err := GetCABundle(ctx, k8sclient, ns.Name, &infrav1.CABundleReference{Kind: "Secret", Name: secret.Name})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(caBundle)).To(Equal("secret-ca"))
+	})
+
+	It("should return the CA bundle of a config map with a custom key", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "ca-", Namespace: ns.Name},
+			Data:       map[string]string{"vcenter.pem": "configmap-ca"},
+		}
+		Expect(k8sclient.Create(ctx, configMap)).To(Succeed())
+		caBundle, err := GetCABundle(ctx, k8sclient, ns.Name, &infrav1.CABundleReference{Kind: "ConfigMap", Name: configMap.Name, Key: "vcenter.pem"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(caBundle)).To(Equal("configmap-ca"))
+	})
+
+	It("should error if the key is missing", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "ca-", Namespace: ns.Name},
+			Data:       map[string]string{"vcenter.pem": "configmap-ca"},
+		}
+		Expect(k8sclient.Create(ctx, configMap)).To(Succeed())
+		_, err := GetCABundle(ctx, k8sclient, ns.Name, &infrav1.CABundleReference{Kind: "ConfigMap", Name: configMap.Name})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
 func createSecret(namespace string) *corev1.Secret {
 	credentialSecret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,274 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ipam claims IP addresses for the network devices of a VSphereVM
+// from IPAM pools, following the Cluster API IPAddressClaim contract.
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+var (
+	// IPAddressClaimGVK is the GroupVersionKind of the IPAddressClaim type
+	// of the Cluster API IPAM contract.
+	IPAddressClaimGVK = schema.GroupVersionKind{Group: "ipam.cluster.x-k8s.io", Version: "v1alpha1", Kind: "IPAddressClaim"}
+
+	// IPAddressGVK is the GroupVersionKind of the IPAddress type of the
+	// Cluster API IPAM contract.
+	IPAddressGVK = schema.GroupVersionKind{Group: "ipam.cluster.x-k8s.io", Version: "v1alpha1", Kind: "IPAddress"}
+)
+
+// Address is an IP address bound to an IPAddressClaim.
+type Address struct {
+	// Address is the IP address in CIDR notation.
+	Address string
+	// Gateway is the gateway of the network of the address, if any.
+	Gateway string
+}
+
+// State is the list of claimed addresses of the network devices of a
+// VSphereVM, indexed by the position of the device.
+type State map[int][]Address
+
+// ClaimName returns the name of the IPAddressClaim of a device for a pool.
+func ClaimName(vmName string, deviceIdx, poolIdx int) string {
+	return fmt.Sprintf("%s-%d-%d", vmName, deviceIdx, poolIdx)
+}
+
+// HasPools reports whether any network device of the VSphereVM claims its
+// addresses from an IPAM pool.
+func HasPools(vm *infrav1.VSphereVM) bool {
+	for _, device := range vm.Spec.Network.Devices {
+		if len(device.AddressesFromPools) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ReconcileClaims creates the missing IPAddressClaims of the VSphereVM and
+// returns the addresses bound to them. It returns false until every claim is
+// bound to an address.
+func ReconcileClaims(ctx context.Context, c client.Client, vm *infrav1.VSphereVM) (State, bool, error) {
+	state := State{}
+	bound := true
+	for i, device := range vm.Spec.Network.Devices {
+		for j, pool := range device.AddressesFromPools {
+			address, err := ReconcileClaim(ctx, c, vm, infrav1.GroupVersion.WithKind("VSphereVM"), ClaimName(vm.Name, i, j), pool)
+			if err != nil {
+				return nil, false, err
+			}
+			if address == nil {
+				bound = false
+				continue
+			}
+			state[i] = append(state[i], *address)
+		}
+	}
+	return state, bound, nil
+}
+
+// MissingPools returns the pools of the network devices of the VSphereVM which
+// do not exist in its namespace, or whose kind is not served by the API
+// server, e.g. "InClusterIPPool pool-0". The pools which CAPV is not allowed
+// to read are left to the IPAM provider.
+func MissingPools(ctx context.Context, c client.Client, vm *infrav1.VSphereVM) ([]string, error) {
+	var missing []string
+	for _, device := range vm.Spec.Network.Devices {
+		for _, pool := range device.AddressesFromPools {
+			if pool.APIGroup == nil {
+				continue
+			}
+			description := fmt.Sprintf("%s %s", pool.Kind, pool.Name)
+			mapping, err := c.RESTMapper().RESTMapping(schema.GroupKind{Group: *pool.APIGroup, Kind: pool.Kind})
+			if err != nil {
+				if meta.IsNoMatchError(err) {
+					missing = append(missing, description)
+					continue
+				}
+				return nil, errors.Wrapf(err, "failed to get the resource of %s.%s", pool.Kind, *pool.APIGroup)
+			}
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(mapping.GroupVersionKind)
+			err = c.Get(ctx, client.ObjectKey{Namespace: vm.Namespace, Name: pool.Name}, obj)
+			switch {
+			case apierrors.IsNotFound(err):
+				missing = append(missing, description)
+			case err != nil && !apierrors.IsForbidden(err):
+				return nil, errors.Wrapf(err, "failed to get %s", description)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// ReleaseClaims deletes the IPAddressClaims of the VSphereVM, which releases
+// their addresses back to the pools.
+func ReleaseClaims(ctx context.Context, c client.Client, vm *infrav1.VSphereVM) error {
+	var errList []error
+	for i, device := range vm.Spec.Network.Devices {
+		for j := range device.AddressesFromPools {
+			if err := ReleaseClaim(ctx, c, vm.Namespace, ClaimName(vm.Name, i, j)); err != nil {
+				errList = append(errList, err)
+			}
+		}
+	}
+	return kerrors.NewAggregate(errList)
+}
+
+// ReconcileClaim creates the IPAddressClaim of an object for a pool if it does
+// not exist yet, and returns the address bound to the claim, or nil until the
+// claim is bound. The claim is owned by the object.
+func ReconcileClaim(ctx context.Context, c client.Client, owner client.Object, ownerKind schema.GroupVersionKind, name string, pool corev1.TypedLocalObjectReference) (*Address, error) {
+	claim, err := getOrCreateClaim(ctx, c, owner, ownerKind, name, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	addressName, _, err := unstructured.NestedString(claim.Object, "status", "addressRef", "name")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the address of IPAddressClaim %s", claim.GetName())
+	}
+	if addressName == "" {
+		return nil, nil
+	}
+
+	address, err := getAddress(ctx, c, owner.GetNamespace(), addressName)
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// ReleaseClaim deletes an IPAddressClaim, which releases its address back to
+// its pool. A missing claim is ignored.
+func ReleaseClaim(ctx context.Context, c client.Client, namespace, name string) error {
+	claim := &unstructured.Unstructured{}
+	claim.SetGroupVersionKind(IPAddressClaimGVK)
+	claim.SetNamespace(namespace)
+	claim.SetName(name)
+	if err := c.Delete(ctx, claim); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete IPAddressClaim %s/%s", namespace, name)
+	}
+	return nil
+}
+
+// ApplyState returns a copy of the network devices with the claimed addresses
+// added to their static configuration. The gateway of a claimed address is
+// only used when the device does not define one for the address family.
+func ApplyState(devices []infrav1.NetworkDeviceSpec, state State) []infrav1.NetworkDeviceSpec {
+	result := make([]infrav1.NetworkDeviceSpec, len(devices))
+	for i := range devices {
+		device := devices[i].DeepCopy()
+		for _, address := range state[i] {
+			device.IPAddrs = append(device.IPAddrs, address.Address)
+			if address.Gateway == "" {
+				continue
+			}
+			if ip := net.ParseIP(address.Gateway); ip != nil && ip.To4() != nil {
+				if device.Gateway4 == "" {
+					device.Gateway4 = address.Gateway
+				}
+			} else if device.Gateway6 == "" {
+				device.Gateway6 = address.Gateway
+			}
+		}
+		result[i] = *device
+	}
+	return result
+}
+
+func getOrCreateClaim(ctx context.Context, c client.Client, owner client.Object, ownerKind schema.GroupVersionKind, name string, pool corev1.TypedLocalObjectReference) (*unstructured.Unstructured, error) {
+	claim := &unstructured.Unstructured{}
+	claim.SetGroupVersionKind(IPAddressClaimGVK)
+	key := client.ObjectKey{Namespace: owner.GetNamespace(), Name: name}
+	err := c.Get(ctx, key, claim)
+	if err == nil {
+		return claim, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "failed to get IPAddressClaim %s", key)
+	}
+
+	claim = &unstructured.Unstructured{}
+	claim.SetGroupVersionKind(IPAddressClaimGVK)
+	claim.SetNamespace(owner.GetNamespace())
+	claim.SetName(name)
+	if clusterName, ok := owner.GetLabels()[clusterv1.ClusterLabelName]; ok {
+		claim.SetLabels(map[string]string{clusterv1.ClusterLabelName: clusterName})
+	}
+	claim.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(owner, ownerKind),
+	})
+	poolRef := map[string]interface{}{
+		"kind": pool.Kind,
+		"name": pool.Name,
+	}
+	if pool.APIGroup != nil {
+		poolRef["apiGroup"] = *pool.APIGroup
+	}
+	if err := unstructured.SetNestedMap(claim.Object, poolRef, "spec", "poolRef"); err != nil {
+		return nil, errors.Wrapf(err, "failed to set the pool of IPAddressClaim %s", key)
+	}
+	if err := c.Create(ctx, claim); err != nil {
+		return nil, errors.Wrapf(err, "failed to create IPAddressClaim %s", key)
+	}
+	return claim, nil
+}
+
+func getAddress(ctx context.Context, c client.Client, namespace, name string) (Address, error) {
+	ipAddress := &unstructured.Unstructured{}
+	ipAddress.SetGroupVersionKind(IPAddressGVK)
+	key := client.ObjectKey{Namespace: namespace, Name: name}
+	if err := c.Get(ctx, key, ipAddress); err != nil {
+		return Address{}, errors.Wrapf(err, "failed to get IPAddress %s", key)
+	}
+
+	address, _, err := unstructured.NestedString(ipAddress.Object, "spec", "address")
+	if err != nil || address == "" {
+		return Address{}, errors.Errorf("IPAddress %s has no address", key)
+	}
+	prefix, _, err := unstructured.NestedInt64(ipAddress.Object, "spec", "prefix")
+	if err != nil {
+		return Address{}, errors.Wrapf(err, "failed to read the prefix of IPAddress %s", key)
+	}
+	gateway, _, err := unstructured.NestedString(ipAddress.Object, "spec", "gateway")
+	if err != nil {
+		return Address{}, errors.Wrapf(err, "failed to read the gateway of IPAddress %s", key)
+	}
+
+	cidr := fmt.Sprintf("%s/%d", address, prefix)
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return Address{}, errors.Wrapf(err, "IPAddress %s has an invalid address", key)
+	}
+	return Address{Address: cidr, Gateway: gateway}, nil
+}
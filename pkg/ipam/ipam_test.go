@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestReconcileClaims(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	vm := &infrav1.VSphereVM{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vm-0", UID: "uid"},
+		Spec: infrav1.VSphereVMSpec{
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+				Network: infrav1.NetworkSpec{
+					Devices: []infrav1.NetworkDeviceSpec{
+						{NetworkName: "dhcp", DHCP4: true},
+						{NetworkName: "pool", AddressesFromPools: []corev1.TypedLocalObjectReference{
+							{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"},
+						}},
+					},
+				},
+			},
+		},
+	}
+	g.Expect(HasPools(vm)).To(BeTrue())
+
+	// The claim is created but not yet bound.
+	state, bound, err := ReconcileClaims(ctx, c, vm)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(bound).To(BeFalse())
+	g.Expect(state).To(BeEmpty())
+
+	claim := &unstructured.Unstructured{}
+	claim.SetGroupVersionKind(IPAddressClaimGVK)
+	g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: ClaimName(vm.Name, 1, 0)}, claim)).To(Succeed())
+	g.Expect(claim.GetOwnerReferences()).To(HaveLen(1))
+	g.Expect(claim.GetOwnerReferences()[0].Name).To(Equal(vm.Name))
+	poolName, _, _ := unstructured.NestedString(claim.Object, "spec", "poolRef", "name")
+	g.Expect(poolName).To(Equal("pool"))
+
+	// Bind the claim to an address as an IPAM provider would.
+	address := &unstructured.Unstructured{}
+	address.SetGroupVersionKind(IPAddressGVK)
+	address.SetNamespace("default")
+	address.SetName("vm-0-address")
+	g.Expect(unstructured.SetNestedField(address.Object, "10.0.0.10", "spec", "address")).To(Succeed())
+	g.Expect(unstructured.SetNestedField(address.Object, int64(24), "spec", "prefix")).To(Succeed())
+	g.Expect(unstructured.SetNestedField(address.Object, "10.0.0.1", "spec", "gateway")).To(Succeed())
+	g.Expect(c.Create(ctx, address)).To(Succeed())
+	g.Expect(unstructured.SetNestedField(claim.Object, "vm-0-address", "status", "addressRef", "name")).To(Succeed())
+	g.Expect(c.Update(ctx, claim)).To(Succeed())
+
+	state, bound, err = ReconcileClaims(ctx, c, vm)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(bound).To(BeTrue())
+	g.Expect(state).To(Equal(State{1: {{Address: "10.0.0.10/24", Gateway: "10.0.0.1"}}}))
+
+	devices := ApplyState(vm.Spec.Network.Devices, state)
+	g.Expect(devices[0]).To(Equal(vm.Spec.Network.Devices[0]))
+	g.Expect(devices[1].IPAddrs).To(Equal([]string{"10.0.0.10/24"}))
+	g.Expect(devices[1].Gateway4).To(Equal("10.0.0.1"))
+	g.Expect(vm.Spec.Network.Devices[1].IPAddrs).To(BeEmpty())
+
+	// Releasing deletes the claims.
+	g.Expect(ReleaseClaims(ctx, c, vm)).To(Succeed())
+	g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: ClaimName(vm.Name, 1, 0)}, claim)).NotTo(Succeed())
+	g.Expect(ReleaseClaims(ctx, c, vm)).To(Succeed())
+}
+
+func TestApplyState_PreservesDeviceGateways(t *testing.T) {
+	g := NewWithT(t)
+	devices := []infrav1.NetworkDeviceSpec{
+		{NetworkName: "pool", Gateway4: "192.168.0.1"},
+	}
+	state := State{0: {
+		{Address: "192.168.0.10/24", Gateway: "192.168.0.254"},
+		{Address: "fd00::10/64", Gateway: "fd00::1"},
+	}}
+
+	result := ApplyState(devices, state)
+	g.Expect(result[0].IPAddrs).To(Equal([]string{"192.168.0.10/24", "fd00::10/64"}))
+	g.Expect(result[0].Gateway4).To(Equal("192.168.0.1"))
+	g.Expect(result[0].Gateway6).To(Equal("fd00::1"))
+}
+
+func TestMissingPools(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	inClusterIPPoolGVK := schema.GroupVersionKind{Group: "ipam.cluster.x-k8s.io", Version: "v1alpha1", Kind: "InClusterIPPool"}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{inClusterIPPoolGVK.GroupVersion()})
+	mapper.Add(inClusterIPPoolGVK, meta.RESTScopeNamespace)
+	pool := &unstructured.Unstructured{}
+	pool.SetGroupVersionKind(inClusterIPPoolGVK)
+	pool.SetNamespace("default")
+	pool.SetName("pool")
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(pool).Build()
+
+	vm := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vm-0"}}
+	vm.Spec.Network.Devices = []infrav1.NetworkDeviceSpec{{NetworkName: "pool", AddressesFromPools: []corev1.TypedLocalObjectReference{
+		{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"},
+		{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "other"},
+		{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "IPPool", Name: "pool"},
+	}}}
+
+	missing, err := MissingPools(ctx, c, vm)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(missing).To(Equal([]string{"InClusterIPPool other", "IPPool pool"}))
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	goctx "context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// configureCache scopes the cache of the manager to the watched namespaces
+// and restricts the cached Secrets and ConfigMaps to the ones matching the
+// cache label selector. The cache is left as is when neither is set.
+func (o *Options) configureCache() error {
+	if len(o.WatchNamespaces) == 0 && o.CacheLabelSelector == "" {
+		return nil
+	}
+	if len(o.WatchNamespaces) > 0 && o.Namespace != "" {
+		return errors.New("the watched namespaces cannot be set with a single watched namespace")
+	}
+
+	cacheOpts := cache.Options{}
+	if o.CacheLabelSelector != "" {
+		selector, err := labels.Parse(o.CacheLabelSelector)
+		if err != nil {
+			return errors.Wrapf(err, "invalid cache label selector %q", o.CacheLabelSelector)
+		}
+		cacheOpts.SelectorsByObject = cache.SelectorsByObject{
+			&corev1.Secret{}:    {Label: selector},
+			&corev1.ConfigMap{}: {Label: selector},
+		}
+		o.NewClient = newFallbackClient
+	}
+
+	newCache := cache.New
+	if len(o.WatchNamespaces) > 0 {
+		// The credentials of the VSphereClusterIdentities are read from the
+		// namespace of the manager.
+		namespaces := append([]string{}, o.WatchNamespaces...)
+		if o.PodNamespace != "" && !containsString(namespaces, o.PodNamespace) {
+			namespaces = append(namespaces, o.PodNamespace)
+		}
+		newCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+	o.NewCache = func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		opts.SelectorsByObject = cacheOpts.SelectorsByObject
+		return newCache(config, opts)
+	}
+	return nil
+}
+
+// newFallbackClient returns a client which reads the Secrets and the
+// ConfigMaps missing from the cache from the API server, since the cache only
+// holds the ones matching the cache label selector.
+func newFallbackClient(cache cache.Cache, config *rest.Config, options client.Options, uncachedObjects ...client.Object) (client.Client, error) {
+	c, err := client.New(config, options)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewDelegatingClient(client.NewDelegatingClientInput{
+		CacheReader:     fallbackReader{cache: cache, apiReader: c},
+		Client:          c,
+		UncachedObjects: uncachedObjects,
+	})
+}
+
+// fallbackReader reads the objects from the cache, and the Secrets and the
+// ConfigMaps which are not found in the cache from the API server. The lists
+// are only read from the cache.
+type fallbackReader struct {
+	cache     client.Reader
+	apiReader client.Reader
+}
+
+func (r fallbackReader) Get(ctx goctx.Context, key client.ObjectKey, obj client.Object) error {
+	err := r.cache.Get(ctx, key, obj)
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	switch obj.(type) {
+	case *corev1.Secret, *corev1.ConfigMap:
+		return r.apiReader.Get(ctx, key, obj)
+	}
+	return err
+}
+
+func (r fallbackReader) List(ctx goctx.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return r.cache.List(ctx, list, opts...)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
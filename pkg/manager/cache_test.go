@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestOptions_ConfigureCache(t *testing.T) {
+	t.Run("leaves the cache as is by default", func(t *testing.T) {
+		g := NewWithT(t)
+		o := &Options{}
+		g.Expect(o.configureCache()).To(Succeed())
+		g.Expect(o.NewCache).To(BeNil())
+		g.Expect(o.NewClient).To(BeNil())
+	})
+
+	t.Run("scopes the cache to the watched namespaces", func(t *testing.T) {
+		g := NewWithT(t)
+		o := &Options{WatchNamespaces: []string{"team-a", "team-b"}, PodNamespace: "capv-system"}
+		g.Expect(o.configureCache()).To(Succeed())
+		g.Expect(o.NewCache).NotTo(BeNil())
+		g.Expect(o.NewClient).To(BeNil())
+		g.Expect(o.WatchNamespaces).To(Equal([]string{"team-a", "team-b"}))
+	})
+
+	t.Run("reads the Secrets missing from the cache from the API server", func(t *testing.T) {
+		g := NewWithT(t)
+		o := &Options{CacheLabelSelector: "cluster.x-k8s.io/cluster-name"}
+		g.Expect(o.configureCache()).To(Succeed())
+		g.Expect(o.NewCache).NotTo(BeNil())
+		g.Expect(o.NewClient).NotTo(BeNil())
+	})
+
+	t.Run("rejects an invalid label selector", func(t *testing.T) {
+		g := NewWithT(t)
+		o := &Options{CacheLabelSelector: "a in (b"}
+		g.Expect(o.configureCache()).NotTo(Succeed())
+	})
+
+	t.Run("rejects the watched namespaces with a single watched namespace", func(t *testing.T) {
+		g := NewWithT(t)
+		o := &Options{WatchNamespaces: []string{"team-a"}}
+		o.Namespace = "team-b"
+		g.Expect(o.configureCache()).NotTo(Succeed())
+	})
+}
+
+func TestFallbackReader_Get(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "capv-system", Name: "credentials"}}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "capv-system", Name: "webhook"}}
+	r := fallbackReader{
+		cache:     fake.NewClientBuilder().Build(),
+		apiReader: fake.NewClientBuilder().WithObjects(secret, service).Build(),
+	}
+
+	t.Run("reads a Secret missing from the cache from the API server", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(r.Get(goctx.Background(), client.ObjectKeyFromObject(secret), &corev1.Secret{})).To(Succeed())
+	})
+
+	t.Run("only reads the other objects from the cache", func(t *testing.T) {
+		g := NewWithT(t)
+		err := r.Get(goctx.Background(), client.ObjectKeyFromObject(service), &corev1.Service{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+}
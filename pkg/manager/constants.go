@@ -36,4 +36,8 @@ const (
 
 	// DefaultLeaderElectionID is the default value for the eponymous manager option.
 	DefaultLeaderElectionID = DefaultPodName + "-runtime"
+
+	// DefaultIPConflictProbeTimeout is the default timeout of the ICMP probe
+	// of a static IP address before it is assigned to a VM.
+	DefaultIPConflictProbeTimeout = time.Second * 2
 )
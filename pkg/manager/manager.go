@@ -26,17 +26,29 @@ import (
 	vmoprv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
 	ncpv1 "github.com/vmware-tanzu/vm-operator/external/ncp/api/v1alpha1"
 	topologyv1 "github.com/vmware-tanzu/vm-operator/external/tanzu-topology/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	expv1 "sigs.k8s.io/cluster-api/exp/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	infrav1a3 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1alpha3"
 	infrav1a4 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1alpha4"
 	infrav1b1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	vmwarev1b1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/audit"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/hooks"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/notify"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/quota"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
 )
 
 // Manager is a CAPV controller manager.
@@ -58,11 +70,14 @@ func New(opts Options) (Manager, error) {
 	_ = infrav1a4.AddToScheme(opts.Scheme)
 	_ = infrav1b1.AddToScheme(opts.Scheme)
 	_ = bootstrapv1.AddToScheme(opts.Scheme)
+	_ = controlplanev1.AddToScheme(opts.Scheme)
+	_ = expv1.AddToScheme(opts.Scheme)
 	_ = vmwarev1b1.AddToScheme(opts.Scheme)
 	_ = vmoprv1.AddToScheme(opts.Scheme)
 	_ = ncpv1.AddToScheme(opts.Scheme)
 	_ = netopv1.AddToScheme(opts.Scheme)
 	_ = topologyv1.AddToScheme(opts.Scheme)
+	_ = apiextensionsv1.AddToScheme(opts.Scheme)
 	// +kubebuilder:scaffold:scheme
 
 	podName, err := os.Hostname()
@@ -70,34 +85,105 @@ func New(opts Options) (Manager, error) {
 		podName = DefaultPodName
 	}
 
+	var lifecycleHooks *hooks.Runner
+	if opts.LifecycleHooksConfigFile != "" {
+		hooksConfig, err := hooks.LoadConfig(opts.LifecycleHooksConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		if lifecycleHooks, err = hooks.NewRunner(hooksConfig, opts.Logger.WithName("lifecycle-hooks")); err != nil {
+			return nil, errors.Wrap(err, "invalid lifecycle hooks config")
+		}
+	}
+
+	var machineNotifier *notify.Notifier
+	if opts.MachineNotificationsConfigFile != "" {
+		notifyConfig, err := notify.LoadConfig(opts.MachineNotificationsConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		if machineNotifier, err = notify.NewNotifier(notifyConfig, opts.Logger.WithName("machine-notifications")); err != nil {
+			return nil, errors.Wrap(err, "invalid machine notifications config")
+		}
+	}
+
+	if feature.Gates.Enabled(feature.VCenterAudit) {
+		var auditSink audit.Sink
+		if opts.VCenterAuditSink != "" {
+			if auditSink, err = audit.NewSink(opts.VCenterAuditSink); err != nil {
+				return nil, errors.Wrap(err, "invalid vCenter audit sink")
+			}
+		}
+		audit.SetAuditor(audit.NewAuditor(auditSink, opts.Logger.WithName("vcenter-audit")))
+	}
+
+	if err := opts.configureCache(); err != nil {
+		return nil, err
+	}
+
 	// Build the controller manager.
 	mgr, err := ctrl.NewManager(opts.KubeConfig, opts.Options)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create manager")
 	}
 
-	// Build the controller manager context.
+	// The API reader does not need the cache, which is not started yet. The
+	// garbage collector of the orphaned VMs cannot be added without the ID.
+	if opts.ManagementClusterID == "" {
+		ns := &corev1.Namespace{}
+		if err := mgr.GetAPIReader().Get(goctx.Background(), client.ObjectKey{Name: metav1.NamespaceSystem}, ns); err != nil {
+			opts.Logger.Error(err, "unable to get the UID of the kube-system namespace, the cloned VMs are not marked with the ID of the management cluster")
+		}
+		opts.ManagementClusterID = string(ns.UID)
+	}
+
+	// Build the controller manager context, which is cancelled when the
+	// manager stops so that the in-flight vCenter operations are cancelled.
+	managerCtx, cancel := goctx.WithCancel(goctx.Background())
 	controllerManagerContext := &context.ControllerManagerContext{
-		Context:                 goctx.Background(),
-		WatchNamespace:          opts.Namespace,
-		Namespace:               opts.PodNamespace,
-		Name:                    opts.PodName,
-		LeaderElectionID:        opts.LeaderElectionID,
-		LeaderElectionNamespace: opts.LeaderElectionNamespace,
-		MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
-		Client:                  mgr.GetClient(),
-		Logger:                  opts.Logger.WithName(opts.PodName),
-		Recorder:                record.New(mgr.GetEventRecorderFor(fmt.Sprintf("%s/%s", opts.PodNamespace, podName))),
-		Scheme:                  opts.Scheme,
-		Username:                opts.Username,
-		Password:                opts.Password,
-		EnableKeepAlive:         opts.EnableKeepAlive,
-		KeepAliveDuration:       opts.KeepAliveDuration,
-		NetworkProvider:         opts.NetworkProvider,
+		Context:                             managerCtx,
+		WatchNamespace:                      opts.Namespace,
+		WatchNamespaces:                     opts.WatchNamespaces,
+		Namespace:                           opts.PodNamespace,
+		Name:                                opts.PodName,
+		LeaderElectionID:                    opts.LeaderElectionID,
+		LeaderElectionNamespace:             opts.LeaderElectionNamespace,
+		MaxConcurrentReconciles:             opts.MaxConcurrentReconciles,
+		ControlPlaneMaxConcurrentReconciles: opts.ControlPlaneMaxConcurrentReconciles,
+		Client:                              mgr.GetClient(),
+		Logger:                              opts.Logger.WithName(opts.PodName),
+		Recorder:                            record.New(mgr.GetEventRecorderFor(fmt.Sprintf("%s/%s", opts.PodNamespace, podName))),
+		Scheme:                              opts.Scheme,
+		Username:                            opts.Username,
+		Password:                            opts.Password,
+		EnableKeepAlive:                     opts.EnableKeepAlive,
+		KeepAliveDuration:                   opts.KeepAliveDuration,
+		MaxConcurrentVCenterRequests:        opts.MaxConcurrentVCenterRequests,
+		NetworkProvider:                     opts.NetworkProvider,
+		LifecycleHooks:                      lifecycleHooks,
+		MachineNotifier:                     machineNotifier,
+		VMInventoryInterval:                 opts.VMInventoryInterval,
+		OrphanedVMGCInterval:                opts.OrphanedVMGCInterval,
+		ManagementClusterID:                 opts.ManagementClusterID,
+		VMStatusBatchInterval:               opts.VMStatusBatchInterval,
+		ProvisioningTimeouts:                opts.ProvisioningTimeouts,
+		IPConflictProbeTimeout:              opts.IPConflictProbeTimeout,
+		CrossNamespaceRefPolicy:             opts.CrossNamespaceRefPolicy,
+		RBACCleanupPolicy:                   opts.RBACCleanupPolicy,
+		ClusterPermissionAllowedPrivileges:  opts.ClusterPermissionAllowedPrivileges,
+		SystemServiceAccountRegistry:        opts.SystemServiceAccountRegistry,
+		TaskThrottle:                        throttle.New(opts.MaxConcurrentVCenterTasks),
+		QuotaAdmitter:                       quota.NewAdmitter(),
+		CostWeights:                         opts.CostWeights,
+		SnapshotChainLimit:                  opts.SnapshotChainLimit,
+		AutoConsolidateDisks:                opts.AutoConsolidateDisks,
+		DriftCheckInterval:                  opts.DriftCheckInterval,
+		AutoCorrectDrift:                    opts.AutoCorrectDrift,
 	}
 
 	// Add the requested items to the manager.
 	if err := opts.AddToManager(controllerManagerContext, mgr); err != nil {
+		cancel()
 		return nil, errors.Wrap(err, "failed to add resources to the manager")
 	}
 
@@ -106,12 +192,30 @@ func New(opts Options) (Manager, error) {
 	return &manager{
 		Manager: mgr,
 		ctx:     controllerManagerContext,
+		cancel:  cancel,
 	}, nil
 }
 
 type manager struct {
 	ctrl.Manager
-	ctx *context.ControllerManagerContext
+	ctx    *context.ControllerManagerContext
+	cancel goctx.CancelFunc
+}
+
+// Start starts the manager, and cancels the controller manager context once
+// the provided context is done. The queued vCenter audit records are sent
+// before it returns.
+func (m *manager) Start(ctx goctx.Context) error {
+	defer audit.GetAuditor().Close()
+	defer m.cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.cancel()
+		case <-m.ctx.Done():
+		}
+	}()
+	return m.Manager.Start(ctx)
 }
 
 func (m *manager) GetContext() *context.ControllerManagerContext {
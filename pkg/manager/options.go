@@ -29,6 +29,7 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
 )
 
 // AddToManagerFunc is a function that can be optionally specified with
@@ -50,6 +51,13 @@ type Options struct {
 	// Defaults to the eponymous constant in this package.
 	MaxConcurrentReconciles int
 
+	// ControlPlaneMaxConcurrentReconciles is the maximum number of allowed,
+	// concurrent reconciles of the machines and VMs of control planes, which
+	// are reconciled apart from the ones of the worker machines.
+	//
+	// Defaults to MaxConcurrentReconciles.
+	ControlPlaneMaxConcurrentReconciles int
+
 	// LeaderElectionNamespace is the namespace in which the pod running the
 	// controller maintains a leader election lock
 	//
@@ -73,9 +81,123 @@ type Options struct {
 	// in keepalive handler
 	KeepAliveDuration time.Duration
 
+	// MaxConcurrentVCenterRequests is a session feature to limit the number
+	// of concurrent requests to each vCenter. The requests are not limited
+	// if it is not set.
+	MaxConcurrentVCenterRequests int
+
+	// MaxConcurrentVCenterTasks are the maximum numbers of concurrent clone,
+	// reconfigure and power tasks issued by the VSphereVMs to each vCenter.
+	// The tasks of an operation are not limited if its limit is not set.
+	MaxConcurrentVCenterTasks throttle.Limits
+
 	// CredentialsFile is the file that contains credentials of CAPV
 	CredentialsFile string
 
+	// LifecycleHooksConfigFile is the file that contains the configuration
+	// of the lifecycle hooks. No hook is invoked if it is not set.
+	LifecycleHooksConfigFile string
+
+	// MachineNotificationsConfigFile is the file that contains the
+	// configuration of the sinks of the machine lifecycle events. No event is
+	// sent if it is not set.
+	MachineNotificationsConfigFile string
+
+	// VMInventoryInterval is the interval at which the VSphereVMInventory of
+	// each VSphereCluster is refreshed. The inventory is not reported if it
+	// is not set.
+	VMInventoryInterval time.Duration
+
+	// OrphanedVMGCInterval is the interval at which the VMs whose VSphereVM
+	// or Cluster no longer exists are garbage collected. The VMs are not
+	// garbage collected if it is not set.
+	OrphanedVMGCInterval time.Duration
+
+	// ManagementClusterID identifies the management cluster on the VMs it
+	// clones, so that the garbage collector only deletes the VMs cloned by
+	// this management cluster. Defaults to the UID of the kube-system
+	// namespace.
+	ManagementClusterID string
+
+	// VMStatusBatchInterval is the minimum interval between two patches of
+	// a VSphereVM that only update its network or storage status. Every
+	// change is patched right away if it is not set.
+	VMStatusBatchInterval time.Duration
+
+	// CrossNamespaceRefPolicy is the policy applied to the object references
+	// pointing to another namespace than the one of the referencing object,
+	// one of Allow, Audit or Deny. Defaults to Allow.
+	CrossNamespaceRefPolicy string
+
+	// RBACCleanupPolicy is the policy applied to the
+	// ServiceAccounts, Roles and RoleBindings of the ProviderServiceAccounts
+	// created by former versions of the controller in supervisor mode, one
+	// of Report, Relabel or Delete. They are not cleaned up if it is not set.
+	RBACCleanupPolicy string
+
+	// ClusterPermissionAllowedPrivileges are the privileges the role of the
+	// permission of a cluster may hold in addition to the privileges CAPV
+	// requires on the VMs and the resource pools.
+	ClusterPermissionAllowedPrivileges []string
+
+	// SystemServiceAccountRegistry is the name of the cluster-scoped
+	// SystemServiceAccountRegistry listing the system service accounts in
+	// supervisor mode. The ConfigMap named by the SERVICE_ACCOUNTS_CM_NAMESPACE
+	// and SERVICE_ACCOUNTS_CM_NAME environment variables is used if it is not
+	// set.
+	SystemServiceAccountRegistry string
+
+	// ProvisioningTimeouts are the default timeouts of the phases of the
+	// provisioning of VMs, which can be overridden per machine.
+	ProvisioningTimeouts context.ProvisioningTimeouts
+
+	// IPConflictProbeTimeout is the timeout of the ICMP probe of the static
+	// IP addresses of a VM before it is cloned, when the IPConflictDetection
+	// feature is enabled. The addresses are not probed if it is not set.
+	IPConflictProbeTimeout time.Duration
+
+	// CostWeights are the hourly costs of the resources of the VMs used to
+	// estimate the cost of the machines and the clusters in the annotations
+	// and the metrics. The cost is not estimated if all the weights are zero.
+	CostWeights context.CostWeights
+
+	// SnapshotChainLimit is the maximum length of the chain of snapshots a
+	// VM runs on before its VSphereVM reports the snapshots as blocking its
+	// reconfigurations and migrations. The chains are not checked if it is
+	// not set.
+	SnapshotChainLimit int
+
+	// AutoConsolidateDisks consolidates the disks of the VMs which need a
+	// consolidation, e.g. after the removal of a snapshot failed.
+	AutoConsolidateDisks bool
+
+	// DriftCheckInterval is the interval at which the configuration of each
+	// ready VM is compared with its VSphereVM, which reports the drift in its
+	// ConfigurationInSync condition. The drift is not checked if it is not
+	// set.
+	DriftCheckInterval time.Duration
+
+	// AutoCorrectDrift corrects the drift of the folder, the resource pool
+	// and the custom VMX keys of the VMs when the drift is checked.
+	AutoCorrectDrift bool
+
+	// VCenterAuditSink is the sink of the records of the operations mutating
+	// vCenter, audited with the VCenterAudit feature gate: a file, - for the
+	// standard output, or an http(s) URL. The operations are only recorded
+	// as events if it is not set.
+	VCenterAuditSink string
+
+	// WatchNamespaces are the namespaces the controllers watch, in addition
+	// to the namespace of the manager. All the namespaces are watched if
+	// neither WatchNamespaces nor Namespace is set.
+	WatchNamespaces []string
+
+	// CacheLabelSelector is the label selector of the Secrets and the
+	// ConfigMaps held in the cache of the manager. The other ones are read
+	// from the API server. All the Secrets and the ConfigMaps are cached if
+	// it is not set.
+	CacheLabelSelector string
+
 	KubeConfig *rest.Config
 
 	// AddToManager is a function that can be optionally specified with
@@ -98,6 +220,10 @@ func (o *Options) defaults() {
 		o.PodName = DefaultPodName
 	}
 
+	if o.ControlPlaneMaxConcurrentReconciles <= 0 {
+		o.ControlPlaneMaxConcurrentReconciles = o.MaxConcurrentReconciles
+	}
+
 	if o.KubeConfig == nil {
 		o.KubeConfig = config.GetConfigOrDie()
 	}
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package naming renders the names of the objects created in vCenter for a
+// cluster from the templates of its naming strategy.
+package naming
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+// MaxLength is the maximum length of a rendered name, the length of a DNS
+// label, which is also shorter than the names vSphere accepts.
+const MaxLength = 63
+
+// randomSuffixLength is the length of the random suffix of a name.
+const randomSuffixLength = 5
+
+// Input is the data a name template refers to.
+type Input struct {
+	// ClusterName is the name of the Cluster.
+	ClusterName string
+	// Namespace is the namespace of the Cluster.
+	Namespace string
+	// MachineName is the name of the Machine. It is empty when the name of
+	// the placement of a cluster is rendered.
+	MachineName string
+	// RandomSuffix is a random string. It is empty when the name of the
+	// placement of a cluster is rendered.
+	RandomSuffix string
+}
+
+// NewRandomSuffix returns a random suffix for the Input of a name.
+func NewRandomSuffix() string {
+	return utilrand.String(randomSuffixLength)
+}
+
+var funcs = template.FuncMap{
+	"trunc": func(n int, s string) string {
+		if n >= 0 && len(s) > n {
+			return s[:n]
+		}
+		return s
+	},
+	"lower": strings.ToLower,
+}
+
+// invalidCharacters matches the sequences of characters a DNS label cannot
+// contain.
+var invalidCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Render renders a name template and converts the result to a DNS label: its
+// letters are lowercased, the sequences of other characters than letters,
+// digits and hyphens are replaced with a hyphen, and the leading and trailing
+// hyphens are removed. It fails when the name is empty or longer than
+// MaxLength, rather than truncating it, as a truncated name could collide
+// with the names of other objects.
+func Render(text string, input Input) (string, error) {
+	tmpl, err := template.New("name").Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid name template")
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, input); err != nil {
+		return "", errors.Wrap(err, "failed to render name template")
+	}
+	name := invalidCharacters.ReplaceAllString(strings.ToLower(buf.String()), "-")
+	name = strings.Trim(name, "-")
+	switch {
+	case name == "":
+		return "", errors.Errorf("name template %q renders an empty name", text)
+	case len(name) > MaxLength:
+		return "", errors.Errorf("name %q rendered from template %q is longer than %d characters", name, text, MaxLength)
+	}
+	return name, nil
+}
+
+// sampleInputs are the inputs the templates are validated with. They have
+// the length of usual names, e.g. of the Machines of a MachineDeployment.
+var sampleInputs = [2]Input{
+	{ClusterName: "workload", Namespace: "default", MachineName: "workload-md-0-5d8f7b9c4-x2k7p", RandomSuffix: "a1b2c"},
+	{ClusterName: "workload", Namespace: "default", MachineName: "workload-md-0-5d8f7b9c4-q9z4m", RandomSuffix: "d3e4f"},
+}
+
+// ValidateVMTemplate validates the template of the names of VMs: it must
+// render a name for usual names of Machines, and distinct names for distinct
+// Machines.
+func ValidateVMTemplate(text string) error {
+	var names [2]string
+	for i, input := range sampleInputs {
+		name, err := Render(text, input)
+		if err != nil {
+			return err
+		}
+		names[i] = name
+	}
+	if names[0] == names[1] {
+		return errors.Errorf("name template %q renders the same name for every machine, it must refer to .MachineName or .RandomSuffix", text)
+	}
+	return nil
+}
+
+// ValidatePlacementTemplate validates the template of the name of the
+// placement of a cluster: it must render a name which only depends on the
+// cluster, as the placement is named before any machine.
+func ValidatePlacementTemplate(text string) error {
+	input := sampleInputs[0]
+	input.MachineName, input.RandomSuffix = "", ""
+	name, err := Render(text, input)
+	if err != nil {
+		return err
+	}
+	for _, input := range sampleInputs {
+		if other, err := Render(text, input); err != nil || other != name {
+			return errors.Errorf("name template %q cannot refer to .MachineName or .RandomSuffix", text)
+		}
+	}
+	return nil
+}
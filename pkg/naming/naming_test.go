@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package naming
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRender(t *testing.T) {
+	input := Input{ClusterName: "Workload", Namespace: "team-a", MachineName: "workload-md-0-5d8f7b9c4-x2k7p", RandomSuffix: "a1b2c"}
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		err      string
+	}{
+		{
+			name:     "renders the fields and the functions",
+			template: "{{.ClusterName}}-{{.MachineName | trunc 13}}-{{.RandomSuffix}}",
+			expected: "workload-workload-md-0-a1b2c",
+		},
+		{
+			name:     "converts the name to a DNS label",
+			template: "_{{.Namespace}}.{{.ClusterName}} {{.RandomSuffix}}_",
+			expected: "team-a-workload-a1b2c",
+		},
+		{
+			name:     "fails on a name longer than the maximum length",
+			template: "{{.Namespace}}-{{.ClusterName}}-" + strings.Repeat("x", MaxLength),
+			err:      "is longer than 63 characters",
+		},
+		{
+			name:     "fails on an empty name",
+			template: "{{.RandomSuffix | trunc 0}}--",
+			err:      "renders an empty name",
+		},
+		{
+			name:     "fails on an unknown field",
+			template: "{{.MachineDeployment}}",
+			err:      "failed to render name template",
+		},
+		{
+			name:     "fails on an unknown function",
+			template: "{{.MachineName | upper}}",
+			err:      "invalid name template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			name, err := Render(tt.template, input)
+			if tt.err != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.err))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(name).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestValidateVMTemplate(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(ValidateVMTemplate("{{.ClusterName}}-{{.MachineName | trunc 20}}-{{.RandomSuffix}}")).To(Succeed())
+	g.Expect(ValidateVMTemplate("{{.Namespace}}-{{.RandomSuffix}}")).To(Succeed())
+	g.Expect(ValidateVMTemplate("{{.Namespace}}-{{.ClusterName}}")).To(MatchError(ContainSubstring("renders the same name for every machine")))
+	g.Expect(ValidateVMTemplate("{{.Namespace}}-{{.ClusterName}}-{{.MachineName}}-" + strings.Repeat("x", 20))).To(MatchError(ContainSubstring("is longer than 63 characters")))
+}
+
+func TestValidatePlacementTemplate(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(ValidatePlacementTemplate("capv-{{.Namespace}}-{{.ClusterName}}")).To(Succeed())
+	g.Expect(ValidatePlacementTemplate("{{.ClusterName}}-{{.RandomSuffix}}")).To(MatchError(ContainSubstring("cannot refer to .MachineName or .RandomSuffix")))
+	g.Expect(ValidatePlacementTemplate("{{.ClusterName}}-{{.MachineName | trunc 3}}")).To(MatchError(ContainSubstring("cannot refer to .MachineName or .RandomSuffix")))
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// SinkConfig describes a single notification sink. Exactly one of URL and
+// Command must be set.
+type SinkConfig struct {
+	// Name identifies the sink in logs and errors.
+	Name string `json:"name"`
+	// Events is the list of event types sent to the sink.
+	// Defaults to all the event types.
+	Events []EventType `json:"events,omitempty"`
+	// URL is the endpoint of an HTTP webhook. The Event is sent as the JSON
+	// body of a POST request, and any non 2xx response is a failure.
+	URL string `json:"url,omitempty"`
+	// Headers are the HTTP headers added to the requests sent to URL, e.g.
+	// an Authorization header.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Command is an exec plugin and its arguments, e.g. a client publishing
+	// the events to an AMQP broker. The Event is written as JSON to the
+	// standard input of the command, and a non-zero exit code is a failure.
+	Command []string `json:"command,omitempty"`
+	// Timeout is the maximum duration of the delivery of an event.
+	// Defaults to 10s.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// Config is the configuration of the machine lifecycle notifications.
+type Config struct {
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+// LoadConfig reads the notifications configuration from a YAML or JSON file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read machine notifications config %q", path)
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse machine notifications config %q", path)
+	}
+	return config, nil
+}
+
+func (c SinkConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("name must be set")
+	}
+	if (c.URL == "") == (len(c.Command) == 0) {
+		return errors.Errorf("exactly one of url and command must be set for sink %q", c.Name)
+	}
+	if len(c.Headers) > 0 && c.URL == "" {
+		return errors.Errorf("headers can only be set with url for sink %q", c.Name)
+	}
+	for _, e := range c.Events {
+		switch e {
+		case MachineCreated, MachineJoined, MachineRemediated, MachineDeleted:
+		default:
+			return errors.Errorf("invalid event %q for sink %q", e, c.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notify sends structured machine lifecycle events to external
+// systems, such as IT service management or change management systems, so
+// that their records of the machines stay in sync with the clusters.
+//
+// An event can be sent more than once, for example when the delivery to
+// another sink failed, so receivers should deduplicate events by their ID.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+
+	// providerIDPrefix is the prefix of the provider ID of a VSphereMachine,
+	// followed by the BIOS UUID of its VM.
+	providerIDPrefix = "vsphere://"
+)
+
+// EventType is the type of a machine lifecycle event.
+type EventType string
+
+const (
+	// MachineCreated is sent once the VM of a machine is created and ready.
+	MachineCreated EventType = "MachineCreated"
+	// MachineJoined is sent once the node of a machine joins the cluster.
+	MachineJoined EventType = "MachineJoined"
+	// MachineRemediated is sent once a MachineHealthCheck marks a machine
+	// as unhealthy, before it is remediated.
+	MachineRemediated EventType = "MachineRemediated"
+	// MachineDeleted is sent once the VM of a machine is deleted.
+	MachineDeleted EventType = "MachineDeleted"
+)
+
+// Event is a machine lifecycle event, with the vCenter identifiers of the
+// machine.
+type Event struct {
+	// ID identifies the event of a machine. It is the same for every
+	// delivery of the event.
+	ID             string      `json:"id"`
+	Type           EventType   `json:"type"`
+	Time           metav1.Time `json:"time"`
+	Namespace      string      `json:"namespace"`
+	Cluster        string      `json:"cluster,omitempty"`
+	Machine        string      `json:"machine,omitempty"`
+	VSphereMachine string      `json:"vsphereMachine"`
+	ControlPlane   bool        `json:"controlPlane,omitempty"`
+	NodeName       string      `json:"nodeName,omitempty"`
+	ProviderID     string      `json:"providerID,omitempty"`
+	Server         string      `json:"server,omitempty"`
+	Datacenter     string      `json:"datacenter,omitempty"`
+	Folder         string      `json:"folder,omitempty"`
+	Template       string      `json:"template,omitempty"`
+	BiosUUID       string      `json:"biosUUID,omitempty"`
+	InstanceUUID   string      `json:"instanceUUID,omitempty"`
+	Addresses      []string    `json:"addresses,omitempty"`
+	// Reason is the reason of a MachineRemediated event.
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewEvent returns the Event of a machine. The VSphereVM is nil once it is
+// deleted.
+func NewEvent(eventType EventType, machine *clusterv1.Machine, vsphereMachine *infrav1.VSphereMachine, vm *infrav1.VSphereVM) Event {
+	event := Event{
+		ID:             fmt.Sprintf("%s/%s", vsphereMachine.UID, eventType),
+		Type:           eventType,
+		Time:           metav1.Now(),
+		Namespace:      vsphereMachine.Namespace,
+		Cluster:        vsphereMachine.Labels[clusterv1.ClusterLabelName],
+		VSphereMachine: vsphereMachine.Name,
+		Server:         vsphereMachine.Spec.Server,
+		Datacenter:     vsphereMachine.Spec.Datacenter,
+		Folder:         vsphereMachine.Spec.Folder,
+		Template:       vsphereMachine.Spec.Template,
+	}
+	_, event.ControlPlane = vsphereMachine.Labels[clusterv1.MachineControlPlaneLabelName]
+	if providerID := vsphereMachine.Spec.ProviderID; providerID != nil && strings.HasPrefix(*providerID, providerIDPrefix) {
+		event.ProviderID = *providerID
+		event.BiosUUID = strings.TrimPrefix(*providerID, providerIDPrefix)
+	}
+	for _, address := range vsphereMachine.Status.Addresses {
+		event.Addresses = append(event.Addresses, address.Address)
+	}
+	if machine != nil {
+		event.Machine = machine.Name
+		if machine.Status.NodeRef != nil {
+			event.NodeName = machine.Status.NodeRef.Name
+		}
+	}
+	if vm != nil {
+		event.InstanceUUID = string(vm.UID)
+		event.Template = vm.Spec.Template
+		if vm.Status.Folder != "" {
+			event.Folder = vm.Status.Folder
+		}
+		if vm.Spec.BiosUUID != "" {
+			event.BiosUUID = vm.Spec.BiosUUID
+		}
+	}
+	return event
+}
+
+// sender delivers an event to a single sink.
+type sender func(ctx context.Context, body []byte) error
+
+type sink struct {
+	config SinkConfig
+	send   sender
+}
+
+// delivery is the state of the background delivery of an event.
+type delivery struct {
+	done bool
+	err  error
+}
+
+// queuedEvent is an event waiting for its background delivery.
+type queuedEvent struct {
+	ctx   context.Context
+	event Event
+	// onDone is called once the event is delivered, or failed to be. Only
+	// the deliveries of the events with an onDone function are recorded.
+	onDone func()
+}
+
+// Notifier sends the events to the configured sinks. A nil Notifier sends no
+// event.
+type Notifier struct {
+	sinks  []sink
+	logger logr.Logger
+
+	mu sync.Mutex
+	// deliveries are the background deliveries of the events of each
+	// machine, by event type.
+	deliveries map[string]map[EventType]*delivery
+	// queues are the events waiting for their background delivery, by
+	// machine. A machine has a queue while its events are being delivered.
+	queues map[string][]queuedEvent
+}
+
+// NewNotifier returns a Notifier for the sinks of the configuration.
+func NewNotifier(config *Config, logger logr.Logger) (*Notifier, error) {
+	n := &Notifier{
+		logger:     logger,
+		deliveries: map[string]map[EventType]*delivery{},
+		queues:     map[string][]queuedEvent{},
+	}
+	for _, c := range config.Sinks {
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+		s := sink{config: c}
+		if c.URL != "" {
+			s.send = httpSender(c.URL, c.Headers)
+		} else {
+			s.send = execSender(c)
+		}
+		n.sinks = append(n.sinks, s)
+	}
+	return n, nil
+}
+
+// Notify sends the event to the sinks of its type. It returns the errors of
+// the failed deliveries.
+func (n *Notifier) Notify(ctx context.Context, event Event) error {
+	if n == nil {
+		return nil
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode machine lifecycle event")
+	}
+	var errList []error
+	for _, s := range n.sinks {
+		if !s.handles(event.Type) {
+			continue
+		}
+		timeout := defaultTimeout
+		if s.config.Timeout != nil {
+			timeout = s.config.Timeout.Duration
+		}
+		sinkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := s.send(sinkCtx, body)
+		cancel()
+		if err != nil {
+			errList = append(errList, errors.Wrapf(err, "failed to send %s event of %s/%s to sink %q", event.Type, event.Namespace, event.VSphereMachine, s.config.Name))
+			continue
+		}
+		n.logger.V(4).Info("sent machine lifecycle event", "sink", s.config.Name, "id", event.ID)
+	}
+	return kerrors.NewAggregate(errList)
+}
+
+// NotifyAsync sends the event to the sinks of its type in the background,
+// after the events of the same machine sent before, so that a reconciliation
+// does not wait for the sinks. It returns whether the event was delivered:
+// the first call starts the delivery and returns false, as do the calls while
+// it is in progress. Once the delivery is over, onDone is called, and the
+// next call returns true if it succeeded, or the errors of the failed
+// deliveries, after which the event is sent again by the following call. The
+// delivery is cancelled when the context is done.
+func (n *Notifier) NotifyAsync(ctx context.Context, event Event, onDone func()) (bool, error) {
+	if n == nil {
+		return true, nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	key := machineKey(event)
+	deliveries := n.deliveries[key]
+	if d, ok := deliveries[event.Type]; ok {
+		if !d.done {
+			return false, nil
+		}
+		delete(deliveries, event.Type)
+		if len(deliveries) == 0 {
+			delete(n.deliveries, key)
+		}
+		return d.err == nil, d.err
+	}
+	if deliveries == nil {
+		deliveries = map[EventType]*delivery{}
+		n.deliveries[key] = deliveries
+	}
+	deliveries[event.Type] = &delivery{}
+	if onDone == nil {
+		onDone = func() {}
+	}
+	n.enqueue(queuedEvent{ctx: ctx, event: event, onDone: onDone})
+	return false, nil
+}
+
+// SendAsync sends the event to the sinks of its type in the background, after
+// the events of the same machine sent before. A failed delivery is logged, and
+// the event is not sent again.
+func (n *Notifier) SendAsync(ctx context.Context, event Event) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.enqueue(queuedEvent{ctx: ctx, event: event})
+}
+
+// Forget forgets the background deliveries of the events of a machine, e.g.
+// once it is deleted. The events waiting for their delivery are still sent.
+func (n *Notifier) Forget(namespace, vsphereMachine string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.deliveries, machineKey(Event{Namespace: namespace, VSphereMachine: vsphereMachine}))
+}
+
+// enqueue queues an event for its background delivery, and starts the
+// delivery of the events of its machine unless they are being delivered. It
+// must be called with the lock held.
+func (n *Notifier) enqueue(e queuedEvent) {
+	key := machineKey(e.event)
+	queue, delivering := n.queues[key]
+	n.queues[key] = append(queue, e)
+	if !delivering {
+		go n.deliver(key)
+	}
+}
+
+// deliver delivers the queued events of a machine in order, until its queue
+// is empty.
+func (n *Notifier) deliver(key string) {
+	for {
+		n.mu.Lock()
+		queue := n.queues[key]
+		if len(queue) == 0 {
+			delete(n.queues, key)
+			n.mu.Unlock()
+			return
+		}
+		e := queue[0]
+		n.queues[key] = queue[1:]
+		n.mu.Unlock()
+
+		err := n.Notify(e.ctx, e.event)
+		if e.onDone == nil {
+			if err != nil {
+				n.logger.Error(err, "failed to send machine lifecycle event", "id", e.event.ID)
+			}
+			continue
+		}
+		n.mu.Lock()
+		if d, ok := n.deliveries[key][e.event.Type]; ok {
+			d.done, d.err = true, err
+		}
+		n.mu.Unlock()
+		e.onDone()
+	}
+}
+
+// machineKey returns the key of the machine of an event.
+func machineKey(event Event) string {
+	return event.Namespace + "/" + event.VSphereMachine
+}
+
+func (s sink) handles(eventType EventType) bool {
+	if len(s.config.Events) == 0 {
+		return true
+	}
+	for _, e := range s.config.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func httpSender(url string, headers map[string]string) sender {
+	return func(ctx context.Context, body []byte) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return errors.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+		}
+		return nil
+	}
+}
+
+func execSender(config SinkConfig) sender {
+	return func(ctx context.Context, body []byte) error {
+		cmd := exec.CommandContext(ctx, config.Command[0], config.Command[1:]...) //nolint:gosec
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Env = append(os.Environ(), fmt.Sprintf("CAPV_SINK_NAME=%s", config.Name))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "command output: %s", bytes.TrimSpace(out))
+		}
+		return nil
+	}
+}
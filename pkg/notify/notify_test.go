@@ -0,0 +1,277 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestNewEvent(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machine-0"},
+		Status:     clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "node-0"}},
+	}
+	vsphereMachine := &infrav1.VSphereMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "vsphere-machine-0",
+			UID:       "machine-uid",
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName:             "cluster",
+				clusterv1.MachineControlPlaneLabelName: "",
+			},
+		},
+		Spec: infrav1.VSphereMachineSpec{
+			ProviderID: pointer.String("vsphere://4207f2b4-9d1b-6b3f-1a8e-2c8e3f0b2a11"),
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+				Server:     "vcenter.example.com",
+				Datacenter: "dc0",
+				Folder:     "/dc0/vm/cluster",
+				Template:   "ubuntu",
+			},
+		},
+		Status: infrav1.VSphereMachineStatus{Addresses: []clusterv1.MachineAddress{{Type: clusterv1.MachineExternalIP, Address: "10.0.0.10"}}},
+	}
+
+	event := NewEvent(MachineJoined, machine, vsphereMachine, nil)
+	g.Expect(event.ID).To(Equal("machine-uid/MachineJoined"))
+	g.Expect(event.Cluster).To(Equal("cluster"))
+	g.Expect(event.Machine).To(Equal("machine-0"))
+	g.Expect(event.NodeName).To(Equal("node-0"))
+	g.Expect(event.ControlPlane).To(BeTrue())
+	g.Expect(event.BiosUUID).To(Equal("4207f2b4-9d1b-6b3f-1a8e-2c8e3f0b2a11"))
+	g.Expect(event.Server).To(Equal("vcenter.example.com"))
+	g.Expect(event.Folder).To(Equal("/dc0/vm/cluster"))
+	g.Expect(event.Addresses).To(Equal([]string{"10.0.0.10"}))
+	g.Expect(event.InstanceUUID).To(BeEmpty())
+
+	// The VSphereVM reports the current folder and the instance UUID of the VM.
+	vm := &infrav1.VSphereVM{
+		ObjectMeta: metav1.ObjectMeta{UID: "vm-uid"},
+		Spec: infrav1.VSphereVMSpec{
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Template: "ubuntu-resolved"},
+		},
+		Status: infrav1.VSphereVMStatus{Folder: "/dc0/vm/moved"},
+	}
+	event = NewEvent(MachineCreated, machine, vsphereMachine, vm)
+	g.Expect(event.InstanceUUID).To(Equal("vm-uid"))
+	g.Expect(event.Folder).To(Equal("/dc0/vm/moved"))
+	g.Expect(event.Template).To(Equal("ubuntu-resolved"))
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	vsphereMachine := &infrav1.VSphereMachine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vsphere-machine-0", UID: "uid"},
+	}
+
+	t.Run("nil notifier sends no event", func(t *testing.T) {
+		g := NewWithT(t)
+		var n *Notifier
+		g.Expect(n.Notify(context.Background(), NewEvent(MachineCreated, nil, vsphereMachine, nil))).To(Succeed())
+	})
+
+	t.Run("http sink receives the events of its types only", func(t *testing.T) {
+		g := NewWithT(t)
+		var received []Event
+		var authorization string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			event := Event{}
+			g.Expect(json.NewDecoder(r.Body).Decode(&event)).To(Succeed())
+			received = append(received, event)
+			authorization = r.Header.Get("Authorization")
+		}))
+		defer server.Close()
+
+		n, err := NewNotifier(&Config{Sinks: []SinkConfig{
+			{Name: "itsm", Events: []EventType{MachineCreated, MachineDeleted}, URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}},
+		}}, ctrllog.Log)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(n.Notify(context.Background(), NewEvent(MachineJoined, nil, vsphereMachine, nil))).To(Succeed())
+		g.Expect(n.Notify(context.Background(), NewEvent(MachineDeleted, nil, vsphereMachine, nil))).To(Succeed())
+		g.Expect(received).To(HaveLen(1))
+		g.Expect(received[0].Type).To(Equal(MachineDeleted))
+		g.Expect(received[0].ID).To(Equal("uid/MachineDeleted"))
+		g.Expect(authorization).To(Equal("Bearer token"))
+	})
+
+	t.Run("failed delivery", func(t *testing.T) {
+		g := NewWithT(t)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		n, err := NewNotifier(&Config{Sinks: []SinkConfig{{Name: "itsm", URL: server.URL}}}, ctrllog.Log)
+		g.Expect(err).NotTo(HaveOccurred())
+		err = n.Notify(context.Background(), NewEvent(MachineCreated, nil, vsphereMachine, nil))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unavailable"))
+	})
+
+	t.Run("exec sink", func(t *testing.T) {
+		g := NewWithT(t)
+		out := filepath.Join(t.TempDir(), "event.json")
+
+		n, err := NewNotifier(&Config{Sinks: []SinkConfig{
+			{Name: "amqp", Events: []EventType{MachineCreated}, Command: []string{"sh", "-c", "cat > " + out}},
+			{Name: "failing", Events: []EventType{MachineDeleted}, Command: []string{"false"}},
+		}}, ctrllog.Log)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Expect(n.Notify(context.Background(), NewEvent(MachineCreated, nil, vsphereMachine, nil))).To(Succeed())
+		data, err := os.ReadFile(out)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(ContainSubstring(`"type":"MachineCreated"`))
+
+		g.Expect(n.Notify(context.Background(), NewEvent(MachineDeleted, nil, vsphereMachine, nil))).NotTo(Succeed())
+	})
+}
+
+func TestNotifier_NotifyAsync(t *testing.T) {
+	g := NewWithT(t)
+	vsphereMachine := &infrav1.VSphereMachine{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vsphere-machine-0", UID: "uid"},
+	}
+
+	var mu sync.Mutex
+	var received []EventType
+	failing := true
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		event := Event{}
+		g.Expect(json.NewDecoder(r.Body).Decode(&event)).To(Succeed())
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		received = append(received, event.Type)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	n, err := NewNotifier(&Config{Sinks: []SinkConfig{{Name: "itsm", URL: server.URL}}}, ctrllog.Log)
+	g.Expect(err).NotTo(HaveOccurred())
+	done := make(chan struct{}, 1)
+	onDone := func() { done <- struct{}{} }
+	created := NewEvent(MachineCreated, nil, vsphereMachine, nil)
+
+	// The delivery is started in the background, and is pending until the
+	// sink answers.
+	delivered, err := n.NotifyAsync(context.Background(), created, onDone)
+	g.Expect(delivered).To(BeFalse())
+	g.Expect(err).NotTo(HaveOccurred())
+	delivered, err = n.NotifyAsync(context.Background(), created, onDone)
+	g.Expect(delivered).To(BeFalse())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// A failed delivery is returned once, then the event is sent again.
+	release <- struct{}{}
+	<-done
+	delivered, err = n.NotifyAsync(context.Background(), created, onDone)
+	g.Expect(delivered).To(BeFalse())
+	g.Expect(err).To(MatchError(ContainSubstring("unavailable")))
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	delivered, err = n.NotifyAsync(context.Background(), created, onDone)
+	g.Expect(delivered).To(BeFalse())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The events sent without waiting for their delivery follow the events
+	// of the machine sent before them.
+	n.SendAsync(context.Background(), NewEvent(MachineDeleted, nil, vsphereMachine, nil))
+	release <- struct{}{}
+	<-done
+	delivered, err = n.NotifyAsync(context.Background(), created, onDone)
+	g.Expect(delivered).To(BeTrue())
+	g.Expect(err).NotTo(HaveOccurred())
+	release <- struct{}{}
+	g.Eventually(func() []EventType {
+		mu.Lock()
+		defer mu.Unlock()
+		return received
+	}).Should(Equal([]EventType{MachineCreated, MachineDeleted}))
+
+	// A nil notifier delivers every event.
+	var none *Notifier
+	delivered, err = none.NotifyAsync(context.Background(), created, onDone)
+	g.Expect(delivered).To(BeTrue())
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestNewNotifier_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SinkConfig
+	}{
+		{name: "missing name", config: SinkConfig{URL: "http://sink"}},
+		{name: "missing url and command", config: SinkConfig{Name: "sink"}},
+		{name: "both url and command", config: SinkConfig{Name: "sink", URL: "http://sink", Command: []string{"true"}}},
+		{name: "headers without url", config: SinkConfig{Name: "sink", Command: []string{"true"}, Headers: map[string]string{"X-Key": "value"}}},
+		{name: "invalid event", config: SinkConfig{Name: "sink", URL: "http://sink", Events: []EventType{"MachineUpgraded"}}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := NewNotifier(&Config{Sinks: []SinkConfig{tt.config}}, ctrllog.Log)
+			g.Expect(err).To(HaveOccurred())
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	g := NewWithT(t)
+	path := filepath.Join(t.TempDir(), "notifications.yaml")
+	g.Expect(os.WriteFile(path, []byte(`sinks:
+- name: itsm
+  events: [MachineCreated, MachineDeleted]
+  url: https://itsm.example.com/capv
+  headers:
+    Authorization: Bearer token
+  timeout: 30s
+`), 0600)).To(Succeed())
+
+	config, err := LoadConfig(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config.Sinks).To(HaveLen(1))
+	g.Expect(config.Sinks[0].Timeout.Duration.String()).To(Equal("30s"))
+	g.Expect(config.Sinks[0].Headers).To(HaveKeyWithValue("Authorization", "Bearer token"))
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package privileges computes the vCenter privileges required by the features
+// of CAPV, and checks them against the privileges of a vCenter session.
+package privileges
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// Features are the features of CAPV which require privileges in addition to
+// the privileges required to clone, configure, power and destroy VMs.
+type Features struct {
+	// LinkedClones are VMs cloned from a snapshot of their template, which
+	// CAPV takes when the template has none.
+	LinkedClones bool
+
+	// Tags are the tags set in the tagIDs of the machines, attached to their
+	// VMs.
+	Tags bool
+
+	// ManagedTags are the tags of the cluster, of the namespace and of the
+	// role of the machines, created on demand and deleted with the cluster.
+	ManagedTags bool
+
+	// StorageDRS places the disks of the VMs in datastore clusters.
+	StorageDRS bool
+
+	// ClusterModules keep the VMs of a machine set on different hosts, with
+	// the cluster modules of their compute cluster.
+	ClusterModules bool
+
+	// ClusterPermissions are the roles created for the clusters, granted on
+	// their folder and resource pool.
+	ClusterPermissions bool
+
+	// Relocations are the VMs migrated to another host or datastore with the
+	// relocate operation.
+	Relocations bool
+
+	// AttachedVolumes are the volumes of Cloud Native Storage queried and
+	// detached before the VMs are destroyed.
+	AttachedVolumes bool
+
+	// Encryption encrypts the VMs when they are cloned, with keys generated
+	// by the key providers of vCenter.
+	Encryption bool
+}
+
+// basePrivileges are the privileges required to clone, configure, power and
+// destroy the VMs of the machines, regardless of the features enabled.
+var basePrivileges = []string{
+	"Datastore.AllocateSpace",
+	"Network.Assign",
+	"Resource.AssignVMToPool",
+	"Sessions.ValidateSession",
+	"StorageProfile.View",
+	"VirtualMachine.Config.AddNewDisk",
+	"VirtualMachine.Config.AddRemoveDevice",
+	"VirtualMachine.Config.AdvancedConfig",
+	"VirtualMachine.Config.CPUCount",
+	"VirtualMachine.Config.DiskExtend",
+	"VirtualMachine.Config.EditDevice",
+	"VirtualMachine.Config.Memory",
+	"VirtualMachine.Config.Settings",
+	"VirtualMachine.Interact.PowerOff",
+	"VirtualMachine.Interact.PowerOn",
+	"VirtualMachine.Inventory.CreateFromExisting",
+	"VirtualMachine.Inventory.Delete",
+	"VirtualMachine.Provisioning.Clone",
+	"VirtualMachine.Provisioning.DeployTemplate",
+}
+
+// Required returns the privileges required by CAPV with the given features,
+// sorted.
+func Required(features Features) []string {
+	privileges := append([]string{}, basePrivileges...)
+	if features.LinkedClones {
+		privileges = append(privileges, "VirtualMachine.State.CreateSnapshot")
+	}
+	if features.Tags || features.ManagedTags {
+		privileges = append(privileges,
+			"InventoryService.Tagging.AttachTag",
+			"InventoryService.Tagging.ObjectAttachable")
+	}
+	if features.ManagedTags {
+		privileges = append(privileges,
+			"InventoryService.Tagging.CreateCategory",
+			"InventoryService.Tagging.CreateTag",
+			"InventoryService.Tagging.DeleteTag",
+			"InventoryService.Tagging.EditCategory")
+	}
+	if features.StorageDRS {
+		privileges = append(privileges, "Resource.ApplyRecommendation")
+	}
+	if features.ClusterModules {
+		privileges = append(privileges, "Host.Inventory.EditCluster")
+	}
+	if features.ClusterPermissions {
+		privileges = append(privileges,
+			"Authorization.ModifyPermissions",
+			"Authorization.ModifyRoles")
+	}
+	if features.Relocations {
+		privileges = append(privileges,
+			"Resource.ColdMigrate",
+			"Resource.HotMigrate")
+	}
+	if features.AttachedVolumes {
+		privileges = append(privileges, "Cns.Searchable")
+	}
+	if features.Encryption {
+		privileges = append(privileges,
+			"Cryptographer.Access",
+			"Cryptographer.AddDisk",
+			"Cryptographer.Clone",
+			"Cryptographer.EncryptNew",
+			"Cryptographer.ManageKeys")
+	}
+	sort.Strings(privileges)
+	return privileges
+}
+
+// PermissionDefaults returns the privileges CAPV requires on the VMs and the
+// resource pools, which are the privileges of the role of the permission of
+// a cluster by default. The other privileges CAPV requires are held on the
+// datastores, the networks or the root folder, where the role of a cluster is
+// not granted.
+func PermissionDefaults() []string {
+	var ids []string
+	for _, id := range basePrivileges {
+		if strings.HasPrefix(id, "VirtualMachine.") || strings.HasPrefix(id, "Resource.") {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// NotAllowedForPermission returns the given privileges which the role of the
+// permission of a cluster may not hold: the privileges which are neither in
+// PermissionDefaults nor in the allowed privileges set by the operator of
+// CAPV, in the order they are given.
+func NotAllowedForPermission(privilegeIDs, allowed []string) []string {
+	allowedIDs := map[string]bool{}
+	for _, id := range append(PermissionDefaults(), allowed...) {
+		allowedIDs[id] = true
+	}
+	var notAllowed []string
+	for _, id := range privilegeIDs {
+		if !allowedIDs[id] {
+			notAllowed = append(notAllowed, id)
+		}
+	}
+	return notAllowed
+}
+
+// Missing returns the given privileges which the user of a session does not
+// hold on the root folder of vCenter, in the order they are given. The
+// privileges of a role assigned on the root folder and propagated to its
+// children are held on every object of the inventory.
+func Missing(ctx context.Context, s *session.Session, privileges []string) ([]string, error) {
+	userSession, err := s.SessionManager.UserSession(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get the user session")
+	}
+	if userSession == nil {
+		return nil, errors.New("the session is not logged in")
+	}
+	authz := object.NewAuthorizationManager(s.Client.Client)
+	granted, err := authz.HasPrivilegeOnEntity(ctx, s.Client.ServiceContent.RootFolder, userSession.Key, privileges)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to check the privileges on the root folder")
+	}
+	missing := []string{}
+	for i, privilege := range privileges {
+		if i >= len(granted) || !granted[i] {
+			missing = append(missing, privilege)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package privileges
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestRequired(t *testing.T) {
+	g := NewWithT(t)
+
+	base := Required(Features{})
+	g.Expect(sort.StringsAreSorted(base)).To(BeTrue())
+	g.Expect(base).To(ContainElements("VirtualMachine.Provisioning.Clone", "Datastore.AllocateSpace"))
+	g.Expect(base).NotTo(ContainElement("VirtualMachine.State.CreateSnapshot"))
+
+	g.Expect(Required(Features{LinkedClones: true})).To(ContainElement("VirtualMachine.State.CreateSnapshot"))
+	g.Expect(Required(Features{Tags: true})).To(ContainElement("InventoryService.Tagging.AttachTag"))
+	g.Expect(Required(Features{Tags: true})).NotTo(ContainElement("InventoryService.Tagging.CreateTag"))
+	g.Expect(Required(Features{ManagedTags: true})).To(ContainElements("InventoryService.Tagging.CreateCategory", "InventoryService.Tagging.EditCategory"))
+	g.Expect(Required(Features{StorageDRS: true})).To(ContainElement("Resource.ApplyRecommendation"))
+	g.Expect(Required(Features{ClusterModules: true})).To(ContainElement("Host.Inventory.EditCluster"))
+	g.Expect(Required(Features{ClusterPermissions: true})).To(ContainElements("Authorization.ModifyPermissions", "Authorization.ModifyRoles"))
+	g.Expect(Required(Features{Relocations: true})).To(ContainElements("Resource.ColdMigrate", "Resource.HotMigrate"))
+	g.Expect(Required(Features{AttachedVolumes: true})).To(ContainElement("Cns.Searchable"))
+	g.Expect(Required(Features{Encryption: true})).To(ContainElements("Cryptographer.EncryptNew", "Cryptographer.ManageKeys"))
+
+	all := Required(Features{LinkedClones: true, Tags: true, ManagedTags: true, StorageDRS: true, ClusterModules: true})
+	g.Expect(sort.StringsAreSorted(all)).To(BeTrue())
+	g.Expect(all).To(ContainElement("InventoryService.Tagging.CreateTag"))
+	// The tagging privileges shared by the tags and the managed tags are required once.
+	seen := map[string]bool{}
+	for _, privilege := range all {
+		g.Expect(seen[privilege]).To(BeFalse(), privilege)
+		seen[privilege] = true
+	}
+}
+
+func TestNotAllowedForPermission(t *testing.T) {
+	g := NewWithT(t)
+
+	defaults := PermissionDefaults()
+	g.Expect(defaults).To(ContainElements("VirtualMachine.Provisioning.Clone", "Resource.AssignVMToPool"))
+	g.Expect(defaults).NotTo(ContainElement("Datastore.AllocateSpace"))
+
+	g.Expect(NotAllowedForPermission(defaults, nil)).To(BeEmpty())
+	g.Expect(NotAllowedForPermission([]string{"VirtualMachine.Interact.PowerOn", "Authorization.ModifyPermissions", "VirtualMachine.Interact.ConsoleInteract"}, nil)).
+		To(Equal([]string{"Authorization.ModifyPermissions", "VirtualMachine.Interact.ConsoleInteract"}))
+	g.Expect(NotAllowedForPermission([]string{"VirtualMachine.Interact.ConsoleInteract"}, []string{"VirtualMachine.Interact.ConsoleInteract"})).To(BeEmpty())
+}
+
+func TestMissing(t *testing.T) {
+	g := NewWithT(t)
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	s, err := session.GetOrCreate(context.Background(), session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The simulator grants every privilege to its user.
+	missing, err := Missing(context.Background(), s, Required(Features{LinkedClones: true, ClusterModules: true}))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(missing).To(BeEmpty())
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	goctx "context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// ErrExceeded is returned when the clone of a VSphereVM would exceed a
+// VSphereResourceQuota of its namespace.
+var ErrExceeded = errors.New("exceeded a VSphereResourceQuota")
+
+// Admitter enforces the VSphereResourceQuotas when the VMs are cloned. The
+// admissions are serialized, and the VSphereVMs admitted are recorded until
+// their clone is seen in the cache, so that the VSphereVMs cloned at the same
+// time cannot all be admitted against the same usage.
+type Admitter struct {
+	mu sync.Mutex
+	// admitted are the UIDs of the VSphereVMs admitted, by namespace.
+	admitted map[string]map[types.UID]struct{}
+}
+
+// NewAdmitter returns an Admitter which has admitted no VSphereVM.
+func NewAdmitter() *Admitter {
+	return &Admitter{admitted: map[string]map[types.UID]struct{}{}}
+}
+
+// Admit returns nil when the VM of a VSphereVM may be cloned within the
+// VSphereResourceQuotas of its namespace, and records its admission.
+// Otherwise it returns an error wrapping ErrExceeded. The resources of the
+// VSphereVMs of the namespace which were admitted, or whose VM was cloned,
+// are counted, whether they belong to a VSphereMachine, a warm pool or a
+// machine pool. A nil Admitter admits every VSphereVM.
+func (a *Admitter) Admit(ctx goctx.Context, c client.Reader, vm *infrav1.VSphereVM) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	admitted := a.admitted[vm.Namespace]
+	if _, ok := admitted[vm.UID]; ok {
+		return nil
+	}
+
+	quotas := &infrav1.VSphereResourceQuotaList{}
+	if err := c.List(ctx, quotas, client.InNamespace(vm.Namespace)); err != nil {
+		return errors.Wrapf(err, "failed to list the VSphereResourceQuotas of namespace %s", vm.Namespace)
+	}
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+
+	vms := &infrav1.VSphereVMList{}
+	if err := c.List(ctx, vms, client.InNamespace(vm.Namespace)); err != nil {
+		return errors.Wrapf(err, "failed to list the VSphereVMs of namespace %s", vm.Namespace)
+	}
+
+	// Forget the VSphereVMs which no longer exist, or whose clone is now
+	// recorded in their status, and count the others which were admitted.
+	remaining := map[types.UID]struct{}{}
+	used := infrav1.VSphereResources{NumCPUs: new(int64), MemoryMiB: new(int64), DiskGiB: new(int64)}
+	for i := range vms.Items {
+		other := &vms.Items[i]
+		if other.UID == vm.UID {
+			continue
+		}
+		_, ok := admitted[other.UID]
+		if isCloned(other) {
+			ok = true
+		} else if ok {
+			remaining[other.UID] = struct{}{}
+		}
+		if ok {
+			used = Add(used, Requests(other.Spec.VirtualMachineCloneSpec))
+		}
+	}
+	a.admitted[vm.Namespace] = remaining
+
+	requested := Requests(vm.Spec.VirtualMachineCloneSpec)
+	for _, q := range quotas.Items {
+		hard := q.Spec.Hard
+		if unrequested := Unrequested(hard, vm.Spec.VirtualMachineCloneSpec); len(unrequested) > 0 {
+			return errors.Wrapf(ErrExceeded, "must set %s, limited by VSphereResourceQuota %s", strings.Join(unrequested, ", "), q.Name)
+		}
+		if exceeded := Exceeded(hard, Add(used, requested)); exceeded != "" {
+			return errors.Wrapf(ErrExceeded, "exceeded VSphereResourceQuota %s: %s", q.Name, exceeded)
+		}
+	}
+	remaining[vm.UID] = struct{}{}
+	return nil
+}
+
+// isCloned returns whether the VM of a VSphereVM was cloned, or is being
+// cloned. The VSphereVM of a warm pool whose VM was claimed is not, as the
+// VM is counted with the VSphereVM which claimed it.
+func isCloned(vm *infrav1.VSphereVM) bool {
+	if _, ok := vm.Annotations[infrav1.WarmPoolClaimedByAnnotation]; ok {
+		return false
+	}
+	return vm.Spec.BiosUUID != "" || vm.Status.TaskRef != "" || vm.Status.Ready
+}
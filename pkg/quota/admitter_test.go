@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func newVM(name string, numCPUs int32) *infrav1.VSphereVM {
+	vm := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name, UID: types.UID(name)}}
+	vm.Spec.NumCPUs, vm.Spec.MemoryMiB, vm.Spec.DiskGiB = numCPUs, 4096, 20
+	return vm
+}
+
+func TestAdmitter_Admit(t *testing.T) {
+	g := NewWithT(t)
+
+	cloned := newVM("cloned", 2)
+	cloned.Spec.BiosUUID = "4216e5e8-fe4e-4a1b-8d20-0a7b2f6c5d10"
+	claimed := newVM("claimed", 2)
+	claimed.Spec.BiosUUID = "4216e5e8-fe4e-4a1b-8d20-0a7b2f6c5d11"
+	claimed.Annotations = map[string]string{infrav1.WarmPoolClaimedByAnnotation: "machine-0"}
+	first, second, third := newVM("first", 4), newVM("second", 4), newVM("third", 2)
+
+	objects := []client.Object{
+		&infrav1.VSphereResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "compute"},
+			Spec:       infrav1.VSphereResourceQuotaSpec{Hard: infrav1.VSphereResources{NumCPUs: pointer.Int64(8)}},
+		},
+		cloned, claimed, first, second, third,
+	}
+	scheme := runtime.NewScheme()
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	a := NewAdmitter()
+
+	// The first VSphereVM is admitted along with the cloned one, and neither
+	// the VSphereVM whose VM was claimed nor the ones not admitted count.
+	g.Expect(a.Admit(goctx.Background(), c, first)).To(Succeed())
+
+	// The second one is refused, although the clone of the first one is not
+	// recorded in its status yet.
+	err := a.Admit(goctx.Background(), c, second)
+	g.Expect(errors.Is(err, ErrExceeded)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("exceeded VSphereResourceQuota compute: numCPUs: 10 > 8"))
+
+	// A VSphereVM admitted stays admitted when its clone is retried.
+	g.Expect(a.Admit(goctx.Background(), c, third)).To(Succeed())
+	g.Expect(a.Admit(goctx.Background(), c, third)).To(Succeed())
+
+	// The resources of a deleted VSphereVM are released.
+	g.Expect(c.Delete(goctx.Background(), first)).To(Succeed())
+	g.Expect(a.Admit(goctx.Background(), c, second)).To(Succeed())
+
+	// A VSphereVM leaving a limited resource to its template is refused.
+	unrequested := newVM("unrequested", 0)
+	g.Expect(c.Create(goctx.Background(), unrequested)).To(Succeed())
+	err = a.Admit(goctx.Background(), c, unrequested)
+	g.Expect(errors.Is(err, ErrExceeded)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("must set numCPUs, limited by VSphereResourceQuota compute"))
+
+	// A nil Admitter admits every VSphereVM.
+	var none *Admitter
+	g.Expect(none.Admit(goctx.Background(), c, unrequested)).To(Succeed())
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quota computes the vSphere resources requested by the
+// VSphereMachines of a namespace, which VSphereResourceQuotas limit, and
+// enforces the quotas when the VMs are cloned.
+package quota
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// resource is a resource of VSphereResources.
+type resource struct {
+	// name is the name of the field of the resource.
+	name string
+	// field returns the field of the resource.
+	field func(*infrav1.VSphereResources) **int64
+}
+
+// resources are the resources of VSphereResources.
+var resources = []resource{
+	{name: "numCPUs", field: func(r *infrav1.VSphereResources) **int64 { return &r.NumCPUs }},
+	{name: "memoryMiB", field: func(r *infrav1.VSphereResources) **int64 { return &r.MemoryMiB }},
+	{name: "diskGiB", field: func(r *infrav1.VSphereResources) **int64 { return &r.DiskGiB }},
+}
+
+// Requests returns the resources requested by a clone spec. A resource which
+// the spec does not set, e.g. the number of CPUs of the template, is zero.
+func Requests(spec infrav1.VirtualMachineCloneSpec) infrav1.VSphereResources {
+	diskGiB := int64(spec.DiskGiB)
+	for _, size := range spec.AdditionalDisksGiB {
+		diskGiB += int64(size)
+	}
+	for _, disk := range spec.DataDisks {
+		diskGiB += int64(disk.SizeGiB)
+	}
+	return infrav1.VSphereResources{
+		NumCPUs:   pointer.Int64(int64(spec.NumCPUs)),
+		MemoryMiB: pointer.Int64(spec.MemoryMiB),
+		DiskGiB:   pointer.Int64(diskGiB),
+	}
+}
+
+// Usage returns the resources requested by VSphereMachines. The machines
+// being deleted are still counted, as their VMs are not destroyed yet.
+func Usage(machines []infrav1.VSphereMachine) infrav1.VSphereResources {
+	used := infrav1.VSphereResources{NumCPUs: pointer.Int64(0), MemoryMiB: pointer.Int64(0), DiskGiB: pointer.Int64(0)}
+	for i := range machines {
+		used = Add(used, Requests(machines[i].Spec.VirtualMachineCloneSpec))
+	}
+	return used
+}
+
+// Add returns the sum of two amounts of resources. A resource is only set in
+// the sum if it is set in both amounts.
+func Add(a, b infrav1.VSphereResources) infrav1.VSphereResources {
+	var sum infrav1.VSphereResources
+	for _, r := range resources {
+		if x, y := *r.field(&a), *r.field(&b); x != nil && y != nil {
+			*r.field(&sum) = pointer.Int64(*x + *y)
+		}
+	}
+	return sum
+}
+
+// Limited returns the resources of an amount limited by hard limits.
+func Limited(hard, amount infrav1.VSphereResources) infrav1.VSphereResources {
+	var limited infrav1.VSphereResources
+	for _, r := range resources {
+		if *r.field(&hard) != nil {
+			*r.field(&limited) = *r.field(&amount)
+		}
+	}
+	return limited
+}
+
+// Exceeded returns a description of the resources of an amount exceeding
+// hard limits, e.g. "numCPUs: 12 > 8", or an empty string.
+func Exceeded(hard, amount infrav1.VSphereResources) string {
+	var exceeded []string
+	for _, r := range resources {
+		if limit, value := *r.field(&hard), *r.field(&amount); limit != nil && value != nil && *value > *limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s: %d > %d", r.name, *value, *limit))
+		}
+	}
+	return strings.Join(exceeded, ", ")
+}
+
+// Unrequested returns the names of the resources limited by hard limits which
+// a clone spec does not request explicitly. Their amount is only known once
+// the VM is cloned from its template, so it cannot be accounted for.
+func Unrequested(hard infrav1.VSphereResources, spec infrav1.VirtualMachineCloneSpec) []string {
+	requests := Requests(spec)
+	var unrequested []string
+	for _, r := range resources {
+		if *r.field(&hard) != nil && **r.field(&requests) == 0 {
+			unrequested = append(unrequested, r.name)
+		}
+	}
+	return unrequested
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestRequests(t *testing.T) {
+	g := NewWithT(t)
+	requests := Requests(infrav1.VirtualMachineCloneSpec{
+		NumCPUs:            4,
+		MemoryMiB:          8192,
+		DiskGiB:            40,
+		AdditionalDisksGiB: []int32{10, 20},
+		DataDisks:          []infrav1.DataDiskSpec{{Name: "etcd", SizeGiB: 5}},
+	})
+	g.Expect(requests).To(Equal(infrav1.VSphereResources{NumCPUs: pointer.Int64(4), MemoryMiB: pointer.Int64(8192), DiskGiB: pointer.Int64(75)}))
+}
+
+func TestUsage(t *testing.T) {
+	g := NewWithT(t)
+	machine := func(numCPUs int32, memoryMiB int64) infrav1.VSphereMachine {
+		m := infrav1.VSphereMachine{}
+		m.Spec.NumCPUs, m.Spec.MemoryMiB, m.Spec.DiskGiB = numCPUs, memoryMiB, 20
+		return m
+	}
+	g.Expect(Usage(nil)).To(Equal(infrav1.VSphereResources{NumCPUs: pointer.Int64(0), MemoryMiB: pointer.Int64(0), DiskGiB: pointer.Int64(0)}))
+	g.Expect(Usage([]infrav1.VSphereMachine{machine(2, 4096), machine(4, 8192)})).To(Equal(infrav1.VSphereResources{NumCPUs: pointer.Int64(6), MemoryMiB: pointer.Int64(12288), DiskGiB: pointer.Int64(40)}))
+}
+
+func TestExceeded(t *testing.T) {
+	hard := infrav1.VSphereResources{NumCPUs: pointer.Int64(8), MemoryMiB: pointer.Int64(16384)}
+	tests := []struct {
+		name   string
+		amount infrav1.VSphereResources
+		want   string
+	}{
+		{
+			name:   "within the limits",
+			amount: infrav1.VSphereResources{NumCPUs: pointer.Int64(8), MemoryMiB: pointer.Int64(16384), DiskGiB: pointer.Int64(1000)},
+		},
+		{
+			name:   "exceeding a limit",
+			amount: infrav1.VSphereResources{NumCPUs: pointer.Int64(10), MemoryMiB: pointer.Int64(16384)},
+			want:   "numCPUs: 10 > 8",
+		},
+		{
+			name:   "exceeding all the limits",
+			amount: infrav1.VSphereResources{NumCPUs: pointer.Int64(10), MemoryMiB: pointer.Int64(20000)},
+			want:   "numCPUs: 10 > 8, memoryMiB: 20000 > 16384",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(Exceeded(hard, tt.amount)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestLimited(t *testing.T) {
+	g := NewWithT(t)
+	hard := infrav1.VSphereResources{DiskGiB: pointer.Int64(100)}
+	amount := infrav1.VSphereResources{NumCPUs: pointer.Int64(2), MemoryMiB: pointer.Int64(4096), DiskGiB: pointer.Int64(40)}
+	g.Expect(Limited(hard, amount)).To(Equal(infrav1.VSphereResources{DiskGiB: pointer.Int64(40)}))
+}
+
+func TestUnrequested(t *testing.T) {
+	g := NewWithT(t)
+	hard := infrav1.VSphereResources{NumCPUs: pointer.Int64(8), DiskGiB: pointer.Int64(100)}
+	g.Expect(Unrequested(hard, infrav1.VirtualMachineCloneSpec{MemoryMiB: 4096})).To(Equal([]string{"numCPUs", "diskGiB"}))
+	g.Expect(Unrequested(hard, infrav1.VirtualMachineCloneSpec{NumCPUs: 2, AdditionalDisksGiB: []int32{10}})).To(BeEmpty())
+}
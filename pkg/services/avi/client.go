@@ -0,0 +1,305 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package avi provisions the control plane endpoint of a cluster as a virtual
+// service of NSX Advanced Load Balancer, formerly Avi Vantage.
+package avi
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+const (
+	// DefaultTenant is the tenant used when none is set.
+	DefaultTenant = "admin"
+	// DefaultCloud is the cloud used when none is set.
+	DefaultCloud = "Default-Cloud"
+	// DefaultAPIVersion is the version of the API used when none is set.
+	DefaultAPIVersion = "20.1.1"
+
+	poolPath           = "/api/pool"
+	vsVIPPath          = "/api/vsvip"
+	virtualServicePath = "/api/virtualservice"
+)
+
+// Client is a client of the REST API of an NSX Advanced Load Balancer
+// controller. It authenticates with HTTP basic authentication, which must be
+// allowed in the system configuration of the controller.
+type Client struct {
+	baseURL    string
+	username   string
+	password   string
+	tenant     string
+	apiVersion string
+	cloud      string
+	network    string
+	httpClient *http.Client
+}
+
+// NewClient returns a client of the controller of an AVIEndpointSpec.
+func NewClient(spec infrav1.AVIEndpointSpec, username, password string) (*Client, error) {
+	if _, err := url.ParseRequestURI(spec.Controller); err != nil {
+		return nil, errors.Wrapf(err, "invalid controller URL %q", spec.Controller)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if spec.CACertificate != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(spec.CACertificate)) {
+			return nil, errors.New("failed to parse the CA certificate of the controller")
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(spec.Controller, "/"),
+		username:   username,
+		password:   password,
+		tenant:     valueOrDefault(spec.Tenant, DefaultTenant),
+		apiVersion: valueOrDefault(spec.APIVersion, DefaultAPIVersion),
+		cloud:      valueOrDefault(spec.Cloud, DefaultCloud),
+		network:    spec.Network,
+		httpClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// EnsureVirtualService creates or updates the pool, the VsVip and the virtual
+// service named name, balancing port to the servers. It returns the virtual
+// IP address allocated to the virtual service, which is empty until the IPAM
+// of the controller allocates it.
+func (c *Client) EnsureVirtualService(ctx context.Context, name string, port int32, servers []string) (string, error) {
+	poolServers := make([]interface{}, 0, len(servers))
+	for _, server := range servers {
+		addrType := "V4"
+		if strings.Contains(server, ":") {
+			addrType = "V6"
+		}
+		poolServers = append(poolServers, map[string]interface{}{
+			"ip": map[string]interface{}{"addr": server, "type": addrType},
+		})
+	}
+	pool, err := c.ensure(ctx, poolPath, name, map[string]interface{}{
+		"name":                name,
+		"cloud_ref":           c.ref("/api/cloud", c.cloud),
+		"default_server_port": port,
+		"servers":             poolServers,
+		"health_monitor_refs": []interface{}{c.ref("/api/healthmonitor", "System-TCP")},
+	}, func(object map[string]interface{}) bool {
+		if equalStrings(serverAddresses(object["servers"]), servers) {
+			return false
+		}
+		object["servers"] = poolServers
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	vsVIP, err := c.ensure(ctx, vsVIPPath, name, map[string]interface{}{
+		"name":      name,
+		"cloud_ref": c.ref("/api/cloud", c.cloud),
+		"vip": []interface{}{
+			map[string]interface{}{
+				"vip_id":           "0",
+				"auto_allocate_ip": true,
+				"ipam_network_subnet": map[string]interface{}{
+					"network_ref": c.ref("/api/network", c.network),
+				},
+			},
+		},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := c.ensure(ctx, virtualServicePath, name, map[string]interface{}{
+		"name":                    name,
+		"cloud_ref":               c.ref("/api/cloud", c.cloud),
+		"vsvip_ref":               vsVIP["url"],
+		"pool_ref":                pool["url"],
+		"services":                []interface{}{map[string]interface{}{"port": port}},
+		"application_profile_ref": c.ref("/api/applicationprofile", "System-L4-Application"),
+	}, nil); err != nil {
+		return "", err
+	}
+
+	return vipAddress(vsVIP), nil
+}
+
+// DeleteVirtualService deletes the virtual service named name, with its VsVip
+// and pool. Missing objects are ignored.
+func (c *Client) DeleteVirtualService(ctx context.Context, name string) error {
+	for _, path := range []string{virtualServicePath, vsVIPPath, poolPath} {
+		object, err := c.get(ctx, path, name)
+		if err != nil {
+			return err
+		}
+		if object == nil {
+			continue
+		}
+		if err := c.do(ctx, http.MethodDelete, path+"/"+fmt.Sprint(object["uuid"]), nil, nil); err != nil {
+			return errors.Wrapf(err, "failed to delete %s %s", path, name)
+		}
+	}
+	return nil
+}
+
+// ensure creates the object named name at path if it does not exist, or
+// updates it when update, if any, changes the existing object.
+func (c *Client) ensure(ctx context.Context, path, name string, desired map[string]interface{}, update func(map[string]interface{}) bool) (map[string]interface{}, error) {
+	object, err := c.get(ctx, path, name)
+	if err != nil {
+		return nil, err
+	}
+	if object == nil {
+		created := map[string]interface{}{}
+		if err := c.do(ctx, http.MethodPost, path, desired, &created); err != nil {
+			return nil, errors.Wrapf(err, "failed to create %s %s", path, name)
+		}
+		return created, nil
+	}
+
+	if update == nil || !update(object) {
+		return object, nil
+	}
+	updated := map[string]interface{}{}
+	if err := c.do(ctx, http.MethodPut, path+"/"+fmt.Sprint(object["uuid"]), object, &updated); err != nil {
+		return nil, errors.Wrapf(err, "failed to update %s %s", path, name)
+	}
+	return updated, nil
+}
+
+// get returns the object named name at path, or nil if it does not exist.
+func (c *Client) get(ctx context.Context, path, name string) (map[string]interface{}, error) {
+	list := struct {
+		Results []map[string]interface{} `json:"results"`
+	}{}
+	if err := c.do(ctx, http.MethodGet, path+"?name="+url.QueryEscape(name), nil, &list); err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s %s", path, name)
+	}
+	if len(list.Results) == 0 {
+		return nil, nil
+	}
+	return list.Results[0], nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Avi-Version", c.apiVersion)
+	req.Header.Set("X-Avi-Tenant", c.tenant)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodDelete {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if result == nil {
+		return nil
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(result), "failed to decode the response")
+}
+
+// ref returns a reference to an object by name, which the controller resolves
+// on creation.
+func (c *Client) ref(path, name string) string {
+	return path + "?name=" + url.QueryEscape(name)
+}
+
+func vipAddress(vsVIP map[string]interface{}) string {
+	vips, _ := vsVIP["vip"].([]interface{})
+	for _, v := range vips {
+		vip, _ := v.(map[string]interface{})
+		ip, _ := vip["ip_address"].(map[string]interface{})
+		if addr, _ := ip["addr"].(string); addr != "" {
+			return addr
+		}
+	}
+	return ""
+}
+
+// serverAddresses returns the addresses of the servers of a pool returned by
+// the controller.
+func serverAddresses(servers interface{}) []string {
+	list, _ := servers.([]interface{})
+	result := make([]string, 0, len(list))
+	for _, s := range list {
+		server, _ := s.(map[string]interface{})
+		ip, _ := server["ip"].(map[string]interface{})
+		if addr, _ := ip["addr"].(string); addr != "" {
+			result = append(result, addr)
+		}
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package avi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// fakeController is a minimal in-memory NSX Advanced Load Balancer controller.
+type fakeController struct {
+	mu      sync.Mutex
+	objects map[string]map[string]map[string]interface{}
+	nextID  int
+	puts    int
+	headers http.Header
+}
+
+func newFakeController() *fakeController {
+	return &fakeController{objects: map[string]map[string]map[string]interface{}{}}
+}
+
+func (f *fakeController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.headers = r.Header.Clone()
+	if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+	kind := parts[0]
+	if f.objects[kind] == nil {
+		f.objects[kind] = map[string]map[string]interface{}{}
+	}
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 1:
+		results := []interface{}{}
+		for _, object := range f.objects[kind] {
+			if object["name"] == r.URL.Query().Get("name") {
+				results = append(results, object)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": len(results), "results": results})
+	case r.Method == http.MethodPost && len(parts) == 1:
+		object := map[string]interface{}{}
+		_ = json.NewDecoder(r.Body).Decode(&object)
+		f.nextID++
+		uuid := fmt.Sprintf("%s-%d", kind, f.nextID)
+		object["uuid"] = uuid
+		object["url"] = "/api/" + kind + "/" + uuid
+		if kind == "vsvip" {
+			vip := object["vip"].([]interface{})[0].(map[string]interface{})
+			vip["ip_address"] = map[string]interface{}{"addr": "192.168.100.10", "type": "V4"}
+		}
+		f.objects[kind][uuid] = object
+		_ = json.NewEncoder(w).Encode(object)
+	case r.Method == http.MethodPut && len(parts) == 2:
+		object := map[string]interface{}{}
+		_ = json.NewDecoder(r.Body).Decode(&object)
+		f.objects[kind][parts[1]] = object
+		f.puts++
+		_ = json.NewEncoder(w).Encode(object)
+	case r.Method == http.MethodDelete && len(parts) == 2:
+		if _, ok := f.objects[kind][parts[1]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.objects[kind], parts[1])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeController) count(kind string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.objects[kind])
+}
+
+func TestEnsureVirtualService(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	controller := newFakeController()
+	server := httptest.NewServer(controller)
+	defer server.Close()
+
+	c, err := NewClient(infrav1.AVIEndpointSpec{Controller: server.URL, Network: "vip-network"}, "admin", "secret")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	vip, err := c.EnsureVirtualService(ctx, "ns-cluster-control-plane", 6443, []string{"10.0.0.11"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vip).To(Equal("192.168.100.10"))
+	g.Expect(controller.count("pool")).To(Equal(1))
+	g.Expect(controller.count("vsvip")).To(Equal(1))
+	g.Expect(controller.count("virtualservice")).To(Equal(1))
+	g.Expect(controller.headers.Get("X-Avi-Version")).To(Equal(DefaultAPIVersion))
+	g.Expect(controller.headers.Get("X-Avi-Tenant")).To(Equal(DefaultTenant))
+
+	// Reconciling the same servers does not update the pool.
+	_, err = c.EnsureVirtualService(ctx, "ns-cluster-control-plane", 6443, []string{"10.0.0.11"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(controller.puts).To(Equal(0))
+	g.Expect(controller.count("pool")).To(Equal(1))
+
+	_, err = c.EnsureVirtualService(ctx, "ns-cluster-control-plane", 6443, []string{"10.0.0.12", "10.0.0.11"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(controller.puts).To(Equal(1))
+	pool, err := c.get(ctx, poolPath, "ns-cluster-control-plane")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(serverAddresses(pool["servers"])).To(ConsistOf("10.0.0.11", "10.0.0.12"))
+
+	g.Expect(c.DeleteVirtualService(ctx, "ns-cluster-control-plane")).To(Succeed())
+	g.Expect(controller.count("pool")).To(Equal(0))
+	g.Expect(controller.count("vsvip")).To(Equal(0))
+	g.Expect(controller.count("virtualservice")).To(Equal(0))
+
+	// Deleting a missing virtual service succeeds.
+	g.Expect(c.DeleteVirtualService(ctx, "ns-cluster-control-plane")).To(Succeed())
+}
+
+func TestClientErrors(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	server := httptest.NewServer(newFakeController())
+	defer server.Close()
+
+	_, err := NewClient(infrav1.AVIEndpointSpec{Controller: "not a url"}, "admin", "secret")
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = NewClient(infrav1.AVIEndpointSpec{Controller: server.URL, CACertificate: "not a certificate"}, "admin", "secret")
+	g.Expect(err).To(HaveOccurred())
+
+	c, err := NewClient(infrav1.AVIEndpointSpec{Controller: server.URL}, "admin", "wrong")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.EnsureVirtualService(ctx, "name", 6443, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("401")))
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bootstrapdata adds files and settings to the bootstrap data of a
+// VM, in the cloud-config or the Ignition format.
+package bootstrapdata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// File is a file written by the bootstrap data.
+type File struct {
+	// Path is the absolute path of the file.
+	Path string
+
+	// Content is the content of the file.
+	Content []byte
+}
+
+// Unit is a systemd unit written by the bootstrap data.
+type Unit struct {
+	// Name is the name of the unit, e.g. "capv.timer".
+	Name string
+
+	// Content is the content of the unit file.
+	Content []byte
+
+	// Enabled is true when the unit is enabled and started.
+	Enabled bool
+}
+
+// Patch describes the changes made to bootstrap data.
+type Patch struct {
+	// Files are written by the bootstrap data, unless it already writes a
+	// file at their path, e.g. when the file is set in the cluster template.
+	Files []File
+
+	// Commands are run before the other commands of a cloud-config when at
+	// least one of the Files is added. They are not run for Ignition, whose
+	// files are written before the services of the guest are started.
+	Commands []string
+
+	// NTPServers are the NTP servers of the guest, unless the bootstrap data
+	// already configures NTP.
+	NTPServers []string
+
+	// Units are the systemd units written by the bootstrap data, unless it
+	// already writes a unit of the same name.
+	Units []Unit
+
+	// NetworkHotplug is true when cloud-init applies the network
+	// configuration of the metadata on the hotplug of a NIC, unless the
+	// bootstrap data already sets its updates. It does not apply to Ignition,
+	// which configures the network only on the first boot.
+	NetworkHotplug bool
+}
+
+// IsEmpty returns true when the patch does not change bootstrap data.
+func (p Patch) IsEmpty() bool {
+	return len(p.Files) == 0 && len(p.NTPServers) == 0 && len(p.Units) == 0 && !p.NetworkHotplug
+}
+
+// Merge returns the patch applying both p and other.
+func (p Patch) Merge(other Patch) Patch {
+	return Patch{
+		Files:      append(append([]File{}, p.Files...), other.Files...),
+		Commands:   append(append([]string{}, p.Commands...), other.Commands...),
+		NTPServers: append(append([]string{}, p.NTPServers...), other.NTPServers...),
+		Units:      append(append([]Unit{}, p.Units...), other.Units...),
+
+		NetworkHotplug: p.NetworkHotplug || other.NetworkHotplug,
+	}
+}
+
+// timesyncdPath is the path of the configuration of systemd-timesyncd, which
+// synchronizes the clock of Ignition guests.
+const timesyncdPath = "/etc/systemd/timesyncd.conf.d/capv.conf"
+
+// ContainerdProxyPath is the path of the systemd drop-in setting the proxy of
+// containerd.
+const ContainerdProxyPath = "/etc/systemd/system/containerd.service.d/http-proxy.conf"
+
+// ClockReportKey is the guestinfo key in which the guest reports the skew of
+// its clock from the clock of its ESXi host, in seconds, positive when the
+// guest clock is ahead.
+const ClockReportKey = "guestinfo.capv.clock-skew"
+
+// ClockReportPeriod is the interval between two measures of the skew of the
+// guest clock.
+const ClockReportPeriod = 30 * time.Second
+
+// ClockReportPatch returns the patch adding a systemd timer, which measures
+// the skew of the guest clock from the clock of its ESXi host through VMware
+// Tools every ClockReportPeriod. The skew is only written to the guestinfo
+// when it changed by more than a second since it was last written, so the
+// configuration of the VM is not updated on every measure.
+func ClockReportPatch() Patch {
+	service := "[Unit]\n" +
+		"Description=Report the skew of the guest clock to vCenter\n" +
+		"\n" +
+		"[Service]\n" +
+		"Type=oneshot\n" +
+		"ExecStart=/bin/sh -c '" +
+		"skew=$$(( $$(date +%%s) - $$(date -d \"$$(vmware-toolbox-cmd stat hosttime)\" +%%s) )) && " +
+		"{ previous=$$(vmware-rpctool \"info-get " + ClockReportKey + "\" 2>/dev/null) && [ -n \"$$previous\" ] && " +
+		"[ $$(( skew - previous )) -le 1 ] && [ $$(( previous - skew )) -le 1 ] || " +
+		"vmware-rpctool \"info-set " + ClockReportKey + " $$skew\"; }'\n"
+	timer := "[Unit]\n" +
+		"Description=Report the skew of the guest clock to vCenter periodically\n" +
+		"\n" +
+		"[Timer]\n" +
+		"OnBootSec=0\n" +
+		fmt.Sprintf("OnUnitActiveSec=%ds\n", int(ClockReportPeriod.Seconds())) +
+		"AccuracySec=1s\n" +
+		"\n" +
+		"[Install]\n" +
+		"WantedBy=timers.target\n"
+	return Patch{
+		Units: []Unit{
+			{Name: "capv-clock-report.service", Content: []byte(service)},
+			{Name: "capv-clock-report.timer", Content: []byte(timer), Enabled: true},
+		},
+	}
+}
+
+// NetworkHotplugPatch returns the patch enabling the network configuration of
+// the NICs hot-added to the VM by cloud-init.
+func NetworkHotplugPatch() Patch {
+	return Patch{NetworkHotplug: true}
+}
+
+// NetworkPatch returns the patch setting the NTP servers and the proxy of the
+// network of a VM. The proxy is set in the environment of containerd, which
+// is restarted by a cloud-config as it may already run when the files of the
+// cloud-config are written.
+func NetworkPatch(network infrav1.NetworkSpec) Patch {
+	patch := Patch{NTPServers: network.NTPServers}
+	if proxy := network.Proxy; proxy != nil {
+		var dropIn strings.Builder
+		dropIn.WriteString("[Service]\n")
+		if proxy.HTTPProxy != "" {
+			fmt.Fprintf(&dropIn, "Environment=\"HTTP_PROXY=%s\"\n", proxy.HTTPProxy)
+		}
+		if proxy.HTTPSProxy != "" {
+			fmt.Fprintf(&dropIn, "Environment=\"HTTPS_PROXY=%s\"\n", proxy.HTTPSProxy)
+		}
+		if len(proxy.NoProxy) > 0 {
+			fmt.Fprintf(&dropIn, "Environment=\"NO_PROXY=%s\"\n", strings.Join(proxy.NoProxy, ","))
+		}
+		patch.Files = append(patch.Files, File{Path: ContainerdProxyPath, Content: []byte(dropIn.String())})
+		patch.Commands = []string{"systemctl daemon-reload", "systemctl restart containerd"}
+	}
+	return patch
+}
+
+// Apply applies a patch to bootstrap data in the cloud-config or Ignition
+// format. The bootstrap data is returned as is when the patch does not
+// change it.
+func Apply(format infrav1.BootstrapFormat, data []byte, patch Patch) ([]byte, error) {
+	if format == infrav1.IgnitionBootstrapFormat {
+		return applyIgnition(data, patch)
+	}
+	return applyCloudConfig(data, patch)
+}
+
+// applyCloudConfig adds the files and the units to the write_files of a
+// cloud-config, the commands, followed by the ones enabling the units, at the
+// beginning of its runcmd, the NTP servers to its ntp and the hotplug of the
+// network to its updates.
+// The leading comment lines, e.g. "## template: jinja", are kept.
+func applyCloudConfig(data []byte, patch Patch) ([]byte, error) {
+	var header bytes.Buffer
+	isCloudConfig := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		if strings.HasPrefix(line, "#cloud-config") {
+			isCloudConfig = true
+		}
+		header.WriteString(line + "\n")
+	}
+	if !isCloudConfig {
+		return nil, errors.New("the bootstrap data is not a cloud-config")
+	}
+
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the cloud-config")
+	}
+
+	changed := false
+	commands := []interface{}{}
+	files, _ := config["write_files"].([]interface{})
+	for _, f := range patch.Files {
+		if hasFile(files, f.Path) {
+			continue
+		}
+		files = append(files, cloudConfigFile(f.Path, f.Content))
+		changed = true
+	}
+	if changed {
+		for _, command := range patch.Commands {
+			commands = append(commands, command)
+		}
+	}
+	var units []interface{}
+	for _, u := range patch.Units {
+		path := unitsDir + u.Name
+		if hasFile(files, path) {
+			continue
+		}
+		files = append(files, cloudConfigFile(path, u.Content))
+		if u.Enabled {
+			units = append(units, "systemctl enable --now "+u.Name)
+		}
+		changed = true
+	}
+	if len(units) > 0 {
+		commands = append(append(commands, "systemctl daemon-reload"), units...)
+	}
+	if changed {
+		config["write_files"] = files
+		if len(commands) > 0 {
+			runcmd, _ := config["runcmd"].([]interface{})
+			config["runcmd"] = append(commands, runcmd...)
+		}
+	}
+	if _, ok := config["ntp"]; !ok && len(patch.NTPServers) > 0 {
+		config["ntp"] = map[string]interface{}{
+			"enabled": true,
+			"servers": patch.NTPServers,
+		}
+		changed = true
+	}
+	if _, ok := config["updates"]; !ok && patch.NetworkHotplug {
+		config["updates"] = map[string]interface{}{
+			"network": map[string]interface{}{
+				"when": []interface{}{"boot-new-instance", "hotplug"},
+			},
+		}
+		changed = true
+	}
+	if !changed {
+		return data, nil
+	}
+
+	body, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render the cloud-config")
+	}
+	return append(header.Bytes(), body...), nil
+}
+
+// applyIgnition adds the files to the files of an Ignition config, the NTP
+// servers to the configuration of systemd-timesyncd, and the units to its
+// systemd units. The files of the
+// version 2 of the config specification name their filesystem.
+func applyIgnition(data []byte, patch Patch) ([]byte, error) {
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the Ignition config")
+	}
+	storage, _ := config["storage"].(map[string]interface{})
+	if storage == nil {
+		storage = map[string]interface{}{}
+	}
+	ignition, _ := config["ignition"].(map[string]interface{})
+	version, _ := ignition["version"].(string)
+
+	patchFiles := append([]File{}, patch.Files...)
+	if len(patch.NTPServers) > 0 {
+		patchFiles = append(patchFiles, File{
+			Path:    timesyncdPath,
+			Content: []byte(fmt.Sprintf("[Time]\nNTP=%s\n", strings.Join(patch.NTPServers, " "))),
+		})
+	}
+
+	changed := false
+	files, _ := storage["files"].([]interface{})
+	for _, f := range patchFiles {
+		if hasFile(files, f.Path) {
+			continue
+		}
+		file := map[string]interface{}{
+			"path": f.Path,
+			// 0644
+			"mode": 420,
+			"contents": map[string]interface{}{
+				"source": "data:," + url.PathEscape(string(f.Content)),
+			},
+		}
+		if strings.HasPrefix(version, "2.") {
+			file["filesystem"] = "root"
+		}
+		files = append(files, file)
+		changed = true
+	}
+	if changed {
+		storage["files"] = files
+		config["storage"] = storage
+	}
+
+	systemd, _ := config["systemd"].(map[string]interface{})
+	if systemd == nil {
+		systemd = map[string]interface{}{}
+	}
+	units, _ := systemd["units"].([]interface{})
+	unitsChanged := false
+	for _, u := range patch.Units {
+		if hasUnit(units, u.Name) {
+			continue
+		}
+		unit := map[string]interface{}{
+			"name":     u.Name,
+			"contents": string(u.Content),
+		}
+		if u.Enabled {
+			unit["enabled"] = true
+		}
+		units = append(units, unit)
+		unitsChanged = true
+	}
+	if unitsChanged {
+		systemd["units"] = units
+		config["systemd"] = systemd
+	}
+	if !changed && !unitsChanged {
+		return data, nil
+	}
+
+	result, err := json.Marshal(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render the Ignition config")
+	}
+	return result, nil
+}
+
+// unitsDir is the directory of the systemd units written by a cloud-config.
+const unitsDir = "/etc/systemd/system/"
+
+func cloudConfigFile(path string, content []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"path":        path,
+		"owner":       "root:root",
+		"permissions": "0644",
+		"content":     string(content),
+	}
+}
+
+func hasUnit(units []interface{}, name string) bool {
+	for _, u := range units {
+		if unit, ok := u.(map[string]interface{}); ok && unit["name"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFile(files []interface{}, path string) bool {
+	for _, f := range files {
+		if file, ok := f.(map[string]interface{}); ok && file["path"] == path {
+			return true
+		}
+	}
+	return false
+}
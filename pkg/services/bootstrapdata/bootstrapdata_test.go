@@ -0,0 +1,222 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bootstrapdata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestNetworkPatch(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(NetworkPatch(infrav1.NetworkSpec{}).IsEmpty()).To(BeTrue())
+
+	patch := NetworkPatch(infrav1.NetworkSpec{
+		NTPServers: []string{"ntp.example.com"},
+		Proxy: &infrav1.ProxySpec{
+			HTTPProxy:  "http://proxy.example.com:3128",
+			HTTPSProxy: "http://proxy.example.com:3129",
+			NoProxy:    []string{"10.0.0.0/8", ".svc"},
+		},
+	})
+	g.Expect(patch.IsEmpty()).To(BeFalse())
+	g.Expect(patch.NTPServers).To(ConsistOf("ntp.example.com"))
+	g.Expect(patch.Files).To(HaveLen(1))
+	g.Expect(patch.Files[0].Path).To(Equal(ContainerdProxyPath))
+	g.Expect(string(patch.Files[0].Content)).To(Equal("[Service]\n" +
+		"Environment=\"HTTP_PROXY=http://proxy.example.com:3128\"\n" +
+		"Environment=\"HTTPS_PROXY=http://proxy.example.com:3129\"\n" +
+		"Environment=\"NO_PROXY=10.0.0.0/8,.svc\"\n"))
+	g.Expect(patch.Commands).To(Equal([]string{"systemctl daemon-reload", "systemctl restart containerd"}))
+}
+
+func TestApply_CloudConfig(t *testing.T) {
+	patch := Patch{
+		Files:      []File{{Path: "/etc/capv.conf", Content: []byte("capv")}},
+		Commands:   []string{"systemctl daemon-reload"},
+		NTPServers: []string{"ntp.example.com"},
+	}
+
+	t.Run("adds the files, commands and NTP servers", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data, err := Apply(infrav1.CloudConfigBootstrapFormat, []byte("## template: jinja\n#cloud-config\nruncmd:\n- kubeadm init\n"), patch)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(strings.HasPrefix(string(data), "## template: jinja\n#cloud-config\n")).To(BeTrue())
+
+		config := map[string]interface{}{}
+		g.Expect(yaml.Unmarshal(data, &config)).To(Succeed())
+		g.Expect(config["runcmd"]).To(Equal([]interface{}{"systemctl daemon-reload", "kubeadm init"}))
+		g.Expect(config["write_files"]).To(HaveLen(1))
+		g.Expect(config["write_files"].([]interface{})[0]).To(HaveKeyWithValue("path", "/etc/capv.conf"))
+		g.Expect(config["ntp"]).To(HaveKeyWithValue("servers", []interface{}{"ntp.example.com"}))
+
+		again, err := Apply(infrav1.CloudConfigBootstrapFormat, data, patch)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(again).To(Equal(data))
+	})
+
+	t.Run("keeps the files and the NTP settings of the cloud-config", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data := []byte("#cloud-config\nwrite_files:\n- path: /etc/capv.conf\n  content: mine\nntp:\n  servers:\n  - time.example.com\nruncmd:\n- kubeadm init\n")
+		result, err := Apply(infrav1.CloudConfigBootstrapFormat, data, patch)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(data))
+	})
+
+	t.Run("fails for another format", func(t *testing.T) {
+		g := NewWithT(t)
+
+		_, err := Apply(infrav1.CloudConfigBootstrapFormat, []byte("#!/bin/sh\necho\n"), patch)
+		g.Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestApply_NetworkHotplug(t *testing.T) {
+	patch := NetworkPatch(infrav1.NetworkSpec{}).Merge(NetworkHotplugPatch())
+
+	t.Run("applies the network configuration on hotplug", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect(patch.IsEmpty()).To(BeFalse())
+
+		data, err := Apply(infrav1.CloudConfigBootstrapFormat, []byte("#cloud-config\nruncmd:\n- kubeadm init\n"), patch)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		config := map[string]interface{}{}
+		g.Expect(yaml.Unmarshal(data, &config)).To(Succeed())
+		g.Expect(config["updates"]).To(Equal(map[string]interface{}{
+			"network": map[string]interface{}{
+				"when": []interface{}{"boot-new-instance", "hotplug"},
+			},
+		}))
+		g.Expect(config["runcmd"]).To(Equal([]interface{}{"kubeadm init"}))
+	})
+
+	t.Run("keeps the updates of the cloud-config", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data := []byte("#cloud-config\nupdates:\n  network:\n    when:\n    - boot\n")
+		result, err := Apply(infrav1.CloudConfigBootstrapFormat, data, patch)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(data))
+	})
+}
+
+func TestApply_Ignition(t *testing.T) {
+	g := NewWithT(t)
+
+	patch := Patch{
+		Files:      []File{{Path: "/etc/capv.conf", Content: []byte("capv")}},
+		Commands:   []string{"systemctl daemon-reload"},
+		NTPServers: []string{"ntp1.example.com", "ntp2.example.com"},
+	}
+	data, err := Apply(infrav1.IgnitionBootstrapFormat, []byte(`{"ignition":{"version":"2.3.0"}}`), patch)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	config := struct {
+		Storage struct {
+			Files []struct {
+				Path       string `json:"path"`
+				Filesystem string `json:"filesystem"`
+				Contents   struct {
+					Source string `json:"source"`
+				} `json:"contents"`
+			} `json:"files"`
+		} `json:"storage"`
+	}{}
+	g.Expect(json.Unmarshal(data, &config)).To(Succeed())
+	g.Expect(config.Storage.Files).To(HaveLen(2))
+	g.Expect(config.Storage.Files[0].Path).To(Equal("/etc/capv.conf"))
+	g.Expect(config.Storage.Files[0].Filesystem).To(Equal("root"))
+	g.Expect(config.Storage.Files[1].Path).To(Equal(timesyncdPath))
+	g.Expect(config.Storage.Files[1].Contents.Source).To(Equal("data:," + "%5BTime%5D%0ANTP=ntp1.example.com%20ntp2.example.com%0A"))
+
+	again, err := Apply(infrav1.IgnitionBootstrapFormat, data, patch)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again).To(Equal(data))
+}
+
+func TestClockReportPatch(t *testing.T) {
+	g := NewWithT(t)
+	patch := ClockReportPatch()
+	g.Expect(patch.Units).To(HaveLen(2))
+
+	// The skew is measured from the clock of the host, and only written when
+	// it changed since it was last written.
+	service := string(patch.Units[0].Content)
+	g.Expect(service).To(ContainSubstring("vmware-toolbox-cmd stat hosttime"))
+	g.Expect(service).To(ContainSubstring(`vmware-rpctool "info-get ` + ClockReportKey + `"`))
+	g.Expect(service).To(ContainSubstring(`|| vmware-rpctool "info-set ` + ClockReportKey + ` $$skew"`))
+}
+
+func TestApply_Units(t *testing.T) {
+	patch := ClockReportPatch()
+
+	t.Run("writes and enables the units of a cloud-config", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data, err := Apply(infrav1.CloudConfigBootstrapFormat, []byte("#cloud-config\nruncmd:\n- kubeadm init\n"), patch)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		config := map[string]interface{}{}
+		g.Expect(yaml.Unmarshal(data, &config)).To(Succeed())
+		g.Expect(config["write_files"]).To(HaveLen(2))
+		g.Expect(config["write_files"].([]interface{})[1]).To(HaveKeyWithValue("path", "/etc/systemd/system/capv-clock-report.timer"))
+		g.Expect(config["runcmd"]).To(Equal([]interface{}{
+			"systemctl daemon-reload",
+			"systemctl enable --now capv-clock-report.timer",
+			"kubeadm init",
+		}))
+
+		again, err := Apply(infrav1.CloudConfigBootstrapFormat, data, patch)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(again).To(Equal(data))
+	})
+
+	t.Run("adds the units to the systemd units of an Ignition config", func(t *testing.T) {
+		g := NewWithT(t)
+
+		data, err := Apply(infrav1.IgnitionBootstrapFormat, []byte(`{"ignition":{"version":"3.1.0"},"systemd":{"units":[{"name":"capv-clock-report.service","contents":"mine"}]}}`), patch)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		config := struct {
+			Storage *struct{} `json:"storage"`
+			Systemd struct {
+				Units []struct {
+					Name     string `json:"name"`
+					Enabled  bool   `json:"enabled"`
+					Contents string `json:"contents"`
+				} `json:"units"`
+			} `json:"systemd"`
+		}{}
+		g.Expect(json.Unmarshal(data, &config)).To(Succeed())
+		g.Expect(config.Storage).To(BeNil())
+		g.Expect(config.Systemd.Units).To(HaveLen(2))
+		g.Expect(config.Systemd.Units[0].Contents).To(Equal("mine"))
+		g.Expect(config.Systemd.Units[1].Name).To(Equal("capv-clock-report.timer"))
+		g.Expect(config.Systemd.Units[1].Enabled).To(BeTrue())
+		g.Expect(config.Systemd.Units[1].Contents).To(ContainSubstring("OnUnitActiveSec=30s"))
+	})
+}
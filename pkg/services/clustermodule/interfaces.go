@@ -0,0 +1,36 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustermodule
+
+import (
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// Service is used to manage the vCenter cluster modules enforcing the
+// anti-affinity of the VMs owned by a KubeadmControlPlane or a MachineDeployment.
+type Service interface {
+	// Create creates a cluster module for the object and returns its UUID.
+	// An empty UUID is returned when no module is required for the object.
+	Create(ctx *context.ClusterContext, wrapper Wrapper) (string, error)
+
+	// DoesExist reports whether the cluster module with the given UUID exists
+	// in the compute cluster used by the object.
+	DoesExist(ctx *context.ClusterContext, wrapper Wrapper, moduleUUID string) (bool, error)
+
+	// Remove deletes the cluster module with the given UUID.
+	Remove(ctx *context.ClusterContext, moduleUUID string) error
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustermodule
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/clustermodules"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+const vSphereMachineTemplateKind = "VSphereMachineTemplate"
+
+type service struct{}
+
+// NewService returns the default Service implementation.
+func NewService() Service {
+	return service{}
+}
+
+func (s service) Create(ctx *context.ClusterContext, wrapper Wrapper) (string, error) {
+	logger := ctx.Logger.WithValues("object", wrapper.GetName(), "namespace", wrapper.GetNamespace())
+
+	template, err := fetchMachineTemplate(ctx, wrapper, logger)
+	if err != nil || template == nil {
+		return "", err
+	}
+
+	vCenterSession, err := fetchSession(ctx, template.Spec.Template.Spec.Datacenter)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get vCenter session for %s", ctx)
+	}
+
+	computeClusterRef, err := getComputeClusterResource(ctx, vCenterSession, template.Spec.Template.Spec.ResourcePool)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get compute cluster for object %s/%s", wrapper.GetNamespace(), wrapper.GetName())
+	}
+
+	provider := clustermodules.NewProvider(vCenterSession.TagManager.Client)
+	moduleUUID, err := provider.CreateModule(ctx, computeClusterRef)
+	if err != nil {
+		return "", err
+	}
+	logger.Info("created cluster module", "moduleUUID", moduleUUID)
+	return moduleUUID, nil
+}
+
+func (s service) DoesExist(ctx *context.ClusterContext, wrapper Wrapper, moduleUUID string) (bool, error) {
+	logger := ctx.Logger.WithValues("object", wrapper.GetName(), "namespace", wrapper.GetNamespace())
+
+	template, err := fetchMachineTemplate(ctx, wrapper, logger)
+	if err != nil || template == nil {
+		return false, err
+	}
+
+	vCenterSession, err := fetchSession(ctx, template.Spec.Template.Spec.Datacenter)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get vCenter session for %s", ctx)
+	}
+
+	computeClusterRef, err := getComputeClusterResource(ctx, vCenterSession, template.Spec.Template.Spec.ResourcePool)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get compute cluster for object %s/%s", wrapper.GetNamespace(), wrapper.GetName())
+	}
+
+	provider := clustermodules.NewProvider(vCenterSession.TagManager.Client)
+	return provider.DoesModuleExist(ctx, moduleUUID, computeClusterRef)
+}
+
+func (s service) Remove(ctx *context.ClusterContext, moduleUUID string) error {
+	vCenterSession, err := fetchSession(ctx, "")
+	if err != nil {
+		return errors.Wrapf(err, "failed to get vCenter session for %s", ctx)
+	}
+
+	provider := clustermodules.NewProvider(vCenterSession.TagManager.Client)
+	return provider.DeleteModule(ctx, moduleUUID)
+}
+
+// fetchMachineTemplate returns the VSphereMachineTemplate used by the object,
+// or nil if the object uses another kind of infrastructure template.
+func fetchMachineTemplate(ctx *context.ClusterContext, wrapper Wrapper, logger logr.Logger) (*infrav1.VSphereMachineTemplate, error) {
+	if kind := wrapper.GetTemplateInfrastructureKind(); kind != vSphereMachineTemplateKind {
+		// Machines using other infrastructure templates are not managed by
+		// this provider and are not part of any cluster module.
+		logger.V(4).Info("skipping cluster module for object using another infrastructure template", "kind", kind)
+		return nil, nil
+	}
+
+	key := wrapper.GetTemplateInfrastructureRef()
+	template := &infrav1.VSphereMachineTemplate{}
+	if err := ctx.Client.Get(ctx, key, template); err != nil {
+		return nil, errors.Wrapf(err, "failed to get VSphereMachineTemplate %s", key)
+	}
+	return template, nil
+}
+
+func fetchSession(ctx *context.ClusterContext, datacenter string) (*session.Session, error) {
+	params := session.NewParams().
+		WithServer(ctx.VSphereCluster.Spec.Server).
+		WithDatacenter(datacenter).
+		WithThumbprint(ctx.VSphereCluster.Spec.Thumbprint).
+		WithFeatures(session.Feature{
+			EnableKeepAlive:       ctx.EnableKeepAlive,
+			KeepAliveDuration:     ctx.KeepAliveDuration,
+			MaxConcurrentRequests: ctx.MaxConcurrentVCenterRequests,
+		})
+
+	caBundle, err := identity.GetCABundle(ctx, ctx.Client, ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Spec.CABundleRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve the CA bundle of the vCenter")
+	}
+	params = params.WithCABundle(caBundle)
+
+	if ctx.VSphereCluster.Spec.IdentityRef != nil {
+		creds, err := identity.GetCredentials(ctx, ctx.Client, ctx.VSphereCluster, ctx.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		return session.GetOrCreate(ctx, params.WithUserInfo(creds.Username, creds.Password))
+	}
+	return session.GetOrCreate(ctx, params.WithUserInfo(ctx.Username, ctx.Password))
+}
+
+// getComputeClusterResource returns the compute cluster owning the resource pool.
+func getComputeClusterResource(ctx *context.ClusterContext, s *session.Session, resourcePool string) (types.ManagedObjectReference, error) {
+	rp, err := s.Finder.ResourcePoolOrDefault(ctx, resourcePool)
+	if err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "failed to find resource pool %q", resourcePool)
+	}
+
+	owner, err := rp.Owner(ctx)
+	if err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "failed to get owner of resource pool %q", resourcePool)
+	}
+
+	ownerPath, err := find.InventoryPath(ctx, s.Client.Client, owner.Reference())
+	if err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "failed to get inventory path of %s", owner.Reference())
+	}
+	if _, err := s.Finder.ClusterComputeResource(ctx, ownerPath); err != nil {
+		return types.ManagedObjectReference{}, errors.Errorf("owner %s of resource pool %q is not a compute cluster", owner.Reference().Value, resourcePool)
+	}
+	return owner.Reference(), nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clustermodule
+
+import (
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Wrapper is an object owning the Machines that share a cluster module.
+type Wrapper interface {
+	client.Object
+
+	// IsControlPlane reports whether the object is a control plane.
+	IsControlPlane() bool
+
+	// GetTemplateInfrastructureRef returns the infrastructure template used
+	// by the Machines owned by the object.
+	GetTemplateInfrastructureRef() client.ObjectKey
+	GetTemplateInfrastructureKind() string
+}
+
+// NewWrapper returns a Wrapper for a KubeadmControlPlane or a MachineDeployment.
+// It returns nil for any other type.
+func NewWrapper(obj client.Object) Wrapper {
+	switch o := obj.(type) {
+	case *controlplanev1.KubeadmControlPlane:
+		return kcpWrapper{o}
+	case *clusterv1.MachineDeployment:
+		return mdWrapper{o}
+	default:
+		return nil
+	}
+}
+
+type kcpWrapper struct {
+	*controlplanev1.KubeadmControlPlane
+}
+
+func (w kcpWrapper) IsControlPlane() bool {
+	return true
+}
+
+func (w kcpWrapper) GetTemplateInfrastructureRef() client.ObjectKey {
+	return client.ObjectKey{
+		Namespace: w.Namespace,
+		Name:      w.Spec.MachineTemplate.InfrastructureRef.Name,
+	}
+}
+
+func (w kcpWrapper) GetTemplateInfrastructureKind() string {
+	return w.Spec.MachineTemplate.InfrastructureRef.Kind
+}
+
+type mdWrapper struct {
+	*clusterv1.MachineDeployment
+}
+
+func (w mdWrapper) IsControlPlane() bool {
+	return false
+}
+
+func (w mdWrapper) GetTemplateInfrastructureRef() client.ObjectKey {
+	return client.ObjectKey{
+		Namespace: w.Namespace,
+		Name:      w.Spec.Template.Spec.InfrastructureRef.Name,
+	}
+}
+
+func (w mdWrapper) GetTemplateInfrastructureKind() string {
+	return w.Spec.Template.Spec.InfrastructureRef.Kind
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// VMHostAffinity is a VM-Host affinity rule, which pins the VMs of a VM group
+// to the hosts of a host group.
+type VMHostAffinity struct {
+	RuleName      string
+	VMGroupName   string
+	HostGroupName string
+}
+
+// ManagedVMHostAffinity returns the mandatory VM-Host affinity rule managed by
+// CAPV for a host group. The rule and its VM group share the same name.
+func ManagedVMHostAffinity(hostGroupName string) VMHostAffinity {
+	name := hostGroupName + "-capv"
+	return VMHostAffinity{
+		RuleName:      name,
+		VMGroupName:   name,
+		HostGroupName: hostGroupName,
+	}
+}
+
+// FindVMHostAffinity returns the VM-Host affinity rule with the given name in
+// a compute cluster, or nil when the cluster has no such rule.
+func FindVMHostAffinity(ctx context.Context, ccr *object.ClusterComputeResource, ruleName string) (*VMHostAffinity, error) {
+	clusterConfigInfoEx, err := ccr.Configuration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rule := findVMHostRule(clusterConfigInfoEx, ruleName)
+	if rule == nil {
+		return nil, nil
+	}
+	return &VMHostAffinity{
+		RuleName:      rule.Name,
+		VMGroupName:   rule.VmGroupName,
+		HostGroupName: rule.AffineHostGroupName,
+	}, nil
+}
+
+// affinityLocks serializes the edits of the groups and the rules of each
+// compute cluster, as vCenter replaces the whole VM list of a group on each
+// edit: the edits made concurrently from the same configuration would
+// otherwise drop each other's VMs.
+var affinityLocks sync.Map
+
+// maxAffinityAttempts is the number of times a VM is added to its VM group
+// when the edit is lost to a concurrent one made outside of CAPV.
+const maxAffinityAttempts = 3
+
+// AddVMToVMHostAffinity adds a VM to the VM group of a VM-Host affinity rule.
+// When create is true, the VM group and a mandatory rule are created if they
+// are missing, for a host group which must exist. The edit is serialized with
+// the other edits of the cluster and waited for, and is made again when the
+// VM is not a member of the VM group once it completes.
+func AddVMToVMHostAffinity(ctx context.Context, ccr *object.ClusterComputeResource, affinity VMHostAffinity, vmRef types.ManagedObjectReference, create bool) error {
+	lock, _ := affinityLocks.LoadOrStore(ccr.Client().URL().Host+"/"+ccr.Reference().Value, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	for attempt := 0; ; attempt++ {
+		clusterConfigInfoEx, err := ccr.Configuration(ctx)
+		if err != nil {
+			return err
+		}
+		spec, err := vmHostAffinitySpec(clusterConfigInfoEx, affinity, vmRef, create)
+		if err != nil {
+			return err
+		}
+		if spec == nil {
+			return nil
+		}
+		if attempt == maxAffinityAttempts {
+			return errors.Errorf("VM %s was removed from VM group %s by concurrent edits %d times", vmRef.Value, affinity.VMGroupName, maxAffinityAttempts)
+		}
+		task, err := ccr.Reconfigure(ctx, spec, true)
+		if err != nil {
+			return err
+		}
+		if err := task.Wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// vmHostAffinitySpec returns the spec adding a VM to the VM group of a
+// VM-Host affinity rule, creating the VM group and the rule when create is
+// true, or nil when the VM is already a member of the VM group of the rule.
+func vmHostAffinitySpec(clusterConfigInfoEx *types.ClusterConfigInfoEx, affinity VMHostAffinity, vmRef types.ManagedObjectReference, create bool) (*types.ClusterConfigSpecEx, error) {
+	spec := &types.ClusterConfigSpecEx{}
+	vmGroup := findVMGroup(clusterConfigInfoEx, affinity.VMGroupName)
+	switch {
+	case vmGroup == nil && !create:
+		return nil, errors.Errorf("cannot find VM group %s", affinity.VMGroupName)
+	case vmGroup == nil:
+		if !hasHostGroup(clusterConfigInfoEx, affinity.HostGroupName) {
+			return nil, errors.Errorf("cannot find host group %s", affinity.HostGroupName)
+		}
+		spec.GroupSpec = append(spec.GroupSpec, types.ClusterGroupSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+			Info: &types.ClusterVmGroup{
+				ClusterGroupInfo: types.ClusterGroupInfo{Name: affinity.VMGroupName},
+				Vm:               []types.ManagedObjectReference{vmRef},
+			},
+		})
+	case !containsVM(vmGroup.Vm, vmRef):
+		vmGroup.Vm = append(vmGroup.Vm, vmRef)
+		spec.GroupSpec = append(spec.GroupSpec, types.ClusterGroupSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationEdit},
+			Info:            vmGroup,
+		})
+	}
+
+	if create && findVMHostRule(clusterConfigInfoEx, affinity.RuleName) == nil {
+		enabled, mandatory := true, true
+		spec.RulesSpec = append(spec.RulesSpec, types.ClusterRuleSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+			Info: &types.ClusterVmHostRuleInfo{
+				ClusterRuleInfo: types.ClusterRuleInfo{
+					Name:      affinity.RuleName,
+					Enabled:   &enabled,
+					Mandatory: &mandatory,
+				},
+				VmGroupName:         affinity.VMGroupName,
+				AffineHostGroupName: affinity.HostGroupName,
+			},
+		})
+	}
+
+	if len(spec.GroupSpec) == 0 && len(spec.RulesSpec) == 0 {
+		return nil, nil
+	}
+	return spec, nil
+}
+
+func findVMHostRule(clusterConfigInfoEx *types.ClusterConfigInfoEx, ruleName string) *types.ClusterVmHostRuleInfo {
+	for _, rule := range clusterConfigInfoEx.Rule {
+		if vmHostRuleInfo, ok := rule.(*types.ClusterVmHostRuleInfo); ok && vmHostRuleInfo.Name == ruleName {
+			return vmHostRuleInfo
+		}
+	}
+	return nil
+}
+
+func findVMGroup(clusterConfigInfoEx *types.ClusterConfigInfoEx, vmGroupName string) *types.ClusterVmGroup {
+	for _, group := range clusterConfigInfoEx.Group {
+		if clusterVMGroup, ok := group.(*types.ClusterVmGroup); ok && clusterVMGroup.Name == vmGroupName {
+			return clusterVMGroup
+		}
+	}
+	return nil
+}
+
+func containsVM(vms []types.ManagedObjectReference, vmRef types.ManagedObjectReference) bool {
+	for _, vm := range vms {
+		if vm == vmRef {
+			return true
+		}
+	}
+	return false
+}
+
+func hasHostGroup(clusterConfigInfoEx *types.ClusterConfigInfoEx, hostGroupName string) bool {
+	for _, group := range clusterConfigInfoEx.Group {
+		if clusterHostGroup, ok := group.(*types.ClusterHostGroup); ok && clusterHostGroup.Name == hostGroupName {
+			return true
+		}
+	}
+	return false
+}
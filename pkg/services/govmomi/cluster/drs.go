@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ForVM returns the compute cluster of the resource pool of a VSphere VM
+// object, or nil when the VM runs on a standalone host.
+func ForVM(ctx context.Context, vm *object.VirtualMachine) (*object.ClusterComputeResource, error) {
+	pool, err := vm.ResourcePool(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the resource pool of VM %s", vm.Reference())
+	}
+	owner, err := pool.Owner(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the owner of resource pool %s", pool.Reference())
+	}
+	if owner.Reference().Type != "ClusterComputeResource" {
+		return nil, nil
+	}
+	return object.NewClusterComputeResource(vm.Client(), owner.Reference()), nil
+}
+
+// FindDRSVMConfig returns the DRS override of a VSphere VM object in the
+// compute cluster, or nil when the VM has none.
+func FindDRSVMConfig(ctx context.Context, ccr *object.ClusterComputeResource, vmRef types.ManagedObjectReference) (*types.ClusterDrsVmConfigInfo, error) {
+	clusterConfigInfoEx, err := ccr.Configuration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusterConfigInfoEx.DrsVmConfig {
+		if clusterConfigInfoEx.DrsVmConfig[i].Key == vmRef {
+			return &clusterConfigInfoEx.DrsVmConfig[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// SetDRSVMConfig adds the DRS override of a VSphere VM object to the compute
+// cluster, or edits it when the VM already has one.
+func SetDRSVMConfig(ctx context.Context, ccr *object.ClusterComputeResource, info types.ClusterDrsVmConfigInfo, exists bool) (*object.Task, error) {
+	operation := types.ArrayUpdateOperationAdd
+	if exists {
+		operation = types.ArrayUpdateOperationEdit
+	}
+	spec := &types.ClusterConfigSpecEx{
+		DrsVmConfigSpec: []types.ClusterDrsVmConfigSpec{
+			{
+				ArrayUpdateSpec: types.ArrayUpdateSpec{
+					Operation: operation,
+				},
+				Info: &info,
+			},
+		},
+	}
+	return ccr.Reconfigure(ctx, spec, true)
+}
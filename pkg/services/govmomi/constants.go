@@ -16,8 +16,18 @@ limitations under the License.
 
 package govmomi
 
+import "time"
+
 const (
 	morefTypeTask = "Task"
+
+	// cloneTaskDescriptionID is the description ID of the tasks cloning VMs.
+	cloneTaskDescriptionID = "VirtualMachine.clone"
+
+	// defaultGuestSoftPowerOffTimeout is the maximum duration of the shutdown
+	// of the guest OS of a VM whose power off mode is trySoft, unless its
+	// VSphereVM overrides it.
+	defaultGuestSoftPowerOffTimeout = 5 * time.Minute
 )
 
 // nolint
@@ -63,6 +63,13 @@ func TestCreate(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// Wait for the clone task, which would otherwise run against the
+	// simulator of the next test.
+	task := object.NewTask(authSession.Client.Client, types.ManagedObjectReference{Type: "Task", Value: vmContext.VSphereVM.Status.TaskRef})
+	if err := task.Wait(vmContext); err != nil {
+		t.Fatal(err)
+	}
+
 	if model.Machine+1 != model.Count().Machine {
 		t.Error("failed to clone vm")
 	}
@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+const (
+	// vmLogsConfigMapSuffix is appended to the name of a VSphereVM to name
+	// the ConfigMap holding the logs of its VM.
+	vmLogsConfigMapSuffix = "-logs"
+
+	// vmwareLogKey and serialLogKey are the keys of the ConfigMap holding
+	// the vmware.log file and the serial output of a VM.
+	vmwareLogKey = "vmware.log"
+	serialLogKey = "serial.log"
+
+	// maxLogBytes is the number of bytes kept from the end of each log, so
+	// that both logs fit in a ConfigMap.
+	maxLogBytes = 256 * 1024
+)
+
+// reconcileDiagnostics handles the diagnostics requested with the annotations
+// of the VSphereVM: a ticket for the HTML5 console of the VM, and the
+// collection of its logs into a ConfigMap. The annotation of a request is
+// removed once it is handled, whether it succeeded or not, so a failed
+// request is only retried when the annotation is set again.
+func (vms *VMService) reconcileDiagnostics(ctx *virtualMachineContext) {
+	annotations := ctx.VSphereVM.GetAnnotations()
+	if _, ok := annotations[infrav1.VMConsoleRequestAnnotation]; ok {
+		if err := vms.reconcileConsole(ctx); err != nil {
+			ctx.Logger.Error(err, "failed to acquire a console ticket for the VM")
+			ctx.Recorder.Warnf(ctx.VSphereVM, "ConsoleRequestFailed", "failed to acquire a console ticket: %v", err)
+		}
+		delete(annotations, infrav1.VMConsoleRequestAnnotation)
+	}
+	if _, ok := annotations[infrav1.VMLogsRequestAnnotation]; ok {
+		if err := vms.reconcileLogs(ctx); err != nil {
+			ctx.Logger.Error(err, "failed to collect the logs of the VM")
+			ctx.Recorder.Warnf(ctx.VSphereVM, "LogsRequestFailed", "failed to collect the logs: %v", err)
+		}
+		delete(annotations, infrav1.VMLogsRequestAnnotation)
+	}
+	ctx.VSphereVM.SetAnnotations(annotations)
+}
+
+// reconcileConsole acquires a WebMKS ticket for the console of the VM and
+// reports its URL in the status of the VSphereVM.
+func (vms *VMService) reconcileConsole(ctx *virtualMachineContext) error {
+	ticket, err := ctx.Obj.AcquireTicket(ctx, string(types.VirtualMachineTicketTypeWebmks))
+	if err != nil {
+		return errors.Wrapf(err, "unable to acquire a webmks ticket for %q", ctx)
+	}
+	ctx.VSphereVM.Status.Console = &infrav1.VirtualMachineConsoleStatus{
+		URL:           consoleURL(ticket, ctx.Session.Client.URL().Hostname()),
+		SSLThumbprint: ticket.SslThumbprint,
+		AcquiredTime:  metav1.Now(),
+	}
+	ctx.Recorder.Eventf(ctx.VSphereVM, "ConsoleTicketAcquired", "acquired a console ticket for the VM")
+	return nil
+}
+
+// consoleURL returns the WebSocket URL of the console of a WebMKS ticket. The
+// console is served by vCenter when the ticket does not name a host.
+func consoleURL(ticket *types.VirtualMachineTicket, server string) string {
+	host, port := ticket.Host, ticket.Port
+	if host == "" {
+		host = server
+	}
+	if port == 0 {
+		port = 443
+	}
+	return fmt.Sprintf("wss://%s:%d/ticket/%s", host, port, ticket.Ticket)
+}
+
+// reconcileLogs copies the end of the vmware.log file and of the serial
+// output of the VM into a ConfigMap owned by the VSphereVM.
+func (vms *VMService) reconcileLogs(ctx *virtualMachineContext) error {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.files", "config.hardware.device"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to fetch the files of %q", ctx)
+	}
+	if obj.Config == nil {
+		return errors.Errorf("unable to find the files of %q", ctx)
+	}
+
+	data := map[string]string{}
+	logDirectory := obj.Config.Files.LogDirectory
+	if logDirectory == "" {
+		var vmx object.DatastorePath
+		if vmx.FromString(obj.Config.Files.VmPathName) {
+			logDirectory = (&object.DatastorePath{Datastore: vmx.Datastore, Path: path.Dir(vmx.Path)}).String()
+		}
+	}
+	var logDir object.DatastorePath
+	if !logDir.FromString(logDirectory) {
+		return errors.Errorf("unable to find the log directory of %q", ctx)
+	}
+	vmwareLog, err := downloadLogTail(ctx, object.DatastorePath{Datastore: logDir.Datastore, Path: path.Join(logDir.Path, vmwareLogKey)})
+	if err != nil {
+		return err
+	}
+	data[vmwareLogKey] = vmwareLog
+
+	if serialPath, ok := serialPortFile(object.VirtualDeviceList(obj.Config.Hardware.Device)); ok {
+		serialLog, err := downloadLogTail(ctx, serialPath)
+		if err != nil {
+			return err
+		}
+		data[serialLogKey] = serialLog
+	}
+
+	now := metav1.Now()
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ctx.VSphereVM.Namespace,
+			Name:      ctx.VSphereVM.Name + vmLogsConfigMapSuffix,
+		},
+	}
+	if _, err := controllerutil.CreateOrPatch(ctx, ctx.Client, configMap, func() error {
+		configMap.Data = data
+		return controllerutil.SetControllerReference(ctx.VSphereVM, configMap, ctx.Scheme)
+	}); err != nil {
+		return errors.Wrapf(err, "unable to write the logs of %q to ConfigMap %s", ctx, client.ObjectKeyFromObject(configMap))
+	}
+	ctx.VSphereVM.Status.Logs = &infrav1.VirtualMachineLogsStatus{
+		ConfigMapName: configMap.Name,
+		CollectedTime: now,
+	}
+	ctx.Recorder.Eventf(ctx.VSphereVM, "LogsCollected", "collected the logs of the VM into ConfigMap %s", configMap.Name)
+	return nil
+}
+
+// serialPortFile returns the datastore path of the file backing the first
+// serial port of a VM which writes to a file.
+func serialPortFile(devices object.VirtualDeviceList) (object.DatastorePath, bool) {
+	for _, device := range devices.SelectByType((*types.VirtualSerialPort)(nil)) {
+		backing, ok := device.GetVirtualDevice().Backing.(*types.VirtualSerialPortFileBackingInfo)
+		if !ok {
+			continue
+		}
+		var p object.DatastorePath
+		if p.FromString(backing.FileName) {
+			return p, true
+		}
+	}
+	return object.DatastorePath{}, false
+}
+
+// downloadLogTail returns the last maxLogBytes of a log file of a VM. A log
+// which does not exist yet is reported as empty.
+func downloadLogTail(ctx *virtualMachineContext, p object.DatastorePath) (string, error) {
+	datastore, err := ctx.Session.Finder.Datastore(ctx, p.Datastore)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to find datastore %q of the logs of %q", p.Datastore, ctx)
+	}
+	param := soap.DefaultDownload
+	param.Headers = map[string]string{"Range": fmt.Sprintf("bytes=-%d", maxLogBytes)}
+	res, err := ctx.Session.Client.DownloadRequest(ctx, datastore.NewURL(p.Path), &param)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to download %s", p.String())
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", errors.Errorf("unable to download %s: %s", p.String(), res.Status)
+	}
+
+	// The range is ignored by the servers which do not support it, so only
+	// the end of the file is kept.
+	log, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to download %s", p.String())
+	}
+	if len(log) > maxLogBytes {
+		log = log[len(log)-maxLogBytes:]
+	}
+	return string(log), nil
+}
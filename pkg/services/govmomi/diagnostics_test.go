@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestConsoleURL(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(consoleURL(&types.VirtualMachineTicket{Ticket: "abc", Host: "esx-0", Port: 902}, "vcenter")).To(Equal("wss://esx-0:902/ticket/abc"))
+	g.Expect(consoleURL(&types.VirtualMachineTicket{Ticket: "abc"}, "vcenter")).To(Equal("wss://vcenter:443/ticket/abc"))
+}
+
+func TestReconcileDiagnostics(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	g := NewWithT(t)
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+
+	// Write a vmware.log longer than the collected tail in the directory of
+	// the VM.
+	var vm mo.VirtualMachine
+	g.Expect(obj.Properties(ctx, obj.Reference(), []string{"config.files.vmPathName"}, &vm)).To(Succeed())
+	var vmx object.DatastorePath
+	g.Expect(vmx.FromString(vm.Config.Files.VmPathName)).To(BeTrue())
+	datastore, err := authSession.Finder.Datastore(ctx, vmx.Datastore)
+	g.Expect(err).NotTo(HaveOccurred())
+	log := strings.Repeat("-", maxLogBytes) + "cloud-init failed"
+	g.Expect(datastore.Upload(ctx, strings.NewReader(log), path.Join(path.Dir(vmx.Path), vmwareLogKey), &soap.DefaultUpload)).To(Succeed())
+
+	t.Run("collects the logs of the VM into a ConfigMap", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx.VSphereVM.Annotations = map[string]string{infrav1.VMLogsRequestAnnotation: ""}
+
+		(&VMService{}).reconcileDiagnostics(ctx)
+		g.Expect(ctx.VSphereVM.Annotations).NotTo(HaveKey(infrav1.VMLogsRequestAnnotation))
+		g.Expect(ctx.VSphereVM.Status.Logs).NotTo(BeNil())
+		g.Expect(ctx.VSphereVM.Status.Logs.ConfigMapName).To(Equal(ctx.VSphereVM.Name + vmLogsConfigMapSuffix))
+
+		configMap := &corev1.ConfigMap{}
+		g.Expect(ctx.Client.Get(ctx, client.ObjectKey{Namespace: ctx.VSphereVM.Namespace, Name: ctx.VSphereVM.Status.Logs.ConfigMapName}, configMap)).To(Succeed())
+		g.Expect(configMap.Data[vmwareLogKey]).To(HaveLen(maxLogBytes))
+		g.Expect(configMap.Data[vmwareLogKey]).To(HaveSuffix("cloud-init failed"))
+		g.Expect(configMap.Data).NotTo(HaveKey(serialLogKey))
+		g.Expect(configMap.OwnerReferences).To(HaveLen(1))
+		g.Expect(configMap.OwnerReferences[0].Name).To(Equal(ctx.VSphereVM.Name))
+	})
+
+	t.Run("removes the console request when the ticket cannot be acquired", func(t *testing.T) {
+		g := NewWithT(t)
+		// The simulator does not implement the acquisition of tickets.
+		ctx.VSphereVM.Annotations = map[string]string{infrav1.VMConsoleRequestAnnotation: ""}
+
+		(&VMService{}).reconcileDiagnostics(ctx)
+		g.Expect(ctx.VSphereVM.Annotations).NotTo(HaveKey(infrav1.VMConsoleRequestAnnotation))
+		g.Expect(ctx.VSphereVM.Status.Console).To(BeNil())
+	})
+}
+
+func TestSerialPortFile(t *testing.T) {
+	g := NewWithT(t)
+	devices := object.VirtualDeviceList{
+		&types.VirtualSerialPort{VirtualDevice: types.VirtualDevice{Backing: &types.VirtualSerialPortURIBackingInfo{}}},
+		&types.VirtualSerialPort{VirtualDevice: types.VirtualDevice{Backing: &types.VirtualSerialPortFileBackingInfo{
+			VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{FileName: "[ds0] vm/serial.log"},
+		}}},
+	}
+
+	p, ok := serialPortFile(devices)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(p).To(Equal(object.DatastorePath{Datastore: "ds0", Path: "vm/serial.log"}))
+
+	_, ok = serialPortFile(devices[:1])
+	g.Expect(ok).To(BeFalse())
+}
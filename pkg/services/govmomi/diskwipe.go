@@ -0,0 +1,247 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// reconcileDiskWipe wipes the disks of the VM, according to the disk wipe
+// policy of the VSphereVM, once the VM is powered off and before it is
+// destroyed. The disks are zeroed one at a time, each with a task tracked
+// like the other tasks of the VM, and the disks wiped are recorded in the
+// status of the VSphereVM, so the wipe resumes where it stopped. The wipe of
+// a disk which failed is retried. It returns true once every disk is wiped.
+func (vms *VMService) reconcileDiskWipe(ctx *virtualMachineContext) (bool, error) {
+	policy := ctx.VSphereVM.Spec.DiskWipePolicy
+	if policy == "" || policy == infrav1.NoneDiskWipePolicy {
+		return true, nil
+	}
+	status := ctx.VSphereVM.Status.DiskWipe
+	if status == nil {
+		status = &infrav1.VirtualMachineDiskWipeStatus{}
+		ctx.VSphereVM.Status.DiskWipe = status
+	}
+	if status.CompletedTime != nil {
+		return true, nil
+	}
+
+	// The in-flight task of the VM is awaited before, so the task of the
+	// disk being wiped, if any, is done.
+	if status.Disk != "" {
+		if err := diskWipeTaskError(ctx, status.TaskRef); err != nil {
+			ctx.Logger.Error(err, "failed to wipe disk", "disk", status.Disk)
+			ctx.Recorder.Warnf(ctx.VSphereVM, "DiskWipeFailed", "failed to wipe disk %s: %v", status.Disk, err)
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.DisksWipedCondition, infrav1.DiskWipeFailedReason, clusterv1.ConditionSeverityWarning,
+				"failed to wipe disk %s: %v", status.Disk, err)
+		} else {
+			status.WipedDisks = append(status.WipedDisks, status.Disk)
+		}
+		status.Disk, status.TaskRef = "", ""
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.hardware.device", "config.files.vmPathName", "config.keyId"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "failed to get the disks of vm %s", ctx)
+	}
+	var devices object.VirtualDeviceList
+	var vmPathName string
+	var vmKey *types.CryptoKeyId
+	if obj.Config != nil {
+		devices, vmPathName, vmKey = obj.Config.Hardware.Device, obj.Config.Files.VmPathName, obj.Config.KeyId
+	}
+	erasedKey := cryptoErasedKey(ctx, policy, vmKey)
+	disks := disksToWipe(devices, vmPathName, erasedKey, status.WipedDisks)
+	if len(disks) == 0 {
+		if erasedKey != nil {
+			if err := removeKey(ctx, *erasedKey); err != nil {
+				return false, err
+			}
+			ctx.Logger.Info("encryption key removed", "keyID", erasedKey.KeyId)
+		}
+		ctx.Logger.Info("disks wiped", "disks", len(status.WipedDisks))
+		completedTime := metav1.Now()
+		status.CompletedTime = &completedTime
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.DisksWipedCondition)
+		return true, nil
+	}
+
+	datacenter, err := ctx.Session.Finder.DatacenterOrDefault(ctx, ctx.VSphereVM.Spec.Datacenter)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find the datacenter of vm %s", ctx)
+	}
+	dcRef := datacenter.Reference()
+	res, err := methods.ZeroFillVirtualDisk_Task(ctx, ctx.Session.Client.Client, &types.ZeroFillVirtualDisk_Task{
+		This:       *ctx.Session.Client.ServiceContent.VirtualDiskManager,
+		Name:       disks[0],
+		Datacenter: &dcRef,
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to trigger the wipe of disk %s of vm %s", disks[0], ctx)
+	}
+
+	ctx.Logger.Info("wiping disk", "disk", disks[0])
+	status.Disk, status.TaskRef = disks[0], res.Returnval.Value
+	ctx.VSphereVM.Status.TaskRef = res.Returnval.Value
+	if conditions.GetReason(ctx.VSphereVM, infrav1.DisksWipedCondition) != infrav1.DiskWipeFailedReason {
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.DisksWipedCondition, infrav1.DiskWipeInProgressReason, clusterv1.ConditionSeverityInfo,
+			"wiping disk %s (%d of %d)", disks[0], len(status.WipedDisks)+1, len(status.WipedDisks)+len(disks))
+	}
+	return false, nil
+}
+
+// diskWipeTaskError returns the error of the task which wiped a disk, or an
+// error when the result of the task is unknown.
+func diskWipeTaskError(ctx *virtualMachineContext, taskRef string) error {
+	var task mo.Task
+	ref := types.ManagedObjectReference{Type: "Task", Value: taskRef}
+	if err := property.DefaultCollector(ctx.Session.Client.Client).RetrieveOne(ctx, ref, []string{"info"}, &task); err != nil {
+		return errors.Wrapf(err, "failed to get the result of task %s", taskRef)
+	}
+	if task.Info.State != types.TaskInfoStateSuccess {
+		return errors.Errorf("task %s is %s: %s", taskRef, task.Info.State, taskError(&task))
+	}
+	return nil
+}
+
+// cryptoErasedKey returns the key of the VM removed from its key provider by
+// the cryptoErase policy, which erases the data of the disks encrypted with
+// it. Only the key generated by CAPV for the VM, recorded on the VSphereVM,
+// is removed: the other keys, e.g. the key of an encrypted template shared by
+// its clones, may be used by other VMs.
+func cryptoErasedKey(ctx *virtualMachineContext, policy infrav1.DiskWipePolicy, vmKey *types.CryptoKeyId) *types.CryptoKeyId {
+	if policy != infrav1.CryptoEraseDiskWipePolicy || vmKey == nil {
+		return nil
+	}
+	if id := ctx.VSphereVM.Annotations[infrav1.VMEncryptionKeyAnnotation]; id == "" || id != vmKey.KeyId {
+		return nil
+	}
+	return vmKey
+}
+
+// removeKey removes the key of the VM from its key provider. The key is
+// removed while the VM still exists, so it is forced.
+func removeKey(ctx *virtualMachineContext, key types.CryptoKeyId) error {
+	cryptoManager := ctx.Session.Client.ServiceContent.CryptoManager
+	if cryptoManager == nil {
+		return errors.Errorf("vCenter has no key provider to remove key %s of vm %s", key.KeyId, ctx)
+	}
+	if _, err := methods.RemoveKey(ctx, ctx.Session.Client.Client, &types.RemoveKey{
+		This:  *cryptoManager,
+		Key:   key,
+		Force: true,
+	}); err != nil {
+		return errors.Wrapf(err, "failed to remove key %s of vm %s", key.KeyId, ctx)
+	}
+	return nil
+}
+
+// disksToWipe returns the file names of the disks of a VM which are wiped,
+// except for the disks already wiped: the files of the backing chains of its
+// disks, i.e. the delta disks of its snapshots and their parents, which are
+// stored in the directory of the VM. The parents of the disks of a linked
+// clone are the disks of its template, stored in the directory of the
+// template, and are never wiped. The disks encrypted with the erased key of
+// the cryptoErase policy are not wiped either.
+//
+// The disks which are not backed by a virtual disk file, e.g. raw device
+// mappings, the first class disks, e.g. the persistent volumes of Cloud
+// Native Storage attached to the VM, and the independent disks, which are
+// not owned by the VM, are never wiped.
+func disksToWipe(devices object.VirtualDeviceList, vmPathName string, erasedKey *types.CryptoKeyId, wiped []string) []string {
+	done := make(map[string]bool, len(wiped))
+	for _, fileName := range wiped {
+		done[fileName] = true
+	}
+	vmDir := datastorePathDir(vmPathName)
+
+	var disks []string
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		disk := device.(*types.VirtualDisk) //nolint:forcetypeassert
+		if disk.VDiskId != nil {
+			continue
+		}
+		for _, file := range diskBackingChain(disk.Backing) {
+			if strings.HasPrefix(file.diskMode, "independent") {
+				break
+			}
+			if file.fileName == "" || done[file.fileName] || datastorePathDir(file.fileName) != vmDir {
+				continue
+			}
+			if erasedKey != nil && file.keyID != nil && file.keyID.KeyId == erasedKey.KeyId {
+				continue
+			}
+			done[file.fileName] = true
+			disks = append(disks, file.fileName)
+		}
+	}
+	return disks
+}
+
+// diskBackingFile is a file of the backing chain of a disk.
+type diskBackingFile struct {
+	fileName string
+	diskMode string
+	keyID    *types.CryptoKeyId
+}
+
+// diskBackingChain returns the files of the backing chain of a disk, from the
+// file written by the VM to the base disk. It returns none for the backings
+// which are not virtual disk files.
+func diskBackingChain(backing types.BaseVirtualDeviceBackingInfo) []diskBackingFile {
+	var chain []diskBackingFile
+	switch backing := backing.(type) {
+	case *types.VirtualDiskFlatVer2BackingInfo:
+		for ; backing != nil; backing = backing.Parent {
+			chain = append(chain, diskBackingFile{fileName: backing.FileName, diskMode: backing.DiskMode, keyID: backing.KeyId})
+		}
+	case *types.VirtualDiskSeSparseBackingInfo:
+		for ; backing != nil; backing = backing.Parent {
+			chain = append(chain, diskBackingFile{fileName: backing.FileName, diskMode: backing.DiskMode, keyID: backing.KeyId})
+		}
+	case *types.VirtualDiskSparseVer2BackingInfo:
+		for ; backing != nil; backing = backing.Parent {
+			chain = append(chain, diskBackingFile{fileName: backing.FileName, diskMode: backing.DiskMode, keyID: backing.KeyId})
+		}
+	}
+	return chain
+}
+
+// datastorePathDir returns the directory of a datastore path, without the
+// datastore, e.g. "vm" for "[ds] vm/vm.vmdk". The disks of a VM on other
+// datastores than the datastore of the VM are stored in a directory of the
+// same name.
+func datastorePathDir(datastorePath string) string {
+	var p object.DatastorePath
+	if !p.FromString(datastorePath) {
+		return ""
+	}
+	return path.Dir(p.Path)
+}
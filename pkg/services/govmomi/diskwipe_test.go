@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestReconcileDiskWipe(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	newContext := func(g *WithT, policy infrav1.DiskWipePolicy) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+		vmContext.VSphereVM.Spec.DiskWipePolicy = policy
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+		g.Expect(err).NotTo(HaveOccurred())
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+	vmDisks := func(g *WithT, ctx *virtualMachineContext) []string {
+		var obj mo.VirtualMachine
+		g.Expect(ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.hardware.device", "config.files.vmPathName"}, &obj)).To(Succeed())
+		disks := disksToWipe(obj.Config.Hardware.Device, obj.Config.Files.VmPathName, nil, nil)
+		g.Expect(disks).NotTo(BeEmpty())
+		return disks
+	}
+
+	t.Run("does not wipe the disks without a policy", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.NoneDiskWipePolicy)
+
+		g.Expect((&VMService{}).reconcileDiskWipe(ctx)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.DiskWipe).To(BeNil())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+	})
+
+	t.Run("completes once every disk is wiped", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.ZeroDiskWipePolicy)
+		ctx.VSphereVM.Status.DiskWipe = &infrav1.VirtualMachineDiskWipeStatus{WipedDisks: vmDisks(g, ctx)}
+
+		g.Expect((&VMService{}).reconcileDiskWipe(ctx)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.DiskWipe.CompletedTime).NotTo(BeNil())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.DisksWipedCondition)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+	})
+
+	t.Run("retries the disk whose task is unknown", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.ZeroDiskWipePolicy)
+		disks := vmDisks(g, ctx)
+		ctx.VSphereVM.Status.DiskWipe = &infrav1.VirtualMachineDiskWipeStatus{Disk: disks[0], TaskRef: "task-unknown"}
+
+		// The simulator does not implement the zero fill of virtual disks.
+		_, err := (&VMService{}).reconcileDiskWipe(ctx)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(ctx.VSphereVM.Status.DiskWipe.WipedDisks).To(BeEmpty())
+		g.Expect(ctx.VSphereVM.Status.DiskWipe.Disk).To(BeEmpty())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.DisksWipedCondition)).To(Equal(infrav1.DiskWipeFailedReason))
+	})
+}
+
+func TestDisksToWipe(t *testing.T) {
+	vmKey := &types.CryptoKeyId{KeyId: "vm-key"}
+	disk := func(key int32, backing types.BaseVirtualDeviceBackingInfo) *types.VirtualDisk {
+		return &types.VirtualDisk{VirtualDevice: types.VirtualDevice{Key: key, Backing: backing}}
+	}
+	flat := func(fileName string, keyID *types.CryptoKeyId, parent *types.VirtualDiskFlatVer2BackingInfo) *types.VirtualDiskFlatVer2BackingInfo {
+		return &types.VirtualDiskFlatVer2BackingInfo{
+			VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{FileName: fileName},
+			DiskMode:                     string(types.VirtualDiskModePersistent),
+			KeyId:                        keyID,
+			Parent:                       parent,
+		}
+	}
+	independent := flat("[ds] vm/vm_4.vmdk", nil, nil)
+	independent.DiskMode = string(types.VirtualDiskModeIndependent_persistent)
+	fcd := disk(2005, flat("[ds] fcd/pv.vmdk", nil, nil))
+	fcd.VDiskId = &types.ID{Id: "pv"}
+	devices := object.VirtualDeviceList{
+		// A disk with a snapshot, whose base disk stays in the chain.
+		disk(2000, flat("[ds] vm/vm-000001.vmdk", nil, flat("[ds] vm/vm.vmdk", nil, nil))),
+		disk(2001, flat("[ds] vm/vm_1.vmdk", vmKey, nil)),
+		// A disk of a linked clone, whose parent is a disk of its template.
+		disk(2002, flat("[ds] vm/vm_2-000001.vmdk", nil, flat("[ds] template/template.vmdk", nil, nil))),
+		disk(2003, &types.VirtualDiskRawDiskMappingVer1BackingInfo{
+			VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{FileName: "[ds] vm/vm_3.vmdk"},
+		}),
+		disk(2004, independent),
+		fcd,
+		// A data disk on another datastore.
+		disk(2006, flat("[ds2] vm/vm_5.vmdk", &types.CryptoKeyId{KeyId: "template-key"}, nil)),
+		&types.VirtualCdrom{VirtualDevice: types.VirtualDevice{Key: 3000}},
+	}
+
+	tests := []struct {
+		name      string
+		erasedKey *types.CryptoKeyId
+		wiped     []string
+		want      []string
+	}{
+		{
+			name: "wipes the backing chains of the virtual disks in the directory of the VM",
+			want: []string{"[ds] vm/vm-000001.vmdk", "[ds] vm/vm.vmdk", "[ds] vm/vm_1.vmdk", "[ds] vm/vm_2-000001.vmdk", "[ds2] vm/vm_5.vmdk"},
+		},
+		{
+			name:      "does not wipe the disks encrypted with the erased key",
+			erasedKey: vmKey,
+			want:      []string{"[ds] vm/vm-000001.vmdk", "[ds] vm/vm.vmdk", "[ds] vm/vm_2-000001.vmdk", "[ds2] vm/vm_5.vmdk"},
+		},
+		{
+			name:  "the disks already wiped are skipped",
+			wiped: []string{"[ds] vm/vm-000001.vmdk", "[ds] vm/vm.vmdk", "[ds2] vm/vm_5.vmdk"},
+			want:  []string{"[ds] vm/vm_1.vmdk", "[ds] vm/vm_2-000001.vmdk"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(disksToWipe(devices, "[ds] vm/vm.vmx", tt.erasedKey, tt.wiped)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestCryptoErasedKey(t *testing.T) {
+	g := NewWithT(t)
+
+	ctx := &virtualMachineContext{VMContext: *fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))}
+	vmKey := &types.CryptoKeyId{KeyId: "vm-key", ProviderId: &types.KeyProviderId{Id: "kms"}}
+
+	// The key of the VM is not known to be its own.
+	g.Expect(cryptoErasedKey(ctx, infrav1.CryptoEraseDiskWipePolicy, vmKey)).To(BeNil())
+
+	ctx.VSphereVM.Annotations = map[string]string{infrav1.VMEncryptionKeyAnnotation: "vm-key"}
+	g.Expect(cryptoErasedKey(ctx, infrav1.CryptoEraseDiskWipePolicy, vmKey)).To(Equal(vmKey))
+	g.Expect(cryptoErasedKey(ctx, infrav1.ZeroDiskWipePolicy, vmKey)).To(BeNil())
+	g.Expect(cryptoErasedKey(ctx, infrav1.CryptoEraseDiskWipePolicy, nil)).To(BeNil())
+	// The VM is encrypted with another key, e.g. the key of its template.
+	g.Expect(cryptoErasedKey(ctx, infrav1.CryptoEraseDiskWipePolicy, &types.CryptoKeyId{KeyId: "template-key"})).To(BeNil())
+}
@@ -0,0 +1,434 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+)
+
+var (
+	// driftDetected counts the properties of the VMs found to differ from
+	// their VSphereVM, once per drift.
+	driftDetected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capv_vm_drift_detected_total",
+			Help: "Total number of drifts of a property of the configuration of a VM from its VSphereVM, by property.",
+		},
+		[]string{"property"},
+	)
+
+	// driftCorrected counts the corrections of the drifted properties of the
+	// VMs.
+	driftCorrected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capv_vm_drift_corrected_total",
+			Help: "Total number of corrections of a drifted property of the configuration of a VM, by property.",
+		},
+		[]string{"property"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(driftDetected, driftCorrected)
+}
+
+// reconcileDrift compares the configuration of a ready VM with its VSphereVM
+// once per drift check interval of the manager, and reports the properties
+// which differ in the drift status and the ConfigurationInSync condition of
+// the VSphereVM. When the manager corrects the drift, the folder, the resource
+// pool and the custom VMX keys of the VM are restored one at a time, and the
+// drift is checked again on the next reconciliation. It returns false while a
+// correction is in progress.
+func (vms *VMService) reconcileDrift(ctx *virtualMachineContext) (bool, error) {
+	if ctx.DriftCheckInterval <= 0 || !ctx.VSphereVM.Status.Ready || !driftCheckDue(ctx, time.Now()) {
+		return true, nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"resourcePool", "config.hardware.device", "config.extraConfig"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "failed to get the configuration of vm %s", ctx)
+	}
+	var drifted []infrav1.VirtualMachineDrift
+	for _, check := range []func(*virtualMachineContext, *mo.VirtualMachine) (*infrav1.VirtualMachineDrift, error){
+		networkDrift, diskDrift, extraConfigDrift, folderDrift, resourcePoolDrift,
+	} {
+		drift, err := check(ctx, &obj)
+		if err != nil {
+			return false, err
+		}
+		if drift != nil {
+			drifted = append(drifted, *drift)
+		}
+	}
+
+	var previous []infrav1.VirtualMachineDrift
+	if ctx.VSphereVM.Status.Drift != nil {
+		previous = ctx.VSphereVM.Status.Drift.Drifted
+	}
+	for _, drift := range drifted {
+		if findDrift(previous, drift.Property) == nil {
+			ctx.Logger.Info("VM configuration drifted", "property", drift.Property, "desired", drift.Desired, "actual", drift.Actual)
+			driftDetected.WithLabelValues(string(drift.Property)).Inc()
+		}
+	}
+	ctx.VSphereVM.Status.Drift = &infrav1.VirtualMachineDriftStatus{
+		Drifted:       drifted,
+		LastCheckTime: metav1.Now(),
+	}
+	if len(drifted) == 0 {
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.ConfigurationInSyncCondition)
+		return true, nil
+	}
+	conditions.MarkFalse(ctx.VSphereVM, infrav1.ConfigurationInSyncCondition, infrav1.ConfigurationDriftedReason, clusterv1.ConditionSeverityWarning,
+		"%s", driftMessage(drifted))
+
+	if !ctx.AutoCorrectDrift {
+		return true, nil
+	}
+	return vms.correctDrift(ctx, &obj, drifted)
+}
+
+// driftCheckDue returns true when the drift of the VM was not checked within
+// the drift check interval, or when its last check found a drift which the
+// manager corrects, unless its correction was blocked.
+func driftCheckDue(ctx *virtualMachineContext, now time.Time) bool {
+	drift := ctx.VSphereVM.Status.Drift
+	if drift == nil {
+		return true
+	}
+	if ctx.AutoCorrectDrift && conditions.GetReason(ctx.VSphereVM, infrav1.ConfigurationInSyncCondition) != infrav1.DriftCorrectionBlockedReason {
+		for _, d := range drift.Drifted {
+			if isCorrectableDrift(d.Property) {
+				return true
+			}
+		}
+	}
+	return !now.Before(drift.LastCheckTime.Add(ctx.DriftCheckInterval))
+}
+
+// isCorrectableDrift returns true for the properties whose drift is reversible
+// without disrupting the guest of the VM.
+func isCorrectableDrift(property infrav1.VirtualMachineDriftProperty) bool {
+	switch property {
+	case infrav1.FolderDriftProperty, infrav1.ResourcePoolDriftProperty, infrav1.ExtraConfigDriftProperty:
+		return true
+	}
+	return false
+}
+
+// correctDrift starts the correction of the first drifted property which is
+// correctable. It returns false once a correction is started, or while it
+// waits for a slot of the vCenter. A drift whose correction is blocked is
+// reported, and is only corrected after the next drift check.
+func (vms *VMService) correctDrift(ctx *virtualMachineContext, obj *mo.VirtualMachine, drifted []infrav1.VirtualMachineDrift) (bool, error) {
+	var (
+		property infrav1.VirtualMachineDriftProperty
+		message  string
+		task     *object.Task
+	)
+	// The snapshots of the VM block its migration back into its resource
+	// pool, but not the correction of the other properties.
+	poolBlocked := blockedBySnapshots(ctx)
+	switch {
+	case findDrift(drifted, infrav1.FolderDriftProperty) != nil:
+		folder, err := ctx.Session.Finder.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to find folder %q of vm %s", ctx.VSphereVM.Spec.Folder, ctx)
+		}
+		if !acquireTaskSlot(&ctx.VMContext, throttle.Reconfigure) {
+			return false, nil
+		}
+		property = infrav1.FolderDriftProperty
+		message = fmt.Sprintf("Moving VM %s back into folder %s", ctx.VSphereVM.Name, folder.InventoryPath)
+		if task, err = folder.MoveInto(ctx, []types.ManagedObjectReference{ctx.Ref}); err != nil {
+			return false, errors.Wrapf(err, "failed to move vm %s into folder %s", ctx, folder.InventoryPath)
+		}
+
+	case findDrift(drifted, infrav1.ResourcePoolDriftProperty) != nil && poolBlocked == nil:
+		pool, err := ctx.Session.Finder.ResourcePoolOrDefault(ctx, ctx.VSphereVM.Spec.ResourcePool)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to find resource pool %q of vm %s", ctx.VSphereVM.Spec.ResourcePool, ctx)
+		}
+		if !acquireTaskSlot(&ctx.VMContext, throttle.Reconfigure) {
+			return false, nil
+		}
+		poolRef := pool.Reference()
+		property = infrav1.ResourcePoolDriftProperty
+		message = fmt.Sprintf("Moving VM %s back into resource pool %s", ctx.VSphereVM.Name, pool.InventoryPath)
+		if task, err = ctx.Obj.Relocate(ctx, types.VirtualMachineRelocateSpec{Pool: &poolRef}, types.VirtualMachineMovePriorityDefaultPriority); err != nil {
+			return false, errors.Wrapf(err, "failed to move vm %s into resource pool %s", ctx, pool.InventoryPath)
+		}
+
+	case findDrift(drifted, infrav1.ExtraConfigDriftProperty) != nil:
+		changed, err := changedCustomVMXKeys(ctx, obj)
+		if err != nil {
+			return false, err
+		}
+		if !acquireTaskSlot(&ctx.VMContext, throttle.Reconfigure) {
+			return false, nil
+		}
+		property = infrav1.ExtraConfigDriftProperty
+		message = fmt.Sprintf("Restoring %d custom VMX keys of VM %s", len(changed), ctx.VSphereVM.Name)
+		if task, err = ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{ExtraConfig: changed}); err != nil {
+			return false, errors.Wrapf(err, "failed to restore the custom VMX keys of vm %s", ctx)
+		}
+
+	default:
+		if findDrift(drifted, infrav1.ResourcePoolDriftProperty) != nil && poolBlocked != nil {
+			ctx.Logger.Info("not moving VM back into its resource pool", "reason", poolBlocked.Error())
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.ConfigurationInSyncCondition, infrav1.DriftCorrectionBlockedReason, clusterv1.ConditionSeverityWarning,
+				"%s, not moving the VM back into its resource pool: %v", driftMessage(drifted), poolBlocked)
+		}
+		return true, nil
+	}
+
+	ctx.Logger.Info("correcting VM configuration drift", "property", property)
+	ctx.Recorder.Eventf(ctx.VSphereVM, "DriftCorrected", "%s", message)
+	driftCorrected.WithLabelValues(string(property)).Inc()
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	return false, nil
+}
+
+// networkDrift compares the network of each NIC of the VM with the network of
+// the device of the VSphereVM with the same index.
+func networkDrift(ctx *virtualMachineContext, obj *mo.VirtualMachine) (*infrav1.VirtualMachineDrift, error) {
+	if obj.Config == nil {
+		return nil, nil
+	}
+	nics := object.VirtualDeviceList(obj.Config.Hardware.Device).SelectByType((*types.VirtualEthernetCard)(nil))
+	var desired, actual []string
+	for i, device := range ctx.VSphereVM.Spec.Network.Devices {
+		if i >= len(nics) {
+			break
+		}
+		network, err := ctx.Session.FindNetwork(ctx, device.NetworkName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to find network %q", device.NetworkName)
+		}
+		backing, err := network.EthernetCardBackingInfo(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get the backing of network %q", device.NetworkName)
+		}
+		nicBacking := nics[i].GetVirtualDevice().Backing
+		if !sameNetworkBacking(nicBacking, backing) {
+			desired = append(desired, fmt.Sprintf("nic %d: %s", i, device.NetworkName))
+			actual = append(actual, fmt.Sprintf("nic %d: %s", i, networkBackingName(ctx, nicBacking)))
+		}
+	}
+	if len(desired) == 0 {
+		return nil, nil
+	}
+	return &infrav1.VirtualMachineDrift{
+		Property: infrav1.NetworkDriftProperty,
+		Desired:  strings.Join(desired, ", "),
+		Actual:   strings.Join(actual, ", "),
+	}, nil
+}
+
+// sameNetworkBacking returns true when a NIC with the given backing is on the
+// network with the desired backing.
+func sameNetworkBacking(actual, desired types.BaseVirtualDeviceBackingInfo) bool {
+	switch desired := desired.(type) {
+	case *types.VirtualEthernetCardNetworkBackingInfo:
+		actual, ok := actual.(*types.VirtualEthernetCardNetworkBackingInfo)
+		return ok && actual.DeviceName == desired.DeviceName
+	case *types.VirtualEthernetCardDistributedVirtualPortBackingInfo:
+		actual, ok := actual.(*types.VirtualEthernetCardDistributedVirtualPortBackingInfo)
+		return ok && actual.Port.PortgroupKey == desired.Port.PortgroupKey
+	case *types.VirtualEthernetCardOpaqueNetworkBackingInfo:
+		actual, ok := actual.(*types.VirtualEthernetCardOpaqueNetworkBackingInfo)
+		return ok && actual.OpaqueNetworkId == desired.OpaqueNetworkId
+	}
+	return true
+}
+
+// networkBackingName describes the network of a NIC with the given backing.
+// The key of a distributed port group is the value of its managed object
+// reference.
+func networkBackingName(ctx *virtualMachineContext, backing types.BaseVirtualDeviceBackingInfo) string {
+	switch backing := backing.(type) {
+	case *types.VirtualEthernetCardNetworkBackingInfo:
+		return backing.DeviceName
+	case *types.VirtualEthernetCardDistributedVirtualPortBackingInfo:
+		ref := types.ManagedObjectReference{Type: "DistributedVirtualPortgroup", Value: backing.Port.PortgroupKey}
+		var portgroup mo.DistributedVirtualPortgroup
+		if err := property.DefaultCollector(ctx.Session.Client.Client).RetrieveOne(ctx, ref, []string{"name"}, &portgroup); err != nil {
+			return "portgroup " + backing.Port.PortgroupKey
+		}
+		return portgroup.Name
+	case *types.VirtualEthernetCardOpaqueNetworkBackingInfo:
+		return "opaque network " + backing.OpaqueNetworkId
+	}
+	return "unknown"
+}
+
+// diskDrift compares the capacity of the primary disk of a fully cloned VM
+// with the disk size of the VSphereVM. The primary disk of a linked clone
+// keeps the size of the template.
+func diskDrift(ctx *virtualMachineContext, obj *mo.VirtualMachine) (*infrav1.VirtualMachineDrift, error) {
+	if obj.Config == nil || ctx.VSphereVM.Spec.DiskGiB <= 0 || ctx.VSphereVM.Status.CloneMode != infrav1.FullClone {
+		return nil, nil
+	}
+	disks := object.VirtualDeviceList(obj.Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return nil, nil
+	}
+	capacityKB := disks[0].(*types.VirtualDisk).CapacityInKB //nolint:forcetypeassert
+	if capacityKB == int64(ctx.VSphereVM.Spec.DiskGiB)*1024*1024 {
+		return nil, nil
+	}
+	return &infrav1.VirtualMachineDrift{
+		Property: infrav1.DiskDriftProperty,
+		Desired:  fmt.Sprintf("%dGiB", ctx.VSphereVM.Spec.DiskGiB),
+		Actual:   strconv.FormatFloat(float64(capacityKB)/(1024*1024), 'f', -1, 64) + "GiB",
+	}, nil
+}
+
+// extraConfigDrift compares the custom VMX keys of the VSphereVM with the
+// extraConfig of the VM.
+func extraConfigDrift(ctx *virtualMachineContext, obj *mo.VirtualMachine) (*infrav1.VirtualMachineDrift, error) {
+	changed, err := changedCustomVMXKeys(ctx, obj)
+	if err != nil || len(changed) == 0 {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	if obj.Config != nil {
+		for _, option := range obj.Config.ExtraConfig {
+			if value := option.GetOptionValue(); value != nil {
+				values[value.Key] = value.Value
+			}
+		}
+	}
+	var desired, actual []string
+	for _, option := range changed {
+		value := option.GetOptionValue()
+		desired = append(desired, fmt.Sprintf("%s=%v", value.Key, value.Value))
+		if current, ok := values[value.Key]; ok {
+			actual = append(actual, fmt.Sprintf("%s=%v", value.Key, current))
+		} else {
+			actual = append(actual, value.Key+" unset")
+		}
+	}
+	return &infrav1.VirtualMachineDrift{
+		Property: infrav1.ExtraConfigDriftProperty,
+		Desired:  strings.Join(desired, ", "),
+		Actual:   strings.Join(actual, ", "),
+	}, nil
+}
+
+// changedCustomVMXKeys returns the custom VMX keys of the VSphereVM whose
+// value differs in the extraConfig of the VM.
+func changedCustomVMXKeys(ctx *virtualMachineContext, obj *mo.VirtualMachine) (extra.Config, error) {
+	if len(ctx.VSphereVM.Spec.CustomVMXKeys) == 0 {
+		return nil, nil
+	}
+	var desired extra.Config
+	if err := desired.SetCustomVMXKeys(ctx.VSphereVM.Spec.CustomVMXKeys); err != nil {
+		return nil, errors.Wrapf(err, "unable to set the custom VMX keys of vm %s", ctx)
+	}
+	var existing []types.BaseOptionValue
+	if obj.Config != nil {
+		existing = obj.Config.ExtraConfig
+	}
+	return changedOptionValues(existing, desired), nil
+}
+
+// folderDrift compares the folder of the VM, as reported by reconcileFolder,
+// with the folder of the VSphereVM. A VM moved out of its folder is not
+// drifted when its folder relocation policy explicitly accepts it.
+func folderDrift(ctx *virtualMachineContext, _ *mo.VirtualMachine) (*infrav1.VirtualMachineDrift, error) {
+	if ctx.VSphereVM.Spec.FolderRelocationPolicy == infrav1.AcceptFolderRelocationPolicy || ctx.VSphereVM.Status.Folder == "" {
+		return nil, nil
+	}
+	folder, err := ctx.Session.Finder.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find folder %q of vm %s", ctx.VSphereVM.Spec.Folder, ctx)
+	}
+	if ctx.VSphereVM.Status.Folder == folder.InventoryPath {
+		return nil, nil
+	}
+	return &infrav1.VirtualMachineDrift{
+		Property: infrav1.FolderDriftProperty,
+		Desired:  folder.InventoryPath,
+		Actual:   ctx.VSphereVM.Status.Folder,
+	}, nil
+}
+
+// resourcePoolDrift compares the resource pool of the VM with the resource
+// pool of the VSphereVM.
+func resourcePoolDrift(ctx *virtualMachineContext, obj *mo.VirtualMachine) (*infrav1.VirtualMachineDrift, error) {
+	if obj.ResourcePool == nil {
+		return nil, nil
+	}
+	pool, err := ctx.Session.Finder.ResourcePoolOrDefault(ctx, ctx.VSphereVM.Spec.ResourcePool)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find resource pool %q of vm %s", ctx.VSphereVM.Spec.ResourcePool, ctx)
+	}
+	if *obj.ResourcePool == pool.Reference() {
+		return nil, nil
+	}
+	current, err := find.InventoryPath(ctx, ctx.Session.Client.Client, *obj.ResourcePool)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get the inventory path of the resource pool of vm %s", ctx)
+	}
+	return &infrav1.VirtualMachineDrift{
+		Property: infrav1.ResourcePoolDriftProperty,
+		Desired:  pool.InventoryPath,
+		Actual:   current,
+	}, nil
+}
+
+// findDrift returns the drift of the given property, or nil if the property
+// is not drifted.
+func findDrift(drifted []infrav1.VirtualMachineDrift, property infrav1.VirtualMachineDriftProperty) *infrav1.VirtualMachineDrift {
+	for i := range drifted {
+		if drifted[i].Property == property {
+			return &drifted[i]
+		}
+	}
+	return nil
+}
+
+// driftMessage describes the drifted properties of a VM.
+func driftMessage(drifted []infrav1.VirtualMachineDrift) string {
+	parts := make([]string, 0, len(drifted))
+	for _, drift := range drifted {
+		parts = append(parts, fmt.Sprintf("%s is %s instead of %s", drift.Property, drift.Actual, drift.Desired))
+	}
+	sort.Strings(parts)
+	return "the configuration of the VM drifted: " + strings.Join(parts, "; ")
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+//nolint:forcetypeassert
+func TestReconcileDrift(t *testing.T) {
+	g := NewWithT(t)
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	newContext := func() *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+		vmContext.DriftCheckInterval = time.Hour
+		vmContext.VSphereVM.Status.Ready = true
+		vmContext.VSphereVM.Status.Folder = "/DC0/vm"
+		vmContext.VSphereVM.Spec.ResourcePool = "/DC0/host/DC0_H0/Resources"
+		vmContext.VSphereVM.Spec.Network.Devices = []infrav1.NetworkDeviceSpec{{NetworkName: "DC0_DVPG0"}}
+		obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+		g.Expect(err).NotTo(HaveOccurred())
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+
+	t.Run("reports a VM in sync", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext()
+
+		ok, err := (&VMService{}).reconcileDrift(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.Drift).NotTo(BeNil())
+		g.Expect(ctx.VSphereVM.Status.Drift.Drifted).To(BeEmpty())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.ConfigurationInSyncCondition)).To(BeTrue())
+	})
+
+	t.Run("reports the drifted properties", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext()
+		ctx.VSphereVM.Spec.ResourcePool = "/DC0/host/DC0_C0/Resources"
+		ctx.VSphereVM.Spec.Network.Devices[0].NetworkName = "VM Network"
+		ctx.VSphereVM.Spec.CustomVMXKeys = map[string]string{"disk.EnableUUID": "TRUE"}
+		ctx.VSphereVM.Status.Folder = "/DC0/vm/moved"
+
+		ok, err := (&VMService{}).reconcileDrift(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.Drift.Drifted).To(ConsistOf(
+			infrav1.VirtualMachineDrift{Property: infrav1.NetworkDriftProperty, Desired: "nic 0: VM Network", Actual: "nic 0: DC0_DVPG0"},
+			infrav1.VirtualMachineDrift{Property: infrav1.ExtraConfigDriftProperty, Desired: "disk.EnableUUID=TRUE", Actual: "disk.EnableUUID unset"},
+			infrav1.VirtualMachineDrift{Property: infrav1.FolderDriftProperty, Desired: "/DC0/vm", Actual: "/DC0/vm/moved"},
+			infrav1.VirtualMachineDrift{Property: infrav1.ResourcePoolDriftProperty, Desired: "/DC0/host/DC0_C0/Resources", Actual: "/DC0/host/DC0_H0/Resources"},
+		))
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.ConfigurationInSyncCondition)).To(Equal(infrav1.ConfigurationDriftedReason))
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+	})
+
+	t.Run("does not report the folder accepted by the folder relocation policy", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext()
+		ctx.VSphereVM.Spec.FolderRelocationPolicy = infrav1.AcceptFolderRelocationPolicy
+		ctx.VSphereVM.Status.Folder = "/DC0/vm/moved"
+
+		_, err := (&VMService{}).reconcileDrift(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ctx.VSphereVM.Status.Drift.Drifted).To(BeEmpty())
+	})
+
+	t.Run("corrects the reversible drift", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext()
+		ctx.AutoCorrectDrift = true
+		ctx.VSphereVM.Spec.Network.Devices[0].NetworkName = "VM Network"
+		ctx.VSphereVM.Spec.CustomVMXKeys = map[string]string{"disk.EnableUUID": "TRUE"}
+
+		ok, err := (&VMService{}).reconcileDrift(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+
+		// The drift is checked again, and the network is only reported.
+		g.Expect(driftCheckDue(ctx, time.Now())).To(BeTrue())
+		ctx.VSphereVM.Spec.CustomVMXKeys = nil
+		ok, err = (&VMService{}).reconcileDrift(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.Drift.Drifted).To(HaveLen(1))
+		g.Expect(driftCheckDue(ctx, time.Now())).To(BeFalse())
+	})
+
+	t.Run("backs off the correction blocked by the snapshots until the next interval", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext()
+		ctx.AutoCorrectDrift = true
+		ctx.VSphereVM.Spec.ResourcePool = "/DC0/host/DC0_C0/Resources"
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition, infrav1.SnapshotChainTooLongReason, clusterv1.ConditionSeverityWarning, "too many snapshots")
+
+		ok, err := (&VMService{}).reconcileDrift(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.ConfigurationInSyncCondition)).To(Equal(infrav1.DriftCorrectionBlockedReason))
+		g.Expect(conditions.GetMessage(ctx.VSphereVM, infrav1.ConfigurationInSyncCondition)).To(ContainSubstring("too many snapshots"))
+		g.Expect(driftCheckDue(ctx, time.Now())).To(BeFalse())
+		g.Expect(driftCheckDue(ctx, time.Now().Add(time.Hour))).To(BeTrue())
+	})
+
+	t.Run("checks the drift once per interval", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext()
+		checked := metav1.NewTime(time.Now().Add(-time.Minute))
+		ctx.VSphereVM.Status.Drift = &infrav1.VirtualMachineDriftStatus{LastCheckTime: checked}
+		ctx.VSphereVM.Spec.Network.Devices[0].NetworkName = "VM Network"
+
+		ok, err := (&VMService{}).reconcileDrift(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.Drift.LastCheckTime).To(Equal(checked))
+		g.Expect(driftCheckDue(ctx, checked.Add(time.Hour))).To(BeTrue())
+	})
+}
@@ -19,16 +19,23 @@ package govmomi
 import (
 	"fmt"
 
+	"github.com/pkg/errors"
 	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
 )
 
 // errNotFound is returned by the findVM function when a VM is not found.
 type errNotFound struct {
 	uuid            string
 	byInventoryPath string
+	byMoRef         string
 }
 
 func (e errNotFound) Error() string {
+	if e.byMoRef != "" {
+		return fmt.Sprintf("vm with managed object reference %s not found", e.byMoRef)
+	}
 	if e.byInventoryPath != "" {
 		return fmt.Sprintf("vm with inventory path %s not found", e.byInventoryPath)
 	}
@@ -73,3 +80,51 @@ func wasNotFoundByBIOSUUID(err error) bool {
 		return false
 	}
 }
+
+func isResourcePoolNotFound(err error) bool {
+	switch err.(type) {
+	case *find.NotFoundError:
+		return true
+	default:
+		return false
+	}
+}
+
+func isManagedObjectNotFound(err error) bool {
+	switch vimFault(err).(type) {
+	case types.ManagedObjectNotFound, *types.ManagedObjectNotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+// isInvalidProperty returns whether an error is the InvalidProperty fault
+// returned by the versions of vCenter which do not have a property.
+func isInvalidProperty(err error) bool {
+	switch vimFault(err).(type) {
+	case types.InvalidProperty, *types.InvalidProperty:
+		return true
+	default:
+		return false
+	}
+}
+
+// isPermissionNotFound returns whether an error is the NotFound fault
+// returned by vCenter when a principal has no permission on an entity.
+func isPermissionNotFound(err error) bool {
+	switch vimFault(err).(type) {
+	case types.NotFound, *types.NotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+func vimFault(err error) types.AnyType {
+	cause := errors.Cause(err)
+	if !soap.IsSoapFault(cause) {
+		return nil
+	}
+	return soap.ToSoapFault(cause).VimFault()
+}
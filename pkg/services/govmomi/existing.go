@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+// existingVMOwnerKey is the key of the extraConfig of an existing VM holding
+// the UID of the VSphereVM bound to it, so that a VM is bound to a single
+// VSphereVM.
+const existingVMOwnerKey = "capv.vspherevm.uid"
+
+// isExistingVM returns whether the VSphereVM is bound to an existing VM
+// rather than to a VM cloned from its template.
+func isExistingVM(ctx *context.VMContext) bool {
+	return ctx.VSphereVM.Spec.ExistingVM != nil
+}
+
+// findExistingVM returns the reference of the existing VM of the VSphereVM,
+// found by its managed object reference or by its BIOS UUID.
+func findExistingVM(ctx *context.VMContext) (types.ManagedObjectReference, error) {
+	spec := ctx.VSphereVM.Spec.ExistingVM
+	if spec.MoRef != "" {
+		ref := types.ManagedObjectReference{Type: "VirtualMachine", Value: spec.MoRef}
+		var obj mo.VirtualMachine
+		if err := ctx.Session.RetrieveOne(ctx, ref, []string{"name"}, &obj); err != nil {
+			if isManagedObjectNotFound(err) {
+				return types.ManagedObjectReference{}, errNotFound{byMoRef: spec.MoRef}
+			}
+			return types.ManagedObjectReference{}, errors.Wrapf(err, "unable to get vm %s", spec.MoRef)
+		}
+		return ref, nil
+	}
+	objRef, err := ctx.Session.FindByBIOSUUID(ctx, spec.UUID)
+	if err != nil {
+		return types.ManagedObjectReference{}, err
+	}
+	if objRef == nil {
+		return types.ManagedObjectReference{}, errNotFound{uuid: spec.UUID}
+	}
+	return objRef.Reference(), nil
+}
+
+// existingVMOwner returns the instance UUID of the VSphereVM bound to an
+// existing VM, or an empty UUID when the VM is not bound.
+func existingVMOwner(ctx *virtualMachineContext) (string, error) {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.extraConfig"}, &obj); err != nil {
+		return "", errors.Wrapf(err, "unable to get extra config of vm %s", ctx)
+	}
+	if obj.Config == nil {
+		return "", nil
+	}
+	var owner string
+	for _, option := range obj.Config.ExtraConfig {
+		if value := option.GetOptionValue(); value.Key == existingVMOwnerKey {
+			owner, _ = value.Value.(string)
+		}
+	}
+	return owner, nil
+}
+
+// setExistingVMOwner writes the instance UUID of the VSphereVM bound to an
+// existing VM, or removes it when the UUID is empty.
+func setExistingVMOwner(ctx *virtualMachineContext, uid string) error {
+	task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		ExtraConfig: []types.BaseOptionValue{&types.OptionValue{Key: existingVMOwnerKey, Value: uid}},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to set the owner of vm %s", ctx)
+	}
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	return nil
+}
+
+// reconcileExistingVMOwner binds the existing VM to the VSphereVM. It returns
+// false until the VM is bound, and when the VM is bound to another VSphereVM,
+// which is reported in the VMProvisioned condition.
+func (vms *VMService) reconcileExistingVMOwner(ctx *virtualMachineContext) (bool, error) {
+	owner, err := existingVMOwner(ctx)
+	if err != nil {
+		return false, err
+	}
+	switch uuid := util.GetVMInstanceUUID(ctx.VSphereVM); owner {
+	case uuid:
+		return true, nil
+	case "":
+		ctx.Logger.Info("binding the existing vm", "vmref", ctx.Ref)
+		setVMInstanceUUID(ctx.VSphereVM, uuid)
+		return false, setExistingVMOwner(ctx, uuid)
+	default:
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.ExistingVMInUseReason, clusterv1.ConditionSeverityError,
+			"vm %s is bound to the VSphereVM with instance UUID %s", ctx.Ref.Value, owner)
+		return false, nil
+	}
+}
+
+// releaseExistingVM releases the existing VM of a deleted VSphereVM whose
+// deletion policy retains it. It returns true once the VM is no longer bound
+// to the VSphereVM, and whether the VM should be destroyed, which is only the
+// case for a VM bound to the VSphereVM with the Delete deletion policy.
+func (vms *VMService) releaseExistingVM(ctx *virtualMachineContext) (released, destroy bool, _ error) {
+	owner, err := existingVMOwner(ctx)
+	if err != nil {
+		return false, false, err
+	}
+	// A VM which is not bound to the VSphereVM is never touched.
+	if owner != util.GetVMInstanceUUID(ctx.VSphereVM) {
+		return true, false, nil
+	}
+	if ctx.VSphereVM.Spec.ExistingVM.DeletionPolicy == infrav1.DeleteExistingVMDeletionPolicy {
+		return true, true, nil
+	}
+	ctx.Logger.Info("releasing the existing vm", "vmref", ctx.Ref)
+	return false, false, setExistingVMOwner(ctx, "")
+}
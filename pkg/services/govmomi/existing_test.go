@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestExistingVM(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	newContext := func(g *WithT, name string, spec infrav1.ExistingVMSpec) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+		vmContext.VSphereVM.Spec.ExistingVM = &spec
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+	waitForTask := func(g *WithT, ctx *virtualMachineContext) {
+		g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+		task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: morefTypeTask, Value: ctx.VSphereVM.Status.TaskRef})
+		g.Expect(task.Wait(ctx)).To(Succeed())
+		ctx.VSphereVM.Status.TaskRef = ""
+	}
+
+	t.Run("finds the VM by its moRef", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM0", infrav1.ExistingVMSpec{})
+		ctx.VSphereVM.Spec.ExistingVM.MoRef = ctx.Ref.Value
+
+		g.Expect(findVM(&ctx.VMContext)).To(Equal(ctx.Ref))
+	})
+
+	t.Run("finds the VM by its BIOS UUID", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM0", infrav1.ExistingVMSpec{})
+		var obj mo.VirtualMachine
+		g.Expect(ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.uuid"}, &obj)).To(Succeed())
+		ctx.VSphereVM.Spec.ExistingVM.UUID = obj.Config.Uuid
+
+		g.Expect(findVM(&ctx.VMContext)).To(Equal(ctx.Ref))
+	})
+
+	t.Run("does not find a missing VM", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM0", infrav1.ExistingVMSpec{MoRef: "vm-missing"})
+
+		_, err := findVM(&ctx.VMContext)
+		g.Expect(isNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("binds and retains the VM", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM0", infrav1.ExistingVMSpec{DeletionPolicy: infrav1.RetainExistingVMDeletionPolicy})
+		vms := &VMService{}
+
+		g.Expect(vms.reconcileExistingVMOwner(ctx)).To(BeFalse())
+		waitForTask(g, ctx)
+		g.Expect(existingVMOwner(ctx)).To(Equal(fake.VSphereVMUUID))
+		g.Expect(vms.reconcileExistingVMOwner(ctx)).To(BeTrue())
+
+		released, destroy, err := vms.releaseExistingVM(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(released).To(BeFalse())
+		g.Expect(destroy).To(BeFalse())
+		waitForTask(g, ctx)
+		g.Expect(existingVMOwner(ctx)).To(BeEmpty())
+	})
+
+	t.Run("destroys the VM with the Delete policy", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM1", infrav1.ExistingVMSpec{DeletionPolicy: infrav1.DeleteExistingVMDeletionPolicy})
+		vms := &VMService{}
+		g.Expect(setExistingVMOwner(ctx, fake.VSphereVMUUID)).To(Succeed())
+		waitForTask(g, ctx)
+
+		released, destroy, err := vms.releaseExistingVM(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(released).To(BeTrue())
+		g.Expect(destroy).To(BeTrue())
+	})
+
+	t.Run("does not touch a VM bound to another VSphereVM", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM0", infrav1.ExistingVMSpec{DeletionPolicy: infrav1.DeleteExistingVMDeletionPolicy})
+		vms := &VMService{}
+		g.Expect(setExistingVMOwner(ctx, "another-vspherevm")).To(Succeed())
+		waitForTask(g, ctx)
+
+		g.Expect(vms.reconcileExistingVMOwner(ctx)).To(BeFalse())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMProvisionedCondition)).To(Equal(infrav1.ExistingVMInUseReason))
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+
+		released, destroy, err := vms.releaseExistingVM(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(released).To(BeTrue())
+		g.Expect(destroy).To(BeFalse())
+		g.Expect(existingVMOwner(ctx)).To(Equal("another-vspherevm"))
+	})
+}
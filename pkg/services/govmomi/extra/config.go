@@ -18,20 +18,35 @@ package extra
 
 import (
 	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 )
 
 // Config is data used with a VM's guestInfo RPC interface.
 type Config []types.BaseOptionValue
 
 // SetCustomVMXKeys sets the custom VMX keys as
-// OptionValues in extraConfig.
+// OptionValues in extraConfig, sorted by key. It fails on the keys set by
+// CAPV, which the custom keys would override.
 func (e *Config) SetCustomVMXKeys(customKeys map[string]string) error {
-	for k, v := range customKeys {
+	keys := make([]string, 0, len(customKeys))
+	for k := range customKeys {
+		if infrav1.IsReservedVMXKey(k) {
+			return errors.Errorf("custom VMX key %q is set by the provider", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
 		*e = append(*e, &types.OptionValue{
 			Key:   k,
-			Value: v,
+			Value: customKeys[k],
 		})
 	}
 	return nil
@@ -53,6 +68,42 @@ func (e *Config) SetCloudInitUserData(data []byte) error {
 	return nil
 }
 
+// SetIgnitionUserData sets the Ignition config at the key
+// "guestinfo.ignition.config.data" as a base64-encoded string.
+func (e *Config) SetIgnitionUserData(data []byte) error {
+	*e = append(*e,
+		&types.OptionValue{
+			Key:   "guestinfo.ignition.config.data",
+			Value: e.encode(data),
+		},
+		&types.OptionValue{
+			Key:   "guestinfo.ignition.config.data.encoding",
+			Value: "base64",
+		},
+	)
+	return nil
+}
+
+// SetBootstrapData sets the bootstrap data at the keys of its format.
+func (e *Config) SetBootstrapData(format infrav1.BootstrapFormat, data []byte) error {
+	if format == infrav1.IgnitionBootstrapFormat {
+		return e.SetIgnitionUserData(data)
+	}
+	return e.SetCloudInitUserData(data)
+}
+
+// SetNetworkKargs sets the kernel arguments configuring the network of the
+// initramfs of an Ignition guest at the key
+// "guestinfo.afterburn.initrd.network-kargs", which Afterburn reads on the
+// first boot of the guest.
+func (e *Config) SetNetworkKargs(kargs string) error {
+	*e = append(*e, &types.OptionValue{
+		Key:   "guestinfo.afterburn.initrd.network-kargs",
+		Value: kargs,
+	})
+	return nil
+}
+
 // SetCloudInitMetadata sets the cloud init user data at the key
 // "guestinfo.metadata" as a base64-encoded string.
 func (e *Config) SetCloudInitMetadata(data []byte) error {
@@ -70,6 +121,119 @@ func (e *Config) SetCloudInitMetadata(data []byte) error {
 	return nil
 }
 
+// InstanceUUIDKey is the key at which the instance UUID of a VM is recorded
+// when it is cloned, so that the VM found by its inventory path is only
+// adopted by the VSphereVM it was cloned for.
+const InstanceUUIDKey = "capv.vspherevm.instance-uuid"
+
+// SetInstanceUUID records the instance UUID of a VM at the key
+// "capv.vspherevm.instance-uuid".
+func (e *Config) SetInstanceUUID(uuid string) error {
+	*e = append(*e, &types.OptionValue{
+		Key:   InstanceUUIDKey,
+		Value: uuid,
+	})
+	return nil
+}
+
+// ManagementClusterIDKey is the key at which the ID of the management cluster
+// cloning a VM is recorded, so that the garbage collector of the orphaned VMs
+// of a management cluster does not delete the VMs of another one.
+const ManagementClusterIDKey = "capv.management-cluster-id"
+
+// SetManagementClusterID records the ID of the management cluster cloning a
+// VM at the key "capv.management-cluster-id".
+func (e *Config) SetManagementClusterID(id string) error {
+	*e = append(*e, &types.OptionValue{
+		Key:   ManagementClusterIDKey,
+		Value: id,
+	})
+	return nil
+}
+
+// dataDiskKeyPrefix is the prefix of the keys at which the locations of the
+// data disks of a VM are recorded.
+const dataDiskKeyPrefix = "capv.dataDisk."
+
+// SetDataDisk records the controller key and unit number of a data disk at
+// the key "capv.dataDisk.<name>".
+func (e *Config) SetDataDisk(name string, controllerKey, unitNumber int32) error {
+	*e = append(*e, &types.OptionValue{
+		Key:   dataDiskKeyPrefix + name,
+		Value: DataDiskLocation(controllerKey, unitNumber),
+	})
+	return nil
+}
+
+// DataDiskLocation returns the location of a disk recorded by SetDataDisk.
+func DataDiskLocation(controllerKey, unitNumber int32) string {
+	return fmt.Sprintf("%d:%d", controllerKey, unitNumber)
+}
+
+// SetDataDiskOnBus records the bus and unit number of a data disk attached
+// to a controller added with the VM, whose key is only assigned when the VM
+// is created, at the key "capv.dataDisk.<name>".
+func (e *Config) SetDataDiskOnBus(name, bus string, unitNumber int32) error {
+	*e = append(*e, &types.OptionValue{
+		Key:   dataDiskKeyPrefix + name,
+		Value: DataDiskBusLocation(bus, unitNumber),
+	})
+	return nil
+}
+
+// DataDiskBusLocation returns the location of a disk recorded by
+// SetDataDiskOnBus, e.g. "scsi1:0".
+func DataDiskBusLocation(bus string, unitNumber int32) string {
+	return fmt.Sprintf("%s:%d", bus, unitNumber)
+}
+
+// ControllerBus returns the name of the bus of a disk controller in the
+// configuration of a VM, e.g. "scsi1", or an empty string for the devices
+// which are not SCSI or NVMe controllers.
+func ControllerBus(controller types.BaseVirtualDevice) string {
+	switch c := controller.(type) {
+	case types.BaseVirtualSCSIController:
+		return fmt.Sprintf("scsi%d", c.GetVirtualSCSIController().BusNumber)
+	case *types.VirtualNVMEController:
+		return fmt.Sprintf("nvme%d", c.BusNumber)
+	default:
+		return ""
+	}
+}
+
+// SetControllerOptions sets the advanced options of a disk controller,
+// prefixed with the name of its bus, sorted by key.
+func (e *Config) SetControllerOptions(bus string, options map[string]string) error {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		*e = append(*e, &types.OptionValue{
+			Key:   bus + "." + k,
+			Value: options[k],
+		})
+	}
+	return nil
+}
+
+// DataDisks returns the names of the data disks recorded in the extra config
+// of a VM, keyed by their location.
+func DataDisks(options []types.BaseOptionValue) map[string]string {
+	disks := map[string]string{}
+	for _, o := range options {
+		opt := o.GetOptionValue()
+		if !strings.HasPrefix(opt.Key, dataDiskKeyPrefix) {
+			continue
+		}
+		if location, ok := opt.Value.(string); ok {
+			disks[location] = strings.TrimPrefix(opt.Key, dataDiskKeyPrefix)
+		}
+	}
+	return disks
+}
+
 // encode first attempts to decode the data as many times as necessary
 // to ensure it is plain-text before returning the result as a base64
 // encoded string.
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// haRestartEventTypes are the types of the events recorded by vCenter when
+// vSphere HA restarts a VM, on another host after the failure of its host, or
+// after its guest stopped reporting a heartbeat.
+var haRestartEventTypes = []string{
+	"VmRestartedOnAlternateHostEvent",
+	"VmDasBeingResetEvent",
+	"VmDasBeingResetWithScreenshotEvent",
+}
+
+// haEventQueryInterval is the minimum interval between two queries of the
+// vSphere HA events of a VM, so that the ready VMs do not query the events of
+// vCenter on every reconcile.
+const haEventQueryInterval = 5 * time.Minute
+
+// haEventQueries holds the time of the last query of the vSphere HA events of
+// each VSphereVM, by UID. The entries older than haEventQueryInterval, e.g. of
+// the deleted VSphereVMs, are pruned when a query is recorded.
+var haEventQueries sync.Map
+
+// haEventQueryDue returns whether the vSphere HA events of a VSphereVM may be
+// queried, and records the query if they may.
+func haEventQueryDue(uid apitypes.UID, now time.Time) bool {
+	if last, ok := haEventQueries.Load(uid); ok && now.Before(last.(time.Time).Add(haEventQueryInterval)) {
+		return false
+	}
+	haEventQueries.Range(func(key, last interface{}) bool {
+		if !now.Before(last.(time.Time).Add(haEventQueryInterval)) {
+			haEventQueries.Delete(key)
+		}
+		return true
+	})
+	haEventQueries.Store(uid, now)
+	return true
+}
+
+// reconcileVMHealth reports the heartbeat of the guest of a ready VM in the
+// GuestHeartbeatHealthy condition, and the restarts of the VM by vSphere HA in
+// the VMRunning condition. The VMRunning condition is set to True once the
+// guest reports a green heartbeat. The events of vSphere HA are queried at
+// most once per haEventQueryInterval. Neither condition is part of the Ready
+// summary of the VSphereVM, as the guests without VMware Tools never report
+// a heartbeat.
+func (vms *VMService) reconcileVMHealth(ctx *virtualMachineContext) error {
+	if !ctx.VSphereVM.Status.Ready {
+		return nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"guest.toolsRunningStatus", "guestHeartbeatStatus"}, &obj); err != nil {
+		return errors.Wrapf(err, "failed to get the guest heartbeat of vm %s", ctx)
+	}
+	healthy := false
+	switch toolsRunning := obj.Guest != nil && obj.Guest.ToolsRunningStatus == string(types.VirtualMachineToolsRunningStatusGuestToolsRunning); {
+	case !toolsRunning:
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.GuestHeartbeatHealthyCondition, infrav1.GuestToolsNotRunningReason, clusterv1.ConditionSeverityWarning,
+			"VMware Tools is not running in the guest")
+	case obj.GuestHeartbeatStatus == types.ManagedEntityStatusGreen:
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.GuestHeartbeatHealthyCondition)
+		healthy = true
+	default:
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.GuestHeartbeatHealthyCondition, infrav1.GuestHeartbeatUnhealthyReason, clusterv1.ConditionSeverityWarning,
+			"the guest heartbeat is %s", obj.GuestHeartbeatStatus)
+	}
+
+	// Only the restarts since the last transition of the VMRunning condition
+	// are reported, so a restart is not reported again once the VM runs.
+	if conditions.GetReason(ctx.VSphereVM, infrav1.VMRunningCondition) != infrav1.RestartedByHAReason && haEventQueryDue(ctx.VSphereVM.UID, time.Now()) {
+		since := ctx.VSphereVM.CreationTimestamp.Time
+		if lastTransition := conditions.GetLastTransitionTime(ctx.VSphereVM, infrav1.VMRunningCondition); lastTransition != nil {
+			since = lastTransition.Time
+		}
+		restart, err := findHARestart(ctx, since)
+		if err != nil {
+			return err
+		}
+		if restart != nil {
+			message := haRestartMessage(restart)
+			ctx.Recorder.Warnf(ctx.VSphereVM, "VMRestartedByHA", "VM %s: %s", ctx.VSphereVM.Name, message)
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMRunningCondition, infrav1.RestartedByHAReason, clusterv1.ConditionSeverityWarning, message)
+			return nil
+		}
+	}
+
+	if healthy {
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.VMRunningCondition)
+	}
+	return nil
+}
+
+// findHARestart returns the latest event of a restart of the VM by vSphere HA
+// created after the given time, or nil if there is none.
+func findHARestart(ctx *virtualMachineContext, since time.Time) (types.BaseEvent, error) {
+	events, err := event.NewManager(ctx.Session.Client.Client).QueryEvents(ctx, types.EventFilterSpec{
+		Entity: &types.EventFilterSpecByEntity{
+			Entity:    ctx.Ref,
+			Recursion: types.EventFilterSpecRecursionOptionSelf,
+		},
+		Time:        &types.EventFilterSpecByTime{BeginTime: &since},
+		EventTypeId: haRestartEventTypes,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query the vSphere HA events of vm %s", ctx)
+	}
+	var latest types.BaseEvent
+	for _, e := range events {
+		if latest == nil || e.GetEvent().CreatedTime.After(latest.GetEvent().CreatedTime) {
+			latest = e
+		}
+	}
+	return latest, nil
+}
+
+// haRestartMessage describes a restart of a VM by vSphere HA.
+func haRestartMessage(e types.BaseEvent) string {
+	at := e.GetEvent().CreatedTime.UTC().Format(time.RFC3339)
+	if restarted, ok := e.(*types.VmRestartedOnAlternateHostEvent); ok {
+		host := ""
+		if restarted.Host != nil {
+			host = restarted.Host.Name
+		}
+		return fmt.Sprintf("vSphere HA restarted the VM on host %s after the failure of host %s at %s", host, restarted.SourceHost.Name, at)
+	}
+	return fmt.Sprintf("vSphere HA reset the VM after its guest stopped reporting a heartbeat at %s", at)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+//nolint:forcetypeassert
+func TestReconcileVMHealth(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	// newContext returns the context of a ready VSphereVM whose VM has the
+	// VMware Tools status and the guest heartbeat status.
+	newContext := func(g *WithT, name string, toolsStatus types.VirtualMachineToolsRunningStatus, heartbeat types.ManagedEntityStatus) (*virtualMachineContext, *simulator.VirtualMachine) {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Status.Ready = true
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		vm := simulator.Map.Get(obj.Reference()).(*simulator.VirtualMachine)
+		vm.Guest.ToolsRunningStatus = string(toolsStatus)
+		vm.GuestHeartbeatStatus = heartbeat
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       object.NewVirtualMachine(authSession.Client.Client, vm.Reference()),
+			Ref:       vm.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}, vm
+	}
+
+	t.Run("reports a green heartbeat", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, _ := newContext(g, "DC0_H0_VM0", types.VirtualMachineToolsRunningStatusGuestToolsRunning, types.ManagedEntityStatusGreen)
+
+		g.Expect((&VMService{}).reconcileVMHealth(ctx)).To(Succeed())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.GuestHeartbeatHealthyCondition)).To(BeTrue())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.VMRunningCondition)).To(BeTrue())
+	})
+
+	t.Run("reports VMware Tools not running", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, _ := newContext(g, "DC0_H0_VM1", types.VirtualMachineToolsRunningStatusGuestToolsNotRunning, types.ManagedEntityStatusGray)
+
+		g.Expect((&VMService{}).reconcileVMHealth(ctx)).To(Succeed())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.GuestHeartbeatHealthyCondition)).To(Equal(infrav1.GuestToolsNotRunningReason))
+		g.Expect(conditions.Has(ctx.VSphereVM, infrav1.VMRunningCondition)).To(BeFalse())
+	})
+
+	t.Run("reports an unhealthy heartbeat", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, _ := newContext(g, "DC0_C0_RP0_VM0", types.VirtualMachineToolsRunningStatusGuestToolsRunning, types.ManagedEntityStatusRed)
+
+		g.Expect((&VMService{}).reconcileVMHealth(ctx)).To(Succeed())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.GuestHeartbeatHealthyCondition)).To(Equal(infrav1.GuestHeartbeatUnhealthyReason))
+		g.Expect(conditions.GetMessage(ctx.VSphereVM, infrav1.GuestHeartbeatHealthyCondition)).To(ContainSubstring("red"))
+	})
+
+	t.Run("does not report the health of a VSphereVM which is not ready", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, _ := newContext(g, "DC0_C0_RP0_VM1", types.VirtualMachineToolsRunningStatusGuestToolsRunning, types.ManagedEntityStatusGreen)
+		ctx.VSphereVM.Status.Ready = false
+
+		g.Expect((&VMService{}).reconcileVMHealth(ctx)).To(Succeed())
+		g.Expect(ctx.VSphereVM.Status.Conditions).To(BeEmpty())
+	})
+
+	t.Run("reports a restart by vSphere HA until the heartbeat is green", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, vm := newContext(g, "DC0_H0_VM0", types.VirtualMachineToolsRunningStatusGuestToolsRunning, types.ManagedEntityStatusGreen)
+		haEventQueries.Delete(ctx.VSphereVM.UID)
+		g.Expect((&VMService{}).reconcileVMHealth(ctx)).To(Succeed())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.VMRunningCondition)).To(BeTrue())
+
+		restarted := &types.VmRestartedOnAlternateHostEvent{SourceHost: types.HostEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "DC0_H1"}}}
+		restarted.Vm = &types.VmEventArgument{Vm: ctx.Ref}
+		restarted.Host = &types.HostEventArgument{EntityEventArgument: types.EntityEventArgument{Name: "DC0_H0"}, Host: *vm.Runtime.Host}
+		g.Expect(event.NewManager(ctx.Session.Client.Client).PostEvent(ctx, restarted)).To(Succeed())
+		vm.GuestHeartbeatStatus = types.ManagedEntityStatusGray
+
+		// The events are not queried again until haEventQueryInterval
+		// elapsed since the last query.
+		g.Expect((&VMService{}).reconcileVMHealth(ctx)).To(Succeed())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.VMRunningCondition)).To(BeTrue())
+
+		haEventQueries.Store(ctx.VSphereVM.UID, time.Now().Add(-haEventQueryInterval))
+		g.Expect((&VMService{}).reconcileVMHealth(ctx)).To(Succeed())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMRunningCondition)).To(Equal(infrav1.RestartedByHAReason))
+		g.Expect(conditions.GetMessage(ctx.VSphereVM, infrav1.VMRunningCondition)).To(ContainSubstring("on host DC0_H0 after the failure of host DC0_H1"))
+
+		// The restart is reported while the heartbeat is not green.
+		g.Expect((&VMService{}).reconcileVMHealth(ctx)).To(Succeed())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMRunningCondition)).To(Equal(infrav1.RestartedByHAReason))
+
+		vm.GuestHeartbeatStatus = types.ManagedEntityStatusGreen
+		g.Expect((&VMService{}).reconcileVMHealth(ctx)).To(Succeed())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.VMRunningCondition)).To(BeTrue())
+	})
+
+	t.Run("reports a running VM powered off outside of Kubernetes", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, _ := newContext(g, "DC0_H0_VM1", types.VirtualMachineToolsRunningStatusGuestToolsRunning, types.ManagedEntityStatusGreen)
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.VMRunningCondition)
+		task, err := ctx.Obj.PowerOff(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+
+		poweredOn, err := (&VMService{}).reconcilePowerState(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(poweredOn).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMRunningCondition)).To(Equal(infrav1.PoweredOffExternallyReason))
+	})
+}
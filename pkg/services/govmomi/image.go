@@ -0,0 +1,246 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// ErrImageNotFound is returned when neither the datacenter nor the content
+// library of a VSphereMachineImage provide its image, and no URL is set to
+// import it from.
+var ErrImageNotFound = errors.New("image not found")
+
+// importPollInterval is the interval at which the import of an OVA into a
+// content library is polled.
+const importPollInterval = 5 * time.Second
+
+// ImageItemName returns the name of the template and of the content library
+// item of a VSphereMachineImage, e.g. "ubuntu-2204-kube-v1.28.3".
+func ImageItemName(image *infrav1.VSphereMachineImage) string {
+	return fmt.Sprintf("%s-kube-%s", image.Spec.ImageName, image.Spec.KubernetesVersion)
+}
+
+// ResolveImageTemplate returns the reference of the template of a
+// VSphereMachineImage. A template of the same name in the image folder is
+// used as is; otherwise the template is deployed from the content library
+// item of the image, which is first imported from the image URL when the
+// library has none.
+//
+// With verifiers, the item is only deployed once they accept one of its
+// checksums, which is returned in the <algorithm>:<value> format. An existing
+// template is then only used as is when it is the template deployed from the
+// verified item recorded in the status of the image.
+func ResolveImageTemplate(ctx goctx.Context, s *session.Session, image *infrav1.VSphereMachineImage, verifiers []ImageVerifier) (types.ManagedObjectReference, string, error) {
+	name := ImageItemName(image)
+
+	tpl, err := s.FindVirtualMachine(ctx, path.Join(image.Spec.Folder, name))
+	if err == nil {
+		if len(verifiers) == 0 {
+			return tpl.Reference(), "", nil
+		}
+		if image.Status.TemplateRef == tpl.Reference().String() {
+			if checksum, ok := parseChecksum(image.Status.VerifiedChecksum); ok && verifyChecksum(verifiers, checksum) == nil {
+				return tpl.Reference(), image.Status.VerifiedChecksum, nil
+			}
+		}
+		return types.ManagedObjectReference{}, "", errors.Wrapf(ErrImageVerificationFailed,
+			"template %q was not deployed from a verified content library item, delete it to deploy it again", name)
+	}
+	if !isVirtualMachineNotFound(err) {
+		return types.ManagedObjectReference{}, "", errors.Wrapf(err, "failed to look up template %q", name)
+	}
+
+	libManager := library.NewManager(s.TagManager.Client)
+	lib, err := libManager.GetLibraryByName(ctx, image.Spec.ContentLibrary)
+	if err != nil {
+		return types.ManagedObjectReference{}, "", errors.Wrapf(err, "failed to find content library %q", image.Spec.ContentLibrary)
+	}
+	itemIDs, err := libManager.FindLibraryItems(ctx, library.FindItem{LibraryID: lib.ID, Name: name})
+	if err != nil {
+		return types.ManagedObjectReference{}, "", errors.Wrapf(err, "failed to find item %q in content library %q", name, lib.Name)
+	}
+
+	var itemID, verifiedChecksum string
+	switch {
+	case len(itemIDs) > 0:
+		itemID = itemIDs[0]
+		if len(verifiers) > 0 {
+			files, err := libManager.ListLibraryItemFiles(ctx, itemID)
+			if err != nil {
+				return types.ManagedObjectReference{}, "", errors.Wrapf(err, "failed to list the files of item %q", name)
+			}
+			checksum, err := verifyItemFiles(verifiers, name, files)
+			if err != nil {
+				return types.ManagedObjectReference{}, "", err
+			}
+			verifiedChecksum = FormatChecksum(checksum)
+		}
+	case image.Spec.URL == "":
+		return types.ManagedObjectReference{}, "", errors.Wrapf(ErrImageNotFound, "no template or item of content library %q is named %q", lib.Name, name)
+	default:
+		// The checksum is verified before the import, so that an untrusted
+		// OVA is not downloaded, and vCenter then verifies the OVA against
+		// it.
+		var checksum *library.Checksum
+		if len(verifiers) > 0 {
+			if image.Spec.Verification == nil || image.Spec.Verification.Checksum == nil {
+				return types.ManagedObjectReference{}, "", errors.Wrapf(ErrImageVerificationFailed, "a checksum is required to import %s", image.Spec.URL)
+			}
+			checksum = &library.Checksum{
+				Algorithm: image.Spec.Verification.Checksum.Algorithm,
+				Checksum:  image.Spec.Verification.Checksum.Value,
+			}
+			if err := verifyChecksum(verifiers, *checksum); err != nil {
+				return types.ManagedObjectReference{}, "", errors.Wrapf(ErrImageVerificationFailed, "%s: %v", image.Spec.URL, err)
+			}
+			verifiedChecksum = FormatChecksum(*checksum)
+		}
+		if itemID, err = importLibraryItem(ctx, libManager, lib, name, image.Spec.URL, checksum); err != nil {
+			return types.ManagedObjectReference{}, "", err
+		}
+	}
+
+	ref, err := deployLibraryItem(ctx, s, image, itemID, name)
+	if err != nil {
+		return types.ManagedObjectReference{}, "", err
+	}
+	return ref, verifiedChecksum, nil
+}
+
+// importLibraryItem imports the OVA at url into a new item of the content
+// library. When checksum is set, vCenter fails the import of an OVA which
+// does not match it. The item is deleted when the import fails, so that the
+// next attempt imports the OVA again rather than deploying the empty item.
+func importLibraryItem(ctx goctx.Context, m *library.Manager, lib *library.Library, name, url string, checksum *library.Checksum) (_ string, reterr error) {
+	itemID, err := m.CreateLibraryItem(ctx, library.Item{Name: name, Type: "ovf", LibraryID: lib.ID})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create item %q in content library %q", name, lib.Name)
+	}
+	defer func() {
+		if reterr == nil {
+			return
+		}
+		if err := m.DeleteLibraryItem(ctx, &library.Item{ID: itemID}); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, errors.Wrapf(err, "failed to delete item %q after its import failed", name)})
+		}
+	}()
+	sessionID, err := m.CreateLibraryItemUpdateSession(ctx, library.Session{LibraryItemID: itemID})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create update session of item %q", name)
+	}
+	if _, err := addLibraryItemFileFromURI(ctx, m, sessionID, path.Base(url), url, checksum); err != nil {
+		_ = m.CancelLibraryItemUpdateSession(ctx, sessionID)
+		return "", errors.Wrapf(err, "failed to import %s into item %q", url, name)
+	}
+	if err := m.WaitOnLibraryItemUpdateSession(ctx, sessionID, importPollInterval, nil); err != nil {
+		_ = m.CancelLibraryItemUpdateSession(ctx, sessionID)
+		return "", errors.Wrapf(err, "failed to import %s into item %q", url, name)
+	}
+	if err := m.CompleteLibraryItemUpdateSession(ctx, sessionID); err != nil {
+		return "", errors.Wrapf(err, "failed to complete the import of item %q", name)
+	}
+	return itemID, nil
+}
+
+// addLibraryItemFileFromURI adds the file at uri to the update session, like
+// library.Manager.AddLibraryItemFileFromURI, with the checksum vCenter
+// verifies the file against.
+func addLibraryItemFileFromURI(ctx goctx.Context, m *library.Manager, sessionID, name, uri string, checksum *library.Checksum) (*library.UpdateFile, error) {
+	if checksum == nil {
+		return m.AddLibraryItemFileFromURI(ctx, sessionID, name, uri)
+	}
+
+	resp, err := m.Head(uri)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	var fingerprint string
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		fingerprint = m.Thumbprint(resp.Request.URL.Host)
+		if fingerprint == "" && m.DefaultTransport().TLSClientConfig.InsecureSkipVerify {
+			fingerprint = soap.ThumbprintSHA1(resp.TLS.PeerCertificates[0])
+		}
+	}
+
+	info, err := m.AddLibraryItemFile(ctx, sessionID, library.UpdateFile{
+		Name:       name,
+		SourceType: "PULL",
+		Size:       resp.ContentLength,
+		Checksum:   checksum,
+		SourceEndpoint: &library.TransferEndpoint{
+			URI:                      uri,
+			SSLCertificateThumbprint: fingerprint,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, m.CompleteLibraryItemUpdateSession(ctx, sessionID)
+}
+
+// deployLibraryItem deploys the content library item as a template in the
+// folder, resource pool and datastore of the image.
+func deployLibraryItem(ctx goctx.Context, s *session.Session, image *infrav1.VSphereMachineImage, itemID, name string) (types.ManagedObjectReference, error) {
+	folder, err := s.Finder.FolderOrDefault(ctx, image.Spec.Folder)
+	if err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "unable to get folder for %q", name)
+	}
+	pool, err := s.Finder.ResourcePoolOrDefault(ctx, image.Spec.ResourcePool)
+	if err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "unable to get resource pool for %q", name)
+	}
+	datastore, err := s.Finder.DatastoreOrDefault(ctx, image.Spec.Datastore)
+	if err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "unable to get datastore for %q", name)
+	}
+
+	ref, err := vcenter.NewManager(s.TagManager.Client).DeployLibraryItem(ctx, itemID, vcenter.Deploy{
+		DeploymentSpec: vcenter.DeploymentSpec{
+			Name:               name,
+			AcceptAllEULA:      true,
+			DefaultDatastoreID: datastore.Reference().Value,
+		},
+		Target: vcenter.Target{
+			ResourcePoolID: pool.Reference().Value,
+			FolderID:       folder.Reference().Value,
+		},
+	})
+	if err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "failed to deploy item %q", name)
+	}
+
+	if err := object.NewVirtualMachine(s.Client.Client, *ref).MarkAsTemplate(ctx); err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "failed to mark %q as a template", name)
+	}
+	return *ref, nil
+}
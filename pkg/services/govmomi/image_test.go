@@ -0,0 +1,65 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/library"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestImportLibraryItem(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	ctx := goctx.Background()
+	authSession, err := session.GetOrCreate(ctx, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	m := library.NewManager(authSession.TagManager.Client)
+	libID, err := m.CreateLibrary(ctx, library.Library{
+		Name:    "images",
+		Type:    "LOCAL",
+		Storage: []library.StorageBackings{{DatastoreID: simulator.Map.Any("Datastore").Reference().Value, Type: "DATASTORE"}},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	lib, err := m.GetLibraryByID(ctx, libID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The item created for an OVA which cannot be imported is deleted.
+	_, err = importLibraryItem(ctx, m, lib, "ubuntu-2204-kube-v1.28.3", "http://127.0.0.1:1/ubuntu-2204-kube-v1.28.3.ova", nil)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring(`failed to import http://127.0.0.1:1/ubuntu-2204-kube-v1.28.3.ova into item "ubuntu-2204-kube-v1.28.3"`))
+
+	itemIDs, err := m.FindLibraryItems(ctx, library.FindItem{LibraryID: lib.ID, Name: "ubuntu-2204-kube-v1.28.3"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(itemIDs).To(BeEmpty())
+}
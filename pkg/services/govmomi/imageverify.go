@@ -0,0 +1,168 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vapi/library"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// ErrImageVerificationFailed is returned when the content of the image of a
+// VSphereMachineImage does not match its verification, or cannot be verified.
+var ErrImageVerificationFailed = errors.New("image verification failed")
+
+// ImageVerifier verifies a checksum of the content of an image, before the
+// machines are cloned from it. An image is only deployed from a content
+// library item when every verifier of the image accepts a checksum of the
+// item.
+type ImageVerifier interface {
+	// Verify returns an error when the checksum is not a trusted checksum of
+	// the image.
+	Verify(checksum library.Checksum) error
+}
+
+// ChecksumVerifier accepts the expected checksum of an image.
+type ChecksumVerifier struct {
+	Expected infrav1.ImageChecksum
+}
+
+// Verify implements ImageVerifier.
+func (v ChecksumVerifier) Verify(checksum library.Checksum) error {
+	if !strings.EqualFold(checksum.Algorithm, v.Expected.Algorithm) {
+		return errors.Errorf("checksum is not a %s checksum", v.Expected.Algorithm)
+	}
+	if !strings.EqualFold(checksum.Checksum, v.Expected.Value) {
+		return errors.Errorf("%s checksum %s does not match the expected checksum %s", checksum.Algorithm, checksum.Checksum, v.Expected.Value)
+	}
+	return nil
+}
+
+// SignatureVerifier accepts the SHA256 checksum of an image signed with the
+// private key of a public key.
+type SignatureVerifier struct {
+	PublicKey crypto.PublicKey
+	Signature []byte
+}
+
+// NewSignatureVerifier returns the verifier of a base64-encoded signature
+// with a PEM-encoded ECDSA or RSA public key.
+func NewSignatureVerifier(signature string, publicKeyPEM []byte) (*SignatureVerifier, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode the signature")
+	}
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, errors.New("failed to decode the PEM-encoded public key")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the public key")
+	}
+	switch publicKey.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, errors.Errorf("unsupported public key type %T", publicKey)
+	}
+	return &SignatureVerifier{PublicKey: publicKey, Signature: sig}, nil
+}
+
+// Verify implements ImageVerifier.
+func (v SignatureVerifier) Verify(checksum library.Checksum) error {
+	if !strings.EqualFold(checksum.Algorithm, "SHA256") {
+		return errors.New("only SHA256 checksums can be verified with a signature")
+	}
+	digest, err := hex.DecodeString(checksum.Checksum)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode the checksum")
+	}
+	switch publicKey := v.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(publicKey, digest, v.Signature) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, v.Signature); err != nil {
+			return errors.Wrap(err, "invalid signature")
+		}
+	default:
+		return errors.Errorf("unsupported public key type %T", publicKey)
+	}
+	return nil
+}
+
+// FormatChecksum returns the checksum in the <algorithm>:<value> format of
+// the status of a VSphereMachineImage.
+func FormatChecksum(checksum library.Checksum) string {
+	return fmt.Sprintf("%s:%s", strings.ToUpper(checksum.Algorithm), strings.ToLower(checksum.Checksum))
+}
+
+// parseChecksum parses a checksum in the <algorithm>:<value> format.
+func parseChecksum(s string) (library.Checksum, bool) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return library.Checksum{}, false
+	}
+	return library.Checksum{Algorithm: parts[0], Checksum: parts[1]}, true
+}
+
+// verifyChecksum returns the error of the first verifier which rejects the
+// checksum.
+func verifyChecksum(verifiers []ImageVerifier, checksum library.Checksum) error {
+	for _, v := range verifiers {
+		if err := v.Verify(checksum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyItemFiles returns the checksum of the files of a content library
+// item, once every file of the item has a checksum accepted by all the
+// verifiers, so that an item is not deployed when one of its files, e.g. a
+// disk added to a verified item, is not verified.
+func verifyItemFiles(verifiers []ImageVerifier, name string, files []library.File) (library.Checksum, error) {
+	if len(files) == 0 {
+		return library.Checksum{}, errors.Wrapf(ErrImageVerificationFailed, "item %q has no file", name)
+	}
+	var errs []string
+	for _, file := range files {
+		if file.Checksum == nil {
+			errs = append(errs, fmt.Sprintf("file %q has no checksum", file.Name))
+			continue
+		}
+		if err := verifyChecksum(verifiers, *file.Checksum); err != nil {
+			errs = append(errs, fmt.Sprintf("file %q: %v", file.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return library.Checksum{}, errors.Wrapf(ErrImageVerificationFailed, "item %q is not verified: %s", name, strings.Join(errs, "; "))
+	}
+	return *files[0].Checksum, nil
+}
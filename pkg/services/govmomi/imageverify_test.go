@@ -0,0 +1,126 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vapi/library"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestImageVerifiers(t *testing.T) {
+	sum := sha256.Sum256([]byte("ubuntu-2204-kube-v1.28.3.ova"))
+	checksum := library.Checksum{Algorithm: "SHA256", Checksum: hex.EncodeToString(sum[:])}
+	otherSum := sha256.Sum256([]byte("tampered.ova"))
+	otherChecksum := library.Checksum{Algorithm: "SHA256", Checksum: hex.EncodeToString(otherSum[:])}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaSig, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPEM := func(key crypto.PublicKey) []byte {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+
+	t.Run("checksum", func(t *testing.T) {
+		g := NewWithT(t)
+		v := ChecksumVerifier{Expected: infrav1.ImageChecksum{Algorithm: "SHA256", Value: checksum.Checksum}}
+		g.Expect(v.Verify(library.Checksum{Algorithm: "sha256", Checksum: checksum.Checksum})).To(Succeed())
+		g.Expect(v.Verify(otherChecksum)).NotTo(Succeed())
+		g.Expect(v.Verify(library.Checksum{Algorithm: "SHA1", Checksum: checksum.Checksum})).NotTo(Succeed())
+	})
+
+	t.Run("ECDSA signature", func(t *testing.T) {
+		g := NewWithT(t)
+		v, err := NewSignatureVerifier(base64.StdEncoding.EncodeToString(ecdsaSig), publicKeyPEM(&ecdsaKey.PublicKey))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(v.Verify(checksum)).To(Succeed())
+		g.Expect(v.Verify(otherChecksum)).NotTo(Succeed())
+		g.Expect(v.Verify(library.Checksum{Algorithm: "SHA1", Checksum: "da39a3ee5e6b4b0d3255bfef95601890afd80709"})).NotTo(Succeed())
+	})
+
+	t.Run("RSA signature", func(t *testing.T) {
+		g := NewWithT(t)
+		v, err := NewSignatureVerifier(base64.StdEncoding.EncodeToString(rsaSig), publicKeyPEM(&rsaKey.PublicKey))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(v.Verify(checksum)).To(Succeed())
+		g.Expect(v.Verify(otherChecksum)).NotTo(Succeed())
+	})
+
+	t.Run("invalid signature or public key", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := NewSignatureVerifier("not base64!", publicKeyPEM(&ecdsaKey.PublicKey))
+		g.Expect(err).To(HaveOccurred())
+		_, err = NewSignatureVerifier(base64.StdEncoding.EncodeToString(ecdsaSig), []byte("not a PEM block"))
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("item files", func(t *testing.T) {
+		g := NewWithT(t)
+		verifiers := []ImageVerifier{ChecksumVerifier{Expected: infrav1.ImageChecksum{Algorithm: "SHA256", Value: checksum.Checksum}}}
+
+		verified, err := verifyItemFiles(verifiers, "ubuntu", []library.File{{Name: "ubuntu.ova", Checksum: &checksum}})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(FormatChecksum(verified)).To(Equal("SHA256:" + checksum.Checksum))
+
+		// A single file matching the checksum does not verify the other
+		// files of the item.
+		_, err = verifyItemFiles(verifiers, "ubuntu", []library.File{
+			{Name: "ubuntu.ova", Checksum: &checksum},
+			{Name: "ubuntu-disk1.vmdk", Checksum: &otherChecksum},
+		})
+		g.Expect(errors.Is(err, ErrImageVerificationFailed)).To(BeTrue())
+		g.Expect(err.Error()).To(ContainSubstring("ubuntu-disk1.vmdk"))
+		_, err = verifyItemFiles(verifiers, "ubuntu", []library.File{
+			{Name: "ubuntu.ova", Checksum: &checksum},
+			{Name: "ubuntu.ovf"},
+		})
+		g.Expect(errors.Is(err, ErrImageVerificationFailed)).To(BeTrue())
+		_, err = verifyItemFiles(verifiers, "ubuntu", nil)
+		g.Expect(errors.Is(err, ErrImageVerificationFailed)).To(BeTrue())
+	})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// GetVMInventoryEntry returns the inventory entry of a VSphereVM as observed
+// in vCenter. The host, datastores and power state are left empty when the VM
+// has no BIOS UUID yet or cannot be found.
+func GetVMInventoryEntry(ctx goctx.Context, s *session.Session, vsphereVM *infrav1.VSphereVM) (infrav1.VSphereVMInventoryEntry, error) {
+	entry := infrav1.VSphereVMInventoryEntry{
+		Name:    vsphereVM.Name,
+		UUID:    vsphereVM.Spec.BiosUUID,
+		IPAddrs: append([]string(nil), vsphereVM.Status.Addresses...),
+	}
+	if entry.UUID == "" {
+		return entry, nil
+	}
+
+	ref, err := s.FindByBIOSUUID(ctx, entry.UUID)
+	if err != nil {
+		return entry, errors.Wrapf(err, "failed to find vm %s by bios uuid %s", vsphereVM.Name, entry.UUID)
+	}
+	if ref == nil {
+		return entry, nil
+	}
+
+	var (
+		obj mo.VirtualMachine
+		pc  = property.DefaultCollector(s.Client.Client)
+	)
+	if err := pc.RetrieveOne(ctx, ref.Reference(), []string{"runtime.host", "runtime.powerState", "datastore"}, &obj); err != nil {
+		return entry, errors.Wrapf(err, "failed to retrieve properties of vm %s", vsphereVM.Name)
+	}
+
+	if powerState, err := toPowerState(obj.Runtime.PowerState); err == nil {
+		entry.PowerState = powerState
+	}
+
+	if obj.Runtime.Host != nil {
+		var host mo.HostSystem
+		if err := pc.RetrieveOne(ctx, *obj.Runtime.Host, []string{"name"}, &host); err != nil {
+			return entry, errors.Wrapf(err, "failed to retrieve the host of vm %s", vsphereVM.Name)
+		}
+		entry.Host = host.Name
+	}
+
+	if len(obj.Datastore) > 0 {
+		var datastores []mo.Datastore
+		if err := pc.Retrieve(ctx, obj.Datastore, []string{"name"}, &datastores); err != nil {
+			return entry, errors.Wrapf(err, "failed to retrieve the datastores of vm %s", vsphereVM.Name)
+		}
+		for _, datastore := range datastores {
+			entry.Datastores = append(entry.Datastores, datastore.Name)
+		}
+	}
+
+	return entry, nil
+}
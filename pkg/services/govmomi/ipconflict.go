@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/ipam"
+)
+
+// probeAddress sends an ICMP echo request to an IP address and returns true if
+// it is answered within the timeout. It is a variable so tests can replace it.
+var probeAddress = icmpProbe
+
+// reconcileIPConflicts checks that none of the static IP addresses of a VM,
+// set in its spec or claimed from IPAM pools, is in use before the VM is
+// cloned with them. An address is in use when vCenter reports it for a VM or
+// a host, or when it answers ICMP echo requests. The VM is not cloned while
+// any of its addresses is in use.
+func reconcileIPConflicts(ctx *context.VMContext) error {
+	var conflicts []string
+	for _, ip := range staticAddresses(ipam.ApplyState(ctx.VSphereVM.Spec.Network.Devices, ctx.IPAMState)) {
+		conflict, err := findIPConflict(ctx, ip)
+		if err != nil {
+			return err
+		}
+		if conflict != "" {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		msg := strings.Join(conflicts, "; ")
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.IPAddressUniqueCondition, infrav1.IPAddressConflictReason, clusterv1.ConditionSeverityWarning, "%s", msg)
+		return errors.Errorf("refusing to assign IP addresses in use: %s", msg)
+	}
+	conditions.MarkTrue(ctx.VSphereVM, infrav1.IPAddressUniqueCondition)
+	return nil
+}
+
+// findIPConflict returns a description of the machine using the IP address,
+// or an empty string if the address is not in use.
+func findIPConflict(ctx *context.VMContext, ip net.IP) (string, error) {
+	searchIndex := object.NewSearchIndex(ctx.Session.Client.Client)
+	for _, vmSearch := range []bool{true, false} {
+		refs, err := searchIndex.FindAllByIp(ctx, nil, ip.String(), vmSearch)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to search for IP address %s", ip)
+		}
+		if len(refs) == 0 {
+			continue
+		}
+		var entity mo.ManagedEntity
+		if err := property.DefaultCollector(ctx.Session.Client.Client).RetrieveOne(ctx, refs[0].Reference(), []string{"name"}, &entity); err != nil {
+			return "", errors.Wrapf(err, "unable to get the name of %s", refs[0].Reference())
+		}
+		kind := "VM"
+		if !vmSearch {
+			kind = "host"
+		}
+		return fmt.Sprintf("%s is in use by %s %s", ip, kind, entity.Name), nil
+	}
+
+	if ctx.IPConflictProbeTimeout <= 0 {
+		return "", nil
+	}
+	answered, err := probeAddress(ctx, ip, ctx.IPConflictProbeTimeout)
+	if err != nil {
+		// The manager may not be allowed to send ICMP requests, in which
+		// case the addresses are only checked against the inventory.
+		ctx.Logger.V(4).Info("unable to probe IP address", "ip", ip.String(), "error", err.Error())
+		return "", nil
+	}
+	if answered {
+		return fmt.Sprintf("%s answers ICMP echo requests", ip), nil
+	}
+	return "", nil
+}
+
+// staticAddresses returns the IP addresses of the network devices, whose
+// addresses are set in CIDR notation.
+func staticAddresses(devices []infrav1.NetworkDeviceSpec) []net.IP {
+	var ips []net.IP
+	for _, device := range devices {
+		for _, addr := range device.IPAddrs {
+			ip, _, err := net.ParseCIDR(addr)
+			if err != nil {
+				ip = net.ParseIP(addr)
+			}
+			if ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// icmpProbe sends an ICMP echo request to the IP address from an unprivileged
+// datagram socket, which requires the group of the manager to be allowed by
+// the net.ipv4.ping_group_range sysctl.
+func icmpProbe(ctx goctx.Context, ip net.IP, timeout time.Duration) (bool, error) {
+	network, address, protocol := "udp4", "0.0.0.0", 1
+	var requestType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	if ip.To4() == nil {
+		network, address, protocol = "udp6", "::", 58
+		requestType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to open ICMP socket")
+	}
+	defer conn.Close()
+
+	request, err := (&icmp.Message{
+		Type: requestType,
+		Body: &icmp.Echo{ID: os.Getpid() & 0xffff, Seq: 1, Data: []byte("capv-ip-conflict-probe")},
+	}).Marshal(nil)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to build ICMP echo request")
+	}
+	if _, err := conn.WriteTo(request, &net.UDPAddr{IP: ip}); err != nil {
+		return false, errors.Wrapf(err, "unable to send ICMP echo request to %s", ip)
+	}
+
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return false, errors.Wrap(err, "unable to set ICMP socket deadline")
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return false, nil
+			}
+			return false, errors.Wrapf(err, "unable to read ICMP echo reply from %s", ip)
+		}
+		reply, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil {
+			continue
+		}
+		if udpAddr, ok := peer.(*net.UDPAddr); ok && reply.Type == replyType && udpAddr.IP.Equal(ip) {
+			return true, nil
+		}
+	}
+}
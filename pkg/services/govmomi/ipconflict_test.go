@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/ipam"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+//nolint:forcetypeassert
+func TestReconcileIPConflicts(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	simulator.Map.WithLock(simulator.SpoofContext(), vm.Reference(), func() {
+		vm.Guest.IpAddress = "192.168.1.10"
+	})
+
+	answering := map[string]bool{}
+	probeAddress = func(_ goctx.Context, ip net.IP, _ time.Duration) (bool, error) {
+		return answering[ip.String()], nil
+	}
+	defer func() { probeAddress = icmpProbe }()
+
+	newContext := func(g *WithT, addrs ...string) *context.VMContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.IPConflictProbeTimeout = time.Second
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+		vmContext.VSphereVM.Spec.Network.Devices = []infrav1.NetworkDeviceSpec{{NetworkName: "VM Network", IPAddrs: addrs}}
+		return vmContext
+	}
+
+	t.Run("with unused addresses", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "192.168.1.20/24")
+		ctx.IPAMState = ipam.State{0: {{Address: "192.168.1.21/24"}}}
+
+		g.Expect(reconcileIPConflicts(ctx)).To(Succeed())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.IPAddressUniqueCondition)).To(BeTrue())
+	})
+
+	t.Run("with an address reported by vCenter for another VM", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "192.168.1.20/24")
+		ctx.IPAMState = ipam.State{0: {{Address: "192.168.1.10/24"}}}
+
+		g.Expect(reconcileIPConflicts(ctx)).NotTo(Succeed())
+		g.Expect(conditions.IsFalse(ctx.VSphereVM, infrav1.IPAddressUniqueCondition)).To(BeTrue())
+		condition := conditions.Get(ctx.VSphereVM, infrav1.IPAddressUniqueCondition)
+		g.Expect(condition.Reason).To(Equal(infrav1.IPAddressConflictReason))
+		g.Expect(condition.Message).To(Equal("192.168.1.10 is in use by VM " + vm.Name))
+	})
+
+	t.Run("with an address answering ICMP echo requests", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "192.168.1.30/24")
+		answering["192.168.1.30"] = true
+
+		g.Expect(reconcileIPConflicts(ctx)).NotTo(Succeed())
+		condition := conditions.Get(ctx.VSphereVM, infrav1.IPAddressUniqueCondition)
+		g.Expect(condition.Message).To(Equal("192.168.1.30 answers ICMP echo requests"))
+
+		// The addresses are not probed without timeout.
+		ctx.IPConflictProbeTimeout = 0
+		g.Expect(reconcileIPConflicts(ctx)).To(Succeed())
+	})
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"context"
+	"path"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// ClusterTagCategory is the category of the tags naming the cluster of
+	// the VMs created by CAPV.
+	ClusterTagCategory = "capv-cluster"
+
+	// NamespaceTagCategory is the category of the tags naming the namespace
+	// of the cluster of the VMs created by CAPV.
+	NamespaceTagCategory = "capv-namespace"
+
+	// MachineRoleTagCategory is the category of the tags naming the role of
+	// the machines of the VMs created by CAPV.
+	MachineRoleTagCategory = "capv-machine-role"
+
+	// ControlPlaneRoleTag is the role tag of the VMs of control plane machines.
+	ControlPlaneRoleTag = "control-plane"
+
+	// WorkerRoleTag is the role tag of the VMs of the other machines.
+	WorkerRoleTag = "worker"
+)
+
+// managedTagAssociableTypes are the types of the objects CAPV attaches the
+// managed tags to: the VMs it creates, and their folders and resource pools.
+var managedTagAssociableTypes = []string{"VirtualMachine", "Folder", "ResourcePool"}
+
+// managedTagIDs caches the IDs of the managed tags, keyed by vCenter,
+// category and tag, so the categories and tags are only looked up once.
+var managedTagIDs sync.Map
+
+// ClusterTagName returns the name of the managed tag of a cluster. Cluster
+// names are only unique within a namespace, so the name includes the
+// namespace of the cluster, e.g. "default/my-cluster".
+func ClusterTagName(namespace, name string) string {
+	return path.Join(namespace, name)
+}
+
+// EnsureManagedTag returns the ID of the tag of a managed category, creating
+// the category and the tag if they do not exist yet. A VM has at most one
+// tag of each managed category.
+func EnsureManagedTag(ctx context.Context, manager *tags.Manager, categoryName, tagName string) (string, error) {
+	key := managedTagKey(manager, categoryName, tagName)
+	if id, ok := managedTagIDs.Load(key); ok {
+		return id.(string), nil
+	}
+
+	logger := ctrl.LoggerFrom(ctx, "category", categoryName, "tag", tagName)
+	var categoryID string
+	category, err := manager.GetCategory(ctx, categoryName)
+	if err != nil {
+		logger.V(4).Info("failed to find existing category, creating a new category")
+		categoryID, err = manager.CreateCategory(ctx, &tags.Category{
+			Name:            categoryName,
+			Description:     "CAPV generated category for managed tags",
+			AssociableTypes: managedTagAssociableTypes,
+			Cardinality:     "SINGLE",
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create tag category %q", categoryName)
+		}
+	} else {
+		if err := widenManagedCategory(ctx, manager, category); err != nil {
+			return "", err
+		}
+		categoryID = category.ID
+	}
+
+	var tagID string
+	tag, err := manager.GetTagForCategory(ctx, tagName, categoryID)
+	if err != nil {
+		logger.V(4).Info("failed to find existing tag, creating a new tag")
+		tagID, err = manager.CreateTag(ctx, &tags.Tag{
+			Description: "CAPV generated tag for managed tags",
+			Name:        tagName,
+			CategoryID:  categoryID,
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create tag %q in category %q", tagName, categoryName)
+		}
+	} else {
+		tagID = tag.ID
+	}
+
+	managedTagIDs.Store(key, tagID)
+	return tagID, nil
+}
+
+// ForgetManagedTag removes the ID of a managed tag from the cache, e.g. when
+// the tag could not be attached because it was deleted in vCenter.
+func ForgetManagedTag(manager *tags.Manager, categoryName, tagName string) {
+	managedTagIDs.Delete(managedTagKey(manager, categoryName, tagName))
+}
+
+// DeleteManagedTag deletes the tag of a managed category. Nothing is deleted
+// if the category or the tag does not exist.
+func DeleteManagedTag(ctx context.Context, manager *tags.Manager, categoryName, tagName string) error {
+	ForgetManagedTag(manager, categoryName, tagName)
+
+	logger := ctrl.LoggerFrom(ctx, "category", categoryName, "tag", tagName)
+	category, err := manager.GetCategory(ctx, categoryName)
+	if err != nil {
+		logger.V(4).Info("failed to find existing category, skipping the deletion of the tag")
+		return nil
+	}
+	tag, err := manager.GetTagForCategory(ctx, tagName, category.ID)
+	if err != nil {
+		logger.V(4).Info("failed to find existing tag, skipping its deletion")
+		return nil
+	}
+	if err := manager.DeleteTag(ctx, tag); err != nil {
+		return errors.Wrapf(err, "failed to delete tag %q of category %q", tagName, categoryName)
+	}
+	return nil
+}
+
+// DetachManagedTag detaches the tag of a managed category from an object.
+// Nothing is detached if the category or the tag does not exist.
+func DetachManagedTag(ctx context.Context, manager *tags.Manager, categoryName, tagName string, ref mo.Reference) error {
+	logger := ctrl.LoggerFrom(ctx, "category", categoryName, "tag", tagName)
+	category, err := manager.GetCategory(ctx, categoryName)
+	if err != nil {
+		logger.V(4).Info("failed to find existing category, skipping the detachment of the tag")
+		return nil
+	}
+	tag, err := manager.GetTagForCategory(ctx, tagName, category.ID)
+	if err != nil {
+		logger.V(4).Info("failed to find existing tag, skipping its detachment")
+		return nil
+	}
+	if err := manager.DetachTag(ctx, tag.ID, ref); err != nil {
+		return errors.Wrapf(err, "failed to detach tag %q of category %q from %s", tagName, categoryName, ref.Reference())
+	}
+	return nil
+}
+
+// widenManagedCategory appends the missing managed tag associable types to a
+// managed category, e.g. one created by an older release for the VMs only.
+// vCenter only allows the associable types of a category to be appended to,
+// and a category without associable types is associable with any object.
+func widenManagedCategory(ctx context.Context, manager *tags.Manager, category *tags.Category) error {
+	if len(category.AssociableTypes) == 0 {
+		return nil
+	}
+	associableTypes := append([]string{}, category.AssociableTypes...)
+	for _, t := range managedTagAssociableTypes {
+		found := false
+		for _, existing := range category.AssociableTypes {
+			if existing == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			associableTypes = append(associableTypes, t)
+		}
+	}
+	if len(associableTypes) == len(category.AssociableTypes) {
+		return nil
+	}
+
+	ctrl.LoggerFrom(ctx, "category", category.Name).V(4).Info("widening the associable types of the category", "associableTypes", associableTypes)
+	category.AssociableTypes = associableTypes
+	if err := manager.UpdateCategory(ctx, category); err != nil {
+		return errors.Wrapf(err, "failed to update the associable types of tag category %q", category.Name)
+	}
+	return nil
+}
+
+func managedTagKey(manager *tags.Manager, categoryName, tagName string) string {
+	return path.Join(manager.URL().Host, categoryName, tagName)
+}
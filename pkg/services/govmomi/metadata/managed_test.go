@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+
+	// run init func to register the tagging API endpoints.
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+func TestManagedTags(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	g.Expect(model.Create()).To(Succeed())
+	t.Cleanup(model.Remove)
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	t.Cleanup(server.Close)
+	pass, _ := server.URL.User.Password()
+
+	s, err := session.GetOrCreate(context.TODO(), session.NewParams().
+		WithServer(server.URL.Host).
+		WithUserInfo(server.URL.User.Username(), pass).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	manager := s.TagManager
+
+	tagName := ClusterTagName("default", "my-cluster")
+	g.Expect(tagName).To(Equal("default/my-cluster"))
+
+	// The category and the tag are created on demand.
+	tagID, err := EnsureManagedTag(context.TODO(), manager, ClusterTagCategory, tagName)
+	g.Expect(err).NotTo(HaveOccurred())
+	category, err := manager.GetCategory(context.TODO(), ClusterTagCategory)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(category.Cardinality).To(Equal("SINGLE"))
+	g.Expect(category.AssociableTypes).To(ConsistOf("VirtualMachine", "Folder", "ResourcePool"))
+	tag, err := manager.GetTagForCategory(context.TODO(), tagName, category.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tag.ID).To(Equal(tagID))
+
+	// Existing tags are reused.
+	ForgetManagedTag(manager, ClusterTagCategory, tagName)
+	g.Expect(EnsureManagedTag(context.TODO(), manager, ClusterTagCategory, tagName)).To(Equal(tagID))
+	otherID, err := EnsureManagedTag(context.TODO(), manager, ClusterTagCategory, ClusterTagName("default", "other-cluster"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(otherID).NotTo(Equal(tagID))
+
+	// Deleting a tag is idempotent and keeps the other tags of the category.
+	g.Expect(DeleteManagedTag(context.TODO(), manager, ClusterTagCategory, tagName)).To(Succeed())
+	g.Expect(DeleteManagedTag(context.TODO(), manager, ClusterTagCategory, tagName)).To(Succeed())
+	g.Expect(DeleteManagedTag(context.TODO(), manager, MachineRoleTagCategory, WorkerRoleTag)).To(Succeed())
+	_, err = manager.GetTagForCategory(context.TODO(), tagName, category.ID)
+	g.Expect(err).To(HaveOccurred())
+	_, err = manager.GetTag(context.TODO(), otherID)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// Detaching a tag which does not exist is a no-op.
+	vmRef := types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-42"}
+	g.Expect(DetachManagedTag(context.TODO(), manager, ClusterTagCategory, tagName, vmRef)).To(Succeed())
+	g.Expect(DetachManagedTag(context.TODO(), manager, MachineRoleTagCategory, WorkerRoleTag, vmRef)).To(Succeed())
+
+	// A category created for the VMs only is widened to the folders and the
+	// resource pools.
+	_, err = manager.CreateCategory(context.TODO(), &tags.Category{
+		Name:            NamespaceTagCategory,
+		AssociableTypes: []string{"VirtualMachine"},
+		Cardinality:     "SINGLE",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = EnsureManagedTag(context.TODO(), manager, NamespaceTagCategory, "default")
+	g.Expect(err).NotTo(HaveOccurred())
+	category, err = manager.GetCategory(context.TODO(), NamespaceTagCategory)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(category.AssociableTypes).To(Equal([]string{"VirtualMachine", "Folder", "ResourcePool"}))
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+)
+
+// reconcileNetworkDevices hot-adds a NIC for each of the network devices
+// added to the VSphereVM once its VM is ready. The NICs of the VM match the
+// devices of the VSphereVM by their index, so the devices beyond the NICs of
+// the VM are the added ones. The metadata of the VM is updated with the added
+// devices once their MAC addresses are reported, so the guest configures them
+// when it handles the hotplug of the NICs. It returns true once the VM has a
+// NIC for every device.
+func (vms *VMService) reconcileNetworkDevices(ctx *virtualMachineContext) (bool, error) {
+	if !feature.Gates.Enabled(feature.NetworkDeviceHotAdd) || !ctx.VSphereVM.Status.Ready {
+		return true, nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.hardware.device"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "failed to get the devices of vm %s", ctx)
+	}
+	if obj.Config == nil {
+		return true, nil
+	}
+	nics := len(object.VirtualDeviceList(obj.Config.Hardware.Device).SelectByType((*types.VirtualEthernetCard)(nil)))
+	devices := ctx.VSphereVM.Spec.Network.Devices
+	if len(devices) <= nics {
+		if len(devices) < nics {
+			ctx.Logger.V(4).Info("network devices removed from the VSphereVM are not removed from the VM", "devices", len(devices), "nics", nics)
+		}
+		return true, nil
+	}
+
+	added := devices[nics:]
+	deviceSpecs, err := vcenter.NetworkDeviceAddSpecs(&ctx.VMContext, added)
+	if err != nil {
+		return false, err
+	}
+	for _, deviceSpec := range deviceSpecs {
+		deviceSpec.GetVirtualDeviceConfigSpec().Device.GetVirtualDevice().Connectable = &types.VirtualDeviceConnectInfo{
+			StartConnected:    true,
+			Connected:         true,
+			AllowGuestControl: true,
+		}
+	}
+
+	if err := blockedBySnapshots(ctx); err != nil {
+		ctx.Logger.Info("not hot-adding network devices", "reason", err.Error())
+		return true, nil
+	}
+	if !acquireTaskSlot(&ctx.VMContext, throttle.Reconfigure) {
+		return false, nil
+	}
+	ctx.Logger.Info("hot-adding network devices", "devices", len(added))
+	task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{DeviceChange: deviceSpecs})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to hot-add the network devices of vm %s", ctx)
+	}
+	for _, device := range added {
+		ctx.Recorder.Eventf(ctx.VSphereVM, "NetworkDeviceAdded", "Adding a network device on network %s to VM %s", device.NetworkName, ctx.VSphereVM.Name)
+	}
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	return false, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestReconcileNetworkDevices(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	newContext := func(g *WithT, name string) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Status.Ready = true
+		vmContext.VSphereVM.Spec.Network.Devices = append(vmContext.VSphereVM.Spec.Network.Devices, infrav1.NetworkDeviceSpec{
+			NetworkName: "VM Network",
+			DHCP4:       true,
+		})
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+	nics := func(g *WithT, ctx *virtualMachineContext) object.VirtualDeviceList {
+		var obj mo.VirtualMachine
+		g.Expect(ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.hardware.device"}, &obj)).To(Succeed())
+		return object.VirtualDeviceList(obj.Config.Hardware.Device).SelectByType((*types.VirtualEthernetCard)(nil))
+	}
+
+	t.Run("does not change the NICs when the gate is disabled", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM0")
+
+		g.Expect((&VMService{}).reconcileNetworkDevices(ctx)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(nics(g, ctx)).To(HaveLen(1))
+	})
+
+	t.Run("hot-adds the NICs of the added devices", func(t *testing.T) {
+		defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.NetworkDeviceHotAdd, true)()
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM1")
+		vms := &VMService{}
+
+		g.Expect(vms.reconcileNetworkDevices(ctx)).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+		task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: morefTypeTask, Value: ctx.VSphereVM.Status.TaskRef})
+		g.Expect(task.Wait(ctx)).To(Succeed())
+
+		devices := nics(g, ctx)
+		g.Expect(devices).To(HaveLen(2))
+		connectable := devices[1].GetVirtualDevice().Connectable
+		g.Expect(connectable).NotTo(BeNil())
+		g.Expect(connectable.Connected).To(BeTrue())
+		g.Expect(connectable.StartConnected).To(BeTrue())
+
+		ctx.VSphereVM.Status.TaskRef = ""
+		g.Expect(vms.reconcileNetworkDevices(ctx)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+	})
+
+	t.Run("waits for a reconfigure slot of the vCenter", func(t *testing.T) {
+		defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.NetworkDeviceHotAdd, true)()
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM1")
+		ctx.TaskThrottle = throttle.New(throttle.Limits{throttle.Reconfigure: 1})
+		g.Expect(ctx.TaskThrottle.Acquire(ctx.VSphereVM.Spec.Server, throttle.Reconfigure, "ns/other", "other")).To(BeTrue())
+
+		g.Expect((&VMService{}).reconcileNetworkDevices(ctx)).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(ctx.TaskThrottle.Waiting(ctx.VSphereVM.Spec.Server, string(ctx.VSphereVM.UID))).To(BeTrue())
+		g.Expect(nics(g, ctx)).To(HaveLen(1))
+	})
+
+	t.Run("does not hot-add NICs before the VM is ready", func(t *testing.T) {
+		defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.NetworkDeviceHotAdd, true)()
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM0")
+		ctx.VSphereVM.Status.Ready = false
+
+		g.Expect((&VMService{}).reconcileNetworkDevices(ctx)).To(BeTrue())
+		g.Expect(nics(g, ctx)).To(HaveLen(1))
+	})
+}
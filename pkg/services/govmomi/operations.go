@@ -0,0 +1,285 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterutilv1 "sigs.k8s.io/cluster-api/util"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+// reconcileOperation processes the operation requested with the operation
+// annotation of the VSphereVM, and reports its result in the status of the
+// VSphereVM. The annotation is removed once the operation is processed,
+// whether it succeeded or not, so the operation is not processed again. An
+// operation issuing a task, i.e. a relocation, is in progress until its task
+// completes, and no other operation is processed meanwhile. It returns false
+// when a task was issued or is waiting for a slot of the vCenter, so that no
+// other task of the VM is issued by the same reconcile.
+func (vms *VMService) reconcileOperation(ctx *virtualMachineContext) bool {
+	if status := ctx.VSphereVM.Status.Operation; status != nil && status.Result == infrav1.OperationInProgress {
+		completeOperation(ctx, status)
+		return true
+	}
+
+	value, ok := ctx.VSphereVM.Annotations[infrav1.VMOperationAnnotation]
+	if !ok {
+		return true
+	}
+	operation := infrav1.VirtualMachineOperation(value)
+	ctx.Logger.Info("processing operation", "operation", operation)
+
+	var message string
+	var started bool
+	var err error
+	switch operation {
+	case infrav1.RestartOperation:
+		message, err = vms.restartVM(ctx)
+	case infrav1.RecloneOperation:
+		message, err = vms.requestReclone(ctx)
+	case infrav1.RecustomizeOperation:
+		if err = blockedBySnapshots(ctx); err == nil {
+			message, err = vms.recustomizeVM(ctx)
+		}
+	case infrav1.RelocateOperation:
+		if err = blockedBySnapshots(ctx); err == nil {
+			if message, started, err = vms.relocateVM(ctx); err == nil && !started {
+				return false
+			}
+		}
+	default:
+		err = errors.Errorf("unknown operation %q", operation)
+	}
+
+	status := &infrav1.VirtualMachineOperationStatus{
+		Operation:     operation,
+		Result:        infrav1.OperationSucceeded,
+		Message:       message,
+		ProcessedTime: metav1.Now(),
+	}
+	delete(ctx.VSphereVM.Annotations, infrav1.VMOperationAnnotation)
+	switch {
+	case err != nil:
+		ctx.Logger.Error(err, "operation failed", "operation", operation)
+		ctx.Recorder.Warnf(ctx.VSphereVM, "OperationFailed", "operation %s failed: %v", operation, err)
+		status.Result = infrav1.OperationFailed
+		status.Message = err.Error()
+	case started:
+		// The relocate annotations are kept until the task completes, to
+		// report its result.
+		status.Result = infrav1.OperationInProgress
+		status.TaskRef = ctx.VSphereVM.Status.TaskRef
+		ctx.VSphereVM.Status.Operation = status
+		return false
+	default:
+		ctx.Recorder.Eventf(ctx.VSphereVM, "OperationSucceeded", "operation %s succeeded: %s", operation, message)
+	}
+	ctx.VSphereVM.Status.Operation = status
+	delete(ctx.VSphereVM.Annotations, infrav1.VMRelocateHostAnnotation)
+	delete(ctx.VSphereVM.Annotations, infrav1.VMRelocateDatastoreAnnotation)
+	return true
+}
+
+// completeOperation reports the result of the task of the operation in
+// progress, which is no longer in flight since reconcileOperation is only
+// called once the task of the VM completed. The result of a task which is no
+// longer known by vCenter is reported as failed.
+func completeOperation(ctx *virtualMachineContext, status *infrav1.VirtualMachineOperationStatus) {
+	var task mo.Task
+	ref := types.ManagedObjectReference{Type: morefTypeTask, Value: status.TaskRef}
+	var err error
+	if err = ctx.Session.RetrieveOne(ctx, ref, []string{"info"}, &task); err != nil {
+		err = errors.Wrapf(err, "unable to get the result of task %s", status.TaskRef)
+	} else {
+		switch task.Info.State {
+		case types.TaskInfoStateSuccess:
+		case types.TaskInfoStateError:
+			err = errors.Errorf("task %s failed: %s", status.TaskRef, taskError(&task))
+		default:
+			return
+		}
+	}
+
+	status.TaskRef = ""
+	status.ProcessedTime = metav1.Now()
+	if err != nil {
+		err = errors.Wrapf(err, "failed to relocate %s", ctx)
+		ctx.Logger.Error(err, "operation failed", "operation", status.Operation)
+		ctx.Recorder.Warnf(ctx.VSphereVM, "OperationFailed", "operation %s failed: %v", status.Operation, err)
+		status.Result = infrav1.OperationFailed
+		status.Message = err.Error()
+	} else {
+		status.Result = infrav1.OperationSucceeded
+		status.Message = fmt.Sprintf("the VM was relocated to %s", relocationTargets(ctx))
+		ctx.Recorder.Eventf(ctx.VSphereVM, "OperationSucceeded", "operation %s succeeded: %s", status.Operation, status.Message)
+	}
+	delete(ctx.VSphereVM.Annotations, infrav1.VMRelocateHostAnnotation)
+	delete(ctx.VSphereVM.Annotations, infrav1.VMRelocateDatastoreAnnotation)
+}
+
+// restartVM resets the VM.
+func (vms *VMService) restartVM(ctx *virtualMachineContext) (string, error) {
+	task, err := ctx.Obj.Reset(ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to reset %s", ctx)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return "", errors.Wrapf(err, "failed to reset %s", ctx)
+	}
+	return "the VM was reset", nil
+}
+
+// recustomizeVM sets the current bootstrap data and cloud-init metadata of
+// the VM, then resets it so that its guest reads them at boot.
+func (vms *VMService) recustomizeVM(ctx *virtualMachineContext) (string, error) {
+	bootstrapData, err := vms.getBootstrapData(&ctx.VMContext)
+	if err != nil {
+		return "", err
+	}
+	if len(bootstrapData) == 0 {
+		return "", errors.Errorf("%s has no bootstrap data", ctx)
+	}
+	metadata, err := vms.getMachineMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var extraConfig extra.Config
+	if err := extraConfig.SetCloudInitMetadata(metadata); err != nil {
+		return "", errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
+	}
+	if err := extraConfig.SetBootstrapData(ctx.VSphereVM.Spec.BootstrapFormat, bootstrapData); err != nil {
+		return "", errors.Wrapf(err, "unable to set bootstrap data on vm %s", ctx)
+	}
+	task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		ExtraConfig: extraConfig,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to set extra config on vm %s", ctx)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return "", errors.Wrapf(err, "unable to set extra config on vm %s", ctx)
+	}
+
+	if _, err := vms.restartVM(ctx); err != nil {
+		return "", err
+	}
+	return "the bootstrap data and the metadata were set and the VM was reset", nil
+}
+
+// relocateVM starts the migration of the VM to the host and/or the datastore
+// set in the relocate annotations of the VSphereVM, tracked by the TaskRef of
+// the VSphereVM, and returns whether the task was started, which waits for a
+// slot of the vCenter otherwise. The VM stays in its resource pool, so the
+// host must be in the compute cluster of the VM.
+func (vms *VMService) relocateVM(ctx *virtualMachineContext) (string, bool, error) {
+	hostName := ctx.VSphereVM.Annotations[infrav1.VMRelocateHostAnnotation]
+	datastoreName := ctx.VSphereVM.Annotations[infrav1.VMRelocateDatastoreAnnotation]
+	if hostName == "" && datastoreName == "" {
+		return "", false, errors.Errorf("%s has neither the %s nor the %s annotation", ctx,
+			infrav1.VMRelocateHostAnnotation, infrav1.VMRelocateDatastoreAnnotation)
+	}
+
+	var spec types.VirtualMachineRelocateSpec
+	if hostName != "" {
+		host, err := ctx.Session.Finder.HostSystem(ctx, hostName)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "unable to find host %q", hostName)
+		}
+		hostRef := host.Reference()
+		spec.Host = &hostRef
+	}
+	if datastoreName != "" {
+		datastore, err := ctx.Session.FindDatastore(ctx, datastoreName)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "unable to find datastore %q", datastoreName)
+		}
+		datastoreRef := datastore.Reference()
+		spec.Datastore = &datastoreRef
+	}
+
+	if !acquireTaskSlot(&ctx.VMContext, throttle.Reconfigure) {
+		return "", false, nil
+	}
+	task, err := ctx.Obj.Relocate(ctx, spec, types.VirtualMachineMovePriorityDefaultPriority)
+	if err != nil {
+		releaseTaskSlot(&ctx.VMContext)
+		return "", false, errors.Wrapf(err, "failed to relocate %s", ctx)
+	}
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	return fmt.Sprintf("the VM is being relocated to %s", relocationTargets(ctx)), true, nil
+}
+
+// relocationTargets describes the host and/or the datastore set in the
+// relocate annotations of the VSphereVM.
+func relocationTargets(ctx *virtualMachineContext) string {
+	var targets []string
+	if hostName := ctx.VSphereVM.Annotations[infrav1.VMRelocateHostAnnotation]; hostName != "" {
+		targets = append(targets, fmt.Sprintf("host %s", hostName))
+	}
+	if datastoreName := ctx.VSphereVM.Annotations[infrav1.VMRelocateDatastoreAnnotation]; datastoreName != "" {
+		targets = append(targets, fmt.Sprintf("datastore %s", datastoreName))
+	}
+	return strings.Join(targets, " and ")
+}
+
+// requestReclone deletes the Machine of the VM, with the MachineReclone
+// feature gate. The MachineSet or the KubeadmControlPlane which owns the
+// Machine then creates a new Machine, whose VM is cloned again.
+func (vms *VMService) requestReclone(ctx *virtualMachineContext) (string, error) {
+	if !feature.Gates.Enabled(feature.MachineReclone) {
+		return "", errors.Errorf("the %s operation requires the %s feature gate", infrav1.RecloneOperation, feature.MachineReclone)
+	}
+	vsphereMachine, err := util.GetOwnerVSphereMachine(ctx, ctx.Client, ctx.VSphereVM.ObjectMeta)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the VSphereMachine of %s", ctx)
+	}
+	if vsphereMachine == nil {
+		return "", errors.Errorf("%s is not owned by a VSphereMachine", ctx)
+	}
+	machine, err := clusterutilv1.GetOwnerMachine(ctx, ctx.Client, vsphereMachine.ObjectMeta)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the Machine of %s", ctx)
+	}
+	if machine == nil {
+		return "", errors.Errorf("%s is not owned by a Machine", ctx)
+	}
+	owner := metav1.GetControllerOf(machine)
+	if owner == nil {
+		return "", errors.Errorf("Machine %s/%s has no owner to replace it", machine.Namespace, machine.Name)
+	}
+
+	if !machine.DeletionTimestamp.IsZero() {
+		return fmt.Sprintf("Machine %s is already being deleted", machine.Name), nil
+	}
+	if err := ctx.Client.Delete(ctx, machine); err != nil && !apierrors.IsNotFound(err) {
+		return "", errors.Wrapf(err, "failed to delete Machine %s/%s", machine.Namespace, machine.Name)
+	}
+	return fmt.Sprintf("Machine %s was deleted, to be replaced by %s %s", machine.Name, owner.Kind, owner.Name), nil
+}
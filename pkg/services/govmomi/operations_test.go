@@ -0,0 +1,227 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestReconcileOperation(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      "machine-0",
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(&clusterv1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "machineset-0", UID: "machineset-0"}}, clusterv1.GroupVersion.WithKind("MachineSet")),
+			},
+		},
+	}
+	vsphereMachine := &infrav1.VSphereMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fake.Namespace,
+			Name:      "machine-0",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: clusterv1.GroupVersion.String(), Kind: "Machine", Name: machine.Name},
+			},
+		},
+	}
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "machine-0-bootstrap"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config\nruncmd: []\n")},
+	}
+
+	newContext := func(g *WithT, operation infrav1.VirtualMachineOperation) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext(machine.DeepCopy(), vsphereMachine.DeepCopy(), bootstrapSecret.DeepCopy())))
+		vmContext.VSphereVM.Annotations = map[string]string{infrav1.VMOperationAnnotation: string(operation)}
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+		g.Expect(err).NotTo(HaveOccurred())
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+
+	t.Run("restarts the VM", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.RestartOperation)
+
+		(&VMService{}).reconcileOperation(ctx)
+		g.Expect(ctx.VSphereVM.Annotations).NotTo(HaveKey(infrav1.VMOperationAnnotation))
+		g.Expect(ctx.VSphereVM.Status.Operation).NotTo(BeNil())
+		g.Expect(ctx.VSphereVM.Status.Operation.Operation).To(Equal(infrav1.RestartOperation))
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationSucceeded))
+	})
+
+	t.Run("sets the bootstrap data of the VM again", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.RecustomizeOperation)
+		ctx.VSphereVM.Spec.BootstrapRef = &corev1.ObjectReference{Namespace: bootstrapSecret.Namespace, Name: bootstrapSecret.Name}
+
+		(&VMService{}).reconcileOperation(ctx)
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationSucceeded), ctx.VSphereVM.Status.Operation.Message)
+
+		var obj mo.VirtualMachine
+		g.Expect(ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.extraConfig"}, &obj)).To(Succeed())
+		var expected extra.Config
+		g.Expect(expected.SetCloudInitUserData(bootstrapSecret.Data["value"])).To(Succeed())
+		g.Expect(changedOptionValues(obj.Config.ExtraConfig, expected)).To(BeEmpty())
+	})
+
+	t.Run("deletes the Machine", func(t *testing.T) {
+		defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.MachineReclone, true)()
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.RecloneOperation)
+		ctx.VSphereVM.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: infrav1.GroupVersion.String(), Kind: "VSphereMachine", Name: vsphereMachine.Name},
+		}
+
+		(&VMService{}).reconcileOperation(ctx)
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationSucceeded), ctx.VSphereVM.Status.Operation.Message)
+		g.Expect(ctx.VSphereVM.Status.Operation.Message).To(ContainSubstring("MachineSet machineset-0"))
+
+		err := ctx.Client.Get(ctx, client.ObjectKeyFromObject(machine), &clusterv1.Machine{})
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	t.Run("fails to reclone without the feature gate", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.RecloneOperation)
+		ctx.VSphereVM.OwnerReferences = []metav1.OwnerReference{
+			{APIVersion: infrav1.GroupVersion.String(), Kind: "VSphereMachine", Name: vsphereMachine.Name},
+		}
+
+		(&VMService{}).reconcileOperation(ctx)
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationFailed))
+		g.Expect(ctx.VSphereVM.Status.Operation.Message).To(ContainSubstring("MachineReclone feature gate"))
+		g.Expect(ctx.Client.Get(ctx, client.ObjectKeyFromObject(machine), &clusterv1.Machine{})).To(Succeed())
+	})
+
+	t.Run("fails to reclone a VSphereVM without a Machine", func(t *testing.T) {
+		defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.MachineReclone, true)()
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.RecloneOperation)
+
+		(&VMService{}).reconcileOperation(ctx)
+		g.Expect(ctx.VSphereVM.Annotations).NotTo(HaveKey(infrav1.VMOperationAnnotation))
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationFailed))
+		g.Expect(ctx.VSphereVM.Status.Operation.Message).To(ContainSubstring("is not owned by a VSphereMachine"))
+	})
+
+	t.Run("relocates the VM to another host", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.RelocateOperation)
+		obj, err := ctx.Session.Finder.VirtualMachine(ctx, "DC0_C0_RP0_VM0")
+		g.Expect(err).NotTo(HaveOccurred())
+		ctx.Obj, ctx.Ref = obj, obj.Reference()
+		ctx.VSphereVM.Annotations[infrav1.VMRelocateHostAnnotation] = "DC0_C0_H1"
+		ctx.VSphereVM.Annotations[infrav1.VMRelocateDatastoreAnnotation] = "LocalDS_0"
+
+		// The relocation is tracked by the task of the VSphereVM, instead of
+		// being waited for.
+		g.Expect((&VMService{}).reconcileOperation(ctx)).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationInProgress), ctx.VSphereVM.Status.Operation.Message)
+		g.Expect(ctx.VSphereVM.Status.Operation.Message).To(Equal("the VM is being relocated to host DC0_C0_H1 and datastore LocalDS_0"))
+		g.Expect(ctx.VSphereVM.Status.Operation.TaskRef).NotTo(BeEmpty())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(Equal(ctx.VSphereVM.Status.Operation.TaskRef))
+		g.Expect(ctx.VSphereVM.Annotations).NotTo(HaveKey(infrav1.VMOperationAnnotation))
+
+		task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: morefTypeTask, Value: ctx.VSphereVM.Status.TaskRef})
+		g.Expect(task.Wait(ctx)).To(Succeed())
+		ctx.VSphereVM.Status.TaskRef = ""
+
+		// The result of the task is reported once it completed.
+		g.Expect((&VMService{}).reconcileOperation(ctx)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationSucceeded), ctx.VSphereVM.Status.Operation.Message)
+		g.Expect(ctx.VSphereVM.Status.Operation.Message).To(Equal("the VM was relocated to host DC0_C0_H1 and datastore LocalDS_0"))
+		g.Expect(ctx.VSphereVM.Status.Operation.TaskRef).To(BeEmpty())
+		g.Expect(ctx.VSphereVM.Annotations).To(BeEmpty())
+
+		host, err := ctx.Session.Finder.HostSystem(ctx, "DC0_C0_H1")
+		g.Expect(err).NotTo(HaveOccurred())
+		var vm mo.VirtualMachine
+		g.Expect(ctx.Obj.Properties(ctx, ctx.Ref, []string{"runtime.host"}, &vm)).To(Succeed())
+		g.Expect(*vm.Runtime.Host).To(Equal(host.Reference()))
+	})
+
+	t.Run("reports the relocation whose task is no longer known as failed", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.RelocateOperation)
+		delete(ctx.VSphereVM.Annotations, infrav1.VMOperationAnnotation)
+		ctx.VSphereVM.Status.Operation = &infrav1.VirtualMachineOperationStatus{
+			Operation: infrav1.RelocateOperation,
+			Result:    infrav1.OperationInProgress,
+			TaskRef:   "task-missing",
+		}
+
+		g.Expect((&VMService{}).reconcileOperation(ctx)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationFailed))
+		g.Expect(ctx.VSphereVM.Status.Operation.Message).To(ContainSubstring("unable to get the result of task task-missing"))
+	})
+
+	t.Run("fails to relocate the VM without a host nor a datastore", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.RelocateOperation)
+
+		(&VMService{}).reconcileOperation(ctx)
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationFailed))
+		g.Expect(ctx.VSphereVM.Status.Operation.Message).To(ContainSubstring("has neither the"))
+	})
+
+	t.Run("fails an unknown operation", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "rebuild")
+
+		(&VMService{}).reconcileOperation(ctx)
+		g.Expect(ctx.VSphereVM.Annotations).NotTo(HaveKey(infrav1.VMOperationAnnotation))
+		g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationFailed))
+		g.Expect(ctx.VSphereVM.Status.Operation.Message).To(Equal(`unknown operation "rebuild"`))
+	})
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/metadata"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// ManagedVM is a VM tagged with the managed tag of a cluster.
+type ManagedVM struct {
+	Ref              types.ManagedObjectReference
+	Name             string
+	BiosUUID         string
+	ClusterNamespace string
+	ClusterName      string
+
+	// ManagementClusterID is the ID of the management cluster which cloned
+	// the VM, empty for the VMs cloned before it was recorded.
+	ManagementClusterID string
+}
+
+// ListManagedVMs returns the VMs tagged with the managed tag of a cluster. No
+// VM is returned if the category of the managed tags does not exist.
+func ListManagedVMs(ctx goctx.Context, s *session.Session) ([]ManagedVM, error) {
+	logger := ctrl.LoggerFrom(ctx, "category", metadata.ClusterTagCategory)
+	// The categories are listed rather than got by name, so that a vCenter
+	// which cannot be reached is not taken for a missing category.
+	categories, err := s.TagManager.GetCategories(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the tag categories")
+	}
+	var categoryID string
+	for _, category := range categories {
+		if category.Name == metadata.ClusterTagCategory {
+			categoryID = category.ID
+		}
+	}
+	if categoryID == "" {
+		logger.V(4).Info("failed to find existing category, skipping the listing of the managed VMs")
+		return nil, nil
+	}
+	clusterTags, err := s.TagManager.GetTagsForCategory(ctx, categoryID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the tags of category %q", metadata.ClusterTagCategory)
+	}
+
+	var (
+		managedVMs []ManagedVM
+		pc         = property.DefaultCollector(s.Client.Client)
+	)
+	for _, tag := range clusterTags {
+		// The name of the tag is the namespace and the name of the cluster.
+		parts := strings.Split(tag.Name, "/")
+		if len(parts) != 2 {
+			logger.V(4).Info("skipping tag which does not name a cluster", "tag", tag.Name)
+			continue
+		}
+
+		attached, err := s.TagManager.ListAttachedObjects(ctx, tag.ID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the objects tagged with %q", tag.Name)
+		}
+		var refs []types.ManagedObjectReference
+		for _, obj := range attached {
+			if ref := obj.Reference(); ref.Type == "VirtualMachine" {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) == 0 {
+			continue
+		}
+
+		var vms []mo.VirtualMachine
+		if err := pc.Retrieve(ctx, refs, []string{"name", "config.uuid", "config.extraConfig"}, &vms); err != nil {
+			return nil, errors.Wrapf(err, "failed to retrieve the VMs tagged with %q", tag.Name)
+		}
+		for _, vm := range vms {
+			managedVM := ManagedVM{
+				Ref:              vm.Reference(),
+				Name:             vm.Name,
+				ClusterNamespace: parts[0],
+				ClusterName:      parts[1],
+			}
+			if vm.Config != nil {
+				managedVM.BiosUUID = vm.Config.Uuid
+				for _, option := range vm.Config.ExtraConfig {
+					if value := option.GetOptionValue(); value.Key == extra.ManagementClusterIDKey {
+						managedVM.ManagementClusterID, _ = value.Value.(string)
+					}
+				}
+			}
+			managedVMs = append(managedVMs, managedVM)
+		}
+	}
+	return managedVMs, nil
+}
+
+// DestroyManagedVM powers off and destroys a managed VM, and verifies that the
+// files of its disks were deleted with it.
+func DestroyManagedVM(ctx goctx.Context, s *session.Session, ref types.ManagedObjectReference) error {
+	var (
+		obj mo.VirtualMachine
+		vm  = object.NewVirtualMachine(s.Client.Client, ref)
+	)
+	if err := vm.Properties(ctx, ref, []string{"name", "runtime.powerState", "config.hardware.device"}, &obj); err != nil {
+		return errors.Wrapf(err, "failed to retrieve properties of vm %s", ref)
+	}
+
+	if obj.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn {
+		task, err := vm.PowerOff(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to trigger power off op for vm %s", obj.Name)
+		}
+		if err := task.Wait(ctx); err != nil {
+			return errors.Wrapf(err, "failed to power off vm %s", obj.Name)
+		}
+	}
+
+	task, err := vm.Destroy(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to trigger destroy op for vm %s", obj.Name)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "failed to destroy vm %s", obj.Name)
+	}
+
+	var devices object.VirtualDeviceList
+	if obj.Config != nil {
+		devices = obj.Config.Hardware.Device
+	}
+	return verifyDisksDeleted(ctx, s, obj.Name, devices)
+}
+
+// verifyDisksDeleted returns an error if the file of one of the disks of a
+// destroyed VM still exists.
+func verifyDisksDeleted(ctx goctx.Context, s *session.Session, vmName string, devices object.VirtualDeviceList) error {
+	var leftovers []string
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		backing, ok := device.GetVirtualDevice().Backing.(types.BaseVirtualDeviceFileBackingInfo)
+		if !ok {
+			continue
+		}
+		info := backing.GetVirtualDeviceFileBackingInfo()
+		if info.Datastore == nil {
+			continue
+		}
+
+		var path object.DatastorePath
+		if !path.FromString(info.FileName) {
+			continue
+		}
+		// The datastore paths are relative to the name of the datastore.
+		datastore := object.NewDatastore(s.Client.Client, *info.Datastore)
+		datastore.InventoryPath = path.Datastore
+		_, err := datastore.Stat(ctx, path.Path)
+		switch {
+		case err == nil:
+			leftovers = append(leftovers, info.FileName)
+		case !errors.As(err, &object.DatastoreNoSuchFileError{}) && !errors.As(err, &object.DatastoreNoSuchDirectoryError{}):
+			return errors.Wrapf(err, "failed to verify the deletion of disk %s of vm %s", info.FileName, vmName)
+		}
+	}
+	if len(leftovers) > 0 {
+		return errors.Errorf("disks %s of vm %s were not deleted", strings.Join(leftovers, ", "), vmName)
+	}
+	return nil
+}
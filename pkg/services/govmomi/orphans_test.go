@@ -0,0 +1,90 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/metadata"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestManagedVMs(t *testing.T) {
+	g := NewWithT(t)
+	ctx := goctx.Background()
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	s, err := session.GetOrCreate(ctx, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// No VM is managed until the category of the managed tags exists.
+	managedVMs, err := ListManagedVMs(ctx, s)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(managedVMs).To(BeEmpty())
+
+	vm, err := s.Finder.VirtualMachine(ctx, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	tagID, err := metadata.EnsureManagedTag(ctx, s.TagManager, metadata.ClusterTagCategory, metadata.ClusterTagName("default", "my-cluster"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.TagManager.AttachTag(ctx, tagID, vm.Reference())).To(Succeed())
+	uuid := vm.UUID(ctx)
+	task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		ExtraConfig: []types.BaseOptionValue{&types.OptionValue{Key: extra.ManagementClusterIDKey, Value: "management-cluster"}},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task.Wait(ctx)).To(Succeed())
+
+	managedVMs, err = ListManagedVMs(ctx, s)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(managedVMs).To(ConsistOf(ManagedVM{
+		Ref:                 vm.Reference(),
+		Name:                "DC0_H0_VM0",
+		BiosUUID:            uuid,
+		ClusterNamespace:    "default",
+		ClusterName:         "my-cluster",
+		ManagementClusterID: "management-cluster",
+	}))
+
+	// The disks of a VM which is not destroyed are reported.
+	devices, err := vm.Device(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(verifyDisksDeleted(ctx, s, "DC0_H0_VM0", devices)).To(MatchError(ContainSubstring("were not deleted")))
+
+	// The powered on VM is powered off and destroyed with its disks.
+	g.Expect(DestroyManagedVM(ctx, s, vm.Reference())).To(Succeed())
+	_, err = s.Finder.VirtualMachine(ctx, "DC0_H0_VM0")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(verifyDisksDeleted(ctx, s, "DC0_H0_VM0", devices)).To(Succeed())
+
+	// A failure to list the categories is not taken for a missing category.
+	g.Expect(s.TagManager.Logout(ctx)).To(Succeed())
+	_, err = ListManagedVMs(ctx, s)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/privileges"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// ReconcileClusterPermission creates the role of a cluster if it does not
+// exist, updates its privileges, and grants it to the principal of the
+// permission on the folder and the resource pool of the cluster. The
+// permission of a principal replaced in the spec, or granted on a folder or a
+// resource pool the cluster no longer uses, is removed. vCenter is not called
+// when the permission recorded in the status is the permission of the spec.
+// When the spec is nil, the current permission and its role are deleted. It
+// returns the permission granted, or the permission to record when it fails,
+// i.e. the current permission with the ID of the role once it is created.
+func ReconcileClusterPermission(ctx goctx.Context, s *session.Session, spec *infrav1.ClusterPermissionSpec, role string, placement *infrav1.ClusterPlacementStatus, current *infrav1.ClusterPermissionStatus) (*infrav1.ClusterPermissionStatus, error) {
+	if spec == nil {
+		if current == nil {
+			return nil, nil
+		}
+		return nil, DeleteClusterPermission(ctx, s, placement, current)
+	}
+
+	privilegeIDs := spec.Privileges
+	if len(privilegeIDs) == 0 {
+		privilegeIDs = privileges.PermissionDefaults()
+	}
+	desired := &infrav1.ClusterPermissionStatus{
+		Role:         role,
+		Principal:    spec.Principal,
+		Group:        spec.Group,
+		Privileges:   sortedPrivileges(privilegeIDs),
+		Propagate:    pointer.BoolDeref(spec.Propagate, true),
+		Folder:       placement.Folder,
+		ResourcePool: placement.ResourcePool,
+	}
+	if current != nil && current.RoleID != 0 {
+		desired.RoleID = current.RoleID
+		if reflect.DeepEqual(current, desired) {
+			return current, nil
+		}
+	}
+
+	authz := object.NewAuthorizationManager(s.Client.Client)
+	roleID, err := reconcileRole(ctx, authz, role, desired.Privileges, current)
+	if err != nil {
+		return current, err
+	}
+	desired.RoleID = roleID
+	// The permission is granted again when it is reconciled after a failure,
+	// as the privileges recorded differ from the privileges of the role.
+	failed := &infrav1.ClusterPermissionStatus{Role: role, RoleID: roleID}
+	if current != nil {
+		failed.Principal, failed.Group = current.Principal, current.Group
+		failed.Folder, failed.ResourcePool = current.Folder, current.ResourcePool
+	}
+
+	if current != nil && current.Principal != "" && (current.Principal != spec.Principal || current.Group != spec.Group ||
+		current.Folder != placement.Folder || current.ResourcePool != placement.ResourcePool) {
+		// The permissions recorded before the folder and the resource pool
+		// were recorded were granted on the current ones.
+		granted := &infrav1.ClusterPlacementStatus{Folder: current.Folder, ResourcePool: current.ResourcePool}
+		if current.Folder == "" && current.ResourcePool == "" {
+			granted = placement
+		}
+		entities, err := placementEntities(ctx, s, granted)
+		if err != nil {
+			return failed, err
+		}
+		for _, entity := range entities {
+			if err := removeEntityPermission(ctx, authz, entity, current); err != nil {
+				return failed, err
+			}
+		}
+	}
+
+	entities, err := placementEntities(ctx, s, placement)
+	if err != nil {
+		return failed, err
+	}
+	permission := types.Permission{
+		Principal: spec.Principal,
+		Group:     spec.Group,
+		RoleId:    roleID,
+		Propagate: desired.Propagate,
+	}
+	for _, entity := range entities {
+		if err := authz.SetEntityPermissions(ctx, entity.Reference(), []types.Permission{permission}); err != nil {
+			return failed, errors.Wrapf(err, "unable to grant role %q to %q on %q", role, spec.Principal, entity.InventoryPath)
+		}
+	}
+	return desired, nil
+}
+
+// DeleteClusterPermission removes the permission of a cluster from its folder
+// and its resource pool, then deletes its role. The folder and the resource
+// pool already deleted are skipped, and a role which was not created for the
+// cluster is kept.
+func DeleteClusterPermission(ctx goctx.Context, s *session.Session, placement *infrav1.ClusterPlacementStatus, current *infrav1.ClusterPermissionStatus) error {
+	authz := object.NewAuthorizationManager(s.Client.Client)
+	entities, err := placementEntities(ctx, s, placement)
+	if err != nil {
+		return err
+	}
+	for _, entity := range entities {
+		if err := removeEntityPermission(ctx, authz, entity, current); err != nil {
+			return err
+		}
+	}
+
+	roles, err := authz.RoleList(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to list the roles")
+	}
+	if existing := ownedRole(roles, current); existing != nil {
+		// The permissions of the role on the objects outside of the folder
+		// and the resource pool were not granted by CAPV.
+		if err := authz.RemoveRole(ctx, existing.RoleId, false); err != nil {
+			return errors.Wrapf(err, "unable to delete role %q", current.Role)
+		}
+	}
+	return nil
+}
+
+// reconcileRole creates a role with the given privileges, or updates the
+// privileges of the role recorded in the current permission. A role of the
+// same name which was not created for the cluster is neither updated nor
+// taken over. It returns the ID of the role.
+func reconcileRole(ctx goctx.Context, authz *object.AuthorizationManager, name string, privilegeIDs []string, current *infrav1.ClusterPermissionStatus) (int32, error) {
+	roles, err := authz.RoleList(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to list the roles")
+	}
+	existing := ownedRole(roles, current)
+	if existing == nil {
+		if roles.ByName(name) != nil {
+			return 0, errors.Errorf("role %q already exists and was not created for the cluster", name)
+		}
+		id, err := authz.AddRole(ctx, name, privilegeIDs)
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to create role %q", name)
+		}
+		return id, nil
+	}
+	if !samePrivileges(existing.Privilege, privilegeIDs) {
+		if err := authz.UpdateRole(ctx, existing.RoleId, name, privilegeIDs); err != nil {
+			return 0, errors.Wrapf(err, "unable to update the privileges of role %q", name)
+		}
+	}
+	return existing.RoleId, nil
+}
+
+// ownedRole returns the role recorded in the current permission. The
+// permissions recorded before the ID of their role was recorded own the role of
+// their name.
+func ownedRole(roles object.AuthorizationRoleList, current *infrav1.ClusterPermissionStatus) *types.AuthorizationRole {
+	if current == nil {
+		return nil
+	}
+	if current.RoleID == 0 {
+		return roles.ByName(current.Role)
+	}
+	if existing := roles.ById(current.RoleID); existing != nil && existing.Name == current.Role {
+		return existing
+	}
+	return nil
+}
+
+func sortedPrivileges(privilegeIDs []string) []string {
+	sorted := append([]string(nil), privilegeIDs...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// samePrivileges returns true if a role holds the given privileges only. The
+// System privileges, which vCenter adds to every role, are ignored.
+func samePrivileges(held, privilegeIDs []string) bool {
+	filter := func(ids []string) []string {
+		var filtered []string
+		for _, id := range ids {
+			if !strings.HasPrefix(id, "System.") {
+				filtered = append(filtered, id)
+			}
+		}
+		sort.Strings(filtered)
+		return filtered
+	}
+	a, b := filter(held), filter(privilegeIDs)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// placementEntities returns the folder and the resource pool of a cluster
+// which still exist.
+func placementEntities(ctx goctx.Context, s *session.Session, placement *infrav1.ClusterPlacementStatus) ([]object.Common, error) {
+	var entities []object.Common
+	if placement == nil {
+		return entities, nil
+	}
+	if placement.Folder != "" {
+		folder, err := s.Finder.Folder(ctx, placement.Folder)
+		switch {
+		case err == nil:
+			entities = append(entities, folder.Common)
+		case !isFolderNotFound(err):
+			return nil, errors.Wrapf(err, "unable to find folder %q", placement.Folder)
+		}
+	}
+	if placement.ResourcePool != "" {
+		pool, err := s.Finder.ResourcePool(ctx, placement.ResourcePool)
+		switch {
+		case err == nil:
+			entities = append(entities, pool.Common)
+		case !isResourcePoolNotFound(err):
+			return nil, errors.Wrapf(err, "unable to find resource pool %q", placement.ResourcePool)
+		}
+	}
+	return entities, nil
+}
+
+func removeEntityPermission(ctx goctx.Context, authz *object.AuthorizationManager, entity object.Common, permission *infrav1.ClusterPermissionStatus) error {
+	err := authz.RemoveEntityPermission(ctx, entity.Reference(), permission.Principal, permission.Group)
+	if err != nil && !isPermissionNotFound(err) {
+		return errors.Wrapf(err, "unable to remove the permission of %q on %q", permission.Principal, entity.InventoryPath)
+	}
+	return nil
+}
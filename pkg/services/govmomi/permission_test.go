@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/privileges"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestClusterPermission(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	ctx := fake.NewControllerManagerContext()
+	authSession, err := session.GetOrCreate(ctx, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	placement, err := ReconcileClusterPlacement(ctx, authSession, &infrav1.ClusterPlacementSpec{
+		Datacenter:   "DC0",
+		ResourcePool: "/DC0/host/DC0_C0/Resources",
+	}, "default-my-cluster")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	authz := object.NewAuthorizationManager(authSession.Client.Client)
+	// vcsim numbers the roles from 0, which is not the ID of a role of
+	// vCenter, and does not return the ID of the role it creates.
+	_, err = authz.AddRole(ctx, "filler", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	role := func() *types.AuthorizationRole {
+		roles, err := authz.RoleList(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		return roles.ByName("capv-default-my-cluster")
+	}
+	entities, err := placementEntities(ctx, authSession, placement)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entities).To(HaveLen(2))
+	permissions := func(entity object.Common) []types.Permission {
+		permissions, err := authz.RetrieveEntityPermissions(ctx, entity.Reference(), false)
+		g.Expect(err).NotTo(HaveOccurred())
+		return permissions
+	}
+
+	// The role is created with the privileges CAPV requires on the VMs and
+	// the resource pools, and granted on the folder and the resource pool.
+	spec := &infrav1.ClusterPermissionSpec{Principal: "VSPHERE.LOCAL\\capv-team-a"}
+	status, err := ReconcileClusterPermission(ctx, authSession, spec, "capv-default-my-cluster", placement, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(role()).NotTo(BeNil())
+	status.RoleID = role().RoleId
+	g.Expect(status).To(Equal(&infrav1.ClusterPermissionStatus{
+		Role:         "capv-default-my-cluster",
+		RoleID:       role().RoleId,
+		Principal:    "VSPHERE.LOCAL\\capv-team-a",
+		Privileges:   privileges.PermissionDefaults(),
+		Propagate:    true,
+		Folder:       placement.Folder,
+		ResourcePool: placement.ResourcePool,
+	}))
+	g.Expect(role().Privilege).To(ContainElements("Resource.AssignVMToPool", "VirtualMachine.Provisioning.Clone"))
+	g.Expect(role().Privilege).NotTo(ContainElement("Datastore.AllocateSpace"))
+	for _, entity := range entities {
+		g.Expect(permissions(entity)).To(ConsistOf(HaveField("Principal", "VSPHERE.LOCAL\\capv-team-a")), entity.InventoryPath)
+		g.Expect(permissions(entity)[0].Propagate).To(BeTrue())
+	}
+
+	// vCenter is not called when the permission is unchanged.
+	unchanged, err := ReconcileClusterPermission(ctx, &session.Session{}, spec, "capv-default-my-cluster", placement, status)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(unchanged).To(Equal(status))
+
+	// The privileges of the role are updated, and the permission of the
+	// replaced principal is removed.
+	spec = &infrav1.ClusterPermissionSpec{
+		Principal:  "VSPHERE.LOCAL\\capv-admins",
+		Group:      true,
+		Privileges: []string{"VirtualMachine.Interact.PowerOn"},
+		Propagate:  pointer.Bool(false),
+	}
+	status, err = ReconcileClusterPermission(ctx, authSession, spec, "capv-default-my-cluster", placement, status)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(status.Group).To(BeTrue())
+	g.Expect(samePrivileges(role().Privilege, spec.Privileges)).To(BeTrue())
+	for _, entity := range entities {
+		g.Expect(permissions(entity)).To(ConsistOf(HaveField("Principal", "VSPHERE.LOCAL\\capv-admins")), entity.InventoryPath)
+		g.Expect(permissions(entity)[0].Propagate).To(BeFalse())
+	}
+
+	// The permission and the role are deleted once the permission is unset.
+	status, err = ReconcileClusterPermission(ctx, authSession, nil, "capv-default-my-cluster", placement, status)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(status).To(BeNil())
+	g.Expect(role()).To(BeNil())
+	for _, entity := range entities {
+		g.Expect(permissions(entity)).To(BeEmpty(), entity.InventoryPath)
+	}
+
+	// A role of the same name which was not created for the cluster is
+	// neither taken over nor deleted.
+	_, err = authz.AddRole(ctx, "capv-default-my-cluster", []string{"VirtualMachine.Interact.PowerOn"})
+	g.Expect(err).NotTo(HaveOccurred())
+	spec = &infrav1.ClusterPermissionSpec{Principal: "VSPHERE.LOCAL\\capv-team-a"}
+	_, err = ReconcileClusterPermission(ctx, authSession, spec, "capv-default-my-cluster", placement, nil)
+	g.Expect(err).To(MatchError(ContainSubstring("was not created for the cluster")))
+	g.Expect(samePrivileges(role().Privilege, []string{"VirtualMachine.Interact.PowerOn"})).To(BeTrue())
+	foreign := &infrav1.ClusterPermissionStatus{Role: "capv-default-my-cluster", RoleID: role().RoleId + 1, Principal: "VSPHERE.LOCAL\\capv-team-a"}
+	g.Expect(DeleteClusterPermission(ctx, authSession, placement, foreign)).To(Succeed())
+	g.Expect(role()).NotTo(BeNil())
+	for _, entity := range entities {
+		g.Expect(permissions(entity)).To(BeEmpty(), entity.InventoryPath)
+	}
+}
+
+func TestClusterPermissionPlacementChange(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	ctx := fake.NewControllerManagerContext()
+	authSession, err := session.GetOrCreate(ctx, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	authz := object.NewAuthorizationManager(authSession.Client.Client)
+	_, err = authz.AddRole(ctx, "filler", nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	permissions := func(entity object.Common) []types.Permission {
+		permissions, err := authz.RetrieveEntityPermissions(ctx, entity.Reference(), false)
+		g.Expect(err).NotTo(HaveOccurred())
+		return permissions
+	}
+
+	oldPlacement, err := ReconcileClusterPlacement(ctx, authSession, &infrav1.ClusterPlacementSpec{Datacenter: "DC0", ResourcePool: "/DC0/host/DC0_C0/Resources"}, "default-my-cluster")
+	g.Expect(err).NotTo(HaveOccurred())
+	spec := &infrav1.ClusterPermissionSpec{Principal: "VSPHERE.LOCAL\\capv-team-a"}
+	status, err := ReconcileClusterPermission(ctx, authSession, spec, "capv-default-my-cluster", oldPlacement, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	oldEntities, err := placementEntities(ctx, authSession, oldPlacement)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(oldEntities).To(HaveLen(2))
+	for _, entity := range oldEntities {
+		g.Expect(permissions(entity)).To(HaveLen(1), entity.InventoryPath)
+	}
+
+	// The permissions on the folder and the resource pool the cluster no
+	// longer uses are removed.
+	placement, err := ReconcileClusterPlacement(ctx, authSession, &infrav1.ClusterPlacementSpec{Datacenter: "DC0", ResourcePool: "/DC0/host/DC0_C0/Resources"}, "default-my-other-cluster")
+	g.Expect(err).NotTo(HaveOccurred())
+	status, err = ReconcileClusterPermission(ctx, authSession, spec, "capv-default-my-cluster", placement, status)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(status.Folder).To(Equal(placement.Folder))
+	g.Expect(status.ResourcePool).To(Equal(placement.ResourcePool))
+	for _, entity := range oldEntities {
+		g.Expect(permissions(entity)).To(BeEmpty(), entity.InventoryPath)
+	}
+	entities, err := placementEntities(ctx, authSession, placement)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entities).To(HaveLen(2))
+	for _, entity := range entities {
+		g.Expect(permissions(entity)).To(ConsistOf(HaveField("Principal", "VSPHERE.LOCAL\\capv-team-a")), entity.InventoryPath)
+	}
+}
+
+func Test_samePrivileges(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(samePrivileges([]string{"System.Read", "Network.Assign", "Datastore.AllocateSpace"}, []string{"Datastore.AllocateSpace", "Network.Assign"})).To(BeTrue())
+	g.Expect(samePrivileges([]string{"System.Read", "Network.Assign"}, []string{"Datastore.AllocateSpace", "Network.Assign"})).To(BeFalse())
+	g.Expect(samePrivileges([]string{"Network.Assign", "Datastore.AllocateSpace"}, []string{"Network.Assign"})).To(BeFalse())
+}
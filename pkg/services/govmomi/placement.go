@@ -0,0 +1,190 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/metadata"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// ReconcileClusterPlacement creates the VM folder and the resource pool of a
+// cluster if they do not exist, and updates the allocation of the resource
+// pool. It returns the inventory paths of the folder and the resource pool.
+func ReconcileClusterPlacement(ctx goctx.Context, s *session.Session, spec *infrav1.ClusterPlacementSpec, name string) (*infrav1.ClusterPlacementStatus, error) {
+	parentFolder, err := s.Finder.FolderOrDefault(ctx, spec.Folder)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find parent folder %q", spec.Folder)
+	}
+	folder, err := s.Finder.Folder(ctx, path.Join(parentFolder.InventoryPath, name))
+	if err != nil {
+		if !isFolderNotFound(err) {
+			return nil, errors.Wrapf(err, "unable to find folder %q", name)
+		}
+		if folder, err = parentFolder.CreateFolder(ctx, name); err != nil {
+			return nil, errors.Wrapf(err, "unable to create folder %q in %q", name, parentFolder.InventoryPath)
+		}
+		folder.InventoryPath = path.Join(parentFolder.InventoryPath, name)
+	}
+
+	parentPool, err := s.Finder.ResourcePoolOrDefault(ctx, spec.ResourcePool)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find parent resource pool %q", spec.ResourcePool)
+	}
+	config := resourceConfigSpec(spec)
+	pool, err := s.Finder.ResourcePool(ctx, path.Join(parentPool.InventoryPath, name))
+	switch {
+	case err == nil:
+		if err := pool.UpdateConfig(ctx, "", &config); err != nil {
+			return nil, errors.Wrapf(err, "unable to update the allocation of resource pool %q", pool.InventoryPath)
+		}
+	case isResourcePoolNotFound(err):
+		if pool, err = parentPool.Create(ctx, name, config); err != nil {
+			return nil, errors.Wrapf(err, "unable to create resource pool %q in %q", name, parentPool.InventoryPath)
+		}
+		pool.InventoryPath = path.Join(parentPool.InventoryPath, name)
+	default:
+		return nil, errors.Wrapf(err, "unable to find resource pool %q", name)
+	}
+
+	return &infrav1.ClusterPlacementStatus{
+		Folder:       folder.InventoryPath,
+		ResourcePool: pool.InventoryPath,
+	}, nil
+}
+
+// TagClusterPlacement attaches the managed tags to the VM folder and the
+// resource pool of a cluster. The managed tags are keyed by their category,
+// and are created on demand.
+func TagClusterPlacement(ctx goctx.Context, s *session.Session, status *infrav1.ClusterPlacementStatus, managedTags map[string]string) error {
+	tagIDs := make([]string, 0, len(managedTags))
+	for category, tag := range managedTags {
+		tagID, err := metadata.EnsureManagedTag(ctx, s.TagManager, category, tag)
+		if err != nil {
+			return err
+		}
+		tagIDs = append(tagIDs, tagID)
+	}
+
+	folder, err := s.Finder.Folder(ctx, status.Folder)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find folder %q", status.Folder)
+	}
+	pool, err := s.Finder.ResourcePool(ctx, status.ResourcePool)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find resource pool %q", status.ResourcePool)
+	}
+	for _, ref := range []mo.Reference{folder, pool} {
+		if err := s.TagManager.AttachMultipleTagsToObject(ctx, tagIDs, ref); err != nil {
+			for category, tag := range managedTags {
+				metadata.ForgetManagedTag(s.TagManager, category, tag)
+			}
+			return errors.Wrapf(err, "failed to attach managed tags to %s", ref.Reference())
+		}
+	}
+	return nil
+}
+
+// DeleteClusterPlacement deletes the VM folder and the resource pool of a
+// cluster. A folder or resource pool which is not empty is kept, as deleting
+// it would delete or move objects CAPV did not create. It returns false when
+// either of them is kept.
+func DeleteClusterPlacement(ctx goctx.Context, s *session.Session, status *infrav1.ClusterPlacementStatus) (bool, error) {
+	deleted := true
+
+	if status.ResourcePool != "" {
+		pool, err := s.Finder.ResourcePool(ctx, status.ResourcePool)
+		switch {
+		case err == nil:
+			var obj mo.ResourcePool
+			if err := pool.Properties(ctx, pool.Reference(), []string{"vm", "resourcePool"}, &obj); err != nil {
+				return false, errors.Wrapf(err, "unable to get the content of resource pool %q", status.ResourcePool)
+			}
+			if len(obj.Vm) > 0 || len(obj.ResourcePool) > 0 {
+				deleted = false
+				break
+			}
+			if err := destroy(ctx, pool.Common); err != nil {
+				return false, errors.Wrapf(err, "unable to delete resource pool %q", status.ResourcePool)
+			}
+		case !isResourcePoolNotFound(err):
+			return false, errors.Wrapf(err, "unable to find resource pool %q", status.ResourcePool)
+		}
+	}
+
+	if status.Folder != "" {
+		folder, err := s.Finder.Folder(ctx, status.Folder)
+		switch {
+		case err == nil:
+			var obj mo.Folder
+			if err := folder.Properties(ctx, folder.Reference(), []string{"childEntity"}, &obj); err != nil {
+				return false, errors.Wrapf(err, "unable to get the content of folder %q", status.Folder)
+			}
+			if len(obj.ChildEntity) > 0 {
+				deleted = false
+				break
+			}
+			if err := destroy(ctx, folder.Common); err != nil {
+				return false, errors.Wrapf(err, "unable to delete folder %q", status.Folder)
+			}
+		case !isFolderNotFound(err):
+			return false, errors.Wrapf(err, "unable to find folder %q", status.Folder)
+		}
+	}
+
+	return deleted, nil
+}
+
+func destroy(ctx goctx.Context, obj object.Common) error {
+	task, err := obj.Destroy(ctx)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
+
+// resourceConfigSpec returns the allocation of the resource pool of a
+// cluster, with the defaults of vCenter for the unset values.
+func resourceConfigSpec(spec *infrav1.ClusterPlacementSpec) types.ResourceConfigSpec {
+	config := types.DefaultResourceConfigSpec()
+	applyResourceAllocation(&config.CpuAllocation, spec.CPU)
+	applyResourceAllocation(&config.MemoryAllocation, spec.Memory)
+	return config
+}
+
+func applyResourceAllocation(info *types.ResourceAllocationInfo, allocation *infrav1.ResourceAllocation) {
+	if allocation == nil {
+		return
+	}
+	if allocation.Reservation != nil {
+		info.Reservation = types.NewInt64(*allocation.Reservation)
+	}
+	if allocation.Limit != nil {
+		info.Limit = types.NewInt64(*allocation.Limit)
+	}
+	if allocation.ExpandableReservation != nil {
+		info.ExpandableReservation = types.NewBool(*allocation.ExpandableReservation)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/metadata"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestClusterPlacement(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	ctx := fake.NewControllerManagerContext()
+	authSession, err := session.GetOrCreate(ctx, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	spec := &infrav1.ClusterPlacementSpec{
+		Datacenter:   "DC0",
+		ResourcePool: "/DC0/host/DC0_C0/Resources",
+		CPU:          &infrav1.ResourceAllocation{Reservation: pointer.Int64(1000), Limit: pointer.Int64(4000)},
+		Memory:       &infrav1.ResourceAllocation{Reservation: pointer.Int64(2048), ExpandableReservation: pointer.Bool(false)},
+	}
+	status, err := ReconcileClusterPlacement(ctx, authSession, spec, "default-my-cluster")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(status.Folder).To(Equal("/DC0/vm/default-my-cluster"))
+	g.Expect(status.ResourcePool).To(Equal("/DC0/host/DC0_C0/Resources/default-my-cluster"))
+
+	getConfig := func() mo.ResourcePool {
+		pool, err := authSession.Finder.ResourcePool(ctx, status.ResourcePool)
+		g.Expect(err).NotTo(HaveOccurred())
+		var obj mo.ResourcePool
+		g.Expect(pool.Properties(ctx, pool.Reference(), []string{"config"}, &obj)).To(Succeed())
+		return obj
+	}
+	config := getConfig().Config
+	g.Expect(*config.CpuAllocation.Reservation).To(Equal(int64(1000)))
+	g.Expect(*config.CpuAllocation.Limit).To(Equal(int64(4000)))
+	g.Expect(*config.MemoryAllocation.Reservation).To(Equal(int64(2048)))
+	g.Expect(*config.MemoryAllocation.Limit).To(Equal(int64(-1)))
+	g.Expect(*config.MemoryAllocation.ExpandableReservation).To(BeFalse())
+
+	// The existing folder and resource pool are reused, and the allocation of
+	// the resource pool is updated.
+	spec.CPU.Limit = pointer.Int64(8000)
+	again, err := ReconcileClusterPlacement(ctx, authSession, spec, "default-my-cluster")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again).To(Equal(status))
+	g.Expect(*getConfig().Config.CpuAllocation.Limit).To(Equal(int64(8000)))
+
+	// The folder and the resource pool are tagged with the managed tags.
+	managedTags := map[string]string{
+		metadata.ClusterTagCategory:   metadata.ClusterTagName("default", "my-cluster"),
+		metadata.NamespaceTagCategory: "default",
+	}
+	g.Expect(TagClusterPlacement(ctx, authSession, status, managedTags)).To(Succeed())
+	g.Expect(TagClusterPlacement(ctx, authSession, status, managedTags)).To(Succeed())
+	folder, err := authSession.Finder.Folder(ctx, status.Folder)
+	g.Expect(err).NotTo(HaveOccurred())
+	pool, err := authSession.Finder.ResourcePool(ctx, status.ResourcePool)
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, ref := range []mo.Reference{folder, pool} {
+		attached, err := authSession.TagManager.GetAttachedTags(ctx, ref)
+		g.Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, tag := range attached {
+			names = append(names, tag.Name)
+		}
+		g.Expect(names).To(ConsistOf("default/my-cluster", "default"))
+	}
+
+	// A folder which is not empty is kept.
+	child, err := folder.CreateFolder(ctx, "unmanaged")
+	g.Expect(err).NotTo(HaveOccurred())
+	deleted, err := DeleteClusterPlacement(ctx, authSession, status)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeFalse())
+	_, err = authSession.Finder.ResourcePool(ctx, status.ResourcePool)
+	g.Expect(isResourcePoolNotFound(err)).To(BeTrue())
+
+	g.Expect(destroy(ctx, child.Common)).To(Succeed())
+	deleted, err = DeleteClusterPlacement(ctx, authSession, status)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeTrue())
+	_, err = authSession.Finder.Folder(ctx, status.Folder)
+	g.Expect(isFolderNotFound(err)).To(BeTrue())
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/template"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// preflightPrivileges are the privileges the user of CAPV needs on each kind
+// of object to clone a VM. The privileges on a template depend on whether it
+// is a VM or a template, see templatePrivileges.
+var preflightPrivileges = map[infrav1.PreflightObjectKind][]string{
+	infrav1.PreflightDatastore:    {"Datastore.AllocateSpace"},
+	infrav1.PreflightNetwork:      {"Network.Assign"},
+	infrav1.PreflightFolder:       {"VirtualMachine.Inventory.CreateFromExisting"},
+	infrav1.PreflightResourcePool: {"Resource.AssignVMToPool"},
+}
+
+// PreflightChecks returns the checks of the objects of the vCenter inventory
+// referenced by the given clone specs, without their results. Each object is
+// checked once, and the checks are sorted by datacenter, kind and name.
+func PreflightChecks(specs ...*infrav1.VirtualMachineCloneSpec) []infrav1.PreflightCheck {
+	type key struct {
+		kind             infrav1.PreflightObjectKind
+		name, datacenter string
+	}
+	seen := map[key]bool{}
+	checks := []infrav1.PreflightCheck{}
+	add := func(kind infrav1.PreflightObjectKind, name, datacenter string) {
+		k := key{kind: kind, name: name, datacenter: datacenter}
+		if name == "" || seen[k] {
+			return
+		}
+		seen[k] = true
+		checks = append(checks, infrav1.PreflightCheck{Kind: kind, Name: name, Datacenter: datacenter})
+	}
+	for _, spec := range specs {
+		add(infrav1.PreflightDatacenter, spec.Datacenter, "")
+		add(infrav1.PreflightDatastore, spec.Datastore, spec.Datacenter)
+		for _, device := range spec.Network.Devices {
+			add(infrav1.PreflightNetwork, device.NetworkName, spec.Datacenter)
+		}
+		add(infrav1.PreflightFolder, spec.Folder, spec.Datacenter)
+		add(infrav1.PreflightResourcePool, spec.ResourcePool, spec.Datacenter)
+		// The template is looked up in its own datacenter, when it is in
+		// another datacenter than the VM.
+		templateDatacenter := spec.Datacenter
+		if spec.TemplateDatacenter != "" {
+			templateDatacenter = spec.TemplateDatacenter
+			add(infrav1.PreflightDatacenter, spec.TemplateDatacenter, "")
+		}
+		add(infrav1.PreflightTemplate, spec.Template, templateDatacenter)
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		if checks[i].Datacenter != checks[j].Datacenter {
+			return checks[i].Datacenter < checks[j].Datacenter
+		}
+		if checks[i].Kind != checks[j].Kind {
+			return checks[i].Kind < checks[j].Kind
+		}
+		return checks[i].Name < checks[j].Name
+	})
+	return checks
+}
+
+// RunPreflightChecks runs the given checks with a session which is not bound to
+// a datacenter, and sets their results. An object is looked up in its
+// datacenter, or in the default datacenter when none is set, and its check
+// passes when the user of the session has the privileges required to clone VMs
+// with it. The checks of the objects of a datacenter which cannot be found
+// fail.
+func RunPreflightChecks(ctx goctx.Context, logger logr.Logger, s *session.Session, checks []infrav1.PreflightCheck) {
+	finders := map[string]*find.Finder{}
+	finderErrs := map[string]error{}
+	finderFor := func(datacenter string) (*find.Finder, error) {
+		if finder, ok := finders[datacenter]; ok {
+			return finder, finderErrs[datacenter]
+		}
+		finder := find.NewFinder(s.Client.Client, false)
+		dc, err := finder.DatacenterOrDefault(ctx, datacenter)
+		if err == nil {
+			finder.SetDatacenter(dc)
+		}
+		finders[datacenter], finderErrs[datacenter] = finder, err
+		return finder, err
+	}
+
+	sessionKey := ""
+	userSession, err := s.SessionManager.UserSession(ctx)
+	if err != nil {
+		logger.Error(err, "failed to get the user session of the preflight checks")
+	} else if userSession != nil {
+		sessionKey = userSession.Key
+	}
+	authz := object.NewAuthorizationManager(s.Client.Client)
+
+	for i := range checks {
+		check := &checks[i]
+		check.MissingPrivileges = nil
+		check.Message = ""
+
+		if check.Kind == infrav1.PreflightDatacenter {
+			_, err := finderFor(check.Name)
+			setPreflightResult(check, err)
+			continue
+		}
+		finder, err := finderFor(check.Datacenter)
+		if err != nil {
+			check.Result = infrav1.PreflightFailed
+			check.Message = fmt.Sprintf("unable to find the datacenter of %s %q: %v", check.Kind, check.Name, err)
+			continue
+		}
+
+		ref, privileges, err := lookupPreflightObject(ctx, logger, s, finder, check)
+		if err != nil || ref == nil || len(privileges) == 0 {
+			setPreflightResult(check, err)
+			continue
+		}
+		granted, err := authz.HasPrivilegeOnEntity(ctx, *ref, sessionKey, privileges)
+		if err != nil {
+			setPreflightResult(check, errors.Wrapf(err, "unable to check the privileges on %s %q", check.Kind, check.Name))
+			continue
+		}
+		for j, privilege := range privileges {
+			if j >= len(granted) || !granted[j] {
+				check.MissingPrivileges = append(check.MissingPrivileges, privilege)
+			}
+		}
+		if len(check.MissingPrivileges) > 0 {
+			check.Result = infrav1.PreflightMissingPrivileges
+			check.Message = fmt.Sprintf("missing privileges %v on %s %q", check.MissingPrivileges, check.Kind, check.Name)
+			continue
+		}
+		check.Result = infrav1.PreflightPassed
+	}
+}
+
+// lookupPreflightObject returns the reference of the object of a check, and the
+// privileges required on it. The reference is nil for a template which is the
+// item of a content library, which is only deployed when a VM is first cloned
+// from it.
+func lookupPreflightObject(ctx goctx.Context, logger logr.Logger, s *session.Session, finder *find.Finder, check *infrav1.PreflightCheck) (*types.ManagedObjectReference, []string, error) {
+	var obj object.Reference
+	var err error
+	switch check.Kind {
+	case infrav1.PreflightDatastore:
+		obj, err = finder.Datastore(ctx, check.Name)
+	case infrav1.PreflightNetwork:
+		obj, err = finder.Network(ctx, check.Name)
+	case infrav1.PreflightFolder:
+		obj, err = finder.Folder(ctx, check.Name)
+	case infrav1.PreflightResourcePool:
+		obj, err = finder.ResourcePool(ctx, check.Name)
+	case infrav1.PreflightTemplate:
+		return lookupPreflightTemplate(ctx, logger, s, finder, check.Name)
+	default:
+		return nil, nil, errors.Errorf("unknown kind %q", check.Kind)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	ref := obj.Reference()
+	return &ref, preflightPrivileges[check.Kind], nil
+}
+
+func lookupPreflightTemplate(ctx goctx.Context, logger logr.Logger, s *session.Session, finder *find.Finder, name string) (*types.ManagedObjectReference, []string, error) {
+	tplCtx := preflightContext{
+		Context: ctx,
+		logger:  logger,
+		session: &session.Session{Client: s.Client, Finder: finder, TagManager: s.TagManager},
+	}
+	tpl, err := template.LookupTemplate(tplCtx, name)
+	if err != nil || tpl == nil {
+		return nil, nil, err
+	}
+
+	var o mo.VirtualMachine
+	if err := tpl.Properties(ctx, tpl.Reference(), []string{"config.template"}, &o); err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to get the properties of template %q", name)
+	}
+	privileges := []string{"VirtualMachine.Provisioning.Clone"}
+	if o.Config != nil && o.Config.Template {
+		privileges = []string{"VirtualMachine.Provisioning.DeployTemplate"}
+	}
+	ref := tpl.Reference()
+	return &ref, privileges, nil
+}
+
+// setPreflightResult sets the result of a check from the error of the lookup
+// of its object.
+func setPreflightResult(check *infrav1.PreflightCheck, err error) {
+	switch {
+	case err == nil:
+		check.Result = infrav1.PreflightPassed
+	case isPreflightNotFound(err):
+		check.Result = infrav1.PreflightNotFound
+		check.Message = fmt.Sprintf("%s %q not found", check.Kind, check.Name)
+	case capverrors.ClassOf(err) == capverrors.Permission:
+		check.Result = infrav1.PreflightMissingPrivileges
+		check.Message = fmt.Sprintf("missing privileges on %s %q: %v", check.Kind, check.Name, err)
+	default:
+		check.Result = infrav1.PreflightFailed
+		check.Message = fmt.Sprintf("unable to check %s %q: %v", check.Kind, check.Name, err)
+	}
+}
+
+func isPreflightNotFound(err error) bool {
+	switch errors.Cause(err).(type) {
+	case *find.NotFoundError, *find.DefaultNotFoundError:
+		return true
+	}
+	return isManagedObjectNotFound(err)
+}
+
+// preflightContext is the context of the lookups of templates which are not
+// run for a VM, e.g. by the preflight checks.
+type preflightContext struct {
+	goctx.Context
+	logger  logr.Logger
+	session *session.Session
+}
+
+func (c preflightContext) GetLogger() logr.Logger {
+	return c.logger
+}
+
+func (c preflightContext) GetSession() *session.Session {
+	return c.session
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestPreflightChecks(t *testing.T) {
+	g := NewWithT(t)
+
+	spec := func(datastore string) *infrav1.VirtualMachineCloneSpec {
+		return &infrav1.VirtualMachineCloneSpec{
+			Datacenter: "DC0",
+			Datastore:  datastore,
+			Template:   "ubuntu",
+			Network: infrav1.NetworkSpec{Devices: []infrav1.NetworkDeviceSpec{
+				{NetworkName: "VM Network"},
+				{NetworkName: "VM Network"},
+			}},
+		}
+	}
+	checks := PreflightChecks(spec("ds-b"), spec("ds-a"))
+	g.Expect(checks).To(Equal([]infrav1.PreflightCheck{
+		{Kind: infrav1.PreflightDatacenter, Name: "DC0"},
+		{Kind: infrav1.PreflightDatastore, Name: "ds-a", Datacenter: "DC0"},
+		{Kind: infrav1.PreflightDatastore, Name: "ds-b", Datacenter: "DC0"},
+		{Kind: infrav1.PreflightNetwork, Name: "VM Network", Datacenter: "DC0"},
+		{Kind: infrav1.PreflightTemplate, Name: "ubuntu", Datacenter: "DC0"},
+	}))
+
+	// The template of another datacenter is checked in its datacenter.
+	otherDatacenter := spec("ds-a")
+	otherDatacenter.TemplateDatacenter = "DC1"
+	checks = PreflightChecks(otherDatacenter)
+	g.Expect(checks).To(Equal([]infrav1.PreflightCheck{
+		{Kind: infrav1.PreflightDatacenter, Name: "DC0"},
+		{Kind: infrav1.PreflightDatacenter, Name: "DC1"},
+		{Kind: infrav1.PreflightDatastore, Name: "ds-a", Datacenter: "DC0"},
+		{Kind: infrav1.PreflightNetwork, Name: "VM Network", Datacenter: "DC0"},
+		{Kind: infrav1.PreflightTemplate, Name: "ubuntu", Datacenter: "DC1"},
+	}))
+}
+
+func TestRunPreflightChecks(t *testing.T) {
+	g := NewWithT(t)
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	ctx := fake.NewControllerManagerContext()
+	authSession, err := session.GetOrCreate(ctx, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	checks := PreflightChecks(
+		&infrav1.VirtualMachineCloneSpec{
+			Datacenter:   "DC0",
+			Datastore:    "LocalDS_0",
+			Folder:       "/DC0/vm",
+			ResourcePool: "/DC0/host/DC0_C0/Resources",
+			Template:     "DC0_H0_VM0",
+			Network:      infrav1.NetworkSpec{Devices: []infrav1.NetworkDeviceSpec{{NetworkName: "VM Network"}}},
+		},
+		&infrav1.VirtualMachineCloneSpec{
+			Datacenter: "DC0",
+			Datastore:  "missing-ds",
+			Template:   "missing-template",
+		},
+		&infrav1.VirtualMachineCloneSpec{
+			Datacenter: "missing-dc",
+			Datastore:  "LocalDS_0",
+		},
+	)
+	RunPreflightChecks(ctx, logr.Discard(), authSession, checks)
+
+	results := map[string]infrav1.PreflightCheckResult{}
+	for _, check := range checks {
+		results[check.Datacenter+"/"+string(check.Kind)+"/"+check.Name] = check.Result
+		if check.Result == infrav1.PreflightPassed {
+			g.Expect(check.Message).To(BeEmpty())
+		} else {
+			g.Expect(check.Message).NotTo(BeEmpty())
+		}
+	}
+	g.Expect(results).To(Equal(map[string]infrav1.PreflightCheckResult{
+		"/Datacenter/DC0":                             infrav1.PreflightPassed,
+		"/Datacenter/missing-dc":                      infrav1.PreflightNotFound,
+		"DC0/Datastore/LocalDS_0":                     infrav1.PreflightPassed,
+		"DC0/Datastore/missing-ds":                    infrav1.PreflightNotFound,
+		"DC0/Folder//DC0/vm":                          infrav1.PreflightPassed,
+		"DC0/Network/VM Network":                      infrav1.PreflightPassed,
+		"DC0/ResourcePool//DC0/host/DC0_C0/Resources": infrav1.PreflightPassed,
+		"DC0/Template/DC0_H0_VM0":                     infrav1.PreflightPassed,
+		"DC0/Template/missing-template":               infrav1.PreflightNotFound,
+		"missing-dc/Datastore/LocalDS_0":              infrav1.PreflightFailed,
+	}))
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/find"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/metadata"
+)
+
+// isRetained returns whether the VM of a deleted VSphereVM is kept in vCenter
+// according to the deletion policy of the VSphereVM.
+func isRetained(ctx *virtualMachineContext) bool {
+	policy := ctx.VSphereVM.Spec.DeletionPolicy
+	return policy == infrav1.RetainVMDeletionPolicy || policy == infrav1.PowerOffAndRetainVMDeletionPolicy
+}
+
+// reconcileRetention keeps the VM of a deleted VSphereVM in vCenter, powered
+// off first with the PowerOffAndRetain deletion policy. The VM is no longer
+// managed by CAPV once retained, so the tag of its cluster is detached for
+// the garbage collector of the orphaned VMs not to destroy it. It returns
+// true once the VM is retained, which is recorded in an event with the
+// inventory path of the VM.
+func (vms *VMService) reconcileRetention(ctx *virtualMachineContext) (bool, error) {
+	policy := ctx.VSphereVM.Spec.DeletionPolicy
+	// The webhooks reject the retention of a VM with pool addresses, whose
+	// claims are deleted with the VSphereVM, but not the VSphereVMs admitted
+	// before.
+	for _, device := range ctx.VSphereVM.Spec.Network.Devices {
+		if len(device.AddressesFromPools) > 0 {
+			return false, errors.Errorf("unable to retain VM %s according to the %s deletion policy: its addresses are claimed from IPAM pools and would be released, set the deletion policy to Delete", ctx.VSphereVM.Name, policy)
+		}
+	}
+	if policy == infrav1.PowerOffAndRetainVMDeletionPolicy {
+		if ok, err := vms.reconcilePowerOff(ctx); err != nil || !ok {
+			return false, err
+		}
+	}
+
+	if clusterName, ok := ctx.VSphereVM.Labels[clusterv1.ClusterLabelName]; ok && feature.Gates.Enabled(feature.ManagedTags) {
+		tagName := metadata.ClusterTagName(ctx.VSphereVM.Namespace, clusterName)
+		if err := metadata.DetachManagedTag(ctx, ctx.Session.TagManager, metadata.ClusterTagCategory, tagName, ctx.Ref); err != nil {
+			return false, err
+		}
+	}
+
+	inventoryPath, err := find.InventoryPath(ctx, ctx.Session.Client.Client, ctx.Ref)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to get the inventory path of VM %s", ctx.VSphereVM.Name)
+	}
+	ctx.Logger.Info("retaining vm", "path", inventoryPath, "deletionPolicy", policy)
+	ctx.Recorder.Eventf(ctx.VSphereVM, "VMRetained", "Retained VM %s in vCenter according to the %s deletion policy", inventoryPath, policy)
+	return true, nil
+}
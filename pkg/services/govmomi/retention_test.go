@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+func TestReconcileRetention(t *testing.T) {
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.ManagedTags, true)()
+
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	newContext := func(g *WithT, name string, policy infrav1.VMDeletionPolicy) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Spec.DeletionPolicy = policy
+		vmContext.VSphereVM.Labels = map[string]string{clusterv1.ClusterLabelName: fake.Clusterv1a2Name}
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		ctx := &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+		g.Expect((&VMService{}).reconcileManagedTags(ctx)).To(Succeed())
+		return ctx
+	}
+	powerState := func(g *WithT, ctx *virtualMachineContext) types.VirtualMachinePowerState {
+		state, err := ctx.Obj.PowerState(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		return state
+	}
+	attachedTags := func(g *WithT, ctx *virtualMachineContext) []string {
+		attached, err := ctx.Session.TagManager.GetAttachedTags(ctx, ctx.Ref)
+		g.Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, tag := range attached {
+			names = append(names, tag.Name)
+		}
+		return names
+	}
+
+	t.Run("retains the VM in its power state", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM0", infrav1.RetainVMDeletionPolicy)
+		g.Expect(isRetained(ctx)).To(BeTrue())
+
+		g.Expect((&VMService{}).reconcileRetention(ctx)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(powerState(g, ctx)).To(Equal(types.VirtualMachinePowerStatePoweredOn))
+		// The VM is no longer found by the garbage collector of the orphaned VMs.
+		g.Expect(attachedTags(g, ctx)).NotTo(ContainElement(fake.Namespace + "/" + fake.Clusterv1a2Name))
+	})
+
+	t.Run("powers off the VM before retaining it", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM1", infrav1.PowerOffAndRetainVMDeletionPolicy)
+		vms := &VMService{}
+
+		g.Expect(vms.reconcileRetention(ctx)).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+		task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: morefTypeTask, Value: ctx.VSphereVM.Status.TaskRef})
+		g.Expect(task.Wait(ctx)).To(Succeed())
+
+		g.Expect(vms.reconcileRetention(ctx)).To(BeTrue())
+		g.Expect(powerState(g, ctx)).To(Equal(types.VirtualMachinePowerStatePoweredOff))
+	})
+
+	t.Run("does not retain a VM with addresses from an IPAM pool", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM1", infrav1.RetainVMDeletionPolicy)
+		ctx.VSphereVM.Spec.Network.Devices = []infrav1.NetworkDeviceSpec{{
+			NetworkName: "VM Network",
+			AddressesFromPools: []corev1.TypedLocalObjectReference{
+				{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"},
+			},
+		}}
+
+		ok, err := (&VMService{}).reconcileRetention(ctx)
+		g.Expect(err).To(MatchError(ContainSubstring("claimed from IPAM pools")))
+		g.Expect(ok).To(BeFalse())
+		// The VM is still found by the garbage collector of the orphaned VMs.
+		g.Expect(attachedTags(g, ctx)).To(ContainElement(fake.Namespace + "/" + fake.Clusterv1a2Name))
+	})
+
+	t.Run("does not retain the VM by default", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM0", "")
+		g.Expect(isRetained(ctx)).To(BeFalse())
+	})
+}
@@ -17,10 +17,15 @@ limitations under the License.
 package govmomi
 
 import (
-	"encoding/base64"
 	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/pbm"
 	pbmTypes "github.com/vmware/govmomi/pbm/types"
@@ -29,16 +34,26 @@ import (
 	"github.com/vmware/govmomi/vim25/types"
 	corev1 "k8s.io/api/core/v1"
 	apitypes "k8s.io/apimachinery/pkg/types"
-	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util/conditions"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/clustermodules"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	capverrors "sigs.k8s.io/cluster-api-provider-vsphere/pkg/errors"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/hooks"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/ipam"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/quota"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/bootstrapdata"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/cluster"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/metadata"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/net"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/kubevip"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
@@ -77,11 +92,16 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 			return vm, err
 		}
 
+		// An existing VM is never cloned, the VSphereVM waits for it to be
+		// found instead.
+		if isExistingVM(ctx) {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.ExistingVMNotFoundReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return vm, nil
+		}
+
 		// If the machine was not found by BIOS UUID it means that it got deleted from vcenter directly
 		if wasNotFoundByBIOSUUID(err) {
-			ctx.VSphereVM.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.UpdateMachineError)
-			ctx.VSphereVM.Status.FailureMessage = pointer.StringPtr(fmt.Sprintf("Unable to find VM by BIOS UUID %s. The vm was removed from infra", ctx.VSphereVM.Spec.BiosUUID))
-			return vm, err
+			return vm, capverrors.NewTerminalf(capierrors.UpdateMachineError, "Unable to find VM by BIOS UUID %s. The vm was removed from infra", ctx.VSphereVM.Spec.BiosUUID)
 		}
 
 		// Otherwise, this is a new machine and the  the VM should be created.
@@ -91,6 +111,33 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.CloningReason, clusterv1.ConditionSeverityInfo, "")
 		}
 
+		// Refuse to clone the VM beyond the quotas of its namespace. The
+		// VSphereVM stays admitted once it is, so that it keeps its share
+		// of the quotas while it waits for a slot or retries its clone.
+		if err := ctx.QuotaAdmitter.Admit(ctx, ctx.Client, ctx.VSphereVM); err != nil {
+			if errors.Is(err, quota.ErrExceeded) {
+				conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.ResourceQuotaExceededReason, clusterv1.ConditionSeverityWarning, err.Error())
+			}
+			return vm, err
+		}
+
+		// Wait for a slot of the vCenter before cloning the VM.
+		if !acquireTaskSlot(ctx, throttle.Clone) {
+			return vm, nil
+		}
+
+		// Refuse to clone the VM with static IP addresses which are in use.
+		if feature.Gates.Enabled(feature.IPConflictDetection) {
+			if err := reconcileIPConflicts(ctx); err != nil {
+				return vm, err
+			}
+		}
+
+		if err := ctx.LifecycleHooks.Run(ctx, hooks.NewRequest(hooks.PreClone, ctx.VSphereVM)); err != nil {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.LifecycleHookFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return vm, err
+		}
+
 		// Get the bootstrap data.
 		bootstrapData, err := vms.getBootstrapData(ctx)
 		if err != nil {
@@ -98,9 +145,30 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 			return vm, err
 		}
 
+		// Record the instance UUID of the VM before it is cloned, so that the
+		// VM is found by it once the VSphereVM is restored with another UID.
+		// The annotation is patched before the clone starts, as a VSphereVM
+		// backed up while its VM is cloned would not record it otherwise.
+		if setVMInstanceUUID(ctx.VSphereVM, util.GetVMInstanceUUID(ctx.VSphereVM)) {
+			if err := ctx.Patch(); err != nil {
+				return vm, errors.Wrapf(err, "failed to record the instance UUID of %s", ctx)
+			}
+		}
+
 		// Create the VM.
 		err = createVM(ctx, bootstrapData)
 		if err != nil {
+			// The slot of a clone which was not issued is released, so that
+			// it does not hold the slot while it waits to be retried.
+			if ctx.VSphereVM.Status.TaskRef == "" {
+				releaseTaskSlot(ctx)
+			}
+			// A VM which no host can run is retried with a backoff, until
+			// the hosts of its resource pool have the capacity to run it.
+			if errors.Is(err, vcenter.ErrOvercommitLimitReached) {
+				conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.OvercommitLimitReachedReason, clusterv1.ConditionSeverityWarning, err.Error())
+				return vm, err
+			}
 			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		}
 		return vm, nil
@@ -118,38 +186,183 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 		State:     &vm,
 	}
 
+	// An existing VM is only reconciled once it is bound to the VSphereVM.
+	existing := isExistingVM(ctx)
+	if existing {
+		if ok, err := vms.reconcileExistingVMOwner(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+	}
+
+	if err := vms.reconcileInstanceUUID(vmCtx, existing); err != nil {
+		return vm, err
+	}
 	vms.reconcileUUID(vmCtx)
+	vms.reconcileVMRef(vmCtx)
+
+	// The placement, the resources and the customization of an existing VM
+	// are managed outside of CAPV, only its metadata and its power state are
+	// reconciled.
+	if !existing {
+		if ok, err := vms.reconcileFolder(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+		if ok, err := vms.reconcileName(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+	}
 
 	if err := vms.reconcileNetworkStatus(vmCtx); err != nil {
 		return vm, err
 	}
 
-	if ok, err := vms.reconcileMetadata(vmCtx); err != nil || !ok {
+	// The storage consumption is only reported, so failing to observe it
+	// does not hold up the reconciliation of the VM.
+	if err := vms.reconcileStorageStatus(vmCtx); err != nil {
+		ctx.Logger.Error(err, "failed to get the storage consumption of the VM")
+	}
+
+	// The placement is only reported, so failing to observe it does not hold
+	// up the reconciliation of the VM.
+	if feature.Gates.Enabled(feature.NodeTopologyLabels) {
+		if err := vms.reconcilePlacementStatus(vmCtx); err != nil {
+			ctx.Logger.Error(err, "failed to get the placement of the VM")
+		}
+	}
+
+	if err := vms.reconcileEncryption(vmCtx); err != nil {
 		return vm, err
 	}
 
-	if err := vms.reconcileStoragePolicy(vmCtx); err != nil {
+	// The diagnostics are handled before the VM is expected to boot, so the
+	// VMs which fail to boot can be diagnosed.
+	if feature.Gates.Enabled(feature.VMDiagnostics) {
+		vms.reconcileDiagnostics(vmCtx)
+	}
+
+	// The snapshots are checked before the operations, the reconfigurations
+	// and the migrations of the VM they block.
+	if !existing {
+		if ok, err := vms.reconcileSnapshots(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+	}
+
+	// The operations are only processed once no task of the VM is in flight,
+	// and their failures are reported in the status of the VSphereVM.
+	if !vms.reconcileOperation(vmCtx) {
+		return vm, nil
+	}
+
+	if ok, err := vms.reconcileExtraConfig(vmCtx); err != nil || !ok {
 		return vm, err
 	}
 
-	if ok, err := vms.reconcileVMGroupInfo(vmCtx); err != nil || !ok {
+	if ok, err := vms.reconcileWarmPoolRestart(vmCtx); err != nil || !ok {
 		return vm, err
 	}
 
-	if ok, err := vms.reconcilePowerState(vmCtx); err != nil || !ok {
+	if !existing {
+		if err := vms.reconcileStoragePolicy(vmCtx); err != nil {
+			return vm, err
+		}
+
+		if ok, err := vms.reconcileVMGroupInfo(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+
+		if ok, err := vms.reconcileDRSAutomationLevel(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+
+		if ok, err := vms.reconcileHostAffinity(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+	}
+
+	// The VMs of a warm pool are kept powered off until they are claimed,
+	// unless the pool keeps them powered on without bootstrap data.
+	if _, ok := ctx.VSphereVM.Labels[infrav1.WarmPoolLabel]; ok {
+		if ctx.VSphereVM.Annotations[infrav1.WarmPoolPowerStateAnnotation] == string(infrav1.PoweredOnWarmPoolPowerState) {
+			if poweredOn, err := vms.reconcilePowerState(vmCtx); err != nil || !poweredOn {
+				return vm, err
+			}
+		}
+		vm.State = infrav1.VirtualMachineStateReady
+		return vm, nil
+	}
+
+	poweredOn, err := vms.reconcilePowerState(vmCtx)
+	if err != nil {
 		return vm, err
 	}
 
-	if err := vms.reconcileTags(vmCtx); err != nil {
-		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.TagsAttachmentFailedReason, clusterv1.ConditionSeverityError, err.Error())
+	// The tags and the cluster module membership do not depend on the power
+	// state of the VM, so they are reconciled while the VM is powering on
+	// rather than once it reports its addresses.
+	if !existing {
+		if err := vms.reconcileTags(vmCtx); err != nil {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.TagsAttachmentFailedReason, clusterv1.ConditionSeverityError, err.Error())
+			return vm, err
+		}
+
+		if err := vms.reconcileClusterModuleMembership(vmCtx); err != nil {
+			return vm, err
+		}
+	}
+
+	if !poweredOn {
+		return vm, nil
+	}
+
+	// The clock skew is only reported, so failing to observe it does not
+	// hold up the reconciliation of the VM.
+	if err := vms.reconcileClockSkew(vmCtx); err != nil {
+		ctx.Logger.Error(err, "failed to get the clock reported by the guest of the VM")
+	}
+
+	// The activation of the guest is only reported, so failing to observe it
+	// does not hold up the reconciliation of the VM.
+	if err := vms.reconcileWindowsActivation(vmCtx); err != nil {
+		ctx.Logger.Error(err, "failed to get the activation status reported by the guest of the VM")
+	}
+
+	// The health of the VM is only reported, so failing to observe it does
+	// not hold up the reconciliation of the VM.
+	if err := vms.reconcileVMHealth(vmCtx); err != nil {
+		ctx.Logger.Error(err, "failed to get the health of the VM")
+	}
+
+	if ok, err := vms.reconcileBootTimeouts(vmCtx); err != nil || !ok {
 		return vm, err
 	}
 
+	if !existing {
+		// The drift is checked before the resources and the network devices
+		// are updated, so the updates in flight are not reported as drift.
+		if ok, err := vms.reconcileDrift(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+
+		if ok, err := vms.reconcileGuestCustomization(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+
+		if ok, err := vms.reconcileResources(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+
+		if ok, err := vms.reconcileNetworkDevices(vmCtx); err != nil || !ok {
+			return vm, err
+		}
+	}
+
 	vm.State = infrav1.VirtualMachineStateReady
 	return vm, nil
 }
 
-// DestroyVM powers off and destroys a virtual machine.
+// DestroyVM powers off and destroys a virtual machine, unless the deletion
+// policy of the VSphereVM retains it.
 func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine, error) {
 	vm := infrav1.VirtualMachine{
 		Name:  ctx.VSphereVM.Name,
@@ -192,25 +405,45 @@ func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine,
 		State:     &vm,
 	}
 
-	// Power off the VM.
-	powerState, err := vms.getPowerState(vmCtx)
-	if err != nil {
-		return vm, err
-	}
-	if powerState == infrav1.VirtualMachinePowerStatePoweredOn {
-		task, err := vmCtx.Obj.PowerOff(ctx)
-		if err != nil {
+	// An existing VM is released rather than destroyed, unless its deletion
+	// policy is Delete.
+	if isExistingVM(ctx) {
+		released, destroy, err := vms.releaseExistingVM(vmCtx)
+		if err != nil || !released {
 			return vm, err
 		}
-		ctx.VSphereVM.Status.TaskRef = task.Reference().Value
-		if err = ctx.Patch(); err != nil {
-			ctx.Logger.Error(err, "patch failed", "vm", ctx.String())
+		if !destroy {
+			vm.State = infrav1.VirtualMachineStateNotFound
+			return vm, nil
+		}
+	}
+
+	// A retained VM is left in vCenter, the VSphereVM is deleted as if the
+	// VM no longer existed.
+	if !isExistingVM(ctx) && isRetained(vmCtx) {
+		if ok, err := vms.reconcileRetention(vmCtx); err != nil || !ok {
 			return vm, err
 		}
-		ctx.Logger.Info("wait for VM to be powered off")
+		vm.State = infrav1.VirtualMachineStateNotFound
 		return vm, nil
 	}
 
+	// Apply the attached volumes policy while the volumes are still in use
+	// by the guest, before the VM is powered off.
+	if ok, err := vms.reconcileAttachedVolumes(vmCtx); err != nil || !ok {
+		return vm, err
+	}
+
+	// Power off the VM.
+	if ok, err := vms.reconcilePowerOff(vmCtx); err != nil || !ok {
+		return vm, err
+	}
+
+	// Wipe the disks of the VM, if requested, once it is powered off.
+	if ok, err := vms.reconcileDiskWipe(vmCtx); err != nil || !ok {
+		return vm, err
+	}
+
 	// At this point the VM is not powered on and can be destroyed. Store the
 	// destroy task's reference and return a requeue error.
 	ctx.Logger.Info("destroying vm")
@@ -223,6 +456,94 @@ func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine,
 	return vm, nil
 }
 
+// reconcilePowerOff powers off the VM before it is destroyed. Unless the power
+// off mode of the VSphereVM is hard, the guest OS is shut down first. It
+// returns true once the VM is no longer powered on.
+func (vms *VMService) reconcilePowerOff(ctx *virtualMachineContext) (bool, error) {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"runtime.powerState", "guest.toolsRunningStatus"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "failed to get the power state of vm %s", ctx)
+	}
+	if obj.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+		if conditions.GetReason(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition) == infrav1.GuestSoftPowerOffInProgressReason {
+			conditions.MarkTrue(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition)
+		}
+		return true, nil
+	}
+
+	if mode := ctx.VSphereVM.Spec.PowerOffMode; mode == infrav1.SoftPowerOffMode || mode == infrav1.TrySoftPowerOffMode {
+		toolsRunning := obj.Guest != nil && obj.Guest.ToolsRunningStatus == string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)
+		if ok, err := vms.reconcileGuestShutdown(ctx, toolsRunning); err != nil || !ok {
+			return false, err
+		}
+	}
+
+	if !acquireTaskSlot(&ctx.VMContext, throttle.Power) {
+		return false, nil
+	}
+	task, err := ctx.Obj.PowerOff(ctx)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to trigger power off op for vm %s", ctx)
+	}
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	if err = ctx.Patch(); err != nil {
+		ctx.Logger.Error(err, "patch failed", "vm", ctx.String())
+		return false, err
+	}
+	ctx.Logger.Info("wait for VM to be powered off")
+	return false, nil
+}
+
+// reconcileGuestShutdown shuts down the guest OS of the VM through VMware
+// Tools, and waits for the VM to be powered off. It returns true when the VM
+// should be powered off instead, which only happens in the trySoft mode when
+// VMware Tools is not running or the shutdown exceeded its timeout.
+func (vms *VMService) reconcileGuestShutdown(ctx *virtualMachineContext, toolsRunning bool) (bool, error) {
+	trySoft := ctx.VSphereVM.Spec.PowerOffMode == infrav1.TrySoftPowerOffMode
+	timeout := defaultGuestSoftPowerOffTimeout
+	if ctx.VSphereVM.Spec.GuestSoftPowerOffTimeout != nil {
+		timeout = ctx.VSphereVM.Spec.GuestSoftPowerOffTimeout.Duration
+	}
+
+	condition := conditions.Get(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition)
+	switch {
+	case condition != nil && condition.Reason == infrav1.GuestSoftPowerOffInProgressReason:
+		if trySoft && time.Since(condition.LastTransitionTime.Time) >= timeout {
+			ctx.Logger.Info("guest OS shutdown timed out, powering off", "timeout", timeout)
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition, infrav1.GuestSoftPowerOffFailedReason, clusterv1.ConditionSeverityWarning,
+				"the guest OS was not shut down within %s", timeout)
+			return true, nil
+		}
+		ctx.Logger.Info("wait for the guest OS to be shut down")
+		return false, nil
+	case condition != nil && condition.Reason == infrav1.GuestSoftPowerOffFailedReason && trySoft:
+		return true, nil
+	case !toolsRunning:
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition, infrav1.GuestSoftPowerOffFailedReason, clusterv1.ConditionSeverityWarning,
+			"VMware Tools is not running")
+		if trySoft {
+			ctx.Logger.Info("VMware Tools is not running, powering off")
+			return true, nil
+		}
+		return false, errors.Errorf("failed to shut down the guest OS of vm %s: VMware Tools is not running", ctx)
+	}
+
+	ctx.Logger.Info("shutting down the guest OS")
+	if err := ctx.Obj.ShutdownGuest(ctx); err != nil {
+		return false, errors.Wrapf(err, "failed to shut down the guest OS of vm %s", ctx)
+	}
+	conditions.MarkFalse(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition, infrav1.GuestSoftPowerOffInProgressReason, clusterv1.ConditionSeverityInfo, "")
+
+	// A reconcile request is triggered once the VM is powered off or, in the
+	// trySoft mode, once the shutdown times out.
+	var deadline time.Time
+	if trySoft {
+		deadline = time.Now().Add(timeout)
+	}
+	reconcileVSphereVMWhenPoweredOff(ctx, deadline)
+	return false, nil
+}
+
 func (vms *VMService) reconcileNetworkStatus(ctx *virtualMachineContext) error {
 	netStatus, err := vms.getNetworkStatus(ctx)
 	if err != nil {
@@ -232,30 +553,343 @@ func (vms *VMService) reconcileNetworkStatus(ctx *virtualMachineContext) error {
 	return nil
 }
 
-func (vms *VMService) reconcileMetadata(ctx *virtualMachineContext) (bool, error) {
-	existingMetadata, err := vms.getMetadata(ctx)
+// reconcileStorageStatus records the storage consumption of the VM and the
+// capacity of its datastores in the status of the VSphereVM.
+func (vms *VMService) reconcileStorageStatus(ctx *virtualMachineContext) error {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"storage"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to fetch storage usage for %q", ctx)
+	}
+	if obj.Storage == nil {
+		return nil
+	}
+
+	refs := make([]types.ManagedObjectReference, 0, len(obj.Storage.PerDatastoreUsage))
+	for _, usage := range obj.Storage.PerDatastoreUsage {
+		refs = append(refs, usage.Datastore)
+	}
+	var datastores []mo.Datastore
+	if len(refs) > 0 {
+		if err := property.DefaultCollector(ctx.Session.Client.Client).Retrieve(ctx, refs, []string{"name", "summary"}, &datastores); err != nil {
+			return errors.Wrapf(err, "unable to fetch the datastores of %q", ctx)
+		}
+	}
+
+	ctx.VSphereVM.Status.Storage = storageStatus(obj.Storage.PerDatastoreUsage, datastores)
+	return nil
+}
+
+// reconcilePlacementStatus records the ESXi host of the VM and the datastore
+// of its configuration files in the status of the VSphereVM.
+func (vms *VMService) reconcilePlacementStatus(ctx *virtualMachineContext) error {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"runtime.host", "config.files.vmPathName"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to fetch the placement of %q", ctx)
+	}
+	if obj.Config != nil {
+		var path object.DatastorePath
+		if path.FromString(obj.Config.Files.VmPathName) {
+			ctx.VSphereVM.Status.Datastore = path.Datastore
+		}
+	}
+	if obj.Runtime.Host != nil {
+		var host mo.HostSystem
+		if err := property.DefaultCollector(ctx.Session.Client.Client).RetrieveOne(ctx, *obj.Runtime.Host, []string{"name"}, &host); err != nil {
+			return errors.Wrapf(err, "unable to fetch the host of %q", ctx)
+		}
+		ctx.VSphereVM.Status.Host = host.Name
+	}
+	return nil
+}
+
+// storageStatus returns the storage consumption of a VM from its usage of
+// the given datastores.
+func storageStatus(usages []types.VirtualMachineUsageOnDatastore, datastores []mo.Datastore) *infrav1.VirtualMachineStorageStatus {
+	byRef := make(map[types.ManagedObjectReference]mo.Datastore, len(datastores))
+	for _, datastore := range datastores {
+		byRef[datastore.Reference()] = datastore
+	}
+
+	status := &infrav1.VirtualMachineStorageStatus{}
+	for _, usage := range usages {
+		datastoreUsage := infrav1.VirtualMachineDatastoreUsage{
+			Name:             usage.Datastore.Value,
+			CommittedBytes:   usage.Committed,
+			ProvisionedBytes: usage.Committed + usage.Uncommitted,
+		}
+		if datastore, ok := byRef[usage.Datastore]; ok {
+			summary := datastore.Summary
+			datastoreUsage.Name = datastore.Name
+			datastoreUsage.CapacityBytes = summary.Capacity
+			datastoreUsage.DatastoreProvisionedBytes = summary.Capacity - summary.FreeSpace + summary.Uncommitted
+		}
+		status.CommittedBytes += datastoreUsage.CommittedBytes
+		status.ProvisionedBytes += datastoreUsage.ProvisionedBytes
+		status.Datastores = append(status.Datastores, datastoreUsage)
+	}
+	sort.Slice(status.Datastores, func(i, j int) bool {
+		return status.Datastores[i].Name < status.Datastores[j].Name
+	})
+	return status
+}
+
+// reconcileExtraConfig updates the cloud-init metadata of the VM and, for a VM
+// claimed from a warm pool, which was cloned without bootstrap data, its
+// bootstrap data. The options which changed are set with a single reconfigure
+// of the VM, so that a claimed VM is not reconfigured twice before its first
+// boot.
+func (vms *VMService) reconcileExtraConfig(ctx *virtualMachineContext) (bool, error) {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.extraConfig", "runtime.powerState"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "unable to get extra config of vm %s", ctx)
+	}
+
+	var extraConfig extra.Config
+	metadata, err := vms.getMachineMetadata(ctx)
 	if err != nil {
 		return false, err
 	}
+	if err := extraConfig.SetCloudInitMetadata(metadata); err != nil {
+		return false, errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
+	}
+	// The initramfs of an Ignition guest configures its network before the
+	// guest reads the metadata.
+	if ctx.VSphereVM.Spec.BootstrapFormat == infrav1.IgnitionBootstrapFormat {
+		devices := ipam.ApplyState(ctx.VSphereVM.Spec.Network.Devices, ctx.IPAMState)
+		kargs, err := util.GetMachineNetworkKargs(devices)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to get network kernel arguments of vm %s", ctx)
+		}
+		if kargs != "" {
+			if err := extraConfig.SetNetworkKargs(kargs); err != nil {
+				return false, errors.Wrapf(err, "unable to set network kernel arguments on vm %s", ctx)
+			}
+		}
+	}
+
+	// The bootstrap data of a claimed or an existing VM is only set while the
+	// VM is powered off, before its first boot, or before a VM claimed from a
+	// warm pool keeping its VMs powered on is restarted.
+	_, claimed := ctx.VSphereVM.Annotations[infrav1.WarmPoolVMAnnotation]
+	if ((claimed || isExistingVM(&ctx.VMContext)) && obj.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOff) || isWarmPoolRestartPending(ctx.VSphereVM) {
+		bootstrapData, err := vms.getBootstrapData(&ctx.VMContext)
+		if err != nil {
+			return false, err
+		}
+		if len(bootstrapData) > 0 {
+			if err := extraConfig.SetBootstrapData(ctx.VSphereVM.Spec.BootstrapFormat, bootstrapData); err != nil {
+				return false, errors.Wrapf(err, "unable to set bootstrap data on vm %s", ctx)
+			}
+		}
+	}
+
+	var existing []types.BaseOptionValue
+	if obj.Config != nil {
+		existing = obj.Config.ExtraConfig
+	}
+	changed := changedOptionValues(existing, extraConfig)
+	if len(changed) == 0 {
+		return true, nil
+	}
+
+	if !acquireTaskSlot(&ctx.VMContext, throttle.Reconfigure) {
+		return false, nil
+	}
+	ctx.Logger.Info("updating extra config", "options", len(changed))
+	task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		ExtraConfig: changed,
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to set extra config on vm %s", ctx)
+	}
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	ctx.Logger.Info("wait for VM extra config to be updated")
+	return false, nil
+}
 
-	newMetadata, err := util.GetMachineMetadata(ctx.VSphereVM.Name, *ctx.VSphereVM, ctx.State.Network...)
+// reconcileWarmPoolRestart restarts a VM claimed from a warm pool keeping its
+// VMs powered on once its bootstrap data is set, so that its guest, which
+// booted without bootstrap data, is bootstrapped when it boots again. The VM
+// is only restarted once, and a VM powered off since it was claimed is not
+// restarted, as it boots with its bootstrap data when it is powered on.
+func (vms *VMService) reconcileWarmPoolRestart(ctx *virtualMachineContext) (bool, error) {
+	if !isWarmPoolRestartPending(ctx.VSphereVM) {
+		return true, nil
+	}
+	powerState, err := vms.getPowerState(ctx)
 	if err != nil {
 		return false, err
 	}
-
-	// If the metadata is the same then return early.
-	if string(newMetadata) == existingMetadata {
+	if powerState != infrav1.VirtualMachinePowerStatePoweredOn {
+		delete(ctx.VSphereVM.Annotations, infrav1.WarmPoolPowerStateAnnotation)
 		return true, nil
 	}
 
-	ctx.Logger.Info("updating metadata")
-	taskRef, err := vms.setMetadata(ctx, newMetadata)
+	if !acquireTaskSlot(&ctx.VMContext, throttle.Power) {
+		return false, nil
+	}
+	ctx.Logger.Info("restarting VM claimed from warm pool")
+	task, err := ctx.Obj.Reset(ctx)
 	if err != nil {
-		return false, errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
+		return false, errors.Wrapf(err, "failed to restart vm %s", ctx)
 	}
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	delete(ctx.VSphereVM.Annotations, infrav1.WarmPoolPowerStateAnnotation)
+	ctx.Logger.Info("wait for VM to be restarted")
+	return false, nil
+}
 
-	ctx.VSphereVM.Status.TaskRef = taskRef
-	ctx.Logger.Info("wait for VM metadata to be updated")
+// isWarmPoolRestartPending returns true if the VSphereVM claimed a VM of a
+// warm pool keeping its VMs powered on, which was not restarted yet.
+func isWarmPoolRestartPending(vm *infrav1.VSphereVM) bool {
+	_, claimed := vm.Annotations[infrav1.WarmPoolVMAnnotation]
+	return claimed && vm.Annotations[infrav1.WarmPoolPowerStateAnnotation] == string(infrav1.PoweredOnWarmPoolPowerState)
+}
+
+// getMachineMetadata returns the cloud-init metadata of the VM, which renders
+// the addresses claimed from IPAM pools alongside the static ones.
+func (vms *VMService) getMachineMetadata(ctx *virtualMachineContext) ([]byte, error) {
+	hostname := ctx.Hostname
+	if hostname == "" {
+		hostname = util.GetMachineHostname(ctx.VSphereVM.Spec.VirtualMachineCloneSpec, "", util.GetVMName(ctx.VSphereVM))
+	}
+	vsphereVM := ctx.VSphereVM.DeepCopy()
+	vsphereVM.Spec.Network.Devices = ipam.ApplyState(vsphereVM.Spec.Network.Devices, ctx.IPAMState)
+	return util.GetMachineMetadata(hostname, *vsphereVM, ctx.State.Network...)
+}
+
+// reconcileBootTimeouts fails the VSphereVM when VMware Tools or the IP
+// addresses are not reported within their timeouts after the power on of the
+// VM. Once the VSphereVM is ready, the timeouts no longer apply.
+func (vms *VMService) reconcileBootTimeouts(ctx *virtualMachineContext) (bool, error) {
+	if ctx.VSphereVM.Status.Ready || (ctx.Timeouts.ToolsStart == 0 && ctx.Timeouts.IPAcquisition == 0) {
+		return true, nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Obj.Reference(), []string{"runtime.bootTime", "guest.toolsRunningStatus"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "failed to get the boot time of vm %s", ctx)
+	}
+	if obj.Runtime.BootTime == nil {
+		return true, nil
+	}
+
+	toolsRunning := obj.Guest != nil && obj.Guest.ToolsRunningStatus == string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)
+	hasIPAddrs := false
+	for _, netStatus := range ctx.State.Network {
+		if len(sanitizeIPAddrs(&ctx.VMContext, netStatus.IPAddrs)) > 0 {
+			hasIPAddrs = true
+			break
+		}
+	}
+
+	if phase, timeout := bootTimeoutPhase(ctx.Timeouts, *obj.Runtime.BootTime, toolsRunning, hasIPAddrs, time.Now()); phase != "" {
+		markProvisioningTimeout(&ctx.VMContext, phase, timeout)
+		return false, nil
+	}
+	return true, nil
+}
+
+// reconcileGuestCustomization reports the customization of the guest of a VM
+// cloned with a customization spec, from the status of the customization
+// reported by VMware Tools. It returns false while the guest is customized,
+// and after its customization failed, so the VM is not reported ready before
+// its guest is customized.
+func (vms *VMService) reconcileGuestCustomization(ctx *virtualMachineContext) (bool, error) {
+	if ctx.VSphereVM.Spec.CustomizationSpec == "" || ctx.VSphereVM.Status.Ready {
+		return true, nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Obj.Reference(), []string{"guest.customizationInfo"}, &obj); err != nil {
+		// vCenter reports the status of the customization since 7.0 U2.
+		if isInvalidProperty(err) {
+			return true, nil
+		}
+		return false, errors.Wrapf(err, "failed to get the guest customization status of vm %s", ctx)
+	}
+	var info *types.GuestInfoCustomizationInfo
+	if obj.Guest != nil {
+		info = obj.Guest.CustomizationInfo
+	}
+
+	done, reason, message := guestCustomizationStatus(info)
+	switch reason {
+	case infrav1.CustomizingReason:
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, reason, clusterv1.ConditionSeverityInfo, message)
+	case infrav1.GuestCustomizationFailedReason:
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, reason, clusterv1.ConditionSeverityWarning, message)
+	}
+	return done, nil
+}
+
+// guestCustomizationStatus returns whether the customization of a guest is
+// done, or the reason and the message of the VMProvisioned condition while it
+// is not. A customization whose status is not reported is done.
+func guestCustomizationStatus(info *types.GuestInfoCustomizationInfo) (bool, string, string) {
+	if info == nil {
+		return true, "", ""
+	}
+	switch types.GuestInfoCustomizationStatus(info.CustomizationStatus) {
+	case types.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_PENDING:
+		return false, infrav1.CustomizingReason, "guest customization is pending"
+	case types.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_RUNNING:
+		return false, infrav1.CustomizingReason, "guest customization is running"
+	case types.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_FAILED:
+		message := "guest customization failed"
+		if info.ErrorMsg != "" {
+			message += ": " + info.ErrorMsg
+		}
+		return false, infrav1.GuestCustomizationFailedReason, message
+	default:
+		return true, "", ""
+	}
+}
+
+// reconcileResources hot-adds the changes of the numCPUs, memoryMiB and diskGiB
+// of a ready VSphereVM to the running VM, and marks the VSphereVM as needing a
+// rollout when a change cannot be applied in place.
+func (vms *VMService) reconcileResources(ctx *virtualMachineContext) (bool, error) {
+	if !feature.Gates.Enabled(feature.InPlaceResourceUpdate) || !ctx.VSphereVM.Status.Ready {
+		return true, nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Obj.Reference(), []string{"config.hardware", "config.cpuHotAddEnabled", "config.memoryHotAddEnabled"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "failed to get the hardware of vm %s", ctx)
+	}
+	if obj.Config == nil {
+		return true, nil
+	}
+
+	spec, needsRollout := resourceUpdate(ctx.VSphereVM, obj.Config)
+	if len(needsRollout) > 0 {
+		conditions.Set(ctx.VSphereVM, &clusterv1.Condition{
+			Type:    infrav1.MachineNeedsRolloutCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  infrav1.ResourcesNotHotPluggableReason,
+			Message: fmt.Sprintf("%s cannot be updated in place", strings.Join(needsRollout, ", ")),
+		})
+	} else {
+		conditions.Delete(ctx.VSphereVM, infrav1.MachineNeedsRolloutCondition)
+	}
+	if spec == nil {
+		return true, nil
+	}
+
+	if err := blockedBySnapshots(ctx); err != nil {
+		ctx.Logger.Info("not updating resources in place", "reason", err.Error())
+		return true, nil
+	}
+	if !acquireTaskSlot(&ctx.VMContext, throttle.Reconfigure) {
+		return false, nil
+	}
+	ctx.Logger.Info("updating resources in place", "numCPUs", spec.NumCPUs, "memoryMiB", spec.MemoryMB, "diskChanges", len(spec.DeviceChange))
+	task, err := ctx.Obj.Reconfigure(ctx, *spec)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to trigger reconfigure op for vm %s", ctx)
+	}
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
 	return false, nil
 }
 
@@ -266,6 +900,17 @@ func (vms *VMService) reconcilePowerState(ctx *virtualMachineContext) (bool, err
 	}
 	switch powerState {
 	case infrav1.VirtualMachinePowerStatePoweredOff:
+		if !acquireTaskSlot(&ctx.VMContext, throttle.Power) {
+			return false, nil
+		}
+
+		// CAPV only powers off a VM which has been running before it is
+		// destroyed, so this one was powered off outside of Kubernetes.
+		if conditions.Has(ctx.VSphereVM, infrav1.VMRunningCondition) && conditions.GetReason(ctx.VSphereVM, infrav1.VMRunningCondition) != infrav1.PoweredOffExternallyReason {
+			ctx.Recorder.Warnf(ctx.VSphereVM, "VMPoweredOffExternally", "VM %s was powered off outside of Kubernetes, powering it on", ctx.VSphereVM.Name)
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMRunningCondition, infrav1.PoweredOffExternallyReason, clusterv1.ConditionSeverityWarning,
+				"the VM was powered off outside of Kubernetes")
+		}
 		ctx.Logger.Info("powering on")
 		task, err := ctx.Obj.PowerOn(ctx)
 		if err != nil {
@@ -330,9 +975,27 @@ func (vms *VMService) reconcileStoragePolicy(ctx *virtualMachineContext) error {
 		return err
 	}
 
+	// Data disks with their own storage policy are associated with it when
+	// they are created.
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Obj.Reference(), []string{"config.extraConfig"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to get extra config of vm %s", ctx)
+	}
+	var dataDisks map[string]string
+	if obj.Config != nil {
+		dataDisks = extra.DataDisks(obj.Config.ExtraConfig)
+	}
+	ownPolicy := map[string]bool{}
+	for _, disk := range ctx.VSphereVM.Spec.DataDisks {
+		ownPolicy[disk.Name] = disk.StoragePolicyName != "" && disk.StoragePolicyName != ctx.VSphereVM.Spec.StoragePolicyName
+	}
+
 	disks := devices.SelectByType((*types.VirtualDisk)(nil))
 	for _, d := range disks {
 		disk := d.(*types.VirtualDisk) //nolint:forcetypeassert
+		if disk.UnitNumber != nil && ownPolicy[dataDiskName(dataDisks, devices, disk)] {
+			continue
+		}
 		found := false
 		// entities associated with storage policy has key in the form <vm-ID>:<disk>
 		diskID := fmt.Sprintf("%s:%d", ctx.Obj.Reference().Value, disk.Key)
@@ -371,86 +1034,143 @@ func (vms *VMService) reconcileStoragePolicy(ctx *virtualMachineContext) error {
 	return nil
 }
 
-func (vms *VMService) reconcileUUID(ctx *virtualMachineContext) {
-	ctx.State.BiosUUID = ctx.Obj.UUID(ctx)
+// dataDiskName returns the name of a data disk of a VM from the locations
+// recorded in its extra config, by the key of its controller, or by the bus of
+// its controller for a controller added with the VM. It returns an empty
+// string for the disks which are not data disks.
+func dataDiskName(dataDisks map[string]string, devices object.VirtualDeviceList, disk *types.VirtualDisk) string {
+	if name, ok := dataDisks[extra.DataDiskLocation(disk.ControllerKey, *disk.UnitNumber)]; ok {
+		return name
+	}
+	if bus := extra.ControllerBus(devices.FindByKey(disk.ControllerKey)); bus != "" {
+		return dataDisks[extra.DataDiskBusLocation(bus, *disk.UnitNumber)]
+	}
+	return ""
 }
 
-func (vms *VMService) getPowerState(ctx *virtualMachineContext) (infrav1.VirtualMachinePowerState, error) {
-	powerState, err := ctx.Obj.PowerState(ctx)
-	if err != nil {
-		return "", err
+// reconcileEncryption reports in the EncryptionReady condition whether the VM
+// of a VSphereVM with encryption is encrypted, including an existing VM, which
+// is never encrypted by CAPV.
+func (vms *VMService) reconcileEncryption(ctx *virtualMachineContext) error {
+	if ctx.VSphereVM.Spec.Encryption == nil {
+		conditions.Delete(ctx.VSphereVM, infrav1.EncryptionReadyCondition)
+		return nil
 	}
-
-	switch powerState {
-	case types.VirtualMachinePowerStatePoweredOn:
-		return infrav1.VirtualMachinePowerStatePoweredOn, nil
-	case types.VirtualMachinePowerStatePoweredOff:
-		return infrav1.VirtualMachinePowerStatePoweredOff, nil
-	case types.VirtualMachinePowerStateSuspended:
-		return infrav1.VirtualMachinePowerStateSuspended, nil
-	default:
-		return "", errors.Errorf("unexpected power state %q for vm %s", powerState, ctx)
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.keyId"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to get the encryption key of vm %s", ctx)
+	}
+	if obj.Config == nil || obj.Config.KeyId == nil {
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.EncryptionReadyCondition, infrav1.VMNotEncryptedReason, clusterv1.ConditionSeverityError,
+			"vm %s is not encrypted", ctx.Ref.Value)
+		return nil
 	}
+	conditions.MarkTrue(ctx.VSphereVM, infrav1.EncryptionReadyCondition)
+	return nil
 }
 
-func (vms *VMService) getMetadata(ctx *virtualMachineContext) (string, error) {
-	var (
-		obj mo.VirtualMachine
-
-		pc    = property.DefaultCollector(ctx.Session.Client.Client)
-		props = []string{"config.extraConfig"}
-	)
-
-	if err := pc.RetrieveOne(ctx, ctx.Ref, props, &obj); err != nil {
-		return "", errors.Wrapf(err, "unable to fetch props %v for vm %s", props, ctx)
-	}
-	if obj.Config == nil {
-		return "", nil
-	}
-
-	var metadataBase64 string
-	for _, ec := range obj.Config.ExtraConfig {
-		if optVal := ec.GetOptionValue(); optVal != nil {
-			// TODO(akutz) Using a switch instead of if in case we ever
-			//             want to check the metadata encoding as well.
-			//             Since the image stamped images always use
-			//             base64, it should be okay to not check.
-			//nolint:gocritic
-			switch optVal.Key {
-			case guestInfoKeyMetadata:
-				if v, ok := optVal.Value.(string); ok {
-					metadataBase64 = v
-				}
-			}
+// reconcileInstanceUUID records the instance UUID of the VM on the VSphereVM,
+// for the VSphereVMs created before it was recorded, and reports the VMs
+// adopted by a VSphereVM which records their instance UUID but has no record
+// of cloning them, e.g. after it is restored from a backup.
+func (vms *VMService) reconcileInstanceUUID(ctx *virtualMachineContext, existing bool) error {
+	if uuid := ctx.VSphereVM.Annotations[infrav1.VMInstanceUUIDAnnotation]; uuid != "" {
+		if !existing && ctx.VSphereVM.Status.VMRef == nil && ctx.VSphereVM.Status.CloneMode == "" {
+			ctx.Logger.Info("adopting the vm", "vmref", ctx.Ref, "instanceUUID", uuid)
+			ctx.Recorder.Eventf(ctx.VSphereVM, "VMAdopted", "Adopted the existing VM %s with instance UUID %s", ctx.Ref.Value, uuid)
 		}
+		return nil
+	}
+	// An existing VM keeps its own instance UUID, and is bound to the UID of
+	// the VSphereVM instead.
+	if existing {
+		setVMInstanceUUID(ctx.VSphereVM, string(ctx.VSphereVM.UID))
+		return nil
+	}
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.instanceUuid"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to get the instance UUID of vm %s", ctx)
 	}
+	if obj.Config != nil && obj.Config.InstanceUuid != "" {
+		setVMInstanceUUID(ctx.VSphereVM, obj.Config.InstanceUuid)
+	}
+	return nil
+}
 
-	if metadataBase64 == "" {
-		return "", nil
+// setVMInstanceUUID records the instance UUID of the VM of the VSphereVM, if
+// it is not recorded yet, and returns whether it was recorded.
+func setVMInstanceUUID(vsphereVM *infrav1.VSphereVM, uuid string) bool {
+	if vsphereVM.Annotations[infrav1.VMInstanceUUIDAnnotation] != "" {
+		return false
+	}
+	if vsphereVM.Annotations == nil {
+		vsphereVM.Annotations = map[string]string{}
 	}
+	vsphereVM.Annotations[infrav1.VMInstanceUUIDAnnotation] = uuid
+	return true
+}
 
-	metadataBuf, err := base64.StdEncoding.DecodeString(metadataBase64)
-	if err != nil {
-		return "", errors.Wrapf(err, "unable to decode metadata for %s", ctx)
+func (vms *VMService) reconcileUUID(ctx *virtualMachineContext) {
+	ctx.State.BiosUUID = ctx.Obj.UUID(ctx)
+}
+
+// reconcileVMRef records the managed object reference of the VM, and the
+// links to the VM in the vSphere Client, in the status of the VSphereVM.
+func (vms *VMService) reconcileVMRef(ctx *virtualMachineContext) {
+	serverGUID := ctx.Session.Client.ServiceContent.About.InstanceUuid
+	ctx.VSphereVM.Status.VMRef = vmReference(ctx.Session.Client.URL(), serverGUID, ctx.Ref, util.GetVMName(ctx.VSphereVM))
+}
+
+// vmReference returns the reference to a VM of the vCenter at the given URL.
+// The vSphere Client identifies a VM with its managed object ID and the
+// instance UUID of its vCenter, so the links are only set with the latter.
+func vmReference(serverURL *url.URL, serverGUID string, ref types.ManagedObjectReference, name string) *infrav1.VirtualMachineReference {
+	vmRef := &infrav1.VirtualMachineReference{
+		Value:      ref.Value,
+		ServerGUID: serverGUID,
+	}
+	if serverGUID == "" {
+		return vmRef
 	}
 
-	return string(metadataBuf), nil
+	host := serverURL.Host
+	if serverURL.Port() == "" {
+		host += ":443"
+	}
+	vmRef.WebClientURL = fmt.Sprintf("https://%s/ui/app/vm;nav=h/urn:vmomi:VirtualMachine:%s:%s/summary", serverURL.Host, ref.Value, serverGUID)
+	vmRef.ConsoleURL = fmt.Sprintf("https://%s/ui/webconsole.html?%s", serverURL.Host, url.Values{
+		"vmId":       {ref.Value},
+		"vmName":     {name},
+		"serverGuid": {serverGUID},
+		"host":       {host},
+	}.Encode())
+	return vmRef
 }
 
-func (vms *VMService) setMetadata(ctx *virtualMachineContext, metadata []byte) (string, error) {
-	var extraConfig extra.Config
-	if err := extraConfig.SetCloudInitMetadata(metadata); err != nil {
-		return "", errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
+func (vms *VMService) getPowerState(ctx *virtualMachineContext) (infrav1.VirtualMachinePowerState, error) {
+	powerState, err := ctx.Obj.PowerState(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
-		ExtraConfig: extraConfig,
-	})
+	state, err := toPowerState(powerState)
 	if err != nil {
-		return "", errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
+		return "", errors.Wrapf(err, "unexpected power state for vm %s", ctx)
 	}
+	return state, nil
+}
 
-	return task.Reference().Value, nil
+func toPowerState(powerState types.VirtualMachinePowerState) (infrav1.VirtualMachinePowerState, error) {
+	switch powerState {
+	case types.VirtualMachinePowerStatePoweredOn:
+		return infrav1.VirtualMachinePowerStatePoweredOn, nil
+	case types.VirtualMachinePowerStatePoweredOff:
+		return infrav1.VirtualMachinePowerStatePoweredOff, nil
+	case types.VirtualMachinePowerStateSuspended:
+		return infrav1.VirtualMachinePowerStateSuspended, nil
+	default:
+		return "", errors.Errorf("unexpected power state %q", powerState)
+	}
 }
 
 func (vms *VMService) getNetworkStatus(ctx *virtualMachineContext) ([]infrav1.NetworkStatus, error) {
@@ -491,6 +1211,32 @@ func (vms *VMService) getBootstrapData(ctx *context.VMContext) ([]byte, error) {
 		return nil, errors.New("error retrieving bootstrap data: secret value key is missing")
 	}
 
+	if len(ctx.KubeVIPManifest) > 0 {
+		var err error
+		value, err = kubevip.InjectManifest(ctx.VSphereVM.Spec.BootstrapFormat, value, ctx.KubeVIPManifest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to add the kube-vip manifest to the bootstrap data of %s", ctx)
+		}
+	}
+
+	// The NTP and proxy settings, the timer reporting the guest clock and the
+	// network configuration of the hot-added NICs are written for Linux
+	// guests.
+	patch := bootstrapdata.NetworkPatch(ctx.VSphereVM.Spec.Network)
+	if timeSync := ctx.VSphereVM.Spec.TimeSync; timeSync != nil && timeSync.MaxClockSkew != nil {
+		patch = patch.Merge(bootstrapdata.ClockReportPatch())
+	}
+	if feature.Gates.Enabled(feature.NetworkDeviceHotAdd) {
+		patch = patch.Merge(bootstrapdata.NetworkHotplugPatch())
+	}
+	if !patch.IsEmpty() && ctx.VSphereVM.Spec.OS != infrav1.WindowsOS {
+		var err error
+		value, err = bootstrapdata.Apply(ctx.VSphereVM.Spec.BootstrapFormat, value, patch)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to add the NTP, proxy, clock report and network hotplug settings to the bootstrap data of %s", ctx)
+		}
+	}
+
 	return value, nil
 }
 
@@ -523,7 +1269,289 @@ func (vms *VMService) reconcileVMGroupInfo(ctx *virtualMachineContext) (bool, er
 	return true, nil
 }
 
+// reconcileFolder reports the folder of the VM in the status of the VSphereVM.
+// A VM moved out of its folder in vCenter, e.g. by an administrator, is moved
+// back into it when the folder relocation policy of the VSphereVM restores
+// it, and is otherwise kept in its new folder.
+func (vms *VMService) reconcileFolder(ctx *virtualMachineContext) (bool, error) {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"parent"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "unable to get the folder of VM %s", ctx.VSphereVM.Name)
+	}
+	if obj.Parent == nil {
+		return true, nil
+	}
+
+	folder, err := ctx.Session.Finder.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to find folder %q of VM %s", ctx.VSphereVM.Spec.Folder, ctx.VSphereVM.Name)
+	}
+	if *obj.Parent == folder.Reference() {
+		ctx.VSphereVM.Status.Folder = folder.InventoryPath
+		return true, nil
+	}
+
+	current, err := find.InventoryPath(ctx, ctx.Session.Client.Client, *obj.Parent)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to get the inventory path of the folder of VM %s", ctx.VSphereVM.Name)
+	}
+	if ctx.VSphereVM.Spec.FolderRelocationPolicy != infrav1.RestoreFolderRelocationPolicy {
+		if ctx.VSphereVM.Status.Folder != current {
+			ctx.Logger.Info("VM was moved out of its folder", "folder", folder.InventoryPath, "currentFolder", current)
+		}
+		ctx.VSphereVM.Status.Folder = current
+		return true, nil
+	}
+
+	ctx.Logger.Info("moving VM back into its folder", "folder", folder.InventoryPath, "currentFolder", current)
+	task, err := folder.MoveInto(ctx, []types.ManagedObjectReference{ctx.Ref})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to move VM %s into folder %s", ctx.VSphereVM.Name, folder.InventoryPath)
+	}
+	ctx.Recorder.Eventf(ctx.VSphereVM, "VMFolderRestored", "Moving VM %s back from folder %s into folder %s", ctx.VSphereVM.Name, current, folder.InventoryPath)
+	ctx.VSphereVM.Status.Folder = current
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	return false, nil
+}
+
+// reconcileName reports the name of the VM in the status of the VSphereVM. A
+// VM renamed in vCenter, e.g. by an administrator, is renamed back when the
+// rename policy of the VSphereVM restores it, and otherwise keeps its new
+// name.
+func (vms *VMService) reconcileName(ctx *virtualMachineContext) (bool, error) {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"name"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "unable to get the name of VM %s", ctx.VSphereVM.Name)
+	}
+
+	name := util.GetVMName(ctx.VSphereVM)
+	if obj.Name == name {
+		ctx.VSphereVM.Status.VMName = name
+		return true, nil
+	}
+
+	if ctx.VSphereVM.Spec.RenamePolicy != infrav1.RestoreRenamePolicy {
+		if ctx.VSphereVM.Status.VMName != obj.Name {
+			ctx.Logger.Info("VM was renamed", "name", name, "currentName", obj.Name)
+		}
+		ctx.VSphereVM.Status.VMName = obj.Name
+		return true, nil
+	}
+
+	ctx.Logger.Info("renaming VM back", "name", name, "currentName", obj.Name)
+	task, err := ctx.Obj.Rename(ctx, name)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to rename VM %s back from %s", name, obj.Name)
+	}
+	ctx.Recorder.Eventf(ctx.VSphereVM, "VMRenameRestored", "Renaming VM %s back to %s", obj.Name, name)
+	ctx.VSphereVM.Status.VMName = obj.Name
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	return false, nil
+}
+
+// reconcileDRSAutomationLevel sets the DRS override of the VM in its compute
+// cluster to the DRS automation level of the VSphereVM, before the VM is
+// powered on so that DRS applies it to the placement of the VM.
+func (vms *VMService) reconcileDRSAutomationLevel(ctx *virtualMachineContext) (bool, error) {
+	level := ctx.VSphereVM.Spec.DRSAutomationLevel
+	if level == "" {
+		return true, nil
+	}
+
+	ccr, err := cluster.ForVM(ctx, ctx.Obj)
+	if err != nil {
+		return false, err
+	}
+	if ccr == nil {
+		ctx.Logger.Info("VM is not in a compute cluster. skipping reconcile DRS automation level")
+		return true, nil
+	}
+
+	current, err := cluster.FindDRSVMConfig(ctx, ccr, ctx.Ref)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to find the DRS override of VM %s", ctx.VSphereVM.Name)
+	}
+	desired := drsVMConfig(ctx.Ref, level)
+	if current != nil && drsVMConfigMatches(*current, desired) {
+		return true, nil
+	}
+
+	task, err := cluster.SetDRSVMConfig(ctx, ccr, desired, current != nil)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to set the DRS automation level of VM %s", ctx.VSphereVM.Name)
+	}
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	ctx.Logger.Info("wait for the DRS automation level of the VM to be set", "level", level)
+	return false, nil
+}
+
+// reconcileClockSkew compares the skew of the guest clock of the VM from the
+// clock of its ESXi host, reported by the guest, with the maximum clock skew
+// of the VSphereVM, and reports the result in the ClockSynchronized condition.
+func (vms *VMService) reconcileClockSkew(ctx *virtualMachineContext) error {
+	timeSync := ctx.VSphereVM.Spec.TimeSync
+	if timeSync == nil || timeSync.MaxClockSkew == nil {
+		return nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.extraConfig"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to get extra config of vm %s", ctx)
+	}
+	var report string
+	if obj.Config != nil {
+		for _, option := range obj.Config.ExtraConfig {
+			if value := option.GetOptionValue(); value.Key == bootstrapdata.ClockReportKey {
+				report, _ = value.Value.(string)
+			}
+		}
+	}
+	if report == "" {
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.ClockSynchronizedCondition, infrav1.WaitingForClockReportReason, clusterv1.ConditionSeverityInfo, "")
+		return nil
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(report), 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "invalid clock skew %q reported by the guest of vm %s", report, ctx)
+	}
+
+	maxSkew := timeSync.MaxClockSkew.Duration
+	switch skew := time.Duration(seconds) * time.Second; {
+	case skew > maxSkew:
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.ClockSynchronizedCondition, infrav1.ClockSkewedReason, clusterv1.ConditionSeverityWarning,
+			"the guest clock is %s ahead of the clock of its ESXi host, more than the maximum skew of %s", skew, maxSkew)
+	case -skew > maxSkew:
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.ClockSynchronizedCondition, infrav1.ClockSkewedReason, clusterv1.ConditionSeverityWarning,
+			"the guest clock is %s behind the clock of its ESXi host, more than the maximum skew of %s", -skew, maxSkew)
+	default:
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.ClockSynchronizedCondition)
+	}
+	return nil
+}
+
+// reconcileWindowsActivation reports the activation status of the Windows
+// guest of the VM, reported by the guest in the guestinfo of the VM after it
+// activated Windows, in the WindowsActivated condition of the VSphereVM.
+func (vms *VMService) reconcileWindowsActivation(ctx *virtualMachineContext) error {
+	if ctx.VSphereVM.Spec.WindowsActivation == nil {
+		return nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.extraConfig"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to get extra config of vm %s", ctx)
+	}
+	var report string
+	if obj.Config != nil {
+		for _, option := range obj.Config.ExtraConfig {
+			if value := option.GetOptionValue(); value.Key == vcenter.WindowsActivationReportKey {
+				report, _ = value.Value.(string)
+			}
+		}
+	}
+	condition, err := windowsActivationCondition(report)
+	if err != nil {
+		return errors.Wrapf(err, "invalid activation status reported by the guest of vm %s", ctx)
+	}
+	conditions.Set(ctx.VSphereVM, condition)
+	return nil
+}
+
+// windowsActivationCondition returns the WindowsActivated condition of a
+// LicenseStatus of the Windows license reported by a guest, or an empty one
+// when the guest has not reported it yet.
+func windowsActivationCondition(report string) (*clusterv1.Condition, error) {
+	report = strings.TrimSpace(report)
+	if report == "" {
+		return conditions.FalseCondition(infrav1.WindowsActivatedCondition, infrav1.WaitingForActivationReportReason, clusterv1.ConditionSeverityInfo, ""), nil
+	}
+	status, err := strconv.Atoi(report)
+	if err != nil {
+		return nil, errors.Errorf("license status %q is not a number", report)
+	}
+	switch status {
+	case 1:
+		return conditions.TrueCondition(infrav1.WindowsActivatedCondition), nil
+	case 2, 3, 4, 6:
+		return conditions.FalseCondition(infrav1.WindowsActivatedCondition, infrav1.WindowsGracePeriodReason, clusterv1.ConditionSeverityWarning,
+			"the guest is not activated and runs in an activation grace period (license status %d)", status), nil
+	case 0, 5:
+		return conditions.FalseCondition(infrav1.WindowsActivatedCondition, infrav1.WindowsNotActivatedReason, clusterv1.ConditionSeverityError,
+			"the guest is not activated (license status %d)", status), nil
+	default:
+		return nil, errors.Errorf("unknown license status %d", status)
+	}
+}
+
+// reconcileHostAffinity adds the VM to the VM group of the VM-Host affinity
+// rule of the VSphereVM, before the VM is powered on so that DRS places it on
+// the hosts of the host group of the rule.
+func (vms *VMService) reconcileHostAffinity(ctx *virtualMachineContext) (bool, error) {
+	spec := ctx.VSphereVM.Spec.HostAffinity
+	if spec == nil {
+		return true, nil
+	}
+
+	ccr, err := cluster.ForVM(ctx, ctx.Obj)
+	if err != nil {
+		return false, err
+	}
+	if ccr == nil {
+		ctx.Logger.Info("VM is not in a compute cluster. skipping reconcile host affinity")
+		return true, nil
+	}
+
+	affinity := cluster.ManagedVMHostAffinity(spec.HostGroupName)
+	create := true
+	if spec.RuleName != "" {
+		rule, err := cluster.FindVMHostAffinity(ctx, ccr, spec.RuleName)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to find VM-Host affinity rule %s", spec.RuleName)
+		}
+		if rule == nil {
+			return false, errors.Errorf("cannot find VM-Host affinity rule %s", spec.RuleName)
+		}
+		affinity, create = *rule, false
+	}
+
+	if err := cluster.AddVMToVMHostAffinity(ctx, ccr, affinity, ctx.Ref, create); err != nil {
+		return false, errors.Wrapf(err, "failed to add VM %s to VM-Host affinity rule %s", ctx.VSphereVM.Name, affinity.RuleName)
+	}
+	return true, nil
+}
+
+// drsVMConfig returns the DRS override of a VM for a DRS automation level.
+// The VMs whose automation level is Disabled keep the behavior of the
+// cluster, which DRS ignores for them.
+func drsVMConfig(vmRef types.ManagedObjectReference, level infrav1.DRSAutomationLevel) types.ClusterDrsVmConfigInfo {
+	enabled := level != infrav1.DisabledDRSAutomationLevel
+	info := types.ClusterDrsVmConfigInfo{Key: vmRef, Enabled: &enabled}
+	switch level {
+	case infrav1.FullyAutomatedDRSAutomationLevel:
+		info.Behavior = types.DrsBehaviorFullyAutomated
+	case infrav1.PartiallyAutomatedDRSAutomationLevel:
+		info.Behavior = types.DrsBehaviorPartiallyAutomated
+	case infrav1.ManualDRSAutomationLevel:
+		info.Behavior = types.DrsBehaviorManual
+	}
+	return info
+}
+
+// drsVMConfigMatches returns whether the current DRS override of a VM applies
+// the desired one. The behavior of a disabled override does not matter.
+func drsVMConfigMatches(current, desired types.ClusterDrsVmConfigInfo) bool {
+	enabled := current.Enabled == nil || *current.Enabled
+	if enabled != *desired.Enabled {
+		return false
+	}
+	return desired.Behavior == "" || current.Behavior == desired.Behavior
+}
+
 func (vms *VMService) reconcileTags(ctx *virtualMachineContext) error {
+	if err := vms.reconcileManagedTags(ctx); err != nil {
+		return err
+	}
+
 	if len(ctx.VSphereVM.Spec.TagIDs) == 0 {
 		ctx.Logger.Info("no tags defined. skipping tags reconciliation")
 		return nil
@@ -536,3 +1564,68 @@ func (vms *VMService) reconcileTags(ctx *virtualMachineContext) error {
 
 	return nil
 }
+
+// reconcileManagedTags attaches the tags of the cluster of the VM, of its
+// namespace and of the role of its machine, which are created on demand.
+func (vms *VMService) reconcileManagedTags(ctx *virtualMachineContext) error {
+	if !feature.Gates.Enabled(feature.ManagedTags) {
+		return nil
+	}
+	clusterName, ok := ctx.VSphereVM.Labels[clusterv1.ClusterLabelName]
+	if !ok {
+		ctx.Logger.V(4).Info("VM has no cluster label. skipping managed tags reconciliation")
+		return nil
+	}
+
+	role := metadata.WorkerRoleTag
+	if _, ok := ctx.VSphereVM.Labels[clusterv1.MachineControlPlaneLabelName]; ok {
+		role = metadata.ControlPlaneRoleTag
+	}
+	managedTags := map[string]string{
+		metadata.ClusterTagCategory:     metadata.ClusterTagName(ctx.VSphereVM.Namespace, clusterName),
+		metadata.NamespaceTagCategory:   ctx.VSphereVM.Namespace,
+		metadata.MachineRoleTagCategory: role,
+	}
+
+	tagIDs := make([]string, 0, len(managedTags))
+	for category, tag := range managedTags {
+		tagID, err := metadata.EnsureManagedTag(ctx, ctx.Session.TagManager, category, tag)
+		if err != nil {
+			return err
+		}
+		tagIDs = append(tagIDs, tagID)
+	}
+
+	if err := ctx.Session.TagManager.AttachMultipleTagsToObject(ctx, tagIDs, ctx.Ref); err != nil {
+		for category, tag := range managedTags {
+			metadata.ForgetManagedTag(ctx.Session.TagManager, category, tag)
+		}
+		return errors.Wrapf(err, "failed to attach managed tags to VM %s", ctx.VSphereVM.Name)
+	}
+	return nil
+}
+
+// reconcileClusterModuleMembership adds the VM to the cluster module of the
+// object owning its Machine, so that the VMs of the object are placed on
+// different ESXi hosts.
+func (vms *VMService) reconcileClusterModuleMembership(ctx *virtualMachineContext) error {
+	if ctx.ClusterModuleInfo == nil {
+		ctx.Logger.V(4).Info("no cluster module defined. skipping cluster module membership reconciliation")
+		return nil
+	}
+
+	moduleUUID := *ctx.ClusterModuleInfo
+	provider := clustermodules.NewProvider(ctx.Session.TagManager.Client)
+	isMember, err := provider.IsMoRefModuleMember(ctx, moduleUUID, ctx.Ref)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check membership of VM %s in cluster module %s", ctx.VSphereVM.Name, moduleUUID)
+	}
+	if !isMember {
+		if err := provider.AddMoRefToModule(ctx, moduleUUID, ctx.Ref); err != nil {
+			return errors.Wrapf(err, "failed to add VM %s to cluster module %s", ctx.VSphereVM.Name, moduleUUID)
+		}
+		ctx.Logger.Info("added VM to cluster module", "moduleUUID", moduleUUID)
+	}
+	ctx.VSphereVM.Status.ModuleUUID = &moduleUUID
+	return nil
+}
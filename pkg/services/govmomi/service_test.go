@@ -0,0 +1,1252 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientrecord "k8s.io/client-go/tools/record"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/bootstrapdata"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/metadata"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/kubevip"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+//nolint:forcetypeassert
+func TestReconcilePowerOff(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	// newContext returns the context of a powered on VM with the power off
+	// mode and, when toolsRunning is set, VMware Tools running.
+	newContext := func(g *WithT, name string, mode infrav1.VirtualMachinePowerOffMode, toolsRunning bool) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Spec.PowerOffMode = mode
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		vm := simulator.Map.Get(obj.Reference()).(*simulator.VirtualMachine)
+		g.Expect(vm.Runtime.PowerState).To(Equal(types.VirtualMachinePowerStatePoweredOn))
+		if toolsRunning {
+			vm.Guest.ToolsRunningStatus = string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)
+		}
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       object.NewVirtualMachine(authSession.Client.Client, vm.Reference()),
+			Ref:       vm.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+	powerState := func(g *WithT, ctx *virtualMachineContext) types.VirtualMachinePowerState {
+		state, err := ctx.Obj.PowerState(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		return state
+	}
+
+	t.Run("powers off the VM in the hard mode", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM0", infrav1.HardPowerOffMode, true)
+
+		ok, err := (&VMService{}).reconcilePowerOff(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+		g.Expect(conditions.Has(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition)).To(BeFalse())
+	})
+
+	t.Run("shuts down the guest OS in the soft mode", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM1", infrav1.SoftPowerOffMode, true)
+
+		ok, err := (&VMService{}).reconcilePowerOff(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition)).To(Equal(infrav1.GuestSoftPowerOffInProgressReason))
+		g.Expect(powerState(g, ctx)).To(Equal(types.VirtualMachinePowerStatePoweredOff))
+
+		ok, err = (&VMService{}).reconcilePowerOff(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition)).To(BeTrue())
+	})
+
+	t.Run("fails to shut down the guest OS without VMware Tools in the soft mode", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM0", infrav1.SoftPowerOffMode, false)
+
+		_, err := (&VMService{}).reconcilePowerOff(ctx)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition)).To(Equal(infrav1.GuestSoftPowerOffFailedReason))
+		g.Expect(powerState(g, ctx)).To(Equal(types.VirtualMachinePowerStatePoweredOn))
+	})
+
+	t.Run("powers off the VM once the guest shutdown times out in the trySoft mode", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM1", infrav1.TrySoftPowerOffMode, true)
+		ctx.VSphereVM.Spec.GuestSoftPowerOffTimeout = &metav1.Duration{Duration: time.Minute}
+
+		shutdownStartedAt := func(startTime time.Time) {
+			ctx.VSphereVM.Status.Conditions = clusterv1.Conditions{{
+				Type:               infrav1.GuestSoftPowerOffSucceededCondition,
+				Status:             corev1.ConditionFalse,
+				Severity:           clusterv1.ConditionSeverityInfo,
+				Reason:             infrav1.GuestSoftPowerOffInProgressReason,
+				LastTransitionTime: metav1.NewTime(startTime),
+			}}
+		}
+
+		// The guest OS is being shut down.
+		shutdownStartedAt(time.Now().Add(-30 * time.Second))
+		ok, err := (&VMService{}).reconcilePowerOff(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+
+		// The shutdown timed out.
+		shutdownStartedAt(time.Now().Add(-time.Minute))
+		ok, err = (&VMService{}).reconcilePowerOff(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.GuestSoftPowerOffSucceededCondition)).To(Equal(infrav1.GuestSoftPowerOffFailedReason))
+	})
+}
+
+func TestReconcileDRSAutomationLevel(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	newContext := func(g *WithT, name string, level infrav1.DRSAutomationLevel) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Spec.DRSAutomationLevel = level
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+	reconcile := func(g *WithT, ctx *virtualMachineContext) bool {
+		ok, err := (&VMService{}).reconcileDRSAutomationLevel(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		if ctx.VSphereVM.Status.TaskRef != "" {
+			task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef})
+			g.Expect(task.Wait(ctx)).To(Succeed())
+			ctx.VSphereVM.Status.TaskRef = ""
+		}
+		return ok
+	}
+
+	t.Run("sets the DRS override of a VM in a compute cluster", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM0", infrav1.ManualDRSAutomationLevel)
+
+		g.Expect(reconcile(g, ctx)).To(BeFalse())
+		g.Expect(reconcile(g, ctx)).To(BeTrue())
+
+		ccr, err := ctx.Session.Finder.ClusterComputeResource(ctx, "DC0_C0")
+		g.Expect(err).NotTo(HaveOccurred())
+		config, err := ccr.Configuration(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(config.DrsVmConfig).To(HaveLen(1))
+		g.Expect(config.DrsVmConfig[0].Key).To(Equal(ctx.Ref))
+		g.Expect(config.DrsVmConfig[0].Behavior).To(Equal(types.DrsBehaviorManual))
+		g.Expect(*config.DrsVmConfig[0].Enabled).To(BeTrue())
+
+		// The override is edited when the automation level changes.
+		ctx.VSphereVM.Spec.DRSAutomationLevel = infrav1.DisabledDRSAutomationLevel
+		g.Expect(reconcile(g, ctx)).To(BeFalse())
+		g.Expect(reconcile(g, ctx)).To(BeTrue())
+		config, err = ccr.Configuration(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(config.DrsVmConfig).To(HaveLen(1))
+		g.Expect(*config.DrsVmConfig[0].Enabled).To(BeFalse())
+	})
+
+	t.Run("skips a VM on a standalone host", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM0", infrav1.DisabledDRSAutomationLevel)
+
+		g.Expect(reconcile(g, ctx)).To(BeTrue())
+	})
+
+	t.Run("keeps the DRS settings of the cluster without automation level", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM1", "")
+
+		g.Expect(reconcile(g, ctx)).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+	})
+}
+
+func TestReconcileWarmPoolRestart(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	newContext := func(g *WithT, name string) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Annotations = map[string]string{
+			infrav1.WarmPoolVMAnnotation:         "pool-vm",
+			infrav1.WarmPoolPowerStateAnnotation: string(infrav1.PoweredOnWarmPoolPowerState),
+		}
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+
+	t.Run("restarts a powered on VM once", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM0")
+
+		ok, err := (&VMService{}).reconcileWarmPoolRestart(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+		g.Expect(ctx.VSphereVM.Annotations).NotTo(HaveKey(infrav1.WarmPoolPowerStateAnnotation))
+		task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef})
+		g.Expect(task.Wait(ctx)).To(Succeed())
+		ctx.VSphereVM.Status.TaskRef = ""
+
+		ok, err = (&VMService{}).reconcileWarmPoolRestart(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+	})
+
+	t.Run("does not restart a VM powered off since it was claimed", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM1")
+		task, err := ctx.Obj.PowerOff(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+
+		ok, err := (&VMService{}).reconcileWarmPoolRestart(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+		g.Expect(ctx.VSphereVM.Annotations).NotTo(HaveKey(infrav1.WarmPoolPowerStateAnnotation))
+	})
+}
+
+func TestReconcileHostAffinity(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	newContext := func(g *WithT, name string, affinity *infrav1.HostAffinitySpec) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VSphereVM.Spec.HostAffinity = affinity
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, name)
+		g.Expect(err).NotTo(HaveOccurred())
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+	reconcile := func(g *WithT, ctx *virtualMachineContext) (bool, error) {
+		ok, err := (&VMService{}).reconcileHostAffinity(ctx)
+		if ctx.VSphereVM.Status.TaskRef != "" {
+			task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef})
+			g.Expect(task.Wait(ctx)).To(Succeed())
+			ctx.VSphereVM.Status.TaskRef = ""
+		}
+		return ok, err
+	}
+	configuration := func(g *WithT, ctx *virtualMachineContext) (*object.ClusterComputeResource, *types.ClusterConfigInfoEx) {
+		ccr, err := ctx.Session.Finder.ClusterComputeResource(ctx, "DC0_C0")
+		g.Expect(err).NotTo(HaveOccurred())
+		config, err := ccr.Configuration(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		return ccr, config
+	}
+	vmGroup := func(config *types.ClusterConfigInfoEx, name string) *types.ClusterVmGroup {
+		for _, group := range config.Group {
+			if vmGroup, ok := group.(*types.ClusterVmGroup); ok && vmGroup.Name == name {
+				return vmGroup
+			}
+		}
+		return nil
+	}
+
+	g := NewWithT(t)
+	ctx := newContext(g, "DC0_C0_RP0_VM0", nil)
+	ccr, _ := configuration(g, ctx)
+	hosts, err := ccr.Hosts(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	task, err := ccr.Reconfigure(ctx, &types.ClusterConfigSpecEx{
+		GroupSpec: []types.ClusterGroupSpec{{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+			Info: &types.ClusterHostGroup{
+				ClusterGroupInfo: types.ClusterGroupInfo{Name: "licensed-hosts"},
+				Host:             []types.ManagedObjectReference{hosts[0].Reference()},
+			},
+		}},
+	}, true)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task.Wait(ctx)).To(Succeed())
+
+	t.Run("creates the VM group and the rule of a host group", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM0", &infrav1.HostAffinitySpec{HostGroupName: "licensed-hosts"})
+
+		ok, err := reconcile(g, ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+
+		_, config := configuration(g, ctx)
+		group := vmGroup(config, "licensed-hosts-capv")
+		g.Expect(group).NotTo(BeNil())
+		g.Expect(group.Vm).To(ConsistOf(ctx.Ref))
+		g.Expect(config.Rule).To(HaveLen(1))
+		rule, ok := config.Rule[0].(*types.ClusterVmHostRuleInfo)
+		g.Expect(ok).To(BeTrue())
+		g.Expect(rule.Name).To(Equal("licensed-hosts-capv"))
+		g.Expect(rule.VmGroupName).To(Equal("licensed-hosts-capv"))
+		g.Expect(rule.AffineHostGroupName).To(Equal("licensed-hosts"))
+		g.Expect(*rule.Mandatory).To(BeTrue())
+		g.Expect(*rule.Enabled).To(BeTrue())
+	})
+
+	t.Run("adds the VM to the VM group of an existing rule", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_C0_RP0_VM1", &infrav1.HostAffinitySpec{RuleName: "licensed-hosts-capv"})
+
+		ok, err := reconcile(g, ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+
+		_, config := configuration(g, ctx)
+		g.Expect(vmGroup(config, "licensed-hosts-capv").Vm).To(HaveLen(2))
+		g.Expect(vmGroup(config, "licensed-hosts-capv").Vm).To(ContainElement(ctx.Ref))
+		g.Expect(config.Rule).To(HaveLen(1))
+	})
+
+	t.Run("adds the VMs reconciled concurrently", func(t *testing.T) {
+		g := NewWithT(t)
+		task, err := ccr.Reconfigure(ctx, &types.ClusterConfigSpecEx{
+			GroupSpec: []types.ClusterGroupSpec{{
+				ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+				Info: &types.ClusterHostGroup{
+					ClusterGroupInfo: types.ClusterGroupInfo{Name: "gpu-hosts"},
+					Host:             []types.ManagedObjectReference{hosts[1].Reference()},
+				},
+			}},
+		}, true)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+
+		var contexts []*virtualMachineContext
+		for _, name := range []string{"DC0_C0_RP0_VM0", "DC0_C0_RP0_VM1"} {
+			contexts = append(contexts, newContext(g, name, &infrav1.HostAffinitySpec{HostGroupName: "gpu-hosts"}))
+		}
+		var wg sync.WaitGroup
+		errs := make(chan error, len(contexts))
+		for _, ctx := range contexts {
+			wg.Add(1)
+			go func(ctx *virtualMachineContext) {
+				defer wg.Done()
+				_, err := (&VMService{}).reconcileHostAffinity(ctx)
+				errs <- err
+			}(ctx)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			g.Expect(err).NotTo(HaveOccurred())
+		}
+
+		_, config := configuration(g, contexts[0])
+		group := vmGroup(config, "gpu-hosts-capv")
+		g.Expect(group).NotTo(BeNil())
+		for _, ctx := range contexts {
+			g.Expect(group.Vm).To(ContainElement(ctx.Ref))
+		}
+	})
+
+	t.Run("fails for a missing rule or host group", func(t *testing.T) {
+		g := NewWithT(t)
+		for _, affinity := range []*infrav1.HostAffinitySpec{{RuleName: "missing"}, {HostGroupName: "missing"}} {
+			ctx := newContext(g, "DC0_C0_RP0_VM1", affinity)
+			ok, err := reconcile(g, ctx)
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(ok).To(BeFalse())
+		}
+	})
+
+	t.Run("skips a VM on a standalone host", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "DC0_H0_VM0", &infrav1.HostAffinitySpec{HostGroupName: "licensed-hosts"})
+
+		ok, err := reconcile(g, ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+	})
+}
+
+func TestReconcileClockSkew(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.TimeSync = &infrav1.TimeSyncSpec{MaxClockSkew: &metav1.Duration{Duration: time.Minute}}
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	if err != nil {
+		t.Fatalf("unable to create session: %s", err)
+	}
+	vmContext.Session = authSession
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	if err != nil {
+		t.Fatalf("unable to find VM: %s", err)
+	}
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+	report := func(g *WithT, skew time.Duration) {
+		task, err := obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+			ExtraConfig: []types.BaseOptionValue{&types.OptionValue{Key: bootstrapdata.ClockReportKey, Value: strconv.FormatInt(int64(skew.Seconds()), 10)}},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+	}
+
+	t.Run("waits for the guest to report its clock", func(t *testing.T) {
+		g := NewWithT(t)
+		g.Expect((&VMService{}).reconcileClockSkew(ctx)).To(Succeed())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.ClockSynchronizedCondition)).To(Equal(infrav1.WaitingForClockReportReason))
+	})
+
+	t.Run("reports a synchronized clock", func(t *testing.T) {
+		g := NewWithT(t)
+		report(g, -10*time.Second)
+		g.Expect((&VMService{}).reconcileClockSkew(ctx)).To(Succeed())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.ClockSynchronizedCondition)).To(BeTrue())
+	})
+
+	t.Run("reports a clock ahead or behind", func(t *testing.T) {
+		g := NewWithT(t)
+		for _, skew := range []time.Duration{5 * time.Minute, -5 * time.Minute} {
+			report(g, skew)
+			g.Expect((&VMService{}).reconcileClockSkew(ctx)).To(Succeed())
+			g.Expect(conditions.IsFalse(ctx.VSphereVM, infrav1.ClockSynchronizedCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.ClockSynchronizedCondition)).To(Equal(infrav1.ClockSkewedReason))
+		}
+	})
+}
+
+func TestWindowsActivationCondition(t *testing.T) {
+	tests := []struct {
+		report   string
+		status   corev1.ConditionStatus
+		reason   string
+		severity clusterv1.ConditionSeverity
+		wantErr  bool
+	}{
+		{report: "", status: corev1.ConditionFalse, reason: infrav1.WaitingForActivationReportReason, severity: clusterv1.ConditionSeverityInfo},
+		{report: "1\n", status: corev1.ConditionTrue},
+		{report: "3", status: corev1.ConditionFalse, reason: infrav1.WindowsGracePeriodReason, severity: clusterv1.ConditionSeverityWarning},
+		{report: "0", status: corev1.ConditionFalse, reason: infrav1.WindowsNotActivatedReason, severity: clusterv1.ConditionSeverityError},
+		{report: "5", status: corev1.ConditionFalse, reason: infrav1.WindowsNotActivatedReason, severity: clusterv1.ConditionSeverityError},
+		{report: "7", wantErr: true},
+		{report: "licensed", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.report, func(t *testing.T) {
+			g := NewWithT(t)
+			condition, err := windowsActivationCondition(tt.report)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(condition.Type).To(Equal(infrav1.WindowsActivatedCondition))
+			g.Expect(condition.Status).To(Equal(tt.status))
+			g.Expect(condition.Reason).To(Equal(tt.reason))
+			g.Expect(condition.Severity).To(Equal(tt.severity))
+		})
+	}
+}
+
+func TestReconcileGuestCustomization(t *testing.T) {
+	g := NewWithT(t)
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.CustomizationSpec = "windows-sysprep"
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+
+	// The simulator does not report the status of the customization.
+	g.Expect((&VMService{}).reconcileGuestCustomization(ctx)).To(BeTrue())
+	g.Expect(conditions.Has(ctx.VSphereVM, infrav1.VMProvisionedCondition)).To(BeFalse())
+}
+
+func TestGuestCustomizationStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    *types.GuestInfoCustomizationInfo
+		done    bool
+		reason  string
+		message string
+	}{
+		{name: "not reported", done: true},
+		{name: "idle", info: &types.GuestInfoCustomizationInfo{CustomizationStatus: string(types.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_IDLE)}, done: true},
+		{name: "succeeded", info: &types.GuestInfoCustomizationInfo{CustomizationStatus: string(types.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_SUCCEEDED)}, done: true},
+		{
+			name:    "pending",
+			info:    &types.GuestInfoCustomizationInfo{CustomizationStatus: string(types.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_PENDING)},
+			reason:  infrav1.CustomizingReason,
+			message: "guest customization is pending",
+		},
+		{
+			name:    "running",
+			info:    &types.GuestInfoCustomizationInfo{CustomizationStatus: string(types.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_RUNNING)},
+			reason:  infrav1.CustomizingReason,
+			message: "guest customization is running",
+		},
+		{
+			name:    "failed",
+			info:    &types.GuestInfoCustomizationInfo{CustomizationStatus: string(types.GuestInfoCustomizationStatusTOOLSDEPLOYPKG_FAILED), ErrorMsg: "sysprep failed"},
+			reason:  infrav1.GuestCustomizationFailedReason,
+			message: "guest customization failed: sysprep failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			done, reason, message := guestCustomizationStatus(tt.info)
+			g.Expect(done).To(Equal(tt.done))
+			g.Expect(reason).To(Equal(tt.reason))
+			g.Expect(message).To(Equal(tt.message))
+		})
+	}
+}
+
+func TestReconcileManagedTags(t *testing.T) {
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.ManagedTags, true)()
+
+	g := NewWithT(t)
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Labels = map[string]string{
+		clusterv1.ClusterLabelName:             fake.Clusterv1a2Name,
+		clusterv1.MachineControlPlaneLabelName: "",
+	}
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+
+	g.Expect((&VMService{}).reconcileManagedTags(ctx)).To(Succeed())
+
+	attached, err := authSession.TagManager.GetAttachedTags(ctx, ctx.Ref)
+	g.Expect(err).NotTo(HaveOccurred())
+	categories := map[string]string{}
+	for _, tag := range attached {
+		category, err := authSession.TagManager.GetCategory(ctx, tag.CategoryID)
+		g.Expect(err).NotTo(HaveOccurred())
+		categories[category.Name] = tag.Name
+	}
+	g.Expect(categories).To(Equal(map[string]string{
+		metadata.ClusterTagCategory:     fake.Namespace + "/" + fake.Clusterv1a2Name,
+		metadata.NamespaceTagCategory:   fake.Namespace,
+		metadata.MachineRoleTagCategory: metadata.ControlPlaneRoleTag,
+	}))
+
+	// The VMs of a cluster are found from its tag.
+	clusterTag, err := authSession.TagManager.GetTagForCategory(ctx, fake.Namespace+"/"+fake.Clusterv1a2Name, metadata.ClusterTagCategory)
+	g.Expect(err).NotTo(HaveOccurred())
+	objects, err := authSession.TagManager.ListAttachedObjects(ctx, clusterTag.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(objects).To(HaveLen(1))
+	g.Expect(objects[0].Reference()).To(Equal(ctx.Ref))
+}
+
+func TestReconcileStorageStatus(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	g := NewWithT(t)
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+
+	g.Expect((&VMService{}).reconcileStorageStatus(ctx)).To(Succeed())
+	storage := ctx.VSphereVM.Status.Storage
+	g.Expect(storage).NotTo(BeNil())
+	g.Expect(storage.Datastores).To(HaveLen(1))
+	g.Expect(storage.Datastores[0].Name).To(Equal("LocalDS_0"))
+	g.Expect(storage.Datastores[0].CapacityBytes).To(BeNumerically(">", 0))
+	g.Expect(storage.CommittedBytes).To(Equal(storage.Datastores[0].CommittedBytes))
+	g.Expect(storage.ProvisionedBytes).To(BeNumerically(">=", storage.CommittedBytes))
+}
+
+func TestReconcilePlacementStatus(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	g := NewWithT(t)
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+
+	g.Expect((&VMService{}).reconcilePlacementStatus(ctx)).To(Succeed())
+	g.Expect(ctx.VSphereVM.Status.Host).To(Equal("DC0_H0"))
+	g.Expect(ctx.VSphereVM.Status.Datastore).To(Equal("LocalDS_0"))
+}
+
+func TestReconcileVMRef(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	g := NewWithT(t)
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+
+	(&VMService{}).reconcileVMRef(ctx)
+	vmRef := ctx.VSphereVM.Status.VMRef
+	g.Expect(vmRef).NotTo(BeNil())
+	g.Expect(vmRef.Value).To(Equal(obj.Reference().Value))
+	g.Expect(vmRef.ServerGUID).To(Equal(authSession.Client.ServiceContent.About.InstanceUuid))
+}
+
+func TestVMReference(t *testing.T) {
+	ref := types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-42"}
+
+	t.Run("links to the VM in the vSphere Client", func(t *testing.T) {
+		g := NewWithT(t)
+		vmRef := vmReference(&url.URL{Scheme: "https", Host: "vcenter.example.com", Path: "/sdk"}, "guid", ref, "my vm")
+		g.Expect(vmRef.Value).To(Equal("vm-42"))
+		g.Expect(vmRef.ServerGUID).To(Equal("guid"))
+		g.Expect(vmRef.WebClientURL).To(Equal("https://vcenter.example.com/ui/app/vm;nav=h/urn:vmomi:VirtualMachine:vm-42:guid/summary"))
+		g.Expect(vmRef.ConsoleURL).To(Equal("https://vcenter.example.com/ui/webconsole.html?host=vcenter.example.com%3A443&serverGuid=guid&vmId=vm-42&vmName=my+vm"))
+	})
+
+	t.Run("keeps the port of the vCenter", func(t *testing.T) {
+		g := NewWithT(t)
+		vmRef := vmReference(&url.URL{Scheme: "https", Host: "vcenter.example.com:8443", Path: "/sdk"}, "guid", ref, "vm")
+		g.Expect(vmRef.WebClientURL).To(HavePrefix("https://vcenter.example.com:8443/ui/"))
+		g.Expect(vmRef.ConsoleURL).To(ContainSubstring("host=vcenter.example.com%3A8443"))
+	})
+
+	t.Run("does not link to the VM without the instance UUID of the vCenter", func(t *testing.T) {
+		g := NewWithT(t)
+		vmRef := vmReference(&url.URL{Scheme: "https", Host: "vcenter.example.com"}, "", ref, "vm")
+		g.Expect(vmRef.Value).To(Equal("vm-42"))
+		g.Expect(vmRef.WebClientURL).To(BeEmpty())
+		g.Expect(vmRef.ConsoleURL).To(BeEmpty())
+	})
+}
+
+func TestDataDiskName(t *testing.T) {
+	g := NewWithT(t)
+	devices := object.VirtualDeviceList{
+		&types.VirtualLsiLogicController{VirtualSCSIController: types.VirtualSCSIController{
+			VirtualController: types.VirtualController{VirtualDevice: types.VirtualDevice{Key: 1000}},
+		}},
+		&types.VirtualNVMEController{VirtualController: types.VirtualController{VirtualDevice: types.VirtualDevice{Key: 31000}, BusNumber: 1}},
+	}
+	dataDisks := map[string]string{"1000:1": "scratch", "nvme1:0": "etcd"}
+	disk := func(controllerKey, unitNumber int32) *types.VirtualDisk {
+		return &types.VirtualDisk{VirtualDevice: types.VirtualDevice{ControllerKey: controllerKey, UnitNumber: &unitNumber}}
+	}
+
+	g.Expect(dataDiskName(dataDisks, devices, disk(1000, 1))).To(Equal("scratch"))
+	g.Expect(dataDiskName(dataDisks, devices, disk(31000, 0))).To(Equal("etcd"))
+	g.Expect(dataDiskName(dataDisks, devices, disk(1000, 0))).To(BeEmpty())
+	g.Expect(dataDiskName(dataDisks, devices, disk(4000, 0))).To(BeEmpty())
+}
+
+func TestStorageStatus(t *testing.T) {
+	g := NewWithT(t)
+	ds0 := types.ManagedObjectReference{Type: "Datastore", Value: "datastore-0"}
+	ds1 := types.ManagedObjectReference{Type: "Datastore", Value: "datastore-1"}
+	datastores := []mo.Datastore{
+		{
+			ManagedEntity: mo.ManagedEntity{ExtensibleManagedObject: mo.ExtensibleManagedObject{Self: ds0}, Name: "ds0"},
+			Summary:       types.DatastoreSummary{Capacity: 100, FreeSpace: 40, Uncommitted: 70},
+		},
+	}
+
+	status := storageStatus([]types.VirtualMachineUsageOnDatastore{
+		{Datastore: ds1, Committed: 5, Uncommitted: 0},
+		{Datastore: ds0, Committed: 10, Uncommitted: 30},
+	}, datastores)
+	g.Expect(status.CommittedBytes).To(Equal(int64(15)))
+	g.Expect(status.ProvisionedBytes).To(Equal(int64(45)))
+	g.Expect(status.Datastores).To(Equal([]infrav1.VirtualMachineDatastoreUsage{
+		{Name: "datastore-1", CommittedBytes: 5, ProvisionedBytes: 5},
+		{Name: "ds0", CommittedBytes: 10, ProvisionedBytes: 40, CapacityBytes: 100, DatastoreProvisionedBytes: 130},
+	}))
+}
+
+func TestGetBootstrapData(t *testing.T) {
+	g := NewWithT(t)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "bootstrap"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config\nruncmd:\n- kubeadm init\n")},
+	}
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext(secret)))
+	vmContext.VSphereVM.Spec.BootstrapRef = &corev1.ObjectReference{Namespace: fake.Namespace, Name: "bootstrap"}
+
+	data, err := (&VMService{}).getBootstrapData(vmContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).To(Equal(secret.Data["value"]))
+
+	// The kube-vip manifest of a control plane machine is added to its bootstrap data.
+	vmContext.KubeVIPManifest = []byte("kind: Pod\n")
+	data, err = (&VMService{}).getBootstrapData(vmContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(HavePrefix("#cloud-config\n"))
+	g.Expect(string(data)).To(ContainSubstring(kubevip.ManifestPath))
+	g.Expect(string(data)).To(ContainSubstring("kubeadm init"))
+
+	// The NTP and proxy settings are added to the bootstrap data of Linux guests only.
+	vmContext.KubeVIPManifest = nil
+	vmContext.VSphereVM.Spec.Network.NTPServers = []string{"ntp.example.com"}
+	vmContext.VSphereVM.Spec.Network.Proxy = &infrav1.ProxySpec{HTTPSProxy: "http://proxy.example.com:3128"}
+	data, err = (&VMService{}).getBootstrapData(vmContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(ContainSubstring(bootstrapdata.ContainerdProxyPath))
+	g.Expect(string(data)).To(ContainSubstring("ntp.example.com"))
+
+	// The timer reporting the guest clock is added when its skew is monitored.
+	vmContext.VSphereVM.Spec.TimeSync = &infrav1.TimeSyncSpec{MaxClockSkew: &metav1.Duration{Duration: time.Minute}}
+	data, err = (&VMService{}).getBootstrapData(vmContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(ContainSubstring("systemctl enable --now capv-clock-report.timer"))
+	g.Expect(string(data)).To(ContainSubstring(bootstrapdata.ContainerdProxyPath))
+
+	vmContext.VSphereVM.Spec.OS = infrav1.WindowsOS
+	data, err = (&VMService{}).getBootstrapData(vmContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).To(Equal(secret.Data["value"]))
+}
+
+//nolint:forcetypeassert
+func TestReconcileFolder(t *testing.T) {
+	g := NewWithT(t)
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+	parent := func() types.ManagedObjectReference {
+		return *simulator.Map.Get(ctx.Ref).(*simulator.VirtualMachine).Parent
+	}
+
+	vmFolder, err := authSession.Finder.DefaultFolder(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	ok, err := (&VMService{}).reconcileFolder(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ctx.VSphereVM.Status.Folder).To(Equal("/DC0/vm"))
+
+	// An administrator moves the VM into another folder.
+	moved, err := vmFolder.CreateFolder(ctx, "moved")
+	g.Expect(err).NotTo(HaveOccurred())
+	task, err := moved.MoveInto(ctx, []types.ManagedObjectReference{ctx.Ref})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task.Wait(ctx)).To(Succeed())
+
+	// The VM is still found by its UUID.
+	vmContext.VSphereVM.Spec.BiosUUID = simulator.Map.Get(ctx.Ref).(*simulator.VirtualMachine).Config.Uuid
+	ref, err := findVM(&ctx.VMContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref).To(Equal(ctx.Ref))
+	simulator.Map.Get(ctx.Ref).(*simulator.VirtualMachine).Config.InstanceUuid = string(vmContext.VSphereVM.UID)
+	vmContext.VSphereVM.Spec.BiosUUID = "00000000-0000-0000-0000-000000000000"
+	ref, err = findVM(&ctx.VMContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref).To(Equal(ctx.Ref))
+
+	// The new folder is accepted by default.
+	ok, err = (&VMService{}).reconcileFolder(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ctx.VSphereVM.Status.Folder).To(Equal("/DC0/vm/moved"))
+	g.Expect(parent()).To(Equal(moved.Reference()))
+
+	// The VM is moved back into its folder when the policy restores it.
+	ctx.VSphereVM.Spec.FolderRelocationPolicy = infrav1.RestoreFolderRelocationPolicy
+	ok, err = (&VMService{}).reconcileFolder(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+	g.Expect(object.NewTask(authSession.Client.Client, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef}).Wait(ctx)).To(Succeed())
+	g.Expect(parent()).To(Equal(vmFolder.Reference()))
+
+	ok, err = (&VMService{}).reconcileFolder(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ctx.VSphereVM.Status.Folder).To(Equal("/DC0/vm"))
+}
+
+func TestReconcileName(t *testing.T) {
+	g := NewWithT(t)
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.VMName = "DC0_H0_VM0"
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+	name := func() string {
+		var vm mo.VirtualMachine
+		g.Expect(obj.Properties(ctx, ctx.Ref, []string{"name"}, &vm)).To(Succeed())
+		return vm.Name
+	}
+
+	ok, err := (&VMService{}).reconcileName(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ctx.VSphereVM.Status.VMName).To(Equal("DC0_H0_VM0"))
+
+	// An administrator renames the VM.
+	task, err := obj.Rename(ctx, "renamed")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task.Wait(ctx)).To(Succeed())
+
+	// The new name is accepted by default.
+	ok, err = (&VMService{}).reconcileName(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ctx.VSphereVM.Status.VMName).To(Equal("renamed"))
+	g.Expect(name()).To(Equal("renamed"))
+
+	// The VM is renamed back when the policy restores it.
+	ctx.VSphereVM.Spec.RenamePolicy = infrav1.RestoreRenamePolicy
+	ok, err = (&VMService{}).reconcileName(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+	g.Expect(object.NewTask(authSession.Client.Client, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef}).Wait(ctx)).To(Succeed())
+	g.Expect(name()).To(Equal("DC0_H0_VM0"))
+
+	ok, err = (&VMService{}).reconcileName(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ctx.VSphereVM.Status.VMName).To(Equal("DC0_H0_VM0"))
+}
+
+//nolint:forcetypeassert
+func TestReconcileInstanceUUID(t *testing.T) {
+	g := NewWithT(t)
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.VMName = "DC0_H0_VM0"
+	recorder := clientrecord.NewFakeRecorder(1)
+	vmContext.Recorder = record.New(recorder)
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+	simVM := simulator.Map.Get(ctx.Ref).(*simulator.VirtualMachine)
+	setInstanceUUIDKey := func(uuid string) {
+		task, err := obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+			ExtraConfig: []types.BaseOptionValue{&types.OptionValue{Key: extra.InstanceUUIDKey, Value: uuid}},
+		})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+	}
+
+	// The instance UUID of the VM of a VSphereVM created before it was
+	// recorded, and before its VM was referenced in its status, is silently
+	// backfilled.
+	g.Expect((&VMService{}).reconcileInstanceUUID(ctx, false)).To(Succeed())
+	g.Expect(ctx.VSphereVM.Annotations).To(HaveKeyWithValue(infrav1.VMInstanceUUIDAnnotation, simVM.Config.InstanceUuid))
+	g.Expect(recorder.Events).To(BeEmpty())
+
+	// The VM of a VSphereVM restored with its instance UUID, but without its
+	// status, is reported as adopted.
+	g.Expect((&VMService{}).reconcileInstanceUUID(ctx, false)).To(Succeed())
+	g.Expect(<-recorder.Events).To(ContainSubstring("VMAdopted"))
+
+	// The VM of a VSphereVM which references it is not adopted again.
+	(&VMService{}).reconcileVMRef(ctx)
+	g.Expect((&VMService{}).reconcileInstanceUUID(ctx, false)).To(Succeed())
+	g.Expect(recorder.Events).To(BeEmpty())
+
+	// The VSphereVM restored with another UID finds its VM by the recorded
+	// instance UUID.
+	ctx.VSphereVM.UID = "20000000-0000-0000-0000-000000000000"
+	ctx.VSphereVM.Spec.BiosUUID = "00000000-0000-0000-0000-000000000000"
+	ref, err := findVM(&ctx.VMContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref).To(Equal(ctx.Ref))
+
+	// The VM found by its inventory path is adopted when it was cloned for
+	// the VSphereVM, but not when it was cloned for another one.
+	ctx.VSphereVM.Spec.BiosUUID = ""
+	ctx.VSphereVM.Annotations[infrav1.VMInstanceUUIDAnnotation] = "30000000-0000-0000-0000-000000000000"
+	setInstanceUUIDKey("30000000-0000-0000-0000-000000000000")
+	ref, err = findVM(&ctx.VMContext)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ref).To(Equal(ctx.Ref))
+	setInstanceUUIDKey("40000000-0000-0000-0000-000000000000")
+	_, err = findVM(&ctx.VMContext)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(isNotFound(err)).To(BeFalse())
+
+	// The instance UUID of an existing VM is the UID of the VSphereVM.
+	delete(ctx.VSphereVM.Annotations, infrav1.VMInstanceUUIDAnnotation)
+	g.Expect((&VMService{}).reconcileInstanceUUID(ctx, true)).To(Succeed())
+	g.Expect(ctx.VSphereVM.Annotations).To(HaveKeyWithValue(infrav1.VMInstanceUUIDAnnotation, "20000000-0000-0000-0000-000000000000"))
+}
+
+//nolint:forcetypeassert
+func TestReconcileEncryption(t *testing.T) {
+	g := NewWithT(t)
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+
+	// The encryption is only reported when it is set.
+	g.Expect((&VMService{}).reconcileEncryption(ctx)).To(Succeed())
+	g.Expect(conditions.Has(ctx.VSphereVM, infrav1.EncryptionReadyCondition)).To(BeFalse())
+
+	ctx.VSphereVM.Spec.Encryption = &infrav1.EncryptionSpec{}
+	g.Expect((&VMService{}).reconcileEncryption(ctx)).To(Succeed())
+	g.Expect(conditions.IsFalse(ctx.VSphereVM, infrav1.EncryptionReadyCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.EncryptionReadyCondition)).To(Equal(infrav1.VMNotEncryptedReason))
+
+	simulator.Map.Get(ctx.Ref).(*simulator.VirtualMachine).Config.KeyId = &types.CryptoKeyId{KeyId: "key-0", ProviderId: &types.KeyProviderId{Id: "kms"}}
+	g.Expect((&VMService{}).reconcileEncryption(ctx)).To(Succeed())
+	g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.EncryptionReadyCondition)).To(BeTrue())
+
+	ctx.VSphereVM.Spec.Encryption = nil
+	g.Expect((&VMService{}).reconcileEncryption(ctx)).To(Succeed())
+	g.Expect(conditions.Has(ctx.VSphereVM, infrav1.EncryptionReadyCondition)).To(BeFalse())
+}
+
+func TestReconcileVMWithFaults(t *testing.T) {
+	g := NewWithT(t)
+
+	vc, err := fake.NewVCenter(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	defer vc.Destroy()
+	ctx, err := fake.NewVMContextWithVCenter(fake.NewControllerContext(fake.NewControllerManagerContext()), vc)
+	g.Expect(err).NotTo(HaveOccurred())
+	vms := &VMService{}
+
+	// The failure of the clone task is reported, then the clone is retried.
+	vc.FailTasks("VirtualMachine.cloneVm", &types.InsufficientResourcesFault{})
+	_, err = vms.ReconcileVM(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+	g.Expect(vc.WaitForTask(ctx, ctx.Session.Client.Client, ctx.VSphereVM.Status.TaskRef)).NotTo(Succeed())
+	_, err = vms.ReconcileVM(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VMProvisionedCondition)).To(Equal(infrav1.TaskFailureReason))
+	g.Expect(ctx.VSphereVM.Status.RetryAfter.IsZero()).To(BeFalse())
+
+	vc.ClearFaults()
+	ctx.VSphereVM.Status.RetryAfter = metav1.NewTime(time.Now().Add(-time.Second))
+	_, err = vms.ReconcileVM(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ctx.VSphereVM.Status.TaskRef).To(BeEmpty())
+
+	// The VSphereVM waits for a slow clone task.
+	vc.DelayTasks("VirtualMachine.cloneVm", time.Second)
+	_, err = vms.ReconcileVM(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	cloneTask := ctx.VSphereVM.Status.TaskRef
+	g.Expect(cloneTask).NotTo(BeEmpty())
+	vm, err := vms.ReconcileVM(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vm.State).To(BeEquivalentTo(infrav1.VirtualMachineStatePending))
+	g.Expect(ctx.VSphereVM.Status.TaskRef).To(Equal(cloneTask))
+	g.Expect(vc.WaitForTask(ctx, ctx.Session.Client.Client, cloneTask)).To(Succeed())
+
+	// The requests of an expired session fail, and a new session is created.
+	expired := ctx.Session
+	g.Expect(vc.ExpireSessions(ctx)).To(Succeed())
+	_, err = expired.Finder.VirtualMachine(ctx, "DC0_H0_VM0")
+	g.Expect(err).To(HaveOccurred())
+	renewed, err := vc.Session(ctx, "DC0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(renewed.Client).NotTo(BeIdenticalTo(expired.Client))
+
+	// The faults of the methods are returned by the simulator.
+	vc.FailMethod("FindByUuid", &types.InvalidState{})
+	_, err = renewed.FindByInstanceUUID(ctx, fake.VSphereVMUUID)
+	g.Expect(err).To(HaveOccurred())
+	vc.ClearFaults()
+	_, err = renewed.FindByInstanceUUID(ctx, fake.VSphereVMUUID)
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestReconcileVMAcrossDatacenters(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.Datacenter = 2
+	vc, err := fake.NewVCenter(model)
+	g.Expect(err).NotTo(HaveOccurred())
+	defer vc.Destroy()
+	ctx, err := fake.NewVMContextWithVCenter(fake.NewControllerContext(fake.NewControllerManagerContext()), vc)
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx.VSphereVM.Spec.Datacenter = "DC1"
+	ctx.VSphereVM.Spec.TemplateDatacenter = "DC0"
+	ctx.VSphereVM.Spec.ResourcePool = "/DC1/host/DC1_C0/Resources"
+	ctx.Session, err = vc.Session(ctx, "DC1")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The template of DC0 is not found in DC1 without the template datacenter.
+	_, err = ctx.Session.Finder.VirtualMachine(ctx, ctx.VSphereVM.Spec.Template)
+	g.Expect(err).To(HaveOccurred())
+
+	// The simulator rejects the NICs of the template, on the distributed
+	// switch of DC0, in DC1.
+	tplSession, err := ctx.Session.WithDatacenter(ctx, "DC0")
+	g.Expect(err).NotTo(HaveOccurred())
+	tpl, err := tplSession.Finder.VirtualMachine(ctx, ctx.VSphereVM.Spec.Template)
+	g.Expect(err).NotTo(HaveOccurred())
+	devices, err := tpl.Device(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(tpl.RemoveDevice(ctx, false, devices.SelectByType((*types.VirtualEthernetCard)(nil))...)).To(Succeed())
+
+	vms := &VMService{}
+	_, err = vms.ReconcileVM(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ctx.VSphereVM.Status.TaskRef).NotTo(BeEmpty())
+	g.Expect(vc.WaitForTask(ctx, ctx.Session.Client.Client, ctx.VSphereVM.Status.TaskRef)).To(Succeed())
+
+	// The instance UUID of the VM is patched onto the VSphereVM before the
+	// clone starts.
+	vsphereVM := &infrav1.VSphereVM{}
+	g.Expect(ctx.Client.Get(ctx, client.ObjectKeyFromObject(ctx.VSphereVM), vsphereVM)).To(Succeed())
+	g.Expect(vsphereVM.Annotations).To(HaveKeyWithValue(infrav1.VMInstanceUUIDAnnotation, string(ctx.VSphereVM.UID)))
+
+	vm, err := ctx.Session.Finder.VirtualMachine(ctx, ctx.VSphereVM.Name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vm.InventoryPath).To(HavePrefix("/DC1/"))
+}
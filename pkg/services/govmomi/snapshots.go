@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+)
+
+// reconcileSnapshots reports the snapshots of the VM which commonly break its
+// reconfigurations and migrations: the disks which need a consolidation, e.g.
+// after the removal of a snapshot failed, and the chains of snapshots longer
+// than the snapshot chain limit of the manager. The disks are consolidated
+// when the manager consolidates them automatically; a consolidation which
+// failed is retried. It returns false while the disks are consolidated.
+func (vms *VMService) reconcileSnapshots(ctx *virtualMachineContext) (bool, error) {
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"runtime.consolidationNeeded", "snapshot"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "failed to get the snapshots of vm %s", ctx)
+	}
+
+	if obj.Runtime.ConsolidationNeeded != nil && *obj.Runtime.ConsolidationNeeded {
+		if !ctx.AutoConsolidateDisks {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition, infrav1.ConsolidationNeededReason, clusterv1.ConditionSeverityWarning,
+				"the disks of the VM need a consolidation")
+			return true, nil
+		}
+
+		// The disks still need a consolidation once the in-flight
+		// consolidation is done when it failed.
+		if conditions.GetReason(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition) == infrav1.ConsolidationInProgressReason {
+			ctx.Recorder.Warnf(ctx.VSphereVM, "ConsolidationFailed", "failed to consolidate the disks of VM %s", ctx.VSphereVM.Name)
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition, infrav1.ConsolidationFailedReason, clusterv1.ConditionSeverityWarning,
+				"failed to consolidate the disks of the VM")
+		}
+		if !acquireTaskSlot(&ctx.VMContext, throttle.Reconfigure) {
+			return false, nil
+		}
+		ctx.Logger.Info("consolidating disks")
+		res, err := methods.ConsolidateVMDisks_Task(ctx, ctx.Session.Client.Client, &types.ConsolidateVMDisks_Task{This: ctx.Ref})
+		if err != nil {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition, infrav1.ConsolidationFailedReason, clusterv1.ConditionSeverityWarning,
+				"failed to consolidate the disks of the VM: %v", err)
+			return false, errors.Wrapf(err, "failed to trigger the consolidation of the disks of vm %s", ctx)
+		}
+		ctx.Recorder.Eventf(ctx.VSphereVM, "ConsolidatingDisks", "Consolidating the disks of VM %s", ctx.VSphereVM.Name)
+		if conditions.GetReason(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition) != infrav1.ConsolidationFailedReason {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition, infrav1.ConsolidationInProgressReason, clusterv1.ConditionSeverityInfo,
+				"consolidating the disks of the VM")
+		}
+		ctx.VSphereVM.Status.TaskRef = res.Returnval.Value
+		return false, nil
+	}
+
+	if limit := ctx.SnapshotChainLimit; limit > 0 {
+		if length := snapshotChainLength(obj.Snapshot); length > limit {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition, infrav1.SnapshotChainTooLongReason, clusterv1.ConditionSeverityWarning,
+				"the VM runs on a chain of %d snapshots, longer than the limit of %d", length, limit)
+			return true, nil
+		}
+	}
+
+	conditions.MarkTrue(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition)
+	return true, nil
+}
+
+// blockedBySnapshots returns an error when the snapshots of the VM block its
+// reconfigurations and migrations, as reported by the SnapshotsHealthy
+// condition of the VSphereVM.
+func blockedBySnapshots(ctx *virtualMachineContext) error {
+	if conditions.Get(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition) == nil || conditions.IsTrue(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition) {
+		return nil
+	}
+	return errors.Errorf("blocked by the snapshots of the VM: %s", conditions.GetMessage(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition))
+}
+
+// snapshotChainLength returns the number of snapshots between the root
+// snapshot of a VM and its current snapshot, which is the number of delta
+// disks the VM runs on.
+func snapshotChainLength(info *types.VirtualMachineSnapshotInfo) int {
+	if info == nil || info.CurrentSnapshot == nil {
+		return 0
+	}
+	var depth func(trees []types.VirtualMachineSnapshotTree, length int) int
+	depth = func(trees []types.VirtualMachineSnapshotTree, length int) int {
+		for _, tree := range trees {
+			if tree.Snapshot == *info.CurrentSnapshot {
+				return length
+			}
+			if found := depth(tree.ChildSnapshotList, length+1); found > 0 {
+				return found
+			}
+		}
+		return 0
+	}
+	return depth(info.RootSnapshotList, 1)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+//nolint:forcetypeassert
+func TestReconcileSnapshots(t *testing.T) {
+	g := NewWithT(t)
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	g.Expect(err).NotTo(HaveOccurred())
+	defer simr.Destroy()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+		WithServer(simr.ServerURL().Host).
+		WithUserInfo(simr.Username(), simr.Password()).
+		WithDatacenter("*"))
+	g.Expect(err).NotTo(HaveOccurred())
+	vmContext.Session = authSession
+	obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Obj:       obj,
+		Ref:       obj.Reference(),
+		State:     &infrav1.VirtualMachine{},
+	}
+
+	ok, err := (&VMService{}).reconcileSnapshots(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition)).To(BeTrue())
+	g.Expect(blockedBySnapshots(ctx)).To(Succeed())
+
+	// The VM runs on a chain of two snapshots.
+	for _, name := range []string{"first", "second"} {
+		task, err := obj.CreateSnapshot(ctx, name, "", false, false)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+	}
+	ok, err = (&VMService{}).reconcileSnapshots(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition)).To(BeTrue())
+
+	ctx.SnapshotChainLimit = 1
+	ok, err = (&VMService{}).reconcileSnapshots(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition)).To(Equal(infrav1.SnapshotChainTooLongReason))
+	g.Expect(conditions.GetMessage(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition)).To(Equal("the VM runs on a chain of 2 snapshots, longer than the limit of 1"))
+
+	// The snapshots block the relocation of the VM.
+	ctx.VSphereVM.Annotations = map[string]string{
+		infrav1.VMOperationAnnotation:    string(infrav1.RelocateOperation),
+		infrav1.VMRelocateHostAnnotation: "DC0_H0",
+	}
+	(&VMService{}).reconcileOperation(ctx)
+	g.Expect(ctx.VSphereVM.Status.Operation.Result).To(Equal(infrav1.OperationFailed))
+	g.Expect(ctx.VSphereVM.Status.Operation.Message).To(HavePrefix("blocked by the snapshots of the VM: "))
+
+	// The disks of the VM need a consolidation.
+	consolidationNeeded := true
+	simulator.Map.Get(ctx.Ref).(*simulator.VirtualMachine).Runtime.ConsolidationNeeded = &consolidationNeeded
+	ok, err = (&VMService{}).reconcileSnapshots(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition)).To(Equal(infrav1.ConsolidationNeededReason))
+
+	// The simulator does not implement the consolidation of the disks.
+	ctx.AutoConsolidateDisks = true
+	ok, err = (&VMService{}).reconcileSnapshots(ctx)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.SnapshotsHealthyCondition)).To(Equal(infrav1.ConsolidationFailedReason))
+}
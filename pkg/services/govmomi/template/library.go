@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Placement is the placement of the VM cloned from a template. The items of
+// content libraries are deployed as base templates with the same placement.
+type Placement struct {
+	// Folder is the name or inventory path of the folder of the VM.
+	Folder string
+
+	// ResourcePool is the name or inventory path of the resource pool of the VM.
+	ResourcePool string
+
+	// Datastore is the name or inventory path of the datastore of the VM.
+	Datastore string
+}
+
+// baseTemplateLocks serializes the deployments of the same base template, so
+// VMs cloned concurrently from the same item and datastore share a single
+// base template.
+var baseTemplateLocks sync.Map
+
+// findTemplateInContentLibrary returns the base template of the content
+// library item named itemName on the datastore of the placement. The item is
+// deployed as a base template the first time it is used on a datastore, and
+// the base template is reused by the VMs cloned on the same datastore. It
+// returns nil if no content library has an item with this name.
+func findTemplateInContentLibrary(ctx tplContext, itemName string, placement Placement) (*object.VirtualMachine, error) {
+	s := ctx.GetSession()
+	if s.TagManager == nil {
+		return nil, nil
+	}
+
+	libManager := library.NewManager(s.TagManager.Client)
+	itemIDs, err := libManager.FindLibraryItems(ctx, library.FindItem{Name: itemName})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find content library item %q", itemName)
+	}
+	if len(itemIDs) == 0 {
+		return nil, nil
+	}
+	item, err := libManager.GetLibraryItem(ctx, itemIDs[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get content library item %q", itemName)
+	}
+	if item.Type != library.ItemTypeOVF && item.Type != library.ItemTypeVMTX {
+		return nil, errors.Errorf("content library item %q of type %q cannot be used as a template", itemName, item.Type)
+	}
+
+	folder, err := s.Finder.FolderOrDefault(ctx, placement.Folder)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get folder for content library item %q", itemName)
+	}
+	pool, err := s.Finder.ResourcePoolOrDefault(ctx, placement.ResourcePool)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get resource pool for content library item %q", itemName)
+	}
+	datastore, err := s.Finder.DatastoreOrDefault(ctx, placement.Datastore)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get datastore for content library item %q", itemName)
+	}
+
+	name := baseTemplateName(item, datastore)
+	lock, _ := baseTemplateLocks.LoadOrStore(path.Join(s.Client.URL().Host, item.ID, datastore.Reference().Value), &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	tpl, err := s.FindVirtualMachine(ctx, path.Join(folder.InventoryPath, name))
+	if err == nil {
+		ctx.GetLogger().V(6).Info("found base template of content library item", "item", itemName, "template", name)
+		return tpl, nil
+	}
+	if !isNotFound(err) {
+		return nil, errors.Wrapf(err, "unable to look up base template %q", name)
+	}
+
+	ctx.GetLogger().Info("deploying base template of content library item", "item", itemName, "template", name, "datastore", datastore.Name())
+	vcenterManager := vcenter.NewManager(s.TagManager.Client)
+	var ref *types.ManagedObjectReference
+	switch item.Type {
+	case library.ItemTypeOVF:
+		ref, err = vcenterManager.DeployLibraryItem(ctx, item.ID, vcenter.Deploy{
+			DeploymentSpec: vcenter.DeploymentSpec{
+				Name:               name,
+				AcceptAllEULA:      true,
+				DefaultDatastoreID: datastore.Reference().Value,
+			},
+			Target: vcenter.Target{
+				ResourcePoolID: pool.Reference().Value,
+				FolderID:       folder.Reference().Value,
+			},
+		})
+	case library.ItemTypeVMTX:
+		storage := &vcenter.DiskStorage{Datastore: datastore.Reference().Value}
+		ref, err = vcenterManager.DeployTemplateLibraryItem(ctx, item.ID, vcenter.DeployTemplate{
+			Name: name,
+			Placement: &vcenter.Placement{
+				ResourcePool: pool.Reference().Value,
+				Folder:       folder.Reference().Value,
+			},
+			DiskStorage:   storage,
+			VMHomeStorage: storage,
+		})
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to deploy content library item %q", itemName)
+	}
+
+	tpl = object.NewVirtualMachine(s.Client.Client, *ref)
+	isTemplate, err := tpl.IsTemplate(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the configuration of %q", name)
+	}
+	if isTemplate {
+		return tpl, nil
+	}
+	if err := tpl.MarkAsTemplate(ctx); err != nil {
+		return nil, errors.Wrapf(err, "failed to mark %q as a template", name)
+	}
+	return tpl, nil
+}
+
+// hasContentLibraryItem returns whether a content library has an item named
+// itemName.
+func hasContentLibraryItem(ctx tplContext, itemName string) (bool, error) {
+	s := ctx.GetSession()
+	if s.TagManager == nil {
+		return false, nil
+	}
+	itemIDs, err := library.NewManager(s.TagManager.Client).FindLibraryItems(ctx, library.FindItem{Name: itemName})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to find content library item %q", itemName)
+	}
+	return len(itemIDs) > 0, nil
+}
+
+// baseTemplateName returns the name of the base template of a content
+// library item on a datastore, e.g. "ubuntu-2204-kube-v1.22.5-vsanDatastore".
+// Datastore names are unique within a datacenter, like the base templates.
+func baseTemplateName(item *library.Item, datastore *object.Datastore) string {
+	return fmt.Sprintf("%s-%s", item.Name, datastore.Name())
+}
+
+func isNotFound(err error) bool {
+	_, ok := errors.Cause(err).(*find.NotFoundError)
+	return ok
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
@@ -33,8 +35,14 @@ type tplContext interface {
 	GetSession() *session.Session
 }
 
-// FindTemplate finds a template based either on a UUID or name.
-func FindTemplate(ctx tplContext, templateID string) (*object.VirtualMachine, error) {
+// FindTemplate finds a template based either on a managed object reference,
+// such as "VirtualMachine:vm-42", a UUID or name. When no template has this
+// name, the content library item with this name is deployed as a base
+// template with the given placement.
+func FindTemplate(ctx tplContext, templateID string, placement Placement) (*object.VirtualMachine, error) {
+	if tpl := findTemplateByRef(ctx, templateID); tpl != nil {
+		return tpl, nil
+	}
 	tpl, err := findTemplateByInstanceUUID(ctx, templateID)
 	if err != nil {
 		return nil, err
@@ -42,7 +50,59 @@ func FindTemplate(ctx tplContext, templateID string) (*object.VirtualMachine, er
 	if tpl != nil {
 		return tpl, nil
 	}
-	return findTemplateByName(ctx, templateID)
+	tpl, err = findTemplateByName(ctx, templateID)
+	if err == nil || !isNotFound(err) {
+		return tpl, err
+	}
+	libTpl, libErr := findTemplateInContentLibrary(ctx, templateID, placement)
+	if libErr != nil {
+		return nil, libErr
+	}
+	if libTpl == nil {
+		return nil, err
+	}
+	return libTpl, nil
+}
+
+// LookupTemplate finds the template FindTemplate would clone a VM from,
+// without deploying the items of content libraries. It returns nil without
+// error when templateID is the name of an item of a content library.
+func LookupTemplate(ctx tplContext, templateID string) (*object.VirtualMachine, error) {
+	if tpl := findTemplateByRef(ctx, templateID); tpl != nil {
+		var o mo.VirtualMachine
+		if err := tpl.Properties(ctx, tpl.Reference(), []string{"name"}, &o); err != nil {
+			return nil, errors.Wrapf(err, "unable to find template %q", templateID)
+		}
+		return tpl, nil
+	}
+	tpl, err := findTemplateByInstanceUUID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	if tpl != nil {
+		return tpl, nil
+	}
+	tpl, err = findTemplateByName(ctx, templateID)
+	if err == nil || !isNotFound(err) {
+		return tpl, err
+	}
+	found, libErr := hasContentLibraryItem(ctx, templateID)
+	if libErr != nil {
+		return nil, libErr
+	}
+	if !found {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func findTemplateByRef(ctx tplContext, templateID string) *object.VirtualMachine {
+	var ref types.ManagedObjectReference
+	if !ref.FromString(templateID) || ref.Type != "VirtualMachine" {
+		return nil
+	}
+	ctx.GetLogger().V(6).Info("find template by managed object reference", "ref", templateID)
+	return object.NewVirtualMachine(ctx.GetSession().Client.Client, ref)
 }
 
 func findTemplateByInstanceUUID(ctx tplContext, templateID string) (*object.VirtualMachine, error) {
@@ -62,7 +122,7 @@ func findTemplateByInstanceUUID(ctx tplContext, templateID string) (*object.Virt
 
 func findTemplateByName(ctx tplContext, templateID string) (*object.VirtualMachine, error) {
 	ctx.GetLogger().V(6).Info("find template by name", "name", templateID)
-	tpl, err := ctx.GetSession().Finder.VirtualMachine(ctx, templateID)
+	tpl, err := ctx.GetSession().FindVirtualMachine(ctx, templateID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to find template by name %q", templateID)
 	}
@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	goctx "context"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/template"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// LookupTemplateRef returns the managed object reference and the name of the
+// template a VM would be cloned from, whether templateID is its name, its
+// inventory path, its instance UUID or its managed object reference. It
+// returns nil without error when templateID is the name of an item of a
+// content library, whose template is only deployed when a VM is cloned.
+func LookupTemplateRef(ctx goctx.Context, logger logr.Logger, s *session.Session, templateID string) (*types.ManagedObjectReference, string, error) {
+	tplCtx := preflightContext{Context: ctx, logger: logger, session: s}
+	tpl, err := template.LookupTemplate(tplCtx, templateID)
+	if err != nil || tpl == nil {
+		return nil, "", err
+	}
+	name, err := tpl.ObjectName(ctx)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "unable to get the name of template %q", templateID)
+	}
+	ref := tpl.Reference()
+	return &ref, name, nil
+}
@@ -17,8 +17,11 @@ limitations under the License.
 package govmomi
 
 import (
+	goctx "context"
+	"fmt"
 	gonet "net"
 	"path"
+	"reflect"
 	"time"
 
 	"github.com/pkg/errors"
@@ -27,13 +30,19 @@ import (
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/net"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/throttle"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
 func sanitizeIPAddrs(ctx *context.VMContext, ipAddrs []string) []string {
@@ -52,12 +61,21 @@ func sanitizeIPAddrs(ctx *context.VMContext, ipAddrs []string) []string {
 }
 
 // findVM searches for a VM in one of two ways:
-//   1. If the BIOS UUID is available, then it is used to find the VM.
+//   1. If the BIOS UUID is available, then it is used to find the VM. If it is
+//      not found by BIOS UUID, it is queried by its instance UUID before being
+//      reported as missing.
 //   2. Lacking the BIOS UUID, the VM is queried by its instance UUID,
 //      which was assigned the value of the VSphereVM resource's UID string.
 //   3. If it is not found by instance UUID, fallback to an inventory path search
 //      using the vm folder path and the VSphereVM name
+//
+// The UUID searches span the whole datacenter, so a VM moved into another
+// folder is still found. A VM bound to an existing VM is only searched for
+// by the reference or the BIOS UUID of the existing VM.
 func findVM(ctx *context.VMContext) (types.ManagedObjectReference, error) {
+	if isExistingVM(ctx) {
+		return findExistingVM(ctx)
+	}
 	if biosUUID := ctx.VSphereVM.Spec.BiosUUID; biosUUID != "" {
 		objRef, err := ctx.Session.FindByBIOSUUID(ctx, biosUUID)
 		if err != nil {
@@ -65,13 +83,21 @@ func findVM(ctx *context.VMContext) (types.ManagedObjectReference, error) {
 		}
 		if objRef == nil {
 			ctx.Logger.Info("vm not found by bios uuid", "biosuuid", biosUUID)
-			return types.ManagedObjectReference{}, errNotFound{uuid: biosUUID}
+			objRef, err = ctx.Session.FindByInstanceUUID(ctx, util.GetVMInstanceUUID(ctx.VSphereVM))
+			if err != nil {
+				return types.ManagedObjectReference{}, err
+			}
+			if objRef == nil {
+				return types.ManagedObjectReference{}, errNotFound{uuid: biosUUID}
+			}
+			ctx.Logger.Info("vm found by instance uuid", "vmref", objRef.Reference())
+			return objRef.Reference(), nil
 		}
 		ctx.Logger.Info("vm found by bios uuid", "vmref", objRef.Reference())
 		return objRef.Reference(), nil
 	}
 
-	instanceUUID := string(ctx.VSphereVM.UID)
+	instanceUUID := util.GetVMInstanceUUID(ctx.VSphereVM)
 	objRef, err := ctx.Session.FindByInstanceUUID(ctx, instanceUUID)
 	if err != nil {
 		return types.ManagedObjectReference{}, err
@@ -82,7 +108,7 @@ func findVM(ctx *context.VMContext) (types.ManagedObjectReference, error) {
 		if err != nil {
 			return types.ManagedObjectReference{}, err
 		}
-		inventoryPath := path.Join(folder.InventoryPath, ctx.VSphereVM.Name)
+		inventoryPath := path.Join(folder.InventoryPath, util.GetVMName(ctx.VSphereVM))
 		ctx.Logger.Info("using inventory path to find vm", "path", inventoryPath)
 		vm, err := ctx.Session.Finder.VirtualMachine(ctx, inventoryPath)
 		if err != nil {
@@ -91,6 +117,13 @@ func findVM(ctx *context.VMContext) (types.ManagedObjectReference, error) {
 			}
 			return types.ManagedObjectReference{}, err
 		}
+		// A VM cloned for another VSphereVM is never adopted, nor cloned
+		// again over.
+		if owner, err := vmInstanceUUIDKey(ctx, vm); err != nil {
+			return types.ManagedObjectReference{}, err
+		} else if owner != "" && owner != instanceUUID {
+			return types.ManagedObjectReference{}, errors.Errorf("vm %s was cloned for the VSphereVM with instance UUID %s, not %s", inventoryPath, owner, instanceUUID)
+		}
 		ctx.Logger.Info("vm found by name", "vmref", vm.Reference())
 		return vm.Reference(), nil
 	}
@@ -98,6 +131,26 @@ func findVM(ctx *context.VMContext) (types.ManagedObjectReference, error) {
 	return objRef.Reference(), nil
 }
 
+// vmInstanceUUIDKey returns the instance UUID recorded in the extraConfig of
+// a VM when it was cloned, or an empty string for a VM cloned before it was
+// recorded.
+func vmInstanceUUIDKey(ctx *context.VMContext, vm *object.VirtualMachine) (string, error) {
+	var obj mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.extraConfig"}, &obj); err != nil {
+		return "", errors.Wrapf(err, "unable to get extra config of vm %s", vm.InventoryPath)
+	}
+	if obj.Config == nil {
+		return "", nil
+	}
+	var uuid string
+	for _, option := range obj.Config.ExtraConfig {
+		if value := option.GetOptionValue(); value.Key == extra.InstanceUUIDKey {
+			uuid, _ = value.Value.(string)
+		}
+	}
+	return uuid, nil
+}
+
 func getTask(ctx *context.VMContext) *mo.Task {
 	if ctx.VSphereVM.Status.TaskRef == "" {
 		return nil
@@ -127,9 +180,10 @@ func checkAndRetryTask(ctx *context.VMContext, task *mo.Task) (bool, error) {
 	// If no task was found then make sure to clear the VSphereVM
 	// resource's Status.TaskRef field.
 	if task == nil {
-		ctx.VSphereVM.Status.TaskRef = ""
+		clearTask(ctx)
 		return false, nil
 	}
+	ctx.VSphereVM.Status.Task = newTaskStatus(ctx.VSphereVM.Status.Task, task)
 
 	// Since RetryAfter is set, the last task failed. Wait for the RetryAfter time duration to expire
 	// before checking/resetting the task.
@@ -137,6 +191,19 @@ func checkAndRetryTask(ctx *context.VMContext, task *mo.Task) (bool, error) {
 		return false, errors.Errorf("last task failed retry after %v", ctx.VSphereVM.Status.RetryAfter)
 	}
 
+	// Give up on a clone that exceeds its timeout.
+	if cloneTimedOut(task, ctx.Timeouts.Clone, time.Now()) {
+		ctx.Logger.Info("clone exceeded its timeout, cancelling the task", "timeout", ctx.Timeouts.Clone)
+		if ctx.Session != nil {
+			if err := object.NewTask(ctx.Session.Client.Client, task.Reference()).Cancel(ctx); err != nil {
+				ctx.Logger.Error(err, "failed to cancel the clone task")
+			}
+		}
+		clearTask(ctx)
+		markProvisioningTimeout(ctx, "clone", ctx.Timeouts.Clone)
+		return true, nil
+	}
+
 	// Otherwise the course of action is determined by the state of the task.
 	logger := ctx.Logger.WithName(task.Reference().Value)
 	logger.Info("task found", "state", task.Info.State, "description-id", task.Info.DescriptionId)
@@ -149,10 +216,12 @@ func checkAndRetryTask(ctx *context.VMContext, task *mo.Task) (bool, error) {
 		return true, nil
 	case types.TaskInfoStateSuccess:
 		logger.Info("task is a success", "description-id", task.Info.DescriptionId)
-		ctx.VSphereVM.Status.TaskRef = ""
+		observeTask(ctx, task.Info)
+		clearTask(ctx)
 		return false, nil
 	case types.TaskInfoStateError:
 		logger.Info("task failed", "description-id", task.Info.DescriptionId)
+		releaseTaskSlot(ctx)
 
 		// NOTE: When a task fails there is not simple way to understand which operation is failing (e.g. cloning or powering on)
 		// so we are reporting failures using a dedicated reason until we find a better solution.
@@ -161,14 +230,19 @@ func checkAndRetryTask(ctx *context.VMContext, task *mo.Task) (bool, error) {
 		if task.Info.Description != nil {
 			description = task.Info.Description.Message
 		}
-		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.TaskFailure, clusterv1.ConditionSeverityInfo, description)
+		if description == "" {
+			description = taskError(task)
+		}
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.TaskFailureReason, clusterv1.ConditionSeverityInfo, description)
 
 		// Instead of directly requeuing the failed task, wait for the RetryAfter duration to pass
 		// before resetting the taskRef from the VSphereVM status.
 		if ctx.VSphereVM.Status.RetryAfter.IsZero() {
+			observeTask(ctx, task.Info)
+			ctx.Recorder.Warnf(ctx.VSphereVM, "TaskFailed", "task %s (%s) failed: %s", task.Reference().Value, task.Info.DescriptionId, taskError(task))
 			ctx.VSphereVM.Status.RetryAfter = metav1.Time{Time: time.Now().Add(1 * time.Minute)}
 		} else {
-			ctx.VSphereVM.Status.TaskRef = ""
+			clearTask(ctx)
 			ctx.VSphereVM.Status.RetryAfter = metav1.Time{}
 		}
 		return true, nil
@@ -177,16 +251,230 @@ func checkAndRetryTask(ctx *context.VMContext, task *mo.Task) (bool, error) {
 	}
 }
 
+// observeTask observes the duration of the task of the VSphereVM which
+// completed, and records its result if the operations are audited.
+func observeTask(ctx *context.VMContext, info types.TaskInfo) {
+	session.ObserveTask(ctx.VSphereVM.Spec.Server, info)
+	if ctx.Session != nil {
+		ctx.Session.RecordTask(ctx, info)
+	}
+}
+
+// clearTask stops tracking the task of the VSphereVM, and releases its slot
+// of the vCenter.
+func clearTask(ctx *context.VMContext) {
+	ctx.VSphereVM.Status.TaskRef = ""
+	ctx.VSphereVM.Status.Task = nil
+	releaseTaskSlot(ctx)
+}
+
+// releaseTaskSlot releases the slot of the vCenter held by the VSphereVM for
+// its task.
+func releaseTaskSlot(ctx *context.VMContext) {
+	if ctx.ControllerContext == nil || ctx.ControllerManagerContext == nil {
+		return
+	}
+	ctx.TaskThrottle.Release(ctx.VSphereVM.Spec.Server, string(ctx.VSphereVM.UID))
+}
+
+// acquireTaskSlot returns true when the VSphereVM may issue a task of the
+// operation to its vCenter, whose slot is then held until the task is seen
+// complete. Otherwise the VSphereVM waits for a slot, and is reconciled again
+// after throttle.RequeueAfter.
+func acquireTaskSlot(ctx *context.VMContext, operation throttle.Operation) bool {
+	cluster := ctx.VSphereVM.Namespace + "/" + ctx.VSphereVM.Labels[clusterv1.ClusterLabelName]
+	if ctx.TaskThrottle.Acquire(ctx.VSphereVM.Spec.Server, operation, cluster, string(ctx.VSphereVM.UID)) {
+		return true
+	}
+	ctx.Logger.Info("waiting for a slot of the vCenter to issue the task", "operation", operation)
+	return false
+}
+
+// taskProgressStep is the granularity of the progress of a task recorded in
+// the VSphereVM. Each change of the status triggers a reconcile of the
+// VSphereVM, which must not happen at every progress update of the task.
+const taskProgressStep = 20
+
+// newTaskStatus returns the status of a task as recorded in the VSphereVM. The
+// current status is kept while the task reports progress within the same step
+// of taskProgressStep, along with the description of its current step.
+func newTaskStatus(current *infrav1.VirtualMachineTaskStatus, task *mo.Task) *infrav1.VirtualMachineTaskStatus {
+	status := &infrav1.VirtualMachineTaskStatus{
+		Ref:           task.Reference().Value,
+		DescriptionID: task.Info.DescriptionId,
+		State:         string(task.Info.State),
+		Progress:      task.Info.Progress - task.Info.Progress%taskProgressStep,
+		QueueTime:     metav1.NewTime(task.Info.QueueTime),
+		Error:         taskError(task),
+	}
+	if current != nil && current.Ref == status.Ref && current.State == status.State &&
+		current.Progress == status.Progress && current.Error == status.Error {
+		return current
+	}
+	if task.Info.Description != nil {
+		status.Description = task.Info.Description.Message
+	}
+	if task.Info.StartTime != nil {
+		startTime := metav1.NewTime(*task.Info.StartTime)
+		status.StartTime = &startTime
+	}
+	return status
+}
+
+// taskError returns the fault message of a failed task.
+func taskError(task *mo.Task) string {
+	switch {
+	case task.Info.Error == nil:
+		return ""
+	case task.Info.Error.LocalizedMessage != "":
+		return task.Info.Error.LocalizedMessage
+	case task.Info.Error.Fault != nil:
+		t := reflect.TypeOf(task.Info.Error.Fault)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		return t.Name()
+	}
+	return ""
+}
+
+// cloneTimedOut returns true if the task is a clone which has been queued or
+// running for longer than the timeout. A zero timeout never expires.
+func cloneTimedOut(task *mo.Task, timeout time.Duration, now time.Time) bool {
+	if timeout == 0 || task.Info.DescriptionId != cloneTaskDescriptionID {
+		return false
+	}
+	if task.Info.State != types.TaskInfoStateQueued && task.Info.State != types.TaskInfoStateRunning {
+		return false
+	}
+	started := task.Info.QueueTime
+	if task.Info.StartTime != nil {
+		started = *task.Info.StartTime
+	}
+	return now.Sub(started) > timeout
+}
+
+// bootTimeoutPhase returns the phase of the provisioning which exceeded its
+// timeout since the VM was powered on, or an empty string if none did.
+func bootTimeoutPhase(timeouts context.ProvisioningTimeouts, bootTime time.Time, toolsRunning, hasIPAddrs bool, now time.Time) (string, time.Duration) {
+	elapsed := now.Sub(bootTime)
+	if !toolsRunning && timeouts.ToolsStart > 0 && elapsed > timeouts.ToolsStart {
+		return "VMware Tools start", timeouts.ToolsStart
+	}
+	if !hasIPAddrs && timeouts.IPAcquisition > 0 && elapsed > timeouts.IPAcquisition {
+		return "IP acquisition", timeouts.IPAcquisition
+	}
+	return "", 0
+}
+
+// markProvisioningTimeout marks the VSphereVM as failed because a phase of
+// its provisioning exceeded its timeout.
+func markProvisioningTimeout(ctx *context.VMContext, phase string, timeout time.Duration) {
+	message := fmt.Sprintf("%s did not complete within %s", phase, timeout)
+	ctx.VSphereVM.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.CreateMachineError)
+	ctx.VSphereVM.Status.FailureMessage = pointer.String(message)
+	conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.ProvisioningTimeoutReason, clusterv1.ConditionSeverityError, message)
+}
+
+// resourceUpdate returns the spec hot-adding the numCPUs, memoryMiB and diskGiB
+// of the VSphereVM to the running VM, or nil if nothing can be hot-added, and
+// the names of the fields which changed but cannot be updated in place.
+func resourceUpdate(vsphereVM *infrav1.VSphereVM, config *types.VirtualMachineConfigInfo) (*types.VirtualMachineConfigSpec, []string) {
+	var (
+		spec         types.VirtualMachineConfigSpec
+		changed      bool
+		needsRollout []string
+	)
+
+	if numCPUs := vsphereVM.Spec.NumCPUs; numCPUs != 0 {
+		// The clone enforces the same minimum.
+		if numCPUs < 2 {
+			numCPUs = 2
+		}
+		coresPerSocket := config.Hardware.NumCoresPerSocket
+		if coresPerSocket == 0 {
+			coresPerSocket = 1
+		}
+		switch current := config.Hardware.NumCPU; {
+		case numCPUs == current:
+		case numCPUs > current && numCPUs%coresPerSocket == 0 && pointer.BoolDeref(config.CpuHotAddEnabled, false):
+			spec.NumCPUs = numCPUs
+			changed = true
+		default:
+			needsRollout = append(needsRollout, "numCPUs")
+		}
+	}
+
+	if memMiB := vsphereVM.Spec.MemoryMiB; memMiB != 0 {
+		switch current := int64(config.Hardware.MemoryMB); {
+		case memMiB == current:
+		case memMiB > current && pointer.BoolDeref(config.MemoryHotAddEnabled, false):
+			spec.MemoryMB = memMiB
+			changed = true
+		default:
+			needsRollout = append(needsRollout, "memoryMiB")
+		}
+	}
+
+	if diskGiB := vsphereVM.Spec.DiskGiB; diskGiB != 0 {
+		disks := object.VirtualDeviceList(config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))
+		if len(disks) != 0 {
+			primaryDisk := *disks[0].(*types.VirtualDisk) //nolint:forcetypeassert
+			capacityKB := int64(diskGiB) * 1024 * 1024
+			switch {
+			case capacityKB == primaryDisk.CapacityInKB:
+			// The disk of a linked clone is a delta disk, which cannot be extended.
+			case capacityKB > primaryDisk.CapacityInKB && vsphereVM.Status.CloneMode != infrav1.LinkedClone:
+				primaryDisk.CapacityInKB = capacityKB
+				spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+					Operation: types.VirtualDeviceConfigSpecOperationEdit,
+					Device:    &primaryDisk,
+				})
+				changed = true
+			default:
+				needsRollout = append(needsRollout, "diskGiB")
+			}
+		}
+	}
+
+	if !changed {
+		return nil, needsRollout
+	}
+	return &spec, needsRollout
+}
+
+// changedOptionValues returns the options of the desired extra config whose
+// value differs from the one in the existing extra config of a VM.
+func changedOptionValues(existing []types.BaseOptionValue, desired extra.Config) extra.Config {
+	values := map[string]interface{}{}
+	for _, ec := range existing {
+		if optVal := ec.GetOptionValue(); optVal != nil {
+			values[optVal.Key] = optVal.Value
+		}
+	}
+	var changed extra.Config
+	for _, ec := range desired {
+		if optVal := ec.GetOptionValue(); values[optVal.Key] != optVal.Value {
+			changed = append(changed, ec)
+		}
+	}
+	return changed
+}
+
+// reconcileVSphereVMWhenNetworkIsReady triggers a reconcile request every time
+// the powered on VM reports a new IP address. When the IPAcquisition timeout
+// is set, the wait is bounded by it, and a reconcile request is triggered once
+// it expires.
 func reconcileVSphereVMWhenNetworkIsReady(ctx *virtualMachineContext, powerOnTask *object.Task) {
 	reconcileVSphereVMOnChannel(
 		&ctx.VMContext,
-		func() (<-chan []interface{}, <-chan error, error) {
+		func(waitCtx goctx.Context) (<-chan []interface{}, <-chan error, error) {
 			// Wait for the VM to be powered on.
-			powerOnTaskInfo, err := powerOnTask.WaitForResult(ctx)
+			powerOnTaskInfo, err := powerOnTask.WaitForResult(waitCtx)
 			if err != nil && powerOnTaskInfo == nil {
 				return nil, nil, errors.Wrapf(err, "failed to wait for power on op for vm %s", ctx)
 			}
-			powerState, err := ctx.Obj.PowerState(ctx)
+			powerState, err := ctx.Obj.PowerState(waitCtx)
 			if err != nil {
 				return nil, nil, errors.Wrapf(err, "failed to get power state for vm %s", ctx)
 			}
@@ -196,8 +484,19 @@ func reconcileVSphereVMWhenNetworkIsReady(ctx *virtualMachineContext, powerOnTas
 					powerState, ctx)
 			}
 
+			var (
+				ipWaitCtx goctx.Context
+				cancel    goctx.CancelFunc
+			)
+			if timeout := ctx.Timeouts.IPAcquisition; timeout > 0 {
+				ipWaitCtx, cancel = goctx.WithTimeout(waitCtx, timeout)
+			} else {
+				ipWaitCtx, cancel = goctx.WithCancel(waitCtx)
+			}
+
 			// Wait for all NICs to have valid MAC addresses.
-			if err := waitForMacAddresses(ctx); err != nil {
+			if err := waitForMacAddresses(ipWaitCtx, ctx); err != nil {
+				cancel()
 				return nil, nil, errors.Wrapf(err, "failed to wait for mac addresses for vm %s", ctx)
 			}
 
@@ -205,21 +504,31 @@ func reconcileVSphereVMWhenNetworkIsReady(ctx *virtualMachineContext, powerOnTas
 			// for all NICs to have MAC addresses in order to ensure the order
 			// of the retrieved MAC addresses matches the order of the device
 			// specs, and not the propery change order.
-			_, macToDeviceIndex, deviceToMacIndex, err := getMacAddresses(ctx)
+			_, macToDeviceIndex, deviceToMacIndex, err := getMacAddresses(ipWaitCtx, ctx)
 			if err != nil {
+				cancel()
 				return nil, nil, errors.Wrapf(err, "failed to get mac addresses for vm %s", ctx)
 			}
 
 			// Wait for the IP addresses to show up for the VM.
-			chanIPAddresses, chanErrs := waitForIPAddresses(ctx, macToDeviceIndex, deviceToMacIndex)
+			chanIPAddresses, chanErrs := waitForIPAddresses(ipWaitCtx, ctx, macToDeviceIndex, deviceToMacIndex)
 
-			// Trigger a reconcile every time a new IP is discovered.
+			// Trigger a reconcile every time a new IP is discovered, and once
+			// the wait times out.
 			chanOfLoggerKeysAndValues := make(chan []interface{})
 			go func() {
+				defer cancel()
 				for ip := range chanIPAddresses {
-					chanOfLoggerKeysAndValues <- []interface{}{
-						"reason", "network",
-						"ipAddress", ip,
+					select {
+					case chanOfLoggerKeysAndValues <- []interface{}{"reason", "network", "ipAddress", ip}:
+					case <-waitCtx.Done():
+						return
+					}
+				}
+				if ipWaitCtx.Err() == goctx.DeadlineExceeded {
+					select {
+					case chanOfLoggerKeysAndValues <- []interface{}{"reason", "timeout", "timeout", ctx.Timeouts.IPAcquisition}:
+					case <-waitCtx.Done():
 					}
 				}
 			}()
@@ -227,6 +536,39 @@ func reconcileVSphereVMWhenNetworkIsReady(ctx *virtualMachineContext, powerOnTas
 		})
 }
 
+// reconcileVSphereVMWhenPoweredOff triggers a reconcile request once the VM is
+// powered off or, unless the deadline is zero, once the deadline is reached.
+func reconcileVSphereVMWhenPoweredOff(ctx *virtualMachineContext, deadline time.Time) {
+	reconcileVSphereVMOnFuncCompletion(
+		&ctx.VMContext,
+		func(waitCtx goctx.Context) ([]interface{}, error) {
+			if !deadline.IsZero() {
+				var cancel goctx.CancelFunc
+				waitCtx, cancel = goctx.WithDeadline(waitCtx, deadline)
+				defer cancel()
+			}
+
+			err := property.Wait(
+				waitCtx, property.DefaultCollector(ctx.Session.Client.Client), ctx.Ref,
+				[]string{"runtime.powerState"},
+				func(changes []types.PropertyChange) bool {
+					for _, change := range changes {
+						if change.Val == types.VirtualMachinePowerStatePoweredOff {
+							return true
+						}
+					}
+					return false
+				})
+			if err != nil && waitCtx.Err() != goctx.DeadlineExceeded {
+				return nil, errors.Wrapf(err, "failed to wait for vm %s to be powered off", ctx)
+			}
+			return []interface{}{"reason", "guestShutdown"}, nil
+		})
+}
+
+// reconcileVSphereVMOnTaskCompletion triggers a reconcile request once the
+// task of the VSphereVM completes. The wait for a clone task is bounded by the
+// Clone timeout, if any, and a reconcile request is triggered once it expires.
 func reconcileVSphereVMOnTaskCompletion(ctx *context.VMContext) {
 	task := getTask(ctx)
 	if task == nil {
@@ -235,6 +577,7 @@ func reconcileVSphereVMOnTaskCompletion(ctx *context.VMContext) {
 			"reason", "no-task")
 		return
 	}
+	ctx.VSphereVM.Status.Task = newTaskStatus(ctx.VSphereVM.Status.Task, task)
 	taskRef := task.Reference()
 	taskHelper := object.NewTask(ctx.Session.Client.Client, taskRef)
 
@@ -245,8 +588,30 @@ func reconcileVSphereVMOnTaskCompletion(ctx *context.VMContext) {
 		"task-entity-name", task.Info.EntityName,
 		"task-description-id", task.Info.DescriptionId)
 
-	reconcileVSphereVMOnFuncCompletion(ctx, func() ([]interface{}, error) {
-		taskInfo, err := taskHelper.WaitForResult(ctx)
+	var deadline time.Time
+	if timeout := ctx.Timeouts.Clone; timeout > 0 && task.Info.DescriptionId == cloneTaskDescriptionID {
+		started := task.Info.QueueTime
+		if task.Info.StartTime != nil {
+			started = *task.Info.StartTime
+		}
+		deadline = started.Add(timeout)
+	}
+
+	reconcileVSphereVMOnFuncCompletion(ctx, func(waitCtx goctx.Context) ([]interface{}, error) {
+		if !deadline.IsZero() {
+			var cancel goctx.CancelFunc
+			waitCtx, cancel = goctx.WithDeadline(waitCtx, deadline)
+			defer cancel()
+		}
+
+		taskInfo, err := taskHelper.WaitForResult(waitCtx)
+		if waitCtx.Err() == goctx.DeadlineExceeded {
+			return []interface{}{
+				"reason", "timeout",
+				"task-ref", taskRef,
+				"timeout", ctx.Timeouts.Clone,
+			}, nil
+		}
 
 		// An error is only returned if the process of waiting for the result
 		// failed, *not* if the task itself failed.
@@ -271,13 +636,22 @@ func reconcileVSphereVMOnTaskCompletion(ctx *context.VMContext) {
 	})
 }
 
-func reconcileVSphereVMOnFuncCompletion(ctx *context.VMContext, waitFn func() (loggerKeysAndValues []interface{}, _ error)) {
+// reconcileVSphereVMOnFuncCompletion calls the wait function in a background
+// goroutine, and triggers a reconcile request once it returns. The wait
+// function is passed the wait context of the VSphereVM, which is cancelled when
+// the VSphereVM is deleted or the controller manager stops.
+func reconcileVSphereVMOnFuncCompletion(ctx *context.VMContext, waitFn func(waitCtx goctx.Context) (loggerKeysAndValues []interface{}, _ error)) {
 	obj := ctx.VSphereVM.DeepCopy()
 	gvk := obj.GetObjectKind().GroupVersionKind()
+	waitCtx := ctx.WaitContextFor(obj.UID)
 
 	// Wait on the function to complete in a background goroutine.
 	go func() {
-		loggerKeysAndValues, err := waitFn()
+		loggerKeysAndValues, err := waitFn(waitCtx)
+		if waitCtx.Err() != nil {
+			ctx.Logger.V(4).Info("wait cancelled", "reason", waitCtx.Err())
+			return
+		}
 		if err != nil {
 			ctx.Logger.Error(err, "failed to wait on func")
 			return
@@ -287,23 +661,30 @@ func reconcileVSphereVMOnFuncCompletion(ctx *context.VMContext, waitFn func() (l
 		// a reconcile event for the associated resource by sending a
 		// GenericEvent into the event channel for the resource type.
 		ctx.Logger.Info("triggering GenericEvent", loggerKeysAndValues...)
-		eventChannel := ctx.GetGenericEventChannelFor(gvk)
-		eventChannel <- event.GenericEvent{
-			Object: obj,
+		select {
+		case ctx.GetGenericEventChannelFor(gvk) <- event.GenericEvent{Object: obj}:
+		case <-waitCtx.Done():
 		}
 	}()
 }
 
-func reconcileVSphereVMOnChannel(ctx *context.VMContext, waitFn func() (<-chan []interface{}, <-chan error, error)) {
+// reconcileVSphereVMOnChannel calls the wait function in a background
+// goroutine, and triggers a reconcile request for every set of logger keys and
+// values received on the channel it returns, until an error is received or the
+// wait context of the VSphereVM is cancelled.
+func reconcileVSphereVMOnChannel(ctx *context.VMContext, waitFn func(waitCtx goctx.Context) (<-chan []interface{}, <-chan error, error)) {
 	obj := ctx.VSphereVM.DeepCopy()
 	gvk := obj.GetObjectKind().GroupVersionKind()
+	waitCtx := ctx.WaitContextFor(obj.UID)
 
 	// Send a generic event for every set of logger keys/values received
 	// on the channel.
 	go func() {
-		chanOfLoggerKeysAndValues, chanErrs, err := waitFn()
+		chanOfLoggerKeysAndValues, chanErrs, err := waitFn(waitCtx)
 		if err != nil {
-			ctx.Logger.Error(err, "failed to wait on func")
+			if waitCtx.Err() == nil {
+				ctx.Logger.Error(err, "failed to wait on func")
+			}
 			return
 		}
 		for {
@@ -312,22 +693,22 @@ func reconcileVSphereVMOnChannel(ctx *context.VMContext, waitFn func() (<-chan [
 				if loggerKeysAndValues == nil {
 					return
 				}
+				// Trigger a reconcile event for the associated resource by
+				// sending a GenericEvent into the event channel for the resource
+				// type.
+				ctx.Logger.Info("triggering GenericEvent", loggerKeysAndValues...)
 				go func() {
-					// Trigger a reconcile event for the associated resource by
-					// sending a GenericEvent into the event channel for the resource
-					// type.
-					ctx.Logger.Info("triggering GenericEvent", loggerKeysAndValues...)
-					eventChannel := ctx.GetGenericEventChannelFor(gvk)
-					eventChannel <- event.GenericEvent{
-						Object: obj,
+					select {
+					case ctx.GetGenericEventChannelFor(gvk) <- event.GenericEvent{Object: obj}:
+					case <-waitCtx.Done():
 					}
 				}()
 			case err := <-chanErrs:
-				if err != nil {
+				if err != nil && waitCtx.Err() == nil {
 					ctx.Logger.Error(err, "error occurred while waiting to trigger a generic event")
 				}
 				return
-			case <-ctx.Done():
+			case <-waitCtx.Done():
 				return
 			}
 		}
@@ -336,9 +717,9 @@ func reconcileVSphereVMOnChannel(ctx *context.VMContext, waitFn func() (<-chan [
 
 // waitForMacAddresses waits for all configured network devices to have
 // valid MAC addresses.
-func waitForMacAddresses(ctx *virtualMachineContext) error {
+func waitForMacAddresses(waitCtx goctx.Context, ctx *virtualMachineContext) error {
 	return property.Wait(
-		ctx, property.DefaultCollector(ctx.Session.Client.Client),
+		waitCtx, property.DefaultCollector(ctx.Session.Client.Client),
 		ctx.Obj.Reference(), []string{"config.hardware.device"},
 		func(propertyChanges []types.PropertyChange) bool {
 			for _, propChange := range propertyChanges {
@@ -363,14 +744,14 @@ func waitForMacAddresses(ctx *virtualMachineContext) error {
 // This happens separately from waitForMacAddresses to ensure returned order of
 // devices matches the spec and not order in which the property changes were
 // noticed.
-func getMacAddresses(ctx *virtualMachineContext) ([]string, map[string]int, map[int]string, error) {
+func getMacAddresses(waitCtx goctx.Context, ctx *virtualMachineContext) ([]string, map[string]int, map[int]string, error) {
 	var (
 		vm                   mo.VirtualMachine
 		macAddresses         []string
 		macToDeviceSpecIndex = map[string]int{}
 		deviceSpecIndexToMac = map[int]string{}
 	)
-	if err := ctx.Obj.Properties(ctx, ctx.Obj.Reference(), []string{"config.hardware.device"}, &vm); err != nil {
+	if err := ctx.Obj.Properties(waitCtx, ctx.Obj.Reference(), []string{"config.hardware.device"}, &vm); err != nil {
 		return nil, nil, nil, err
 	}
 	i := 0
@@ -394,6 +775,7 @@ func getMacAddresses(ctx *virtualMachineContext) ([]string, map[string]int, map[
 // that use the maps.
 // nolint:gocyclo,gocognit
 func waitForIPAddresses(
+	waitCtx goctx.Context,
 	ctx *virtualMachineContext,
 	macToDeviceIndex map[string]int,
 	deviceToMacIndex map[int]string) (<-chan string, <-chan error) {
@@ -428,13 +810,19 @@ func waitForIPAddresses(
 				// device spec.
 				deviceSpecIndex, ok := macToDeviceIndex[mac]
 				if !ok {
-					chanErrs <- errors.Errorf("unknown device spec index for mac %s while waiting for ip addresses for vm %s", mac, ctx)
+					select {
+					case chanErrs <- errors.Errorf("unknown device spec index for mac %s while waiting for ip addresses for vm %s", mac, ctx):
+					case <-waitCtx.Done():
+					}
 					// Return true to stop the property collector from waiting
 					// on any more changes.
 					return true
 				}
 				if deviceSpecIndex < 0 || deviceSpecIndex >= len(ctx.VSphereVM.Spec.Network.Devices) {
-					chanErrs <- errors.Errorf("invalid device spec index %d for mac %s while waiting for ip addresses for vm %s", deviceSpecIndex, mac, ctx)
+					select {
+					case chanErrs <- errors.Errorf("invalid device spec index %d for mac %s while waiting for ip addresses for vm %s", deviceSpecIndex, mac, ctx):
+					case <-waitCtx.Done():
+					}
 					// Return true to stop the property collector from waiting
 					// on any more changes.
 					return true
@@ -481,7 +869,11 @@ func waitForIPAddresses(
 								"addressType", "static",
 								"addressValue", discoveredIP)
 							macToHasStaticIP[mac][discoveredIP] = struct{}{}
-							chanIPAddresses <- discoveredIP
+							select {
+							case chanIPAddresses <- discoveredIP:
+							case <-waitCtx.Done():
+								return true
+							}
 						}
 					case gonet.ParseIP(discoveredIP).To4() != nil:
 						// An IPv4 address...
@@ -493,20 +885,29 @@ func waitForIPAddresses(
 									"addressType", "dhcp4",
 									"addressValue", discoveredIP)
 								macToHasIPv4Lease[mac] = struct{}{}
-								chanIPAddresses <- discoveredIP
+								select {
+								case chanIPAddresses <- discoveredIP:
+								case <-waitCtx.Done():
+									return true
+								}
 							}
 						}
 					default:
-						// An IPv6 address..
-						if deviceSpec.DHCP6 {
+						// An IPv6 address, leased with DHCP6 or autoconfigured
+						// with SLAAC.
+						if deviceSpec.DHCP6 || deviceSpec.SLAAC {
 							// Has an IPv6 lease been discovered yet?
 							if _, ok := macToHasIPv6Lease[mac]; !ok {
 								ctx.Logger.Info(
 									"discovered IP address",
-									"addressType", "dhcp6",
+									"addressType", ipv6AddressType(deviceSpec),
 									"addressValue", discoveredIP)
 								macToHasIPv6Lease[mac] = struct{}{}
-								chanIPAddresses <- discoveredIP
+								select {
+								case chanIPAddresses <- discoveredIP:
+								case <-waitCtx.Done():
+									return true
+								}
 							}
 						}
 					}
@@ -519,7 +920,10 @@ func waitForIPAddresses(
 		for i, deviceSpec := range ctx.VSphereVM.Spec.Network.Devices {
 			mac, ok := deviceToMacIndex[i]
 			if !ok {
-				chanErrs <- errors.Errorf("invalid mac index %d waiting for ip addresses for vm %s", i, ctx)
+				select {
+				case chanErrs <- errors.Errorf("invalid mac index %d waiting for ip addresses for vm %s", i, ctx):
+				case <-waitCtx.Done():
+				}
 
 				// Return true to stop the property collector from waiting
 				// on any more changes.
@@ -535,13 +939,13 @@ func waitForIPAddresses(
 					return false
 				}
 			}
-			// If the device spec requires DHCP6 then the Wait is not
-			// over if there is no IPv4 lease.
-			if deviceSpec.DHCP6 {
+			// If the device spec requires DHCP6 or SLAAC then the Wait is
+			// not over if there is no IPv6 lease.
+			if deviceSpec.DHCP6 || deviceSpec.SLAAC {
 				if _, ok := macToHasIPv6Lease[mac]; !ok {
 					ctx.Logger.Info(
 						"the VM is missing the requested IP address",
-						"addressType", "dhcp6")
+						"addressType", ipv6AddressType(deviceSpec))
 					return false
 				}
 			}
@@ -568,10 +972,14 @@ func waitForIPAddresses(
 	// network device specs. However, every time a new IP is discovered,
 	// a reconcile request will be triggered for the VSphereVM.
 	go func() {
-		if err := property.Wait(
-			ctx, propCollector, ctx.Obj.Reference(),
-			[]string{"guest.net"}, onPropertyChange); err != nil {
-			chanErrs <- errors.Wrapf(err, "failed to wait for ip addresses for vm %s", ctx)
+		err := property.Wait(
+			waitCtx, propCollector, ctx.Obj.Reference(),
+			[]string{"guest.net"}, onPropertyChange)
+		if err != nil && waitCtx.Err() == nil {
+			select {
+			case chanErrs <- errors.Wrapf(err, "failed to wait for ip addresses for vm %s", ctx):
+			case <-waitCtx.Done():
+			}
 		}
 		close(chanIPAddresses)
 		close(chanErrs)
@@ -579,3 +987,12 @@ func waitForIPAddresses(
 
 	return chanIPAddresses, chanErrs
 }
+
+// ipv6AddressType returns the type of the dynamic IPv6 addresses of a device,
+// as logged while waiting for them.
+func ipv6AddressType(deviceSpec infrav1.NetworkDeviceSpec) string {
+	if deviceSpec.DHCP6 {
+		return "dhcp6"
+	}
+	return "slaac"
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package govmomi
 
 import (
+	goctx "context"
 	"fmt"
 	"testing"
 	"time"
@@ -26,10 +27,15 @@ import (
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientrecord "k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/cluster-api/util/conditions"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
 )
 
 func Test_ShouldRetryTask(t *testing.T) {
@@ -111,20 +117,232 @@ func Test_ShouldRetryTask(t *testing.T) {
 
 	t.Run("when failed task was previously not checked", func(t *testing.T) {
 		g := NewWithT(t)
+		recorder := clientrecord.NewFakeRecorder(1)
 		vmCtx := &context.VMContext{
-			Logger: logr.Discard(),
+			ControllerContext: &context.ControllerContext{Recorder: record.New(recorder)},
+			Logger:            logr.Discard(),
 			VSphereVM: &infrav1.VSphereVM{Status: infrav1.VSphereVMStatus{
 				// RetryAfter is not set since this is the first reconcile
 				TaskRef: "task-123",
 			}},
 		}
 		task := baseTask(types.TaskInfoStateError, "task is stuck")
+		task.Info.DescriptionId = cloneTaskDescriptionID
+		task.Info.Error = &types.LocalizedMethodFault{LocalizedMessage: "insufficient disk space"}
 
 		reconciled, err := checkAndRetryTask(vmCtx, &task)
 		g.Expect(err).NotTo(HaveOccurred())
 		g.Expect(reconciled).To(BeTrue())
 		g.Expect(conditions.IsFalse(vmCtx.VSphereVM, infrav1.VMProvisionedCondition))
 		g.Expect(vmCtx.VSphereVM.Status.RetryAfter.Unix()).To(BeNumerically("<=", metav1.Now().Add(1*time.Minute).Unix()))
+		g.Expect(vmCtx.VSphereVM.Status.Task).NotTo(BeNil())
+		g.Expect(vmCtx.VSphereVM.Status.Task.State).To(Equal(string(types.TaskInfoStateError)))
+		g.Expect(vmCtx.VSphereVM.Status.Task.Error).To(Equal("insufficient disk space"))
+		g.Expect(<-recorder.Events).To(And(ContainSubstring("TaskFailed"), ContainSubstring("insufficient disk space")))
+	})
+
+	t.Run("when task is running", func(t *testing.T) {
+		g := NewWithT(t)
+		vmCtx := &context.VMContext{
+			Logger: logr.Discard(),
+			VSphereVM: &infrav1.VSphereVM{Status: infrav1.VSphereVMStatus{
+				TaskRef: "task-123",
+			}},
+		}
+		started := time.Now().Add(-time.Minute)
+		task := baseTask(types.TaskInfoStateRunning, "")
+		task.Info.DescriptionId = cloneTaskDescriptionID
+		task.Info.Progress = 40
+		task.Info.StartTime = &started
+
+		_, err := checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vmCtx.VSphereVM.Status.Task).NotTo(BeNil())
+		g.Expect(vmCtx.VSphereVM.Status.Task.DescriptionID).To(Equal(cloneTaskDescriptionID))
+		g.Expect(vmCtx.VSphereVM.Status.Task.Progress).To(Equal(int32(40)))
+		g.Expect(vmCtx.VSphereVM.Status.Task.StartTime.Time).To(BeTemporally("~", started, time.Second))
+
+		// The progress within the same step is not recorded.
+		recorded := vmCtx.VSphereVM.Status.Task
+		task.Info.Progress = 55
+		task.Info.Description = &types.LocalizableMessage{Message: "Copying Virtual Machine files"}
+		_, err = checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vmCtx.VSphereVM.Status.Task).To(BeIdenticalTo(recorded))
+
+		task.Info.Progress = 61
+		_, err = checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vmCtx.VSphereVM.Status.Task.Progress).To(Equal(int32(60)))
+		g.Expect(vmCtx.VSphereVM.Status.Task.Description).To(Equal("Copying Virtual Machine files"))
+
+		task.Info.State = types.TaskInfoStateSuccess
+		_, err = checkAndRetryTask(vmCtx, &task)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vmCtx.VSphereVM.Status.Task).To(BeNil())
+		g.Expect(vmCtx.VSphereVM.Status.TaskRef).To(BeEmpty())
+	})
+}
+
+func Test_CloneTimedOut(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+	started := now.Add(-2 * time.Hour)
+
+	task := baseTask(types.TaskInfoStateRunning, "")
+	task.Info.DescriptionId = cloneTaskDescriptionID
+	task.Info.StartTime = &started
+	g.Expect(cloneTimedOut(&task, time.Hour, now)).To(BeTrue())
+	g.Expect(cloneTimedOut(&task, 3*time.Hour, now)).To(BeFalse())
+	g.Expect(cloneTimedOut(&task, 0, now)).To(BeFalse())
+
+	task.Info.DescriptionId = "VirtualMachine.powerOn"
+	g.Expect(cloneTimedOut(&task, time.Hour, now)).To(BeFalse())
+
+	queued := baseTask(types.TaskInfoStateQueued, "")
+	queued.Info.DescriptionId = cloneTaskDescriptionID
+	queued.Info.QueueTime = started
+	g.Expect(cloneTimedOut(&queued, time.Hour, now)).To(BeTrue())
+
+	done := baseTask(types.TaskInfoStateSuccess, "")
+	done.Info.DescriptionId = cloneTaskDescriptionID
+	done.Info.StartTime = &started
+	g.Expect(cloneTimedOut(&done, time.Hour, now)).To(BeFalse())
+}
+
+func Test_BootTimeoutPhase(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+	bootTime := now.Add(-20 * time.Minute)
+	timeouts := context.ProvisioningTimeouts{ToolsStart: 10 * time.Minute, IPAcquisition: 30 * time.Minute}
+
+	phase, timeout := bootTimeoutPhase(timeouts, bootTime, false, false, now)
+	g.Expect(phase).To(Equal("VMware Tools start"))
+	g.Expect(timeout).To(Equal(10 * time.Minute))
+
+	phase, _ = bootTimeoutPhase(timeouts, bootTime, true, false, now)
+	g.Expect(phase).To(BeEmpty())
+
+	phase, timeout = bootTimeoutPhase(timeouts, now.Add(-time.Hour), true, false, now)
+	g.Expect(phase).To(Equal("IP acquisition"))
+	g.Expect(timeout).To(Equal(30 * time.Minute))
+
+	phase, _ = bootTimeoutPhase(timeouts, now.Add(-time.Hour), true, true, now)
+	g.Expect(phase).To(BeEmpty())
+
+	phase, _ = bootTimeoutPhase(context.ProvisioningTimeouts{}, now.Add(-time.Hour), false, false, now)
+	g.Expect(phase).To(BeEmpty())
+}
+
+func Test_ResourceUpdate(t *testing.T) {
+	g := NewWithT(t)
+	config := &types.VirtualMachineConfigInfo{
+		Hardware: types.VirtualHardware{
+			NumCPU:            2,
+			NumCoresPerSocket: 2,
+			MemoryMB:          4096,
+			Device: []types.BaseVirtualDevice{
+				&types.VirtualDisk{CapacityInKB: 20 * 1024 * 1024},
+			},
+		},
+	}
+	vsphereVM := &infrav1.VSphereVM{
+		Spec: infrav1.VSphereVMSpec{
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{NumCPUs: 2, MemoryMiB: 4096, DiskGiB: 20},
+		},
+		Status: infrav1.VSphereVMStatus{CloneMode: infrav1.FullClone},
+	}
+
+	spec, needsRollout := resourceUpdate(vsphereVM, config)
+	g.Expect(spec).To(BeNil())
+	g.Expect(needsRollout).To(BeEmpty())
+
+	// Without hot-add, the CPU and memory changes require a rollout, while
+	// the disk of a full clone can be extended.
+	vsphereVM.Spec.NumCPUs = 4
+	vsphereVM.Spec.MemoryMiB = 8192
+	vsphereVM.Spec.DiskGiB = 40
+	spec, needsRollout = resourceUpdate(vsphereVM, config)
+	g.Expect(needsRollout).To(ConsistOf("numCPUs", "memoryMiB"))
+	g.Expect(spec).NotTo(BeNil())
+	g.Expect(spec.NumCPUs).To(BeZero())
+	g.Expect(spec.DeviceChange).To(HaveLen(1))
+	g.Expect(spec.DeviceChange[0].GetVirtualDeviceConfigSpec().Device.(*types.VirtualDisk).CapacityInKB).To(Equal(int64(40 * 1024 * 1024)))
+	g.Expect(config.Hardware.Device[0].(*types.VirtualDisk).CapacityInKB).To(Equal(int64(20 * 1024 * 1024)))
+
+	config.CpuHotAddEnabled = pointer.Bool(true)
+	config.MemoryHotAddEnabled = pointer.Bool(true)
+	spec, needsRollout = resourceUpdate(vsphereVM, config)
+	g.Expect(needsRollout).To(BeEmpty())
+	g.Expect(spec.NumCPUs).To(Equal(int32(4)))
+	g.Expect(spec.MemoryMB).To(Equal(int64(8192)))
+
+	// CPUs are hot-added in whole sockets, and resources are never removed
+	// in place.
+	vsphereVM.Spec.NumCPUs = 3
+	vsphereVM.Spec.MemoryMiB = 2048
+	_, needsRollout = resourceUpdate(vsphereVM, config)
+	g.Expect(needsRollout).To(ConsistOf("numCPUs", "memoryMiB"))
+
+	// The disk of a linked clone cannot be extended.
+	vsphereVM.Spec.NumCPUs = 2
+	vsphereVM.Spec.MemoryMiB = 4096
+	vsphereVM.Status.CloneMode = infrav1.LinkedClone
+	spec, needsRollout = resourceUpdate(vsphereVM, config)
+	g.Expect(spec).To(BeNil())
+	g.Expect(needsRollout).To(ConsistOf("diskGiB"))
+}
+
+func Test_ChangedOptionValues(t *testing.T) {
+	g := NewWithT(t)
+	var desired extra.Config
+	g.Expect(desired.SetCloudInitMetadata([]byte("instance-id: vm"))).To(Succeed())
+	g.Expect(desired.SetCloudInitUserData([]byte("#cloud-config"))).To(Succeed())
+
+	// All the options are set on a VM without extra config.
+	g.Expect(changedOptionValues(nil, desired)).To(HaveLen(4))
+
+	// Only the options which changed are set, in a single reconfigure.
+	var existing extra.Config
+	g.Expect(existing.SetCloudInitMetadata([]byte("instance-id: vm"))).To(Succeed())
+	g.Expect(existing.SetCloudInitUserData([]byte("#cloud-config\nruncmd: []"))).To(Succeed())
+	existing = append(existing, &types.OptionValue{Key: "disk.enableUUID", Value: "TRUE"})
+	changed := changedOptionValues(existing, desired)
+	g.Expect(changed).To(HaveLen(1))
+	g.Expect(changed[0].GetOptionValue().Key).To(Equal("guestinfo.userdata"))
+
+	copy(existing, desired)
+	g.Expect(changedOptionValues(existing, desired)).To(BeEmpty())
+}
+
+func Test_ReconcileVSphereVMOnFuncCompletion(t *testing.T) {
+	t.Run("triggers a reconcile once the wait function returns", func(t *testing.T) {
+		g := NewWithT(t)
+		vmCtx := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		events := vmCtx.GetGenericEventChannelFor(vmCtx.VSphereVM.GetObjectKind().GroupVersionKind())
+
+		reconcileVSphereVMOnFuncCompletion(vmCtx, func(goctx.Context) ([]interface{}, error) {
+			return []interface{}{"reason", "test"}, nil
+		})
+		g.Eventually(events).Should(Receive())
+	})
+
+	t.Run("stops waiting once the waits of the VSphereVM are cancelled", func(t *testing.T) {
+		g := NewWithT(t)
+		vmCtx := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		events := vmCtx.GetGenericEventChannelFor(vmCtx.VSphereVM.GetObjectKind().GroupVersionKind())
+
+		returned := make(chan struct{})
+		reconcileVSphereVMOnFuncCompletion(vmCtx, func(waitCtx goctx.Context) ([]interface{}, error) {
+			defer close(returned)
+			<-waitCtx.Done()
+			return nil, waitCtx.Err()
+		})
+		g.Consistently(returned, 100*time.Millisecond).ShouldNot(BeClosed())
+
+		vmCtx.CancelWaitsFor(vmCtx.VSphereVM.UID)
+		g.Eventually(returned).Should(BeClosed())
+		g.Consistently(events, 100*time.Millisecond).ShouldNot(Receive())
 	})
 }
 
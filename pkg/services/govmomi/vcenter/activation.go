@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+const (
+	// WindowsActivationReportKey is the guestinfo key in which the Windows
+	// guest of a VM reports the LicenseStatus of its Windows license.
+	WindowsActivationReportKey = "guestinfo.capv.windows-activation"
+
+	// productKeySecretKey is the key of the product key in the Secret named
+	// by the productKeySecretName of a Windows activation.
+	productKeySecretKey = "productKey"
+
+	// slmgrCommand runs the software licensing management tool of Windows.
+	slmgrCommand = `cscript //B //NoLogo %SystemRoot%\System32\slmgr.vbs`
+
+	// windowsActivationReportScript reports the LicenseStatus of the Windows
+	// license, identified by the application ID of Windows, with the rpctool
+	// of VMware Tools.
+	windowsActivationReportScript = `$product = Get-CimInstance SoftwareLicensingProduct -Filter "ApplicationID='55c92734-d682-4d71-983e-d6ec3f16059f' AND PartialProductKey IS NOT NULL" | Select-Object -First 1
+& "$env:ProgramFiles\VMware\VMware Tools\rpctool.exe" "info-set ` + WindowsActivationReportKey + ` $($product.LicenseStatus)"
+`
+
+	// windowsActivationReportDir and windowsActivationReportPath are the
+	// directory and the path of the report script in the guest.
+	windowsActivationReportDir  = `%ProgramData%\CAPV`
+	windowsActivationReportPath = windowsActivationReportDir + `\windows-activation.ps1`
+
+	// windowsActivationReportTask is the scheduled task of the guest running
+	// the report script, so that a change of the activation status, e.g. the
+	// end of the grace period, is reported.
+	windowsActivationReportTask = "CAPV Windows activation report"
+
+	// windowsActivationReportInterval is the interval, in hours, of the
+	// scheduled task running the report script.
+	windowsActivationReportInterval = 1
+)
+
+// getProductKey returns the product key of the Secret named by the Windows
+// activation of the VSphereVM, or an empty key when none is named.
+func getProductKey(ctx *context.VMContext, activation *infrav1.WindowsActivationSpec) (string, error) {
+	if activation.ProductKeySecretName == "" {
+		return "", nil
+	}
+	secret := &corev1.Secret{}
+	secretKey := apitypes.NamespacedName{
+		Namespace: ctx.VSphereVM.Namespace,
+		Name:      activation.ProductKeySecretName,
+	}
+	if err := ctx.Client.Get(ctx, secretKey, secret); err != nil {
+		return "", errors.Wrapf(err, "failed to get the product key secret %s", secretKey)
+	}
+	productKey, ok := secret.Data[productKeySecretKey]
+	if !ok || len(productKey) == 0 {
+		return "", errors.Errorf("the product key secret %s has no %s key", secretKey, productKeySecretKey)
+	}
+	return string(productKey), nil
+}
+
+// setSysprepActivation sets the product key of a sysprep customization spec,
+// and appends to the commands run at the first logon after the customization
+// the commands setting the KMS server, activating Windows, reporting its
+// license status, and scheduling the report of its license status every
+// windowsActivationReportInterval hours and at boot. The commands are only
+// run when the spec enables the autologon.
+func setSysprepActivation(spec *types.CustomizationSpec, activation *infrav1.WindowsActivationSpec, productKey string) error {
+	sysprep, ok := spec.Identity.(*types.CustomizationSysprep)
+	if !ok {
+		return errors.Errorf("only sysprep customization specs are supported, got %T", spec.Identity)
+	}
+	if !sysprep.GuiUnattended.AutoLogon || sysprep.GuiUnattended.AutoLogonCount < 1 {
+		return errors.New("the activation of Windows requires the autologon of the sysprep customization spec")
+	}
+	if productKey != "" {
+		sysprep.UserData.ProductId = productKey
+	}
+
+	var commands []string
+	if activation.KMSServer != "" {
+		commands = append(commands, slmgrCommand+" /skms "+activation.KMSServer)
+	}
+	runReport := "powershell -NoProfile -NonInteractive -ExecutionPolicy Bypass -File " + windowsActivationReportPath
+	commands = append(commands,
+		slmgrCommand+" /ato",
+		writeReportScriptCommand(),
+		runReport,
+		fmt.Sprintf(`schtasks /Create /F /RU SYSTEM /SC HOURLY /MO %d /TN "%s" /TR "%s"`, windowsActivationReportInterval, windowsActivationReportTask, runReport),
+		fmt.Sprintf(`schtasks /Create /F /RU SYSTEM /SC ONSTART /TN "%s at boot" /TR "%s"`, windowsActivationReportTask, runReport))
+	if sysprep.GuiRunOnce == nil {
+		sysprep.GuiRunOnce = &types.CustomizationGuiRunOnce{}
+	}
+	sysprep.GuiRunOnce.CommandList = append(sysprep.GuiRunOnce.CommandList, commands...)
+	return nil
+}
+
+// writeReportScriptCommand returns the command writing the report script to
+// windowsActivationReportPath. The script is passed in base64, so that it is
+// not parsed by the command line.
+func writeReportScriptCommand() string {
+	script := base64.StdEncoding.EncodeToString([]byte(windowsActivationReportScript))
+	return fmt.Sprintf(`powershell -NoProfile -NonInteractive -Command "New-Item -ItemType Directory -Force -Path '%s' | Out-Null; [IO.File]::WriteAllBytes('%s', [Convert]::FromBase64String('%s'))"`,
+		windowsActivationReportDir, windowsActivationReportPath, script)
+}
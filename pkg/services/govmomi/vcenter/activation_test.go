@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestSetSysprepActivation(t *testing.T) {
+	newSpec := func(autoLogon bool) *types.CustomizationSpec {
+		return &types.CustomizationSpec{
+			Identity: &types.CustomizationSysprep{
+				GuiUnattended: types.CustomizationGuiUnattended{AutoLogon: autoLogon, AutoLogonCount: 1},
+				GuiRunOnce:    &types.CustomizationGuiRunOnce{CommandList: []string{"echo first"}},
+			},
+		}
+	}
+
+	t.Run("sets the product key and appends the activation commands", func(t *testing.T) {
+		spec := newSpec(true)
+		activation := &v1beta1.WindowsActivationSpec{KMSServer: "kms.example.com:1688"}
+		if err := setSysprepActivation(spec, activation, "AAAAA-BBBBB-CCCCC-DDDDD-EEEEE"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		sysprep := spec.Identity.(*types.CustomizationSysprep)
+		if sysprep.UserData.ProductId != "AAAAA-BBBBB-CCCCC-DDDDD-EEEEE" {
+			t.Errorf("Expected the product key to be set, got %q", sysprep.UserData.ProductId)
+		}
+		commands := sysprep.GuiRunOnce.CommandList
+		if len(commands) != 7 || commands[0] != "echo first" {
+			t.Fatalf("Expected 6 commands appended to the existing one, got %v", commands)
+		}
+		if !strings.HasSuffix(commands[1], "slmgr.vbs /skms kms.example.com:1688") || !strings.HasSuffix(commands[2], "slmgr.vbs /ato") {
+			t.Errorf("Expected the KMS server to be set before the activation, got %v", commands[1:3])
+		}
+		if commands[3] != writeReportScriptCommand() || !strings.HasSuffix(commands[4], "-File "+windowsActivationReportPath) {
+			t.Errorf("Expected the report script to be written then run, got %v", commands[3:5])
+		}
+		if !strings.Contains(commands[5], "/SC HOURLY") || !strings.Contains(commands[6], "/SC ONSTART") {
+			t.Errorf("Expected the report to be scheduled every hour and at boot, got %v", commands[5:])
+		}
+	})
+
+	t.Run("keeps the product key of the spec", func(t *testing.T) {
+		spec := newSpec(true)
+		spec.Identity.(*types.CustomizationSysprep).UserData.ProductId = "spec-key"
+		if err := setSysprepActivation(spec, &v1beta1.WindowsActivationSpec{}, ""); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		sysprep := spec.Identity.(*types.CustomizationSysprep)
+		if sysprep.UserData.ProductId != "spec-key" {
+			t.Errorf("Expected the product key of the spec, got %q", sysprep.UserData.ProductId)
+		}
+		if len(sysprep.GuiRunOnce.CommandList) != 6 {
+			t.Errorf("Expected the activation and report commands without a KMS server, got %v", sysprep.GuiRunOnce.CommandList)
+		}
+	})
+
+	t.Run("requires the autologon", func(t *testing.T) {
+		if err := setSysprepActivation(newSpec(false), &v1beta1.WindowsActivationSpec{}, ""); err == nil {
+			t.Error("Expected an error without the autologon")
+		}
+	})
+}
+
+func TestWriteReportScriptCommand(t *testing.T) {
+	command := writeReportScriptCommand()
+	start, end := strings.Index(command, "FromBase64String('"), strings.LastIndex(command, "')")
+	if start < 0 || end < start {
+		t.Fatalf("Expected the script in base64, got %q", command)
+	}
+	b, err := base64.StdEncoding.DecodeString(command[start+len("FromBase64String('") : end])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(b) != windowsActivationReportScript {
+		t.Errorf("Expected the report script, got %q", b)
+	}
+	if strings.Contains(command, "\n") {
+		t.Errorf("Expected a single line command, got %q", command)
+	}
+}
+
+func TestGetProductKey(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: "windows-product-key"},
+		Data:       map[string][]byte{"productKey": []byte("AAAAA-BBBBB-CCCCC-DDDDD-EEEEE")},
+	}
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext(secret)))
+
+	if key, err := getProductKey(vmContext, &v1beta1.WindowsActivationSpec{}); err != nil || key != "" {
+		t.Errorf("Expected no product key, got %q, %v", key, err)
+	}
+	key, err := getProductKey(vmContext, &v1beta1.WindowsActivationSpec{ProductKeySecretName: "windows-product-key"})
+	if err != nil || key != "AAAAA-BBBBB-CCCCC-DDDDD-EEEEE" {
+		t.Errorf("Expected the product key of the secret, got %q, %v", key, err)
+	}
+	if _, err := getProductKey(vmContext, &v1beta1.WindowsActivationSpec{ProductKeySecretName: "missing"}); err == nil {
+		t.Error("Expected an error for a missing secret")
+	}
+}
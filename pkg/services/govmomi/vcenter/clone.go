@@ -19,6 +19,9 @@ package vcenter
 import (
 	"fmt"
 	"math/rand"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -27,18 +30,33 @@ import (
 	pbmTypes "github.com/vmware/govmomi/pbm/types"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/ipam"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/template"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
 const (
 	fullCloneDiskMoveType = types.VirtualMachineRelocateDiskMoveOptionsMoveAllDiskBackingsAndConsolidate
 	linkCloneDiskMoveType = types.VirtualMachineRelocateDiskMoveOptionsCreateNewChildDiskBacking
+
+	// linkedCloneSnapshotName is the name of the snapshot taken of a source
+	// VM without snapshots when a linked clone is requested.
+	linkedCloneSnapshotName = "capv-linked-clone"
 )
 
+// linkedCloneSnapshotLocks serializes the lookups and the snapshots of the
+// same source VM, so that the VSphereVMs cloned from it at the same time do
+// not each take a snapshot of a source VM without any. It is keyed by the
+// vCenter and the managed object of the source VM.
+var linkedCloneSnapshotLocks sync.Map
+
 // Clone kicks off a clone operation on vCenter to create a new virtual machine.
 // nolint:gocognit,gocyclo
 func Clone(ctx *context.VMContext, bootstrapData []byte) error {
@@ -48,13 +66,26 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 		Session:           ctx.Session,
 		Logger:            ctx.Logger.WithName("vcenter"),
 		PatchHelper:       ctx.PatchHelper,
+		Hostname:          ctx.Hostname,
+		IPAMState:         ctx.IPAMState,
+
+		VSphereFailureDomain: ctx.VSphereFailureDomain,
+		OvercommitPolicy:     ctx.OvercommitPolicy,
 	}
 	ctx.Logger.Info("starting clone process")
 
 	var extraConfig extra.Config
 	if len(bootstrapData) > 0 {
 		ctx.Logger.Info("applied bootstrap data to VM clone spec")
-		if err := extraConfig.SetCloudInitUserData(bootstrapData); err != nil {
+		if err := extraConfig.SetBootstrapData(ctx.VSphereVM.Spec.BootstrapFormat, bootstrapData); err != nil {
+			return err
+		}
+	}
+	if err := extraConfig.SetInstanceUUID(util.GetVMInstanceUUID(ctx.VSphereVM)); err != nil {
+		return err
+	}
+	if ctx.ManagementClusterID != "" {
+		if err := extraConfig.SetManagementClusterID(ctx.ManagementClusterID); err != nil {
 			return err
 		}
 	}
@@ -65,34 +96,50 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 		}
 	}
 
-	tpl, err := template.FindTemplate(ctx, ctx.VSphereVM.Spec.Template)
+	// The template of another datacenter is found with a session scoped to
+	// its datacenter, and cloned into the placement of the VM.
+	tplCtx := ctx
+	if datacenter := ctx.VSphereVM.Spec.TemplateDatacenter; datacenter != "" {
+		tplSession, err := ctx.Session.WithDatacenter(ctx, datacenter)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get the datacenter of the template of %q", ctx)
+		}
+		tplCtx = &context.VMContext{
+			ControllerContext: ctx.ControllerContext,
+			VSphereVM:         ctx.VSphereVM,
+			Session:           tplSession,
+			Logger:            ctx.Logger,
+		}
+	}
+	tpl, err := template.FindTemplate(tplCtx, ctx.VSphereVM.Spec.Template, template.Placement{
+		Folder:       ctx.VSphereVM.Spec.Folder,
+		ResourcePool: ctx.VSphereVM.Spec.ResourcePool,
+		Datastore:    ctx.VSphereVM.Spec.Datastore,
+	})
 	if err != nil {
 		return err
 	}
 
+	var tplEncrypted bool
+	if ctx.VSphereVM.Spec.Encryption != nil {
+		if tplEncrypted, err = isTemplateEncrypted(ctx, tpl); err != nil {
+			return err
+		}
+	}
+
 	// If a linked clone is requested then a MoRef for a snapshot must be
 	// found with which to perform the linked clone.
 	var snapshotRef *types.ManagedObjectReference
-	//nolint:nestif
 	if ctx.VSphereVM.Spec.CloneMode == "" || ctx.VSphereVM.Spec.CloneMode == infrav1.LinkedClone {
 		ctx.Logger.Info("linked clone requested")
-		// If the name of a snapshot was not provided then find the template's
-		// current snapshot.
-		if snapshotName := ctx.VSphereVM.Spec.Snapshot; snapshotName == "" {
-			ctx.Logger.Info("searching for current snapshot")
-			var vm mo.VirtualMachine
-			if err := tpl.Properties(ctx, tpl.Reference(), []string{"snapshot"}, &vm); err != nil {
-				return errors.Wrapf(err, "error getting snapshot information for template %s", ctx.VSphereVM.Spec.Template)
-			}
-			if vm.Snapshot != nil {
-				snapshotRef = vm.Snapshot.CurrentSnapshot
-			}
+		// A linked clone shares the disks of its template, so only a full
+		// clone of an unencrypted template can be encrypted.
+		if ctx.VSphereVM.Spec.Encryption != nil && !tplEncrypted {
+			ctx.Logger.Info("the template is not encrypted, falling back to a full clone")
 		} else {
-			ctx.Logger.Info("searching for snapshot by name", "snapshotName", snapshotName)
-			var err error
-			snapshotRef, err = tpl.FindSnapshot(ctx, snapshotName)
+			snapshotRef, err = getLinkedCloneSnapshot(ctx, tpl)
 			if err != nil {
-				ctx.Logger.Info("failed to find snapshot", "snapshotName", snapshotName)
+				return err
 			}
 		}
 	}
@@ -142,6 +189,22 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	}
 	deviceSpecs = append(deviceSpecs, networkSpecs...)
 
+	if len(ctx.VSphereVM.Spec.PCIDevices) != 0 {
+		deviceSpecs = append(deviceSpecs, getPCIDeviceSpecs(ctx.VSphereVM.Spec.PCIDevices)...)
+	}
+
+	if len(ctx.VSphereVM.Spec.DataDisks) != 0 {
+		profileIDs, err := getDataDiskProfileIDs(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "error getting storage policies of data disks for %q", ctx)
+		}
+		dataDiskSpecs, err := getDataDiskSpecs(ctx.VSphereVM.Spec.DataDisks, devices, profileIDs, &extraConfig)
+		if err != nil {
+			return errors.Wrapf(err, "error getting data disk specs for %q", ctx)
+		}
+		deviceSpecs = append(deviceSpecs, dataDiskSpecs...)
+	}
+
 	numCPUs := ctx.VSphereVM.Spec.NumCPUs
 	if numCPUs < 2 {
 		numCPUs = 2
@@ -158,9 +221,9 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	spec := types.VirtualMachineCloneSpec{
 		Config: &types.VirtualMachineConfigSpec{
 			// Assign the clone's InstanceUUID the value of the Kubernetes Machine
-			// object's UID. This allows lookup of the cloned VM prior to knowing
-			// the VM's UUID.
-			InstanceUuid:      string(ctx.VSphereVM.UID),
+			// object's UID, recorded in its instance UUID annotation. This allows
+			// lookup of the cloned VM prior to knowing the VM's UUID.
+			InstanceUuid:      util.GetVMInstanceUUID(ctx.VSphereVM),
 			Flags:             newVMFlagInfo(),
 			DeviceChange:      deviceSpecs,
 			ExtraConfig:       extraConfig,
@@ -181,9 +244,25 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 		Snapshot: snapshotRef,
 	}
 
+	host, err := selectHost(ctx, pool, numCPUs, memMiB)
+	if err != nil {
+		return err
+	}
+	spec.Location.Host = host
+
+	if timeSync := ctx.VSphereVM.Spec.TimeSync; timeSync != nil && timeSync.SyncWithHost {
+		spec.Config.Tools = &types.ToolsConfigInfo{SyncTimeWithHost: pointer.Bool(true)}
+	}
+
+	// PCI passthrough devices and vGPUs require the whole memory of the VM to
+	// be reserved.
+	if len(ctx.VSphereVM.Spec.PCIDevices) != 0 {
+		spec.Config.MemoryReservationLockedToMax = pointer.Bool(true)
+	}
+
 	var datastoreRef *types.ManagedObjectReference
 	if ctx.VSphereVM.Spec.Datastore != "" {
-		datastore, err := ctx.Session.Finder.Datastore(ctx, ctx.VSphereVM.Spec.Datastore)
+		datastore, err := ctx.Session.FindDatastore(ctx, ctx.VSphereVM.Spec.Datastore)
 		if err != nil {
 			return errors.Wrapf(err, "unable to get datastore %s for %q", ctx.VSphereVM.Spec.Datastore, ctx)
 		}
@@ -246,8 +325,37 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	disks := devices.SelectByType((*types.VirtualDisk)(nil))
 	spec.Location.Disk = getDiskLocators(disks, *datastoreRef)
 
+	if ctx.VSphereVM.Spec.Encryption != nil {
+		if err := setEncryptionConfig(ctx, storageProfileID, tplEncrypted, &spec); err != nil {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.EncryptionReadyCondition, infrav1.EncryptionNotSupportedReason, clusterv1.ConditionSeverityError, err.Error())
+			return errors.Wrapf(err, "unable to encrypt %q", ctx)
+		}
+	}
+
+	if ctx.VSphereVM.Spec.SecureBoot || ctx.VSphereVM.Spec.TPM {
+		var obj mo.VirtualMachine
+		if err := tpl.Properties(ctx, tpl.Reference(), []string{"config.firmware"}, &obj); err != nil {
+			return errors.Wrapf(err, "unable to get the firmware of the template for %q", ctx)
+		}
+		if err := setSecurityConfig(ctx.VSphereVM.Spec.VirtualMachineCloneSpec, obj.Config, devices, storageProfileID, spec.Config); err != nil {
+			return errors.Wrapf(err, "unable to configure the secure boot or the virtual TPM of %q", ctx)
+		}
+	}
+
+	if ctx.VSphereVM.Spec.GuestID != "" {
+		spec.Config.GuestId = ctx.VSphereVM.Spec.GuestID
+	}
+
+	if ctx.VSphereVM.Spec.CustomizationSpec != "" {
+		customization, err := getSysprepCustomization(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get the customization spec %s for %q", ctx.VSphereVM.Spec.CustomizationSpec, ctx)
+		}
+		spec.Customization = customization
+	}
+
 	ctx.Logger.Info("cloning machine", "namespace", ctx.VSphereVM.Namespace, "name", ctx.VSphereVM.Name, "cloneType", ctx.VSphereVM.Status.CloneMode)
-	task, err := tpl.Clone(ctx, folder, ctx.VSphereVM.Name, spec)
+	task, err := tpl.Clone(ctx, folder, util.GetVMName(ctx.VSphereVM), spec)
 	if err != nil {
 		return errors.Wrapf(err, "error trigging clone op for machine %s", ctx)
 	}
@@ -263,6 +371,210 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	return nil
 }
 
+// setSecurityConfig configures the EFI secure boot and the virtual TPM of the
+// clone of the template. Both require the EFI firmware, which cannot be
+// changed once the guest OS of the template is installed. A virtual TPM is
+// only supported on an encrypted VM, so the home of the VM is placed with the
+// storage policy of the VM, which is expected to enable the VM encryption.
+func setSecurityConfig(cloneSpec infrav1.VirtualMachineCloneSpec, tplConfig *types.VirtualMachineConfigInfo, devices object.VirtualDeviceList, storageProfileID string, config *types.VirtualMachineConfigSpec) error {
+	if tplConfig == nil || tplConfig.Firmware != string(types.GuestOsDescriptorFirmwareTypeEfi) {
+		return errors.Errorf("template %s does not use the EFI firmware", cloneSpec.Template)
+	}
+
+	if cloneSpec.SecureBoot {
+		config.BootOptions = &types.VirtualMachineBootOptions{
+			EfiSecureBootEnabled: pointer.Bool(true),
+		}
+	}
+
+	if cloneSpec.TPM {
+		if storageProfileID == "" {
+			return errors.New("a virtual TPM requires a storage policy with VM encryption")
+		}
+		config.VmProfile = []types.BaseVirtualMachineProfileSpec{
+			&types.VirtualMachineDefinedProfileSpec{ProfileId: storageProfileID},
+		}
+		// The virtual TPM of a template which already has one is cloned
+		// along with its other devices.
+		if len(devices.SelectByType((*types.VirtualTPM)(nil))) == 0 {
+			config.DeviceChange = append(config.DeviceChange, &types.VirtualDeviceConfigSpec{
+				Device: &types.VirtualTPM{
+					VirtualDevice: types.VirtualDevice{
+						// Assign a temporary device key to ensure that a
+						// unique one will be generated when the device is
+						// created.
+						Key: -400,
+					},
+				},
+				Operation: types.VirtualDeviceConfigSpecOperationAdd,
+			})
+		}
+	}
+	return nil
+}
+
+// getSysprepCustomization returns the guest customization of the clone, read
+// from the sysprep customization spec of vCenter named by the VSphereVM. The
+// computer name and the network settings of the spec are replaced by the ones
+// of the VSphereVM, so that a single spec can be shared by all the VMs, and
+// the Windows activation of the VSphereVM is added to it.
+func getSysprepCustomization(ctx *context.VMContext) (*types.CustomizationSpec, error) {
+	// The computer name of a VM of a warm pool is only known once the VM is
+	// claimed by a machine, long after the customization was applied.
+	if _, ok := ctx.VSphereVM.Labels[infrav1.WarmPoolLabel]; ok {
+		return nil, errors.New("the VMs of a warm pool cannot be customized")
+	}
+	if ctx.Hostname == "" {
+		return nil, errors.New("the hostname of the VM is unknown")
+	}
+	item, err := object.NewCustomizationSpecManager(ctx.Session.Client.Client).GetCustomizationSpec(ctx, ctx.VSphereVM.Spec.CustomizationSpec)
+	if err != nil {
+		return nil, err
+	}
+	devices := ipam.ApplyState(ctx.VSphereVM.Spec.Network.Devices, ctx.IPAMState)
+	if err := setSysprepIdentity(&item.Spec, ctx.Hostname, devices); err != nil {
+		return nil, err
+	}
+	if activation := ctx.VSphereVM.Spec.WindowsActivation; activation != nil {
+		productKey, err := getProductKey(ctx, activation)
+		if err != nil {
+			return nil, err
+		}
+		if err := setSysprepActivation(&item.Spec, activation, productKey); err != nil {
+			return nil, err
+		}
+	}
+	return &item.Spec, nil
+}
+
+// setSysprepIdentity sets the computer name and the settings of the network
+// adapters of a sysprep customization spec. The settings are mapped to the
+// adapters of the VM in the order of the network devices.
+func setSysprepIdentity(spec *types.CustomizationSpec, hostname string, devices []infrav1.NetworkDeviceSpec) error {
+	sysprep, ok := spec.Identity.(*types.CustomizationSysprep)
+	if !ok {
+		return errors.Errorf("only sysprep customization specs are supported, got %T", spec.Identity)
+	}
+	// The computer name is the first label of a fully qualified hostname.
+	sysprep.UserData.ComputerName = &types.CustomizationFixedName{
+		Name: strings.SplitN(hostname, ".", 2)[0],
+	}
+
+	spec.NicSettingMap = make([]types.CustomizationAdapterMapping, 0, len(devices))
+	for i := range devices {
+		settings, err := getCustomizationIPSettings(devices[i])
+		if err != nil {
+			return errors.Wrapf(err, "invalid network device %d", i)
+		}
+		spec.NicSettingMap = append(spec.NicSettingMap, types.CustomizationAdapterMapping{Adapter: settings})
+	}
+	return nil
+}
+
+// getCustomizationIPSettings returns the customization of a network adapter.
+// The IPv4 settings of an adapter are required, so an adapter without a static
+// IPv4 address uses DHCP.
+func getCustomizationIPSettings(device infrav1.NetworkDeviceSpec) (types.CustomizationIPSettings, error) {
+	settings := types.CustomizationIPSettings{
+		Ip:            &types.CustomizationDhcpIpGenerator{},
+		DnsServerList: device.Nameservers,
+	}
+
+	var ipv4 bool
+	var ipv6 []types.BaseCustomizationIpV6Generator
+	for _, addr := range device.IPAddrs {
+		ip, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return settings, errors.Wrapf(err, "invalid address %s", addr)
+		}
+		if ip.To4() == nil {
+			ones, _ := ipNet.Mask.Size()
+			ipv6 = append(ipv6, &types.CustomizationFixedIpV6{IpAddress: ip.String(), SubnetMask: int32(ones)})
+			continue
+		}
+		if ipv4 {
+			return settings, errors.Errorf("sysprep only supports a single IPv4 address per network adapter, got %v", device.IPAddrs)
+		}
+		ipv4 = true
+		settings.Ip = &types.CustomizationFixedIp{IpAddress: ip.String()}
+		settings.SubnetMask = net.IP(ipNet.Mask).String()
+		if device.Gateway4 != "" {
+			settings.Gateway = []string{device.Gateway4}
+		}
+	}
+	if device.DHCP6 {
+		ipv6 = append(ipv6, &types.CustomizationDhcpIpV6Generator{})
+	}
+	if device.SLAAC {
+		ipv6 = append(ipv6, &types.CustomizationAutoIpV6Generator{})
+	}
+	if len(ipv6) > 0 {
+		settings.IpV6Spec = &types.CustomizationIPSettingsIpV6AddressSpec{Ip: ipv6}
+		if device.Gateway6 != "" {
+			settings.IpV6Spec.Gateway = []string{device.Gateway6}
+		}
+	}
+	return settings, nil
+}
+
+// getLinkedCloneSnapshot returns the snapshot of the template from which to
+// perform a linked clone, or nil to fall back to a full clone. When the linked
+// clone mode is requested explicitly, a snapshot is taken of a source VM that
+// has none, and an error is returned if no snapshot can be used.
+func getLinkedCloneSnapshot(ctx *context.VMContext, tpl *object.VirtualMachine) (*types.ManagedObjectReference, error) {
+	explicit := ctx.VSphereVM.Spec.CloneMode == infrav1.LinkedClone
+
+	if snapshotName := ctx.VSphereVM.Spec.Snapshot; snapshotName != "" {
+		ctx.Logger.Info("searching for snapshot by name", "snapshotName", snapshotName)
+		snapshotRef, err := tpl.FindSnapshot(ctx, snapshotName)
+		if err != nil {
+			if explicit {
+				return nil, errors.Wrapf(err, "unable to find snapshot %s of template %s for linked clone", snapshotName, ctx.VSphereVM.Spec.Template)
+			}
+			ctx.Logger.Info("failed to find snapshot", "snapshotName", snapshotName)
+			return nil, nil
+		}
+		return snapshotRef, nil
+	}
+
+	// If the name of a snapshot was not provided then find the template's
+	// current snapshot.
+	lock, _ := linkedCloneSnapshotLocks.LoadOrStore(tpl.Client().URL().Host+"/"+tpl.Reference().Value, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	ctx.Logger.Info("searching for current snapshot")
+	var vm mo.VirtualMachine
+	if err := tpl.Properties(ctx, tpl.Reference(), []string{"snapshot", "config.template"}, &vm); err != nil {
+		return nil, errors.Wrapf(err, "error getting snapshot information for template %s", ctx.VSphereVM.Spec.Template)
+	}
+	if vm.Snapshot != nil && vm.Snapshot.CurrentSnapshot != nil {
+		return vm.Snapshot.CurrentSnapshot, nil
+	}
+	if !explicit {
+		return nil, nil
+	}
+
+	// A VM marked as a template cannot be snapshotted.
+	if vm.Config != nil && vm.Config.Template {
+		return nil, errors.Errorf("template %s has no snapshot for linked clone, snapshot it before marking it as a template or use the %s mode", ctx.VSphereVM.Spec.Template, infrav1.FullClone)
+	}
+	ctx.Logger.Info("taking snapshot of source vm for linked clone", "snapshotName", linkedCloneSnapshotName)
+	task, err := tpl.CreateSnapshot(ctx, linkedCloneSnapshotName, "Base snapshot of linked clones", false, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error trigging snapshot op for template %s", ctx.VSphereVM.Spec.Template)
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error taking snapshot of template %s", ctx.VSphereVM.Spec.Template)
+	}
+	snapshotRef, ok := info.Result.(types.ManagedObjectReference)
+	if !ok {
+		return nil, errors.Errorf("unexpected result %T of snapshot op for template %s", info.Result, ctx.VSphereVM.Spec.Template)
+	}
+	return &snapshotRef, nil
+}
+
 func newVMFlagInfo() *types.VirtualMachineFlagInfo {
 	diskUUIDEnabled := true
 	return &types.VirtualMachineFlagInfo{
@@ -340,8 +652,264 @@ func getDiskConfigSpec(disk *types.VirtualDisk, diskCloneCapacityKB int64) (type
 	}, nil
 }
 
+// getDataDiskProfileIDs returns the IDs of the storage policies of the data
+// disks of the VM, keyed by the names of the disks. Data disks without a
+// storage policy use the storage policy of the VM.
+func getDataDiskProfileIDs(ctx *context.VMContext) (map[string]string, error) {
+	profileIDs := map[string]string{}
+	idsByPolicy := map[string]string{}
+	var pbmClient *pbm.Client
+	for _, disk := range ctx.VSphereVM.Spec.DataDisks {
+		policy := disk.StoragePolicyName
+		if policy == "" {
+			policy = ctx.VSphereVM.Spec.StoragePolicyName
+		}
+		if policy == "" {
+			continue
+		}
+		if _, ok := idsByPolicy[policy]; !ok {
+			if pbmClient == nil {
+				var err error
+				if pbmClient, err = pbm.NewClient(ctx, ctx.Session.Client.Client); err != nil {
+					return nil, errors.Wrap(err, "unable to create pbm client")
+				}
+			}
+			id, err := pbmClient.ProfileIDByName(ctx, policy)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to get storageProfileID from name %s", policy)
+			}
+			idsByPolicy[policy] = id
+		}
+		profileIDs[disk.Name] = idsByPolicy[policy]
+	}
+	return profileIDs, nil
+}
+
+// dataDiskController is a controller the data disks of a VM are attached to.
+type dataDiskController struct {
+	key int32
+	// bus is the name of the bus of a controller added with the VM, whose key
+	// is only assigned when the VM is created. It is empty for the controller
+	// of the primary disk of the template.
+	bus         string
+	unitNumbers []int32
+	options     map[string]string
+}
+
+// getDataDiskSpecs returns the specs creating the data disks of the VM. The
+// disks are attached to the controller of the primary disk of the template,
+// or to the controllers added for them: a controller of its own for a
+// dedicated disk, and otherwise a controller shared by the disks of the same
+// controller type. The location of each disk is recorded in the extra config
+// of the VM, with the options of the added controllers. The disks are created
+// as persistent disks of the VM, so they are deleted along with the VM.
+func getDataDiskSpecs(dataDisks []infrav1.DataDiskSpec, devices object.VirtualDeviceList, profileIDs map[string]string, extraConfig *extra.Config) ([]types.BaseVirtualDeviceConfigSpec, error) {
+	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
+
+	// The added controllers are appended to a copy of the devices, so their
+	// bus numbers and keys are not reused by the next controllers.
+	withControllers := append(object.VirtualDeviceList{}, devices...)
+	var primary *dataDiskController
+	var added []*dataDiskController
+	shared := map[infrav1.DiskControllerType]*dataDiskController{}
+
+	// Assign temporary device keys to ensure that unique ones will be
+	// generated when the devices are created.
+	key := int32(-300)
+	for _, dataDisk := range dataDisks {
+		var controller *dataDiskController
+		switch spec := dataDisk.Controller; {
+		case spec == nil:
+			if primary == nil {
+				var err error
+				if primary, err = primaryDataDiskController(devices); err != nil {
+					return nil, err
+				}
+			}
+			controller = primary
+		case !spec.Dedicated && shared[spec.Type] != nil:
+			controller = shared[spec.Type]
+		default:
+			device, err := newDataDiskController(withControllers, spec.Type)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to add a controller for data disk %q", dataDisk.Name)
+			}
+			withControllers = append(withControllers, device)
+			deviceSpecs = append(deviceSpecs, &types.VirtualDeviceConfigSpec{
+				Device:    device,
+				Operation: types.VirtualDeviceConfigSpecOperationAdd,
+			})
+			controller = &dataDiskController{
+				key:         device.GetVirtualDevice().Key,
+				bus:         extra.ControllerBus(device),
+				unitNumbers: freeUnitNumbers(nil, device),
+				options:     map[string]string{},
+			}
+			added = append(added, controller)
+			if !spec.Dedicated {
+				shared[spec.Type] = controller
+			}
+		}
+		if dataDisk.Controller != nil {
+			for k, v := range dataDisk.Controller.ExtraConfig {
+				controller.options[k] = v
+			}
+		}
+		if len(controller.unitNumbers) == 0 {
+			return nil, errors.Errorf("controller %d has no free unit number for data disk %q", controller.key, dataDisk.Name)
+		}
+		unitNumber := controller.unitNumbers[0]
+		controller.unitNumbers = controller.unitNumbers[1:]
+
+		backing := &types.VirtualDiskFlatVer2BackingInfo{
+			DiskMode:        string(types.VirtualDiskModePersistent),
+			ThinProvisioned: pointer.Bool(false),
+		}
+		switch dataDisk.ProvisioningMode {
+		case infrav1.ThickProvisioningMode:
+		case infrav1.EagerlyZeroedProvisioningMode:
+			backing.EagerlyScrub = pointer.Bool(true)
+		default:
+			backing.ThinProvisioned = pointer.Bool(true)
+		}
+		spec := &types.VirtualDeviceConfigSpec{
+			Device: &types.VirtualDisk{
+				VirtualDevice: types.VirtualDevice{
+					Key:           key,
+					ControllerKey: controller.key,
+					UnitNumber:    pointer.Int32(unitNumber),
+					Backing:       backing,
+				},
+				CapacityInKB: int64(dataDisk.SizeGiB) * 1024 * 1024,
+			},
+			Operation:     types.VirtualDeviceConfigSpecOperationAdd,
+			FileOperation: types.VirtualDeviceConfigSpecFileOperationCreate,
+		}
+		if id, ok := profileIDs[dataDisk.Name]; ok {
+			spec.Profile = []types.BaseVirtualMachineProfileSpec{
+				&types.VirtualMachineDefinedProfileSpec{ProfileId: id},
+			}
+		}
+		if controller.bus != "" {
+			if err := extraConfig.SetDataDiskOnBus(dataDisk.Name, controller.bus, unitNumber); err != nil {
+				return nil, err
+			}
+		} else if err := extraConfig.SetDataDisk(dataDisk.Name, controller.key, unitNumber); err != nil {
+			return nil, err
+		}
+		deviceSpecs = append(deviceSpecs, spec)
+		key--
+	}
+
+	for _, controller := range added {
+		if err := extraConfig.SetControllerOptions(controller.bus, controller.options); err != nil {
+			return nil, err
+		}
+	}
+	return deviceSpecs, nil
+}
+
+// primaryDataDiskController returns the controller of the primary disk of the
+// template.
+func primaryDataDiskController(devices object.VirtualDeviceList) (*dataDiskController, error) {
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return nil, errors.Errorf("Invalid disk count: %d", len(disks))
+	}
+	controllerKey := disks[0].GetVirtualDevice().ControllerKey
+	controller := devices.FindByKey(controllerKey)
+	if controller == nil {
+		return nil, errors.Errorf("unable to find controller %d of the primary disk", controllerKey)
+	}
+	return &dataDiskController{
+		key:         controllerKey,
+		unitNumbers: freeUnitNumbers(devices, controller),
+	}, nil
+}
+
+// newDataDiskController returns a controller of the given type on the first
+// bus of its type which is not used by the devices.
+func newDataDiskController(devices object.VirtualDeviceList, controllerType infrav1.DiskControllerType) (types.BaseVirtualDevice, error) {
+	var controller types.BaseVirtualDevice
+	var err error
+	var busNumber int32
+	switch controllerType {
+	case infrav1.ParaVirtualDiskControllerType:
+		controller, err = devices.CreateSCSIController("pvscsi")
+		if err == nil {
+			busNumber = controller.(types.BaseVirtualSCSIController).GetVirtualSCSIController().BusNumber //nolint:forcetypeassert
+		}
+	case infrav1.NVMeDiskControllerType:
+		controller, err = devices.CreateNVMEController()
+		if err == nil {
+			busNumber = controller.(*types.VirtualNVMEController).BusNumber //nolint:forcetypeassert
+		}
+	default:
+		return nil, errors.Errorf("unknown controller type %q", controllerType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if busNumber < 0 {
+		return nil, errors.Errorf("no free bus for a %s controller", controllerType)
+	}
+	return controller, nil
+}
+
+// freeUnitNumbers returns the unit numbers of a controller that are not used
+// by its devices.
+func freeUnitNumbers(devices object.VirtualDeviceList, controller types.BaseVirtualDevice) []int32 {
+	maxUnits := int32(30)
+	used := map[int32]bool{}
+	switch c := controller.(type) {
+	case types.BaseVirtualSCSIController:
+		maxUnits = 16
+		used[c.GetVirtualSCSIController().ScsiCtlrUnitNumber] = true
+	case *types.VirtualNVMEController:
+		maxUnits = 15
+	case *types.VirtualIDEController:
+		maxUnits = 2
+	}
+	for _, device := range devices {
+		d := device.GetVirtualDevice()
+		if d.ControllerKey == controller.GetVirtualDevice().Key && d.UnitNumber != nil {
+			used[*d.UnitNumber] = true
+		}
+	}
+	var unitNumbers []int32
+	for unit := int32(0); unit < maxUnits; unit++ {
+		if !used[unit] {
+			unitNumbers = append(unitNumbers, unit)
+		}
+	}
+	return unitNumbers
+}
+
 const ethCardType = "vmxnet3"
 
+// createEthernetCard creates the virtual ethernet card of the adapter type of
+// a network device.
+func createEthernetCard(netSpec *infrav1.NetworkDeviceSpec, backing types.BaseVirtualDeviceBackingInfo) (types.BaseVirtualDevice, error) {
+	switch netSpec.AdapterType {
+	case infrav1.NetworkAdapterTypeSRIOV:
+		dev := &types.VirtualSriovEthernetCard{
+			SriovBacking: &types.VirtualSriovEthernetCardSriovBackingInfo{
+				PhysicalFunctionBacking: &types.VirtualPCIPassthroughDeviceBackingInfo{
+					Id: netSpec.PhysicalFunction,
+				},
+			},
+		}
+		dev.Backing = backing
+		return dev, nil
+	case infrav1.NetworkAdapterTypePVRDMA:
+		dev := &types.VirtualVmxnet3Vrdma{}
+		dev.Backing = backing
+		return dev, nil
+	default:
+		return object.EthernetCardTypes().CreateEthernetCard(ethCardType, backing)
+	}
+}
+
 func getNetworkSpecs(ctx *context.VMContext, devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
 	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
 
@@ -354,10 +922,21 @@ func getNetworkSpecs(ctx *context.VMContext, devices object.VirtualDeviceList) (
 	}
 
 	// Add new NICs based on the machine config.
+	addSpecs, err := NetworkDeviceAddSpecs(ctx, ctx.VSphereVM.Spec.Network.Devices)
+	if err != nil {
+		return nil, err
+	}
+	return append(deviceSpecs, addSpecs...), nil
+}
+
+// NetworkDeviceAddSpecs returns the specs adding a NIC for each of the network
+// devices, e.g. to hot-add the devices added to the network of a VSphereVM.
+func NetworkDeviceAddSpecs(ctx *context.VMContext, netSpecs []infrav1.NetworkDeviceSpec) ([]types.BaseVirtualDeviceConfigSpec, error) {
+	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
 	key := int32(-100)
-	for i := range ctx.VSphereVM.Spec.Network.Devices {
-		netSpec := &ctx.VSphereVM.Spec.Network.Devices[i]
-		ref, err := ctx.Session.Finder.Network(ctx, netSpec.NetworkName)
+	for i := range netSpecs {
+		netSpec := &netSpecs[i]
+		ref, err := ctx.Session.FindNetwork(ctx, netSpec.NetworkName)
 		if err != nil {
 			return nil, errors.Wrapf(err, "unable to find network %q", netSpec.NetworkName)
 		}
@@ -365,9 +944,9 @@ func getNetworkSpecs(ctx *context.VMContext, devices object.VirtualDeviceList) (
 		if err != nil {
 			return nil, errors.Wrapf(err, "unable to create new ethernet card backing info for network %q on %q", netSpec.NetworkName, ctx)
 		}
-		dev, err := object.EthernetCardTypes().CreateEthernetCard(ethCardType, backing)
+		dev, err := createEthernetCard(netSpec, backing)
 		if err != nil {
-			return nil, errors.Wrapf(err, "unable to create new ethernet card %q for network %q on %q", ethCardType, netSpec.NetworkName, ctx)
+			return nil, errors.Wrapf(err, "unable to create new ethernet card %q for network %q on %q", adapterType(netSpec), netSpec.NetworkName, ctx)
 		}
 
 		// Get the actual NIC object. This is safe to assert without a check
@@ -391,9 +970,55 @@ func getNetworkSpecs(ctx *context.VMContext, devices object.VirtualDeviceList) (
 			Device:    dev,
 			Operation: types.VirtualDeviceConfigSpecOperationAdd,
 		})
-		ctx.Logger.V(4).Info("created network device", "eth-card-type", ethCardType, "network-spec", netSpec)
+		ctx.Logger.V(4).Info("created network device", "eth-card-type", adapterType(netSpec), "network-spec", netSpec)
 		key--
 	}
 
 	return deviceSpecs, nil
 }
+
+// adapterType returns the adapter type of a network device, defaulting to
+// vmxnet3.
+func adapterType(netSpec *infrav1.NetworkDeviceSpec) infrav1.NetworkAdapterType {
+	if netSpec.AdapterType == "" {
+		return infrav1.NetworkAdapterTypeVmxnet3
+	}
+	return netSpec.AdapterType
+}
+
+func getPCIDeviceSpecs(pciDevices []infrav1.PCIDeviceSpec) []types.BaseVirtualDeviceConfigSpec {
+	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
+
+	// Assign temporary device keys to ensure that unique ones will be
+	// generated when the devices are created.
+	key := int32(-200)
+	for _, pciDevice := range pciDevices {
+		var backing types.BaseVirtualDeviceBackingInfo
+		if pciDevice.VGPUProfile != "" {
+			backing = &types.VirtualPCIPassthroughVmiopBackingInfo{
+				Vgpu: pciDevice.VGPUProfile,
+			}
+		} else {
+			backing = &types.VirtualPCIPassthroughDynamicBackingInfo{
+				AllowedDevice: []types.VirtualPCIPassthroughAllowedDevice{
+					{
+						VendorId: *pciDevice.VendorID,
+						DeviceId: *pciDevice.DeviceID,
+					},
+				},
+			}
+		}
+		deviceSpecs = append(deviceSpecs, &types.VirtualDeviceConfigSpec{
+			Device: &types.VirtualPCIPassthrough{
+				VirtualDevice: types.VirtualDevice{
+					Key:     key,
+					Backing: backing,
+				},
+			},
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+		})
+		key--
+	}
+
+	return deviceSpecs
+}
@@ -19,17 +19,26 @@ package vcenter
 import (
 	ctx "context"
 	"crypto/tls"
+	"sync"
 	"testing"
 
+	"github.com/go-logr/logr"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/library"
 
 	// run init func to register the tagging API endpoints.
 	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/utils/pointer"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/template"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
@@ -141,6 +150,640 @@ func TestGetDiskSpec(t *testing.T) {
 	}
 }
 
+func TestGetPCIDeviceSpecs(t *testing.T) {
+	deviceSpecs := getPCIDeviceSpecs([]v1beta1.PCIDeviceSpec{
+		{DeviceID: pointer.Int32(7864), VendorID: pointer.Int32(4318)},
+		{VGPUProfile: "grid_t4-4c"},
+	})
+	if len(deviceSpecs) != 2 {
+		t.Fatalf("Expected 2 device specs, got %d", len(deviceSpecs))
+	}
+
+	passthroughs := make([]*types.VirtualPCIPassthrough, 0, len(deviceSpecs))
+	for _, deviceSpec := range deviceSpecs {
+		spec := deviceSpec.GetVirtualDeviceConfigSpec()
+		if spec.Operation != types.VirtualDeviceConfigSpecOperationAdd {
+			t.Errorf("Expected operation %q, got %q", types.VirtualDeviceConfigSpecOperationAdd, spec.Operation)
+		}
+		passthrough, ok := spec.Device.(*types.VirtualPCIPassthrough)
+		if !ok {
+			t.Fatalf("Expected device of type VirtualPCIPassthrough, got %T", spec.Device)
+		}
+		passthroughs = append(passthroughs, passthrough)
+	}
+
+	if passthroughs[0].Key == passthroughs[1].Key {
+		t.Errorf("Expected unique device keys, got %d twice", passthroughs[0].Key)
+	}
+	dynamicBacking, ok := passthroughs[0].Backing.(*types.VirtualPCIPassthroughDynamicBackingInfo)
+	if !ok {
+		t.Fatalf("Expected dynamic DirectPath I/O backing, got %T", passthroughs[0].Backing)
+	}
+	if allowed := dynamicBacking.AllowedDevice[0]; allowed.DeviceId != 7864 || allowed.VendorId != 4318 {
+		t.Errorf("Expected device 7864 from vendor 4318, got device %d from vendor %d", allowed.DeviceId, allowed.VendorId)
+	}
+	vgpuBacking, ok := passthroughs[1].Backing.(*types.VirtualPCIPassthroughVmiopBackingInfo)
+	if !ok {
+		t.Fatalf("Expected vGPU backing, got %T", passthroughs[1].Backing)
+	}
+	if vgpuBacking.Vgpu != "grid_t4-4c" {
+		t.Errorf("Expected vGPU profile %q, got %q", "grid_t4-4c", vgpuBacking.Vgpu)
+	}
+}
+
+func TestSetSecurityConfig(t *testing.T) {
+	efi := &types.VirtualMachineConfigInfo{Firmware: string(types.GuestOsDescriptorFirmwareTypeEfi)}
+	bios := &types.VirtualMachineConfigInfo{Firmware: string(types.GuestOsDescriptorFirmwareTypeBios)}
+
+	t.Run("requires the EFI firmware", func(t *testing.T) {
+		config := &types.VirtualMachineConfigSpec{}
+		if err := setSecurityConfig(v1beta1.VirtualMachineCloneSpec{SecureBoot: true}, bios, nil, "", config); err == nil {
+			t.Error("Expected an error for a BIOS template")
+		}
+	})
+
+	t.Run("enables secure boot", func(t *testing.T) {
+		config := &types.VirtualMachineConfigSpec{}
+		if err := setSecurityConfig(v1beta1.VirtualMachineCloneSpec{SecureBoot: true}, efi, nil, "", config); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.BootOptions == nil || !*config.BootOptions.EfiSecureBootEnabled {
+			t.Errorf("Expected secure boot to be enabled, got %+v", config.BootOptions)
+		}
+		if len(config.DeviceChange) != 0 || len(config.VmProfile) != 0 {
+			t.Errorf("Expected no virtual TPM, got devices %+v and profiles %+v", config.DeviceChange, config.VmProfile)
+		}
+	})
+
+	t.Run("requires a storage policy for a virtual TPM", func(t *testing.T) {
+		config := &types.VirtualMachineConfigSpec{}
+		if err := setSecurityConfig(v1beta1.VirtualMachineCloneSpec{TPM: true}, efi, nil, "", config); err == nil {
+			t.Error("Expected an error without storage policy")
+		}
+	})
+
+	t.Run("adds a virtual TPM to an encrypted VM", func(t *testing.T) {
+		config := &types.VirtualMachineConfigSpec{}
+		if err := setSecurityConfig(v1beta1.VirtualMachineCloneSpec{TPM: true}, efi, nil, "encryption-policy", config); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.BootOptions != nil {
+			t.Errorf("Expected the boot options to be unchanged, got %+v", config.BootOptions)
+		}
+		if len(config.VmProfile) != 1 || config.VmProfile[0].(*types.VirtualMachineDefinedProfileSpec).ProfileId != "encryption-policy" {
+			t.Errorf("Expected the VM home to use the encryption policy, got %+v", config.VmProfile)
+		}
+		if len(config.DeviceChange) != 1 {
+			t.Fatalf("Expected 1 device spec, got %d", len(config.DeviceChange))
+		}
+		spec := config.DeviceChange[0].GetVirtualDeviceConfigSpec()
+		if _, ok := spec.Device.(*types.VirtualTPM); !ok || spec.Operation != types.VirtualDeviceConfigSpecOperationAdd {
+			t.Errorf("Expected a virtual TPM to be added, got %q of %T", spec.Operation, spec.Device)
+		}
+	})
+
+	t.Run("keeps the virtual TPM of the template", func(t *testing.T) {
+		config := &types.VirtualMachineConfigSpec{}
+		devices := object.VirtualDeviceList{&types.VirtualTPM{}}
+		if err := setSecurityConfig(v1beta1.VirtualMachineCloneSpec{TPM: true}, efi, devices, "encryption-policy", config); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(config.DeviceChange) != 0 {
+			t.Errorf("Expected no device spec, got %d", len(config.DeviceChange))
+		}
+	})
+}
+
+func TestSetSysprepIdentity(t *testing.T) {
+	newSpec := func() *types.CustomizationSpec {
+		return &types.CustomizationSpec{
+			Identity: &types.CustomizationSysprep{
+				UserData: types.CustomizationUserData{ComputerName: &types.CustomizationVirtualMachineName{}},
+			},
+		}
+	}
+
+	t.Run("sets the computer name and the network settings", func(t *testing.T) {
+		spec := newSpec()
+		devices := []v1beta1.NetworkDeviceSpec{
+			{
+				IPAddrs:     []string{"192.168.4.21/24", "fd00::21/64"},
+				Gateway4:    "192.168.4.1",
+				Gateway6:    "fd00::1",
+				Nameservers: []string{"192.168.4.2"},
+			},
+			{DHCP4: true},
+		}
+		if err := setSysprepIdentity(spec, "win-md-0-x2k9z.example.com", devices); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		computerName := spec.Identity.(*types.CustomizationSysprep).UserData.ComputerName
+		if name, ok := computerName.(*types.CustomizationFixedName); !ok || name.Name != "win-md-0-x2k9z" {
+			t.Errorf("Expected computer name win-md-0-x2k9z, got %+v", computerName)
+		}
+		if len(spec.NicSettingMap) != 2 {
+			t.Fatalf("Expected the settings of 2 adapters, got %+v", spec.NicSettingMap)
+		}
+
+		static := spec.NicSettingMap[0].Adapter
+		if ip, ok := static.Ip.(*types.CustomizationFixedIp); !ok || ip.IpAddress != "192.168.4.21" {
+			t.Errorf("Expected the fixed address 192.168.4.21, got %+v", static.Ip)
+		}
+		if static.SubnetMask != "255.255.255.0" || len(static.Gateway) != 1 || static.Gateway[0] != "192.168.4.1" {
+			t.Errorf("Expected the subnet mask 255.255.255.0 and the gateway 192.168.4.1, got %q and %v", static.SubnetMask, static.Gateway)
+		}
+		if len(static.DnsServerList) != 1 || static.DnsServerList[0] != "192.168.4.2" {
+			t.Errorf("Expected the nameserver 192.168.4.2, got %v", static.DnsServerList)
+		}
+		if static.IpV6Spec == nil || len(static.IpV6Spec.Ip) != 1 || static.IpV6Spec.Gateway[0] != "fd00::1" {
+			t.Fatalf("Expected a fixed IPv6 address and gateway, got %+v", static.IpV6Spec)
+		}
+		if ip, ok := static.IpV6Spec.Ip[0].(*types.CustomizationFixedIpV6); !ok || ip.IpAddress != "fd00::21" || ip.SubnetMask != 64 {
+			t.Errorf("Expected the fixed address fd00::21/64, got %+v", static.IpV6Spec.Ip[0])
+		}
+
+		dhcp := spec.NicSettingMap[1].Adapter
+		if _, ok := dhcp.Ip.(*types.CustomizationDhcpIpGenerator); !ok {
+			t.Errorf("Expected DHCP, got %+v", dhcp.Ip)
+		}
+		if dhcp.IpV6Spec != nil || len(dhcp.Gateway) != 0 {
+			t.Errorf("Expected no IPv6 settings nor gateway, got %+v", dhcp)
+		}
+	})
+
+	t.Run("requires a single IPv4 address per adapter", func(t *testing.T) {
+		devices := []v1beta1.NetworkDeviceSpec{{IPAddrs: []string{"192.168.4.21/24", "192.168.4.22/24"}}}
+		if err := setSysprepIdentity(newSpec(), "win-0", devices); err == nil {
+			t.Error("Expected an error for 2 IPv4 addresses")
+		}
+	})
+
+	t.Run("requires a sysprep spec", func(t *testing.T) {
+		spec := &types.CustomizationSpec{Identity: &types.CustomizationLinuxPrep{}}
+		if err := setSysprepIdentity(spec, "linux-0", nil); err == nil {
+			t.Error("Expected an error for a Linux spec")
+		}
+	})
+}
+
+func TestGetSysprepCustomization(t *testing.T) {
+	model, session, server := initSimulator(t)
+	t.Cleanup(model.Remove)
+	t.Cleanup(server.Close)
+
+	newContext := func(customizationSpec, hostname string) *context.VMContext {
+		return &context.VMContext{
+			ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+			VSphereVM: &v1beta1.VSphereVM{
+				Spec: v1beta1.VSphereVMSpec{
+					VirtualMachineCloneSpec: v1beta1.VirtualMachineCloneSpec{
+						OS:                v1beta1.WindowsOS,
+						CustomizationSpec: customizationSpec,
+						Network: v1beta1.NetworkSpec{
+							Devices: []v1beta1.NetworkDeviceSpec{{DHCP4: true}},
+						},
+					},
+				},
+			},
+			Session:  session,
+			Hostname: hostname,
+			Logger:   logr.Discard(),
+		}
+	}
+
+	customization, err := getSysprepCustomization(newContext("vcsim-windows-static", "win-0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := customization.Identity.(*types.CustomizationSysprep).UserData.ComputerName.(*types.CustomizationFixedName); !ok || name.Name != "win-0" {
+		t.Errorf("Expected computer name win-0, got %+v", customization.Identity)
+	}
+	if len(customization.NicSettingMap) != 1 {
+		t.Errorf("Expected the settings of 1 adapter, got %+v", customization.NicSettingMap)
+	}
+
+	if _, err := getSysprepCustomization(newContext("vcsim-linux", "linux-0")); err == nil {
+		t.Error("Expected an error for a Linux spec")
+	}
+	if _, err := getSysprepCustomization(newContext("missing", "win-0")); err == nil {
+		t.Error("Expected an error for a missing spec")
+	}
+
+	warmPoolContext := newContext("vcsim-windows-static", "")
+	warmPoolContext.VSphereVM.Labels = map[string]string{v1beta1.WarmPoolLabel: "pool"}
+	if _, err := getSysprepCustomization(warmPoolContext); err == nil {
+		t.Error("Expected an error for a VM of a warm pool")
+	}
+}
+
+func TestCreateEthernetCard(t *testing.T) {
+	backing := &types.VirtualEthernetCardNetworkBackingInfo{}
+
+	dev, err := createEthernetCard(&v1beta1.NetworkDeviceSpec{}, backing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dev.(*types.VirtualVmxnet3); !ok {
+		t.Errorf("Expected a vmxnet3 card by default, got %T", dev)
+	}
+
+	dev, err = createEthernetCard(&v1beta1.NetworkDeviceSpec{AdapterType: v1beta1.NetworkAdapterTypeSRIOV, PhysicalFunction: "0000:3b:00.0"}, backing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sriov, ok := dev.(*types.VirtualSriovEthernetCard)
+	if !ok {
+		t.Fatalf("Expected an SR-IOV card, got %T", dev)
+	}
+	if sriov.SriovBacking == nil || sriov.SriovBacking.PhysicalFunctionBacking.Id != "0000:3b:00.0" {
+		t.Errorf("Expected the SR-IOV card to be backed by physical function %q, got %+v", "0000:3b:00.0", sriov.SriovBacking)
+	}
+	if sriov.Backing != backing {
+		t.Errorf("Expected the SR-IOV card to use the network backing")
+	}
+
+	dev, err = createEthernetCard(&v1beta1.NetworkDeviceSpec{AdapterType: v1beta1.NetworkAdapterTypePVRDMA}, backing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvrdma, ok := dev.(*types.VirtualVmxnet3Vrdma)
+	if !ok {
+		t.Fatalf("Expected a PVRDMA card, got %T", dev)
+	}
+	if pvrdma.Backing != backing {
+		t.Errorf("Expected the PVRDMA card to use the network backing")
+	}
+}
+
+func TestGetDataDiskSpecs(t *testing.T) {
+	controller := &types.VirtualLsiLogicController{
+		VirtualSCSIController: types.VirtualSCSIController{
+			VirtualController:  types.VirtualController{VirtualDevice: types.VirtualDevice{Key: 1000}},
+			ScsiCtlrUnitNumber: 7,
+		},
+	}
+	primaryDisk := &types.VirtualDisk{
+		VirtualDevice: types.VirtualDevice{Key: 2000, ControllerKey: 1000, UnitNumber: pointer.Int32(0)},
+	}
+	devices := object.VirtualDeviceList{controller, primaryDisk}
+
+	dataDisks := []v1beta1.DataDiskSpec{
+		{Name: "etcd", SizeGiB: 10, ProvisioningMode: v1beta1.EagerlyZeroedProvisioningMode, StoragePolicyName: "fast"},
+		{Name: "containerd", SizeGiB: 50},
+	}
+	var extraConfig extra.Config
+	specs, err := getDataDiskSpecs(dataDisks, devices, map[string]string{"etcd": "fast-id"}, &extraConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 2 {
+		t.Fatalf("Expected 2 data disk specs, got %d", len(specs))
+	}
+
+	etcd := specs[0].GetVirtualDeviceConfigSpec()
+	if etcd.FileOperation != types.VirtualDeviceConfigSpecFileOperationCreate {
+		t.Errorf("Expected the data disk to be created, got file operation %q", etcd.FileOperation)
+	}
+	etcdDisk := etcd.Device.(*types.VirtualDisk) //nolint:forcetypeassert
+	if etcdDisk.ControllerKey != 1000 || *etcdDisk.UnitNumber != 1 {
+		t.Errorf("Expected the data disk at 1000:1, got %d:%d", etcdDisk.ControllerKey, *etcdDisk.UnitNumber)
+	}
+	if etcdDisk.CapacityInKB != 10*1024*1024 {
+		t.Errorf("Expected a capacity of %dKiB, got %dKiB", 10*1024*1024, etcdDisk.CapacityInKB)
+	}
+	etcdBacking := etcdDisk.Backing.(*types.VirtualDiskFlatVer2BackingInfo) //nolint:forcetypeassert
+	if *etcdBacking.ThinProvisioned || etcdBacking.EagerlyScrub == nil || !*etcdBacking.EagerlyScrub {
+		t.Errorf("Expected an eagerly zeroed thick disk, got %+v", etcdBacking)
+	}
+	if len(etcd.Profile) != 1 || etcd.Profile[0].(*types.VirtualMachineDefinedProfileSpec).ProfileId != "fast-id" {
+		t.Errorf("Expected the data disk to use storage policy %q, got %+v", "fast-id", etcd.Profile)
+	}
+
+	containerd := specs[1].GetVirtualDeviceConfigSpec()
+	containerdDisk := containerd.Device.(*types.VirtualDisk) //nolint:forcetypeassert
+	if *containerdDisk.UnitNumber != 2 {
+		t.Errorf("Expected the data disk at unit number 2, got %d", *containerdDisk.UnitNumber)
+	}
+	if !*containerdDisk.Backing.(*types.VirtualDiskFlatVer2BackingInfo).ThinProvisioned {
+		t.Errorf("Expected a thin disk by default")
+	}
+	if len(containerd.Profile) != 0 {
+		t.Errorf("Expected no storage policy, got %+v", containerd.Profile)
+	}
+
+	recorded := extra.DataDisks(extraConfig)
+	if recorded["1000:1"] != "etcd" || recorded["1000:2"] != "containerd" {
+		t.Errorf("Expected the locations of the data disks to be recorded, got %v", recorded)
+	}
+
+	// Unit numbers 0-15 are used by the disks or reserved by the controller.
+	for i := int32(1); i < 16; i++ {
+		devices = append(devices, &types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{Key: 2000 + i, ControllerKey: 1000, UnitNumber: pointer.Int32(i)},
+		})
+	}
+	if _, err := getDataDiskSpecs(dataDisks, devices, nil, &extra.Config{}); err == nil {
+		t.Errorf("Expected an error when the controller has no free unit numbers")
+	}
+}
+
+func TestGetDataDiskSpecsWithControllers(t *testing.T) {
+	devices := object.VirtualDeviceList{
+		&types.ParaVirtualSCSIController{VirtualSCSIController: types.VirtualSCSIController{
+			VirtualController:  types.VirtualController{VirtualDevice: types.VirtualDevice{Key: 1000}},
+			ScsiCtlrUnitNumber: 7,
+		}},
+		&types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{Key: 2000, ControllerKey: 1000, UnitNumber: pointer.Int32(0)},
+		},
+	}
+
+	pvscsi := &v1beta1.DataDiskControllerSpec{Type: v1beta1.ParaVirtualDiskControllerType, Dedicated: true, ExtraConfig: map[string]string{"queueDepth": "254"}}
+	nvme := &v1beta1.DataDiskControllerSpec{Type: v1beta1.NVMeDiskControllerType}
+	dataDisks := []v1beta1.DataDiskSpec{
+		{Name: "etcd", SizeGiB: 10, Controller: pvscsi},
+		{Name: "containerd", SizeGiB: 50, Controller: nvme},
+		{Name: "logs", SizeGiB: 20, Controller: nvme},
+		{Name: "scratch", SizeGiB: 5},
+	}
+	var extraConfig extra.Config
+	specs, err := getDataDiskSpecs(dataDisks, devices, nil, &extraConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 6 {
+		t.Fatalf("Expected 2 controller and 4 data disk specs, got %d", len(specs))
+	}
+
+	// The dedicated controller is added on the first free SCSI bus.
+	pvscsiController, ok := specs[0].GetVirtualDeviceConfigSpec().Device.(*types.ParaVirtualSCSIController)
+	if !ok || pvscsiController.BusNumber != 1 || pvscsiController.Key >= 0 {
+		t.Fatalf("Expected a paravirtual controller on bus 1 with a temporary key, got %+v", specs[0].GetVirtualDeviceConfigSpec().Device)
+	}
+	etcdDisk := specs[1].GetVirtualDeviceConfigSpec().Device.(*types.VirtualDisk) //nolint:forcetypeassert
+	if etcdDisk.ControllerKey != pvscsiController.Key || *etcdDisk.UnitNumber != 0 {
+		t.Errorf("Expected the etcd disk at unit 0 of the paravirtual controller, got %d:%d", etcdDisk.ControllerKey, *etcdDisk.UnitNumber)
+	}
+
+	// The disks of the same type share a controller.
+	nvmeController, ok := specs[2].GetVirtualDeviceConfigSpec().Device.(*types.VirtualNVMEController)
+	if !ok || nvmeController.BusNumber != 0 || nvmeController.Key == pvscsiController.Key {
+		t.Fatalf("Expected an NVMe controller on bus 0, got %+v", specs[2].GetVirtualDeviceConfigSpec().Device)
+	}
+	for i, unit := range []int32{0, 1} {
+		disk := specs[3+i].GetVirtualDeviceConfigSpec().Device.(*types.VirtualDisk) //nolint:forcetypeassert
+		if disk.ControllerKey != nvmeController.Key || *disk.UnitNumber != unit {
+			t.Errorf("Expected the data disk at unit %d of the NVMe controller, got %d:%d", unit, disk.ControllerKey, *disk.UnitNumber)
+		}
+	}
+
+	// The disks without a controller use the controller of the primary disk.
+	scratchDisk := specs[5].GetVirtualDeviceConfigSpec().Device.(*types.VirtualDisk) //nolint:forcetypeassert
+	if scratchDisk.ControllerKey != 1000 || *scratchDisk.UnitNumber != 1 {
+		t.Errorf("Expected the scratch disk at 1000:1, got %d:%d", scratchDisk.ControllerKey, *scratchDisk.UnitNumber)
+	}
+
+	recorded := extra.DataDisks(extraConfig)
+	if recorded["scsi1:0"] != "etcd" || recorded["nvme0:0"] != "containerd" || recorded["nvme0:1"] != "logs" || recorded["1000:1"] != "scratch" {
+		t.Errorf("Expected the locations of the data disks to be recorded, got %v", recorded)
+	}
+	found := false
+	for _, o := range extraConfig {
+		if opt := o.GetOptionValue(); opt.Key == "scsi1.queueDepth" && opt.Value == "254" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the options of the paravirtual controller to be set, got %+v", extraConfig)
+	}
+
+	// SCSI buses 1-3 are used.
+	for bus := int32(1); bus < 4; bus++ {
+		devices = append(devices, &types.ParaVirtualSCSIController{VirtualSCSIController: types.VirtualSCSIController{
+			VirtualController: types.VirtualController{VirtualDevice: types.VirtualDevice{Key: 1000 + bus}, BusNumber: bus},
+		}})
+	}
+	if _, err := getDataDiskSpecs(dataDisks, devices, nil, &extra.Config{}); err == nil {
+		t.Errorf("Expected an error when no SCSI bus is free")
+	}
+}
+
+func TestGetLinkedCloneSnapshot(t *testing.T) {
+	model, session, server := initSimulator(t)
+	t.Cleanup(model.Remove)
+	t.Cleanup(server.Close)
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine) //nolint:forcetypeassert
+	machine := object.NewVirtualMachine(session.Client.Client, vm.Reference())
+
+	newContext := func(cloneMode v1beta1.CloneMode, snapshot string) *context.VMContext {
+		return &context.VMContext{
+			ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+			VSphereVM: &v1beta1.VSphereVM{
+				Spec: v1beta1.VSphereVMSpec{
+					VirtualMachineCloneSpec: v1beta1.VirtualMachineCloneSpec{
+						Template:  vm.Name,
+						CloneMode: cloneMode,
+						Snapshot:  snapshot,
+					},
+				},
+			},
+			Logger: logr.Discard(),
+		}
+	}
+
+	// Without an explicit clone mode, a source without snapshots falls back
+	// to a full clone.
+	snapshotRef, err := getLinkedCloneSnapshot(newContext("", ""), machine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshotRef != nil {
+		t.Fatalf("Expected no snapshot, got %v", snapshotRef)
+	}
+
+	if _, err := getLinkedCloneSnapshot(newContext(v1beta1.LinkedClone, "missing"), machine); err == nil {
+		t.Fatal("Expected an error for a missing snapshot of an explicit linked clone")
+	}
+
+	// An explicit linked clone takes a snapshot of the source.
+	snapshotRef, err = getLinkedCloneSnapshot(newContext(v1beta1.LinkedClone, ""), machine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := machine.FindSnapshot(ctx.TODO(), linkedCloneSnapshotName)
+	if err != nil {
+		t.Fatalf("Expected the source to have snapshot %q: %v", linkedCloneSnapshotName, err)
+	}
+	if snapshotRef == nil || *snapshotRef != *expected {
+		t.Fatalf("Expected snapshot %v, got %v", expected, snapshotRef)
+	}
+
+	// The snapshot is then used as the current snapshot.
+	snapshotRef, err = getLinkedCloneSnapshot(newContext("", ""), machine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshotRef == nil || *snapshotRef != *expected {
+		t.Fatalf("Expected current snapshot %v, got %v", expected, snapshotRef)
+	}
+
+	// A template without snapshots cannot be snapshotted.
+	task, err := machine.RemoveAllSnapshot(ctx.TODO(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if task, err = machine.PowerOff(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := machine.MarkAsTemplate(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getLinkedCloneSnapshot(newContext(v1beta1.LinkedClone, ""), machine); err == nil {
+		t.Fatal("Expected an error for a linked clone of a template without snapshots")
+	}
+}
+
+func TestGetLinkedCloneSnapshot_Concurrent(t *testing.T) {
+	model, session, server := initSimulator(t)
+	t.Cleanup(model.Remove)
+	t.Cleanup(server.Close)
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine) //nolint:forcetypeassert
+	machine := object.NewVirtualMachine(session.Client.Client, vm.Reference())
+
+	// The explicit linked clones of a source without snapshots, reconciled at
+	// the same time, share a single snapshot.
+	const clones = 5
+	snapshotRefs := make([]*types.ManagedObjectReference, clones)
+	errs := make([]error, clones)
+	var wg sync.WaitGroup
+	for i := 0; i < clones; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			snapshotRefs[i], errs[i] = getLinkedCloneSnapshot(&context.VMContext{
+				ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+				VSphereVM: &v1beta1.VSphereVM{
+					Spec: v1beta1.VSphereVMSpec{
+						VirtualMachineCloneSpec: v1beta1.VirtualMachineCloneSpec{Template: vm.Name, CloneMode: v1beta1.LinkedClone},
+					},
+				},
+				Logger: logr.Discard(),
+			}, machine)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < clones; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if snapshotRefs[i] == nil || *snapshotRefs[i] != *snapshotRefs[0] {
+			t.Fatalf("Expected snapshot %v, got %v", snapshotRefs[0], snapshotRefs[i])
+		}
+	}
+	var moVM mo.VirtualMachine
+	if err := machine.Properties(ctx.TODO(), machine.Reference(), []string{"snapshot"}, &moVM); err != nil {
+		t.Fatal(err)
+	}
+	if moVM.Snapshot == nil || len(moVM.Snapshot.RootSnapshotList) != 1 {
+		t.Fatalf("Expected a single snapshot of the source, got %v", moVM.Snapshot)
+	}
+}
+
+func TestFindContentLibraryTemplate(t *testing.T) {
+	model, session, server := initSimulator(t)
+	t.Cleanup(model.Remove)
+	t.Cleanup(server.Close)
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine) //nolint:forcetypeassert
+
+	datastore, err := session.Finder.DefaultDatastore(ctx.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := session.Finder.DefaultFolder(ctx.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := session.Finder.DefaultResourcePool(ctx.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	libManager := library.NewManager(session.TagManager.Client)
+	libID, err := libManager.CreateLibrary(ctx.TODO(), library.Library{
+		Name:    "templates",
+		Type:    "LOCAL",
+		Storage: []library.StorageBackings{{DatastoreID: datastore.Reference().Value, Type: "DATASTORE"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemID, err := vcenter.NewManager(session.TagManager.Client).CreateTemplate(ctx.TODO(), vcenter.Template{
+		Name:     "ubuntu-2004",
+		Library:  libID,
+		SourceVM: vm.Reference().Value,
+		Placement: &vcenter.Placement{
+			Folder:       folder.Reference().Value,
+			ResourcePool: pool.Reference().Value,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rename the VM backing the item, so only the content library provides
+	// a template named after the item.
+	item, err := libManager.GetLibraryItem(ctx.TODO(), itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backing, err := session.Finder.VirtualMachine(ctx.TODO(), item.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := backing.Rename(ctx.TODO(), "ubuntu-2004-backing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	vmCtx := &context.VMContext{
+		ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+		Session:           session,
+		Logger:            logr.Discard(),
+	}
+	tpl, err := template.FindTemplate(vmCtx, "ubuntu-2004", template.Placement{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := tpl.ObjectName(ctx.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "ubuntu-2004-" + datastore.Name(); name != expected {
+		t.Fatalf("Expected base template %q, got %q", expected, name)
+	}
+
+	// The base template is reused by the next clones on the same datastore.
+	reused, err := template.FindTemplate(vmCtx, "ubuntu-2004", template.Placement{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reused.Reference() != tpl.Reference() {
+		t.Fatalf("Expected base template %v to be reused, got %v", tpl.Reference(), reused.Reference())
+	}
+
+	if _, err := template.FindTemplate(vmCtx, "missing", template.Placement{}); err == nil {
+		t.Fatal("Expected an error for a template missing from the inventory and the content libraries")
+	}
+}
+
 func validateDiskSpec(t *testing.T, device types.BaseVirtualDeviceConfigSpec, cloneDiskSize int32) {
 	t.Helper()
 	disk := device.GetVirtualDeviceConfigSpec().Device.(*types.VirtualDisk)
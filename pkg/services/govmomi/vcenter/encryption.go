@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/pbm"
+	pbmTypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+const (
+	// encryptionNamespacePrefix is the prefix of the namespace of the
+	// capabilities of the VM encryption I/O filter, e.g.
+	// "vmwarevmcrypt@ENCRYPTION".
+	encryptionNamespacePrefix = "vmwarevmcrypt"
+
+	// dataServiceNamespace is the namespace of the capabilities referencing
+	// a data service policy, e.g. the encryption properties of a storage
+	// policy with VM encryption.
+	dataServiceNamespace = "com.vmware.storageprofile.dataservice"
+
+	// defaultEncryptionPropertiesID is the ID of the "Default encryption
+	// properties" data service policy of vCenter, referenced by its "VM
+	// Encryption Policy".
+	defaultEncryptionPropertiesID = "ad5a249d-cbc2-43af-9366-694d7664fa52"
+)
+
+// isTemplateEncrypted returns whether the template of a VM is encrypted, in
+// which case its clones are encrypted with its key.
+func isTemplateEncrypted(ctx *context.VMContext, tpl *object.VirtualMachine) (bool, error) {
+	var obj mo.VirtualMachine
+	if err := tpl.Properties(ctx, tpl.Reference(), []string{"config.keyId"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "unable to get the encryption key of the template for %q", ctx)
+	}
+	return obj.Config != nil && obj.Config.KeyId != nil, nil
+}
+
+// setEncryptionConfig encrypts the clone of the template with the storage
+// policy of the VM, applied to the home and the disks of the VM. The storage
+// policy of the VM, and the ones of its data disks, must enable the VM
+// encryption. The key of the VM is generated by the key provider of the
+// VSphereVM, unless the template is encrypted, in which case the clone is
+// encrypted with the key of the template.
+func setEncryptionConfig(ctx *context.VMContext, storageProfileID string, tplEncrypted bool, spec *types.VirtualMachineCloneSpec) error {
+	if storageProfileID == "" {
+		return errors.New("the encryption requires a storage policy with VM encryption")
+	}
+	pbmClient, err := pbm.NewClient(ctx, ctx.Session.Client.Client)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create pbm client for %q", ctx)
+	}
+	policies := map[string]string{ctx.VSphereVM.Spec.StoragePolicyName: storageProfileID}
+	for _, disk := range ctx.VSphereVM.Spec.DataDisks {
+		if _, ok := policies[disk.StoragePolicyName]; ok || disk.StoragePolicyName == "" {
+			continue
+		}
+		id, err := pbmClient.ProfileIDByName(ctx, disk.StoragePolicyName)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get storageProfileID from name %s", disk.StoragePolicyName)
+		}
+		policies[disk.StoragePolicyName] = id
+	}
+	for name, id := range policies {
+		ok, err := isEncryptionPolicy(ctx, pbmClient, id)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get the capabilities of storage policy %s", name)
+		}
+		if !ok {
+			return errors.Errorf("storage policy %s does not enable the VM encryption", name)
+		}
+	}
+
+	profile := []types.BaseVirtualMachineProfileSpec{
+		&types.VirtualMachineDefinedProfileSpec{ProfileId: storageProfileID},
+	}
+	spec.Config.VmProfile = profile
+	spec.Location.Profile = profile
+	for i := range spec.Location.Disk {
+		spec.Location.Disk[i].Profile = profile
+	}
+
+	keyProviderID := ctx.VSphereVM.Spec.Encryption.KeyProviderID
+	if tplEncrypted || keyProviderID == "" {
+		return nil
+	}
+	keyID, err := vmEncryptionKey(ctx, keyProviderID)
+	if err != nil {
+		return err
+	}
+	spec.Config.Crypto = &types.CryptoSpecEncrypt{CryptoKeyId: *keyID}
+	return nil
+}
+
+// vmEncryptionKey returns the key the VM is encrypted with. The key is
+// generated once, and recorded on the VSphereVM before the VM is cloned, so
+// that the next clone attempts reuse it rather than generate a key for each
+// attempt.
+func vmEncryptionKey(ctx *context.VMContext, keyProviderID string) (*types.CryptoKeyId, error) {
+	if id := ctx.VSphereVM.Annotations[infrav1.VMEncryptionKeyAnnotation]; id != "" {
+		return &types.CryptoKeyId{KeyId: id, ProviderId: &types.KeyProviderId{Id: keyProviderID}}, nil
+	}
+	keyID, err := generateKey(ctx, keyProviderID)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.VSphereVM.Annotations == nil {
+		ctx.VSphereVM.Annotations = map[string]string{}
+	}
+	ctx.VSphereVM.Annotations[infrav1.VMEncryptionKeyAnnotation] = keyID.KeyId
+	if err := ctx.Patch(); err != nil {
+		return nil, errors.Wrapf(err, "unable to record the encryption key of %q", ctx)
+	}
+	return keyID, nil
+}
+
+// isEncryptionPolicy returns whether a storage policy enables the VM
+// encryption, with a capability of the VM encryption I/O filter, or with a
+// data service policy which has one.
+func isEncryptionPolicy(ctx *context.VMContext, pbmClient *pbm.Client, profileID string) (bool, error) {
+	profiles, err := pbmClient.RetrieveContent(ctx, []pbmTypes.PbmProfileId{{UniqueId: profileID}})
+	if err != nil {
+		return false, err
+	}
+	encrypted, dataServices := encryptionCapabilities(profiles)
+	if encrypted || len(dataServices) == 0 {
+		return encrypted, nil
+	}
+	profiles, err = pbmClient.RetrieveContent(ctx, dataServices)
+	if err != nil {
+		return false, err
+	}
+	encrypted, _ = encryptionCapabilities(profiles)
+	return encrypted, nil
+}
+
+// encryptionCapabilities returns whether the storage policies have a
+// capability of the VM encryption I/O filter, and the data service policies
+// they reference otherwise. The default encryption properties of vCenter are
+// known to enable the VM encryption.
+func encryptionCapabilities(profiles []pbmTypes.BasePbmProfile) (bool, []pbmTypes.PbmProfileId) {
+	var dataServices []pbmTypes.PbmProfileId
+	for _, profile := range profiles {
+		capabilityProfile, ok := profile.(*pbmTypes.PbmCapabilityProfile)
+		if !ok {
+			continue
+		}
+		constraints, ok := capabilityProfile.Constraints.(*pbmTypes.PbmCapabilitySubProfileConstraints)
+		if !ok {
+			continue
+		}
+		for _, subProfile := range constraints.SubProfiles {
+			for _, capability := range subProfile.Capability {
+				switch {
+				case strings.HasPrefix(capability.Id.Namespace, encryptionNamespacePrefix):
+					return true, nil
+				case capability.Id.Namespace == dataServiceNamespace && capability.Id.Id == defaultEncryptionPropertiesID:
+					return true, nil
+				case capability.Id.Namespace == dataServiceNamespace:
+					dataServices = append(dataServices, pbmTypes.PbmProfileId{UniqueId: capability.Id.Id})
+				}
+			}
+		}
+	}
+	return false, dataServices
+}
+
+// generateKey generates the key of a VM with a key provider of vCenter.
+func generateKey(ctx *context.VMContext, keyProviderID string) (*types.CryptoKeyId, error) {
+	cryptoManager := ctx.Session.Client.ServiceContent.CryptoManager
+	if cryptoManager == nil {
+		return nil, errors.Errorf("vCenter has no key provider to generate a key with %s", keyProviderID)
+	}
+	res, err := methods.GenerateKey(ctx, ctx.Session.Client.Client, &types.GenerateKey{
+		This:        *cryptoManager,
+		KeyProvider: &types.KeyProviderId{Id: keyProviderID},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to generate a key with key provider %s", keyProviderID)
+	}
+	if !res.Returnval.Success {
+		return nil, errors.Errorf("unable to generate a key with key provider %s: %s", keyProviderID, res.Returnval.Reason)
+	}
+	return &res.Returnval.KeyId, nil
+}
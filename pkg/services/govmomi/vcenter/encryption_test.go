@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	// run init func to register the storage policy API endpoints.
+	_ "github.com/vmware/govmomi/pbm/simulator"
+	pbmTypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+const (
+	// encryptionPolicyID is the ID of the "VM Encryption Policy" of vcsim.
+	encryptionPolicyID = "4d5f673c-536f-11e6-beb8-9e71128cae77"
+	// vsanPolicyID is the ID of the "vSAN Default Storage Policy" of vcsim.
+	vsanPolicyID = "aa6d5a82-1c88-45da-85d3-3d74b91a5bad"
+)
+
+func TestEncryptionCapabilities(t *testing.T) {
+	profile := func(namespace, id string) pbmTypes.BasePbmProfile {
+		return &pbmTypes.PbmCapabilityProfile{
+			Constraints: &pbmTypes.PbmCapabilitySubProfileConstraints{
+				SubProfiles: []pbmTypes.PbmCapabilitySubProfile{{
+					Capability: []pbmTypes.PbmCapabilityInstance{{
+						Id: pbmTypes.PbmCapabilityMetadataUniqueId{Namespace: namespace, Id: id},
+					}},
+				}},
+			},
+		}
+	}
+
+	if encrypted, _ := encryptionCapabilities([]pbmTypes.BasePbmProfile{profile("vmwarevmcrypt@ENCRYPTION", "AllowCleartextFilters")}); !encrypted {
+		t.Error("Expected a capability of the VM encryption I/O filter to enable the encryption")
+	}
+	if encrypted, _ := encryptionCapabilities([]pbmTypes.BasePbmProfile{profile(dataServiceNamespace, defaultEncryptionPropertiesID)}); !encrypted {
+		t.Error("Expected the default encryption properties to enable the encryption")
+	}
+	encrypted, dataServices := encryptionCapabilities([]pbmTypes.BasePbmProfile{profile(dataServiceNamespace, "custom-encryption")})
+	if encrypted || len(dataServices) != 1 || dataServices[0].UniqueId != "custom-encryption" {
+		t.Errorf("Expected the data service policy to be looked up, got %t and %+v", encrypted, dataServices)
+	}
+	if encrypted, dataServices := encryptionCapabilities([]pbmTypes.BasePbmProfile{profile("VSAN", "hostFailuresToTolerate")}); encrypted || len(dataServices) != 0 {
+		t.Errorf("Expected a vSAN policy not to enable the encryption, got %t and %+v", encrypted, dataServices)
+	}
+}
+
+func TestSetEncryptionConfig(t *testing.T) {
+	model, session, server := initSimulator(t)
+	t.Cleanup(model.Remove)
+	t.Cleanup(server.Close)
+
+	newContext := func(storagePolicyName, keyProviderID string) *context.VMContext {
+		return &context.VMContext{
+			ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+			VSphereVM: &v1beta1.VSphereVM{
+				Spec: v1beta1.VSphereVMSpec{
+					VirtualMachineCloneSpec: v1beta1.VirtualMachineCloneSpec{
+						StoragePolicyName: storagePolicyName,
+						Encryption:        &v1beta1.EncryptionSpec{KeyProviderID: keyProviderID},
+					},
+				},
+			},
+			Session: session,
+			Logger:  logr.Discard(),
+		}
+	}
+	newSpec := func() *types.VirtualMachineCloneSpec {
+		return &types.VirtualMachineCloneSpec{
+			Config: &types.VirtualMachineConfigSpec{},
+			Location: types.VirtualMachineRelocateSpec{
+				Disk: []types.VirtualMachineRelocateSpecDiskLocator{{DiskId: 2000}},
+			},
+		}
+	}
+
+	t.Run("requires a storage policy", func(t *testing.T) {
+		if err := setEncryptionConfig(newContext("", ""), "", false, newSpec()); err == nil {
+			t.Error("Expected an error without storage policy")
+		}
+	})
+
+	t.Run("requires a storage policy with VM encryption", func(t *testing.T) {
+		if err := setEncryptionConfig(newContext("vSAN Default Storage Policy", ""), vsanPolicyID, false, newSpec()); err == nil {
+			t.Error("Expected an error for a storage policy without VM encryption")
+		}
+	})
+
+	t.Run("requires data disks with VM encryption", func(t *testing.T) {
+		vmCtx := newContext("VM Encryption Policy", "")
+		vmCtx.VSphereVM.Spec.DataDisks = []v1beta1.DataDiskSpec{{Name: "data", SizeGiB: 10, StoragePolicyName: "vSAN Default Storage Policy"}}
+		if err := setEncryptionConfig(vmCtx, encryptionPolicyID, false, newSpec()); err == nil {
+			t.Error("Expected an error for a data disk without VM encryption")
+		}
+	})
+
+	t.Run("applies the storage policy to the home and the disks of the VM", func(t *testing.T) {
+		spec := newSpec()
+		if err := setEncryptionConfig(newContext("VM Encryption Policy", ""), encryptionPolicyID, false, spec); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, profiles := range [][]types.BaseVirtualMachineProfileSpec{spec.Config.VmProfile, spec.Location.Profile, spec.Location.Disk[0].Profile} {
+			if len(profiles) != 1 || profiles[0].(*types.VirtualMachineDefinedProfileSpec).ProfileId != encryptionPolicyID { //nolint:forcetypeassert
+				t.Errorf("Expected the encryption policy, got %+v", profiles)
+			}
+		}
+		if spec.Config.Crypto != nil {
+			t.Errorf("Expected the default key provider, got %+v", spec.Config.Crypto)
+		}
+	})
+
+	t.Run("keeps the key of an encrypted template", func(t *testing.T) {
+		spec := newSpec()
+		if err := setEncryptionConfig(newContext("VM Encryption Policy", "kms"), encryptionPolicyID, true, spec); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if spec.Config.Crypto != nil {
+			t.Errorf("Expected the key of the template, got %+v", spec.Config.Crypto)
+		}
+	})
+
+	t.Run("reuses the key recorded on the VSphereVM", func(t *testing.T) {
+		vmCtx := newContext("VM Encryption Policy", "kms")
+		vmCtx.VSphereVM.Annotations = map[string]string{v1beta1.VMEncryptionKeyAnnotation: "key-1"}
+		spec := newSpec()
+		if err := setEncryptionConfig(vmCtx, encryptionPolicyID, false, spec); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		crypto, ok := spec.Config.Crypto.(*types.CryptoSpecEncrypt)
+		if !ok || crypto.CryptoKeyId.KeyId != "key-1" || crypto.CryptoKeyId.ProviderId.Id != "kms" {
+			t.Errorf("Expected the recorded key, got %+v", spec.Config.Crypto)
+		}
+	})
+
+	t.Run("generates the key with the key provider", func(t *testing.T) {
+		// vcsim has no key provider.
+		if err := setEncryptionConfig(newContext("VM Encryption Policy", "kms"), encryptionPolicyID, false, newSpec()); err == nil {
+			t.Error("Expected an error without key provider")
+		}
+	})
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/cluster"
+)
+
+// ErrOvercommitLimitReached is returned when none of the hosts of the resource
+// pool of a VM can run it within the overcommit policy of its cluster.
+var ErrOvercommitLimitReached = errors.New("no host can run the VM within the overcommit policy of its cluster")
+
+var (
+	// hostCPUOvercommitRatio is the ratio of the virtual CPUs of the powered
+	// on VMs of each host to its physical CPU cores.
+	hostCPUOvercommitRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capv_host_cpu_overcommit_ratio",
+			Help: "Ratio of the virtual CPUs of the powered on VMs of an ESXi host to its physical CPU cores, by vCenter and host, observed when a VM was last placed on its compute resource.",
+		},
+		[]string{"server", "host"},
+	)
+
+	// hostMemoryOvercommitRatio is the ratio of the memory of the powered on
+	// VMs of each host to its physical memory.
+	hostMemoryOvercommitRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capv_host_memory_overcommit_ratio",
+			Help: "Ratio of the memory of the powered on VMs of an ESXi host to its physical memory, by vCenter and host, observed when a VM was last placed on its compute resource.",
+		},
+		[]string{"server", "host"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(hostCPUOvercommitRatio, hostMemoryOvercommitRatio)
+}
+
+// overcommitReports tracks the hosts reported in the overcommit gauges, so
+// that the gauges of the hosts removed from their compute resource, and of
+// the compute resources no cluster places VMs on anymore, are reset.
+var overcommitReports = struct {
+	sync.Mutex
+	// hosts are the names of the hosts reported for each compute resource,
+	// keyed by vCenter and managed object reference.
+	hosts map[computeResourceKey][]string
+	// clusters are the compute resources each cluster placed VMs on, keyed
+	// by the namespace and the name of the cluster.
+	clusters map[string]map[computeResourceKey]struct{}
+}{
+	hosts:    map[computeResourceKey][]string{},
+	clusters: map[string]map[computeResourceKey]struct{}{},
+}
+
+// computeResourceKey identifies a compute resource of a vCenter.
+type computeResourceKey struct {
+	server string
+	ref    types.ManagedObjectReference
+}
+
+// reportOvercommitRatios sets the overcommit gauges of the hosts of a compute
+// resource on which a VM of a cluster is placed, and resets the gauges of the
+// hosts which were reported for the compute resource but no longer are, e.g.
+// the hosts removed from it or in maintenance mode.
+func reportOvercommitRatios(cluster string, key computeResourceKey, allocations []hostAllocation) {
+	overcommitReports.Lock()
+	defer overcommitReports.Unlock()
+
+	reported := make([]string, 0, len(allocations))
+	current := map[string]bool{}
+	for _, allocation := range allocations {
+		cpuRatio, memRatio := allocation.ratios(0, 0)
+		hostCPUOvercommitRatio.WithLabelValues(key.server, allocation.name).Set(cpuRatio)
+		hostMemoryOvercommitRatio.WithLabelValues(key.server, allocation.name).Set(memRatio)
+		reported = append(reported, allocation.name)
+		current[allocation.name] = true
+	}
+	for _, host := range overcommitReports.hosts[key] {
+		if !current[host] {
+			hostCPUOvercommitRatio.DeleteLabelValues(key.server, host)
+			hostMemoryOvercommitRatio.DeleteLabelValues(key.server, host)
+		}
+	}
+	overcommitReports.hosts[key] = reported
+	if overcommitReports.clusters[cluster] == nil {
+		overcommitReports.clusters[cluster] = map[computeResourceKey]struct{}{}
+	}
+	overcommitReports.clusters[cluster][key] = struct{}{}
+}
+
+// ForgetOvercommitRatios resets the overcommit gauges of the hosts of the
+// compute resources on which a deleted cluster placed VMs, unless another
+// cluster places VMs on them too.
+func ForgetOvercommitRatios(namespace, name string) {
+	overcommitReports.Lock()
+	defer overcommitReports.Unlock()
+
+	cluster := namespace + "/" + name
+	keys := overcommitReports.clusters[cluster]
+	delete(overcommitReports.clusters, cluster)
+	for key := range keys {
+		inUse := false
+		for _, others := range overcommitReports.clusters {
+			if _, ok := others[key]; ok {
+				inUse = true
+				break
+			}
+		}
+		if inUse {
+			continue
+		}
+		for _, host := range overcommitReports.hosts[key] {
+			hostCPUOvercommitRatio.DeleteLabelValues(key.server, host)
+			hostMemoryOvercommitRatio.DeleteLabelValues(key.server, host)
+		}
+		delete(overcommitReports.hosts, key)
+	}
+}
+
+// hostAllocation is the allocation of the resources of a host to its powered
+// on VMs.
+type hostAllocation struct {
+	ref       types.ManagedObjectReference
+	name      string
+	cpuCores  int64
+	memoryMiB int64
+	vmCPUs    int64
+	vmMemMiB  int64
+}
+
+// ratios returns the CPU and the memory overcommit ratios of the host once a
+// VM with the given resources is added to it.
+func (a hostAllocation) ratios(numCPUs, memMiB int64) (float64, float64) {
+	return float64(a.vmCPUs+numCPUs) / float64(a.cpuCores), float64(a.vmMemMiB+memMiB) / float64(a.memoryMiB)
+}
+
+// selectHost returns the host of the compute resource of a resource pool on
+// which a VM with the given resources is placed, within the overcommit policy
+// of its cluster. The host whose most limited resource is the least
+// overcommitted is selected. It returns nil when the cluster of the VM has no
+// overcommit policy, to let vCenter place the VM.
+func selectHost(ctx *context.VMContext, pool *object.ResourcePool, numCPUs int32, memMiB int64) (*types.ManagedObjectReference, error) {
+	policy := ctx.OvercommitPolicy
+	if policy == nil || (policy.CPURatioPercent == nil && policy.MemoryRatioPercent == nil) {
+		return nil, nil
+	}
+
+	owner, hosts, err := candidateHosts(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := getHostAllocations(ctx, hosts)
+	if err != nil {
+		return nil, err
+	}
+	cluster := ctx.VSphereVM.Namespace + "/" + ctx.VSphereVM.Labels[clusterv1.ClusterLabelName]
+	reportOvercommitRatios(cluster, computeResourceKey{server: ctx.VSphereVM.Spec.Server, ref: owner}, allocations)
+
+	var (
+		selected *hostAllocation
+		lowest   float64
+	)
+	for i := range allocations {
+		allocation := &allocations[i]
+		cpuRatio, memRatio := allocation.ratios(int64(numCPUs), memMiB)
+		var usage float64
+		if limit := policy.CPURatioPercent; limit != nil {
+			if cpuRatio > float64(*limit)/100 {
+				continue
+			}
+			usage = cpuRatio / (float64(*limit) / 100)
+		}
+		if limit := policy.MemoryRatioPercent; limit != nil {
+			if memRatio > float64(*limit)/100 {
+				continue
+			}
+			if memUsage := memRatio / (float64(*limit) / 100); memUsage > usage {
+				usage = memUsage
+			}
+		}
+		if selected == nil || usage < lowest {
+			selected, lowest = allocation, usage
+		}
+	}
+	if selected == nil {
+		return nil, errors.Wrapf(ErrOvercommitLimitReached, "none of the %d hosts of resource pool %s can run %d CPUs and %d MiB", len(allocations), pool.InventoryPath, numCPUs, memMiB)
+	}
+	ctx.Logger.Info("selected host within the overcommit policy", "host", selected.name)
+	return &selected.ref, nil
+}
+
+// candidateHosts returns the compute resource of a resource pool, and its
+// hosts on which the VM may be placed, restricted to the host groups of its
+// failure domain and its host affinity.
+func candidateHosts(ctx *context.VMContext, pool *object.ResourcePool) (types.ManagedObjectReference, []types.ManagedObjectReference, error) {
+	owner, err := pool.Owner(ctx)
+	if err != nil {
+		return types.ManagedObjectReference{}, nil, errors.Wrapf(err, "failed to get the owner of resource pool %s", pool.InventoryPath)
+	}
+	computeResource := object.NewComputeResource(ctx.Session.Client.Client, owner.Reference())
+	hostSystems, err := computeResource.Hosts(ctx)
+	if err != nil {
+		return owner.Reference(), nil, errors.Wrapf(err, "failed to get the hosts of resource pool %s", pool.InventoryPath)
+	}
+	hosts := make([]types.ManagedObjectReference, 0, len(hostSystems))
+	for _, host := range hostSystems {
+		hosts = append(hosts, host.Reference())
+	}
+	if owner.Reference().Type != "ClusterComputeResource" {
+		return owner.Reference(), hosts, nil
+	}
+
+	ccr := object.NewClusterComputeResource(ctx.Session.Client.Client, owner.Reference())
+	var hostGroups []string
+	if fd := ctx.VSphereFailureDomain; fd != nil && fd.Spec.Topology.Hosts != nil {
+		hostGroups = append(hostGroups, fd.Spec.Topology.Hosts.HostGroupName)
+	}
+	if affinity := ctx.VSphereVM.Spec.HostAffinity; affinity != nil {
+		hostGroupName := affinity.HostGroupName
+		if affinity.RuleName != "" {
+			rule, err := cluster.FindVMHostAffinity(ctx, ccr, affinity.RuleName)
+			if err != nil {
+				return owner.Reference(), nil, errors.Wrapf(err, "unable to find VM-Host affinity rule %s", affinity.RuleName)
+			}
+			if rule == nil {
+				return owner.Reference(), nil, errors.Errorf("cannot find VM-Host affinity rule %s", affinity.RuleName)
+			}
+			hostGroupName = rule.HostGroupName
+		}
+		hostGroups = append(hostGroups, hostGroupName)
+	}
+	for _, hostGroup := range hostGroups {
+		members, err := cluster.ListHostsFromGroup(ctx, ccr, hostGroup)
+		if err != nil {
+			return owner.Reference(), nil, errors.Wrapf(err, "failed to list the hosts of host group %s", hostGroup)
+		}
+		inGroup := map[types.ManagedObjectReference]bool{}
+		for _, member := range members {
+			inGroup[member.Reference()] = true
+		}
+		filtered := hosts[:0]
+		for _, host := range hosts {
+			if inGroup[host] {
+				filtered = append(filtered, host)
+			}
+		}
+		hosts = filtered
+	}
+	return owner.Reference(), hosts, nil
+}
+
+// getHostAllocations returns the allocations of the connected hosts which are
+// not in maintenance mode, sorted by name.
+func getHostAllocations(ctx *context.VMContext, refs []types.ManagedObjectReference) ([]hostAllocation, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	collector := property.DefaultCollector(ctx.Session.Client.Client)
+	var hosts []mo.HostSystem
+	if err := collector.Retrieve(ctx, refs, []string{"name", "hardware.cpuInfo.numCpuCores", "hardware.memorySize", "runtime.connectionState", "runtime.inMaintenanceMode", "vm"}, &hosts); err != nil {
+		return nil, errors.Wrap(err, "failed to get the hardware of the hosts")
+	}
+
+	var vmRefs []types.ManagedObjectReference
+	for _, host := range hosts {
+		vmRefs = append(vmRefs, host.Vm...)
+	}
+	vmsByRef := map[types.ManagedObjectReference]mo.VirtualMachine{}
+	if len(vmRefs) > 0 {
+		var vms []mo.VirtualMachine
+		if err := collector.Retrieve(ctx, vmRefs, []string{"summary.config.numCpu", "summary.config.memorySizeMB", "runtime.powerState"}, &vms); err != nil {
+			return nil, errors.Wrap(err, "failed to get the resources of the VMs of the hosts")
+		}
+		for _, vm := range vms {
+			vmsByRef[vm.Reference()] = vm
+		}
+	}
+
+	allocations := make([]hostAllocation, 0, len(hosts))
+	for _, host := range hosts {
+		if host.Hardware == nil || host.Hardware.CpuInfo.NumCpuCores <= 0 || host.Hardware.MemorySize <= 0 ||
+			host.Runtime.ConnectionState != types.HostSystemConnectionStateConnected || host.Runtime.InMaintenanceMode {
+			continue
+		}
+		allocation := hostAllocation{
+			ref:       host.Reference(),
+			name:      host.Name,
+			cpuCores:  int64(host.Hardware.CpuInfo.NumCpuCores),
+			memoryMiB: host.Hardware.MemorySize / (1024 * 1024),
+		}
+		for _, vmRef := range host.Vm {
+			vm, ok := vmsByRef[vmRef]
+			if !ok || vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+				continue
+			}
+			allocation.vmCPUs += int64(vm.Summary.Config.NumCpu)
+			allocation.vmMemMiB += int64(vm.Summary.Config.MemorySizeMB)
+		}
+		allocations = append(allocations, allocation)
+	}
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].name < allocations[j].name
+	})
+	return allocations, nil
+}
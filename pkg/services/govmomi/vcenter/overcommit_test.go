@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	ctx "context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestSelectHost(t *testing.T) {
+	model, session, server := initSimulator(t)
+	t.Cleanup(model.Remove)
+	t.Cleanup(server.Close)
+
+	finder := find.NewFinder(session.Client.Client)
+	datacenter, err := finder.Datacenter(ctx.TODO(), "DC0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	finder.SetDatacenter(datacenter)
+	pool, err := finder.ResourcePool(ctx.TODO(), "/DC0/host/DC0_C0/Resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccr, err := finder.ClusterComputeResource(ctx.TODO(), "/DC0/host/DC0_C0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := ccr.Hosts(ctx.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupHost := hosts[len(hosts)-1].Reference()
+	task, err := ccr.Reconfigure(ctx.TODO(), &types.ClusterConfigSpecEx{
+		GroupSpec: []types.ClusterGroupSpec{{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+			Info: &types.ClusterHostGroup{
+				ClusterGroupInfo: types.ClusterGroupInfo{Name: "zone-a"},
+				Host:             []types.ManagedObjectReference{groupHost},
+			},
+		}},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	vmContext := func(policy *v1beta1.OvercommitPolicy, hostAffinity *v1beta1.HostAffinitySpec) *context.VMContext {
+		return &context.VMContext{
+			ControllerContext: fake.NewControllerContext(fake.NewControllerManagerContext()),
+			Session:           session,
+			Logger:            logr.Discard(),
+			VSphereVM: &v1beta1.VSphereVM{
+				Spec: v1beta1.VSphereVMSpec{
+					VirtualMachineCloneSpec: v1beta1.VirtualMachineCloneSpec{
+						Server:       server.URL.Host,
+						HostAffinity: hostAffinity,
+					},
+				},
+			},
+			OvercommitPolicy: policy,
+		}
+	}
+
+	t.Run("lets vCenter place the VM without a policy", func(t *testing.T) {
+		host, err := selectHost(vmContext(nil, nil), pool, 1, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host != nil {
+			t.Errorf("Expected no host, got %s", host)
+		}
+	})
+
+	t.Run("selects the least overcommitted host", func(t *testing.T) {
+		vctx := vmContext(&v1beta1.OvercommitPolicy{CPURatioPercent: pointer.Int32(100)}, nil)
+		allocations, err := getHostAllocations(vctx, refs(hosts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		host, err := selectHost(vctx, pool, 1, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host == nil {
+			t.Fatal("Expected a host")
+		}
+		for _, allocation := range allocations {
+			if allocation.ref == *host {
+				continue
+			}
+			if selected := allocationOf(t, allocations, *host); allocation.vmCPUs < selected.vmCPUs {
+				t.Errorf("Expected the host with the fewest vCPUs, got %s with %d vCPUs instead of %s with %d vCPUs", selected.name, selected.vmCPUs, allocation.name, allocation.vmCPUs)
+			}
+		}
+	})
+
+	t.Run("fails when no host fits the policy", func(t *testing.T) {
+		// The hosts of the simulator have 2 CPU cores and 4 GiB of memory.
+		_, err := selectHost(vmContext(&v1beta1.OvercommitPolicy{CPURatioPercent: pointer.Int32(100)}, nil), pool, 3, 1024)
+		if !errors.Is(err, ErrOvercommitLimitReached) {
+			t.Errorf("Expected %q, got %v", ErrOvercommitLimitReached, err)
+		}
+		_, err = selectHost(vmContext(&v1beta1.OvercommitPolicy{MemoryRatioPercent: pointer.Int32(150)}, nil), pool, 1, 8192)
+		if !errors.Is(err, ErrOvercommitLimitReached) {
+			t.Errorf("Expected %q, got %v", ErrOvercommitLimitReached, err)
+		}
+	})
+
+	t.Run("selects a host of the host affinity group", func(t *testing.T) {
+		vctx := vmContext(&v1beta1.OvercommitPolicy{MemoryRatioPercent: pointer.Int32(200)}, &v1beta1.HostAffinitySpec{HostGroupName: "zone-a"})
+		host, err := selectHost(vctx, pool, 1, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host == nil || *host != groupHost {
+			t.Errorf("Expected host %s, got %v", groupHost, host)
+		}
+	})
+}
+
+func TestReportOvercommitRatios(t *testing.T) {
+	clusterA := computeResourceKey{server: "vcenter-0", ref: types.ManagedObjectReference{Type: "ClusterComputeResource", Value: "domain-c1"}}
+	clusterB := computeResourceKey{server: "vcenter-0", ref: types.ManagedObjectReference{Type: "ClusterComputeResource", Value: "domain-c2"}}
+	// The series of the hosts reported by the other tests are not counted.
+	reported := testutil.CollectAndCount(hostCPUOvercommitRatio) + testutil.CollectAndCount(hostMemoryOvercommitRatio)
+	series := func() int {
+		return testutil.CollectAndCount(hostCPUOvercommitRatio) + testutil.CollectAndCount(hostMemoryOvercommitRatio) - reported
+	}
+	t.Cleanup(func() {
+		ForgetOvercommitRatios("default", "workload-a")
+		ForgetOvercommitRatios("default", "workload-b")
+	})
+
+	reportOvercommitRatios("default/workload-a", clusterA, []hostAllocation{{name: "esx-0"}, {name: "esx-1"}})
+	reportOvercommitRatios("default/workload-b", clusterA, []hostAllocation{{name: "esx-0"}, {name: "esx-1"}})
+	reportOvercommitRatios("default/workload-b", clusterB, []hostAllocation{{name: "esx-2"}})
+	if got := series(); got != 6 {
+		t.Fatalf("Expected 6 series, got %d", got)
+	}
+
+	// A host removed from its compute resource is no longer reported.
+	reportOvercommitRatios("default/workload-a", clusterA, []hostAllocation{{name: "esx-0"}})
+	if got := series(); got != 4 {
+		t.Errorf("Expected 4 series once esx-1 is removed, got %d", got)
+	}
+
+	// The hosts of a compute resource used by another cluster are reported
+	// until no cluster uses it.
+	ForgetOvercommitRatios("default", "workload-a")
+	if got := series(); got != 4 {
+		t.Errorf("Expected 4 series while workload-b uses the hosts, got %d", got)
+	}
+	ForgetOvercommitRatios("default", "workload-b")
+	if got := series(); got != 0 {
+		t.Errorf("Expected no series once the clusters are deleted, got %d", got)
+	}
+}
+
+func refs(hosts []*object.HostSystem) []types.ManagedObjectReference {
+	references := make([]types.ManagedObjectReference, 0, len(hosts))
+	for _, host := range hosts {
+		references = append(references, host.Reference())
+	}
+	return references
+}
+
+func allocationOf(t *testing.T, allocations []hostAllocation, ref types.ManagedObjectReference) hostAllocation {
+	t.Helper()
+	for _, allocation := range allocations {
+		if allocation.ref == ref {
+			return allocation
+		}
+	}
+	t.Fatalf("Expected an allocation for host %s", ref)
+	return hostAllocation{}
+}
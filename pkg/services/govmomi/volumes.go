@@ -0,0 +1,176 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/cns"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// cnsPersistentVolumeClaimType is the entity type of the metadata of a CNS
+// volume describing its PersistentVolumeClaim.
+const cnsPersistentVolumeClaimType = "PERSISTENT_VOLUME_CLAIM"
+
+// reconcileAttachedVolumes applies the attached volumes policy of the
+// VSphereVM to the persistent volumes of Kubernetes which are still attached
+// to the VM, before the VM is powered off and destroyed. The volumes are the
+// first class disks of the VM known to Cloud Native Storage, so the disks
+// cloned from the template and the data disks are never detached. It returns
+// true once no volume is attached.
+func (vms *VMService) reconcileAttachedVolumes(ctx *virtualMachineContext) (bool, error) {
+	policy := ctx.VSphereVM.Spec.AttachedVolumesPolicy
+	if policy == "" || policy == infrav1.IgnoreAttachedVolumesPolicy {
+		return true, nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.hardware.device"}, &obj); err != nil {
+		return false, errors.Wrapf(err, "failed to get the disks of vm %s", ctx)
+	}
+	var devices object.VirtualDeviceList
+	if obj.Config != nil {
+		devices = obj.Config.Hardware.Device
+	}
+	volumeIDs := firstClassDiskIDs(devices)
+	if len(volumeIDs) == 0 {
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.VolumesDetachedCondition)
+		return true, nil
+	}
+
+	cnsClient, err := cns.NewClient(ctx, ctx.Session.Client.Client)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to create the CNS client of vm %s", ctx)
+	}
+	res, err := cnsClient.QueryVolume(ctx, cnstypes.CnsQueryFilter{VolumeIds: volumeIDs})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to query the volumes of vm %s", ctx)
+	}
+	if len(res.Volumes) == 0 {
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.VolumesDetachedCondition)
+		return true, nil
+	}
+	volumes := describeVolumes(res.Volumes)
+
+	if policy == infrav1.BlockAttachedVolumesPolicy {
+		ctx.Logger.Info("waiting for the volumes to be detached", "volumes", volumes)
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VolumesDetachedCondition, infrav1.VolumesAttachedReason, clusterv1.ConditionSeverityWarning,
+			"volumes %s are attached", strings.Join(volumes, ", "))
+		return false, nil
+	}
+
+	ctx.Logger.Info("detaching volumes", "volumes", volumes)
+	conditions.MarkFalse(ctx.VSphereVM, infrav1.VolumesDetachedCondition, infrav1.VolumeDetachInProgressReason, clusterv1.ConditionSeverityInfo,
+		"detaching volumes %s", strings.Join(volumes, ", "))
+	specs := make([]cnstypes.CnsVolumeAttachDetachSpec, 0, len(res.Volumes))
+	for _, volume := range res.Volumes {
+		specs = append(specs, cnstypes.CnsVolumeAttachDetachSpec{VolumeId: volume.VolumeId, Vm: ctx.Ref})
+	}
+	if err := detachVolumes(ctx, cnsClient, specs); err != nil {
+		ctx.Logger.Error(err, "failed to detach volumes", "volumes", volumes)
+		ctx.Recorder.Warnf(ctx.VSphereVM, "VolumeDetachFailed", "failed to detach volumes %s: %v", strings.Join(volumes, ", "), err)
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VolumesDetachedCondition, infrav1.VolumeDetachFailedReason, clusterv1.ConditionSeverityWarning,
+			"failed to detach volumes %s: %v", strings.Join(volumes, ", "), err)
+		return false, nil
+	}
+	ctx.Recorder.Eventf(ctx.VSphereVM, "VolumesDetached", "Detached volumes %s", strings.Join(volumes, ", "))
+
+	// The disks of the VM are checked again, and the VM is destroyed once no
+	// volume is attached.
+	return false, nil
+}
+
+// detachVolumes detaches the given volumes with Cloud Native Storage, and
+// returns the faults of the volumes which could not be detached. The CNS
+// client does not go through the round trippers of the session, so the
+// detachment is audited here.
+func detachVolumes(ctx *virtualMachineContext, cnsClient *cns.Client, specs []cnstypes.CnsVolumeAttachDetachSpec) error {
+	task, err := cnsClient.DetachVolume(ctx, specs)
+	if err != nil {
+		ctx.Session.RecordMutation(ctx, "CnsDetachVolume", ctx.Ref.String(), "", err)
+		return err
+	}
+	err = waitForDetach(ctx, task)
+	ctx.Session.RecordMutation(ctx, "CnsDetachVolume", ctx.Ref.String(), task.Reference().Value, err)
+	return err
+}
+
+// waitForDetach waits for the task detaching volumes, and returns the faults
+// of the volumes which could not be detached.
+func waitForDetach(ctx *virtualMachineContext, task *object.Task) error {
+	taskInfo, err := cns.GetTaskInfo(ctx, task)
+	if err != nil {
+		return err
+	}
+	results, err := cns.GetTaskResultArray(ctx, taskInfo)
+	if err != nil {
+		return err
+	}
+	var faults []string
+	for _, result := range results {
+		res := result.GetCnsVolumeOperationResult()
+		if res.Fault != nil {
+			faults = append(faults, fmt.Sprintf("%s: %s", res.VolumeId.Id, res.Fault.LocalizedMessage))
+		}
+	}
+	if len(faults) > 0 {
+		return errors.New(strings.Join(faults, ", "))
+	}
+	return nil
+}
+
+// firstClassDiskIDs returns the IDs of the first class disks of a VM, which
+// back the volumes of Cloud Native Storage.
+func firstClassDiskIDs(devices object.VirtualDeviceList) []cnstypes.CnsVolumeId {
+	var ids []cnstypes.CnsVolumeId
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		if disk := device.(*types.VirtualDisk); disk.VDiskId != nil && disk.VDiskId.Id != "" {
+			ids = append(ids, cnstypes.CnsVolumeId{Id: disk.VDiskId.Id})
+		}
+	}
+	return ids
+}
+
+// describeVolumes returns the name of each volume, with the namespace and
+// the name of its PersistentVolumeClaim when CNS knows it.
+func describeVolumes(volumes []cnstypes.CnsVolume) []string {
+	descriptions := make([]string, 0, len(volumes))
+	for _, volume := range volumes {
+		name := volume.Name
+		if name == "" {
+			name = volume.VolumeId.Id
+		}
+		for _, metadata := range volume.Metadata.EntityMetadata {
+			if entity, ok := metadata.(*cnstypes.CnsKubernetesEntityMetadata); ok && entity.EntityType == cnsPersistentVolumeClaimType {
+				name = fmt.Sprintf("%s (%s/%s)", name, entity.Namespace, entity.EntityName)
+				break
+			}
+		}
+		descriptions = append(descriptions, name)
+	}
+	return descriptions
+}
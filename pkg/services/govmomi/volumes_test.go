@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/cns"
+	_ "github.com/vmware/govmomi/cns/simulator"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/test/helpers"
+)
+
+//nolint:forcetypeassert
+func TestReconcileAttachedVolumes(t *testing.T) {
+	simr, err := helpers.VCSimBuilder().WithModel(simulator.VPX()).Build()
+	if err != nil {
+		t.Fatalf("unable to create simulator: %s", err)
+	}
+	defer simr.Destroy()
+
+	newContext := func(g *WithT, policy infrav1.AttachedVolumesPolicy) *virtualMachineContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		authSession, err := session.GetOrCreate(vmContext, session.NewParams().
+			WithServer(simr.ServerURL().Host).
+			WithUserInfo(simr.Username(), simr.Password()).
+			WithDatacenter("*"))
+		g.Expect(err).NotTo(HaveOccurred())
+		vmContext.Session = authSession
+		vmContext.VSphereVM.Spec.AttachedVolumesPolicy = policy
+
+		obj, err := authSession.Finder.VirtualMachine(vmContext, "DC0_H0_VM0")
+		g.Expect(err).NotTo(HaveOccurred())
+		return &virtualMachineContext{
+			VMContext: *vmContext,
+			Obj:       obj,
+			Ref:       obj.Reference(),
+			State:     &infrav1.VirtualMachine{},
+		}
+	}
+	// setVolume backs the first disk of the VM with the given first class
+	// disk, as the CSI driver attaches a volume, or detaches it when the ID
+	// is empty.
+	setVolume := func(ctx *virtualMachineContext, id string) {
+		vm := simulator.Map.Get(ctx.Ref).(*simulator.VirtualMachine)
+		for _, device := range vm.Config.Hardware.Device {
+			if disk, ok := device.(*types.VirtualDisk); ok {
+				disk.VDiskId = nil
+				if id != "" {
+					disk.VDiskId = &types.ID{Id: id}
+				}
+				return
+			}
+		}
+	}
+
+	t.Run("ignores the volumes by default", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, "")
+
+		ok, err := (&VMService{}).reconcileAttachedVolumes(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(conditions.Has(ctx.VSphereVM, infrav1.VolumesDetachedCondition)).To(BeFalse())
+	})
+
+	t.Run("destroys a VM without volumes", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.BlockAttachedVolumesPolicy)
+
+		ok, err := (&VMService{}).reconcileAttachedVolumes(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.VolumesDetachedCondition)).To(BeTrue())
+	})
+
+	t.Run("blocks then detaches the attached volumes", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.BlockAttachedVolumesPolicy)
+
+		cnsClient, err := cns.NewClient(ctx, ctx.Session.Client.Client)
+		g.Expect(err).NotTo(HaveOccurred())
+		task, err := cnsClient.CreateVolume(ctx, []cnstypes.CnsVolumeCreateSpec{{
+			Name:                 "pvc-0",
+			VolumeType:           "BLOCK",
+			BackingObjectDetails: &cnstypes.CnsBlockBackingDetails{BackingDiskId: "fcd-0"},
+			Metadata: cnstypes.CnsVolumeMetadata{
+				EntityMetadata: []cnstypes.BaseCnsEntityMetadata{
+					&cnstypes.CnsKubernetesEntityMetadata{
+						CnsEntityMetadata: cnstypes.CnsEntityMetadata{EntityName: "data-0"},
+						EntityType:        cnsPersistentVolumeClaimType,
+						Namespace:         "default",
+					},
+				},
+			},
+		}})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+		task, err = cnsClient.AttachVolume(ctx, []cnstypes.CnsVolumeAttachDetachSpec{{VolumeId: cnstypes.CnsVolumeId{Id: "fcd-0"}, Vm: ctx.Ref}})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+		setVolume(ctx, "fcd-0")
+		defer setVolume(ctx, "")
+
+		ok, err := (&VMService{}).reconcileAttachedVolumes(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VolumesDetachedCondition)).To(Equal(infrav1.VolumesAttachedReason))
+		g.Expect(conditions.GetMessage(ctx.VSphereVM, infrav1.VolumesDetachedCondition)).To(Equal("volumes pvc-0 (default/data-0) are attached"))
+
+		ctx.VSphereVM.Spec.AttachedVolumesPolicy = infrav1.DetachAttachedVolumesPolicy
+		ok, err = (&VMService{}).reconcileAttachedVolumes(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VolumesDetachedCondition)).To(Equal(infrav1.VolumeDetachInProgressReason))
+
+		// The simulator does not remove the disk of a detached volume, whose
+		// detach fails when it is retried.
+		ok, err = (&VMService{}).reconcileAttachedVolumes(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeFalse())
+		g.Expect(conditions.GetReason(ctx.VSphereVM, infrav1.VolumesDetachedCondition)).To(Equal(infrav1.VolumeDetachFailedReason))
+
+		setVolume(ctx, "")
+		ok, err = (&VMService{}).reconcileAttachedVolumes(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+		g.Expect(conditions.IsTrue(ctx.VSphereVM, infrav1.VolumesDetachedCondition)).To(BeTrue())
+	})
+
+	t.Run("ignores the first class disks unknown to CNS", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx := newContext(g, infrav1.BlockAttachedVolumesPolicy)
+		setVolume(ctx, "fcd-unknown")
+		defer setVolume(ctx, "")
+
+		ok, err := (&VMService{}).reconcileAttachedVolumes(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(ok).To(BeTrue())
+	})
+}
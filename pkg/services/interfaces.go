@@ -70,6 +70,10 @@ type NetworkProvider interface {
 	// This operation should be idempotent
 	ProvisionClusterNetwork(ctx *vmware.ClusterContext) error
 
+	// DeleteClusterNetwork deletes the network resource of a given cluster
+	// This operation should be idempotent
+	DeleteClusterNetwork(ctx *vmware.ClusterContext) error
+
 	// GetClusterNetworkName returns the name of a valid cluster network if one exists
 	// Returns an empty string if the operation is not supported
 	GetClusterNetworkName(ctx *vmware.ClusterContext) (string, error)
@@ -80,6 +84,10 @@ type NetworkProvider interface {
 	// ConfigureVirtualMachine configures a VM for the particular network
 	ConfigureVirtualMachine(ctx *vmware.ClusterContext, vm *vmoprv1.VirtualMachine) error
 
+	// ConfigureVirtualMachineSecurityGroups declares the membership of the network interfaces of a VM
+	// in the given security groups, replacing its previous memberships
+	ConfigureVirtualMachineSecurityGroups(ctx *vmware.ClusterContext, vm *vmoprv1.VirtualMachine, securityGroups []string) error
+
 	// Verify the status of the network after vnet creation
 	VerifyNetworkStatus(ctx *vmware.ClusterContext, obj runtime.Object) error
 }
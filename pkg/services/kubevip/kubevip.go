@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package kubevip renders the static pod of kube-vip advertising the control
+// plane endpoint of a cluster, and adds it to the bootstrap data of the
+// control plane machines.
+package kubevip
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/bootstrapdata"
+)
+
+const (
+	// DefaultImage is the image of kube-vip used when none is set.
+	DefaultImage = "ghcr.io/kube-vip/kube-vip:v0.4.1"
+
+	// DefaultInterface is the network interface on which the virtual IP
+	// address is advertised when none is set, which is the name given by the
+	// cloud-init metadata to the first network device of a VM.
+	DefaultInterface = "eth0"
+
+	// ManifestPath is the path of the static pod manifest of kube-vip.
+	ManifestPath = "/etc/kubernetes/manifests/kube-vip.yaml"
+
+	// defaultLeaseName is the name of the lease of the leader election of
+	// kube-vip.
+	defaultLeaseName = "plndr-cp-lock"
+)
+
+// Manifest returns the static pod manifest of kube-vip advertising the
+// endpoint with ARP from the leader of the control plane machines.
+func Manifest(spec infrav1.KubeVIPEndpointSpec, endpoint infrav1.APIEndpoint) ([]byte, error) {
+	return yaml.Marshal(newPod(spec, "kube-vip", "", endpoint.Host, endpoint.Port))
+}
+
+// FailoverManifest returns the static pod manifest of kube-vip advertising
+// each of the addresses of an endpoint with failover. Every address is
+// advertised by its own pod, electing its own leader, so that the alternate
+// addresses are ready to be promoted.
+func FailoverManifest(spec infrav1.KubeVIPEndpointSpec, port int32, addresses []string) ([]byte, error) {
+	pods := &corev1.PodList{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "PodList",
+		},
+	}
+	for i, address := range addresses {
+		name, leaseName := "kube-vip", ""
+		if i > 0 {
+			name, leaseName = fmt.Sprintf("kube-vip-%d", i), fmt.Sprintf("%s-%d", defaultLeaseName, i)
+		}
+		pods.Items = append(pods.Items, *newPod(spec, name, leaseName, address, port))
+	}
+	return yaml.Marshal(pods)
+}
+
+// newPod returns the static pod of kube-vip advertising an address. The pod
+// uses the default lease of kube-vip for its leader election when leaseName
+// is empty.
+func newPod(spec infrav1.KubeVIPEndpointSpec, name, leaseName, address string, port int32) *corev1.Pod {
+	image := spec.Image
+	if image == "" {
+		image = DefaultImage
+	}
+	iface := spec.Interface
+	if iface == "" {
+		iface = DefaultInterface
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "cp_enable", Value: "true"},
+		{Name: "vip_interface", Value: iface},
+		{Name: "address", Value: address},
+		{Name: "port", Value: strconv.Itoa(int(port))},
+		{Name: "vip_arp", Value: "true"},
+		{Name: "vip_leaderelection", Value: "true"},
+		{Name: "vip_leaseduration", Value: "15"},
+		{Name: "vip_renewdeadline", Value: "10"},
+		{Name: "vip_retryperiod", Value: "2"},
+	}
+	if leaseName != "" {
+		env = append(env, corev1.EnvVar{Name: "vip_leasename", Value: leaseName})
+	}
+	// kube-vip advertises a /32 by default, and the neighbours of an IPv6
+	// address with NDP instead of ARP.
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		env = append(env, corev1.EnvVar{Name: "vip_cidr", Value: "128"})
+	}
+
+	hostPathType := corev1.HostPathFileOrCreate
+	return &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kube-system",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:            "kube-vip",
+					Image:           image,
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Args:            []string{"manager"},
+					Env:             env,
+					SecurityContext: &corev1.SecurityContext{
+						Capabilities: &corev1.Capabilities{
+							Add: []corev1.Capability{"NET_ADMIN", "NET_RAW"},
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{MountPath: "/etc/kubernetes/admin.conf", Name: "kubeconfig"},
+					},
+				},
+			},
+			HostNetwork: true,
+			HostAliases: []corev1.HostAlias{
+				{IP: "127.0.0.1", Hostnames: []string{"kubernetes"}},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "kubeconfig",
+					VolumeSource: corev1.VolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{
+							Path: "/etc/kubernetes/admin.conf",
+							Type: &hostPathType,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// InjectManifest adds the static pod manifest of kube-vip to bootstrap data in
+// the cloud-config or Ignition format. The bootstrap data is returned as is
+// when it already writes a file at ManifestPath, e.g. when the manifest is set
+// in the cluster template.
+func InjectManifest(format infrav1.BootstrapFormat, data, manifest []byte) ([]byte, error) {
+	return bootstrapdata.Apply(format, data, bootstrapdata.Patch{
+		Files: []bootstrapdata.File{{Path: ManifestPath, Content: manifest}},
+	})
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubevip
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestManifest(t *testing.T) {
+	g := NewWithT(t)
+
+	manifest, err := Manifest(infrav1.KubeVIPEndpointSpec{}, infrav1.APIEndpoint{Host: "10.0.0.10", Port: 6443})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pod := &corev1.Pod{}
+	g.Expect(yaml.Unmarshal(manifest, pod)).To(Succeed())
+	g.Expect(pod.Namespace).To(Equal("kube-system"))
+	g.Expect(pod.Spec.HostNetwork).To(BeTrue())
+	g.Expect(pod.Spec.Containers).To(HaveLen(1))
+	container := pod.Spec.Containers[0]
+	g.Expect(container.Image).To(Equal(DefaultImage))
+	g.Expect(container.Env).To(ContainElements(
+		corev1.EnvVar{Name: "vip_interface", Value: DefaultInterface},
+		corev1.EnvVar{Name: "address", Value: "10.0.0.10"},
+		corev1.EnvVar{Name: "port", Value: "6443"},
+	))
+
+	manifest, err = Manifest(infrav1.KubeVIPEndpointSpec{Interface: "ens192", Image: "registry.local/kube-vip:v0.4.2"}, infrav1.APIEndpoint{Host: "10.0.0.10", Port: 443})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(yaml.Unmarshal(manifest, pod)).To(Succeed())
+	g.Expect(pod.Spec.Containers[0].Image).To(Equal("registry.local/kube-vip:v0.4.2"))
+	g.Expect(pod.Spec.Containers[0].Env).To(ContainElements(
+		corev1.EnvVar{Name: "vip_interface", Value: "ens192"},
+		corev1.EnvVar{Name: "port", Value: "443"},
+	))
+	g.Expect(pod.Spec.Containers[0].Env).NotTo(ContainElement(HaveField("Name", "vip_cidr")))
+
+	manifest, err = Manifest(infrav1.KubeVIPEndpointSpec{}, infrav1.APIEndpoint{Host: "fd00::10", Port: 6443})
+	g.Expect(err).NotTo(HaveOccurred())
+	pod = &corev1.Pod{}
+	g.Expect(yaml.Unmarshal(manifest, pod)).To(Succeed())
+	g.Expect(pod.Spec.Containers[0].Env).To(ContainElements(
+		corev1.EnvVar{Name: "address", Value: "fd00::10"},
+		corev1.EnvVar{Name: "vip_cidr", Value: "128"},
+	))
+}
+
+func TestFailoverManifest(t *testing.T) {
+	g := NewWithT(t)
+
+	manifest, err := FailoverManifest(infrav1.KubeVIPEndpointSpec{}, 6443, []string{"10.0.0.10", "10.0.0.11"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pods := &corev1.PodList{}
+	g.Expect(yaml.Unmarshal(manifest, pods)).To(Succeed())
+	g.Expect(pods.Kind).To(Equal("PodList"))
+	g.Expect(pods.Items).To(HaveLen(2))
+	g.Expect(pods.Items[0].Name).To(Equal("kube-vip"))
+	g.Expect(pods.Items[0].Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "address", Value: "10.0.0.10"}))
+	g.Expect(pods.Items[0].Spec.Containers[0].Env).NotTo(ContainElement(HaveField("Name", "vip_leasename")))
+	g.Expect(pods.Items[1].Name).To(Equal("kube-vip-1"))
+	g.Expect(pods.Items[1].Spec.Containers[0].Env).To(ContainElements(
+		corev1.EnvVar{Name: "address", Value: "10.0.0.11"},
+		corev1.EnvVar{Name: "vip_leasename", Value: "plndr-cp-lock-1"},
+	))
+}
+
+func TestInjectManifestCloudConfig(t *testing.T) {
+	g := NewWithT(t)
+	manifest := []byte("kind: Pod\n")
+
+	data := []byte("## template: jinja\n#cloud-config\nwrite_files:\n- path: /etc/kubernetes/pki/ca.crt\n  content: ca\nruncmd:\n- kubeadm init\n")
+	result, err := InjectManifest(infrav1.CloudConfigBootstrapFormat, data, manifest)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(strings.HasPrefix(string(result), "## template: jinja\n#cloud-config\n")).To(BeTrue())
+
+	config := struct {
+		WriteFiles []map[string]string `json:"write_files"`
+		RunCmd     []string            `json:"runcmd"`
+	}{}
+	g.Expect(yaml.Unmarshal(result, &config)).To(Succeed())
+	g.Expect(config.RunCmd).To(Equal([]string{"kubeadm init"}))
+	g.Expect(config.WriteFiles).To(HaveLen(2))
+	g.Expect(config.WriteFiles[1]).To(Equal(map[string]string{
+		"path":        ManifestPath,
+		"owner":       "root:root",
+		"permissions": "0644",
+		"content":     "kind: Pod\n",
+	}))
+
+	// The manifest is only added once.
+	again, err := InjectManifest(infrav1.CloudConfigBootstrapFormat, result, manifest)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again).To(Equal(result))
+
+	_, err = InjectManifest(infrav1.CloudConfigBootstrapFormat, []byte("#!/bin/sh\necho hello\n"), manifest)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestInjectManifestIgnition(t *testing.T) {
+	g := NewWithT(t)
+	manifest := []byte("kind: Pod\n")
+
+	type ignitionConfig struct {
+		Storage struct {
+			Files []struct {
+				Path       string `json:"path"`
+				Filesystem string `json:"filesystem"`
+				Mode       int    `json:"mode"`
+				Contents   struct {
+					Source string `json:"source"`
+				} `json:"contents"`
+			} `json:"files"`
+		} `json:"storage"`
+	}
+
+	result, err := InjectManifest(infrav1.IgnitionBootstrapFormat, []byte(`{"ignition":{"version":"3.1.0"}}`), manifest)
+	g.Expect(err).NotTo(HaveOccurred())
+	config := ignitionConfig{}
+	g.Expect(json.Unmarshal(result, &config)).To(Succeed())
+	g.Expect(config.Storage.Files).To(HaveLen(1))
+	file := config.Storage.Files[0]
+	g.Expect(file.Path).To(Equal(ManifestPath))
+	g.Expect(file.Filesystem).To(BeEmpty())
+	g.Expect(file.Mode).To(Equal(0o644))
+	g.Expect(file.Contents.Source).To(Equal("data:,kind:%20Pod%0A"))
+
+	again, err := InjectManifest(infrav1.IgnitionBootstrapFormat, result, manifest)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again).To(Equal(result))
+
+	result, err = InjectManifest(infrav1.IgnitionBootstrapFormat, []byte(`{"ignition":{"version":"2.3.0"},"storage":{"files":[{"path":"/etc/hostname","filesystem":"root"}]}}`), manifest)
+	g.Expect(err).NotTo(HaveOccurred())
+	config = ignitionConfig{}
+	g.Expect(json.Unmarshal(result, &config)).To(Succeed())
+	g.Expect(config.Storage.Files).To(HaveLen(2))
+	g.Expect(config.Storage.Files[1].Filesystem).To(Equal("root"))
+
+	_, err = InjectManifest(infrav1.IgnitionBootstrapFormat, []byte("#cloud-config\n"), manifest)
+	g.Expect(err).To(HaveOccurred())
+}
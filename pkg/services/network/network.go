@@ -18,11 +18,13 @@ package network
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 	netopv1 "github.com/vmware-tanzu/net-operator-api/api/v1alpha1"
 	vmopv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
 	ncpv1 "github.com/vmware-tanzu/vm-operator/external/ncp/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -41,6 +43,9 @@ const (
 	NSXTTypeNetwork = "nsx-t"
 	// This constant is also defined in VM Operator.
 	NSXTVNetSelectorKey = "ncp.vmware.com/virtual-network-name"
+	// NSXTSecurityGroupLabelPrefix prefixes the labels declaring the security groups of a VM,
+	// which NCP realizes as tags of the segment ports of the VM.
+	NSXTSecurityGroupLabelPrefix = "security-group.capv.vmware.com/"
 
 	CAPVDefaultNetworkLabel    = "capv.vmware.com/is-default-network"
 	NetOpNetworkNameAnnotation = "netoperator.vmware.com/network-name"
@@ -65,6 +70,10 @@ func (np *dummyNetworkProvider) ProvisionClusterNetwork(ctx *vmware.ClusterConte
 	return nil
 }
 
+func (np *dummyNetworkProvider) DeleteClusterNetwork(ctx *vmware.ClusterContext) error {
+	return nil
+}
+
 func (np *dummyNetworkProvider) GetClusterNetworkName(ctx *vmware.ClusterContext) (string, error) {
 	return "", nil
 }
@@ -73,6 +82,12 @@ func (np *dummyNetworkProvider) ConfigureVirtualMachine(ctx *vmware.ClusterConte
 	return nil
 }
 
+// ConfigureVirtualMachineSecurityGroups refuses the security groups, which
+// are only supported by the NSX-T network provider.
+func (np *dummyNetworkProvider) ConfigureVirtualMachineSecurityGroups(ctx *vmware.ClusterContext, vm *vmopv1.VirtualMachine, securityGroups []string) error {
+	return unsupportedSecurityGroups("dummy", securityGroups)
+}
+
 func (np *dummyNetworkProvider) GetVMServiceAnnotations(ctx *vmware.ClusterContext) (map[string]string, error) {
 	return map[string]string{}, nil
 }
@@ -113,6 +128,11 @@ func (np *netopNetworkProvider) ProvisionClusterNetwork(ctx *vmware.ClusterConte
 	return nil
 }
 
+func (np *netopNetworkProvider) DeleteClusterNetwork(ctx *vmware.ClusterContext) error {
+	// The Network is shared by the clusters of the namespace and is not owned by CAPV.
+	return nil
+}
+
 func (np *netopNetworkProvider) getDefaultClusterNetwork(ctx *vmware.ClusterContext) (*netopv1.Network, error) {
 	labels := map[string]string{CAPVDefaultNetworkLabel: "true"}
 
@@ -146,6 +166,22 @@ func (np *netopNetworkProvider) GetClusterNetworkName(ctx *vmware.ClusterContext
 	return network.Name, nil
 }
 
+// ConfigureVirtualMachineSecurityGroups refuses the security groups, as the
+// vSphere Distributed networks have none.
+func (np *netopNetworkProvider) ConfigureVirtualMachineSecurityGroups(ctx *vmware.ClusterContext, vm *vmopv1.VirtualMachine, securityGroups []string) error {
+	return unsupportedSecurityGroups("vsphere-network", securityGroups)
+}
+
+// unsupportedSecurityGroups returns an error when security groups are listed
+// for a network provider which does not support them, so that they are not
+// silently ignored.
+func unsupportedSecurityGroups(provider string, securityGroups []string) error {
+	if len(securityGroups) == 0 {
+		return nil
+	}
+	return errors.Errorf("security groups %s are not supported by the %s network provider", strings.Join(securityGroups, ", "), provider)
+}
+
 func (np *netopNetworkProvider) GetVMServiceAnnotations(ctx *vmware.ClusterContext) (map[string]string, error) {
 	networkName, err := np.GetClusterNetworkName(ctx)
 	if err != nil {
@@ -221,6 +257,8 @@ func (np *nsxtNetworkProvider) verifyNSXTVirtualNetworkStatus(ctx *vmware.Cluste
 		}
 	}
 
+	ctx.VSphereCluster.Status.NetworkName = vnet.Name
+	ctx.VSphereCluster.Status.NetworkSNATIP = vnet.Status.DefaultSNATIP
 	conditions.MarkTrue(ctx.VSphereCluster, infrav1.ClusterNetworkReadyCondition)
 	return nil
 }
@@ -292,6 +330,28 @@ func (np *nsxtNetworkProvider) ProvisionClusterNetwork(ctx *vmware.ClusterContex
 	return np.verifyNSXTVirtualNetworkStatus(ctx, vnet)
 }
 
+// DeleteClusterNetwork deletes the NSX-T vnet of the cluster, which releases
+// its segment, SNAT rule and firewall section. NCP only deletes them once
+// the VMs attached to the vnet are gone.
+func (np *nsxtNetworkProvider) DeleteClusterNetwork(ctx *vmware.ClusterContext) error {
+	cluster := ctx.VSphereCluster
+	vnet := &ncpv1.VirtualNetwork{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      GetNSXTVirtualNetworkName(cluster.Name),
+		},
+	}
+
+	ctx.Logger.V(2).Info("Deleting", "vnet", vnet.Name, "namespace", vnet.Namespace)
+	if err := np.client.Delete(ctx, vnet); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete vnet %s", types.NamespacedName{Namespace: vnet.Namespace, Name: vnet.Name})
+	}
+
+	cluster.Status.NetworkName = ""
+	cluster.Status.NetworkSNATIP = ""
+	return nil
+}
+
 // Returns the name of a valid cluster network if one exists.
 func (np *nsxtNetworkProvider) GetClusterNetworkName(ctx *vmware.ClusterContext) (string, error) {
 	vnet := &ncpv1.VirtualNetwork{}
@@ -330,3 +390,22 @@ func (np *nsxtNetworkProvider) ConfigureVirtualMachine(ctx *vmware.ClusterContex
 	})
 	return nil
 }
+
+// ConfigureVirtualMachineSecurityGroups labels a VirtualMachine with the security groups of its network interfaces.
+func (np *nsxtNetworkProvider) ConfigureVirtualMachineSecurityGroups(ctx *vmware.ClusterContext, vm *vmopv1.VirtualMachine, securityGroups []string) error {
+	for k := range vm.Labels {
+		if strings.HasPrefix(k, NSXTSecurityGroupLabelPrefix) {
+			delete(vm.Labels, k)
+		}
+	}
+	if len(securityGroups) == 0 {
+		return nil
+	}
+	if vm.Labels == nil {
+		vm.Labels = map[string]string{}
+	}
+	for _, group := range securityGroups {
+		vm.Labels[NSXTSecurityGroupLabelPrefix+group] = "true"
+	}
+	return nil
+}
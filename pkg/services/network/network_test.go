@@ -25,6 +25,7 @@ import (
 	"github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
 	ncpv1 "github.com/vmware-tanzu/vm-operator/external/ncp/api/v1alpha1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	apitypes "k8s.io/apimachinery/pkg/types"
@@ -63,6 +64,7 @@ var _ = Describe("Network provider", func() {
 		dummyCluster     = "dummy-cluster"
 		dummyVM          = "dummy-vm"
 		fakeSNATIP       = "192.168.10.2"
+		fakeVnetSNATIP   = "192.168.20.5"
 		clusterKind      = "Cluster"
 		infraClusterKind = "VSphereCluster"
 		ctx              *vmware.ClusterContext
@@ -185,6 +187,82 @@ var _ = Describe("Network provider", func() {
 		})
 	})
 
+	Context("ConfigureVirtualMachineSecurityGroups", func() {
+		var securityGroups []string
+
+		BeforeEach(func() {
+			securityGroups = []string{"web", "db"}
+		})
+
+		JustBeforeEach(func() {
+			err = np.ConfigureVirtualMachineSecurityGroups(ctx, vm, securityGroups)
+		})
+
+		Context("with dummy network provider", func() {
+			BeforeEach(func() {
+				np = DummyNetworkProvider()
+			})
+			It("should refuse the security groups", func() {
+				Expect(err).To(MatchError("security groups web, db are not supported by the dummy network provider"))
+				Expect(vm.Labels).To(BeEmpty())
+			})
+
+			Context("without security groups", func() {
+				BeforeEach(func() {
+					securityGroups = nil
+				})
+				It("should succeed", func() {
+					Expect(err).To(BeNil())
+				})
+			})
+		})
+
+		Context("with netop network provider", func() {
+			BeforeEach(func() {
+				scheme := runtime.NewScheme()
+				Expect(netopv1alpha1.AddToScheme(scheme)).To(Succeed())
+				client := fake.NewClientBuilder().WithScheme(scheme).Build()
+				np = NetOpNetworkProvider(client)
+			})
+			It("should refuse the security groups", func() {
+				Expect(err).To(MatchError("security groups web, db are not supported by the vsphere-network network provider"))
+				Expect(vm.Labels).To(BeEmpty())
+			})
+		})
+
+		Context("with nsx-t network provider", func() {
+			BeforeEach(func() {
+				scheme := runtime.NewScheme()
+				Expect(ncpv1.AddToScheme(scheme)).To(Succeed())
+				client := fake.NewClientBuilder().WithScheme(scheme).Build()
+				np = NsxtNetworkProvider(client, "false")
+				vm.Labels = map[string]string{
+					"app":                                "node",
+					NSXTSecurityGroupLabelPrefix + "ssh": "true",
+				}
+			})
+
+			It("should replace the security group labels of the VM", func() {
+				Expect(err).To(BeNil())
+				Expect(vm.Labels).To(Equal(map[string]string{
+					"app":                                "node",
+					NSXTSecurityGroupLabelPrefix + "web": "true",
+					NSXTSecurityGroupLabelPrefix + "db":  "true",
+				}))
+			})
+
+			Context("without security groups", func() {
+				BeforeEach(func() {
+					securityGroups = nil
+				})
+				It("should remove the security group labels of the VM", func() {
+					Expect(err).To(BeNil())
+					Expect(vm.Labels).To(Equal(map[string]string{"app": "node"}))
+				})
+			})
+		})
+	})
+
 	Context("ProvisionClusterNetwork", func() {
 		var (
 			scheme             *runtime.Scheme
@@ -209,6 +287,7 @@ var _ = Describe("Network provider", func() {
 							Status: "True",
 						},
 					},
+					DefaultSNATIP: fakeVnetSNATIP,
 				},
 			}
 			configmapObj = &v1.ConfigMap{
@@ -302,6 +381,12 @@ var _ = Describe("Network provider", func() {
 				Expect(createdVNET.Spec.WhitelistSourceRanges).To(BeEmpty())
 			})
 
+			It("should report the vnet in the status of the vsphereCluster", func() {
+				Expect(err).To(BeNil())
+				Expect(ctx.VSphereCluster.Status.NetworkName).To(Equal(GetNSXTVirtualNetworkName(ctx.VSphereCluster.Name)))
+				Expect(ctx.VSphereCluster.Status.NetworkSNATIP).To(Equal(fakeVnetSNATIP))
+			})
+
 			// The organization of these tests are inverted so easiest to put this here because
 			// NCP will eventually be removed.
 			It("GetVMServiceAnnotations", func() {
@@ -464,6 +549,47 @@ var _ = Describe("Network provider", func() {
 		})
 	})
 
+	Context("DeleteClusterNetwork", func() {
+		var (
+			client runtimeclient.Client
+			scheme *runtime.Scheme
+		)
+
+		BeforeEach(func() {
+			scheme = runtime.NewScheme()
+			Expect(ncpv1.AddToScheme(scheme)).To(Succeed())
+		})
+
+		Context("with dummy network provider", func() {
+			It("should succeed", func() {
+				np = DummyNetworkProvider()
+				Expect(np.DeleteClusterNetwork(ctx)).To(Succeed())
+			})
+		})
+
+		Context("with nsx-t network provider", func() {
+			It("should delete the vnet and clear the status of the vsphereCluster", func() {
+				vnetObj := createUnReadyNsxtVirtualNetwork(ctx, ncpv1.VirtualNetworkStatus{})
+				client = fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(vnetObj).Build()
+				np = NsxtNetworkProvider(client, "false")
+				ctx.VSphereCluster.Status.NetworkName = vnetObj.Name
+				ctx.VSphereCluster.Status.NetworkSNATIP = fakeVnetSNATIP
+
+				Expect(np.DeleteClusterNetwork(ctx)).To(Succeed())
+				err = client.Get(ctx, runtimeclient.ObjectKeyFromObject(vnetObj), &ncpv1.VirtualNetwork{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+				Expect(ctx.VSphereCluster.Status.NetworkName).To(BeEmpty())
+				Expect(ctx.VSphereCluster.Status.NetworkSNATIP).To(BeEmpty())
+			})
+
+			It("should succeed when the vnet does not exist", func() {
+				client = fake.NewClientBuilder().WithScheme(scheme).Build()
+				np = NsxtNetworkProvider(client, "false")
+				Expect(np.DeleteClusterNetwork(ctx)).To(Succeed())
+			})
+		})
+	})
+
 	Context("GetVMServiceAnnotations", func() {
 		Context("with netop network provider", func() {
 			var defaultNetwork *netopv1alpha1.Network
@@ -19,9 +19,13 @@ package services
 import (
 	goctx "context"
 	"encoding/json"
+	"fmt"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -29,17 +33,35 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/utils/integer"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	clusterutilv1 "sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/naming"
 	infrautilv1 "sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
+// vmHealthConditions are the conditions of a VSphereVM copied to its
+// VSphereMachine.
+var vmHealthConditions = []clusterv1.ConditionType{
+	infrav1.MachineNeedsRolloutCondition,
+	infrav1.GuestHeartbeatHealthyCondition,
+	infrav1.VMRunningCondition,
+	infrav1.WindowsActivatedCondition,
+	infrav1.EncryptionReadyCondition,
+}
+
+// maxVMNameAttempts is the number of random suffixes drawn to generate a VM
+// name which is not used yet.
+const maxVMNameAttempts = 5
+
 type VimMachineService struct{}
 
 func (v *VimMachineService) FetchVSphereMachine(c client.Client, name types.NamespacedName) (context.MachineContext, error) {
@@ -101,11 +123,18 @@ func (v *VimMachineService) SyncFailureReason(c context.MachineContext) (bool, e
 	if err != nil {
 		return false, err
 	}
-	if vsphereVM != nil {
-		// Reconcile VSphereMachine's failures
+	// Reconcile VSphereMachine's failures. A failure of the VSphereMachine
+	// itself, such as its node not joining in time, is kept.
+	if vsphereVM != nil && (vsphereVM.Status.FailureReason != nil || vsphereVM.Status.FailureMessage != nil) {
 		ctx.VSphereMachine.Status.FailureReason = vsphereVM.Status.FailureReason
 		ctx.VSphereMachine.Status.FailureMessage = vsphereVM.Status.FailureMessage
 	}
+	// The machines of a cluster whose creation is rolled back are marked as
+	// failed, so their VMs are not cloned again.
+	if ctx.VSphereCluster != nil && ctx.VSphereCluster.Status.CreationRollback != nil && ctx.VSphereMachine.Status.FailureReason == nil {
+		ctx.VSphereMachine.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.CreateMachineError)
+		ctx.VSphereMachine.Status.FailureMessage = pointer.String("the creation of the cluster is rolled back")
+	}
 
 	return ctx.VSphereMachine.Status.FailureReason != nil || ctx.VSphereMachine.Status.FailureMessage != nil, err
 }
@@ -120,9 +149,31 @@ func (v *VimMachineService) ReconcileNormal(c context.MachineContext) (bool, err
 		return false, err
 	}
 
-	vm, err := v.createOrUpdateVSPhereVM(ctx, vsphereVM)
+	template, ok, err := v.resolveImageTemplate(ctx, vsphereVM)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		ctx.Logger.Info("waiting for the template of the VSphereMachineImage to be resolved")
+		return true, nil
+	}
+
+	// A machine whose network is not configured with the IP families of its
+	// cluster would never join it, so its VM is not created.
+	if vsphereVM == nil {
+		if err := infrautilv1.ValidateMachineIPFamilies(ctx.Cluster, ctx.VSphereMachine.Spec.Network.Devices, infrautilv1.IsControlPlaneMachine(ctx.Machine)); err != nil {
+			ctx.Logger.Info("not creating the VM", "reason", err.Error())
+			conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, infrav1.IPFamilyMismatchReason, clusterv1.ConditionSeverityError, "%v", err)
+			return false, nil
+		}
+	}
+
+	vm, err := v.createOrUpdateVSPhereVM(ctx, vsphereVM, template)
 
 	if err != nil && !apierrors.IsAlreadyExists(err) {
+		if apierrors.IsInvalid(err) {
+			conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, infrav1.VSphereVMRejectedReason, clusterv1.ConditionSeverityError, "%v", err)
+		}
 		return false, err
 	}
 
@@ -138,6 +189,16 @@ func (v *VimMachineService) ReconcileNormal(c context.MachineContext) (bool, err
 	vmObj.SetAPIVersion(vm.GetObjectKind().GroupVersionKind().GroupVersion().String())
 	vmObj.SetKind(vm.GetObjectKind().GroupVersionKind().Kind)
 
+	// Surface the resource changes that require the machine to be replaced,
+	// and the health of the VM, which remediations can act upon.
+	for _, t := range vmHealthConditions {
+		if c := conditions.Get(conditions.UnstructuredGetter(vmObj), t); c != nil {
+			conditions.Set(ctx.VSphereMachine, c)
+		} else {
+			conditions.Delete(ctx.VSphereMachine, t)
+		}
+	}
+
 	// Waits the VM's ready state.
 	if ok, err := v.waitReadyState(ctx, vmObj); !ok {
 		if err != nil {
@@ -169,7 +230,21 @@ func (v *VimMachineService) ReconcileNormal(c context.MachineContext) (bool, err
 		return true, nil
 	}
 
+	// The topology is only reported, so failing to observe it does not hold
+	// up the readiness of the VSphereMachine, which keeps its last topology.
+	if feature.Gates.Enabled(feature.NodeTopologyLabels) {
+		if err := v.reconcileTopology(ctx, vmObj); err != nil {
+			ctx.Logger.Error(err, "failed to reconcile the topology")
+		}
+	}
+
 	ctx.VSphereMachine.Status.Ready = true
+
+	// A VSphereMachine whose node did not join in time is marked as failed,
+	// and requeued so that the failure is not reported as provisioned.
+	if !v.reconcileBootstrapJoin(ctx, vmObj) {
+		return true, nil
+	}
 	return false, nil
 }
 
@@ -215,6 +290,31 @@ func (v *VimMachineService) waitReadyState(ctx *context.VIMMachineContext, vm *u
 	return true, nil
 }
 
+// reconcileBootstrapJoin fails the VSphereMachine when the node of its Machine
+// does not join the cluster within the bootstrap join timeout after the
+// VSphereVM became ready. It returns false if the VSphereMachine failed.
+func (v *VimMachineService) reconcileBootstrapJoin(ctx *context.VIMMachineContext, vm *unstructured.Unstructured) bool {
+	if ctx.Machine.Status.NodeRef != nil {
+		return true
+	}
+	timeout := ctx.ProvisioningTimeouts.Resolve(ctx.VSphereMachine.Spec.Timeouts).BootstrapJoin
+	if timeout == 0 {
+		return true
+	}
+	provisioned := conditions.Get(conditions.UnstructuredGetter(vm), infrav1.VMProvisionedCondition)
+	if provisioned == nil || provisioned.Status != corev1.ConditionTrue || time.Since(provisioned.LastTransitionTime.Time) <= timeout {
+		return true
+	}
+
+	message := fmt.Sprintf("bootstrap join did not complete within %s", timeout)
+	ctx.Logger.Info("node did not join the cluster in time", "timeout", timeout)
+	ctx.VSphereMachine.Status.FailureReason = capierrors.MachineStatusErrorPtr(capierrors.CreateMachineError)
+	ctx.VSphereMachine.Status.FailureMessage = pointer.String(message)
+	conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, infrav1.ProvisioningTimeoutReason, clusterv1.ConditionSeverityError, message)
+	conditions.MarkFalse(ctx.VSphereMachine, infrav1.GuestBootstrappedCondition, infrav1.ProvisioningTimeoutReason, clusterv1.ConditionSeverityError, message)
+	return false
+}
+
 func (v *VimMachineService) reconcileProviderID(ctx *context.VIMMachineContext, vm *unstructured.Unstructured) (bool, error) {
 	biosUUID, ok, err := unstructured.NestedString(vm.Object, "spec", "biosUUID")
 	if !ok {
@@ -289,6 +389,11 @@ func (v *VimMachineService) reconcileNetwork(ctx *context.VIMMachineContext, vm
 	}
 
 	if addresses, ok, _ := unstructured.NestedStringSlice(vm.Object, "status", "addresses"); ok {
+		// The addresses of the primary IP family of a dual-stack cluster are
+		// reported first, since the first address is the preferred one.
+		if _, primaryIPv6, err := infrautilv1.GetClusterIPFamilies(ctx.Cluster); err == nil {
+			addresses = infrautilv1.SortAddressesByFamily(addresses, primaryIPv6)
+		}
 		var machineAddresses []clusterv1.MachineAddress
 		for _, addr := range addresses {
 			machineAddresses = append(machineAddresses, clusterv1.MachineAddress{
@@ -307,7 +412,39 @@ func (v *VimMachineService) reconcileNetwork(ctx *context.VIMMachineContext, vm
 	return true, nil
 }
 
-func (v *VimMachineService) createOrUpdateVSPhereVM(ctx *context.VIMMachineContext, vsphereVM *infrav1.VSphereVM) (runtime.Object, error) {
+// resolveImageTemplate returns the template the VSphereVM is cloned from. It is
+// the template of the VSphereMachine, unless the VSphereMachine references a
+// VSphereMachineImage, in which case it is the resolved template of the image,
+// or false when the image is not resolved yet. The template of an existing
+// VSphereVM is kept as is.
+func (v *VimMachineService) resolveImageTemplate(ctx *context.VIMMachineContext, vsphereVM *infrav1.VSphereVM) (string, bool, error) {
+	imageRef := ctx.VSphereMachine.Spec.ImageRef
+	if imageRef == nil {
+		return ctx.VSphereMachine.Spec.Template, true, nil
+	}
+	if vsphereVM != nil && vsphereVM.Spec.Template != "" {
+		return vsphereVM.Spec.Template, true, nil
+	}
+
+	image := &infrav1.VSphereMachineImage{}
+	key := client.ObjectKey{Namespace: ctx.VSphereMachine.Namespace, Name: imageRef.Name}
+	if err := ctx.Client.Get(ctx, key, image); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", false, errors.Wrapf(err, "failed to get VSphereMachineImage %s", key)
+		}
+		conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, infrav1.WaitingForImageReason, clusterv1.ConditionSeverityInfo,
+			"VSphereMachineImage %s not found", imageRef.Name)
+		return "", false, nil
+	}
+	if !image.Status.Ready || image.Status.TemplateRef == "" {
+		conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, infrav1.WaitingForImageReason, clusterv1.ConditionSeverityInfo,
+			"waiting for VSphereMachineImage %s to resolve its template", imageRef.Name)
+		return "", false, nil
+	}
+	return image.Status.TemplateRef, true, nil
+}
+
+func (v *VimMachineService) createOrUpdateVSPhereVM(ctx *context.VIMMachineContext, vsphereVM *infrav1.VSphereVM, template string) (runtime.Object, error) {
 	// Create or update the VSphereVM resource.
 	vm := &infrav1.VSphereVM{
 		ObjectMeta: metav1.ObjectMeta{
@@ -350,9 +487,16 @@ func (v *VimMachineService) createOrUpdateVSPhereVM(ctx *context.VIMMachineConte
 			vm.Labels[clusterv1.MachineControlPlaneLabelName] = val
 		}
 
+		// The VSphereVMs of the machines of a MachineDeployment only claim
+		// the VMs of the warm pools of the MachineDeployment, if any.
+		if val, ok := ctx.Machine.Labels[clusterv1.MachineDeploymentLabelName]; ok {
+			vm.Labels[clusterv1.MachineDeploymentLabelName] = val
+		}
+
 		// Copy the VSphereMachine's VM clone spec into the VSphereVM's
 		// clone spec.
 		ctx.VSphereMachine.Spec.VirtualMachineCloneSpec.DeepCopyInto(&vm.Spec.VirtualMachineCloneSpec)
+		vm.Spec.Template = template
 
 		// If Failure Domain is present on CAPI machine, use that to override the vm clone spec.
 		if overrideFunc, ok := v.generateOverrideFunc(ctx); ok {
@@ -371,8 +515,48 @@ func (v *VimMachineService) createOrUpdateVSPhereVM(ctx *context.VIMMachineConte
 		if vm.Spec.Thumbprint == "" {
 			vm.Spec.Thumbprint = ctx.VSphereCluster.Spec.Thumbprint
 		}
+		if vm.Spec.CABundleRef == nil {
+			vm.Spec.CABundleRef = ctx.VSphereCluster.Spec.CABundleRef
+		}
+		applyClusterNetworkSettings(&vm.Spec.Network, ctx.VSphereCluster.Spec.NetworkSettings)
+
+		// The VMs of the machines without a failure domain are placed in the
+		// folder and the resource pool of the cluster, if any, unless their
+		// VSphereMachine sets its own. The placement of an existing VSphereVM
+		// is kept.
+		if placement := ctx.VSphereCluster.Status.Placement; placement != nil && ctx.Machine.Spec.FailureDomain == nil {
+			if vm.Spec.Folder == "" {
+				vm.Spec.Folder = placement.Folder
+			}
+			if vm.Spec.ResourcePool == "" {
+				vm.Spec.ResourcePool = placement.ResourcePool
+			}
+		}
 		if vsphereVM != nil {
+			vm.Spec.Folder = vsphereVM.Spec.Folder
+			vm.Spec.ResourcePool = vsphereVM.Spec.ResourcePool
 			vm.Spec.BiosUUID = vsphereVM.Spec.BiosUUID
+			vm.Spec.Network.NTPServers = vsphereVM.Spec.Network.NTPServers
+			vm.Spec.Network.Proxy = vsphereVM.Spec.Network.Proxy
+			vm.Spec.VMName = vsphereVM.Spec.VMName
+			return nil
+		}
+
+		// A new VSphereVM claims a matching VM of a warm pool, if any, rather
+		// than cloning one.
+		if err := v.claimWarmPoolVM(ctx, vm); err != nil {
+			return err
+		}
+
+		// The VM of a new VSphereVM is named after the naming strategy of
+		// the cluster, unless it is claimed from a warm pool.
+		if _, claimed := vm.Annotations[infrav1.WarmPoolVMAnnotation]; !claimed && vm.CreationTimestamp.IsZero() {
+			vmName, err := v.generateVMName(ctx, vm)
+			if err != nil {
+				conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, infrav1.VMNameInvalidReason, clusterv1.ConditionSeverityError, "%v", err)
+				return err
+			}
+			vm.Spec.VMName = vmName
 		}
 		return nil
 	}
@@ -389,25 +573,173 @@ func (v *VimMachineService) createOrUpdateVSPhereVM(ctx *context.VIMMachineConte
 	return vm, nil
 }
 
+// generateVMName renders the name of the VM of a new VSphereVM from the VM
+// template of the naming strategy of the cluster. The random suffix is drawn
+// again when the name is already used by another VSphereVM of the same
+// vCenter. An empty name is returned when the cluster has no VM template,
+// in which case the VM is named after the VSphereVM.
+func (v *VimMachineService) generateVMName(ctx *context.VIMMachineContext, vm *infrav1.VSphereVM) (string, error) {
+	strategy := ctx.VSphereCluster.Spec.Naming
+	if strategy == nil || strategy.VM == "" {
+		return "", nil
+	}
+
+	vms := &infrav1.VSphereVMList{}
+	if err := ctx.Client.List(ctx, vms); err != nil {
+		return "", errors.Wrap(err, "failed to list the VSphereVMs")
+	}
+	used := map[string]bool{}
+	for i := range vms.Items {
+		other := &vms.Items[i]
+		if other.Namespace == vm.Namespace && other.Name == vm.Name {
+			continue
+		}
+		if other.Spec.Server == vm.Spec.Server {
+			used[infrautilv1.GetVMName(other)] = true
+		}
+	}
+
+	input := naming.Input{
+		ClusterName: ctx.Machine.Labels[clusterv1.ClusterLabelName],
+		Namespace:   ctx.Machine.Namespace,
+		MachineName: ctx.Machine.Name,
+	}
+	for i := 0; i < maxVMNameAttempts; i++ {
+		input.RandomSuffix = naming.NewRandomSuffix()
+		name, err := naming.Render(strategy.VM, input)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to generate the name of the VM of %s", ctx)
+		}
+		if !used[name] {
+			return name, nil
+		}
+	}
+	return "", errors.Errorf("failed to generate a name for the VM of %s which is not used by another VSphereVM of vCenter %s", ctx, vm.Spec.Server)
+}
+
+// claimWarmPoolVM claims the VM of a ready VSphereVM of a warm pool whose
+// clone spec is equal to the clone spec of the new VSphereVM, by annotating
+// the VSphereVM of the pool and setting its BIOS UUID on the new VSphereVM.
+// A VM claimed by an earlier attempt to create the VSphereVM is reused.
+func (v *VimMachineService) claimWarmPoolVM(ctx *context.VIMMachineContext, vm *infrav1.VSphereVM) error {
+	poolVMs := &infrav1.VSphereVMList{}
+	if err := ctx.Client.List(ctx, poolVMs, client.InNamespace(vm.Namespace), client.HasLabels{infrav1.WarmPoolLabel}); err != nil {
+		return errors.Wrapf(err, "failed to list the VSphereVMs of warm pools in namespace %s", vm.Namespace)
+	}
+
+	var candidate *infrav1.VSphereVM
+	for i := range poolVMs.Items {
+		poolVM := &poolVMs.Items[i]
+		if claimedBy, ok := poolVM.Annotations[infrav1.WarmPoolClaimedByAnnotation]; ok {
+			if claimedBy == vm.Name && poolVM.Spec.BiosUUID != "" {
+				setWarmPoolVM(vm, poolVM)
+				return nil
+			}
+			continue
+		}
+		if candidate != nil || !isClaimable(poolVM, vm) {
+			continue
+		}
+		candidate = poolVM
+	}
+	if candidate == nil {
+		return nil
+	}
+
+	// The update fails on conflict when the VM is claimed concurrently.
+	if candidate.Annotations == nil {
+		candidate.Annotations = map[string]string{}
+	}
+	candidate.Annotations[infrav1.WarmPoolClaimedByAnnotation] = vm.Name
+	if err := ctx.Client.Update(ctx, candidate); err != nil {
+		return errors.Wrapf(err, "failed to claim VSphereVM %s/%s of warm pool %s", candidate.Namespace, candidate.Name, candidate.Labels[infrav1.WarmPoolLabel])
+	}
+	ctx.Logger.Info("claimed VM of warm pool", "pool", candidate.Labels[infrav1.WarmPoolLabel], "vm", candidate.Name)
+	setWarmPoolVM(vm, candidate)
+	return nil
+}
+
+// isClaimable returns true if the VSphereVM of a warm pool is ready, idle and
+// cloned with the clone spec of the given VSphereVM, for the same cluster and
+// MachineDeployment.
+func isClaimable(poolVM, vm *infrav1.VSphereVM) bool {
+	if !poolVM.DeletionTimestamp.IsZero() || !poolVM.Status.Ready || poolVM.Status.TaskRef != "" || poolVM.Spec.BiosUUID == "" {
+		return false
+	}
+	if clusterName, ok := poolVM.Labels[clusterv1.ClusterLabelName]; ok && clusterName != vm.Labels[clusterv1.ClusterLabelName] {
+		return false
+	}
+	if deploymentName, ok := poolVM.Labels[clusterv1.MachineDeploymentLabelName]; ok && deploymentName != vm.Labels[clusterv1.MachineDeploymentLabelName] {
+		return false
+	}
+	return apiequality.Semantic.DeepEqual(poolVM.Spec.VirtualMachineCloneSpec, vm.Spec.VirtualMachineCloneSpec)
+}
+
+// setWarmPoolVM records the VM of a warm pool claimed by a VSphereVM. A VM
+// kept powered on is marked to be restarted once its bootstrap data is set.
+func setWarmPoolVM(vm, poolVM *infrav1.VSphereVM) {
+	if vm.Annotations == nil {
+		vm.Annotations = map[string]string{}
+	}
+	vm.Annotations[infrav1.WarmPoolVMAnnotation] = poolVM.Name
+	if poolVM.Annotations[infrav1.WarmPoolPowerStateAnnotation] == string(infrav1.PoweredOnWarmPoolPowerState) {
+		vm.Annotations[infrav1.WarmPoolPowerStateAnnotation] = string(infrav1.PoweredOnWarmPoolPowerState)
+	}
+	vm.Spec.BiosUUID = poolVM.Spec.BiosUUID
+}
+
+// reconcileTopology records the region and the zone of the failure domain of
+// the machine, and the ESXi host and the datastore of its VM, in the topology
+// of the VSphereMachine. The topology is left as is on error.
+func (v *VimMachineService) reconcileTopology(ctx *context.VIMMachineContext, vm *unstructured.Unstructured) error {
+	topology := &infrav1.MachineTopologyStatus{}
+	topology.Host, _, _ = unstructured.NestedString(vm.Object, "status", "host")
+	topology.Datastore, _, _ = unstructured.NestedString(vm.Object, "status", "datastore")
+
+	if failureDomainName := ctx.Machine.Spec.FailureDomain; failureDomainName != nil {
+		var vsphereDeploymentZone infrav1.VSphereDeploymentZone
+		if err := ctx.Client.Get(ctx, client.ObjectKey{Name: *failureDomainName}, &vsphereDeploymentZone); err != nil {
+			return errors.Wrapf(err, "failed to get VSphereDeploymentZone %s", *failureDomainName)
+		}
+		var vsphereFailureDomain infrav1.VSphereFailureDomain
+		if err := ctx.Client.Get(ctx, client.ObjectKey{Name: vsphereDeploymentZone.Spec.FailureDomain}, &vsphereFailureDomain); err != nil {
+			return errors.Wrapf(err, "failed to get VSphereFailureDomain %s", vsphereDeploymentZone.Spec.FailureDomain)
+		}
+		topology.Region = vsphereFailureDomain.Spec.Region.Name
+		topology.Zone = vsphereFailureDomain.Spec.Zone.Name
+	}
+
+	if *topology == (infrav1.MachineTopologyStatus{}) {
+		topology = nil
+	}
+	ctx.VSphereMachine.Status.Topology = topology
+	return nil
+}
+
 // generateOverrideFunc returns a function which can override the values in the VSphereVM Spec
 // with the values from the FailureDomain (if any) set on the owner CAPI machine.
-//nolint:nestif
 func (v *VimMachineService) generateOverrideFunc(ctx *context.VIMMachineContext) (func(vm *infrav1.VSphereVM), bool) {
-	failureDomainName := ctx.Machine.Spec.FailureDomain
+	return failureDomainOverrideFunc(ctx, ctx.Client, ctx.Logger, ctx.Machine.Spec.FailureDomain)
+}
+
+// failureDomainOverrideFunc returns a function which can override the values
+// in the VSphereVM Spec with the values from the FailureDomain, if any.
+//nolint:nestif
+func failureDomainOverrideFunc(ctx goctx.Context, c client.Client, logger logr.Logger, failureDomainName *string) (func(vm *infrav1.VSphereVM), bool) {
 	if failureDomainName == nil {
 		return nil, false
 	}
 
 	// Use the failureDomain name to fetch the vSphereDeploymentZone object
 	var vsphereDeploymentZone infrav1.VSphereDeploymentZone
-	if err := ctx.Client.Get(ctx, client.ObjectKey{Name: *failureDomainName}, &vsphereDeploymentZone); err != nil {
-		ctx.Logger.Error(err, "unable to fetch vsphere deployment zone", "name", *failureDomainName)
+	if err := c.Get(ctx, client.ObjectKey{Name: *failureDomainName}, &vsphereDeploymentZone); err != nil {
+		logger.Error(err, "unable to fetch vsphere deployment zone", "name", *failureDomainName)
 		return nil, false
 	}
 
 	var vsphereFailureDomain infrav1.VSphereFailureDomain
-	if err := ctx.Client.Get(ctx, client.ObjectKey{Name: vsphereDeploymentZone.Spec.FailureDomain}, &vsphereFailureDomain); err != nil {
-		ctx.Logger.Error(err, "unable to fetch failure domain", "name", vsphereDeploymentZone.Spec.FailureDomain)
+	if err := c.Get(ctx, client.ObjectKey{Name: vsphereDeploymentZone.Spec.FailureDomain}, &vsphereFailureDomain); err != nil {
+		logger.Error(err, "unable to fetch failure domain", "name", vsphereDeploymentZone.Spec.FailureDomain)
 		return nil, false
 	}
 
@@ -419,6 +751,10 @@ func (v *VimMachineService) generateOverrideFunc(ctx *context.VIMMachineContext)
 		}
 		if vsphereDeploymentZone.Spec.PlacementConstraint.ResourcePool != "" {
 			vm.Spec.ResourcePool = vsphereDeploymentZone.Spec.PlacementConstraint.ResourcePool
+		} else if computeCluster := vsphereFailureDomain.Spec.Topology.ComputeCluster; computeCluster != nil && *computeCluster != "" {
+			// Without an explicit resource pool, place the VM in the root
+			// resource pool of the compute cluster of the failure domain.
+			vm.Spec.ResourcePool = fmt.Sprintf("%s/Resources", *computeCluster)
 		}
 		if vsphereFailureDomain.Spec.Topology.Datastore != "" {
 			vm.Spec.Datastore = vsphereFailureDomain.Spec.Topology.Datastore
@@ -430,6 +766,34 @@ func (v *VimMachineService) generateOverrideFunc(ctx *context.VIMMachineContext)
 	return overrideWithFailureDomainFunc, true
 }
 
+// applyClusterNetworkSettings sets the DNS, NTP and proxy settings of the
+// cluster which are not set in the network of a VSphereVM. The nameservers and
+// the search domains of the cluster are set on the devices other than the
+// workload devices.
+func applyClusterNetworkSettings(network *infrav1.NetworkSpec, settings *infrav1.ClusterNetworkSettings) {
+	if settings == nil {
+		return
+	}
+	for i := range network.Devices {
+		device := &network.Devices[i]
+		if device.Role == infrav1.NetworkDeviceRoleWorkload {
+			continue
+		}
+		if len(device.Nameservers) == 0 {
+			device.Nameservers = append([]string(nil), settings.Nameservers...)
+		}
+		if len(device.SearchDomains) == 0 {
+			device.SearchDomains = append([]string(nil), settings.SearchDomains...)
+		}
+	}
+	if len(network.NTPServers) == 0 {
+		network.NTPServers = append([]string(nil), settings.NTPServers...)
+	}
+	if network.Proxy == nil && settings.Proxy != nil {
+		network.Proxy = settings.Proxy.DeepCopy()
+	}
+}
+
 // overrideNetworkDeviceSpecs updates the network devices with the network definitions from the PlacementConstraint.
 // The substitution is done based on the order in which the network devices have been defined.
 //
@@ -18,11 +18,18 @@ package services
 
 import (
 	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
@@ -100,6 +107,28 @@ var _ = Describe("VimMachineService_GenerateOverrideFunc", func() {
 			Expect(vm.Spec.Datacenter).To(Equal("dc-one"))
 		})
 
+		Context("without a resource pool in the deployment zone", func() {
+			BeforeEach(func() {
+				zone := deplZone("three")
+				zone.Spec.PlacementConstraint.ResourcePool = ""
+				fd := failureDomain("three")
+				fd.Spec.Topology.ComputeCluster = pointer.String("cluster-three")
+				Expect(controllerCtx.Client.Create(controllerCtx, zone)).To(Succeed())
+				Expect(controllerCtx.Client.Create(controllerCtx, fd)).To(Succeed())
+				machineCtx.Machine.Spec.FailureDomain = pointer.String("zone-three")
+			})
+
+			It("uses the root resource pool of the compute cluster of the failure domain", func() {
+				overrideFunc, ok := vimMachineService.generateOverrideFunc(machineCtx)
+				Expect(ok).To(BeTrue())
+
+				vm := &infrav1.VSphereVM{Spec: infrav1.VSphereVMSpec{}}
+				overrideFunc(vm)
+
+				Expect(vm.Spec.ResourcePool).To(Equal("cluster-three/Resources"))
+			})
+		})
+
 		Context("for non-existent failure domain value", func() {
 			BeforeEach(func() {
 				machineCtx.Machine.Spec.FailureDomain = pointer.String("non-existent-zone")
@@ -183,3 +212,517 @@ var _ = Describe("VimMachineService_GenerateOverrideFunc", func() {
 		})
 	})
 })
+
+var _ = Describe("VimMachineService_ReconcileTopology", func() {
+	var (
+		machineCtx        *context.VIMMachineContext
+		vimMachineService *VimMachineService
+		vm                *unstructured.Unstructured
+	)
+
+	BeforeEach(func() {
+		deploymentZone := &infrav1.VSphereDeploymentZone{
+			ObjectMeta: metav1.ObjectMeta{Name: "zone-one"},
+			Spec:       infrav1.VSphereDeploymentZoneSpec{FailureDomain: "fd-one"},
+		}
+		failureDomain := &infrav1.VSphereFailureDomain{
+			ObjectMeta: metav1.ObjectMeta{Name: "fd-one"},
+			Spec: infrav1.VSphereFailureDomainSpec{
+				Region: infrav1.FailureDomain{Name: "region-a"},
+				Zone:   infrav1.FailureDomain{Name: "zone-a"},
+			},
+		}
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(deploymentZone, failureDomain))
+		machineCtx = fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+		vimMachineService = &VimMachineService{}
+
+		vsphereVM := &infrav1.VSphereVM{Status: infrav1.VSphereVMStatus{Host: "esx-0", Datastore: "ds-0"}}
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(vsphereVM)
+		Expect(err).NotTo(HaveOccurred())
+		vm = &unstructured.Unstructured{Object: data}
+	})
+
+	It("records the host and the datastore of the VM", func() {
+		Expect(vimMachineService.reconcileTopology(machineCtx, vm)).To(Succeed())
+		Expect(machineCtx.VSphereMachine.Status.Topology).To(Equal(&infrav1.MachineTopologyStatus{Host: "esx-0", Datastore: "ds-0"}))
+	})
+
+	It("records the region and the zone of the failure domain", func() {
+		machineCtx.Machine.Spec.FailureDomain = pointer.String("zone-one")
+		Expect(vimMachineService.reconcileTopology(machineCtx, vm)).To(Succeed())
+		Expect(machineCtx.VSphereMachine.Status.Topology).To(Equal(&infrav1.MachineTopologyStatus{Region: "region-a", Zone: "zone-a", Host: "esx-0", Datastore: "ds-0"}))
+	})
+
+	It("keeps the topology for a missing failure domain", func() {
+		topology := &infrav1.MachineTopologyStatus{Region: "region-a", Zone: "zone-a", Host: "esx-0", Datastore: "ds-0"}
+		machineCtx.VSphereMachine.Status.Topology = topology.DeepCopy()
+		machineCtx.Machine.Spec.FailureDomain = pointer.String("zone-two")
+		Expect(vimMachineService.reconcileTopology(machineCtx, vm)).NotTo(Succeed())
+		Expect(machineCtx.VSphereMachine.Status.Topology).To(Equal(topology))
+	})
+
+	It("does not record an empty topology", func() {
+		Expect(vimMachineService.reconcileTopology(machineCtx, &unstructured.Unstructured{Object: map[string]interface{}{}})).To(Succeed())
+		Expect(machineCtx.VSphereMachine.Status.Topology).To(BeNil())
+	})
+})
+
+var _ = Describe("VimMachineService_ReconcileBootstrapJoin", func() {
+	var (
+		machineCtx        *context.VIMMachineContext
+		vimMachineService *VimMachineService
+		vm                *unstructured.Unstructured
+	)
+
+	provisionedSince := func(d time.Duration) *unstructured.Unstructured {
+		vsphereVM := &infrav1.VSphereVM{}
+		conditions.MarkTrue(vsphereVM, infrav1.VMProvisionedCondition)
+		vsphereVM.Status.Conditions[0].LastTransitionTime = metav1.NewTime(time.Now().Add(-d))
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(vsphereVM)
+		Expect(err).NotTo(HaveOccurred())
+		return &unstructured.Unstructured{Object: data}
+	}
+
+	BeforeEach(func() {
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		controllerCtx.ProvisioningTimeouts.BootstrapJoin = 30 * time.Minute
+		machineCtx = fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+		vimMachineService = &VimMachineService{}
+	})
+
+	Context("when the node has not joined within the timeout", func() {
+		BeforeEach(func() {
+			vm = provisionedSince(time.Hour)
+		})
+
+		It("marks the VSphereMachine as failed", func() {
+			Expect(vimMachineService.reconcileBootstrapJoin(machineCtx, vm)).To(BeFalse())
+			Expect(machineCtx.VSphereMachine.Status.FailureReason).NotTo(BeNil())
+			Expect(conditions.GetReason(machineCtx.VSphereMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.ProvisioningTimeoutReason))
+			Expect(conditions.GetReason(machineCtx.VSphereMachine, infrav1.GuestBootstrappedCondition)).To(Equal(infrav1.ProvisioningTimeoutReason))
+		})
+
+		It("uses the timeout of the VSphereMachine", func() {
+			machineCtx.VSphereMachine.Spec.Timeouts = &infrav1.ProvisioningTimeouts{BootstrapJoin: &metav1.Duration{Duration: 2 * time.Hour}}
+			Expect(vimMachineService.reconcileBootstrapJoin(machineCtx, vm)).To(BeTrue())
+			Expect(machineCtx.VSphereMachine.Status.FailureReason).To(BeNil())
+		})
+
+		It("does nothing once the node has joined", func() {
+			machineCtx.Machine.Status.NodeRef = &corev1.ObjectReference{Name: "node"}
+			Expect(vimMachineService.reconcileBootstrapJoin(machineCtx, vm)).To(BeTrue())
+			Expect(machineCtx.VSphereMachine.Status.FailureReason).To(BeNil())
+		})
+	})
+
+	Context("when the timeout has not expired", func() {
+		It("does not fail the VSphereMachine", func() {
+			Expect(vimMachineService.reconcileBootstrapJoin(machineCtx, provisionedSince(time.Minute))).To(BeTrue())
+			Expect(machineCtx.VSphereMachine.Status.FailureReason).To(BeNil())
+		})
+	})
+})
+
+var _ = Describe("VimMachineService_ResolveImageTemplate", func() {
+	var (
+		machineCtx        *context.VIMMachineContext
+		vimMachineService *VimMachineService
+	)
+
+	image := func(name string, templateRef string) *infrav1.VSphereMachineImage {
+		return &infrav1.VSphereMachineImage{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fake.Namespace, Name: name},
+			Status:     infrav1.VSphereMachineImageStatus{Ready: templateRef != "", TemplateRef: templateRef},
+		}
+	}
+
+	BeforeEach(func() {
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(image("resolved", "VirtualMachine:vm-42"), image("pending", "")))
+		machineCtx = fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+		machineCtx.VSphereMachine.Spec.Template = "ubuntu"
+		vimMachineService = &VimMachineService{}
+	})
+
+	It("uses the template of the VSphereMachine without an image reference", func() {
+		template, ok, err := vimMachineService.resolveImageTemplate(machineCtx, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(template).To(Equal("ubuntu"))
+	})
+
+	Context("with an image reference", func() {
+		BeforeEach(func() {
+			machineCtx.VSphereMachine.Spec.Template = ""
+		})
+
+		It("uses the template resolved by the VSphereMachineImage", func() {
+			machineCtx.VSphereMachine.Spec.ImageRef = &corev1.LocalObjectReference{Name: "resolved"}
+			template, ok, err := vimMachineService.resolveImageTemplate(machineCtx, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(template).To(Equal("VirtualMachine:vm-42"))
+		})
+
+		It("keeps the template of an existing VSphereVM", func() {
+			machineCtx.VSphereMachine.Spec.ImageRef = &corev1.LocalObjectReference{Name: "resolved"}
+			vsphereVM := &infrav1.VSphereVM{Spec: infrav1.VSphereVMSpec{VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Template: "VirtualMachine:vm-7"}}}
+			template, ok, err := vimMachineService.resolveImageTemplate(machineCtx, vsphereVM)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(template).To(Equal("VirtualMachine:vm-7"))
+		})
+
+		It("waits for the VSphereMachineImage to be resolved", func() {
+			machineCtx.VSphereMachine.Spec.ImageRef = &corev1.LocalObjectReference{Name: "pending"}
+			_, ok, err := vimMachineService.resolveImageTemplate(machineCtx, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(conditions.GetReason(machineCtx.VSphereMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.WaitingForImageReason))
+		})
+
+		It("waits for a missing VSphereMachineImage", func() {
+			machineCtx.VSphereMachine.Spec.ImageRef = &corev1.LocalObjectReference{Name: "missing"}
+			_, ok, err := vimMachineService.resolveImageTemplate(machineCtx, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			Expect(conditions.GetReason(machineCtx.VSphereMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.WaitingForImageReason))
+		})
+	})
+})
+
+var _ = Describe("VimMachineService_ClaimWarmPoolVM", func() {
+	var (
+		machineCtx        *context.VIMMachineContext
+		vimMachineService *VimMachineService
+		vm                *infrav1.VSphereVM
+	)
+
+	cloneSpec := infrav1.VirtualMachineCloneSpec{Template: "ubuntu", Server: "vcenter"}
+	poolVM := func(name, biosUUID, clusterName string, annotations map[string]string) *infrav1.VSphereVM {
+		poolVM := &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   fake.Namespace,
+				Name:        name,
+				Labels:      map[string]string{infrav1.WarmPoolLabel: "pool", clusterv1.ClusterLabelName: clusterName},
+				Annotations: annotations,
+			},
+			Spec:   infrav1.VSphereVMSpec{VirtualMachineCloneSpec: cloneSpec, BiosUUID: biosUUID},
+			Status: infrav1.VSphereVMStatus{Ready: biosUUID != ""},
+		}
+		return poolVM
+	}
+
+	newContext := func(objects ...client.Object) {
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(objects...))
+		machineCtx = fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+		vm = &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: fake.Namespace,
+				Name:      "machine-0",
+				Labels:    map[string]string{clusterv1.ClusterLabelName: fake.Clusterv1a2Name},
+			},
+			Spec: infrav1.VSphereVMSpec{VirtualMachineCloneSpec: cloneSpec},
+		}
+		vimMachineService = &VimMachineService{}
+	}
+
+	It("claims a ready VM with the same clone spec", func() {
+		otherSpec := poolVM("other-spec", "uuid-other-spec", fake.Clusterv1a2Name, nil)
+		otherSpec.Spec.Template = "photon"
+		newContext(
+			otherSpec,
+			poolVM("other-cluster", "uuid-other-cluster", "other", nil),
+			poolVM("pending", "", fake.Clusterv1a2Name, nil),
+			poolVM("ready", "uuid-ready", fake.Clusterv1a2Name, nil),
+		)
+
+		Expect(vimMachineService.claimWarmPoolVM(machineCtx, vm)).To(Succeed())
+		Expect(vm.Spec.BiosUUID).To(Equal("uuid-ready"))
+		Expect(vm.Annotations).To(HaveKeyWithValue(infrav1.WarmPoolVMAnnotation, "ready"))
+		Expect(vm.Annotations).NotTo(HaveKey(infrav1.WarmPoolPowerStateAnnotation))
+
+		claimed := &infrav1.VSphereVM{}
+		Expect(machineCtx.Client.Get(machineCtx, client.ObjectKey{Namespace: fake.Namespace, Name: "ready"}, claimed)).To(Succeed())
+		Expect(claimed.Annotations).To(HaveKeyWithValue(infrav1.WarmPoolClaimedByAnnotation, "machine-0"))
+	})
+
+	It("claims a VM of the pools of the MachineDeployment of the machine", func() {
+		otherDeployment := poolVM("other-deployment", "uuid-other-deployment", fake.Clusterv1a2Name, nil)
+		otherDeployment.Labels[clusterv1.MachineDeploymentLabelName] = "other"
+		sameDeployment := poolVM("same-deployment", "uuid-same-deployment", fake.Clusterv1a2Name, nil)
+		sameDeployment.Labels[clusterv1.MachineDeploymentLabelName] = "workers"
+		newContext(otherDeployment, sameDeployment)
+		vm.Labels[clusterv1.MachineDeploymentLabelName] = "workers"
+
+		Expect(vimMachineService.claimWarmPoolVM(machineCtx, vm)).To(Succeed())
+		Expect(vm.Spec.BiosUUID).To(Equal("uuid-same-deployment"))
+	})
+
+	It("does not claim a VM of the pools of another MachineDeployment", func() {
+		deployment := poolVM("deployment", "uuid-deployment", fake.Clusterv1a2Name, nil)
+		deployment.Labels[clusterv1.MachineDeploymentLabelName] = "workers"
+		newContext(deployment)
+
+		Expect(vimMachineService.claimWarmPoolVM(machineCtx, vm)).To(Succeed())
+		Expect(vm.Spec.BiosUUID).To(BeEmpty())
+	})
+
+	It("marks a VM kept powered on to be restarted", func() {
+		newContext(
+			poolVM("powered-on", "uuid-powered-on", fake.Clusterv1a2Name, map[string]string{infrav1.WarmPoolPowerStateAnnotation: string(infrav1.PoweredOnWarmPoolPowerState)}),
+		)
+
+		Expect(vimMachineService.claimWarmPoolVM(machineCtx, vm)).To(Succeed())
+		Expect(vm.Spec.BiosUUID).To(Equal("uuid-powered-on"))
+		Expect(vm.Annotations).To(HaveKeyWithValue(infrav1.WarmPoolPowerStateAnnotation, string(infrav1.PoweredOnWarmPoolPowerState)))
+	})
+
+	It("reuses the VM claimed by an earlier attempt", func() {
+		newContext(
+			poolVM("ready", "uuid-ready", fake.Clusterv1a2Name, nil),
+			poolVM("claimed", "uuid-claimed", fake.Clusterv1a2Name, map[string]string{infrav1.WarmPoolClaimedByAnnotation: "machine-0"}),
+		)
+
+		Expect(vimMachineService.claimWarmPoolVM(machineCtx, vm)).To(Succeed())
+		Expect(vm.Spec.BiosUUID).To(Equal("uuid-claimed"))
+		Expect(vm.Annotations).To(HaveKeyWithValue(infrav1.WarmPoolVMAnnotation, "claimed"))
+	})
+
+	It("does not claim a VM claimed by another machine", func() {
+		newContext(
+			poolVM("claimed", "uuid-claimed", fake.Clusterv1a2Name, map[string]string{infrav1.WarmPoolClaimedByAnnotation: "machine-1"}),
+		)
+
+		Expect(vimMachineService.claimWarmPoolVM(machineCtx, vm)).To(Succeed())
+		Expect(vm.Spec.BiosUUID).To(BeEmpty())
+		Expect(vm.Annotations).NotTo(HaveKey(infrav1.WarmPoolVMAnnotation))
+	})
+})
+
+var _ = Describe("VimMachineService_GenerateVMName", func() {
+	var (
+		machineCtx        *context.VIMMachineContext
+		vimMachineService *VimMachineService
+		vm                *infrav1.VSphereVM
+	)
+
+	vsphereVM := func(namespace, name, vmName, server string) *infrav1.VSphereVM {
+		return &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: infrav1.VSphereVMSpec{
+				VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Server: server},
+				VMName:                  vmName,
+			},
+		}
+	}
+
+	newContext := func(strategy *infrav1.NamingStrategy, objects ...client.Object) {
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext(objects...))
+		machineCtx = fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+		machineCtx.Machine.Labels = map[string]string{clusterv1.ClusterLabelName: "prod"}
+		machineCtx.VSphereCluster.Spec.Naming = strategy
+		vm = vsphereVM(fake.Namespace, machineCtx.Machine.Name, "", "vcenter")
+		vimMachineService = &VimMachineService{}
+	}
+
+	It("does not generate a name without a VM template", func() {
+		newContext(&infrav1.NamingStrategy{Placement: "{{ .ClusterName }}"})
+
+		name, err := vimMachineService.generateVMName(machineCtx, vm)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(BeEmpty())
+	})
+
+	It("renders the VM template of the cluster", func() {
+		newContext(&infrav1.NamingStrategy{VM: "{{ .ClusterName }}-{{ .Namespace }}-{{ .RandomSuffix }}"})
+
+		name, err := vimMachineService.generateVMName(machineCtx, vm)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(MatchRegexp(`^prod-%s-[a-z0-9]{5}$`, fake.Namespace))
+	})
+
+	It("fails when the name is used by another VSphereVM of the same vCenter", func() {
+		newContext(&infrav1.NamingStrategy{VM: "{{ .ClusterName }}-{{ .MachineName }}"},
+			vsphereVM("other", "other-vm", "prod-"+fake.Clusterv1a2Name, "vcenter"))
+
+		_, err := vimMachineService.generateVMName(machineCtx, vm)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("ignores the VSphereVMs of other vCenters and the VSphereVM itself", func() {
+		newContext(&infrav1.NamingStrategy{VM: "{{ .ClusterName }}-{{ .MachineName }}"},
+			vsphereVM("other", "other-vm", "prod-"+fake.Clusterv1a2Name, "other-vcenter"),
+			vsphereVM(fake.Namespace, fake.Clusterv1a2Name, "prod-"+fake.Clusterv1a2Name, "vcenter"))
+
+		name, err := vimMachineService.generateVMName(machineCtx, vm)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("prod-" + fake.Clusterv1a2Name))
+	})
+})
+
+var _ = Describe("VimMachineService_applyClusterNetworkSettings", func() {
+	settings := &infrav1.ClusterNetworkSettings{
+		Nameservers:   []string{"10.0.0.2"},
+		SearchDomains: []string{"example.com"},
+		NTPServers:    []string{"ntp.example.com"},
+		Proxy:         &infrav1.ProxySpec{HTTPSProxy: "http://proxy.example.com:3128"},
+	}
+
+	It("fills the settings which are not set by the machine", func() {
+		network := infrav1.NetworkSpec{
+			Devices: []infrav1.NetworkDeviceSpec{
+				{NetworkName: "primary"},
+				{NetworkName: "secondary", Nameservers: []string{"192.168.0.2"}},
+			},
+		}
+		applyClusterNetworkSettings(&network, settings)
+
+		Expect(network.Devices[0].Nameservers).To(ConsistOf("10.0.0.2"))
+		Expect(network.Devices[0].SearchDomains).To(ConsistOf("example.com"))
+		Expect(network.Devices[1].Nameservers).To(ConsistOf("192.168.0.2"))
+		Expect(network.NTPServers).To(ConsistOf("ntp.example.com"))
+		Expect(network.Proxy).To(Equal(settings.Proxy))
+		Expect(network.Proxy).NotTo(BeIdenticalTo(settings.Proxy))
+	})
+
+	It("keeps the settings of the machine", func() {
+		proxy := &infrav1.ProxySpec{HTTPProxy: "http://other.example.com:3128"}
+		network := infrav1.NetworkSpec{
+			Devices:    []infrav1.NetworkDeviceSpec{{NetworkName: "primary", SearchDomains: []string{"other.com"}}},
+			NTPServers: []string{"time.example.com"},
+			Proxy:      proxy,
+		}
+		applyClusterNetworkSettings(&network, settings)
+
+		Expect(network.Devices[0].SearchDomains).To(ConsistOf("other.com"))
+		Expect(network.NTPServers).To(ConsistOf("time.example.com"))
+		Expect(network.Proxy).To(BeIdenticalTo(proxy))
+	})
+
+	It("skips the workload network devices", func() {
+		network := infrav1.NetworkSpec{
+			Devices: []infrav1.NetworkDeviceSpec{{NetworkName: "storage", Role: infrav1.NetworkDeviceRoleWorkload}},
+		}
+		applyClusterNetworkSettings(&network, settings)
+
+		Expect(network.Devices[0].Nameservers).To(BeEmpty())
+		Expect(network.Devices[0].SearchDomains).To(BeEmpty())
+	})
+})
+
+var _ = Describe("VimMachineService_CreateOrUpdateVSphereVM", func() {
+	var (
+		machineCtx        *context.VIMMachineContext
+		vimMachineService *VimMachineService
+	)
+
+	BeforeEach(func() {
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		machineCtx = fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+		machineCtx.Machine.Spec.Bootstrap.DataSecretName = pointer.String("bootstrap-data")
+		machineCtx.VSphereCluster.Status.Placement = &infrav1.ClusterPlacementStatus{Folder: "/dc0/vm/cluster", ResourcePool: "/dc0/host/cluster0/Resources/cluster"}
+		vimMachineService = &VimMachineService{}
+	})
+
+	getVSphereVM := func() *infrav1.VSphereVM {
+		vm := &infrav1.VSphereVM{}
+		Expect(machineCtx.Client.Get(machineCtx, client.ObjectKey{Namespace: machineCtx.VSphereMachine.Namespace, Name: machineCtx.Machine.Name}, vm)).To(Succeed())
+		return vm
+	}
+
+	It("places the VM in the folder and the resource pool of the cluster", func() {
+		_, err := vimMachineService.createOrUpdateVSPhereVM(machineCtx, nil, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		vm := getVSphereVM()
+		Expect(vm.Spec.Folder).To(Equal("/dc0/vm/cluster"))
+		Expect(vm.Spec.ResourcePool).To(Equal("/dc0/host/cluster0/Resources/cluster"))
+	})
+
+	It("keeps the folder and the resource pool set by the VSphereMachine", func() {
+		machineCtx.VSphereMachine.Spec.Folder = "/dc0/vm/own"
+		machineCtx.VSphereMachine.Spec.ResourcePool = "/dc0/host/cluster0/Resources/own"
+		_, err := vimMachineService.createOrUpdateVSPhereVM(machineCtx, nil, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		vm := getVSphereVM()
+		Expect(vm.Spec.Folder).To(Equal("/dc0/vm/own"))
+		Expect(vm.Spec.ResourcePool).To(Equal("/dc0/host/cluster0/Resources/own"))
+	})
+})
+
+var _ = Describe("ApplyMachinePoolVMPlacement", func() {
+	var (
+		controllerCtx  *context.ControllerContext
+		vsphereCluster *infrav1.VSphereCluster
+	)
+
+	BeforeEach(func() {
+		controllerCtx = fake.NewControllerContext(fake.NewControllerManagerContext())
+		vsphereCluster = &infrav1.VSphereCluster{
+			Spec: infrav1.VSphereClusterSpec{Server: "vcenter", Thumbprint: "thumbprint"},
+			Status: infrav1.VSphereClusterStatus{
+				Placement: &infrav1.ClusterPlacementStatus{Folder: "/dc0/vm/cluster", ResourcePool: "/dc0/host/cluster0/Resources/cluster"},
+			},
+		}
+	})
+
+	It("defaults the VM to the server and the placement of the cluster", func() {
+		vm := &infrav1.VSphereVM{}
+		ApplyMachinePoolVMPlacement(controllerCtx, controllerCtx.Client, controllerCtx.Logger, vm, vsphereCluster, nil)
+
+		Expect(vm.Spec.Server).To(Equal("vcenter"))
+		Expect(vm.Spec.Thumbprint).To(Equal("thumbprint"))
+		Expect(vm.Spec.Folder).To(Equal("/dc0/vm/cluster"))
+		Expect(vm.Spec.ResourcePool).To(Equal("/dc0/host/cluster0/Resources/cluster"))
+	})
+
+	It("keeps the server of the template", func() {
+		vm := &infrav1.VSphereVM{Spec: infrav1.VSphereVMSpec{VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Server: "other"}}}
+		ApplyMachinePoolVMPlacement(controllerCtx, controllerCtx.Client, controllerCtx.Logger, vm, vsphereCluster, nil)
+
+		Expect(vm.Spec.Server).To(Equal("other"))
+	})
+
+	It("keeps the folder and the resource pool of the template", func() {
+		vm := &infrav1.VSphereVM{Spec: infrav1.VSphereVMSpec{VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{Folder: "/dc0/vm/pool"}}}
+		ApplyMachinePoolVMPlacement(controllerCtx, controllerCtx.Client, controllerCtx.Logger, vm, vsphereCluster, nil)
+
+		Expect(vm.Spec.Folder).To(Equal("/dc0/vm/pool"))
+		Expect(vm.Spec.ResourcePool).To(Equal("/dc0/host/cluster0/Resources/cluster"))
+	})
+})
+
+var _ = Describe("VimMachineService_SyncFailureReason", func() {
+	var (
+		machineCtx        *context.VIMMachineContext
+		vimMachineService *VimMachineService
+	)
+
+	BeforeEach(func() {
+		controllerCtx := fake.NewControllerContext(fake.NewControllerManagerContext())
+		machineCtx = fake.NewMachineContext(fake.NewClusterContext(controllerCtx))
+		vimMachineService = &VimMachineService{}
+		vm := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: machineCtx.VSphereMachine.Namespace, Name: machineCtx.VSphereMachine.Name}}
+		Expect(machineCtx.Client.Create(machineCtx, vm)).To(Succeed())
+	})
+
+	It("does not fail the VSphereMachine of a cluster", func() {
+		failed, err := vimMachineService.SyncFailureReason(machineCtx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failed).To(BeFalse())
+	})
+
+	Context("when the creation of the cluster is rolled back", func() {
+		BeforeEach(func() {
+			machineCtx.VSphereCluster.Status.CreationRollback = &infrav1.ClusterCreationRollbackStatus{StartTime: metav1.Now()}
+		})
+
+		It("marks the VSphereMachine as failed", func() {
+			failed, err := vimMachineService.SyncFailureReason(machineCtx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(failed).To(BeTrue())
+			Expect(*machineCtx.VSphereMachine.Status.FailureMessage).To(Equal("the creation of the cluster is rolled back"))
+		})
+	})
+})
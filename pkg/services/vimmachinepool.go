@@ -0,0 +1,55 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	goctx "context"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// ApplyMachinePoolVMPlacement sets the clone spec of a new VSphereVM of a
+// VSphereMachinePool the way it is set for the VSphereVM of a machine: the
+// values of the failure domain, if any, override the template of the pool,
+// and the values set in neither default to the ones of the VSphereCluster.
+func ApplyMachinePoolVMPlacement(ctx goctx.Context, c client.Client, logger logr.Logger, vm *infrav1.VSphereVM,
+	vsphereCluster *infrav1.VSphereCluster, failureDomain *string) {
+	if overrideFunc, ok := failureDomainOverrideFunc(ctx, c, logger, failureDomain); ok {
+		overrideFunc(vm)
+	}
+	if vm.Spec.Server == "" {
+		vm.Spec.Server = vsphereCluster.Spec.Server
+	}
+	if vm.Spec.Thumbprint == "" {
+		vm.Spec.Thumbprint = vsphereCluster.Spec.Thumbprint
+	}
+	if vm.Spec.CABundleRef == nil {
+		vm.Spec.CABundleRef = vsphereCluster.Spec.CABundleRef
+	}
+	applyClusterNetworkSettings(&vm.Spec.Network, vsphereCluster.Spec.NetworkSettings)
+	if placement := vsphereCluster.Status.Placement; placement != nil && failureDomain == nil {
+		if vm.Spec.Folder == "" {
+			vm.Spec.Folder = placement.Folder
+		}
+		if vm.Spec.ResourcePool == "" {
+			vm.Spec.ResourcePool = placement.ResourcePool
+		}
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vmoperator
+
+import (
+	goctx "context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	vmoprv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/vmware"
+)
+
+// storageClassQuotaSuffix is the suffix of the name of the resources that
+// limit the storage requested from a storage class in a ResourceQuota. The
+// supervisor adds one of these resources to the quota of a namespace for each
+// storage policy assigned to the namespace.
+const storageClassQuotaSuffix = ".storageclass.storage.k8s.io/requests.storage"
+
+// vmResolutionError documents a VirtualMachineClass or a VirtualMachineImage
+// which VM Operator cannot create the VM of a machine with.
+type vmResolutionError struct {
+	reason  string
+	message string
+}
+
+func (e *vmResolutionError) Error() string {
+	return e.message
+}
+
+// resolveVMResources resolves the VirtualMachineClass, the storage classes and
+// the VirtualMachineImage of a machine before its VM Operator VirtualMachine
+// is created, so that a class or a storage class which cannot be used in the
+// namespace, or an image which is not compatible with the Kubernetes version
+// of the machine, is reported in the conditions of the VSphereMachine instead
+// of failing the creation of the VM later. Nothing is resolved once the
+// VirtualMachine exists, or when VM Operator is not installed.
+func resolveVMResources(ctx *vmware.SupervisorMachineContext) error {
+	vm := &vmoprv1.VirtualMachine{}
+	err := ctx.Client.Get(ctx, client.ObjectKey{Namespace: ctx.Machine.Namespace, Name: ctx.Machine.Name}, vm)
+	switch {
+	case err == nil:
+		return nil
+	case meta.IsNoMatchError(err):
+		return nil
+	case !apierrors.IsNotFound(err):
+		return errors.Wrapf(err, "failed to get VirtualMachine %s/%s", ctx.Machine.Namespace, ctx.Machine.Name)
+	}
+
+	if err := resolveVMClass(ctx); err != nil {
+		return err
+	}
+	if err := resolveVMStorageClasses(ctx); err != nil {
+		return err
+	}
+	return resolveVMImage(ctx)
+}
+
+func resolveVMClass(ctx *vmware.SupervisorMachineContext) error {
+	className := ctx.VSphereMachine.Spec.ClassName
+	vmClass := &vmoprv1.VirtualMachineClass{}
+	if err := ctx.Client.Get(ctx, types.NamespacedName{Name: className}, vmClass); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		if apierrors.IsNotFound(err) {
+			return &vmResolutionError{
+				reason:  vmwarev1.ClassNotFoundReason,
+				message: fmt.Sprintf("VirtualMachineClass %q not found", className),
+			}
+		}
+		return errors.Wrapf(err, "failed to get VirtualMachineClass %s", className)
+	}
+
+	bindings := &vmoprv1.VirtualMachineClassBindingList{}
+	if err := ctx.Client.List(ctx, bindings, client.InNamespace(ctx.VSphereMachine.Namespace)); err != nil {
+		return errors.Wrapf(err, "failed to list VirtualMachineClassBindings in namespace %s", ctx.VSphereMachine.Namespace)
+	}
+	for _, binding := range bindings.Items {
+		if binding.ClassRef.Name == className && binding.DeletionTimestamp.IsZero() {
+			return nil
+		}
+	}
+	return &vmResolutionError{
+		reason:  vmwarev1.ClassNotFoundReason,
+		message: fmt.Sprintf("VirtualMachineClass %q is not bound to namespace %s", className, ctx.VSphereMachine.Namespace),
+	}
+}
+
+func resolveVMStorageClasses(ctx *vmware.SupervisorMachineContext) error {
+	storageClasses := map[string]struct{}{}
+	if ctx.VSphereMachine.Spec.StorageClass != "" {
+		storageClasses[ctx.VSphereMachine.Spec.StorageClass] = struct{}{}
+	}
+	for _, volume := range ctx.VSphereMachine.Spec.Volumes {
+		if volume.StorageClass != "" {
+			storageClasses[volume.StorageClass] = struct{}{}
+		}
+	}
+	unavailable, err := UnavailableStorageClasses(ctx, ctx.Client, ctx.VSphereMachine.Namespace, storageClasses)
+	if err != nil {
+		return err
+	}
+	if len(unavailable) > 0 {
+		return &vmResolutionError{
+			reason:  vmwarev1.StorageClassNotFoundReason,
+			message: fmt.Sprintf("storage classes %s are not part of the storage quotas of namespace %s", strings.Join(unavailable, ", "), ctx.VSphereMachine.Namespace),
+		}
+	}
+	return nil
+}
+
+func resolveVMImage(ctx *vmware.SupervisorMachineContext) error {
+	imageName := ctx.VSphereMachine.Spec.ImageName
+	vmImage := &vmoprv1.VirtualMachineImage{}
+	if err := ctx.Client.Get(ctx, types.NamespacedName{Name: imageName}, vmImage); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		if apierrors.IsNotFound(err) {
+			return &vmResolutionError{
+				reason:  vmwarev1.ImageNotFoundReason,
+				message: fmt.Sprintf("VirtualMachineImage %q not found", imageName),
+			}
+		}
+		return errors.Wrapf(err, "failed to get VirtualMachineImage %s", imageName)
+	}
+
+	if vmImage.Status.ImageSupported != nil && !*vmImage.Status.ImageSupported {
+		return &vmResolutionError{
+			reason:  vmwarev1.ImageIncompatibleReason,
+			message: fmt.Sprintf("VirtualMachineImage %q is not supported by VM Operator", imageName),
+		}
+	}
+
+	// The version of the images of Kubernetes releases is the Kubernetes
+	// version they ship, e.g. "v1.21.6+vmware.1-tkg.1.b3d708a". The images
+	// without a version are not checked.
+	imageVersion := imageKubernetesVersion(vmImage)
+	if imageVersion == nil || ctx.Machine.Spec.Version == nil {
+		return nil
+	}
+	machineVersion, err := version.ParseSemantic(*ctx.Machine.Spec.Version)
+	if err != nil {
+		return nil
+	}
+	if imageVersion.Major() != machineVersion.Major() || imageVersion.Minor() != machineVersion.Minor() || imageVersion.Patch() != machineVersion.Patch() {
+		return &vmResolutionError{
+			reason: vmwarev1.ImageIncompatibleReason,
+			message: fmt.Sprintf("VirtualMachineImage %q ships Kubernetes v%d.%d.%d, the machine requests %s", imageName,
+				imageVersion.Major(), imageVersion.Minor(), imageVersion.Patch(), *ctx.Machine.Spec.Version),
+		}
+	}
+	return nil
+}
+
+// imageKubernetesVersion returns the Kubernetes version of the product of an
+// image, or nil when the image has none.
+func imageKubernetesVersion(vmImage *vmoprv1.VirtualMachineImage) *version.Version {
+	for _, v := range []string{vmImage.Spec.ProductInfo.FullVersion, vmImage.Spec.ProductInfo.Version} {
+		if v == "" {
+			continue
+		}
+		if parsed, err := version.ParseSemantic(v); err == nil {
+			return parsed
+		}
+	}
+	return nil
+}
+
+// UnavailableStorageClasses returns the sorted names of the storage classes
+// which have no storage quota, or a zero storage quota, in the namespace.
+// Nothing is returned when the ResourceQuotas of the namespace do not limit
+// the storage requested from any storage class, as the namespace is then not
+// managed by the supervisor.
+func UnavailableStorageClasses(ctx goctx.Context, c client.Reader, namespace string, storageClasses map[string]struct{}) ([]string, error) {
+	if len(storageClasses) == 0 {
+		return nil, nil
+	}
+
+	quotas := &corev1.ResourceQuotaList{}
+	if err := c.List(ctx, quotas, client.InNamespace(namespace)); err != nil {
+		return nil, errors.Wrapf(err, "failed to list ResourceQuotas in namespace %s", namespace)
+	}
+	// available maps the storage classes with a quota to whether none of
+	// their quotas is zero.
+	available := map[string]bool{}
+	for _, quota := range quotas.Items {
+		for name, quantity := range quota.Spec.Hard {
+			if !strings.HasSuffix(string(name), storageClassQuotaSuffix) {
+				continue
+			}
+			storageClass := strings.TrimSuffix(string(name), storageClassQuotaSuffix)
+			if _, found := available[storageClass]; !found || quantity.IsZero() {
+				available[storageClass] = !quantity.IsZero()
+			}
+		}
+	}
+	if len(available) == 0 {
+		return nil, nil
+	}
+
+	var unavailable []string
+	for storageClass := range storageClasses {
+		if !available[storageClass] {
+			unavailable = append(unavailable, storageClass)
+		}
+	}
+	sort.Strings(unavailable)
+	return unavailable, nil
+}
@@ -21,6 +21,8 @@ import (
 	goctx "context"
 	"encoding/base64"
 	"fmt"
+	"net"
+	"strconv"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -166,6 +168,17 @@ func (v *VmopMachineService) ReconcileNormal(c context.MachineContext) (bool, er
 	// Set the VM state. Will get reset throughout the reconcile
 	ctx.VSphereMachine.Status.VMStatus = vmwarev1.VirtualMachineStatePending
 
+	// Resolve the class, the storage classes and the image of the VM before
+	// it is created, as VM Operator only reports them in an event once the
+	// creation failed.
+	if err := resolveVMResources(ctx); err != nil {
+		var resolutionErr *vmResolutionError
+		if errors.As(err, &resolutionErr) {
+			conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, resolutionErr.reason, clusterv1.ConditionSeverityError, resolutionErr.message)
+		}
+		return false, err
+	}
+
 	// Define the VM Operator VirtualMachine resource to reconcile.
 	vmOperatorVM := v.newVMOperatorVM(ctx)
 
@@ -395,7 +408,7 @@ func (v VmopMachineService) getGuestInfoMetadata(ctx *vmware.SupervisorMachineCo
 	controlPlaneEndpoint := ""
 	if util.IsControlPlaneMachine(ctx.Machine) && !ctx.Cluster.Spec.ControlPlaneEndpoint.IsZero() {
 		apiEndpoint := ctx.Cluster.Spec.ControlPlaneEndpoint
-		controlPlaneEndpoint = fmt.Sprintf("%s:%d", apiEndpoint.Host, apiEndpoint.Port)
+		controlPlaneEndpoint = net.JoinHostPort(apiEndpoint.Host, strconv.Itoa(int(apiEndpoint.Port)))
 	}
 
 	buf := &bytes.Buffer{}
@@ -29,6 +29,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
 
@@ -129,6 +130,14 @@ var _ = Describe("VirtualMachine tests", func() {
 		clusterContext := util.CreateClusterContext(cluster, vsphereCluster)
 		ctx = util.CreateMachineContext(clusterContext, machine, vsphereMachine)
 		ctx.ControllerContext = clusterContext.ControllerContext
+
+		// The class is bound to the namespace and the image is supported.
+		Expect(ctx.Client.Create(ctx, &vmoprv1.VirtualMachineClass{ObjectMeta: metav1.ObjectMeta{Name: className}})).To(Succeed())
+		Expect(ctx.Client.Create(ctx, &vmoprv1.VirtualMachineClassBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: className, Namespace: vsphereMachine.Namespace},
+			ClassRef:   vmoprv1.ClassReference{Kind: "VirtualMachineClass", Name: className},
+		})).To(Succeed())
+		Expect(ctx.Client.Create(ctx, &vmoprv1.VirtualMachineImage{ObjectMeta: metav1.ObjectMeta{Name: imageName}})).To(Succeed())
 	})
 
 	Context("Reconcile VirtualMachine", func() {
@@ -410,6 +419,59 @@ var _ = Describe("VirtualMachine tests", func() {
 			verifyOutput(ctx)
 		})
 
+		Specify("Reconcile Machine with an unresolved class or image", func() {
+			expectReconcileError = true
+			expectBootstrapConfigMap = false
+			expectVMOpVM = false
+			expectedImageName = imageName
+
+			By("VirtualMachineClass doesn't exist")
+			vsphereMachine.Spec.ClassName = "missing-className"
+			expectedConditions = clusterv1.Conditions{{
+				Type:    infrav1.VMProvisionedCondition,
+				Status:  corev1.ConditionFalse,
+				Reason:  vmwarev1.ClassNotFoundReason,
+				Message: "not found",
+			}}
+			requeue, err = vmService.ReconcileNormal(ctx)
+			verifyOutput(ctx)
+
+			By("VirtualMachineClass isn't bound to the namespace")
+			vsphereMachine.Spec.ClassName = "unbound-className"
+			Expect(ctx.Client.Create(ctx, &vmoprv1.VirtualMachineClass{ObjectMeta: metav1.ObjectMeta{Name: "unbound-className"}})).To(Succeed())
+			expectedConditions[0].Message = "is not bound to namespace"
+			requeue, err = vmService.ReconcileNormal(ctx)
+			verifyOutput(ctx)
+
+			By("The storage class is not part of the storage quotas of the namespace")
+			vsphereMachine.Spec.ClassName = className
+			Expect(ctx.Client.Create(ctx, &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "storage-quota", Namespace: vsphereMachine.Namespace},
+				Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{
+					"other-storageClass.storageclass.storage.k8s.io/requests.storage": resource.MustParse("1Ti"),
+				}},
+			})).To(Succeed())
+			expectedConditions[0].Reason = vmwarev1.StorageClassNotFoundReason
+			expectedConditions[0].Message = "storage classes test-storageClass are not part of the storage quotas of namespace"
+			requeue, err = vmService.ReconcileNormal(ctx)
+			verifyOutput(ctx)
+
+			By("VirtualMachineImage ships another Kubernetes version")
+			vsphereMachine.Spec.StorageClass = "other-storageClass"
+			vsphereMachine.Spec.ImageName = "v1.22-imageName"
+			Expect(ctx.Client.Create(ctx, &vmoprv1.VirtualMachineImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "v1.22-imageName"},
+				Spec: vmoprv1.VirtualMachineImageSpec{
+					ProductInfo: vmoprv1.VirtualMachineImageProductInfo{FullVersion: "v1.22.9+vmware.1-tkg.1.cc71bc8"},
+				},
+			})).To(Succeed())
+			machine.Spec.Version = pointer.String("v1.21.6+vmware.1")
+			expectedConditions[0].Reason = vmwarev1.ImageIncompatibleReason
+			expectedConditions[0].Message = "ships Kubernetes v1.22.9, the machine requests v1.21.6+vmware.1"
+			requeue, err = vmService.ReconcileNormal(ctx)
+			verifyOutput(ctx)
+		})
+
 		Specify("Reconcile machine when vm prerequisites check fails", func() {
 			secretName := machine.GetName() + "-data"
 			secret := &corev1.Secret{
@@ -0,0 +1,196 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/audit"
+)
+
+// mutatingMethods are the SOAP methods mutating vCenter which do not create
+// a task, in addition to the methods creating a task.
+var mutatingMethods = map[string]bool{
+	"AddAuthorizationRole":             true,
+	"AddCustomFieldDef":                true,
+	"AnswerVM":                         true,
+	"CreateFolder":                     true,
+	"CreateResourcePool":               true,
+	"CreateVApp":                       true,
+	"GenerateKey":                      true,
+	"MarkAsTemplate":                   true,
+	"MarkAsVirtualMachine":             true,
+	"RemoveAuthorizationRole":          true,
+	"RemoveEntityPermission":           true,
+	"RemoveKey":                        true,
+	"SetCustomValue":                   true,
+	"SetEntityPermissions":             true,
+	"SetField":                         true,
+	"UnregisterVM":                     true,
+	"UpdateAuthorizationRole":          true,
+	"UpdateChildResourceConfiguration": true,
+	"UpdateConfig":                     true,
+}
+
+// isMutatingMethod returns whether a SOAP method mutates vCenter: the
+// methods creating a task, but the searches of the datastores, and the
+// methods of mutatingMethods.
+func isMutatingMethod(method string) bool {
+	if strings.HasSuffix(method, "_Task") {
+		return !strings.HasPrefix(method, "Search")
+	}
+	return mutatingMethods[method]
+}
+
+// isMutatingRequest returns whether a REST request mutates vCenter: the
+// requests other than GET, but the logins and logouts, and the actions
+// listing or getting objects, which the tagging API sends as POST requests.
+func isMutatingRequest(req *http.Request) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead || strings.HasSuffix(req.URL.Path, "/session") {
+		return false
+	}
+	action := restAction(req)
+	return !strings.HasPrefix(action, "list") && !strings.HasPrefix(action, "get")
+}
+
+// restAction returns the action of a REST request, e.g. attach for
+// POST /rest/com/vmware/cis/tagging/tag-association/id:urn?~action=attach.
+func restAction(req *http.Request) string {
+	query := req.URL.Query()
+	if action := query.Get("~action"); action != "" {
+		return action
+	}
+	return query.Get("action")
+}
+
+// auditOwner returns the owner of the operations performed with the
+// context, nil if there is none.
+func auditOwner(ctx context.Context) *audit.Owner {
+	if owner, ok := audit.OwnerFrom(ctx); ok {
+		return &owner
+	}
+	return nil
+}
+
+// auditSOAP records a SOAP request mutating vCenter. A request creating a
+// task is recorded as submitted, its result is recorded by RecordTask when the
+// completion of the task is observed.
+func auditSOAP(ctx context.Context, server, user, method string, req, res soap.HasFault, err error) {
+	a := audit.GetAuditor()
+	if a == nil || !isMutatingMethod(method) {
+		return
+	}
+	record := audit.Record{Server: server, User: user, Operation: method, Result: audit.Succeeded}
+	if target, ok := soapField(req, "Req", "This"); ok {
+		record.Target = target.String()
+	}
+	switch task, ok := soapField(res, "Res", "Returnval"); {
+	case err != nil:
+		record.Result = audit.Failed
+		record.Error = err.Error()
+	case ok && task.Type == "Task":
+		record.Task = task.Value
+		record.Result = audit.Submitted
+	}
+	a.Record(auditOwner(ctx), record)
+}
+
+// auditREST records a REST request mutating vCenter.
+func auditREST(req *http.Request, server, user string, res *http.Response, err error) {
+	a := audit.GetAuditor()
+	if a == nil || !isMutatingRequest(req) {
+		return
+	}
+	record := audit.Record{Server: server, User: user, Operation: fmt.Sprintf("%s %s", req.Method, req.URL.Path), Result: audit.Succeeded}
+	if action := restAction(req); action != "" {
+		record.Operation = fmt.Sprintf("%s %s?action=%s", req.Method, req.URL.Path, action)
+	}
+	switch {
+	case err != nil:
+		record.Result = audit.Failed
+		record.Error = err.Error()
+	case res.StatusCode >= http.StatusBadRequest:
+		record.Result = audit.Failed
+		record.Error = res.Status
+	}
+	a.Record(auditOwner(req.Context()), record)
+}
+
+// soapField returns the managed object reference of the field of the
+// struct a request or response body points to, e.g. the This field of the
+// Req of a request, or the Returnval field of the Res of a response.
+func soapField(body soap.HasFault, field, ref string) (types.ManagedObjectReference, bool) {
+	v := reflect.ValueOf(body)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return types.ManagedObjectReference{}, false
+	}
+	if v = v.FieldByName(field); !v.IsValid() || v.Kind() != reflect.Ptr || v.IsNil() {
+		return types.ManagedObjectReference{}, false
+	}
+	if v = v.Elem().FieldByName(ref); !v.IsValid() {
+		return types.ManagedObjectReference{}, false
+	}
+	moref, ok := v.Interface().(types.ManagedObjectReference)
+	return moref, ok && moref.Value != ""
+}
+
+// RecordMutation records an operation mutating vCenter which is not sent
+// through the SOAP or REST clients of the session, such as an operation of
+// the CNS API, if the operations are audited.
+func (s *Session) RecordMutation(ctx context.Context, operation, target, task string, err error) {
+	a := audit.GetAuditor()
+	if a == nil {
+		return
+	}
+	record := audit.Record{Server: s.URL().Host, User: s.user, Operation: operation, Target: target, Task: task, Result: audit.Succeeded}
+	if err != nil {
+		record.Result = audit.Failed
+		record.Error = err.Error()
+	}
+	a.Record(auditOwner(ctx), record)
+}
+
+// RecordTask records the result of a completed task, if the operations are
+// audited. It is called by the controllers when they observe the completion
+// of the tasks they track, whose submission auditSOAP recorded.
+func (s *Session) RecordTask(ctx context.Context, info types.TaskInfo) {
+	a := audit.GetAuditor()
+	if a == nil || (info.State != types.TaskInfoStateSuccess && info.State != types.TaskInfoStateError) {
+		return
+	}
+	record := audit.Record{Server: s.URL().Host, User: s.user, Operation: info.Name, Task: info.Task.Value, Result: audit.Succeeded}
+	if info.Entity != nil {
+		record.Target = info.Entity.String()
+	}
+	if info.State == types.TaskInfoStateError {
+		record.Result = audit.Failed
+		if info.Error != nil {
+			record.Error = info.Error.LocalizedMessage
+		}
+	}
+	a.Record(auditOwner(ctx), record)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientrecord "k8s.io/client-go/tools/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/audit"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+// memorySink keeps the records sent to it.
+type memorySink struct {
+	mu      sync.Mutex
+	records []audit.Record
+}
+
+func (s *memorySink) Send(_ context.Context, body []byte) error {
+	var r audit.Record
+	if err := json.Unmarshal(body, &r); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, r)
+	return nil
+}
+
+func (s *memorySink) operations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	operations := make([]string, 0, len(s.records))
+	for _, r := range s.records {
+		operations = append(operations, r.Operation)
+	}
+	return operations
+}
+
+func (s *memorySink) find(operation string) audit.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.records {
+		if r.Operation == operation {
+			return r
+		}
+	}
+	return audit.Record{}
+}
+
+func TestAudit(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	model := simulator.VPX()
+	g.Expect(model.Create()).To(Succeed())
+	t.Cleanup(model.Remove)
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	t.Cleanup(server.Close)
+	pass, _ := server.URL.User.Password()
+
+	s, err := GetOrCreate(ctx, NewParams().
+		WithServer(server.URL.Host).
+		WithUserInfo(server.URL.User.Username(), pass).
+		WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	t.Cleanup(func() { Invalidate(server.URL.Host) })
+
+	sink := &memorySink{}
+	audit.SetAuditor(audit.NewAuditor(sink, logr.Discard()))
+	t.Cleanup(func() { audit.SetAuditor(nil) })
+	fakeRecorder := clientrecord.NewFakeRecorder(10)
+	vm := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "vm"}}
+	ownerCtx := audit.WithOwner(ctx, audit.Owner{Object: vm, Kind: "VSphereVM", Controller: "vspherevm-controller", Recorder: record.New(fakeRecorder)})
+
+	// The tasks are recorded when they are submitted, and their result when
+	// their completion is observed.
+	obj, err := s.Finder.VirtualMachine(ctx, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	task, err := obj.Rename(ownerCtx, "renamed")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Eventually(sink.operations).Should(ContainElement("Rename_Task"))
+	renamed := sink.find("Rename_Task")
+	g.Expect(renamed.Server).To(Equal(server.URL.Host))
+	g.Expect(renamed.User).To(Equal(server.URL.User.Username()))
+	g.Expect(renamed.Controller).To(Equal("vspherevm-controller"))
+	g.Expect(renamed.Kind).To(Equal("VSphereVM"))
+	g.Expect(renamed.Namespace).To(Equal("ns"))
+	g.Expect(renamed.Name).To(Equal("vm"))
+	g.Expect(renamed.Target).To(Equal(obj.Reference().String()))
+	g.Expect(renamed.Task).To(Equal(task.Reference().Value))
+	g.Expect(renamed.Result).To(Equal(audit.Submitted))
+	g.Expect(<-fakeRecorder.Events).To(HavePrefix("Normal VCenterMutation Rename_Task on " + obj.Reference().String()))
+
+	info, err := task.WaitForResult(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	s.RecordTask(ownerCtx, *info)
+	g.Expect(<-fakeRecorder.Events).To(Equal(fmt.Sprintf("Normal VCenterMutation %s on %s by %s in %s, task %s: Succeeded",
+		info.Name, obj.Reference(), server.URL.User.Username(), server.URL.Host, task.Reference().Value)))
+	info.State = types.TaskInfoStateError
+	info.Error = &types.LocalizedMethodFault{LocalizedMessage: "access denied"}
+	s.RecordTask(ownerCtx, *info)
+	g.Expect(<-fakeRecorder.Events).To(SatisfyAll(
+		HavePrefix("Warning VCenterMutationFailed "+info.Name+" on "+obj.Reference().String()),
+		HaveSuffix("Failed: access denied")))
+
+	// The failed operations are recorded as warnings.
+	folder, err := s.Finder.Folder(ctx, "/DC0/vm")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = folder.CreateFolder(ownerCtx, "audited")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = folder.CreateFolder(ownerCtx, "audited")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(<-fakeRecorder.Events).To(HavePrefix("Normal VCenterMutation CreateFolder on " + folder.Reference().String()))
+	g.Expect(<-fakeRecorder.Events).To(HavePrefix("Warning VCenterMutationFailed CreateFolder on " + folder.Reference().String()))
+
+	// The mutations of the REST API are recorded, but not the queries; the
+	// operations without owner are only sent to the sink.
+	_, err = s.TagManager.CreateCategory(ctx, &tags.Category{Name: "audited", Cardinality: "SINGLE"})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = s.TagManager.GetCategories(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Eventually(sink.operations).Should(ContainElement(HavePrefix("POST /rest/com/vmware/cis/tagging/category")))
+	g.Expect(sink.operations()).To(HaveLen(6))
+	g.Expect(fakeRecorder.Events).To(BeEmpty())
+}
+
+func TestIsMutatingRequest(t *testing.T) {
+	request := func(method, rawURL string) *http.Request {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &http.Request{Method: method, URL: u}
+	}
+	tests := []struct {
+		req      *http.Request
+		mutating bool
+	}{
+		{request(http.MethodPost, "/rest/com/vmware/cis/tagging/tag"), true},
+		{request(http.MethodPost, "/rest/com/vmware/cis/tagging/tag-association/id:urn?~action=attach"), true},
+		{request(http.MethodDelete, "/api/vcenter/cluster/modules/id"), true},
+		{request(http.MethodGet, "/rest/com/vmware/cis/tagging/tag"), false},
+		{request(http.MethodPost, "/rest/com/vmware/cis/tagging/tag-association?~action=list-attached-tags"), false},
+		{request(http.MethodPost, "/rest/com/vmware/cis/tagging/category/id:urn?~action=get"), false},
+		{request(http.MethodPost, "/rest/com/vmware/cis/session"), false},
+	}
+	for _, tt := range tests {
+		if got := isMutatingRequest(tt.req); got != tt.mutating {
+			t.Errorf("isMutatingRequest(%s %s) = %t, want %t", tt.req.Method, tt.req.URL, got, tt.mutating)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// inventoryTypes are the types of the objects held in the inventory cache:
+// the datastores, networks and VMs resolved by the cache, and the folders and
+// datacenters of their inventory paths.
+var inventoryTypes = []string{
+	"Folder",
+	"StoragePod",
+	"Datacenter",
+	"Datastore",
+	"Network",
+	"DistributedVirtualPortgroup",
+	"OpaqueNetwork",
+	"VirtualMachine",
+}
+
+const (
+	// inventoryPageSize is the maximum number of objects of an update of the
+	// inventory, which splits the initial fill of a large inventory into
+	// several pages.
+	inventoryPageSize = 1000
+
+	// inventoryRetryInterval is the delay before the updates of the
+	// inventory are watched again after an error.
+	inventoryRetryInterval = 30 * time.Second
+)
+
+// inventoryKind is a kind of object resolved by the inventory cache, as the
+// Finder resolves it: from the folder of the datacenter holding its kind.
+type inventoryKind struct {
+	name   string
+	folder string
+	types  []string
+}
+
+var (
+	datastoreKind      = inventoryKind{name: "datastore", folder: "datastore", types: []string{"Datastore"}}
+	networkKind        = inventoryKind{name: "network", folder: "network", types: []string{"Network", "DistributedVirtualPortgroup", "OpaqueNetwork"}}
+	virtualMachineKind = inventoryKind{name: "vm", folder: "vm", types: []string{"VirtualMachine"}}
+)
+
+func (k inventoryKind) matches(ref types.ManagedObjectReference) bool {
+	for _, t := range k.types {
+		if ref.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// inventoryObject is an object of the inventory cache.
+type inventoryObject struct {
+	name   string
+	parent *types.ManagedObjectReference
+}
+
+// inventoryCache holds the names and the parents of the objects of the
+// inventory of a vCenter. It is filled, then kept up to date, by the updates
+// of a property collector watching a view of the whole inventory, so that
+// the objects are resolved without a request to vCenter.
+type inventoryCache struct {
+	server string
+	logger logr.Logger
+	// done is closed once the updates of the inventory are no longer
+	// watched.
+	done chan struct{}
+
+	mu      sync.RWMutex
+	objects map[types.ManagedObjectReference]inventoryObject
+	byName  map[string]map[types.ManagedObjectReference]struct{}
+	synced  bool
+	root    types.ManagedObjectReference
+}
+
+func newInventoryCache(server string, logger logr.Logger) *inventoryCache {
+	return &inventoryCache{
+		server:  server,
+		logger:  logger,
+		done:    make(chan struct{}),
+		objects: map[types.ManagedObjectReference]inventoryObject{},
+		byName:  map[string]map[types.ManagedObjectReference]struct{}{},
+	}
+}
+
+// run watches the updates of the inventory until the context is cancelled.
+// The watch is started again after an error, and the cache is not used until
+// it is filled again.
+func (c *inventoryCache) run(ctx context.Context, client *vim25.Client) {
+	defer close(c.done)
+	for {
+		err := c.watch(ctx, client)
+		c.mu.Lock()
+		c.synced = false
+		c.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		c.logger.Error(err, "failed to watch the vCenter inventory, watching it again", "retryAfter", inventoryRetryInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(inventoryRetryInterval):
+		}
+	}
+}
+
+// watch fills the cache and applies the updates of the inventory, until the
+// context is cancelled or the property collector fails.
+func (c *inventoryCache) watch(ctx context.Context, client *vim25.Client) error {
+	v, err := view.NewManager(client).CreateContainerView(ctx, client.ServiceContent.RootFolder, inventoryTypes, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = v.Destroy(context.Background())
+	}()
+
+	filter := new(property.WaitFilter)
+	filter.Options = &types.WaitOptions{MaxObjectUpdates: inventoryPageSize}
+	filter.Spec.ObjectSet = []types.ObjectSpec{{
+		Obj:  v.Reference(),
+		Skip: types.NewBool(true),
+		SelectSet: []types.BaseSelectionSpec{
+			&types.TraversalSpec{Type: "ContainerView", Path: "view"},
+		},
+	}}
+	for _, t := range inventoryTypes {
+		filter.Spec.PropSet = append(filter.Spec.PropSet, types.PropertySpec{Type: t, PathSet: []string{"name", "parent"}})
+	}
+
+	c.mu.Lock()
+	c.root = client.ServiceContent.RootFolder
+	c.objects = map[types.ManagedObjectReference]inventoryObject{}
+	c.byName = map[string]map[types.ManagedObjectReference]struct{}{}
+	c.mu.Unlock()
+
+	return property.WaitForUpdates(ctx, property.DefaultCollector(client), filter, func(updates []types.ObjectUpdate) bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, update := range updates {
+			c.apply(update)
+		}
+		if !c.synced && !filter.Truncated {
+			c.synced = true
+			c.logger.V(2).Info("filled the vCenter inventory cache", "objects", len(c.objects))
+		}
+		return false
+	})
+}
+
+// apply applies an update of an object to the cache.
+func (c *inventoryCache) apply(update types.ObjectUpdate) {
+	current, ok := c.objects[update.Obj]
+	if ok {
+		c.unindex(update.Obj, current.name)
+	}
+	if update.Kind == types.ObjectUpdateKindLeave {
+		delete(c.objects, update.Obj)
+		return
+	}
+	for _, change := range update.ChangeSet {
+		switch change.Name {
+		case "name":
+			current.name, _ = change.Val.(string)
+		case "parent":
+			if parent, ok := change.Val.(types.ManagedObjectReference); ok {
+				current.parent = &parent
+			} else {
+				current.parent = nil
+			}
+		}
+	}
+	c.objects[update.Obj] = current
+	if c.byName[current.name] == nil {
+		c.byName[current.name] = map[types.ManagedObjectReference]struct{}{}
+	}
+	c.byName[current.name][update.Obj] = struct{}{}
+}
+
+func (c *inventoryCache) unindex(ref types.ManagedObjectReference, name string) {
+	delete(c.byName[name], ref)
+	if len(c.byName[name]) == 0 {
+		delete(c.byName, name)
+	}
+}
+
+// inventoryPath returns the inventory path of an object, e.g.
+// /DC0/datastore/LocalDS_0. It returns false when an ancestor of the object
+// is not in the cache, e.g. the vApp of a VM.
+func (c *inventoryCache) inventoryPath(ref types.ManagedObjectReference) (string, bool) {
+	var names []string
+	// The root folder is not part of the view, nor of the inventory paths.
+	for ref != c.root {
+		obj, ok := c.objects[ref]
+		if !ok || obj.parent == nil {
+			return "", false
+		}
+		names = append([]string{obj.name}, names...)
+		ref = *obj.parent
+	}
+	return "/" + strings.Join(names, "/"), true
+}
+
+// lookup returns the object of a kind found at a path of the datacenter, and
+// its inventory path, as the Finder finds it: a name is searched in the
+// folder of the kind and its sub-folders, a relative path from the folder of
+// the kind, and an absolute path from the root folder. It returns false when
+// the cache cannot resolve the path as the Finder, e.g. when it is not
+// filled, when the path is a pattern, or when it matches no or several
+// objects, so that the Finder is used instead.
+func (c *inventoryCache) lookup(kind inventoryKind, datacenterPath, p string) (types.ManagedObjectReference, string, bool) {
+	if c == nil || datacenterPath == "" || p == "" || strings.ContainsAny(p, "*?[\\") || strings.Contains(p, "..") || strings.HasPrefix(p, ".") {
+		return types.ManagedObjectReference{}, "", false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.synced {
+		return types.ManagedObjectReference{}, "", false
+	}
+
+	folder := path.Join(datacenterPath, kind.folder)
+	var want func(string) bool
+	switch {
+	case strings.HasPrefix(p, "/"):
+		want = func(inventoryPath string) bool { return inventoryPath == path.Clean(p) }
+	case strings.Contains(p, "/"):
+		want = func(inventoryPath string) bool { return inventoryPath == path.Join(folder, p) }
+	default:
+		want = func(inventoryPath string) bool { return strings.HasPrefix(inventoryPath, folder+"/") }
+	}
+
+	var (
+		found         types.ManagedObjectReference
+		foundPath     string
+		matches       int
+		name          = path.Base(p)
+		inventoryPath string
+		ok            bool
+	)
+	for ref := range c.byName[name] {
+		if !kind.matches(ref) {
+			continue
+		}
+		if inventoryPath, ok = c.inventoryPath(ref); !ok || !want(inventoryPath) {
+			continue
+		}
+		found, foundPath = ref, inventoryPath
+		matches++
+	}
+	if matches != 1 {
+		return types.ManagedObjectReference{}, "", false
+	}
+	return found, foundPath, true
+}
+
+// lookupInventory resolves a path of the datacenter of the session from the
+// inventory cache of its vCenter, and counts the hits and misses of the
+// cache.
+func (s *Session) lookupInventory(kind inventoryKind, p string) (types.ManagedObjectReference, string, bool) {
+	if s.inventory == nil || s.datacenter == nil {
+		return types.ManagedObjectReference{}, "", false
+	}
+	ref, inventoryPath, ok := s.inventory.lookup(kind, s.datacenter.InventoryPath, p)
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	inventoryLookupsTotal.WithLabelValues(s.inventory.server, kind.name, result).Inc()
+	return ref, inventoryPath, ok
+}
+
+// FindDatastore finds a datastore of the datacenter of the session, as
+// Finder.Datastore. The datastore is resolved from the inventory cache of
+// its vCenter when the InventoryCache feature gate is enabled.
+func (s *Session) FindDatastore(ctx context.Context, p string) (*object.Datastore, error) {
+	if ref, inventoryPath, ok := s.lookupInventory(datastoreKind, p); ok {
+		datastore := object.NewDatastore(s.Client.Client, ref)
+		datastore.InventoryPath = inventoryPath
+		datastore.DatacenterPath = s.datacenter.InventoryPath
+		return datastore, nil
+	}
+	return s.Finder.Datastore(ctx, p)
+}
+
+// FindNetwork finds a network of the datacenter of the session, as
+// Finder.Network. The network is resolved from the inventory cache of its
+// vCenter when the InventoryCache feature gate is enabled.
+func (s *Session) FindNetwork(ctx context.Context, p string) (object.NetworkReference, error) {
+	if ref, inventoryPath, ok := s.lookupInventory(networkKind, p); ok {
+		if network, ok := object.NewReference(s.Client.Client, ref).(object.NetworkReference); ok {
+			if common, ok := network.(interface{ SetInventoryPath(string) }); ok {
+				common.SetInventoryPath(inventoryPath)
+			}
+			return network, nil
+		}
+	}
+	return s.Finder.Network(ctx, p)
+}
+
+// FindVirtualMachine finds a VM or a template of the datacenter of the
+// session, as Finder.VirtualMachine. The VM is resolved from the inventory
+// cache of its vCenter when the InventoryCache feature gate is enabled.
+func (s *Session) FindVirtualMachine(ctx context.Context, p string) (*object.VirtualMachine, error) {
+	if ref, inventoryPath, ok := s.lookupInventory(virtualMachineKind, p); ok {
+		vm := object.NewVirtualMachine(s.Client.Client, ref)
+		vm.InventoryPath = inventoryPath
+		return vm, nil
+	}
+	return s.Finder.VirtualMachine(ctx, p)
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
+)
+
+func TestInventoryCache(t *testing.T) {
+	defer featuregatetesting.SetFeatureGateDuringTest(t, feature.Gates, feature.InventoryCache, true)()
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	model := simulator.VPX()
+	g.Expect(model.Create()).To(Succeed())
+	t.Cleanup(model.Remove)
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	t.Cleanup(server.Close)
+	pass, _ := server.URL.User.Password()
+
+	s, err := GetOrCreate(ctx, NewParams().
+		WithServer(server.URL.Host).
+		WithUserInfo(server.URL.User.Username(), pass).
+		WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.inventory).NotTo(BeNil())
+	t.Cleanup(func() {
+		// The watch of the inventory stops once its client is logged out.
+		Invalidate(server.URL.Host)
+		<-s.inventory.done
+	})
+	g.Eventually(func() bool {
+		s.inventory.mu.RLock()
+		defer s.inventory.mu.RUnlock()
+		return s.inventory.synced
+	}).Should(BeTrue())
+	hits := func(kind string) float64 {
+		return testutil.ToFloat64(inventoryLookupsTotal.WithLabelValues(server.URL.Host, kind, "hit"))
+	}
+
+	// The datastores, networks and VMs are resolved from the cache, by name,
+	// relative and absolute path.
+	datastore, err := s.FindDatastore(ctx, "LocalDS_0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(datastore.InventoryPath).To(Equal("/DC0/datastore/LocalDS_0"))
+	g.Expect(datastore.DatacenterPath).To(Equal("/DC0"))
+	expected, err := s.Finder.Datastore(ctx, "LocalDS_0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(datastore.Reference()).To(Equal(expected.Reference()))
+	g.Expect(hits("datastore")).To(Equal(1.0))
+
+	network, err := s.FindNetwork(ctx, "DC0_DVPG0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(network.GetInventoryPath()).To(Equal("/DC0/network/DC0_DVPG0"))
+	g.Expect(network.Reference().Type).To(Equal("DistributedVirtualPortgroup"))
+	_, err = s.FindNetwork(ctx, "/DC0/network/VM Network")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(hits("network")).To(Equal(2.0))
+
+	vm, err := s.FindVirtualMachine(ctx, "DC0_H0_VM0")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vm.InventoryPath).To(Equal("/DC0/vm/DC0_H0_VM0"))
+	g.Expect(hits("vm")).To(Equal(1.0))
+
+	// The patterns and the objects not found are resolved by the finder.
+	_, err = s.FindVirtualMachine(ctx, "DC0_H0_*")
+	g.Expect(err).To(HaveOccurred())
+	_, err = s.FindDatastore(ctx, "missing")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(hits("vm")).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(inventoryLookupsTotal.WithLabelValues(server.URL.Host, "vm", "miss"))).To(Equal(1.0))
+
+	// The cache is kept up to date.
+	task, err := vm.Rename(ctx, "renamed")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(task.Wait(ctx)).To(Succeed())
+	g.Eventually(func() string {
+		renamed, _, _ := s.inventory.lookup(virtualMachineKind, "/DC0", "renamed")
+		return renamed.Value
+	}).Should(Equal(vm.Reference().Value))
+}
+
+func TestInventoryCacheLookup(t *testing.T) {
+	ref := func(kind, value string) types.ManagedObjectReference {
+		return types.ManagedObjectReference{Type: kind, Value: value}
+	}
+	root := ref("Folder", "root")
+	c := newInventoryCache("vcenter", logr.Discard())
+	c.root = root
+	for _, update := range []struct {
+		ref    types.ManagedObjectReference
+		name   string
+		parent types.ManagedObjectReference
+	}{
+		{ref("Datacenter", "dc0"), "DC0", root},
+		{ref("Folder", "ds0"), "datastore", ref("Datacenter", "dc0")},
+		{ref("Folder", "sub"), "sub", ref("Folder", "ds0")},
+		{ref("Datastore", "a"), "ds", ref("Folder", "ds0")},
+		{ref("Datastore", "b"), "ds", ref("Folder", "sub")},
+		{ref("Datastore", "c"), "other", ref("Folder", "sub")},
+	} {
+		c.apply(types.ObjectUpdate{Kind: types.ObjectUpdateKindEnter, Obj: update.ref, ChangeSet: []types.PropertyChange{
+			{Name: "name", Val: update.name},
+			{Name: "parent", Val: update.parent},
+		}})
+	}
+
+	tests := []struct {
+		name     string
+		p        string
+		expected string
+	}{
+		{name: "unique name in a sub-folder", p: "other", expected: "c"},
+		{name: "ambiguous name", p: "ds"},
+		{name: "relative path", p: "sub/ds", expected: "b"},
+		{name: "absolute path", p: "/DC0/datastore/ds", expected: "a"},
+		{name: "pattern", p: "oth*"},
+		{name: "missing", p: "missing"},
+	}
+
+	g := NewWithT(t)
+	_, _, ok := c.lookup(datastoreKind, "/DC0", "other")
+	g.Expect(ok).To(BeFalse(), "the cache is not used until it is filled")
+	c.synced = true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			found, _, ok := c.lookup(datastoreKind, "/DC0", tt.p)
+			g.Expect(ok).To(Equal(tt.expected != ""))
+			g.Expect(found.Value).To(Equal(tt.expected))
+		})
+	}
+
+	// The objects removed from the inventory are removed from the cache.
+	c.apply(types.ObjectUpdate{Kind: types.ObjectUpdateKindLeave, Obj: ref("Datastore", "c")})
+	_, _, ok = c.lookup(datastoreKind, "/DC0", "other")
+	g.Expect(ok).To(BeFalse())
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// activeSessions is the number of pooled clients logged in each vCenter.
+	activeSessions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capv_vcenter_active_sessions",
+			Help: "Number of pooled client sessions logged in vCenter, by vCenter.",
+		},
+		[]string{"server"},
+	)
+
+	// loginFailuresTotal counts the failed logins in each vCenter.
+	loginFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capv_vcenter_login_failures_total",
+			Help: "Total number of failed logins in vCenter, by vCenter.",
+		},
+		[]string{"server"},
+	)
+
+	// requestDuration observes the round trip latency of the requests to each
+	// vCenter, by API.
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "capv_vcenter_request_duration_seconds",
+			Help:    "Round trip latency of the requests to vCenter, by vCenter and API (soap or rest).",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"server", "api"},
+	)
+
+	// tasksTotal counts the tasks issued to each vCenter, by type.
+	tasksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capv_vcenter_tasks_total",
+			Help: "Total number of tasks issued to vCenter, by vCenter and type.",
+		},
+		[]string{"server", "type"},
+	)
+
+	// inventoryLookupsTotal counts the lookups of the inventory cache of each
+	// vCenter, by kind of object and result.
+	inventoryLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capv_vcenter_inventory_cache_lookups_total",
+			Help: "Total number of lookups of the vCenter inventory cache, by vCenter, kind (datastore, network or vm) and result (hit, or miss when the lookup is sent to vCenter).",
+		},
+		[]string{"server", "kind", "result"},
+	)
+
+	// taskDuration observes the time from the queueing to the completion of
+	// the tasks tracked by the VSphereVMs, by type and final state.
+	taskDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "capv_vcenter_task_duration_seconds",
+			Help:    "Time from the queueing to the completion of the vCenter tasks tracked by the VSphereVMs, by vCenter, type and state (success or error).",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+		},
+		[]string{"server", "type", "state"},
+	)
+)
+
+// taskTypes are the values of the type label of the task metrics, by the
+// name of the method creating the task. The tasks of the other methods have
+// the other type.
+var taskTypes = map[string]string{
+	"CloneVM_Task":        "clone",
+	"ReconfigVM_Task":     "reconfigure",
+	"PowerOnVM_Task":      "poweron",
+	"PowerOffVM_Task":     "poweroff",
+	"Destroy_Task":        "destroy",
+	"CreateSnapshot_Task": "snapshot",
+	"RelocateVM_Task":     "relocate",
+}
+
+func init() {
+	metrics.Registry.MustRegister(activeSessions, loginFailuresTotal, requestDuration, tasksTotal, taskDuration, inventoryLookupsTotal)
+}
+
+// taskType returns the value of the type label of the task created by the
+// method.
+func taskType(method string) string {
+	if t, ok := taskTypes[method]; ok {
+		return t
+	}
+	return "other"
+}
+
+// ObserveTask observes the duration of a task of the vCenter which completed.
+// It must be called once per task, when it is first seen complete.
+func ObserveTask(server string, info types.TaskInfo) {
+	if info.CompleteTime == nil {
+		return
+	}
+	taskDuration.WithLabelValues(server, taskType(info.Name), string(info.State)).Observe(info.CompleteTime.Sub(info.QueueTime).Seconds())
+}
+
+// soapRoundTripper limits the number of concurrent SOAP requests to a vCenter,
+// observes their latency, counts the tasks they issue and audits the requests
+// mutating vCenter.
+type soapRoundTripper struct {
+	soap.RoundTripper
+	server  string
+	limiter chan struct{}
+
+	// host and user are the vCenter and the user of the audit records.
+	host string
+	user string
+}
+
+// RoundTrip implements soap.RoundTripper.
+func (rt *soapRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	// The long polls of the property collector would hold a request slot for
+	// as long as they wait, and their duration is not a latency.
+	if _, ok := req.(*methods.WaitForUpdatesExBody); ok {
+		return rt.RoundTripper.RoundTrip(ctx, req, res)
+	}
+
+	release, err := acquire(ctx, rt.limiter)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = rt.RoundTripper.RoundTrip(ctx, req, res)
+	// The slot is released before the request is audited, so that a slow
+	// audit does not hold back the other requests.
+	release()
+	requestDuration.WithLabelValues(rt.server, "soap").Observe(time.Since(start).Seconds())
+	method := soapMethod(req)
+	if err == nil && strings.HasSuffix(method, "_Task") {
+		tasksTotal.WithLabelValues(rt.server, taskType(method)).Inc()
+	}
+	auditSOAP(ctx, rt.host, rt.user, method, req, res, err)
+	return err
+}
+
+// soapMethod returns the name of the method of a SOAP request, whose body is
+// of the type named after the method with the Body suffix.
+func soapMethod(req soap.HasFault) string {
+	t := reflect.TypeOf(req)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.TrimSuffix(t.Name(), "Body")
+}
+
+// restRoundTripper limits the number of concurrent REST requests to a
+// vCenter, observes their latency and audits the requests mutating vCenter.
+type restRoundTripper struct {
+	http.RoundTripper
+	server  string
+	limiter chan struct{}
+
+	// host and user are the vCenter and the user of the audit records.
+	host string
+	user string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *restRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := acquire(req.Context(), rt.limiter)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := rt.RoundTripper.RoundTrip(req)
+	release()
+	requestDuration.WithLabelValues(rt.server, "rest").Observe(time.Since(start).Seconds())
+	auditREST(req, rt.host, rt.user, res, err)
+	return res, err
+}
+
+// acquire waits for a request slot of the limiter, and returns the function
+// releasing it. Requests are not limited if the limiter is nil.
+func acquire(ctx context.Context, limiter chan struct{}) (func(), error) {
+	if limiter == nil {
+		return func() {}, nil
+	}
+	select {
+	case limiter <- struct{}{}:
+		return func() { <-limiter }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
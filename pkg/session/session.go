@@ -18,6 +18,11 @@ package session
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
 	"net/url"
 	"sync"
 	"time"
@@ -28,6 +33,7 @@ import (
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/session/keepalive"
 	"github.com/vmware/govmomi/vapi/rest"
 	"github.com/vmware/govmomi/vapi/tags"
 	"github.com/vmware/govmomi/vim25"
@@ -36,24 +42,50 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/feature"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/constants"
 )
 
 var sessionCache = map[string]Session{}
 
+// clientPool caches the clients of each vCenter, user and credentials, which
+// are shared by the sessions of all the datacenters of the vCenter.
+var clientPool = map[string]*pooledClient{}
+
+// serverLimiters limits the number of concurrent requests to each vCenter.
+var serverLimiters = map[string]chan struct{}{}
+
 var sessionMU sync.Mutex
 
+// logoutTimeout is the timeout of the logout of an evicted client.
+const logoutTimeout = 10 * time.Second
+
+// staleClientTimeout is the time after which a client of a vCenter and user
+// which is no longer used, e.g. because its password or CA bundle was
+// rotated, is evicted from the pool.
+const staleClientTimeout = 10 * time.Minute
+
 // Session is a vSphere session with a configured Finder.
 type Session struct {
 	*govmomi.Client
 	Finder     *find.Finder
 	datacenter *object.Datacenter
 	TagManager *tags.Manager
+	inventory  *inventoryCache
+
+	// user is the user the session is logged in with, in the records of the
+	// operations it audits.
+	user string
 }
 
 type Feature struct {
 	EnableKeepAlive   bool
 	KeepAliveDuration time.Duration
+
+	// MaxConcurrentRequests is the maximum number of concurrent requests to
+	// a vCenter, shared by all its sessions. The limit of a vCenter is set by
+	// its first session, and the requests are not limited if it is not set.
+	MaxConcurrentRequests int
 }
 
 func DefaultFeature() Feature {
@@ -67,6 +99,7 @@ type Params struct {
 	datacenter string
 	userinfo   *url.Userinfo
 	thumbprint string
+	caBundle   []byte
 	feature    Feature
 }
 
@@ -96,59 +129,86 @@ func (p *Params) WithThumbprint(thumbprint string) *Params {
 	return p
 }
 
+// WithCABundle sets the PEM-encoded CA certificates against which the
+// certificate of the server is verified, instead of the system roots.
+func (p *Params) WithCABundle(caBundle []byte) *Params {
+	p.caBundle = caBundle
+	return p
+}
+
 func (p *Params) WithFeatures(feature Feature) *Params {
 	p.feature = feature
 	return p
 }
 
+// pooledClient is a SOAP client logged in vCenter, and the REST client of the
+// tagging API created from it.
+type pooledClient struct {
+	server     string
+	user       *url.Userinfo
+	client     *govmomi.Client
+	restClient *rest.Client
+	tagManager *tags.Manager
+
+	soapKeepAlive *keepalive.HandlerSOAP
+	restKeepAlive *keepalive.HandlerREST
+
+	inventory     *inventoryCache
+	stopInventory context.CancelFunc
+
+	// lastUsed is the last time a session was got with the client.
+	lastUsed time.Time
+}
+
 // GetOrCreate gets a cached session or creates a new one if one does not
-// already exist.
+// already exist. The sessions of the datacenters of a vCenter share the
+// clients logged in with the same user, so that they count as a single
+// session against the session limit of vCenter.
 func GetOrCreate(ctx context.Context, params *Params) (*Session, error) {
-	logger := ctrl.LoggerFrom(ctx).WithName("session")
+	logger := ctrl.LoggerFrom(ctx).WithName("session").WithValues("server", params.server, "datacenter", params.datacenter)
 	sessionMU.Lock()
 	defer sessionMU.Unlock()
 
-	sessionKey := params.server + params.userinfo.Username() + params.datacenter
-	if cachedSession, ok := sessionCache[sessionKey]; ok {
-		logger = logger.WithValues("server", params.server, "datacenter", params.datacenter)
-		// if keepalive is enabled we depend upon roundtripper to reestablish the connection
-		// and remove the key if it could not
-		if params.feature.EnableKeepAlive {
-			return &cachedSession, nil
+	// The clients logged in with another password, or verifying the server
+	// with another thumbprint or CA bundle, are pooled apart, so that the
+	// clusters of a vCenter and user with different credentials do not
+	// replace each other's client on every reconcile.
+	poolKey := newPoolKey(params)
+	sessionKey := poolKey + params.datacenter
+	now := time.Now()
+	evictStaleLocked(logger, poolKey, params, now)
+
+	pc, ok := clientPool[poolKey]
+	// if keepalive is enabled we depend upon the keepalive handlers to
+	// evict the clients whose session could not be kept alive
+	if ok && !params.feature.EnableKeepAlive {
+		if err := pc.checkActive(ctx); err != nil {
+			logger.Info("vSphere client session is no longer active, logging in again", "reason", err.Error())
+			evictLocked(poolKey, pc)
+			ok = false
 		}
+	}
+	if !ok {
 		var err error
-		if ok, err = cachedSession.SessionManager.SessionIsActive(ctx); ok {
-			logger.V(2).Info("found active cached vSphere client session")
-			return &cachedSession, nil
+		if pc, err = newPooledClient(ctx, logger, poolKey, params); err != nil {
+			loginFailuresTotal.WithLabelValues(params.server).Inc()
+			return nil, err
 		}
-		logger.V(2).Error(err, "error checking if session is active")
-	}
-
-	soapURL, err := soap.ParseURL(params.server)
-	if err != nil {
-		return nil, errors.Wrapf(err, "error parsing vSphere URL %q", params.server)
-	}
-	if soapURL == nil {
-		return nil, errors.Errorf("error parsing vSphere URL %q", params.server)
+		clientPool[poolKey] = pc
+		activeSessions.WithLabelValues(params.server).Inc()
+		logger.V(2).Info("pooled vSphere client session")
 	}
+	pc.lastUsed = now
 
-	soapURL.User = params.userinfo
-	client, err := newClient(ctx, logger, sessionKey, soapURL, params.thumbprint, params.feature)
-	if err != nil {
-		return nil, err
+	if cachedSession, ok := sessionCache[sessionKey]; ok && cachedSession.Client == pc.client {
+		logger.V(2).Info("found cached vSphere client session")
+		return &cachedSession, nil
 	}
 
-	session := Session{Client: client}
-	session.UserAgent = v1beta1.GroupVersion.String()
+	session := Session{Client: pc.client, TagManager: pc.tagManager, inventory: pc.inventory, user: pc.user.Username()}
 
 	// Assign the finder to the session.
 	session.Finder = find.NewFinder(session.Client.Client, false)
-	// Assign tag manager to the session.
-	manager, err := newManager(ctx, client.Client, soapURL.User)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to create tags manager")
-	}
-	session.TagManager = manager
 
 	// Assign the datacenter if one was specified.
 	if params.datacenter != "" {
@@ -162,65 +222,260 @@ func GetOrCreate(ctx context.Context, params *Params) (*Session, error) {
 	// Cache the session.
 	sessionCache[sessionKey] = session
 
-	logger.V(2).Info("cached vSphere client session", "server", params.server, "datacenter", params.datacenter)
+	logger.V(2).Info("cached vSphere client session")
 
 	return &session, nil
 }
 
-func newClient(ctx context.Context, logger logr.Logger, sessionKey string, url *url.URL, thumbprint string, feature Feature) (*govmomi.Client, error) {
-	insecure := thumbprint == ""
+func newPooledClient(ctx context.Context, logger logr.Logger, poolKey string, params *Params) (*pooledClient, error) {
+	soapURL, err := soap.ParseURL(params.server)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing vSphere URL %q", params.server)
+	}
+	if soapURL == nil {
+		return nil, errors.Errorf("error parsing vSphere URL %q", params.server)
+	}
+	soapURL.User = params.userinfo
+
+	if params.feature.MaxConcurrentRequests > 0 {
+		if _, ok := serverLimiters[params.server]; !ok {
+			serverLimiters[params.server] = make(chan struct{}, params.feature.MaxConcurrentRequests)
+		}
+	}
+
+	pc := &pooledClient{server: params.server, user: params.userinfo}
+	if err := pc.newClient(ctx, logger, poolKey, soapURL, params); err != nil {
+		return nil, err
+	}
+	if err := pc.newManager(ctx, logger, poolKey, soapURL.User, params); err != nil {
+		pc.logout()
+		return nil, errors.Wrap(err, "unable to create tags manager")
+	}
+	if feature.Gates.Enabled(feature.InventoryCache) {
+		pc.startInventory(logger)
+	}
+	return pc, nil
+}
+
+func (pc *pooledClient) newClient(ctx context.Context, logger logr.Logger, poolKey string, url *url.URL, params *Params) error {
+	insecure := params.thumbprint == "" && len(params.caBundle) == 0
 	soapClient := soap.NewClient(url, insecure)
-	if !insecure {
-		soapClient.SetThumbprint(url.Host, thumbprint)
+	if len(params.caBundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(params.caBundle) {
+			return errors.Errorf("no PEM-encoded certificate found in the CA bundle of %q", params.server)
+		}
+		soapClient.DefaultTransport().TLSClientConfig.RootCAs = pool
+	}
+	if params.thumbprint != "" {
+		soapClient.SetThumbprint(url.Host, params.thumbprint)
 	}
+	soapClient.UserAgent = v1beta1.GroupVersion.String()
 
 	vimClient, err := vim25.NewClient(ctx, soapClient)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	c := &govmomi.Client{
-		Client:         vimClient,
-		SessionManager: session.NewManager(vimClient),
+	var roundTripper soap.RoundTripper = &soapRoundTripper{
+		RoundTripper: soapClient,
+		server:       params.server,
+		limiter:      serverLimiters[params.server],
+		host:         url.Host,
+		user:         url.User.Username(),
 	}
+	vimClient.RoundTripper = roundTripper
 
-	if feature.EnableKeepAlive {
-		vimClient.RoundTripper = session.KeepAliveHandler(vimClient.RoundTripper, feature.KeepAliveDuration, func(tripper soap.RoundTripper) error {
+	if params.feature.EnableKeepAlive {
+		pc.soapKeepAlive = keepalive.NewHandlerSOAP(roundTripper, params.feature.KeepAliveDuration, func() error {
 			// we tried implementing
 			// c.Login here but the client once logged out
 			// keeps errong in invalid username or password
 			// we tried with cached username and password in session still the error persisted
-			// hence we just clear the cache and expect the client to
-			// be recreated in next GetOrCreate call
-			_, err := methods.GetCurrentTime(ctx, tripper)
+			// hence we just evict the client and expect a new one to
+			// be created in next GetOrCreate call
+			_, err := methods.GetCurrentTime(context.Background(), roundTripper)
 			if err != nil {
 				logger.Error(err, "failed to keep alive govmomi client")
-				clearCache(sessionKey)
+				evict(poolKey, pc)
 			}
 			return err
 		})
+		vimClient.RoundTripper = pc.soapKeepAlive
 	}
 
-	if err := c.Login(ctx, url.User); err != nil {
-		return nil, err
+	pc.client = &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
+	return pc.client.Login(ctx, url.User)
+}
+
+// newManager creates a Manager that encompasses the REST Client for the VSphere tagging API.
+// The REST client shares the transport of the SOAP client.
+func (pc *pooledClient) newManager(ctx context.Context, logger logr.Logger, poolKey string, user *url.Userinfo, params *Params) error {
+	rc := rest.NewClient(pc.client.Client)
+	transport := rc.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	rc.Transport = &restRoundTripper{
+		RoundTripper: transport,
+		server:       params.server,
+		limiter:      serverLimiters[params.server],
+		host:         pc.client.URL().Host,
+		user:         user.Username(),
+	}
+
+	if params.feature.EnableKeepAlive {
+		pc.restKeepAlive = keepalive.NewHandlerREST(rc, params.feature.KeepAliveDuration, func() error {
+			s, err := rc.Session(context.Background())
+			if err == nil && s == nil {
+				err = errors.New("session is not authenticated")
+			}
+			if err != nil {
+				logger.Error(err, "failed to keep alive the REST client")
+				evict(poolKey, pc)
+			}
+			return err
+		})
+		rc.Transport = pc.restKeepAlive
 	}
 
-	return c, nil
+	if err := rc.Login(ctx, user); err != nil {
+		return err
+	}
+	pc.restClient = rc
+	pc.tagManager = tags.NewManager(rc)
+	return nil
+}
+
+// checkActive returns an error if the SOAP session is no longer active. The
+// REST session, which expires on its own, is logged in again if it expired.
+func (pc *pooledClient) checkActive(ctx context.Context) error {
+	ok, err := pc.client.SessionManager.SessionIsActive(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("session is not active")
+	}
+
+	s, err := pc.restClient.Session(ctx)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		ctrl.LoggerFrom(ctx).WithName("session").Info("REST session expired, logging in again")
+		return pc.restClient.Login(ctx, pc.user)
+	}
+	return nil
 }
 
-func clearCache(sessionKey string) {
+// startInventory starts to fill the inventory cache of the vCenter of the
+// client, and to keep it up to date until the client is logged out.
+func (pc *pooledClient) startInventory(logger logr.Logger) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pc.inventory = newInventoryCache(pc.server, logger.WithName("inventory"))
+	pc.stopInventory = cancel
+	go pc.inventory.run(ctx, pc.client.Client)
+}
+
+// logout logs the client out of vCenter on a best effort basis, which stops
+// its keepalive handlers. It does not wait for the logout, as it may be called
+// by a keepalive handler.
+func (pc *pooledClient) logout() {
+	go func() {
+		// The watch of the inventory is cancelled before the logout, which
+		// would fail its cancellation.
+		if pc.stopInventory != nil {
+			pc.stopInventory()
+			<-pc.inventory.done
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), logoutTimeout)
+		defer cancel()
+		if pc.restClient != nil {
+			_ = pc.restClient.Logout(ctx)
+		}
+		if pc.client != nil {
+			_ = pc.client.Logout(ctx)
+		}
+	}()
+}
+
+// newPoolKey returns the key of the pooled client of the params, made of the
+// vCenter, the user and a hash of the password, the thumbprint and the CA
+// bundle.
+func newPoolKey(params *Params) string {
+	password, _ := params.userinfo.Password()
+	h := sha256.New()
+	for _, v := range [][]byte{[]byte(password), []byte(params.thumbprint), params.caBundle} {
+		_ = binary.Write(h, binary.BigEndian, uint64(len(v)))
+		_, _ = h.Write(v)
+	}
+	return params.server + params.userinfo.Username() + "#" + hex.EncodeToString(h.Sum(nil))
+}
+
+// evictStaleLocked evicts the clients of the vCenter and user of the params
+// logged in with other credentials which were not used for the
+// staleClientTimeout, e.g. the client of a password which has since been
+// rotated, instead of keeping them until their session expires.
+func evictStaleLocked(logger logr.Logger, poolKey string, params *Params, now time.Time) {
+	for key, pc := range clientPool {
+		if key == poolKey || pc.server != params.server || pc.user.Username() != params.userinfo.Username() {
+			continue
+		}
+		if now.Sub(pc.lastUsed) > staleClientTimeout {
+			logger.Info("evicting the vSphere client of credentials which are no longer used")
+			evictLocked(key, pc)
+		}
+	}
+}
+
+// Invalidate evicts the clients of a vCenter from the pool, with the sessions
+// using them, and logs them out, e.g. once its credentials are rotated. The
+// next GetOrCreate logs in again.
+func Invalidate(server string) {
 	sessionMU.Lock()
 	defer sessionMU.Unlock()
-	delete(sessionCache, sessionKey)
+	for poolKey, pc := range clientPool {
+		if pc.server == server {
+			evictLocked(poolKey, pc)
+		}
+	}
 }
 
-// newManager creates a Manager that encompasses the REST Client for the VSphere tagging API.
-func newManager(ctx context.Context, client *vim25.Client, user *url.Userinfo) (*tags.Manager, error) {
-	rc := rest.NewClient(client)
-	if err := rc.Login(ctx, user); err != nil {
-		return nil, err
+func evict(poolKey string, pc *pooledClient) {
+	sessionMU.Lock()
+	defer sessionMU.Unlock()
+	evictLocked(poolKey, pc)
+}
+
+// evictLocked removes a client from the pool, with the sessions using it, and
+// logs it out. Nothing is evicted if the client was already replaced.
+func evictLocked(poolKey string, pc *pooledClient) {
+	if clientPool[poolKey] != pc {
+		return
+	}
+	delete(clientPool, poolKey)
+	for key, s := range sessionCache {
+		if s.Client == pc.client {
+			delete(sessionCache, key)
+		}
+	}
+	activeSessions.WithLabelValues(pc.server).Dec()
+	pc.logout()
+}
+
+// WithDatacenter returns a session sharing the client of the session, whose
+// Finder is scoped to another datacenter of the same vCenter.
+func (s *Session) WithDatacenter(ctx context.Context, datacenter string) (*Session, error) {
+	finder := find.NewFinder(s.Client.Client, false)
+	dc, err := finder.Datacenter(ctx, datacenter)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find datacenter %q", datacenter)
 	}
-	return tags.NewManager(rc), nil
+	finder.SetDatacenter(dc)
+	return &Session{Client: s.Client, Finder: finder, datacenter: dc, TagManager: s.TagManager, inventory: s.inventory, user: s.user}, nil
 }
 
 // FindByBIOSUUID finds an object by its BIOS UUID.
@@ -0,0 +1,262 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+
+	// run init func to register the tagging API endpoints.
+	_ "github.com/vmware/govmomi/vapi/simulator"
+)
+
+func TestGetOrCreate(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	model := simulator.VPX()
+	model.Datacenter = 2
+	g.Expect(model.Create()).To(Succeed())
+	t.Cleanup(model.Remove)
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	t.Cleanup(server.Close)
+	pass, _ := server.URL.User.Password()
+	params := func() *Params {
+		return NewParams().
+			WithServer(server.URL.Host).
+			WithUserInfo(server.URL.User.Username(), pass)
+	}
+
+	// The sessions of the datacenters share the clients of the vCenter.
+	dc0, err := GetOrCreate(ctx, params().WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	dc1, err := GetOrCreate(ctx, params().WithDatacenter("DC1"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(dc1.Client).To(BeIdenticalTo(dc0.Client))
+	g.Expect(dc1.TagManager).To(BeIdenticalTo(dc0.TagManager))
+	g.Expect(dc1.datacenter.Name()).To(Equal("DC1"))
+	g.Expect(testutil.ToFloat64(activeSessions.WithLabelValues(server.URL.Host))).To(Equal(1.0))
+
+	// The cached sessions are reused.
+	cached, err := GetOrCreate(ctx, params().WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cached.Client).To(BeIdenticalTo(dc0.Client))
+	g.Expect(cached.Finder).To(BeIdenticalTo(dc0.Finder))
+
+	// The client of a session which is no longer active is replaced.
+	g.Expect(dc0.Client.Logout(ctx)).To(Succeed())
+	renewed, err := GetOrCreate(ctx, params().WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(renewed.Client).NotTo(BeIdenticalTo(dc0.Client))
+	g.Expect(renewed.TagManager).NotTo(BeIdenticalTo(dc0.TagManager))
+	g.Expect(testutil.ToFloat64(activeSessions.WithLabelValues(server.URL.Host))).To(Equal(1.0))
+	_, err = renewed.TagManager.GetCategories(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The client logged in with another password is pooled apart, and the
+	// client of the previous password is evicted once it is no longer used.
+	rotatedParams := func() *Params {
+		return NewParams().
+			WithServer(server.URL.Host).
+			WithUserInfo(server.URL.User.Username(), "rotated").
+			WithDatacenter("DC0")
+	}
+	rotated, err := GetOrCreate(ctx, rotatedParams())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rotated.Client).NotTo(BeIdenticalTo(renewed.Client))
+	g.Expect(testutil.ToFloat64(activeSessions.WithLabelValues(server.URL.Host))).To(Equal(2.0))
+	previous, err := GetOrCreate(ctx, params().WithDatacenter("DC0"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(previous.Client).To(BeIdenticalTo(renewed.Client))
+	sessionMU.Lock()
+	clientPool[newPoolKey(params())].lastUsed = time.Now().Add(-2 * staleClientTimeout)
+	sessionMU.Unlock()
+	rotated, err = GetOrCreate(ctx, rotatedParams())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testutil.ToFloat64(activeSessions.WithLabelValues(server.URL.Host))).To(Equal(1.0))
+	g.Expect(clientPool).NotTo(HaveKey(newPoolKey(params())))
+
+	// The invalidated clients of the vCenter are logged in again.
+	Invalidate(server.URL.Host)
+	g.Expect(testutil.ToFloat64(activeSessions.WithLabelValues(server.URL.Host))).To(Equal(0.0))
+	invalidated, err := GetOrCreate(ctx, rotatedParams())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(invalidated.Client).NotTo(BeIdenticalTo(rotated.Client))
+
+	// The failed logins are counted.
+	_, err = GetOrCreate(ctx, NewParams().WithServer(server.URL.Host).WithUserInfo("", ""))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(testutil.ToFloat64(loginFailuresTotal.WithLabelValues(server.URL.Host))).To(Equal(1.0))
+	g.Expect(testutil.CollectAndCount(requestDuration)).To(BeNumerically(">", 0))
+}
+
+func TestGetOrCreateWithCABundle(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	model := simulator.VPX()
+	g.Expect(model.Create()).To(Succeed())
+	t.Cleanup(model.Remove)
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	t.Cleanup(server.Close)
+	pass, _ := server.URL.User.Password()
+	params := func(caBundle []byte) *Params {
+		return NewParams().
+			WithServer(server.URL.Host).
+			WithUserInfo(server.URL.User.Username(), pass).
+			WithCABundle(caBundle)
+	}
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	// The certificate of the server is verified with the CA bundle.
+	verified, err := GetOrCreate(ctx, params(caBundle))
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = verified.TagManager.GetCategories(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The server is not trusted by another CA, and the client of the CA
+	// bundle is kept meanwhile.
+	_, err = GetOrCreate(ctx, params(otherCABundle(t)))
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("certificate"))
+
+	_, err = GetOrCreate(ctx, params([]byte("not a certificate")))
+	g.Expect(err).To(MatchError(ContainSubstring("no PEM-encoded certificate")))
+
+	kept, err := GetOrCreate(ctx, params(caBundle))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(kept.Client).To(BeIdenticalTo(verified.Client))
+
+	// The client verifying the server with a thumbprint too is pooled apart
+	// from the client verifying it with the CA bundle only.
+	thumbprint, err := GetOrCreate(ctx, params(caBundle).WithThumbprint(soap.ThumbprintSHA1(server.Certificate())))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(thumbprint.Client).NotTo(BeIdenticalTo(verified.Client))
+	again, err := GetOrCreate(ctx, params(caBundle))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(again.Client).To(BeIdenticalTo(verified.Client))
+}
+
+// otherCABundle returns the PEM-encoded certificate of a self-signed CA.
+func otherCABundle(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestAcquire(t *testing.T) {
+	g := NewWithT(t)
+
+	// The requests are not limited without a limiter.
+	release, err := acquire(context.Background(), nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	release()
+
+	limiter := make(chan struct{}, 1)
+	release, err = acquire(context.Background(), limiter)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The requests wait for a free slot until their context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = acquire(ctx, limiter)
+	g.Expect(err).To(MatchError(context.DeadlineExceeded))
+
+	release()
+	release, err = acquire(context.Background(), limiter)
+	g.Expect(err).NotTo(HaveOccurred())
+	release()
+}
+
+type nopRoundTripper struct{}
+
+func (nopRoundTripper) RoundTrip(context.Context, soap.HasFault, soap.HasFault) error { return nil }
+
+func TestTaskMetrics(t *testing.T) {
+	g := NewWithT(t)
+	rt := &soapRoundTripper{RoundTripper: nopRoundTripper{}, server: "vcenter"}
+
+	// The tasks issued are counted by type.
+	g.Expect(rt.RoundTrip(context.Background(), &methods.CloneVM_TaskBody{}, &methods.CloneVM_TaskBody{})).To(Succeed())
+	g.Expect(rt.RoundTrip(context.Background(), &methods.MarkAsTemplateBody{}, &methods.MarkAsTemplateBody{})).To(Succeed())
+	g.Expect(rt.RoundTrip(context.Background(), &methods.UpgradeVM_TaskBody{}, &methods.UpgradeVM_TaskBody{})).To(Succeed())
+	g.Expect(testutil.ToFloat64(tasksTotal.WithLabelValues("vcenter", "clone"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(tasksTotal.WithLabelValues("vcenter", "other"))).To(Equal(1.0))
+
+	// The duration of the completed tasks is observed.
+	queued := time.Now()
+	completed := queued.Add(time.Minute)
+	ObserveTask("vcenter", types.TaskInfo{Name: "PowerOnVM_Task", State: types.TaskInfoStateRunning, QueueTime: queued})
+	g.Expect(testutil.CollectAndCount(taskDuration)).To(Equal(0))
+	ObserveTask("vcenter", types.TaskInfo{Name: "PowerOnVM_Task", State: types.TaskInfoStateSuccess, QueueTime: queued, CompleteTime: &completed})
+	g.Expect(testutil.CollectAndCompare(taskDuration, strings.NewReader(`
+# HELP capv_vcenter_task_duration_seconds Time from the queueing to the completion of the vCenter tasks tracked by the VSphereVMs, by vCenter, type and state (success or error).
+# TYPE capv_vcenter_task_duration_seconds histogram
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="1"} 0
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="2"} 0
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="4"} 0
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="8"} 0
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="16"} 0
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="32"} 0
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="64"} 1
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="128"} 1
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="256"} 1
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="512"} 1
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="1024"} 1
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="2048"} 1
+capv_vcenter_task_duration_seconds_bucket{server="vcenter",state="success",type="poweron",le="+Inf"} 1
+capv_vcenter_task_duration_seconds_sum{server="vcenter",state="success",type="poweron"} 60
+capv_vcenter_task_duration_seconds_count{server="vcenter",state="success",type="poweron"} 1
+`))).To(Succeed())
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package throttle
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// inFlight is the number of slots held by the VSphereVMs, by vCenter and
+	// operation.
+	inFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capv_vcenter_operations_in_flight",
+			Help: "Number of throttled vCenter tasks issued by the VSphereVMs and not yet complete, by vCenter and operation.",
+		},
+		[]string{"server", "operation"},
+	)
+
+	// waiting is the number of VSphereVMs waiting for a slot, by vCenter and
+	// operation.
+	waiting = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "capv_vcenter_operations_waiting",
+			Help: "Number of VSphereVMs waiting to issue a throttled vCenter task, by vCenter and operation.",
+		},
+		[]string{"server", "operation"},
+	)
+
+	// throttledTotal counts the tasks which VSphereVMs were denied to issue.
+	throttledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "capv_vcenter_operations_throttled_total",
+			Help: "Total number of times a VSphereVM was denied to issue a vCenter task, by vCenter and operation.",
+		},
+		[]string{"server", "operation"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(inFlight, waiting, throttledTotal)
+}
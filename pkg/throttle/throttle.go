@@ -0,0 +1,239 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package throttle limits the number of concurrent tasks of each operation
+// which the VSphereVMs issue to a vCenter, so that large scale-outs do not
+// overwhelm vCenter with concurrent clones, and shares the tasks of each
+// vCenter fairly between the clusters whose VSphereVMs wait for them.
+//
+// The VSphereVMs are not blocked while they wait: a VSphereVM which is denied
+// a slot is reconciled again after RequeueAfter, and its wait is recorded so
+// that the slots released in between go to the clusters holding fewer slots
+// than their fair share.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Operation is a kind of vCenter task whose concurrency is limited.
+type Operation string
+
+const (
+	// Clone is the clone of a VM.
+	Clone Operation = "clone"
+
+	// Reconfigure is the reconfiguration of a VM, e.g. of its extraConfig or
+	// of its resources.
+	Reconfigure Operation = "reconfigure"
+
+	// Power is the power on or the power off of a VM.
+	Power Operation = "power"
+)
+
+// Limits are the maximum numbers of concurrent tasks of each operation
+// issued to each vCenter. The tasks of an operation without a positive limit
+// are not throttled.
+type Limits map[Operation]int
+
+// RequeueAfter is the delay after which a VSphereVM waiting for a slot is
+// reconciled again.
+const RequeueAfter = 15 * time.Second
+
+const (
+	// slotTimeout is the age after which a slot is released although its
+	// task was never seen complete, e.g. when its VSphereVM was deleted.
+	slotTimeout = time.Hour
+
+	// waitTimeout is the duration after which a VSphereVM which no longer
+	// asked for a slot stops waiting.
+	waitTimeout = 4 * RequeueAfter
+)
+
+// holding is a slot held, or waited for, by a VSphereVM. since is the time
+// the slot was acquired, or the last time the VSphereVM asked for it.
+type holding struct {
+	cluster string
+	since   time.Time
+}
+
+// queue tracks the slots of an operation of a vCenter.
+type queue struct {
+	slots   map[string]holding
+	waiting map[string]holding
+}
+
+type queueKey struct {
+	server    string
+	operation Operation
+}
+
+// Throttle limits the concurrent tasks of the VSphereVMs, by vCenter and
+// operation. A nil Throttle throttles no task.
+type Throttle struct {
+	mu     sync.Mutex
+	limits Limits
+	queues map[queueKey]*queue
+	now    func() time.Time
+}
+
+// New returns a Throttle for the limits. It returns nil when no operation is
+// limited.
+func New(limits Limits) *Throttle {
+	t := &Throttle{limits: Limits{}, queues: map[queueKey]*queue{}, now: time.Now}
+	for operation, limit := range limits {
+		if limit > 0 {
+			t.limits[operation] = limit
+		}
+	}
+	if len(t.limits) == 0 {
+		return nil
+	}
+	return t
+}
+
+// Acquire returns true when the holder, a VSphereVM of the cluster, may issue
+// a task of the operation to the server. The holder then holds a slot until
+// it is released, and a holder which already holds a slot of the operation
+// keeps it. Otherwise the holder waits for a slot, which is denied while the
+// server runs as many tasks of the operation as its limit, or while the
+// cluster holds its fair share of the slots and another cluster, holding
+// fewer, waits for one.
+func (t *Throttle) Acquire(server string, operation Operation, cluster, holder string) bool {
+	if t == nil {
+		return true
+	}
+	limit, ok := t.limits[operation]
+	if !ok {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := queueKey{server: server, operation: operation}
+	q := t.queue(key)
+	defer t.observe(key, q)
+	now := t.now()
+	q.expire(now)
+	if _, ok := q.slots[holder]; ok {
+		return true
+	}
+
+	if len(q.slots) >= limit || !q.fair(cluster, limit) {
+		q.waiting[holder] = holding{cluster: cluster, since: now}
+		throttledTotal.WithLabelValues(server, string(operation)).Inc()
+		return false
+	}
+	delete(q.waiting, holder)
+	q.slots[holder] = holding{cluster: cluster, since: now}
+	return true
+}
+
+// Release releases the slots of the server held by the holder, once its task
+// completed or when it no longer tracks a task.
+func (t *Throttle) Release(server, holder string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, q := range t.queues {
+		if key.server != server {
+			continue
+		}
+		if _, ok := q.slots[holder]; ok {
+			delete(q.slots, holder)
+			t.observe(key, q)
+		}
+	}
+}
+
+// Waiting returns true when the holder waits for a slot of the server.
+func (t *Throttle) Waiting(server, holder string) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, q := range t.queues {
+		if _, ok := q.waiting[holder]; ok && key.server == server {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Throttle) queue(key queueKey) *queue {
+	q, ok := t.queues[key]
+	if !ok {
+		q = &queue{slots: map[string]holding{}, waiting: map[string]holding{}}
+		t.queues[key] = q
+	}
+	return q
+}
+
+// observe updates the metrics of a queue.
+func (t *Throttle) observe(key queueKey, q *queue) {
+	inFlight.WithLabelValues(key.server, string(key.operation)).Set(float64(len(q.slots)))
+	waiting.WithLabelValues(key.server, string(key.operation)).Set(float64(len(q.waiting)))
+}
+
+// expire releases the slots held for longer than slotTimeout, and stops the
+// waits of the holders which did not ask for a slot within waitTimeout.
+func (q *queue) expire(now time.Time) {
+	for holder, h := range q.slots {
+		if now.Sub(h.since) > slotTimeout {
+			delete(q.slots, holder)
+		}
+	}
+	for holder, h := range q.waiting {
+		if now.Sub(h.since) > waitTimeout {
+			delete(q.waiting, holder)
+		}
+	}
+}
+
+// fair returns true when a slot may go to the cluster: unless the cluster
+// holds its fair share of the limit, i.e. the limit divided by the number of
+// clusters holding or waiting for slots, while another cluster holding fewer
+// than its share waits for one.
+func (q *queue) fair(cluster string, limit int) bool {
+	held := map[string]int{cluster: 0}
+	for _, h := range q.slots {
+		held[h.cluster]++
+	}
+	for _, h := range q.waiting {
+		if _, ok := held[h.cluster]; !ok {
+			held[h.cluster] = 0
+		}
+	}
+	share := (limit + len(held) - 1) / len(held)
+	if held[cluster] < share {
+		return true
+	}
+	for _, h := range q.waiting {
+		if h.cluster != cluster && held[h.cluster] < share {
+			return false
+		}
+	}
+	return true
+}
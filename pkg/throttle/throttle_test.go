@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestThrottle(t *testing.T) {
+	const server = "vcenter.example.com"
+
+	newThrottle := func(limit int) (*Throttle, *time.Time) {
+		now := time.Now()
+		throttle := New(Limits{Clone: limit})
+		throttle.now = func() time.Time { return now }
+		return throttle, &now
+	}
+
+	t.Run("does not throttle without limits", func(t *testing.T) {
+		g := NewWithT(t)
+
+		g.Expect(New(Limits{Clone: 0})).To(BeNil())
+		var throttle *Throttle
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "vm-1")).To(BeTrue())
+		g.Expect(throttle.Waiting(server, "vm-1")).To(BeFalse())
+		throttle.Release(server, "vm-1")
+	})
+
+	t.Run("limits the concurrent tasks of an operation", func(t *testing.T) {
+		g := NewWithT(t)
+		throttle, _ := newThrottle(2)
+
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "vm-1")).To(BeTrue())
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "vm-2")).To(BeTrue())
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "vm-1")).To(BeTrue())
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "vm-3")).To(BeFalse())
+		g.Expect(throttle.Waiting(server, "vm-3")).To(BeTrue())
+
+		// The other operations and vCenters are not limited.
+		g.Expect(throttle.Acquire(server, Power, "ns/a", "vm-3")).To(BeTrue())
+		g.Expect(throttle.Acquire("other.example.com", Clone, "ns/a", "vm-3")).To(BeTrue())
+
+		throttle.Release(server, "vm-1")
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "vm-3")).To(BeTrue())
+		g.Expect(throttle.Waiting(server, "vm-3")).To(BeFalse())
+	})
+
+	t.Run("shares the slots between the waiting clusters", func(t *testing.T) {
+		g := NewWithT(t)
+		throttle, _ := newThrottle(4)
+
+		for _, vm := range []string{"a-1", "a-2", "a-3", "a-4"} {
+			g.Expect(throttle.Acquire(server, Clone, "ns/a", vm)).To(BeTrue())
+		}
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "a-5")).To(BeFalse())
+		g.Expect(throttle.Acquire(server, Clone, "ns/b", "b-1")).To(BeFalse())
+
+		// The released slot goes to the cluster holding fewer slots than its
+		// share, instead of the next VM of the cluster holding them all.
+		throttle.Release(server, "a-1")
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "a-5")).To(BeFalse())
+		g.Expect(throttle.Acquire(server, Clone, "ns/b", "b-1")).To(BeTrue())
+
+		throttle.Release(server, "a-2")
+		g.Expect(throttle.Acquire(server, Clone, "ns/b", "b-2")).To(BeTrue())
+
+		// The VMs of the cluster b wait while it holds its share and the
+		// cluster a does not.
+		throttle.Release(server, "a-3")
+		g.Expect(throttle.Acquire(server, Clone, "ns/b", "b-3")).To(BeFalse())
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "a-5")).To(BeTrue())
+	})
+
+	t.Run("expires the slots and the waits", func(t *testing.T) {
+		g := NewWithT(t)
+		throttle, now := newThrottle(1)
+
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "vm-1")).To(BeTrue())
+		g.Expect(throttle.Acquire(server, Clone, "ns/b", "vm-2")).To(BeFalse())
+
+		*now = now.Add(waitTimeout + time.Second)
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "vm-3")).To(BeFalse())
+		g.Expect(throttle.Waiting(server, "vm-2")).To(BeFalse())
+
+		*now = now.Add(slotTimeout)
+		g.Expect(throttle.Acquire(server, Clone, "ns/a", "vm-3")).To(BeTrue())
+	})
+}
@@ -39,6 +39,86 @@ network:
       dhcp4: {{ $net.DHCP4 }}
       dhcp6: {{ $net.DHCP6 }}
       {{- end }}
+      {{- if $net.SLAAC }}
+      accept-ra: true
+      {{- end }}
+      {{- if workload $net }}
+      {{- if $net.DHCP4 }}
+      dhcp4-overrides:
+        use-routes: false
+      {{- end }}
+      {{- if $net.DHCP6 }}
+      dhcp6-overrides:
+        use-routes: false
+      {{- end }}
+      {{- end }}
+      {{- if $net.IPAddrs }}
+      addresses:
+      {{- range $net.IPAddrs }}
+      - "{{ . }}"
+      {{- end }}
+      {{- end }}
+      {{- if $net.Gateway4 }}
+      gateway4: "{{ $net.Gateway4 }}"
+      {{- end }}
+      {{- if $net.Gateway6 }}
+      gateway6: "{{ $net.Gateway6 }}"
+      {{- end }}
+      {{- if .MTU }}
+      mtu: {{ .MTU }}
+      {{- end }}
+      {{- if .Routes }}
+      routes:
+      {{- range .Routes }}
+      - to: "{{ .To }}"
+        via: "{{ .Via }}"
+        metric: {{ .Metric }}
+      {{- end }}
+      {{- end }}
+      {{- if nameservers $net }}
+      nameservers:
+        {{- if $net.Nameservers }}
+        addresses:
+        {{- range $net.Nameservers }}
+        - "{{ . }}"
+        {{- end }}
+        {{- end }}
+        {{- if $net.SearchDomains }}
+        search:
+        {{- range $net.SearchDomains }}
+        - "{{ . }}"
+        {{- end }}
+        {{- end }}
+      {{- end }}
+    {{- end }}
+  {{- if .Routes }}
+  routes:
+  {{- range .Routes }}
+  - to: "{{ .To }}"
+    via: "{{ .Via }}"
+    metric: {{ .Metric }}
+  {{- end }}
+  {{- end }}
+`
+
+// windowsMetadataFormat is the metadata of a Windows guest, read by the VMware
+// guestinfo service of Cloudbase-Init. Cloudbase-Init neither renames the
+// network adapters nor waits for the network, and always installs the routes
+// of DHCP.
+const windowsMetadataFormat = `
+instance-id: "{{ .Hostname }}"
+local-hostname: "{{ .Hostname }}"
+network:
+  version: 2
+  ethernets:
+    {{- range $i, $net := .Devices }}
+    id{{ $i }}:
+      match:
+        macaddress: "{{ $net.MACAddr }}"
+      {{- if or $net.DHCP4 $net.DHCP6 }}
+      dhcp4: {{ $net.DHCP4 }}
+      dhcp6: {{ $net.DHCP6 }}
+      {{- end }}
       {{- if $net.IPAddrs }}
       addresses:
       {{- range $net.IPAddrs }}
@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/external"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IsControlPlaneUpgradeInProgress returns true if the control plane referenced by
+// the cluster is rolling out new machines. The control plane object is read
+// as unstructured data and inspected for the KubeadmControlPlane
+// MachinesSpecUpToDate condition, so other control plane providers reporting
+// the same condition are supported as well.
+func IsControlPlaneUpgradeInProgress(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (bool, error) {
+	if cluster == nil || cluster.Spec.ControlPlaneRef == nil {
+		return false, nil
+	}
+
+	controlPlane, err := external.Get(ctx, c, cluster.Spec.ControlPlaneRef, cluster.Namespace)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get control plane for cluster %s/%s", cluster.Namespace, cluster.Name)
+	}
+
+	getter := conditions.UnstructuredGetter(controlPlane)
+	return conditions.IsFalse(getter, controlplanev1.MachinesSpecUpToDateCondition) &&
+		conditions.GetReason(getter, controlplanev1.MachinesSpecUpToDateCondition) == controlplanev1.RollingUpdateInProgressReason, nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	controlplanev1 "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsControlPlaneUpgradeInProgress(t *testing.T) {
+	newKCP := func(c *clusterv1.Condition) *controlplanev1.KubeadmControlPlane {
+		kcp := &controlplanev1.KubeadmControlPlane{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: controlplanev1.GroupVersion.String(),
+				Kind:       "KubeadmControlPlane",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-kcp",
+				Namespace: "test-ns",
+			},
+		}
+		if c != nil {
+			conditions.Set(kcp, c)
+		}
+		return kcp
+	}
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cluster",
+			Namespace: "test-ns",
+		},
+		Spec: clusterv1.ClusterSpec{
+			ControlPlaneRef: &corev1.ObjectReference{
+				APIVersion: controlplanev1.GroupVersion.String(),
+				Kind:       "KubeadmControlPlane",
+				Name:       "test-kcp",
+				Namespace:  "test-ns",
+			},
+		},
+	}
+
+	cases := []struct {
+		name         string
+		kcp          *controlplanev1.KubeadmControlPlane
+		expectedResp bool
+	}{
+		{
+			name:         "no condition",
+			kcp:          newKCP(nil),
+			expectedResp: false,
+		},
+		{
+			name:         "machines up to date",
+			kcp:          newKCP(conditions.TrueCondition(controlplanev1.MachinesSpecUpToDateCondition)),
+			expectedResp: false,
+		},
+		{
+			name: "rolling update in progress",
+			kcp: newKCP(conditions.FalseCondition(controlplanev1.MachinesSpecUpToDateCondition,
+				controlplanev1.RollingUpdateInProgressReason, clusterv1.ConditionSeverityWarning, "")),
+			expectedResp: true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(controlplanev1.AddToScheme(scheme)).To(Succeed())
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.kcp).Build()
+
+			actualResp, err := IsControlPlaneUpgradeInProgress(context.Background(), c, cluster)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(actualResp).To(Equal(tc.expectedResp))
+		})
+	}
+
+	t.Run("no control plane ref", func(t *testing.T) {
+		g := NewWithT(t)
+		c := fake.NewClientBuilder().Build()
+		actualResp, err := IsControlPlaneUpgradeInProgress(context.Background(), c, &clusterv1.Cluster{})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(actualResp).To(BeFalse())
+	})
+}
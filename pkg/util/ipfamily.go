@@ -0,0 +1,171 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// IPFamilies are the IP families of a network configuration.
+type IPFamilies struct {
+	IPv4 bool
+	IPv6 bool
+}
+
+// String returns the families, e.g. "IPv4,IPv6".
+func (f IPFamilies) String() string {
+	var families []string
+	if f.IPv4 {
+		families = append(families, "IPv4")
+	}
+	if f.IPv6 {
+		families = append(families, "IPv6")
+	}
+	if len(families) == 0 {
+		return "none"
+	}
+	return strings.Join(families, ",")
+}
+
+func (f *IPFamilies) add(ip net.IP) {
+	if ip.To4() != nil {
+		f.IPv4 = true
+	} else {
+		f.IPv6 = true
+	}
+}
+
+// GetClusterIPFamilies returns the IP families of the pod and service CIDRs
+// of a cluster, and whether IPv6 is its primary family, which is the family
+// of its first pod CIDR, or of its first service CIDR without pod CIDRs. A
+// cluster without CIDRs has the primary family of its control plane endpoint
+// when it is an IP address.
+func GetClusterIPFamilies(cluster *clusterv1.Cluster) (IPFamilies, bool, error) {
+	var (
+		families    IPFamilies
+		primaryIPv6 bool
+		primarySet  bool
+		blocks      []string
+	)
+	if cluster == nil {
+		return families, false, nil
+	}
+	if clusterNetwork := cluster.Spec.ClusterNetwork; clusterNetwork != nil {
+		if clusterNetwork.Pods != nil {
+			blocks = append(blocks, clusterNetwork.Pods.CIDRBlocks...)
+		}
+		if clusterNetwork.Services != nil {
+			blocks = append(blocks, clusterNetwork.Services.CIDRBlocks...)
+		}
+	}
+	for _, block := range blocks {
+		ip, _, err := net.ParseCIDR(block)
+		if err != nil {
+			return families, false, errors.Wrapf(err, "invalid CIDR %s of the cluster network", block)
+		}
+		families.add(ip)
+		if !primarySet {
+			primaryIPv6, primarySet = ip.To4() == nil, true
+		}
+	}
+	if ip := net.ParseIP(cluster.Spec.ControlPlaneEndpoint.Host); !primarySet && ip != nil {
+		primaryIPv6 = ip.To4() == nil
+	}
+	return families, primaryIPv6, nil
+}
+
+// GetMachineIPFamilies returns the IP families configured on the network
+// devices of a machine, by their static addresses, DHCP and SLAAC. The
+// families are not known when a device claims addresses from IPAM pools,
+// until they are claimed, nor for a machine without network devices.
+func GetMachineIPFamilies(devices []infrav1.NetworkDeviceSpec) (IPFamilies, bool) {
+	var families IPFamilies
+	if len(devices) == 0 {
+		return families, false
+	}
+	for _, device := range devices {
+		if len(device.AddressesFromPools) > 0 {
+			return families, false
+		}
+		for _, addr := range device.IPAddrs {
+			if ip, _, err := net.ParseCIDR(addr); err == nil {
+				families.add(ip)
+			}
+		}
+		families.IPv4 = families.IPv4 || device.DHCP4
+		families.IPv6 = families.IPv6 || device.DHCP6 || device.SLAAC
+	}
+	return families, true
+}
+
+// ValidateMachineIPFamilies returns an error if the network devices of a
+// machine are not configured with every IP family of the pod and service
+// CIDRs of its cluster, or, for a control plane machine, with the family of
+// the control plane endpoint when it is an IP address. The families of the
+// devices claiming addresses from IPAM pools are not validated.
+func ValidateMachineIPFamilies(cluster *clusterv1.Cluster, devices []infrav1.NetworkDeviceSpec, controlPlane bool) error {
+	machineFamilies, known := GetMachineIPFamilies(devices)
+	if !known {
+		return nil
+	}
+	clusterFamilies, _, err := GetClusterIPFamilies(cluster)
+	if err != nil {
+		return err
+	}
+	if clusterFamilies.IPv4 && !machineFamilies.IPv4 || clusterFamilies.IPv6 && !machineFamilies.IPv6 {
+		return errors.Errorf("the network of the machine is configured with %s, but the pod and service CIDRs of the cluster require %s", machineFamilies, clusterFamilies)
+	}
+	if controlPlane && cluster != nil {
+		if ip := net.ParseIP(cluster.Spec.ControlPlaneEndpoint.Host); ip != nil {
+			var endpointFamilies IPFamilies
+			endpointFamilies.add(ip)
+			if endpointFamilies.IPv4 && !machineFamilies.IPv4 || endpointFamilies.IPv6 && !machineFamilies.IPv6 {
+				return errors.Errorf("the network of the machine is configured with %s, but the control plane endpoint %s requires %s", machineFamilies, ip, endpointFamilies)
+			}
+		}
+	}
+	return nil
+}
+
+// SortAddressesByFamily sorts the addresses of a machine so that the
+// addresses of the primary family of its cluster come first, as the first
+// address is the one used by default, e.g. as the node IP. The order of the
+// addresses of a family is kept, and the addresses which are not IP
+// addresses come last.
+func SortAddressesByFamily(addresses []string, primaryIPv6 bool) []string {
+	rank := func(address string) int {
+		ip := net.ParseIP(address)
+		switch {
+		case ip == nil:
+			return 2
+		case (ip.To4() == nil) == primaryIPv6:
+			return 0
+		default:
+			return 1
+		}
+	}
+	sorted := append([]string(nil), addresses...)
+	sort.SliceStable(sorted, func(i, j int) bool { return rank(sorted[i]) < rank(sorted[j]) })
+	return sorted
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util_test
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+func newClusterWithNetwork(endpoint string, pods, services []string) *clusterv1.Cluster {
+	return &clusterv1.Cluster{
+		Spec: clusterv1.ClusterSpec{
+			ClusterNetwork: &clusterv1.ClusterNetwork{
+				Pods:     &clusterv1.NetworkRanges{CIDRBlocks: pods},
+				Services: &clusterv1.NetworkRanges{CIDRBlocks: services},
+			},
+			ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: endpoint, Port: 6443},
+		},
+	}
+}
+
+func TestGetClusterIPFamilies(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	families, primaryIPv6, err := util.GetClusterIPFamilies(newClusterWithNetwork("", []string{"fd00:10::/56", "10.244.0.0/16"}, []string{"fd00:20::/112"}))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(families).To(gomega.Equal(util.IPFamilies{IPv4: true, IPv6: true}))
+	g.Expect(primaryIPv6).To(gomega.BeTrue())
+
+	families, primaryIPv6, err = util.GetClusterIPFamilies(&clusterv1.Cluster{Spec: clusterv1.ClusterSpec{ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "fd00::10"}}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(families).To(gomega.Equal(util.IPFamilies{}))
+	g.Expect(primaryIPv6).To(gomega.BeTrue())
+
+	_, _, err = util.GetClusterIPFamilies(newClusterWithNetwork("", []string{"10.244.0.0"}, nil))
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestValidateMachineIPFamilies(t *testing.T) {
+	dualStack := newClusterWithNetwork("10.0.0.100", []string{"10.244.0.0/16", "fd00:10::/56"}, []string{"10.96.0.0/12", "fd00:20::/112"})
+	ipv6 := newClusterWithNetwork("fd00::100", []string{"fd00:10::/56"}, []string{"fd00:20::/112"})
+
+	tests := []struct {
+		name         string
+		cluster      *clusterv1.Cluster
+		devices      []infrav1.NetworkDeviceSpec
+		controlPlane bool
+		wantErr      bool
+	}{
+		{
+			name:    "dual-stack cluster with DHCP4 and SLAAC",
+			cluster: dualStack,
+			devices: []infrav1.NetworkDeviceSpec{{DHCP4: true, SLAAC: true}},
+		},
+		{
+			name:    "dual-stack cluster with static addresses of both families",
+			cluster: dualStack,
+			devices: []infrav1.NetworkDeviceSpec{{IPAddrs: []string{"10.0.0.10/24"}}, {IPAddrs: []string{"fd00::10/64"}}},
+		},
+		{
+			name:    "dual-stack cluster with IPv4 only",
+			cluster: dualStack,
+			devices: []infrav1.NetworkDeviceSpec{{DHCP4: true}},
+			wantErr: true,
+		},
+		{
+			name:    "IPv6 cluster with DHCP6",
+			cluster: ipv6,
+			devices: []infrav1.NetworkDeviceSpec{{DHCP6: true}},
+		},
+		{
+			name:    "IPv6 cluster with IPv4 only",
+			cluster: ipv6,
+			devices: []infrav1.NetworkDeviceSpec{{IPAddrs: []string{"10.0.0.10/24"}}},
+			wantErr: true,
+		},
+		{
+			name:    "addresses claimed from IPAM pools are not validated",
+			cluster: ipv6,
+			devices: []infrav1.NetworkDeviceSpec{{AddressesFromPools: []corev1.TypedLocalObjectReference{{Kind: "InClusterIPPool", Name: "pool"}}}},
+		},
+		{
+			name:    "cluster without network",
+			cluster: &clusterv1.Cluster{},
+			devices: []infrav1.NetworkDeviceSpec{{DHCP4: true}},
+		},
+		{
+			name:         "IPv6 control plane endpoint on an IPv4 control plane machine",
+			cluster:      newClusterWithNetwork("fd00::100", nil, nil),
+			devices:      []infrav1.NetworkDeviceSpec{{DHCP4: true}},
+			controlPlane: true,
+			wantErr:      true,
+		},
+		{
+			name:    "IPv6 control plane endpoint on an IPv4 worker machine",
+			cluster: newClusterWithNetwork("fd00::100", nil, nil),
+			devices: []infrav1.NetworkDeviceSpec{{DHCP4: true}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			err := util.ValidateMachineIPFamilies(tc.cluster, tc.devices, tc.controlPlane)
+			if tc.wantErr {
+				g.Expect(err).To(gomega.HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestSortAddressesByFamily(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	addresses := []string{"10.0.0.10", "fd00::10", "vm.local", "10.0.1.10", "fd00::11"}
+	g.Expect(util.SortAddressesByFamily(addresses, true)).To(gomega.Equal([]string{"fd00::10", "fd00::11", "10.0.0.10", "10.0.1.10", "vm.local"}))
+	g.Expect(util.SortAddressesByFamily(addresses, false)).To(gomega.Equal([]string{"10.0.0.10", "10.0.1.10", "fd00::10", "fd00::11", "vm.local"}))
+	g.Expect(addresses[0]).To(gomega.Equal("10.0.0.10"))
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"net"
 	"regexp"
+	"strings"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -145,6 +146,67 @@ func GetMachinePreferredIPAddress(machine *infrav1.VSphereMachine) (string, erro
 	return "", ErrNoMachineIPAddr
 }
 
+// GetPublishedIPAddresses returns the addresses of the network statuses of a
+// VM which are published according to its network spec. The statuses are
+// matched to the devices of the spec by their index, like in the metadata of
+// the VM. An address which is not an IP address is not published when the
+// addresses are restricted to CIDRs.
+func GetPublishedIPAddresses(network infrav1.NetworkSpec, networkStatuses []infrav1.NetworkStatus) ([]string, error) {
+	var (
+		devices = infrav1.AllPublishedAddressDevices
+		cidrs   []*net.IPNet
+	)
+	if published := network.PublishedAddresses; published != nil {
+		if published.Devices != "" {
+			devices = published.Devices
+		}
+		for _, cidrString := range published.CIDRs {
+			_, cidr, err := net.ParseCIDR(cidrString)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error parsing published addresses CIDR %q", cidrString)
+			}
+			cidrs = append(cidrs, cidr)
+		}
+	}
+
+	management := 0
+	for i, device := range network.Devices {
+		if device.Role == infrav1.NetworkDeviceRoleManagement {
+			management = i
+			break
+		}
+	}
+
+	addresses := []string{}
+	for i, status := range networkStatuses {
+		var role infrav1.NetworkDeviceRole
+		if i < len(network.Devices) {
+			role = network.Devices[i].Role
+		}
+		switch {
+		case devices == infrav1.ManagementPublishedAddressDevices && i != management:
+			continue
+		case devices == infrav1.NonWorkloadPublishedAddressDevices && role == infrav1.NetworkDeviceRoleWorkload:
+			continue
+		}
+		for _, address := range status.IPAddrs {
+			if len(cidrs) == 0 || cidrsContain(cidrs, net.ParseIP(address)) {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses, nil
+}
+
+func cidrsContain(cidrs []*net.IPNet, ip net.IP) bool {
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsControlPlaneMachine returns true if the provided resource is
 // a member of the control plane.
 func IsControlPlaneMachine(machine metav1.Object) bool {
@@ -152,8 +214,66 @@ func IsControlPlaneMachine(machine metav1.Object) bool {
 	return ok
 }
 
+// GetVMName returns the name of the virtual machine of a VSphereVM in vSphere.
+func GetVMName(vm *infrav1.VSphereVM) string {
+	if vm.Spec.VMName != "" {
+		return vm.Spec.VMName
+	}
+	return vm.Name
+}
+
+// GetVMInstanceUUID returns the instance UUID of the virtual machine of a
+// VSphereVM, recorded in its instance UUID annotation, or its UID for a
+// VSphereVM whose virtual machine is not created yet.
+func GetVMInstanceUUID(vm *infrav1.VSphereVM) string {
+	if uuid := vm.Annotations[infrav1.VMInstanceUUIDAnnotation]; uuid != "" {
+		return uuid
+	}
+	return string(vm.UID)
+}
+
+// GetMachineHostname returns the guest hostname of a virtual machine according
+// to the hostname strategy of its clone spec. The machine name is the name of
+// the CAPI Machine and the VM name is the name of the virtual machine in vSphere.
+func GetMachineHostname(spec infrav1.VirtualMachineCloneSpec, machineName, vmName string) string {
+	if machineName == "" {
+		machineName = vmName
+	}
+	if spec.OS == infrav1.WindowsOS {
+		machineName = windowsComputerName(machineName)
+		vmName = windowsComputerName(vmName)
+	}
+	switch spec.HostnameStrategy {
+	case infrav1.VMNameHostnameStrategy:
+		return vmName
+	case infrav1.FQDNHostnameStrategy:
+		if spec.HostnameDomain == "" {
+			return machineName
+		}
+		return fmt.Sprintf("%s.%s", machineName, spec.HostnameDomain)
+	default:
+		return machineName
+	}
+}
+
+// windowsComputerNameMaxLength is the maximum length of the NetBIOS name of a
+// Windows computer.
+const windowsComputerNameMaxLength = 15
+
+// windowsComputerName shortens a name to the length of a Windows computer name.
+// The random suffix of the names generated for the Machines of a
+// MachineDeployment or a KubeadmControlPlane is kept, so that the shortened
+// names remain unique within a cluster.
+func windowsComputerName(name string) string {
+	if len(name) <= windowsComputerNameMaxLength {
+		return name
+	}
+	return strings.TrimRight(name[:9], "-") + "-" + name[len(name)-5:]
+}
+
 // GetMachineMetadata returns the cloud-init metadata as a base-64 encoded
-// string for a given VSphereMachine.
+// string for a given VSphereMachine. The metadata of a Windows guest is in the
+// subset of the format supported by Cloudbase-Init.
 func GetMachineMetadata(hostname string, vsphereVM infrav1.VSphereVM, networkStatuses ...infrav1.NetworkStatus) ([]byte, error) {
 	// Create a copy of the devices and add their MAC addresses from a network status.
 	devices := make([]infrav1.NetworkDeviceSpec, integer.IntMax(len(vsphereVM.Spec.Network.Devices), len(networkStatuses)))
@@ -182,7 +302,7 @@ func GetMachineMetadata(hostname string, vsphereVM infrav1.VSphereVM, networkSta
 		if vsphereVM.Spec.Network.Devices[i].DHCP4 {
 			waitForIPv4 = true
 		}
-		if vsphereVM.Spec.Network.Devices[i].DHCP6 {
+		if vsphereVM.Spec.Network.Devices[i].DHCP6 || vsphereVM.Spec.Network.Devices[i].SLAAC {
 			waitForIPv6 = true
 		}
 	}
@@ -192,13 +312,22 @@ func GetMachineMetadata(hostname string, vsphereVM infrav1.VSphereVM, networkSta
 		devices[i].MACAddr = status.MACAddr
 	}
 
+	format := metadataFormat
+	if vsphereVM.Spec.OS == infrav1.WindowsOS {
+		format = windowsMetadataFormat
+	}
+
 	buf := &bytes.Buffer{}
 	tpl := template.Must(template.New("t").Funcs(
 		template.FuncMap{
 			"nameservers": func(spec infrav1.NetworkDeviceSpec) bool {
 				return len(spec.Nameservers) > 0 || len(spec.SearchDomains) > 0
 			},
-		}).Parse(metadataFormat))
+			// A workload device must not install a second default route.
+			"workload": func(spec infrav1.NetworkDeviceSpec) bool {
+				return spec.Role == infrav1.NetworkDeviceRoleWorkload
+			},
+		}).Parse(format))
 	if err := tpl.Execute(buf, struct {
 		Hostname    string
 		Devices     []infrav1.NetworkDeviceSpec
@@ -220,6 +349,57 @@ func GetMachineMetadata(hostname string, vsphereVM infrav1.VSphereVM, networkSta
 	return buf.Bytes(), nil
 }
 
+// GetMachineNetworkKargs returns the dracut kernel arguments configuring the
+// network devices of a VM in the initramfs of an Ignition guest, e.g.
+// "ip=192.168.1.10::192.168.1.1:255.255.255.0::ens192:off nameserver=8.8.8.8".
+// The arguments of a device without a device name apply to any interface.
+func GetMachineNetworkKargs(devices []infrav1.NetworkDeviceSpec) (string, error) {
+	var kargs, nameservers []string
+	seen := map[string]bool{}
+	for _, device := range devices {
+		for _, addr := range device.IPAddrs {
+			ip, ipNet, err := net.ParseCIDR(addr)
+			if err != nil {
+				return "", errors.Wrapf(err, "invalid address %s", addr)
+			}
+			if ip.To4() != nil {
+				kargs = append(kargs, fmt.Sprintf("ip=%s::%s:%s::%s:off", ip, device.Gateway4, net.IP(ipNet.Mask), device.DeviceName))
+				continue
+			}
+			gateway6 := device.Gateway6
+			if gateway6 != "" {
+				gateway6 = "[" + gateway6 + "]"
+			}
+			ones, _ := ipNet.Mask.Size()
+			kargs = append(kargs, fmt.Sprintf("ip=[%s]::%s:%d::%s:off", ip, gateway6, ones, device.DeviceName))
+		}
+		var protocols []string
+		if device.DHCP4 {
+			protocols = append(protocols, "dhcp")
+		}
+		if device.DHCP6 {
+			protocols = append(protocols, "dhcp6")
+		}
+		if device.SLAAC {
+			protocols = append(protocols, "auto6")
+		}
+		for _, protocol := range protocols {
+			if device.DeviceName == "" {
+				kargs = append(kargs, "ip="+protocol)
+			} else {
+				kargs = append(kargs, fmt.Sprintf("ip=%s:%s", device.DeviceName, protocol))
+			}
+		}
+		for _, nameserver := range device.Nameservers {
+			if !seen[nameserver] {
+				seen[nameserver] = true
+				nameservers = append(nameservers, "nameserver="+nameserver)
+			}
+		}
+	}
+	return strings.Join(append(kargs, nameservers...), " "), nil
+}
+
 func GetOwnerVSphereMachine(ctx context.Context, c client.Client, obj metav1.ObjectMeta) (*infrav1.VSphereMachine, error) {
 	for _, ref := range obj.OwnerReferences {
 		gv, err := schema.ParseGroupVersion(ref.APIVersion)
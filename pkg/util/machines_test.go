@@ -338,6 +338,43 @@ network:
       wakeonlan: true
       dhcp4: false
       dhcp6: true
+`,
+		},
+		{
+			name: "static6+slaac",
+			machine: &infrav1.VSphereVM{
+				Spec: infrav1.VSphereVMSpec{
+					VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+						Network: infrav1.NetworkSpec{
+							Devices: []infrav1.NetworkDeviceSpec{
+								{
+									NetworkName: "network1",
+									MACAddr:     "00:00:00:00:00",
+									SLAAC:       true,
+									IPAddrs:     []string{"fd00::10/64"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: `
+instance-id: "test-vm"
+local-hostname: "test-vm"
+wait-on-network:
+  ipv4: false
+  ipv6: true
+network:
+  version: 2
+  ethernets:
+    id0:
+      match:
+        macaddress: "00:00:00:00:00"
+      set-name: "eth0"
+      wakeonlan: true
+      accept-ra: true
+      addresses:
+      - "fd00::10/64"
 `,
 		},
 		{
@@ -525,6 +562,68 @@ network:
       dhcp4: false
       dhcp6: true
       mtu: 100
+`,
+		},
+		{
+			name: "2nets-management+workload",
+			machine: &infrav1.VSphereVM{
+				Spec: infrav1.VSphereVMSpec{
+					VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+						Network: infrav1.NetworkSpec{
+							Devices: []infrav1.NetworkDeviceSpec{
+								{
+									NetworkName: "management",
+									MACAddr:     "00:00:00:00:00",
+									DHCP4:       true,
+									Role:        infrav1.NetworkDeviceRoleManagement,
+								},
+								{
+									NetworkName: "workload",
+									MACAddr:     "00:00:00:00:01",
+									DHCP4:       true,
+									Role:        infrav1.NetworkDeviceRoleWorkload,
+									Routes: []infrav1.NetworkRouteSpec{
+										{
+											To:     "10.1.0.0/16",
+											Via:    "10.0.0.1",
+											Metric: 100,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: `
+instance-id: "test-vm"
+local-hostname: "test-vm"
+wait-on-network:
+  ipv4: true
+  ipv6: false
+network:
+  version: 2
+  ethernets:
+    id0:
+      match:
+        macaddress: "00:00:00:00:00"
+      set-name: "eth0"
+      wakeonlan: true
+      dhcp4: true
+      dhcp6: false
+    id1:
+      match:
+        macaddress: "00:00:00:00:01"
+      set-name: "eth1"
+      wakeonlan: true
+      dhcp4: true
+      dhcp6: false
+      dhcp4-overrides:
+        use-routes: false
+      routes:
+      - to: "10.1.0.0/16"
+        via: "10.0.0.1"
+        metric: 100
 `,
 		},
 		{
@@ -637,6 +736,54 @@ network:
       wakeonlan: true
       dhcp4: false
       dhcp6: true
+`,
+		},
+		{
+			name: "windows",
+			machine: &infrav1.VSphereVM{
+				Spec: infrav1.VSphereVMSpec{
+					VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+						OS: infrav1.WindowsOS,
+						Network: infrav1.NetworkSpec{
+							Devices: []infrav1.NetworkDeviceSpec{
+								{
+									NetworkName: "network1",
+									MACAddr:     "00:00:00:00:00",
+									DHCP4:       true,
+									DeviceName:  "ens192",
+								},
+								{
+									NetworkName: "network2",
+									MACAddr:     "00:00:00:00:01",
+									IPAddrs:     []string{"192.168.4.21/24"},
+									Gateway4:    "192.168.4.1",
+									Nameservers: []string{"8.8.8.8"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: `
+instance-id: "test-vm"
+local-hostname: "test-vm"
+network:
+  version: 2
+  ethernets:
+    id0:
+      match:
+        macaddress: "00:00:00:00:00"
+      dhcp4: true
+      dhcp6: false
+    id1:
+      match:
+        macaddress: "00:00:00:00:01"
+      addresses:
+      - "192.168.4.21/24"
+      gateway4: "192.168.4.1"
+      nameservers:
+        addresses:
+        - "8.8.8.8"
 `,
 		},
 	}
@@ -658,6 +805,187 @@ network:
 	}
 }
 
+func TestGetMachineNetworkKargs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	kargs, err := util.GetMachineNetworkKargs([]infrav1.NetworkDeviceSpec{
+		{
+			DeviceName:  "ens192",
+			IPAddrs:     []string{"192.168.1.10/24", "fd00::10/64"},
+			Gateway4:    "192.168.1.1",
+			Gateway6:    "fd00::1",
+			Nameservers: []string{"8.8.8.8", "8.8.4.4"},
+		},
+		{
+			DHCP4:       true,
+			DHCP6:       true,
+			Nameservers: []string{"8.8.8.8"},
+		},
+		{
+			DeviceName: "ens224",
+			DHCP4:      true,
+		},
+		{
+			DeviceName: "ens256",
+			SLAAC:      true,
+		},
+	})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(kargs).To(gomega.Equal("ip=192.168.1.10::192.168.1.1:255.255.255.0::ens192:off " +
+		"ip=[fd00::10]::[fd00::1]:64::ens192:off " +
+		"ip=dhcp ip=dhcp6 ip=ens224:dhcp ip=ens256:auto6 " +
+		"nameserver=8.8.8.8 nameserver=8.8.4.4"))
+
+	_, err = util.GetMachineNetworkKargs([]infrav1.NetworkDeviceSpec{{IPAddrs: []string{"192.168.1.10"}}})
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestGetPublishedIPAddresses(t *testing.T) {
+	devices := []infrav1.NetworkDeviceSpec{
+		{NetworkName: "storage"},
+		{NetworkName: "management", Role: infrav1.NetworkDeviceRoleManagement},
+		{NetworkName: "workload", Role: infrav1.NetworkDeviceRoleWorkload},
+	}
+	statuses := []infrav1.NetworkStatus{
+		{IPAddrs: []string{"172.16.0.10"}},
+		{IPAddrs: []string{"10.0.0.10", "fd00::10"}},
+		{IPAddrs: []string{"192.168.0.10"}},
+	}
+
+	tests := []struct {
+		name      string
+		published *infrav1.PublishedAddressesSpec
+		devices   []infrav1.NetworkDeviceSpec
+		addresses []string
+	}{
+		{
+			name:      "every address by default",
+			devices:   devices,
+			addresses: []string{"172.16.0.10", "10.0.0.10", "fd00::10", "192.168.0.10"},
+		},
+		{
+			name:      "the addresses of the management device",
+			published: &infrav1.PublishedAddressesSpec{Devices: infrav1.ManagementPublishedAddressDevices},
+			devices:   devices,
+			addresses: []string{"10.0.0.10", "fd00::10"},
+		},
+		{
+			name:      "the addresses of the first device without a management device",
+			published: &infrav1.PublishedAddressesSpec{Devices: infrav1.ManagementPublishedAddressDevices},
+			devices:   []infrav1.NetworkDeviceSpec{{NetworkName: "storage"}, {NetworkName: "management"}},
+			addresses: []string{"172.16.0.10"},
+		},
+		{
+			name:      "the addresses of the devices other than the workload devices",
+			published: &infrav1.PublishedAddressesSpec{Devices: infrav1.NonWorkloadPublishedAddressDevices},
+			devices:   devices,
+			addresses: []string{"172.16.0.10", "10.0.0.10", "fd00::10"},
+		},
+		{
+			name:      "the addresses in a CIDR",
+			published: &infrav1.PublishedAddressesSpec{CIDRs: []string{"10.0.0.0/16", "192.168.0.0/24"}},
+			devices:   devices,
+			addresses: []string{"10.0.0.10", "192.168.0.10"},
+		},
+		{
+			name:      "no address matching both the devices and the CIDRs",
+			published: &infrav1.PublishedAddressesSpec{Devices: infrav1.ManagementPublishedAddressDevices, CIDRs: []string{"192.168.0.0/24"}},
+			devices:   devices,
+			addresses: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			network := infrav1.NetworkSpec{Devices: tt.devices, PublishedAddresses: tt.published}
+			addresses, err := util.GetPublishedIPAddresses(network, statuses)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			g.Expect(addresses).To(gomega.Equal(tt.addresses))
+		})
+	}
+
+	_, err := util.GetPublishedIPAddresses(infrav1.NetworkSpec{PublishedAddresses: &infrav1.PublishedAddressesSpec{CIDRs: []string{"10.0.0.1"}}}, statuses)
+	g := gomega.NewWithT(t)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestGetMachineHostname(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	testCases := []struct {
+		name             string
+		spec             infrav1.VirtualMachineCloneSpec
+		machineName      string
+		expectedHostname string
+	}{
+		{
+			name:             "default strategy",
+			spec:             infrav1.VirtualMachineCloneSpec{},
+			machineName:      "machine-0",
+			expectedHostname: "machine-0",
+		},
+		{
+			name:             "machine name strategy",
+			spec:             infrav1.VirtualMachineCloneSpec{HostnameStrategy: infrav1.MachineNameHostnameStrategy},
+			machineName:      "machine-0",
+			expectedHostname: "machine-0",
+		},
+		{
+			name:             "vm name strategy",
+			spec:             infrav1.VirtualMachineCloneSpec{HostnameStrategy: infrav1.VMNameHostnameStrategy},
+			machineName:      "machine-0",
+			expectedHostname: "vm-0",
+		},
+		{
+			name:             "fqdn strategy",
+			spec:             infrav1.VirtualMachineCloneSpec{HostnameStrategy: infrav1.FQDNHostnameStrategy, HostnameDomain: "example.com"},
+			machineName:      "machine-0",
+			expectedHostname: "machine-0.example.com",
+		},
+		{
+			name:             "unknown machine name",
+			spec:             infrav1.VirtualMachineCloneSpec{HostnameStrategy: infrav1.FQDNHostnameStrategy, HostnameDomain: "example.com"},
+			expectedHostname: "vm-0.example.com",
+		},
+		{
+			name:             "windows guest",
+			spec:             infrav1.VirtualMachineCloneSpec{OS: infrav1.WindowsOS},
+			machineName:      "win-md-0-7d9f8b6c4-x2k9z",
+			expectedHostname: "win-md-0-x2k9z",
+		},
+		{
+			name:             "windows guest with a short name",
+			spec:             infrav1.VirtualMachineCloneSpec{OS: infrav1.WindowsOS},
+			machineName:      "machine-0",
+			expectedHostname: "machine-0",
+		},
+		{
+			name:             "windows guest with fqdn strategy",
+			spec:             infrav1.VirtualMachineCloneSpec{OS: infrav1.WindowsOS, HostnameStrategy: infrav1.FQDNHostnameStrategy, HostnameDomain: "example.com"},
+			machineName:      "win-cp-7d9f8-x2k9z",
+			expectedHostname: "win-cp-7d-x2k9z.example.com",
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			hostname := util.GetMachineHostname(tc.spec, tc.machineName, "vm-0")
+			g.Expect(hostname).To(gomega.Equal(tc.expectedHostname))
+		})
+	}
+}
+
+func TestGetVMInstanceUUID(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	vm := &infrav1.VSphereVM{ObjectMeta: metav1.ObjectMeta{UID: "10000000-0000-0000-0000-000000000000"}}
+	g.Expect(util.GetVMInstanceUUID(vm)).To(gomega.Equal("10000000-0000-0000-0000-000000000000"))
+
+	// A restored VSphereVM keeps the instance UUID of its virtual machine.
+	vm.Annotations = map[string]string{infrav1.VMInstanceUUIDAnnotation: "20000000-0000-0000-0000-000000000000"}
+	g.Expect(util.GetVMInstanceUUID(vm)).To(gomega.Equal("20000000-0000-0000-0000-000000000000"))
+}
+
 func TestConvertProviderIDToUUID(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crossnamespace
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Policy is the policy applied to the object references that point to
+// another namespace than the one of the object holding them.
+type Policy string
+
+const (
+	// AllowPolicy allows cross-namespace references.
+	AllowPolicy Policy = "Allow"
+
+	// AuditPolicy allows cross-namespace references, but logs them.
+	AuditPolicy Policy = "Audit"
+
+	// DenyPolicy rejects the objects with cross-namespace references.
+	DenyPolicy Policy = "Deny"
+)
+
+// ParsePolicy returns the Policy named s, defaulting to AllowPolicy when s is empty.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case "":
+		return AllowPolicy, nil
+	case AllowPolicy, AuditPolicy, DenyPolicy:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid cross-namespace reference policy %q, must be one of %s, %s or %s", s, AllowPolicy, AuditPolicy, DenyPolicy)
+	}
+}
+
+// enforce applies the policy to the cross-namespace references of an object.
+func (p Policy) enforce(logger logr.Logger, gk schema.GroupKind, namespace, name string, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	switch p {
+	case DenyPolicy:
+		return apierrors.NewInvalid(gk, name, allErrs)
+	case AuditPolicy:
+		logger.Info("Object has cross-namespace references", "kind", gk.String(), "namespace", namespace, "name", name, "references", allErrs.ToAggregate().Error())
+	}
+	return nil
+}
+
+// validateNamespace returns an error if the namespace of a reference is set
+// and differs from the namespace of the object holding the reference.
+func validateNamespace(fldPath *field.Path, namespace, refNamespace string) field.ErrorList {
+	if refNamespace == "" || refNamespace == namespace {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(fldPath, fmt.Sprintf("cannot reference namespace %q from namespace %q", refNamespace, namespace))}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crossnamespace
+
+import (
+	goctx "context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-crossnamespace-vmware-infrastructure-cluster-x-k8s-io-v1beta1-providerserviceaccount,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=vmware.infrastructure.cluster.x-k8s.io,resources=providerserviceaccounts,versions=v1beta1,name=crossnamespace.providerserviceaccount.vmware.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// ProviderServiceAccountValidator applies the cross-namespace reference
+// policy to the VSphereCluster referenced by a ProviderServiceAccount.
+type ProviderServiceAccountValidator struct {
+	Policy Policy
+	Logger logr.Logger
+}
+
+var _ admission.CustomValidator = &ProviderServiceAccountValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *ProviderServiceAccountValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(
+		"/validate-crossnamespace-vmware-infrastructure-cluster-x-k8s-io-v1beta1-providerserviceaccount",
+		admission.WithCustomValidator(&vmwarev1.ProviderServiceAccount{}, v))
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *ProviderServiceAccountValidator) ValidateCreate(_ goctx.Context, obj runtime.Object) error {
+	psa, ok := obj.(*vmwarev1.ProviderServiceAccount)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a ProviderServiceAccount but got a %T", obj))
+	}
+	return v.validate(nil, psa)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *ProviderServiceAccountValidator) ValidateUpdate(_ goctx.Context, oldObj, newObj runtime.Object) error {
+	oldPSA, ok := oldObj.(*vmwarev1.ProviderServiceAccount)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a ProviderServiceAccount but got a %T", oldObj))
+	}
+	psa, ok := newObj.(*vmwarev1.ProviderServiceAccount)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a ProviderServiceAccount but got a %T", newObj))
+	}
+	return v.validate(oldPSA, psa)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *ProviderServiceAccountValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
+
+func (v *ProviderServiceAccountValidator) validate(oldPSA, psa *vmwarev1.ProviderServiceAccount) error {
+	if v.Policy == AllowPolicy || v.Policy == "" || psa.Spec.Ref == nil {
+		return nil
+	}
+	if oldPSA != nil && oldPSA.Spec.Ref != nil && oldPSA.Spec.Ref.Namespace == psa.Spec.Ref.Namespace {
+		return nil
+	}
+	allErrs := validateNamespace(field.NewPath("spec", "ref", "namespace"), psa.Namespace, psa.Spec.Ref.Namespace)
+	return v.Policy.enforce(v.Logger, vmwarev1.GroupVersion.WithKind("ProviderServiceAccount").GroupKind(), psa.Namespace, psa.Name, allErrs)
+}
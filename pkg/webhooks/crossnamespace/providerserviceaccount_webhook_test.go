@@ -0,0 +1,49 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crossnamespace
+
+import (
+	goctx "context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+)
+
+func TestProviderServiceAccountValidator(t *testing.T) {
+	g := NewWithT(t)
+
+	newPSA := func(refNamespace string) *vmwarev1.ProviderServiceAccount {
+		return &vmwarev1.ProviderServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "psa"},
+			Spec: vmwarev1.ProviderServiceAccountSpec{
+				Ref: &corev1.ObjectReference{Kind: "VSphereCluster", Namespace: refNamespace, Name: "cluster"},
+			},
+		}
+	}
+
+	validator := &ProviderServiceAccountValidator{Policy: DenyPolicy, Logger: logr.Discard()}
+	g.Expect(validator.ValidateCreate(goctx.TODO(), newPSA("ns"))).To(Succeed())
+	g.Expect(validator.ValidateCreate(goctx.TODO(), newPSA("other"))).NotTo(Succeed())
+
+	validator.Policy = AuditPolicy
+	g.Expect(validator.ValidateCreate(goctx.TODO(), newPSA("other"))).To(Succeed())
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crossnamespace
+
+import (
+	goctx "context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-crossnamespace-infrastructure-cluster-x-k8s-io-v1beta1-vspherecluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vsphereclusters,versions=v1beta1,name=crossnamespace.vspherecluster.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereClusterValidator applies the cross-namespace reference policy to the
+// identity referenced by a VSphereCluster. A VSphereClusterIdentity is cluster
+// scoped, so it is considered a cross-namespace reference unless the namespace
+// of the VSphereCluster is selected by its allowedNamespaces.
+type VSphereClusterValidator struct {
+	Client client.Reader
+	Policy Policy
+	Logger logr.Logger
+}
+
+var _ admission.CustomValidator = &VSphereClusterValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *VSphereClusterValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if v.Client == nil {
+		v.Client = mgr.GetAPIReader()
+	}
+	mgr.GetWebhookServer().Register(
+		"/validate-crossnamespace-infrastructure-cluster-x-k8s-io-v1beta1-vspherecluster",
+		admission.WithCustomValidator(&infrav1.VSphereCluster{}, v))
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *VSphereClusterValidator) ValidateCreate(ctx goctx.Context, obj runtime.Object) error {
+	cluster, ok := obj.(*infrav1.VSphereCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereCluster but got a %T", obj))
+	}
+	return v.validate(ctx, nil, cluster)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *VSphereClusterValidator) ValidateUpdate(ctx goctx.Context, oldObj, newObj runtime.Object) error {
+	oldCluster, ok := oldObj.(*infrav1.VSphereCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereCluster but got a %T", oldObj))
+	}
+	cluster, ok := newObj.(*infrav1.VSphereCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereCluster but got a %T", newObj))
+	}
+	return v.validate(ctx, oldCluster, cluster)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *VSphereClusterValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
+
+func (v *VSphereClusterValidator) validate(ctx goctx.Context, oldCluster, cluster *infrav1.VSphereCluster) error {
+	if v.Policy == AllowPolicy || v.Policy == "" {
+		return nil
+	}
+	ref := cluster.Spec.IdentityRef
+	if ref == nil || ref.Kind != infrav1.VSphereClusterIdentityKind {
+		return nil
+	}
+	if oldCluster != nil && reflect.DeepEqual(oldCluster.Spec.IdentityRef, ref) {
+		return nil
+	}
+
+	allErrs, err := validateIdentityRef(ctx, v.Client, field.NewPath("spec", "identityRef"), cluster.Namespace, ref)
+	if err != nil {
+		return err
+	}
+	return v.Policy.enforce(v.Logger, infrav1.GroupVersion.WithKind("VSphereCluster").GroupKind(), cluster.Namespace, cluster.Name, allErrs)
+}
+
+// validateIdentityRef returns an error if the namespace is not allowed to use
+// the referenced VSphereClusterIdentity. Identities which do not exist yet are
+// left to the VSphereCluster controller.
+func validateIdentityRef(ctx goctx.Context, c client.Reader, fldPath *field.Path, namespace string, ref *infrav1.VSphereIdentityReference) (field.ErrorList, error) {
+	identity := &infrav1.VSphereClusterIdentity{}
+	if err := c.Get(ctx, client.ObjectKey{Name: ref.Name}, identity); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, apierrors.NewInternalError(errors.Wrapf(err, "failed to get VSphereClusterIdentity %s", ref.Name))
+	}
+
+	forbidden := field.ErrorList{field.Forbidden(fldPath, fmt.Sprintf("VSphereClusterIdentity %s does not allow namespace %s", ref.Name, namespace))}
+	if identity.Spec.AllowedNamespaces == nil {
+		return forbidden, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&identity.Spec.AllowedNamespaces.Selector)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, ref.Name, fmt.Sprintf("VSphereClusterIdentity has an invalid namespace selector: %v", err))}, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return nil, apierrors.NewInternalError(errors.Wrapf(err, "failed to get namespace %s", namespace))
+	}
+	if !selector.Matches(labels.Set(ns.GetLabels())) {
+		return forbidden, nil
+	}
+	return nil, nil
+}
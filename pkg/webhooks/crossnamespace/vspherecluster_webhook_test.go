@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crossnamespace
+
+import (
+	goctx "context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestVSphereClusterValidator(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Labels: map[string]string{"tenant": "a"}}},
+		&infrav1.VSphereClusterIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"},
+			Spec: infrav1.VSphereClusterIdentitySpec{
+				AllowedNamespaces: &infrav1.AllowedNamespaces{
+					Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}},
+				},
+			},
+		},
+		&infrav1.VSphereClusterIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant-b"},
+			Spec: infrav1.VSphereClusterIdentitySpec{
+				AllowedNamespaces: &infrav1.AllowedNamespaces{
+					Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "b"}},
+				},
+			},
+		},
+		&infrav1.VSphereClusterIdentity{ObjectMeta: metav1.ObjectMeta{Name: "no-namespaces"}},
+	).Build()
+
+	newCluster := func(kind infrav1.VSphereIdentityKind, name string) *infrav1.VSphereCluster {
+		return &infrav1.VSphereCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"},
+			Spec: infrav1.VSphereClusterSpec{
+				IdentityRef: &infrav1.VSphereIdentityReference{Kind: kind, Name: name},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		cluster *infrav1.VSphereCluster
+		wantErr bool
+	}{
+		{
+			name:    "identity allowing the namespace",
+			cluster: newCluster(infrav1.VSphereClusterIdentityKind, "tenant-a"),
+		},
+		{
+			name:    "identity not allowing the namespace",
+			cluster: newCluster(infrav1.VSphereClusterIdentityKind, "tenant-b"),
+			wantErr: true,
+		},
+		{
+			name:    "identity allowing no namespace",
+			cluster: newCluster(infrav1.VSphereClusterIdentityKind, "no-namespaces"),
+			wantErr: true,
+		},
+		{
+			name:    "missing identity",
+			cluster: newCluster(infrav1.VSphereClusterIdentityKind, "missing"),
+		},
+		{
+			name:    "secret identity",
+			cluster: newCluster(infrav1.SecretKind, "credentials"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			validator := &VSphereClusterValidator{Client: c, Policy: DenyPolicy, Logger: logr.Discard()}
+			err := validator.ValidateCreate(goctx.TODO(), tc.cluster)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+
+	t.Run("the allow policy does not validate the identity", func(t *testing.T) {
+		g := NewWithT(t)
+		validator := &VSphereClusterValidator{Client: c, Policy: AllowPolicy, Logger: logr.Discard()}
+		g.Expect(validator.ValidateCreate(goctx.TODO(), newCluster(infrav1.VSphereClusterIdentityKind, "tenant-b"))).To(Succeed())
+	})
+
+	t.Run("unchanged references are not validated on update", func(t *testing.T) {
+		g := NewWithT(t)
+		validator := &VSphereClusterValidator{Client: c, Policy: DenyPolicy, Logger: logr.Discard()}
+		oldCluster := newCluster(infrav1.VSphereClusterIdentityKind, "tenant-b")
+		cluster := oldCluster.DeepCopy()
+		cluster.Spec.Server = "vcenter.example.com"
+		g.Expect(validator.ValidateUpdate(goctx.TODO(), oldCluster, cluster)).To(Succeed())
+
+		cluster.Spec.IdentityRef.Name = "no-namespaces"
+		g.Expect(validator.ValidateUpdate(goctx.TODO(), oldCluster, cluster)).NotTo(Succeed())
+	})
+}
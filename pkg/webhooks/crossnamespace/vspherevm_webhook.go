@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crossnamespace
+
+import (
+	goctx "context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-crossnamespace-infrastructure-cluster-x-k8s-io-v1beta1-vspherevm,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vspherevms,versions=v1beta1,name=crossnamespace.vspherevm.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereVMValidator applies the cross-namespace reference policy to the
+// bootstrap data referenced by a VSphereVM.
+type VSphereVMValidator struct {
+	Policy Policy
+	Logger logr.Logger
+}
+
+var _ admission.CustomValidator = &VSphereVMValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *VSphereVMValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(
+		"/validate-crossnamespace-infrastructure-cluster-x-k8s-io-v1beta1-vspherevm",
+		admission.WithCustomValidator(&infrav1.VSphereVM{}, v))
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *VSphereVMValidator) ValidateCreate(_ goctx.Context, obj runtime.Object) error {
+	vm, ok := obj.(*infrav1.VSphereVM)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereVM but got a %T", obj))
+	}
+	return v.validate(nil, vm)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *VSphereVMValidator) ValidateUpdate(_ goctx.Context, oldObj, newObj runtime.Object) error {
+	oldVM, ok := oldObj.(*infrav1.VSphereVM)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereVM but got a %T", oldObj))
+	}
+	vm, ok := newObj.(*infrav1.VSphereVM)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereVM but got a %T", newObj))
+	}
+	return v.validate(oldVM, vm)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *VSphereVMValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
+
+func (v *VSphereVMValidator) validate(oldVM, vm *infrav1.VSphereVM) error {
+	if v.Policy == AllowPolicy || v.Policy == "" || vm.Spec.BootstrapRef == nil {
+		return nil
+	}
+	if oldVM != nil && oldVM.Spec.BootstrapRef != nil && oldVM.Spec.BootstrapRef.Namespace == vm.Spec.BootstrapRef.Namespace {
+		return nil
+	}
+	allErrs := validateNamespace(field.NewPath("spec", "bootstrapRef", "namespace"), vm.Namespace, vm.Spec.BootstrapRef.Namespace)
+	return v.Policy.enforce(v.Logger, infrav1.GroupVersion.WithKind("VSphereVM").GroupKind(), vm.Namespace, vm.Name, allErrs)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crossnamespace
+
+import (
+	goctx "context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestVSphereVMValidator(t *testing.T) {
+	newVM := func(bootstrapNamespace string) *infrav1.VSphereVM {
+		return &infrav1.VSphereVM{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "vm"},
+			Spec: infrav1.VSphereVMSpec{
+				BootstrapRef: &corev1.ObjectReference{Kind: "Secret", Namespace: bootstrapNamespace, Name: "bootstrap-data"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		vm      *infrav1.VSphereVM
+		wantErr bool
+	}{
+		{
+			name:   "cross-namespace reference with the allow policy",
+			policy: AllowPolicy,
+			vm:     newVM("other"),
+		},
+		{
+			name:   "cross-namespace reference with the audit policy",
+			policy: AuditPolicy,
+			vm:     newVM("other"),
+		},
+		{
+			name:    "cross-namespace reference with the deny policy",
+			policy:  DenyPolicy,
+			vm:      newVM("other"),
+			wantErr: true,
+		},
+		{
+			name:   "same namespace reference with the deny policy",
+			policy: DenyPolicy,
+			vm:     newVM("ns"),
+		},
+		{
+			name:   "reference without namespace with the deny policy",
+			policy: DenyPolicy,
+			vm:     newVM(""),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			validator := &VSphereVMValidator{Policy: tc.policy, Logger: logr.Discard()}
+			err := validator.ValidateCreate(goctx.TODO(), tc.vm)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+
+	t.Run("unchanged references are not validated on update", func(t *testing.T) {
+		g := NewWithT(t)
+		validator := &VSphereVMValidator{Policy: DenyPolicy, Logger: logr.Discard()}
+		oldVM := newVM("other")
+		vm := oldVM.DeepCopy()
+		vm.Spec.NumCPUs = 4
+		g.Expect(validator.ValidateUpdate(goctx.TODO(), oldVM, vm)).To(Succeed())
+
+		vm.Spec.BootstrapRef.Namespace = "another"
+		g.Expect(validator.ValidateUpdate(goctx.TODO(), oldVM, vm)).NotTo(Succeed())
+	})
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ippool
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// poolValidator validates the references to IPAM pools.
+type poolValidator struct {
+	RESTMapper meta.RESTMapper
+}
+
+// validatePool returns an error if the kind of a pool is not served by the API
+// server. The references without a group or a kind are rejected by the
+// webhooks of the types. The pools are not required to exist, as they may be
+// created after the objects referencing them, e.g. by clusterctl move; a
+// missing pool is reported by the VSphereVMs claiming addresses from it.
+func (v poolValidator) validatePool(fldPath *field.Path, pool corev1.TypedLocalObjectReference) (field.ErrorList, error) {
+	if pool.APIGroup == nil || *pool.APIGroup == "" || pool.Kind == "" || pool.Name == "" {
+		return nil, nil
+	}
+
+	if _, err := v.RESTMapper.RESTMapping(schema.GroupKind{Group: *pool.APIGroup, Kind: pool.Kind}); err != nil {
+		if meta.IsNoMatchError(err) {
+			return field.ErrorList{field.Invalid(fldPath.Child("kind"), pool.Kind, fmt.Sprintf("is not served by the API server in group %s", *pool.APIGroup))}, nil
+		}
+		return nil, apierrors.NewInternalError(errors.Wrapf(err, "failed to get the resource of %s.%s", pool.Kind, *pool.APIGroup))
+	}
+	return nil, nil
+}
+
+// validateDevicePools validates the pools of the network devices which are
+// not referenced by the old devices, so that an update is not rejected once a
+// pool in use is removed.
+func (v poolValidator) validateDevicePools(fldPath *field.Path, oldDevices, devices []infrav1.NetworkDeviceSpec) (field.ErrorList, error) {
+	oldPools := map[string]bool{}
+	for _, device := range oldDevices {
+		for _, pool := range device.AddressesFromPools {
+			oldPools[poolKey(pool)] = true
+		}
+	}
+
+	var allErrs field.ErrorList
+	for i, device := range devices {
+		for j, pool := range device.AddressesFromPools {
+			if oldPools[poolKey(pool)] {
+				continue
+			}
+			poolErrs, err := v.validatePool(fldPath.Index(i).Child("addressesFromPools").Index(j), pool)
+			if err != nil {
+				return nil, err
+			}
+			allErrs = append(allErrs, poolErrs...)
+		}
+	}
+	return allErrs, nil
+}
+
+// poolKey returns a key identifying a pool, since the group of a
+// TypedLocalObjectReference is a pointer.
+func poolKey(pool corev1.TypedLocalObjectReference) string {
+	group := ""
+	if pool.APIGroup != nil {
+		group = *pool.APIGroup
+	}
+	return fmt.Sprintf("%s/%s/%s", group, pool.Kind, pool.Name)
+}
+
+func aggregateObjErrors(obj client.Object, gk schema.GroupKind, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(gk, obj.GetName(), allErrs)
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ippool
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+var inClusterIPPoolGVK = schema.GroupVersionKind{Group: "ipam.cluster.x-k8s.io", Version: "v1alpha1", Kind: "InClusterIPPool"}
+
+func newPoolValidator() poolValidator {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{inClusterIPPoolGVK.GroupVersion()})
+	mapper.Add(inClusterIPPoolGVK, meta.RESTScopeNamespace)
+	return poolValidator{RESTMapper: mapper}
+}
+
+func poolRef(kind, name string) corev1.TypedLocalObjectReference {
+	return corev1.TypedLocalObjectReference{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: kind, Name: name}
+}
+
+func newMachine(pools ...corev1.TypedLocalObjectReference) *infrav1.VSphereMachine {
+	machine := &infrav1.VSphereMachine{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "machine"}}
+	machine.Spec.Network.Devices = []infrav1.NetworkDeviceSpec{{NetworkName: "vm-network", AddressesFromPools: pools}}
+	return machine
+}
+
+func TestVSphereMachineValidator(t *testing.T) {
+	tests := []struct {
+		name       string
+		oldMachine *infrav1.VSphereMachine
+		machine    *infrav1.VSphereMachine
+		wantErr    bool
+	}{
+		{
+			name:    "served kind",
+			machine: newMachine(poolRef("InClusterIPPool", "pool")),
+		},
+		{
+			name:    "pool created after the machine",
+			machine: newMachine(poolRef("InClusterIPPool", "other")),
+		},
+		{
+			name:    "kind not served",
+			machine: newMachine(poolRef("IPPool", "pool")),
+			wantErr: true,
+		},
+		{
+			name:       "kind not served already in use",
+			oldMachine: newMachine(poolRef("IPPool", "pool")),
+			machine:    newMachine(poolRef("IPPool", "pool")),
+		},
+		{
+			name:       "kind not served added",
+			oldMachine: newMachine(poolRef("InClusterIPPool", "pool")),
+			machine:    newMachine(poolRef("InClusterIPPool", "pool"), poolRef("IPPool", "pool")),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			v := &VSphereMachineValidator{poolValidator: newPoolValidator()}
+			var err error
+			if tt.oldMachine == nil {
+				err = v.ValidateCreate(goctx.Background(), tt.machine)
+			} else {
+				err = v.ValidateUpdate(goctx.Background(), tt.oldMachine, tt.machine)
+			}
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestVSphereMachineTemplateValidator(t *testing.T) {
+	g := NewWithT(t)
+	v := &VSphereMachineTemplateValidator{poolValidator: newPoolValidator()}
+
+	template := &infrav1.VSphereMachineTemplate{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "template"}}
+	template.Spec.Template.Spec.Network.Devices = []infrav1.NetworkDeviceSpec{{NetworkName: "vm-network", AddressesFromPools: []corev1.TypedLocalObjectReference{poolRef("InClusterIPPool", "other")}}}
+	g.Expect(v.ValidateCreate(goctx.Background(), template)).To(Succeed())
+
+	template.Spec.Template.Spec.Network.Devices[0].AddressesFromPools = []corev1.TypedLocalObjectReference{poolRef("IPPool", "pool")}
+	g.Expect(v.ValidateCreate(goctx.Background(), template)).NotTo(Succeed())
+}
+
+func TestVSphereClusterValidator(t *testing.T) {
+	g := NewWithT(t)
+	v := &VSphereClusterValidator{poolValidator: newPoolValidator()}
+
+	cluster := &infrav1.VSphereCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"},
+		Spec: infrav1.VSphereClusterSpec{
+			ControlPlaneEndpointProvider: &infrav1.ControlPlaneEndpointProviderSpec{
+				KubeVIP: &infrav1.KubeVIPEndpointSpec{AddressFromPool: poolRef("InClusterIPPool", "other")},
+			},
+		},
+	}
+	g.Expect(v.ValidateCreate(goctx.Background(), cluster)).To(Succeed())
+
+	cluster.Spec.ControlPlaneEndpointProvider.KubeVIP.AddressFromPool = poolRef("IPPool", "vips")
+	g.Expect(v.ValidateCreate(goctx.Background(), cluster)).NotTo(Succeed())
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ippool
+
+import (
+	goctx "context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create,path=/validate-ippool-infrastructure-cluster-x-k8s-io-v1beta1-vspherecluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vsphereclusters,versions=v1beta1,name=ippool.vspherecluster.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereClusterValidator validates that the kind of the IPAM pool of the
+// kube-vip control plane endpoint of a VSphereCluster is served by the API
+// server. The pool is often created with the cluster, so it is not required
+// to exist yet.
+type VSphereClusterValidator struct {
+	poolValidator
+}
+
+var _ admission.CustomValidator = &VSphereClusterValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *VSphereClusterValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if v.RESTMapper == nil {
+		v.RESTMapper = mgr.GetRESTMapper()
+	}
+	mgr.GetWebhookServer().Register(
+		"/validate-ippool-infrastructure-cluster-x-k8s-io-v1beta1-vspherecluster",
+		admission.WithCustomValidator(&infrav1.VSphereCluster{}, v))
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *VSphereClusterValidator) ValidateCreate(_ goctx.Context, obj runtime.Object) error {
+	cluster, ok := obj.(*infrav1.VSphereCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereCluster but got a %T", obj))
+	}
+	provider := cluster.Spec.ControlPlaneEndpointProvider
+	if provider == nil || provider.KubeVIP == nil {
+		return nil
+	}
+	allErrs, err := v.validatePool(field.NewPath("spec", "controlPlaneEndpointProvider", "kubeVIP", "addressFromPool"), provider.KubeVIP.AddressFromPool)
+	if err != nil {
+		return err
+	}
+	return aggregateObjErrors(cluster, infrav1.GroupVersion.WithKind("VSphereCluster").GroupKind(), allErrs)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *VSphereClusterValidator) ValidateUpdate(_ goctx.Context, _, _ runtime.Object) error {
+	return nil
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *VSphereClusterValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ippool
+
+import (
+	goctx "context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-ippool-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachine,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vspheremachines,versions=v1beta1,name=ippool.vspheremachine.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereMachineValidator validates that the kinds of the IPAM pools of the
+// network devices of a VSphereMachine are served by the API server. The pools
+// are not required to exist, so that the machines can be created before their
+// pools, e.g. by clusterctl move; a missing pool is reported in the
+// IPAddressClaimed condition of the VSphereVM of the machine instead.
+type VSphereMachineValidator struct {
+	poolValidator
+}
+
+var _ admission.CustomValidator = &VSphereMachineValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *VSphereMachineValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if v.RESTMapper == nil {
+		v.RESTMapper = mgr.GetRESTMapper()
+	}
+	mgr.GetWebhookServer().Register(
+		"/validate-ippool-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachine",
+		admission.WithCustomValidator(&infrav1.VSphereMachine{}, v))
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *VSphereMachineValidator) ValidateCreate(_ goctx.Context, obj runtime.Object) error {
+	machine, ok := obj.(*infrav1.VSphereMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachine but got a %T", obj))
+	}
+	return v.validate(nil, machine)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *VSphereMachineValidator) ValidateUpdate(_ goctx.Context, oldObj, newObj runtime.Object) error {
+	oldMachine, ok := oldObj.(*infrav1.VSphereMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachine but got a %T", oldObj))
+	}
+	machine, ok := newObj.(*infrav1.VSphereMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachine but got a %T", newObj))
+	}
+	return v.validate(oldMachine, machine)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *VSphereMachineValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
+
+func (v *VSphereMachineValidator) validate(oldMachine, machine *infrav1.VSphereMachine) error {
+	var oldDevices []infrav1.NetworkDeviceSpec
+	if oldMachine != nil {
+		oldDevices = oldMachine.Spec.Network.Devices
+	}
+	allErrs, err := v.validateDevicePools(field.NewPath("spec", "network", "devices"), oldDevices, machine.Spec.Network.Devices)
+	if err != nil {
+		return err
+	}
+	return aggregateObjErrors(machine, infrav1.GroupVersion.WithKind("VSphereMachine").GroupKind(), allErrs)
+}
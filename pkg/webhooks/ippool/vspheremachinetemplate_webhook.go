@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ippool
+
+import (
+	goctx "context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create,path=/validate-ippool-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachinetemplate,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vspheremachinetemplates,versions=v1beta1,name=ippool.vspheremachinetemplate.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereMachineTemplateValidator validates that the kinds of the IPAM pools
+// of the network devices of a VSphereMachineTemplate are served by the API
+// server. The pools themselves are often created with the template, so they
+// are not required to exist yet. The spec of a template is immutable, so it
+// is only validated on creation.
+type VSphereMachineTemplateValidator struct {
+	poolValidator
+}
+
+var _ admission.CustomValidator = &VSphereMachineTemplateValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *VSphereMachineTemplateValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if v.RESTMapper == nil {
+		v.RESTMapper = mgr.GetRESTMapper()
+	}
+	mgr.GetWebhookServer().Register(
+		"/validate-ippool-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachinetemplate",
+		admission.WithCustomValidator(&infrav1.VSphereMachineTemplate{}, v))
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *VSphereMachineTemplateValidator) ValidateCreate(_ goctx.Context, obj runtime.Object) error {
+	template, ok := obj.(*infrav1.VSphereMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachineTemplate but got a %T", obj))
+	}
+	allErrs, err := v.validateDevicePools(field.NewPath("spec", "template", "spec", "network", "devices"), nil, template.Spec.Template.Spec.Network.Devices)
+	if err != nil {
+		return err
+	}
+	return aggregateObjErrors(template, infrav1.GroupVersion.WithKind("VSphereMachineTemplate").GroupKind(), allErrs)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *VSphereMachineTemplateValidator) ValidateUpdate(_ goctx.Context, _, _ runtime.Object) error {
+	return nil
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *VSphereMachineTemplateValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package namespacedefaults
+
+import (
+	goctx "context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+const (
+	// ServerAnnotation is set on a namespace to the vSphere server of the
+	// VSphereClusters created in the namespace without a server.
+	ServerAnnotation = "defaults.vsphere.infrastructure.cluster.x-k8s.io/server"
+
+	// ThumbprintAnnotation is set on a namespace to the thumbprint of the
+	// certificate of the server of ServerAnnotation. It is only used for the
+	// objects of this server.
+	ThumbprintAnnotation = "defaults.vsphere.infrastructure.cluster.x-k8s.io/thumbprint"
+
+	// TemplateAnnotation is set on a namespace to the template of the
+	// VSphereMachines and VSphereMachineTemplates created in the namespace
+	// without a template or an image.
+	TemplateAnnotation = "defaults.vsphere.infrastructure.cluster.x-k8s.io/template"
+)
+
+// namespaceDefaults are the defaults read from the annotations of a
+// namespace.
+type namespaceDefaults struct {
+	Server     string
+	Thumbprint string
+	Template   string
+}
+
+// getNamespaceDefaults returns the defaults of a namespace. A namespace which
+// does not exist has no defaults.
+func getNamespaceDefaults(ctx goctx.Context, c client.Reader, namespace string) (namespaceDefaults, error) {
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return namespaceDefaults{}, nil
+		}
+		return namespaceDefaults{}, apierrors.NewInternalError(errors.Wrapf(err, "failed to get namespace %s", namespace))
+	}
+	annotations := ns.GetAnnotations()
+	return namespaceDefaults{
+		Server:     annotations[ServerAnnotation],
+		Thumbprint: annotations[ThumbprintAnnotation],
+		Template:   annotations[TemplateAnnotation],
+	}, nil
+}
+
+// defaultThumbprint sets the thumbprint of a server if it is the server of
+// the defaults, so that a thumbprint is never paired with another server.
+func (d namespaceDefaults) defaultThumbprint(server string, thumbprint *string) {
+	if *thumbprint == "" && server != "" && server == d.Server {
+		*thumbprint = d.Thumbprint
+	}
+}
+
+// defaultMachineSpec sets the template and the thumbprint of a machine. The
+// server is not defaulted, since a machine without a server uses the server
+// of its cluster.
+func (d namespaceDefaults) defaultMachineSpec(spec *infrav1.VSphereMachineSpec) {
+	if spec.Template == "" && spec.ImageRef == nil {
+		spec.Template = d.Template
+	}
+	d.defaultThumbprint(spec.Server, &spec.Thumbprint)
+}
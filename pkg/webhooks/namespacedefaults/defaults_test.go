@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package namespacedefaults
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func newFakeClient(g *WithT) client.Client {
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a",
+			Annotations: map[string]string{
+				ServerAnnotation:     "vcenter.example.com",
+				ThumbprintAnnotation: "AA:BB",
+				TemplateAnnotation:   "ubuntu-2004-kube-v1.23.5",
+			},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+	).Build()
+}
+
+func TestVSphereClusterDefaulter(t *testing.T) {
+	tests := []struct {
+		name           string
+		cluster        *infrav1.VSphereCluster
+		wantServer     string
+		wantThumbprint string
+	}{
+		{
+			name:           "cluster without a server",
+			cluster:        &infrav1.VSphereCluster{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}},
+			wantServer:     "vcenter.example.com",
+			wantThumbprint: "AA:BB",
+		},
+		{
+			name: "cluster with the default server",
+			cluster: &infrav1.VSphereCluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"},
+				Spec:       infrav1.VSphereClusterSpec{Server: "vcenter.example.com"},
+			},
+			wantServer:     "vcenter.example.com",
+			wantThumbprint: "AA:BB",
+		},
+		{
+			name: "cluster with another server",
+			cluster: &infrav1.VSphereCluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"},
+				Spec:       infrav1.VSphereClusterSpec{Server: "other.example.com"},
+			},
+			wantServer: "other.example.com",
+		},
+		{
+			name: "cluster with a thumbprint",
+			cluster: &infrav1.VSphereCluster{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"},
+				Spec:       infrav1.VSphereClusterSpec{Thumbprint: "CC:DD"},
+			},
+			wantServer:     "vcenter.example.com",
+			wantThumbprint: "CC:DD",
+		},
+		{
+			name:    "namespace without defaults",
+			cluster: &infrav1.VSphereCluster{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b"}},
+		},
+		{
+			name:    "namespace not found",
+			cluster: &infrav1.VSphereCluster{ObjectMeta: metav1.ObjectMeta{Namespace: "team-c"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			d := &VSphereClusterDefaulter{Client: newFakeClient(g)}
+			g.Expect(d.Default(goctx.Background(), tt.cluster)).To(Succeed())
+			g.Expect(tt.cluster.Spec.Server).To(Equal(tt.wantServer))
+			g.Expect(tt.cluster.Spec.Thumbprint).To(Equal(tt.wantThumbprint))
+		})
+	}
+}
+
+func TestVSphereMachineDefaulter(t *testing.T) {
+	g := NewWithT(t)
+	d := &VSphereMachineDefaulter{Client: newFakeClient(g)}
+
+	machine := &infrav1.VSphereMachine{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}}
+	g.Expect(d.Default(goctx.Background(), machine)).To(Succeed())
+	g.Expect(machine.Spec.Template).To(Equal("ubuntu-2004-kube-v1.23.5"))
+	g.Expect(machine.Spec.Server).To(BeEmpty())
+	g.Expect(machine.Spec.Thumbprint).To(BeEmpty())
+
+	machine = &infrav1.VSphereMachine{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}}
+	machine.Spec.Server = "vcenter.example.com"
+	machine.Spec.ImageRef = &corev1.LocalObjectReference{Name: "ubuntu"}
+	g.Expect(d.Default(goctx.Background(), machine)).To(Succeed())
+	g.Expect(machine.Spec.Template).To(BeEmpty())
+	g.Expect(machine.Spec.Thumbprint).To(Equal("AA:BB"))
+}
+
+func TestVSphereMachineTemplateDefaulter(t *testing.T) {
+	g := NewWithT(t)
+	d := &VSphereMachineTemplateDefaulter{Client: newFakeClient(g)}
+
+	template := &infrav1.VSphereMachineTemplate{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}}
+	template.Spec.Template.Spec.Template = "windows-2019"
+	g.Expect(d.Default(goctx.Background(), template)).To(Succeed())
+	g.Expect(template.Spec.Template.Spec.Template).To(Equal("windows-2019"))
+
+	template = &infrav1.VSphereMachineTemplate{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}}
+	g.Expect(d.Default(goctx.Background(), template)).To(Succeed())
+	g.Expect(template.Spec.Template.Spec.Template).To(Equal("ubuntu-2004-kube-v1.23.5"))
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package namespacedefaults
+
+import (
+	goctx "context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create,path=/mutate-namespacedefaults-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachinetemplate,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vspheremachinetemplates,versions=v1beta1,name=namespacedefaults.vspheremachinetemplate.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereMachineTemplateDefaulter sets the template and the thumbprint of the
+// VSphereMachineTemplates created without them to the defaults of their
+// namespace. The spec of a template is immutable, so it is only defaulted on
+// creation.
+type VSphereMachineTemplateDefaulter struct {
+	Client client.Reader
+}
+
+var _ admission.CustomDefaulter = &VSphereMachineTemplateDefaulter{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (d *VSphereMachineTemplateDefaulter) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if d.Client == nil {
+		d.Client = mgr.GetAPIReader()
+	}
+	mgr.GetWebhookServer().Register(
+		"/mutate-namespacedefaults-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachinetemplate",
+		admission.WithCustomDefaulter(&infrav1.VSphereMachineTemplate{}, d))
+	return nil
+}
+
+// Default implements admission.CustomDefaulter.
+func (d *VSphereMachineTemplateDefaulter) Default(ctx goctx.Context, obj runtime.Object) error {
+	template, ok := obj.(*infrav1.VSphereMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachineTemplate but got a %T", obj))
+	}
+	defaults, err := getNamespaceDefaults(ctx, d.Client, template.Namespace)
+	if err != nil {
+		return err
+	}
+	defaults.defaultMachineSpec(&template.Spec.Template.Spec)
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package permission
+
+import (
+	goctx "context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/privileges"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-permission-infrastructure-cluster-x-k8s-io-v1beta1-vspherecluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vsphereclusters,versions=v1beta1,name=permission.vspherecluster.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereClusterValidator rejects a VSphereCluster whose permission grants
+// privileges which are neither the privileges CAPV requires on the VMs and the
+// resource pools nor in AllowedPrivileges. The role of the permission is
+// created with the credentials of CAPV, so any privilege CAPV holds could
+// otherwise be granted to the principal of the permission.
+type VSphereClusterValidator struct {
+	AllowedPrivileges []string
+}
+
+var _ admission.CustomValidator = &VSphereClusterValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *VSphereClusterValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(
+		"/validate-permission-infrastructure-cluster-x-k8s-io-v1beta1-vspherecluster",
+		admission.WithCustomValidator(&infrav1.VSphereCluster{}, v))
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *VSphereClusterValidator) ValidateCreate(_ goctx.Context, obj runtime.Object) error {
+	cluster, ok := obj.(*infrav1.VSphereCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereCluster but got a %T", obj))
+	}
+	return v.validate(nil, cluster)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *VSphereClusterValidator) ValidateUpdate(_ goctx.Context, oldObj, newObj runtime.Object) error {
+	oldCluster, ok := oldObj.(*infrav1.VSphereCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereCluster but got a %T", oldObj))
+	}
+	cluster, ok := newObj.(*infrav1.VSphereCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereCluster but got a %T", newObj))
+	}
+	return v.validate(oldCluster, cluster)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *VSphereClusterValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
+
+func (v *VSphereClusterValidator) validate(oldCluster, cluster *infrav1.VSphereCluster) error {
+	privilegeIDs := permissionPrivileges(cluster)
+	// The privileges admitted before the allow-list was changed are left
+	// to the controller, so that the other fields can still be updated.
+	if oldCluster != nil && reflect.DeepEqual(permissionPrivileges(oldCluster), privilegeIDs) {
+		return nil
+	}
+	notAllowed := privileges.NotAllowedForPermission(privilegeIDs, v.AllowedPrivileges)
+	if len(notAllowed) == 0 {
+		return nil
+	}
+	allErrs := field.ErrorList{field.Forbidden(
+		field.NewPath("spec", "placement", "permission", "privileges"),
+		fmt.Sprintf("privileges %s are not allowed for the permission of a cluster", strings.Join(notAllowed, ", ")))}
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind("VSphereCluster").GroupKind(), cluster.Name, allErrs)
+}
+
+func permissionPrivileges(cluster *infrav1.VSphereCluster) []string {
+	if cluster.Spec.Placement == nil || cluster.Spec.Placement.Permission == nil {
+		return nil
+	}
+	return cluster.Spec.Placement.Permission.Privileges
+}
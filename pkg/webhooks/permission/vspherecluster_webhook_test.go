@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package permission
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func TestVSphereClusterValidator(t *testing.T) {
+	newCluster := func(privilegeIDs ...string) *infrav1.VSphereCluster {
+		return &infrav1.VSphereCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"},
+			Spec: infrav1.VSphereClusterSpec{
+				Placement: &infrav1.ClusterPlacementSpec{
+					Permission: &infrav1.ClusterPermissionSpec{
+						Principal:  `VSPHERE.LOCAL\capv-team-a`,
+						Privileges: privilegeIDs,
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		cluster *infrav1.VSphereCluster
+		wantErr bool
+	}{
+		{
+			name:    "no placement",
+			cluster: &infrav1.VSphereCluster{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cluster"}},
+		},
+		{
+			name:    "default privileges",
+			cluster: newCluster(),
+		},
+		{
+			name:    "privileges required by CAPV",
+			cluster: newCluster("VirtualMachine.Interact.PowerOn", "Resource.AssignVMToPool"),
+		},
+		{
+			name:    "privilege not allowed",
+			cluster: newCluster("VirtualMachine.Interact.PowerOn", "Authorization.ModifyPermissions"),
+			wantErr: true,
+		},
+		{
+			name:    "privilege allowed by the operator",
+			allowed: []string{"VirtualMachine.Interact.ConsoleInteract"},
+			cluster: newCluster("VirtualMachine.Interact.ConsoleInteract"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			validator := &VSphereClusterValidator{AllowedPrivileges: tc.allowed}
+			err := validator.ValidateCreate(goctx.TODO(), tc.cluster)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+
+	t.Run("unchanged privileges are not validated on update", func(t *testing.T) {
+		g := NewWithT(t)
+		validator := &VSphereClusterValidator{}
+		oldCluster := newCluster("Authorization.ModifyPermissions")
+		cluster := oldCluster.DeepCopy()
+		cluster.Spec.Server = "vcenter.example.com"
+		g.Expect(validator.ValidateUpdate(goctx.TODO(), oldCluster, cluster)).To(Succeed())
+
+		cluster.Spec.Placement.Permission.Privileges = append(cluster.Spec.Placement.Permission.Privileges, "Global.Settings")
+		g.Expect(validator.ValidateUpdate(goctx.TODO(), oldCluster, cluster)).NotTo(Succeed())
+	})
+}
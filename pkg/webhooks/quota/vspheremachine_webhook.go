@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	goctx "context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/quota"
+)
+
+// +kubebuilder:webhook:verbs=create,path=/validate-quota-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachine,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=vspheremachines,versions=v1beta1,name=quota.vspheremachine.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereMachineValidator rejects the creation of a VSphereMachine whose
+// resources would exceed a VSphereResourceQuota of its namespace, once added
+// to the resources of the other VSphereMachines of the namespace. The clone
+// spec of a VSphereMachine is immutable, so its updates are not validated.
+type VSphereMachineValidator struct {
+	Client client.Reader
+}
+
+var _ admission.CustomValidator = &VSphereMachineValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *VSphereMachineValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if v.Client == nil {
+		v.Client = mgr.GetClient()
+	}
+	mgr.GetWebhookServer().Register(
+		"/validate-quota-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachine",
+		admission.WithCustomValidator(&infrav1.VSphereMachine{}, v))
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *VSphereMachineValidator) ValidateCreate(ctx goctx.Context, obj runtime.Object) error {
+	machine, ok := obj.(*infrav1.VSphereMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachine but got a %T", obj))
+	}
+
+	quotas := &infrav1.VSphereResourceQuotaList{}
+	if err := v.Client.List(ctx, quotas, client.InNamespace(machine.Namespace)); err != nil {
+		return apierrors.NewInternalError(errors.Wrapf(err, "failed to list the VSphereResourceQuotas of namespace %s", machine.Namespace))
+	}
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+
+	machines := &infrav1.VSphereMachineList{}
+	if err := v.Client.List(ctx, machines, client.InNamespace(machine.Namespace)); err != nil {
+		return apierrors.NewInternalError(errors.Wrapf(err, "failed to list the VSphereMachines of namespace %s", machine.Namespace))
+	}
+	others := make([]infrav1.VSphereMachine, 0, len(machines.Items))
+	for _, other := range machines.Items {
+		if other.Name != machine.Name {
+			others = append(others, other)
+		}
+	}
+	used := quota.Usage(others)
+	requested := quota.Requests(machine.Spec.VirtualMachineCloneSpec)
+
+	gr := infrav1.GroupVersion.WithResource("vspheremachines").GroupResource()
+	for _, q := range quotas.Items {
+		hard := q.Spec.Hard
+		if unrequested := quota.Unrequested(hard, machine.Spec.VirtualMachineCloneSpec); len(unrequested) > 0 {
+			return apierrors.NewForbidden(gr, machine.Name, errors.Errorf("must set %s, limited by VSphereResourceQuota %s", strings.Join(unrequested, ", "), q.Name))
+		}
+		if exceeded := quota.Exceeded(hard, quota.Add(used, requested)); exceeded != "" {
+			return apierrors.NewForbidden(gr, machine.Name, errors.Errorf("exceeded VSphereResourceQuota %s: %s", q.Name, exceeded))
+		}
+	}
+	return nil
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *VSphereMachineValidator) ValidateUpdate(_ goctx.Context, _, _ runtime.Object) error {
+	return nil
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *VSphereMachineValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+func newMachine(namespace, name string, numCPUs int32, memoryMiB int64) *infrav1.VSphereMachine {
+	machine := &infrav1.VSphereMachine{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	machine.Spec.NumCPUs, machine.Spec.MemoryMiB, machine.Spec.DiskGiB = numCPUs, memoryMiB, 20
+	return machine
+}
+
+func newResourceQuota(namespace, name string, hard infrav1.VSphereResources) *infrav1.VSphereResourceQuota {
+	return &infrav1.VSphereResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       infrav1.VSphereResourceQuotaSpec{Hard: hard},
+	}
+}
+
+func TestVSphereMachineValidator_ValidateCreate(t *testing.T) {
+	objects := []client.Object{
+		newResourceQuota("team-a", "compute", infrav1.VSphereResources{NumCPUs: pointer.Int64(8), MemoryMiB: pointer.Int64(16384)}),
+		newResourceQuota("team-a", "storage", infrav1.VSphereResources{DiskGiB: pointer.Int64(100)}),
+		newMachine("team-a", "existing-0", 2, 4096),
+		newMachine("team-a", "existing-1", 2, 4096),
+		newMachine("team-b", "existing", 16, 65536),
+	}
+
+	tests := []struct {
+		name    string
+		machine *infrav1.VSphereMachine
+		wantErr string
+	}{
+		{
+			name:    "within the quotas",
+			machine: newMachine("team-a", "machine", 4, 8192),
+		},
+		{
+			name:    "exceeding a quota",
+			machine: newMachine("team-a", "machine", 6, 8192),
+			wantErr: "exceeded VSphereResourceQuota compute: numCPUs: 10 > 8",
+		},
+		{
+			name: "exceeding the disk quota",
+			machine: func() *infrav1.VSphereMachine {
+				machine := newMachine("team-a", "machine", 2, 4096)
+				machine.Spec.AdditionalDisksGiB = []int32{50}
+				return machine
+			}(),
+			wantErr: "exceeded VSphereResourceQuota storage: diskGiB: 110 > 100",
+		},
+		{
+			name:    "not requesting a limited resource",
+			machine: newMachine("team-a", "machine", 0, 4096),
+			wantErr: "must set numCPUs, limited by VSphereResourceQuota compute",
+		},
+		{
+			name:    "in a namespace without quota",
+			machine: newMachine("team-c", "machine", 64, 0),
+		},
+		{
+			name:    "retrying the creation of a machine",
+			machine: newMachine("team-a", "existing-1", 6, 4096),
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	validator := &VSphereMachineValidator{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := validator.ValidateCreate(goctx.Background(), tt.machine)
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vmware
+
+import (
+	goctx "context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-vmware-infrastructure-cluster-x-k8s-io-v1beta1-providerserviceaccount,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=vmware.infrastructure.cluster.x-k8s.io,resources=providerserviceaccounts,versions=v1beta1,name=validation.providerserviceaccount.vmware.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// ProviderServiceAccountValidator validates that a ProviderServiceAccount
+// selects its clusters with either a ref or a cluster selector.
+type ProviderServiceAccountValidator struct{}
+
+var _ admission.CustomValidator = &ProviderServiceAccountValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *ProviderServiceAccountValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&vmwarev1.ProviderServiceAccount{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *ProviderServiceAccountValidator) ValidateCreate(_ goctx.Context, obj runtime.Object) error {
+	psa, ok := obj.(*vmwarev1.ProviderServiceAccount)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a ProviderServiceAccount but got a %T", obj))
+	}
+	return aggregateObjErrors(psa, validateProviderServiceAccountSpec(field.NewPath("spec"), &psa.Spec))
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *ProviderServiceAccountValidator) ValidateUpdate(_ goctx.Context, _, newObj runtime.Object) error {
+	psa, ok := newObj.(*vmwarev1.ProviderServiceAccount)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a ProviderServiceAccount but got a %T", newObj))
+	}
+	return aggregateObjErrors(psa, validateProviderServiceAccountSpec(field.NewPath("spec"), &psa.Spec))
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *ProviderServiceAccountValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
+
+func validateProviderServiceAccountSpec(fldPath *field.Path, spec *vmwarev1.ProviderServiceAccountSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	switch {
+	case spec.Ref == nil && spec.ClusterSelector == nil:
+		allErrs = append(allErrs, field.Required(fldPath.Child("ref"), "either ref or clusterSelector must be set"))
+	case spec.Ref != nil && spec.ClusterSelector != nil:
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("clusterSelector"), "cannot be set with ref"))
+	case spec.ClusterSelector != nil:
+		if _, err := metav1.LabelSelectorAsSelector(spec.ClusterSelector); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("clusterSelector"), spec.ClusterSelector, err.Error()))
+		}
+	}
+	return allErrs
+}
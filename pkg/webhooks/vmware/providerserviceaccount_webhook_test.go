@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vmware
+
+import (
+	goctx "context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+)
+
+func TestProviderServiceAccountValidator(t *testing.T) {
+	validator := &ProviderServiceAccountValidator{}
+
+	newPSA := func(ref *corev1.ObjectReference, selector *metav1.LabelSelector) *vmwarev1.ProviderServiceAccount {
+		return &vmwarev1.ProviderServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "psa"},
+			Spec: vmwarev1.ProviderServiceAccountSpec{
+				Ref:              ref,
+				ClusterSelector:  selector,
+				TargetNamespace:  "target",
+				TargetSecretName: "secret",
+			},
+		}
+	}
+	ref := &corev1.ObjectReference{Name: "cluster"}
+
+	tests := []struct {
+		name    string
+		psa     *vmwarev1.ProviderServiceAccount
+		wantErr bool
+	}{
+		{
+			name: "ref",
+			psa:  newPSA(ref, nil),
+		},
+		{
+			name: "cluster selector",
+			psa:  newPSA(nil, &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}),
+		},
+		{
+			name: "empty cluster selector selecting every cluster",
+			psa:  newPSA(nil, &metav1.LabelSelector{}),
+		},
+		{
+			name:    "neither ref nor cluster selector",
+			psa:     newPSA(nil, nil),
+			wantErr: true,
+		},
+		{
+			name:    "both ref and cluster selector",
+			psa:     newPSA(ref, &metav1.LabelSelector{}),
+			wantErr: true,
+		},
+		{
+			name: "invalid cluster selector",
+			psa: newPSA(nil, &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "env", Operator: "Unknown", Values: []string{"prod"}},
+			}}),
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := validator.ValidateCreate(goctx.TODO(), tc.psa)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			err = validator.ValidateUpdate(goctx.TODO(), newPSA(ref, nil), tc.psa)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
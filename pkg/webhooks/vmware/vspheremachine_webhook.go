@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vmware
+
+import (
+	goctx "context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	vmoprv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-vmware-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachine,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=vmware.infrastructure.cluster.x-k8s.io,resources=vspheremachines,versions=v1beta1,name=validation.vspheremachine.vmware.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereMachineValidator validates that the VirtualMachineClass and the
+// VirtualMachineImage referenced by a VSphereMachine can be used in its
+// namespace.
+type VSphereMachineValidator struct {
+	Client client.Reader
+}
+
+var _ admission.CustomValidator = &VSphereMachineValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *VSphereMachineValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if v.Client == nil {
+		v.Client = mgr.GetAPIReader()
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&vmwarev1.VSphereMachine{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *VSphereMachineValidator) ValidateCreate(ctx goctx.Context, obj runtime.Object) error {
+	machine, ok := obj.(*vmwarev1.VSphereMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachine but got a %T", obj))
+	}
+	allErrs, err := validateVMOperatorRefs(ctx, v.Client, machine.Namespace, field.NewPath("spec"), nil, &machine.Spec)
+	if err != nil {
+		return err
+	}
+	return aggregateObjErrors(machine, allErrs)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *VSphereMachineValidator) ValidateUpdate(ctx goctx.Context, oldObj, newObj runtime.Object) error {
+	oldMachine, ok := oldObj.(*vmwarev1.VSphereMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachine but got a %T", oldObj))
+	}
+	machine, ok := newObj.(*vmwarev1.VSphereMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachine but got a %T", newObj))
+	}
+	allErrs, err := validateVMOperatorRefs(ctx, v.Client, machine.Namespace, field.NewPath("spec"), &oldMachine.Spec, &machine.Spec)
+	if err != nil {
+		return err
+	}
+	return aggregateObjErrors(machine, allErrs)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *VSphereMachineValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
+
+// validateVMOperatorRefs validates that the VirtualMachineClass of a machine
+// is bound to its namespace, and is not being unbound from it, and that its
+// VirtualMachineImage exists and is supported. On update, only the references
+// which changed are validated, so machines are not blocked once a class is
+// unbound or an image is removed. Nothing is validated when vm-operator is
+// not installed.
+func validateVMOperatorRefs(ctx goctx.Context, c client.Reader, namespace string, fldPath *field.Path, oldSpec, spec *vmwarev1.VSphereMachineSpec) (field.ErrorList, error) {
+	var allErrs field.ErrorList
+
+	if oldSpec == nil || oldSpec.ClassName != spec.ClassName {
+		classErrs, err := validateVMClass(ctx, c, namespace, fldPath.Child("className"), spec.ClassName)
+		if err != nil {
+			return nil, err
+		}
+		allErrs = append(allErrs, classErrs...)
+	}
+
+	if oldSpec == nil || oldSpec.ImageName != spec.ImageName {
+		imageErrs, err := validateVMImage(ctx, c, fldPath.Child("imageName"), spec.ImageName)
+		if err != nil {
+			return nil, err
+		}
+		allErrs = append(allErrs, imageErrs...)
+	}
+
+	return allErrs, nil
+}
+
+func validateVMClass(ctx goctx.Context, c client.Reader, namespace string, fldPath *field.Path, className string) (field.ErrorList, error) {
+	if className == "" {
+		return field.ErrorList{field.Required(fldPath, "must be set")}, nil
+	}
+
+	vmClass := &vmoprv1.VirtualMachineClass{}
+	if err := c.Get(ctx, types.NamespacedName{Name: className}, vmClass); err != nil {
+		switch {
+		case meta.IsNoMatchError(err):
+			return nil, nil
+		case apierrors.IsNotFound(err):
+			return field.ErrorList{field.NotFound(fldPath, className)}, nil
+		default:
+			return nil, apierrors.NewInternalError(errors.Wrapf(err, "failed to get VirtualMachineClass %s", className))
+		}
+	}
+
+	bindings := &vmoprv1.VirtualMachineClassBindingList{}
+	if err := c.List(ctx, bindings, client.InNamespace(namespace)); err != nil {
+		return nil, apierrors.NewInternalError(errors.Wrapf(err, "failed to list VirtualMachineClassBindings in namespace %s", namespace))
+	}
+	for _, binding := range bindings.Items {
+		if binding.ClassRef.Name == className && binding.DeletionTimestamp.IsZero() {
+			return nil, nil
+		}
+	}
+	return field.ErrorList{field.Invalid(fldPath, className, fmt.Sprintf("VirtualMachineClass is not bound to namespace %s", namespace))}, nil
+}
+
+func validateVMImage(ctx goctx.Context, c client.Reader, fldPath *field.Path, imageName string) (field.ErrorList, error) {
+	if imageName == "" {
+		return field.ErrorList{field.Required(fldPath, "must be set")}, nil
+	}
+
+	vmImage := &vmoprv1.VirtualMachineImage{}
+	if err := c.Get(ctx, types.NamespacedName{Name: imageName}, vmImage); err != nil {
+		switch {
+		case meta.IsNoMatchError(err):
+			return nil, nil
+		case apierrors.IsNotFound(err):
+			return field.ErrorList{field.NotFound(fldPath, imageName)}, nil
+		default:
+			return nil, apierrors.NewInternalError(errors.Wrapf(err, "failed to get VirtualMachineImage %s", imageName))
+		}
+	}
+	if vmImage.Status.ImageSupported != nil && !*vmImage.Status.ImageSupported {
+		return field.ErrorList{field.Invalid(fldPath, imageName, "VirtualMachineImage is not supported by vm-operator")}, nil
+	}
+	return nil, nil
+}
+
+func aggregateObjErrors(obj client.Object, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(obj.GetObjectKind().GroupVersionKind().GroupKind(), obj.GetName(), allErrs)
+}
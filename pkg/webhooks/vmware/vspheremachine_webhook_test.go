@@ -0,0 +1,142 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vmware
+
+import (
+	goctx "context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	vmoprv1 "github.com/vmware-tanzu/vm-operator-api/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+)
+
+func TestVSphereMachineValidator(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(vmoprv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(vmwarev1.AddToScheme(scheme)).To(Succeed())
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&vmoprv1.VirtualMachineClass{ObjectMeta: metav1.ObjectMeta{Name: "best-effort-small"}},
+		&vmoprv1.VirtualMachineClass{ObjectMeta: metav1.ObjectMeta{Name: "guaranteed-large"}},
+		&vmoprv1.VirtualMachineClassBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "best-effort-small"},
+			ClassRef:   vmoprv1.ClassReference{Kind: "VirtualMachineClass", Name: "best-effort-small"},
+		},
+		&vmoprv1.VirtualMachineClass{ObjectMeta: metav1.ObjectMeta{Name: "best-effort-medium"}},
+		&vmoprv1.VirtualMachineClassBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "ns",
+				Name:              "best-effort-medium",
+				DeletionTimestamp: &metav1.Time{Time: time.Now()},
+				Finalizers:        []string{"vmoperator.vmware.com/virtualmachineclassbinding"},
+			},
+			ClassRef: vmoprv1.ClassReference{Kind: "VirtualMachineClass", Name: "best-effort-medium"},
+		},
+		&vmoprv1.VirtualMachineImage{ObjectMeta: metav1.ObjectMeta{Name: "ubuntu"}},
+		&vmoprv1.VirtualMachineImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "unsupported"},
+			Status:     vmoprv1.VirtualMachineImageStatus{ImageSupported: pointer.Bool(false)},
+		},
+	).Build()
+	validator := &VSphereMachineValidator{Client: c}
+
+	newMachine := func(className, imageName string) *vmwarev1.VSphereMachine {
+		return &vmwarev1.VSphereMachine{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "machine"},
+			Spec:       vmwarev1.VSphereMachineSpec{ClassName: className, ImageName: imageName},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		machine *vmwarev1.VSphereMachine
+		wantErr bool
+	}{
+		{
+			name:    "bound class and supported image",
+			machine: newMachine("best-effort-small", "ubuntu"),
+		},
+		{
+			name:    "missing class",
+			machine: newMachine("missing", "ubuntu"),
+			wantErr: true,
+		},
+		{
+			name:    "class not bound to the namespace",
+			machine: newMachine("guaranteed-large", "ubuntu"),
+			wantErr: true,
+		},
+		{
+			name:    "class being unbound from the namespace",
+			machine: newMachine("best-effort-medium", "ubuntu"),
+			wantErr: true,
+		},
+		{
+			name:    "missing image",
+			machine: newMachine("best-effort-small", "missing"),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported image",
+			machine: newMachine("best-effort-small", "unsupported"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := validator.ValidateCreate(goctx.TODO(), tc.machine)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+
+	t.Run("unchanged references are not validated on update", func(t *testing.T) {
+		g := NewWithT(t)
+		oldMachine := newMachine("guaranteed-large", "missing")
+		machine := oldMachine.DeepCopy()
+		machine.Spec.ProviderID = pointer.String("vsphere://42305f0b-dad7-1d3d-5727-0eaffffffffc")
+		g.Expect(validator.ValidateUpdate(goctx.TODO(), oldMachine, machine)).To(Succeed())
+
+		machine.Spec.ImageName = "unsupported"
+		g.Expect(validator.ValidateUpdate(goctx.TODO(), oldMachine, machine)).NotTo(Succeed())
+	})
+
+	t.Run("templates are validated", func(t *testing.T) {
+		g := NewWithT(t)
+		templateValidator := &VSphereMachineTemplateValidator{Client: c}
+		template := &vmwarev1.VSphereMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "template"},
+		}
+		template.Spec.Template.Spec = newMachine("best-effort-small", "ubuntu").Spec
+		g.Expect(templateValidator.ValidateCreate(goctx.TODO(), template)).To(Succeed())
+
+		template.Spec.Template.Spec.ClassName = "guaranteed-large"
+		g.Expect(templateValidator.ValidateCreate(goctx.TODO(), template)).NotTo(Succeed())
+	})
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vmware
+
+import (
+	goctx "context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	vmwarev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/vmware/v1beta1"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-vmware-infrastructure-cluster-x-k8s-io-v1beta1-vspheremachinetemplate,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=vmware.infrastructure.cluster.x-k8s.io,resources=vspheremachinetemplates,versions=v1beta1,name=validation.vspheremachinetemplate.vmware.infrastructure.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+// VSphereMachineTemplateValidator validates that the VirtualMachineClass and
+// the VirtualMachineImage referenced by a VSphereMachineTemplate can be used
+// in its namespace.
+type VSphereMachineTemplateValidator struct {
+	Client client.Reader
+}
+
+var _ admission.CustomValidator = &VSphereMachineTemplateValidator{}
+
+// SetupWebhookWithManager registers the webhook with the manager.
+func (v *VSphereMachineTemplateValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if v.Client == nil {
+		v.Client = mgr.GetAPIReader()
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&vmwarev1.VSphereMachineTemplate{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *VSphereMachineTemplateValidator) ValidateCreate(ctx goctx.Context, obj runtime.Object) error {
+	template, ok := obj.(*vmwarev1.VSphereMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachineTemplate but got a %T", obj))
+	}
+	allErrs, err := validateVMOperatorRefs(ctx, v.Client, template.Namespace, field.NewPath("spec", "template", "spec"), nil, &template.Spec.Template.Spec)
+	if err != nil {
+		return err
+	}
+	return aggregateObjErrors(template, allErrs)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *VSphereMachineTemplateValidator) ValidateUpdate(ctx goctx.Context, oldObj, newObj runtime.Object) error {
+	oldTemplate, ok := oldObj.(*vmwarev1.VSphereMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachineTemplate but got a %T", oldObj))
+	}
+	template, ok := newObj.(*vmwarev1.VSphereMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a VSphereMachineTemplate but got a %T", newObj))
+	}
+	allErrs, err := validateVMOperatorRefs(ctx, v.Client, template.Namespace, field.NewPath("spec", "template", "spec"), &oldTemplate.Spec.Template.Spec, &template.Spec.Template.Spec)
+	if err != nil {
+		return err
+	}
+	return aggregateObjErrors(template, allErrs)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *VSphereMachineTemplateValidator) ValidateDelete(_ goctx.Context, _ runtime.Object) error {
+	return nil
+}
@@ -53,6 +53,8 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/manager"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/webhooks/ippool"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/webhooks/namespacedefaults"
 )
 
 func init() {
@@ -146,6 +148,10 @@ func NewTestEnvironment() *TestEnvironment {
 		Password:   simr.Password(),
 	}
 	managerOpts.AddToManager = func(ctx *context.ControllerManagerContext, mgr ctrlmgr.Manager) error {
+		if err := (&infrav1.VSphereCluster{}).SetupWebhookWithManager(mgr); err != nil {
+			return err
+		}
+
 		if err := (&infrav1.VSphereMachine{}).SetupWebhookWithManager(mgr); err != nil {
 			return err
 		}
@@ -181,6 +187,26 @@ func NewTestEnvironment() *TestEnvironment {
 			return err
 		}
 
+		if err := (&namespacedefaults.VSphereClusterDefaulter{}).SetupWebhookWithManager(mgr); err != nil {
+			return err
+		}
+		if err := (&namespacedefaults.VSphereMachineDefaulter{}).SetupWebhookWithManager(mgr); err != nil {
+			return err
+		}
+		if err := (&namespacedefaults.VSphereMachineTemplateDefaulter{}).SetupWebhookWithManager(mgr); err != nil {
+			return err
+		}
+
+		if err := (&ippool.VSphereClusterValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			return err
+		}
+		if err := (&ippool.VSphereMachineValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			return err
+		}
+		if err := (&ippool.VSphereMachineTemplateValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			return err
+		}
+
 		return nil
 	}
 